@@ -3,21 +3,43 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strings"
 
+	"github.com/malphas-lang/malphas-lang/internal/diag"
 	"github.com/malphas-lang/malphas-lang/internal/haruspex/analysis"
 	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
 	"github.com/malphas-lang/malphas-lang/internal/haruspex/liveir"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/project"
 	"github.com/malphas-lang/malphas-lang/internal/haruspex/server"
 	"github.com/malphas-lang/malphas-lang/internal/parser"
 	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
+var (
+	// output writes the diagnostics at the end of a run
+	output = diagnostics.Output{Format: diagnostics.FormatText}
+	// progress receives everything else, which only the text format shows
+	progress io.Writer = os.Stdout
+)
+
 func main() {
 	lspMode := flag.Bool("lsp", false, "Run in LSP mode for editor integration")
+	format := flag.String("format", "text", "Diagnostic output format: text, json or sarif")
 	flag.Parse()
 
+	f, err := diagnostics.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	output.Format = f
+	if f != diagnostics.FormatText {
+		progress = io.Discard
+	}
+
 	if *lspMode {
 		runLSP()
 	} else {
@@ -36,26 +58,31 @@ func runLSP() {
 
 func runCLI() {
 	if len(flag.Args()) < 1 {
-		fmt.Println("Usage: malphas-haruspex <file.mal> or malphas-haruspex --lsp")
+		fmt.Println("Usage: malphas-haruspex [--format=text|json|sarif] <file.mal|dir> or malphas-haruspex --lsp")
 		os.Exit(1)
 	}
 
 	filename := flag.Arg(0)
+	if info, err := os.Stat(filename); err == nil && info.IsDir() {
+		runProject(filename)
+		return
+	}
+
 	content, err := os.ReadFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to read file: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Analyzing %s...\n", filename)
+	fmt.Fprintf(progress, "Analyzing %s...\n", filename)
+	reporter := diagnostics.NewReporter()
+	defer writeDiagnostics(reporter)
 
 	// 1. Parse
 	p := parser.New(string(content), parser.WithFilename(filename))
 	file := p.ParseFile()
 
-	for _, err := range p.Errors() {
-		fmt.Printf("Parse Error: %s at %v\n", err.Message, err.Span)
-	}
+	reportParseErrors(reporter, p.Errors())
 
 	if file == nil {
 		return
@@ -63,30 +90,31 @@ func runCLI() {
 
 	// 2. Typecheck
 	checker := types.NewChecker()
-	checker.Check(file)
+	checker.CheckWithFilename(file, filename)
 
-	for _, err := range checker.Errors {
-		fmt.Printf("Type Error: %s\n", err.Message)
+	reportTypeErrors(reporter, checker.Errors)
+	if len(p.Errors()) == 0 {
+		analysis.Lint(file, checker, reporter)
+		analysis.DetectRaces(file, checker, reporter)
 	}
 
 	// 3. Lower
 	lowerer := liveir.NewLowerer(checker.ExprTypes)
 	functions, err := lowerer.LowerModule(file)
 	if err != nil {
-		fmt.Printf("Lowering Failed: %v\n", err)
+		fmt.Fprintf(progress, "Lowering Failed: %v\n", err)
 		return
 	}
 
 	// 4. Analyze
 	engine := analysis.NewEngine()
-	reporter := diagnostics.NewReporter()
 
 	for _, fn := range functions {
 		states, err := engine.Analyze(fn, reporter)
 		if err != nil {
-			fmt.Printf("Analysis Failed for %s: %v\n", fn.Name, err)
+			fmt.Fprintf(progress, "Analysis Failed for %s: %v\n", fn.Name, err)
 		} else {
-			fmt.Printf("Analysis of %s completed successfully.\n", fn.Name)
+			fmt.Fprintf(progress, "Analysis of %s completed successfully.\n", fn.Name)
 
 			// Sort block IDs for consistent output
 			var ids []int
@@ -97,16 +125,98 @@ func runCLI() {
 
 			for _, id := range ids {
 				state := states[id]
-				fmt.Printf("Block %d:\n%s\n\n", id, state)
+				fmt.Fprintf(progress, "Block %d:\n%s\n\n", id, state)
 			}
 		}
 	}
+}
 
-	// Print diagnostics
-	if len(reporter.Diagnostics()) > 0 {
-		fmt.Println("Diagnostics:")
-		for _, d := range reporter.Diagnostics() {
-			fmt.Println(d)
+// runProject analyzes every file under dir, following calls across files
+func runProject(dir string) {
+	proj, err := project.Load(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load project: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(progress, "Analyzing %s (%d files, %d functions)...\n", proj.Root, len(proj.Files), len(proj.Functions))
+	output.Root = proj.Root
+	reporter := diagnostics.NewReporter()
+	defer writeDiagnostics(reporter)
+
+	for _, f := range proj.Files {
+		reportParseErrors(reporter, f.ParseErrors)
+		reportTypeErrors(reporter, f.TypeErrors)
+	}
+
+	proj.Lint(reporter)
+	proj.Analyze(nil, reporter)
+
+	var ids []string
+	for id := range proj.Summaries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if len(ids) > 0 {
+		fmt.Fprintln(progress, "Summaries:")
+	}
+	for _, id := range ids {
+		summary := proj.Summaries[id]
+		returns := "unknown"
+		if summary.Returns != nil {
+			returns = summary.Returns.String()
 		}
+		fmt.Fprintf(progress, "  %s(%s) -> %s\n", proj.Name(id), strings.Join(summary.Params, ", "), returns)
+	}
+
+	ids = ids[:0]
+	for id := range proj.Skipped {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Fprintf(progress, "Skipped %s: %v\n", proj.Name(id), proj.Skipped[id])
+	}
+}
+
+// reportParseErrors reports errs, which the text format prints as they come
+func reportParseErrors(reporter *diagnostics.Reporter, errs []parser.ParseError) {
+	for _, err := range errs {
+		if output.Format == diagnostics.FormatText {
+			fmt.Printf("Parse Error: %s at %v\n", err.Message, err.Span)
+			continue
+		}
+		reporter.Add(diagnostics.Diagnostic{
+			Pos:     err.Span,
+			Message: err.Message,
+			Kind:    diagnostics.KindError,
+			Rule:    diagnostics.RuleParseError,
+		})
+	}
+}
+
+// reportTypeErrors reports errs, which the text format prints as they come
+func reportTypeErrors(reporter *diagnostics.Reporter, errs []diag.Diagnostic) {
+	for _, err := range errs {
+		if output.Format == diagnostics.FormatText {
+			fmt.Printf("Type Error: %s: %s\n", err.Span, err.Message)
+			continue
+		}
+		reporter.Add(diagnostics.FromChecker(err))
+	}
+}
+
+// writeDiagnostics writes the diagnostics of the run in the chosen format
+func writeDiagnostics(reporter *diagnostics.Reporter) {
+	ds := reporter.Diagnostics()
+	if output.Format == diagnostics.FormatText {
+		if len(ds) == 0 {
+			return
+		}
+		fmt.Println("Diagnostics:")
+	}
+	if err := output.Write(os.Stdout, ds); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write diagnostics: %v\n", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/mir/interp"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// Benchmarks are the functions of test files named bench_*, taking no
+// arguments and returning nothing. `malphas bench` compiles each file with a
+// generated main in place of its own, which times each benchmark over a
+// growing number of calls, as go test -bench does: it starts with one call
+// and, until the calls take --benchtime, predicts from the time per call how
+// many would take that long. The program reports the last round on a line
+// starting with benchMarker, which the command turns into ns/op.
+
+// benchtimeFlag is how long each benchmark should run for.
+var benchtimeFlag = flag.Duration("benchtime", time.Second, "with bench, run each benchmark for about this long")
+
+// benchFlag selects the benchmarks to run.
+var benchFlag = flag.String("bench", "", "with bench, run only the benchmarks whose name matches this regular expression")
+
+// benchMarker starts the line the generated main prints for each benchmark:
+// the marker, then the benchmark's name, its number of calls and the
+// nanoseconds they took.
+const benchMarker = "malphas-bench "
+
+// maxBenchN is the most calls a benchmark is timed over.
+const maxBenchN = 1000000000
+
+// benchResult is the timing of one benchmark.
+type benchResult struct {
+	name    string
+	n       int64
+	elapsed time.Duration
+}
+
+// nsPerOp returns the time of one call in nanoseconds.
+func (r benchResult) nsPerOp() int64 {
+	return r.elapsed.Nanoseconds() / r.n
+}
+
+// runBench executes the bench command
+func runBench(args []string) {
+	if !*interpFlag {
+		if err := checkRunnable("bench"); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *benchtimeFlag <= 0 {
+		fmt.Fprintf(os.Stderr, "error: invalid --benchtime %s (must be positive)\n", *benchtimeFlag)
+		os.Exit(1)
+	}
+	filter, err := regexp.Compile(*benchFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: invalid --bench: %v\n", err)
+		os.Exit(1)
+	}
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	var files []string
+	for _, path := range args {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error accessing path %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		found, err := findTestFiles(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding test files: %v\n", err)
+			os.Exit(1)
+		}
+		files = append(files, found...)
+	}
+
+	ran, failed := false, false
+	for _, filename := range files {
+		start := time.Now()
+		results, err := benchFile(filename, filter)
+		if err == nil && results == nil {
+			continue
+		}
+		ran = true
+		if err != nil {
+			failed = true
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			fmt.Printf("FAIL\t%s\n", filename)
+			continue
+		}
+		width := 0
+		for _, r := range results {
+			width = max(width, len(r.name))
+		}
+		for _, r := range results {
+			fmt.Printf("%-*s %12d %12d ns/op\n", width, r.name, r.n, r.nsPerOp())
+		}
+		fmt.Printf("ok\t%s\t%.3fs\n", filename, time.Since(start).Seconds())
+	}
+	if !ran {
+		fmt.Printf("No benchmarks found in %s\n", strings.Join(args, " "))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// benchFile runs the benchmarks of filename that filter matches, returning
+// nil if there are none.
+func benchFile(filename string, filter *regexp.Regexp) ([]benchResult, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+	p := parser.New(string(src), parser.WithFilename(filename))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		var ds []diag.Diagnostic
+		for _, err := range p.Errors() {
+			ds = append(ds, err.Diagnostic())
+		}
+		reportDiagnostics(ds)
+		return nil, fmt.Errorf("%s: parse failed", filename)
+	}
+
+	benches, err := findBenchFunctions(file, filter)
+	if err != nil || len(benches) == 0 {
+		return nil, err
+	}
+	typed, err := checkBenchProgram(filename, file, benches)
+	if err != nil {
+		return nil, err
+	}
+
+	var out strings.Builder
+	if *interpFlag {
+		err = interpretBenchProgram(filename, typed, &out)
+	} else {
+		err = runBenchProgram(filename, typed, &out)
+	}
+	results := parseBenchOutput(out.String(), os.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(results) != len(benches) {
+		return nil, fmt.Errorf("%s: the benchmarks stopped after %d of %d", filename, len(results), len(benches))
+	}
+	return results, nil
+}
+
+// findBenchFunctions returns the benchmarks of file that filter matches. A
+// function named bench_* that is not a benchmark is an error.
+func findBenchFunctions(file *ast.File, filter *regexp.Regexp) ([]*ast.FnDecl, error) {
+	var benches []*ast.FnDecl
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FnDecl)
+		if !ok || fn.Name == nil || !strings.HasPrefix(fn.Name.Name, "bench_") {
+			continue
+		}
+		if len(fn.Params) > 0 || fn.ReturnType != nil || len(fn.TypeParams) > 0 {
+			span := fn.Name.Span()
+			return nil, fmt.Errorf("%s:%d:%d: benchmark %s must take no arguments and return nothing",
+				span.Filename, span.Line, span.Column, fn.Name.Name)
+		}
+		if filter.MatchString(fn.Name.Name) {
+			benches = append(benches, fn)
+		}
+	}
+	return benches, nil
+}
+
+// benchHarness returns the source of the functions timing benches and of
+// the main calling them, each for about target.
+func benchHarness(benches []*ast.FnDecl, target time.Duration) string {
+	var b strings.Builder
+	for _, fn := range benches {
+		name := fn.Name.Name
+		fmt.Fprintf(&b, `fn __malphas_bench_%[1]s(target: int) {
+    let mut n = 1;
+    while true {
+        let start = __time_monotonic_ns__();
+        let mut i = 0;
+        while i < n {
+            %[1]s();
+            i = i + 1;
+        }
+        let elapsed = __time_monotonic_ns__() - start;
+        if elapsed >= target || n >= %[2]d {
+            println("%[3]s%[1]s " + __string_from_int__(n) + " " + __string_from_int__(elapsed));
+            return;
+        }
+        let per_op = elapsed / n;
+        let mut next = n * 100;
+        // && is not short-circuiting, so the division needs its own branch
+        if per_op > 0 {
+            let predicted = target / per_op * 6 / 5;
+            if predicted < next {
+                next = predicted;
+            }
+        }
+        if next <= n {
+            next = n + 1;
+        }
+        if next > %[2]d {
+            next = %[2]d;
+        }
+        n = next;
+    }
+}
+
+`, name, maxBenchN, benchMarker)
+	}
+	b.WriteString("fn main() {\n")
+	for _, fn := range benches {
+		fmt.Fprintf(&b, "    __malphas_bench_%s(%d);\n", fn.Name.Name, target.Nanoseconds())
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// checkBenchProgram replaces the main of file by the harness timing benches,
+// in place, and type checks the result. Only errors are reported: the warnings of a
+// test file are for `malphas check`.
+func checkBenchProgram(filename string, file *ast.File, benches []*ast.FnDecl) (*types.TypedFile, error) {
+	hp := parser.New(benchHarness(benches, *benchtimeFlag), parser.WithFilename("<bench harness>"))
+	harness := hp.ParseFile()
+	if len(hp.Errors()) > 0 {
+		return nil, fmt.Errorf("internal compiler error: bench harness: %v", hp.Errors())
+	}
+	decls := make([]ast.Decl, 0, len(file.Decls)+len(harness.Decls))
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FnDecl); ok && fn.Name != nil && fn.Name.Name == "main" {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	file.Decls = append(decls, harness.Decls...)
+
+	absFilename, err := filepath.Abs(filename)
+	if err != nil {
+		absFilename = filename
+	}
+	checker := types.NewChecker()
+	checker.CheckWithFilename(file, absFilename)
+	if len(checker.Errors) > 0 {
+		reportDiagnostics(checker.Errors)
+		return nil, fmt.Errorf("%s: type check failed", filename)
+	}
+	return checker.Typed(file)
+}
+
+// interpretBenchProgram runs the benchmarks of filename with the MIR
+// interpreter, writing their output to out.
+func interpretBenchProgram(filename string, typed *types.TypedFile, out io.Writer) error {
+	mirModule, err := optimizedMIR(typed)
+	if err != nil {
+		return err
+	}
+	in := interp.New(mirModule, interp.Options{
+		Args:     []string{filename},
+		Stdout:   out,
+		Overflow: overflowMode,
+	})
+	status, err := in.Run()
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("exit status %d", status)
+	}
+	return nil
+}
+
+// runBenchProgram compiles the benchmarks of filename with optimizations,
+// as `malphas run` does, and runs them, writing their output to out.
+func runBenchProgram(filename string, typed *types.TypedFile, out io.Writer) error {
+	ir, err := compileToLLVM(typed)
+	if err != nil {
+		return err
+	}
+	optimizationLevel := os.Getenv("MALPHAS_OPT")
+	if optimizationLevel == "" {
+		optimizationLevel = "2" // Default to -O2
+	}
+	ir = optimizeLLVM(ir, optimizationLevel)
+
+	dir, err := newBuildDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, exeName("bench"))
+	if err := buildExecutable(context.Background(), dir, filename, ir, exe); err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(*runTimeoutFlag)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, exe)
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %s (--run-timeout)", *runTimeoutFlag)
+		}
+		return err
+	}
+	return nil
+}
+
+// parseBenchOutput returns the results in the output of a benchmark program,
+// copying the rest of the output, which the benchmarks printed, to w.
+func parseBenchOutput(output string, w io.Writer) []benchResult {
+	var results []benchResult
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		before, report, found := strings.Cut(line, benchMarker)
+		if !found {
+			fmt.Fprintln(w, line)
+			continue
+		}
+		if before != "" {
+			// The benchmark printed a line without ending it
+			fmt.Fprintln(w, before)
+		}
+		fields := strings.Fields(report)
+		if len(fields) != 3 {
+			continue
+		}
+		n, err1 := strconv.ParseInt(fields[1], 10, 64)
+		ns, err2 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 != nil || err2 != nil || n <= 0 {
+			continue
+		}
+		results = append(results, benchResult{name: fields[0], n: n, elapsed: time.Duration(ns)})
+	}
+	return results
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+const benchSource = `fn sum(n: int) -> int {
+    let mut total = 0;
+    let mut i = 0;
+    while i < n {
+        total = total + i;
+        i = i + 1;
+    }
+    return total;
+}
+
+fn bench_sum_10() {
+    sum(10);
+}
+
+fn bench_sum_1000() {
+    sum(1000);
+}
+
+fn main() {
+    println("not a benchmark");
+}
+`
+
+func TestParseBenchOutput(t *testing.T) {
+	var passed strings.Builder
+	results := parseBenchOutput("hello\n"+
+		"partial"+benchMarker+"bench_a 100 2500\n"+
+		benchMarker+"bench_b 3 oops\n"+
+		benchMarker+"bench_c 1000000 2000000000\n", &passed)
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want bench_a and bench_c: %v", len(results), results)
+	}
+	if r := results[0]; r.name != "bench_a" || r.n != 100 || r.nsPerOp() != 25 {
+		t.Errorf("bench_a = %+v (%d ns/op), want 100 calls of 25 ns", r, r.nsPerOp())
+	}
+	if r := results[1]; r.nsPerOp() != 2000 {
+		t.Errorf("bench_c = %d ns/op, want 2000", r.nsPerOp())
+	}
+	if passed.String() != "hello\npartial\n" {
+		t.Errorf("the output of the benchmarks was not passed through: %q", passed.String())
+	}
+}
+
+func TestFindBenchFunctions(t *testing.T) {
+	file := parser.New(benchSource, parser.WithFilename("sum_test.mal")).ParseFile()
+	benches, err := findBenchFunctions(file, regexp.MustCompile("1000"))
+	if err != nil || len(benches) != 1 || benches[0].Name.Name != "bench_sum_1000" {
+		t.Fatalf("findBenchFunctions(1000) = %v, %v, want bench_sum_1000", benches, err)
+	}
+
+	bad := strings.Replace(benchSource, "fn bench_sum_10()", "fn bench_sum_10(n: int)", 1)
+	file = parser.New(bad, parser.WithFilename("sum_test.mal")).ParseFile()
+	if _, err := findBenchFunctions(file, regexp.MustCompile("")); err == nil || !strings.Contains(err.Error(), "bench_sum_10") {
+		t.Errorf("a benchmark taking an argument should be rejected, got %v", err)
+	}
+}
+
+func TestBenchInterpreted(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sum_test.mal"), []byte(benchSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, status := runCompiler(t, dir, "", "--benchtime=20ms", "bench", "--interp")
+	if status != 0 {
+		t.Fatalf("bench exited with status %d:\n%s", status, stderr)
+	}
+	if strings.Contains(stdout, "not a benchmark") {
+		t.Error("the main of the file ran along with the benchmarks")
+	}
+	var results []benchResult
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 4 && fields[3] == "ns/op" {
+			results = append(results, benchResult{name: fields[0]})
+		}
+	}
+	if len(results) != 2 || results[0].name != "bench_sum_10" || results[1].name != "bench_sum_1000" {
+		t.Errorf("got output\n%s\nwant a line for each benchmark", stdout)
+	}
+	if !strings.Contains(stdout, "ok\tsum_test.mal") {
+		t.Errorf("missing the summary line of the file:\n%s", stdout)
+	}
+}
+
+func TestBenchCompiled(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sum_test.mal"), []byte(benchSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A round of bench_sum_10 takes under a nanosecond per call, so the
+	// harness must not divide by the time per call
+	args := append([]string{"--benchtime=50ms"}, compiledFlags(t)...)
+	stdout, stderr, status := runCompiler(t, dir, "", append(args, "bench")...)
+	if status == 1 && missingToolchain(stderr) {
+		t.Fatalf("cannot compile natively (pass -interp-only to skip compiled runs):\n%s", stderr)
+	}
+	if status != 0 {
+		t.Fatalf("bench exited with status %d:\n%s%s", status, stdout, stderr)
+	}
+	if !strings.Contains(stdout, "bench_sum_10") || !strings.Contains(stdout, "bench_sum_1000") {
+		t.Errorf("got output\n%s\nwant a line for each benchmark", stdout)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// buildTimeoutFlag bounds each tool a build runs: opt, llc, and the C
+// compiler compiling the runtime and linking.
+var buildTimeoutFlag = flag.Duration("build-timeout", 60*time.Second, "time limit for each step of compiling and linking (opt, llc, the C compiler); 0 for none")
+
+// runTimeoutFlag bounds the program `malphas run` runs, which by default
+// may run for as long as it likes, as a server does.
+var runTimeoutFlag = flag.Duration("run-timeout", 0, "with run, kill the program after this long, e.g. 30s; 0 for no limit")
+
+// checkTimeouts validates the timeout flags.
+func checkTimeouts() error {
+	if *buildTimeoutFlag < 0 {
+		return fmt.Errorf("invalid --build-timeout %s (must not be negative)", *buildTimeoutFlag)
+	}
+	if *runTimeoutFlag < 0 {
+		return fmt.Errorf("invalid --run-timeout %s (must not be negative)", *runTimeoutFlag)
+	}
+	return nil
+}
+
+// withTimeout returns a context that is done after d, or never if d is 0.
+func withTimeout(d time.Duration) (context.Context, context.CancelFunc) {
+	if d == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), d)
+}
+
+// buildStep returns the context of a build step run within ctx, which
+// --build-timeout bounds.
+func buildStep(ctx context.Context) (context.Context, context.CancelFunc) {
+	if *buildTimeoutFlag == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, *buildTimeoutFlag)
+}
+
+// stepTimedOut returns the error of a build step run within ctx that ran
+// out of time, naming --build-timeout unless it was ctx that expired.
+func stepTimedOut(ctx context.Context, step string) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s timed out", step)
+	}
+	return fmt.Errorf("%s timed out after %s (raise the limit with --build-timeout, or 0 for none)", step, *buildTimeoutFlag)
+}
+
+// A build keeps its intermediate files, the object file of the program and
+// with --runtime-from-source that of the runtime, in a temporary directory
+// of its own, which it removes when done. The IR is never written to a file:
+// it is piped to opt and llc. Only the executable `malphas build` produces
+// is written outside that directory.
+
+// newBuildDir creates the temporary directory of a build. The caller removes
+// it with os.RemoveAll.
+func newBuildDir() (string, error) {
+	dir, err := os.MkdirTemp("", "malphas_build_*")
+	if err != nil {
+		return "", fmt.Errorf("error creating build directory: %v", err)
+	}
+	return dir, nil
+}
+
+// assemble compiles ir to the object file objFile with llc, which reads the
+// IR from its standard input.
+func assemble(ctx context.Context, ir, objFile string) error {
+	llcPath, err := findLLC()
+	if err != nil {
+		return err
+	}
+	stepCtx, cancel := buildStep(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(stepCtx, llcPath, llcArgs(objFile)...)
+	cmd.Stdin = strings.NewReader(ir)
+	var stderr strings.Builder
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	debugLog("Compiling LLVM IR to object file: %s\n", objFile)
+	endLLC := startPhase("llc")
+	err = cmd.Run()
+	endLLC()
+	if err != nil {
+		if stepCtx.Err() == context.DeadlineExceeded {
+			return stepTimedOut(ctx, "LLVM compilation")
+		}
+		msg := fmt.Sprintf("LLVM compilation failed: %v\n  llc path: %s", err, llcPath)
+		if stderr.Len() > 0 {
+			msg += "\n\nllc error output:\n" + stderr.String()
+		}
+		// Also print the LLVM IR for debugging if it's small enough
+		if len(ir) < 10000 {
+			msg += "\n\nGenerated LLVM IR (for debugging):\n" + ir
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	debugLog("LLVM compilation successful\n")
+	return nil
+}
+
+// linkExecutable links objFile, compiled from filename, with the runtime and
+// the target's libraries into the executable exePath. A runtime compiled
+// from source is compiled into dir.
+func linkExecutable(ctx context.Context, dir, filename, objFile, exePath string) error {
+	// Link with the runtime and, unless --gc=none, the Boehm GC library
+	runtimeArgs, err := runtimeLinkArgs(ctx, dir, filename)
+	if err != nil {
+		return err
+	}
+	platformArgs, err := targetLinkArgs(ctx)
+	if err != nil {
+		return err
+	}
+	linkArgs := append([]string{"-o", exePath, objFile}, runtimeArgs...)
+	linkArgs = append(linkArgs, platformArgs...)
+	debugLog("Linking binary: %s\n", exePath)
+	stepCtx, cancel := buildStep(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(stepCtx, cCompiler(), linkArgs...)
+	var stderr strings.Builder
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = &stderr
+	endLink := startPhase("link")
+	err = cmd.Run()
+	endLink()
+	if err != nil {
+		if stepCtx.Err() == context.DeadlineExceeded {
+			return stepTimedOut(ctx, "linking")
+		}
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("linking failed: %v\nNote: LLVM backend requires 'clang' to be installed", err)
+		}
+		return fmt.Errorf("linking failed: %v\n%s", err, stderr.String())
+	}
+	debugLog("Linking successful\n")
+	return nil
+}
+
+// buildExecutable compiles ir, the IR of filename, to the executable
+// exePath, keeping the object files in dir.
+func buildExecutable(ctx context.Context, dir, filename, ir, exePath string) error {
+	objFile := filepath.Join(dir, "main.o")
+	if err := assemble(ctx, ir, objFile); err != nil {
+		return err
+	}
+	return linkExecutable(ctx, dir, filename, objFile, exePath)
+}
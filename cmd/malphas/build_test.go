@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const answerIR = `define i32 @answer() {
+  ret i32 42
+}
+`
+
+func TestOptimizeAndAssembleThroughPipes(t *testing.T) {
+	if _, err := findLLC(); err != nil {
+		t.Skip("llc not installed")
+	}
+	if _, err := findOpt(); err == nil {
+		optimized := optimizeLLVM(answerIR, "2")
+		if !strings.Contains(optimized, "@answer") {
+			t.Errorf("optimized IR lost the function:\n%s", optimized)
+		}
+		if out := verifyLLVM(answerIR); out != "" {
+			t.Errorf("valid IR failed verification: %s", out)
+		}
+		if out := verifyLLVM(strings.Replace(answerIR, "i32 42", "i64 42", 1)); !strings.Contains(out, "<stdin>") {
+			t.Errorf("invalid IR passed verification, or the error does not come from stdin: %q", out)
+		}
+	}
+
+	dir := t.TempDir()
+	objFile := filepath.Join(dir, "main.o")
+	if err := assemble(context.Background(), answerIR, objFile); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(objFile); err != nil || info.Size() == 0 {
+		t.Errorf("no object file written: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("assemble left %d files in the build directory, want only the object file", len(entries))
+	}
+
+	err := assemble(context.Background(), "define i32 @broken(", filepath.Join(dir, "broken.o"))
+	if err == nil || !strings.Contains(err.Error(), "llc error output") {
+		t.Errorf("assemble(broken IR) = %v, want llc's error", err)
+	}
+}
+
+func TestBuildTimeouts(t *testing.T) {
+	defer func(build, run time.Duration) { *buildTimeoutFlag, *runTimeoutFlag = build, run }(*buildTimeoutFlag, *runTimeoutFlag)
+
+	if *runTimeoutFlag != 0 {
+		t.Errorf("run should have no time limit by default, got %s", *runTimeoutFlag)
+	}
+	ctx, cancel := withTimeout(0)
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("a zero timeout should set no deadline")
+	}
+	cancel()
+
+	*buildTimeoutFlag = 0
+	ctx, cancel = buildStep(context.Background())
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("--build-timeout=0 should set no deadline")
+	}
+	cancel()
+
+	*buildTimeoutFlag = time.Nanosecond
+	ctx, cancel = buildStep(context.Background())
+	<-ctx.Done()
+	cancel()
+	if err := stepTimedOut(context.Background(), "linking"); !strings.Contains(err.Error(), "--build-timeout") {
+		t.Errorf("timeout error should name the flag: %v", err)
+	}
+
+	*runTimeoutFlag = -time.Second
+	if err := checkTimeouts(); err == nil {
+		t.Error("a negative --run-timeout should be rejected")
+	}
+}
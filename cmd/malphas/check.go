@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// checkLowerFlag makes `check` also run the steps between type checking and
+// code generation, which find some errors the checker does not.
+var checkLowerFlag = flag.Bool("lower", false, "with check, also lower each file to MIR and monomorphize it, reporting the errors those steps find")
+
+// runCheck reports the diagnostics of each file without generating code, so
+// it needs neither LLVM nor a C compiler. It exits with status 1 if any
+// file has errors.
+func runCheck(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: malphas check <file>...\n")
+		os.Exit(1)
+	}
+	failed := false
+	for _, filename := range args {
+		errorCount, warningCount, hiddenErrors = 0, 0, 0
+		endFile := startPhase(filename)
+		err := checkFile(filename, *checkLowerFlag)
+		endFile()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed = true
+		}
+		printSummary(filename, err != nil)
+	}
+	reportTimings()
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// checkFile parses and type-checks filename, then lowers it to MIR if lower
+// is set, reporting the diagnostics of each step.
+func checkFile(filename string, lower bool) error {
+	typed, err := checkSource(filename)
+	if err != nil || !lower {
+		return err
+	}
+	_, err = lowerToMIR(typed)
+	return err
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckFile(t *testing.T) {
+	defer func() { errorCount, warningCount, hiddenErrors = 0, 0, 0 }()
+	dir := t.TempDir()
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	ok := write("ok.mal", "fn main() {\n    let _x = 1;\n}\n")
+	bad := write("bad.mal", "fn main() {\n    let _x: int = \"s\";\n}\n")
+
+	for _, lower := range []bool{false, true} {
+		if err := checkFile(ok, lower); err != nil {
+			t.Errorf("checkFile(ok, lower=%v) = %v, want nil", lower, err)
+		}
+		if err := checkFile(bad, lower); err == nil {
+			t.Errorf("checkFile(bad, lower=%v) should report the type error", lower)
+		}
+	}
+	if errorCount != 2 {
+		t.Errorf("got %d errors reported, want 2", errorCount)
+	}
+	if err := checkFile(filepath.Join(dir, "missing.mal"), false); err == nil {
+		t.Error("checkFile of a missing file should fail")
+	}
+}
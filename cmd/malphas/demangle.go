@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/malphas-lang/malphas-lang/internal/mangle"
+)
+
+// symbolPattern matches the words of a symbol listing that may be mangled
+var symbolPattern = regexp.MustCompile(`[A-Za-z0-9_$.-]+`)
+
+// runDemangle prints the Malphas names of the symbols given as arguments, one
+// per line. Without arguments it copies standard input to standard output
+// with every symbol demangled, as a filter for nm, objdump or a debugger.
+func runDemangle(args []string) {
+	if len(args) > 0 {
+		for _, symbol := range args {
+			fmt.Println(mangle.Demangle(symbol))
+		}
+		return
+	}
+	if err := demangleText(os.Stdout, os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// demangleText copies r to w line by line, demangling each symbol
+func demangleText(w io.Writer, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	out := bufio.NewWriter(w)
+	for scanner.Scan() {
+		out.WriteString(symbolPattern.ReplaceAllStringFunc(scanner.Text(), mangle.Demangle))
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDemangleText(t *testing.T) {
+	in := `0000000000001130 T main
+0000000000001160 T geo.Point.new
+00000000000011a0 T pair$Box-5Bint-5D-2Cbool
+                 U printf
+`
+	want := `0000000000001130 T main
+0000000000001160 T geo::Point::new
+00000000000011a0 T pair$Box[int],bool
+                 U printf
+`
+	var out strings.Builder
+	if err := demangleText(&out, strings.NewReader(in)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("demangleText =\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/mir/optimize"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// emitFlag makes `build` write an intermediate form of the program in place
+// of a binary.
+var emitFlag = flag.String("emit", "", "with build, write an intermediate form instead of a binary: mir writes the optimized MIR to <name>.mir")
+
+// parseEmit validates the value of the --emit flag.
+func parseEmit(s string) (string, error) {
+	switch s {
+	case "", "mir":
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid --emit value %q (want mir)", s)
+}
+
+// optimizedMIR lowers a checked file to MIR and runs the MIR passes on it,
+// giving the module that code generation starts from. MALPHAS_DEBUG_MIR
+// prints it to stderr.
+func optimizedMIR(typed *types.TypedFile) (*mir.Module, error) {
+	// Steps 1 and 2: Lower AST to MIR and monomorphize generic functions
+	mirModule, err := lowerToMIR(typed)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 3: Optional MIR optimizations (--mir-opt / MALPHAS_OPT)
+	endOptimize := startPhase("MIR optimize")
+	mirModule = optimize.Run(mirModule, mirPasses)
+	endOptimize()
+
+	// Step 4: Move values that never leave their function off the GC heap
+	endEscapes := startPhase("escape analysis")
+	optimize.AnalyzeEscapes(mirModule)
+	endEscapes()
+
+	if os.Getenv("MALPHAS_DEBUG_MIR") != "" {
+		fmt.Fprintf(os.Stderr, "Generated MIR:\n%s\n", mirModule.PrettyPrint())
+	}
+	return mirModule, nil
+}
+
+// emitMIR writes the MIR of filename to <name>.mir in the current directory.
+func emitMIR(filename string) error {
+	typed, err := checkSource(filename)
+	if err != nil {
+		return err
+	}
+	mirModule, err := optimizedMIR(typed)
+	if err != nil {
+		return err
+	}
+	base := filepath.Base(filename)
+	outName := strings.TrimSuffix(base, filepath.Ext(base)) + ".mir"
+	if err := os.WriteFile(outName, []byte(mirModule.PrettyPrint()+"\n"), 0o644); err != nil {
+		return fmt.Errorf("error writing MIR: %v", err)
+	}
+	fmt.Printf("Wrote %s\n", outName)
+	return nil
+}
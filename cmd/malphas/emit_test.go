@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+func TestEmitMIR(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "prog.mal")
+	if err := os.WriteFile(src, []byte("fn twice(x: int) -> int {\n    return x * 2;\n}\nfn main() {\n    let _y = twice(4);\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	if err := emitMIR(src); err != nil {
+		t.Fatalf("emitMIR: %v", err)
+	}
+	out, err := os.ReadFile("prog.mir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(out)
+	if !strings.Contains(text, "fn twice(x_0: int) -> int {") {
+		t.Errorf("prog.mir should hold twice:\n%s", text)
+	}
+	if _, err := mir.Parse(text); err != nil {
+		t.Errorf("prog.mir does not parse: %v", err)
+	}
+}
+
+func TestParseEmit(t *testing.T) {
+	for _, value := range []string{"", "mir"} {
+		if _, err := parseEmit(value); err != nil {
+			t.Errorf("parseEmit(%q) = %v", value, err)
+		}
+	}
+	if _, err := parseEmit("asm"); err == nil {
+		t.Error("parseEmit should reject asm")
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The golden tests compile programs end to end with this package's test
+// binary standing in for the compiler:
+//
+//   - each tests/run-pass/<name>.mal is run, compiled and with --interp, and
+//     its standard output compared with <name>.stdout
+//   - each tests/compile-fail/<name>.mal is checked, and its diagnostics
+//     compared with <name>.stderr
+//
+// A program's leading comments may hold directives: `// exit-code: N` for
+// the expected exit status (0 by default) and `// flags: ...` for compiler
+// flags. Run `go test ./cmd/malphas -run Golden -update` to rewrite the
+// expected files.
+//
+// The compiled runs need llc and clang, and link the runtime built from
+// runtime/runtime.c (with Boehm GC unless -golden-flags selects --gc=none),
+// or $MALPHAS_RUNTIME if set. A missing toolchain fails them; pass
+// -interp-only to skip them instead.
+
+var update = flag.Bool("update", false, "rewrite the expected output of the golden tests")
+
+var goldenFlags = flag.String("golden-flags", "", "compiler flags for every compiled run-pass test, such as --gc=none")
+
+var interpOnly = flag.Bool("interp-only", false, "run the run-pass tests with --interp only, skipping the compiled runs")
+
+// goldenDir is the tests directory, relative to this package
+const goldenDir = "../../tests"
+
+// runtimeSource is the runtime's source, relative to this package
+const runtimeSource = "../../runtime/runtime.c"
+
+// compilerEnv makes the test binary run main instead of the tests
+const compilerEnv = "MALPHAS_GOLDEN_COMPILER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(compilerEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	code := m.Run()
+	if goldenRuntimes.dir != "" {
+		os.RemoveAll(goldenRuntimes.dir)
+	}
+	os.Exit(code)
+}
+
+// goldenRuntimes holds the runtime archives built for the compiled tests, by
+// archive name, in a directory removed once the tests have run
+var goldenRuntimes = struct {
+	sync.Mutex
+	dir  string
+	libs map[string]string
+}{libs: map[string]string{}}
+
+// compiledFlags returns the compiler flags of a compiled test run: those of
+// -golden-flags, then flags, then the runtime library to link. The test is
+// skipped under -interp-only and fails if the runtime cannot be built.
+func compiledFlags(t *testing.T, flags ...string) []string {
+	t.Helper()
+	if *interpOnly {
+		t.Skip("compiled run skipped by -interp-only")
+	}
+	args := append(strings.Fields(*goldenFlags), flags...)
+	if os.Getenv("MALPHAS_RUNTIME") != "" {
+		return args
+	}
+	lib, err := goldenRuntime(args)
+	if err != nil {
+		t.Fatalf("cannot build the runtime for the compiled run (pass -interp-only to skip compiled runs):\n%v", err)
+	}
+	return append(args, "--runtime-lib="+lib)
+}
+
+// goldenRuntime returns the runtime archive for the --gc and --panic modes
+// of the compiler flags args, compiling runtime.c the first time a mode is
+// asked for. Building it here rather than linking an installed archive
+// makes the tests exercise the runtime of this tree.
+func goldenRuntime(args []string) (string, error) {
+	gc, panicMode := "boehm", "exit"
+	for _, arg := range args {
+		name, value, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch name {
+		case "gc":
+			gc = value
+		case "panic":
+			panicMode = value
+		}
+	}
+	name := "libmalphas_runtime"
+	cflags := []string{"-O2", "-fPIC"}
+	if gc == "none" {
+		name += "_nogc"
+		cflags = append(cflags, "-DMALPHAS_GC_NONE")
+	} else if includePath := findGCIncludePath(); includePath != "" {
+		cflags = append(cflags, "-I"+includePath)
+	}
+	if panicMode == "abort" {
+		name += "_abort"
+		cflags = append(cflags, "-DMALPHAS_PANIC_ABORT")
+	}
+	name += ".a"
+
+	goldenRuntimes.Lock()
+	defer goldenRuntimes.Unlock()
+	if lib, ok := goldenRuntimes.libs[name]; ok {
+		return lib, nil
+	}
+	if goldenRuntimes.dir == "" {
+		dir, err := os.MkdirTemp("", "malphas-golden-runtime")
+		if err != nil {
+			return "", err
+		}
+		goldenRuntimes.dir = dir
+	}
+	lib := filepath.Join(goldenRuntimes.dir, name)
+	obj := strings.TrimSuffix(lib, ".a") + ".o"
+	steps := [][]string{
+		append(append([]string{"clang"}, cflags...), "-c", "-o", obj, runtimeSource),
+		{"ar", "rcs", lib, obj},
+	}
+	for _, step := range steps {
+		if out, err := exec.Command(step[0], step[1:]...).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("%s: %v\n%s", strings.Join(step, " "), err, out)
+		}
+	}
+	goldenRuntimes.libs[name] = lib
+	return lib, nil
+}
+
+// goldenProgram is a test program and its directives
+type goldenProgram struct {
+	name     string
+	dir      string
+	flags    []string
+	args     []string // Passed to the program
+	stdin    string
+	exitCode int
+}
+
+// goldenPrograms returns the programs in tests/<kind>
+func goldenPrograms(t *testing.T, kind string) []goldenProgram {
+	t.Helper()
+	dir := filepath.Join(goldenDir, kind)
+	paths, err := filepath.Glob(filepath.Join(dir, "*.mal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no programs in %s", dir)
+	}
+	var programs []goldenProgram
+	for _, path := range paths {
+		prog := goldenProgram{name: filepath.Base(path), dir: dir}
+		if err := prog.readDirectives(path); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		programs = append(programs, prog)
+	}
+	return programs
+}
+
+// readDirectives reads the directives in the leading comments of path
+func (p *goldenProgram) readDirectives(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		comment, ok := strings.CutPrefix(line, "//")
+		if !ok {
+			break
+		}
+		key, value, ok := strings.Cut(comment, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "exit-code":
+			code, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			p.exitCode = code
+		case "flags":
+			p.flags = strings.Fields(value)
+		case "args":
+			p.args = strings.Fields(value)
+		case "stdin":
+			p.stdin = value + "\n"
+		}
+	}
+	return scanner.Err()
+}
+
+// expected returns the contents of the expected-output file of p with the
+// given extension, or rewrites it with got under -update
+func (p goldenProgram) expected(t *testing.T, ext, got string) string {
+	t.Helper()
+	path := filepath.Join(p.dir, strings.TrimSuffix(p.name, ".mal")+ext)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read expected output: %v (run with -update to create it)", err)
+	}
+	return string(want)
+}
+
+// runCompiler runs the compiler with args in dir, feeding it stdin, and
+// returns its output and exit status
+func runCompiler(t *testing.T, dir, stdin string, args ...string) (stdout, stderr string, status int) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), compilerEnv+"=1")
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		status = exitErr.ExitCode()
+	case err != nil:
+		t.Fatalf("run compiler: %v", err)
+	}
+	return out.String(), errOut.String(), status
+}
+
+// missingToolchain reports whether a failed build was for want of LLVM or
+// of the runtime library rather than an error in the program
+func missingToolchain(stderr string) bool {
+	return strings.Contains(stderr, "requires 'llc'") ||
+		(strings.Contains(stderr, "runtime library") && strings.Contains(stderr, "not found"))
+}
+
+func TestGoldenRunPass(t *testing.T) {
+	for _, prog := range goldenPrograms(t, "run-pass") {
+		t.Run(prog.name, func(t *testing.T) {
+			args := append(append([]string{"--color=never"}, prog.flags...), "run", "--interp", prog.name, "--")
+			stdout, stderr, status := runCompiler(t, prog.dir, prog.stdin, append(args, prog.args...)...)
+			if status != prog.exitCode {
+				t.Fatalf("interpreted: exit status %d, want %d; stderr:\n%s", status, prog.exitCode, stderr)
+			}
+			want := prog.expected(t, ".stdout", stdout)
+			if stdout != want {
+				t.Errorf("interpreted: stdout:\n%s\nwant:\n%s", stdout, want)
+			}
+
+			args = append([]string{"--color=never"}, compiledFlags(t, prog.flags...)...)
+			args = append(args, "run", prog.name, "--")
+			stdout, stderr, status = runCompiler(t, prog.dir, prog.stdin, append(args, prog.args...)...)
+			if status == 1 && stdout == "" && missingToolchain(stderr) {
+				t.Fatalf("cannot compile natively (pass -interp-only to skip compiled runs):\n%s", stderr)
+			}
+			if status != prog.exitCode {
+				t.Fatalf("compiled: exit status %d, want %d; stderr:\n%s", status, prog.exitCode, stderr)
+			}
+			if stdout != want {
+				t.Errorf("compiled: stdout:\n%s\nwant:\n%s", stdout, want)
+			}
+		})
+	}
+}
+
+func TestGoldenCompileFail(t *testing.T) {
+	for _, prog := range goldenPrograms(t, "compile-fail") {
+		t.Run(prog.name, func(t *testing.T) {
+			args := append(append([]string{"--color=never", "--lower"}, prog.flags...), "check", prog.name)
+			_, stderr, status := runCompiler(t, prog.dir, "", args...)
+			if status != 1 {
+				t.Errorf("exit status %d, want 1", status)
+			}
+			if want := prog.expected(t, ".stderr", stderr); stderr != want {
+				t.Errorf("diagnostics:\n%s\nwant:\n%s", stderr, want)
+			}
+		})
+	}
+}
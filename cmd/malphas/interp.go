@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/mir/interp"
+)
+
+// interpFlag makes `malphas run` interpret the program's MIR instead of
+// compiling it, so programs run without LLVM or a C compiler.
+var interpFlag = flag.Bool("interp", false, "with run: interpret the program's MIR instead of compiling it (needs no llc or C compiler; extern functions are not supported)")
+
+// runInterpreted is `malphas run --interp`: it runs filename with the MIR
+// interpreter, passing it args, and exits with the program's status.
+func runInterpreted(filename string, args []string) {
+	typed, err := checkSource(filename)
+	var mirModule *mir.Module
+	if err == nil {
+		mirModule, err = optimizedMIR(typed)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		printSummary(filename, true)
+		os.Exit(1)
+	}
+	printSummary(filename, false)
+
+	in := interp.New(mirModule, interp.Options{
+		Args:     append([]string{filename}, args...),
+		Overflow: overflowMode,
+	})
+	endRun := startPhase("interpret")
+	status, err := in.Run()
+	endRun()
+	reportTimings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(status)
+}
@@ -2,100 +2,174 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	goruntime "runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/malphas-lang/malphas-lang/internal/ast"
 	mir2llvm "github.com/malphas-lang/malphas-lang/internal/codegen/mir2llvm"
 	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/format"
 	"github.com/malphas-lang/malphas-lang/internal/lsp"
 	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/mir/optimize"
 	"github.com/malphas-lang/malphas-lang/internal/parser"
 	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
-// findLLC finds the llc executable, checking PATH first, then common installation locations.
+// llvmPathFlag points at the LLVM installation providing llc and opt.
+var llvmPathFlag = flag.String("llvm-path", "", "LLVM installation prefix or bin directory for llc and opt (default $MALPHAS_LLVM_PREFIX, else PATH and common install locations)")
+
+// minLLVMMajor is the oldest LLVM release accepted. opt's -passes= pipelines
+// (the new pass manager) are only usable from LLVM 13 on.
+const minLLVMMajor = 13
+
+var llvmVersionPattern = regexp.MustCompile(`LLVM version (\d+)\.`)
+
+// llvmTools caches resolved tool paths, since probing runs `--version`.
+var llvmTools = map[string]string{}
+
+// findLLC finds the llc executable (LLVM static compiler).
 func findLLC() (string, error) {
-	// First, try to find llc in PATH
-	if path, err := exec.LookPath("llc"); err == nil {
+	return findLLVMTool("llc")
+}
+
+// findOpt finds the opt executable (LLVM optimizer).
+func findOpt() (string, error) {
+	return findLLVMTool("opt")
+}
+
+// findLLVMTool returns the first candidate from llvmToolCandidates that
+// exists and reports LLVM minLLVMMajor or newer. The error lists every
+// location probed and why it was rejected.
+func findLLVMTool(name string) (string, error) {
+	if path, ok := llvmTools[name]; ok {
 		return path, nil
 	}
 
-	// If not in PATH, check common Homebrew locations
-	brewPrefix := os.Getenv("HOMEBREW_PREFIX")
-	if brewPrefix == "" {
-		// Try common Homebrew prefixes
-		for _, prefix := range []string{"/opt/homebrew", "/usr/local"} {
-			llcPath := filepath.Join(prefix, "opt/llvm/bin/llc")
-			if _, err := os.Stat(llcPath); err == nil {
-				return llcPath, nil
-			}
+	candidates, explicit := llvmToolCandidates(name)
+	var probed []string
+	if !explicit {
+		if _, err := exec.LookPath(name); err != nil {
+			probed = append(probed, "$PATH (not found)")
 		}
-	} else {
-		// Check HOMEBREW_PREFIX location
-		llcPath := filepath.Join(brewPrefix, "opt/llvm/bin/llc")
-		if _, err := os.Stat(llcPath); err == nil {
-			return llcPath, nil
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err != nil {
+			probed = append(probed, path+" (not found)")
+			continue
+		}
+		major, err := llvmMajorVersion(path)
+		if err != nil {
+			probed = append(probed, fmt.Sprintf("%s (%v)", path, err))
+			continue
+		}
+		if major < minLLVMMajor {
+			probed = append(probed, fmt.Sprintf("%s (LLVM %d is too old, need %d or newer)", path, major, minLLVMMajor))
+			continue
 		}
+		debugLog("Using %s from %s (LLVM %d)\n", name, path, major)
+		llvmTools[name] = path
+		return path, nil
 	}
 
-	return "", fmt.Errorf("llc not found in PATH or common installation locations")
+	return "", fmt.Errorf("%s (LLVM %d or newer) not found; probed:\n  %s\nset --llvm-path or MALPHAS_LLVM_PREFIX to your LLVM installation",
+		name, minLLVMMajor, strings.Join(probed, "\n  "))
 }
 
-// findOpt finds the opt executable (LLVM optimizer), checking PATH first, then common installation locations.
-func findOpt() (string, error) {
-	// First, try to find opt in PATH
-	if path, err := exec.LookPath("opt"); err == nil {
-		return path, nil
+// llvmToolCandidates lists the paths to try for an LLVM tool, most preferred
+// first. An installation given through --llvm-path or MALPHAS_LLVM_PREFIX is
+// the only place looked at (explicit is then true); otherwise PATH, Homebrew,
+// the LLVM installer's directory on Windows and the versioned
+// /usr/lib/llvm-<N> directories of Linux distributions are searched, newest
+// version first.
+func llvmToolCandidates(name string) (candidates []string, explicit bool) {
+	prefix := *llvmPathFlag
+	if prefix == "" {
+		prefix = os.Getenv("MALPHAS_LLVM_PREFIX")
+	}
+	exe := hostExeName(name)
+	if prefix != "" {
+		return []string{filepath.Join(prefix, "bin", exe), filepath.Join(prefix, exe)}, true
 	}
 
-	// If not in PATH, check common Homebrew locations
-	brewPrefix := os.Getenv("HOMEBREW_PREFIX")
-	if brewPrefix == "" {
-		// Try common Homebrew prefixes
-		for _, prefix := range []string{"/opt/homebrew", "/usr/local"} {
-			optPath := filepath.Join(prefix, "opt/llvm/bin/opt")
-			if _, err := os.Stat(optPath); err == nil {
-				return optPath, nil
-			}
-		}
-	} else {
-		// Check HOMEBREW_PREFIX location
-		optPath := filepath.Join(brewPrefix, "opt/llvm/bin/opt")
-		if _, err := os.Stat(optPath); err == nil {
-			return optPath, nil
+	if path, err := exec.LookPath(name); err == nil {
+		candidates = append(candidates, path)
+	}
+
+	brewPrefixes := []string{"/opt/homebrew", "/usr/local"}
+	if brewPrefix := os.Getenv("HOMEBREW_PREFIX"); brewPrefix != "" {
+		brewPrefixes = []string{brewPrefix}
+	}
+	for _, prefix := range brewPrefixes {
+		candidates = append(candidates, filepath.Join(prefix, "opt", "llvm", "bin", name))
+	}
+	if goruntime.GOOS == "windows" {
+		// The official installer's default location
+		if programFiles := os.Getenv("ProgramFiles"); programFiles != "" {
+			candidates = append(candidates, filepath.Join(programFiles, "LLVM", "bin", exe))
 		}
 	}
 
-	return "", fmt.Errorf("opt not found in PATH or common installation locations")
+	versioned, _ := filepath.Glob(filepath.Join("/usr/lib/llvm-*/bin", name))
+	sort.Slice(versioned, func(i, j int) bool {
+		return llvmDirVersion(versioned[i]) > llvmDirVersion(versioned[j])
+	})
+	return append(candidates, versioned...), false
+}
+
+// llvmDirVersion extracts N from a /usr/lib/llvm-N/bin/<tool> path
+func llvmDirVersion(path string) int {
+	dir := filepath.Base(filepath.Dir(filepath.Dir(path)))
+	n, _ := strconv.Atoi(strings.TrimPrefix(dir, "llvm-"))
+	return n
+}
+
+// llvmMajorVersion runs `tool --version` and returns the LLVM major version.
+func llvmMajorVersion(tool string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, tool, "--version").CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run --version: %v", err)
+	}
+	m := llvmVersionPattern.FindSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("unrecognized --version output")
+	}
+	return strconv.Atoi(string(m[1]))
 }
 
-// optimizeLLVM applies LLVM optimization passes to the IR file.
-// Returns the path to the optimized IR file, or the original file if optimization fails.
-func optimizeLLVM(irFile string, optimizationLevel string) (string, error) {
-	debugLog("Starting LLVM optimization for %s (level %s)\n", irFile, optimizationLevel)
+// optimizeLLVM applies LLVM optimization passes to the IR, which opt reads
+// from its standard input and writes back to its standard output.
+// Returns the optimized IR, or the original IR if optimization fails.
+func optimizeLLVM(ir string, optimizationLevel string) string {
+	debugLog("Starting LLVM optimization (level %s)\n", optimizationLevel)
 	// Find opt tool
 	optPath, err := findOpt()
 	if err != nil {
 		debugLog("opt not found, skipping optimization\n")
-		// Optimization is optional - if opt is not found, just return original file
-		return irFile, nil
+		// Optimization is optional - if opt is not found, just return original IR
+		return ir
 	}
 
-	// Create temp file for optimized IR
-	optFile := irFile + ".opt"
-
 	// Build optimization pipeline based on level
 	var pipeline string
 	switch optimizationLevel {
 	case "0", "none":
 		// No optimizations
-		return irFile, nil
+		return ir
 	case "1", "s":
 		// Basic optimizations
 		pipeline = "default<O1>"
@@ -112,15 +186,16 @@ func optimizeLLVM(irFile string, optimizationLevel string) (string, error) {
 
 	// Run opt with the selected passes
 	// Use new pass manager syntax: -passes='pipeline'
-	args := []string{"-S", "-o", optFile, "-passes=" + pipeline, irFile}
+	args := []string{"-S", "-o", "-", "-passes=" + pipeline, "-"}
 
-	// Add timeout for optimization
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := buildStep(context.Background())
 	defer cancel()
 
 	debugLog("Running opt command: %s %v\n", optPath, args)
 	cmd := exec.CommandContext(ctx, optPath, args...)
-	var stderrBuf strings.Builder
+	cmd.Stdin = strings.NewReader(ir)
+	var stdoutBuf, stderrBuf strings.Builder
+	cmd.Stdout = &stdoutBuf
 	cmd.Stderr = &stderrBuf
 	if err := cmd.Run(); err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
@@ -128,7 +203,7 @@ func optimizeLLVM(irFile string, optimizationLevel string) (string, error) {
 		} else {
 			debugLog("Optimization failed: %v\n", err)
 		}
-		// Optimization failed - return original file
+		// Optimization failed - return original IR
 		// This is non-fatal, so we just log and continue
 		if os.Getenv("MALPHAS_DEBUG_OPT") != "" {
 			fmt.Fprintf(os.Stderr, "Warning: LLVM optimization failed: %v\n", err)
@@ -136,18 +211,317 @@ func optimizeLLVM(irFile string, optimizationLevel string) (string, error) {
 				fmt.Fprintf(os.Stderr, "opt error output: %s\n", stderrBuf.String())
 			}
 		}
-		return irFile, nil
+		return ir
 	}
 
-	debugLog("Optimization successful: %s\n", optFile)
-	// Return optimized file
-	return optFile, nil
+	debugLog("Optimization successful\n")
+	return stdoutBuf.String()
+}
+
+// verifyLLVM runs LLVM's IR verifier over ir. It returns the verifier's
+// output if the module is malformed, and "" if it is valid or opt is not
+// installed (llc still rejects broken IR later, just less helpfully).
+func verifyLLVM(ir string) string {
+	optPath, err := findOpt()
+	if err != nil {
+		debugLog("opt not found, skipping IR verification\n")
+		return ""
+	}
+
+	ctx, cancel := buildStep(context.Background())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, optPath, "-passes=verify", "-disable-output", "-")
+	cmd.Stdin = strings.NewReader(ir)
+	var stderrBuf strings.Builder
+	cmd.Stderr = &stderrBuf
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			debugLog("IR verification timed out\n")
+			return ""
+		}
+		if stderrBuf.Len() == 0 {
+			return err.Error()
+		}
+		return stderrBuf.String()
+	}
+	return ""
 }
 
 // formatter is a global formatter instance for diagnostics.
 var formatter = diag.NewFormatter()
 
-// formatDiagnostic formats and prints a diagnostic to stderr with Rust-style formatting.
+// errorFormatFlag selects how diagnostics are printed.
+var errorFormatFlag = flag.String("error-format", "human", "diagnostic output format: human, or json for one JSON object per line on stderr")
+
+// jsonErrors is whether errorFormatFlag asks for JSON.
+var jsonErrors bool
+
+// parseErrorFormat validates the value of the --error-format flag.
+func parseErrorFormat(s string) (bool, error) {
+	switch s {
+	case "", "human":
+		return false, nil
+	case "json":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid error format %q (expected human or json)", s)
+	}
+}
+
+// maxErrorsFlag caps the number of errors printed.
+var maxErrorsFlag = flag.Int("max-errors", 0, "stop printing errors after this many; 0 prints all")
+
+// colorFlag selects whether diagnostics use ANSI colors.
+var colorFlag = flag.String("color", "auto", "colored diagnostics: auto (when stderr is a terminal and NO_COLOR is unset), always, or never")
+
+// shortFlag prints each diagnostic on one line.
+var shortFlag = flag.Bool("short", false, "print each diagnostic on a single file:line:column line, without source excerpts")
+
+// contextLinesFlag is the number of source lines shown around a diagnostic.
+var contextLinesFlag = flag.Int("context-lines", 2, "source lines shown before and after the lines of a diagnostic")
+
+// diagnosticWidthFlag limits the width of the source lines of diagnostics.
+var diagnosticWidthFlag = flag.Int("diagnostic-width", 0, "max width of rendered source lines in diagnostics (default $COLUMNS, else no limit)")
+
+// parseColor validates the value of the --color flag and resolves auto:
+// colors are used when stderr is a terminal and NO_COLOR is unset or empty.
+func parseColor(s string) (bool, error) {
+	switch s {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "", "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		info, err := os.Stderr.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("invalid color mode %q (expected auto, always or never)", s)
+	}
+}
+
+// diagnosticWidth returns the max width of diagnostics: the flag if set,
+// else $COLUMNS, else 0 for no limit.
+func diagnosticWidth(flagWidth int) int {
+	if flagWidth > 0 {
+		return flagWidth
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return 0
+}
+
+// overflowFlag selects integer overflow behavior for generated code.
+var overflowFlag = flag.String("overflow", "wrap", "integer overflow behavior: wrap, panic, or checked")
+
+// overflowMode is the parsed value of overflowFlag.
+var overflowMode mir2llvm.OverflowMode
+
+// mirOptFlag selects the MIR optimization passes run before LLVM codegen.
+var mirOptFlag = flag.String("mir-opt", "", "MIR optimization passes: all, none, or a comma-separated list of fold, dce, copies, bounds (default: all if $MALPHAS_OPT sets an optimization level, else none)")
+
+// mirPasses is the parsed value of mirOptFlag.
+var mirPasses []optimize.Pass
+
+// mirOptSpec picks the MIR pass selection. An explicit --mir-opt wins;
+// otherwise every pass runs when MALPHAS_OPT asks for an optimized build.
+// MALPHAS_OPT holds an LLVM level (0-3, s, z, none, default), not pass names.
+func mirOptSpec(flagValue, optLevel string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	switch optLevel {
+	case "", "0", "none":
+		return "none"
+	}
+	return "all"
+}
+
+// cacheFlag enables reuse of monomorphized functions' IR across builds.
+var cacheFlag = flag.Bool("cache", true, "reuse generated code for generic instantiations across builds (stored in $MALPHAS_CACHE_DIR, default the user cache directory)")
+
+// buildCache returns the cache for generated code, or nil if caching is
+// disabled or there is nowhere to keep it. The salt changes whenever the
+// compiler binary does, so entries from another compiler build never match.
+func buildCache() (mir2llvm.FragmentCache, string) {
+	if !*cacheFlag {
+		return nil, ""
+	}
+	dir := os.Getenv("MALPHAS_CACHE_DIR")
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, ""
+		}
+		dir = filepath.Join(userDir, "malphas")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, ""
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return nil, ""
+	}
+	salt := fmt.Sprintf("%s:%d:%d", exe, info.Size(), info.ModTime().UnixNano())
+	return mir2llvm.DirCache(dir), salt
+}
+
+// gcFlag selects the memory manager compiled into the runtime.
+var gcFlag = flag.String("gc", "boehm", "memory management: boehm (garbage collected) or none (arena, released at exit)")
+
+// gcMode is the parsed value of gcFlag.
+var gcMode = "boehm"
+
+// parseGCMode validates the value of the --gc flag.
+func parseGCMode(s string) (string, error) {
+	switch s {
+	case "", "boehm":
+		return "boehm", nil
+	case "none":
+		return "none", nil
+	default:
+		return "", fmt.Errorf("invalid gc mode %q (expected boehm or none)", s)
+	}
+}
+
+// gcCompileFlags returns the extra clang flags for compiling runtime.c.
+// With --gc=none the runtime is built against its own arena allocator and
+// does not need the Boehm headers.
+func gcCompileFlags() []string {
+	if gcMode == "none" {
+		return []string{"-DMALPHAS_GC_NONE"}
+	}
+	if includePath := findGCIncludePath(); includePath != "" {
+		return []string{"-I" + includePath}
+	}
+	return nil
+}
+
+// gcLinkFlags returns the libraries and search paths needed to link a
+// program against the runtime.
+func gcLinkFlags() []string {
+	if gcMode == "none" {
+		return nil
+	}
+	flags := []string{"-lgc"}
+	if brewPrefix := os.Getenv("HOMEBREW_PREFIX"); brewPrefix != "" {
+		flags = append(flags, "-L"+filepath.Join(brewPrefix, "lib"))
+	} else {
+		for _, prefix := range []string{"/opt/homebrew", "/usr/local"} {
+			if _, err := os.Stat(filepath.Join(prefix, "lib", "libgc.a")); err == nil {
+				flags = append(flags, "-L"+filepath.Join(prefix, "lib"))
+				break
+			}
+		}
+	}
+	return flags
+}
+
+// panicFlag selects what the runtime does after reporting a panic.
+var panicFlag = flag.String("panic", "exit", "on panic, print the message and backtrace then: exit (status 101) or abort (raise SIGABRT, for debuggers and core dumps)")
+
+// panicMode is the parsed value of panicFlag.
+var panicMode = "exit"
+
+// parsePanicMode validates the value of the --panic flag.
+func parsePanicMode(s string) (string, error) {
+	switch s {
+	case "", "exit":
+		return "exit", nil
+	case "abort":
+		return "abort", nil
+	default:
+		return "", fmt.Errorf("invalid panic mode %q (expected exit or abort)", s)
+	}
+}
+
+// panicCompileFlags returns the extra clang flags for compiling runtime.c
+// under the selected --panic mode.
+func panicCompileFlags() []string {
+	if panicMode == "abort" {
+		return []string{"-DMALPHAS_PANIC_ABORT"}
+	}
+	return nil
+}
+
+// panicLinkFlags returns the linker flags that keep the program's function
+// names in its dynamic symbol table, where the runtime looks them up to
+// print panic backtraces. Windows programs and static executables, which
+// have no dynamic symbol table, print no backtrace.
+func panicLinkFlags() []string {
+	if target.IsWindows() || target.IsWASI() || *staticFlag {
+		return nil
+	}
+	return []string{"-rdynamic"}
+}
+
+// linkLibs is a list of C libraries given by repeated --link-lib flags.
+type linkLibs []string
+
+func (l *linkLibs) String() string { return strings.Join(*l, ",") }
+
+func (l *linkLibs) Set(s string) error {
+	if s == "" || strings.HasPrefix(s, "-") {
+		return fmt.Errorf("invalid library name %q", s)
+	}
+	*l = append(*l, s)
+	return nil
+}
+
+// linkLibFlags is the parsed value of the --link-lib flags.
+var linkLibFlags linkLibs
+
+// linkFlags returns the clang flags linking the libraries named by
+// --link-lib, for the C functions of extern declarations.
+func (l linkLibs) linkFlags() []string {
+	flags := make([]string, len(l))
+	for i, lib := range l {
+		flags[i] = "-l" + lib
+	}
+	return flags
+}
+
+// findGCIncludePath looks for the Boehm GC headers in the usual Homebrew
+// locations. An empty result means the system include path is used.
+func findGCIncludePath() string {
+	prefixes := []string{"/opt/homebrew", "/usr/local"}
+	if brewPrefix := os.Getenv("HOMEBREW_PREFIX"); brewPrefix != "" {
+		prefixes = []string{brewPrefix}
+	}
+	for _, prefix := range prefixes {
+		// Check the keg of bdw-gc before the shared include directory
+		for _, dir := range []string{filepath.Join(prefix, "opt", "bdw-gc", "include"), filepath.Join(prefix, "include")} {
+			if _, err := os.Stat(filepath.Join(dir, "gc", "gc.h")); err == nil {
+				return dir
+			}
+		}
+	}
+	return ""
+}
+
+// reportDiagnostics prints ds to stderr, separated by blank lines in the
+// human format unless --short is given.
+func reportDiagnostics(ds []diag.Diagnostic) {
+	printed := false
+	for _, d := range ds {
+		if !countDiagnostic(d) {
+			continue
+		}
+		if printed && !jsonErrors && !*shortFlag {
+			fmt.Fprintf(os.Stderr, "\n")
+		}
+		formatDiagnostic(d)
+		printed = true
+	}
+}
+
+// formatDiagnostic formats and prints a diagnostic to stderr with Rust-style
+// formatting, or as a line of JSON with --error-format=json.
 func formatDiagnostic(d diag.Diagnostic) {
 	// Ensure primary span is set if we have LabeledSpans but no primary Span
 	if len(d.LabeledSpans) > 0 && !d.Span.IsValid() {
@@ -164,6 +538,12 @@ func formatDiagnostic(d diag.Diagnostic) {
 		}
 	}
 
+	if jsonErrors {
+		if err := formatter.FormatJSON(os.Stderr, d); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		return
+	}
 	formatter.Format(d)
 }
 
@@ -176,36 +556,127 @@ func debugLog(format string, a ...interface{}) {
 func main() {
 	debugLog("Malphas compiler started (pre-flags)\n")
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: malphas [flags] <command> [arguments]\n")
+		fmt.Fprintf(os.Stderr, "Usage: malphas [flags] <command> [flags] [arguments]\n")
 		fmt.Fprintf(os.Stderr, "\nCommands:\n")
 		fmt.Fprintf(os.Stderr, "  build <file>    Compile a Malphas source file\n")
-		fmt.Fprintf(os.Stderr, "  run <file>      Compile and run a Malphas source file\n")
-		fmt.Fprintf(os.Stderr, "  fmt <file>      Format a Malphas source file\n")
+		fmt.Fprintf(os.Stderr, "  run <file> [-- args] Compile and run a Malphas source file with args (--interp: interpret it instead)\n")
+		fmt.Fprintf(os.Stderr, "  check <file>... Report the diagnostics of Malphas source files without compiling them\n")
+		fmt.Fprintf(os.Stderr, "  fmt <file>...   Format Malphas source files in place\n")
 		fmt.Fprintf(os.Stderr, "  test [path]     Run tests in the specified path (default: current directory)\n")
+		fmt.Fprintf(os.Stderr, "  bench [path]    Run the bench_* functions of the test files in path and report ns/op\n")
+		fmt.Fprintf(os.Stderr, "  repl            Start an interactive session evaluating declarations, statements and expressions\n")
+		fmt.Fprintf(os.Stderr, "  eval <code>     Run statements and print the value of a trailing expression\n")
 		fmt.Fprintf(os.Stderr, "  lsp             Start the Language Server Protocol server\n")
+		fmt.Fprintf(os.Stderr, "  demangle [sym]  Print the Malphas names of symbols (default: filter standard input)\n")
 		fmt.Fprintf(os.Stderr, "  version         Show version information\n")
+		fmt.Fprintf(os.Stderr, "\nFlags:\n")
+		flag.PrintDefaults()
 	}
+	flag.Var(warningFlags, "W", "warning level: error=<code> turns warnings with the code into errors, ignore=<code> drops them; <code> may be all. shadow enables the off-by-default lint for shadowed variables (repeatable)")
+	flag.Var(&linkLibFlags, "link-lib", "link the program against the C library `name`, as clang -l<name> (repeatable)")
 	flag.Parse()
 
-	if len(os.Args) < 2 {
+	if flag.NArg() < 1 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	// Flags may also follow the command, as in `malphas build --emit=mir x.mal`
 	command := flag.Arg(0)
-	args := flag.Args()[1:]
+	flag.CommandLine.Parse(flag.Args()[1:])
+	args := flag.Args()
+
+	mode, err := mir2llvm.ParseOverflowMode(*overflowFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	overflowMode = mode
+
+	gc, err := parseGCMode(*gcFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	gcMode = gc
+
+	panicMode, err = parsePanicMode(*panicFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	target, err = parseTarget(*targetFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkStaticTarget(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	gcMode, err = targetGCMode(gcMode, flagSet("gc"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	jsonErrors, err = parseErrorFormat(*errorFormatFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	color, err := parseColor(*colorFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	formatter = diag.NewFormatter(
+		diag.WithColor(color),
+		diag.WithShort(*shortFlag),
+		diag.WithContextLines(*contextLinesFlag),
+		diag.WithMaxWidth(diagnosticWidth(*diagnosticWidthFlag)),
+	)
+
+	passes, err := optimize.ParsePasses(mirOptSpec(*mirOptFlag, os.Getenv("MALPHAS_OPT")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	mirPasses = passes
+
+	if err := checkTimeouts(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := parseEmit(*emitFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
 
 	switch command {
 	case "build":
 		runBuild(args)
 	case "run":
 		runRun(args)
+	case "check":
+		runCheck(args)
 	case "fmt":
 		runFmt(args)
 	case "test":
 		// runTest(args)
+	case "bench":
+		runBench(args)
+	case "repl":
+		runREPL()
+	case "eval":
+		runEval(args)
 	case "lsp":
 		runLSP()
+	case "demangle":
+		runDemangle(args)
 	case "version", "-v", "--version":
 		runVersion()
 	default:
@@ -215,157 +686,133 @@ func main() {
 	}
 }
 
-func compileToTemp(filename string) (string, error) {
+// compileFile checks filename and compiles it to LLVM IR, which it returns.
+func compileFile(filename string) (string, error) {
+	typed, err := checkSource(filename)
+	if err != nil {
+		return "", err
+	}
+
+	// Compile to LLVM IR (via MIR)
+	return compileToLLVM(typed)
+}
+
+// checkSource parses and type-checks filename, reporting its diagnostics.
+// Warnings do not fail the check.
+func checkSource(filename string) (*types.TypedFile, error) {
 	// Read file
 	src, err := os.ReadFile(filename)
 	if err != nil {
-		return "", fmt.Errorf("error reading file: %v", err)
+		return nil, fmt.Errorf("error reading file: %v", err)
 	}
 
 	// Parse
+	endParse := startPhase("parse")
 	p := parser.New(string(src), parser.WithFilename(filename))
 	file := p.ParseFile()
+	endParse()
 
 	if len(p.Errors()) > 0 {
-		for i, err := range p.Errors() {
-			if i > 0 {
-				fmt.Fprintf(os.Stderr, "\n")
-			}
-			// Convert parser error to diagnostic format
-			diagSpan := diag.Span{
-				Filename: err.Span.Filename,
-				Line:     err.Span.Line,
-				Column:   err.Span.Column,
-				Start:    err.Span.Start,
-				End:      err.Span.End,
-			}
-
-			code := err.Code
-			if code == "" {
-				code = diag.Code("PARSE_ERROR")
-			}
-
-			diagErr := diag.Diagnostic{
-				Stage:    diag.StageParser,
-				Severity: err.Severity,
-				Code:     code,
-				Message:  err.Message,
-				Span:     diagSpan,
-				Help:     err.Help,
-				Notes:    err.Notes,
-			}
-
-			// Add primary labeled span
-			if err.PrimaryLabel != "" && diagSpan.IsValid() {
-				diagErr = diagErr.WithPrimarySpan(diagSpan, err.PrimaryLabel)
-			} else if diagSpan.IsValid() {
-				diagErr = diagErr.WithPrimarySpan(diagSpan, "")
-			}
-
-			// Add secondary labeled spans
-			for _, sec := range err.SecondarySpans {
-				secSpan := diag.Span{
-					Filename: sec.Span.Filename,
-					Line:     sec.Span.Line,
-					Column:   sec.Span.Column,
-					Start:    sec.Span.Start,
-					End:      sec.Span.End,
-				}
-				if secSpan.IsValid() {
-					diagErr = diagErr.WithSecondarySpan(secSpan, sec.Label)
-				}
-			}
-
-			formatDiagnostic(diagErr)
+		var ds []diag.Diagnostic
+		for _, err := range p.Errors() {
+			ds = append(ds, err.Diagnostic())
 		}
-		return "", fmt.Errorf("parse failed")
+		reportDiagnostics(ds)
+		return nil, fmt.Errorf("parse failed")
 	}
 
 	// Type Check
 	checker := types.NewChecker()
+	checker.WarnShadow = warningFlags.enabled(diag.CodeShadowedVariable)
 	// Convert filename to absolute path for module resolution
 	absFilename, err := filepath.Abs(filename)
 	if err != nil {
 		absFilename = filename // Fallback to original if abs fails
 	}
+	endCheck := startPhase("type check")
 	checker.CheckWithFilename(file, absFilename)
-
-	if len(checker.Errors) > 0 {
-		for i, err := range checker.Errors {
-			if i > 0 {
-				fmt.Fprintf(os.Stderr, "\n")
-			}
-			formatDiagnostic(err)
+	endCheck()
+
+	// Warnings are reported with the errors, after the -W flags are applied
+	ds := append(checker.Errors, warningFlags.apply(checker.Warnings)...)
+	reportDiagnostics(ds)
+	for _, d := range ds {
+		if d.Severity != diag.SeverityWarning {
+			return nil, fmt.Errorf("type check failed")
 		}
-		return "", fmt.Errorf("type check failed")
 	}
-
-	// Compile to LLVM IR (via MIR)
-	return compileToLLVM(file, checker)
+	return checker.Typed(file)
 }
 
-// compileToLLVM generates LLVM IR and returns the path to the .ll file.
-// Uses MIR as an intermediate representation (AST -> MIR -> LLVM).
-func compileToLLVM(file *ast.File, checker *types.Checker) (string, error) {
+// compileToLLVM generates LLVM IR and returns it. No file is written: the
+// IR is piped to opt and llc. Uses MIR as an intermediate representation (AST -> MIR -> LLVM).
+func compileToLLVM(typed *types.TypedFile) (string, error) {
 	debugLog("Using MIR-to-LLVM codegen\n")
 
-	// Step 1: Lower AST to MIR
-	lowerer := mir.NewLowerer(checker.ExprTypes, checker.CallTypeArgs, checker.GlobalScope, checker.MethodTable, checker.Modules)
-	mirModule, err := lowerer.LowerModule(file)
+	// Steps 1 to 4: Lower AST to MIR, monomorphize and optimize it
+	mirModule, err := optimizedMIR(typed)
 	if err != nil {
-		return "", fmt.Errorf("MIR lowering error: %v", err)
+		return "", err
 	}
 
-	// Step 2: Monomorphize generic functions
-	monomorphizer := mir.NewMonomorphizer(mirModule)
-	if err := monomorphizer.Monomorphize(); err != nil {
-		return "", fmt.Errorf("MIR monomorphization error: %v", err)
-	}
-
-	// Step 3: Generate LLVM IR from MIR
+	// Step 5: Generate LLVM IR from MIR
 	llvmGen := mir2llvm.NewGenerator()
+	llvmGen.Overflow = overflowMode
+	llvmGen.Target = target
+	llvmGen.Cache, llvmGen.CacheSalt = buildCache()
+	endCodegen := startPhase("codegen")
 	llvmIR, err := llvmGen.Generate(mirModule)
+	endCodegen()
 	if err != nil {
 		// Report LLVM codegen errors
-		if len(llvmGen.Errors) > 0 {
-			for i, diagErr := range llvmGen.Errors {
-				if i > 0 {
-					fmt.Fprintf(os.Stderr, "\n")
-				}
-				formatDiagnostic(diagErr)
-			}
-		}
+		reportDiagnostics(llvmGen.Errors)
 		return "", fmt.Errorf("MIR-to-LLVM codegen error: %v", err)
 	}
 
 	// Check for errors even if Generate didn't return an error
 	if len(llvmGen.Errors) > 0 {
-		for i, diagErr := range llvmGen.Errors {
-			if i > 0 {
-				fmt.Fprintf(os.Stderr, "\n")
-			}
-			formatDiagnostic(diagErr)
-		}
+		reportDiagnostics(llvmGen.Errors)
 		return "", fmt.Errorf("MIR-to-LLVM codegen failed with %d error(s)", len(llvmGen.Errors))
 	}
 
-	// Create temp file for LLVM IR
-	tmpFile, err := os.CreateTemp("", "malphas_*.ll")
-	if err != nil {
-		return "", fmt.Errorf("error creating temp file: %v", err)
+	// Debug: print IR to stderr for inspection
+	if os.Getenv("MALPHAS_DEBUG_IR") != "" {
+		fmt.Fprintf(os.Stderr, "Generated LLVM IR:\n%s\n", llvmIR)
 	}
-	defer tmpFile.Close()
 
-	if _, err := tmpFile.WriteString(llvmIR); err != nil {
-		return "", fmt.Errorf("error writing LLVM IR: %v", err)
+	// Step 6: Catch malformed IR here rather than as raw llc output
+	endVerify := startPhase("verify")
+	out := verifyLLVM(llvmIR)
+	endVerify()
+	if out != "" {
+		reportDiagnostics([]diag.Diagnostic{llvmGen.DiagnoseInvalidIR(llvmIR, out)})
+		return "", fmt.Errorf("generated LLVM IR failed verification")
 	}
 
-	// Debug: print IR to stderr for inspection
-	if os.Getenv("MALPHAS_DEBUG_IR") != "" {
-		fmt.Fprintf(os.Stderr, "Generated LLVM IR:\n%s\n", llvmIR)
+	return llvmIR, nil
+}
+
+// lowerToMIR lowers a checked file to MIR and monomorphizes its generic
+// functions, reporting the diagnostics of lowering.
+func lowerToMIR(typed *types.TypedFile) (*mir.Module, error) {
+	lowerer := mir.NewTypedLowerer(typed)
+	endLower := startPhase("lower to MIR")
+	mirModule, err := lowerer.LowerModule(typed.File)
+	endLower()
+	if err != nil {
+		return nil, fmt.Errorf("MIR lowering error: %v", err)
+	}
+	if len(lowerer.Errors) > 0 {
+		reportDiagnostics(lowerer.Errors)
+		return nil, fmt.Errorf("MIR lowering failed")
 	}
 
-	return tmpFile.Name(), nil
+	defer startPhase("monomorphize")()
+	monomorphizer := mir.NewMonomorphizer(mirModule)
+	if err := monomorphizer.Monomorphize(); err != nil {
+		return nil, fmt.Errorf("MIR monomorphization error: %v", err)
+	}
+	return mirModule, nil
 }
 
 func runBuild(args []string) {
@@ -374,27 +821,39 @@ func runBuild(args []string) {
 		os.Exit(1)
 	}
 	filename := args[0]
+	if *emitFlag == "mir" {
+		if err := emitMIR(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			printSummary(filename, true)
+			os.Exit(1)
+		}
+		printSummary(filename, false)
+		reportTimings()
+		return
+	}
+	if watching() {
+		runWatch(filename)
+	}
 	fmt.Printf("Building %s...\n", filename)
 
-	tmpFile, err := compileToTemp(filename)
+	ir, err := compileFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		printSummary(filename, true)
 		os.Exit(1)
 	}
-	defer os.Remove(tmpFile)
+	printSummary(filename, false)
 
 	// Determine output binary name
 	base := filepath.Base(filename)
 	ext := filepath.Ext(base)
-	outName := strings.TrimSuffix(base, ext)
+	outName := exeName(strings.TrimSuffix(base, ext))
 
 	// Find llc executable
-	llcPath, err := findLLC()
-	if err != nil {
+	if _, err := findLLC(); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		fmt.Fprintf(os.Stderr, "Note: LLVM backend requires 'llc' (LLVM compiler) to be installed\n")
-		fmt.Fprintf(os.Stderr, "  Install with: brew install llvm\n")
-		fmt.Fprintf(os.Stderr, "  Or ensure llc is in your PATH\n")
+		fmt.Fprintf(os.Stderr, "  Install with: brew install llvm, or apt install llvm\n")
 		os.Exit(1)
 	}
 
@@ -403,179 +862,61 @@ func runBuild(args []string) {
 	if optimizationLevel == "" {
 		optimizationLevel = "2" // Default to -O2
 	}
-	optimizedIRFile, err := optimizeLLVM(tmpFile, optimizationLevel)
-	if err == nil && optimizedIRFile != tmpFile {
-		// Use optimized IR file
-		defer os.Remove(optimizedIRFile)
-		tmpFile = optimizedIRFile
-	}
-
-	// Compile LLVM IR to object file
-	objFile := tmpFile + ".o"
+	endOpt := startPhase("opt")
+	ir = optimizeLLVM(ir, optimizationLevel)
+	endOpt()
 
-	// Add timeout for compilation
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-
-	fmt.Fprintf(os.Stderr, "[DEBUG] Compiling LLVM IR to object file: %s -> %s\n", tmpFile, objFile)
-	cmd := exec.CommandContext(ctx, llcPath, "-filetype=obj", "-mtriple=arm64-apple-darwin", "-o", objFile, tmpFile)
-	var stderrBuf strings.Builder
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = &stderrBuf
-	if err := cmd.Run(); err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			fmt.Fprintf(os.Stderr, "LLVM compilation timed out after 60s\n")
-			os.Exit(1)
-		}
-		fmt.Fprintf(os.Stderr, "LLVM compilation failed: %v\n", err)
-		fmt.Fprintf(os.Stderr, "  llc path: %s\n", llcPath)
-		if stderrBuf.Len() > 0 {
-			fmt.Fprintf(os.Stderr, "\nllc error output:\n%s\n", stderrBuf.String())
-		}
-		// Also print the LLVM IR for debugging if it's small enough
-		if irContent, err := os.ReadFile(tmpFile); err == nil && len(irContent) < 10000 {
-			fmt.Fprintf(os.Stderr, "\nGenerated LLVM IR (for debugging):\n%s\n", string(irContent))
-		}
+	dir, err := newBuildDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "[DEBUG] LLVM compilation successful\n")
-	defer os.Remove(objFile)
-
-	// Compile runtime library
-	runtimeDir := filepath.Join(filepath.Dir(filename), "..", "runtime")
-	if _, err := os.Stat(runtimeDir); os.IsNotExist(err) {
-		// Try relative to current directory
-		runtimeDir = "runtime"
-	}
-	runtimeC := filepath.Join(runtimeDir, "runtime.c")
-	runtimeObj := runtimeC + ".o"
-
-	// Check if runtime.c exists
-	if _, err := os.Stat(runtimeC); os.IsNotExist(err) {
-		// Try to find runtime directory relative to executable
-		exePath, _ := os.Executable()
-		if exePath != "" {
-			exeDir := filepath.Dir(exePath)
-			runtimeC = filepath.Join(exeDir, "..", "runtime", "runtime.c")
-			runtimeObj = runtimeC + ".o"
-		}
-	}
-
-	// Compile runtime if it exists
-	if _, err := os.Stat(runtimeC); err == nil {
-		// Compile runtime with GC support
-		// Note: Requires Boehm GC to be installed (libgc-dev on Ubuntu, bdw-gc on Homebrew)
-		// Try to find GC include path
-		gcIncludePath := ""
-		if brewPrefix := os.Getenv("HOMEBREW_PREFIX"); brewPrefix != "" {
-			// Check standard Homebrew location for bdw-gc
-			if _, err := os.Stat(brewPrefix + "/opt/bdw-gc/include/gc/gc.h"); err == nil {
-				gcIncludePath = brewPrefix + "/opt/bdw-gc/include"
-			} else if _, err := os.Stat(brewPrefix + "/include/gc/gc.h"); err == nil {
-				gcIncludePath = brewPrefix + "/include"
-			}
-		} else {
-			// Try common Homebrew locations
-			for _, prefix := range []string{"/opt/homebrew", "/usr/local"} {
-				if _, err := os.Stat(prefix + "/opt/bdw-gc/include/gc/gc.h"); err == nil {
-					gcIncludePath = prefix + "/opt/bdw-gc/include"
-					break
-				} else if _, err := os.Stat(prefix + "/include/gc/gc.h"); err == nil {
-					gcIncludePath = prefix + "/include"
-					break
-				}
-			}
-		}
-
-		compileArgs := []string{"-c", "-o", runtimeObj, runtimeC}
-		if gcIncludePath != "" {
-			compileArgs = append(compileArgs, "-I"+gcIncludePath)
-		}
-
-		// Use same context/timeout
-		debugLog("Compiling runtime: %s\n", runtimeC)
-		cmd = exec.CommandContext(ctx, "clang", compileArgs...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			if ctx.Err() == context.DeadlineExceeded {
-				fmt.Fprintf(os.Stderr, "Runtime compilation timed out\n")
-				os.Exit(1)
-			}
-			fmt.Fprintf(os.Stderr, "Runtime compilation failed: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Note: Boehm GC must be installed (libgc-dev on Ubuntu, bdw-gc on Homebrew)\n")
-			os.Exit(1)
-		}
-		debugLog("Runtime compilation successful\n")
-		defer os.Remove(runtimeObj)
-
-		// Link with runtime and Boehm GC library
-		linkArgs := []string{"-o", outName, objFile, runtimeObj, "-lgc"}
-		// Add library path if needed
-		if brewPrefix := os.Getenv("HOMEBREW_PREFIX"); brewPrefix != "" {
-			linkArgs = append(linkArgs, "-L"+brewPrefix+"/lib")
-		} else {
-			for _, prefix := range []string{"/opt/homebrew", "/usr/local"} {
-				if _, err := os.Stat(prefix + "/lib/libgc.a"); err == nil {
-					linkArgs = append(linkArgs, "-L"+prefix+"/lib")
-					break
-				}
-			}
-		}
-		linkArgs = append(linkArgs, "-pthread")
-		debugLog("Linking binary: %s\n", outName)
-		cmd = exec.CommandContext(ctx, "clang", linkArgs...)
-	} else {
-		// Link without runtime (will fail if runtime functions are called)
-		fmt.Fprintf(os.Stderr, "Warning: runtime.c not found, linking without runtime library\n")
-		// Still link with GC even if runtime.c is missing (in case it's needed)
-		debugLog("Linking binary without runtime: %s\n", outName)
-		cmd = exec.CommandContext(ctx, "clang", "-o", outName, objFile, "-lgc")
-	}
-
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			fmt.Fprintf(os.Stderr, "Linking timed out\n")
-			os.Exit(1)
-		}
-		fmt.Fprintf(os.Stderr, "Linking failed: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Note: LLVM backend requires 'clang' to be installed\n")
+	err = buildExecutable(context.Background(), dir, filename, ir, outName)
+	os.RemoveAll(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
-	debugLog("Linking successful\n")
 
 	fmt.Printf("Build successful: %s\n", outName)
+	reportTimings()
 }
 
 func runRun(args []string) {
 	if len(args) < 1 {
-		fmt.Fprintf(os.Stderr, "Usage: malphas run <file>\n")
+		fmt.Fprintf(os.Stderr, "Usage: malphas run <file> [-- args]\n")
 		os.Exit(1)
 	}
 	filename := args[0]
+	progArgs := programArgs(args[1:])
 	debugLog("runRun started for file: %s\n", filename)
+	if watching() {
+		runWatch(filename)
+	}
+	if *interpFlag {
+		runInterpreted(filename, progArgs)
+	}
+	if err := checkRunnable("run"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Find llc executable
-	llcPath, err := findLLC()
-	if err != nil {
+	if _, err := findLLC(); err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		fmt.Fprintf(os.Stderr, "Note: LLVM backend requires 'llc' (LLVM compiler) to be installed\n")
-		fmt.Fprintf(os.Stderr, "  Install with: brew install llvm\n")
-		fmt.Fprintf(os.Stderr, "  Or ensure llc is in your PATH\n")
+		fmt.Fprintf(os.Stderr, "  Install with: brew install llvm, or apt install llvm\n")
 		os.Exit(1)
 	}
 
 	// For LLVM backend, build and run the binary
-	debugLog("Compiling to temp file...\n")
-	tmpFile, err := compileToTemp(filename)
+	ir, err := compileFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
+		printSummary(filename, true)
 		os.Exit(1)
 	}
-	debugLog("Compiled to temp file: %s\n", tmpFile)
-	defer os.Remove(tmpFile)
+	printSummary(filename, false)
 
 	// Apply LLVM optimizations if requested
 	optimizationLevel := os.Getenv("MALPHAS_OPT")
@@ -583,180 +924,149 @@ func runRun(args []string) {
 		optimizationLevel = "2" // Default to -O2
 	}
 	debugLog("Applying optimizations (level %s)...\n", optimizationLevel)
-	optimizedIRFile, err := optimizeLLVM(tmpFile, optimizationLevel)
-	if err == nil && optimizedIRFile != tmpFile {
-		// Use optimized IR file
-		defer os.Remove(optimizedIRFile)
-		tmpFile = optimizedIRFile
-	}
+	endOpt := startPhase("opt")
+	ir = optimizeLLVM(ir, optimizationLevel)
+	endOpt()
 	debugLog("Optimization complete (or skipped)\n")
 
-	// Compile LLVM IR to object file
-	objFile := tmpFile + ".o"
+	// The binary is an intermediate too: it lives in the build directory
+	dir, err := newBuildDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	exe := filepath.Join(dir, exeName("main"))
 
-	// Add timeout for compilation
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+	err = buildExecutable(context.Background(), dir, filename, ir, exe)
+	if err != nil {
+		os.RemoveAll(dir)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
-	fmt.Fprintf(os.Stderr, "[DEBUG] Compiling LLVM IR to object file: %s -> %s\n", tmpFile, objFile)
-	cmd := exec.CommandContext(ctx, llcPath, "-filetype=obj", "-mtriple=arm64-apple-darwin", "-o", objFile, tmpFile)
-	var stderrBuf strings.Builder
+	// Run the binary
+	reportTimings()
+
+	// The program runs without a time limit unless --run-timeout sets one
+	runCtx, runCancel := withTimeout(*runTimeoutFlag)
+	debugLog("Running binary: %s\n", exe)
+	cmd := exec.CommandContext(runCtx, exe, progArgs...)
+	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = &stderrBuf
-	if err := cmd.Run(); err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			fmt.Fprintf(os.Stderr, "LLVM compilation timed out after 60s\n")
+	cmd.Stderr = os.Stderr
+	// Interrupting the compiler interrupts the program, which decides when
+	// to exit, as when --watch stops it
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	if err = cmd.Start(); err == nil {
+		go func() {
+			for sig := range interrupts {
+				cmd.Process.Signal(sig)
+			}
+		}()
+		err = cmd.Wait()
+	}
+	signal.Stop(interrupts)
+	runCancel()
+	os.RemoveAll(dir)
+	if err != nil {
+		if runCtx.Err() == context.DeadlineExceeded {
+			fmt.Fprintf(os.Stderr, "Execution timed out after %s (--run-timeout)\n", *runTimeoutFlag)
 			os.Exit(1)
 		}
-		fmt.Fprintf(os.Stderr, "LLVM compilation failed: %v\n", err)
-		fmt.Fprintf(os.Stderr, "  llc path: %s\n", llcPath)
-		if stderrBuf.Len() > 0 {
-			fmt.Fprintf(os.Stderr, "\nllc error output:\n%s\n", stderrBuf.String())
+		// Propagate the program's own exit code (e.g. from `fn main() -> int`)
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+			os.Exit(exitErr.ExitCode())
 		}
-		// Also print the LLVM IR for debugging if it's small enough
-		if irContent, err := os.ReadFile(tmpFile); err == nil && len(irContent) < 10000 {
-			fmt.Fprintf(os.Stderr, "\nGenerated LLVM IR (for debugging):\n%s\n", string(irContent))
+		// A program killed by a signal exits like it would from a shell
+		if sig, ok := terminatingSignal(err); ok {
+			fmt.Fprintf(os.Stderr, "program terminated by signal %s\n", signalName(sig))
+			os.Exit(128 + int(sig))
 		}
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "[DEBUG] LLVM compilation successful\n")
-	defer os.Remove(objFile)
+	debugLog("Execution successful\n")
+}
 
-	// Compile runtime library
-	runtimeDir := filepath.Join(filepath.Dir(filename), "..", "runtime")
-	if _, err := os.Stat(runtimeDir); os.IsNotExist(err) {
-		runtimeDir = "runtime"
-	}
-	runtimeC := filepath.Join(runtimeDir, "runtime.c")
-	runtimeObj := runtimeC + ".o"
+// signalNames names the signals that commonly end a program
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGTRAP: "SIGTRAP",
+}
 
-	// Check if runtime.c exists
-	if _, err := os.Stat(runtimeC); os.IsNotExist(err) {
-		exePath, _ := os.Executable()
-		if exePath != "" {
-			exeDir := filepath.Dir(exePath)
-			runtimeC = filepath.Join(exeDir, "..", "runtime", "runtime.c")
-			runtimeObj = runtimeC + ".o"
-		}
+// signalName returns the name of sig, such as SIGSEGV, or its number.
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
 	}
+	return strconv.Itoa(int(sig))
+}
 
-	// Create temporary binary
-	tmpBinary, err := os.CreateTemp("", "malphas_bin_*")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create temp binary: %v\n", err)
-		os.Exit(1)
+// terminatingSignal returns the signal that killed a program which ended
+// with err, if a signal did.
+func terminatingSignal(err error) (syscall.Signal, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
 	}
-	tmpBinary.Close()
-	defer os.Remove(tmpBinary.Name())
-
-	// Compile runtime if it exists
-	if _, err := os.Stat(runtimeC); err == nil {
-		// Compile runtime with GC support
-		// Note: Requires Boehm GC to be installed (libgc-dev on Ubuntu, bdw-gc on Homebrew)
-		// Try to find GC include path
-		gcIncludePath := ""
-		if brewPrefix := os.Getenv("HOMEBREW_PREFIX"); brewPrefix != "" {
-			// Check standard Homebrew location for bdw-gc
-			if _, err := os.Stat(brewPrefix + "/opt/bdw-gc/include/gc/gc.h"); err == nil {
-				gcIncludePath = brewPrefix + "/opt/bdw-gc/include"
-			} else if _, err := os.Stat(brewPrefix + "/include/gc/gc.h"); err == nil {
-				gcIncludePath = brewPrefix + "/include"
-			}
-		} else {
-			// Try common Homebrew locations
-			for _, prefix := range []string{"/opt/homebrew", "/usr/local"} {
-				if _, err := os.Stat(prefix + "/opt/bdw-gc/include/gc/gc.h"); err == nil {
-					gcIncludePath = prefix + "/opt/bdw-gc/include"
-					break
-				} else if _, err := os.Stat(prefix + "/include/gc/gc.h"); err == nil {
-					gcIncludePath = prefix + "/include"
-					break
-				}
-			}
-		}
-
-		compileArgs := []string{"-c", "-o", runtimeObj, runtimeC}
-		if gcIncludePath != "" {
-			compileArgs = append(compileArgs, "-I"+gcIncludePath)
-		}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+	return status.Signal(), true
+}
 
-		// Use same context/timeout
-		debugLog("Compiling runtime: %s\n", runtimeC)
-		cmd = exec.CommandContext(ctx, "clang", compileArgs...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			if ctx.Err() == context.DeadlineExceeded {
-				fmt.Fprintf(os.Stderr, "Runtime compilation timed out\n")
-				os.Exit(1)
-			}
-			fmt.Fprintf(os.Stderr, "Runtime compilation failed: %v\n", err)
-			fmt.Fprintf(os.Stderr, "Note: Boehm GC must be installed (libgc-dev on Ubuntu, bdw-gc on Homebrew)\n")
-			os.Exit(1)
-		}
-		debugLog("Runtime compilation successful\n")
-		defer os.Remove(runtimeObj)
-
-		// Link with runtime and Boehm GC library
-		linkArgs := []string{"-o", tmpBinary.Name(), objFile, runtimeObj, "-lgc"}
-		// Add library path if needed
-		if brewPrefix := os.Getenv("HOMEBREW_PREFIX"); brewPrefix != "" {
-			linkArgs = append(linkArgs, "-L"+brewPrefix+"/lib")
-		} else {
-			for _, prefix := range []string{"/opt/homebrew", "/usr/local"} {
-				if _, err := os.Stat(prefix + "/lib/libgc.a"); err == nil {
-					linkArgs = append(linkArgs, "-L"+prefix+"/lib")
-					break
-				}
-			}
-		}
-		linkArgs = append(linkArgs, "-pthread")
-		debugLog("Linking binary: %s\n", tmpBinary.Name())
-		cmd = exec.CommandContext(ctx, "clang", linkArgs...)
-	} else {
-		fmt.Fprintf(os.Stderr, "Warning: runtime.c not found, linking without runtime library\n")
-		// Still link with GC even if runtime.c is missing (in case it's needed)
-		debugLog("Linking binary without runtime: %s\n", tmpBinary.Name())
-		cmd = exec.CommandContext(ctx, "clang", "-o", tmpBinary.Name(), objFile, "-lgc")
+// programArgs returns the arguments of `malphas run` following the file,
+// which are passed to the program. A leading "--" separating them from the
+// file is dropped, so that arguments may look like flags.
+func programArgs(args []string) []string {
+	if len(args) > 0 && args[0] == "--" {
+		return args[1:]
 	}
+	return args
+}
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			fmt.Fprintf(os.Stderr, "Linking timed out\n")
-			os.Exit(1)
-		}
-		fmt.Fprintf(os.Stderr, "Linking failed: %v\n", err)
+func runFmt(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: malphas fmt <file>...\n")
 		os.Exit(1)
 	}
-	debugLog("Linking successful\n")
-
-	// Run the binary
-	// Create a new context for execution with its own timeout
-	runCtx, runCancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer runCancel()
-
-	debugLog("Running binary: %s\n", tmpBinary.Name())
-	cmd = exec.CommandContext(runCtx, tmpBinary.Name())
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		if runCtx.Err() == context.DeadlineExceeded {
-			fmt.Fprintf(os.Stderr, "Execution timed out after 60s\n")
-			os.Exit(1)
+	failed := false
+	for _, filename := range args {
+		if err := formatFile(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			failed = true
 		}
+	}
+	if failed {
 		os.Exit(1)
 	}
-	debugLog("Execution successful\n")
 }
 
-func runFmt(args []string) {
-	if len(args) < 1 {
-		fmt.Fprintf(os.Stderr, "Usage: malphas fmt <file>\n")
-		os.Exit(1)
+// formatFile formats filename in place, leaving it untouched if it is
+// already formatted
+func formatFile(filename string) error {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	out, err := format.Source(string(src))
+	if err != nil {
+		return err
+	}
+	if out == string(src) {
+		return nil
 	}
-	fmt.Printf("Formatting %s... (not implemented)\n", args[0])
+	return os.WriteFile(filename, []byte(out), 0644)
 }
 
 func runLSP() {
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunReportsSignal(t *testing.T) {
+	dir := t.TempDir()
+	src := "fn main() {\n    let xs: []int = [1, 2, 3];\n    let i = 7;\n    println(xs[i]);\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "abort.mal"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := append([]string{"--color=never"}, compiledFlags(t, "--panic=abort")...)
+	_, stderr, status := runCompiler(t, dir, "", append(args, "run", "abort.mal")...)
+	if status != 128+6 {
+		t.Errorf("exit status %d, want %d", status, 128+6)
+	}
+	if want := "program terminated by signal SIGABRT"; !strings.Contains(stderr, want) {
+		t.Errorf("stderr:\n%s\nwant it to contain %q", stderr, want)
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// The REPL keeps a session: the declarations entered so far and the
+// statements of a main function that accumulates every other input. Each
+// input is type-checked as part of the whole session program, which is then
+// compiled and run from the start. The output the earlier statements
+// printed is hidden, so only the new input's output and the value of a
+// trailing expression are shown. Earlier statements therefore run again
+// with each input, side effects included.
+
+// replSession is the program built up by the inputs of a REPL or eval.
+type replSession struct {
+	dir   string   // Directory of the session's source and binaries
+	items []string // Declarations, in input order
+	stmts []string // Statements of main, in input order
+	shown int      // Bytes of output the statements so far printed
+}
+
+// newReplSession returns an empty session with its own temporary directory.
+func newReplSession() (*replSession, error) {
+	dir, err := os.MkdirTemp("", "malphas_repl_*")
+	if err != nil {
+		return nil, err
+	}
+	return &replSession{dir: dir}, nil
+}
+
+// close removes the session's files.
+func (s *replSession) close() {
+	os.RemoveAll(s.dir)
+}
+
+// replSource returns the session program with items and stmts.
+func replSource(items, stmts []string) string {
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(item)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("fn main() {\n")
+	for _, stmt := range stmts {
+		b.WriteString(stmt)
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// isReplItem reports whether input is a list of declarations rather than
+// statements.
+func isReplItem(input string) bool {
+	p := parser.New(input)
+	file := p.ParseFile()
+	return len(p.Errors()) == 0 && len(file.Decls) > 0
+}
+
+// scanReplInput scans input for the brackets it leaves open, whether it
+// ends inside a string, and the offset of its last `;` outside brackets
+// (-1 if none).
+func scanReplInput(input string) (depth int, inString bool, lastSemi int) {
+	lastSemi = -1
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(input) && input[i+1] == '/':
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == ';' && depth == 0:
+			lastSemi = i
+		}
+	}
+	return depth, inString, lastSemi
+}
+
+// splitReplValue splits input into the statements before its last `;` and
+// the expression after it, whose value the REPL prints. The expression is
+// empty when input ends with `;` or a block, which are statements.
+func splitReplValue(input string) (stmts, value string) {
+	input = strings.TrimSpace(input)
+	if strings.HasSuffix(input, ";") || strings.HasSuffix(input, "}") {
+		return input, ""
+	}
+	_, _, last := scanReplInput(input)
+	return strings.TrimSpace(input[:last+1]), strings.TrimSpace(input[last+1:])
+}
+
+// replInputComplete reports whether input closes every bracket and string it
+// opens, so that the REPL stops reading continuation lines.
+func replInputComplete(input string) bool {
+	depth, inString, _ := scanReplInput(input)
+	return depth <= 0 && !inString
+}
+
+// replVoid reports whether t is the type of an expression without a value.
+func replVoid(t types.Type) bool {
+	p, ok := t.(*types.Primitive)
+	return ok && (p.Kind == types.Void || p.Kind == types.Never)
+}
+
+// replPrintable reports whether println prints values of type t.
+func replPrintable(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Primitive:
+		switch t.Kind {
+		case types.Int, types.Int8, types.Int32, types.Int64, types.U8, types.U16, types.U32, types.Usize,
+			types.Float, types.Bool, types.String:
+			return true
+		}
+	case *types.Named:
+		if t.Ref != nil {
+			return replPrintable(t.Ref)
+		}
+	}
+	return false
+}
+
+// check parses and type-checks the session program with items and stmts,
+// returning its diagnostics instead of reporting them.
+func (s *replSession) check(items, stmts []string) (*types.CheckResult, string, []diag.Diagnostic, error) {
+	filename := filepath.Join(s.dir, "repl.mal")
+	src := replSource(items, stmts)
+	if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+		return nil, "", nil, err
+	}
+	// Each input rewrites the file, so the excerpts of diagnostics must not
+	// come from an earlier version
+	formatter.SetSource(filename, src)
+	p := parser.New(src, parser.WithFilename(filename))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		var ds []diag.Diagnostic
+		for _, err := range p.Errors() {
+			ds = append(ds, err.Diagnostic())
+		}
+		return nil, filename, ds, nil
+	}
+	checker := types.NewChecker()
+	checker.CheckWithFilename(file, filename)
+	result := checker.Result()
+	return result, filename, result.Errors, nil
+}
+
+// replValueType returns the type of the expression statement ending main in
+// the checked file, or nil if main ends otherwise.
+func replValueType(result *types.CheckResult) types.Type {
+	for _, decl := range result.File.Decls {
+		fn, ok := decl.(*ast.FnDecl)
+		if !ok || fn.Name == nil || fn.Name.Name != "main" || fn.Body == nil || len(fn.Body.Stmts) == 0 {
+			continue
+		}
+		if stmt, ok := fn.Body.Stmts[len(fn.Body.Stmts)-1].(*ast.ExprStmt); ok {
+			return result.Types[stmt.Expr]
+		}
+	}
+	return nil
+}
+
+// eval adds input to the session and runs the session program, writing the
+// new output to out. An input that does not compile or whose run fails is
+// not kept.
+func (s *replSession) eval(ctx context.Context, input string, out io.Writer) error {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil
+	}
+	items, stmts := s.items, s.stmts
+	var note string
+	if isReplItem(input) {
+		items = append(items[:len(items):len(items)], input)
+	} else {
+		before, value := splitReplValue(input)
+		stmt := input
+		if value != "" {
+			stmt += ";"
+		}
+		stmts = append(stmts[:len(stmts):len(stmts)], stmt)
+		if value != "" {
+			// The value is printed if println can print it, else named
+			result, _, ds, err := s.check(items, stmts)
+			if err != nil {
+				return err
+			}
+			if len(ds) == 0 {
+				if t := replValueType(result); t != nil && replPrintable(t) {
+					stmts[len(stmts)-1] = strings.TrimSpace(before + "\nprintln(" + value + ");")
+				} else if t != nil && !replVoid(t) {
+					note = fmt.Sprintf("<value of type %s>\n", t)
+				}
+			}
+		}
+	}
+
+	result, filename, ds, err := s.check(items, stmts)
+	if err != nil {
+		return err
+	}
+	if len(ds) > 0 {
+		reportDiagnostics(ds)
+		return errReplNoCompile
+	}
+
+	// The IR is not optimized, as each binary runs once
+	typed, err := result.Typed()
+	if err != nil {
+		return err
+	}
+	ir, err := compileToLLVM(typed)
+	if err != nil {
+		return err
+	}
+	exe := filepath.Join(s.dir, exeName("repl"))
+	if err := buildExecutable(ctx, s.dir, filename, ir, exe); err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, exe)
+	output, runErr := cmd.CombinedOutput()
+	if len(output) > s.shown {
+		out.Write(output[s.shown:])
+	}
+	if runErr != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("execution timed out")
+		}
+		return fmt.Errorf("program failed: %v", runErr)
+	}
+	io.WriteString(out, note)
+	s.items, s.stmts, s.shown = items, stmts, len(output)
+	return nil
+}
+
+// errReplNoCompile is the error of an input whose diagnostics were reported.
+var errReplNoCompile = errors.New("input does not compile")
+
+// replTimeout bounds the build and run of each input.
+const replTimeout = 60 * time.Second
+
+// runEval evaluates the code given as arguments as one REPL input, printing
+// its output and the value of its trailing expression.
+func runEval(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: malphas eval <code>\n")
+		os.Exit(1)
+	}
+	if err := checkRunnable("eval"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	session, err := newReplSession()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), replTimeout)
+	err = session.eval(ctx, strings.Join(args, " "), os.Stdout)
+	cancel()
+	session.close()
+	if err != nil {
+		if !errors.Is(err, errReplNoCompile) {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// replHelp lists the REPL's commands.
+const replHelp = `Enter declarations, statements or expressions; the value of an expression
+without a trailing semicolon is printed. Input continues over several lines
+until its brackets are closed.
+  :help    show this help
+  :source  print the program of the session so far
+  :reset   forget the session's declarations and statements
+  :quit    leave (as does end of input)
+`
+
+// runREPL reads inputs from standard input and evaluates each in one
+// session until end of input or :quit.
+func runREPL() {
+	if err := checkRunnable("repl"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	session, err := newReplSession()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	defer session.close()
+
+	interactive := false
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+		fmt.Println("Malphas REPL. Type :help for help.")
+	}
+	prompt := func(p string) {
+		if interactive {
+			fmt.Print(p)
+		}
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	var input strings.Builder
+	prompt(">> ")
+	for scanner.Scan() {
+		input.WriteString(scanner.Text())
+		input.WriteString("\n")
+		if !replInputComplete(input.String()) {
+			prompt(".. ")
+			continue
+		}
+		line := strings.TrimSpace(input.String())
+		input.Reset()
+
+		switch line {
+		case ":quit", ":q":
+			return
+		case ":help":
+			fmt.Print(replHelp)
+		case ":source":
+			fmt.Print(replSource(session.items, session.stmts))
+		case ":reset":
+			session.items, session.stmts, session.shown = nil, nil, 0
+		default:
+			if strings.HasPrefix(line, ":") {
+				fmt.Fprintf(os.Stderr, "unknown command %s (try :help)\n", line)
+				break
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), replTimeout)
+			err := session.eval(ctx, line, os.Stdout)
+			cancel()
+			if err != nil && !errors.Is(err, errReplNoCompile) {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			}
+		}
+		prompt(">> ")
+	}
+	if interactive {
+		fmt.Println()
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestSplitReplValue(t *testing.T) {
+	tests := []struct {
+		input, stmts, value string
+	}{
+		{"1 + 2", "", "1 + 2"},
+		{"let x = 2; x * 3", "let x = 2;", "x * 3"},
+		{`let s = "a;b"; s`, `let s = "a;b";`, "s"},
+		{"f(a, { let b = 1; b })", "", "f(a, { let b = 1; b })"},
+		{"let x = 1;", "let x = 1;", ""},
+		{"if x > 1 { println(x); }", "if x > 1 { println(x); }", ""},
+	}
+	for _, tt := range tests {
+		stmts, value := splitReplValue(tt.input)
+		if stmts != tt.stmts || value != tt.value {
+			t.Errorf("splitReplValue(%q) = %q, %q, want %q, %q", tt.input, stmts, value, tt.stmts, tt.value)
+		}
+	}
+}
+
+func TestReplInputComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		complete bool
+	}{
+		{"let x = 1;", true},
+		{"fn f() {", false},
+		{"fn f() {\n  1\n}", true},
+		{`let s = "{";`, true},
+		{`let s = "abc`, false},
+		{"let x = (1 + // (\n", false},
+	}
+	for _, tt := range tests {
+		if got := replInputComplete(tt.input); got != tt.complete {
+			t.Errorf("replInputComplete(%q) = %v, want %v", tt.input, got, tt.complete)
+		}
+	}
+}
+
+func TestIsReplItem(t *testing.T) {
+	for _, input := range []string{"fn f() -> int { 1 }", "struct P { x: int }"} {
+		if !isReplItem(input) {
+			t.Errorf("%q should be a declaration", input)
+		}
+	}
+	for _, input := range []string{"let x = 1;", "1 + 2", "f()"} {
+		if isReplItem(input) {
+			t.Errorf("%q should be a statement", input)
+		}
+	}
+}
+
+func TestReplSessionChecksAgainstEarlierInputs(t *testing.T) {
+	session, err := newReplSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.close()
+
+	items := []string{"fn double(x: int) -> int { x * 2 }"}
+	stmts := []string{"let a = double(2);", "a + 1;"}
+	result, _, ds, err := session.check(items, stmts)
+	if err != nil || len(ds) > 0 {
+		t.Fatalf("session program should check: %v %v", err, ds)
+	}
+	if typ := replValueType(result); typ == nil || !replPrintable(typ) {
+		t.Errorf("a + 1 should have a printable type, got %v", typ)
+	}
+
+	if _, _, ds, _ := session.check(nil, stmts); len(ds) == 0 {
+		t.Error("double is undefined without the earlier declaration")
+	}
+}
+
+func TestReplDiagnosticsShowCurrentInput(t *testing.T) {
+	session, err := newReplSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.close()
+
+	var out bytes.Buffer
+	saved := formatter
+	formatter = diag.NewFormatter(diag.WithOutput(&out))
+	defer func() { formatter = saved }()
+	defer func() { errorCount, warningCount, hiddenErrors = 0, 0, 0 }()
+
+	for _, input := range []string{"aa + 1", "bbbbbb * 7"} {
+		out.Reset()
+		if err := session.eval(context.Background(), input, io.Discard); !errors.Is(err, errReplNoCompile) {
+			t.Fatalf("eval(%q) = %v, want errReplNoCompile", input, err)
+		}
+		if !strings.Contains(out.String(), input) {
+			t.Errorf("diagnostics of %q do not show it:\n%s", input, out.String())
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Programs are linked against a precompiled runtime archive,
+// libmalphas_runtime.a, built by `make runtime` and installed next to the
+// compiler. The archive defines the marker symbol malphas_runtime_abi_v<N>
+// for the ABI version of runtime.h it was built from; the compiler refuses an
+// archive built for another version, and the link requires the marker, so a
+// stale archive is reported instead of failing at run time.
+
+// runtimeABIVersion is the MALPHAS_RUNTIME_ABI_VERSION of runtime/runtime.h
+// the generated code is written against. Bump both together whenever the
+// layout of a runtime type or the signature of a runtime function changes.
+const runtimeABIVersion = 1
+
+// runtimeLibFlag names the runtime archive to link.
+var runtimeLibFlag = flag.String("runtime-lib", "", "runtime library to link (default $MALPHAS_RUNTIME, else libmalphas_runtime*.a next to the compiler)")
+
+// runtimeFromSourceFlag compiles runtime.c for each build, as for working
+// on the runtime itself.
+var runtimeFromSourceFlag = flag.Bool("runtime-from-source", false, "compile runtime/runtime.c for each build instead of linking the precompiled runtime library")
+
+// runtimeLibName returns the file name of the runtime archive built for the
+// selected --gc and --panic modes, and for musl targets against musl or for
+// WASI targets against wasi-libc.
+func runtimeLibName() string {
+	name := "libmalphas_runtime"
+	if gcMode == "none" {
+		name += "_nogc"
+	}
+	if panicMode == "abort" {
+		name += "_abort"
+	}
+	if target.IsMusl() {
+		name += "_musl"
+	}
+	if target.IsWASI() {
+		name += "_wasi"
+	}
+	return name + ".a"
+}
+
+// runtimeMakeTarget returns the make target building the runtime archives
+// for the target.
+func runtimeMakeTarget() string {
+	switch {
+	case target.IsMusl():
+		return "make runtime-musl"
+	case target.IsWASI():
+		return "make runtime-wasi"
+	}
+	return "make runtime"
+}
+
+// runtimeLibDirs returns the directories searched for the runtime archive:
+// $MALPHAS_RUNTIME if it names a directory, then the compiler's own directory
+// and the lib directories of its installation prefix.
+func runtimeLibDirs() []string {
+	var dirs []string
+	if env := os.Getenv("MALPHAS_RUNTIME"); env != "" {
+		dirs = append(dirs, env)
+	}
+	if exe, err := os.Executable(); err == nil {
+		exeDir := filepath.Dir(exe)
+		dirs = append(dirs, exeDir, filepath.Join(exeDir, "..", "lib", "malphas"), filepath.Join(exeDir, "..", "lib"))
+	}
+	return dirs
+}
+
+// findRuntimeLib returns the path of the runtime archive to link.
+func findRuntimeLib() (string, error) {
+	if *runtimeLibFlag != "" {
+		return *runtimeLibFlag, nil
+	}
+	if env := os.Getenv("MALPHAS_RUNTIME"); env != "" {
+		if info, err := os.Stat(env); err == nil && !info.IsDir() {
+			return env, nil
+		}
+	}
+	name := runtimeLibName()
+	dirs := runtimeLibDirs()
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("runtime library %s not found (searched %s)\n"+
+		"  build it with `%s`, set MALPHAS_RUNTIME to its path, or pass --runtime-from-source to compile runtime/runtime.c",
+		name, strings.Join(dirs, ", "), runtimeMakeTarget())
+}
+
+// runtimeABIMarker matches the marker symbol naming an archive's ABI version
+var runtimeABIMarker = regexp.MustCompile(`malphas_runtime_abi_v([0-9]+)`)
+
+// runtimeABI returns the ABI version the runtime archive at path was built
+// for, read from the marker symbol in its symbol table.
+func runtimeABI(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.HasPrefix(data, []byte("!<arch>\n")) {
+		return 0, fmt.Errorf("%s is not a static library", path)
+	}
+	m := runtimeABIMarker.FindSubmatch(data)
+	if m == nil {
+		return 0, fmt.Errorf("%s has no ABI version marker; it was built from an older runtime", path)
+	}
+	return strconv.Atoi(string(m[1]))
+}
+
+// checkRuntimeLib reports a runtime archive built for another ABI version.
+func checkRuntimeLib(path string) error {
+	version, err := runtimeABI(path)
+	if err != nil {
+		return fmt.Errorf("cannot use runtime library: %v\n  rebuild it with `%s`", err, runtimeMakeTarget())
+	}
+	if version != runtimeABIVersion {
+		return fmt.Errorf("runtime library %s has ABI version %d, but this compiler needs version %d\n  rebuild it with `%s`", path, version, runtimeABIVersion, runtimeMakeTarget())
+	}
+	return nil
+}
+
+// runtimeMarkerFlag returns the linker flag requiring the ABI marker of this
+// compiler's runtime version, so that linking another archive fails.
+func runtimeMarkerFlag() string {
+	return linkSymbolFlag(fmt.Sprintf("malphas_runtime_abi_v%d", runtimeABIVersion))
+}
+
+// findRuntimeSource returns the path of runtime.c for --runtime-from-source:
+// the runtime directory beside the source file's directory, in the current
+// directory, or beside the compiler's directory.
+func findRuntimeSource(filename string) (string, error) {
+	candidates := []string{
+		filepath.Join(filepath.Dir(filename), "..", "runtime", "runtime.c"),
+		filepath.Join("runtime", "runtime.c"),
+	}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(exe), "..", "runtime", "runtime.c"))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("--runtime-from-source: runtime/runtime.c not found")
+}
+
+// runtimeLinkArgs returns the clang arguments that link a program compiled
+// from filename against the runtime. The precompiled archive is used unless
+// --runtime-from-source is given, in which case runtime.c is compiled for the
+// selected modes into the build directory dir.
+func runtimeLinkArgs(ctx context.Context, dir, filename string) ([]string, error) {
+	if !*runtimeFromSourceFlag {
+		lib, err := findRuntimeLib()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkRuntimeLib(lib); err != nil {
+			return nil, err
+		}
+		debugLog("Linking runtime library: %s\n", lib)
+		return []string{lib, runtimeMarkerFlag()}, nil
+	}
+
+	runtimeC, err := findRuntimeSource(filename)
+	if err != nil {
+		return nil, err
+	}
+	obj := filepath.Join(dir, "runtime.o")
+
+	// With the default --gc=boehm this requires Boehm GC (libgc-dev on
+	// Ubuntu, bdw-gc on Homebrew)
+	compileArgs := append(cTargetFlags(), "-c", "-o", obj, runtimeC)
+	compileArgs = append(compileArgs, gcCompileFlags()...)
+	compileArgs = append(compileArgs, panicCompileFlags()...)
+	debugLog("Compiling runtime: %s\n", runtimeC)
+	stepCtx, cancel := buildStep(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(stepCtx, cCompiler(), compileArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	endRuntime := startPhase("compile runtime")
+	err = cmd.Run()
+	endRuntime()
+	if err != nil {
+		if stepCtx.Err() == context.DeadlineExceeded {
+			return nil, stepTimedOut(ctx, "runtime compilation")
+		}
+		msg := fmt.Sprintf("runtime compilation failed: %v", err)
+		if gcMode != "none" {
+			msg += "\nNote: Boehm GC must be installed (libgc-dev on Ubuntu, bdw-gc on Homebrew), or build with --gc=none"
+		}
+		return nil, fmt.Errorf("%s", msg)
+	}
+	debugLog("Runtime compilation successful\n")
+	return []string{obj}, nil
+}
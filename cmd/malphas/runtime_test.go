@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeABIMatchesHeader(t *testing.T) {
+	header, err := os.ReadFile(filepath.Join("..", "..", "runtime", "runtime.h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("#define MALPHAS_RUNTIME_ABI_VERSION %d\n", runtimeABIVersion)
+	if !strings.Contains(string(header), want) {
+		t.Errorf("runtime.h should define the ABI version this compiler expects: %q", want)
+	}
+}
+
+func TestCheckRuntimeLib(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	current := write("current.a", fmt.Sprintf("!<arch>\n/ ... malphas_runtime_abi_v%d ...", runtimeABIVersion))
+	if err := checkRuntimeLib(current); err != nil {
+		t.Errorf("current library refused: %v", err)
+	}
+
+	tests := []struct {
+		name, content, want string
+	}{
+		{"stale.a", "!<arch>\n/ ... malphas_runtime_abi_v0 ...", "has ABI version 0, but this compiler needs version"},
+		{"unmarked.a", "!<arch>\n/ ... runtime_alloc ...", "has no ABI version marker"},
+		{"object.o", "\x7fELF ... malphas_runtime_abi_v1", "is not a static library"},
+	}
+	for _, tt := range tests {
+		err := checkRuntimeLib(write(tt.name, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRuntimeLibName(t *testing.T) {
+	defer func(gc, p string) { gcMode, panicMode = gc, p }(gcMode, panicMode)
+	tests := []struct{ gc, panic, want string }{
+		{"boehm", "exit", "libmalphas_runtime.a"},
+		{"none", "exit", "libmalphas_runtime_nogc.a"},
+		{"boehm", "abort", "libmalphas_runtime_abort.a"},
+		{"none", "abort", "libmalphas_runtime_nogc_abort.a"},
+	}
+	for _, tt := range tests {
+		gcMode, panicMode = tt.gc, tt.panic
+		if got := runtimeLibName(); got != tt.want {
+			t.Errorf("--gc=%s --panic=%s: got %s, want %s", tt.gc, tt.panic, got, tt.want)
+		}
+	}
+}
+
+func TestFindRuntimeLibFromEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, runtimeLibName())
+	if err := os.WriteFile(lib, []byte("!<arch>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MALPHAS_RUNTIME", dir)
+	if got, err := findRuntimeLib(); err != nil || got != lib {
+		t.Errorf("directory in MALPHAS_RUNTIME: got %q, %v; want %q", got, err, lib)
+	}
+	t.Setenv("MALPHAS_RUNTIME", lib)
+	if got, err := findRuntimeLib(); err != nil || got != lib {
+		t.Errorf("file in MALPHAS_RUNTIME: got %q, %v; want %q", got, err, lib)
+	}
+}
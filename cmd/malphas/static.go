@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// staticFlag links programs statically, for self-contained binaries that
+// run in minimal containers.
+var staticFlag = flag.Bool("static", false, "link the program statically, with the runtime, the GC and the C library, into a self-contained binary (Linux only; use a *-linux-musl --target to link against musl)")
+
+// checkStaticTarget reports --static for a target without static
+// executables.
+func checkStaticTarget() error {
+	if !*staticFlag {
+		return nil
+	}
+	switch target.OS() {
+	case "linux":
+		return nil
+	case "darwin":
+		return fmt.Errorf("--static is not supported for %s: macOS has no static executables", target.Triple)
+	case "windows":
+		return fmt.Errorf("--static is not supported for %s: Windows programs already link the C runtime statically and only need system DLLs", target.Triple)
+	case "wasi":
+		return fmt.Errorf("--static is not supported for %s: a WebAssembly module is always self-contained", target.Triple)
+	}
+	return fmt.Errorf("--static is only supported for Linux targets, not %s", target.Triple)
+}
+
+// staticLinkFlags returns the flags of a static link.
+func staticLinkFlags() []string {
+	if !*staticFlag {
+		return nil
+	}
+	return []string{"-static"}
+}
+
+// staticLib is a library a static link needs the archive of, with what to do
+// when it is missing.
+type staticLib struct {
+	name string
+	hint string
+}
+
+// staticLibs returns the libraries, besides the runtime archive, that a
+// static link of a program needs as archives.
+func staticLibs() []staticLib {
+	libc := staticLib{"c", "install the static C library (libc6-dev on Debian/Ubuntu, glibc-static on Fedora), or link against musl with --target=" + target.Arch() + "-unknown-linux-musl"}
+	if target.IsMusl() {
+		libc.hint = "install musl (musl-tools on Debian/Ubuntu, musl on Alpine) so that musl-gcc links against it"
+	}
+	libs := []staticLib{libc}
+	if gcMode != "none" {
+		hint := "install the static Boehm GC (libgc-dev on Debian/Ubuntu, gc-dev on Alpine), or build with --gc=none"
+		if target.IsMusl() {
+			hint = "build the Boehm GC with musl-gcc and install it into musl's library directory, or build with --gc=none"
+		}
+		libs = append(libs, staticLib{"gc", hint})
+	}
+	for _, lib := range linkLibFlags {
+		libs = append(libs, staticLib{lib, "install the static version of the library given to --link-lib"})
+	}
+	return libs
+}
+
+// findStaticLib returns the path of the archive lib<name>.a in the library
+// search path of cCompiler, or "" if it has none. It is a variable so that
+// tests can stand in for the compiler.
+var findStaticLib = func(ctx context.Context, name string) string {
+	args := append(cTargetFlags(), "-print-file-name=lib"+name+".a")
+	out, err := exec.CommandContext(ctx, cCompiler(), args...).Output()
+	if err != nil {
+		return ""
+	}
+	// The compiler echoes the bare file name when it does not find it
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		return ""
+	}
+	return path
+}
+
+// checkStaticLibs reports the libraries of a static link that have no
+// archive, since the linker's own error for them names no remedy.
+func checkStaticLibs(ctx context.Context) error {
+	var missing []string
+	for _, lib := range staticLibs() {
+		if findStaticLib(ctx, lib.name) == "" {
+			missing = append(missing, fmt.Sprintf("  lib%s.a (-l%s): %s", lib.name, lib.name, lib.hint))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("--static: %s finds no static archive for:\n%s", cCompiler(), strings.Join(missing, "\n"))
+	}
+	return nil
+}
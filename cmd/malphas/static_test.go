@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/codegen/mir2llvm"
+)
+
+func TestCheckStaticTarget(t *testing.T) {
+	defer func(saved mir2llvm.Target, static bool) { target, *staticFlag = saved, static }(target, *staticFlag)
+	*staticFlag = true
+
+	tests := []struct{ triple, want string }{
+		{"x86_64-unknown-linux-gnu", ""},
+		{"aarch64-unknown-linux-musl", ""},
+		{"arm64-apple-darwin", "macOS has no static executables"},
+		{"x86_64-pc-windows-msvc", "link the C runtime statically"},
+	}
+	for _, tt := range tests {
+		target, _ = mir2llvm.ParseTarget(tt.triple)
+		err := checkStaticTarget()
+		if (err == nil) != (tt.want == "") || err != nil && !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.triple, err, tt.want)
+		}
+	}
+}
+
+func TestCheckStaticLibsNamesMissingArchives(t *testing.T) {
+	defer func(saved func(context.Context, string) string, gc string, libs linkLibs) {
+		findStaticLib, gcMode, linkLibFlags = saved, gc, libs
+	}(findStaticLib, gcMode, linkLibFlags)
+	defer func(saved mir2llvm.Target) { target = saved }(target)
+	target, _ = mir2llvm.ParseTarget("x86_64-unknown-linux-gnu")
+
+	installed := map[string]bool{"c": true}
+	findStaticLib = func(_ context.Context, name string) string {
+		if installed[name] {
+			return "/usr/lib/lib" + name + ".a"
+		}
+		return ""
+	}
+
+	gcMode, linkLibFlags = "boehm", linkLibs{"sqlite3"}
+	err := checkStaticLibs(context.Background())
+	if err == nil {
+		t.Fatal("missing libgc.a and libsqlite3.a should be reported")
+	}
+	for _, want := range []string{"libgc.a (-lgc)", "--gc=none", "libsqlite3.a (-lsqlite3)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error should mention %q:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "libc.a") {
+		t.Errorf("the installed libc.a should not be reported:\n%v", err)
+	}
+
+	gcMode, linkLibFlags = "none", nil
+	if err := checkStaticLibs(context.Background()); err != nil {
+		t.Errorf("with --gc=none only libc.a is needed: %v", err)
+	}
+}
+
+func TestMuslRuntimeLibName(t *testing.T) {
+	defer func(saved mir2llvm.Target, gc, p string) { target, gcMode, panicMode = saved, gc, p }(target, gcMode, panicMode)
+	target, _ = mir2llvm.ParseTarget("x86_64-unknown-linux-musl")
+	gcMode, panicMode = "none", "exit"
+	if got := runtimeLibName(); got != "libmalphas_runtime_nogc_musl.a" {
+		t.Errorf("got %s, want libmalphas_runtime_nogc_musl.a", got)
+	}
+	if got := runtimeMakeTarget(); got != "make runtime-musl" {
+		t.Errorf("got %s, want make runtime-musl", got)
+	}
+}
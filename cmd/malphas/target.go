@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	goruntime "runtime"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/codegen/mir2llvm"
+)
+
+// targetFlag selects the platform programs are built for.
+var targetFlag = flag.String("target", "", "target triple of the built program, e.g. x86_64-pc-windows-msvc, arm64-apple-darwin or wasm32-wasi (default: the host)")
+
+// target is the parsed value of targetFlag.
+var target = mir2llvm.DefaultTarget
+
+// hostTriple returns the target triple of the machine the compiler runs on.
+// Windows defaults to the MSVC environment, which clang targets there.
+func hostTriple() string {
+	arch := "x86_64"
+	if goruntime.GOARCH == "arm64" {
+		arch = "aarch64"
+	}
+	switch goruntime.GOOS {
+	case "darwin":
+		if arch == "aarch64" {
+			return "arm64-apple-darwin"
+		}
+		return arch + "-apple-darwin"
+	case "windows":
+		return arch + "-pc-windows-msvc"
+	}
+	return arch + "-unknown-linux-gnu"
+}
+
+// parseTarget validates the value of the --target flag; empty selects the
+// host.
+func parseTarget(s string) (mir2llvm.Target, error) {
+	if s == "" {
+		s = hostTriple()
+	}
+	return mir2llvm.ParseTarget(s)
+}
+
+// crossCompiling reports whether programs are built for another platform
+// than the compiler's, so they cannot be run here. A program for another C
+// library of the host's platform, such as musl, runs.
+func crossCompiling() bool {
+	host, _ := mir2llvm.ParseTarget(hostTriple())
+	return target.Arch() != host.Arch() || target.OS() != host.OS()
+}
+
+// exeName returns the file name of the program called name on the target.
+func exeName(name string) string {
+	switch {
+	case target.IsWindows() && !strings.HasSuffix(strings.ToLower(name), ".exe"):
+		return name + ".exe"
+	case target.IsWASI() && !strings.HasSuffix(name, ".wasm"):
+		return name + ".wasm"
+	}
+	return name
+}
+
+// hostExeName returns the file name of the host tool called name.
+func hostExeName(name string) string {
+	if goruntime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// llcArgs returns the llc arguments compiling the IR on llc's standard input
+// to the object file objFile for the target. The code is position independent, as the
+// executables clang links on Linux and macOS are, except in a WebAssembly
+// module, whose memory is its own.
+func llcArgs(objFile string) []string {
+	args := []string{"-filetype=obj", "-mtriple=" + target.Triple}
+	if !target.IsWASI() {
+		args = append(args, "-relocation-model=pic")
+	}
+	return append(args, "-o", objFile, "-")
+}
+
+// cCompiler returns the C compiler that compiles the runtime and links
+// programs: clang, or for musl targets of the host's architecture the
+// musl-gcc wrapper when it is installed, since it brings musl's headers and
+// libraries along.
+func cCompiler() string {
+	if target.IsMusl() && !crossCompiling() {
+		if _, err := exec.LookPath("musl-gcc"); err == nil {
+			return "musl-gcc"
+		}
+	}
+	return "clang"
+}
+
+// cTargetFlags returns the flags selecting the target for cCompiler, needed
+// only for another target than the host's, which clang defaults to. For
+// WASI, $WASI_SYSROOT names the wasi-libc sysroot, such as wasi-sdk's
+// share/wasi-sysroot, unless clang was configured with one.
+func cTargetFlags() []string {
+	if target.Triple == hostTriple() || cCompiler() != "clang" {
+		return nil
+	}
+	flags := []string{"--target=" + target.Triple}
+	if sysroot := os.Getenv("WASI_SYSROOT"); target.IsWASI() && sysroot != "" {
+		flags = append(flags, "--sysroot="+sysroot)
+	}
+	return flags
+}
+
+// platformLinkFlags returns the system libraries the runtime needs on the
+// target: POSIX threads, or Winsock on Windows, where threads and
+// synchronization come from kernel32, which is always linked. WASI programs
+// run on a single thread and need nothing beyond wasi-libc.
+func platformLinkFlags() []string {
+	switch {
+	case target.IsWindows():
+		return []string{"-lws2_32"}
+	case target.IsWASI():
+		return nil
+	}
+	return []string{"-pthread"}
+}
+
+// targetGCMode returns the memory manager of programs for the target, given
+// the --gc mode and whether the flag was set. The Boehm collector has no
+// WASI port, so WASI programs use the arena unless --gc=boehm was asked for.
+func targetGCMode(mode string, explicit bool) (string, error) {
+	if !target.IsWASI() || mode == "none" {
+		return mode, nil
+	}
+	if explicit {
+		return "", fmt.Errorf("--gc=%s is not supported for %s: the Boehm GC does not run under WASI; use --gc=none", mode, target.Triple)
+	}
+	return "none", nil
+}
+
+// flagSet reports whether the flag called name was given on the command
+// line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// linkSymbolFlag returns the linker flag making the link require symbol,
+// spelled for the target's linker and symbol naming.
+func linkSymbolFlag(symbol string) string {
+	switch {
+	case target.OS() == "darwin":
+		return "-Wl,-u,_" + symbol
+	case target.IsMSVC():
+		return "-Wl,/include:" + symbol
+	}
+	return "-Wl,-u," + symbol
+}
+
+// targetLinkArgs returns the cCompiler arguments, other than the inputs and
+// the libraries of the runtime, that link a program for the target. With
+// --static it first checks that every library has a static archive.
+func targetLinkArgs(ctx context.Context) ([]string, error) {
+	if *staticFlag {
+		if err := checkStaticLibs(ctx); err != nil {
+			return nil, err
+		}
+	}
+	args := cTargetFlags()
+	args = append(args, staticLinkFlags()...)
+	args = append(args, gcLinkFlags()...)
+	args = append(args, linkLibFlags.linkFlags()...)
+	args = append(args, panicLinkFlags()...)
+	return append(args, platformLinkFlags()...), nil
+}
+
+// checkRunnable reports a program built for another platform, which `run`
+// and `test` cannot execute.
+func checkRunnable(command string) error {
+	if target.IsWASI() {
+		return fmt.Errorf("%s: cannot run a program built for %s directly; use `malphas build --target=%s` and run the .wasm module with a WASI runtime such as wasmtime",
+			command, target.Triple, target.Triple)
+	}
+	if crossCompiling() {
+		return fmt.Errorf("%s: cannot run a program built for %s on this %s host; use `malphas build --target=%s`",
+			command, target.Triple, hostTriple(), target.Triple)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/codegen/mir2llvm"
+)
+
+func TestParseTargetDefaultsToHost(t *testing.T) {
+	got, err := parseTarget("")
+	if err != nil {
+		t.Fatalf("the host %s should be a supported target: %v", hostTriple(), err)
+	}
+	if got.Triple != hostTriple() {
+		t.Errorf("got %s, want the host %s", got.Triple, hostTriple())
+	}
+	if _, err := parseTarget("riscv64-unknown-linux-gnu"); err == nil {
+		t.Error("riscv64 should be rejected")
+	}
+}
+
+func TestTargetLinkArgs(t *testing.T) {
+	defer func(saved mir2llvm.Target) { target = saved }(target)
+
+	tests := []struct {
+		triple   string
+		exe      string
+		marker   string
+		platform []string
+		panic    []string
+	}{
+		{"x86_64-unknown-linux-gnu", "hello", "-Wl,-u,malphas_runtime_abi_v1", []string{"-pthread"}, []string{"-rdynamic"}},
+		{"arm64-apple-darwin", "hello", "-Wl,-u,_malphas_runtime_abi_v1", []string{"-pthread"}, []string{"-rdynamic"}},
+		{"x86_64-pc-windows-msvc", "hello.exe", "-Wl,/include:malphas_runtime_abi_v1", []string{"-lws2_32"}, nil},
+		{"x86_64-w64-mingw32", "hello.exe", "-Wl,-u,malphas_runtime_abi_v1", []string{"-lws2_32"}, nil},
+		{"wasm32-wasi", "hello.wasm", "-Wl,-u,malphas_runtime_abi_v1", nil, nil},
+	}
+	for _, tt := range tests {
+		var err error
+		if target, err = parseTarget(tt.triple); err != nil {
+			t.Fatal(err)
+		}
+		if got := exeName("hello"); got != tt.exe {
+			t.Errorf("%s: program name %q, want %q", tt.triple, got, tt.exe)
+		}
+		if got := linkSymbolFlag("malphas_runtime_abi_v1"); got != tt.marker {
+			t.Errorf("%s: marker flag %q, want %q", tt.triple, got, tt.marker)
+		}
+		if got := platformLinkFlags(); !reflect.DeepEqual(got, tt.platform) {
+			t.Errorf("%s: platform flags %q, want %q", tt.triple, got, tt.platform)
+		}
+		if got := panicLinkFlags(); !reflect.DeepEqual(got, tt.panic) {
+			t.Errorf("%s: panic flags %q, want %q", tt.triple, got, tt.panic)
+		}
+		if got := llcArgs("a.o")[1]; got != "-mtriple="+tt.triple {
+			t.Errorf("%s: llc given %q", tt.triple, got)
+		}
+	}
+}
+
+func TestWASITarget(t *testing.T) {
+	defer func(saved mir2llvm.Target, gc, p string) { target, gcMode, panicMode = saved, gc, p }(target, gcMode, panicMode)
+	target, _ = mir2llvm.ParseTarget("wasm32-wasi")
+
+	if mode, err := targetGCMode("boehm", false); err != nil || mode != "none" {
+		t.Errorf("WASI programs should default to --gc=none, got %q, %v", mode, err)
+	}
+	if _, err := targetGCMode("boehm", true); err == nil {
+		t.Error("--gc=boehm should be rejected for WASI")
+	}
+	gcMode, panicMode = "none", "exit"
+	if got := runtimeLibName(); got != "libmalphas_runtime_nogc_wasi.a" {
+		t.Errorf("got %s, want libmalphas_runtime_nogc_wasi.a", got)
+	}
+	if got := llcArgs("a.o"); slices.Contains(got, "-relocation-model=pic") {
+		t.Errorf("a WebAssembly module should not be position independent: %q", got)
+	}
+	if err := checkRunnable("run"); err == nil || !strings.Contains(err.Error(), "wasmtime") {
+		t.Errorf("checkRunnable() = %v, want a pointer to a WASI runtime", err)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
 	"github.com/malphas-lang/malphas-lang/internal/parser"
@@ -23,6 +26,10 @@ type TestResult struct {
 
 // runTest executes the test command
 func runTest(args []string) {
+	if err := checkRunnable("test"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
 	if len(args) == 0 {
 		// Run all tests in current directory and subdirectories
 		runAllTests(".")
@@ -72,8 +79,8 @@ func runAllTests(path string) {
 	var failedTests int
 
 	// Run each test file
-	for _, testFile := range testFiles {
-		results := runTestFile(testFile)
+	for _, checked := range checkTestFiles(testFiles) {
+		results := runTestFile(checked)
 		for _, result := range results {
 			totalTests++
 			if result.Passed {
@@ -111,8 +118,8 @@ func findTestFiles(dir string) ([]string, error) {
 			return err
 		}
 
-		// Skip hidden directories
-		if info.IsDir() && strings.HasPrefix(info.Name(), ".") {
+		// Skip hidden directories, but not the one given, which may be "."
+		if info.IsDir() && path != dir && strings.HasPrefix(info.Name(), ".") {
 			return filepath.SkipDir
 		}
 
@@ -136,50 +143,78 @@ func findTestFiles(dir string) ([]string, error) {
 	return testFiles, err
 }
 
-// runTestFile runs all tests in a single test file
-func runTestFile(filename string) []TestResult {
-	// Read and parse the test file
+// checkedTestFile is a test file that has been read, parsed and type
+// checked, or the error that stopped it
+type checkedTestFile struct {
+	filename string
+	result   *types.CheckResult
+	err      error
+}
+
+// checkTestFiles parses and type checks the test files, several at once,
+// and returns them in the order given
+func checkTestFiles(filenames []string) []*checkedTestFile {
+	program := types.NewProgram()
+	checked := make([]*checkedTestFile, len(filenames))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < min(runtime.GOMAXPROCS(0), len(filenames)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				checked[j] = checkTestFile(program, filenames[j])
+			}
+		}()
+	}
+	for i := range filenames {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return checked
+}
+
+// checkTestFile reads, parses and type checks a test file
+func checkTestFile(program *types.Program, filename string) *checkedTestFile {
+	checked := &checkedTestFile{filename: filename}
 	src, err := os.ReadFile(filename)
 	if err != nil {
-		return []TestResult{
-			{
-				Name:   filepath.Base(filename),
-				Passed: false,
-				Error:  fmt.Errorf("failed to read file: %v", err),
-			},
-		}
+		checked.err = fmt.Errorf("failed to read file: %v", err)
+		return checked
 	}
 
 	p := parser.New(string(src), parser.WithFilename(filename))
 	file := p.ParseFile()
-
 	if len(p.Errors()) > 0 {
-		return []TestResult{
-			{
-				Name:   filepath.Base(filename),
-				Passed: false,
-				Error:  fmt.Errorf("parse errors: %v", p.Errors()),
-			},
-		}
+		checked.err = fmt.Errorf("parse errors: %v", p.Errors())
+		return checked
 	}
 
-	// Type check
-	checker := types.NewChecker()
 	absFilename, err := filepath.Abs(filename)
 	if err != nil {
 		absFilename = filename
 	}
-	checker.CheckWithFilename(file, absFilename)
+	checked.result = program.Check(file, absFilename)
+	if checked.result.Failed() {
+		checked.err = fmt.Errorf("type check errors: %v", checked.result.Errors)
+	}
+	return checked
+}
 
-	if len(checker.Errors) > 0 {
+// runTestFile runs all tests in a single checked test file
+func runTestFile(checked *checkedTestFile) []TestResult {
+	filename := checked.filename
+	if checked.err != nil {
 		return []TestResult{
 			{
 				Name:   filepath.Base(filename),
 				Passed: false,
-				Error:  fmt.Errorf("type check errors: %v", checker.Errors),
+				Error:  checked.err,
 			},
 		}
 	}
+	file := checked.result.File
 
 	// Find all test functions (functions starting with "test_")
 	testFunctions := findTestFunctions(file)
@@ -198,7 +233,7 @@ func runTestFile(filename string) []TestResult {
 		}
 		if hasMain {
 			return []TestResult{
-				runSingleTest(filename, file, checker, filepath.Base(filename)),
+				runSingleTest(filename, checked.result, filepath.Base(filename)),
 			}
 		}
 		// No test functions and no main - skip this file
@@ -236,7 +271,7 @@ func runTestFile(filename string) []TestResult {
 
 	// Run the test file once - main() should call all test functions
 	// If any test fails (panics or returns error), the whole file fails
-	result := runSingleTest(filename, file, checker, filepath.Base(filename))
+	result := runSingleTest(filename, checked.result, filepath.Base(filename))
 
 	// Report the same result for all test functions
 	// This is a limitation: we can't tell which specific test failed
@@ -270,93 +305,37 @@ func findTestFunctions(file *ast.File) []*ast.FnDecl {
 }
 
 // runSingleTest runs a single test by compiling and executing it
-func runSingleTest(filename string, file *ast.File, checker *types.Checker, testName string) TestResult {
-	// Compile the test file to LLVM IR (file and checker are already parsed/checked)
-	irFile, err := compileToLLVM(file, checker)
-	if err != nil {
-		return TestResult{
-			Name:   testName,
-			Passed: false,
-			Error:  fmt.Errorf("compilation failed: %v", err),
-		}
+func runSingleTest(filename string, checked *types.CheckResult, testName string) TestResult {
+	// Compile the test file to LLVM IR (it is already parsed and checked)
+	ir := ""
+	typed, err := checked.Typed()
+	if err == nil {
+		ir, err = compileToLLVM(typed)
 	}
-	defer os.Remove(irFile) // Clean up temp file
-
-	// Find llc
-	llcPath, err := findLLC()
 	if err != nil {
 		return TestResult{
 			Name:   testName,
 			Passed: false,
-			Error:  fmt.Errorf("llc not found: %v", err),
-		}
-	}
-
-	// Compile to object file
-	objFile := irFile + ".o"
-	cmd := exec.Command(llcPath, "-filetype=obj", "-mtriple=arm64-apple-darwin", "-o", objFile, irFile)
-	var llcStderr strings.Builder
-	cmd.Stderr = &llcStderr
-	if err := cmd.Run(); err != nil {
-		return TestResult{
-			Name:   testName,
-			Passed: false,
-			Error:  fmt.Errorf("llc failed: %v\n%s", err, llcStderr.String()),
+			Error:  fmt.Errorf("compilation failed: %v", err),
 		}
 	}
-	defer os.Remove(objFile)
 
-	// Create temp executable
-	exeFile, err := os.CreateTemp("", "malphas_test_*.exe")
+	// Build the executable in a directory of its own
+	dir, err := newBuildDir()
 	if err != nil {
 		return TestResult{
 			Name:   testName,
 			Passed: false,
-			Error:  fmt.Errorf("failed to create temp file: %v", err),
+			Error:  err,
 		}
 	}
-	exeFile.Close()
-	exePath := exeFile.Name()
-	defer os.Remove(exePath)
-
-	// Find runtime object file
-	runtimeObj := filepath.Join("runtime", "runtime.o")
-	if _, err := os.Stat(runtimeObj); os.IsNotExist(err) {
-		// Try alternative paths
-		runtimeObj = filepath.Join(filepath.Dir(filename), "..", "runtime", "runtime.o")
-		if _, err := os.Stat(runtimeObj); os.IsNotExist(err) {
-			runtimeObj = filepath.Join("..", "runtime", "runtime.o")
-		}
-	}
-
-	// Link with runtime
-	linkArgs := []string{"-o", exePath, objFile}
-	if _, err := os.Stat(runtimeObj); err == nil {
-		linkArgs = append(linkArgs, runtimeObj)
-	}
-
-	// Add library path for libgc if needed
-	if brewPrefix := os.Getenv("HOMEBREW_PREFIX"); brewPrefix != "" {
-		linkArgs = append(linkArgs, "-L"+brewPrefix+"/lib")
-	} else {
-		for _, prefix := range []string{"/opt/homebrew", "/usr/local"} {
-			if _, err := os.Stat(prefix + "/lib/libgc.a"); err == nil {
-				linkArgs = append(linkArgs, "-L"+prefix+"/lib")
-				break
-			}
-		}
-	}
-
-	linkArgs = append(linkArgs, "-lgc", "-pthread")
-
-	linkCmd := exec.Command("clang", linkArgs...)
-	var linkStderr strings.Builder
-	linkCmd.Stderr = &linkStderr
-	if err := linkCmd.Run(); err != nil {
+	defer os.RemoveAll(dir)
+	exePath := filepath.Join(dir, exeName("test"))
+	if err := buildExecutable(context.Background(), dir, filename, ir, exePath); err != nil {
 		return TestResult{
 			Name:   testName,
 			Passed: false,
-			Error:  fmt.Errorf("linking failed: %v\n%s", err, linkStderr.String()),
+			Error:  err,
 		}
 	}
 
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var timingsFlag = flag.Bool("timings", false, "print how long each compiler phase took (parse, check, lowering, codegen, opt, llc, link) to stderr")
+
+var traceFlag = flag.String("trace", "", "write the compiler phases to `file` as a Chrome trace, for chrome://tracing or Perfetto")
+
+// phase is one timed step of the compiler. Phases started while another is
+// running are nested in it.
+type phase struct {
+	name  string
+	start time.Time
+	dur   time.Duration
+	depth int
+}
+
+// phaseTimer records the phases of a compiler run in the order they start.
+// The compiler runs its phases one after another, so it needs no locking.
+type phaseTimer struct {
+	start  time.Time
+	phases []*phase
+	depth  int
+}
+
+var timer = &phaseTimer{start: time.Now()}
+
+// startPhase starts timing the phase name and returns the function that
+// ends it, as in
+//
+//	defer startPhase("parse")()
+func startPhase(name string) func() {
+	return timer.startPhase(name)
+}
+
+func (t *phaseTimer) startPhase(name string) func() {
+	p := &phase{name: name, start: time.Now(), depth: t.depth}
+	t.phases = append(t.phases, p)
+	t.depth++
+	return func() {
+		p.dur = time.Since(p.start)
+		t.depth--
+	}
+}
+
+// reportTimings prints the phase table if --timings is set and writes the
+// trace if --trace is set. It reports at most once, as the build and run
+// commands call it before handing over to the program they built.
+func reportTimings() {
+	if timer.phases == nil {
+		return
+	}
+	if *timingsFlag {
+		writeTimings(os.Stderr, timer.phases, time.Since(timer.start))
+	}
+	if *traceFlag != "" {
+		if err := writeTraceFile(*traceFlag, timer); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+	}
+	timer.phases = nil
+}
+
+// writeTimings prints a table of the phases with their share of total, the
+// time since the compiler started.
+func writeTimings(w io.Writer, phases []*phase, total time.Duration) {
+	fmt.Fprintf(w, "\n%-28s %10s %7s\n", "phase", "time", "%")
+	for _, p := range phases {
+		name := fmt.Sprintf("%*s%s", 2*p.depth, "", p.name)
+		fmt.Fprintf(w, "%-28s %10s %6.1f%%\n", name, formatDuration(p.dur), percent(p.dur, total))
+	}
+	fmt.Fprintf(w, "%-28s %10s\n", "total", formatDuration(total))
+}
+
+func percent(d, total time.Duration) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return 100 * float64(d) / float64(total)
+}
+
+// formatDuration rounds d to a precision that suits the table.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+// traceEvent is a complete event of the Chrome trace event format, with
+// times in microseconds.
+type traceEvent struct {
+	Name  string  `json:"name"`
+	Cat   string  `json:"cat"`
+	Phase string  `json:"ph"`
+	TS    float64 `json:"ts"`
+	Dur   float64 `json:"dur"`
+	PID   int     `json:"pid"`
+	TID   int     `json:"tid"`
+}
+
+type traceFile struct {
+	TraceEvents     []traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+}
+
+// writeTrace writes the phases of t as a Chrome trace. Nested phases lie
+// within their parents, which the trace viewers draw as a flame graph.
+func writeTrace(w io.Writer, t *phaseTimer) error {
+	trace := traceFile{TraceEvents: []traceEvent{}, DisplayTimeUnit: "ms"}
+	for _, p := range t.phases {
+		trace.TraceEvents = append(trace.TraceEvents, traceEvent{
+			Name:  p.name,
+			Cat:   "compiler",
+			Phase: "X",
+			TS:    float64(p.start.Sub(t.start).Nanoseconds()) / 1e3,
+			Dur:   float64(p.dur.Nanoseconds()) / 1e3,
+			PID:   1,
+			TID:   1,
+		})
+	}
+	enc := json.NewEncoder(w)
+	return enc.Encode(trace)
+}
+
+func writeTraceFile(path string, t *phaseTimer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error writing trace: %v", err)
+	}
+	if err := writeTrace(f, t); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing trace: %v", err)
+	}
+	return f.Close()
+}
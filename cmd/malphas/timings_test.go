@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhaseTimerNesting(t *testing.T) {
+	pt := &phaseTimer{start: time.Now()}
+	endOuter := pt.startPhase("compile")
+	pt.startPhase("parse")()
+	endCheck := pt.startPhase("check")
+	endCheck()
+	endOuter()
+	pt.startPhase("link")()
+
+	want := []struct {
+		name  string
+		depth int
+	}{{"compile", 0}, {"parse", 1}, {"check", 1}, {"link", 0}}
+	if len(pt.phases) != len(want) {
+		t.Fatalf("got %d phases, want %d", len(pt.phases), len(want))
+	}
+	for i, w := range want {
+		if p := pt.phases[i]; p.name != w.name || p.depth != w.depth {
+			t.Errorf("phase %d = %s at depth %d, want %s at depth %d", i, p.name, p.depth, w.name, w.depth)
+		}
+	}
+	if outer := pt.phases[0]; outer.dur < pt.phases[1].dur+pt.phases[2].dur {
+		t.Errorf("compile took %v, less than the phases nested in it", outer.dur)
+	}
+}
+
+func TestWriteTimings(t *testing.T) {
+	phases := []*phase{
+		{name: "parse", dur: 2 * time.Millisecond},
+		{name: "lower", dur: 6 * time.Millisecond, depth: 1},
+	}
+	var buf bytes.Buffer
+	writeTimings(&buf, phases, 8*time.Millisecond)
+	out := buf.String()
+	for _, want := range []string{"parse", "  lower", "2ms", "25.0%", "75.0%", "total", "8ms"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("timings table should contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteTrace(t *testing.T) {
+	start := time.Now()
+	pt := &phaseTimer{start: start, phases: []*phase{
+		{name: "parse", start: start.Add(time.Millisecond), dur: 1500 * time.Microsecond},
+	}}
+	var buf bytes.Buffer
+	if err := writeTrace(&buf, pt); err != nil {
+		t.Fatal(err)
+	}
+	var trace traceFile
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("trace is not JSON: %v\n%s", err, buf.String())
+	}
+	if len(trace.TraceEvents) != 1 {
+		t.Fatalf("got %d events, want 1", len(trace.TraceEvents))
+	}
+	ev := trace.TraceEvents[0]
+	if ev.Name != "parse" || ev.Phase != "X" || ev.TS != 1000 || ev.Dur != 1500 {
+		t.Errorf("event = %+v, want a complete parse event at 1000µs lasting 1500µs", ev)
+	}
+}
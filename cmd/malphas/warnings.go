@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// warningLevels holds the -W flags: for each warning code, or "all", whether
+// its warnings are turned into errors ("error") or dropped ("ignore"), or
+// for an off-by-default lint, that it is reported ("warn").
+type warningLevels map[diag.Code]string
+
+// lints maps the names of the off-by-default lints, enabled with -W <name>,
+// to the code of their warnings. "all" does not enable them.
+var lints = map[string]diag.Code{
+	"shadow": diag.CodeShadowedVariable,
+}
+
+// String implements flag.Value.
+func (w warningLevels) String() string {
+	var flags []string
+	for code, level := range w {
+		flags = append(flags, level+"="+string(code))
+	}
+	sort.Strings(flags)
+	return strings.Join(flags, ",")
+}
+
+// Set implements flag.Value for one `-W error=<code>`, `-W ignore=<code>`
+// or `-W <lint>`.
+func (w warningLevels) Set(s string) error {
+	if code, ok := lints[s]; ok {
+		w[code] = "warn"
+		return nil
+	}
+	level, code, ok := strings.Cut(s, "=")
+	if !ok || code == "" || (level != "error" && level != "ignore") {
+		return fmt.Errorf("invalid warning flag %q (expected error=<code>, ignore=<code> or shadow)", s)
+	}
+	w[diag.Code(code)] = level
+	return nil
+}
+
+// warningFlags is the parsed value of the -W flags.
+var warningFlags = warningLevels{}
+
+// enabled reports whether the off-by-default lint reporting warnings with
+// code was turned on, by -W <lint> or -W error=<code>
+func (w warningLevels) enabled(code diag.Code) bool {
+	level := w[code]
+	return level == "warn" || level == "error"
+}
+
+// apply returns ds with the levels of w applied to its warnings: ignored
+// warnings are left out and the others may become errors. A level for a
+// code wins over one for "all".
+func (w warningLevels) apply(ds []diag.Diagnostic) []diag.Diagnostic {
+	var out []diag.Diagnostic
+	for _, d := range ds {
+		if d.Severity == diag.SeverityWarning {
+			level, ok := w[d.Code]
+			if !ok {
+				level = w["all"]
+			}
+			switch level {
+			case "ignore":
+				continue
+			case "error":
+				d.Severity = diag.SeverityError
+			}
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// Counts of the diagnostics reported, for the summary at the end. Errors
+// beyond --max-errors are counted in hiddenErrors instead of being printed.
+var errorCount, warningCount, hiddenErrors int
+
+// countDiagnostic counts d toward the summary, and reports whether it is
+// to be printed.
+func countDiagnostic(d diag.Diagnostic) bool {
+	switch d.Severity {
+	case diag.SeverityWarning:
+		warningCount++
+	case diag.SeverityError, "":
+		if *maxErrorsFlag > 0 && errorCount >= *maxErrorsFlag {
+			hiddenErrors++
+			return false
+		}
+		errorCount++
+	}
+	return true
+}
+
+// plural returns "n thing" or "n things".
+func plural(n int, thing string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, thing)
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
+
+// printSummary prints the counts of the errors and warnings printed while
+// compiling filename, in the human format.
+func printSummary(filename string, failed bool) {
+	if jsonErrors {
+		return
+	}
+	if hiddenErrors > 0 {
+		fmt.Fprintf(os.Stderr, "\nnote: %s not shown (--max-errors=%d)\n", plural(hiddenErrors, "more error"), *maxErrorsFlag)
+	}
+	errorCount += hiddenErrors
+	switch {
+	case failed && errorCount > 0 && warningCount > 0:
+		fmt.Fprintf(os.Stderr, "\nerror: could not compile `%s` due to %s; %s emitted\n", filename, plural(errorCount, "previous error"), plural(warningCount, "warning"))
+	case failed && errorCount > 0:
+		fmt.Fprintf(os.Stderr, "\nerror: could not compile `%s` due to %s\n", filename, plural(errorCount, "previous error"))
+	case warningCount > 0:
+		fmt.Fprintf(os.Stderr, "\nwarning: %s emitted\n", plural(warningCount, "warning"))
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestWarningLevels(t *testing.T) {
+	w := warningLevels{}
+	for _, flag := range []string{"ignore=all", "error=DEPRECATED"} {
+		if err := w.Set(flag); err != nil {
+			t.Fatalf("Set(%q): %v", flag, err)
+		}
+	}
+	for _, flag := range []string{"DEPRECATED", "warn=DEPRECATED", "error="} {
+		if err := w.Set(flag); err == nil {
+			t.Errorf("Set(%q) should fail", flag)
+		}
+	}
+
+	if w.enabled(diag.CodeShadowedVariable) {
+		t.Error("shadow lint enabled without -W shadow")
+	}
+	if err := w.Set("shadow"); err != nil || !w.enabled(diag.CodeShadowedVariable) {
+		t.Errorf("Set(\"shadow\") = %v, want the shadow lint enabled", err)
+	}
+
+	ds := w.apply([]diag.Diagnostic{
+		{Severity: diag.SeverityWarning, Code: diag.CodeDeprecated},
+		{Severity: diag.SeverityWarning, Code: diag.CodeUnusedVariable},
+		{Severity: diag.SeverityError, Code: diag.CodeTypeMismatch},
+	})
+	if len(ds) != 2 {
+		t.Fatalf("got %d diagnostics, want 2: %v", len(ds), ds)
+	}
+	if ds[0].Code != diag.CodeDeprecated || ds[0].Severity != diag.SeverityError {
+		t.Errorf("deprecation = %+v, want it turned into an error", ds[0])
+	}
+	if ds[1].Code != diag.CodeTypeMismatch {
+		t.Errorf("error = %+v, want it kept", ds[1])
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	defer func(n int) { *maxErrorsFlag = n }(*maxErrorsFlag)
+	defer func() { errorCount, warningCount, hiddenErrors = 0, 0, 0 }()
+	*maxErrorsFlag = 2
+
+	var printed int
+	for _, severity := range []diag.Severity{diag.SeverityError, diag.SeverityWarning, diag.SeverityError, diag.SeverityError} {
+		if countDiagnostic(diag.Diagnostic{Severity: severity}) {
+			printed++
+		}
+	}
+	if printed != 3 || errorCount != 2 || hiddenErrors != 1 || warningCount != 1 {
+		t.Errorf("printed %d, errors %d, hidden %d, warnings %d", printed, errorCount, hiddenErrors, warningCount)
+	}
+}
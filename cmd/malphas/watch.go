@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// watchFlag makes build and run start over whenever a source file changes.
+var watchFlag = flag.Bool("watch", false, "with build and run: rebuild, and rerun, whenever a .mal file in the program's directory changes")
+
+// watchChildEnv marks the compiler process a watch starts for each change,
+// which builds or runs once instead of watching.
+const watchChildEnv = "MALPHAS_WATCH_CHILD"
+
+// watchInterval is how often the sources are checked for changes.
+const watchInterval = 300 * time.Millisecond
+
+// watchStopGrace is how long a stopped build or program has to exit after
+// being interrupted before it is killed.
+const watchStopGrace = 3 * time.Second
+
+// watching reports whether the command should watch its sources rather than
+// build once.
+func watching() bool {
+	return *watchFlag && os.Getenv(watchChildEnv) == ""
+}
+
+// runWatch is `malphas build --watch` and `malphas run --watch`: it runs the
+// compiler with the same arguments, and on every change to a .mal file in the
+// directory tree of filename stops it if it is still running, clears the
+// terminal and runs it again, until interrupted.
+func runWatch(filename string) {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: --watch: %v\n", err)
+		os.Exit(1)
+	}
+	root := filepath.Dir(filename)
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	info, err := os.Stdout.Stat()
+	clear := err == nil && info.Mode()&os.ModeCharDevice != 0
+
+	sources := sourceStamps(root)
+	for {
+		if clear {
+			// Home the cursor, then clear the screen and the scrollback
+			fmt.Print("\x1b[H\x1b[2J\x1b[3J")
+		}
+		child := exec.Command(exe, os.Args[1:]...)
+		child.Env = append(os.Environ(), watchChildEnv+"=1")
+		child.Stdin = os.Stdin
+		child.Stdout = os.Stdout
+		child.Stderr = os.Stderr
+		if err := child.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: --watch: %v\n", err)
+			os.Exit(1)
+		}
+		done := make(chan error, 1)
+		go func() { done <- child.Wait() }()
+
+		ticker := time.NewTicker(watchInterval)
+		for changed := false; !changed; {
+			select {
+			case err := <-done:
+				done = nil
+				fmt.Fprintf(os.Stderr, "\n[watch] %s; waiting for changes in %s\n", exitStatus(err), root)
+			case <-interrupts:
+				stopChild(child, done)
+				os.Exit(130)
+			case <-ticker.C:
+				if next := sourceStamps(root); !maps.Equal(next, sources) {
+					sources, changed = next, true
+				}
+			}
+		}
+		ticker.Stop()
+		stopChild(child, done)
+	}
+}
+
+// stopChild interrupts child unless it has exited, which done reports, and
+// waits for it to exit, killing it if it takes longer than watchStopGrace.
+func stopChild(child *exec.Cmd, done <-chan error) {
+	if done == nil {
+		return
+	}
+	if err := child.Process.Signal(os.Interrupt); err != nil {
+		// Windows cannot interrupt another process
+		child.Process.Kill()
+	}
+	select {
+	case <-done:
+	case <-time.After(watchStopGrace):
+		child.Process.Kill()
+		<-done
+	}
+}
+
+// exitStatus describes how a build or program that ended with err exited.
+func exitStatus(err error) string {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return "exited"
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		return fmt.Sprintf("exited with status %d", exitErr.ExitCode())
+	}
+	if sig, ok := terminatingSignal(err); ok {
+		return "terminated by signal " + signalName(sig)
+	}
+	return err.Error()
+}
+
+// fileStamp is what tells that a file changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// sourceStamps returns the stamps of the .mal files under root, skipping
+// hidden directories.
+func sourceStamps(root string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".mal" {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			stamps[path] = fileStamp{info.ModTime(), info.Size()}
+		}
+		return nil
+	})
+	return stamps
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSourceStamps(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.mal", "fn main() {}\n")
+	write("geo/point.mal", "pub struct Point { x: int }\n")
+	write("notes.txt", "not a source\n")
+	write(".git/HEAD.mal", "hidden\n")
+
+	stamps := sourceStamps(root)
+	if len(stamps) != 2 {
+		t.Fatalf("got stamps for %v, want main.mal and geo/point.mal", stamps)
+	}
+	if same := sourceStamps(root); !maps.Equal(same, stamps) {
+		t.Error("stamps changed without a change to the sources")
+	}
+
+	write("notes.txt", "still not a source\n")
+	write(".git/HEAD.mal", "still hidden\n")
+	if same := sourceStamps(root); !maps.Equal(same, stamps) {
+		t.Error("a change to a file that is not a source should be ignored")
+	}
+
+	write("geo/point.mal", "pub struct Point { x: int, y: int }\n")
+	if changed := sourceStamps(root); maps.Equal(changed, stamps) {
+		t.Error("an edited module was not noticed")
+	}
+
+	stamps = sourceStamps(root)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(root, "main.mal"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed := sourceStamps(root); maps.Equal(changed, stamps) {
+		t.Error("a rewritten file of the same size was not noticed")
+	}
+}
+
+func TestExitStatusSignal(t *testing.T) {
+	err := exec.Command("sh", "-c", "kill -SEGV $$").Run()
+	if err == nil {
+		t.Fatal("the shell was not killed")
+	}
+	if got, want := exitStatus(err), "terminated by signal SIGSEGV"; got != want {
+		t.Errorf("exitStatus = %q, want %q", got, want)
+	}
+}
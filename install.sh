@@ -142,6 +142,15 @@ main() {
         error "Build failed"
         exit 1
     fi
+
+    # The compiler links programs against these precompiled runtimes
+    info "Building runtime libraries..."
+    if make runtime; then
+        success "Runtime libraries built"
+    else
+        error "Runtime build failed (it needs clang, and Boehm GC headers for the collected runtimes)"
+        exit 1
+    fi
     
     # If build-only, we're done
     if [ "$BUILD_ONLY" = true ]; then
@@ -193,6 +202,10 @@ main() {
         exit 1
     fi
     chmod +x "$INSTALL_PATH"
+    if ! cp libmalphas_runtime*.a "$INSTALL_DIR"; then
+        error "Failed to copy the runtime libraries to $INSTALL_DIR"
+        exit 1
+    fi
     
     # Verify installation
     if command -v malphas &> /dev/null || [ -f "$INSTALL_PATH" ]; then
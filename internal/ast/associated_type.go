@@ -14,7 +14,8 @@ import "github.com/malphas-lang/malphas-lang/internal/lexer"
 type AssociatedType struct {
 	Name   *Ident
 	Bounds []TypeExpr // Optional trait bounds
-	span   lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the associated type span.
@@ -48,6 +49,7 @@ func (*AssociatedType) declNode() {}
 type TypeAssignment struct {
 	Name *Ident
 	Type TypeExpr
+	Comments
 	span lexer.Span
 }
 
@@ -1,6 +1,10 @@
 package ast
 
-import "github.com/malphas-lang/malphas-lang/internal/lexer"
+import (
+	"sync"
+
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
 
 // Node represents any AST node with an associated source span.
 type Node interface {
@@ -33,16 +37,26 @@ type TypeExpr interface {
 
 // File represents a parsed compilation unit.
 type File struct {
-	Package *PackageDecl
-	Mods    []*ModDecl
-	Uses    []*UseDecl
-	Decls   []Decl
-	span    lexer.Span
+	Package  *PackageDecl
+	Mods     []*ModDecl
+	Uses     []*UseDecl
+	Decls    []Decl
+	Comments []*Comment // every comment in the file, in source order
+	span     lexer.Span
+
+	indexOnce sync.Once
+	index     *Index
 }
 
 // Span returns the span covering the entire file.
 func (f *File) Span() lexer.Span { return f.span }
 
+// Index returns the file's position index, building it on first use.
+func (f *File) Index() *Index {
+	f.indexOnce.Do(func() { f.index = NewIndex(f) })
+	return f.index
+}
+
 // NewFile constructs a file node with the provided span.
 func NewFile(span lexer.Span) *File {
 	return &File{span: span}
@@ -56,6 +70,7 @@ func (f *File) SetSpan(span lexer.Span) {
 // PackageDecl represents a package declaration.
 type PackageDecl struct {
 	Name *Ident
+	Comments
 	span lexer.Span
 }
 
@@ -77,8 +92,10 @@ func (d *PackageDecl) SetSpan(span lexer.Span) {
 
 // ModDecl represents a module declaration.
 type ModDecl struct {
+	Pub  bool // the module can be named from outside its parent
 	Name *Ident
 	Body *File // nil for external modules (mod name;), non-nil for inline modules (mod name { ... })
+	Comments
 	span lexer.Span
 }
 
@@ -106,7 +123,8 @@ func (*ModDecl) declNode() {}
 type UseDecl struct {
 	Path  []*Ident
 	Alias *Ident
-	span  lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the declaration span.
@@ -140,7 +158,10 @@ type FnDecl struct {
 	Effects    TypeExpr // Optional effect row
 	Where      *WhereClause
 	Body       *BlockExpr
-	span       lexer.Span
+	Attrs      []*Attribute // e.g. #[deprecated]
+	ABI        string       // "C" for `extern "C" fn`, which has no body
+	Comments
+	span lexer.Span
 }
 
 // Span returns the declaration span.
@@ -370,7 +391,8 @@ type LetStmt struct {
 	Name    *Ident
 	Type    TypeExpr
 	Value   Expr
-	span    lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the statement span.
@@ -402,7 +424,9 @@ type StructDecl struct {
 	TypeParams []GenericParam
 	Where      *WhereClause
 	Fields     []*StructField
-	span       lexer.Span
+	Attrs      []*Attribute // e.g. #[deprecated]
+	Comments
+	span lexer.Span
 }
 
 // Span returns the declaration span.
@@ -459,7 +483,9 @@ type EnumDecl struct {
 	TypeParams []GenericParam
 	Where      *WhereClause
 	Variants   []*EnumVariant
-	span       lexer.Span
+	Attrs      []*Attribute // e.g. #[deprecated]
+	Comments
+	span lexer.Span
 }
 
 // Span returns the enum declaration span.
@@ -518,7 +544,8 @@ type TypeAliasDecl struct {
 	TypeParams []GenericParam
 	Where      *WhereClause
 	Target     TypeExpr
-	span       lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the type alias span.
@@ -550,7 +577,8 @@ type ConstDecl struct {
 	Name  *Ident
 	Type  TypeExpr
 	Value Expr
-	span  lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the const declaration span.
@@ -575,6 +603,40 @@ func (d *ConstDecl) SetSpan(span lexer.Span) {
 // declNode marks ConstDecl as a declaration.
 func (*ConstDecl) declNode() {}
 
+// StaticDecl represents a static (global) variable declaration.
+type StaticDecl struct {
+	Pub     bool
+	Mutable bool
+	Name    *Ident
+	Type    TypeExpr
+	Value   Expr
+	Comments
+	span lexer.Span
+}
+
+// Span returns the static declaration span.
+func (d *StaticDecl) Span() lexer.Span { return d.span }
+
+// NewStaticDecl constructs a static declaration node.
+func NewStaticDecl(isPub, mutable bool, name *Ident, typ TypeExpr, value Expr, span lexer.Span) *StaticDecl {
+	return &StaticDecl{
+		Pub:     isPub,
+		Mutable: mutable,
+		Name:    name,
+		Type:    typ,
+		Value:   value,
+		span:    span,
+	}
+}
+
+// SetSpan updates the static declaration span.
+func (d *StaticDecl) SetSpan(span lexer.Span) {
+	d.span = span
+}
+
+// declNode marks StaticDecl as a declaration.
+func (*StaticDecl) declNode() {}
+
 // TraitDecl represents a trait declaration.
 type TraitDecl struct {
 	Pub             bool
@@ -582,7 +644,8 @@ type TraitDecl struct {
 	TypeParams      []GenericParam
 	Methods         []*FnDecl
 	AssociatedTypes []*AssociatedType // Associated types declared in this trait
-	span            lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the declaration span.
@@ -617,7 +680,8 @@ type ImplDecl struct {
 	Methods         []*FnDecl
 	TypeAssignments []*TypeAssignment // Associated type specifications
 	Where           *WhereClause
-	span            lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the declaration span.
@@ -648,7 +712,9 @@ func (*ImplDecl) declNode() {}
 // ReturnStmt represents a return statement.
 type ReturnStmt struct {
 	Value Expr
-	span  lexer.Span
+	Attrs []*Attribute // e.g. #[tailcall]
+	Comments
+	span lexer.Span
 }
 
 // Span returns the statement span.
@@ -673,6 +739,7 @@ func (*ReturnStmt) stmtNode() {}
 // ExprStmt represents an expression statement.
 type ExprStmt struct {
 	Expr Expr
+	Comments
 	span lexer.Span
 }
 
@@ -746,7 +813,8 @@ func (*IfExpr) exprNode() {}
 type IfStmt struct {
 	Clauses []*IfClause
 	Else    *BlockExpr
-	span    lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the statement span.
@@ -771,7 +839,8 @@ func (*IfStmt) stmtNode() {}
 type WhileStmt struct {
 	Condition Expr
 	Body      *BlockExpr
-	span      lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the statement span.
@@ -797,7 +866,8 @@ type ForStmt struct {
 	Iterator *Ident
 	Iterable Expr
 	Body     *BlockExpr
-	span     lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the statement span.
@@ -821,6 +891,7 @@ func (*ForStmt) stmtNode() {}
 
 // BreakStmt represents a break statement.
 type BreakStmt struct {
+	Comments
 	span lexer.Span
 }
 
@@ -842,6 +913,7 @@ func (*BreakStmt) stmtNode() {}
 
 // ContinueStmt represents a continue statement.
 type ContinueStmt struct {
+	Comments
 	span lexer.Span
 }
 
@@ -869,7 +941,8 @@ type SpawnStmt struct {
 	Block           *BlockExpr       // For: spawn { ... };
 	FunctionLiteral *FunctionLiteral // For: spawn |x| { ... }(args);
 	Args            []Expr           // Arguments for function literal call (only used when FunctionLiteral is set)
-	span            lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the statement span.
@@ -908,9 +981,10 @@ func (*SpawnStmt) stmtNode() {}
 
 // SelectCase represents a single case in a select statement.
 type SelectCase struct {
-	Comm Stmt // SendStmt or ExprStmt (recv)
-	Body *BlockExpr
-	span lexer.Span
+	Comm  Stmt // SendStmt or ExprStmt (recv); nil for `default` and `after`
+	After Expr // Timeout in milliseconds for an `after(ms)` case
+	Body  *BlockExpr
+	span  lexer.Span
 }
 
 // Span returns the case span.
@@ -928,10 +1002,23 @@ func NewSelectCase(comm Stmt, body *BlockExpr, span lexer.Span) *SelectCase {
 	}
 }
 
+// NewSelectAfterCase constructs an `after(timeout) => { ... }` select case.
+func NewSelectAfterCase(timeout Expr, body *BlockExpr, span lexer.Span) *SelectCase {
+	return &SelectCase{
+		After: timeout,
+		Body:  body,
+		span:  span,
+	}
+}
+
+// IsDefault reports whether the case is a `default` case.
+func (c *SelectCase) IsDefault() bool { return c.Comm == nil && c.After == nil }
+
 // SelectStmt represents a select statement.
 type SelectStmt struct {
 	Cases []*SelectCase
-	span  lexer.Span
+	Comments
+	span lexer.Span
 }
 
 // Span returns the statement span.
@@ -1226,6 +1313,32 @@ func (m *MapLiteral) SetSpan(span lexer.Span) {
 // exprNode marks MapLiteral as an expression.
 func (*MapLiteral) exprNode() {}
 
+// SpawnExpr represents a spawn in expression position: `spawn worker(args)`.
+// It evaluates to a JoinHandle for the spawned call.
+type SpawnExpr struct {
+	Call *CallExpr
+	span lexer.Span
+}
+
+// Span returns the expression span.
+func (e *SpawnExpr) Span() lexer.Span { return e.span }
+
+// NewSpawnExpr constructs a spawn expression node.
+func NewSpawnExpr(call *CallExpr, span lexer.Span) *SpawnExpr {
+	return &SpawnExpr{
+		Call: call,
+		span: span,
+	}
+}
+
+// SetSpan updates the spawn expression span.
+func (e *SpawnExpr) SetSpan(span lexer.Span) {
+	e.span = span
+}
+
+// exprNode marks SpawnExpr as an expression.
+func (*SpawnExpr) exprNode() {}
+
 // PrefixExpr represents a prefix expression.
 type PrefixExpr struct {
 	Op   lexer.TokenType
@@ -1719,8 +1832,9 @@ type TypeWrapperExpr struct {
 	span lexer.Span
 }
 
-func (e *TypeWrapperExpr) Span() lexer.Span { return e.span }
-func (e *TypeWrapperExpr) exprNode()        {}
+func (e *TypeWrapperExpr) Span() lexer.Span        { return e.span }
+func (e *TypeWrapperExpr) SetSpan(span lexer.Span) { e.span = span }
+func (e *TypeWrapperExpr) exprNode()               {}
 
 func NewTypeWrapperExpr(typ TypeExpr, span lexer.Span) *TypeWrapperExpr {
 	return &TypeWrapperExpr{Type: typ, span: span}
@@ -0,0 +1,42 @@
+package ast
+
+import "github.com/malphas-lang/malphas-lang/internal/lexer"
+
+// Attribute represents an attribute such as #[tailcall] or
+// #[deprecated("use g")].
+type Attribute struct {
+	Name *Ident
+	Args []Expr // nil without parentheses
+	span lexer.Span
+}
+
+// Span returns the attribute span, from `#` to the closing `]`.
+func (a *Attribute) Span() lexer.Span { return a.span }
+
+// SetSpan updates the attribute span.
+func (a *Attribute) SetSpan(span lexer.Span) { a.span = span }
+
+// NewAttribute constructs an attribute node.
+func NewAttribute(name *Ident, args []Expr, span lexer.Span) *Attribute {
+	return &Attribute{
+		Name: name,
+		Args: args,
+		span: span,
+	}
+}
+
+// HasAttribute reports whether attrs contains an attribute with the given name.
+func HasAttribute(attrs []*Attribute, name string) bool {
+	return FindAttribute(attrs, name) != nil
+}
+
+// FindAttribute returns the first attribute of attrs with the given name, or
+// nil.
+func FindAttribute(attrs []*Attribute, name string) *Attribute {
+	for _, attr := range attrs {
+		if attr.Name != nil && attr.Name.Name == name {
+			return attr
+		}
+	}
+	return nil
+}
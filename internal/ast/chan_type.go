@@ -11,6 +11,9 @@ type ChanType struct {
 // Span returns the channel type span.
 func (t *ChanType) Span() lexer.Span { return t.span }
 
+// SetSpan updates the channel type span.
+func (t *ChanType) SetSpan(span lexer.Span) { t.span = span }
+
 // typeNode marks ChanType as a type expression.
 func (*ChanType) typeNode() {}
 
@@ -0,0 +1,41 @@
+package ast
+
+import "github.com/malphas-lang/malphas-lang/internal/lexer"
+
+// Comment represents a `//` or `/* */` comment.
+type Comment struct {
+	Text string // the comment as written, delimiters included
+	span lexer.Span
+}
+
+// Span returns the comment span.
+func (c *Comment) Span() lexer.Span { return c.span }
+
+// SetSpan updates the comment span.
+func (c *Comment) SetSpan(span lexer.Span) { c.span = span }
+
+// NewComment constructs a comment node.
+func NewComment(text string, span lexer.Span) *Comment {
+	return &Comment{
+		Text: text,
+		span: span,
+	}
+}
+
+// Comments holds the comments attached to a declaration or statement.
+// Leading are the comments between the previous declaration or statement
+// (or the start of the enclosing block) and this one; Trailing is a comment
+// starting on the line where this one ends.
+type Comments struct {
+	Leading  []*Comment
+	Trailing *Comment
+}
+
+// Attached returns the comments attached to the node embedding c.
+func (c *Comments) Attached() *Comments { return c }
+
+// Commented is implemented by the nodes comments are attached to.
+type Commented interface {
+	Node
+	Attached() *Comments
+}
@@ -0,0 +1,208 @@
+//go:build ignore
+
+// gen_walk generates walk_gen.go, the traversal of the children of every
+// AST node that Walk, Inspect and Rewrite are built on. Run it with
+// `go generate ./internal/ast` after adding or changing a node type.
+//
+// A node type is a struct type with a Span method. Its children are the
+// fields whose type is a node interface (Node, Expr, Stmt, ...), a pointer
+// to a node type, or a slice of either, visited in declaration order.
+// Comments are trivia and are not children.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var output = flag.String("o", "walk_gen.go", "output file")
+
+// trivia are the node types that are never children
+var trivia = map[string]bool{"Comment": true}
+
+// child is a field of a node that holds child nodes
+type child struct {
+	field string
+	typ   string // Field type without the slice, such as Expr or *BlockExpr
+	slice bool
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gen_walk: ")
+	flag.Parse()
+
+	fset := token.NewFileSet()
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var files []*ast.File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") || path == "gen_walk.go" || path == filepath.Base(*output) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		files = append(files, f)
+	}
+
+	structs := make(map[string]*ast.StructType)
+	interfaces := make(map[string]bool)
+	hasSpan := make(map[string]bool)
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv != nil && decl.Name.Name == "Span" {
+					hasSpan[receiverName(decl.Recv.List[0].Type)] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					switch t := ts.Type.(type) {
+					case *ast.StructType:
+						structs[ts.Name.Name] = t
+					case *ast.InterfaceType:
+						if ts.Name.Name == "Node" || embedsNode(t) {
+							interfaces[ts.Name.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var nodes []string
+	for name := range structs {
+		if hasSpan[name] {
+			nodes = append(nodes, name)
+		}
+	}
+	sort.Strings(nodes)
+
+	isChild := func(typ ast.Expr) (string, bool) {
+		switch t := typ.(type) {
+		case *ast.Ident:
+			return t.Name, interfaces[t.Name]
+		case *ast.StarExpr:
+			if id, ok := t.X.(*ast.Ident); ok && hasSpan[id.Name] && !trivia[id.Name] {
+				return "*" + id.Name, true
+			}
+		}
+		return "", false
+	}
+
+	children := make(map[string][]child)
+	var leaves []string
+	for _, name := range nodes {
+		for _, field := range structs[name].Fields.List {
+			typ, slice := field.Type, false
+			if arr, ok := typ.(*ast.ArrayType); ok && arr.Len == nil {
+				typ, slice = arr.Elt, true
+			}
+			elem, ok := isChild(typ)
+			if !ok {
+				continue
+			}
+			for _, fieldName := range field.Names {
+				children[name] = append(children[name], child{field: fieldName.Name, typ: elem, slice: slice})
+			}
+		}
+		if len(children[name]) == 0 {
+			leaves = append(leaves, "*"+name)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen_walk.go; DO NOT EDIT.\n\npackage ast\n\n")
+
+	buf.WriteString("// walkChildren calls visit for each child of node, in field order. Nil\n")
+	buf.WriteString("// fields are skipped.\n")
+	buf.WriteString("func walkChildren(node Node, visit func(Node)) {\n\tswitch n := node.(type) {\n")
+	for _, name := range nodes {
+		if len(children[name]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\tcase *%s:\n", name)
+		for _, c := range children[name] {
+			if c.slice {
+				fmt.Fprintf(&buf, "\t\tfor _, x := range n.%s {\n\t\t\tvisit(x)\n\t\t}\n", c.field)
+			} else {
+				fmt.Fprintf(&buf, "\t\tif n.%s != nil {\n\t\t\tvisit(n.%s)\n\t\t}\n", c.field, c.field)
+			}
+		}
+	}
+	writeTail(&buf, leaves)
+
+	buf.WriteString("// rewriteChildren replaces each child of node with its rewrite under fn,\n")
+	buf.WriteString("// in field order. Nil fields are skipped.\n")
+	buf.WriteString("func rewriteChildren(node Node, fn func(Node) Node) {\n\tswitch n := node.(type) {\n")
+	for _, name := range nodes {
+		if len(children[name]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\tcase *%s:\n", name)
+		for _, c := range children[name] {
+			where := name + "." + c.field
+			if c.slice {
+				fmt.Fprintf(&buf, "\t\tfor i, x := range n.%s {\n\t\t\tn.%s[i] = rewriteField[%s](x, fn, %q)\n\t\t}\n", c.field, c.field, c.typ, where)
+			} else {
+				fmt.Fprintf(&buf, "\t\tif n.%s != nil {\n\t\t\tn.%s = rewriteField[%s](n.%s, fn, %q)\n\t\t}\n", c.field, c.field, c.typ, c.field, where)
+			}
+		}
+	}
+	writeTail(&buf, leaves)
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("formatting output: %v", err)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeTail closes a switch over node types: leaves have no children and
+// any other type is one the generator has not seen
+func writeTail(buf *bytes.Buffer, leaves []string) {
+	fmt.Fprintf(buf, "\tcase nil, %s:\n", strings.Join(leaves, ", "))
+	buf.WriteString("\t\t// No children\n")
+	buf.WriteString("\tdefault:\n\t\tpanic(\"ast: unknown node type \" + typeName(node) + \"; run go generate\")\n\t}\n}\n\n")
+}
+
+// receiverName returns the type name of a method receiver
+func receiverName(recv ast.Expr) string {
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	if id, ok := recv.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// embedsNode reports whether an interface embeds Node
+func embedsNode(t *ast.InterfaceType) bool {
+	for _, m := range t.Methods.List {
+		if id, ok := m.Type.(*ast.Ident); ok && len(m.Names) == 0 && id.Name == "Node" {
+			return true
+		}
+	}
+	return false
+}
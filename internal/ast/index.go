@@ -0,0 +1,149 @@
+package ast
+
+import "sort"
+
+// Index maps source offsets to the nodes of a file and nodes to their
+// parents. File.Index builds one per parsed file on first use; it reflects
+// the file as it was then, so rewriting the file afterwards needs NewIndex.
+//
+// Offsets are rune offsets, as in lexer.Span.
+type Index struct {
+	root    *indexEntry
+	parents map[Node]Node
+}
+
+// indexEntry is a node with a recorded position and the positioned nodes
+// directly under it. Nodes without a position are transparent: their
+// children belong to the nearest positioned ancestor.
+type indexEntry struct {
+	node     Node
+	start    int
+	end      int
+	children []*indexEntry // By start offset
+	maxEnd   []int         // maxEnd[i] is the greatest end of children[:i+1]
+}
+
+// NewIndex builds the index of file.
+func NewIndex(file *File) *Index {
+	ix := &Index{parents: make(map[Node]Node)}
+	ix.root = &indexEntry{node: file, start: file.Span().Start, end: file.Span().End}
+
+	stack := []*indexEntry{ix.root}
+	var ancestors []Node
+	Inspect(file, func(n Node) bool {
+		if n == nil {
+			return false
+		}
+		if len(ancestors) > 0 {
+			if _, seen := ix.parents[n]; !seen {
+				ix.parents[n] = ancestors[len(ancestors)-1]
+			}
+		}
+		ancestors = append(ancestors, n)
+		if n == Node(file) {
+			return true
+		}
+		if span := n.Span(); span.End > span.Start {
+			entry := &indexEntry{node: n, start: span.Start, end: span.End}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, entry)
+			stack = append(stack, entry)
+		} else {
+			stack = append(stack, stack[len(stack)-1])
+		}
+		return true
+	}, func(n Node) {
+		ancestors = ancestors[:len(ancestors)-1]
+		if n != Node(file) {
+			stack = stack[:len(stack)-1]
+		}
+	})
+	ix.root.sort()
+	return ix
+}
+
+// sort orders the children under e by start offset, keeping traversal order
+// among equal starts, and records their running greatest end.
+func (e *indexEntry) sort() {
+	sort.SliceStable(e.children, func(i, j int) bool {
+		return e.children[i].start < e.children[j].start
+	})
+	e.maxEnd = make([]int, len(e.children))
+	for i, child := range e.children {
+		e.maxEnd[i] = child.end
+		if i > 0 && e.maxEnd[i-1] > child.end {
+			e.maxEnd[i] = e.maxEnd[i-1]
+		}
+		child.sort()
+	}
+}
+
+// childAt returns the narrowest child of e whose span contains offset, or
+// nil. Of equally narrow children the last in traversal order wins.
+func (e *indexEntry) childAt(offset int) *indexEntry {
+	// Only the children starting at or before offset can contain it, and of
+	// those none before the last whose running end passes offset
+	i := sort.Search(len(e.children), func(i int) bool { return e.children[i].start > offset }) - 1
+	var best *indexEntry
+	for ; i >= 0 && e.maxEnd[i] > offset; i-- {
+		child := e.children[i]
+		if child.end <= offset {
+			continue
+		}
+		if best == nil || child.end-child.start < best.end-best.start {
+			best = child
+		}
+	}
+	return best
+}
+
+// PathAt returns the positioned nodes whose span contains offset, from the
+// file down to the innermost one.
+func (ix *Index) PathAt(offset int) []Node {
+	var path []Node
+	e := ix.root
+	if e.end > e.start && (offset < e.start || offset >= e.end) {
+		return nil
+	}
+	if e.end > e.start {
+		path = append(path, e.node)
+	}
+	for {
+		e = e.childAt(offset)
+		if e == nil {
+			return path
+		}
+		path = append(path, e.node)
+	}
+}
+
+// NodeAt returns the innermost positioned node whose span contains offset,
+// or nil.
+func (ix *Index) NodeAt(offset int) Node {
+	path := ix.PathAt(offset)
+	if len(path) == 0 {
+		return nil
+	}
+	return path[len(path)-1]
+}
+
+// Parent returns the node whose field holds n, or nil for the file and for
+// nodes outside it.
+func (ix *Index) Parent(n Node) Node {
+	return ix.parents[n]
+}
+
+// Ancestors returns the nodes enclosing n, from its parent up to the file.
+func (ix *Index) Ancestors(n Node) []Node {
+	var chain []Node
+	for p := ix.parents[n]; p != nil; p = ix.parents[p] {
+		chain = append(chain, p)
+	}
+	return chain
+}
+
+// NodeAt returns the innermost node of file whose span contains offset, or
+// nil, using the file's index.
+func NodeAt(file *File, offset int) Node {
+	return file.Index().NodeAt(offset)
+}
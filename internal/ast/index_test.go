@@ -0,0 +1,94 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+)
+
+const indexSrc = `package main;
+struct Point { x: int, y: int }
+impl Point {
+    fn norm(self) -> int { return self.x * self.x + self.y * self.y; }
+}
+fn main() {
+    let p = Point { x: 3, y: 4 };
+    println(p.norm());
+}
+`
+
+func TestNodeAt(t *testing.T) {
+	file := parse(t, indexSrc)
+	offset := strings.Index(indexSrc, "println")
+
+	ident, ok := ast.NodeAt(file, offset+3).(*ast.Ident)
+	if !ok || ident.Name != "println" {
+		t.Fatalf("NodeAt = %#v, want the println identifier", ast.NodeAt(file, offset+3))
+	}
+
+	var kinds []string
+	for _, n := range file.Index().PathAt(offset) {
+		kinds = append(kinds, strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+	}
+	want := "File FnDecl BlockExpr ExprStmt CallExpr Ident"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("PathAt = %s, want %s", got, want)
+	}
+
+	if n := ast.NodeAt(file, len([]rune(indexSrc))+5); n != nil {
+		t.Errorf("NodeAt past the end = %T, want nil", n)
+	}
+}
+
+// TestNodeAtMatchesScan checks the index against a scan of the whole file
+// at every offset that falls on an identifier.
+func TestNodeAtMatchesScan(t *testing.T) {
+	file := parse(t, indexSrc)
+	for offset := range len([]rune(indexSrc)) {
+		var want *ast.Ident
+		ast.Walk(file, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && ident.Span().Start <= offset && offset < ident.Span().End {
+				want = ident
+			}
+			return true
+		})
+		if want == nil {
+			continue
+		}
+		if got := ast.NodeAt(file, offset); got != ast.Node(want) {
+			t.Errorf("offset %d: NodeAt = %#v, want %q", offset, got, want.Name)
+		}
+	}
+}
+
+func TestParents(t *testing.T) {
+	file := parse(t, indexSrc)
+	index := file.Index()
+	if index != file.Index() {
+		t.Error("File.Index built a second index")
+	}
+
+	field, ok := ast.NodeAt(file, strings.Index(indexSrc, "x * self")).(*ast.Ident)
+	if !ok {
+		t.Fatal("no identifier at self.x")
+	}
+	if _, ok := index.Parent(field).(*ast.FieldExpr); !ok {
+		t.Errorf("Parent = %T, want *ast.FieldExpr", index.Parent(field))
+	}
+
+	ancestors := index.Ancestors(field)
+	var impl *ast.ImplDecl
+	for _, n := range ancestors {
+		if d, ok := n.(*ast.ImplDecl); ok {
+			impl = d
+		}
+	}
+	if impl == nil || ancestors[len(ancestors)-1] != ast.Node(file) {
+		t.Errorf("Ancestors = %v, want a chain through the impl up to the file", ancestors)
+	}
+	if index.Parent(file) != nil {
+		t.Errorf("Parent(file) = %T, want nil", index.Parent(file))
+	}
+}
@@ -63,7 +63,8 @@ type PatternField struct {
 	span    lexer.Span
 }
 
-func (p *PatternField) Span() lexer.Span { return p.span }
+func (p *PatternField) Span() lexer.Span        { return p.span }
+func (p *PatternField) SetSpan(span lexer.Span) { p.span = span }
 
 func (p *StructPattern) Span() lexer.Span        { return p.span }
 func (p *StructPattern) SetSpan(span lexer.Span) { p.span = span }
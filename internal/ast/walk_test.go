@@ -0,0 +1,178 @@
+package ast_test
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+func parse(t *testing.T, src string) *ast.File {
+	t.Helper()
+	p := parser.New(src)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return file
+}
+
+// identNames returns the names of the identifiers under node, in the order
+// Walk visits them
+func identNames(node ast.Node) []string {
+	var names []string
+	ast.Walk(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			names = append(names, ident.Name)
+		}
+		return true
+	})
+	return names
+}
+
+func TestWalkVisitsEveryChild(t *testing.T) {
+	file := parse(t, `package main;
+fn pick[T: Show](x: T, n: i64) -> T {
+    let p = (x as T, spawn work(n));
+    let m = {"k": n};
+    match p {
+        (a, _) => { a },
+    }
+}
+`)
+	got := strings.Join(identNames(file), " ")
+	want := "main pick T Show x T n i64 T p x T work n m n p a a"
+	if got != want {
+		t.Errorf("identifiers = %q, want %q", got, want)
+	}
+}
+
+func TestWalkPrunes(t *testing.T) {
+	file := parse(t, `package main;
+fn f() { g(1); }
+fn h() { g(2); }
+`)
+	var fns []string
+	calls := 0
+	ast.Walk(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FnDecl:
+			fns = append(fns, n.Name.Name)
+			return n.Name.Name == "f"
+		case *ast.CallExpr:
+			calls++
+		}
+		return true
+	})
+	if strings.Join(fns, ",") != "f,h" || calls != 1 {
+		t.Errorf("visited functions %v and %d calls, want f,h and 1", fns, calls)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	file := parse(t, `package main;
+fn f() { let x = a + b; }
+`)
+	var events []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.InfixExpr:
+			events = append(events, "enter infix")
+		case *ast.Ident:
+			events = append(events, "enter "+n.Name)
+		case *ast.LetStmt:
+			return false
+		}
+		return true
+	}, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.FnDecl:
+			events = append(events, "leave "+n.Name.Name)
+		case *ast.LetStmt:
+			events = append(events, "leave let")
+		}
+	})
+	// The let statement is pruned, so neither its children nor its post
+	// callback are visited
+	got := strings.Join(events, ", ")
+	want := "enter main, enter f, leave f"
+	if got != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	file := parse(t, `package main;
+fn f() -> int { return x * (y + x); }
+`)
+	// Replace every x with z and every addition with its left operand
+	result := ast.Rewrite(file, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if n.Name == "x" {
+				return ast.NewIdent("z", n.Span())
+			}
+		case *ast.InfixExpr:
+			if n.Op == lexer.PLUS {
+				return n.Left
+			}
+		}
+		return n
+	})
+	if result != ast.Node(file) {
+		t.Fatalf("Rewrite returned %T, want the file", result)
+	}
+	got := strings.Join(identNames(file), " ")
+	if want := "main f int z y"; got != want {
+		t.Errorf("identifiers after rewrite = %q, want %q", got, want)
+	}
+}
+
+func TestRewriteMismatch(t *testing.T) {
+	file := parse(t, `package main;
+fn f() { g(); }
+`)
+	defer func() {
+		r := recover()
+		if r == nil || !strings.Contains(r.(string), "CallExpr.Callee") {
+			t.Errorf("recovered %v, want a panic naming CallExpr.Callee", r)
+		}
+	}()
+	ast.Rewrite(file, func(n ast.Node) ast.Node {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == "g" {
+			return &ast.BreakStmt{}
+		}
+		return n
+	})
+}
+
+// TestGeneratedWalkUpToDate checks that walk_gen.go matches the node
+// declarations, so a new or changed node type cannot be missed by Walk.
+func TestGeneratedWalkUpToDate(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out := filepath.Join(t.TempDir(), "walk_gen.go")
+	cmd := exec.Command(goTool, "run", "gen_walk.go", "-o", out)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("gen_walk: %v\n%s", err, output)
+	}
+	want, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("walk_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("walk_gen.go is out of date; run go generate ./internal/ast")
+	}
+}
@@ -0,0 +1,350 @@
+package mir2llvm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// Fixed-size arrays are values: [T; N] is the LLVM array type [N x T], so
+// assigning an array or passing it to a function copies its elements. An
+// array local always lives in its stack slot, which indexing addresses
+// directly; an index not known to be in range is checked against the
+// length, which is part of the type. Where a slice is expected, such as a
+// []T parameter, the array is copied into a new slice.
+
+// arrayOf returns the array type typ stands for, or nil
+func arrayOf(typ types.Type) *types.Array {
+	switch t := typ.(type) {
+	case *types.Array:
+		return t
+	case *types.Named:
+		if t.Ref != nil {
+			return arrayOf(t.Ref)
+		}
+	}
+	return nil
+}
+
+// sliceOf returns the slice type typ stands for, or nil
+func sliceOf(typ types.Type) *types.Slice {
+	switch t := typ.(type) {
+	case *types.Slice:
+		return t
+	case *types.Named:
+		if t.Ref != nil {
+			return sliceOf(t.Ref)
+		}
+	}
+	return nil
+}
+
+// pointeeArray returns the array a reference or pointer of type typ points
+// to, or nil
+func pointeeArray(typ types.Type) *types.Array {
+	switch t := typ.(type) {
+	case *types.Reference:
+		return arrayOf(t.Elem)
+	case *types.Pointer:
+		return arrayOf(t.Elem)
+	}
+	return nil
+}
+
+// generateArrayValue builds the array value of cons element by element and
+// stores it in the result's stack slot
+func (g *Generator) generateArrayValue(cons *mir.ConstructArray, arr *types.Array) error {
+	arrType, err := g.mapType(arr)
+	if err != nil {
+		return err
+	}
+	elemType, err := g.mapType(arr.Elem)
+	if err != nil {
+		return err
+	}
+
+	value := "undef"
+	for i, elem := range cons.Elements {
+		reg, err := g.generateOperand(elem)
+		if err != nil {
+			return fmt.Errorf("failed to generate element %d: %w", i, err)
+		}
+		if reg, err = g.coerceArray(elem, reg, arr.Elem); err != nil {
+			return err
+		}
+		next := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = insertvalue %s %s, %s %s, %d", next, arrType, value, elemType, reg, i))
+		value = next
+	}
+	g.storeArray(cons.Result, arrType, value)
+	return nil
+}
+
+// storeArray stores the array value reg, of LLVM type arrType, in the stack
+// slot of local
+func (g *Generator) storeArray(local mir.Local, arrType, reg string) {
+	slot, ok := g.arraySlots[local.ID]
+	if !ok {
+		slot = g.nextReg()
+		g.emit(fmt.Sprintf("  %s = alloca %s", slot, arrType))
+		g.arraySlots[local.ID] = slot
+	}
+	g.emit(fmt.Sprintf("  store %s %s, %s* %s", arrType, reg, arrType, slot))
+	g.localRegs[local.ID] = slot
+	g.localIsValue[local.ID] = false
+}
+
+// pinArrayResult moves an array that stmt produced in a register into the
+// result's stack slot, so that every later use, including one that indexes
+// or assigns into the array, finds it there
+func (g *Generator) pinArrayResult(stmt mir.Statement) {
+	var result mir.Local
+	switch s := stmt.(type) {
+	case *mir.LoadField:
+		result = s.Result
+	case *mir.LoadIndex:
+		result = s.Result
+	case *mir.Load:
+		result = s.Result
+	case *mir.LoadStatic:
+		result = s.Result
+	case *mir.AccessVariantPayload:
+		result = s.Result
+	case *mir.Cast:
+		result = s.Result
+	case *mir.Phi:
+		result = s.Result
+	case *mir.Receive:
+		result = s.Result
+	case *mir.Join:
+		result = s.Result
+	default:
+		return
+	}
+	arr := arrayOf(result.Type)
+	if arr == nil || !g.localIsValue[result.ID] {
+		return
+	}
+	arrType, err := g.mapType(arr)
+	if err != nil {
+		return
+	}
+	g.storeArray(result, arrType, g.localRegs[result.ID])
+}
+
+// arrayAddress returns an [N x T]* to the array op: the stack slot of an
+// array local, the pointer held by a reference to an array, or a copy of
+// any other array value
+func (g *Generator) arrayAddress(op mir.Operand) (string, *types.Array, error) {
+	typ := op.OperandType()
+	if arr := pointeeArray(typ); arr != nil {
+		reg, err := g.generateOperand(op)
+		return reg, arr, err
+	}
+	arr := arrayOf(typ)
+	if arr == nil {
+		return "", nil, fmt.Errorf("expected array, got %s", typ)
+	}
+	if ref, ok := op.(*mir.LocalRef); ok && !g.localIsValue[ref.Local.ID] {
+		if reg, ok := g.localRegs[ref.Local.ID]; ok {
+			return reg, arr, nil
+		}
+	}
+
+	reg, err := g.generateOperand(op)
+	if err != nil {
+		return "", nil, err
+	}
+	arrType, err := g.mapType(arr)
+	if err != nil {
+		return "", nil, err
+	}
+	slot := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = alloca %s", slot, arrType))
+	g.emit(fmt.Sprintf("  store %s %s, %s* %s", arrType, reg, arrType, slot))
+	return slot, arr, nil
+}
+
+// arrayElementPtr returns a pointer to the element of the array at addr
+// selected by indices, one per dimension of nested arrays, checking each
+// index against its array's length unless inBounds is set. It also returns
+// the element's type.
+func (g *Generator) arrayElementPtr(addr string, arr *types.Array, indices []mir.Operand, inBounds bool) (string, types.Type, error) {
+	arrType, err := g.mapType(arr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	gep := []string{"i64 0"}
+	var elem types.Type = arr
+	for _, indexOp := range indices {
+		inner := arrayOf(elem)
+		if inner == nil {
+			return "", nil, fmt.Errorf("cannot index %s", elem)
+		}
+		indexReg, err := g.generateOperand(indexOp)
+		if err != nil {
+			return "", nil, err
+		}
+		if !inBounds {
+			g.checkArrayIndex(indexReg, inner.Len)
+		}
+		gep = append(gep, "i64 "+indexReg)
+		elem = inner.Elem
+	}
+
+	ptr := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = getelementptr inbounds %s, %s* %s, %s", ptr, arrType, arrType, addr, strings.Join(gep, ", ")))
+	return ptr, elem, nil
+}
+
+// checkArrayIndex panics unless the index at indexReg is below length.
+// Code emitted afterwards continues in the in-bounds block.
+func (g *Generator) checkArrayIndex(indexReg string, length int64) {
+	label := strings.TrimPrefix(g.nextReg(), "%")
+	panicLabel := label + "_out_of_bounds"
+	okLabel := label + "_in_bounds"
+
+	outside := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = icmp uge i64 %s, %d", outside, indexReg, length))
+	g.emitTerminator(fmt.Sprintf("  br i1 %s, label %%%s, label %%%s", outside, panicLabel, okLabel))
+	g.emitLabel(panicLabel)
+	g.emit(fmt.Sprintf("  call void @runtime_index_panic(i64 %d, i64 %s)", length, indexReg))
+	g.emitTerminator("  unreachable")
+	g.emitLabel(okLabel)
+}
+
+// generateArrayLoad generates `result = array[indices]`
+func (g *Generator) generateArrayLoad(load *mir.LoadIndex) error {
+	addr, arr, err := g.arrayAddress(load.Target)
+	if err != nil {
+		return err
+	}
+	ptr, elem, err := g.arrayElementPtr(addr, arr, load.Indices, load.InBounds)
+	if err != nil {
+		return err
+	}
+	elemType, err := g.mapType(elem)
+	if err != nil {
+		return err
+	}
+
+	resultReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = load %s, %s* %s", resultReg, elemType, elemType, ptr))
+	g.localRegs[load.Result.ID] = resultReg
+	g.localIsValue[load.Result.ID] = true
+	return nil
+}
+
+// generateArrayStore generates `array[indices] = value`, writing into the
+// array's own storage
+func (g *Generator) generateArrayStore(store *mir.StoreIndex) error {
+	addr, arr, err := g.arrayAddress(store.Target)
+	if err != nil {
+		return err
+	}
+	valueReg, err := g.generateOperand(store.Value)
+	if err != nil {
+		return err
+	}
+	ptr, elem, err := g.arrayElementPtr(addr, arr, store.Indices, store.InBounds)
+	if err != nil {
+		return err
+	}
+	if valueReg, err = g.coerceArray(store.Value, valueReg, elem); err != nil {
+		return err
+	}
+	elemType, err := g.mapType(elem)
+	if err != nil {
+		return err
+	}
+
+	g.emit(fmt.Sprintf("  store %s %s, %s* %s", elemType, valueReg, elemType, ptr))
+	return nil
+}
+
+// coerceArray returns valueReg, the value of op, copied into a new slice if
+// op is an array and target a slice type, and unchanged otherwise
+func (g *Generator) coerceArray(op mir.Operand, valueReg string, target types.Type) (string, error) {
+	arr := arrayOf(op.OperandType())
+	slice := sliceOf(target)
+	if arr == nil || slice == nil {
+		return valueReg, nil
+	}
+	return g.arrayToSlice(valueReg, arr, slice.Elem)
+}
+
+// calleeParamTypes returns the parameter types of the function call calls,
+// if known. The runtime's slice functions take a slice first, of the
+// elements of the array passed for it if any.
+func (g *Generator) calleeParamTypes(call *mir.Call) []types.Type {
+	if strings.HasPrefix(call.Func, "runtime_slice_") && len(call.Args) > 0 {
+		if arr := arrayOf(call.Args[0].OperandType()); arr != nil {
+			return []types.Type{&types.Slice{Elem: arr.Elem}}
+		}
+		return nil
+	}
+	if call.Func != "" {
+		if fn := g.findFunction(call.Func); fn != nil {
+			params := make([]types.Type, len(fn.Params))
+			for i, param := range fn.Params {
+				params[i] = param.Type
+			}
+			return params
+		}
+		if ext := g.findExtern(call.Func); ext != nil {
+			return ext.Params
+		}
+		return nil
+	}
+	if call.FuncOperand == nil {
+		return nil
+	}
+	if fnType, ok := call.FuncOperand.OperandType().(*types.Function); ok {
+		return fnType.Params
+	}
+	return nil
+}
+
+// arrayToSlice copies the array value reg into a new slice with elements of
+// type elem. Nested arrays become nested slices if elem is a slice type.
+func (g *Generator) arrayToSlice(reg string, arr *types.Array, elem types.Type) (string, error) {
+	elemSize, err := g.sliceElementSize(elem)
+	if err != nil {
+		return "", err
+	}
+	capacity := arr.Len
+	if capacity == 0 {
+		capacity = 1
+	}
+	sliceReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = call %%struct.Slice* @runtime_slice_new(i64 %s, i64 %d, i64 %d)",
+		sliceReg, elemSize, arr.Len, capacity))
+
+	arrType, err := g.mapType(arr)
+	if err != nil {
+		return "", err
+	}
+	inner, innerSlice := arrayOf(arr.Elem), sliceOf(elem)
+	if inner == nil || innerSlice == nil {
+		// The elements have the same layout, so copy them all at once
+		dataReg := g.loadSliceField(sliceReg, 0, "i8*")
+		castReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s*", castReg, dataReg, arrType))
+		g.emit(fmt.Sprintf("  store %s %s, %s* %s", arrType, reg, arrType, castReg))
+		return sliceReg, nil
+	}
+
+	for i := int64(0); i < arr.Len; i++ {
+		elemReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = extractvalue %s %s, %d", elemReg, arrType, reg, i))
+		sub, err := g.arrayToSlice(elemReg, inner, innerSlice.Elem)
+		if err != nil {
+			return "", err
+		}
+		g.storeElement(sliceReg, fmt.Sprintf("%d", i), "%struct.Slice*", sub, true)
+	}
+	return sliceReg, nil
+}
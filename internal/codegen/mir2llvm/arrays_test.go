@@ -0,0 +1,66 @@
+package mir2llvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func TestGenerateArrays(t *testing.T) {
+	src := `package main;
+
+fn first(xs: [int; 3]) -> int {
+	return xs[0];
+}
+
+fn total(xs: []int) -> int {
+	return len(xs);
+}
+
+fn main() {
+	let a = [1, 2, 3];
+	let mut b = a;
+	b[0] = 9;
+	println(first(b));
+	println(total(a));
+}
+`
+	p := parser.New(src)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("Parse error: %v", p.Errors()[0])
+	}
+	checker := types.NewChecker()
+	checker.Check(file)
+	if len(checker.Errors) > 0 {
+		t.Fatalf("Type check error: %v", checker.Errors[0])
+	}
+	lowerer := mir.NewLowerer(checker.ExprTypes, checker.CallTypeArgs, nil, nil, nil)
+	mod, err := lowerer.LowerModule(file)
+	if err != nil {
+		t.Fatalf("Lower error: %v", err)
+	}
+
+	ir, err := NewGenerator().Generate(mod)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	for _, want := range []string{
+		"define i64 @first([3 x i64] %xs)",
+		"alloca [3 x i64]",
+		"insertvalue [3 x i64] undef, i64 1, 0",
+		"getelementptr inbounds [3 x i64], [3 x i64]*",
+		"call void @runtime_index_panic(i64 3, i64 0)",
+		"call %struct.Slice* @runtime_slice_new(i64 8, i64 3, i64 3)",
+	} {
+		if !strings.Contains(ir, want) {
+			t.Errorf("IR should contain %q, got:\n%s", want, ir)
+		}
+	}
+	if strings.Contains(ir, "call i8* @runtime_alloc") {
+		t.Errorf("arrays should not be heap allocated, got:\n%s", ir)
+	}
+}
@@ -0,0 +1,66 @@
+package mir2llvm
+
+import (
+	"fmt"
+)
+
+// Slice element access is bounds checked by runtime_slice_get and
+// runtime_slice_set, which panic when the index is not below the slice's
+// length. Indices the MIR bounds pass has proved in range (LoadIndex and
+// StoreIndex with InBounds set) are instead addressed inline, without a
+// call or a check.
+
+// elementPtr returns an i8* to element index of the slice at base, checking
+// the index unless inBounds is set.
+func (g *Generator) elementPtr(base, index string, inBounds bool) string {
+	if !inBounds {
+		elemPtrReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = call i8* @runtime_slice_get(%%struct.Slice* %s, i64 %s)",
+			elemPtrReg, base, index))
+		return elemPtrReg
+	}
+	dataReg := g.loadSliceField(base, 0, "i8*")
+	sizeReg := g.loadSliceField(base, 3, "i64")
+	offsetReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = mul i64 %s, %s", offsetReg, index, sizeReg))
+	elemPtrReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = getelementptr inbounds i8, i8* %s, i64 %s", elemPtrReg, dataReg, offsetReg))
+	return elemPtrReg
+}
+
+// storeElement stores the value at valueReg, of LLVM type valueType, into
+// element index of the slice at base. Elements are stored as LLVM values, so
+// a struct element is the pointer to the struct.
+func (g *Generator) storeElement(base, index, valueType, valueReg string, inBounds bool) {
+	if !inBounds {
+		valuePtr := g.spillToI8Ptr(valueType, valueReg)
+		g.emit(fmt.Sprintf("  call void @runtime_slice_set(%%struct.Slice* %s, i64 %s, i8* %s)",
+			base, index, valuePtr))
+		return
+	}
+	elemPtrReg := g.elementPtr(base, index, true)
+	castReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s*", castReg, elemPtrReg, valueType))
+	g.emit(fmt.Sprintf("  store %s %s, %s* %s", valueType, valueReg, valueType, castReg))
+}
+
+// loadSliceField loads field index of the slice header at base
+func (g *Generator) loadSliceField(base string, index int, llvmType string) string {
+	fieldPtrReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = getelementptr inbounds %%struct.Slice, %%struct.Slice* %s, i32 0, i32 %d",
+		fieldPtrReg, base, index))
+	valueReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = load %s, %s* %s", valueReg, llvmType, llvmType, fieldPtrReg))
+	return valueReg
+}
+
+// spillToI8Ptr stores a value in a stack temporary and returns an i8* to it,
+// for runtime functions that copy values from a pointer
+func (g *Generator) spillToI8Ptr(valueType, valueReg string) string {
+	tempAlloca := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = alloca %s", tempAlloca, valueType))
+	g.emit(fmt.Sprintf("  store %s %s, %s* %s", valueType, valueReg, valueType, tempAlloca))
+	valuePtr := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = bitcast %s* %s to i8*", valuePtr, valueType, tempAlloca))
+	return valuePtr
+}
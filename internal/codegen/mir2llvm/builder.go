@@ -0,0 +1,122 @@
+package mir2llvm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// irBuilder accumulates the textual LLVM IR of a module.
+//
+// Inside a function body it tracks the basic block being filled and whether
+// that block already ends in a terminator, so the generator cannot produce
+// the classic malformed shapes:
+//
+//   - starting a new block while the previous one is still open inserts a
+//     fallthrough `br` to the new block;
+//   - emitting anything after a terminator opens a fresh, unreachable block
+//     instead of appending to the terminated one (e.g. a `br` following a
+//     `ret` lands in its own dead block, which LLVM accepts and drops);
+//   - closing a function whose last block is still open ends it with
+//     `unreachable`.
+//
+// Outside a function body lines are written verbatim.
+type irBuilder struct {
+	out strings.Builder
+
+	// inFunction is set between beginFunction and endFunction
+	inFunction bool
+
+	// block is the label of the open block ("" before the entry label)
+	block string
+
+	// terminated reports that the open block has its terminator, or that no
+	// block has been opened yet
+	terminated bool
+
+	// deadBlocks numbers the blocks opened for code following a terminator
+	deadBlocks int
+}
+
+// WriteString appends raw text, bypassing block tracking. It is used for
+// module-level fragments such as spawn wrappers that are built separately.
+func (b *irBuilder) WriteString(s string) {
+	b.out.WriteString(s)
+}
+
+// String returns the IR emitted so far
+func (b *irBuilder) String() string {
+	return b.out.String()
+}
+
+// Reset discards all emitted IR and block state
+func (b *irBuilder) Reset() {
+	*b = irBuilder{}
+}
+
+func (b *irBuilder) line(s string) {
+	b.out.WriteString(s)
+	b.out.WriteString("\n")
+}
+
+// beginFunction writes a `define ... {` header. The body starts with no open
+// block; the caller opens the entry block with startBlock.
+func (b *irBuilder) beginFunction(header string) {
+	b.line(header)
+	b.inFunction = true
+	b.block = ""
+	b.terminated = true
+	b.deadBlocks = 0
+}
+
+// endFunction closes the function body
+func (b *irBuilder) endFunction() {
+	if b.inFunction && !b.terminated {
+		b.line("  unreachable")
+	}
+	b.line("}")
+	b.inFunction = false
+	b.block = ""
+}
+
+// startBlock opens a block labelled label, falling through into it from the
+// current block if that has not been terminated
+func (b *irBuilder) startBlock(label string) {
+	if b.inFunction && !b.terminated && b.block != "" {
+		b.line(fmt.Sprintf("  br label %%%s", label))
+	}
+	b.line(label + ":")
+	b.block = label
+	b.terminated = false
+}
+
+// instr appends a non-terminator instruction to the open block
+func (b *irBuilder) instr(s string) {
+	b.ensureOpen()
+	b.line(s)
+}
+
+// terminate appends a terminator (br, ret, switch, unreachable) and closes
+// the open block
+func (b *irBuilder) terminate(s string) {
+	b.ensureOpen()
+	b.line(s)
+	if b.inFunction {
+		b.terminated = true
+	}
+}
+
+// blockTerminated reports whether the open block already has a terminator
+func (b *irBuilder) blockTerminated() bool {
+	return b.inFunction && b.terminated
+}
+
+// ensureOpen gives code emitted after a terminator a block of its own
+func (b *irBuilder) ensureOpen() {
+	if !b.inFunction || !b.terminated {
+		return
+	}
+	b.deadBlocks++
+	b.line(fmt.Sprintf("dead.%d:", b.deadBlocks))
+	b.block = fmt.Sprintf("dead.%d", b.deadBlocks)
+	b.terminated = false
+}
@@ -0,0 +1,91 @@
+package mir2llvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func TestBuilderFallthroughBranch(t *testing.T) {
+	var b irBuilder
+	b.beginFunction("define void @f() {")
+	b.startBlock("entry")
+	b.instr("  call void @g()")
+	b.startBlock("next")
+	b.terminate("  ret void")
+	b.endFunction()
+
+	want := "define void @f() {\nentry:\n  call void @g()\n  br label %next\nnext:\n  ret void\n}\n"
+	if got := b.String(); got != want {
+		t.Errorf("expected fallthrough br, got:\n%s", got)
+	}
+}
+
+func TestBuilderCodeAfterTerminator(t *testing.T) {
+	var b irBuilder
+	b.beginFunction("define void @f() {")
+	b.startBlock("entry")
+	b.terminate("  ret void")
+	b.terminate("  br label %entry")
+	b.instr("  call void @g()")
+	b.endFunction()
+
+	want := "define void @f() {\nentry:\n  ret void\ndead.1:\n  br label %entry\ndead.2:\n  call void @g()\n  unreachable\n}\n"
+	if got := b.String(); got != want {
+		t.Errorf("expected code after terminators in dead blocks, got:\n%s", got)
+	}
+}
+
+// TestGenerateBlocksEndInOneTerminator checks every block of a generated
+// function has exactly one terminator, as its last instruction
+func TestGenerateBlocksEndInOneTerminator(t *testing.T) {
+	flag := mir.Local{ID: 1, Name: "flag", Type: types.TypeBool}
+	exit := &mir.BasicBlock{Label: "exit"}
+	entry := &mir.BasicBlock{
+		Label:      "entry",
+		Statements: []mir.Statement{&mir.Assign{Local: flag, RHS: &mir.Literal{Type: types.TypeBool, Value: true}}},
+		Terminator: &mir.Branch{Condition: &mir.LocalRef{Local: flag}, True: exit, False: exit},
+	}
+	fn := &mir.Function{
+		Name:       "f",
+		ReturnType: types.TypeVoid,
+		Locals:     []mir.Local{flag},
+		Entry:      entry,
+		Blocks:     []*mir.BasicBlock{entry, exit},
+	}
+
+	gen := NewGenerator()
+	output, err := gen.Generate(&mir.Module{Functions: []*mir.Function{fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	body := output[strings.Index(output, "define void @f("):]
+	body = body[:strings.Index(body, "\n}")]
+	var blockLines []string
+	for _, line := range strings.Split(body, "\n")[1:] {
+		if strings.HasSuffix(line, ":") {
+			checkTerminated(t, blockLines, body)
+			blockLines = nil
+			continue
+		}
+		blockLines = append(blockLines, strings.TrimSpace(line))
+	}
+	checkTerminated(t, blockLines, body)
+}
+
+func checkTerminated(t *testing.T, lines []string, body string) {
+	t.Helper()
+	if lines == nil {
+		return
+	}
+	for i, line := range lines {
+		op := strings.Fields(line)[0]
+		isTerm := op == "br" || op == "ret" || op == "switch" || op == "unreachable"
+		if isTerm != (i == len(lines)-1) {
+			t.Fatalf("block must end in exactly one terminator, got:\n%s", body)
+		}
+	}
+}
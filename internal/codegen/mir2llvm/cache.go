@@ -0,0 +1,248 @@
+package mir2llvm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// FragmentCache stores the generated IR of monomorphized functions between
+// builds. Keys are hex digests that cover everything the generated code
+// depends on, so an entry never has to be invalidated.
+type FragmentCache interface {
+	Load(key string) ([]byte, bool)
+	Store(key string, data []byte)
+}
+
+// DirCache is a FragmentCache backed by a directory, one file per entry.
+// Failures to read or write are treated as misses.
+type DirCache string
+
+func (c DirCache) path(key string) string {
+	return filepath.Join(string(c), "mono", key[:2], key)
+}
+
+// Load returns the entry stored under key
+func (c DirCache) Load(key string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(key))
+	return data, err == nil
+}
+
+// Store writes the entry for key. The file is renamed into place so that
+// concurrent builds never read a partial entry.
+func (c DirCache) Store(key string, data []byte) {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// cachedFragment is the serialized form of a worker Generator's output
+type cachedFragment struct {
+	IR           string
+	Strings      []string // String constant contents, in local numbering order
+	Wrappers     []string
+	WrapperNames []string
+	Intrinsics   map[string]string
+}
+
+// cacheable reports whether fn's generated code is looked up in g.Cache
+func (g *Generator) cacheable(fn *mir.Function) bool {
+	return g.Cache != nil && fn.Instance != nil
+}
+
+// fragmentKey derives the cache key of a specialized function from its
+// generic symbol and type arguments, its full MIR, the signatures of the
+// functions it references, and the module-level IR emitted before any
+// function (runtime declarations and type layouts).
+func (g *Generator) fragmentKey(fn *mir.Function, prelude []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "malphas-mono-v7\x00%s\x00%d\x00%s\x00", g.CacheSalt, g.Overflow, fn.Instance.Generic)
+	for _, arg := range fn.Instance.TypeArgs {
+		fmt.Fprintf(h, "%s\x00", arg)
+	}
+	h.Write(prelude)
+
+	fp := &fingerprinter{w: h, blocks: make(map[*mir.BasicBlock]int)}
+	for i, block := range fn.Blocks {
+		fp.blocks[block] = i
+	}
+	fp.value(reflect.ValueOf(fn).Elem())
+	// Block references above are indices; the blocks themselves go here
+	for _, block := range fn.Blocks {
+		fp.value(reflect.ValueOf(block).Elem())
+	}
+
+	for _, name := range referencedFunctions(fn) {
+		fmt.Fprintf(h, "\x00callee %s", name)
+		if callee := g.findFunction(name); callee != nil {
+			for _, param := range callee.Params {
+				fmt.Fprintf(h, " %s", param.Type)
+			}
+			fmt.Fprintf(h, " -> %s", callee.ReturnType)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// referencedFunctions returns the sorted names fn calls, spawns, closes over
+// or registers as finalizers
+func referencedFunctions(fn *mir.Function) []string {
+	seen := make(map[string]bool)
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			switch s := stmt.(type) {
+			case *mir.Call:
+				seen[s.Func] = true
+			case *mir.Spawn:
+				seen[s.Func] = true
+			case *mir.MakeClosure:
+				seen[s.Func] = true
+			case *mir.RegisterDrop:
+				seen[s.Func] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	typeInterface = reflect.TypeOf((*types.Type)(nil)).Elem()
+	blockPtrType  = reflect.TypeOf((*mir.BasicBlock)(nil))
+	spanType      = reflect.TypeOf(lexer.Span{})
+)
+
+// fingerprinter writes a canonical encoding of a MIR value. Types are
+// written by name (their layouts are covered by the prelude), blocks by
+// index, and map entries in sorted order, so equal MIR always hashes alike.
+type fingerprinter struct {
+	w      io.Writer
+	blocks map[*mir.BasicBlock]int
+}
+
+func (f *fingerprinter) value(v reflect.Value) {
+	if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			io.WriteString(f.w, "nil;")
+			return
+		}
+	}
+	if v.Type() == blockPtrType {
+		fmt.Fprintf(f.w, "bb%d;", f.blocks[v.Interface().(*mir.BasicBlock)])
+		return
+	}
+	if v.Type().Implements(typeInterface) {
+		fmt.Fprintf(f.w, "type(%s);", v.Interface().(types.Type))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		fmt.Fprintf(f.w, "%s:", v.Elem().Type())
+		f.value(v.Elem())
+	case reflect.Ptr:
+		f.value(v.Elem())
+	case reflect.Struct:
+		fmt.Fprintf(f.w, "%s{", v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			// Source positions do not change the generated code
+			if v.Type().Field(i).Type == spanType {
+				continue
+			}
+			f.value(v.Field(i))
+		}
+		io.WriteString(f.w, "}")
+	case reflect.Slice, reflect.Array:
+		fmt.Fprintf(f.w, "[%d:", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			f.value(v.Index(i))
+		}
+		io.WriteString(f.w, "]")
+	case reflect.Map:
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var entry strings.Builder
+			sub := &fingerprinter{w: &entry, blocks: f.blocks}
+			sub.value(iter.Key())
+			io.WriteString(&entry, "=")
+			sub.value(iter.Value())
+			entries = append(entries, entry.String())
+		}
+		sort.Strings(entries)
+		fmt.Fprintf(f.w, "map[%s]", strings.Join(entries, ","))
+	default:
+		fmt.Fprintf(f.w, "%#v;", v)
+	}
+}
+
+// loadFragment restores a worker Generator from a cache entry
+func (g *Generator) loadFragment(fn *mir.Function, data []byte) (*Generator, bool) {
+	var cached cachedFragment
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cached); err != nil {
+		return nil, false
+	}
+	w := g.forkForFunction()
+	w.builder.WriteString(cached.IR)
+	for i, content := range cached.Strings {
+		w.stringConstants[content] = fmt.Sprintf("@.str.%d", i)
+	}
+	w.spawnWrappers = cached.Wrappers
+	w.spawnWrapperNames = cached.WrapperNames
+	for name, decl := range cached.Intrinsics {
+		w.intrinsics[name] = decl
+	}
+	w.functions[sanitizeName(fn.Name)] = fn
+	return w, true
+}
+
+// storeFragment writes a worker Generator's output to the cache
+func (g *Generator) storeFragment(key string, w *Generator) {
+	cached := cachedFragment{
+		IR:           w.builder.String(),
+		Strings:      make([]string, len(w.stringConstants)),
+		Wrappers:     w.spawnWrappers,
+		WrapperNames: w.spawnWrapperNames,
+		Intrinsics:   w.intrinsics,
+	}
+	for content, name := range w.stringConstants {
+		var index int
+		fmt.Sscanf(name, "@.str.%d", &index)
+		cached.Strings[index] = content
+	}
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(cached); err != nil {
+		return
+	}
+	g.Cache.Store(key, data.Bytes())
+}
@@ -0,0 +1,109 @@
+package mir2llvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// memoryCache is a FragmentCache that counts hits
+type memoryCache struct {
+	entries map[string][]byte
+	hits    int
+}
+
+func (c *memoryCache) Load(key string) ([]byte, bool) {
+	data, ok := c.entries[key]
+	if ok {
+		c.hits++
+	}
+	return data, ok
+}
+
+func (c *memoryCache) Store(key string, data []byte) {
+	c.entries[key] = data
+}
+
+// instanceModule holds show$int, a specialization of show[T] that prints a
+// label and spawns a worker
+func instanceModule(label string) *mir.Module {
+	param := mir.Local{ID: 0, Name: "x", Type: types.TypeInt}
+	worker := createTestFunction("worker", []mir.Local{param}, types.TypeVoid)
+	worker.Entry.Terminator = &mir.Return{}
+
+	show := createTestFunction("show$int", []mir.Local{param}, types.TypeVoid)
+	show.Instance = &mir.Instance{Generic: "show", TypeArgs: []types.Type{types.TypeInt}}
+	show.Entry.Statements = []mir.Statement{
+		&mir.Call{Result: mir.Local{ID: 1, Type: types.TypeVoid}, Func: "println", Args: []mir.Operand{&mir.Literal{Type: types.TypeString, Value: label}}},
+		&mir.Spawn{Func: "worker", Args: []mir.Operand{&mir.LocalRef{Local: param}}},
+	}
+	show.Entry.Terminator = &mir.Return{}
+	return &mir.Module{Functions: []*mir.Function{worker, show}}
+}
+
+func TestGenerateReusesCachedInstances(t *testing.T) {
+	cache := &memoryCache{entries: make(map[string][]byte)}
+	generate := func(label string) string {
+		gen := newTestGenerator()
+		gen.Cache = cache
+		got, err := gen.Generate(instanceModule(label))
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		return got
+	}
+
+	first := generate("value")
+	if len(cache.entries) != 1 || cache.hits != 0 {
+		t.Fatalf("expected one stored instance and no hits, got %d entries and %d hits", len(cache.entries), cache.hits)
+	}
+
+	second := generate("value")
+	if cache.hits != 1 {
+		t.Fatalf("expected the instance to be reused, got %d hits", cache.hits)
+	}
+	if second != first {
+		t.Errorf("cached output differs:\n%s\nwant:\n%s", second, first)
+	}
+	for _, want := range []string{"@" + spawnWrapperName("worker", []string{"i64"}, "void") + "(", `c"value\00"`} {
+		if !strings.Contains(second, want) {
+			t.Errorf("cached output should contain %q", want)
+		}
+	}
+
+	// A changed body must not hit the old entry
+	changed := generate("other")
+	if cache.hits != 1 || len(cache.entries) != 2 {
+		t.Errorf("expected a miss for a changed instance, got %d hits and %d entries", cache.hits, len(cache.entries))
+	}
+	if !strings.Contains(changed, `c"other\00"`) {
+		t.Errorf("changed instance should print its own label")
+	}
+}
+
+func TestFragmentKeyCoversCompilerSettings(t *testing.T) {
+	module := instanceModule("value")
+	show := module.Functions[1]
+	key := func(gen *Generator) string {
+		gen.currentModule = module
+		return gen.fragmentKey(show, nil)
+	}
+
+	base := key(NewGenerator())
+	salted := NewGenerator()
+	salted.CacheSalt = "another compiler"
+	checked := NewGenerator()
+	checked.Overflow = OverflowPanic
+
+	if key(NewGenerator()) != base {
+		t.Errorf("fragmentKey() should be stable")
+	}
+	if key(salted) == base {
+		t.Errorf("fragmentKey() should depend on the cache salt")
+	}
+	if key(checked) == base {
+		t.Errorf("fragmentKey() should depend on the overflow mode")
+	}
+}
@@ -0,0 +1,56 @@
+package mir2llvm
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func TestClosureCallPassesEnvironment(t *testing.T) {
+	src := `package main;
+
+fn main() {
+	let base = 10;
+	let add_base = |x: int| { x + base };
+	let y = add_base(5);
+}
+`
+	p := parser.New(src)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("Parse error: %v", p.Errors()[0])
+	}
+	checker := types.NewChecker()
+	checker.Check(file)
+	if len(checker.Errors) > 0 {
+		t.Fatalf("Type check error: %v", checker.Errors[0])
+	}
+	mod, err := mir.NewLowerer(checker.ExprTypes, checker.CallTypeArgs, nil, nil, nil).LowerModule(file)
+	if err != nil {
+		t.Fatalf("Lower error: %v", err)
+	}
+
+	ir, err := NewGenerator().Generate(mod)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	for _, want := range []string{
+		// The closure's function reads base from the environment it is given
+		"define i64 @main_closure_1(%struct.main_closure_1_env* %env, i64 %x)",
+		"%struct.main_closure_1_env = type { i64 }",
+		"bitcast i64 (%struct.main_closure_1_env*, i64)* @main_closure_1 to i8* (i8*)*",
+		// and the call hands over the environment stored in the closure
+		"to i64 (i8*, i64)*",
+	} {
+		if !strings.Contains(ir, want) {
+			t.Errorf("IR should contain %q, got:\n%s", want, ir)
+		}
+	}
+	if !regexp.MustCompile(`call i64 %reg\d+\(i8\* %reg\d+, i64 `).MatchString(ir) {
+		t.Errorf("closure call should pass the environment first, got:\n%s", ir)
+	}
+}
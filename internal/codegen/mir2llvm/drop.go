@@ -0,0 +1,44 @@
+package mir2llvm
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+// generateRegisterDrop registers the drop method of a value as its finalizer.
+// The method takes the struct pointer the runtime hands back as an i8*.
+func (g *Generator) generateRegisterDrop(reg *mir.RegisterDrop) error {
+	objReg, valueType, err := g.dropObject(reg.Value)
+	if err != nil {
+		return err
+	}
+	drop := fmt.Sprintf("bitcast (void (%s)* @%s to void (i8*)*)", valueType, sanitizeName(reg.Func))
+	g.emit(fmt.Sprintf("  call void @runtime_drop_register(i8* %s, void (i8*)* %s)", objReg, drop))
+	return nil
+}
+
+// generateCancelDrop forgets the finalizer of a value dropped at scope exit
+func (g *Generator) generateCancelDrop(cancel *mir.CancelDrop) error {
+	objReg, _, err := g.dropObject(cancel.Value)
+	if err != nil {
+		return err
+	}
+	g.emit(fmt.Sprintf("  call void @runtime_drop_cancel(i8* %s)", objReg))
+	return nil
+}
+
+// dropObject returns value cast to i8*, along with its LLVM type
+func (g *Generator) dropObject(value mir.Operand) (string, string, error) {
+	valueReg, err := g.generateOperand(value)
+	if err != nil {
+		return "", "", err
+	}
+	valueType, err := g.mapType(value.OperandType())
+	if err != nil {
+		return "", "", err
+	}
+	objReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = bitcast %s %s to i8*", objReg, valueType, valueReg))
+	return objReg, valueType, nil
+}
@@ -0,0 +1,277 @@
+package mir2llvm
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// An enum value is normally a pointer to a { tag, payload } object, which
+// is allocated when the value is constructed. Two shapes of enum are laid
+// out without the object:
+//
+//   - An enum whose variants carry no payload, like `enum Color { Red,
+//     Green }`, is its tag: an i32, or the integer type of its #[repr].
+//   - An enum with one empty variant and one variant whose payload is a
+//     single reference or struct, like `Option[&T]`, is that payload. The
+//     payload is a pointer that is never null, so null stands for the empty
+//     variant.
+//
+// The layout is decided from the enum's type, with generic arguments
+// substituted, so every function agrees on it.
+
+// enumLayout is how values of an enum type are represented.
+type enumLayout int
+
+const (
+	enumBoxed enumLayout = iota // pointer to a { tag, payload } object
+	enumTag                     // the tag itself
+	enumNiche                   // the payload pointer, or null
+)
+
+// enumRepr describes the representation of an enum type.
+type enumRepr struct {
+	layout  enumLayout
+	enum    *types.Enum
+	payload types.Type // enumNiche: the type of the payload
+	some    int        // enumNiche: the index of the variant with the payload
+	none    int        // enumNiche: the index of the empty variant
+}
+
+// enumOf returns the enum definition of typ and its generic arguments, or
+// nil if typ is not an enum.
+func (g *Generator) enumOf(typ types.Type) (*types.Enum, []types.Type) {
+	switch t := typ.(type) {
+	case *types.Enum:
+		return t, nil
+	case *types.GenericInstance:
+		if e, ok := t.Base.(*types.Enum); ok {
+			return e, t.Args
+		}
+	case *types.Named:
+		if t.Ref != nil {
+			return g.enumOf(t.Ref)
+		}
+		if e, ok := g.enumDefs[sanitizeName(t.Name)]; ok {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+// enumReprOf returns the representation of typ, or false if typ is not an
+// enum.
+func (g *Generator) enumReprOf(typ types.Type) (enumRepr, bool) {
+	e, args := g.enumOf(typ)
+	if e == nil {
+		return enumRepr{}, false
+	}
+	repr := enumRepr{layout: enumBoxed, enum: e}
+	if len(e.Variants) == 0 {
+		return repr, true
+	}
+
+	unit := true
+	for _, v := range e.Variants {
+		if len(v.Params) > 0 {
+			unit = false
+		}
+	}
+	if unit {
+		repr.layout = enumTag
+		return repr, true
+	}
+
+	if len(e.Variants) != 2 {
+		return repr, true
+	}
+	some, none := 0, 1
+	if len(e.Variants[0].Params) == 0 {
+		some, none = 1, 0
+	}
+	if len(e.Variants[none].Params) != 0 || len(e.Variants[some].Params) != 1 {
+		return repr, true
+	}
+	payload := e.Variants[some].Params[0]
+	if len(args) > 0 && len(e.TypeParams) > 0 {
+		subst := make(map[string]types.Type)
+		for i, param := range e.TypeParams {
+			if i < len(args) {
+				subst[param.Name] = args[i]
+			}
+		}
+		payload = types.Substitute(payload, subst)
+	}
+	if nonNullPointer(payload) {
+		repr.layout = enumNiche
+		repr.payload = payload
+		repr.some = some
+		repr.none = none
+	}
+	return repr, true
+}
+
+// nonNullPointer reports whether values of typ are pointers that are never
+// null: references, and structs, which are always allocated. A reference to
+// an enum is left out, so that no enum's layout depends on its own.
+func nonNullPointer(typ types.Type) bool {
+	switch t := typ.(type) {
+	case *types.Reference:
+		switch elem := t.Elem.(type) {
+		case *types.Enum, *types.TypeParam:
+			return false
+		case *types.GenericInstance:
+			_, isEnum := elem.Base.(*types.Enum)
+			return !isEnum
+		case *types.Named:
+			return elem.Ref != nil && nonNullPointer(&types.Reference{Elem: elem.Ref})
+		}
+		return true
+	case *types.Struct:
+		return true
+	case *types.GenericInstance:
+		_, ok := t.Base.(*types.Struct)
+		return ok
+	case *types.Named:
+		return t.Ref != nil && nonNullPointer(t.Ref)
+	}
+	return false
+}
+
+// enumBoxed reports whether values of typ, an enum type, are pointers to
+// an allocated { tag, payload } object.
+func (g *Generator) enumBoxed(typ types.Type) bool {
+	repr, ok := g.enumReprOf(typ)
+	return ok && repr.layout == enumBoxed
+}
+
+// mapEnumType returns the LLVM type of values of typ, an enum type named
+// name.
+func (g *Generator) mapEnumType(typ types.Type, name string) (string, error) {
+	repr, _ := g.enumReprOf(typ)
+	switch repr.layout {
+	case enumTag:
+		return g.enumTagType(name), nil
+	case enumNiche:
+		return g.mapType(repr.payload)
+	}
+	return "%enum." + sanitizeName(name) + "*", nil
+}
+
+// setEnumResult makes reg, of LLVM type llvmType, the value of result:
+// stored to its alloca if it has one, or used directly.
+func (g *Generator) setEnumResult(result mir.Local, llvmType, reg string) {
+	if allocaReg, ok := g.localRegs[result.ID]; ok && !g.localIsValue[result.ID] {
+		g.emit(fmt.Sprintf("  store %s %s, %s* %s", llvmType, reg, llvmType, allocaReg))
+		return
+	}
+	g.localRegs[result.ID] = reg
+	g.localIsValue[result.ID] = true
+}
+
+// enumValue returns the value of target, an enum of an unboxed layout, and
+// its representation, loading through a reference or pointer to it.
+func (g *Generator) enumValue(target mir.Operand) (string, enumRepr, string, bool, error) {
+	typ := target.OperandType()
+	indirect := false
+	switch t := typ.(type) {
+	case *types.Reference:
+		typ, indirect = t.Elem, true
+	case *types.Pointer:
+		typ, indirect = t.Elem, true
+	}
+	repr, ok := g.enumReprOf(typ)
+	if !ok || repr.layout == enumBoxed {
+		return "", repr, "", false, nil
+	}
+	llvmType, err := g.mapEnumType(typ, repr.enum.Name)
+	if err != nil {
+		return "", repr, "", false, err
+	}
+	reg, err := g.generateOperand(target)
+	if err != nil {
+		return "", repr, "", false, err
+	}
+	if indirect {
+		loadReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = load %s, %s* %s", loadReg, llvmType, llvmType, reg))
+		reg = loadReg
+	}
+	return reg, repr, llvmType, true, nil
+}
+
+// generateUnboxedEnum constructs a value of an enum laid out as its tag or
+// its payload, reporting false for a boxed enum.
+func (g *Generator) generateUnboxedEnum(cons *mir.ConstructEnum) (bool, error) {
+	repr, ok := g.enumReprOf(cons.Result.Type)
+	if !ok || repr.layout == enumBoxed {
+		return false, nil
+	}
+	llvmType, err := g.mapEnumType(cons.Result.Type, repr.enum.Name)
+	if err != nil {
+		return true, err
+	}
+
+	var reg string
+	switch {
+	case repr.layout == enumTag:
+		reg = fmt.Sprintf("%d", cons.VariantIndex)
+	case cons.VariantIndex == repr.none:
+		reg = "null"
+	default:
+		if len(cons.Values) != 1 {
+			return true, fmt.Errorf("variant %s takes 1 value, got %d", cons.Variant, len(cons.Values))
+		}
+		if reg, err = g.generateOperand(cons.Values[0]); err != nil {
+			return true, err
+		}
+	}
+	g.setEnumResult(cons.Result, llvmType, reg)
+	return true, nil
+}
+
+// generateUnboxedDiscriminant computes the variant index of an enum laid
+// out as its tag or its payload, reporting false for a boxed enum.
+func (g *Generator) generateUnboxedDiscriminant(disc *mir.Discriminant) (bool, error) {
+	valueReg, repr, llvmType, ok, err := g.enumValue(disc.Target)
+	if !ok || err != nil {
+		return ok, err
+	}
+	resultType, err := g.mapType(disc.Result.Type)
+	if err != nil {
+		return true, fmt.Errorf("failed to map result type: %w", err)
+	}
+
+	resultReg := valueReg
+	if repr.layout == enumNiche {
+		isSomeReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = icmp ne %s %s, null", isSomeReg, llvmType, valueReg))
+		resultReg = g.nextReg()
+		g.emit(fmt.Sprintf("  %s = select i1 %s, %s %d, %s %d", resultReg, isSomeReg, resultType, repr.some, resultType, repr.none))
+	} else if resultType != llvmType {
+		// Tags are variant indices, so never negative: zext
+		castOp := "zext"
+		if intBits(llvmType) > intBits(resultType) {
+			castOp = "trunc"
+		}
+		resultReg = g.nextReg()
+		g.emit(fmt.Sprintf("  %s = %s %s %s to %s", resultReg, castOp, llvmType, valueReg, resultType))
+	}
+	g.setEnumResult(disc.Result, resultType, resultReg)
+	return true, nil
+}
+
+// generateUnboxedPayload reads the payload of an enum laid out as its
+// payload, which is the value itself, reporting false for a boxed enum.
+func (g *Generator) generateUnboxedPayload(access *mir.AccessVariantPayload) (bool, error) {
+	valueReg, repr, llvmType, ok, err := g.enumValue(access.Target)
+	if !ok || err != nil {
+		return ok, err
+	}
+	if repr.layout != enumNiche || access.VariantIndex != repr.some || access.MemberIndex != 0 {
+		return true, fmt.Errorf("variant %d of %s has no payload member %d", access.VariantIndex, repr.enum.Name, access.MemberIndex)
+	}
+	g.setEnumResult(access.Result, llvmType, valueReg)
+	return true, nil
+}
@@ -0,0 +1,158 @@
+package mir2llvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func TestMapType_EnumLayouts(t *testing.T) {
+	gen := newTestGenerator()
+
+	node := &types.Struct{Name: "Node", Fields: []types.Field{{Name: "value", Type: types.TypeInt}}}
+	option := &types.Enum{
+		Name:       "Option",
+		TypeParams: []types.TypeParam{{Name: "T"}},
+		Variants: []types.Variant{
+			{Name: "Some", Params: []types.Type{&types.TypeParam{Name: "T"}}},
+			{Name: "None"},
+		},
+	}
+	color := &types.Enum{Name: "Color", Variants: []types.Variant{{Name: "Red"}, {Name: "Green"}}}
+	shape := &types.Enum{Name: "Shape", Variants: []types.Variant{
+		{Name: "Circle", Params: []types.Type{types.TypeInt}},
+		{Name: "Point"},
+	}}
+
+	tests := []struct {
+		name string
+		typ  types.Type
+		want string
+	}{
+		{"fieldless enum", color, "i32"},
+		{"option of reference", &types.GenericInstance{Base: option, Args: []types.Type{&types.Reference{Elem: types.TypeInt}}}, "i64*"},
+		{"option of struct", &types.GenericInstance{Base: option, Args: []types.Type{node}}, "%struct.Node*"},
+		{"option of int", &types.GenericInstance{Base: option, Args: []types.Type{types.TypeInt}}, "%enum.Option*"},
+		{"option of reference to enum", &types.GenericInstance{Base: option, Args: []types.Type{&types.Reference{Elem: color}}}, "%enum.Option*"},
+		{"int payload", shape, "%enum.Shape*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gen.mapType(tt.typ)
+			if err != nil {
+				t.Fatalf("mapType() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("mapType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateEnum_NicheLayout(t *testing.T) {
+	src := `package main;
+
+struct Node {
+	value: int,
+}
+
+enum Lookup {
+	Found(Node),
+	Missing,
+}
+
+fn find(flag: bool) -> Lookup {
+	if flag {
+		return Lookup::Found(Node { value: 7 });
+	}
+	return Lookup::Missing;
+}
+
+fn main() {
+	match find(true) {
+		Lookup::Found(n) => println(n.value),
+		Lookup::Missing => println(0),
+	};
+}
+`
+	p := parser.New(src)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("Parse error: %v", p.Errors()[0])
+	}
+	checker := types.NewChecker()
+	checker.Check(file)
+	if len(checker.Errors) > 0 {
+		t.Fatalf("Type check error: %v", checker.Errors[0])
+	}
+	lowerer := mir.NewLowerer(checker.ExprTypes, checker.CallTypeArgs, checker.GlobalScope, nil, nil)
+	mod, err := lowerer.LowerModule(file)
+	if err != nil {
+		t.Fatalf("Lower error: %v", err)
+	}
+
+	ir, err := NewGenerator().Generate(mod)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	for _, want := range []string{
+		"define %struct.Node* @find(i1 %flag)",
+		"store %struct.Node* null, %struct.Node**",
+		"icmp ne %struct.Node* ",
+		"select i1 ",
+	} {
+		if !strings.Contains(ir, want) {
+			t.Errorf("IR should contain %q, got:\n%s", want, ir)
+		}
+	}
+	if strings.Contains(ir, "getelementptr inbounds %enum.Lookup") {
+		t.Errorf("Lookup should not be allocated as a tagged object, got:\n%s", ir)
+	}
+}
+
+func TestLLVMTypeLayout(t *testing.T) {
+	tests := []struct {
+		typ         string
+		size, align int64
+	}{
+		{"i1", 1, 1},
+		{"i32", 4, 4},
+		{"double", 8, 8},
+		{"%String*", 8, 8},
+		{"i128", 16, 16},
+		{"[4 x i64]", 32, 8},
+		{"{i64, double}", 16, 8},
+		{"{i1, i64}", 16, 8},
+		{"{i32, i1}", 8, 4},
+		{"{[3 x i8], {i32, i8*}}", 24, 8},
+		{"{}", 0, 1},
+	}
+	for _, tt := range tests {
+		size, align := llvmTypeLayout(tt.typ, 8)
+		if size != tt.size || align != tt.align {
+			t.Errorf("llvmTypeLayout(%q) = %d, %d, want %d, %d", tt.typ, size, align, tt.size, tt.align)
+		}
+	}
+}
+
+func TestEmitEnumDefinitions_PayloadFitsLargestVariant(t *testing.T) {
+	gen := newTestGenerator()
+
+	shape := &types.Enum{Name: "Shape", Variants: []types.Variant{
+		{Name: "Quad", Params: []types.Type{&types.Array{Elem: types.TypeInt, Len: 4}}},
+		{Name: "Pair", Params: []types.Type{types.TypeBool, types.TypeFloat}},
+		{Name: "Empty"},
+	}}
+	module := &mir.Module{Enums: []*types.Enum{shape}}
+	gen.registerEnums(module)
+	gen.emitEnumDefinitions(module)
+
+	// The array of four ints takes 32 bytes, more than the 8 per value that
+	// would be enough for every other variant
+	if want := "%enum.Shape = type { i32, [32 x i8] }"; !strings.Contains(gen.builder.String(), want) {
+		t.Errorf("emitEnumDefinitions() should emit %q, got:\n%s", want, gen.builder.String())
+	}
+}
@@ -0,0 +1,99 @@
+package mir2llvm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+// emitExternDeclarations emits a declare for each C function the module
+// calls through an extern declaration. Functions the runtime declarations
+// already cover, such as pthread_create, are not declared twice.
+func (g *Generator) emitExternDeclarations(module *mir.Module) error {
+	if len(module.Externs) == 0 {
+		return nil
+	}
+
+	declared := g.builder.String()
+	for _, ext := range module.Externs {
+		if strings.Contains(declared, "@"+ext.Name+"(") {
+			continue
+		}
+
+		retType := "void"
+		if ext.ReturnType != nil {
+			var err error
+			if retType, err = g.mapType(ext.ReturnType); err != nil {
+				return fmt.Errorf("extern function %s: %w", ext.Name, err)
+			}
+		}
+		params := make([]string, len(ext.Params))
+		for i, param := range ext.Params {
+			var err error
+			if params[i], err = g.mapType(param); err != nil {
+				return fmt.Errorf("extern function %s: %w", ext.Name, err)
+			}
+		}
+		g.emit(fmt.Sprintf("declare %s @%s(%s)", retType, ext.Name, strings.Join(params, ", ")))
+	}
+	g.emit("")
+	return nil
+}
+
+// findExtern returns the extern function of the module named name, or nil
+func (g *Generator) findExtern(name string) *mir.Extern {
+	if g.currentModule == nil {
+		return nil
+	}
+	for _, ext := range g.currentModule.Externs {
+		if ext.Name == name {
+			return ext
+		}
+	}
+	return nil
+}
+
+// coerceExternArgs converts the arguments of a call to ext whose LLVM type
+// differs from the declared parameter type, such as an i64 literal passed
+// as an i32, rewriting argRegs and argTypes in place
+func (g *Generator) coerceExternArgs(ext *mir.Extern, argRegs, argTypes []string) error {
+	for i, param := range ext.Params {
+		if i >= len(argRegs) {
+			break
+		}
+		want, err := g.mapType(param)
+		if err != nil {
+			return err
+		}
+		have := argTypes[i]
+		if have == want {
+			continue
+		}
+
+		var op string
+		haveInt, wantInt := isIntType(have), isIntType(want)
+		switch {
+		case haveInt && wantInt && intBits(have) > intBits(want):
+			op = "trunc"
+		case haveInt && wantInt:
+			op = "sext"
+		case strings.HasSuffix(have, "*") && strings.HasSuffix(want, "*"):
+			op = "bitcast"
+		default:
+			return fmt.Errorf("cannot pass %s as %s to extern function %s", have, want, ext.Name)
+		}
+		reg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = %s %s %s to %s", reg, op, have, argRegs[i], want))
+		argRegs[i] = reg
+		argTypes[i] = want
+	}
+	return nil
+}
+
+// isIntType reports whether llvmType is an integer type such as i32
+func isIntType(llvmType string) bool {
+	_, err := strconv.Atoi(strings.TrimPrefix(llvmType, "i"))
+	return strings.HasPrefix(llvmType, "i") && err == nil
+}
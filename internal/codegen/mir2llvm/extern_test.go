@@ -0,0 +1,76 @@
+package mir2llvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func TestGenerateExternDeclarations(t *testing.T) {
+	src := `package main;
+
+extern "C" fn abs(x: i32) -> i32;
+extern "C" fn srand(seed: u32);
+
+fn magnitude(x: i32) -> i32 {
+	return abs(x);
+}
+
+fn main() {}
+`
+	p := parser.New(src)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("Parse error: %v", p.Errors()[0])
+	}
+	checker := types.NewChecker()
+	checker.Check(file)
+	if len(checker.Errors) > 0 {
+		t.Fatalf("Type check error: %v", checker.Errors[0])
+	}
+	mod, err := mir.NewLowerer(checker.ExprTypes, checker.CallTypeArgs, nil, nil, nil).LowerModule(file)
+	if err != nil {
+		t.Fatalf("Lower error: %v", err)
+	}
+
+	ir, err := NewGenerator().Generate(mod)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	for _, want := range []string{"declare i32 @abs(i32)", "declare void @srand(i32)", "call i32 @abs(i32 "} {
+		if !strings.Contains(ir, want) {
+			t.Errorf("IR should contain %q, got:\n%s", want, ir)
+		}
+	}
+	if strings.Contains(ir, "define i32 @abs(") {
+		t.Errorf("extern function abs should not be defined, got:\n%s", ir)
+	}
+}
+
+func TestGenerateExternCallCoercesArguments(t *testing.T) {
+	gen := newTestGenerator()
+
+	call := &mir.Call{
+		Result: mir.Local{ID: 1, Name: "result", Type: types.TypeVoid},
+		Func:   "srand",
+		Args:   []mir.Operand{&mir.Literal{Type: types.TypeInt, Value: int64(5)}},
+	}
+	fn := createTestFunction("test", []mir.Local{}, types.TypeVoid)
+	fn.Entry.Statements = []mir.Statement{call}
+	fn.Entry.Terminator = &mir.Return{Value: nil}
+
+	module := &mir.Module{
+		Functions: []*mir.Function{fn},
+		Externs:   []*mir.Extern{{Name: "srand", Params: []types.Type{&types.Primitive{Kind: types.U32}}}},
+	}
+	result, err := gen.Generate(module)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if !strings.Contains(result, "trunc i64 5 to i32") {
+		t.Errorf("Generate() should truncate the i64 argument to i32, got:\n%s", result)
+	}
+}
@@ -7,12 +7,29 @@ import (
 	"github.com/malphas-lang/malphas-lang/internal/mir"
 )
 
+// inlineAttribute returns the LLVM function attribute for an #[inline]
+// request, with a leading space, or "" for none.
+func inlineAttribute(kind mir.InlineKind) string {
+	switch kind {
+	case mir.InlineHint:
+		return " inlinehint"
+	case mir.InlineAlways:
+		return " alwaysinline"
+	case mir.InlineNever:
+		return " noinline"
+	}
+	return ""
+}
+
 // generateFunction generates LLVM IR for a MIR function
 func (g *Generator) generateFunction(fn *mir.Function) error {
 	// Set current function
 	g.currentFunc = fn
+	g.functions[sanitizeName(fn.Name)] = fn
 	g.localRegs = make(map[int]string)
 	g.blockLabels = make(map[*mir.BasicBlock]string)
+	g.stackSlots = make(map[mir.Statement]string)
+	g.arraySlots = make(map[int]string)
 	g.regCounter = 0
 
 	// Map return type
@@ -40,9 +57,14 @@ func (g *Generator) generateFunction(fn *mir.Function) error {
 		paramParts = append(paramParts, fmt.Sprintf("%s %%%s", paramType, paramName))
 	}
 
+	// main receives the C argc/argv so the runtime can expose them to the program
+	if fn.Name == "main" {
+		paramParts = append([]string{"i32 %argc", "i8** %argv"}, paramParts...)
+	}
+
 	// Emit function signature
 	paramsStr := strings.Join(paramParts, ", ")
-	g.emit(fmt.Sprintf("define %s @%s(%s) {", retLLVM, sanitizeName(fn.Name), paramsStr))
+	g.builder.beginFunction(fmt.Sprintf("define %s @%s(%s)%s {", retLLVM, sanitizeName(fn.Name), paramsStr, inlineAttribute(fn.Inline)))
 
 	// Map parameters to their initial register names (they're in SSA registers)
 	// We'll allocate space for them after emitting the entry label
@@ -84,14 +106,23 @@ func (g *Generator) generateFunction(fn *mir.Function) error {
 		g.blockLabels[block] = label
 	}
 
-	// Generate blocks in order
+	// Generate blocks in order. Blocks nothing jumps to, such as the code
+	// after a call of panic, are left out: they may use the results of calls
+	// that never return.
+	reachable := reachableBlocks(fn)
 	for _, block := range fn.Blocks {
+		if !reachable[block] {
+			continue
+		}
 		// Use mapped label
 		llvmLabel := g.blockLabels[block]
 
 		// Emit label (use "entry" for entry block, otherwise use the label)
 		if block == fn.Entry {
-			g.emit("entry:")
+			g.emitLabel("entry")
+			if fn.Name == "main" {
+				g.emit("  call void @runtime_args_init(i32 %argc, i8** %argv)")
+			}
 
 			// Allocate space for parameters immediately after entry label
 			// This ensures allocas come after the label in LLVM IR
@@ -112,6 +143,9 @@ func (g *Generator) generateFunction(fn *mir.Function) error {
 				g.emit(fmt.Sprintf("  store %s %s, %s* %s", paramType, paramReg, paramType, allocaReg))
 				// Update register mapping to point to alloca
 				g.localRegs[param.ID] = allocaReg
+				if arrayOf(param.Type) != nil {
+					g.arraySlots[param.ID] = allocaReg
+				}
 				// Mark as alloca (not a direct value)
 				g.localIsValue[param.ID] = false
 			}
@@ -135,13 +169,20 @@ func (g *Generator) generateFunction(fn *mir.Function) error {
 				allocaReg := g.nextReg()
 				g.emit(fmt.Sprintf("  %s = alloca %s", allocaReg, localType))
 				g.localRegs[local.ID] = allocaReg
+				if arrayOf(local.Type) != nil {
+					g.arraySlots[local.ID] = allocaReg
+				}
 				// Mark as alloca (not a direct value)
 				// This is important because later code might try to mark this as a value
 				// (e.g., AccessVariantPayload), but we want to ensure allocas are treated correctly
 				g.localIsValue[local.ID] = false
 			}
+
+			if err := g.emitStackSlots(fn); err != nil {
+				return err
+			}
 		} else {
-			g.emit(fmt.Sprintf("%s:", llvmLabel))
+			g.emitLabel(llvmLabel)
 		}
 
 		if err := g.generateBlock(block, fn, retLLVM); err != nil {
@@ -149,12 +190,82 @@ func (g *Generator) generateFunction(fn *mir.Function) error {
 		}
 	}
 
-	g.emit("}")
+	g.builder.endFunction()
 	g.emit("")
 
 	return nil
 }
 
+// reachableBlocks returns the blocks of fn that control can reach from its
+// entry block
+func reachableBlocks(fn *mir.Function) map[*mir.BasicBlock]bool {
+	reachable := make(map[*mir.BasicBlock]bool)
+	work := []*mir.BasicBlock{fn.Entry}
+	for len(work) > 0 {
+		block := work[len(work)-1]
+		work = work[:len(work)-1]
+		if block == nil || reachable[block] {
+			continue
+		}
+		reachable[block] = true
+		switch t := block.Terminator.(type) {
+		case *mir.Goto:
+			work = append(work, t.Target)
+		case *mir.Branch:
+			work = append(work, t.True, t.False)
+		case *mir.Select:
+			for _, c := range t.Cases {
+				work = append(work, c.Target)
+			}
+		}
+	}
+	return reachable
+}
+
+// emitStackSlots reserves entry-block storage for every construction that
+// escape analysis marked StackAlloc. A construction inside a loop reuses its
+// slot on each iteration rather than growing the frame.
+func (g *Generator) emitStackSlots(fn *mir.Function) error {
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			var sizeReg string
+			var err error
+			switch s := stmt.(type) {
+			case *mir.ConstructStruct:
+				if !s.StackAlloc {
+					continue
+				}
+				sizeReg, err = g.calculateElementSize(s.Result.Type)
+			case *mir.ConstructTuple:
+				if !s.StackAlloc {
+					continue
+				}
+				sizeReg, err = g.calculateElementSize(s.Result.Type)
+			case *mir.ConstructEnum:
+				if !s.StackAlloc || !g.enumBoxed(s.Result.Type) {
+					continue
+				}
+				sizeReg, err = g.calculateElementSize(s.Result.Type)
+			case *mir.MakeClosure:
+				if !s.StackAlloc {
+					continue
+				}
+				sizeReg = "16"
+			default:
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to size stack slot: %w", err)
+			}
+
+			slotReg := g.nextReg()
+			g.emit(fmt.Sprintf("  %s = alloca i8, i64 %s, align 16", slotReg, sizeReg))
+			g.stackSlots[stmt] = slotReg
+		}
+	}
+	return nil
+}
+
 // generateBlock generates LLVM IR for a basic block
 func (g *Generator) generateBlock(block *mir.BasicBlock, fn *mir.Function, retLLVM string) error {
 	// Generate statements
@@ -162,6 +273,7 @@ func (g *Generator) generateBlock(block *mir.BasicBlock, fn *mir.Function, retLL
 		if err := g.generateStatement(stmt); err != nil {
 			return fmt.Errorf("error generating statement: %w", err)
 		}
+		g.pinArrayResult(stmt)
 	}
 
 	// Generate terminator
@@ -172,11 +284,11 @@ func (g *Generator) generateBlock(block *mir.BasicBlock, fn *mir.Function, retLL
 	} else {
 		// Block without terminator - add implicit return if void
 		if retLLVM == "void" {
-			g.emit("  ret void")
+			g.emitTerminator("  ret void")
 		} else {
 			// Non-void function without return - this is an error
 			// For now, return undef
-			g.emit(fmt.Sprintf("  ret %s undef", retLLVM))
+			g.emitTerminator(fmt.Sprintf("  ret %s undef", retLLVM))
 		}
 	}
 
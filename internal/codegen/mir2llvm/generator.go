@@ -2,16 +2,18 @@ package mir2llvm
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/malphas-lang/malphas-lang/internal/diag"
 	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
 // Generator generates LLVM IR from MIR
 type Generator struct {
-	// Output buffer for LLVM IR
-	builder strings.Builder
+	// Output buffer for LLVM IR, tracking basic blocks inside function bodies
+	builder irBuilder
 
 	// Current function being generated
 	currentFunc *mir.Function
@@ -25,6 +27,16 @@ type Generator struct {
 	// Block label mapping (MIR BasicBlock -> LLVM label)
 	blockLabels map[*mir.BasicBlock]string
 
+	// Entry-block storage for constructions marked StackAlloc (statement -> i8* register)
+	stackSlots map[mir.Statement]string
+
+	// Stack slots of the array locals of the current function (Local.ID ->
+	// [N x T]* register), which always hold their arrays
+	arraySlots map[int]string
+
+	// Generated functions by LLVM name, for attributing invalid IR to source
+	functions map[string]*mir.Function
+
 	// Register counter for generating unique register names
 	regCounter int
 
@@ -37,6 +49,15 @@ type Generator struct {
 	// Track defined enum types
 	enumTypes map[string]bool
 
+	// Definition of each defined enum, to lay out enum types named without one
+	enumDefs map[string]*types.Enum
+
+	// Payload size in bytes of each defined enum (the N in { i32, [N x i8] })
+	enumPayloadSizes map[string]int64
+
+	// LLVM type of the tag of each defined enum with a #[repr] (i32 otherwise)
+	enumTagTypes map[string]string
+
 	// Modules for cross-module references (needed for type info)
 	modules map[string]interface{} // We'll need AST files, but use interface{} for now
 
@@ -51,24 +72,68 @@ type Generator struct {
 
 	// Spawn wrapper functions (collected during generation)
 	spawnWrappers []string
+
+	// Name of each entry in spawnWrappers
+	spawnWrapperNames []string
+
+	// LLVM intrinsic declarations used by the module (name -> declaration)
+	intrinsics map[string]string
+
+	// Overflow selects the integer overflow behavior (wrap, panic, or checked)
+	Overflow OverflowMode
+
+	// Target is the platform the module is generated for
+	Target Target
+
+	// Workers bounds how many functions are generated concurrently
+	// (0 uses GOMAXPROCS, 1 generates serially)
+	Workers int
+
+	// Cache, if set, holds the IR of monomorphized functions from earlier
+	// builds. CacheSalt identifies the compiler build; entries written by a
+	// different salt are never reused.
+	Cache     FragmentCache
+	CacheSalt string
 }
 
 // NewGenerator creates a new MIR-to-LLVM generator
 func NewGenerator() *Generator {
 	return &Generator{
-		localRegs:       make(map[int]string),
-		localIsValue:    make(map[int]bool),
-		blockLabels:     make(map[*mir.BasicBlock]string),
-		regCounter:      0,
-		structTypes:     make(map[string]bool),
-		structFields:    make(map[string]map[string]int),
-		enumTypes:       make(map[string]bool),
-		modules:         make(map[string]interface{}),
-		Errors:          make([]diag.Diagnostic, 0),
-		stringConstants: make(map[string]string),
+		localRegs:        make(map[int]string),
+		localIsValue:     make(map[int]bool),
+		blockLabels:      make(map[*mir.BasicBlock]string),
+		stackSlots:       make(map[mir.Statement]string),
+		arraySlots:       make(map[int]string),
+		functions:        make(map[string]*mir.Function),
+		regCounter:       0,
+		structTypes:      make(map[string]bool),
+		structFields:     make(map[string]map[string]int),
+		enumTypes:        make(map[string]bool),
+		enumDefs:         make(map[string]*types.Enum),
+		enumPayloadSizes: make(map[string]int64),
+		enumTagTypes:     make(map[string]string),
+		modules:          make(map[string]interface{}),
+		Errors:           make([]diag.Diagnostic, 0),
+		stringConstants:  make(map[string]string),
+		intrinsics:       make(map[string]string),
+		Target:           DefaultTarget,
 	}
 }
 
+// findFunction returns the module function with the given (possibly unsanitized) name
+func (g *Generator) findFunction(name string) *mir.Function {
+	if g.currentModule == nil {
+		return nil
+	}
+	target := sanitizeName(name)
+	for _, fn := range g.currentModule.Functions {
+		if sanitizeName(fn.Name) == target {
+			return fn
+		}
+	}
+	return nil
+}
+
 // Generate generates LLVM IR from a MIR Module
 func (g *Generator) Generate(module *mir.Module) (string, error) {
 	// Reset state
@@ -76,10 +141,13 @@ func (g *Generator) Generate(module *mir.Module) (string, error) {
 	g.localRegs = make(map[int]string)
 	g.localIsValue = make(map[int]bool)
 	g.blockLabels = make(map[*mir.BasicBlock]string)
+	g.functions = make(map[string]*mir.Function)
 	g.regCounter = 0
 	g.Errors = make([]diag.Diagnostic, 0)
 	g.stringConstants = make(map[string]string)
 	g.spawnWrappers = make([]string, 0)
+	g.spawnWrapperNames = make([]string, 0)
+	g.intrinsics = make(map[string]string)
 	g.currentModule = module // Store current module for struct lookups
 
 	// Emit module header
@@ -88,6 +156,15 @@ func (g *Generator) Generate(module *mir.Module) (string, error) {
 	// Emit runtime declarations (same as AST-to-LLVM generator)
 	g.emitRuntimeDeclarations()
 
+	// Record the enums first: the layout of an enum type is needed to map
+	// it in extern signatures and struct fields
+	g.registerEnums(module)
+
+	// Emit declarations for the C functions of extern declarations
+	if err := g.emitExternDeclarations(module); err != nil {
+		return "", err
+	}
+
 	// Emit common type declarations
 	g.emitCommonTypeDeclarations()
 
@@ -100,15 +177,14 @@ func (g *Generator) Generate(module *mir.Module) (string, error) {
 	// Emit enum definitions
 	g.emitEnumDefinitions(module)
 
-	// Generate functions
-	for _, fn := range module.Functions {
-		// Skip generic functions - only generate specialized (monomorphized) versions
-		if len(fn.TypeParams) > 0 {
-			continue
-		}
-		if err := g.generateFunction(fn); err != nil {
-			return "", fmt.Errorf("error generating function %s: %w", fn.Name, err)
-		}
+	// Emit globals for statics
+	if err := g.emitStatics(module); err != nil {
+		return "", err
+	}
+
+	// Generate functions (concurrently, merged back in module order)
+	if err := g.generateFunctions(module.Functions); err != nil {
+		return "", err
 	}
 
 	// Emit spawn wrapper functions
@@ -119,21 +195,37 @@ func (g *Generator) Generate(module *mir.Module) (string, error) {
 	// Emit string constants
 	g.emitStringConstants()
 
+	// Emit declarations for LLVM intrinsics used by the generated code
+	g.emitIntrinsicDeclarations()
+
 	return g.builder.String(), nil
 }
 
-// emit writes a line to the output buffer
+// emit writes a line to the output buffer. Inside a function body the line
+// must be a non-terminator instruction; use emitTerminator and emitLabel for
+// control flow.
 func (g *Generator) emit(line string) {
-	g.builder.WriteString(line)
-	g.builder.WriteString("\n")
+	g.builder.instr(line)
+}
+
+// emitTerminator ends the current basic block with a br, ret, switch or
+// unreachable instruction
+func (g *Generator) emitTerminator(line string) {
+	g.builder.terminate(line)
+}
+
+// emitLabel starts a new basic block, falling through from the current one
+// if it has no terminator yet
+func (g *Generator) emitLabel(label string) {
+	g.builder.startBlock(label)
 }
 
 // emitModuleHeader emits the LLVM module header
 func (g *Generator) emitModuleHeader() {
 	g.emit("; ModuleID = 'malphas'")
 	g.emit("source_filename = \"malphas\"")
-	g.emit("target datalayout = \"e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128\"")
-	g.emit("target triple = \"x86_64-unknown-linux-gnu\"")
+	g.emit(fmt.Sprintf("target datalayout = %q", g.Target.DataLayout))
+	g.emit(fmt.Sprintf("target triple = %q", g.Target.Triple))
 	g.emit("")
 }
 
@@ -149,6 +241,11 @@ func (g *Generator) emitRuntimeDeclarations() {
 	g.emit("declare i8* @runtime_alloc(i64)")
 	g.emit("")
 
+	// Finalizers for values with a Drop impl
+	g.emit("declare void @runtime_drop_register(i8*, void (i8*)*)")
+	g.emit("declare void @runtime_drop_cancel(i8*)")
+	g.emit("")
+
 	// String operations
 	g.emit("declare %String* @runtime_string_new(i8*, i64)")
 	g.emit("declare void @runtime_string_free(%String*)")
@@ -158,12 +255,28 @@ func (g *Generator) emitRuntimeDeclarations() {
 	g.emit("declare %String* @runtime_string_from_double(double)")
 	g.emit("declare %String* @runtime_string_from_bool(i1)")
 	g.emit("declare %String* @runtime_string_format(%String*, %String*, %String*, %String*, %String*)")
+	g.emit("declare i64 @runtime_string_len(%String*)")
+	g.emit("declare %String* @runtime_string_substring(%String*, i64, i64)")
+	g.emit("declare i64 @runtime_string_index_of(%String*, %String*)")
+	g.emit("declare i64 @runtime_string_compare(%String*, %String*)")
+	g.emit("declare %String* @runtime_string_to_upper(%String*)")
+	g.emit("declare %String* @runtime_string_to_lower(%String*)")
+	g.emit("declare %String* @runtime_string_trim(%String*)")
+	g.emit("declare i64 @runtime_string_builder_new(i64)")
+	g.emit("declare void @runtime_string_builder_append(i64, %String*)")
+	g.emit("declare i64 @runtime_string_builder_len(i64)")
+	g.emit("declare %String* @runtime_string_builder_build(i64)")
+	g.emit("declare void @runtime_string_builder_clear(i64)")
 	g.emit("")
 
 	// Print functions
 	g.emit("declare void @runtime_println_i64(i64)")
 	g.emit("declare void @runtime_println_i32(i32)")
 	g.emit("declare void @runtime_println_i8(i8)")
+	g.emit("declare void @runtime_println_u64(i64)")
+	g.emit("declare void @runtime_println_u32(i32)")
+	g.emit("declare void @runtime_println_u16(i16)")
+	g.emit("declare void @runtime_println_u8(i8)")
 	g.emit("declare void @runtime_println_double(double)")
 	g.emit("declare void @runtime_println_bool(i1)")
 	g.emit("declare void @runtime_println_string(%String*)")
@@ -187,12 +300,15 @@ func (g *Generator) emitRuntimeDeclarations() {
 	g.emit("")
 
 	// HashMap operations
-	g.emit("declare %HashMap* @runtime_hashmap_new()")
-	g.emit("declare void @runtime_hashmap_put(%HashMap*, %String*, i8*)")
-	g.emit("declare i8* @runtime_hashmap_get(%HashMap*, %String*)")
-	g.emit("declare i8 @runtime_hashmap_contains_key(%HashMap*, %String*)")
+	g.emit("declare %HashMap* @runtime_hashmap_new(i64, i64, i8)")
+	g.emit("declare void @runtime_hashmap_put(%HashMap*, i8*, i8*)")
+	g.emit("declare i8* @runtime_hashmap_get(%HashMap*, i8*)")
+	g.emit("declare i8 @runtime_hashmap_contains_key(%HashMap*, i8*)")
+	g.emit("declare i8* @runtime_hashmap_remove(%HashMap*, i8*)")
 	g.emit("declare i64 @runtime_hashmap_len(%HashMap*)")
 	g.emit("declare i8 @runtime_hashmap_is_empty(%HashMap*)")
+	g.emit("declare %struct.Slice* @runtime_hashmap_keys(%HashMap*)")
+	g.emit("declare %struct.Slice* @runtime_hashmap_values(%HashMap*)")
 	g.emit("declare void @runtime_hashmap_free(%HashMap*)")
 	g.emit("")
 
@@ -200,12 +316,15 @@ func (g *Generator) emitRuntimeDeclarations() {
 	g.emit("declare %Channel* @runtime_channel_new(i64, i64)")
 	g.emit("declare void @runtime_channel_send(%Channel*, i8*)")
 	g.emit("declare i8* @runtime_channel_recv(%Channel*)")
+	g.emit("declare i8* @runtime_channel_recv_or_zero(%Channel*)")
 	g.emit("declare void @runtime_channel_close(%Channel*)")
 	g.emit("declare i8 @runtime_channel_is_closed(%Channel*)")
 	g.emit("declare i8 @runtime_channel_try_send(%Channel*, i8*)")
 	g.emit("declare i8 @runtime_channel_try_recv(%Channel*, i8**)")
 	g.emit("declare void @runtime_channel_wait_for_send(%Channel*)")
 	g.emit("declare void @runtime_channel_wait_for_recv(%Channel*)")
+	g.emit("%SelectCase = type { %Channel*, i8*, i64 }")
+	g.emit("declare i64 @runtime_select(%SelectCase*, i64, i8, i64)")
 	g.emit("declare void @runtime_nanosleep(i64)")
 	g.emit("")
 
@@ -221,10 +340,74 @@ func (g *Generator) emitRuntimeDeclarations() {
 	g.emit("; Legion (user-level thread) operations")
 	g.emit("%Legion = type opaque")
 	g.emit("declare %Legion* @runtime_legion_spawn(void (i8*)*, i8*, i64)")
+	g.emit("declare i8* @runtime_spawn_args_alloc(i64)")
+	g.emit("declare void @runtime_spawn_args_free(i8*)")
 	g.emit("declare void @runtime_legion_start(%Legion*)")
+	g.emit("declare void @runtime_legion_set_result(i8*)")
+	g.emit("declare i8* @runtime_legion_join(%Legion*)")
 	g.emit("declare void @runtime_legion_yield()")
 	g.emit("declare void @runtime_scheduler_shutdown()")
 	g.emit("")
+
+	// Panics and arithmetic traps (--overflow=panic|checked)
+	g.emit("declare void @runtime_panic(%String*) noreturn")
+	g.emit("declare void @runtime_panic_overflow(i32)")
+	g.emit("declare void @runtime_index_panic(i64, i64) noreturn")
+	g.emit("declare i8* @runtime_optional_unwrap(i8*, %String*)")
+	g.emit("")
+
+	// Process entry, arguments and environment
+	g.emit("declare void @runtime_report_main_error(%String*)")
+	g.emit("declare void @runtime_args_init(i32, i8**)")
+	g.emit("declare i64 @runtime_args_count()")
+	g.emit("declare %String* @runtime_args_get(i64)")
+	g.emit("declare %String** @runtime_env_get(%String*)")
+	g.emit("declare i64 @runtime_env_set(%String*, %String*)")
+	g.emit("")
+
+	// Time
+	g.emit("declare i64 @runtime_time_monotonic_ns()")
+	g.emit("declare i64 @runtime_time_wall_ns()")
+	g.emit("declare void @runtime_time_sleep_ns(i64)")
+	g.emit("")
+
+	// File I/O
+	g.emit("declare i64 @runtime_fs_open(%String*, i64)")
+	g.emit("declare i64 @runtime_fs_close(i64)")
+	g.emit("declare i64 @runtime_fs_write(i64, %String*)")
+	g.emit("declare %String* @runtime_fs_read_all(i64)")
+	g.emit("declare i64 @runtime_fs_last_error()")
+	g.emit("declare %String* @runtime_fs_strerror(i64)")
+	g.emit("declare i64 @runtime_fs_reader_new(i64)")
+	g.emit("declare %String** @runtime_fs_reader_read_line(i64)")
+	g.emit("declare %String* @runtime_fs_reader_read(i64, i64)")
+	g.emit("")
+
+	// TCP networking
+	g.emit("declare i64 @runtime_net_listen(%String*, i64)")
+	g.emit("declare i64 @runtime_net_accept(i64)")
+	g.emit("declare i64 @runtime_net_connect(%String*, i64)")
+	g.emit("declare i64 @runtime_net_local_port(i64)")
+	g.emit("")
+
+	// Synchronization
+	g.emit("declare i64 @runtime_mutex_new()")
+	g.emit("declare i64 @runtime_mutex_try_lock(i64)")
+	g.emit("declare void @runtime_mutex_lock(i64)")
+	g.emit("declare void @runtime_mutex_unlock(i64)")
+	g.emit("declare i64 @runtime_rwlock_new()")
+	g.emit("declare void @runtime_rwlock_read_lock(i64)")
+	g.emit("declare void @runtime_rwlock_read_unlock(i64)")
+	g.emit("declare void @runtime_rwlock_write_lock(i64)")
+	g.emit("declare void @runtime_rwlock_write_unlock(i64)")
+	g.emit("declare i64 @runtime_atomic_new(i64)")
+	g.emit("declare i64 @runtime_atomic_load(i64)")
+	g.emit("declare void @runtime_atomic_store(i64, i64)")
+	g.emit("declare i64 @runtime_atomic_fetch_add(i64, i64)")
+	g.emit("declare i64 @runtime_atomic_fetch_sub(i64, i64)")
+	g.emit("declare i64 @runtime_atomic_swap(i64, i64)")
+	g.emit("declare i64 @runtime_atomic_compare_exchange(i64, i64, i64)")
+	g.emit("")
 }
 
 // emitCommonTypeDeclarations emits type declarations for common stdlib types
@@ -245,11 +428,11 @@ func (g *Generator) emitCommonTypeDeclarations() {
 // emitGCInitialization emits GC initialization as a global constructor
 func (g *Generator) emitGCInitialization() {
 	g.emit("; GC initialization function")
-	g.emit("define internal void @malphas_gc_init() {")
-	g.emit("entry:")
+	g.builder.beginFunction("define internal void @malphas_gc_init() {")
+	g.emitLabel("entry")
 	g.emit("  call void @runtime_gc_init()")
-	g.emit("  ret void")
-	g.emit("}")
+	g.emitTerminator("  ret void")
+	g.builder.endFunction()
 	g.emit("")
 	g.emit("; Global constructor to initialize GC at program startup")
 	g.emit("@llvm.global_ctors = appending global [1 x { i32, void ()*, i8* }] [{ i32, void ()*, i8* } { i32 65535, void ()* @malphas_gc_init, i8* null }]")
@@ -263,25 +446,40 @@ func (g *Generator) nextReg() string {
 	return reg
 }
 
-// emitStringConstants emits the global string constants
+// stringConstant returns the global holding the String for content,
+// numbering a new one on first use. Identical literals share one global.
+func (g *Generator) stringConstant(content string) string {
+	name, ok := g.stringConstants[content]
+	if !ok {
+		name = fmt.Sprintf("@.str.%d", len(g.stringConstants))
+		g.stringConstants[content] = name
+	}
+	return name
+}
+
+// emitStringConstants emits the string constants in numbering order. Each
+// is a String laid out as the runtime's { len, data }, pointing at its
+// null-terminated bytes, so a literal is used without allocating. The
+// runtime never writes to a String it did not just allocate, so both can
+// be read-only.
 func (g *Generator) emitStringConstants() {
 	if len(g.stringConstants) == 0 {
 		return
 	}
 
-	g.emit("")
-	g.emit("; String constants")
+	contents := make([]string, len(g.stringConstants))
 	for content, name := range g.stringConstants {
-		// Calculate length including null terminator?
-		// Malphas strings might not be null-terminated internally, but let's follow C style for now
-		// or just raw bytes. runtime_string_new takes length.
-		// Let's emit as [N x i8]
-
-		// Escape string content for LLVM
-		escaped := escapeStringForLLVM(content)
-		length := len(content)
+		index, _ := strconv.Atoi(strings.TrimPrefix(name, "@.str."))
+		contents[index] = content
+	}
 
-		g.emit(fmt.Sprintf("%s = private unnamed_addr constant [%d x i8] c\"%s\", align 1", name, length, escaped))
+	g.emit("")
+	g.emit("; String constants")
+	for i, content := range contents {
+		name := fmt.Sprintf("@.str.%d", i)
+		n := len(content) + 1
+		g.emit(fmt.Sprintf("%s.data = private unnamed_addr constant [%d x i8] c\"%s\\00\", align 1", name, n, escapeStringForLLVM(content)))
+		g.emit(fmt.Sprintf("%s = private unnamed_addr constant { i64, i8* } { i64 %d, i8* getelementptr inbounds ([%d x i8], [%d x i8]* %s.data, i64 0, i64 0) }", name, len(content), n, n, name))
 	}
 	g.emit("")
 }
@@ -308,7 +506,8 @@ func (g *Generator) emitStructDefinitions(module *mir.Module) {
 	g.emit("; Struct definitions")
 	for _, s := range module.Structs {
 		name := sanitizeName(s.Name)
-		if g.structTypes[name] {
+		// Only the specializations of a generic struct have a layout
+		if g.structTypes[name] || len(s.TypeParams) > 0 {
 			continue
 		}
 		g.structTypes[name] = true
@@ -352,35 +551,29 @@ func (g *Generator) emitEnumDefinitions(module *mir.Module) {
 	}
 
 	g.emit("; Enum definitions")
+	emitted := make(map[string]bool)
 	for _, e := range module.Enums {
 		name := sanitizeName(e.Name)
-		if g.enumTypes[name] {
+		// Only the specializations of a generic enum have a layout
+		if emitted[name] || len(e.TypeParams) > 0 {
 			continue
 		}
-		g.enumTypes[name] = true
-
-		// Enums are represented as { tag, payload }
-		// Tag is i64 (or smaller if possible, but let's stick to i64 for alignment)
-		// Payload is a byte array large enough to hold the largest variant
-		// For now, we use a fixed size payload or calculate max size.
-		// Calculating max size requires mapping all variant payload types.
+		emitted[name] = true
 
+		// Enums are represented as { tag, payload }, where the payload is a
+		// byte array large enough to hold the largest variant's values, laid
+		// out as the { ... } struct that constructing and matching use
 		maxSize := int64(0)
 		for _, v := range e.Variants {
-			// Calculate size of this variant's payload
-			currentSize := int64(0)
-			for _, _ = range v.Params {
-				// We need size in bytes.
-				// g.calculateElementSize returns string (might be register).
-				// But here we need constant size for type definition.
-				// We can estimate or use a safe upper bound (e.g. 256 bytes).
-				// Or use a pointer to payload if it's large?
-				// Malphas enums are value types, so payload is inline.
-
-				// For now, let's assume max payload size is 64 bytes (8 pointers).
-				// TODO: Calculate actual max size
-				currentSize += 8 // Assume 8 bytes per field
+			var fields []string
+			for _, param := range v.Params {
+				llvmType, err := g.mapType(param)
+				if err != nil {
+					llvmType = "i8*"
+				}
+				fields = append(fields, llvmType)
 			}
+			currentSize, _ := llvmTypeLayout("{"+strings.Join(fields, ", ")+"}", g.Target.PointerSize())
 			if currentSize > maxSize {
 				maxSize = currentSize
 			}
@@ -391,8 +584,39 @@ func (g *Generator) emitEnumDefinitions(module *mir.Module) {
 
 		// Emit enum definition
 		// %enum.Name = type { i32, [N x i8] }
-		// We use i32 for tag.
-		g.emit(fmt.Sprintf("%%enum.%s = type { i32, [%d x i8] }", name, maxSize))
+		g.emit(fmt.Sprintf("%%enum.%s = type { %s, [%d x i8] }", name, g.enumTagType(name), maxSize))
+		g.enumPayloadSizes[name] = maxSize
 	}
 	g.emit("")
 }
+
+// registerEnums records the definition and tag type of each enum of
+// module. The tag is i32 unless #[repr] gives another integer type.
+func (g *Generator) registerEnums(module *mir.Module) {
+	for _, e := range module.Enums {
+		name := sanitizeName(e.Name)
+		if g.enumTypes[name] {
+			continue
+		}
+		g.enumTypes[name] = true
+		g.enumDefs[name] = e
+		if e.Repr != nil {
+			if tagType, err := g.mapType(e.Repr); err == nil {
+				g.enumTagTypes[name] = tagType
+			}
+		}
+	}
+}
+
+// enumTagType returns the LLVM type of the tag field of an enum.
+func (g *Generator) enumTagType(enumName string) string {
+	if tagType, ok := g.enumTagTypes[sanitizeName(enumName)]; ok {
+		return tagType
+	}
+	return "i32"
+}
+
+// enumPayloadType returns the LLVM type of the payload field of an enum.
+func (g *Generator) enumPayloadType(enumName string) string {
+	return fmt.Sprintf("[%d x i8]", g.enumPayloadSizes[sanitizeName(enumName)])
+}
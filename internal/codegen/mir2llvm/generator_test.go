@@ -1,6 +1,7 @@
 package mir2llvm
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 
@@ -324,10 +325,12 @@ func TestSanitizeName(t *testing.T) {
 	}{
 		{"simple", "foo", "foo"},
 		{"with_underscore", "foo_bar", "foo_bar"},
-		{"with_dot", "foo.bar", "foo.bar"},
-		{"with_dash", "foo-bar", "foo_bar"},
-		{"with_special", "foo@bar#baz", "foo_bar_baz"},
-		{"starts_with_number", "123foo", "_123foo"},
+		{"path", "Foo::bar", "Foo.bar"},
+		{"with_dot", "foo.bar", "foo-2Ebar"},
+		{"with_dash", "foo-bar", "foo-2Dbar"},
+		{"with_special", "foo@bar#baz", "foo-40bar-23baz"},
+		{"specialization", "id$int", "id$int"},
+		{"starts_with_number", "123foo", "-3123foo"},
 		{"empty", "", "_"},
 	}
 
@@ -377,7 +380,7 @@ func TestGenerate_RuntimeDeclarations(t *testing.T) {
 		"declare %String* @runtime_string_new(i8*, i64)",
 		"declare void @runtime_println_i64(i64)",
 		"declare %struct.Slice* @runtime_slice_new(i64, i64, i64)",
-		"declare %HashMap* @runtime_hashmap_new()",
+		"declare %HashMap* @runtime_hashmap_new(i64, i64, i8)",
 		"declare %Channel* @runtime_channel_new(i64, i64)",
 	}
 
@@ -439,6 +442,33 @@ func TestGenerateFunction_SimpleVoid(t *testing.T) {
 	}
 }
 
+func TestGenerateFunction_InlineAttributes(t *testing.T) {
+	tests := []struct {
+		inline mir.InlineKind
+		want   string
+	}{
+		{mir.InlineDefault, "define void @f() {"},
+		{mir.InlineHint, "define void @f() inlinehint {"},
+		{mir.InlineAlways, "define void @f() alwaysinline {"},
+		{mir.InlineNever, "define void @f() noinline {"},
+	}
+
+	for _, tt := range tests {
+		gen := newTestGenerator()
+		fn := createTestFunction("f", []mir.Local{}, types.TypeVoid)
+		fn.Entry.Terminator = &mir.Return{Value: nil}
+		fn.Inline = tt.inline
+
+		result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{fn}})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if !strings.Contains(result, tt.want) {
+			t.Errorf("Generate() with inline kind %d should contain %q, got:\n%s", tt.inline, tt.want, result)
+		}
+	}
+}
+
 func TestGenerateFunction_WithParameters(t *testing.T) {
 	gen := newTestGenerator()
 
@@ -613,6 +643,24 @@ func TestGenerateOperand_LiteralInt(t *testing.T) {
 	}
 }
 
+func TestGenerateOperand_LiteralIntAsFloat(t *testing.T) {
+	gen := newTestGenerator()
+
+	// The checker types the 2 of `x * 2` as float for a float x
+	lit := &mir.Literal{
+		Type:  types.TypeFloat,
+		Value: int64(2),
+	}
+
+	result, err := gen.generateOperand(lit)
+	if err != nil {
+		t.Fatalf("generateOperand() error = %v", err)
+	}
+	if result != "0x4000000000000000" {
+		t.Errorf("generateOperand() = %v, want the double constant 0x4000000000000000", result)
+	}
+}
+
 func TestGenerateOperand_LiteralBool(t *testing.T) {
 	gen := newTestGenerator()
 
@@ -672,15 +720,33 @@ func TestGenerateOperand_LiteralString(t *testing.T) {
 		Value: "hello",
 	}
 
-	_, err := gen.generateOperand(lit)
-	if err != nil {
-		t.Fatalf("generateOperand() error = %v", err)
+	// Both uses should share one constant String, with no allocation
+	for i := 0; i < 2; i++ {
+		if _, err := gen.generateOperand(lit); err != nil {
+			t.Fatalf("generateOperand() error = %v", err)
+		}
 	}
-
-	// Should generate a call to runtime_string_new
 	output := gen.builder.String()
-	if !strings.Contains(output, "call %String* @runtime_string_new") {
-		t.Errorf("generateOperand() should generate string runtime call, got:\n%s", output)
+	if strings.Contains(output, "@runtime_string_new") {
+		t.Errorf("a string literal should not be allocated, got:\n%s", output)
+	}
+	if strings.Count(output, "bitcast { i64, i8* }* @.str.0 to %String*") != 2 {
+		t.Errorf("both uses should refer to @.str.0, got:\n%s", output)
+	}
+
+	gen.builder.Reset()
+	gen.emitStringConstants()
+	consts := gen.builder.String()
+	for _, want := range []string{
+		`@.str.0.data = private unnamed_addr constant [6 x i8] c"hello\00"`,
+		"@.str.0 = private unnamed_addr constant { i64, i8* } { i64 5,",
+	} {
+		if !strings.Contains(consts, want) {
+			t.Errorf("string constants should contain %q, got:\n%s", want, consts)
+		}
+	}
+	if len(gen.stringConstants) != 1 {
+		t.Errorf("expected one string constant, got %d", len(gen.stringConstants))
 	}
 }
 
@@ -692,15 +758,57 @@ func TestGenerateOperand_LiteralNil(t *testing.T) {
 		Value: nil,
 	}
 
-	_, err := gen.generateOperand(lit)
+	reg, err := gen.generateOperand(lit)
 	if err != nil {
 		t.Fatalf("generateOperand() error = %v", err)
 	}
 
-	// Should generate inttoptr
-	output := gen.builder.String()
-	if !strings.Contains(output, "inttoptr i64 0") {
-		t.Errorf("generateOperand() should generate inttoptr for nil, got:\n%s", output)
+	// nil is the null constant, which fits whatever pointer type the use expects
+	if reg != "null" {
+		t.Errorf("generateOperand() = %q for nil, want null", reg)
+	}
+	if output := gen.builder.String(); output != "" {
+		t.Errorf("generateOperand() should emit nothing for nil, got:\n%s", output)
+	}
+}
+
+func TestGenerateCast_BoxesValuesThroughOpaquePointers(t *testing.T) {
+	gen := newTestGenerator()
+
+	x := mir.Local{ID: 0, Name: "x", Type: types.TypeFloat}
+	boxed := mir.Local{ID: 1, Name: "boxed", Type: &types.Primitive{Kind: types.Nil}}
+	back := mir.Local{ID: 2, Name: "back", Type: types.TypeFloat}
+	fn := createTestFunction("roundtrip", []mir.Local{x}, types.TypeFloat)
+	fn.Locals = []mir.Local{boxed, back}
+	fn.Entry.Statements = append(fn.Entry.Statements,
+		&mir.Cast{Result: boxed, Operand: &mir.LocalRef{Local: x}, Type: boxed.Type},
+		&mir.Cast{Result: back, Operand: &mir.LocalRef{Local: boxed}, Type: back.Type},
+	)
+	fn.Entry.Terminator = &mir.Return{Value: &mir.LocalRef{Local: back}}
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	body := result[strings.Index(result, "define double @roundtrip"):]
+	for _, want := range []string{
+		// The float is copied into a heap box sized for a double
+		"getelementptr double, double* null, i32 1",
+		"call i8* @runtime_alloc(i64 %",
+		"store double %",
+		// and loaded back out of it
+		"to double*",
+		"load double, double* %",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Generate() should contain %q, got:\n%s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"bitcast double %", "inttoptr"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("Generate() should not contain %q, got:\n%s", unwanted, body)
+		}
 	}
 }
 
@@ -914,6 +1022,49 @@ func TestGenerateStatement_LoadIndex(t *testing.T) {
 	}
 }
 
+// TestGenerateStatement_LoadIndexInBounds tests that an access known to be in
+// bounds addresses the element inline instead of calling the checked runtime
+func TestGenerateStatement_LoadIndexInBounds(t *testing.T) {
+	gen := newTestGenerator()
+
+	sliceType := &types.Slice{Elem: types.TypeInt}
+	targetRef := &mir.LocalRef{Local: mir.Local{ID: 1, Name: "arr", Type: sliceType}}
+	index := &mir.LocalRef{Local: mir.Local{ID: 2, Name: "i", Type: types.TypeInt}}
+	gen.localRegs[1] = "%reg0"
+	gen.localRegs[2] = "%reg1"
+	gen.localIsValue[2] = true
+
+	err := gen.generateLoadIndex(&mir.LoadIndex{
+		Result:   mir.Local{ID: 3, Name: "elem", Type: types.TypeInt},
+		Target:   targetRef,
+		Indices:  []mir.Operand{index},
+		InBounds: true,
+	})
+	if err != nil {
+		t.Fatalf("generateLoadIndex() error = %v", err)
+	}
+	err = gen.generateStoreIndex(&mir.StoreIndex{
+		Target:   targetRef,
+		Indices:  []mir.Operand{index},
+		Value:    &mir.Literal{Type: types.TypeInt, Value: int64(42)},
+		InBounds: true,
+	})
+	if err != nil {
+		t.Fatalf("generateStoreIndex() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if strings.Contains(output, "@runtime_slice_get") || strings.Contains(output, "@runtime_slice_set") {
+		t.Errorf("in-bounds accesses should not call the checked runtime, got:\n%s", output)
+	}
+	if !strings.Contains(output, "getelementptr inbounds i8, i8*") {
+		t.Errorf("in-bounds accesses should compute the element address inline, got:\n%s", output)
+	}
+	if !strings.Contains(output, "store i64 42, i64*") {
+		t.Errorf("in-bounds store should store the value directly, got:\n%s", output)
+	}
+}
+
 func TestGenerateStatement_StoreIndex(t *testing.T) {
 	gen := newTestGenerator()
 
@@ -995,11 +1146,73 @@ func TestGenerateStatement_ConstructArray(t *testing.T) {
 	}
 
 	output := gen.builder.String()
-	if !strings.Contains(output, "call %Slice* @runtime_slice_new") {
+	if !strings.Contains(output, "call %struct.Slice* @runtime_slice_new") {
 		t.Errorf("generateConstructArray() should generate runtime_slice_new call, got:\n%s", output)
 	}
 }
 
+func TestGenerateStatement_SliceLen(t *testing.T) {
+	gen := newTestGenerator()
+
+	s := mir.Local{ID: 1, Name: "s", Type: &types.Slice{Elem: types.TypeInt}}
+	result := mir.Local{ID: 2, Name: "n", Type: types.TypeInt}
+	gen.localRegs[s.ID] = "%s"
+	gen.localIsValue[s.ID] = true
+
+	call := &mir.Call{
+		Result: result,
+		Func:   "len",
+		Args:   []mir.Operand{&mir.LocalRef{Local: s}},
+	}
+
+	if err := gen.generateCall(call); err != nil {
+		t.Fatalf("generateCall() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if !strings.Contains(output, "call i64 @runtime_slice_len(%struct.Slice* %s)") {
+		t.Errorf("Expected len of a slice to call @runtime_slice_len, got:\n%s", output)
+	}
+}
+
+func TestGenerateStatement_MapIndex(t *testing.T) {
+	gen := newTestGenerator()
+
+	mapType := &types.Map{Key: types.TypeString, Value: types.TypeInt}
+	m := mir.Local{ID: 1, Name: "m", Type: mapType}
+	key := mir.Local{ID: 2, Name: "k", Type: types.TypeString}
+	result := mir.Local{ID: 3, Name: "v", Type: &types.Optional{Elem: types.TypeInt}}
+
+	if err := gen.generateConstructStruct(&mir.ConstructStruct{Result: m, Type: mapType}); err != nil {
+		t.Fatalf("generateConstructStruct() error = %v", err)
+	}
+	gen.localRegs[key.ID] = "%k"
+	gen.localIsValue[key.ID] = true
+
+	load := &mir.LoadIndex{
+		Result:  result,
+		Target:  &mir.LocalRef{Local: m},
+		Indices: []mir.Operand{&mir.LocalRef{Local: key}},
+	}
+	if err := gen.generateLoadIndex(load); err != nil {
+		t.Fatalf("generateLoadIndex() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if !strings.Contains(output, "call %HashMap* @runtime_hashmap_new(i64 8, i64 8, i8 1)") {
+		t.Errorf("Expected a string-keyed map to be created, got:\n%s", output)
+	}
+	if !strings.Contains(output, "store %String* %k, %String** ") {
+		t.Errorf("Expected the key to be spilled to pass it by pointer, got:\n%s", output)
+	}
+	if !strings.Contains(output, "call i8* @runtime_hashmap_get(%HashMap* ") {
+		t.Errorf("Expected map indexing to call @runtime_hashmap_get, got:\n%s", output)
+	}
+	if !strings.Contains(output, "bitcast i8* ") || !strings.Contains(output, " to i64*") {
+		t.Errorf("Expected the value pointer to be cast to int?, got:\n%s", output)
+	}
+}
+
 func TestGenerateStatement_ConstructTuple(t *testing.T) {
 	gen := newTestGenerator()
 
@@ -1026,6 +1239,61 @@ func TestGenerateStatement_ConstructTuple(t *testing.T) {
 	}
 }
 
+func TestGenerateStatement_PtrOffset(t *testing.T) {
+	gen := newTestGenerator()
+
+	ptrType := &types.Pointer{Elem: types.TypeInt}
+	ptrLocal := mir.Local{ID: 1, Name: "p", Type: ptrType}
+	gen.localRegs[ptrLocal.ID] = "%p"
+	gen.localIsValue[ptrLocal.ID] = true
+
+	offset := &mir.PtrOffset{
+		Result:  mir.Local{ID: 2, Name: "q", Type: ptrType},
+		Pointer: &mir.LocalRef{Local: ptrLocal},
+		Offset:  &mir.Literal{Type: &types.Primitive{Kind: types.Int32}, Value: int64(-1)},
+	}
+
+	if err := gen.generatePtrOffset(offset); err != nil {
+		t.Fatalf("generatePtrOffset() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	for _, want := range []string{"sext i32 -1 to i64", "getelementptr i64, i64* %p, i64 %"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("generatePtrOffset() should contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestGenerateEnum_ReprTag(t *testing.T) {
+	gen := newTestGenerator()
+
+	color := &types.Enum{Name: "Color", Variants: []types.Variant{{Name: "Red"}, {Name: "Blue"}}, Repr: types.TypeU8}
+	value := mir.Local{ID: 1, Name: "c", Type: color}
+	tag := mir.Local{ID: 2, Name: "tag", Type: types.TypeInt}
+
+	fn := createTestFunction("test", []mir.Local{}, types.TypeVoid)
+	fn.Entry.Statements = []mir.Statement{
+		&mir.ConstructEnum{Result: value, Type: "Color", Variant: "Blue", VariantIndex: 1},
+		&mir.Discriminant{Result: tag, Target: &mir.LocalRef{Local: value}},
+	}
+	fn.Entry.Terminator = &mir.Return{Value: nil}
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{fn}, Enums: []*types.Enum{color}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	// A fieldless enum is its tag, so the value is the constant i8 1
+	for _, want := range []string{"%enum.Color = type { i8, [0 x i8] }", "zext i8 1 to i64"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Generate() should contain %q, got:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "call i8* @runtime_alloc") {
+		t.Errorf("Generate() should not allocate a fieldless enum, got:\n%s", result)
+	}
+}
+
 func TestGenerate_CompleteFunction(t *testing.T) {
 	gen := newTestGenerator()
 
@@ -1423,3 +1691,359 @@ func TestIntOperatorIntrinsic_Div_StillWorks(t *testing.T) {
 		t.Errorf("Should not contain 'fdiv' for integer operation, got:\n%s", output)
 	}
 }
+
+func TestStdlibIntrinsic_CallsRuntime(t *testing.T) {
+	gen := newTestGenerator()
+
+	s := mir.Local{ID: 1, Name: "s", Type: types.TypeString}
+	result := mir.Local{ID: 2, Name: "result", Type: types.TypeInt}
+	gen.localRegs[s.ID] = "%s"
+	gen.localIsValue[s.ID] = true
+
+	call := &mir.Call{
+		Result: result,
+		Func:   "__string_len__",
+		Args:   []mir.Operand{&mir.LocalRef{Local: s}},
+	}
+
+	if err := gen.generateCall(call); err != nil {
+		t.Fatalf("generateCall() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if !strings.Contains(output, "call i64 @runtime_string_len(%String* %s)") {
+		t.Errorf("Expected call to @runtime_string_len, got:\n%s", output)
+	}
+	if strings.Contains(output, "__string_len__") {
+		t.Errorf("Intrinsic name should not reach the IR, got:\n%s", output)
+	}
+}
+
+func TestMainReturn_IntExitCode(t *testing.T) {
+	gen := newTestGenerator()
+	gen.currentFunc = &mir.Function{Name: "main", ReturnType: types.TypeInt}
+
+	ret := &mir.Return{Value: &mir.Literal{Type: types.TypeInt, Value: int64(3)}}
+	if err := gen.generateReturn(ret, "i32"); err != nil {
+		t.Fatalf("generateReturn() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if !strings.Contains(output, "trunc i64 3 to i32") {
+		t.Errorf("Expected exit code truncated to i32, got:\n%s", output)
+	}
+}
+
+func TestMainReturn_ResultErr(t *testing.T) {
+	gen := newTestGenerator()
+	resultEnum := &types.Enum{
+		Name:       "Result",
+		TypeParams: []types.TypeParam{{Name: "T"}, {Name: "E"}},
+		Variants: []types.Variant{
+			{Name: "Ok", Params: []types.Type{&types.TypeParam{Name: "T"}}},
+			{Name: "Err", Params: []types.Type{&types.TypeParam{Name: "E"}}},
+		},
+	}
+	gen.enumTypes["Result"] = true
+	gen.enumPayloadSizes["Result"] = 8
+	resultType := &types.GenericInstance{Base: resultEnum, Args: []types.Type{types.TypeVoid, types.TypeString}}
+	gen.currentFunc = &mir.Function{Name: "main", ReturnType: resultType}
+
+	r := mir.Local{ID: 1, Name: "r", Type: resultType}
+	gen.localRegs[r.ID] = "%r"
+	gen.localIsValue[r.ID] = true
+
+	if err := gen.generateReturn(&mir.Return{Value: &mir.LocalRef{Local: r}}, "i32"); err != nil {
+		t.Fatalf("generateReturn() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	for _, want := range []string{
+		"icmp eq i32",
+		"bitcast [8 x i8]*",
+		"call void @runtime_report_main_error(%String*",
+		"ret i32 1",
+		"ret i32 0",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestGenerateFunction_MainReceivesArgs(t *testing.T) {
+	gen := newTestGenerator()
+
+	fn := createTestFunction("main", []mir.Local{}, types.TypeVoid)
+	fn.Entry.Terminator = &mir.Return{Value: nil}
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"define i32 @main(i32 %argc, i8** %argv) {",
+		"call void @runtime_args_init(i32 %argc, i8** %argv)",
+		"ret i32 0",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Generate() should contain %q, got:\n%s", want, result)
+		}
+	}
+}
+
+func TestGenerateSpawn_WrapperCallsFunction(t *testing.T) {
+	gen := newTestGenerator()
+
+	param := mir.Local{ID: 0, Name: "n", Type: types.TypeInt}
+	worker := createTestFunction("worker", []mir.Local{param}, types.TypeInt)
+	worker.Entry.Terminator = &mir.Return{Value: &mir.LocalRef{Local: param}}
+
+	fn := createTestFunction("main", []mir.Local{}, types.TypeVoid)
+	fn.Entry.Statements = append(fn.Entry.Statements, &mir.Spawn{
+		Func: "worker",
+		Args: []mir.Operand{&mir.Literal{Type: types.TypeInt, Value: int64(7)}},
+	})
+	fn.Entry.Terminator = &mir.Return{Value: nil}
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{worker, fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	wrapper := spawnWrapperName("worker", []string{"i64"}, "i64")
+	for _, want := range []string{
+		"call %Legion* @runtime_legion_spawn(void (i8*)* @" + wrapper + ", i8* %reg",
+		"define internal void @" + wrapper + "(i8* %env) {",
+		"%arg0 = load i64, i64* %cast0",
+		"%result = call i64 @worker(i64 %arg0)",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Generate() should contain %q, got:\n%s", want, result)
+		}
+	}
+}
+
+func TestGenerateSpawn_WrapperFreesArguments(t *testing.T) {
+	gen := newTestGenerator()
+
+	a := mir.Local{ID: 0, Name: "a", Type: types.TypeInt}
+	b := mir.Local{ID: 1, Name: "b", Type: types.TypeBool}
+	worker := createTestFunction("worker", []mir.Local{a, b}, types.TypeVoid)
+	idle := createTestFunction("idle", []mir.Local{}, types.TypeVoid)
+
+	fn := createTestFunction("main", []mir.Local{}, types.TypeVoid)
+	fn.Entry.Statements = append(fn.Entry.Statements,
+		&mir.Spawn{
+			Func: "worker",
+			Args: []mir.Operand{
+				&mir.Literal{Type: types.TypeInt, Value: int64(7)},
+				&mir.Literal{Type: types.TypeBool, Value: true},
+			},
+		},
+		&mir.Spawn{Func: "idle"},
+	)
+	fn.Entry.Terminator = &mir.Return{Value: nil}
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{worker, idle, fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// The arguments come from the spawn allocator, not the collected heap
+	if !strings.Contains(result, "call i8* @runtime_spawn_args_alloc(i64 9)") {
+		t.Errorf("spawn should allocate its arguments with runtime_spawn_args_alloc, got:\n%s", result)
+	}
+
+	// The wrapper frees them after unpacking the last one, before the call
+	wrapper := result[strings.Index(result, "define internal void @"+spawnWrapperName("worker", []string{"i64", "i1"}, "void")):]
+	wrapper = wrapper[:strings.Index(wrapper, "\n}\n")]
+	unpacked := strings.Index(wrapper, "%arg1 = load i1, i1* %cast1")
+	freed := strings.Index(wrapper, "call void @runtime_spawn_args_free(i8* %env)")
+	called := strings.Index(wrapper, "call void @worker(i64 %arg0, i1 %arg1)")
+	if unpacked < 0 || freed < unpacked || called < freed {
+		t.Errorf("wrapper should unpack, free the arguments, then call worker, got:\n%s", wrapper)
+	}
+
+	// A spawn without arguments has nothing to free
+	idleWrapper := result[strings.Index(result, "define internal void @"+spawnWrapperName("idle", nil, "void")):]
+	idleWrapper = idleWrapper[:strings.Index(idleWrapper, "\n}\n")]
+	if strings.Contains(idleWrapper, "runtime_spawn_args_free") {
+		t.Errorf("wrapper of a spawn without arguments should not free them, got:\n%s", idleWrapper)
+	}
+}
+
+func TestGenerateSpawnJoin(t *testing.T) {
+	gen := newTestGenerator()
+
+	worker := createTestFunction("worker", []mir.Local{}, types.TypeInt)
+	worker.Entry.Terminator = &mir.Return{Value: &mir.Literal{Type: types.TypeInt, Value: int64(3)}}
+
+	handle := mir.Local{ID: 0, Name: "h", Type: &types.JoinHandle{Elem: types.TypeInt}}
+	value := mir.Local{ID: 1, Name: "v", Type: types.TypeInt}
+	fn := createTestFunction("main", []mir.Local{}, types.TypeInt)
+	fn.Entry.Statements = append(fn.Entry.Statements,
+		&mir.Spawn{Func: "worker", Result: &handle},
+		&mir.Join{Result: value, Handle: &mir.LocalRef{Local: handle}},
+	)
+	fn.Entry.Terminator = &mir.Return{Value: &mir.LocalRef{Local: value}}
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{worker, fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"call void @runtime_legion_set_result(i8* %box)",
+		"call i8* @runtime_legion_join(%Legion* %reg",
+		"to i64*",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Generate() should contain %q, got:\n%s", want, result)
+		}
+	}
+}
+
+func TestGenerateReceive(t *testing.T) {
+	gen := newTestGenerator()
+
+	ch := mir.Local{ID: 0, Name: "c", Type: &types.Channel{Elem: types.TypeInt}}
+	value := mir.Local{ID: 1, Name: "v", Type: types.TypeInt}
+	maybe := mir.Local{ID: 2, Name: "m", Type: &types.Optional{Elem: types.TypeInt}}
+	fn := createTestFunction("drain", []mir.Local{ch}, types.TypeVoid)
+	fn.Entry.Statements = append(fn.Entry.Statements,
+		&mir.Receive{Result: value, Channel: &mir.LocalRef{Local: ch}},
+		&mir.Receive{Result: maybe, Channel: &mir.LocalRef{Local: ch}, Optional: true},
+	)
+	fn.Entry.Terminator = &mir.Return{Value: nil}
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"call i8* @runtime_channel_recv_or_zero(%Channel* %",
+		"call i8* @runtime_channel_recv(%Channel* %",
+		"to i64*",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Generate() should contain %q, got:\n%s", want, result)
+		}
+	}
+}
+
+func TestGenerateChannel_StructsTravelByValue(t *testing.T) {
+	gen := newTestGenerator()
+	gen.structTypes["Point"] = true
+	gen.structFields["Point"] = map[string]int{"x": 0, "y": 1}
+
+	point := &types.Struct{Name: "Point"}
+	ch := mir.Local{ID: 0, Name: "c", Type: &types.Channel{Elem: point}}
+	p := mir.Local{ID: 1, Name: "p", Type: point}
+	q := mir.Local{ID: 2, Name: "q", Type: point}
+	maybe := mir.Local{ID: 3, Name: "m", Type: &types.Optional{Elem: point}}
+	fn := createTestFunction("relay", []mir.Local{p}, types.TypeVoid)
+	fn.Locals = []mir.Local{ch, q, maybe}
+	fn.Entry.Statements = append(fn.Entry.Statements,
+		&mir.MakeChannel{Result: ch, Type: ch.Type, Capacity: &mir.Literal{Type: types.TypeInt, Value: int64(1)}},
+		&mir.Send{Channel: &mir.LocalRef{Local: ch}, Value: &mir.LocalRef{Local: p}},
+		&mir.Receive{Result: q, Channel: &mir.LocalRef{Local: ch}},
+		&mir.Receive{Result: maybe, Channel: &mir.LocalRef{Local: ch}, Optional: true},
+	)
+	fn.Entry.Terminator = &mir.Return{Value: nil}
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	// Slots are sized by the struct, not by the pointer to it
+	if !strings.Contains(result, "getelementptr %struct.Point, %struct.Point* null, i32 1") {
+		t.Errorf("channel slots should hold a whole Point, got:\n%s", result)
+	}
+	for _, want := range []*regexp.Regexp{
+		// the struct itself is copied in
+		regexp.MustCompile(`bitcast %struct\.Point\* %reg\d+ to i8\*\n\s+call void @runtime_channel_send`),
+		// and the copy received is the new struct, without a load
+		regexp.MustCompile(`runtime_channel_recv_or_zero\(.*\n\s+%reg\d+ = bitcast i8\* %reg\d+ to %struct\.Point\*\n\s+%reg\d+ = load %Channel`),
+		// which an optional receive keeps in a cell, or null once closed
+		regexp.MustCompile(`select i1 %reg\d+, %struct\.Point\*\* null, %struct\.Point\*\* %reg\d+`),
+	} {
+		if !want.MatchString(result) {
+			t.Errorf("Generate() should match %s, got:\n%s", want, result)
+		}
+	}
+}
+
+func TestGenerateSelect(t *testing.T) {
+	gen := newTestGenerator()
+
+	ch := mir.Local{ID: 0, Name: "c", Type: &types.Channel{Elem: types.TypeInt}}
+	value := mir.Local{ID: 1, Name: "x", Type: types.TypeInt}
+	recvBlock := &mir.BasicBlock{Label: "got", Terminator: &mir.Return{Value: nil}}
+	timeoutBlock := &mir.BasicBlock{Label: "timed_out", Terminator: &mir.Return{Value: nil}}
+
+	fn := createTestFunction("wait", []mir.Local{ch}, types.TypeVoid)
+	fn.Locals = []mir.Local{value}
+	fn.Entry.Terminator = &mir.Select{Cases: []mir.SelectCase{
+		{Kind: "recv", Channel: &mir.LocalRef{Local: ch}, Result: &value, Target: recvBlock},
+		{Kind: "after", Timeout: &mir.Literal{Type: types.TypeInt, Value: int64(250)}, Target: timeoutBlock},
+	}}
+	fn.Blocks = append(fn.Blocks, recvBlock, timeoutBlock)
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"alloca [1 x %SelectCase]",
+		"call i64 @runtime_select(%SelectCase* %",
+		", i64 1, i8 0, i64 250)",
+		"i64 -2, label %",
+		"unreachable",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Generate() should contain %q, got:\n%s", want, result)
+		}
+	}
+	if strings.Contains(result, "call void @runtime_nanosleep") {
+		t.Errorf("select should not poll with runtime_nanosleep, got:\n%s", result)
+	}
+}
+
+func TestGenerateStackAllocatedStruct(t *testing.T) {
+	gen := newTestGenerator()
+	gen.structTypes["Point"] = true
+	gen.structFields["Point"] = map[string]int{"x": 0, "y": 1}
+
+	point := &types.Struct{Name: "Point"}
+	p := mir.Local{ID: 0, Name: "p", Type: point}
+	fn := createTestFunction("local_point", nil, types.TypeVoid)
+	fn.Locals = []mir.Local{p}
+	fn.Entry.Statements = append(fn.Entry.Statements, &mir.ConstructStruct{
+		Result: p,
+		Type:   point,
+		Fields: map[string]mir.Operand{
+			"x": &mir.Literal{Type: types.TypeInt, Value: int64(1)},
+			"y": &mir.Literal{Type: types.TypeInt, Value: int64(2)},
+		},
+		StackAlloc: true,
+	})
+	fn.Entry.Terminator = &mir.Return{Value: nil}
+
+	result, err := gen.Generate(&mir.Module{Functions: []*mir.Function{fn}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	body := result[strings.Index(result, "define void @local_point"):]
+	if !strings.Contains(body, "alloca i8, i64 %") {
+		t.Errorf("Generate() should reserve a stack slot, got:\n%s", body)
+	}
+	if strings.Contains(body, "@runtime_alloc") {
+		t.Errorf("Generate() should not heap allocate a StackAlloc struct, got:\n%s", body)
+	}
+}
@@ -0,0 +1,224 @@
+package mir2llvm
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// Maps are a %HashMap* into the runtime's hash table. Keys and values are
+// passed to it by pointer and copied in, so every key is spilled to a stack
+// temporary first. Looking a key up yields a pointer to the stored value, or
+// null, which is already the representation of V?.
+
+// mapOf returns the map type of op, looking through references
+func mapOf(op mir.Operand) (*types.Map, bool) {
+	typ := op.OperandType()
+	for {
+		switch t := typ.(type) {
+		case *types.Reference:
+			typ = t.Elem
+			continue
+		case *types.Pointer:
+			typ = t.Elem
+			continue
+		case *types.Named:
+			if t.Ref != nil {
+				typ = t.Ref
+				continue
+			}
+		}
+		break
+	}
+	m, ok := typ.(*types.Map)
+	return m, ok
+}
+
+// isMapBuiltin reports whether name is a builtin taking a map as its first
+// argument that generateMapCall implements
+func isMapBuiltin(name string) bool {
+	switch name {
+	case "contains", "delete", "__map_remove__", "__map_keys__", "__map_values__":
+		return true
+	}
+	return false
+}
+
+// generateMapCall generates a map builtin, or reports false if call is not
+// one
+func (g *Generator) generateMapCall(call *mir.Call) (bool, error) {
+	if call.Func == "__map_new__" {
+		m, ok := call.Result.Type.(*types.Map)
+		if !ok {
+			return false, nil
+		}
+		mapReg, err := g.newMap(m)
+		if err != nil {
+			return true, err
+		}
+		for i := 0; i+1 < len(call.Args); i += 2 {
+			if err := g.mapPut(mapReg, call.Args[i], call.Args[i+1]); err != nil {
+				return true, err
+			}
+		}
+		return true, g.setCallResult(call.Result, "%HashMap*", mapReg)
+	}
+
+	if !isMapBuiltin(call.Func) || len(call.Args) == 0 {
+		return false, nil
+	}
+	if _, ok := mapOf(call.Args[0]); !ok {
+		return false, nil
+	}
+	mapReg, err := g.generateOperand(call.Args[0])
+	if err != nil {
+		return true, err
+	}
+
+	switch call.Func {
+	case "__map_keys__", "__map_values__":
+		runtimeFunc := "runtime_hashmap_keys"
+		if call.Func == "__map_values__" {
+			runtimeFunc = "runtime_hashmap_values"
+		}
+		sliceReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = call %%struct.Slice* @%s(%%HashMap* %s)", sliceReg, runtimeFunc, mapReg))
+		return true, g.setCallResult(call.Result, "%struct.Slice*", sliceReg)
+	}
+
+	if len(call.Args) != 2 {
+		return true, fmt.Errorf("%s expects a map and a key, got %d arguments", call.Func, len(call.Args))
+	}
+	keyPtr, err := g.spillOperand(call.Args[1])
+	if err != nil {
+		return true, err
+	}
+
+	switch call.Func {
+	case "contains":
+		flagReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = call i8 @runtime_hashmap_contains_key(%%HashMap* %s, i8* %s)", flagReg, mapReg, keyPtr))
+		boolReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = icmp ne i8 %s, 0", boolReg, flagReg))
+		return true, g.setCallResult(call.Result, "i1", boolReg)
+	case "delete":
+		g.emit(fmt.Sprintf("  call i8* @runtime_hashmap_remove(%%HashMap* %s, i8* %s)", mapReg, keyPtr))
+		return true, nil
+	default: // __map_remove__
+		valueReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = call i8* @runtime_hashmap_remove(%%HashMap* %s, i8* %s)", valueReg, mapReg, keyPtr))
+		return true, g.setPointerResult(call.Result, valueReg)
+	}
+}
+
+// newMap emits a call creating an empty map of type m and returns its
+// register
+func (g *Generator) newMap(m *types.Map) (string, error) {
+	keySize, err := g.sliceElementSize(m.Key)
+	if err != nil {
+		return "", err
+	}
+	valueSize, err := g.sliceElementSize(m.Value)
+	if err != nil {
+		return "", err
+	}
+	stringKeys := 0
+	if isStringType(m.Key) {
+		stringKeys = 1
+	}
+	mapReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = call %%HashMap* @runtime_hashmap_new(i64 %s, i64 %s, i8 %d)",
+		mapReg, keySize, valueSize, stringKeys))
+	return mapReg, nil
+}
+
+// mapPut stores value under key in the map at mapReg
+func (g *Generator) mapPut(mapReg string, key, value mir.Operand) error {
+	keyPtr, err := g.spillOperand(key)
+	if err != nil {
+		return err
+	}
+	valuePtr, err := g.spillOperand(value)
+	if err != nil {
+		return err
+	}
+	g.emit(fmt.Sprintf("  call void @runtime_hashmap_put(%%HashMap* %s, i8* %s, i8* %s)", mapReg, keyPtr, valuePtr))
+	return nil
+}
+
+// generateMapLoad generates `result = m[key]`, a V? pointing at the stored
+// value
+func (g *Generator) generateMapLoad(load *mir.LoadIndex) error {
+	if len(load.Indices) != 1 {
+		return fmt.Errorf("map indexing requires exactly one key, got %d", len(load.Indices))
+	}
+	mapReg, err := g.generateOperand(load.Target)
+	if err != nil {
+		return err
+	}
+	keyPtr, err := g.spillOperand(load.Indices[0])
+	if err != nil {
+		return err
+	}
+	valueReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = call i8* @runtime_hashmap_get(%%HashMap* %s, i8* %s)", valueReg, mapReg, keyPtr))
+	return g.setPointerResult(load.Result, valueReg)
+}
+
+// generateMapStore generates `m[key] = value`
+func (g *Generator) generateMapStore(store *mir.StoreIndex) error {
+	if len(store.Indices) != 1 {
+		return fmt.Errorf("map indexing requires exactly one key, got %d", len(store.Indices))
+	}
+	mapReg, err := g.generateOperand(store.Target)
+	if err != nil {
+		return err
+	}
+	return g.mapPut(mapReg, store.Indices[0], store.Value)
+}
+
+// spillOperand evaluates op into a stack temporary and returns an i8* to it
+func (g *Generator) spillOperand(op mir.Operand) (string, error) {
+	reg, err := g.generateOperand(op)
+	if err != nil {
+		return "", err
+	}
+	llvmType, err := g.mapType(op.OperandType())
+	if err != nil {
+		return "", err
+	}
+	return g.spillToI8Ptr(llvmType, reg), nil
+}
+
+// setPointerResult bitcasts the i8* at reg to the type of result and stores
+// it there
+func (g *Generator) setPointerResult(result mir.Local, reg string) error {
+	resultType, err := g.mapType(result.Type)
+	if err != nil {
+		return err
+	}
+	if resultType == "void" {
+		return nil
+	}
+	if resultType != "i8*" {
+		castReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s", castReg, reg, resultType))
+		reg = castReg
+	}
+	return g.setCallResult(result, resultType, reg)
+}
+
+// setCallResult stores reg, of LLVM type llvmType, into result's stack slot,
+// the way generateCall stores a call's result
+func (g *Generator) setCallResult(result mir.Local, llvmType, reg string) error {
+	allocaReg, ok := g.localRegs[result.ID]
+	if !ok || allocaReg == "undef" {
+		allocaReg = g.nextReg()
+		g.emit(fmt.Sprintf("  %s = alloca %s", allocaReg, llvmType))
+		g.localRegs[result.ID] = allocaReg
+	}
+	g.emit(fmt.Sprintf("  store %s %s, %s* %s", llvmType, reg, llvmType, allocaReg))
+	g.localIsValue[result.ID] = false
+	return nil
+}
@@ -46,7 +46,7 @@ func TestMultiDimensionalIndexing_2D(t *testing.T) {
 	}
 
 	// Verify first call uses index 0
-	if !strings.Contains(output, "runtime_slice_get(%Slice* %reg0, i64 0)") {
+	if !strings.Contains(output, "runtime_slice_get(%struct.Slice* %reg0, i64 0)") {
 		t.Errorf("Expected first call with index 0. Output:\n%s", output)
 	}
 
@@ -56,7 +56,7 @@ func TestMultiDimensionalIndexing_2D(t *testing.T) {
 	}
 
 	// Verify there's a bitcast between the two calls for traversing the nested slice
-	if !strings.Contains(output, "bitcast i8*") && !strings.Contains(output, "to %Slice*") {
+	if !strings.Contains(output, "bitcast i8*") && !strings.Contains(output, "to %struct.Slice*") {
 		t.Errorf("Expected bitcast for nested slice traversal. Output:\n%s", output)
 	}
 }
@@ -2,6 +2,8 @@ package mir2llvm
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/malphas-lang/malphas-lang/internal/mir"
 )
@@ -58,6 +60,10 @@ func (g *Generator) generateLiteral(lit *mir.Literal) (string, error) {
 
 	switch v := lit.Value.(type) {
 	case int64:
+		// An integer literal used as a float, as in `x * 2` for a float x
+		if litType == "double" {
+			return fmt.Sprintf("0x%016X", math.Float64bits(float64(v))), nil
+		}
 		// Integer literal
 		if litType == "i64" || litType == "i32" || litType == "i8" {
 			// Can use directly in LLVM IR
@@ -82,21 +88,9 @@ func (g *Generator) generateLiteral(lit *mir.Literal) (string, error) {
 		return "0", nil
 
 	case string:
-		// String literal - use runtime function
-		// Check if already exists
-		globalName, ok := g.stringConstants[v]
-		if !ok {
-			globalName = fmt.Sprintf("@.str.%d", len(g.stringConstants))
-			g.stringConstants[v] = globalName
-		}
-
-		// Get pointer to string data
-		length := len(v)
-		ptrReg := g.nextReg()
-		g.emit(fmt.Sprintf("  %s = getelementptr inbounds [%d x i8], [%d x i8]* %s, i64 0, i64 0", ptrReg, length, length, globalName))
-
+		// String literal: a constant String shared by every use
 		reg := g.nextReg()
-		g.emit(fmt.Sprintf("  %s = call %%String* @runtime_string_new(i8* %s, i64 %d)", reg, ptrReg, length))
+		g.emit(fmt.Sprintf("  %s = bitcast { i64, i8* }* %s to %%String*", reg, g.stringConstant(v)))
 		return reg, nil
 
 	case nil:
@@ -105,9 +99,12 @@ func (g *Generator) generateLiteral(lit *mir.Literal) (string, error) {
 			// Can't create a void pointer, return undef
 			return "undef", nil
 		}
-		reg := g.nextReg()
-		g.emit(fmt.Sprintf("  %s = inttoptr i64 0 to %s", reg, litType))
-		return reg, nil
+		// nil is the null pointer of whatever pointer type the use expects,
+		// e.g. a *T field, and the zero value of anything else
+		if strings.HasSuffix(litType, "*") {
+			return "null", nil
+		}
+		return "zeroinitializer", nil
 
 	default:
 		return "", fmt.Errorf("unsupported literal type: %T", v)
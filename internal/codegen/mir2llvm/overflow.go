@@ -0,0 +1,275 @@
+package mir2llvm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// OverflowMode controls how integer arithmetic behaves when a result does not fit its type.
+type OverflowMode int
+
+const (
+	// OverflowWrap uses two's complement wrapping (the default).
+	OverflowWrap OverflowMode = iota
+	// OverflowPanic traps add/sub/mul overflow through runtime_panic_overflow.
+	OverflowPanic
+	// OverflowChecked behaves like OverflowPanic and additionally traps
+	// MIN / -1. Division by zero traps in every mode.
+	OverflowChecked
+)
+
+// Overflow operation codes passed to runtime_panic_overflow.
+// Keep in sync with overflow_op_names in runtime/runtime.c.
+const (
+	overflowOpAdd = iota
+	overflowOpSub
+	overflowOpMul
+	overflowOpDiv
+)
+
+// ParseOverflowMode parses the value of the --overflow flag.
+func ParseOverflowMode(s string) (OverflowMode, error) {
+	switch s {
+	case "", "wrap":
+		return OverflowWrap, nil
+	case "panic":
+		return OverflowPanic, nil
+	case "checked":
+		return OverflowChecked, nil
+	default:
+		return OverflowWrap, fmt.Errorf("invalid overflow mode %q (expected wrap, panic, or checked)", s)
+	}
+}
+
+// String returns the flag spelling of the mode.
+func (m OverflowMode) String() string {
+	switch m {
+	case OverflowPanic:
+		return "panic"
+	case OverflowChecked:
+		return "checked"
+	default:
+		return "wrap"
+	}
+}
+
+// overflowIntrinsic returns the llvm.*.with.overflow intrinsic name for an integer op.
+func overflowIntrinsic(op string, signed bool, llvmType string) string {
+	prefix := "u"
+	if signed {
+		prefix = "s"
+	}
+	return fmt.Sprintf("llvm.%s%s.with.overflow.%s", prefix, op, llvmType)
+}
+
+// useIntrinsic records an intrinsic declaration so it is emitted once at module level.
+func (g *Generator) useIntrinsic(name, decl string) {
+	g.intrinsics[name] = decl
+}
+
+// emitIntrinsicDeclarations emits declarations for the LLVM intrinsics used by the module.
+func (g *Generator) emitIntrinsicDeclarations() {
+	if len(g.intrinsics) == 0 {
+		return
+	}
+	g.emit("")
+	g.emit("; LLVM intrinsic declarations")
+	names := make([]string, 0, len(g.intrinsics))
+	for name := range g.intrinsics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.emit(g.intrinsics[name])
+	}
+}
+
+// emitOverflowArith emits an integer add/sub/mul through llvm.*.with.overflow and
+// returns the value register and the i1 overflow flag register.
+func (g *Generator) emitOverflowArith(op string, signed bool, llvmType, lhs, rhs string) (string, string) {
+	intrinsic := overflowIntrinsic(op, signed, llvmType)
+	pairType := fmt.Sprintf("{ %s, i1 }", llvmType)
+	g.useIntrinsic(intrinsic, fmt.Sprintf("declare %s @%s(%s, %s)", pairType, intrinsic, llvmType, llvmType))
+
+	pairReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = call %s @%s(%s %s, %s %s)", pairReg, pairType, intrinsic, llvmType, lhs, llvmType, rhs))
+	valueReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = extractvalue %s %s, 0", valueReg, pairType, pairReg))
+	flagReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = extractvalue %s %s, 1", flagReg, pairType, pairReg))
+	return valueReg, flagReg
+}
+
+// emitOverflowTrap branches to a block calling runtime_panic_overflow when flagReg is set.
+// Code emitted afterwards continues in the non-overflow block.
+func (g *Generator) emitOverflowTrap(flagReg string, opCode int) {
+	label := strings.TrimPrefix(g.nextReg(), "%")
+	trapLabel := label + "_overflow"
+	okLabel := label + "_ok"
+
+	g.emitTerminator(fmt.Sprintf("  br i1 %s, label %%%s, label %%%s", flagReg, trapLabel, okLabel))
+	g.emitLabel(trapLabel)
+	g.emit(fmt.Sprintf("  call void @runtime_panic_overflow(i32 %d)", opCode))
+	g.emitTerminator("  unreachable")
+	g.emitLabel(okLabel)
+}
+
+// emitTrappingArith emits an add/sub/mul that panics on overflow and returns the result register.
+func (g *Generator) emitTrappingArith(op string, opCode int, signed bool, llvmType, lhs, rhs string) string {
+	valueReg, flagReg := g.emitOverflowArith(op, signed, llvmType, lhs, rhs)
+	g.emitOverflowTrap(flagReg, opCode)
+	return valueReg
+}
+
+// emitIntDivision emits lhs / rhs and returns the result register. Division
+// by zero panics in every overflow mode, as it does under --interp. Signed
+// MIN / -1 panics in checked mode and wraps to MIN otherwise, where a plain
+// sdiv would be undefined.
+func (g *Generator) emitIntDivision(signed bool, llvmType, lhs, rhs string) string {
+	g.emitDivisionCheck(signed && g.Overflow == OverflowChecked, llvmType, lhs, rhs)
+	resultReg := g.nextReg()
+	if !signed {
+		g.emit(fmt.Sprintf("  %s = udiv %s %s, %s", resultReg, llvmType, lhs, rhs))
+		return resultReg
+	}
+	if g.Overflow == OverflowChecked {
+		g.emit(fmt.Sprintf("  %s = sdiv %s %s, %s", resultReg, llvmType, lhs, rhs))
+		return resultReg
+	}
+	// x / -1 is -x, so divide by 1 instead and negate
+	negOneReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = icmp eq %s %s, -1", negOneReg, llvmType, rhs))
+	divisorReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = select i1 %s, %s 1, %s %s", divisorReg, negOneReg, llvmType, llvmType, rhs))
+	quotientReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = sdiv %s %s, %s", quotientReg, llvmType, lhs, divisorReg))
+	negReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = sub %s 0, %s", negReg, llvmType, lhs))
+	g.emit(fmt.Sprintf("  %s = select i1 %s, %s %s, %s %s", resultReg, negOneReg, llvmType, negReg, llvmType, quotientReg))
+	return resultReg
+}
+
+// emitDivisionCheck traps division by zero and, if signed, MIN / -1.
+func (g *Generator) emitDivisionCheck(signed bool, llvmType, lhs, rhs string) {
+	zeroReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = icmp eq %s %s, 0", zeroReg, llvmType, rhs))
+	flagReg := zeroReg
+	if signed {
+		minReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = shl %s 1, %d", minReg, llvmType, intBits(llvmType)-1))
+		isMinReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = icmp eq %s %s, %s", isMinReg, llvmType, lhs, minReg))
+		isNegOneReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = icmp eq %s %s, -1", isNegOneReg, llvmType, rhs))
+		bothReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = and i1 %s, %s", bothReg, isMinReg, isNegOneReg))
+		flagReg = g.nextReg()
+		g.emit(fmt.Sprintf("  %s = or i1 %s, %s", flagReg, zeroReg, bothReg))
+	}
+	g.emitOverflowTrap(flagReg, overflowOpDiv)
+}
+
+// isUnsigned checks if an integer type is one of the unsigned kinds
+func isUnsigned(t types.Type) bool {
+	switch typ := t.(type) {
+	case *types.Primitive:
+		switch typ.Kind {
+		case types.U8, types.U16, types.U32, types.U64, types.U128, types.Usize:
+			return true
+		}
+	case *types.Named:
+		if typ.Ref != nil {
+			return isUnsigned(typ.Ref)
+		}
+		switch typ.Name {
+		case "u8", "u16", "u32", "u64", "u128", "usize":
+			return true
+		}
+	}
+	return false
+}
+
+// intBits returns the bit width of an LLVM integer type such as "i32" (64 if unknown).
+func intBits(llvmType string) int {
+	bits, err := strconv.Atoi(strings.TrimPrefix(llvmType, "i"))
+	if err != nil {
+		return 64
+	}
+	return bits
+}
+
+// arithmeticBuiltins maps the checked_*/wrapping_* builtins to their LLVM op.
+var arithmeticBuiltins = map[string]string{
+	"checked_add":  "add",
+	"checked_sub":  "sub",
+	"checked_mul":  "mul",
+	"wrapping_add": "add",
+	"wrapping_sub": "sub",
+	"wrapping_mul": "mul",
+}
+
+// isArithmeticBuiltin checks if a function name is a checked_*/wrapping_* builtin
+func isArithmeticBuiltin(funcName string) bool {
+	_, ok := arithmeticBuiltins[funcName]
+	return ok
+}
+
+// generateArithmeticBuiltin generates inline LLVM for checked_* and wrapping_* builtins.
+// wrapping_* always wraps regardless of the overflow mode; checked_* returns nil on overflow.
+func (g *Generator) generateArithmeticBuiltin(call *mir.Call) error {
+	op := arithmeticBuiltins[call.Func]
+	if len(call.Args) != 2 {
+		return fmt.Errorf("%s requires 2 arguments", call.Func)
+	}
+
+	argType := call.Args[0].OperandType()
+	llvmType, err := g.mapType(argType)
+	if err != nil {
+		return err
+	}
+	lhs, err := g.generateOperand(call.Args[0])
+	if err != nil {
+		return err
+	}
+	rhs, err := g.generateOperand(call.Args[1])
+	if err != nil {
+		return err
+	}
+
+	retType, err := g.mapType(call.Result.Type)
+	if err != nil {
+		return err
+	}
+	allocaReg, hasAlloca := g.localRegs[call.Result.ID]
+	if !hasAlloca {
+		allocaReg = g.nextReg()
+		g.emit(fmt.Sprintf("  %s = alloca %s", allocaReg, retType))
+		g.localRegs[call.Result.ID] = allocaReg
+	}
+
+	var resultReg string
+	if strings.HasPrefix(call.Func, "wrapping_") {
+		resultReg = g.nextReg()
+		g.emit(fmt.Sprintf("  %s = %s %s %s, %s", resultReg, op, llvmType, lhs, rhs))
+	} else {
+		valueReg, flagReg := g.emitOverflowArith(op, !isUnsigned(argType), llvmType, lhs, rhs)
+
+		// Box the value so it can be returned as an optional (nil on overflow)
+		rawReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = call i8* @runtime_alloc(i64 %d)", rawReg, (intBits(llvmType)+7)/8))
+		boxReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s*", boxReg, rawReg, llvmType))
+		g.emit(fmt.Sprintf("  store %s %s, %s* %s", llvmType, valueReg, llvmType, boxReg))
+		resultReg = g.nextReg()
+		g.emit(fmt.Sprintf("  %s = select i1 %s, %s* null, %s* %s", resultReg, flagReg, llvmType, llvmType, boxReg))
+	}
+
+	g.emit(fmt.Sprintf("  store %s %s, %s* %s", retType, resultReg, retType, allocaReg))
+	g.localIsValue[call.Result.ID] = false
+	return nil
+}
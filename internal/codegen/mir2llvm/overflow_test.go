@@ -0,0 +1,239 @@
+package mir2llvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func TestParseOverflowMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    OverflowMode
+		wantErr bool
+	}{
+		{"", OverflowWrap, false},
+		{"wrap", OverflowWrap, false},
+		{"panic", OverflowPanic, false},
+		{"checked", OverflowChecked, false},
+		{"saturate", OverflowWrap, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseOverflowMode(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseOverflowMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseOverflowMode(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestOverflowWrap_PlainAdd(t *testing.T) {
+	gen := newTestGenerator()
+
+	a := mir.Local{ID: 1, Name: "a", Type: types.TypeInt}
+	result := mir.Local{ID: 2, Name: "result", Type: types.TypeInt}
+	gen.localRegs[a.ID] = "%a"
+	gen.localIsValue[a.ID] = true
+
+	call := &mir.Call{
+		Result: result,
+		Func:   "__add__",
+		Args:   []mir.Operand{&mir.LocalRef{Local: a}, &mir.Literal{Type: types.TypeInt, Value: int64(1)}},
+	}
+
+	if err := gen.generateOperatorIntrinsic(call); err != nil {
+		t.Fatalf("generateOperatorIntrinsic() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if strings.Contains(output, "with.overflow") {
+		t.Errorf("wrap mode should not emit overflow intrinsics, got:\n%s", output)
+	}
+}
+
+func TestOverflowPanic_AddTraps(t *testing.T) {
+	gen := newTestGenerator()
+	gen.Overflow = OverflowPanic
+
+	a := mir.Local{ID: 1, Name: "a", Type: types.TypeInt}
+	result := mir.Local{ID: 2, Name: "result", Type: types.TypeInt}
+	gen.localRegs[a.ID] = "%a"
+	gen.localIsValue[a.ID] = true
+
+	call := &mir.Call{
+		Result: result,
+		Func:   "__add__",
+		Args:   []mir.Operand{&mir.LocalRef{Local: a}, &mir.Literal{Type: types.TypeInt, Value: int64(1)}},
+	}
+
+	if err := gen.generateOperatorIntrinsic(call); err != nil {
+		t.Fatalf("generateOperatorIntrinsic() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if !strings.Contains(output, "@llvm.sadd.with.overflow.i64") {
+		t.Errorf("Expected sadd.with.overflow call, got:\n%s", output)
+	}
+	if !strings.Contains(output, "call void @runtime_panic_overflow(i32 0)") {
+		t.Errorf("Expected runtime_panic_overflow call, got:\n%s", output)
+	}
+	if _, ok := gen.intrinsics["llvm.sadd.with.overflow.i64"]; !ok {
+		t.Errorf("Expected intrinsic declaration to be recorded")
+	}
+}
+
+func TestOverflowPanic_DivTrapsOnlyZero(t *testing.T) {
+	gen := newTestGenerator()
+	gen.Overflow = OverflowPanic
+
+	call := &mir.Call{
+		Result: mir.Local{ID: 1, Name: "result", Type: types.TypeInt},
+		Func:   "__div__",
+		Args:   []mir.Operand{&mir.Literal{Type: types.TypeInt, Value: int64(10)}, &mir.Literal{Type: types.TypeInt, Value: int64(2)}},
+	}
+
+	if err := gen.generateOperatorIntrinsic(call); err != nil {
+		t.Fatalf("generateOperatorIntrinsic() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if !strings.Contains(output, "icmp eq i64 2, 0") || !strings.Contains(output, "call void @runtime_panic_overflow(i32 3)") {
+		t.Errorf("Expected division by zero trap, got:\n%s", output)
+	}
+	if strings.Contains(output, "or i1") {
+		t.Errorf("panic mode should not trap MIN / -1, got:\n%s", output)
+	}
+	if strings.Contains(output, "sdiv i64 10, 2") {
+		t.Errorf("Expected the divisor -1 to be replaced, got:\n%s", output)
+	}
+}
+
+func TestOverflowWrap_UnsignedDiv(t *testing.T) {
+	gen := newTestGenerator()
+
+	call := &mir.Call{
+		Result: mir.Local{ID: 1, Name: "result", Type: types.TypeU64},
+		Func:   "__div__",
+		Args:   []mir.Operand{&mir.Literal{Type: types.TypeU64, Value: int64(10)}, &mir.Literal{Type: types.TypeU64, Value: int64(2)}},
+	}
+
+	if err := gen.generateOperatorIntrinsic(call); err != nil {
+		t.Fatalf("generateOperatorIntrinsic() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if !strings.Contains(output, "call void @runtime_panic_overflow(i32 3)") {
+		t.Errorf("Expected division by zero trap, got:\n%s", output)
+	}
+	if !strings.Contains(output, "udiv i64 10, 2") || strings.Contains(output, "sdiv") {
+		t.Errorf("Expected udiv, got:\n%s", output)
+	}
+}
+
+func TestOverflowChecked_DivTraps(t *testing.T) {
+	gen := newTestGenerator()
+	gen.Overflow = OverflowChecked
+
+	call := &mir.Call{
+		Result: mir.Local{ID: 1, Name: "result", Type: types.TypeInt},
+		Func:   "__div__",
+		Args:   []mir.Operand{&mir.Literal{Type: types.TypeInt, Value: int64(10)}, &mir.Literal{Type: types.TypeInt, Value: int64(2)}},
+	}
+
+	if err := gen.generateOperatorIntrinsic(call); err != nil {
+		t.Fatalf("generateOperatorIntrinsic() error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if !strings.Contains(output, "call void @runtime_panic_overflow(i32 3)") {
+		t.Errorf("Expected division trap, got:\n%s", output)
+	}
+	if !strings.Contains(output, "sdiv i64") {
+		t.Errorf("Expected sdiv after the check, got:\n%s", output)
+	}
+}
+
+func TestArithmeticBuiltins(t *testing.T) {
+	gen := newTestGenerator()
+
+	args := []mir.Operand{
+		&mir.Literal{Type: types.TypeInt, Value: int64(1)},
+		&mir.Literal{Type: types.TypeInt, Value: int64(2)},
+	}
+
+	wrapping := &mir.Call{
+		Result: mir.Local{ID: 1, Name: "w", Type: types.TypeInt},
+		Func:   "wrapping_mul",
+		Args:   args,
+	}
+	if err := gen.generateCall(wrapping); err != nil {
+		t.Fatalf("generateCall(wrapping_mul) error = %v", err)
+	}
+
+	checked := &mir.Call{
+		Result: mir.Local{ID: 2, Name: "c", Type: &types.Optional{Elem: types.TypeInt}},
+		Func:   "checked_add",
+		Args:   args,
+	}
+	if err := gen.generateCall(checked); err != nil {
+		t.Fatalf("generateCall(checked_add) error = %v", err)
+	}
+
+	output := gen.builder.String()
+	if !strings.Contains(output, "= mul i64 1, 2") {
+		t.Errorf("Expected plain mul for wrapping_mul, got:\n%s", output)
+	}
+	if !strings.Contains(output, "@llvm.sadd.with.overflow.i64") {
+		t.Errorf("Expected overflow intrinsic for checked_add, got:\n%s", output)
+	}
+	if !strings.Contains(output, "i64* null") {
+		t.Errorf("Expected nil result on overflow for checked_add, got:\n%s", output)
+	}
+	if strings.Contains(output, "@checked_add") || strings.Contains(output, "@wrapping_mul") {
+		t.Errorf("Builtins should be inlined, got:\n%s", output)
+	}
+}
+
+func TestArithmeticBuiltinsUseTheOperandWidth(t *testing.T) {
+	tests := []struct {
+		typ    types.Type
+		fn     string
+		wantOp string
+	}{
+		{types.TypeU8, "wrapping_add", "= add i8"},
+		{types.TypeU8, "checked_add", "@llvm.uadd.with.overflow.i8"},
+		{types.TypeInt32, "wrapping_mul", "= mul i32"},
+		{types.TypeInt32, "checked_sub", "@llvm.ssub.with.overflow.i32"},
+	}
+	for _, tt := range tests {
+		gen := newTestGenerator()
+		result := tt.typ
+		if strings.HasPrefix(tt.fn, "checked_") {
+			result = &types.Optional{Elem: tt.typ}
+		}
+		call := &mir.Call{
+			Result: mir.Local{ID: 1, Name: "r", Type: result},
+			Func:   tt.fn,
+			Args: []mir.Operand{
+				&mir.Literal{Type: tt.typ, Value: int64(1)},
+				&mir.Literal{Type: tt.typ, Value: int64(2)},
+			},
+		}
+		if err := gen.generateCall(call); err != nil {
+			t.Fatalf("generateCall(%s on %s) error = %v", tt.fn, tt.typ, err)
+		}
+		output := gen.builder.String()
+		if !strings.Contains(output, tt.wantOp) {
+			t.Errorf("%s on %s: expected %q, got:\n%s", tt.fn, tt.typ, tt.wantOp, output)
+		}
+		if strings.Contains(output, " i64 1") {
+			t.Errorf("%s on %s: operands widened to i64, got:\n%s", tt.fn, tt.typ, output)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package mir2llvm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+// functionFragment is the IR of one function, generated by its own worker
+// Generator. String constants are numbered locally by the worker and
+// renumbered when the fragment is merged, and spawn wrappers are named after
+// their signature, so the merged module is byte-for-byte what serial
+// generation would produce.
+type functionFragment struct {
+	fn  *mir.Function
+	gen *Generator
+	err error
+}
+
+// moduleLocalName matches the module-level names a worker numbers itself
+var moduleLocalName = regexp.MustCompile(`@\.str\.\d+\b`)
+
+// generateFunctions generates every non-generic function and appends them
+// to the output in module order. Up to g.Workers functions are generated at
+// once; the struct and enum tables they read are complete before this runs
+// and are not written afterwards.
+func (g *Generator) generateFunctions(functions []*mir.Function) error {
+	var fragments []*functionFragment
+	for _, fn := range functions {
+		// Skip generic functions - only generate specialized (monomorphized) versions
+		if len(fn.TypeParams) > 0 {
+			continue
+		}
+		fragments = append(fragments, &functionFragment{fn: fn})
+	}
+
+	workers := g.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(fragments) {
+		workers = len(fragments)
+	}
+
+	// Everything emitted so far (declarations and type layouts) is part of
+	// the cache key of every specialized function
+	var prelude []byte
+	if g.Cache != nil {
+		sum := sha256.Sum256([]byte(g.builder.String()))
+		prelude = sum[:]
+	}
+
+	jobs := make(chan *functionFragment)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for frag := range jobs {
+				g.generateFragment(frag, prelude)
+			}
+		}()
+	}
+	for _, frag := range fragments {
+		jobs <- frag
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, frag := range fragments {
+		if frag.err != nil {
+			return fmt.Errorf("error generating function %s: %w", frag.fn.Name, frag.err)
+		}
+		g.mergeFragment(frag.gen)
+	}
+	return nil
+}
+
+// generateFragment generates one function into frag, reusing the cached
+// IR of a specialized function when g.Cache has it
+func (g *Generator) generateFragment(frag *functionFragment, prelude []byte) {
+	var key string
+	if g.cacheable(frag.fn) {
+		key = g.fragmentKey(frag.fn, prelude)
+		if data, ok := g.Cache.Load(key); ok {
+			if w, ok := g.loadFragment(frag.fn, data); ok {
+				frag.gen = w
+				return
+			}
+		}
+	}
+
+	frag.gen = g.forkForFunction()
+	frag.err = frag.gen.generateFunction(frag.fn)
+	if key != "" && frag.err == nil && len(frag.gen.Errors) == 0 {
+		g.storeFragment(key, frag.gen)
+	}
+}
+
+// forkForFunction returns a Generator for one function that shares the
+// module-wide type tables with g but owns all per-function and output state
+func (g *Generator) forkForFunction() *Generator {
+	return &Generator{
+		localRegs:        make(map[int]string),
+		localIsValue:     make(map[int]bool),
+		blockLabels:      make(map[*mir.BasicBlock]string),
+		stackSlots:       make(map[mir.Statement]string),
+		arraySlots:       make(map[int]string),
+		functions:        make(map[string]*mir.Function),
+		structTypes:      g.structTypes,
+		structFields:     g.structFields,
+		enumTypes:        g.enumTypes,
+		enumDefs:         g.enumDefs,
+		enumPayloadSizes: g.enumPayloadSizes,
+		enumTagTypes:     g.enumTagTypes,
+		modules:          g.modules,
+		currentModule:    g.currentModule,
+		stringConstants:  make(map[string]string),
+		intrinsics:       make(map[string]string),
+		Overflow:         g.Overflow,
+		Target:           g.Target,
+	}
+}
+
+// mergeFragment appends a worker's function to g, renaming the string
+// constants it introduced to their module-wide names and adding the spawn
+// wrappers g does not have yet
+func (g *Generator) mergeFragment(w *Generator) {
+	rename := make(map[string]string)
+
+	// Number new string constants in the order the worker first used them
+	locals := make([]string, len(w.stringConstants))
+	for content, name := range w.stringConstants {
+		index, _ := strconv.Atoi(strings.TrimPrefix(name, "@.str."))
+		locals[index] = content
+	}
+	for _, content := range locals {
+		global, ok := g.stringConstants[content]
+		if !ok {
+			global = fmt.Sprintf("@.str.%d", len(g.stringConstants))
+			g.stringConstants[content] = global
+		}
+		rename[w.stringConstants[content]] = global
+	}
+
+	relink := func(ir string) string {
+		return moduleLocalName.ReplaceAllStringFunc(ir, func(name string) string {
+			if global, ok := rename[name]; ok {
+				return global
+			}
+			return name
+		})
+	}
+
+	g.builder.WriteString(relink(w.builder.String()))
+	for i, name := range w.spawnWrapperNames {
+		if !slices.Contains(g.spawnWrapperNames, name) {
+			g.spawnWrappers = append(g.spawnWrappers, w.spawnWrappers[i])
+			g.spawnWrapperNames = append(g.spawnWrapperNames, name)
+		}
+	}
+	for name, decl := range w.intrinsics {
+		g.intrinsics[name] = decl
+	}
+	for name, fn := range w.functions {
+		g.functions[name] = fn
+	}
+	g.Errors = append(g.Errors, w.Errors...)
+}
@@ -0,0 +1,63 @@
+package mir2llvm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// parallelTestModule builds functions that share string constants and each
+// spawn the same worker, so merging has to renumber the constants and share
+// the wrapper
+func parallelTestModule() *mir.Module {
+	param := mir.Local{ID: 0, Name: "n", Type: types.TypeInt}
+	worker := createTestFunction("worker", []mir.Local{param}, types.TypeInt)
+	worker.Entry.Terminator = &mir.Return{Value: &mir.LocalRef{Local: param}}
+
+	functions := []*mir.Function{worker}
+	for i := 0; i < 8; i++ {
+		fn := createTestFunction(fmt.Sprintf("f%d", i), []mir.Local{}, types.TypeVoid)
+		fn.Entry.Statements = []mir.Statement{
+			&mir.Call{Result: mir.Local{ID: 1, Type: types.TypeVoid}, Func: "println", Args: []mir.Operand{&mir.Literal{Type: types.TypeString, Value: "shared"}}},
+			&mir.Call{Result: mir.Local{ID: 2, Type: types.TypeVoid}, Func: "println", Args: []mir.Operand{&mir.Literal{Type: types.TypeString, Value: fmt.Sprintf("own %d", i)}}},
+			&mir.Spawn{Func: "worker", Args: []mir.Operand{&mir.Literal{Type: types.TypeInt, Value: int64(i)}}},
+		}
+		fn.Entry.Terminator = &mir.Return{}
+		functions = append(functions, fn)
+	}
+	return &mir.Module{Functions: functions}
+}
+
+func TestGenerateParallelMatchesSerial(t *testing.T) {
+	serial := newTestGenerator()
+	serial.Workers = 1
+	want, err := serial.Generate(parallelTestModule())
+	if err != nil {
+		t.Fatalf("serial Generate() error = %v", err)
+	}
+
+	parallel := newTestGenerator()
+	parallel.Workers = 4
+	got, err := parallel.Generate(parallelTestModule())
+	if err != nil {
+		t.Fatalf("parallel Generate() error = %v", err)
+	}
+
+	if got != want {
+		t.Errorf("parallel output differs from serial:\n%s\nwant:\n%s", got, want)
+	}
+
+	if !strings.Contains(got, `c"own 7\00"`) {
+		t.Errorf("Generate() should contain the string of f7")
+	}
+	wrapper := "define internal void @" + spawnWrapperName("worker", []string{"i64"}, "i64") + "("
+	if strings.Count(got, wrapper) != 1 {
+		t.Errorf("expected the spawns of worker to share one wrapper %s", wrapper)
+	}
+	if strings.Count(got, `c"shared\00"`) != 1 {
+		t.Errorf("expected the shared string to be emitted once")
+	}
+}
@@ -1,7 +1,12 @@
 package mir2llvm
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/malphas-lang/malphas-lang/internal/mir"
@@ -17,10 +22,16 @@ func (g *Generator) generateStatement(stmt mir.Statement) error {
 		return g.generateCall(s)
 	case *mir.Spawn:
 		return g.generateSpawn(s)
+	case *mir.Join:
+		return g.generateJoin(s)
 	case *mir.Yield:
 		return g.generateYield(s)
 	case *mir.Load:
 		return g.generateLoad(s)
+	case *mir.LoadStatic:
+		return g.generateLoadStatic(s)
+	case *mir.StoreStatic:
+		return g.generateStoreStatic(s)
 	case *mir.LoadField:
 		return g.generateLoadField(s)
 	case *mir.StoreField:
@@ -57,6 +68,12 @@ func (g *Generator) generateStatement(stmt mir.Statement) error {
 		return g.generateMakeClosure(s)
 	case *mir.AddressOf:
 		return g.generateAddressOf(s)
+	case *mir.PtrOffset:
+		return g.generatePtrOffset(s)
+	case *mir.RegisterDrop:
+		return g.generateRegisterDrop(s)
+	case *mir.CancelDrop:
+		return g.generateCancelDrop(s)
 	default:
 		return fmt.Errorf("unsupported statement type: %T", stmt)
 	}
@@ -72,11 +89,16 @@ func (g *Generator) generateMakeChannel(stmt *mir.MakeChannel) error {
 		return fmt.Errorf("make_channel expects channel type, got %T", stmt.Type)
 	}
 
-	// Calculate element size
-	elemSize, err := g.calculateElementSize(elemType)
+	// Each slot holds the LLVM value itself (a pointer for strings), or the
+	// fields of a struct, which channels carry by value
+	slotType, _, err := g.channelSlotType(elemType)
 	if err != nil {
 		return err
 	}
+	sizePtrReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = getelementptr %s, %s* null, i32 1", sizePtrReg, slotType, slotType))
+	elemSize := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = ptrtoint %s* %s to i64", elemSize, slotType, sizePtrReg))
 
 	// Get capacity
 	capReg, err := g.generateOperand(stmt.Capacity)
@@ -117,31 +139,11 @@ func (g *Generator) generateSend(stmt *mir.Send) error {
 		return err
 	}
 
-	// We need to pass value as i8*.
-	valType := stmt.Value.OperandType()
-	valLLVMType, err := g.mapType(valType)
+	valPtr, err := g.channelValuePtr(stmt.Value.OperandType(), valReg)
 	if err != nil {
 		return err
 	}
 
-	var valPtr string
-
-	if isPrimitive(valType) {
-		// Store in temp alloca
-		tempAlloca := g.nextReg()
-		g.emit(fmt.Sprintf("  %s = alloca %s", tempAlloca, valLLVMType))
-		g.emit(fmt.Sprintf("  store %s %s, %s* %s", valLLVMType, valReg, valLLVMType, tempAlloca))
-
-		// Cast to i8*
-		valPtr = g.nextReg()
-		g.emit(fmt.Sprintf("  %s = bitcast %s* %s to i8*", valPtr, valLLVMType, tempAlloca))
-	} else {
-		// It's already a pointer (struct*, string*, etc.)
-		// bitcast valReg to i8*
-		valPtr = g.nextReg()
-		g.emit(fmt.Sprintf("  %s = bitcast %s %s to i8*", valPtr, valLLVMType, valReg))
-	}
-
 	g.emit(fmt.Sprintf("  call void @runtime_channel_send(%%Channel* %s, i8* %s)", chReg, valPtr))
 	return nil
 }
@@ -154,32 +156,53 @@ func (g *Generator) generateReceive(recv *mir.Receive) error {
 		return err
 	}
 
-	// Call runtime_channel_recv
-	// fn runtime_channel_recv(ch: *Channel) -> i64
-	// Note: currently returns i64 (value or pointer), need to cast to result type
-	callReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = call i64 @malphas_channel_recv(%%Channel* %s)", callReg, chanReg))
-
-	// Cast result to expected type
-	// If result type is primitive (fits in i64), we might need to cast or truncate
-	// If result type is pointer, inttoptr
 	resultType, err := g.mapType(recv.Result.Type)
 	if err != nil {
 		return err
 	}
 
-	finalReg := g.nextReg()
-	if isPrimitive(recv.Result.Type) {
-		// For primitives, we assume they are packed into i64
-		// But for now, let's assume simple cast
-		if resultType == "i64" {
-			finalReg = callReg
+	// The runtime hands back a pointer to a copy of the element. Optional
+	// receives use runtime_channel_recv directly: T? is T* in LLVM, and the
+	// pointer is null once the channel is closed and drained.
+	if recv.Optional {
+		boxReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = call i8* @runtime_channel_recv(%%Channel* %s)", boxReg, chanReg))
+		finalReg := g.nextReg()
+		elemType := recv.Result.Type
+		if opt, ok := elemType.(*types.Optional); ok {
+			elemType = opt.Elem
+		}
+		_, byValue, err := g.channelSlotType(elemType)
+		if err != nil {
+			return err
+		}
+		if byValue {
+			// The copy is the struct itself, so T? needs a cell holding
+			// the pointer to it, or null when nothing was received
+			elemLLVM := strings.TrimSuffix(resultType, "*")
+			structReg := g.nextReg()
+			g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s", structReg, boxReg, elemLLVM))
+			rawCell := g.nextReg()
+			g.emit(fmt.Sprintf("  %s = call i8* @runtime_alloc(i64 8)", rawCell))
+			cellReg := g.nextReg()
+			g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s", cellReg, rawCell, resultType))
+			g.emit(fmt.Sprintf("  store %s %s, %s %s", elemLLVM, structReg, resultType, cellReg))
+			closedReg := g.nextReg()
+			g.emit(fmt.Sprintf("  %s = icmp eq i8* %s, null", closedReg, boxReg))
+			g.emit(fmt.Sprintf("  %s = select i1 %s, %s null, %s %s", finalReg, closedReg, resultType, resultType, cellReg))
 		} else {
-			g.emit(fmt.Sprintf("  %s = trunc i64 %s to %s", finalReg, callReg, resultType))
+			g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s", finalReg, boxReg, resultType))
 		}
-	} else {
-		// Pointer type
-		g.emit(fmt.Sprintf("  %s = inttoptr i64 %s to %s", finalReg, callReg, resultType))
+		g.localRegs[recv.Result.ID] = finalReg
+		g.localIsValue[recv.Result.ID] = true
+		return nil
+	}
+
+	boxReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = call i8* @runtime_channel_recv_or_zero(%%Channel* %s)", boxReg, chanReg))
+	finalReg, err := g.channelReceivedValue(recv.Result.Type, boxReg)
+	if err != nil {
+		return err
 	}
 
 	g.localRegs[recv.Result.ID] = finalReg
@@ -188,6 +211,61 @@ func (g *Generator) generateReceive(recv *mir.Receive) error {
 	return nil
 }
 
+// channelSlotType returns the LLVM type of a channel slot holding an elem,
+// and whether elem travels by value. A struct is mapped to a pointer, but
+// sending it must not share the struct with the receiver: the slot holds the
+// struct's fields, which are copied in on send and out into a fresh struct
+// on every receive, so later writes on either side stay unseen by the other.
+func (g *Generator) channelSlotType(elem types.Type) (string, bool, error) {
+	llvmType, err := g.mapType(elem)
+	if err != nil {
+		return "", false, err
+	}
+	structType := strings.TrimSuffix(llvmType, "*")
+	if strings.HasPrefix(llvmType, "%struct.") && !strings.HasSuffix(structType, "*") {
+		return structType, true, nil
+	}
+	return llvmType, false, nil
+}
+
+// channelValuePtr returns an i8* to the bytes the channel copies for the
+// value in valReg: the struct itself for structs, or a temporary holding
+// the value for everything else
+func (g *Generator) channelValuePtr(valType types.Type, valReg string) (string, error) {
+	slotType, byValue, err := g.channelSlotType(valType)
+	if err != nil {
+		return "", err
+	}
+	valPtr := g.nextReg()
+	if byValue {
+		g.emit(fmt.Sprintf("  %s = bitcast %s* %s to i8*", valPtr, slotType, valReg))
+		return valPtr, nil
+	}
+	tempAlloca := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = alloca %s", tempAlloca, slotType))
+	g.emit(fmt.Sprintf("  store %s %s, %s* %s", slotType, valReg, slotType, tempAlloca))
+	g.emit(fmt.Sprintf("  %s = bitcast %s* %s to i8*", valPtr, slotType, tempAlloca))
+	return valPtr, nil
+}
+
+// channelReceivedValue turns the copy of an element the runtime received
+// into the value of elemType: the copy is the struct for structs, and holds
+// the value for everything else
+func (g *Generator) channelReceivedValue(elemType types.Type, boxReg string) (string, error) {
+	slotType, byValue, err := g.channelSlotType(elemType)
+	if err != nil {
+		return "", err
+	}
+	typedReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s*", typedReg, boxReg, slotType))
+	if byValue {
+		return typedReg, nil
+	}
+	valReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = load %s, %s* %s", valReg, slotType, slotType, typedReg))
+	return valReg, nil
+}
+
 // generateSizeOf generates LLVM IR for SizeOf
 func (g *Generator) generateSizeOf(s *mir.SizeOf) error {
 	sizeReg, err := g.calculateElementSize(s.Type)
@@ -210,6 +288,48 @@ func (g *Generator) generateAlignOf(a *mir.AlignOf) error {
 	return nil
 }
 
+// generatePtrOffset generates LLVM IR for PtrOffset as a getelementptr over
+// the pointer's element type. Signed offsets are sign-extended to i64, so
+// negative offsets move backwards.
+func (g *Generator) generatePtrOffset(p *mir.PtrOffset) error {
+	ptrReg, err := g.generateOperand(p.Pointer)
+	if err != nil {
+		return err
+	}
+	offsetReg, err := g.generateOperand(p.Offset)
+	if err != nil {
+		return err
+	}
+
+	ptrType, err := g.mapType(p.Pointer.OperandType())
+	if err != nil {
+		return err
+	}
+	offsetType, err := g.mapType(p.Offset.OperandType())
+	if err != nil {
+		return err
+	}
+	if offsetType != "i64" {
+		op := "sext"
+		if intBits(offsetType) > 64 {
+			op = "trunc"
+		} else if !isSigned(p.Offset.OperandType()) {
+			op = "zext"
+		}
+		extReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = %s %s %s to i64", extReg, op, offsetType, offsetReg))
+		offsetReg = extReg
+	}
+
+	elemType := strings.TrimSuffix(ptrType, "*")
+	resReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = getelementptr %s, %s %s, i64 %s", resReg, elemType, ptrType, ptrReg, offsetReg))
+
+	g.localRegs[p.Result.ID] = resReg
+	g.localIsValue[p.Result.ID] = true
+	return nil
+}
+
 // generateAddressOf generates LLVM IR for AddressOf
 func (g *Generator) generateAddressOf(stmt *mir.AddressOf) error {
 	// Get the alloca register for the target local
@@ -285,6 +405,22 @@ func (g *Generator) generateCast(cast *mir.Cast) error {
 		return err
 	}
 
+	// Values cross an opaque pointer (a type parameter, or the i8* the
+	// runtime's collections take) boxed: casting a value that is not a
+	// pointer to i8* copies it into a block of its own, and casting i8* back
+	// to such a value loads it from the box. Reinterpreting the bits with
+	// inttoptr or bitcast would lose floats and booleans.
+	if dstLLVM == "i8*" && !strings.HasSuffix(srcLLVM, "*") && srcLLVM != "void" {
+		g.localRegs[cast.Result.ID] = g.boxValue(srcLLVM, opReg)
+		g.localIsValue[cast.Result.ID] = true
+		return nil
+	}
+	if srcLLVM == "i8*" && !isPointer(srcType) && !strings.HasSuffix(dstLLVM, "*") && dstLLVM != "void" {
+		g.localRegs[cast.Result.ID] = g.unboxValue(dstLLVM, opReg)
+		g.localIsValue[cast.Result.ID] = true
+		return nil
+	}
+
 	// Determine cast instruction
 	var castOp string
 
@@ -361,6 +497,31 @@ func (g *Generator) generateCast(cast *mir.Cast) error {
 	return nil
 }
 
+// boxValue copies the value in reg, of LLVM type llvmType, into a fresh
+// heap block and returns an i8* to it. The box lives as long as whoever
+// holds the pointer, so it may outlive the current function.
+func (g *Generator) boxValue(llvmType, reg string) string {
+	sizePtr := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = getelementptr %s, %s* null, i32 1", sizePtr, llvmType, llvmType))
+	size := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = ptrtoint %s* %s to i64", size, llvmType, sizePtr))
+	box := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = call i8* @runtime_alloc(i64 %s)", box, size))
+	typed := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s*", typed, box, llvmType))
+	g.emit(fmt.Sprintf("  store %s %s, %s* %s", llvmType, reg, llvmType, typed))
+	return box
+}
+
+// unboxValue loads the value of LLVM type llvmType from the box reg points to
+func (g *Generator) unboxValue(llvmType, reg string) string {
+	typed := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s*", typed, reg, llvmType))
+	value := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = load %s, %s* %s", value, llvmType, llvmType, typed))
+	return value
+}
+
 // generateAssign generates LLVM IR for an assignment
 func (g *Generator) generateAssign(assign *mir.Assign) error {
 	// Get type for local
@@ -383,6 +544,9 @@ func (g *Generator) generateAssign(assign *mir.Assign) error {
 	if err != nil {
 		return err
 	}
+	if rhsReg, err = g.coerceArray(assign.RHS, rhsReg, assign.Local.Type); err != nil {
+		return err
+	}
 
 	// Get local register (allocate if needed)
 	localReg, ok := g.localRegs[assign.Local.ID]
@@ -410,16 +574,37 @@ func (g *Generator) generateCall(call *mir.Call) error {
 	if isOperatorIntrinsic(call.Func) {
 		return g.generateOperatorIntrinsic(call)
 	}
+	if isArithmeticBuiltin(call.Func) {
+		return g.generateArithmeticBuiltin(call)
+	}
+	if handled, err := g.generateMapCall(call); handled {
+		return err
+	}
+	if call.Func == "println" && len(call.Args) == 1 {
+		if opt, ok := call.Args[0].OperandType().(*types.Optional); ok {
+			return g.generatePrintlnOptional(call.Args[0], opt)
+		}
+	}
 
 	// Generate argument registers
 	var argRegs []string
 	var argTypes []string
 
-	for _, arg := range call.Args {
+	paramTypes := g.calleeParamTypes(call)
+	for i, arg := range call.Args {
 		argReg, err := g.generateOperand(arg)
 		if err != nil {
 			return err
 		}
+		if i < len(paramTypes) && arrayOf(arg.OperandType()) != nil && sliceOf(paramTypes[i]) != nil {
+			// An array passed for a slice parameter is copied into a slice
+			if argReg, err = g.coerceArray(arg, argReg, paramTypes[i]); err != nil {
+				return err
+			}
+			argRegs = append(argRegs, argReg)
+			argTypes = append(argTypes, "%struct.Slice*")
+			continue
+		}
 		argRegs = append(argRegs, argReg)
 
 		// Infer argument type from operand
@@ -441,6 +626,12 @@ func (g *Generator) generateCall(call *mir.Call) error {
 		argTypes = append(argTypes, argType)
 	}
 
+	if ext := g.findExtern(call.Func); ext != nil {
+		if err := g.coerceExternArgs(ext, argRegs, argTypes); err != nil {
+			return err
+		}
+	}
+
 	// Build call arguments string
 	var callArgs []string
 	for i, argReg := range argRegs {
@@ -483,7 +674,10 @@ func (g *Generator) generateCall(call *mir.Call) error {
 	var funcName string
 	var funcPtrReg string
 
-	if call.Func != "" {
+	if intrinsic, ok := types.LookupIntrinsic(call.Func); ok {
+		// Stdlib intrinsics call straight into the C runtime
+		funcName = intrinsic.Runtime
+	} else if call.Func != "" {
 		funcName = sanitizeName(call.Func)
 	} else if call.FuncOperand != nil {
 		// Indirect call or closure call
@@ -507,13 +701,20 @@ func (g *Generator) generateCall(call *mir.Call) error {
 			return fmt.Errorf("call operand must be a function type, got %T", call.FuncOperand.OperandType())
 		}
 
-		fnSig, err := g.getFunctionSignature(fnType)
+		fnSig, err := g.closureSignature(fnType, "i8*")
 		if err != nil {
 			return err
 		}
 
 		funcPtrReg = g.nextReg()
 		g.emit(fmt.Sprintf("  %s = bitcast i8* (i8*)* %s to %s*", funcPtrReg, rawFuncPtrReg, fnSig))
+
+		// The closure's function takes its environment first
+		envPtrReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = getelementptr inbounds %%Closure, %%Closure* %s, i32 0, i32 1", envPtrReg, opReg))
+		envReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = load i8*, i8** %s", envReg, envPtrReg))
+		callArgsStr = strings.Join(append([]string{"i8* " + envReg}, callArgs...), ", ")
 	} else {
 		return fmt.Errorf("call instruction missing function name or operand")
 	}
@@ -527,68 +728,20 @@ func (g *Generator) generateCall(call *mir.Call) error {
 			callArgsStr = "i64 0"
 		} else if len(argTypes) > 0 {
 			// Map println to appropriate runtime function based on first argument type
-			switch argTypes[0] {
-			case "i64":
-				funcName = "runtime_println_i64"
-			case "i32":
-				funcName = "runtime_println_i32"
-			case "i16":
-				funcName = "runtime_println_i64" // Use i64 version, convert i16 to i64
-			case "i8":
-				funcName = "runtime_println_i8"
-			case "i128":
-				funcName = "runtime_println_i64" // Use i64 version, truncate i128 to i64
-			case "double":
-				funcName = "runtime_println_double"
-			case "i1":
-				funcName = "runtime_println_bool"
-			case "%String*":
-				funcName = "runtime_println_string"
-			default:
-				// Try to infer from operand type if it's a named type
-				if len(call.Args) > 0 {
-					if localRef, ok := call.Args[0].(*mir.LocalRef); ok {
-						if named, ok := localRef.Local.Type.(*types.Named); ok {
-							switch named.Name {
-							case "int", "i64":
-								funcName = "runtime_println_i64"
-							case "i32":
-								funcName = "runtime_println_i32"
-							case "i8":
-								funcName = "runtime_println_i8"
-							case "float":
-								funcName = "runtime_println_double"
-							case "bool":
-								funcName = "runtime_println_bool"
-							case "string":
-								funcName = "runtime_println_string"
-							default:
-								funcName = "runtime_println_i64" // Default fallback
-							}
-						} else if prim, ok := localRef.Local.Type.(*types.Primitive); ok {
-							switch prim.Kind {
-							case types.Int, types.Int64:
-								funcName = "runtime_println_i64"
-							case types.Int32:
-								funcName = "runtime_println_i32"
-							case types.Int8:
-								funcName = "runtime_println_i8"
-							case types.Float:
-								funcName = "runtime_println_double"
-							case types.Bool:
-								funcName = "runtime_println_bool"
-							case types.String:
-								funcName = "runtime_println_string"
-							default:
-								funcName = "runtime_println_i64" // Default fallback
-							}
-						}
-					}
-				}
-				if funcName == "println" {
-					funcName = "runtime_println_i64" // Final fallback
-				}
-			}
+			funcName = printlnFunc(argTypes[0], call.Args[0].OperandType())
+		}
+	}
+	if funcName == "panic" {
+		funcName = "runtime_panic"
+	}
+	if funcName == "len" && len(argTypes) == 1 {
+		switch argTypes[0] {
+		case "%struct.Slice*":
+			funcName = "runtime_slice_len"
+		case "%String*":
+			funcName = "runtime_string_len"
+		case "%HashMap*":
+			funcName = "runtime_hashmap_len"
 		}
 	}
 
@@ -614,6 +767,107 @@ func (g *Generator) generateCall(call *mir.Call) error {
 	return nil
 }
 
+// printlnFunc returns the runtime function printing a value of LLVM type
+// llvmType, falling back to its Malphas type t
+func printlnFunc(llvmType string, t types.Type) string {
+	if isUnsigned(t) {
+		switch llvmType {
+		case "i64":
+			return "runtime_println_u64"
+		case "i32":
+			return "runtime_println_u32"
+		case "i16":
+			return "runtime_println_u16"
+		case "i8":
+			return "runtime_println_u8"
+		}
+	}
+	switch llvmType {
+	case "i64":
+		return "runtime_println_i64"
+	case "i32":
+		return "runtime_println_i32"
+	case "i16":
+		return "runtime_println_i64" // Use i64 version, convert i16 to i64
+	case "i8":
+		return "runtime_println_i8"
+	case "i128":
+		return "runtime_println_i64" // Use i64 version, truncate i128 to i64
+	case "double":
+		return "runtime_println_double"
+	case "i1":
+		return "runtime_println_bool"
+	case "%String*":
+		return "runtime_println_string"
+	}
+	// Try to infer from the operand's type if it's a named type
+	switch t := t.(type) {
+	case *types.Named:
+		switch t.Name {
+		case "i32":
+			return "runtime_println_i32"
+		case "i8":
+			return "runtime_println_i8"
+		case "float":
+			return "runtime_println_double"
+		case "bool":
+			return "runtime_println_bool"
+		case "string":
+			return "runtime_println_string"
+		}
+	case *types.Primitive:
+		switch t.Kind {
+		case types.Int32:
+			return "runtime_println_i32"
+		case types.Int8:
+			return "runtime_println_i8"
+		case types.Float:
+			return "runtime_println_double"
+		case types.Bool:
+			return "runtime_println_bool"
+		case types.String:
+			return "runtime_println_string"
+		}
+	}
+	return "runtime_println_i64" // Default fallback
+}
+
+// generatePrintlnOptional prints an optional like the interpreter does: its
+// value if it has one, else nil
+func (g *Generator) generatePrintlnOptional(arg mir.Operand, opt *types.Optional) error {
+	ptrReg, err := g.generateOperand(arg)
+	if err != nil {
+		return err
+	}
+	elemType, err := g.mapType(opt.Elem)
+	if err != nil {
+		return err
+	}
+	label := strings.TrimPrefix(g.nextReg(), "%")
+	nilLabel := label + "_nil"
+	someLabel := label + "_some"
+	doneLabel := label + "_printed"
+
+	isNilReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = icmp eq %s* %s, null", isNilReg, elemType, ptrReg))
+	g.emitTerminator(fmt.Sprintf("  br i1 %s, label %%%s, label %%%s", isNilReg, nilLabel, someLabel))
+
+	g.emitLabel(nilLabel)
+	nilReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = bitcast { i64, i8* }* %s to %%String*", nilReg, g.stringConstant("nil")))
+	g.emit(fmt.Sprintf("  call void @runtime_println_string(%%String* %s)", nilReg))
+	g.emitTerminator(fmt.Sprintf("  br label %%%s", doneLabel))
+
+	g.emitLabel(someLabel)
+	valueReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = load %s, %s* %s", valueReg, elemType, elemType, ptrReg))
+	g.emit(fmt.Sprintf("  call void @%s(%s %s)", printlnFunc(elemType, opt.Elem), elemType, valueReg))
+	g.emitTerminator(fmt.Sprintf("  br label %%%s", doneLabel))
+
+	g.emitLabel(doneLabel)
+	return nil
+}
+
 // isOperatorIntrinsic checks if a function name is an operator intrinsic
 func isOperatorIntrinsic(funcName string) bool {
 	operators := []string{
@@ -634,6 +888,12 @@ func isFloatType(llvmType string) bool {
 	return llvmType == "float" || llvmType == "double"
 }
 
+// isNilLiteral checks if an operand is the nil literal
+func isNilLiteral(op mir.Operand) bool {
+	lit, ok := op.(*mir.Literal)
+	return ok && lit.Value == nil
+}
+
 // generateOperatorIntrinsic generates inline LLVM operations for operator intrinsics
 func (g *Generator) generateOperatorIntrinsic(call *mir.Call) error {
 	// Check if result already has an alloca (from pre-allocation)
@@ -674,30 +934,39 @@ func (g *Generator) generateOperatorIntrinsic(call *mir.Call) error {
 
 	// Try to infer from first argument for comparison ops, or if result type is bool
 	if (isComparison || (call.Result.Type != nil && call.Result.Type == types.TypeBool)) && len(call.Args) > 0 {
-		if lit, ok := call.Args[0].(*mir.Literal); ok {
-			operationType, _ = g.mapType(lit.Type)
-		} else if localRef, ok := call.Args[0].(*mir.LocalRef); ok {
-			operationType, _ = g.mapType(localRef.Local.Type)
+		operand := call.Args[0]
+		// For `nil == x`, take the pointer type from x
+		if isNilLiteral(operand) && len(call.Args) > 1 {
+			operand = call.Args[1]
+		}
+		if t := operand.OperandType(); t != nil {
+			var err error
+			if operationType, err = g.mapType(t); err != nil {
+				return err
+			}
 		}
 	}
 
 	// If not a comparison and not inferred yet, try result type
 	if operationType == "" && call.Result.Type != nil && !isComparison {
 		var err error
-		operationType, err = g.mapType(call.Result.Type)
-		if err != nil {
-			operationType = "i64" // fallback to int
+		if operationType, err = g.mapType(call.Result.Type); err != nil {
+			return err
 		}
 	}
 
-	// Fallback to i64 if we still don't know
 	if operationType == "" {
-		operationType = "i64"
+		return fmt.Errorf("cannot determine the operand type of %s", call.Func)
 	}
 
 	// Generate operands
 	var argRegs []string
 	for _, arg := range call.Args {
+		// nil compares against optionals and pointers of any type
+		if isComparison && isNilLiteral(arg) && strings.HasSuffix(operationType, "*") {
+			argRegs = append(argRegs, "null")
+			continue
+		}
 		argReg, err := g.generateOperand(arg)
 		if err != nil {
 			return err
@@ -708,6 +977,10 @@ func (g *Generator) generateOperatorIntrinsic(call *mir.Call) error {
 	// Determine if this is a float operation
 	isFloat := isFloatType(operationType)
 
+	// Integer add/sub/mul/div are checked unless the overflow mode is wrap
+	trapOverflow := !isFloat && g.Overflow != OverflowWrap && strings.HasPrefix(operationType, "i") && operationType != "i1"
+	signed := len(call.Args) == 0 || !isUnsigned(call.Args[0].OperandType())
+
 	// Generate the appropriate LLVM operation
 	switch call.Func {
 	case "__add__":
@@ -716,6 +989,8 @@ func (g *Generator) generateOperatorIntrinsic(call *mir.Call) error {
 		}
 		if isFloat {
 			g.emit(fmt.Sprintf("  %s = fadd %s %s, %s", resultReg, operationType, argRegs[0], argRegs[1]))
+		} else if trapOverflow {
+			resultReg = g.emitTrappingArith("add", overflowOpAdd, signed, operationType, argRegs[0], argRegs[1])
 		} else {
 			g.emit(fmt.Sprintf("  %s = add %s %s, %s", resultReg, operationType, argRegs[0], argRegs[1]))
 		}
@@ -725,6 +1000,8 @@ func (g *Generator) generateOperatorIntrinsic(call *mir.Call) error {
 		}
 		if isFloat {
 			g.emit(fmt.Sprintf("  %s = fsub %s %s, %s", resultReg, operationType, argRegs[0], argRegs[1]))
+		} else if trapOverflow {
+			resultReg = g.emitTrappingArith("sub", overflowOpSub, signed, operationType, argRegs[0], argRegs[1])
 		} else {
 			g.emit(fmt.Sprintf("  %s = sub %s %s, %s", resultReg, operationType, argRegs[0], argRegs[1]))
 		}
@@ -734,6 +1011,8 @@ func (g *Generator) generateOperatorIntrinsic(call *mir.Call) error {
 		}
 		if isFloat {
 			g.emit(fmt.Sprintf("  %s = fmul %s %s, %s", resultReg, operationType, argRegs[0], argRegs[1]))
+		} else if trapOverflow {
+			resultReg = g.emitTrappingArith("mul", overflowOpMul, signed, operationType, argRegs[0], argRegs[1])
 		} else {
 			g.emit(fmt.Sprintf("  %s = mul %s %s, %s", resultReg, operationType, argRegs[0], argRegs[1]))
 		}
@@ -744,7 +1023,7 @@ func (g *Generator) generateOperatorIntrinsic(call *mir.Call) error {
 		if isFloat {
 			g.emit(fmt.Sprintf("  %s = fdiv %s %s, %s", resultReg, operationType, argRegs[0], argRegs[1]))
 		} else {
-			g.emit(fmt.Sprintf("  %s = sdiv %s %s, %s", resultReg, operationType, argRegs[0], argRegs[1]))
+			resultReg = g.emitIntDivision(signed, operationType, argRegs[0], argRegs[1])
 		}
 	case "__eq__":
 		if len(argRegs) != 2 {
@@ -934,8 +1213,12 @@ func (g *Generator) generateLoadField(load *mir.LoadField) error {
 		fieldPtrReg, structType, structType+"*", targetReg, fieldIndex))
 
 	// Bitcast field pointer to result type pointer
+	fieldPtrType := structType
+	if localRef, ok := load.Target.(*mir.LocalRef); ok {
+		fieldPtrType = g.fieldLLVMType(localRef.Local.Type, load.Field, structType)
+	}
 	castReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = bitcast %s* %s to %s*", castReg, structType, fieldPtrReg, resultType))
+	g.emit(fmt.Sprintf("  %s = bitcast %s* %s to %s*", castReg, fieldPtrType, fieldPtrReg, resultType))
 
 	// Load field value
 	g.emit(fmt.Sprintf("  %s = load %s, %s* %s", resultReg, resultType, resultType, castReg))
@@ -1056,6 +1339,9 @@ func (g *Generator) generateStoreField(store *mir.StoreField) error {
 			if llvmType, err := g.mapType(fieldType); err == nil {
 				valueType = llvmType
 			}
+			if valueReg, err = g.coerceArray(store.Value, valueReg, fieldType); err != nil {
+				return err
+			}
 		}
 	}
 	// If we couldn't get it from struct definition, try to infer from value
@@ -1077,8 +1363,12 @@ func (g *Generator) generateStoreField(store *mir.StoreField) error {
 		fieldPtrReg, structType, structType+"*", targetReg, fieldIndex))
 
 	// Bitcast field pointer to value type pointer
+	fieldPtrType := structType
+	if localRef, ok := store.Target.(*mir.LocalRef); ok {
+		fieldPtrType = g.fieldLLVMType(localRef.Local.Type, store.Field, structType)
+	}
 	castReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = bitcast %s* %s to %s*", castReg, structType, fieldPtrReg, valueType))
+	g.emit(fmt.Sprintf("  %s = bitcast %s* %s to %s*", castReg, fieldPtrType, fieldPtrReg, valueType))
 
 	// Store value
 	g.emit(fmt.Sprintf("  store %s %s, %s* %s", valueType, valueReg, valueType, castReg))
@@ -1088,6 +1378,12 @@ func (g *Generator) generateStoreField(store *mir.StoreField) error {
 
 // generateLoadIndex generates LLVM IR for loading an array/slice element
 func (g *Generator) generateLoadIndex(load *mir.LoadIndex) error {
+	if _, ok := mapOf(load.Target); ok {
+		return g.generateMapLoad(load)
+	}
+	if t := load.Target.OperandType(); arrayOf(t) != nil || pointeeArray(t) != nil {
+		return g.generateArrayLoad(load)
+	}
 	targetReg, err := g.generateOperand(load.Target)
 	if err != nil {
 		return err
@@ -1111,11 +1407,9 @@ func (g *Generator) generateLoadIndex(load *mir.LoadIndex) error {
 			return err
 		}
 
-		// Call runtime_slice_get
-		// returns i8* pointer to the element
-		elemPtrReg := g.nextReg()
-		g.emit(fmt.Sprintf("  %s = call i8* @runtime_slice_get(%%Slice* %s, i64 %s)",
-			elemPtrReg, currentBase, indexReg))
+		// Pointer to the element, bounds checked by runtime_slice_get
+		// unless the last index is known to be in range
+		elemPtrReg := g.elementPtr(currentBase, indexReg, load.InBounds && i == len(load.Indices)-1)
 
 		if i < len(load.Indices)-1 {
 			// Not the last index, so the element must be a Slice
@@ -1123,7 +1417,7 @@ func (g *Generator) generateLoadIndex(load *mir.LoadIndex) error {
 			// Note: runtime_slice_get returns a pointer to the element.
 			// If the element is a Slice struct, we have a pointer to it.
 			nextBase := g.nextReg()
-			g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %%Slice*", nextBase, elemPtrReg))
+			g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %%struct.Slice*", nextBase, elemPtrReg))
 			currentBase = nextBase
 		} else {
 			// Last index, load the final value
@@ -1216,6 +1510,12 @@ func (g *Generator) generateLoadIndex(load *mir.LoadIndex) error {
 
 // generateStoreIndex generates LLVM IR for storing to an array/slice element
 func (g *Generator) generateStoreIndex(store *mir.StoreIndex) error {
+	if _, ok := mapOf(store.Target); ok {
+		return g.generateMapStore(store)
+	}
+	if t := store.Target.OperandType(); arrayOf(t) != nil || pointeeArray(t) != nil {
+		return g.generateArrayStore(store)
+	}
 	targetReg, err := g.generateOperand(store.Target)
 	if err != nil {
 		return err
@@ -1224,6 +1524,18 @@ func (g *Generator) generateStoreIndex(store *mir.StoreIndex) error {
 	if err != nil {
 		return err
 	}
+	valueType, err := g.mapType(store.Value.OperandType())
+	if err != nil {
+		// Pass the value through unchanged, as a pointer to copy from
+		valueType = "i8*"
+	}
+	if elem, err := g.getElementType(store.Target.OperandType()); err == nil && sliceOf(elem) != nil {
+		// An array stored into a slice of slices becomes a slice
+		if valueReg, err = g.coerceArray(store.Value, valueReg, elem); err != nil {
+			return err
+		}
+		valueType = "%struct.Slice*"
+	}
 
 	// Handle multi-dimensional indexing
 	currentBase := targetReg
@@ -1236,25 +1548,42 @@ func (g *Generator) generateStoreIndex(store *mir.StoreIndex) error {
 
 		if i < len(store.Indices)-1 {
 			// Not the last index, we need to traverse
-			elemPtrReg := g.nextReg()
-			g.emit(fmt.Sprintf("  %s = call i8* @runtime_slice_get(%%Slice* %s, i64 %s)",
-				elemPtrReg, currentBase, indexReg))
+			elemPtrReg := g.elementPtr(currentBase, indexReg, false)
 
 			nextBase := g.nextReg()
-			g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %%Slice*", nextBase, elemPtrReg))
+			g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %%struct.Slice*", nextBase, elemPtrReg))
 			currentBase = nextBase
 		} else {
 			// Last index, perform the store
-			g.emit(fmt.Sprintf("  call void @runtime_slice_set(%%Slice* %s, i64 %s, i8* %s)",
-				currentBase, indexReg, valueReg))
+			g.storeElement(currentBase, indexReg, valueType, valueReg, store.InBounds)
 		}
 	}
 
 	return nil
 }
 
+// allocObject returns an i8* to size bytes of storage for the value built by
+// stmt: the stack slot reserved by emitStackSlots if escape analysis allowed
+// one, GC memory otherwise
+func (g *Generator) allocObject(stmt mir.Statement, sizeReg string) string {
+	if slot, ok := g.stackSlots[stmt]; ok {
+		return slot
+	}
+	memReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = call i8* @runtime_alloc(i64 %s)", memReg, sizeReg))
+	return memReg
+}
+
 // generateConstructStruct generates LLVM IR for struct construction
 func (g *Generator) generateConstructStruct(cons *mir.ConstructStruct) error {
+	if m, ok := cons.Type.(*types.Map); ok {
+		// `map[K, V]{}` is parsed as a struct literal
+		mapReg, err := g.newMap(m)
+		if err != nil {
+			return err
+		}
+		return g.setCallResult(cons.Result, "%HashMap*", mapReg)
+	}
 	// Get struct type
 	var structType string
 	if cons.Type == nil {
@@ -1278,9 +1607,8 @@ func (g *Generator) generateConstructStruct(cons *mir.ConstructStruct) error {
 		return fmt.Errorf("failed to calculate struct size: %w", err)
 	}
 
-	// Allocate struct on heap
-	memReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = call i8* @runtime_alloc(i64 %s)", memReg, sizeReg))
+	// Allocate struct on the heap, or in its stack slot if it does not escape
+	memReg := g.allocObject(cons, sizeReg)
 
 	// Cast to struct pointer
 	allocaReg := g.nextReg()
@@ -1297,9 +1625,9 @@ func (g *Generator) generateConstructStruct(cons *mir.ConstructStruct) error {
 	if !ok {
 		// For generic structs, we might be looking for "Slice" but structFields has "Slice$Item"
 		// Try prefix matching: if any key starts with structName followed by $, use that
-		for key, fm := range g.structFields {
+		for _, key := range slices.Sorted(maps.Keys(g.structFields)) {
 			if strings.HasPrefix(key, structName+"$") || key == structName {
-				fieldMap = fm
+				fieldMap = g.structFields[key]
 				ok = true
 				break
 			}
@@ -1310,7 +1638,13 @@ func (g *Generator) generateConstructStruct(cons *mir.ConstructStruct) error {
 		}
 	}
 
-	for fieldName, fieldValue := range cons.Fields {
+	// Store the fields in declaration order, so the output does not depend
+	// on map iteration
+	fieldNames := slices.SortedFunc(maps.Keys(cons.Fields), func(a, b string) int {
+		return cmp.Or(cmp.Compare(fieldMap[a], fieldMap[b]), cmp.Compare(a, b))
+	})
+	for _, fieldName := range fieldNames {
+		fieldValue := cons.Fields[fieldName]
 		fieldIndex, ok := fieldMap[fieldName]
 		if !ok {
 			return fmt.Errorf("field %s not found in struct %s", fieldName, structName)
@@ -1332,6 +1666,9 @@ func (g *Generator) generateConstructStruct(cons *mir.ConstructStruct) error {
 			if llvmType, err := g.mapType(fieldTypObj); err == nil {
 				fieldType = llvmType
 			}
+			if fieldReg, err = g.coerceArray(fieldValue, fieldReg, fieldTypObj); err != nil {
+				return err
+			}
 		}
 		// If we couldn't get it from struct, try to infer from value
 		if fieldType == "i64" {
@@ -1361,6 +1698,10 @@ func (g *Generator) generateConstructStruct(cons *mir.ConstructStruct) error {
 
 // generateConstructArray generates LLVM IR for array/slice construction
 func (g *Generator) generateConstructArray(cons *mir.ConstructArray) error {
+	if arr := arrayOf(cons.Result.Type); arr != nil {
+		return g.generateArrayValue(cons, arr)
+	}
+
 	// Extract element type from array/slice type
 	elemType, err := g.getElementType(cons.Type)
 	if err != nil {
@@ -1368,7 +1709,7 @@ func (g *Generator) generateConstructArray(cons *mir.ConstructArray) error {
 	}
 
 	// Calculate element size in bytes
-	elemSize, err := g.calculateElementSize(elemType)
+	elemSize, err := g.sliceElementSize(elemType)
 	if err != nil {
 		return fmt.Errorf("failed to calculate element size: %w", err)
 	}
@@ -1385,7 +1726,7 @@ func (g *Generator) generateConstructArray(cons *mir.ConstructArray) error {
 	resultReg := g.nextReg()
 	g.localRegs[cons.Result.ID] = resultReg
 	g.localIsValue[cons.Result.ID] = true // The pointer is the value
-	g.emit(fmt.Sprintf("  %s = call %%Slice* @runtime_slice_new(i64 %s, i64 %d, i64 %d)",
+	g.emit(fmt.Sprintf("  %s = call %%struct.Slice* @runtime_slice_new(i64 %s, i64 %d, i64 %d)",
 		resultReg, elemSize, length, capacity))
 
 	// Store each element into the slice
@@ -1404,6 +1745,15 @@ func (g *Generator) generateConstructArray(cons *mir.ConstructArray) error {
 			actualElemType = lit.Type
 		}
 
+		// An array literal nested in a slice literal becomes a slice
+		if arrayOf(actualElemType) != nil && sliceOf(elemType) != nil {
+			elemReg, err = g.coerceArray(elem, elemReg, elemType)
+			if err != nil {
+				return err
+			}
+			actualElemType = elemType
+		}
+
 		// Get element LLVM type for temporary storage
 		elemLLVMType, err := g.mapType(actualElemType)
 		if err != nil {
@@ -1429,7 +1779,7 @@ func (g *Generator) generateConstructArray(cons *mir.ConstructArray) error {
 
 		// Call runtime_slice_set to store the element
 		// Use the index directly as a constant
-		g.emit(fmt.Sprintf("  call void @runtime_slice_set(%%Slice* %s, i64 %d, i8* %s)",
+		g.emit(fmt.Sprintf("  call void @runtime_slice_set(%%struct.Slice* %s, i64 %d, i8* %s)",
 			resultReg, i, elemPtr))
 	}
 
@@ -1464,9 +1814,8 @@ func (g *Generator) generateConstructTuple(cons *mir.ConstructTuple) error {
 		return fmt.Errorf("failed to calculate tuple size: %w", err)
 	}
 
-	// Allocate tuple on heap
-	memReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = call i8* @runtime_alloc(i64 %s)", memReg, sizeReg))
+	// Allocate tuple on the heap, or in its stack slot if it does not escape
+	memReg := g.allocObject(cons, sizeReg)
 
 	// Cast to tuple pointer
 	allocaReg := g.nextReg()
@@ -1531,6 +1880,10 @@ func (g *Generator) generateConstructTuple(cons *mir.ConstructTuple) error {
 
 // generateConstructEnum generates LLVM IR for enum construction
 func (g *Generator) generateConstructEnum(cons *mir.ConstructEnum) error {
+	if ok, err := g.generateUnboxedEnum(cons); ok || err != nil {
+		return err
+	}
+
 	// Get enum type
 	enumType := "%enum." + sanitizeName(cons.Type)
 	enumPtrType := enumType + "*"
@@ -1541,9 +1894,8 @@ func (g *Generator) generateConstructEnum(cons *mir.ConstructEnum) error {
 		return fmt.Errorf("failed to calculate enum size: %w", err)
 	}
 
-	// Allocate enum on heap
-	memReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = call i8* @runtime_alloc(i64 %s)", memReg, sizeReg))
+	// Allocate enum on the heap, or in its stack slot if it does not escape
+	memReg := g.allocObject(cons, sizeReg)
 
 	// Cast to enum pointer
 	allocaReg := g.nextReg()
@@ -1559,7 +1911,8 @@ func (g *Generator) generateConstructEnum(cons *mir.ConstructEnum) error {
 	g.emit(fmt.Sprintf("  %s = getelementptr inbounds %s, %s %s, i32 0, i32 0",
 		tagPtrReg, enumType, enumPtrType, allocaReg))
 
-	g.emit(fmt.Sprintf("  store i32 %d, i32* %s", cons.VariantIndex, tagPtrReg))
+	tagType := g.enumTagType(cons.Type)
+	g.emit(fmt.Sprintf("  store %s %d, %s* %s", tagType, cons.VariantIndex, tagType, tagPtrReg))
 
 	// Set payload if any
 	if len(cons.Values) > 0 {
@@ -1602,9 +1955,8 @@ func (g *Generator) generateConstructEnum(cons *mir.ConstructEnum) error {
 
 		// Bitcast payload pointer to correct type
 		castPayloadPtrReg := g.nextReg()
-		// Note: payload field in enum struct is usually [0 x i8] or similar opaque type
-		// We cast it to the actual payload type pointer
-		g.emit(fmt.Sprintf("  %s = bitcast [0 x i8]* %s to %s*", castPayloadPtrReg, payloadPtrReg, payloadType))
+		// The payload field is an opaque byte array; cast it to the actual payload type pointer
+		g.emit(fmt.Sprintf("  %s = bitcast %s* %s to %s*", castPayloadPtrReg, g.enumPayloadType(cons.Type), payloadPtrReg, payloadType))
 
 		// Store values
 		if len(cons.Values) == 1 {
@@ -1644,6 +1996,10 @@ func (g *Generator) generateConstructEnum(cons *mir.ConstructEnum) error {
 
 // generateDiscriminant generates LLVM IR for extracting enum discriminant
 func (g *Generator) generateDiscriminant(disc *mir.Discriminant) error {
+	if ok, err := g.generateUnboxedDiscriminant(disc); ok || err != nil {
+		return err
+	}
+
 	// Get target register
 	targetReg, err := g.generateOperand(disc.Target)
 	if err != nil {
@@ -1679,17 +2035,20 @@ func (g *Generator) generateDiscriminant(disc *mir.Discriminant) error {
 	g.emit(fmt.Sprintf("  %s = getelementptr inbounds %s, %s* %s, i32 0, i32 0",
 		discPtrReg, enumType, enumType, targetReg))
 
-	// Load discriminant
-	// Assuming discriminant is i32
+	// Load discriminant, an i32 unless the enum has a #[repr]
+	tagType := g.enumTagType(strings.TrimPrefix(enumType, "%enum."))
 	discValReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = load i32, i32* %s", discValReg, discPtrReg))
+	g.emit(fmt.Sprintf("  %s = load %s, %s* %s", discValReg, tagType, tagType, discPtrReg))
 
 	// Cast to result type if needed (e.g. if result is i64)
 	var finalReg string
-	if resultType != "i32" {
-		// Sign extend or zero extend? Discriminants are usually positive indices.
-		// Let's use zext.
-		g.emit(fmt.Sprintf("  %s = zext i32 %s to %s", resultReg, discValReg, resultType))
+	if resultType != tagType {
+		// Discriminants are variant indices, so never negative: zext
+		castOp := "zext"
+		if intBits(tagType) > intBits(resultType) {
+			castOp = "trunc"
+		}
+		g.emit(fmt.Sprintf("  %s = %s %s %s to %s", resultReg, castOp, tagType, discValReg, resultType))
 		finalReg = resultReg
 	} else {
 		// Just use the loaded value
@@ -1711,36 +2070,22 @@ func (g *Generator) generateDiscriminant(disc *mir.Discriminant) error {
 
 // generateAccessVariantPayload generates LLVM IR for accessing enum variant payload
 func (g *Generator) generateAccessVariantPayload(access *mir.AccessVariantPayload) error {
+	if ok, err := g.generateUnboxedPayload(access); ok || err != nil {
+		return err
+	}
+
 	// Get target register
 	targetReg, err := g.generateOperand(access.Target)
 	if err != nil {
 		return err
 	}
 
-	// Get enum type
-	var enumType *types.Enum
-	var genericArgs []types.Type
-
-	if localRef, ok := access.Target.(*mir.LocalRef); ok {
-		if e, ok := localRef.Local.Type.(*types.Enum); ok {
-			enumType = e
-		} else if ptr, ok := localRef.Local.Type.(*types.Pointer); ok {
-			if e, ok := ptr.Elem.(*types.Enum); ok {
-				enumType = e
-			} else if generic, ok := ptr.Elem.(*types.GenericInstance); ok {
-				if e, ok := generic.Base.(*types.Enum); ok {
-					enumType = e
-					genericArgs = generic.Args
-				}
-			}
-		} else if generic, ok := localRef.Local.Type.(*types.GenericInstance); ok {
-			if e, ok := generic.Base.(*types.Enum); ok {
-				enumType = e
-				genericArgs = generic.Args
-			}
-		}
+	// Get enum type, through a pointer to it if need be
+	targetType := access.Target.OperandType()
+	if ptr, ok := targetType.(*types.Pointer); ok {
+		targetType = ptr.Elem
 	}
-	// Also check Literal type if needed, but usually it's LocalRef
+	enumType, genericArgs := g.enumOf(targetType)
 
 	if enumType == nil {
 		// Try to map type and see if it looks like enum
@@ -1800,9 +2145,14 @@ func (g *Generator) generateAccessVariantPayload(access *mir.AccessVariantPayloa
 		payloadType = "{" + strings.Join(elemTypes, ", ") + "}"
 	}
 
+	// A void payload (e.g. Ok() of Result[void, E]) carries no data to load
+	if payloadType == "void" {
+		return nil
+	}
+
 	// Bitcast payload pointer
 	castPayloadPtrReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = bitcast [0 x i8]* %s to %s*", castPayloadPtrReg, payloadPtrReg, payloadType))
+	g.emit(fmt.Sprintf("  %s = bitcast %s* %s to %s*", castPayloadPtrReg, g.enumPayloadType(enumType.Name), payloadPtrReg, payloadType))
 
 	// Check if there's already an alloca for this result (from pre-allocation)
 	allocaReg, hasAlloca := g.localRegs[access.Result.ID]
@@ -1847,12 +2197,11 @@ func (g *Generator) generateAccessVariantPayload(access *mir.AccessVariantPayloa
 
 // generateSpawn generates LLVM IR for spawning a legion
 func (g *Generator) generateSpawn(spawn *mir.Spawn) error {
+	if g.Target.IsWASI() {
+		return fmt.Errorf("spawn is not supported on %s: WASI programs run on a single thread", g.Target.Triple)
+	}
 	funcName := sanitizeName(spawn.Func)
 
-	// Generate wrapper function that conforms to legion signature: void (*fn)(void*)
-	wrapperName := fmt.Sprintf("spawn_wrapper_%s_%d", funcName, g.regCounter)
-	g.regCounter++
-
 	// Generate argument registers and types
 	var argRegs []string
 	var argTypes []string
@@ -1878,54 +2227,22 @@ func (g *Generator) generateSpawn(spawn *mir.Spawn) error {
 		argTypes = append(argTypes, argType)
 	}
 
-	// Build wrapper function
-	wrapper := strings.Builder{}
-
-	var argStructPtr string
-	if len(spawn.Args) == 0 {
-		// No arguments - just call the function
-	} else {
-		// Has arguments - unpack from struct and call function
-		var unpackedArgs []string
-		offset := 0
-
-		for i, argType := range argTypes {
-			// Calculate aligned offset
-			alignment := 8 // Assume 8-byte alignment for simplicity
-			if argType == "i32" || argType == "float" {
-				alignment = 4
-			} else if argType == "i8" || argType == "i1" {
-				alignment = 1
-			}
-			offset = (offset + alignment - 1) & ^(alignment - 1)
-
-			// Get pointer to this argument in the struct
-			_ = fmt.Sprintf("%%offset%d", i) // offsetReg unused in incomplete code
-
-			// Cast to argument type pointer
-			_ = fmt.Sprintf("%%cast%d", i) // castReg unused in incomplete code
-
-			// Load the argument value
-			loadReg := fmt.Sprintf("%%arg%d", i)
-
-			unpackedArgs = append(unpackedArgs, fmt.Sprintf("%s %s", argType, loadReg))
-
-			// Update offset
-			size := 8 // Default size
-			if argType == "i32" || argType == "float" {
-				size = 4
-			} else if argType == "i8" || argType == "i1" {
-				size = 1
-			}
-			offset += size
+	retType := "void"
+	if target := g.findFunction(spawn.Func); target != nil {
+		if t, err := g.mapType(target.ReturnType); err == nil {
+			retType = t
 		}
+	}
 
-		// Call the function with unpacked arguments
-		_ = strings.Join(unpackedArgs, ", ") // argsStr unused in incomplete code
+	// Generate wrapper function that conforms to legion signature:
+	// void (*fn)(void*). Spawns with the same signature share it.
+	wrapperName := spawnWrapperName(funcName, argTypes, retType)
+	if !slices.Contains(g.spawnWrapperNames, wrapperName) {
+		g.spawnWrappers = append(g.spawnWrappers, spawnWrapper(wrapperName, funcName, argTypes, retType))
+		g.spawnWrapperNames = append(g.spawnWrapperNames, wrapperName)
 	}
 
-	// Add wrapper to collection
-	g.spawnWrappers = append(g.spawnWrappers, wrapper.String())
+	var argStructPtr string
 
 	// Now pack arguments into a struct if needed
 	if len(spawn.Args) > 0 {
@@ -1949,9 +2266,9 @@ func (g *Generator) generateSpawn(spawn *mir.Spawn) error {
 			structSize += size
 		}
 
-		// Allocate struct
+		// Allocate struct; the spawned legion's wrapper frees it
 		argStructReg := g.nextReg()
-		g.emit(fmt.Sprintf("  %s = call i8* @runtime_alloc(i64 %d)", argStructReg, structSize))
+		g.emit(fmt.Sprintf("  %s = call i8* @runtime_spawn_args_alloc(i64 %d)", argStructReg, structSize))
 		argStructPtr = argStructReg
 
 		// Pack arguments into struct
@@ -1994,12 +2311,115 @@ func (g *Generator) generateSpawn(spawn *mir.Spawn) error {
 
 	// Call runtime_legion_spawn with the wrapper
 	legionPtrReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = call %%Legion* @runtime_legion_spawn(void (i8*)* @%s, i8* %s, i64 8192)",
+	// Stack size 0 selects the runtime default (LEGION_STACK_SIZE)
+	g.emit(fmt.Sprintf("  %s = call %%Legion* @runtime_legion_spawn(void (i8*)* @%s, i8* %s, i64 0)",
 		legionPtrReg, wrapperName, argStructPtr))
 
 	// Call runtime_legion_start to begin execution
 	g.emit(fmt.Sprintf("  call void @runtime_legion_start(%%Legion* %s)", legionPtrReg))
 
+	// Spawn expressions keep the legion as their JoinHandle
+	if spawn.Result != nil {
+		g.localRegs[spawn.Result.ID] = legionPtrReg
+		g.localIsValue[spawn.Result.ID] = true
+	}
+
+	return nil
+}
+
+// spawnWrapperName mangles the name of the wrapper that spawns funcName with
+// arguments of argTypes. It hashes the signature instead of counting the
+// wrappers, so the name does not depend on the order functions are
+// generated in and builds of the same source are identical.
+func spawnWrapperName(funcName string, argTypes []string, retType string) string {
+	sum := sha256.Sum256([]byte(funcName + "(" + strings.Join(argTypes, ",") + ")" + retType))
+	return fmt.Sprintf("spawn_wrapper_%s_%s", funcName, hex.EncodeToString(sum[:4]))
+}
+
+// spawnWrapper returns the wrapper function named wrapperName: it unpacks
+// the arguments from the struct generateSpawn packs (same layout), frees the
+// struct, calls funcName and boxes its result for join
+func spawnWrapper(wrapperName, funcName string, argTypes []string, retType string) string {
+	wrapper := strings.Builder{}
+	wrapper.WriteString(fmt.Sprintf("\ndefine internal void @%s(i8* %%env) {\nentry:\n", wrapperName))
+
+	var unpackedArgs []string
+	offset := 0
+	for i, argType := range argTypes {
+		// Calculate aligned offset
+		alignment := 8 // Assume 8-byte alignment for simplicity
+		if argType == "i32" || argType == "float" {
+			alignment = 4
+		} else if argType == "i8" || argType == "i1" {
+			alignment = 1
+		}
+		offset = (offset + alignment - 1) & ^(alignment - 1)
+
+		offsetReg := fmt.Sprintf("%%offset%d", i)
+		castReg := fmt.Sprintf("%%cast%d", i)
+		loadReg := fmt.Sprintf("%%arg%d", i)
+		wrapper.WriteString(fmt.Sprintf("  %s = getelementptr i8, i8* %%env, i64 %d\n", offsetReg, offset))
+		wrapper.WriteString(fmt.Sprintf("  %s = bitcast i8* %s to %s*\n", castReg, offsetReg, argType))
+		wrapper.WriteString(fmt.Sprintf("  %s = load %s, %s* %s\n", loadReg, argType, argType, castReg))
+		unpackedArgs = append(unpackedArgs, fmt.Sprintf("%s %s", argType, loadReg))
+
+		// Update offset
+		size := 8 // Default size
+		if argType == "i32" || argType == "float" {
+			size = 4
+		} else if argType == "i8" || argType == "i1" {
+			size = 1
+		}
+		offset += size
+	}
+	if len(argTypes) > 0 {
+		// The wrapper owns the arguments; they are not needed once unpacked
+		wrapper.WriteString("  call void @runtime_spawn_args_free(i8* %env)\n")
+	}
+
+	argsStr := strings.Join(unpackedArgs, ", ")
+	if retType == "void" {
+		wrapper.WriteString(fmt.Sprintf("  call void @%s(%s)\n", funcName, argsStr))
+	} else {
+		wrapper.WriteString(fmt.Sprintf("  %%result = call %s @%s(%s)\n", retType, funcName, argsStr))
+		wrapper.WriteString(fmt.Sprintf("  %%size.ptr = getelementptr %s, %s* null, i32 1\n", retType, retType))
+		wrapper.WriteString(fmt.Sprintf("  %%size = ptrtoint %s* %%size.ptr to i64\n", retType))
+		wrapper.WriteString("  %box = call i8* @runtime_alloc(i64 %size)\n")
+		wrapper.WriteString(fmt.Sprintf("  %%box.typed = bitcast i8* %%box to %s*\n", retType))
+		wrapper.WriteString(fmt.Sprintf("  store %s %%result, %s* %%box.typed\n", retType, retType))
+		wrapper.WriteString("  call void @runtime_legion_set_result(i8* %box)\n")
+	}
+	wrapper.WriteString("  ret void\n}\n")
+
+	return wrapper.String()
+}
+
+// generateJoin generates LLVM IR for waiting on a JoinHandle and unboxing
+// the spawned function's result
+func (g *Generator) generateJoin(join *mir.Join) error {
+	handleReg, err := g.generateOperand(join.Handle)
+	if err != nil {
+		return err
+	}
+
+	boxReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = call i8* @runtime_legion_join(%%Legion* %s)", boxReg, handleReg))
+
+	resultType, err := g.mapType(join.Result.Type)
+	if err != nil {
+		return err
+	}
+	if resultType == "void" {
+		return nil
+	}
+
+	typedReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s*", typedReg, boxReg, resultType))
+	valueReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = load %s, %s* %s", valueReg, resultType, resultType, typedReg))
+
+	g.localRegs[join.Result.ID] = valueReg
+	g.localIsValue[join.Result.ID] = true
 	return nil
 }
 
@@ -2017,8 +2437,7 @@ func (g *Generator) generateMakeClosure(mc *mir.MakeClosure) error {
 	closureType := "%Closure"
 	closurePtrType := "%Closure*"
 
-	closureReg := g.nextReg()
-	g.emit(fmt.Sprintf("  %s = call i8* @runtime_alloc(i64 16)", closureReg)) // 2 pointers = 16 bytes
+	closureReg := g.allocObject(mc, "16") // 2 pointers = 16 bytes
 
 	closurePtrReg := g.nextReg()
 	g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s", closurePtrReg, closureReg, closurePtrType))
@@ -2032,7 +2451,12 @@ func (g *Generator) generateMakeClosure(mc *mir.MakeClosure) error {
 	if !ok {
 		return fmt.Errorf("MakeClosure result must be a function type, got %T", mc.Result.Type)
 	}
-	fnSig, err := g.getFunctionSignature(fnType)
+	envType := mc.Env.OperandType()
+	envLLVMType, err := g.mapType(envType)
+	if err != nil {
+		return err
+	}
+	fnSig, err := g.closureSignature(fnType, envLLVMType)
 	if err != nil {
 		return err
 	}
@@ -2055,12 +2479,6 @@ func (g *Generator) generateMakeClosure(mc *mir.MakeClosure) error {
 	g.emit(fmt.Sprintf("  %s = getelementptr inbounds %s, %s %s, i32 0, i32 1", envPtrReg, closureType, closurePtrType, closurePtrReg))
 
 	// Cast env to i8*
-	envType := mc.Env.OperandType()
-	envLLVMType, err := g.mapType(envType)
-	if err != nil {
-		return err
-	}
-
 	var envI8Ptr string
 	if envLLVMType == "i8*" {
 		envI8Ptr = envReg
@@ -2098,3 +2516,24 @@ func (g *Generator) getFunctionSignature(fnType *types.Function) (string, error)
 
 	return fmt.Sprintf("%s (%s)", retType, strings.Join(paramTypes, ", ")), nil
 }
+
+// closureSignature returns the LLVM type of the function of a closure of
+// type fnType, which takes its environment, of LLVM type env, before the
+// parameters of fnType
+func (g *Generator) closureSignature(fnType *types.Function, env string) (string, error) {
+	retType, err := g.mapType(fnType.Return)
+	if err != nil {
+		return "", err
+	}
+
+	paramTypes := []string{env}
+	for _, param := range fnType.Params {
+		pt, err := g.mapType(param)
+		if err != nil {
+			return "", err
+		}
+		paramTypes = append(paramTypes, pt)
+	}
+
+	return fmt.Sprintf("%s (%s)", retType, strings.Join(paramTypes, ", ")), nil
+}
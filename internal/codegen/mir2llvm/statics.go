@@ -0,0 +1,93 @@
+package mir2llvm
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+// emitStatics emits a global for each static of the module, initialized
+// with its constant value. Immutable statics are LLVM constants. A string
+// static points at the same constant String as a literal with its value.
+func (g *Generator) emitStatics(module *mir.Module) error {
+	if len(module.Statics) == 0 {
+		return nil
+	}
+
+	g.emit("; Statics")
+	for _, static := range module.Statics {
+		typ, err := g.mapType(static.Type)
+		if err != nil {
+			return fmt.Errorf("static %s: %w", static.Name, err)
+		}
+		kind := "constant"
+		if static.Mutable {
+			kind = "global"
+		}
+
+		var init string
+		switch v := static.Value.(type) {
+		case int64:
+			init = fmt.Sprintf("%d", v)
+		case float64:
+			init = fmt.Sprintf("0x%016X", math.Float64bits(v))
+		case bool:
+			init = fmt.Sprintf("%t", v)
+		case string:
+			init = fmt.Sprintf("bitcast ({ i64, i8* }* %s to %%String*)", g.stringConstant(v))
+		default:
+			return fmt.Errorf("static %s has no constant value", static.Name)
+		}
+		g.emit(fmt.Sprintf("@static.%s = internal %s %s %s", static.Name, kind, typ, init))
+	}
+	g.emit("")
+	return nil
+}
+
+// generateLoadStatic generates LLVM IR for reading a static
+func (g *Generator) generateLoadStatic(load *mir.LoadStatic) error {
+	resultType, err := g.mapType(load.Result.Type)
+	if err != nil {
+		return err
+	}
+
+	resultReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = load %s, %s* @static.%s", resultReg, resultType, resultType, load.Name))
+
+	g.localRegs[load.Result.ID] = resultReg
+	g.localIsValue[load.Result.ID] = true
+	return nil
+}
+
+// generateStoreStatic generates LLVM IR for writing a mutable static
+func (g *Generator) generateStoreStatic(store *mir.StoreStatic) error {
+	valueReg, err := g.generateOperand(store.Value)
+	if err != nil {
+		return err
+	}
+	static := g.findStatic(store.Name)
+	if static == nil {
+		return fmt.Errorf("unknown static: %s", store.Name)
+	}
+	valueType, err := g.mapType(static.Type)
+	if err != nil {
+		return err
+	}
+
+	g.emit(fmt.Sprintf("  store %s %s, %s* @static.%s", valueType, valueReg, valueType, store.Name))
+	return nil
+}
+
+// findStatic returns the static of the module named name, or nil
+func (g *Generator) findStatic(name string) *mir.Static {
+	if g.currentModule == nil {
+		return nil
+	}
+	for _, static := range g.currentModule.Statics {
+		if static.Name == name {
+			return static
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package mir2llvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func TestGenerateStatics(t *testing.T) {
+	src := `package main;
+
+static LIMIT: int = 10;
+static SCALE: float = 0.5;
+static GREETING: string = "hi";
+static mut COUNT: int = 0;
+
+fn main() {
+	unsafe {
+		COUNT = COUNT + LIMIT;
+	};
+	println(GREETING);
+	println(SCALE);
+}
+`
+	p := parser.New(src)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("Parse error: %v", p.Errors()[0])
+	}
+	checker := types.NewChecker()
+	checker.Check(file)
+	if len(checker.Errors) > 0 {
+		t.Fatalf("Type check error: %v", checker.Errors[0])
+	}
+	lowerer := mir.NewLowerer(checker.ExprTypes, checker.CallTypeArgs, nil, nil, nil)
+	lowerer.Consts = checker.Consts
+	mod, err := lowerer.LowerModule(file)
+	if err != nil {
+		t.Fatalf("Lower error: %v", err)
+	}
+
+	ir, err := NewGenerator().Generate(mod)
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	for _, want := range []string{
+		"@static.LIMIT = internal constant i64 10",
+		"@static.SCALE = internal constant double 0x3FE0000000000000",
+		"@.str.0.data = private unnamed_addr constant [3 x i8] c\"hi\\00\"",
+		"@static.GREETING = internal constant %String* bitcast ({ i64, i8* }* @.str.0 to %String*)",
+		"@static.COUNT = internal global i64 0",
+		"load i64, i64* @static.COUNT",
+		"store i64 ",
+	} {
+		if !strings.Contains(ir, want) {
+			t.Errorf("IR should contain %q, got:\n%s", want, ir)
+		}
+	}
+}
@@ -182,3 +182,42 @@ func TestStructFieldTypes_StringField(t *testing.T) {
 		t.Errorf("Expected 'store i64' for int field, got:\n%s", output)
 	}
 }
+
+func TestConstructStructStoresFieldsInOrder(t *testing.T) {
+	point := &types.Struct{
+		Name: "Point3",
+		Fields: []types.Field{
+			{Name: "z", Type: types.TypeInt},
+			{Name: "x", Type: types.TypeInt},
+			{Name: "y", Type: types.TypeInt},
+		},
+	}
+	var want string
+	for i := 0; i < 10; i++ {
+		gen := newTestGenerator()
+		gen.structTypes["Point3"] = true
+		gen.structFields["Point3"] = map[string]int{"z": 0, "x": 1, "y": 2}
+		err := gen.generateConstructStruct(&mir.ConstructStruct{
+			Result: mir.Local{ID: 1, Name: "p", Type: point},
+			Type:   point,
+			Fields: map[string]mir.Operand{
+				"x": &mir.Literal{Type: types.TypeInt, Value: int64(1)},
+				"y": &mir.Literal{Type: types.TypeInt, Value: int64(2)},
+				"z": &mir.Literal{Type: types.TypeInt, Value: int64(3)},
+			},
+		})
+		if err != nil {
+			t.Fatalf("generateConstructStruct() error = %v", err)
+		}
+		got := gen.builder.String()
+		if i == 0 {
+			want = got
+			z, x, y := strings.Index(got, "store i64 3"), strings.Index(got, "store i64 1"), strings.Index(got, "store i64 2")
+			if z < 0 || !(z < x && x < y) {
+				t.Fatalf("fields should be stored in declaration order z, x, y:\n%s", got)
+			}
+		} else if got != want {
+			t.Fatalf("output changed between runs:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package mir2llvm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Target describes the platform generated code runs on: the target triple
+// and data layout written into the module header, which llc and the linker
+// must agree on.
+type Target struct {
+	Triple     string
+	DataLayout string
+}
+
+// DefaultTarget is the target of a Generator that was not given one.
+var DefaultTarget = Target{
+	Triple:     "x86_64-unknown-linux-gnu",
+	DataLayout: "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128",
+}
+
+// Data layouts by architecture and object format (ELF, Mach-O and COFF
+// mangling differ), as LLVM defines them for each triple.
+var dataLayouts = map[string]map[string]string{
+	"x86_64": {
+		"linux":   "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128",
+		"darwin":  "e-m:o-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128",
+		"windows": "e-m:w-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128",
+	},
+	"aarch64": {
+		"linux":   "e-m:e-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128",
+		"darwin":  "e-m:o-i64:64-i128:128-n32:64-S128",
+		"windows": "e-m:w-p:64:64-i32:32-i64:64-i128:128-n32:64-S128",
+	},
+	"wasm32": {
+		"wasi": "e-m:e-p:32:32-p10:8:8-p20:8:8-i64:64-n32:64-S128-ni:1:10:20",
+	},
+}
+
+// ParseTarget returns the Target for a triple such as x86_64-pc-windows-msvc
+// or arm64-apple-darwin. The 64-bit x86 and ARM architectures on Linux,
+// macOS and Windows are supported, as the runtime's context switch is, and
+// WebAssembly under WASI, where the runtime runs without legions.
+func ParseTarget(triple string) (Target, error) {
+	t := Target{Triple: triple}
+	layouts, ok := dataLayouts[t.Arch()]
+	if !ok {
+		return Target{}, fmt.Errorf("unsupported target %q (expected an x86_64, aarch64 or wasm32 triple)", triple)
+	}
+	layout, ok := layouts[t.OS()]
+	if !ok {
+		if t.Arch() == "wasm32" {
+			return Target{}, fmt.Errorf("unsupported target %q (expected wasm32-wasi)", triple)
+		}
+		return Target{}, fmt.Errorf("unsupported target %q (expected a linux, darwin or windows triple)", triple)
+	}
+	t.DataLayout = layout
+	return t, nil
+}
+
+// Arch returns the architecture of the triple, normalized to LLVM's
+// x86_64 or aarch64 spelling.
+func (t Target) Arch() string {
+	arch, _, _ := strings.Cut(t.Triple, "-")
+	switch arch {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	}
+	return arch
+}
+
+// OS returns the operating system of the triple: linux, darwin, windows,
+// wasi, or the triple's own spelling of any other.
+func (t Target) OS() string {
+	parts := strings.Split(t.Triple, "-")
+	for _, part := range parts[1:] {
+		switch {
+		case strings.HasPrefix(part, "linux"):
+			return "linux"
+		case strings.HasPrefix(part, "darwin"), strings.HasPrefix(part, "macos"):
+			return "darwin"
+		case part == "windows", part == "win32", part == "mingw32":
+			return "windows"
+		case strings.HasPrefix(part, "wasi"):
+			return "wasi"
+		}
+	}
+	if len(parts) > 2 {
+		return parts[2]
+	}
+	return ""
+}
+
+// IsWindows reports whether the target is Windows, under either the MSVC or
+// the MinGW environment.
+func (t Target) IsWindows() bool {
+	return t.OS() == "windows"
+}
+
+// IsMSVC reports whether the target links with the Microsoft toolchain, as
+// opposed to MinGW's GNU ld.
+func (t Target) IsMSVC() bool {
+	return t.IsWindows() && !strings.HasSuffix(t.Triple, "-gnu") && !strings.Contains(t.Triple, "mingw")
+}
+
+// IsMusl reports whether the target links against musl instead of glibc.
+func (t Target) IsMusl() bool {
+	return t.OS() == "linux" && strings.Contains(t.Triple, "musl")
+}
+
+// IsWASI reports whether the target is WebAssembly under WASI, whose programs
+// run on a single thread without sockets of their own.
+func (t Target) IsWASI() bool {
+	return t.OS() == "wasi"
+}
+
+// PointerSize returns the size of a pointer on the target in bytes.
+func (t Target) PointerSize() int64 {
+	if t.Arch() == "wasm32" {
+		return 4
+	}
+	return 8
+}
@@ -0,0 +1,110 @@
+package mir2llvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		triple, arch, os string
+		msvc             bool
+		mangling         string
+	}{
+		{"x86_64-unknown-linux-gnu", "x86_64", "linux", false, "m:e"},
+		{"aarch64-unknown-linux-musl", "aarch64", "linux", false, "m:e"},
+		{"arm64-apple-darwin", "aarch64", "darwin", false, "m:o"},
+		{"x86_64-apple-macosx14.0.0", "x86_64", "darwin", false, "m:o"},
+		{"x86_64-pc-windows-msvc", "x86_64", "windows", true, "m:w"},
+		{"x86_64-pc-windows-gnu", "x86_64", "windows", false, "m:w"},
+		{"x86_64-w64-mingw32", "x86_64", "windows", false, "m:w"},
+		{"aarch64-pc-windows-msvc", "aarch64", "windows", true, "m:w"},
+		{"wasm32-wasi", "wasm32", "wasi", false, "p:32:32"},
+		{"wasm32-unknown-wasip1", "wasm32", "wasi", false, "p:32:32"},
+	}
+	for _, tt := range tests {
+		target, err := ParseTarget(tt.triple)
+		if err != nil {
+			t.Errorf("ParseTarget(%q): %v", tt.triple, err)
+			continue
+		}
+		if target.Arch() != tt.arch || target.OS() != tt.os || target.IsMSVC() != tt.msvc {
+			t.Errorf("ParseTarget(%q) = %s/%s msvc=%v, want %s/%s msvc=%v",
+				tt.triple, target.Arch(), target.OS(), target.IsMSVC(), tt.arch, tt.os, tt.msvc)
+		}
+		if !strings.Contains(target.DataLayout, tt.mangling) {
+			t.Errorf("ParseTarget(%q): data layout %q should use %s mangling", tt.triple, target.DataLayout, tt.mangling)
+		}
+	}
+
+	for _, triple := range []string{"i686-pc-windows-msvc", "wasm32-unknown-unknown", "x86_64-unknown-freebsd"} {
+		if _, err := ParseTarget(triple); err == nil {
+			t.Errorf("ParseTarget(%q) should fail", triple)
+		}
+	}
+}
+
+func TestModuleHeaderNamesTarget(t *testing.T) {
+	gen := NewGenerator()
+	target, err := ParseTarget("x86_64-pc-windows-msvc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen.Target = target
+	ir, err := gen.Generate(&mir.Module{})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if !strings.Contains(ir, `target triple = "x86_64-pc-windows-msvc"`) ||
+		!strings.Contains(ir, `target datalayout = "e-m:w-`) {
+		t.Errorf("module header should name the Windows target:\n%s", ir[:strings.Index(ir, "\n\n")])
+	}
+}
+
+func TestWASIPointerSize(t *testing.T) {
+	gen := newTestGenerator()
+	target, err := ParseTarget("wasm32-wasi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen.Target = target
+
+	if got := target.PointerSize(); got != 4 {
+		t.Errorf("PointerSize() = %d, want 4", got)
+	}
+	for _, typ := range []types.Type{types.TypeString, &types.Pointer{Elem: types.TypeInt}} {
+		size, err := gen.calculateElementSize(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != "4" {
+			t.Errorf("calculateElementSize(%s) = %s, want 4", typ, size)
+		}
+	}
+	if size, _ := llvmTypeLayout("{i32, i8*}", target.PointerSize()); size != 8 {
+		t.Errorf("llvmTypeLayout({i32, i8*}) = %d on wasm32, want 8", size)
+	}
+}
+
+func TestGenerateSpawnRejectedForWASI(t *testing.T) {
+	gen := newTestGenerator()
+	target, err := ParseTarget("wasm32-wasi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen.Target = target
+
+	worker := createTestFunction("worker", []mir.Local{}, types.TypeVoid)
+	worker.Entry.Terminator = &mir.Return{}
+	fn := createTestFunction("main", []mir.Local{}, types.TypeVoid)
+	fn.Entry.Statements = append(fn.Entry.Statements, &mir.Spawn{Func: "worker"})
+	fn.Entry.Terminator = &mir.Return{}
+
+	_, err = gen.Generate(&mir.Module{Functions: []*mir.Function{worker, fn}})
+	if err == nil || !strings.Contains(err.Error(), "spawn is not supported on wasm32-wasi") {
+		t.Errorf("Generate() error = %v, want spawn rejected for wasm32-wasi", err)
+	}
+}
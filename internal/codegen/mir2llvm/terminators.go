@@ -19,157 +19,171 @@ func (g *Generator) generateTerminator(term mir.Terminator, fn *mir.Function, re
 		return g.generateBranch(t)
 	case *mir.Select:
 		return g.generateSelect(t)
+	case *mir.Unreachable:
+		g.emitTerminator("  unreachable")
+		return nil
 	default:
 		return fmt.Errorf("unsupported terminator type: %T", term)
 	}
 }
 
-// generateSelect generates LLVM IR for a select statement
+// generateSelect generates LLVM IR for a select statement. The channel cases
+// are packed into an array of %SelectCase records and handed to
+// runtime_select, which blocks until one of them can proceed, runs it, and
+// returns its index (or -1 for default, -2 once the `after` timeout elapses).
 func (g *Generator) generateSelect(stmt *mir.Select) error {
-	// Generate polling loop
-	loopLabel := g.nextReg()                       // actually label
-	loopLabel = strings.TrimPrefix(loopLabel, "%") // remove %
+	label := strings.TrimPrefix(g.nextReg(), "%")
+
+	var channelCases []mir.SelectCase
+	var defaultCase, afterCase *mir.SelectCase
+	for i := range stmt.Cases {
+		switch stmt.Cases[i].Kind {
+		case "send", "recv":
+			channelCases = append(channelCases, stmt.Cases[i])
+		case "default":
+			defaultCase = &stmt.Cases[i]
+		case "after":
+			afterCase = &stmt.Cases[i]
+		}
+	}
 
-	g.emit(fmt.Sprintf("  br label %%%s", loopLabel))
-	g.emit(fmt.Sprintf("%s:", loopLabel))
+	n := len(channelCases)
+	arrayType := fmt.Sprintf("[%d x %%SelectCase]", n)
+	casesReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = alloca %s", casesReg, arrayType))
 
-	// For each case
-	for i, c := range stmt.Cases {
-		nextLabel := fmt.Sprintf("%s_next_%d", loopLabel, i)
+	for i, c := range channelCases {
+		chReg, err := g.generateOperand(c.Channel)
+		if err != nil {
+			return err
+		}
 
-		if c.Kind == "send" {
-			// Get channel
-			chReg, err := g.generateOperand(c.Channel)
-			if err != nil {
-				return err
-			}
+		slot := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = getelementptr %s, %s* %s, i64 0, i64 %d", slot, arrayType, arrayType, casesReg, i))
+		chField := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = getelementptr %%SelectCase, %%SelectCase* %s, i32 0, i32 0", chField, slot))
+		g.emit(fmt.Sprintf("  store %%Channel* %s, %%Channel** %s", chReg, chField))
 
-			// Prepare value pointer
+		valPtr := "null"
+		kind := 1
+		if c.Kind == "send" {
+			kind = 0
 			valReg, err := g.generateOperand(c.Value)
 			if err != nil {
 				return err
 			}
-
-			valType := c.Value.OperandType()
-			valLLVMType, err := g.mapType(valType)
-			if err != nil {
-				return err
-			}
-
-			var valPtr string
-			if isPrimitive(valType) {
-				tempAlloca := g.nextReg()
-				g.emit(fmt.Sprintf("  %s = alloca %s", tempAlloca, valLLVMType))
-				g.emit(fmt.Sprintf("  store %s %s, %s* %s", valLLVMType, valReg, valLLVMType, tempAlloca))
-				valPtr = g.nextReg()
-				g.emit(fmt.Sprintf("  %s = bitcast %s* %s to i8*", valPtr, valLLVMType, tempAlloca))
-			} else {
-				valPtr = g.nextReg()
-				g.emit(fmt.Sprintf("  %s = bitcast %s %s to i8*", valPtr, valLLVMType, valReg))
-			}
-
-			// Call try_send
-			successReg := g.nextReg()
-			g.emit(fmt.Sprintf("  %s = call i8 @runtime_channel_try_send(%%Channel* %s, i8* %s)", successReg, chReg, valPtr))
-
-			// Branch
-			targetLabel, ok := g.blockLabels[c.Target]
-			if !ok {
-				return fmt.Errorf("target block not found")
-			}
-
-			g.emit(fmt.Sprintf("  %s_bool = trunc i8 %s to i1", successReg, successReg))
-			g.emit(fmt.Sprintf("  br i1 %s_bool, label %%%s, label %%%s", successReg, targetLabel, nextLabel))
-
-		} else if c.Kind == "recv" {
-			// Get channel
-			chReg, err := g.generateOperand(c.Channel)
+			// Passed like a plain send: the channel copies elem_size bytes
+			valPtr, err = g.channelValuePtr(c.Value.OperandType(), valReg)
 			if err != nil {
 				return err
 			}
+		}
+		valField := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = getelementptr %%SelectCase, %%SelectCase* %s, i32 0, i32 1", valField, slot))
+		g.emit(fmt.Sprintf("  store i8* %s, i8** %s", valPtr, valField))
+		kindField := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = getelementptr %%SelectCase, %%SelectCase* %s, i32 0, i32 2", kindField, slot))
+		g.emit(fmt.Sprintf("  store i64 %d, i64* %s", kind, kindField))
+	}
 
-			// Prepare result pointer
-			resPtrAlloca := g.nextReg()
-			g.emit(fmt.Sprintf("  %s = alloca i8*", resPtrAlloca))
-
-			// Call try_recv
-			successReg := g.nextReg()
-			g.emit(fmt.Sprintf("  %s = call i8 @runtime_channel_try_recv(%%Channel* %s, i8** %s)", successReg, chReg, resPtrAlloca))
-
-			// Branch
-			targetLabel, ok := g.blockLabels[c.Target]
-			if !ok {
-				return fmt.Errorf("target block not found")
-			}
-
-			successLabel := fmt.Sprintf("%s_success_%d", loopLabel, i)
-			g.emit(fmt.Sprintf("  %s_bool = trunc i8 %s to i1", successReg, successReg))
-			g.emit(fmt.Sprintf("  br i1 %s_bool, label %%%s, label %%%s", successReg, successLabel, nextLabel))
-
-			g.emit(fmt.Sprintf("%s:", successLabel))
-
-			if c.Result != nil {
-				// Load i8* from resPtrAlloca
-				resPtr := g.nextReg()
-				g.emit(fmt.Sprintf("  %s = load i8*, i8** %s", resPtr, resPtrAlloca))
-
-				// Result type
-				resultType := c.Result.Type
-				resultLLVMType, err := g.mapType(resultType)
-				if err != nil {
-					return err
-				}
-
-				// Allocate local (if not already allocated)
-				localReg, ok := g.localRegs[c.Result.ID]
-				if !ok {
-					localReg = g.nextReg()
-					g.emit(fmt.Sprintf("  %s = alloca %s", localReg, resultLLVMType))
-					g.localRegs[c.Result.ID] = localReg
-				}
-				g.localIsValue[c.Result.ID] = false
+	timeoutReg := "-1"
+	if afterCase != nil {
+		reg, err := g.generateOperand(afterCase.Timeout)
+		if err != nil {
+			return err
+		}
+		timeoutReg = reg
+	}
+	hasDefault := 0
+	if defaultCase != nil {
+		hasDefault = 1
+	}
 
-				// Cast and store
-				castPtr := g.nextReg()
-				g.emit(fmt.Sprintf("  %s = bitcast i8* %s to %s*", castPtr, resPtr, resultLLVMType))
+	basePtr := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = getelementptr %s, %s* %s, i64 0, i64 0", basePtr, arrayType, arrayType, casesReg))
+	chosen := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = call i64 @runtime_select(%%SelectCase* %s, i64 %d, i8 %d, i64 %s)", chosen, basePtr, n, hasDefault, timeoutReg))
+
+	// Receives that bind a result go through a block that copies the value
+	// out of the case record before entering the case body
+	var dests []string
+	for i, c := range channelCases {
+		targetLabel, ok := g.blockLabels[c.Target]
+		if !ok {
+			return fmt.Errorf("target block not found")
+		}
+		if c.Kind == "recv" && c.Result != nil {
+			targetLabel = fmt.Sprintf("%s_recv_%d", label, i)
+		}
+		dests = append(dests, fmt.Sprintf("i64 %d, label %%%s", i, targetLabel))
+	}
+	for _, fallback := range []struct {
+		c     *mir.SelectCase
+		index int
+	}{{defaultCase, -1}, {afterCase, -2}} {
+		if fallback.c == nil {
+			continue
+		}
+		targetLabel, ok := g.blockLabels[fallback.c.Target]
+		if !ok {
+			return fmt.Errorf("target block not found")
+		}
+		dests = append(dests, fmt.Sprintf("i64 %d, label %%%s", fallback.index, targetLabel))
+	}
 
-				valReg := g.nextReg()
-				g.emit(fmt.Sprintf("  %s = load %s, %s* %s", valReg, resultLLVMType, resultLLVMType, castPtr))
+	unreachableLabel := label + "_none"
+	g.emitTerminator(fmt.Sprintf("  switch i64 %s, label %%%s [ %s ]", chosen, unreachableLabel, strings.Join(dests, " ")))
+	g.emitLabel(unreachableLabel)
+	g.emitTerminator("  unreachable")
 
-				g.emit(fmt.Sprintf("  store %s %s, %s* %s", resultLLVMType, valReg, resultLLVMType, localReg))
-			}
+	for i, c := range channelCases {
+		if c.Kind != "recv" || c.Result == nil {
+			continue
+		}
+		g.emitLabel(fmt.Sprintf("%s_recv_%d", label, i))
 
-			g.emit(fmt.Sprintf("  br label %%%s", targetLabel))
+		resultLLVMType, err := g.mapType(c.Result.Type)
+		if err != nil {
+			return err
+		}
+		slot := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = getelementptr %s, %s* %s, i64 0, i64 %d, i32 1", slot, arrayType, arrayType, casesReg, i))
+		boxReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = load i8*, i8** %s", boxReg, slot))
+		valReg, err := g.channelReceivedValue(c.Result.Type, boxReg)
+		if err != nil {
+			return err
+		}
 
-		} else if c.Kind == "default" {
-			// Default case always succeeds
-			targetLabel, ok := g.blockLabels[c.Target]
-			if !ok {
-				return fmt.Errorf("target block not found")
-			}
-			g.emit(fmt.Sprintf("  br label %%%s", targetLabel))
+		localReg, ok := g.localRegs[c.Result.ID]
+		if !ok {
+			localReg = g.nextReg()
+			g.emit(fmt.Sprintf("  %s = alloca %s", localReg, resultLLVMType))
+			g.localRegs[c.Result.ID] = localReg
 		}
+		g.localIsValue[c.Result.ID] = false
+		g.emit(fmt.Sprintf("  store %s %s, %s* %s", resultLLVMType, valReg, resultLLVMType, localReg))
 
-		g.emit(fmt.Sprintf("%s:", nextLabel))
+		targetLabel := g.blockLabels[c.Target]
+		g.emitTerminator(fmt.Sprintf("  br label %%%s", targetLabel))
 	}
 
-	// If we fall through all cases (and no default), yield and loop
-	g.emit("  call void @runtime_legion_yield()")
-	g.emit("  call void @runtime_nanosleep(i64 100000)") // 100us
-	g.emit(fmt.Sprintf("  br label %%%s", loopLabel))
-
 	return nil
 }
 
 // generateReturn generates LLVM IR for a return statement
 func (g *Generator) generateReturn(ret *mir.Return, retLLVM string) error {
+	if g.currentFunc != nil && g.currentFunc.Name == "main" {
+		return g.generateMainReturn(ret)
+	}
+
 	if ret.Value == nil {
 		// Void return
 		if retLLVM == "i32" {
 			// Special case for main: return 0
-			g.emit("  ret i32 0")
+			g.emitTerminator("  ret i32 0")
 		} else {
-			g.emit("  ret void")
+			g.emitTerminator("  ret void")
 		}
 		return nil
 	}
@@ -178,9 +192,9 @@ func (g *Generator) generateReturn(ret *mir.Return, retLLVM string) error {
 	if isVoidType(ret.Value.OperandType()) {
 		// Treat as void return
 		if retLLVM == "i32" {
-			g.emit("  ret i32 0")
+			g.emitTerminator("  ret i32 0")
 		} else {
-			g.emit("  ret void")
+			g.emitTerminator("  ret void")
 		}
 		return nil
 	}
@@ -190,8 +204,84 @@ func (g *Generator) generateReturn(ret *mir.Return, retLLVM string) error {
 	if err != nil {
 		return fmt.Errorf("failed to generate return value: %w", err)
 	}
+	if g.currentFunc != nil {
+		if valueReg, err = g.coerceArray(ret.Value, valueReg, g.currentFunc.ReturnType); err != nil {
+			return err
+		}
+	}
+
+	g.emitTerminator(fmt.Sprintf("  ret %s %s", retLLVM, valueReg))
+	return nil
+}
+
+// generateMainReturn converts the value returned from main into the process exit code.
+// Integers are returned as-is (truncated to i32); Result[void, E] exits with 0 for Ok
+// and 1 for Err, reporting the error on stderr first.
+func (g *Generator) generateMainReturn(ret *mir.Return) error {
+	if ret.Value == nil || isVoidType(ret.Value.OperandType()) {
+		g.emitTerminator("  ret i32 0")
+		return nil
+	}
+
+	valType := ret.Value.OperandType()
+	valueReg, err := g.generateOperand(ret.Value)
+	if err != nil {
+		return fmt.Errorf("failed to generate return value: %w", err)
+	}
+
+	enum, args, isResult := types.AsResult(valType)
+	if !isResult {
+		llvmType, err := g.mapType(valType)
+		if err != nil {
+			return err
+		}
+		switch bits := intBits(llvmType); {
+		case bits > 32:
+			truncReg := g.nextReg()
+			g.emit(fmt.Sprintf("  %s = trunc %s %s to i32", truncReg, llvmType, valueReg))
+			valueReg = truncReg
+		case bits < 32:
+			extOp := "sext"
+			if isUnsigned(valType) {
+				extOp = "zext"
+			}
+			extReg := g.nextReg()
+			g.emit(fmt.Sprintf("  %s = %s %s %s to i32", extReg, extOp, llvmType, valueReg))
+			valueReg = extReg
+		}
+		g.emitTerminator(fmt.Sprintf("  ret i32 %s", valueReg))
+		return nil
+	}
 
-	g.emit(fmt.Sprintf("  ret %s %s", retLLVM, valueReg))
+	enumLLVMType := "%enum." + sanitizeName(enum.Name)
+	tagPtrReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = getelementptr inbounds %s, %s* %s, i32 0, i32 0", tagPtrReg, enumLLVMType, enumLLVMType, valueReg))
+	tagReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = load i32, i32* %s", tagReg, tagPtrReg))
+	isErrReg := g.nextReg()
+	g.emit(fmt.Sprintf("  %s = icmp eq i32 %s, %d", isErrReg, tagReg, enum.VariantIndex("Err")))
+
+	label := strings.TrimPrefix(g.nextReg(), "%")
+	errLabel := label + "_main_err"
+	okLabel := label + "_main_ok"
+	g.emitTerminator(fmt.Sprintf("  br i1 %s, label %%%s, label %%%s", isErrReg, errLabel, okLabel))
+
+	g.emitLabel(errLabel)
+	// Only string errors can be printed generically; others are reported without a message
+	msgReg := "null"
+	if len(args) == 2 && isStringType(args[1]) {
+		payloadPtrReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = getelementptr inbounds %s, %s* %s, i32 0, i32 1", payloadPtrReg, enumLLVMType, enumLLVMType, valueReg))
+		castReg := g.nextReg()
+		g.emit(fmt.Sprintf("  %s = bitcast %s* %s to %%String**", castReg, g.enumPayloadType(enum.Name), payloadPtrReg))
+		msgReg = g.nextReg()
+		g.emit(fmt.Sprintf("  %s = load %%String*, %%String** %s", msgReg, castReg))
+	}
+	g.emit(fmt.Sprintf("  call void @runtime_report_main_error(%%String* %s)", msgReg))
+	g.emitTerminator("  ret i32 1")
+
+	g.emitLabel(okLabel)
+	g.emitTerminator("  ret i32 0")
 	return nil
 }
 
@@ -204,7 +294,7 @@ func (g *Generator) generateGoto(gotoTerm *mir.Goto) error {
 		return fmt.Errorf("block label not found for target %s", gotoTerm.Target.Label)
 	}
 
-	g.emit(fmt.Sprintf("  br label %%%s", targetLabel))
+	g.emitTerminator(fmt.Sprintf("  br label %%%s", targetLabel))
 	return nil
 }
 
@@ -258,7 +348,7 @@ func (g *Generator) generateBranch(branch *mir.Branch) error {
 		return fmt.Errorf("block label not found for false target %s", branch.False.Label)
 	}
 
-	g.emit(fmt.Sprintf("  br i1 %s, label %%%s, label %%%s", condReg, trueLabel, falseLabel))
+	g.emitTerminator(fmt.Sprintf("  br i1 %s, label %%%s, label %%%s", condReg, trueLabel, falseLabel))
 	return nil
 }
 
@@ -271,3 +361,9 @@ func isVoidType(t types.Type) bool {
 	}
 	return false
 }
+
+// isStringType checks if a type is the primitive string type
+func isStringType(t types.Type) bool {
+	p, ok := t.(*types.Primitive)
+	return ok && p.Kind == types.String
+}
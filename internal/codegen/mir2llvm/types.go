@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/malphas-lang/malphas-lang/internal/mangle"
 	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
@@ -21,9 +22,10 @@ func (g *Generator) mapType(typ types.Type) (string, error) {
 		return "%struct." + sanitizeName(t.Name) + "*", nil
 
 	case *types.Enum:
-		return "%enum." + sanitizeName(t.Name) + "*", nil
+		return g.mapEnumType(t, t.Name)
 
 	case *types.Array:
+		// Arrays are values, stored inline
 		elemType, err := g.mapType(t.Elem)
 		if err != nil {
 			return "", err
@@ -42,6 +44,9 @@ func (g *Generator) mapType(typ types.Type) (string, error) {
 	case *types.Channel:
 		return "%Channel*", nil
 
+	case *types.JoinHandle:
+		return "%Legion*", nil
+
 	case *types.Pointer:
 		elemType, err := g.mapType(t.Elem)
 		if err != nil {
@@ -116,7 +121,7 @@ func (g *Generator) mapType(typ types.Type) (string, error) {
 		}
 		// Check enum types
 		if g.enumTypes[t.Name] {
-			return "%enum." + sanitizeName(t.Name) + "*", nil
+			return g.mapEnumType(t, t.Name)
 		}
 		// If Ref is nil, it might be a generic parameter or forward declaration.
 		// Use i8* (opaque pointer) to be safe and allow bitcasting.
@@ -126,10 +131,15 @@ func (g *Generator) mapType(typ types.Type) (string, error) {
 		if structType, ok := t.Base.(*types.Struct); ok {
 			return "%struct." + sanitizeName(structType.Name) + "*", nil
 		}
+		if enumType, ok := t.Base.(*types.Enum); ok {
+			return g.mapEnumType(t, enumType.Name)
+		}
 		return g.mapType(t.Base)
 
 	case *types.TypeParam:
-		return "i8*", nil
+		// Generic functions are only generated specialized, with every type
+		// parameter replaced by its argument
+		return "", fmt.Errorf("type parameter %s was not specialized", t.Name)
 
 	default:
 		return "", fmt.Errorf("unsupported type: %T", typ)
@@ -167,31 +177,17 @@ func mapPrimitiveType(kind types.PrimitiveKind) string {
 		return "%String*"
 	case types.Nil:
 		return "i8*"
-	case types.Void:
+	case types.Void, types.Never:
 		return "void"
 	default:
 		return "i64"
 	}
 }
 
-// sanitizeName sanitizes a name for use in LLVM IR
+// sanitizeName turns a MIR name into an LLVM identifier. Distinct names
+// give distinct identifiers; see package mangle.
 func sanitizeName(name string) string {
-	result := make([]rune, 0, len(name))
-	for _, r := range name {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
-			(r >= '0' && r <= '9') || r == '_' || r == '.' {
-			result = append(result, r)
-		} else {
-			result = append(result, '_')
-		}
-	}
-	if len(result) == 0 {
-		return "_"
-	}
-	if result[0] >= '0' && result[0] <= '9' {
-		return "_" + string(result)
-	}
-	return string(result)
+	return mangle.Symbol(name)
 }
 
 // joinTypes joins type strings with a separator
@@ -206,7 +202,20 @@ func joinTypes(types []string, sep string) string {
 	return result
 }
 
-// getFieldType extracts the type of a field from a struct definition
+// fieldLLVMType returns the LLVM type of a struct field, i.e. the pointee of the
+// getelementptr result for that field, or fallback if it cannot be determined.
+func (g *Generator) fieldLLVMType(structType types.Type, fieldName, fallback string) string {
+	fieldType, err := g.getFieldType(structType, fieldName)
+	if err != nil {
+		return fallback
+	}
+	llvmType, err := g.mapType(fieldType)
+	if err != nil {
+		return fallback
+	}
+	return llvmType
+}
+
 // It unwraps pointers, references, and generic instances to find the underlying struct
 func (g *Generator) getFieldType(structType types.Type, fieldName string) (types.Type, error) {
 	if structType == nil {
@@ -264,6 +273,78 @@ func (g *Generator) getElementType(typ types.Type) (types.Type, error) {
 	}
 }
 
+// llvmTypeLayout returns the size and alignment in bytes of an LLVM type as
+// mapType writes it, on a target with ptrSize-byte pointers. Types it does
+// not know, such as a named runtime type used by value, count as
+// pointer-sized.
+func llvmTypeLayout(t string, ptrSize int64) (size, align int64) {
+	t = strings.TrimSpace(t)
+	switch {
+	case t == "void":
+		return 0, 1
+	case strings.HasSuffix(t, "*"):
+		return ptrSize, ptrSize
+	case t == "double":
+		return 8, 8
+	case t == "float":
+		return 4, 4
+	case strings.HasPrefix(t, "i"):
+		var bits int64
+		if _, err := fmt.Sscanf(t, "i%d", &bits); err == nil {
+			size = (bits + 7) / 8
+			align = 1
+			for align < size && align < 16 {
+				align *= 2
+			}
+			return (size + align - 1) / align * align, align
+		}
+	case strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]"):
+		var n int64
+		var elem string
+		if _, err := fmt.Sscanf(t, "[%d x", &n); err == nil {
+			elem = t[strings.Index(t, " x ")+3 : len(t)-1]
+			elemSize, elemAlign := llvmTypeLayout(elem, ptrSize)
+			return n * elemSize, elemAlign
+		}
+	case strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}"):
+		align = 1
+		for _, field := range splitTopLevel(t[1 : len(t)-1]) {
+			fieldSize, fieldAlign := llvmTypeLayout(field, ptrSize)
+			size = (size + fieldAlign - 1) / fieldAlign * fieldAlign
+			size += fieldSize
+			if fieldAlign > align {
+				align = fieldAlign
+			}
+		}
+		return (size + align - 1) / align * align, align
+	}
+	return ptrSize, ptrSize
+}
+
+// splitTopLevel splits a list of LLVM types at the commas outside any
+// brackets or braces
+func splitTopLevel(list string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range list {
+		switch c {
+		case '{', '[', '(':
+			depth++
+		case '}', ']', ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(list[start:]) != "" {
+		parts = append(parts, list[start:])
+	}
+	return parts
+}
+
 // calculateElementSize calculates the size in bytes of an element type
 // Returns the size as a string (either a constant like "8" or a register name)
 // If the size needs to be calculated at runtime, it emits LLVM IR and returns the register name
@@ -285,12 +366,12 @@ func (g *Generator) calculateElementSize(elemType types.Type) (string, error) {
 		case types.Float:
 			return "8", nil // double
 		case types.String:
-			return "8", nil // pointer size
+			return g.pointerSize(), nil
 		default:
-			return "8", nil // default to pointer size
+			return g.pointerSize(), nil // default to pointer size
 		}
 	case *types.Pointer, *types.Reference, *types.Optional:
-		return "8", nil // pointer size on 64-bit
+		return g.pointerSize(), nil
 	case *types.Struct, *types.Enum, *types.Map, *types.Channel, *types.Function:
 		// For complex types, calculate size using LLVM's getelementptr trick
 		llvmType, err := g.mapType(elemType)
@@ -306,8 +387,8 @@ func (g *Generator) calculateElementSize(elemType types.Type) (string, error) {
 		g.emit(fmt.Sprintf("  %s = ptrtoint %s* %s to i64", sizeReg, baseType, gepReg))
 		return sizeReg, nil
 	case *types.Array:
-		// For arrays, calculate element size * length
-		elemSize, err := g.calculateElementSize(t.Elem)
+		// For arrays, the size of the element slots times the length
+		elemSize, err := g.sliceElementSize(t.Elem)
 		if err != nil {
 			return "", err
 		}
@@ -325,7 +406,7 @@ func (g *Generator) calculateElementSize(elemType types.Type) (string, error) {
 		return "24", nil // Slice struct: data (8) + len (8) + cap (8) + elem_size (8)
 	case *types.Tuple:
 		// For tuples, use a reasonable default (could be improved)
-		return "8", nil
+		return g.pointerSize(), nil
 	case *types.Named:
 		if t.Ref != nil {
 			return g.calculateElementSize(t.Ref)
@@ -343,15 +424,32 @@ func (g *Generator) calculateElementSize(elemType types.Type) (string, error) {
 		case "bool":
 			return "1", nil
 		case "string":
-			return "8", nil
+			return g.pointerSize(), nil
 		default:
-			return "8", nil // default to pointer size
+			return g.pointerSize(), nil // default to pointer size
 		}
 	case *types.GenericInstance:
 		return g.calculateElementSize(t.Base)
 	default:
-		return "8", nil // default to pointer size
+		return g.pointerSize(), nil // default to pointer size
+	}
+}
+
+// pointerSize returns the size of a pointer on the target, as a constant
+// for calculateElementSize
+func (g *Generator) pointerSize() string {
+	return fmt.Sprintf("%d", g.Target.PointerSize())
+}
+
+// sliceElementSize returns the size of an element slot in a slice of
+// elemType: the size of the LLVM value, so a pointer's for the pointer to a
+// struct, enum or other heap object rather than the object's own size
+func (g *Generator) sliceElementSize(elemType types.Type) (string, error) {
+	llvmType, err := g.mapType(elemType)
+	if err == nil && strings.HasSuffix(llvmType, "*") {
+		return g.pointerSize(), nil
 	}
+	return g.calculateElementSize(elemType)
 }
 
 // calculateAlignment calculates the alignment in bytes of a type
@@ -452,7 +550,7 @@ func getFloatSize(t types.Type) int {
 // isPointer checks if a type is a pointer type
 func isPointer(t types.Type) bool {
 	switch p := t.(type) {
-	case *types.Pointer, *types.Reference, *types.Optional, *types.Struct, *types.Enum, *types.Map, *types.Channel, *types.Function, *types.Slice:
+	case *types.Pointer, *types.Reference, *types.Optional, *types.Struct, *types.Enum, *types.Map, *types.Channel, *types.JoinHandle, *types.Function, *types.Slice:
 		return true
 	case *types.Primitive:
 		// String is primitive but handled as pointer
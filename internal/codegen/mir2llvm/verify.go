@@ -0,0 +1,125 @@
+package mir2llvm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/mangle"
+)
+
+var (
+	// opt/llc parse errors: "opt: <stdin>:12:3: error: use of undefined value '%y'",
+	// or with the name of the .ll file the IR was read from
+	llvmLocatedError = regexp.MustCompile(`(?:\.ll|<stdin>):(\d+):\d+: error: (.*)$`)
+	// Verifier messages that name the function, e.g. "... in function 'main' ..."
+	llvmFunctionName = regexp.MustCompile(`function '([^']+)'`)
+	llvmDefineName   = regexp.MustCompile(`^define [^@]*@([^\s(]+)\(`)
+)
+
+// DiagnoseInvalidIR turns the output of an LLVM tool that rejected ir into
+// an internal compiler error. The IR line at fault is located from the
+// tool's line number, or from the instruction the verifier quotes, and the
+// diagnostic points at the Malphas function whose code produced it.
+func (g *Generator) DiagnoseInvalidIR(ir, toolOutput string) diag.Diagnostic {
+	irLines := strings.Split(ir, "\n")
+
+	message := ""
+	line := 0
+	fnName := ""
+	for _, out := range strings.Split(toolOutput, "\n") {
+		if out == "" {
+			continue
+		}
+		if m := llvmLocatedError.FindStringSubmatch(out); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			message = m[2]
+			break
+		}
+		if strings.HasPrefix(out, "  ") {
+			// The verifier quotes offending instructions verbatim
+			if line == 0 {
+				line = findIRLine(irLines, out)
+			}
+			continue
+		}
+		if m := llvmFunctionName.FindStringSubmatch(out); m != nil && fnName == "" {
+			fnName = m[1]
+		}
+		if message == "" && !strings.HasPrefix(out, "opt:") && !strings.HasPrefix(out, "llc:") {
+			message = out
+		}
+	}
+	if message == "" {
+		message = strings.TrimSpace(toolOutput)
+	}
+
+	d := diag.Diagnostic{
+		Stage:    diag.StageCodegen,
+		Severity: diag.SeverityError,
+		Code:     diag.CodeGenInvalidIR,
+		Help:     "this is a bug in the Malphas compiler; set MALPHAS_DEBUG_IR=1 to print the generated IR",
+	}
+
+	block := ""
+	if line > 0 && line <= len(irLines) {
+		var definedIn string
+		definedIn, block = enclosingFunction(irLines, line)
+		if fnName == "" {
+			fnName = definedIn
+		}
+		d.Notes = append(d.Notes, fmt.Sprintf("at LLVM IR line %d: %s", line, strings.TrimSpace(irLines[line-1])))
+	}
+
+	if fnName == "" {
+		d.Message = fmt.Sprintf("internal compiler error: generated invalid LLVM IR: %s", message)
+		return d
+	}
+
+	d.Message = fmt.Sprintf("internal compiler error: generated invalid LLVM IR for `%s`: %s", mangle.Demangle(fnName), message)
+	if block != "" {
+		d.Notes = append([]string{fmt.Sprintf("in block `%s`", block)}, d.Notes...)
+	}
+	if fn, ok := g.functions[fnName]; ok && fn.Span.Line > 0 {
+		d.Span = diag.Span{
+			Filename: fn.Span.Filename,
+			Line:     fn.Span.Line,
+			Column:   fn.Span.Column,
+			Start:    fn.Span.Start,
+			End:      fn.Span.End,
+		}
+		d.LabeledSpans = []diag.LabeledSpan{{Span: d.Span, Label: "while compiling this function", Style: "primary"}}
+	}
+	return d
+}
+
+// findIRLine returns the 1-based line of ir equal to quoted, or 0
+func findIRLine(ir []string, quoted string) int {
+	quoted = strings.TrimSpace(quoted)
+	for i, l := range ir {
+		if strings.TrimSpace(l) == quoted {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// enclosingFunction returns the LLVM name of the function containing the
+// 1-based line, and the label of the block it is in
+func enclosingFunction(ir []string, line int) (fn, block string) {
+	for i := line - 1; i >= 0; i-- {
+		l := ir[i]
+		if block == "" && strings.HasSuffix(l, ":") && !strings.HasPrefix(l, " ") {
+			block = strings.TrimSuffix(l, ":")
+		}
+		if strings.HasPrefix(l, "}") {
+			return "", ""
+		}
+		if m := llvmDefineName.FindStringSubmatch(l); m != nil {
+			return m[1], block
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,72 @@
+package mir2llvm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+const invalidIR = `define i32 @add_one(i32 %a) {
+entry:
+  %x = add i32 %a, 1
+  ret i32 %y
+}
+
+define i32 @pick(i1 %c) {
+entry:
+  br i1 %c, label %a, label %b
+a:
+  %z = add i32 1, 2
+  br label %b
+b:
+  ret i32 %z
+}
+`
+
+func TestDiagnoseInvalidIR_LocatedError(t *testing.T) {
+	gen := NewGenerator()
+	gen.functions["add_one"] = &mir.Function{Name: "add_one", Span: lexer.Span{Filename: "main.mal", Line: 3, Column: 1}}
+
+	d := gen.DiagnoseInvalidIR(invalidIR, "opt: <stdin>:4:11: error: use of undefined value '%y'\n  ret i32 %y\n          ^\n")
+
+	if d.Code != diag.CodeGenInvalidIR {
+		t.Errorf("expected code %s, got %s", diag.CodeGenInvalidIR, d.Code)
+	}
+	if !strings.Contains(d.Message, "`add_one`") || !strings.Contains(d.Message, "use of undefined value '%y'") {
+		t.Errorf("unexpected message: %s", d.Message)
+	}
+	if d.Span.Filename != "main.mal" || d.Span.Line != 3 {
+		t.Errorf("expected span at main.mal:3, got %s", d.Span)
+	}
+	if len(d.Notes) != 2 || d.Notes[0] != "in block `entry`" || !strings.Contains(d.Notes[1], "ret i32 %y") {
+		t.Errorf("unexpected notes: %q", d.Notes)
+	}
+}
+
+func TestDiagnoseInvalidIR_QuotedInstruction(t *testing.T) {
+	gen := NewGenerator()
+	out := "Instruction does not dominate all uses!\n  %z = add i32 1, 2\n  ret i32 %z\nopt: /tmp/malphas_1.ll: error: input module is broken!\n"
+
+	d := gen.DiagnoseInvalidIR(invalidIR, out)
+
+	if !strings.Contains(d.Message, "`pick`: Instruction does not dominate all uses!") {
+		t.Errorf("unexpected message: %s", d.Message)
+	}
+	if len(d.Notes) == 0 || d.Notes[0] != "in block `a`" {
+		t.Errorf("expected the quoted instruction to be located in block a, got %q", d.Notes)
+	}
+}
+
+func TestDiagnoseInvalidIR_DemanglesFunctionName(t *testing.T) {
+	gen := NewGenerator()
+	out := "Call parameter type does not match function signature!\n  call void @Point.new$int(i64 1)\nin function 'Point.new$int'\n"
+
+	d := gen.DiagnoseInvalidIR(invalidIR, out)
+
+	if !strings.Contains(d.Message, "`Point::new$int`") {
+		t.Errorf("expected the Malphas name of the function, got: %s", d.Message)
+	}
+}
@@ -47,6 +47,7 @@ const (
 	// Type checker errors
 	CodeTypeUndefinedIdentifier    Code = "TYPE_UNDEFINED_IDENTIFIER"
 	CodeTypeMismatch               Code = "TYPE_MISMATCH"
+	CodeTypeNumericMismatch        Code = "TYPE_NUMERIC_MISMATCH"
 	CodeTypeCannotAssign           Code = "TYPE_CANNOT_ASSIGN"
 	CodeTypeInvalidOperation       Code = "TYPE_INVALID_OPERATION"
 	CodeTypeMissingField           Code = "TYPE_MISSING_FIELD"
@@ -57,10 +58,19 @@ const (
 	CodeTypeMissingAssociatedType  Code = "TYPE_MISSING_ASSOCIATED_TYPE"
 	CodeTypeUnknownAssociatedType  Code = "TYPE_UNKNOWN_ASSOCIATED_TYPE"
 	CodeTypeBorrowConflict         Code = "TYPE_BORROW_CONFLICT"
+	CodeTypeTemporaryBorrow        Code = "TYPE_TEMPORARY_BORROW"
+	CodeTypeUseAfterMove           Code = "TYPE_USE_AFTER_MOVE"
 	CodeTypeUnsafeRequired         Code = "TYPE_UNSAFE_REQUIRED"
 	CodeTypeInvalidPattern         Code = "TYPE_INVALID_PATTERN"
 	CodeTypeNonExhaustiveMatch     Code = "TYPE_NON_EXHAUSTIVE_MATCH"
+	CodeTypeUnsyncedCapture        Code = "TYPE_UNSYNCED_CAPTURE"
 	CodeUnreachableCode            Code = "UNREACHABLE_CODE"
+	CodeUnusedVariable             Code = "UNUSED_VARIABLE"
+	CodeShadowedVariable           Code = "SHADOWED_VARIABLE"
+	CodeDeprecated                 Code = "DEPRECATED"
+	CodeTypeInvalidAttribute       Code = "TYPE_INVALID_ATTRIBUTE"
+	CodeTypeNotFFISafe             Code = "TYPE_NOT_FFI_SAFE"
+	CodeTypePrivateItem            Code = "TYPE_PRIVATE_ITEM"
 
 	// Codegen errors
 	CodeGenUnsupportedExpr      Code = "CODEGEN_UNSUPPORTED_EXPR"
@@ -79,6 +89,9 @@ const (
 	CodeGenControlFlowError     Code = "CODEGEN_CONTROL_FLOW_ERROR"
 	CodeGenFormatStringError    Code = "CODEGEN_FORMAT_STRING_ERROR"
 	CodeGenInvalidOperation     Code = "CODEGEN_INVALID_OPERATION"
+	CodeGenInvalidIR            Code = "CODEGEN_INVALID_IR"
+	CodeGenTailCall             Code = "CODEGEN_TAIL_CALL"
+	CodeGenInstantiationDepth   Code = "CODEGEN_INSTANTIATION_DEPTH"
 )
 
 // Span represents a location in source code.
@@ -103,6 +116,20 @@ func (s Span) IsValid() bool {
 	return s.Line > 0 && s.Column > 0
 }
 
+// Edit replaces the text of Span, located by its Start and End offsets,
+// with NewText. An empty span inserts NewText at Start.
+type Edit struct {
+	Span    Span
+	NewText string
+}
+
+// Fix is a machine-applicable change that resolves a diagnostic, such as
+// adding an import or renaming a variable.
+type Fix struct {
+	Message string // What the fix does, e.g. "Prefix `x` with an underscore"
+	Edits   []Edit
+}
+
 // Diagnostic is a compiler diagnostic surfaced to end-users.
 type Diagnostic struct {
 	Stage      Stage
@@ -118,6 +145,7 @@ type Diagnostic struct {
 	Notes        []string    // Additional notes to display
 	Help         string      // Help text (alternative to Suggestion, can include code)
 	ProofChain   []ProofStep // Proof chain showing the reasoning that led to this error
+	Fixes        []Fix       // Edits that resolve the diagnostic, for tools to apply
 }
 
 // WithSuggestion returns a new diagnostic with the given suggestion.
@@ -176,6 +204,12 @@ func (d Diagnostic) WithProofStep(message string, span Span) Diagnostic {
 	return d
 }
 
+// WithFix adds a fix made of the given edits.
+func (d Diagnostic) WithFix(message string, edits ...Edit) Diagnostic {
+	d.Fixes = append(d.Fixes, Fix{Message: message, Edits: edits})
+	return d
+}
+
 // WithProofChain adds multiple proof steps at once.
 func (d Diagnostic) WithProofChain(steps []ProofStep) Diagnostic {
 	d.ProofChain = append(d.ProofChain, steps...)
@@ -0,0 +1,66 @@
+package diag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ApplyEdits returns src with edits applied. Edits are located by their
+// character offsets and must not overlap.
+func ApplyEdits(src string, edits []Edit) string {
+	sorted := append([]Edit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Span.Start > sorted[j].Span.Start })
+
+	runes := []rune(src)
+	for _, e := range sorted {
+		start, end := clamp(e.Span.Start, len(runes)), clamp(e.Span.End, len(runes))
+		if end < start {
+			end = start
+		}
+		runes = append(runes[:start], append([]rune(e.NewText), runes[end:]...)...)
+	}
+	return string(runes)
+}
+
+func clamp(n, limit int) int {
+	return max(0, min(n, limit))
+}
+
+// FixDiff renders the lines of src that edits change as a diff snippet:
+// the old lines prefixed with `-` and the new ones with `+`, numbered as in
+// the file before and after the edits. Lines the edits leave alone are not
+// shown. It returns "" when the edits change nothing.
+func FixDiff(src string, edits []Edit) string {
+	oldLines := strings.Split(src, "\n")
+	newLines := strings.Split(ApplyEdits(src, edits), "\n")
+
+	// Only the lines between the first and last differing ones are shown
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	removed := oldLines[prefix : len(oldLines)-suffix]
+	added := newLines[prefix : len(newLines)-suffix]
+	if len(removed) == 0 && len(added) == 0 {
+		return ""
+	}
+
+	last := prefix + max(len(removed), len(added))
+	width := len(fmt.Sprintf("%d", last))
+	var b strings.Builder
+	fmt.Fprintf(&b, " %s |\n", strings.Repeat(" ", width))
+	for i, line := range removed {
+		fmt.Fprintf(&b, " %*d - %s\n", width, prefix+1+i, line)
+	}
+	for i, line := range added {
+		fmt.Fprintf(&b, " %*d + %s\n", width, prefix+1+i, line)
+	}
+	fmt.Fprintf(&b, " %s |\n", strings.Repeat(" ", width))
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package diag_test
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestFixDiff(t *testing.T) {
+	src := "fn main() {\n    let é = totl;\n}\n"
+
+	tests := []struct {
+		name  string
+		edits []diag.Edit
+		want  string
+	}{
+		{
+			name:  "replacement",
+			edits: []diag.Edit{{Span: diag.Span{Start: 24, End: 28}, NewText: "total"}},
+			want: "   |\n" +
+				" 2 -     let é = totl;\n" +
+				" 2 +     let é = total;\n" +
+				"   |\n",
+		},
+		{
+			name:  "insertion",
+			edits: []diag.Edit{{Span: diag.Span{Start: 0, End: 0}, NewText: "use std::io;\n\n"}},
+			want: "   |\n" +
+				" 1 + use std::io;\n" +
+				" 2 + \n" +
+				"   |\n",
+		},
+		{
+			name:  "no change",
+			edits: []diag.Edit{{Span: diag.Span{Start: 24, End: 28}, NewText: "totl"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diag.FixDiff(src, tt.edits); got != tt.want {
+				t.Errorf("FixDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
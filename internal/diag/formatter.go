@@ -2,6 +2,7 @@ package diag
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
@@ -10,13 +11,100 @@ import (
 // Formatter formats diagnostics in a Rust-style format with source code snippets.
 type Formatter struct {
 	sourceCache map[string]string // Cache of source files by filename
+
+	out          io.Writer
+	color        bool // Whether to use ANSI colors
+	contextLines int  // Lines shown around the spans
+	maxWidth     int  // Max width of a rendered source line, 0 for no limit
+	short        bool // Whether to print one line per diagnostic
+}
+
+// FormatterOption configures a Formatter.
+type FormatterOption func(*Formatter)
+
+// WithOutput sets where diagnostics are printed, stderr by default.
+func WithOutput(w io.Writer) FormatterOption {
+	return func(f *Formatter) {
+		f.out = w
+	}
+}
+
+// WithColor turns ANSI colors on or off. They are off by default.
+func WithColor(color bool) FormatterOption {
+	return func(f *Formatter) {
+		f.color = color
+	}
+}
+
+// WithContextLines sets how many lines are shown before and after the
+// lines of the spans, 2 by default.
+func WithContextLines(n int) FormatterOption {
+	return func(f *Formatter) {
+		f.contextLines = max(0, n)
+	}
+}
+
+// WithMaxWidth cuts rendered lines, line numbers included, to width
+// columns. Lines are cut on the right, unless that would hide the spans on
+// them. Zero means no limit.
+func WithMaxWidth(width int) FormatterOption {
+	return func(f *Formatter) {
+		f.maxWidth = max(0, width)
+	}
+}
+
+// WithShort prints each diagnostic on a single line, in the form
+// `file:line:column: severity[code]: message`.
+func WithShort(short bool) FormatterOption {
+	return func(f *Formatter) {
+		f.short = short
+	}
 }
 
 // NewFormatter creates a new diagnostic formatter.
-func NewFormatter() *Formatter {
-	return &Formatter{
-		sourceCache: make(map[string]string),
+func NewFormatter(opts ...FormatterOption) *Formatter {
+	f := &Formatter{
+		sourceCache:  make(map[string]string),
+		out:          os.Stderr,
+		contextLines: 2,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// ANSI styles of the parts of a diagnostic
+const (
+	styleError     = "\x1b[1;31m"
+	styleWarning   = "\x1b[1;33m"
+	styleNote      = "\x1b[1;32m"
+	styleHelp      = "\x1b[1;36m"
+	styleGutter    = "\x1b[1;34m"
+	styleBold      = "\x1b[1m"
+	styleReset     = "\x1b[0m"
+	styleRemoved   = "\x1b[31m"
+	styleAdded     = "\x1b[32m"
+	styleSecondary = styleGutter
+)
+
+// paint wraps s in style when colors are on.
+func (f *Formatter) paint(style, s string) string {
+	if !f.color || s == "" {
+		return s
+	}
+	return style + s + styleReset
+}
+
+// severityStyle returns the style of a severity's name.
+func severityStyle(severity string) string {
+	switch severity {
+	case "warning":
+		return styleWarning
+	case "note", "info":
+		return styleNote
 	}
+	return styleError
 }
 
 // LoadSource loads source code for a file (cached).
@@ -36,8 +124,19 @@ func (f *Formatter) LoadSource(filename string) (string, error) {
 	return src, nil
 }
 
+// SetSource sets the source code of a file, replacing any cached copy, for
+// files rewritten while the formatter is in use.
+func (f *Formatter) SetSource(filename, src string) {
+	f.sourceCache[filename] = src
+}
+
 // Format formats and prints a diagnostic in Rust-style format.
 func (f *Formatter) Format(d Diagnostic) {
+	if f.short {
+		f.formatShort(d)
+		return
+	}
+
 	// Build list of spans to display
 	spans := f.collectSpans(d)
 	if len(spans) == 0 {
@@ -88,16 +187,32 @@ func (f *Formatter) collectSpans(d Diagnostic) []LabeledSpan {
 
 // printHeader prints the error header (error[E0000]: message).
 func (f *Formatter) printHeader(d Diagnostic) {
+	fmt.Fprintf(f.out, "%s: %s\n", f.severityTag(d), f.paint(styleBold, d.Message))
+}
+
+// severityTag returns the severity of d with its code, e.g. `error[E0000]`.
+func (f *Formatter) severityTag(d Diagnostic) string {
 	severity := string(d.Severity)
 	if severity == "" {
 		severity = "error"
 	}
-
 	if d.Code != "" {
-		fmt.Fprintf(os.Stderr, "%s[%s]: %s\n", severity, d.Code, d.Message)
-	} else {
-		fmt.Fprintf(os.Stderr, "%s: %s\n", severity, d.Message)
+		severity += "[" + string(d.Code) + "]"
+	}
+	return f.paint(severityStyle(string(d.Severity)), severity)
+}
+
+// formatShort prints d on one line, prefixed with its location if it has
+// one, for tools that read diagnostics line by line.
+func (f *Formatter) formatShort(d Diagnostic) {
+	spans := f.collectSpans(d)
+	message := strings.ReplaceAll(d.Message, "\n", " ")
+	if len(spans) > 0 && spans[0].Span.IsValid() {
+		s := spans[0].Span
+		fmt.Fprintf(f.out, "%s:%d:%d: %s: %s\n", s.Filename, s.Line, s.Column, f.severityTag(d), message)
+		return
 	}
+	fmt.Fprintf(f.out, "%s: %s\n", f.severityTag(d), message)
 }
 
 // printFileSpans prints source code with underlines for spans in a file.
@@ -136,18 +251,18 @@ func (f *Formatter) printFileSpans(filename string, src string, spans []LabeledS
 	startLine := lineNumbers[0]
 	endLine := lineNumbers[len(lineNumbers)-1]
 
-	// Add context lines (2 before, 2 after)
-	contextStart := max(1, startLine-2)
-	contextEnd := min(maxLine, endLine+2)
+	// Add context lines before and after
+	contextStart := max(1, startLine-f.contextLines)
+	contextEnd := min(maxLine, endLine+f.contextLines)
 
 	// Calculate padding for line numbers
 	lineNumWidth := len(fmt.Sprintf("%d", contextEnd))
 
 	// Print file path
-	fmt.Fprintf(os.Stderr, "  --> %s\n", filename)
+	fmt.Fprintf(f.out, "  %s %s\n", f.paint(styleGutter, "-->"), filename)
 
 	// Print line numbers and code
-	fmt.Fprintf(os.Stderr, "   %s |\n", strings.Repeat(" ", lineNumWidth))
+	f.printGutter(lineNumWidth)
 
 	// Track which lines have primary spans
 	hasPrimary := make(map[int]bool)
@@ -165,23 +280,79 @@ func (f *Formatter) printFileSpans(filename string, src string, spans []LabeledS
 		}
 
 		// Print line number and code (right-align line numbers)
-		lineNumStr := fmt.Sprintf("%*d", lineNumWidth, lineNum)
-		fmt.Fprintf(os.Stderr, " %s | %s\n", lineNumStr, lineContent)
+		w := f.window(lineNumWidth, lineContent, lineSpans)
+		lineNumStr := fmt.Sprintf("%*d |", lineNumWidth, lineNum)
+		fmt.Fprintf(f.out, " %s %s\n", f.paint(styleGutter, lineNumStr), w.cut(lineContent))
 
 		// Print underlines for spans on this line
 		if len(lineSpans) > 0 {
-			f.printUnderlines(lineNumWidth, lineContent, lineSpans, hasPrimary[lineNum])
+			f.printUnderlines(lineNumWidth, w.cut(lineContent), w, lineSpans, hasPrimary[lineNum])
 		}
 	}
 
 	// Print closing separator
-	fmt.Fprintf(os.Stderr, "   %s |\n", strings.Repeat(" ", lineNumWidth))
+	f.printGutter(lineNumWidth)
 }
 
-// printUnderlines prints underlines (^) for spans on a line.
-func (f *Formatter) printUnderlines(lineNumWidth int, lineContent string, spans []LabeledSpan, hasPrimary bool) {
+// printGutter prints an empty line of the gutter
+func (f *Formatter) printGutter(lineNumWidth int) {
+	fmt.Fprintf(f.out, " %s %s\n", strings.Repeat(" ", lineNumWidth), f.paint(styleGutter, "|"))
+}
+
+// lineWindow is the part of a source line that is rendered: bytes
+// [start, end) of it, with `...` marking the cut ends.
+type lineWindow struct {
+	start, end int
+	length     int // Length of the whole line
+}
+
+// ellipsis marks the cut ends of a line
+const ellipsis = "..."
+
+// window returns the part of line to render within the max width. When the
+// spans on the line do not fit in its first columns, the window starts a
+// little before them.
+func (f *Formatter) window(lineNumWidth int, line string, spans []LabeledSpan) lineWindow {
+	w := lineWindow{end: len(line), length: len(line)}
+	avail := f.maxWidth - (lineNumWidth + 4) // After " N | "
+	if f.maxWidth == 0 || len(line) <= avail {
+		return w
+	}
+	avail = max(avail-2*len(ellipsis), 1)
+
+	spanStart, spanEnd := len(line), 0
+	for _, span := range spans {
+		spanStart = min(spanStart, span.Span.Column-1)
+		spanEnd = max(spanEnd, span.Span.Column-1+max(1, span.Span.End-span.Span.Start))
+	}
+	if len(spans) > 0 && spanEnd > avail {
+		w.start = min(max(0, spanStart-4), len(line))
+	}
+	w.end = min(len(line), w.start+avail)
+	return w
+}
+
+// cut returns the rendered part of s, which is the line or its underline.
+func (w lineWindow) cut(s string) string {
+	if w.start == 0 && w.end >= w.length {
+		return s
+	}
+	var b strings.Builder
+	if w.start > 0 {
+		b.WriteString(ellipsis)
+	}
+	b.WriteString(s[min(w.start, len(s)):min(w.end, len(s))])
+	if w.end < w.length {
+		b.WriteString(ellipsis)
+	}
+	return b.String()
+}
+
+// printUnderlines prints underlines (^) for spans on a line. lineContent
+// is the rendered part of the line, w.
+func (f *Formatter) printUnderlines(lineNumWidth int, lineContent string, w lineWindow, spans []LabeledSpan, hasPrimary bool) {
 	// Build underline string
-	underline := make([]byte, len(lineContent))
+	underline := make([]byte, w.length)
 	for i := range underline {
 		underline[i] = ' '
 	}
@@ -215,23 +386,21 @@ func (f *Formatter) printUnderlines(lineNumWidth int, lineContent string, spans
 		}
 	}
 
-	// Find the rightmost underline to determine where labels go
-	rightmost := -1
-	for i := len(underline) - 1; i >= 0; i-- {
-		if underline[i] != ' ' {
-			rightmost = i
-			break
-		}
+	// Cut the underline like the line, and find its rightmost mark to
+	// determine where labels go
+	rendered := string(underline[min(w.start, len(underline)):min(w.end, len(underline))])
+	if w.start > 0 {
+		rendered = strings.Repeat(" ", len(ellipsis)) + rendered
 	}
+	rendered = strings.TrimRight(rendered, " ")
+	rightmost := len(rendered) - 1
 
 	if rightmost == -1 {
 		return
 	}
 
 	// Print underlines
-	underlineStr := string(underline)
-	fmt.Fprintf(os.Stderr, "   %s | %s", strings.Repeat(" ", lineNumWidth), underlineStr)
-
+	fmt.Fprintf(f.out, " %s %s %s", strings.Repeat(" ", lineNumWidth), f.paint(styleGutter, "|"), f.paintUnderline(rendered))
 	// Collect and print labels
 	primaryLabel := ""
 	secondaryLabels := []string{}
@@ -247,14 +416,14 @@ func (f *Formatter) printUnderlines(lineNumWidth int, lineContent string, spans
 
 	// Print primary label inline
 	if primaryLabel != "" {
-		fmt.Fprintf(os.Stderr, " %s", primaryLabel)
+		fmt.Fprintf(f.out, " %s", f.paint(styleError, primaryLabel))
 	}
 
-	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(f.out, "\n")
 
 	// Print secondary labels on separate lines
 	for _, label := range secondaryLabels {
-		fmt.Fprintf(os.Stderr, "   %s |", strings.Repeat(" ", lineNumWidth))
+		fmt.Fprintf(f.out, " %s %s", strings.Repeat(" ", lineNumWidth), f.paint(styleGutter, "|"))
 		// Calculate position for secondary label (at end of line or after content)
 		labelPos := len(lineContent) + 1
 		if labelPos < rightmost+2 {
@@ -262,56 +431,132 @@ func (f *Formatter) printUnderlines(lineNumWidth int, lineContent string, spans
 		}
 		// Add spaces to align with the label position
 		if labelPos > len(lineContent) {
-			fmt.Fprintf(os.Stderr, "%s", strings.Repeat(" ", labelPos-len(lineContent)))
+			fmt.Fprintf(f.out, "%s", strings.Repeat(" ", labelPos-len(lineContent)))
 		}
-		fmt.Fprintf(os.Stderr, " %s\n", label)
+		fmt.Fprintf(f.out, " %s\n", f.paint(styleSecondary, label))
 	}
 }
 
+// paintUnderline colors the `^` marks of an underline like errors and the
+// `~` marks like secondary spans.
+func (f *Formatter) paintUnderline(underline string) string {
+	if !f.color {
+		return underline
+	}
+	var b strings.Builder
+	for i := 0; i < len(underline); {
+		j := i
+		for j < len(underline) && underline[j] == underline[i] {
+			j++
+		}
+		switch underline[i] {
+		case '^':
+			b.WriteString(f.paint(styleError, underline[i:j]))
+		case '~':
+			b.WriteString(f.paint(styleSecondary, underline[i:j]))
+		default:
+			b.WriteString(underline[i:j])
+		}
+		i = j
+	}
+	return b.String()
+}
+
 // printHelp prints help text and suggestions.
 func (f *Formatter) printHelp(d Diagnostic) {
 	// Print proof chain first (shows the reasoning)
 	if len(d.ProofChain) > 0 {
 		for _, step := range d.ProofChain {
-			fmt.Fprintf(os.Stderr, "\n")
+			fmt.Fprintf(f.out, "\n")
 			if step.Span.IsValid() {
-				fmt.Fprintf(os.Stderr, "  = note: %s\n", step.Message)
-				fmt.Fprintf(os.Stderr, "           at %s\n", step.Span.String())
+				fmt.Fprintf(f.out, "  = %s %s\n", f.paint(styleBold, "note:"), step.Message)
+				fmt.Fprintf(f.out, "           at %s\n", step.Span.String())
 			} else {
-				fmt.Fprintf(os.Stderr, "  = note: %s\n", step.Message)
+				fmt.Fprintf(f.out, "  = %s %s\n", f.paint(styleBold, "note:"), step.Message)
 			}
 		}
 	}
 
 	// Print notes
 	for _, note := range d.Notes {
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "  = note: %s\n", note)
+		fmt.Fprintf(f.out, "\n")
+		fmt.Fprintf(f.out, "  = %s %s\n", f.paint(styleBold, "note:"), note)
 	}
 
 	// Print help (preferred over suggestion)
 	if d.Help != "" {
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "help: %s\n", d.Help)
+		fmt.Fprintf(f.out, "\n")
+		fmt.Fprintf(f.out, "%s %s\n", f.paint(styleHelp, "help:"), d.Help)
 	} else if d.Suggestion != "" {
-		fmt.Fprintf(os.Stderr, "\n")
-		fmt.Fprintf(os.Stderr, "help: %s\n", d.Suggestion)
+		fmt.Fprintf(f.out, "\n")
+		fmt.Fprintf(f.out, "%s %s\n", f.paint(styleHelp, "help:"), d.Suggestion)
 	}
 
 	// Print related spans (old format, for backward compatibility)
 	for _, related := range d.Related {
 		if related.IsValid() {
-			fmt.Fprintf(os.Stderr, "\n")
-			fmt.Fprintf(os.Stderr, "  = note: related location at %s\n", related.String())
+			fmt.Fprintf(f.out, "\n")
+			fmt.Fprintf(f.out, "  = %s related location at %s\n", f.paint(styleBold, "note:"), related.String())
+		}
+	}
+
+	f.printFixes(d)
+}
+
+// printFixes prints each fix of the diagnostic as a diff of the lines it
+// changes. Fixes spanning several files, or in files that cannot be read,
+// are left out.
+func (f *Formatter) printFixes(d Diagnostic) {
+	for _, fix := range d.Fixes {
+		if len(fix.Edits) == 0 {
+			continue
+		}
+		filename := fix.Edits[0].Span.Filename
+		sameFile := true
+		for _, e := range fix.Edits {
+			sameFile = sameFile && e.Span.Filename == filename
+		}
+		src, err := f.LoadSource(filename)
+		if !sameFile || err != nil || src == "" {
+			continue
+		}
+		if diff := FixDiff(src, fix.Edits); diff != "" {
+			fmt.Fprintf(f.out, "\n")
+			fmt.Fprintf(f.out, "%s %s\n", f.paint(styleHelp, "help:"), fix.Message)
+			fmt.Fprint(f.out, f.paintDiff(diff))
+		}
+	}
+}
+
+// paintDiff colors the removed lines of a FixDiff snippet red and the
+// added ones green.
+func (f *Formatter) paintDiff(diff string) string {
+	if !f.color {
+		return diff
+	}
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		bar := strings.IndexAny(line, "-+|")
+		if bar < 0 {
+			continue
+		}
+		switch line[bar] {
+		case '-':
+			lines[i] = line[:bar] + f.paint(styleRemoved, strings.TrimSuffix(line[bar:], "\n")) + "\n"
+		case '+':
+			lines[i] = line[:bar] + f.paint(styleAdded, strings.TrimSuffix(line[bar:], "\n")) + "\n"
+		default:
+			lines[i] = line[:bar] + f.paint(styleGutter, line[bar:bar+1]) + line[bar+1:]
 		}
 	}
+	return strings.Join(lines, "")
 }
 
 // formatSimple formats a diagnostic without source code (fallback).
 func (f *Formatter) formatSimple(d Diagnostic) {
 	f.printHeader(d)
 	if d.Span.IsValid() {
-		fmt.Fprintf(os.Stderr, "  --> %s\n", d.Span.String())
+		fmt.Fprintf(f.out, "  %s %s\n", f.paint(styleGutter, "-->"), d.Span.String())
 	}
 	f.printHelp(d)
 }
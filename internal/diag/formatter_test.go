@@ -0,0 +1,80 @@
+package diag_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// formatOptions formats a diagnostic on `totl` in a small file with opts
+func formatOptions(t *testing.T, line string, opts ...diag.FormatterOption) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.mal")
+	src := "fn main() {\n" + line + "\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	col := strings.Index(line, "totl")
+	start := len("fn main() {\n") + col
+	d := diag.Diagnostic{
+		Severity: diag.SeverityError,
+		Code:     diag.CodeTypeUndefinedIdentifier,
+		Message:  "undefined identifier `totl`",
+		Span:     diag.Span{Filename: path, Line: 2, Column: col + 1, Start: start, End: start + 4},
+	}
+
+	var buf bytes.Buffer
+	diag.NewFormatter(append(opts, diag.WithOutput(&buf))...).Format(d)
+	return strings.ReplaceAll(buf.String(), path, "main.mal")
+}
+
+func TestFormatterShort(t *testing.T) {
+	got := formatOptions(t, "    let x = totl;", diag.WithShort(true))
+	want := "main.mal:2:13: error[TYPE_UNDEFINED_IDENTIFIER]: undefined identifier `totl`\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatterColor(t *testing.T) {
+	if got := formatOptions(t, "    let x = totl;"); strings.Contains(got, "\x1b[") {
+		t.Errorf("colors without WithColor:\n%s", got)
+	}
+	got := formatOptions(t, "    let x = totl;", diag.WithColor(true))
+	if !strings.Contains(got, "\x1b[1;31merror[TYPE_UNDEFINED_IDENTIFIER]\x1b[0m") {
+		t.Errorf("no colored header:\n%q", got)
+	}
+}
+
+func TestFormatterContextLines(t *testing.T) {
+	got := formatOptions(t, "    let x = totl;", diag.WithContextLines(0))
+	if strings.Contains(got, "fn main()") || !strings.Contains(got, "let x = totl;") {
+		t.Errorf("want only the line of the span:\n%s", got)
+	}
+}
+
+func TestFormatterMaxWidth(t *testing.T) {
+	line := "    let x = " + strings.Repeat("a + ", 20) + "totl;"
+	got := formatOptions(t, line, diag.WithMaxWidth(40), diag.WithContextLines(0))
+	for _, l := range strings.Split(got, "\n") {
+		if len(l) > 40 && !strings.HasPrefix(l, "error") {
+			t.Errorf("line longer than 40 columns: %q", l)
+		}
+	}
+	if !strings.Contains(got, "...a + totl;") {
+		t.Errorf("want the line cut before the span:\n%s", got)
+	}
+	// The underline stays under the span
+	lines := strings.Split(got, "\n")
+	for i, l := range lines {
+		if strings.Contains(l, "totl;") && i+1 < len(lines) {
+			if strings.Index(lines[i+1], "^^^^") != strings.Index(l, "totl") {
+				t.Errorf("underline misplaced:\n%s\n%s", l, lines[i+1])
+			}
+		}
+	}
+}
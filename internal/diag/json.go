@@ -0,0 +1,142 @@
+package diag
+
+import (
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+)
+
+// JSONSpan is a span in the JSON form of a diagnostic. Offsets are in bytes
+// of the source file, end exclusive, unlike the character offsets of Span.
+type JSONSpan struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	ByteStart int    `json:"byte_start"`
+	ByteEnd   int    `json:"byte_end"`
+}
+
+// JSONLabeledSpan is a labeled span in the JSON form of a diagnostic.
+type JSONLabeledSpan struct {
+	JSONSpan
+	Label   string `json:"label,omitempty"`
+	Primary bool   `json:"primary"`
+}
+
+// JSONEdit is an edit of a fix in the JSON form of a diagnostic.
+type JSONEdit struct {
+	Span    JSONSpan `json:"span"`
+	NewText string   `json:"new_text"`
+}
+
+// JSONFix is a fix in the JSON form of a diagnostic.
+type JSONFix struct {
+	Message string     `json:"message"`
+	Edits   []JSONEdit `json:"edits"`
+}
+
+// JSONNote is a note in the JSON form of a diagnostic: a step of its proof
+// chain, one of its notes, or a related location.
+type JSONNote struct {
+	Message string    `json:"message"`
+	Span    *JSONSpan `json:"span,omitempty"`
+}
+
+// JSONDiagnostic is the JSON form of a diagnostic, holding what the human
+// format prints: the header, the labeled spans, the notes and the help.
+type JSONDiagnostic struct {
+	Code     Code              `json:"code,omitempty"`
+	Severity Severity          `json:"severity"`
+	Stage    Stage             `json:"stage,omitempty"`
+	Message  string            `json:"message"`
+	Spans    []JSONLabeledSpan `json:"spans"`
+	Notes    []JSONNote        `json:"notes"`
+	Help     string            `json:"help,omitempty"`
+	Fixes    []JSONFix         `json:"fixes,omitempty"`
+}
+
+// FormatJSON writes d to w as JSON on a single line.
+func (f *Formatter) FormatJSON(w io.Writer, d Diagnostic) error {
+	data, err := json.Marshal(f.ToJSON(d))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ToJSON returns the JSON form of d. Byte offsets are computed from the
+// source files of the spans; for a file that cannot be read, the character
+// offsets are used as they are.
+func (f *Formatter) ToJSON(d Diagnostic) JSONDiagnostic {
+	out := JSONDiagnostic{
+		Code:     d.Code,
+		Severity: d.Severity,
+		Stage:    d.Stage,
+		Message:  d.Message,
+		Spans:    []JSONLabeledSpan{},
+		Notes:    []JSONNote{},
+		Help:     d.Help,
+	}
+	if out.Severity == "" {
+		out.Severity = SeverityError
+	}
+	if out.Help == "" {
+		out.Help = d.Suggestion
+	}
+
+	for _, ls := range f.collectSpans(d) {
+		out.Spans = append(out.Spans, JSONLabeledSpan{
+			JSONSpan: f.jsonSpan(ls.Span),
+			Label:    ls.Label,
+			Primary:  ls.Style != "secondary",
+		})
+	}
+
+	// Notes in the order the human format prints them
+	for _, step := range d.ProofChain {
+		note := JSONNote{Message: step.Message}
+		if step.Span.IsValid() {
+			span := f.jsonSpan(step.Span)
+			note.Span = &span
+		}
+		out.Notes = append(out.Notes, note)
+	}
+	for _, n := range d.Notes {
+		out.Notes = append(out.Notes, JSONNote{Message: n})
+	}
+	for _, related := range d.Related {
+		if related.IsValid() {
+			span := f.jsonSpan(related)
+			out.Notes = append(out.Notes, JSONNote{Message: "related location", Span: &span})
+		}
+	}
+
+	for _, fix := range d.Fixes {
+		jf := JSONFix{Message: fix.Message, Edits: []JSONEdit{}}
+		for _, e := range fix.Edits {
+			jf.Edits = append(jf.Edits, JSONEdit{Span: f.jsonSpan(e.Span), NewText: e.NewText})
+		}
+		out.Fixes = append(out.Fixes, jf)
+	}
+	return out
+}
+
+func (f *Formatter) jsonSpan(s Span) JSONSpan {
+	span := JSONSpan{File: s.Filename, Line: s.Line, Column: s.Column, ByteStart: s.Start, ByteEnd: s.End}
+	if src, err := f.LoadSource(s.Filename); err == nil && src != "" {
+		span.ByteStart = byteOffset(src, s.Start)
+		span.ByteEnd = byteOffset(src, s.End)
+	}
+	return span
+}
+
+// byteOffset converts an offset in characters of src to one in bytes
+func byteOffset(src string, chars int) int {
+	offset := 0
+	for i := 0; i < chars && offset < len(src); i++ {
+		_, size := utf8.DecodeRuneInString(src[offset:])
+		offset += size
+	}
+	return offset
+}
@@ -0,0 +1,53 @@
+package diag_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestFormatJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.mal")
+	src := "let é = totl;\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// `totl` is at characters 8-12, after the two bytes of é
+	span := diag.Span{Filename: path, Line: 1, Column: 9, Start: 8, End: 12}
+	d := diag.Diagnostic{
+		Stage:    diag.StageTypeCheck,
+		Severity: diag.SeverityError,
+		Code:     diag.CodeTypeUndefinedIdentifier,
+		Message:  "undefined identifier `totl`",
+	}.WithPrimarySpan(span, "not found").WithNote("names are case sensitive").WithHelp("did you mean `total`?")
+
+	var buf bytes.Buffer
+	if err := diag.NewFormatter().FormatJSON(&buf, d); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+		t.Fatalf("expected a single line, got %q", buf.String())
+	}
+
+	var got diag.JSONDiagnostic
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if got.Code != diag.CodeTypeUndefinedIdentifier || got.Severity != diag.SeverityError || got.Help != "did you mean `total`?" {
+		t.Errorf("diagnostic = %+v", got)
+	}
+	if len(got.Spans) != 1 {
+		t.Fatalf("spans = %+v", got.Spans)
+	}
+	if s := got.Spans[0]; s.ByteStart != 9 || s.ByteEnd != 13 || s.Line != 1 || s.Label != "not found" || !s.Primary {
+		t.Errorf("span = %+v", s)
+	}
+	if len(got.Notes) != 1 || got.Notes[0].Message != "names are case sensitive" {
+		t.Errorf("notes = %+v", got.Notes)
+	}
+}
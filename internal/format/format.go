@@ -0,0 +1,186 @@
+// Package format lays out Malphas source code.
+//
+// The formatter is deliberately conservative: it works on lines rather than
+// on the AST, so it preserves comments and never reorders or rewrites code.
+// It re-indents each line by its bracket depth, trims trailing whitespace,
+// collapses runs of blank lines and ends the file with a single newline.
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+// indentUnit is one level of indentation
+const indentUnit = "    "
+
+// Line is the formatted form of one line of the input
+type Line struct {
+	Text string
+	Drop bool // The line is a redundant blank line and is removed
+}
+
+// Source formats src.
+func Source(src string) (string, error) {
+	lines, err := Lines(src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		if !line.Drop {
+			b.WriteString(line.Text)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String(), nil
+}
+
+// Lines formats src and returns one entry for each line of src, split at
+// '\n'. Formatting a range of lines is done by applying only their entries.
+// Source that does not lex is left alone and reported as an error.
+func Lines(src string) ([]Line, error) {
+	runes := []rune(src)
+	starts := []int{0} // Rune offset of each line
+	for i, r := range runes {
+		if r == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	lineOf := func(offset int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+	}
+
+	l := lexer.NewWithTrivia(src)
+	var toks []lexer.Token
+	for {
+		tok := l.NextToken()
+		if tok.Type == lexer.EOF {
+			break
+		}
+		if tok.Type != lexer.WHITESPACE && tok.Type != lexer.NEWLINE {
+			toks = append(toks, tok)
+		}
+	}
+	if len(l.Errors) > 0 {
+		e := l.Errors[0]
+		return nil, fmt.Errorf("%d:%d: %s", e.Span.Line, e.Span.Column, e.Message)
+	}
+
+	n := len(starts)
+	indent := make([]int, n)
+	verbatim := make([]bool, n)  // The line starts inside a block comment
+	openEnded := make([]bool, n) // The line ends inside a block comment
+	continues := make([]bool, n) // The line continues the statement of the line before
+	for i := range indent {
+		indent[i] = -1
+	}
+
+	// Each group holds the brackets opened on one line that are still open.
+	// However many a line opens, the lines that follow are indented once.
+	type group struct{ line, open int }
+	var stack []group
+	last := -1 // Index of the last code token
+	for i, tok := range toks {
+		line := lineOf(tok.Span.Start)
+		if end := lineOf(tok.Span.End); end > line {
+			for l := line + 1; l <= end && tok.Span.End > starts[l]; l++ {
+				verbatim[l] = true
+			}
+			for l := line; l < end; l++ {
+				openEnded[l] = true
+			}
+		}
+		isComment := tok.Type == lexer.LINE_COMMENT || tok.Type == lexer.BLOCK_COMMENT
+		closer := tok.Type == lexer.RPAREN || tok.Type == lexer.RBRACE || tok.Type == lexer.RBRACKET
+
+		if indent[line] < 0 {
+			indent[line] = len(stack)
+			if closer && len(stack) > 0 {
+				indent[line]--
+			}
+			if !isComment && !closer && tok.Type != lexer.LBRACE && last >= 0 && continuation(toks, last, lineOf) {
+				continues[line] = true
+			}
+		}
+		if isComment {
+			continue
+		}
+
+		switch tok.Type {
+		case lexer.LPAREN, lexer.LBRACE, lexer.LBRACKET:
+			if len(stack) > 0 && stack[len(stack)-1].line == line {
+				stack[len(stack)-1].open++
+			} else {
+				stack = append(stack, group{line: line, open: 1})
+			}
+		case lexer.RPAREN, lexer.RBRACE, lexer.RBRACKET:
+			if len(stack) > 0 {
+				if stack[len(stack)-1].open--; stack[len(stack)-1].open == 0 {
+					stack = stack[:len(stack)-1]
+				}
+			}
+		}
+		last = i
+	}
+
+	lines := make([]Line, n)
+	blank := true // Blank lines at the start of the file are dropped
+	for i := range lines {
+		end := len(runes)
+		if i+1 < n {
+			end = starts[i+1] - 1
+		}
+		text := string(runes[starts[i]:end])
+		if verbatim[i] {
+			lines[i] = Line{Text: text}
+			blank = false
+			continue
+		}
+		if !openEnded[i] {
+			text = strings.TrimRight(text, " \t\r")
+		}
+		text = strings.TrimLeft(text, " \t")
+		if text == "" {
+			lines[i] = Line{Drop: blank}
+			blank = true
+			continue
+		}
+		depth := indent[i]
+		if continues[i] {
+			depth++
+		}
+		if depth < 0 {
+			depth = 0
+		}
+		lines[i] = Line{Text: strings.Repeat(indentUnit, depth) + text}
+		blank = false
+	}
+	// Drop the blank lines at the end of the file
+	for i := n - 1; i >= 0 && lines[i].Text == "" && !verbatim[i]; i-- {
+		lines[i].Drop = true
+	}
+	return lines, nil
+}
+
+// continuation reports whether the line after the one ending with toks[last]
+// continues the same statement or expression, so is indented once more
+func continuation(toks []lexer.Token, last int, lineOf func(int) int) bool {
+	switch toks[last].Type {
+	case lexer.SEMICOLON, lexer.COMMA, lexer.LBRACE, lexer.RBRACE, lexer.LPAREN, lexer.LBRACKET:
+		return false
+	}
+	// Attributes stand on their own line before the item they annotate
+	line := lineOf(toks[last].Span.Start)
+	first := last
+	for first > 0 && lineOf(toks[first-1].Span.Start) == line {
+		first--
+	}
+	for first < last && (toks[first].Type == lexer.LINE_COMMENT || toks[first].Type == lexer.BLOCK_COMMENT) {
+		first++
+	}
+	return toks[first].Type != lexer.HASH
+}
@@ -0,0 +1,71 @@
+package format
+
+import "testing"
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "reindents blocks",
+			src:  "fn main() {\nlet x = 1;\n  if x > 0 {\n        println(x);\n }\n}\n",
+			want: "fn main() {\n    let x = 1;\n    if x > 0 {\n        println(x);\n    }\n}\n",
+		},
+		{
+			name: "trims trailing whitespace and adds final newline",
+			src:  "fn main() {   \n    return;\t\n}",
+			want: "fn main() {\n    return;\n}\n",
+		},
+		{
+			name: "collapses blank lines",
+			src:  "\n\nfn a() {\n}\n\n\n   \nfn b() {\n}\n\n\n",
+			want: "fn a() {\n}\n\nfn b() {\n}\n",
+		},
+		{
+			name: "indents once per line of brackets",
+			src:  "fn main() {\nlet v = foo(bar(\n1,\n2,\n));\n}\n",
+			want: "fn main() {\n    let v = foo(bar(\n        1,\n        2,\n    ));\n}\n",
+		},
+		{
+			name: "indents continuation lines",
+			src:  "fn main() {\nlet x = a\n+ b;\nlet y = 1;\n}\n",
+			want: "fn main() {\n    let x = a\n        + b;\n    let y = 1;\n}\n",
+		},
+		{
+			name: "attributes are not continued",
+			src:  "#[test]\n  fn t() {\n}\n",
+			want: "#[test]\nfn t() {\n}\n",
+		},
+		{
+			name: "keeps comments",
+			src:  "fn main() {\n// leading\nlet x = 1; // trailing\n  /* block\n     stays */\n}\n",
+			want: "fn main() {\n    // leading\n    let x = 1; // trailing\n    /* block\n     stays */\n}\n",
+		},
+		{
+			name: "leaves the inside of block comments alone",
+			src:  "fn main() {\n/*   \n* keep   \n */\n}\n",
+			want: "fn main() {\n    /*   \n* keep   \n */\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := Source(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+		if again, _ := Source(got); again != got {
+			t.Errorf("%s: formatting is not idempotent:\n%s", tt.name, again)
+		}
+	}
+}
+
+func TestSourceDoesNotLex(t *testing.T) {
+	if _, err := Source("fn main() {\nlet s = \"open;\n}\n"); err == nil {
+		t.Fatal("expected an error for an unterminated string")
+	}
+}
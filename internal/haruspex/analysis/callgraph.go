@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/liveir"
+)
+
+// CallGraph records which functions call which, by FunctionID.
+type CallGraph struct {
+	Functions map[string]*liveir.LiveFunction
+	Callees   map[string][]string // Only the callees among Functions, sorted
+}
+
+// NewCallGraph builds the call graph of fns from their resolved calls.
+func NewCallGraph(fns []*liveir.LiveFunction) *CallGraph {
+	g := &CallGraph{
+		Functions: make(map[string]*liveir.LiveFunction, len(fns)),
+		Callees:   make(map[string][]string, len(fns)),
+	}
+	for _, fn := range fns {
+		g.Functions[fn.ID] = fn
+	}
+	for _, fn := range fns {
+		seen := make(map[string]bool)
+		for _, block := range fn.Blocks {
+			for _, node := range block.Nodes {
+				if node.Op != liveir.OpCall || seen[node.Target] || g.Functions[node.Target] == nil {
+					continue
+				}
+				seen[node.Target] = true
+				g.Callees[fn.ID] = append(g.Callees[fn.ID], node.Target)
+			}
+		}
+		sort.Strings(g.Callees[fn.ID])
+	}
+	return g
+}
+
+// Components returns the strongly connected components of g, each a set of
+// mutually recursive functions, with callees before their callers. Analyzing
+// the components in order finds each call's callee already summarized,
+// except for calls within a component.
+func (g *CallGraph) Components() [][]string {
+	ids := make([]string, 0, len(g.Functions))
+	for id := range g.Functions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Tarjan's algorithm emits each component after the ones it reaches
+	index := make(map[string]int)
+	low := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var visit func(id string)
+	visit = func(id string) {
+		index[id] = len(index)
+		low[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, callee := range g.Callees[id] {
+			if _, visited := index[callee]; !visited {
+				visit(callee)
+				low[id] = min(low[id], low[callee])
+			} else if onStack[callee] {
+				low[id] = min(low[id], index[callee])
+			}
+		}
+
+		if low[id] == index[id] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == id {
+					break
+				}
+			}
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+
+	for _, id := range ids {
+		if _, visited := index[id]; !visited {
+			visit(id)
+		}
+	}
+	return components
+}
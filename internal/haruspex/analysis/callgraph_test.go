@@ -0,0 +1,39 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/liveir"
+)
+
+// caller returns a function with one call to each of callees
+func caller(id string, callees ...string) *liveir.LiveFunction {
+	entry := &liveir.LiveBlock{ID: 0}
+	for _, callee := range callees {
+		entry.Nodes = append(entry.Nodes, liveir.LiveNode{
+			Op:      liveir.OpCall,
+			Target:  callee,
+			Outputs: []liveir.LiveValue{{ID: len(entry.Nodes) + 1}},
+		})
+	}
+	return &liveir.LiveFunction{Name: id, ID: id, Entry: entry, Blocks: []*liveir.LiveBlock{entry}}
+}
+
+func TestCallGraphComponents(t *testing.T) {
+	g := NewCallGraph([]*liveir.LiveFunction{
+		caller("main", "even", "log", "println"),
+		caller("even", "odd"),
+		caller("odd", "even"),
+		caller("log"),
+	})
+
+	if got := g.Callees["main"]; !reflect.DeepEqual(got, []string{"even", "log"}) {
+		t.Errorf("callees of main = %v, unknown callees should be dropped", got)
+	}
+
+	want := [][]string{{"even", "odd"}, {"log"}, {"main"}}
+	if got := g.Components(); !reflect.DeepEqual(got, want) {
+		t.Errorf("components = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,191 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/liveir"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+// chanOp is a send or receive in one of the tasks of a function, where task
+// 0 is the function itself
+type chanOp struct {
+	node liveir.LiveNode
+	task int
+	arm  bool // Whether it is what a select arm waits on
+}
+
+// selectSite is a select and the operations its arms wait on
+type selectSite struct {
+	pos      lexer.Span
+	arms     []chanOp
+	blocking bool // Whether it lacks a default and an after arm
+}
+
+// deadlockFinder follows the channels a function makes. Channels are known
+// by the variables they are bound to. One that is passed, returned, sent,
+// copied to another variable, or made twice under the same name is not
+// followed, as its partners may be anywhere.
+type deadlockFinder struct {
+	made    map[string]*liveir.LiveNode // Channel variable to where it is made
+	escaped map[string]bool
+	ops     []chanOp
+	selects []selectSite
+	tasks   int
+}
+
+// detectDeadlocks reports the operations on channels made in fn that block
+// forever: sends on unbuffered channels that no other task receives from,
+// receives from channels nothing sends on, and selects none of whose arms
+// can fire. Operations in the blocks states finds dead are left out; tasks
+// spawned by fn are assumed to run all of their code.
+func detectDeadlocks(fn *liveir.LiveFunction, states map[int]*SymState, reporter *diagnostics.Reporter) {
+	f := &deadlockFinder{made: make(map[string]*liveir.LiveNode), escaped: make(map[string]bool)}
+	f.collect(fn, 0, func(b *liveir.LiveBlock) bool {
+		state, ok := states[b.ID]
+		return ok && !state.Unsatisfiable
+	})
+
+	for _, op := range f.ops {
+		if op.arm {
+			continue
+		}
+		if reason := f.blocked(op); reason != "" {
+			verb := "send on"
+			if op.node.Op == liveir.OpRecv {
+				verb = "receive from"
+			}
+			reporter.Add(diagnostics.Diagnostic{
+				Pos:     op.node.Pos,
+				Message: fmt.Sprintf("Deadlock: %s `%s` blocks forever, as %s", verb, op.node.Target, reason),
+				Kind:    diagnostics.KindWarning,
+				Rule:    diagnostics.RuleDeadlock,
+				Related: []diagnostics.Related{f.madeHere(op.node.Target)},
+			})
+		}
+	}
+
+	for _, s := range f.selects {
+		var dead []diagnostics.Related // The arms that never fire, and why
+		for _, arm := range s.arms {
+			if reason := f.blocked(arm); reason != "" {
+				dead = append(dead, diagnostics.Related{Pos: arm.node.Pos, Message: reason})
+			}
+		}
+		if s.blocking && len(dead) == len(s.arms) {
+			message := "Deadlock: select blocks forever, as none of its arms can fire"
+			if len(s.arms) == 0 {
+				message = "Deadlock: select blocks forever, as it has no arms"
+			}
+			for i := range dead {
+				dead[i].Message = "This arm never fires, as " + dead[i].Message
+			}
+			reporter.Add(diagnostics.Diagnostic{
+				Pos:     s.pos,
+				Message: message,
+				Kind:    diagnostics.KindWarning,
+				Rule:    diagnostics.RuleDeadlock,
+				Related: dead,
+			})
+			continue
+		}
+		for _, arm := range dead {
+			reporter.Add(diagnostics.Diagnostic{
+				Pos:     arm.Pos,
+				Message: "Select arm can never fire, as " + arm.Message,
+				Kind:    diagnostics.KindWarning,
+				Rule:    diagnostics.RuleDeadlock,
+			})
+		}
+	}
+}
+
+// collect records the channel operations of fn, which runs as task, in the
+// blocks live accepts, and those of the tasks fn spawns
+func (f *deadlockFinder) collect(fn *liveir.LiveFunction, task int, live func(*liveir.LiveBlock) bool) {
+	makes := make(map[int]*liveir.LiveNode) // Channel value to where it is made
+	for _, block := range fn.Blocks {
+		if !live(block) {
+			continue
+		}
+		for i := range block.Nodes {
+			node := &block.Nodes[i]
+			for _, in := range node.Inputs {
+				val, ok := in.(liveir.LiveValue)
+				if !ok {
+					continue
+				}
+				if made := makes[val.ID]; made != nil && node.Op == liveir.OpAssign {
+					if _, twice := f.made[node.Target]; twice {
+						f.escaped[node.Target] = true
+					}
+					f.made[node.Target] = made
+				} else if val.Expr != nil && val.Expr.Kind == liveir.SymVar {
+					f.escaped[val.Expr.Name] = true
+				}
+			}
+
+			switch node.Op {
+			case liveir.OpMakeChan:
+				makes[node.Outputs[0].ID] = node
+			case liveir.OpSend, liveir.OpRecv:
+				arm := block.Arm != nil && block.Arm.Pos == node.Pos
+				f.ops = append(f.ops, chanOp{node: *node, task: task, arm: arm})
+			case liveir.OpSelect:
+				s := selectSite{pos: node.Pos, blocking: true}
+				for _, next := range block.Next {
+					if next.Arm == nil {
+						s.blocking = false
+						continue
+					}
+					s.arms = append(s.arms, chanOp{node: *next.Arm, task: task, arm: true})
+				}
+				f.selects = append(f.selects, s)
+			}
+		}
+	}
+
+	for _, t := range fn.Tasks {
+		f.tasks++
+		f.collect(t, f.tasks, func(*liveir.LiveBlock) bool { return true })
+	}
+}
+
+// blocked returns why op can never complete, or "" if it may
+func (f *deadlockFinder) blocked(op chanOp) string {
+	name := op.node.Target
+	made := f.made[name]
+	if name == "" || made == nil || f.escaped[name] {
+		return ""
+	}
+
+	if op.node.Op == liveir.OpRecv {
+		for _, other := range f.ops {
+			if other.node.Op == liveir.OpSend && other.node.Target == name {
+				return ""
+			}
+		}
+		return fmt.Sprintf("nothing sends on `%s`", name)
+	}
+
+	if !unbuffered(made) {
+		return ""
+	}
+	for _, other := range f.ops {
+		if other.node.Op == liveir.OpRecv && other.node.Target == name && other.task != op.task {
+			return ""
+		}
+	}
+	return fmt.Sprintf("`%s` is unbuffered and no other task receives from it", name)
+}
+
+// unbuffered reports whether the channel made by node has no capacity
+func unbuffered(node *liveir.LiveNode) bool {
+	size, ok := node.Inputs[0].(liveir.LiveValue)
+	return ok && size.Kind == liveir.ValueKindConcrete && size.Expr != nil && size.Expr.Kind == liveir.SymConst && size.Expr.Value == 0
+}
+
+func (f *deadlockFinder) madeHere(name string) diagnostics.Related {
+	return diagnostics.Related{Pos: f.made[name].Pos, Message: fmt.Sprintf("`%s` is made here", name)}
+}
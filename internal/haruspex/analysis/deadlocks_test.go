@@ -0,0 +1,161 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/liveir"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// deadlocks lowers and analyzes every function of src, and returns its
+// Deadlock diagnostics
+func deadlocks(t *testing.T, src string) []diagnostics.Diagnostic {
+	t.Helper()
+	p := parser.New(src, parser.WithFilename("deadlocks.mal"))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	checker := types.NewChecker()
+	checker.CheckWithFilename(file, "deadlocks.mal")
+	functions, err := liveir.NewLowerer(checker.ExprTypes).LowerModule(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reporter := diagnostics.NewReporter()
+	for _, fn := range functions {
+		if _, err := NewEngine().Analyze(fn, reporter); err != nil {
+			t.Fatalf("analyzing %s: %v", fn.Name, err)
+		}
+	}
+	var out []diagnostics.Diagnostic
+	for _, d := range reporter.Diagnostics() {
+		if d.Rule == diagnostics.RuleDeadlock {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func TestDetectDeadlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string // Messages of the diagnostics, by prefix
+	}{
+		{
+			name: "send without receiver",
+			src: `package main;
+fn main() {
+    let ch = Channel[int]::new(0);
+    ch <- 1;
+    let x = <-ch;
+}
+`,
+			want: []string{"Deadlock: send on `ch` blocks forever, as `ch` is unbuffered"},
+		},
+		{
+			name: "buffered send",
+			src: `package main;
+fn main() {
+    let ch = Channel[int]::new(1);
+    ch <- 1;
+    let x = <-ch;
+}
+`,
+		},
+		{
+			name: "receiver in spawned task",
+			src: `package main;
+fn main() {
+    let ch = Channel[int]::new(0);
+    spawn {
+        let x = <-ch;
+    };
+    ch <- 1;
+}
+`,
+		},
+		{
+			name: "receive without sender",
+			src: `package main;
+fn main() {
+    let ch = make[chan int](1);
+    let x = <-ch;
+}
+`,
+			want: []string{"Deadlock: receive from `ch` blocks forever, as nothing sends on `ch`"},
+		},
+		{
+			name: "channel passed to spawned call",
+			src: `package main;
+fn worker(c: chan int) {
+    c <- 42;
+}
+fn main() {
+    let ch = Channel[int]::new(0);
+    spawn worker(ch);
+    let x = <-ch;
+}
+`,
+		},
+		{
+			name: "sender only in dead branch",
+			src: `package main;
+fn main() {
+    let ch = Channel[int]::new(1);
+    if false {
+        ch <- 1;
+    }
+    let x = <-ch;
+}
+`,
+			want: []string{"Deadlock: receive from `ch`"},
+		},
+		{
+			name: "select with no arm that fires",
+			src: `package main;
+fn main() {
+    let a = Channel[int]::new(1);
+    let b = Channel[int]::new(0);
+    select {
+        case let x = <-a => {}
+        case b <- 1 => {}
+    }
+}
+`,
+			want: []string{"Deadlock: select blocks forever, as none of its arms can fire"},
+		},
+		{
+			name: "select with default",
+			src: `package main;
+fn main() {
+    let a = Channel[int]::new(1);
+    select {
+        case let x = <-a => {}
+        default => {}
+    }
+}
+`,
+			want: []string{"Select arm can never fire, as nothing sends on `a`"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := deadlocks(t, tt.src)
+			if len(ds) != len(tt.want) {
+				t.Fatalf("got %d diagnostics, want %d: %v", len(ds), len(tt.want), ds)
+			}
+			for i, d := range ds {
+				if !strings.HasPrefix(d.Message, tt.want[i]) {
+					t.Errorf("diagnostic %d = %q, want %q...", i, d.Message, tt.want[i])
+				}
+			}
+		})
+	}
+}
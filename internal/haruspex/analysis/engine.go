@@ -9,16 +9,22 @@ import (
 
 // Engine is the main entry point for the Haruspex analysis.
 type Engine struct {
-	// Configuration, etc.
+	// Summaries of the functions calls may resolve to, by FunctionID. Calls
+	// to functions without a summary return unknown values.
+	Summaries map[string]*Summary
+
+	returns []*liveir.SymExpr // Values returned on the paths analyzed so far
 }
 
 // NewEngine creates a new analysis engine.
 func NewEngine() *Engine {
-	return &Engine{}
+	return &Engine{Summaries: make(map[string]*Summary)}
 }
 
 // Analyze performs semantic analysis on the given function.
 func (e *Engine) Analyze(fn *liveir.LiveFunction, reporter *diagnostics.Reporter) (map[int]*SymState, error) {
+	e.returns = nil
+
 	// Worklist of blocks to process
 	worklist := []*liveir.LiveBlock{fn.Entry}
 
@@ -94,17 +100,35 @@ func (e *Engine) Analyze(fn *liveir.LiveFunction, reporter *diagnostics.Reporter
 
 	// Post-analysis: Check for unreachable blocks/code
 	for _, block := range fn.Blocks {
+		if len(block.Nodes) == 0 {
+			continue // No code to report, as in an implicit else or after a return
+		}
 		state, visited := blockStates[block.ID]
 		if !visited {
-			pos := block.Pos
-			if len(block.Nodes) > 0 {
-				pos = block.Nodes[0].Pos
-			}
-			reporter.Warning(pos, "Unreachable block")
+			reporter.Add(diagnostics.Diagnostic{
+				Pos:     block.Nodes[0].Pos,
+				Message: "Unreachable block",
+				Kind:    diagnostics.KindWarning,
+				Rule:    diagnostics.RuleUnreachable,
+			})
 		} else if state.Unsatisfiable {
-			reporter.Warning(block.Pos, "Unreachable code (unsatisfiable path)")
+			d := diagnostics.Diagnostic{
+				Pos:     block.Pos,
+				Message: "Unreachable code (unsatisfiable path)",
+				Kind:    diagnostics.KindWarning,
+				Rule:    diagnostics.RuleDeadBranch,
+			}
+			if block.Body.End > block.Body.Start {
+				d.Fixes = []diagnostics.Fix{{
+					Message: "Remove the body of the dead branch",
+					Edits:   []diagnostics.Edit{{Span: block.Body, NewText: "{}"}},
+				}}
+			}
+			reporter.Add(d)
 		}
 	}
 
+	detectDeadlocks(fn, blockStates, reporter)
+
 	return blockStates, nil
 }
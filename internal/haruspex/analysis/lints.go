@@ -0,0 +1,241 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// Lint reports the definitions in file that nothing reads, using the
+// def-use information of checker, which must have checked file: local
+// variables, parameters, private functions, and fields of private structs.
+// Names starting with an underscore are exempt, as are main and test_
+// functions. Assigning to a variable does not count as reading it.
+func Lint(file *ast.File, checker *types.Checker, reporter *diagnostics.Reporter) {
+	l := &linter{
+		checker:  checker,
+		reporter: reporter,
+		uses:     make(map[*ast.Ident][]*ast.Ident),
+		targets:  make(map[*ast.Ident]bool),
+		fields:   make(map[string]bool),
+	}
+	l.collect(file)
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FnDecl:
+			l.lintFunction(d)
+			l.lintParams(d)
+		case *ast.ImplDecl:
+			for _, m := range d.Methods {
+				l.lintParams(m)
+			}
+		case *ast.StructDecl:
+			l.lintFields(d)
+		}
+	}
+
+	ast.Walk(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStmt:
+			l.lintVariable(n.Name)
+		case *ast.MatchArm:
+			walkPattern(n.Pattern, func(p ast.Pattern) {
+				if v, ok := p.(*ast.VarPattern); ok {
+					l.lintVariable(v.Name)
+				}
+			})
+		}
+		return true
+	})
+}
+
+type linter struct {
+	checker  *types.Checker
+	reporter *diagnostics.Reporter
+
+	uses    map[*ast.Ident][]*ast.Ident // Defining identifier to its uses
+	targets map[*ast.Ident]bool         // Uses that are assigned to, not read
+	fields  map[string]bool             // Names of the fields read anywhere
+
+	literalFields []*ast.StructLiteralField
+}
+
+func (l *linter) collect(file *ast.File) {
+	for ident, sym := range l.checker.Uses {
+		if def := sym.DefIdent(); def != nil {
+			l.uses[def] = append(l.uses[def], ident)
+		}
+	}
+
+	ast.Walk(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignExpr:
+			if target, ok := n.Target.(*ast.Ident); ok {
+				l.targets[target] = true
+			}
+		case *ast.FieldExpr:
+			l.fields[n.Field.Name] = true
+		case *ast.StructLiteralField:
+			l.literalFields = append(l.literalFields, n)
+		case *ast.MatchArm:
+			walkPattern(n.Pattern, func(p ast.Pattern) {
+				if s, ok := p.(*ast.StructPattern); ok {
+					for _, f := range s.Fields {
+						l.fields[f.Name.Name] = true
+					}
+				}
+			})
+		}
+		return true
+	})
+}
+
+// read reports whether def is read anywhere
+func (l *linter) read(def *ast.Ident) bool {
+	for _, use := range l.uses[def] {
+		if !l.targets[use] {
+			return true
+		}
+	}
+	return false
+}
+
+// readOutside reports whether def is read somewhere other than inside span
+func (l *linter) readOutside(def *ast.Ident, span lexer.Span) bool {
+	for _, use := range l.uses[def] {
+		at := use.Span()
+		inside := at.Filename == span.Filename && at.Start >= span.Start && at.End <= span.End
+		if !l.targets[use] && !inside {
+			return true
+		}
+	}
+	return false
+}
+
+// exempt reports whether name is one that lints leave alone
+func exempt(name *ast.Ident) bool {
+	return name == nil || strings.HasPrefix(name.Name, "_")
+}
+
+// renameFix prefixes def and its uses, which are all assignments, with an
+// underscore
+func (l *linter) renameFix(def *ast.Ident) diagnostics.Fix {
+	fix := diagnostics.Fix{Message: fmt.Sprintf("Prefix `%s` with an underscore", def.Name)}
+	for _, ident := range append([]*ast.Ident{def}, l.uses[def]...) {
+		fix.Edits = append(fix.Edits, diagnostics.Edit{Span: ident.Span(), NewText: "_" + ident.Name})
+	}
+	return fix
+}
+
+func (l *linter) lintVariable(name *ast.Ident) {
+	if exempt(name) || l.checker.Defs[name] == nil || l.read(name) {
+		return
+	}
+	message := fmt.Sprintf("Variable `%s` is never used", name.Name)
+	if len(l.uses[name]) > 0 {
+		message = fmt.Sprintf("Variable `%s` is assigned but never read", name.Name)
+	}
+	l.reporter.Add(diagnostics.Diagnostic{
+		Pos:     name.Span(),
+		Message: message,
+		Kind:    diagnostics.KindWarning,
+		Rule:    diagnostics.RuleUnusedVariable,
+		Fixes:   []diagnostics.Fix{l.renameFix(name)},
+	})
+}
+
+func (l *linter) lintParams(fn *ast.FnDecl) {
+	if fn.Body == nil {
+		return // A signature, whose parameters have nothing to be used by
+	}
+	for _, p := range fn.Params {
+		if exempt(p.Name) || p.Name.Name == "self" || l.read(p.Name) {
+			continue
+		}
+		l.reporter.Add(diagnostics.Diagnostic{
+			Pos:     p.Name.Span(),
+			Message: fmt.Sprintf("Parameter `%s` of `%s` is never used", p.Name.Name, fn.Name.Name),
+			Kind:    diagnostics.KindWarning,
+			Rule:    diagnostics.RuleUnusedParameter,
+			Fixes:   []diagnostics.Fix{l.renameFix(p.Name)},
+		})
+	}
+}
+
+// lintFunction reports a private function that nothing but itself calls
+func (l *linter) lintFunction(fn *ast.FnDecl) {
+	name := fn.Name
+	if fn.Pub || exempt(name) || name.Name == "main" || strings.HasPrefix(name.Name, "test_") {
+		return
+	}
+	if l.readOutside(name, fn.Span()) {
+		return
+	}
+	l.reporter.Add(diagnostics.Diagnostic{
+		Pos:     name.Span(),
+		Message: fmt.Sprintf("Function `%s` is never called", name.Name),
+		Kind:    diagnostics.KindWarning,
+		Rule:    diagnostics.RuleUnusedFunction,
+		Fixes: []diagnostics.Fix{{
+			Message: fmt.Sprintf("Remove `%s`", name.Name),
+			Edits:   []diagnostics.Edit{{Span: fn.Span()}},
+		}},
+	})
+}
+
+// lintFields reports the fields of a private struct whose names no field
+// access or struct pattern in the file reads. Fields are matched by name,
+// as accesses are not resolved to the struct they read from.
+func (l *linter) lintFields(s *ast.StructDecl) {
+	if s.Pub {
+		return // Other modules may read them
+	}
+	for _, f := range s.Fields {
+		if exempt(f.Name) || l.fields[f.Name.Name] {
+			continue
+		}
+		fix := diagnostics.Fix{Message: fmt.Sprintf("Prefix `%s` with an underscore", f.Name.Name)}
+		fix.Edits = append(fix.Edits, diagnostics.Edit{Span: f.Name.Span(), NewText: "_" + f.Name.Name})
+		for _, lf := range l.literalFields {
+			if lf.Name.Name == f.Name.Name {
+				fix.Edits = append(fix.Edits, diagnostics.Edit{Span: lf.Name.Span(), NewText: "_" + f.Name.Name})
+			}
+		}
+		l.reporter.Add(diagnostics.Diagnostic{
+			Pos:     f.Name.Span(),
+			Message: fmt.Sprintf("Field `%s` of `%s` is never read", f.Name.Name, s.Name.Name),
+			Kind:    diagnostics.KindWarning,
+			Rule:    diagnostics.RuleUnusedField,
+			Fixes:   []diagnostics.Fix{fix},
+		})
+	}
+}
+
+// walkPattern calls fn for p and each pattern nested in it
+func walkPattern(p ast.Pattern, fn func(ast.Pattern)) {
+	if p == nil {
+		return
+	}
+	fn(p)
+	switch p := p.(type) {
+	case *ast.StructPattern:
+		for _, f := range p.Fields {
+			if f.Pattern != nil {
+				walkPattern(f.Pattern, fn)
+			}
+		}
+	case *ast.EnumPattern:
+		for _, arg := range p.Args {
+			walkPattern(arg, fn)
+		}
+	case *ast.TuplePattern:
+		for _, el := range p.Elements {
+			walkPattern(el, fn)
+		}
+	}
+}
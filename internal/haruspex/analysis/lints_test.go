@@ -0,0 +1,123 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+const lintSource = `package main;
+
+struct Point {
+    x: int,
+    y: int,
+}
+
+pub struct Public {
+    hidden: int,
+}
+
+fn helper(a: int, _b: int, c: int) -> int {
+    a + 1
+}
+
+fn countdown(n: int) -> int {
+    if n > 0 {
+        return countdown(n - 1);
+    }
+    return 0;
+}
+
+fn used() -> int {
+    2
+}
+
+fn test_helper() {}
+
+fn main() {
+    let p = Point { x: used(), y: 2 };
+    let unused = 3;
+    let written = 0;
+    written = 5;
+    let _ignored = 4;
+    println(p.x);
+}
+`
+
+func lint(t *testing.T, src string) []diagnostics.Diagnostic {
+	t.Helper()
+	p := parser.New(src, parser.WithFilename("lint.mal"))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	checker := types.NewChecker()
+	checker.CheckWithFilename(file, "lint.mal")
+	reporter := diagnostics.NewReporter()
+	Lint(file, checker, reporter)
+	return reporter.Diagnostics()
+}
+
+// applyFix applies the edits of fix, last first, to src
+func applyFix(src string, fix diagnostics.Fix) string {
+	runes := []rune(src)
+	for i := len(fix.Edits) - 1; i >= 0; i-- {
+		e := fix.Edits[i]
+		runes = append(runes[:e.Span.Start], append([]rune(e.NewText), runes[e.Span.End:]...)...)
+	}
+	return string(runes)
+}
+
+func TestLint(t *testing.T) {
+	ds := lint(t, lintSource)
+
+	want := []struct {
+		rule, message string
+	}{
+		{diagnostics.RuleUnusedField, "Field `y` of `Point` is never read"},
+		{diagnostics.RuleUnusedFunction, "Function `helper` is never called"},
+		{diagnostics.RuleUnusedParameter, "Parameter `c` of `helper` is never used"},
+		{diagnostics.RuleUnusedFunction, "Function `countdown` is never called"},
+		{diagnostics.RuleUnusedVariable, "Variable `unused` is never used"},
+		{diagnostics.RuleUnusedVariable, "Variable `written` is assigned but never read"},
+	}
+	if len(ds) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d:\n%v", len(ds), len(want), ds)
+	}
+	for i, w := range want {
+		if ds[i].Rule != w.rule || ds[i].Message != w.message || ds[i].Kind != diagnostics.KindWarning {
+			t.Errorf("diagnostic %d = %s %q, want %s %q", i, ds[i].Rule, ds[i].Message, w.rule, w.message)
+		}
+		if len(ds[i].Fixes) != 1 {
+			t.Errorf("%q has %d fixes", ds[i].Message, len(ds[i].Fixes))
+		}
+	}
+
+	// Renaming covers the assignment too, and silences the lint
+	fixed := applyFix(lintSource, ds[5].Fixes[0])
+	if !strings.Contains(fixed, "let _written = 0;\n    _written = 5;") {
+		t.Errorf("fix produced:\n%s", fixed)
+	}
+	// The field is renamed in literals as well
+	fixed = applyFix(fixed, ds[0].Fixes[0])
+	if !strings.Contains(fixed, "Point { x: used(), _y: 2 }") {
+		t.Errorf("fix produced:\n%s", fixed)
+	}
+	if ds := lint(t, fixed); len(ds) != len(want)-2 {
+		t.Errorf("after fixes: %v", ds)
+	}
+}
+
+func TestLintRemoveFunction(t *testing.T) {
+	src := "fn main() {}\n\nfn stale() {\n    println(1);\n}\n"
+	ds := lint(t, src)
+	if len(ds) != 1 || ds[0].Rule != diagnostics.RuleUnusedFunction {
+		t.Fatalf("diagnostics = %v", ds)
+	}
+	if got := applyFix(src, ds[0].Fixes[0]); got != "fn main() {}\n\n\n" {
+		t.Errorf("fix produced %q", got)
+	}
+}
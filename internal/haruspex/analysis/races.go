@@ -0,0 +1,238 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// DetectRaces reports variables that a spawned task captures and its parent
+// function modifies after the spawn, with nothing ordering the two. A
+// modification counts as after the spawn when it comes later in the
+// function, or anywhere in a loop around the spawn, where it is also after
+// the spawn of the previous iteration. Capturing a reference or pointer
+// taken with `&x` or `&mut x` counts as capturing x.
+//
+// Only assignments count as modifications: calls of methods that take
+// `&mut self` are not resolved.
+func DetectRaces(file *ast.File, checker *types.Checker, reporter *diagnostics.Reporter) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FnDecl:
+			detectRaces(d, checker, reporter)
+		case *ast.ImplDecl:
+			for _, m := range d.Methods {
+				detectRaces(m, checker, reporter)
+			}
+		}
+	}
+}
+
+// spawnSite is a spawned task and the locals of its parent it captures
+type spawnSite struct {
+	span     lexer.Span
+	loops    []lexer.Span              // Loops around the spawn
+	captures map[*ast.Ident]*ast.Ident // Captured variable to its first use in the task
+}
+
+// mutation is an assignment in the parent to a variable or through it
+type mutation struct {
+	span  lexer.Span
+	def   *ast.Ident // The variable modified
+	loops []lexer.Span
+}
+
+type raceFinder struct {
+	checker *types.Checker
+	fnSpan  lexer.Span
+	aliases map[*ast.Ident]*ast.Ident // Reference variable to the variable it refers to
+	spawns  []*spawnSite
+	writes  []mutation
+}
+
+func detectRaces(fn *ast.FnDecl, checker *types.Checker, reporter *diagnostics.Reporter) {
+	if fn.Body == nil {
+		return
+	}
+	f := &raceFinder{checker: checker, fnSpan: fn.Span(), aliases: make(map[*ast.Ident]*ast.Ident)}
+	f.walk(fn.Body, nil, nil)
+
+	for _, w := range f.writes {
+		for _, s := range f.spawns {
+			use, captured := s.captures[w.def]
+			if !captured || !(w.span.Start >= s.span.End || sharesLoop(s.loops, w.loops, w.def)) {
+				continue
+			}
+			reporter.Add(diagnostics.Diagnostic{
+				Pos:     w.span,
+				Message: fmt.Sprintf("Possible data race: `%s` is modified here while a spawned task may use it", w.def.Name),
+				Kind:    diagnostics.KindWarning,
+				Rule:    diagnostics.RuleDataRace,
+				Related: []diagnostics.Related{
+					{Pos: use.Span(), Message: fmt.Sprintf("`%s` is captured by the spawned task here", use.Name)},
+				},
+			})
+			break // One report per modification
+		}
+	}
+}
+
+// sharesLoop reports whether a loop encloses both a spawn and a mutation
+// of def, which each iteration shares because def is declared outside it
+func sharesLoop(a, b []lexer.Span, def *ast.Ident) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y && !within(def.Span(), x) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// local returns the definition of the local variable or parameter ident
+// refers to, or nil if it refers to anything else
+func (f *raceFinder) local(ident *ast.Ident) *ast.Ident {
+	sym := f.checker.Uses[ident]
+	if sym == nil {
+		return nil
+	}
+	switch sym.DefNode.(type) {
+	case *ast.LetStmt, *ast.Param, *ast.VarPattern:
+	default:
+		return nil
+	}
+	def := sym.DefIdent()
+	if def == nil || def.Span().Filename != f.fnSpan.Filename || def.Span().Start < f.fnSpan.Start || def.Span().End > f.fnSpan.End {
+		return nil
+	}
+	return def
+}
+
+// walk records the spawns, mutations and references under n, which is
+// inside loops and, when spawn is set, inside that spawned task
+func (f *raceFinder) walk(n ast.Node, loops []lexer.Span, spawn *spawnSite) {
+	ast.Walk(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.WhileStmt, *ast.ForStmt:
+			inner := append(append([]lexer.Span(nil), loops...), n.Span())
+			switch n := n.(type) {
+			case *ast.WhileStmt:
+				f.walk(n.Condition, inner, spawn)
+				f.walk(n.Body, inner, spawn)
+			case *ast.ForStmt:
+				f.walk(n.Iterable, inner, spawn)
+				f.walk(n.Body, inner, spawn)
+			}
+			return false
+
+		case *ast.SpawnStmt:
+			site := &spawnSite{span: n.Span(), loops: loops, captures: make(map[*ast.Ident]*ast.Ident)}
+			f.spawns = append(f.spawns, site)
+			if n.Block != nil {
+				f.walk(n.Block, nil, site)
+			}
+			if n.FunctionLiteral != nil {
+				f.walk(n.FunctionLiteral.Body, nil, site)
+			}
+			var args []ast.Expr
+			if n.Call != nil {
+				args = n.Call.Args
+			}
+			for _, arg := range append(args, n.Args...) {
+				f.captureArg(arg, site)
+			}
+			return false
+
+		case *ast.LetStmt:
+			if ref, ok := n.Value.(*ast.PrefixExpr); ok && (ref.Op == lexer.AMPERSAND || ref.Op == lexer.REF_MUT) {
+				if target, ok := ref.Expr.(*ast.Ident); ok {
+					if def := f.local(target); def != nil {
+						f.aliases[n.Name] = def
+					}
+				}
+			}
+
+		case *ast.AssignExpr:
+			if spawn == nil {
+				f.recordWrite(n.Target, n.Span(), loops)
+			}
+
+		case *ast.Ident:
+			if spawn != nil {
+				f.capture(n, spawn)
+			}
+			return false
+		}
+		return true
+	})
+}
+
+// capture records ident, used inside the task of spawn, if it refers to a
+// local of the parent
+func (f *raceFinder) capture(ident *ast.Ident, spawn *spawnSite) {
+	def := f.local(ident)
+	if def == nil || within(def.Span(), spawn.span) {
+		return // Not a local of the parent
+	}
+	for _, captured := range []*ast.Ident{def, f.aliases[def]} {
+		if _, seen := spawn.captures[captured]; captured != nil && !seen {
+			spawn.captures[captured] = ident
+		}
+	}
+}
+
+// captureArg records what an argument of a spawned call shares with the
+// task. Arguments are evaluated before the spawn and passed by value, so
+// only references share a variable: `&x`, or a variable holding one.
+func (f *raceFinder) captureArg(arg ast.Expr, spawn *spawnSite) {
+	switch a := arg.(type) {
+	case *ast.PrefixExpr:
+		if target, ok := a.Expr.(*ast.Ident); ok && (a.Op == lexer.AMPERSAND || a.Op == lexer.REF_MUT) {
+			f.capture(target, spawn)
+		}
+	case *ast.Ident:
+		if def := f.local(a); def != nil && f.aliases[def] != nil {
+			f.capture(a, spawn)
+		}
+	}
+}
+
+// recordWrite records the variable an assignment to target modifies: the
+// variable at its root, or the one it refers to when written through
+func (f *raceFinder) recordWrite(target ast.Expr, span lexer.Span, loops []lexer.Span) {
+	through := false
+	for {
+		switch t := target.(type) {
+		case *ast.FieldExpr:
+			target = t.Target
+			continue
+		case *ast.IndexExpr:
+			target = t.Target
+			continue
+		case *ast.PrefixExpr:
+			if t.Op == lexer.ASTERISK {
+				target, through = t.Expr, true
+				continue
+			}
+		case *ast.Ident:
+			def := f.local(t)
+			if def == nil {
+				return
+			}
+			if alias := f.aliases[def]; through && alias != nil {
+				def = alias
+			}
+			f.writes = append(f.writes, mutation{span: span, def: def, loops: loops})
+		}
+		return
+	}
+}
+
+// within reports whether span a lies inside span b
+func within(a, b lexer.Span) bool {
+	return a.Filename == b.Filename && a.Start >= b.Start && a.End <= b.End
+}
@@ -0,0 +1,148 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// races runs DetectRaces over src, ignoring type errors: the checker rejects
+// some of the captures these tests are about
+func races(t *testing.T, src string) []diagnostics.Diagnostic {
+	t.Helper()
+	p := parser.New(src, parser.WithFilename("races.mal"))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	checker := types.NewChecker()
+	checker.CheckWithFilename(file, "races.mal")
+	reporter := diagnostics.NewReporter()
+	DetectRaces(file, checker, reporter)
+	return reporter.Diagnostics()
+}
+
+func TestDetectRaces(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []int // Lines of the reported modifications
+	}{
+		{
+			name: "modified after spawn",
+			src: `package main;
+fn main() {
+    let mut x = 1;
+    spawn {
+        println(x);
+    };
+    x = 2;
+}
+`,
+			want: []int{7},
+		},
+		{
+			name: "modified before spawn",
+			src: `package main;
+fn main() {
+    let mut x = 1;
+    x = 2;
+    spawn {
+        println(x);
+    };
+}
+`,
+		},
+		{
+			name: "reference passed to spawned call",
+			src: `package main;
+fn worker(p: &int) {
+    println(*p);
+}
+fn main() {
+    let mut x = 1;
+    spawn worker(&x);
+    x = 2;
+}
+`,
+			want: []int{8},
+		},
+		{
+			name: "value passed to spawned call",
+			src: `package main;
+fn worker(v: int) {
+    println(v);
+}
+fn main() {
+    let mut x = 1;
+    spawn worker(x);
+    x = 2;
+}
+`,
+		},
+		{
+			name: "written through a reference",
+			src: `package main;
+fn main() {
+    let mut x = 1;
+    let p = &mut x;
+    spawn {
+        println(x);
+    };
+    *p = 2;
+}
+`,
+			want: []int{8},
+		},
+		{
+			name: "modified before spawn in a loop",
+			src: `package main;
+fn main() {
+    let mut x = 0;
+    while x < 3 {
+        x = x + 1;
+        spawn {
+            println(x);
+        };
+    }
+}
+`,
+			want: []int{5},
+		},
+		{
+			name: "declared inside the loop",
+			src: `package main;
+fn main() {
+    let mut i = 0;
+    while i < 3 {
+        let mut x = i;
+        x = x + 1;
+        spawn {
+            println(x);
+        };
+        i = i + 1;
+    }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := races(t, tt.src)
+			if len(ds) != len(tt.want) {
+				t.Fatalf("got %d diagnostics, want %d: %v", len(ds), len(tt.want), ds)
+			}
+			for i, d := range ds {
+				if d.Rule != diagnostics.RuleDataRace || d.Pos.Line != tt.want[i] {
+					t.Errorf("diagnostic %d = %v, want a data race at line %d", i, d, tt.want[i])
+				}
+				if len(d.Related) != 1 || d.Related[0].Pos.Filename != "races.mal" {
+					t.Errorf("related = %+v", d.Related)
+				}
+			}
+		})
+	}
+}
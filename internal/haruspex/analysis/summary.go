@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/liveir"
+)
+
+// Summary describes what a function computes, so that its callers can be
+// analyzed without analyzing it again.
+type Summary struct {
+	Params []string
+	// Returns is the value the function returns on every path, in terms of
+	// Params. It is nil when the paths disagree, the value depends on more
+	// than the parameters, or the function returns nothing.
+	Returns *liveir.SymExpr
+	// Diagnostics found analyzing the function itself.
+	Diagnostics []diagnostics.Diagnostic
+}
+
+// Summarize analyzes fn, using the summaries of the functions it calls, and
+// summarizes it.
+func (e *Engine) Summarize(fn *liveir.LiveFunction) (*Summary, error) {
+	reporter := diagnostics.NewReporter()
+	if _, err := e.Analyze(fn, reporter); err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{Diagnostics: reporter.Diagnostics()}
+	params := make(map[string]bool)
+	for _, p := range fn.Params {
+		if p.Expr != nil && p.Expr.Kind == liveir.SymVar {
+			summary.Params = append(summary.Params, p.Expr.Name)
+			params[p.Expr.Name] = true
+		}
+	}
+	if len(summary.Params) != len(fn.Params) {
+		return summary, nil
+	}
+
+	for i, ret := range e.returns {
+		if ret == nil || (i > 0 && !ret.Equals(e.returns[0])) {
+			return summary, nil
+		}
+	}
+	if len(e.returns) == 0 {
+		return summary, nil
+	}
+
+	closed := true
+	e.returns[0].Vars(func(name string) {
+		closed = closed && params[name]
+	})
+	if closed {
+		summary.Returns = e.returns[0]
+	}
+	return summary, nil
+}
+
+// SummaryCache holds summaries by the fingerprint of what they were computed
+// from, so that unchanged functions are not analyzed again.
+type SummaryCache struct {
+	entries map[string]*Summary
+	Hits    int
+	Misses  int
+}
+
+// NewSummaryCache creates an empty summary cache.
+func NewSummaryCache() *SummaryCache {
+	return &SummaryCache{entries: make(map[string]*Summary)}
+}
+
+// Get returns the summary cached for fingerprint, counting the hit or miss.
+func (c *SummaryCache) Get(fingerprint string) (*Summary, bool) {
+	s, ok := c.entries[fingerprint]
+	if ok {
+		c.Hits++
+	} else {
+		c.Misses++
+	}
+	return s, ok
+}
+
+// Put caches s for fingerprint.
+func (c *SummaryCache) Put(fingerprint string, s *Summary) {
+	c.entries[fingerprint] = s
+}
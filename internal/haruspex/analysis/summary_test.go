@@ -0,0 +1,46 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/liveir"
+)
+
+func TestSummarizeSubstitutesArguments(t *testing.T) {
+	a := liveir.LiveValue{ID: 1, Kind: liveir.ValueKindSymbolic, Expr: &liveir.SymExpr{Kind: liveir.SymVar, Name: "a"}}
+	one := liveir.LiveValue{ID: 2, Kind: liveir.ValueKindConcrete, Expr: &liveir.SymExpr{Kind: liveir.SymConst, Value: 1}}
+	sum := liveir.LiveValue{ID: 3, Kind: liveir.ValueKindSymbolic}
+	entry := &liveir.LiveBlock{Nodes: []liveir.LiveNode{
+		{Op: liveir.OpAdd, Inputs: []liveir.LiveExpr{a, one}, Outputs: []liveir.LiveValue{sum}},
+		{Op: liveir.OpReturn, Inputs: []liveir.LiveExpr{sum}},
+	}}
+	inc := &liveir.LiveFunction{Name: "inc", ID: "inc", Params: []liveir.LiveValue{a}, Entry: entry, Blocks: []*liveir.LiveBlock{entry}}
+
+	engine := NewEngine()
+	summary, err := engine.Summarize(inc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary.Returns.String(); got != "(a + 1)" {
+		t.Fatalf("inc returns %s", got)
+	}
+	engine.Summaries["inc"] = summary
+
+	// let x = inc(41);
+	arg := liveir.LiveValue{ID: 4, Kind: liveir.ValueKindConcrete, Expr: &liveir.SymExpr{Kind: liveir.SymConst, Value: 41}}
+	result := liveir.LiveValue{ID: 5, Kind: liveir.ValueKindSymbolic}
+	exit := &liveir.LiveBlock{ID: 1}
+	block := &liveir.LiveBlock{ID: 0, Next: []*liveir.LiveBlock{exit}, Nodes: []liveir.LiveNode{
+		{Op: liveir.OpCall, Target: "inc", Inputs: []liveir.LiveExpr{arg}, Outputs: []liveir.LiveValue{result}},
+		{Op: liveir.OpAssign, Target: "x", Inputs: []liveir.LiveExpr{result}},
+	}}
+	main := &liveir.LiveFunction{Name: "main", ID: "main", Entry: block, Blocks: []*liveir.LiveBlock{block, exit}}
+	states, err := engine.Analyze(main, diagnostics.NewReporter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := states[exit.ID].GetVar("x"); x.Kind != liveir.ValueKindConcrete || x.Expr.Value != 42 {
+		t.Errorf("x = %s, want concrete(42)", x)
+	}
+}
@@ -17,9 +17,12 @@ func (e *Engine) Transfer(state *SymState, node liveir.LiveNode) ([]*SymState, e
 		if len(node.Inputs) > 0 && node.Target != "" {
 			val := node.Inputs[0].(liveir.LiveValue)
 
-			// Resolve expression from Temps if available
+			// Resolve expression from Temps if available, in terms of the
+			// values variables hold rather than the variables themselves
 			if expr, ok := newState.Temps[val.ID]; ok {
-				val.Expr = expr
+				val.Expr = expr.Substitute(bindings(newState))
+			} else if val.Expr != nil {
+				val.Expr = val.Expr.Substitute(bindings(newState))
 			} else if val.Expr == nil {
 				// If no temp expr, check if it's a variable reference (SymVar)
 				// For now, if it's a direct variable load (not implemented yet), we might need to look it up
@@ -126,8 +129,30 @@ func (e *Engine) Transfer(state *SymState, node liveir.LiveNode) ([]*SymState, e
 			return []*SymState{trueState, falseState}, nil
 		}
 		return []*SymState{newState.Clone(), newState.Clone()}, nil
+	case liveir.OpCall:
+		if len(node.Outputs) > 0 {
+			newState.Temps[node.Outputs[0].ID] = e.callResult(newState, node)
+		}
+	case liveir.OpMakeChan, liveir.OpRecv:
+		// Channels and the values received from them stand for themselves
+		if len(node.Outputs) > 0 {
+			name := "chan"
+			if node.Op == liveir.OpRecv {
+				name = "recv"
+			}
+			newState.Temps[node.Outputs[0].ID] = &liveir.SymExpr{Kind: liveir.SymVar, Name: fmt.Sprintf("%s#%d", name, node.Outputs[0].ID)}
+		}
+	case liveir.OpSend, liveir.OpSpawn, liveir.OpSelect:
+		// No effect on values
 	case liveir.OpReturn:
-		// Handle return
+		// A bare return has the zero value as input
+		var expr *liveir.SymExpr
+		if len(node.Inputs) > 0 {
+			if val, ok := node.Inputs[0].(liveir.LiveValue); ok && val.ID != 0 {
+				expr = resolveExpr(newState, val).Substitute(bindings(newState))
+			}
+		}
+		e.returns = append(e.returns, expr)
 	default:
 		return nil, fmt.Errorf("unsupported operation: %v", node.Op)
 	}
@@ -135,6 +160,39 @@ func (e *Engine) Transfer(state *SymState, node liveir.LiveNode) ([]*SymState, e
 	return []*SymState{newState}, nil
 }
 
+// callResult is the value of a call: the summarized result of the callee
+// with the arguments in place of its parameters, or else a variable standing
+// for this call alone.
+func (e *Engine) callResult(state *SymState, node liveir.LiveNode) *liveir.SymExpr {
+	result := &liveir.SymExpr{Kind: liveir.SymVar, Name: fmt.Sprintf("call#%d", node.Outputs[0].ID)}
+	summary := e.Summaries[node.Target]
+	if summary == nil || summary.Returns == nil || len(summary.Params) != len(node.Inputs) {
+		return result
+	}
+
+	vars := bindings(state)
+	args := make(map[string]*liveir.SymExpr, len(summary.Params))
+	for i, param := range summary.Params {
+		arg, ok := node.Inputs[i].(liveir.LiveValue)
+		if !ok || arg.Kind == liveir.ValueKindUnknown {
+			return result
+		}
+		args[param] = resolveExpr(state, arg).Substitute(vars)
+	}
+	return summary.Returns.Substitute(args)
+}
+
+// bindings maps the variables of state whose values are known to them
+func bindings(state *SymState) map[string]*liveir.SymExpr {
+	vars := make(map[string]*liveir.SymExpr, len(state.Vars))
+	for id, val := range state.Vars {
+		if val.Kind != liveir.ValueKindUnknown && val.Expr != nil {
+			vars[string(id)] = val.Expr
+		}
+	}
+	return vars
+}
+
 func resolveExpr(state *SymState, val liveir.LiveValue) *liveir.SymExpr {
 	if expr, ok := state.Temps[val.ID]; ok {
 		return expr
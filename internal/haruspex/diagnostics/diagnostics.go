@@ -29,11 +29,66 @@ func (k DiagnosticKind) String() string {
 	}
 }
 
+// Rule IDs identify what a diagnostic is about, for tools that filter or
+// suppress diagnostics by kind.
+const (
+	RuleUnreachable = "Unreachable" // Code no path enters
+	RuleDeadBranch  = "DeadBranch"  // A branch its condition never takes
+	RuleParseError  = "ParseError"
+	RuleTypeError   = "TypeError" // Unless the checker gave an error code
+
+	RuleUnusedVariable  = "UnusedVariable"
+	RuleUnusedParameter = "UnusedParameter"
+	RuleUnusedFunction  = "UnusedFunction"
+	RuleUnusedField     = "UnusedField"
+
+	RuleDataRace = "DataRace" // A spawned task and its parent both use a variable
+	RuleDeadlock = "Deadlock" // A channel operation that blocks forever
+)
+
+// Rules describes the rules Haruspex itself reports.
+var Rules = map[string]string{
+	RuleUnreachable: "Code that no execution path can reach",
+	RuleDeadBranch:  "Branch that cannot be taken because its condition always has the other value",
+	RuleParseError:  "Source that could not be parsed",
+	RuleTypeError:   "Source that does not type check",
+
+	RuleUnusedVariable:  "Local variable that is never read",
+	RuleUnusedParameter: "Function parameter that is never used",
+	RuleUnusedFunction:  "Private function that is never called",
+	RuleUnusedField:     "Field of a private struct that is never read",
+
+	RuleDataRace: "Variable modified by a function after a task it spawned captured it",
+	RuleDeadlock: "Channel operation or select that no other operation can complete",
+}
+
 // Diagnostic represents a single message to the user.
 type Diagnostic struct {
 	Pos     lexer.Span
 	Message string
 	Kind    DiagnosticKind
+	Rule    string // One of the Rule constants, or a checker error code
+	Fixes   []Fix
+	Related []Related
+}
+
+// Related is another location that explains a diagnostic.
+type Related struct {
+	Pos     lexer.Span
+	Message string
+}
+
+// Fix is a suggested change that resolves a diagnostic.
+type Fix struct {
+	Message string
+	Edits   []Edit
+}
+
+// Edit replaces the text between the Start and End offsets of Span with
+// NewText.
+type Edit struct {
+	Span    lexer.Span
+	NewText string
 }
 
 func (d Diagnostic) String() string {
@@ -62,6 +117,11 @@ func (r *Reporter) Report(kind DiagnosticKind, pos lexer.Span, format string, ar
 	})
 }
 
+// Add adds a diagnostic built by the caller, such as one with a rule or fixes.
+func (r *Reporter) Add(d Diagnostic) {
+	r.diagnostics = append(r.diagnostics, d)
+}
+
 // Error reports an error.
 func (r *Reporter) Error(pos lexer.Span, format string, args ...interface{}) {
 	r.Report(KindError, pos, format, args...)
@@ -77,12 +137,15 @@ func (r *Reporter) Info(pos lexer.Span, format string, args ...interface{}) {
 	r.Report(KindInfo, pos, format, args...)
 }
 
-// Diagnostics returns all collected diagnostics, sorted by position.
+// Diagnostics returns all collected diagnostics, sorted by file and position.
 func (r *Reporter) Diagnostics() []Diagnostic {
 	sorted := make([]Diagnostic, len(r.diagnostics))
 	copy(sorted, r.diagnostics)
 
 	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Pos.Filename != sorted[j].Pos.Filename {
+			return sorted[i].Pos.Filename < sorted[j].Pos.Filename
+		}
 		if sorted[i].Pos.Line != sorted[j].Pos.Line {
 			return sorted[i].Pos.Line < sorted[j].Pos.Line
 		}
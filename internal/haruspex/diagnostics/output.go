@@ -0,0 +1,359 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+// Format is a way of writing diagnostics out.
+type Format string
+
+const (
+	FormatText  Format = "text"  // One line per diagnostic, for people
+	FormatJSON  Format = "json"  // A JSON document, for scripts
+	FormatSARIF Format = "sarif" // SARIF 2.1.0, for CI and code review tools
+)
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON, FormatSARIF:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want text, json or sarif)", s)
+}
+
+// Output writes diagnostics in a format.
+type Output struct {
+	Format Format
+	// Root, when set, makes file paths relative to it. SARIF locations are
+	// then relative to the %SRCROOT% base.
+	Root string
+}
+
+// Write writes ds to w.
+func (o Output) Write(w io.Writer, ds []Diagnostic) error {
+	switch o.Format {
+	case FormatJSON:
+		return writeJSON(w, o.jsonDiagnostics(ds))
+	case FormatSARIF:
+		return writeJSON(w, o.sarifLog(ds))
+	default:
+		for _, d := range ds {
+			d.Pos.Filename = o.path(d.Pos.Filename)
+			if _, err := fmt.Fprintln(w, d); err != nil {
+				return err
+			}
+			for _, r := range d.Related {
+				if _, err := fmt.Fprintf(w, "  %s:%d:%d: note: %s\n", o.path(r.Pos.Filename), r.Pos.Line, r.Pos.Column, r.Message); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (o Output) path(filename string) string {
+	if o.Root == "" || filename == "" {
+		return filename
+	}
+	if rel, err := filepath.Rel(o.Root, filename); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filename
+}
+
+// FromChecker converts an error of the type checker, keeping its fixes.
+func FromChecker(d diag.Diagnostic) Diagnostic {
+	span := func(s diag.Span) lexer.Span {
+		return lexer.Span{Filename: s.Filename, Line: s.Line, Column: s.Column, Start: s.Start, End: s.End}
+	}
+	out := Diagnostic{
+		Pos:     span(d.Span),
+		Message: d.Message,
+		Kind:    KindError,
+		Rule:    string(d.Code),
+	}
+	if d.Severity == diag.SeverityWarning {
+		out.Kind = KindWarning
+	}
+	if out.Rule == "" {
+		out.Rule = RuleTypeError
+	}
+	for _, fix := range d.Fixes {
+		f := Fix{Message: fix.Message}
+		for _, e := range fix.Edits {
+			f.Edits = append(f.Edits, Edit{Span: span(e.Span), NewText: e.NewText})
+		}
+		out.Fixes = append(out.Fixes, f)
+	}
+	return out
+}
+
+type jsonSpan struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Start  int    `json:"start"` // Offsets in characters, end exclusive
+	End    int    `json:"end"`
+}
+
+type jsonEdit struct {
+	Span    jsonSpan `json:"span"`
+	NewText string   `json:"newText"`
+}
+
+type jsonFix struct {
+	Message string     `json:"message"`
+	Edits   []jsonEdit `json:"edits"`
+}
+
+type jsonRelated struct {
+	Message string   `json:"message"`
+	Span    jsonSpan `json:"span"`
+}
+
+type jsonDiagnostic struct {
+	Rule     string        `json:"rule,omitempty"`
+	Severity string        `json:"severity"`
+	Message  string        `json:"message"`
+	Span     jsonSpan      `json:"span"`
+	Related  []jsonRelated `json:"related,omitempty"`
+	Fixes    []jsonFix     `json:"fixes,omitempty"`
+}
+
+func (o Output) jsonSpan(s lexer.Span) jsonSpan {
+	return jsonSpan{File: o.path(s.Filename), Line: s.Line, Column: s.Column, Start: s.Start, End: s.End}
+}
+
+func (o Output) jsonDiagnostics(ds []Diagnostic) any {
+	out := struct {
+		Diagnostics []jsonDiagnostic `json:"diagnostics"`
+	}{Diagnostics: []jsonDiagnostic{}}
+	for _, d := range ds {
+		jd := jsonDiagnostic{
+			Rule:     d.Rule,
+			Severity: strings.ToLower(d.Kind.String()),
+			Message:  d.Message,
+			Span:     o.jsonSpan(d.Pos),
+		}
+		for _, r := range d.Related {
+			jd.Related = append(jd.Related, jsonRelated{Message: r.Message, Span: o.jsonSpan(r.Pos)})
+		}
+		for _, fix := range d.Fixes {
+			jf := jsonFix{Message: fix.Message, Edits: []jsonEdit{}}
+			for _, e := range fix.Edits {
+				jf.Edits = append(jf.Edits, jsonEdit{Span: o.jsonSpan(e.Span), NewText: e.NewText})
+			}
+			jd.Fixes = append(jd.Fixes, jf)
+		}
+		out.Diagnostics = append(out.Diagnostics, jd)
+	}
+	return out
+}
+
+// The subset of SARIF 2.1.0 that Haruspex produces
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	RuleIndex        *int            `json:"ruleIndex,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+	Fixes            []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	CharOffset  int `json:"charOffset"`
+	CharLength  int `json:"charLength"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion   `json:"deletedRegion"`
+	InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+}
+
+func (o Output) artifact(filename string) sarifArtifactLocation {
+	path := o.path(filename)
+	if path != filename {
+		return sarifArtifactLocation{URI: path, URIBaseID: "%SRCROOT%"}
+	}
+	if filepath.IsAbs(path) {
+		return sarifArtifactLocation{URI: "file://" + filepath.ToSlash(path)}
+	}
+	return sarifArtifactLocation{URI: filepath.ToSlash(path)}
+}
+
+// region locates s by offsets, which SARIF counts in the same code points as
+// the lexer when the run's columnKind is unicodeCodePoints.
+func region(s lexer.Span) sarifRegion {
+	return sarifRegion{StartLine: s.Line, StartColumn: s.Column, CharOffset: s.Start, CharLength: max(s.End-s.Start, 0)}
+}
+
+func (o Output) sarifLog(ds []Diagnostic) any {
+	// Every rule used gets an entry, described when it is one of Haruspex's
+	var ids []string
+	seen := make(map[string]bool)
+	for _, d := range ds {
+		if d.Rule != "" && !seen[d.Rule] {
+			seen[d.Rule] = true
+			ids = append(ids, d.Rule)
+		}
+	}
+	sort.Strings(ids)
+	index := make(map[string]int, len(ids))
+	rules := []sarifRule{}
+	for i, id := range ids {
+		index[id] = i
+		rule := sarifRule{ID: id}
+		if text, ok := Rules[id]; ok {
+			rule.ShortDescription = &sarifMessage{Text: text}
+		}
+		rules = append(rules, rule)
+	}
+
+	results := []sarifResult{}
+	for _, d := range ds {
+		result := sarifResult{
+			RuleID:  d.Rule,
+			Level:   sarifLevel(d.Kind),
+			Message: sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: o.artifact(d.Pos.Filename),
+				Region:           region(d.Pos),
+			}}},
+		}
+		if i, ok := index[d.Rule]; ok {
+			result.RuleIndex = &i
+		}
+		for i, r := range d.Related {
+			id := i
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				ID: &id,
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: o.artifact(r.Pos.Filename),
+					Region:           region(r.Pos),
+				},
+				Message: &sarifMessage{Text: r.Message},
+			})
+		}
+		for _, fix := range d.Fixes {
+			result.Fixes = append(result.Fixes, o.sarifFix(fix))
+		}
+		results = append(results, result)
+	}
+
+	return sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool:       sarifTool{Driver: sarifDriver{Name: "haruspex", Rules: rules}},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	}
+}
+
+// sarifFix groups the edits of fix by file, as SARIF changes are per artifact
+func (o Output) sarifFix(fix Fix) sarifFix {
+	out := sarifFix{Description: sarifMessage{Text: fix.Message}, ArtifactChanges: []sarifArtifactChange{}}
+	changes := make(map[string]int)
+	for _, e := range fix.Edits {
+		i, ok := changes[e.Span.Filename]
+		if !ok {
+			i = len(out.ArtifactChanges)
+			changes[e.Span.Filename] = i
+			out.ArtifactChanges = append(out.ArtifactChanges, sarifArtifactChange{ArtifactLocation: o.artifact(e.Span.Filename)})
+		}
+		replacement := sarifReplacement{DeletedRegion: sarifRegion{CharOffset: e.Span.Start, CharLength: max(e.Span.End-e.Span.Start, 0)}}
+		if e.NewText != "" {
+			replacement.InsertedContent = &sarifMessage{Text: e.NewText}
+		}
+		out.ArtifactChanges[i].Replacements = append(out.ArtifactChanges[i].Replacements, replacement)
+	}
+	return out
+}
+
+func sarifLevel(k DiagnosticKind) string {
+	switch k {
+	case KindError:
+		return "error"
+	case KindWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
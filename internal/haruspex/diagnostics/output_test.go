@@ -0,0 +1,111 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+func sampleDiagnostics() []Diagnostic {
+	body := lexer.Span{Filename: "/src/main.mal", Line: 4, Column: 16, Start: 40, End: 60}
+	return []Diagnostic{
+		{
+			Pos:     body,
+			Message: "Unreachable code (unsatisfiable path)",
+			Kind:    KindWarning,
+			Rule:    RuleDeadBranch,
+			Fixes:   []Fix{{Message: "Remove the body of the dead branch", Edits: []Edit{{Span: body, NewText: "{}"}}}},
+		},
+		FromChecker(diag.Diagnostic{
+			Severity: diag.SeverityError,
+			Code:     diag.CodeTypeUndefinedIdentifier,
+			Message:  "undefined identifier `totl`",
+			Span:     diag.Span{Filename: "/elsewhere/lib.mal", Line: 2, Column: 5, Start: 9, End: 13},
+		}),
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (Output{Format: FormatJSON, Root: "/src"}).Write(&buf, sampleDiagnostics()); err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Diagnostics []jsonDiagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if len(out.Diagnostics) != 2 {
+		t.Fatalf("got %d diagnostics", len(out.Diagnostics))
+	}
+
+	d := out.Diagnostics[0]
+	if d.Rule != RuleDeadBranch || d.Severity != "warning" || d.Span.File != "main.mal" || d.Span.Line != 4 || d.Span.Start != 40 {
+		t.Errorf("diagnostic = %+v", d)
+	}
+	if len(d.Fixes) != 1 || d.Fixes[0].Edits[0].NewText != "{}" || d.Fixes[0].Edits[0].Span.End != 60 {
+		t.Errorf("fixes = %+v", d.Fixes)
+	}
+
+	// Files outside the root keep their paths, and checker codes are rules
+	d = out.Diagnostics[1]
+	if d.Rule != string(diag.CodeTypeUndefinedIdentifier) || d.Severity != "error" || d.Span.File != "/elsewhere/lib.mal" {
+		t.Errorf("diagnostic = %+v", d)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (Output{Format: FormatSARIF, Root: "/src"}).Write(&buf, sampleDiagnostics()); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("log = %+v", log)
+	}
+	run := log.Runs[0]
+
+	rules := run.Tool.Driver.Rules
+	if len(rules) != 2 || rules[0].ID != RuleDeadBranch || rules[0].ShortDescription == nil || rules[1].ShortDescription != nil {
+		t.Errorf("rules = %+v", rules)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("got %d results", len(run.Results))
+	}
+
+	r := run.Results[0]
+	loc := r.Locations[0].PhysicalLocation
+	if r.RuleID != RuleDeadBranch || *r.RuleIndex != 0 || r.Level != "warning" {
+		t.Errorf("result = %+v", r)
+	}
+	if loc.ArtifactLocation != (sarifArtifactLocation{URI: "main.mal", URIBaseID: "%SRCROOT%"}) || loc.Region.CharOffset != 40 || loc.Region.CharLength != 20 {
+		t.Errorf("location = %+v", loc)
+	}
+	if len(r.Fixes) != 1 || r.Fixes[0].ArtifactChanges[0].Replacements[0].InsertedContent.Text != "{}" {
+		t.Errorf("fixes = %+v", r.Fixes)
+	}
+
+	if r := run.Results[1]; r.Level != "error" || r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "file:///elsewhere/lib.mal" {
+		t.Errorf("result = %+v", r)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"text", "json", "sarif"} {
+		if f, err := ParseFormat(s); err != nil || string(f) != s {
+			t.Errorf("ParseFormat(%q) = %q, %v", s, f, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected an error for xml")
+	}
+}
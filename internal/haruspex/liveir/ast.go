@@ -116,6 +116,11 @@ const (
 	OpAnd
 	OpOr
 	OpNot
+	OpMakeChan // Makes a channel; its input is the capacity
+	OpSend     // Sends its input on the channel named by Target
+	OpRecv     // Receives from the channel named by Target
+	OpSpawn    // Starts a task running the function Target with the inputs
+	OpSelect   // Ends a block whose successors are the arms of a select
 )
 
 // LiveNode represents a node in the LiveIR control flow graph.
@@ -123,17 +128,22 @@ type LiveNode struct {
 	Op      LiveOp
 	Inputs  []LiveExpr
 	Outputs []LiveValue
-	Target  string     // Target variable name for OpAssign
+	Target  string     // Target variable name for OpAssign, channel variable for OpSend and OpRecv, callee ID for OpCall and OpSpawn ("" if unresolved)
 	Pos     lexer.Span // Source position
 }
 
 // LiveFunction represents a function in LiveIR.
 type LiveFunction struct {
 	Name   string
+	ID     string     // Unique across a project, see FunctionID
+	Pos    lexer.Span // Span of the declaration
 	Params []LiveValue
 	Locals []LiveValue
 	Entry  *LiveBlock
 	Blocks []*LiveBlock
+	// Tasks are the spawned blocks and closures of the function, lowered as
+	// functions of their own that refer to its variables by name
+	Tasks []*LiveFunction
 }
 
 // LiveBlock represents a basic block in the CFG.
@@ -142,4 +152,15 @@ type LiveBlock struct {
 	Nodes []LiveNode
 	Next  []*LiveBlock
 	Pos   lexer.Span // Position of the block start (approximate)
+	Body  lexer.Span // The braced body of the branch the block starts, if any
+	// Arm is the channel operation a select arm starting the block waits
+	// on, which is also among its nodes. It is nil for other blocks and for
+	// default and after arms.
+	Arm *LiveNode
+}
+
+// FunctionID identifies the function name declared in file, so that calls
+// resolve to the same function whichever file of a project they are in.
+func FunctionID(file, name string) string {
+	return file + "#" + name
 }
@@ -11,6 +11,9 @@ import (
 // Lowerer converts type-checked AST to LiveIR.
 type Lowerer struct {
 	TypeInfo map[ast.Node]types.Type
+	// Uses resolves the callees of calls to the functions they name. When
+	// nil, calls are lowered without a callee and their results are unknown.
+	Uses map[*ast.Ident]*types.Symbol
 
 	currentFunc  *LiveFunction
 	currentBlock *LiveBlock
@@ -59,6 +62,8 @@ func (l *Lowerer) LowerFunction(decl *ast.FnDecl) (*LiveFunction, error) {
 
 	fn := &LiveFunction{
 		Name:   decl.Name.Name,
+		ID:     FunctionID(decl.Span().Filename, decl.Name.Name),
+		Pos:    decl.Span(),
 		Params: make([]LiveValue, 0),
 		Locals: make([]LiveValue, 0),
 		Blocks: make([]*LiveBlock, 0),
@@ -68,16 +73,26 @@ func (l *Lowerer) LowerFunction(decl *ast.FnDecl) (*LiveFunction, error) {
 	// Create entry block
 	entryBlock := l.newBlock(decl.Span())
 	fn.Entry = entryBlock
-	fn.Blocks = append(fn.Blocks, entryBlock)
 	l.currentBlock = entryBlock
 
-	// TODO: Lower parameters
+	// Parameters are symbolic: their values are whatever the caller passes
+	for _, param := range decl.Params {
+		val := l.newValue(ValueKindSymbolic, l.TypeInfo[param])
+		val.Expr = &SymExpr{Kind: SymVar, Name: param.Name.Name}
+		fn.Params = append(fn.Params, val)
+	}
 
 	// Lower body
 	if decl.Body != nil {
 		if err := l.lowerBlock(decl.Body); err != nil {
 			return nil, err
 		}
+		// The tail expression of the body is the function's result
+		if decl.Body.Tail != nil {
+			if err := l.lowerReturn(decl.Body.Tail, decl.Body.Tail.Span()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return fn, nil
@@ -16,9 +16,22 @@ func (l *Lowerer) lowerExpr(expr ast.Expr) (LiveValue, error) {
 	case *ast.BoolLit:
 		return l.lowerBoolLit(e)
 	case *ast.InfixExpr:
+		if e.Op == lexer.LARROW {
+			return l.lowerSend(e)
+		}
 		return l.lowerInfixExpr(e)
+	case *ast.PrefixExpr:
+		if e.Op == lexer.LARROW {
+			return l.lowerRecv(e)
+		}
+		return LiveValue{}, fmt.Errorf("unsupported prefix operator: %s", e.Op)
 	case *ast.Ident:
 		return l.lowerIdent(e)
+	case *ast.CallExpr:
+		if capacity, ok := channelCapacity(e); ok {
+			return l.lowerMakeChan(e, capacity)
+		}
+		return l.lowerCallExpr(e)
 	default:
 		return LiveValue{}, fmt.Errorf("unsupported expression type: %T", e)
 	}
@@ -73,6 +86,147 @@ func (l *Lowerer) lowerInfixExpr(expr *ast.InfixExpr) (LiveValue, error) {
 	return result, nil
 }
 
+// lowerCallExpr emits a call to the function the callee resolves to. An
+// argument that cannot be lowered is passed as an unknown value, so that a
+// call such as println("...") does not stop the lowering of its caller.
+func (l *Lowerer) lowerCallExpr(expr *ast.CallExpr) (LiveValue, error) {
+	var args []LiveExpr
+	for _, arg := range expr.Args {
+		val, err := l.lowerExpr(arg)
+		if err != nil {
+			val = l.newValue(ValueKindUnknown, l.TypeInfo[arg])
+		}
+		args = append(args, val)
+	}
+
+	result := l.newValue(ValueKindSymbolic, l.TypeInfo[expr])
+
+	node := LiveNode{
+		Op:      OpCall,
+		Inputs:  args,
+		Outputs: []LiveValue{result},
+		Target:  l.calleeID(expr.Callee),
+		Pos:     expr.Span(),
+	}
+	l.emit(node)
+
+	return result, nil
+}
+
+// channelCapacity reports whether call makes a channel, with make[chan T](n)
+// or Channel::new(n), and returns the capacity it is made with, which is nil
+// when the call gives none.
+func channelCapacity(call *ast.CallExpr) (ast.Expr, bool) {
+	callee := call.Callee
+	if idx, ok := callee.(*ast.IndexExpr); ok {
+		callee = idx.Target
+	}
+	if ident, ok := callee.(*ast.Ident); ok && ident.Name == "make" {
+		if len(call.Args) == 0 {
+			return nil, true
+		}
+		return call.Args[len(call.Args)-1], true
+	}
+
+	path, ok := callee.(*ast.InfixExpr)
+	if !ok || path.Op != lexer.DOUBLE_COLON {
+		return nil, false
+	}
+	if right, ok := path.Right.(*ast.Ident); !ok || right.Name != "new" {
+		return nil, false
+	}
+	left := path.Left
+	if idx, ok := left.(*ast.IndexExpr); ok {
+		left = idx.Target
+	}
+	if ident, ok := left.(*ast.Ident); !ok || ident.Name != "Channel" {
+		return nil, false
+	}
+	if len(call.Args) == 0 {
+		return nil, true
+	}
+	return call.Args[0], true
+}
+
+// lowerMakeChan emits the making of a channel. A capacity that cannot be
+// lowered is unknown, and a missing one is zero.
+func (l *Lowerer) lowerMakeChan(expr *ast.CallExpr, capacity ast.Expr) (LiveValue, error) {
+	size := l.newValue(ValueKindConcrete, &types.Primitive{Kind: types.Int})
+	size.Expr = &SymExpr{Kind: SymConst, Value: 0}
+	if capacity != nil {
+		val, err := l.lowerExpr(capacity)
+		if err != nil {
+			val = l.newValue(ValueKindUnknown, l.TypeInfo[capacity])
+		}
+		size = val
+	}
+
+	result := l.newValue(ValueKindSymbolic, l.TypeInfo[expr])
+	l.emit(LiveNode{
+		Op:      OpMakeChan,
+		Inputs:  []LiveExpr{size},
+		Outputs: []LiveValue{result},
+		Pos:     expr.Span(),
+	})
+	return result, nil
+}
+
+// channelName returns the variable expr names, or "" if it is not a variable
+func channelName(expr ast.Expr) string {
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// lowerSend emits ch <- value, whose own value is unknown
+func (l *Lowerer) lowerSend(expr *ast.InfixExpr) (LiveValue, error) {
+	val, err := l.lowerExpr(expr.Right)
+	if err != nil {
+		val = l.newValue(ValueKindUnknown, l.TypeInfo[expr.Right])
+	}
+	l.emit(LiveNode{
+		Op:     OpSend,
+		Inputs: []LiveExpr{val},
+		Target: channelName(expr.Left),
+		Pos:    expr.Span(),
+	})
+	return l.newValue(ValueKindUnknown, nil), nil
+}
+
+// lowerRecv emits <-ch
+func (l *Lowerer) lowerRecv(expr *ast.PrefixExpr) (LiveValue, error) {
+	result := l.newValue(ValueKindSymbolic, l.TypeInfo[expr])
+	l.emit(LiveNode{
+		Op:      OpRecv,
+		Outputs: []LiveValue{result},
+		Target:  channelName(expr.Expr),
+		Pos:     expr.Span(),
+	})
+	return result, nil
+}
+
+// calleeID returns the FunctionID of the function callee names, either
+// directly or as module::name, or "" if it names none.
+func (l *Lowerer) calleeID(callee ast.Expr) string {
+	ident, ok := callee.(*ast.Ident)
+	if path, isPath := callee.(*ast.InfixExpr); isPath && path.Op == lexer.DOUBLE_COLON {
+		ident, ok = path.Right.(*ast.Ident)
+	}
+	if !ok || l.Uses == nil {
+		return ""
+	}
+	sym := l.Uses[ident]
+	if sym == nil {
+		return ""
+	}
+	decl, ok := sym.DefNode.(*ast.FnDecl)
+	if !ok {
+		return ""
+	}
+	return FunctionID(decl.Span().Filename, decl.Name.Name)
+}
+
 func (l *Lowerer) mapBinaryOp(op lexer.TokenType) LiveOp {
 	switch op {
 	case lexer.PLUS:
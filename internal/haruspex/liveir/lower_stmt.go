@@ -17,6 +17,10 @@ func (l *Lowerer) lowerStmt(stmt ast.Stmt) error {
 		return l.lowerReturnStmt(s)
 	case *ast.ExprStmt:
 		return l.lowerExprStmt(s)
+	case *ast.SpawnStmt:
+		return l.lowerSpawnStmt(s)
+	case *ast.SelectStmt:
+		return l.lowerSelectStmt(s)
 	default:
 		return fmt.Errorf("unsupported statement type: %T", s)
 	}
@@ -58,6 +62,7 @@ func (l *Lowerer) lowerIfStmt(stmt *ast.IfStmt) error {
 
 	// 2. Create blocks
 	thenBlock := l.newBlock(clause.Body.Span())
+	thenBlock.Body = clause.Body.Span()
 	var elseSpan lexer.Span
 	if stmt.Else != nil {
 		elseSpan = stmt.Else.Span()
@@ -65,6 +70,9 @@ func (l *Lowerer) lowerIfStmt(stmt *ast.IfStmt) error {
 		elseSpan = stmt.Span() // Fallback
 	}
 	elseBlock := l.newBlock(elseSpan)
+	if stmt.Else != nil {
+		elseBlock.Body = elseSpan
+	}
 	mergeBlock := l.newBlock(stmt.Span()) // Merge block pos is end of if?
 
 	// 3. Create branch node
@@ -103,11 +111,16 @@ func (l *Lowerer) lowerIfStmt(stmt *ast.IfStmt) error {
 }
 
 func (l *Lowerer) lowerReturnStmt(stmt *ast.ReturnStmt) error {
+	return l.lowerReturn(stmt.Value, stmt.Span())
+}
+
+// lowerReturn returns value, which is nil for a bare return
+func (l *Lowerer) lowerReturn(value ast.Expr, pos lexer.Span) error {
 	var val LiveValue
 	var err error
 
-	if stmt.Value != nil {
-		val, err = l.lowerExpr(stmt.Value)
+	if value != nil {
+		val, err = l.lowerExpr(value)
 		if err != nil {
 			return err
 		}
@@ -116,15 +129,13 @@ func (l *Lowerer) lowerReturnStmt(stmt *ast.ReturnStmt) error {
 	node := LiveNode{
 		Op:     OpReturn,
 		Inputs: []LiveExpr{val},
-		Pos:    stmt.Span(),
+		Pos:    pos,
 	}
 	l.emit(node)
 
-	l.emit(node)
-
 	// Terminate block: create a new unlinked block for any subsequent code
 	// This block will be unreachable unless jumped to (which isn't possible here)
-	deadBlock := l.newBlock(stmt.Span()) // Use return stmt span as start of dead block? Or next stmt?
+	deadBlock := l.newBlock(pos) // Use return stmt span as start of dead block? Or next stmt?
 	l.currentBlock = deadBlock
 
 	return nil
@@ -134,3 +145,120 @@ func (l *Lowerer) lowerExprStmt(stmt *ast.ExprStmt) error {
 	_, err := l.lowerExpr(stmt.Expr)
 	return err
 }
+
+// lowerSpawnStmt emits the start of a task. A spawned call starts the
+// function called; a spawned block or closure is lowered as a task of the
+// current function.
+func (l *Lowerer) lowerSpawnStmt(stmt *ast.SpawnStmt) error {
+	var target string
+	var argExprs []ast.Expr
+	switch {
+	case stmt.Call != nil:
+		target = l.calleeID(stmt.Call.Callee)
+		argExprs = stmt.Call.Args
+	case stmt.Block != nil:
+		id, err := l.lowerTask(nil, stmt.Block, stmt.Span())
+		if err != nil {
+			return err
+		}
+		target = id
+	case stmt.FunctionLiteral != nil:
+		id, err := l.lowerTask(stmt.FunctionLiteral.Params, stmt.FunctionLiteral.Body, stmt.Span())
+		if err != nil {
+			return err
+		}
+		target = id
+		argExprs = stmt.Args
+	}
+
+	var args []LiveExpr
+	for _, arg := range argExprs {
+		val, err := l.lowerExpr(arg)
+		if err != nil {
+			val = l.newValue(ValueKindUnknown, l.TypeInfo[arg])
+		}
+		args = append(args, val)
+	}
+	l.emit(LiveNode{
+		Op:     OpSpawn,
+		Inputs: args,
+		Target: target,
+		Pos:    stmt.Span(),
+	})
+	return nil
+}
+
+// lowerTask lowers the body of a spawned block or closure as a task of the
+// current function, and returns its ID
+func (l *Lowerer) lowerTask(params []*ast.Param, body *ast.BlockExpr, pos lexer.Span) (string, error) {
+	parent, resume := l.currentFunc, l.currentBlock
+	defer func() {
+		l.currentFunc, l.currentBlock = parent, resume
+	}()
+
+	n := len(parent.Tasks) + 1
+	task := &LiveFunction{
+		Name: fmt.Sprintf("%s$spawn%d", parent.Name, n),
+		ID:   fmt.Sprintf("%s$spawn%d", parent.ID, n),
+		Pos:  pos,
+	}
+	l.currentFunc = task
+	task.Entry = l.newBlock(pos)
+	l.currentBlock = task.Entry
+
+	for _, param := range params {
+		val := l.newValue(ValueKindSymbolic, l.TypeInfo[param])
+		val.Expr = &SymExpr{Kind: SymVar, Name: param.Name.Name}
+		task.Params = append(task.Params, val)
+	}
+	if err := l.lowerBlock(body); err != nil {
+		return "", err
+	}
+	if body.Tail != nil {
+		if _, err := l.lowerExpr(body.Tail); err != nil {
+			return "", err
+		}
+	}
+
+	parent.Tasks = append(parent.Tasks, task)
+	return task.ID, nil
+}
+
+// lowerSelectStmt ends the current block with the select, whose arms are its
+// successors. Each arm starts with the channel operation it waits on.
+func (l *Lowerer) lowerSelectStmt(stmt *ast.SelectStmt) error {
+	l.emit(LiveNode{Op: OpSelect, Pos: stmt.Span()})
+	selectBlock := l.currentBlock
+	mergeBlock := l.newBlock(stmt.Span())
+
+	for _, c := range stmt.Cases {
+		arm := l.newBlock(c.Span())
+		arm.Body = c.Body.Span()
+		selectBlock.Next = append(selectBlock.Next, arm)
+		l.currentBlock = arm
+
+		if c.Comm != nil {
+			if err := l.lowerStmt(c.Comm); err != nil {
+				return err
+			}
+			for i := range arm.Nodes {
+				if op := arm.Nodes[i].Op; op == OpSend || op == OpRecv {
+					comm := arm.Nodes[i]
+					arm.Arm = &comm
+					break
+				}
+			}
+			if arm.Arm == nil {
+				return fmt.Errorf("select arm without a channel operation")
+			}
+		}
+
+		if err := l.lowerBlock(c.Body); err != nil {
+			return err
+		}
+		l.currentBlock.Next = []*LiveBlock{mergeBlock}
+	}
+
+	l.currentBlock = mergeBlock
+	return nil
+}
@@ -79,38 +79,106 @@ func (e *SymExpr) Eval(vars map[string]interface{}) (interface{}, bool) {
 		return nil, false
 	case SymConst:
 		return e.Value, true
-	case SymAdd:
-		l, okL := e.Left.Eval(vars)
-		r, okR := e.Right.Eval(vars)
-		if okL && okR {
-			// Type assertion needed (assuming int for now)
-			if li, ok := l.(int); ok {
-				if ri, ok := r.(int); ok {
-					return li + ri, true
-				}
+	case SymNot:
+		if v, ok := e.Left.Eval(vars); ok {
+			if b, ok := v.(bool); ok {
+				return !b, true
 			}
 		}
+		return nil, false
+	}
+
+	l, okL := e.Left.Eval(vars)
+	r, okR := e.Right.Eval(vars)
+	if !okL || !okR {
+		return nil, false
+	}
+	switch e.Kind {
+	case SymEq:
+		return l == r, true
+	case SymNeq:
+		return l != r, true
+	}
+
+	// Type assertion needed (assuming int for now)
+	li, okL := l.(int)
+	ri, okR := r.(int)
+	if !okL || !okR {
+		return nil, false
+	}
+	switch e.Kind {
+	case SymAdd:
+		return li + ri, true
 	case SymSub:
-		l, okL := e.Left.Eval(vars)
-		r, okR := e.Right.Eval(vars)
-		if okL && okR {
-			if li, ok := l.(int); ok {
-				if ri, ok := r.(int); ok {
-					return li - ri, true
-				}
-			}
+		return li - ri, true
+	case SymMul:
+		return li * ri, true
+	case SymDiv:
+		if ri == 0 {
+			return nil, false // Left for the program to fail at runtime
 		}
+		return li / ri, true
 	case SymLt:
-		l, okL := e.Left.Eval(vars)
-		r, okR := e.Right.Eval(vars)
-		if okL && okR {
-			if li, ok := l.(int); ok {
-				if ri, ok := r.(int); ok {
-					return li < ri, true
-				}
-			}
-		}
-		// TODO: Implement other ops
+		return li < ri, true
+	case SymLte:
+		return li <= ri, true
+	case SymGt:
+		return li > ri, true
+	case SymGte:
+		return li >= ri, true
 	}
 	return nil, false
 }
+
+// Substitute returns e with each variable named in bindings replaced by its
+// binding. Subexpressions without bound variables are shared, not copied.
+func (e *SymExpr) Substitute(bindings map[string]*SymExpr) *SymExpr {
+	if e == nil {
+		return nil
+	}
+	switch e.Kind {
+	case SymVar:
+		if b, ok := bindings[e.Name]; ok {
+			return b
+		}
+		return e
+	case SymConst:
+		return e
+	}
+	left := e.Left.Substitute(bindings)
+	right := e.Right.Substitute(bindings)
+	if left == e.Left && right == e.Right {
+		return e
+	}
+	return &SymExpr{Kind: e.Kind, Left: left, Right: right}
+}
+
+// Vars calls fn with the name of each variable in e
+func (e *SymExpr) Vars(fn func(name string)) {
+	if e == nil {
+		return
+	}
+	if e.Kind == SymVar {
+		fn(e.Name)
+		return
+	}
+	e.Left.Vars(fn)
+	e.Right.Vars(fn)
+}
+
+// Equals reports whether e and other are structurally the same expression.
+func (e *SymExpr) Equals(other *SymExpr) bool {
+	if e == nil || other == nil {
+		return e == other
+	}
+	if e.Kind != other.Kind {
+		return false
+	}
+	switch e.Kind {
+	case SymVar:
+		return e.Name == other.Name
+	case SymConst:
+		return e.Value == other.Value
+	}
+	return e.Left.Equals(other.Left) && e.Right.Equals(other.Right)
+}
@@ -0,0 +1,216 @@
+// Package project runs Haruspex over every Malphas file under a directory,
+// following calls between functions across files.
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/analysis"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/liveir"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// Project is the set of Malphas files under a root directory.
+type Project struct {
+	Root      string
+	Files     []*File
+	Functions []*liveir.LiveFunction // Every function that could be lowered
+	Graph     *analysis.CallGraph
+	// Summaries of the analyzed functions by FunctionID, after Analyze
+	Summaries map[string]*analysis.Summary
+	// Skipped holds why a function could not be lowered or analyzed, by
+	// FunctionID. Calls to skipped functions return unknown values.
+	Skipped map[string]error
+}
+
+// File is one source file of a project.
+type File struct {
+	Path        string // Absolute
+	Source      []rune
+	ParseErrors []parser.ParseError
+	TypeErrors  []diag.Diagnostic
+
+	ast     *ast.File // nil when the file did not parse
+	checker *types.Checker
+}
+
+// Load parses, checks and lowers every .mal file under root. Directories
+// whose names start with a dot are skipped.
+func Load(root string) (*Project, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".mal" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	p := &Project{
+		Root:      root,
+		Summaries: make(map[string]*analysis.Summary),
+		Skipped:   make(map[string]error),
+	}
+	for _, path := range paths {
+		if err := p.load(path); err != nil {
+			return nil, err
+		}
+	}
+	p.Graph = analysis.NewCallGraph(p.Functions)
+	return p, nil
+}
+
+func (p *Project) load(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	f := &File{Path: path, Source: []rune(string(content))}
+	p.Files = append(p.Files, f)
+
+	ps := parser.New(string(content), parser.WithFilename(path))
+	file := ps.ParseFile()
+	f.ParseErrors = ps.Errors()
+	if file == nil || len(f.ParseErrors) > 0 {
+		return nil
+	}
+
+	checker := types.NewChecker()
+	checker.CheckWithFilename(file, path)
+	f.TypeErrors = checker.Errors
+	f.ast, f.checker = file, checker
+
+	lowerer := liveir.NewLowerer(checker.ExprTypes)
+	lowerer.Uses = checker.Uses
+	for _, decl := range file.Decls {
+		fnDecl, ok := decl.(*ast.FnDecl)
+		if !ok {
+			continue
+		}
+		fn, err := lowerer.LowerFunction(fnDecl)
+		if err != nil {
+			p.Skipped[liveir.FunctionID(path, fnDecl.Name.Name)] = err
+			continue
+		}
+		p.Functions = append(p.Functions, fn)
+	}
+	return nil
+}
+
+// Analyze analyzes every function after the functions it calls, so that
+// calls are analyzed through the summaries of their callees. Summaries are
+// looked up in cache first, which may be shared between analyses of
+// successive versions of a project, and may be nil. The diagnostics of all
+// functions go to reporter, whether or not they come from the cache.
+func (p *Project) Analyze(cache *analysis.SummaryCache, reporter *diagnostics.Reporter) {
+	if cache == nil {
+		cache = analysis.NewSummaryCache()
+	}
+	engine := analysis.NewEngine()
+	fingerprints := make(map[string]string)
+
+	for _, component := range p.Graph.Components() {
+		fingerprint := p.fingerprint(component, fingerprints)
+		for _, id := range component {
+			fingerprints[id] = fingerprint
+		}
+
+		for _, id := range component {
+			key := fingerprint + "/" + id
+			summary, ok := cache.Get(key)
+			if !ok {
+				var err error
+				summary, err = engine.Summarize(p.Graph.Functions[id])
+				if err != nil {
+					p.Skipped[id] = err
+					continue
+				}
+				cache.Put(key, summary)
+			}
+			engine.Summaries[id] = summary
+			p.Summaries[id] = summary
+			for _, d := range summary.Diagnostics {
+				reporter.Add(d)
+			}
+		}
+	}
+}
+
+// Lint reports the unused definitions and possible data races of every file
+// that parsed.
+func (p *Project) Lint(reporter *diagnostics.Reporter) {
+	for _, f := range p.Files {
+		if f.ast != nil {
+			analysis.Lint(f.ast, f.checker, reporter)
+			analysis.DetectRaces(f.ast, f.checker, reporter)
+		}
+	}
+}
+
+// fingerprint identifies what the summaries of component are computed
+// from: the text and position of its functions, and the fingerprints of the
+// functions they call outside it.
+func (p *Project) fingerprint(component []string, fingerprints map[string]string) string {
+	h := sha256.New()
+	inComponent := make(map[string]bool, len(component))
+	for _, id := range component {
+		inComponent[id] = true
+		fn := p.Graph.Functions[id]
+		fmt.Fprintf(h, "%s\x00%d\x00%s\x00", id, fn.Pos.Start, p.text(fn))
+	}
+	for _, id := range component {
+		for _, callee := range p.Graph.Callees[id] {
+			if !inComponent[callee] {
+				fmt.Fprintf(h, "%s\x00%s\x00", callee, fingerprints[callee])
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// text returns the source text of fn
+func (p *Project) text(fn *liveir.LiveFunction) string {
+	for _, f := range p.Files {
+		if f.Path == fn.Pos.Filename && fn.Pos.Start <= fn.Pos.End && fn.Pos.End <= len(f.Source) {
+			return string(f.Source[fn.Pos.Start:fn.Pos.End])
+		}
+	}
+	return ""
+}
+
+// Name returns a readable name for the function id, relative to the root.
+func (p *Project) Name(id string) string {
+	path, name, ok := strings.Cut(id, "#")
+	if !ok {
+		return id
+	}
+	if rel, err := filepath.Rel(p.Root, path); err == nil {
+		path = rel
+	}
+	return path + ":" + name
+}
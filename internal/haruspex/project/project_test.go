@@ -0,0 +1,149 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/analysis"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/liveir"
+)
+
+const mainSource = `package main;
+
+mod utils;
+
+fn check(x: int) -> int {
+    if x > 100 {
+        return 1;
+    }
+    return 0;
+}
+
+fn main() {
+    let n = utils::double(utils::limit());
+    if n > 100 {
+        println("too big");
+    }
+    check(n);
+}
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func load(t *testing.T, dir string) *Project {
+	t.Helper()
+	p, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range p.Files {
+		if len(f.ParseErrors) > 0 || len(f.TypeErrors) > 0 {
+			t.Fatalf("%s: %v %v", f.Path, f.ParseErrors, f.TypeErrors)
+		}
+	}
+	return p
+}
+
+func TestAnalyzeAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.mal":  mainSource,
+		"utils.mal": "pub fn limit() -> int {\n    return 10;\n}\n\npub fn double(a: int) -> int {\n    a * 2\n}\n",
+	})
+	if err := os.Mkdir(filepath.Join(dir, ".hidden"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, filepath.Join(dir, ".hidden"), map[string]string{"skip.mal": "fn skipped() {}\n"})
+
+	p := load(t, dir)
+	if len(p.Files) != 2 || len(p.Skipped) != 0 {
+		t.Fatalf("files %d, skipped %v", len(p.Files), p.Skipped)
+	}
+
+	mainPath := filepath.Join(p.Root, "main.mal")
+	utilsPath := filepath.Join(p.Root, "utils.mal")
+	if callees := p.Graph.Callees[liveir.FunctionID(mainPath, "main")]; len(callees) != 3 {
+		t.Errorf("callees of main = %v", callees)
+	}
+
+	cache := analysis.NewSummaryCache()
+	reporter := diagnostics.NewReporter()
+	p.Analyze(cache, reporter)
+
+	if got := p.Summaries[liveir.FunctionID(utilsPath, "double")].Returns.String(); got != "(a * 2)" {
+		t.Errorf("double returns %s", got)
+	}
+	if got := p.Summaries[liveir.FunctionID(mainPath, "check")].Returns; got != nil {
+		t.Errorf("check returns %s, want unknown", got)
+	}
+
+	// n is 20, so the branch in main is dead, but not the one in check
+	ds := reporter.Diagnostics()
+	if len(ds) != 1 || ds[0].Pos.Filename != mainPath || ds[0].Pos.Line != 14 || !strings.Contains(ds[0].Message, "Unreachable") {
+		t.Fatalf("diagnostics = %v", ds)
+	}
+	if ds[0].Rule != diagnostics.RuleDeadBranch || len(ds[0].Fixes) != 1 {
+		t.Errorf("dead branch rule %q, fixes %v", ds[0].Rule, ds[0].Fixes)
+	}
+
+	// Nothing changed, so nothing is analyzed again
+	reporter = diagnostics.NewReporter()
+	load(t, dir).Analyze(cache, reporter)
+	if cache.Hits != 4 || cache.Misses != 4 {
+		t.Errorf("hits %d, misses %d after reloading", cache.Hits, cache.Misses)
+	}
+	if len(reporter.Diagnostics()) != 1 {
+		t.Errorf("cached diagnostics = %v", reporter.Diagnostics())
+	}
+
+	// A changed callee invalidates its callers, and the branch comes alive
+	writeFiles(t, dir, map[string]string{
+		"utils.mal": "pub fn limit() -> int {\n    return 60;\n}\n\npub fn double(a: int) -> int {\n    a * 2\n}\n",
+	})
+	reporter = diagnostics.NewReporter()
+	load(t, dir).Analyze(cache, reporter)
+	if cache.Hits != 6 || cache.Misses != 6 {
+		t.Errorf("hits %d, misses %d after changing limit", cache.Hits, cache.Misses)
+	}
+	if ds := reporter.Diagnostics(); len(ds) != 0 {
+		t.Errorf("diagnostics after change = %v", ds)
+	}
+}
+
+func TestSkippedCallee(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.mal": `package main;
+
+fn name() -> string {
+    "malphas"
+}
+
+fn main() {
+    let n = name();
+    println(n);
+}
+`,
+	})
+
+	p := load(t, dir)
+	reporter := diagnostics.NewReporter()
+	p.Analyze(nil, reporter)
+
+	if _, ok := p.Skipped[liveir.FunctionID(filepath.Join(p.Root, "main.mal"), "name")]; !ok {
+		t.Errorf("expected name to be skipped, got %v", p.Skipped)
+	}
+	if _, ok := p.Summaries[liveir.FunctionID(filepath.Join(p.Root, "main.mal"), "main")]; !ok {
+		t.Error("expected main to be analyzed")
+	}
+}
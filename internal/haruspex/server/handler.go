@@ -26,6 +26,8 @@ func (s *Server) HandleMessage(msg *RPCMessage) {
 			s.handleDidChange(msg)
 		case "textDocument/didSave":
 			s.handleDidSave(msg)
+		case "textDocument/codeAction":
+			s.handleCodeAction(msg)
 		default:
 			// Ignore unknown methods for now
 		}
@@ -37,7 +39,8 @@ func (s *Server) HandleMessage(msg *RPCMessage) {
 func (s *Server) handleInitialize(msg *RPCMessage) {
 	result := map[string]any{
 		"capabilities": map[string]any{
-			"textDocumentSync": 1, // Full sync
+			"textDocumentSync":   1, // Full sync
+			"codeActionProvider": true,
 		},
 	}
 	resultBytes, _ := json.Marshal(result)
@@ -106,10 +109,7 @@ func (s *Server) analyze(uri, text string) {
 	// Report parse errors
 	for _, err := range p.Errors() {
 		diags = append(diags, map[string]any{
-			"range": map[string]any{
-				"start": map[string]int{"line": err.Span.Line - 1, "character": err.Span.Column - 1},
-				"end":   map[string]int{"line": err.Span.Line - 1, "character": err.Span.Column - 1 + (err.Span.End - err.Span.Start)},
-			},
+			"range":    lspRange(text, err.Span.Start, err.Span.End),
 			"severity": 1, // Error
 			"message":  err.Message,
 		})
@@ -126,28 +126,28 @@ func (s *Server) analyze(uri, text string) {
 
 	// Report type errors
 	for _, err := range checker.Errors {
-		// TODO: Map diagnostic types correctly
 		diags = append(diags, map[string]any{
-			"range": map[string]any{
-				"start": map[string]int{"line": 0, "character": 0}, // Placeholder, need span from diagnostic
-				"end":   map[string]int{"line": 0, "character": 0},
-			},
+			"range":    lspRange(text, err.Span.Start, err.Span.End),
 			"severity": 1, // Error
 			"message":  err.Message,
 		})
 	}
 
-	// 3. Lower to LiveIR
+	// 3. Lint and look for races, unless the file is incomplete and would
+	// look unused
+	reporter := diagnostics.NewReporter()
+	if len(p.Errors()) == 0 {
+		analysis.Lint(file, checker, reporter)
+		analysis.DetectRaces(file, checker, reporter)
+	}
+
+	// 4. Lower to LiveIR and analyze
 	lowerer := liveir.NewLowerer(checker.ExprTypes)
 	functions, err := lowerer.LowerModule(file)
 	if err != nil {
 		s.log(fmt.Sprintf("Lowering failed: %v", err))
-		return
 	}
-
-	// 4. Analyze
 	engine := analysis.NewEngine()
-	reporter := diagnostics.NewReporter()
 	for _, fn := range functions {
 		if _, err := engine.Analyze(fn, reporter); err != nil {
 			s.log(fmt.Sprintf("Analysis failed for function %s: %v", fn.Name, err))
@@ -155,9 +155,145 @@ func (s *Server) analyze(uri, text string) {
 		}
 	}
 
+	found := reporter.Diagnostics()
+	for _, d := range found {
+		diags = append(diags, lspDiagnostic(text, d))
+	}
+
+	s.mu.Lock()
+	s.documents[uri] = document{text: text, diagnostics: found}
+	s.mu.Unlock()
+
 	s.publishDiagnostics(uri, diags)
 }
 
+// handleCodeAction offers the fixes of the Haruspex diagnostics in the
+// requested range as quick fixes.
+func (s *Server) handleCodeAction(msg *RPCMessage) {
+	var params struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Range struct {
+			Start struct{ Line, Character int } `json:"start"`
+			End   struct{ Line, Character int } `json:"end"`
+		} `json:"range"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		s.log(fmt.Sprintf("Failed to parse codeAction params: %v", err))
+		return
+	}
+
+	s.mu.Lock()
+	doc := s.documents[params.TextDocument.URI]
+	s.mu.Unlock()
+
+	actions := []any{}
+	for _, d := range doc.diagnostics {
+		start, end := lineOf(doc.text, d.Pos.Start), lineOf(doc.text, d.Pos.End)
+		if end < params.Range.Start.Line || start > params.Range.End.Line {
+			continue
+		}
+		for _, fix := range d.Fixes {
+			var edits []any
+			for _, e := range fix.Edits {
+				if e.Span.Filename == d.Pos.Filename {
+					edits = append(edits, map[string]any{
+						"range":   lspRange(doc.text, e.Span.Start, e.Span.End),
+						"newText": e.NewText,
+					})
+				}
+			}
+			if len(edits) != len(fix.Edits) {
+				continue // The fix reaches into other files
+			}
+			actions = append(actions, map[string]any{
+				"title":       fix.Message,
+				"kind":        "quickfix",
+				"diagnostics": []any{lspDiagnostic(doc.text, d)},
+				"edit": map[string]any{
+					"changes": map[string]any{params.TextDocument.URI: edits},
+				},
+			})
+		}
+	}
+
+	resultBytes, _ := json.Marshal(actions)
+	s.write(&RPCMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  resultBytes,
+	})
+}
+
+// lspDiagnostic converts a Haruspex diagnostic of the document text
+func lspDiagnostic(text string, d diagnostics.Diagnostic) map[string]any {
+	severity := 3 // Information
+	switch d.Kind {
+	case diagnostics.KindError:
+		severity = 1
+	case diagnostics.KindWarning:
+		severity = 2
+	}
+	diag := map[string]any{
+		"range":    lspRange(text, d.Pos.Start, d.Pos.End),
+		"severity": severity,
+		"source":   "haruspex",
+		"message":  d.Message,
+	}
+	if d.Rule != "" {
+		diag["code"] = d.Rule
+	}
+	var related []any
+	for _, r := range d.Related {
+		if r.Pos.Filename != d.Pos.Filename {
+			continue // Only the document's own text is at hand
+		}
+		related = append(related, map[string]any{
+			"location": map[string]any{"uri": r.Pos.Filename, "range": lspRange(text, r.Pos.Start, r.Pos.End)},
+			"message":  r.Message,
+		})
+	}
+	if len(related) > 0 {
+		diag["relatedInformation"] = related
+	}
+	return diag
+}
+
+// lspRange converts rune offsets in text to an LSP range, whose characters
+// count UTF-16 code units
+func lspRange(text string, start, end int) map[string]any {
+	return map[string]any{
+		"start": lspPosition(text, start),
+		"end":   lspPosition(text, max(start, end)),
+	}
+}
+
+func lspPosition(text string, offset int) map[string]int {
+	line, character := 0, 0
+	i := 0
+	for _, r := range text {
+		if i == offset {
+			break
+		}
+		i++
+		if r == '\n' {
+			line++
+			character = 0
+		} else if r >= 0x10000 {
+			character += 2
+		} else {
+			character++
+		}
+	}
+	return map[string]int{"line": line, "character": character}
+}
+
+// lineOf returns the 0-based line of the rune offset in text
+func lineOf(text string, offset int) int {
+	return lspPosition(text, offset)["line"]
+}
+
 func (s *Server) publishDiagnostics(uri string, diagnostics []any) {
 	params := map[string]any{
 		"uri":         uri,
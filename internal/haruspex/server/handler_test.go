@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// messages reads back everything the server wrote to out
+func messages(t *testing.T, out *bytes.Buffer) []*RPCMessage {
+	t.Helper()
+	var msgs []*RPCMessage
+	reader := bufio.NewReader(out)
+	for reader.Buffered() > 0 || out.Len() > 0 {
+		msg, err := ReadMessage(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestLintDiagnosticsAndCodeActions(t *testing.T) {
+	var out bytes.Buffer
+	s := NewServer()
+	s.writer = &out
+
+	uri := "file:///tmp/lint.mal"
+	s.analyze(uri, "fn main() {\n    let é = 1;\n}\n")
+
+	var published struct {
+		URI         string `json:"uri"`
+		Diagnostics []struct {
+			Range struct {
+				Start struct{ Line, Character int } `json:"start"`
+				End   struct{ Line, Character int } `json:"end"`
+			} `json:"range"`
+			Severity int    `json:"severity"`
+			Code     string `json:"code"`
+			Message  string `json:"message"`
+		} `json:"diagnostics"`
+	}
+	for _, msg := range messages(t, &out) {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			if err := json.Unmarshal(msg.Params, &published); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(published.Diagnostics) != 1 {
+		t.Fatalf("published %+v", published)
+	}
+	d := published.Diagnostics[0]
+	if d.Code != "UnusedVariable" || d.Severity != 2 || d.Range.Start.Line != 1 || d.Range.Start.Character != 8 || d.Range.End.Character != 9 {
+		t.Errorf("diagnostic = %+v", d)
+	}
+
+	id := json.RawMessage(`1`)
+	params, _ := json.Marshal(map[string]any{
+		"textDocument": map[string]string{"uri": uri},
+		"range": map[string]any{
+			"start": map[string]int{"line": 1, "character": 0},
+			"end":   map[string]int{"line": 1, "character": 0},
+		},
+	})
+	s.HandleMessage(&RPCMessage{JSONRPC: "2.0", ID: &id, Method: "textDocument/codeAction", Params: params})
+
+	msgs := messages(t, &out)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages", len(msgs))
+	}
+	var actions []struct {
+		Title string `json:"title"`
+		Kind  string `json:"kind"`
+		Edit  struct {
+			Changes map[string][]struct {
+				NewText string `json:"newText"`
+			} `json:"changes"`
+		} `json:"edit"`
+	}
+	if err := json.Unmarshal(msgs[0].Result, &actions); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Title != "Prefix `é` with an underscore" || actions[0].Kind != "quickfix" || actions[0].Edit.Changes[uri][0].NewText != "_é" {
+		t.Errorf("actions = %+v", actions)
+	}
+}
@@ -5,20 +5,30 @@ import (
 	"io"
 	"os"
 	"sync"
+
+	"github.com/malphas-lang/malphas-lang/internal/haruspex/diagnostics"
 )
 
 // Server represents the Haruspex LSP server.
 type Server struct {
-	reader *bufio.Reader
-	writer io.Writer
-	mu     sync.Mutex
+	reader    *bufio.Reader
+	writer    io.Writer
+	mu        sync.Mutex
+	documents map[string]document // By URI, as last analyzed
+}
+
+// document is an analyzed document, kept to answer code actions
+type document struct {
+	text        string
+	diagnostics []diagnostics.Diagnostic
 }
 
 // NewServer creates a new LSP server.
 func NewServer() *Server {
 	return &Server{
-		reader: bufio.NewReader(os.Stdin),
-		writer: os.Stdout,
+		reader:    bufio.NewReader(os.Stdin),
+		writer:    os.Stdout,
+		documents: make(map[string]document),
 	}
 }
 
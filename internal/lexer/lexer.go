@@ -63,6 +63,10 @@ type Lexer struct {
 	filename string
 
 	Errors []LexerError
+
+	// Comments holds the comments skipped so far, in source order, when
+	// trivia tokens are not emitted.
+	Comments []Token
 }
 
 func (l *Lexer) addError(kind LexerErrorKind, msg string, span Span) {
@@ -237,10 +241,11 @@ func (l *Lexer) skipLineCommentWithStart(startLine, startColumn, startPos int) *
 	endPos := l.pos
 	raw := string(l.input[startPos:endPos])
 
+	tok := l.makeToken(LINE_COMMENT, startLine, startColumn, startPos, endPos, raw, raw)
 	if l.emitTrivia {
-		tok := l.makeToken(LINE_COMMENT, startLine, startColumn, startPos, endPos, raw, raw)
 		return &tok
 	}
+	l.Comments = append(l.Comments, tok)
 	return nil
 }
 
@@ -272,10 +277,11 @@ func (l *Lexer) skipBlockCommentWithStart(startLine, startColumn, startPos int)
 	endPos := l.pos
 	raw := string(l.input[startPos:endPos])
 
+	tok := l.makeToken(BLOCK_COMMENT, startLine, startColumn, startPos, endPos, raw, raw)
 	if l.emitTrivia {
-		tok := l.makeToken(BLOCK_COMMENT, startLine, startColumn, startPos, endPos, raw, raw)
 		return &tok
 	}
+	l.Comments = append(l.Comments, tok)
 	return nil
 }
 
@@ -503,6 +509,12 @@ func (l *Lexer) NextToken() Token {
 			l.read()
 			return l.makeToken(QUESTION, startLine, startColumn, startPos, l.pos, raw, raw)
 
+		case '#':
+			startLine, startColumn, startPos := l.currentSpanStart()
+			raw := string(l.ch)
+			l.read()
+			return l.makeToken(HASH, startLine, startColumn, startPos, l.pos, raw, raw)
+
 		case '|':
 			startLine, startColumn, startPos := l.currentSpanStart()
 			if l.peek() == '|' {
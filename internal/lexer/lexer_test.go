@@ -368,6 +368,28 @@ let y = 20;`
 	}
 }
 
+func TestNextToken_RecordsSkippedComments(t *testing.T) {
+	input := `/* head */ let x = 10; // tail
+let y = 20;`
+
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+	}
+
+	if len(l.Comments) != 2 {
+		t.Fatalf("expected 2 comments, got %d", len(l.Comments))
+	}
+	if l.Comments[0].Type != BLOCK_COMMENT || l.Comments[0].Raw != "/* head */" {
+		t.Fatalf("unexpected first comment: %q %q", l.Comments[0].Type, l.Comments[0].Raw)
+	}
+	if l.Comments[1].Type != LINE_COMMENT || l.Comments[1].Raw != "// tail" {
+		t.Fatalf("unexpected second comment: %q %q", l.Comments[1].Type, l.Comments[1].Raw)
+	}
+	if l.Comments[1].Span.Line != 1 || l.Comments[1].Span.Column != 24 {
+		t.Fatalf("unexpected span for second comment: %+v", l.Comments[1].Span)
+	}
+}
+
 func TestNextToken_LineCommentAtEOF(t *testing.T) {
 	input := `let x = 10; // comment at end`
 
@@ -74,10 +74,13 @@ const (
 	ARROW  TokenType = "->"
 	LARROW TokenType = "<-"
 
+	HASH TokenType = "#" // Starts an attribute: #[name]
+
 	// Keywords
 	LET      TokenType = "LET"
 	MUT      TokenType = "MUT"
 	CONST    TokenType = "CONST"
+	STATIC   TokenType = "STATIC"
 	FN       TokenType = "FN"
 	STRUCT   TokenType = "STRUCT"
 	ENUM     TokenType = "ENUM"
@@ -107,6 +110,7 @@ const (
 	CASE     TokenType = "CASE"
 	WHERE    TokenType = "WHERE"
 	UNSAFE   TokenType = "UNSAFE"
+	EXTERN   TokenType = "EXTERN"
 	EXISTS   TokenType = "EXISTS"
 	FORALL   TokenType = "FORALL"
 
@@ -121,6 +125,7 @@ var keywords = map[string]TokenType{
 	"let":      LET,
 	"mut":      MUT,
 	"const":    CONST,
+	"static":   STATIC,
 	"fn":       FN,
 	"struct":   STRUCT,
 	"enum":     ENUM,
@@ -151,6 +156,7 @@ var keywords = map[string]TokenType{
 	"case":     CASE,
 	"where":    WHERE,
 	"unsafe":   UNSAFE,
+	"extern":   EXTERN,
 	"exists":   EXISTS,
 	"forall":   FORALL,
 }
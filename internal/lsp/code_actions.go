@@ -0,0 +1,147 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// CodeActionParams is sent with textDocument/codeAction.
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      CodeActionContext      `json:"context"`
+}
+
+// CodeActionContext carries the diagnostics the client shows at the range.
+// Actions are built from the server's own diagnostics, which hold the fixes.
+type CodeActionContext struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Only        []string     `json:"only,omitempty"`
+}
+
+// CodeAction is a quick fix for one diagnostic.
+type CodeAction struct {
+	Title       string         `json:"title"`
+	Kind        string         `json:"kind"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	IsPreferred bool           `json:"isPreferred,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit"`
+}
+
+// CodeActionOptions advertises the kinds of code actions offered.
+type CodeActionOptions struct {
+	CodeActionKinds []string `json:"codeActionKinds"`
+}
+
+// codeActionQuickFix is the only kind of code action offered
+const codeActionQuickFix = "quickfix"
+
+func (s *Server) handleCodeAction(msg *jsonrpcMessage) *jsonrpcMessage {
+	var params CodeActionParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &jsonrpcError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid params: %v", err),
+			},
+		}
+	}
+
+	doc, ok := s.document(params.TextDocument.URI)
+	if !ok || !wantsKind(params.Context.Only, codeActionQuickFix) {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Result:  nil,
+		}
+	}
+
+	return &jsonrpcMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  codeActions(doc, params.Range),
+	}
+}
+
+// wantsKind reports whether a client asking only for kinds accepts kind.
+// Kinds are hierarchical, so asking for "quickfix" also accepts
+// "quickfix.import".
+func wantsKind(only []string, kind string) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, k := range only {
+		if k == kind || len(kind) > len(k) && kind[:len(k)+1] == k+"." {
+			return true
+		}
+	}
+	return false
+}
+
+// codeActions turns the fixes of the diagnostics of doc that overlap rng
+// into quick fixes. A fix shared by several diagnostics, such as adding all
+// missing match arms, is offered once.
+func codeActions(doc *Document, rng Range) []CodeAction {
+	converter := newDiagnosticConverter(doc)
+	actions := []CodeAction{}
+	offered := make(map[string]bool)
+	for _, d := range doc.Errors {
+		if len(d.Fixes) == 0 {
+			continue
+		}
+		lspDiag := converter.convert(d)
+		if !overlaps(lspDiag.Range, rng) {
+			continue
+		}
+		for _, fix := range d.Fixes {
+			edit, ok := converter.workspaceEdit(fix)
+			if !ok {
+				continue
+			}
+			key := fmt.Sprintf("%s %v", fix.Message, fix.Edits)
+			if offered[key] {
+				continue
+			}
+			offered[key] = true
+			actions = append(actions, CodeAction{
+				Title:       fix.Message,
+				Kind:        codeActionQuickFix,
+				Diagnostics: []Diagnostic{lspDiag},
+				IsPreferred: len(d.Fixes) == 1,
+				Edit:        edit,
+			})
+		}
+	}
+	return actions
+}
+
+// workspaceEdit converts the edits of fix. Unlike diagnostic ranges, edit
+// ranges come from offsets alone, as insertions have empty spans.
+func (c *diagnosticConverter) workspaceEdit(fix diag.Fix) (*WorkspaceEdit, bool) {
+	edit := &WorkspaceEdit{Changes: make(map[string][]TextEdit)}
+	for _, e := range fix.Edits {
+		src, ok := c.source(e.Span.Filename)
+		if !ok || e.Span.Start > e.Span.End || e.Span.End > len([]rune(src)) {
+			return nil, false
+		}
+		uri := c.location(e.Span).URI
+		edit.Changes[uri] = append(edit.Changes[uri], TextEdit{
+			Range:   Range{Start: positionAt(src, e.Span.Start), End: positionAt(src, e.Span.End)},
+			NewText: e.NewText,
+		})
+	}
+	return edit, len(edit.Changes) > 0
+}
+
+// overlaps reports whether a and b share a position, counting their ends
+func overlaps(a, b Range) bool {
+	return !before(a.End, b.Start) && !before(b.End, a.Start)
+}
+
+func before(a, b Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+}
@@ -0,0 +1,81 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+)
+
+const codeActionSource = `package main;
+
+enum Light {
+    Red,
+    Green,
+}
+
+fn main() {
+    let count = 1;
+    let light = Light::Red;
+    match light {
+        Light::Red => println("stop")
+    };
+}
+`
+
+func TestCodeActions(t *testing.T) {
+	s := NewServer()
+	doc := &Document{URI: "file:///tmp/actions.mal", Content: codeActionSource}
+	s.updateDocument(doc)
+
+	titles := func(actions []CodeAction) []string {
+		var out []string
+		for _, a := range actions {
+			out = append(out, a.Title)
+		}
+		return out
+	}
+
+	// The line of `let count = 1;`
+	actions := codeActions(doc, Range{Start: Position{Line: 8, Character: 8}, End: Position{Line: 8, Character: 8}})
+	if len(actions) != 1 || actions[0].Title != "Prefix `count` with an underscore" || !actions[0].IsPreferred {
+		t.Fatalf("actions for unused variable = %v", titles(actions))
+	}
+	edits := actions[0].Edit.Changes[doc.URI]
+	if got := applyEdits(doc.Content, edits); !strings.Contains(got, "let _count = 1;") {
+		t.Errorf("unused variable fix produced:\n%s", got)
+	}
+	if actions[0].Kind != codeActionQuickFix || len(actions[0].Diagnostics) != 1 {
+		t.Errorf("action = %+v", actions[0])
+	}
+
+	// The match reports its missing variant
+	actions = codeActions(doc, Range{Start: Position{Line: 10, Character: 4}, End: Position{Line: 12, Character: 5}})
+	if len(actions) != 1 || actions[0].Title != "Add match arm for `Light::Green`" {
+		t.Fatalf("actions for match = %v", titles(actions))
+	}
+	got := applyEdits(doc.Content, actions[0].Edit.Changes[doc.URI])
+	if want := "        Light::Red => println(\"stop\"),\n        Light::Green => {}\n    };"; !strings.Contains(got, want) {
+		t.Errorf("match fix produced:\n%s", got)
+	}
+
+	// Nothing applies to the enum declaration
+	if actions := codeActions(doc, Range{Start: Position{Line: 3, Character: 0}, End: Position{Line: 3, Character: 0}}); len(actions) != 0 {
+		t.Errorf("unexpected actions %v", titles(actions))
+	}
+}
+
+func TestWantsKind(t *testing.T) {
+	tests := []struct {
+		only []string
+		want bool
+	}{
+		{nil, true},
+		{[]string{"quickfix"}, true},
+		{[]string{"refactor"}, false},
+		{[]string{"source", "quickfix"}, true},
+	}
+	for _, tt := range tests {
+		if got := wantsKind(tt.only, codeActionQuickFix); got != tt.want {
+			t.Errorf("wantsKind(%v) = %v, want %v", tt.only, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package lsp
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
@@ -53,6 +54,7 @@ const (
 	completionKindModule        = 9
 	completionKindProperty      = 10
 	completionKindKeyword       = 14
+	completionKindEnumMember    = 20
 	completionKindTypeParameter = 25
 )
 
@@ -69,11 +71,9 @@ func (s *Server) handleCompletion(msg *jsonrpcMessage) *jsonrpcMessage {
 		}
 	}
 
-	s.mu.RLock()
-	doc, ok := s.Documents[params.TextDocument.URI]
-	s.mu.RUnlock()
+	doc, ok := s.document(params.TextDocument.URI)
 
-	if !ok || doc.Checker == nil {
+	if !ok {
 		return &jsonrpcMessage{
 			JSONRPC: "2.0",
 			ID:      msg.ID,
@@ -94,28 +94,35 @@ func (s *Server) handleCompletion(msg *jsonrpcMessage) *jsonrpcMessage {
 func (s *Server) getCompletions(doc *Document, pos Position) []CompletionItem {
 	var items []CompletionItem
 
-	// Check if we're in a member access context (e.g., "self." or "obj.")
 	offset := positionToOffset(doc.Content, pos)
-	memberAccessType := s.getMemberAccessType(doc, offset)
-
-	if memberAccessType != nil {
-		// Provide completions for struct fields
-		items = append(items, s.completionsForType(doc, memberAccessType)...)
-		// Also include regular completions in case user wants to type something else
-		if doc.Checker != nil && doc.Checker.GlobalScope != nil {
-			items = append(items, s.completionsFromScope(doc.Checker.GlobalScope)...)
+	result := doc.Result
+
+	// Work out the context from a repaired copy of the document, which also
+	// covers code that is broken because it is still being typed
+	if site := findCompletionSite(s.program, doc, offset); site != nil {
+		if items, ok := s.siteCompletions(site); ok {
+			return items
+		}
+		items = append(items, s.localCompletions(site)...)
+		result = site.result
+	} else if memberAccessType := s.getMemberAccessType(doc, offset); memberAccessType != nil {
+		// Fall back to the last successful check for member access
+		// (e.g., "self." or "obj.")
+		items = append(items, s.completionsForType(doc.Result, memberAccessType)...)
+		if doc.Result != nil && doc.Result.Scope != nil {
+			items = append(items, s.completionsFromScope(doc.Result.Scope)...)
 		}
 		return items
 	}
 
 	// Get all symbols from the global scope
-	if doc.Checker != nil && doc.Checker.GlobalScope != nil {
-		items = append(items, s.completionsFromScope(doc.Checker.GlobalScope)...)
+	if result != nil && result.Scope != nil {
+		items = append(items, s.completionsFromScope(result.Scope)...)
 	}
 
 	// Add keywords
 	keywords := []string{
-		"fn", "let", "mut", "const", "return", "if", "else",
+		"fn", "let", "mut", "const", "static", "return", "if", "else",
 		"match", "struct", "enum", "trait", "impl", "pub", "use",
 		"mod", "spawn", "select", "for", "loop", "break", "continue",
 		"int", "float", "bool", "string", "void",
@@ -139,6 +146,10 @@ func (s *Server) completionsFromScope(scope *types.Scope) []CompletionItem {
 	}
 
 	for name, sym := range scope.Symbols {
+		// Runtime intrinsics such as __map_len__ are not meant to be called directly
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
 		kind := completionKindVariable
 		detail := ""
 
@@ -189,12 +200,13 @@ func formatFunctionSignature(fn *types.Function) string {
 // getMemberAccessType checks if the cursor is after a dot (member access)
 // and returns the type of the expression before the dot.
 func (s *Server) getMemberAccessType(doc *Document, offset int) types.Type {
-	if doc.Checker == nil || doc.File == nil {
+	if doc.Result == nil || doc.File == nil {
 		return nil
 	}
 
-	// Look backwards from the cursor to find a dot
-	content := doc.Content
+	// Look backwards from the cursor to find a dot. Offsets count runes, as
+	// span offsets do.
+	content := []rune(doc.Content)
 	if offset >= len(content) {
 		return nil
 	}
@@ -211,7 +223,7 @@ func (s *Server) getMemberAccessType(doc *Document, offset int) types.Type {
 			break
 		}
 		// Stop if we hit certain operators that break the expression
-		if strings.ContainsRune(";{}()[]", rune(content[i])) {
+		if strings.ContainsRune(";{}()[]", content[i]) {
 			break
 		}
 	}
@@ -267,9 +279,9 @@ func (s *Server) getMemberAccessType(doc *Document, offset int) types.Type {
 	}
 
 	// Look up the type of the target expression
-	if doc.Checker != nil && doc.Checker.ExprTypes != nil {
-		if typ, ok := doc.Checker.ExprTypes[targetExpr]; ok {
-			return s.unwrapType(doc, typ)
+	if doc.Result != nil && doc.Result.Types != nil {
+		if typ, ok := doc.Result.Types[targetExpr]; ok {
+			return s.unwrapType(doc.Result, typ)
 		}
 	}
 
@@ -284,14 +296,14 @@ func (s *Server) getMemberAccessType(doc *Document, offset int) types.Type {
 
 	// For FieldExpr, we need to resolve the target's type
 	if fieldExpr, ok := targetExpr.(*ast.FieldExpr); ok {
-		if doc.Checker != nil && doc.Checker.ExprTypes != nil {
-			if targetType, ok := doc.Checker.ExprTypes[fieldExpr.Target]; ok {
-				unwrapped := s.unwrapType(doc, targetType)
+		if doc.Result != nil && doc.Result.Types != nil {
+			if targetType, ok := doc.Result.Types[fieldExpr.Target]; ok {
+				unwrapped := s.unwrapType(doc.Result, targetType)
 				// If it's a struct, get the field type
 				if st, ok := unwrapped.(*types.Struct); ok {
 					for _, f := range st.Fields {
 						if f.Name == fieldExpr.Field.Name {
-							return s.unwrapType(doc, f.Type)
+							return s.unwrapType(doc.Result, f.Type)
 						}
 					}
 				}
@@ -303,7 +315,7 @@ func (s *Server) getMemberAccessType(doc *Document, offset int) types.Type {
 }
 
 // unwrapType unwraps references, pointers, named types, and generic instances to get to the concrete type
-func (s *Server) unwrapType(doc *Document, typ types.Type) types.Type {
+func (s *Server) unwrapType(result *types.CheckResult, typ types.Type) types.Type {
 	if typ == nil {
 		return nil
 	}
@@ -322,15 +334,15 @@ func (s *Server) unwrapType(doc *Document, typ types.Type) types.Type {
 				continue
 			}
 			// Fallback to scope lookup if Ref is nil
-			if doc != nil && doc.Checker != nil {
-				if doc.Checker.GlobalScope != nil {
-					if sym := doc.Checker.GlobalScope.Lookup(t.Name); sym != nil && sym.Type != nil {
+			if result != nil {
+				if result.Scope != nil {
+					if sym := result.Scope.Lookup(t.Name); sym != nil && sym.Type != nil {
 						typ = sym.Type
 						continue
 					}
 				}
-				if doc.Checker.Modules != nil {
-					for _, modInfo := range doc.Checker.Modules {
+				if result.Modules != nil {
+					for _, modInfo := range result.Modules {
 						if modInfo.Scope != nil {
 							if sym := modInfo.Scope.Lookup(t.Name); sym != nil && sym.Type != nil {
 								typ = sym.Type
@@ -369,57 +381,83 @@ func findIdentifierEndingBefore(file *ast.File, offset int) *ast.Ident {
 	return found
 }
 
-// completionsForType returns completion items for a given type
-func (s *Server) completionsForType(doc *Document, typ types.Type) []CompletionItem {
+// completionsForType returns the fields and methods of a value of type typ
+func (s *Server) completionsForType(result *types.CheckResult, typ types.Type) []CompletionItem {
 	var items []CompletionItem
 
+	for {
+		if ref, ok := typ.(*types.Reference); ok {
+			typ = ref.Elem
+		} else if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem
+		} else {
+			break
+		}
+	}
 	if typ == nil {
 		return items
 	}
 
-	// Handle GenericInstance - get fields from the base struct
+	// Members of a generic instance show their instantiated types
+	subst := make(map[string]types.Type)
 	if genInst, ok := typ.(*types.GenericInstance); ok {
-		// Unwrap the base to get to the actual struct
-		base := s.unwrapType(doc, genInst.Base)
-		if st, ok := base.(*types.Struct); ok {
-			for _, field := range st.Fields {
-				items = append(items, CompletionItem{
-					Label:  field.Name,
-					Kind:   completionKindField,
-					Detail: field.Type.String(),
-				})
+		if st, ok := s.unwrapType(result, genInst.Base).(*types.Struct); ok {
+			for i, tp := range st.TypeParams {
+				if i < len(genInst.Args) {
+					subst[tp.Name] = genInst.Args[i]
+				}
 			}
 		}
-		return items
 	}
 
-	// Unwrap the type first
-	typ = s.unwrapType(doc, typ)
-
-	// Handle struct types
-	if s, ok := typ.(*types.Struct); ok {
-		for _, field := range s.Fields {
+	if st, ok := s.unwrapType(result, typ).(*types.Struct); ok {
+		for _, field := range st.Fields {
 			items = append(items, CompletionItem{
 				Label:  field.Name,
 				Kind:   completionKindField,
-				Detail: field.Type.String(),
+				Detail: types.Substitute(field.Type, subst).String(),
 			})
 		}
 	}
 
-	// TODO: Handle method completions when method table is available
+	if result != nil {
+		methods := result.MethodsOf(typ)
+		for _, name := range sortedMethodNames(methods) {
+			fn := methods[name]
+			if fn.Receiver == nil {
+				continue // Static methods are completed after `::`
+			}
+			if inst, ok := types.Substitute(fn, subst).(*types.Function); ok {
+				fn = inst
+			}
+			items = append(items, CompletionItem{
+				Label:  name,
+				Kind:   completionKindMethod,
+				Detail: formatSignature(name, fn, nil, nil),
+			})
+		}
+	}
 
 	return items
 }
 
+func sortedMethodNames(methods map[string]*types.Function) []string {
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // lookupIdentifierType looks up an identifier in all available scopes
 func (s *Server) lookupIdentifierType(doc *Document, name string, offset int) types.Type {
-	if doc.Checker == nil {
+	if doc.Result == nil {
 		return nil
 	}
 
 	// First, try to find the identifier in the AST and check ExprTypes
-	if doc.File != nil && doc.Checker.ExprTypes != nil {
+	if doc.File != nil && doc.Result.Types != nil {
 		var foundIdent *ast.Ident
 		ast.Walk(doc.File, func(n ast.Node) bool {
 			if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
@@ -432,29 +470,29 @@ func (s *Server) lookupIdentifierType(doc *Document, name string, offset int) ty
 			}
 			return true
 		})
-		
+
 		if foundIdent != nil {
-			if typ, ok := doc.Checker.ExprTypes[foundIdent]; ok {
-				return s.unwrapType(doc, typ)
+			if typ, ok := doc.Result.Types[foundIdent]; ok {
+				return s.unwrapType(doc.Result, typ)
 			}
 		}
 	}
 
 	// Try the global scope
-	if doc.Checker.GlobalScope != nil {
-		sym := doc.Checker.GlobalScope.Lookup(name)
+	if doc.Result.Scope != nil {
+		sym := doc.Result.Scope.Lookup(name)
 		if sym != nil {
-			return s.unwrapType(doc, sym.Type)
+			return s.unwrapType(doc.Result, sym.Type)
 		}
 	}
 
 	// Also check module scopes
-	if doc.Checker.Modules != nil {
-		for _, modInfo := range doc.Checker.Modules {
+	if doc.Result.Modules != nil {
+		for _, modInfo := range doc.Result.Modules {
 			if modInfo.Scope != nil {
 				sym := modInfo.Scope.Lookup(name)
 				if sym != nil {
-					return s.unwrapType(doc, sym.Type)
+					return s.unwrapType(doc.Result, sym.Type)
 				}
 			}
 		}
@@ -464,46 +502,32 @@ func (s *Server) lookupIdentifierType(doc *Document, name string, offset int) ty
 	// For method receivers, find the containing function and check its receiver type
 	if doc.File != nil && name == "self" {
 		// Find the function that contains this offset
+		index := doc.File.Index()
 		var containingFn *ast.FnDecl
-		ast.Walk(doc.File, func(n ast.Node) bool {
+		for _, n := range index.PathAt(offset) {
 			if fn, ok := n.(*ast.FnDecl); ok {
-				span := fn.Span()
-				if span.Start <= offset && span.End >= offset {
-					containingFn = fn
-					return false // Found it, stop searching
-				}
+				containingFn = fn
+				break
 			}
-			return true
-		})
-		
+		}
+
 		if containingFn != nil {
 			// Check if this function is in an impl block (it's a method)
-			// Find the impl block that contains this function
-			var implBlock *ast.ImplDecl
-			ast.Walk(doc.File, func(n ast.Node) bool {
-				if impl, ok := n.(*ast.ImplDecl); ok {
-					span := impl.Span()
-					if span.Start <= offset && span.End >= offset {
-						implBlock = impl
-						return false
-					}
-				}
-				return true
-			})
-			
-			if implBlock != nil && doc.Checker != nil {
+			implBlock, _ := index.Parent(containingFn).(*ast.ImplDecl)
+
+			if implBlock != nil && doc.Result != nil {
 				// This is a method - get the receiver type from the impl target
 				if implBlock.Target != nil {
 					// Get the type of the impl target from ExprTypes (already resolved by checker)
-					if typ, ok := doc.Checker.ExprTypes[implBlock.Target]; ok {
-						return s.unwrapType(doc, typ)
+					if typ, ok := doc.Result.Types[implBlock.Target]; ok {
+						return s.unwrapType(doc.Result, typ)
 					}
-					
+
 					// Fallback: try to get struct from symbol if we can find it
 					// This handles cases where the struct is in scope but not yet in ExprTypes
 					var structName string
 					var typeArgs []ast.TypeExpr
-					
+
 					if genType, ok := implBlock.Target.(*ast.GenericType); ok && genType.Base != nil {
 						if namedType, ok := genType.Base.(*ast.NamedType); ok && namedType.Name != nil {
 							structName = namedType.Name.Name
@@ -512,21 +536,21 @@ func (s *Server) lookupIdentifierType(doc *Document, name string, offset int) ty
 					} else if namedType, ok := implBlock.Target.(*ast.NamedType); ok && namedType.Name != nil {
 						structName = namedType.Name.Name
 					}
-					
+
 					if structName != "" {
 						// Look up in global scope
-						if doc.Checker.GlobalScope != nil {
-							sym := doc.Checker.GlobalScope.Lookup(structName)
+						if doc.Result.Scope != nil {
+							sym := doc.Result.Scope.Lookup(structName)
 							if sym != nil && sym.Type != nil {
 								// Unwrap to get the actual struct type
-								unwrapped := s.unwrapType(doc, sym.Type)
+								unwrapped := s.unwrapType(doc.Result, sym.Type)
 								if st, ok := unwrapped.(*types.Struct); ok {
 									// If we have type args, create a GenericInstance
 									if len(typeArgs) > 0 {
 										args := []types.Type{}
 										for _, arg := range typeArgs {
-											if doc.Checker.ExprTypes != nil {
-												if argType, ok := doc.Checker.ExprTypes[arg]; ok {
+											if doc.Result.Types != nil {
+												if argType, ok := doc.Result.Types[arg]; ok {
 													args = append(args, argType)
 												} else {
 													args = append(args, types.TypeVoid)
@@ -541,20 +565,20 @@ func (s *Server) lookupIdentifierType(doc *Document, name string, offset int) ty
 								}
 							}
 						}
-						
+
 						// Check module scopes
-						if doc.Checker.Modules != nil {
-							for _, modInfo := range doc.Checker.Modules {
+						if doc.Result.Modules != nil {
+							for _, modInfo := range doc.Result.Modules {
 								if modInfo.Scope != nil {
 									sym := modInfo.Scope.Lookup(structName)
 									if sym != nil && sym.Type != nil {
-										unwrapped := s.unwrapType(doc, sym.Type)
+										unwrapped := s.unwrapType(doc.Result, sym.Type)
 										if st, ok := unwrapped.(*types.Struct); ok {
 											if len(typeArgs) > 0 {
 												args := []types.Type{}
 												for _, arg := range typeArgs {
-													if doc.Checker.ExprTypes != nil {
-														if argType, ok := doc.Checker.ExprTypes[arg]; ok {
+													if doc.Result.Types != nil {
+														if argType, ok := doc.Result.Types[arg]; ok {
 															args = append(args, argType)
 														} else {
 															args = append(args, types.TypeVoid)
@@ -581,7 +605,7 @@ func (s *Server) lookupIdentifierType(doc *Document, name string, offset int) ty
 }
 
 // extractIdentifierBeforeDot extracts the identifier name from source text before a dot
-func (s *Server) extractIdentifierBeforeDot(content string, dotPos int) string {
+func (s *Server) extractIdentifierBeforeDot(content []rune, dotPos int) string {
 	if dotPos <= 0 {
 		return ""
 	}
@@ -602,7 +626,7 @@ func (s *Server) extractIdentifierBeforeDot(content string, dotPos int) string {
 		return ""
 	}
 
-	ident := content[start:dotPos]
+	ident := string(content[start:dotPos])
 	// Validate it's a valid identifier (starts with letter or underscore)
 	if len(ident) > 0 && ((ident[0] >= 'a' && ident[0] <= 'z') || (ident[0] >= 'A' && ident[0] <= 'Z') || ident[0] == '_') {
 		return ident
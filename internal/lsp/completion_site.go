@@ -0,0 +1,294 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// completionPlaceholder stands in for the identifier being completed while
+// the document is re-parsed
+const completionPlaceholder = "__complete"
+
+// completionEndings replace the rest of the cursor's line when the document
+// does not parse with just the placeholder inserted, closing the constructs
+// people are most often in the middle of typing
+var completionEndings = []string{"", ";", " = 0;", ")", ");", ") {}", " {}", "}"}
+
+// completionSite is the identifier being completed, found in a copy of the
+// document that was repaired so that it parses and type checks
+type completionSite struct {
+	result *types.CheckResult
+	path   []ast.Node // Nodes containing the placeholder, outermost first
+	ident  *ast.Ident // The placeholder
+}
+
+// parent returns the node directly containing the placeholder
+func (site *completionSite) parent() ast.Node {
+	if len(site.path) < 2 {
+		return nil
+	}
+	return site.path[len(site.path)-2]
+}
+
+// findCompletionSite replaces the identifier under the cursor with a
+// placeholder and parses the result. Code being typed rarely parses as is
+// (`p.`, `let x: `), so when it does not, the rest of the line is replaced by
+// each of completionEndings in turn. The first variant that parses is type
+// checked, which tells what the placeholder is: a field, a path segment, a
+// type or a plain name.
+func findCompletionSite(program *types.Program, doc *Document, offset int) *completionSite {
+	content := []rune(doc.Content)
+	if offset > len(content) {
+		offset = len(content)
+	}
+	start := offset
+	for start > 0 && isIdentRune(content[start-1]) {
+		start--
+	}
+	end := offset
+	for end < len(content) && isIdentRune(content[end]) {
+		end++
+	}
+	lineEnd := end
+	for lineEnd < len(content) && content[lineEnd] != '\n' {
+		lineEnd++
+	}
+
+	before := string(content[:start]) + completionPlaceholder
+	candidates := []string{before + string(content[end:])}
+	for _, ending := range completionEndings {
+		candidates = append(candidates, before+ending+string(content[lineEnd:]))
+	}
+
+	filePath := uriToPath(doc.URI)
+	for _, src := range candidates {
+		p := parser.New(src, parser.WithFilename(filePath))
+		file := p.ParseFile()
+		if len(p.Errors()) > 0 {
+			continue
+		}
+		path := file.Index().PathAt(start)
+		ident, ok := innermost(path).(*ast.Ident)
+		if !ok || ident.Name != completionPlaceholder {
+			continue
+		}
+		return &completionSite{result: program.CheckVariant(file, absPath(filePath)), path: path, ident: ident}
+	}
+	return nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// siteCompletions returns the items for the site's context. ok is false for a
+// plain name, which the caller completes from the scope.
+func (s *Server) siteCompletions(site *completionSite) (items []CompletionItem, ok bool) {
+	switch parent := site.parent().(type) {
+	case *ast.FieldExpr:
+		if parent.Field == site.ident {
+			return s.completionsForType(site.result, site.result.Types[parent.Target]), true
+		}
+	case *ast.InfixExpr:
+		if parent.Op == lexer.DOUBLE_COLON && parent.Right == site.ident {
+			return s.pathCompletions(site.result, parent.Left), true
+		}
+	case *ast.NamedType:
+		return s.typeCompletions(site), true
+	}
+	return nil, false
+}
+
+// pathCompletions returns what can follow `left::`: the public symbols of a
+// module, or the variants and static methods of a type
+func (s *Server) pathCompletions(result *types.CheckResult, left ast.Expr) []CompletionItem {
+	if index, ok := left.(*ast.IndexExpr); ok {
+		left = index.Target // Vec[int]::new
+	}
+	ident, ok := left.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	if mod, ok := result.Modules[ident.Name]; ok {
+		return s.completionsFromScope(mod.Scope)
+	}
+
+	sym := result.SymbolOf(ident)
+	if sym == nil {
+		sym = result.Scope.Lookup(ident.Name)
+	}
+	if sym == nil || sym.Type == nil {
+		return nil
+	}
+
+	var items []CompletionItem
+	if enum, ok := s.unwrapType(result, sym.Type).(*types.Enum); ok {
+		for _, variant := range enum.Variants {
+			detail := enum.Name + "::" + variant.Name
+			if len(variant.Params) > 0 {
+				params := make([]string, len(variant.Params))
+				for i, param := range variant.Params {
+					params[i] = param.String()
+				}
+				detail += "(" + strings.Join(params, ", ") + ")"
+			}
+			items = append(items, CompletionItem{
+				Label:  variant.Name,
+				Kind:   completionKindEnumMember,
+				Detail: detail,
+			})
+		}
+	}
+
+	methods := result.MethodsOf(sym.Type)
+	for _, name := range sortedMethodNames(methods) {
+		if fn := methods[name]; fn.Receiver == nil {
+			items = append(items, CompletionItem{
+				Label:  name,
+				Kind:   completionKindFunction,
+				Detail: formatSignature(name, fn, nil, nil),
+			})
+		}
+	}
+	return items
+}
+
+// primitiveTypeNames are the built-in types that can be named in type position
+var primitiveTypeNames = []string{
+	"int", "float", "bool", "string", "void",
+	"i8", "i32", "i64", "u8", "u16", "u32", "u64", "u128", "usize",
+}
+
+// typeCompletions returns the types that can be named at the site: the
+// primitives, declared structs, enums, traits and aliases, the type
+// parameters of enclosing declarations, and loaded modules
+func (s *Server) typeCompletions(site *completionSite) []CompletionItem {
+	var items []CompletionItem
+	for _, name := range primitiveTypeNames {
+		items = append(items, CompletionItem{Label: name, Kind: completionKindKeyword, Detail: "builtin type"})
+	}
+
+	seen := make(map[string]bool)
+	for scope := site.result.Scope; scope != nil; scope = scope.Parent {
+		names := make([]string, 0, len(scope.Symbols))
+		for name := range scope.Symbols {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if seen[name] || name == completionPlaceholder {
+				continue
+			}
+			if detail, ok := typeSymbolDetail(scope.Symbols[name]); ok {
+				seen[name] = true
+				items = append(items, CompletionItem{Label: name, Kind: completionKindClass, Detail: detail})
+			}
+		}
+	}
+	for _, name := range types.PreludeTypeNames() {
+		if !seen[name] {
+			items = append(items, CompletionItem{Label: name, Kind: completionKindClass, Detail: "prelude type"})
+		}
+	}
+
+	for _, node := range site.path {
+		var params []ast.GenericParam
+		switch decl := node.(type) {
+		case *ast.FnDecl:
+			params = decl.TypeParams
+		case *ast.StructDecl:
+			params = decl.TypeParams
+		case *ast.EnumDecl:
+			params = decl.TypeParams
+		case *ast.TraitDecl:
+			params = decl.TypeParams
+		case *ast.ImplDecl:
+			params = decl.TypeParams
+		}
+		for _, param := range params {
+			if tp, ok := param.(*ast.TypeParam); ok && tp.Name != nil {
+				items = append(items, CompletionItem{Label: tp.Name.Name, Kind: completionKindTypeParameter, Detail: "type parameter"})
+			}
+		}
+	}
+
+	modules := make([]string, 0, len(site.result.Modules))
+	for name := range site.result.Modules {
+		if !strings.Contains(name, "/") { // Prelude modules cannot be named
+			modules = append(modules, name)
+		}
+	}
+	sort.Strings(modules)
+	for _, name := range modules {
+		items = append(items, CompletionItem{Label: name, Kind: completionKindModule, Detail: "module"})
+	}
+	return items
+}
+
+// typeSymbolDetail reports whether sym names a type, and describes it
+func typeSymbolDetail(sym *types.Symbol) (string, bool) {
+	switch sym.DefNode.(type) {
+	case *ast.StructDecl:
+		return "struct", true
+	case *ast.EnumDecl:
+		return "enum", true
+	case *ast.TraitDecl:
+		return "trait", true
+	case *ast.TypeAliasDecl:
+		return "type " + sym.Type.String(), true
+	}
+	return "", false
+}
+
+// localCompletions returns the variables and parameters visible at the site:
+// those defined in the enclosing function before the placeholder
+func (s *Server) localCompletions(site *completionSite) []CompletionItem {
+	var fn *ast.FnDecl
+	for _, node := range site.path {
+		if decl, ok := node.(*ast.FnDecl); ok {
+			fn = decl
+		}
+	}
+	if fn == nil {
+		return nil
+	}
+
+	fnSpan := fn.Span()
+	at := site.ident.Span().Start
+	var locals []*ast.Ident
+	for ident := range site.result.Defs {
+		span := ident.Span()
+		if span.Filename == fnSpan.Filename && span.Start > fnSpan.Start && span.End <= at {
+			locals = append(locals, ident)
+		}
+	}
+	sort.Slice(locals, func(i, j int) bool { return locals[i].Span().Start < locals[j].Span().Start })
+
+	// A later definition shadows an earlier one of the same name
+	var items []CompletionItem
+	index := make(map[string]int)
+	for _, ident := range locals {
+		sym := site.result.Defs[ident]
+		if sym.Type == nil {
+			continue
+		}
+		item := CompletionItem{
+			Label:  sym.Name,
+			Kind:   completionKindVariable,
+			Detail: sym.Type.String(),
+		}
+		if i, ok := index[sym.Name]; ok {
+			items[i] = item
+			continue
+		}
+		index[sym.Name] = len(items)
+		items = append(items, item)
+	}
+	return items
+}
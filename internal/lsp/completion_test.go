@@ -0,0 +1,99 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+)
+
+const completionPrelude = `struct Point[T] { x: T, y: T }
+
+impl[T] Point[T] {
+    fn new(x: T, y: T) -> Point[T] {
+        return Point[T] { x: x, y: y };
+    }
+    fn sum(&self) -> int {
+        return 0;
+    }
+}
+
+enum Color { Red, Rgb(int, int, int) }
+
+fn main() {
+    let p = Point[int] { x: 1, y: 2 };
+    let count = 3;
+`
+
+// completeAt completes at the end of line, which is appended to
+// completionPrelude and left unfinished
+func completeAt(t *testing.T, line string) map[string]CompletionItem {
+	t.Helper()
+	s := NewServer()
+	doc := &Document{URI: "file:///tmp/complete.mal", Content: completionPrelude + line + "\n}\n"}
+	s.updateDocument(doc)
+
+	pos := Position{Line: strings.Count(completionPrelude, "\n"), Character: len(line)}
+	items := make(map[string]CompletionItem)
+	for _, item := range s.getCompletions(doc, pos) {
+		items[item.Label] = item
+	}
+	return items
+}
+
+func TestCompleteMembersOfBrokenCode(t *testing.T) {
+	for _, line := range []string{"    p.", "    p.s", "    println(p."} {
+		items := completeAt(t, line)
+		if items["x"].Kind != completionKindField || items["x"].Detail != "int" {
+			t.Errorf("%q: expected field x: int, got %+v", line, items["x"])
+		}
+		if items["sum"].Kind != completionKindMethod || items["sum"].Detail != "fn sum() -> int" {
+			t.Errorf("%q: expected method sum, got %+v", line, items["sum"])
+		}
+		if _, ok := items["new"]; ok {
+			t.Errorf("%q: static method new should not be offered after `.`", line)
+		}
+		if _, ok := items["main"]; ok {
+			t.Errorf("%q: only members should be offered after `.`", line)
+		}
+	}
+}
+
+func TestCompletePaths(t *testing.T) {
+	items := completeAt(t, "    let c = Color::")
+	if items["Red"].Kind != completionKindEnumMember || items["Rgb"].Detail != "Color::Rgb(int, int, int)" {
+		t.Errorf("expected the variants of Color, got %+v", items)
+	}
+
+	items = completeAt(t, "    let q = Point[int]::")
+	if _, ok := items["new"]; !ok || len(items) != 1 {
+		t.Errorf("expected only the static method new, got %+v", items)
+	}
+}
+
+func TestCompleteTypes(t *testing.T) {
+	items := completeAt(t, "    let z: ")
+	for _, want := range []string{"int", "Point", "Color", "Vec"} {
+		if _, ok := items[want]; !ok {
+			t.Errorf("expected type %s to be offered", want)
+		}
+	}
+	for _, unwanted := range []string{"p", "main", "println"} {
+		if _, ok := items[unwanted]; ok {
+			t.Errorf("%s is not a type", unwanted)
+		}
+	}
+}
+
+func TestCompleteNamesIncludesLocals(t *testing.T) {
+	items := completeAt(t, "    c")
+	if items["count"].Detail != "int" || items["p"].Detail != "Point[int]" {
+		t.Errorf("expected the locals of main, got %+v %+v", items["count"], items["p"])
+	}
+	if _, ok := items["main"]; !ok {
+		t.Errorf("expected global functions")
+	}
+	for label := range items {
+		if strings.HasPrefix(label, "__") {
+			t.Errorf("intrinsic %s should not be offered", label)
+		}
+	}
+}
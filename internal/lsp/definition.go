@@ -3,6 +3,10 @@ package lsp
 import (
 	"encoding/json"
 	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
 // DefinitionParams represents definition request parameters.
@@ -29,11 +33,9 @@ func (s *Server) handleDefinition(msg *jsonrpcMessage) *jsonrpcMessage {
 		}
 	}
 
-	s.mu.RLock()
-	doc, ok := s.Documents[params.TextDocument.URI]
-	s.mu.RUnlock()
+	doc, ok := s.document(params.TextDocument.URI)
 
-	if !ok || doc.Checker == nil || doc.File == nil {
+	if !ok || doc.Result == nil || doc.File == nil {
 		return &jsonrpcMessage{
 			JSONRPC: "2.0",
 			ID:      msg.ID,
@@ -42,7 +44,7 @@ func (s *Server) handleDefinition(msg *jsonrpcMessage) *jsonrpcMessage {
 	}
 
 	// Find definition
-	location := s.findDefinition(doc, params.Position, params.TextDocument.URI)
+	location := s.findDefinition(doc, params.Position)
 
 	return &jsonrpcMessage{
 		JSONRPC: "2.0",
@@ -51,48 +53,96 @@ func (s *Server) handleDefinition(msg *jsonrpcMessage) *jsonrpcMessage {
 	}
 }
 
-func (s *Server) findDefinition(doc *Document, pos Position, uri string) *Location {
-	// Convert LSP position to source offset
-	offset := positionToOffset(doc.Content, pos)
-
-	// Find the identifier at this position
-	ident := findIdentifierAt(doc.File, offset)
-	if ident == nil {
+func (s *Server) findDefinition(doc *Document, pos Position) *Location {
+	sym := symbolAt(doc, pos)
+	if sym == nil {
 		return nil
 	}
-
-	// Look up symbol
-	if doc.Checker == nil || doc.Checker.GlobalScope == nil {
+	def := sym.DefIdent()
+	if def == nil {
 		return nil
 	}
+	location := newDiagnosticConverter(doc).location(diagSpan(def.Span()))
+	return &location
+}
 
-	sym := doc.Checker.GlobalScope.Lookup(ident.Name)
-	if sym == nil || sym.DefNode == nil {
-		return nil
+// ReferenceParams represents find-references request parameters.
+type ReferenceParams struct {
+	TextDocumentPositionParams
+	Context ReferenceContext `json:"context"`
+}
+
+// ReferenceContext controls whether the declaration is part of the result.
+type ReferenceContext struct {
+	IncludeDeclaration bool `json:"includeDeclaration"`
+}
+
+func (s *Server) handleReferences(msg *jsonrpcMessage) *jsonrpcMessage {
+	var params ReferenceParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &jsonrpcError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid params: %v", err),
+			},
+		}
+	}
+
+	doc, ok := s.document(params.TextDocument.URI)
+
+	if !ok || doc.Result == nil || doc.File == nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Result:  nil,
+		}
+	}
+
+	return &jsonrpcMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  s.findReferences(doc, params.Position, params.Context.IncludeDeclaration),
 	}
+}
 
-	// Get definition span
-	span := sym.DefNode.Span()
-
-	// Convert to LSP range
-	range_ := Range{
-		Start: Position{
-			Line:      span.Line - 1,
-			Character: span.Column - 1,
-		},
-		End: Position{
-			Line:      span.Line - 1,
-			Character: span.Column - 1,
-		},
+func (s *Server) findReferences(doc *Document, pos Position, includeDeclaration bool) []Location {
+	sym := symbolAt(doc, pos)
+	if sym == nil {
+		return nil
 	}
 
-	// For now, assume definition is in the same file
-	// TODO: Handle cross-file definitions (modules)
-	defURI := uri
+	conv := newDiagnosticConverter(doc)
+	locations := []Location{}
+	if def := sym.DefIdent(); def != nil && includeDeclaration {
+		locations = append(locations, conv.location(diagSpan(def.Span())))
+	}
+	for _, ident := range doc.Result.References(sym) {
+		locations = append(locations, conv.location(diagSpan(ident.Span())))
+	}
+	return locations
+}
 
-	return &Location{
-		URI:   defURI,
-		Range: range_,
+// symbolAt returns the symbol named by the identifier under pos, whether the
+// identifier is a use or the definition itself
+func symbolAt(doc *Document, pos Position) *types.Symbol {
+	if doc.Result == nil || doc.File == nil {
+		return nil
 	}
+	ident := findIdentifierAt(doc.File, positionToOffset(doc.Content, pos))
+	if ident == nil {
+		return nil
+	}
+	return doc.Result.SymbolOf(ident)
 }
 
+func diagSpan(span lexer.Span) diag.Span {
+	return diag.Span{
+		Filename: span.Filename,
+		Line:     span.Line,
+		Column:   span.Column,
+		Start:    span.Start,
+		End:      span.End,
+	}
+}
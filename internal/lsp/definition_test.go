@@ -0,0 +1,73 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDefinitionAcrossModules(t *testing.T) {
+	dir := t.TempDir()
+	utils := "pub fn helper(n: int) -> int {\n    return n + 1;\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "utils.mal"), []byte(utils), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer()
+	doc := &Document{
+		URI:     pathToURI(filepath.Join(dir, "main.mal")),
+		Content: "mod utils;\n\nfn main() {\n    let x = utils::helper(1);\n    println(x);\n}\n",
+	}
+	s.updateDocument(doc)
+	if errs := errorsOf(doc); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	got := s.findDefinition(doc, Position{Line: 3, Character: 20})
+	want := Location{
+		URI:   pathToURI(filepath.Join(dir, "utils.mal")),
+		Range: Range{Start: Position{Line: 0, Character: 7}, End: Position{Line: 0, Character: 13}},
+	}
+	if got == nil || *got != want {
+		t.Errorf("definition of helper = %+v, want %+v", got, want)
+	}
+}
+
+func TestFindReferences(t *testing.T) {
+	s := NewServer()
+	doc := &Document{
+		URI:     "file:///tmp/refs.mal",
+		Content: "fn twice(n: int) -> int {\n    return n + n;\n}\n\nfn main() {\n    let n = twice(2);\n    println(twice(n));\n}\n",
+	}
+	s.updateDocument(doc)
+	if errs := errorsOf(doc); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	// From the declaration of twice, with the declaration included
+	refs := s.findReferences(doc, Position{Line: 0, Character: 4}, true)
+	wantLines := []int{0, 5, 6}
+	if len(refs) != len(wantLines) {
+		t.Fatalf("references to twice = %+v, want lines %v", refs, wantLines)
+	}
+	for i, ref := range refs {
+		if ref.Range.Start.Line != wantLines[i] {
+			t.Errorf("reference %d is on line %d, want %d", i, ref.Range.Start.Line, wantLines[i])
+		}
+	}
+
+	// From a use of the parameter n; the local n in main is a different symbol
+	refs = s.findReferences(doc, Position{Line: 1, Character: 11}, false)
+	if len(refs) != 2 {
+		t.Fatalf("references to parameter n = %+v, want 2", refs)
+	}
+	for _, ref := range refs {
+		if ref.Range.Start.Line != 1 {
+			t.Errorf("parameter n should only be used on line 2, got %+v", ref.Range)
+		}
+	}
+
+	if def := s.findDefinition(doc, Position{Line: 6, Character: 18}); def == nil || def.Range.Start != (Position{Line: 5, Character: 8}) {
+		t.Errorf("definition of local n = %+v, want line 6 column 9", def)
+	}
+}
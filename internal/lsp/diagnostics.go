@@ -0,0 +1,220 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// Diagnostic represents an LSP diagnostic.
+type Diagnostic struct {
+	Range              Range                          `json:"range"`
+	Severity           int                            `json:"severity"`
+	Code               string                         `json:"code,omitempty"`
+	Source             string                         `json:"source,omitempty"`
+	Message            string                         `json:"message"`
+	RelatedInformation []DiagnosticRelatedInformation `json:"relatedInformation,omitempty"`
+}
+
+// DiagnosticRelatedInformation points at another location relevant to a
+// diagnostic, such as the declaration a type mismatch conflicts with.
+type DiagnosticRelatedInformation struct {
+	Location Location `json:"location"`
+	Message  string   `json:"message"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Position is a zero-based line and UTF-16 code unit offset.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// diagnosticConverter turns compiler diagnostics for one document into LSP
+// diagnostics. Spans in other files are resolved against their source,
+// which is read once per file.
+type diagnosticConverter struct {
+	doc     *Document
+	path    string
+	sources map[string]string
+}
+
+func newDiagnosticConverter(doc *Document) *diagnosticConverter {
+	return &diagnosticConverter{
+		doc:     doc,
+		path:    uriToPath(doc.URI),
+		sources: make(map[string]string),
+	}
+}
+
+// convert builds the LSP form of d. The primary span is the range; the
+// primary label, proof chain, notes and help that the CLI prints around the
+// source excerpt are appended to the message; secondary spans, old-style
+// related spans and located proof steps become related information.
+func (c *diagnosticConverter) convert(d diag.Diagnostic) Diagnostic {
+	primary := d.Span
+	primaryLabel := ""
+	var related []DiagnosticRelatedInformation
+	for _, ls := range d.LabeledSpans {
+		if ls.Style == "primary" && primaryLabel == "" && ls.Span.IsValid() {
+			primary = ls.Span
+			primaryLabel = ls.Label
+			continue
+		}
+		if ls.Span.IsValid() {
+			message := ls.Label
+			if message == "" {
+				message = "related location"
+			}
+			related = append(related, c.related(ls.Span, message))
+		}
+	}
+	for _, span := range d.Related {
+		if span.IsValid() {
+			related = append(related, c.related(span, "related location"))
+		}
+	}
+
+	var message strings.Builder
+	message.WriteString(d.Message)
+	if primaryLabel != "" && primaryLabel != d.Message {
+		message.WriteString("\n" + primaryLabel)
+	}
+	for _, step := range d.ProofChain {
+		message.WriteString("\nnote: " + step.Message)
+		if step.Span.IsValid() {
+			related = append(related, c.related(step.Span, step.Message))
+		}
+	}
+	for _, note := range d.Notes {
+		message.WriteString("\nnote: " + note)
+	}
+	if d.Help != "" {
+		message.WriteString("\nhelp: " + d.Help)
+	} else if d.Suggestion != "" {
+		message.WriteString("\nhelp: " + d.Suggestion)
+	}
+
+	return Diagnostic{
+		Range:              c.rangeOf(primary),
+		Severity:           diagnosticSeverity(d.Severity),
+		Code:               string(d.Code),
+		Source:             "malphas",
+		Message:            message.String(),
+		RelatedInformation: related,
+	}
+}
+
+func (c *diagnosticConverter) related(span diag.Span, message string) DiagnosticRelatedInformation {
+	return DiagnosticRelatedInformation{
+		Location: c.location(span),
+		Message:  message,
+	}
+}
+
+// location maps a span to an LSP location, in another file when the span
+// names one
+func (c *diagnosticConverter) location(span diag.Span) Location {
+	uri := c.doc.URI
+	if span.Filename != "" && !c.isDocument(span.Filename) {
+		uri = pathToURI(span.Filename)
+	}
+	return Location{URI: uri, Range: c.rangeOf(span)}
+}
+
+// isDocument reports whether filename names the document being converted
+func (c *diagnosticConverter) isDocument(filename string) bool {
+	if filename == c.path {
+		return true
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	docAbs, err := filepath.Abs(c.path)
+	return err == nil && abs == docAbs
+}
+
+// source returns the text a span's offsets refer to
+func (c *diagnosticConverter) source(filename string) (string, bool) {
+	if filename == "" || c.isDocument(filename) {
+		return c.doc.Content, true
+	}
+	if src, ok := c.sources[filename]; ok {
+		return src, true
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", false
+	}
+	c.sources[filename] = string(data)
+	return string(data), true
+}
+
+// rangeOf maps a span to an LSP range. Span offsets count runes from the
+// start of the file; when they are missing or the source is unavailable the
+// range covers the span's line and column only.
+func (c *diagnosticConverter) rangeOf(span diag.Span) Range {
+	if span.End > span.Start {
+		if src, ok := c.source(span.Filename); ok && span.End <= len([]rune(src)) {
+			return Range{Start: positionAt(src, span.Start), End: positionAt(src, span.End)}
+		}
+	}
+	start := Position{Line: max(span.Line-1, 0), Character: max(span.Column-1, 0)}
+	end := start
+	if span.End > span.Start {
+		end.Character += span.End - span.Start
+	}
+	return Range{Start: start, End: end}
+}
+
+// positionAt converts a rune offset in src to an LSP position
+func positionAt(src string, offset int) Position {
+	var pos Position
+	i := 0
+	for _, r := range src {
+		if i == offset {
+			break
+		}
+		i++
+		if r == '\n' {
+			pos.Line++
+			pos.Character = 0
+			continue
+		}
+		pos.Character += utf16.RuneLen(r)
+	}
+	return pos
+}
+
+func diagnosticSeverity(sev diag.Severity) int {
+	switch sev {
+	case diag.SeverityError:
+		return 1 // Error
+	case diag.SeverityWarning:
+		return 2 // Warning
+	case diag.SeverityNote:
+		return 3 // Information
+	default:
+		return 1
+	}
+}
+
+// pathToURI converts a file path to a file:// URI.
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "file://" + path
+}
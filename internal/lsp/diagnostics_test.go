@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestConvertDiagnostic(t *testing.T) {
+	content := "fn main() {\n    let s = \"héllo😀\"; let x: int = s;\n}\n"
+	doc := &Document{URI: "file:///tmp/main.mal", Content: content}
+
+	// Span offsets count runes
+	useStart := len([]rune(content[:strings.Index(content, "= s;")])) + 2
+	declStart := len([]rune("fn main() {\n    let "))
+
+	d := diag.Diagnostic{
+		Severity: diag.SeverityError,
+		Code:     diag.CodeTypeMismatch,
+		Message:  "expected `int`, found `string`",
+		Span:     diag.Span{Filename: "/tmp/main.mal", Line: 2, Column: 40, Start: useStart, End: useStart + 1},
+		Notes:    []string{"`s` was inferred as `string`"},
+		Help:     "convert with `parse_int(s)`",
+	}
+	d = d.WithPrimarySpan(d.Span, "expected `int`")
+	d = d.WithSecondarySpan(diag.Span{Filename: "/tmp/main.mal", Line: 2, Column: 9, Start: declStart, End: declStart + 1}, "declared here")
+	d = d.WithSecondarySpan(diag.Span{Filename: "/tmp/other.mal", Line: 3, Column: 5, Start: 0, End: 0}, "imported from here")
+
+	got := newDiagnosticConverter(doc).convert(d)
+
+	// 35 runes precede the use of s on its line, but 😀 is two UTF-16 code
+	// units
+	wantStart := Position{Line: 1, Character: 36}
+	if got.Range.Start != wantStart || got.Range.End.Character != wantStart.Character+1 {
+		t.Errorf("range = %+v, want start %+v with length 1", got.Range, wantStart)
+	}
+	if got.Code != "TYPE_MISMATCH" || got.Source != "malphas" || got.Severity != 1 {
+		t.Errorf("unexpected code/source/severity: %+v", got)
+	}
+	for _, want := range []string{"expected `int`, found `string`", "\nexpected `int`", "\nnote: `s` was inferred", "\nhelp: convert with"} {
+		if !strings.Contains(got.Message, want) {
+			t.Errorf("message %q should contain %q", got.Message, want)
+		}
+	}
+
+	if len(got.RelatedInformation) != 2 {
+		t.Fatalf("expected 2 related locations, got %+v", got.RelatedInformation)
+	}
+	decl := got.RelatedInformation[0]
+	if decl.Location.URI != doc.URI || decl.Message != "declared here" || decl.Location.Range.Start != (Position{Line: 1, Character: 8}) {
+		t.Errorf("unexpected declaration location: %+v", decl)
+	}
+	other := got.RelatedInformation[1]
+	if other.Location.URI != "file:///tmp/other.mal" || other.Location.Range.Start != (Position{Line: 2, Character: 4}) {
+		t.Errorf("unexpected cross-file location: %+v", other)
+	}
+}
+
+func TestUpdateDocumentKeepsParseErrorHelp(t *testing.T) {
+	s := NewServer()
+	doc := &Document{URI: "file:///tmp/broken.mal", Content: "fn main() {\n    let x = ;\n}\n"}
+	s.updateDocument(doc)
+	if len(doc.Errors) == 0 {
+		t.Fatal("expected a parse error")
+	}
+
+	got := newDiagnosticConverter(doc).convert(doc.Errors[0])
+	if got.Code == "" || got.Range.Start.Line != 1 {
+		t.Errorf("unexpected diagnostic: %+v", got)
+	}
+}
+
+func TestUpdateDocumentReportsTypeErrors(t *testing.T) {
+	s := NewServer()
+	doc := &Document{URI: "file:///tmp/typed.mal", Content: "fn main() {\n    let x: int = \"no\";\n}\n"}
+	s.updateDocument(doc)
+	if len(doc.Errors) == 0 {
+		t.Fatal("expected a type error")
+	}
+
+	got := newDiagnosticConverter(doc).convert(doc.Errors[0])
+	if got.Range.Start.Line != 1 || got.Range.End == got.Range.Start {
+		t.Errorf("type error should cover its expression on line 2, got %+v", got.Range)
+	}
+}
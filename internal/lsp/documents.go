@@ -0,0 +1,161 @@
+package lsp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// defaultDebounce is how long the server waits after a change before
+// checking the document, so a burst of keystrokes is checked once
+const defaultDebounce = 150 * time.Millisecond
+
+// analysis is the result of parsing and checking one version of a document
+type analysis struct {
+	content string
+	file    *ast.File
+	result  *types.CheckResult // nil when the content does not parse
+	errors  []diag.Diagnostic
+}
+
+// analyze parses and type checks the content of the document at uri. base
+// is the document's last analysis that type checked, or nil; when the
+// content changed only inside one function body, base is edited rather
+// than redone (see incremental.go).
+func (s *Server) analyze(uri, content string, base *analysis) *analysis {
+	if a := s.reanalyze(uri, content, base); a != nil {
+		return a
+	}
+	filePath := uriToPath(uri)
+	p := parser.New(content, parser.WithFilename(filePath))
+	a := &analysis{content: content, file: p.ParseFile()}
+	for _, err := range p.Errors() {
+		a.errors = append(a.errors, err.Diagnostic())
+	}
+	if len(p.Errors()) == 0 {
+		a.result = s.checkFile(a.file, filePath)
+		a.errors = append(a.errors, a.result.Errors...)
+		a.errors = append(a.errors, a.result.Warnings...)
+	}
+	return a
+}
+
+// install makes a the document's current parse and check. A document that
+// does not parse keeps the check of its last version that did.
+func install(doc *Document, a *analysis) {
+	doc.File = a.file
+	doc.Errors = a.errors
+	doc.dirty = false
+	if a.result != nil {
+		doc.Result = a.result
+		doc.checked = a
+	}
+}
+
+// applyChange applies a content change to content
+func applyChange(content string, change TextDocumentContentChangeEvent) string {
+	if change.Range == nil {
+		return change.Text
+	}
+	runes := []rune(content)
+	start := positionToOffset(content, change.Range.Start)
+	end := positionToOffset(content, change.Range.End)
+	if end < start {
+		end = start
+	}
+	return string(runes[:start]) + change.Text + string(runes[end:])
+}
+
+// scheduleCheck checks doc once it has gone s.debounce without changes. The
+// caller holds s.mu.
+func (s *Server) scheduleCheck(doc *Document) {
+	if doc.pending != nil {
+		doc.pending.Stop()
+	}
+	uri := doc.URI
+	doc.pending = time.AfterFunc(s.debounce, func() { s.flush(uri) })
+}
+
+// flush checks the changes to the document at uri that are waiting for the
+// debounce and publishes its diagnostics. The check runs without holding
+// s.mu; if the document changes in the meantime the result is dropped,
+// since the newer change has scheduled a check of its own.
+func (s *Server) flush(uri string) {
+	s.mu.Lock()
+	checkMu := s.checkMu[uri]
+	if checkMu == nil {
+		checkMu = new(sync.Mutex)
+		s.checkMu[uri] = checkMu
+	}
+	s.mu.Unlock()
+	checkMu.Lock()
+	defer checkMu.Unlock()
+
+	s.mu.Lock()
+	doc, ok := s.Documents[uri]
+	if !ok || !doc.dirty {
+		s.mu.Unlock()
+		return
+	}
+	if doc.pending != nil {
+		doc.pending.Stop()
+		doc.pending = nil
+	}
+	content, base := doc.Content, doc.checked
+	s.mu.Unlock()
+
+	a := s.analyze(uri, content, base)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Documents[uri] != doc {
+		return
+	}
+	if doc.Content != content {
+		// The check may have reused the nodes of base, so the next one
+		// must start from a instead
+		if a.result != nil {
+			doc.checked = a
+		}
+		return
+	}
+	install(doc, a)
+	s.publishDiagnostics(doc)
+}
+
+// flushAll checks the changes to every open document that are waiting for
+// the debounce. Since checks edit the nodes of earlier ones, requests that
+// read documents besides their own call it first.
+func (s *Server) flushAll() {
+	s.mu.RLock()
+	var dirty []string
+	for uri, doc := range s.Documents {
+		if doc.dirty {
+			dirty = append(dirty, uri)
+		}
+	}
+	s.mu.RUnlock()
+	for _, uri := range dirty {
+		s.flush(uri)
+	}
+}
+
+// document returns a snapshot of the open document at uri for answering a
+// request. Changes still waiting for the debounce are checked first, so the
+// answer is about the text the client has.
+func (s *Server) document(uri string) (*Document, bool) {
+	s.flush(uri)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	doc, ok := s.Documents[uri]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *doc
+	return &snapshot, true
+}
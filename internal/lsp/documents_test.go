@@ -0,0 +1,119 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestApplyChange(t *testing.T) {
+	at := func(line, char int) Position { return Position{Line: line, Character: char} }
+	tests := []struct {
+		name    string
+		content string
+		change  TextDocumentContentChangeEvent
+		want    string
+	}{
+		{"full text", "old", TextDocumentContentChangeEvent{Text: "new"}, "new"},
+		{"insert", "let x = 1;\n", TextDocumentContentChangeEvent{Range: &Range{Start: at(0, 9), End: at(0, 9)}, Text: "0"}, "let x = 10;\n"},
+		{"across lines", "a\nbc\nd", TextDocumentContentChangeEvent{Range: &Range{Start: at(0, 1), End: at(2, 0)}, Text: "-"}, "a-d"},
+		{"after astral character", "\"😀\" + x", TextDocumentContentChangeEvent{Range: &Range{Start: at(0, 7), End: at(0, 8)}, Text: "y"}, "\"😀\" + y"},
+	}
+	for _, tt := range tests {
+		if got := applyChange(tt.content, tt.change); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// errorsOf returns the diagnostics of doc that are errors, leaving out
+// warnings such as unused variables
+func errorsOf(doc *Document) []diag.Diagnostic {
+	var errs []diag.Diagnostic
+	for _, d := range doc.Errors {
+		if d.Severity == diag.SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
+
+// notify delivers a notification to s as the client would send it
+func notify(t *testing.T, s *Server, method string, params interface{}) {
+	t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.handleMessage(context.Background(), &jsonrpcMessage{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+func TestDidChangeIsDebounced(t *testing.T) {
+	var out bytes.Buffer
+	s := NewServer()
+	s.out = &out
+	s.debounce = time.Hour
+
+	uri := "file:///tmp/debounce.mal"
+	notify(t, s, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: "fn main() {\n}\n"},
+	})
+	// Typing "let x = 1;" in three changes
+	for i, change := range []struct {
+		at   int
+		text string
+	}{{11, "l"}, {12, "et"}, {14, " x = 1;"}} {
+		at := Position{Line: 0, Character: change.at}
+		notify(t, s, "textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: i + 2},
+			ContentChanges: []TextDocumentContentChangeEvent{{Range: &Range{Start: at, End: at}, Text: change.text}},
+		})
+	}
+	if n := strings.Count(out.String(), "publishDiagnostics"); n != 1 {
+		t.Fatalf("published %d times before the debounce elapsed, want once for didOpen", n)
+	}
+
+	// A request checks the pending changes at once
+	doc, ok := s.document(uri)
+	if !ok {
+		t.Fatal("document not open")
+	}
+	if doc.Content != "fn main() {let x = 1;\n}\n" {
+		t.Fatalf("content = %q", doc.Content)
+	}
+	if n := strings.Count(out.String(), "publishDiagnostics"); n != 2 {
+		t.Fatalf("published %d times, want 2", n)
+	}
+	if !strings.Contains(out.String(), `"version":4`) || strings.Contains(out.String(), `"version":2`) {
+		t.Errorf("expected diagnostics for the last version only, got %s", out.String())
+	}
+
+	// Without a request, the check runs once the debounce elapses
+	s.debounce = time.Millisecond
+	notify(t, s, "textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 5},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "fn main() {\n    let y: int = true;\n}\n"}},
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.RLock()
+		dirty := s.Documents[uri].dirty
+		published := out.String()
+		s.mu.RUnlock()
+		if !dirty {
+			if !strings.Contains(published, `"version":5`) {
+				t.Errorf("expected diagnostics for version 5, got %s", published)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("debounced check did not run")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
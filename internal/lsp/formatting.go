@@ -0,0 +1,126 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/malphas-lang/malphas-lang/internal/format"
+)
+
+// DocumentFormattingParams is sent with textDocument/formatting. The
+// client's formatting options are ignored: Malphas has a single layout.
+type DocumentFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DocumentRangeFormattingParams is sent with textDocument/rangeFormatting.
+type DocumentRangeFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+func (s *Server) handleFormatting(msg *jsonrpcMessage) *jsonrpcMessage {
+	var params DocumentFormattingParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &jsonrpcError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid params: %v", err),
+			},
+		}
+	}
+
+	return &jsonrpcMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  s.formattingEdits(params.TextDocument.URI, 0, -1),
+	}
+}
+
+func (s *Server) handleRangeFormatting(msg *jsonrpcMessage) *jsonrpcMessage {
+	var params DocumentRangeFormattingParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &jsonrpcError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid params: %v", err),
+			},
+		}
+	}
+
+	// A selection ending at the start of a line does not include that line
+	first, last := params.Range.Start.Line, params.Range.End.Line
+	if last > first && params.Range.End.Character == 0 {
+		last--
+	}
+	return &jsonrpcMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  s.formattingEdits(params.TextDocument.URI, first, last),
+	}
+}
+
+// formattingEdits formats the lines first to last of the document at uri,
+// or to its end if last is negative. Documents that do not lex get no
+// edits, so formatting on save is a no-op while a string is still open.
+func (s *Server) formattingEdits(uri string, first, last int) []TextEdit {
+	doc, ok := s.document(uri)
+	if !ok {
+		return nil
+	}
+	lines, err := format.Lines(doc.Content)
+	if err != nil {
+		return nil
+	}
+	return lineEdits(doc.Content, lines, first, last)
+}
+
+// lineEdits returns the edits that turn the lines first to last of content
+// into their formatted form. Each changed line is replaced up to its line
+// break, so the document keeps its line endings.
+func lineEdits(content string, lines []format.Line, first, last int) []TextEdit {
+	orig := strings.Split(content, "\n")
+	if last < 0 || last >= len(orig) {
+		last = len(orig) - 1
+	}
+	eol := "\n"
+	if strings.Contains(content, "\r\n") {
+		eol = "\r\n"
+	}
+	edits := []TextEdit{}
+	for i := max(first, 0); i <= last; i++ {
+		old := strings.TrimSuffix(orig[i], "\r")
+		end := Position{Line: i, Character: utf16Len(old)}
+		if lines[i].Drop {
+			if i+1 < len(orig) {
+				end = Position{Line: i + 1}
+			}
+			if end != (Position{Line: i}) {
+				edits = append(edits, TextEdit{Range: Range{Start: Position{Line: i}, End: end}})
+			}
+			continue
+		}
+		text := strings.TrimSuffix(lines[i].Text, "\r")
+		if i == len(orig)-1 {
+			text += eol // The file ends with a line break
+		}
+		if text != old {
+			edits = append(edits, TextEdit{Range: Range{Start: Position{Line: i}, End: end}, NewText: text})
+		}
+	}
+	return edits
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
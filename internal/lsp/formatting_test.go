@@ -0,0 +1,44 @@
+package lsp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFormatting(t *testing.T) {
+	s := NewServer()
+	s.out = &bytes.Buffer{}
+	uri := "file:///tmp/format.mal"
+	content := "fn main() {\r\nlet x = 1;   \r\n\r\n\r\n  let y = 2;\r\n}"
+	notify(t, s, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: content},
+	})
+
+	edits := s.formattingEdits(uri, 0, -1)
+	if got, want := applyEdits(content, edits), "fn main() {\r\n    let x = 1;\r\n\r\n    let y = 2;\r\n}\r\n"; got != want {
+		t.Errorf("formatting: got %q, want %q", got, want)
+	}
+
+	// Only the selected line changes
+	edits = s.formattingEdits(uri, 4, 4)
+	if got, want := applyEdits(content, edits), "fn main() {\r\nlet x = 1;   \r\n\r\n\r\n    let y = 2;\r\n}"; got != want {
+		t.Errorf("range formatting: got %q, want %q", got, want)
+	}
+
+	// Formatted documents need no edits
+	notify(t, s, "textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "fn main() {\n}\n"}},
+	})
+	if edits := s.formattingEdits(uri, 0, -1); len(edits) != 0 {
+		t.Errorf("expected no edits, got %v", edits)
+	}
+}
+
+// applyEdits applies non-overlapping edits to content, last first
+func applyEdits(content string, edits []TextEdit) string {
+	for i := len(edits) - 1; i >= 0; i-- {
+		content = applyChange(content, TextDocumentContentChangeEvent{Range: &edits[i].Range, Text: edits[i].NewText})
+	}
+	return content
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"unicode/utf16"
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
 	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
@@ -38,11 +40,9 @@ func (s *Server) handleHover(msg *jsonrpcMessage) *jsonrpcMessage {
 		}
 	}
 
-	s.mu.RLock()
-	doc, ok := s.Documents[params.TextDocument.URI]
-	s.mu.RUnlock()
+	doc, ok := s.document(params.TextDocument.URI)
 
-	if !ok || doc.Checker == nil || doc.File == nil {
+	if !ok || doc.Result == nil || doc.File == nil {
 		return &jsonrpcMessage{
 			JSONRPC: "2.0",
 			ID:      msg.ID,
@@ -61,104 +61,201 @@ func (s *Server) handleHover(msg *jsonrpcMessage) *jsonrpcMessage {
 }
 
 func (s *Server) getHover(doc *Document, pos Position) *Hover {
-	// Convert LSP position to source offset
-	offset := positionToOffset(doc.Content, pos)
-
-	// Find the identifier at this position
-	ident := findIdentifierAt(doc.File, offset)
-	if ident == nil {
+	if doc.Result == nil || doc.File == nil {
 		return nil
 	}
+	path := doc.File.Index().PathAt(positionToOffset(doc.Content, pos))
 
-	// Look up symbol
-	if doc.Checker == nil || doc.Checker.GlobalScope == nil {
+	var content string
+	var target ast.Node
+	if ident, ok := innermost(path).(*ast.Ident); ok {
+		if sym := doc.Result.SymbolOf(ident); sym != nil {
+			content = symbolHover(doc.Result, sym, ident, path)
+			target = ident
+		}
+	}
+	if content == "" {
+		// Fall back to the innermost expression or type the checker resolved
+		for i := len(path) - 1; i >= 0; i-- {
+			switch path[i].(type) {
+			case ast.Expr, ast.TypeExpr:
+			default:
+				continue
+			}
+			if typ, ok := doc.Result.Types[path[i]]; ok && typ != nil {
+				content = fmt.Sprintf("```malphas\n%s\n```", typ)
+				target = path[i]
+				break
+			}
+		}
+	}
+	if content == "" {
 		return nil
 	}
 
-	sym := doc.Checker.GlobalScope.Lookup(ident.Name)
-	if sym == nil {
-		return nil
+	span := newDiagnosticConverter(doc).rangeOf(diagSpan(target.Span()))
+	return &Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: content,
+		},
+		Range: &span,
+	}
+}
+
+// symbolHover describes the symbol ident resolves to. Functions show their
+// signature with type parameter bounds and, when ident is the callee of a
+// generic call, the signature instantiated with the call's type arguments.
+func symbolHover(result *types.CheckResult, sym *types.Symbol, ident *ast.Ident, path []ast.Node) string {
+	fn, ok := sym.Type.(*types.Function)
+	if !ok {
+		typ := sym.Type
+		if inferred, ok := result.Types[ident]; ok && inferred != nil {
+			typ = inferred
+		}
+		if typ == nil {
+			return ""
+		}
+		return fmt.Sprintf("```malphas\n%s: %s\n```", sym.Name, typ)
 	}
 
-	// Build hover content
-	content := fmt.Sprintf("```malphas\n%s: %s\n```", sym.Name, sym.Type.String())
+	var names []string
+	if decl, ok := sym.DefNode.(*ast.FnDecl); ok {
+		for _, param := range decl.Params {
+			names = append(names, param.Name.Name)
+		}
+	}
+	content := fmt.Sprintf("```malphas\n%s\n```", formatSignature(sym.Name, fn, names, nil))
 
-	// If it's a function, show signature
-	if fn, ok := sym.Type.(*types.Function); ok {
-		content = fmt.Sprintf("```malphas\nfn %s%s -> %s\n```",
-			sym.Name,
-			formatParams(fn.Params),
-			fn.Return.String())
+	if call := calleeOf(ident, path); call != nil && len(fn.TypeParams) > 0 {
+		if args := result.CallTypeArgs[call]; len(args) == len(fn.TypeParams) {
+			content += fmt.Sprintf("\n\ninstantiated as\n```malphas\n%s\n```", formatSignature(sym.Name, fn, names, args))
+		}
 	}
+	return content
+}
 
-	// Get range for the identifier
-	span := ident.Span()
-	range_ := &Range{
-		Start: Position{
-			Line:      span.Line - 1,
-			Character: span.Column - 1,
-		},
-		End: Position{
-			Line:      span.Line - 1,
-			Character: span.Column - 1 + len(ident.Name),
-		},
+// formatSignature renders fn as a declaration. With typeArgs the type
+// parameters are replaced by them.
+func formatSignature(name string, fn *types.Function, names []string, typeArgs []types.Type) string {
+	subst := make(map[string]types.Type)
+	var typeParams []string
+	for i, tp := range fn.TypeParams {
+		if typeArgs != nil {
+			subst[tp.Name] = typeArgs[i]
+			typeParams = append(typeParams, typeArgs[i].String())
+			continue
+		}
+		// Bounds are listed once, in the brackets
+		subst[tp.Name] = &types.TypeParam{Name: tp.Name}
+		typeParams = append(typeParams, formatTypeParam(tp))
 	}
 
-	return &Hover{
-		Contents: MarkupContent{
-			Kind:  "markdown",
-			Value: content,
-		},
-		Range: range_,
+	params := make([]string, len(fn.Params))
+	for i, param := range fn.Params {
+		params[i] = types.Substitute(param, subst).String()
+		if i < len(names) {
+			params[i] = names[i] + ": " + params[i]
+		}
+	}
+
+	var b strings.Builder
+	if fn.Unsafe {
+		b.WriteString("unsafe ")
+	}
+	b.WriteString("fn " + name)
+	if len(typeParams) > 0 {
+		b.WriteString("[" + strings.Join(typeParams, ", ") + "]")
 	}
+	b.WriteString("(" + strings.Join(params, ", ") + ")")
+	if fn.Return != nil && fn.Return != types.TypeVoid {
+		b.WriteString(" -> " + types.Substitute(fn.Return, subst).String())
+	}
+	return b.String()
 }
 
-func findIdentifierAt(file *ast.File, offset int) *ast.Ident {
-	var found *ast.Ident
-	ast.Walk(file, func(n ast.Node) bool {
-		if ident, ok := n.(*ast.Ident); ok {
-			span := ident.Span()
-			if offset >= span.Start && offset < span.End {
-				found = ident
-				return false // Stop walking
+func formatTypeParam(tp types.TypeParam) string {
+	if len(tp.Bounds) == 0 {
+		return tp.Name
+	}
+	bounds := make([]string, len(tp.Bounds))
+	for i, bound := range tp.Bounds {
+		if trait, ok := bound.(*types.Trait); ok {
+			bounds[i] = trait.Name
+		} else {
+			bounds[i] = bound.String()
+		}
+	}
+	return tp.Name + ": " + strings.Join(bounds, " + ")
+}
+
+// calleeOf returns the call whose callee names ident, looking through
+// explicit type arguments (f[int](x)) and paths (m::f(x))
+func calleeOf(ident *ast.Ident, path []ast.Node) *ast.CallExpr {
+	var child ast.Node = ident
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == ident {
+			continue
+		}
+		switch parent := path[i].(type) {
+		case *ast.CallExpr:
+			if parent.Callee == child {
+				return parent
+			}
+			return nil
+		case *ast.IndexExpr:
+			if parent.Target != child {
+				return nil
+			}
+		case *ast.InfixExpr:
+			if parent.Op != lexer.DOUBLE_COLON || parent.Right != child {
+				return nil
 			}
+		default:
+			return nil
 		}
-		return true // Continue walking
-	})
-	return found
+		child = path[i]
+	}
+	return nil
+}
+
+// innermost returns the last node of path, or nil
+func innermost(path []ast.Node) ast.Node {
+	if len(path) == 0 {
+		return nil
+	}
+	return path[len(path)-1]
+}
+
+// findIdentifierAt returns the identifier at offset in file, or nil
+func findIdentifierAt(file *ast.File, offset int) *ast.Ident {
+	ident, _ := ast.NodeAt(file, offset).(*ast.Ident)
+	return ident
 }
 
+// positionToOffset converts an LSP position to a rune offset in content,
+// the unit span offsets use. Characters count UTF-16 code units.
 func positionToOffset(content string, pos Position) int {
 	line := 0
 	col := 0
 	offset := 0
 
-	for i, r := range content {
-		if line == pos.Line && col == pos.Character {
+	for _, r := range content {
+		if line == pos.Line && col >= pos.Character {
 			return offset
 		}
 
 		if r == '\n' {
+			if line == pos.Line {
+				return offset // Past the end of the line
+			}
 			line++
 			col = 0
 		} else {
-			col++
+			col += utf16.RuneLen(r)
 		}
-		offset = i + 1
+		offset++
 	}
 
 	return offset
 }
-
-func formatParams(params []types.Type) string {
-	if len(params) == 0 {
-		return "()"
-	}
-
-	parts := make([]string, len(params))
-	for i, p := range params {
-		parts[i] = p.String()
-	}
-	return "(" + strings.Join(parts, ", ") + ")"
-}
-
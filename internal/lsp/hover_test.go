@@ -0,0 +1,81 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+)
+
+const hoverSource = `trait Named {
+    fn name(&self) -> string;
+}
+
+struct Dog {}
+
+impl Named for Dog {
+    fn name(&self) -> string {
+        return "dog";
+    }
+}
+
+fn pick[T: Named](a: T, b: T) -> T {
+    return a;
+}
+
+fn main() {
+    let d = pick(Dog {}, Dog {});
+    let n = 1 + 2;
+    println(n);
+}
+`
+
+func TestHover(t *testing.T) {
+	s := NewServer()
+	doc := &Document{URI: "file:///tmp/hover.mal", Content: hoverSource}
+	s.updateDocument(doc)
+	if errs := errorsOf(doc); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	tests := []struct {
+		name string
+		pos  Position
+		want []string
+	}{
+		{"generic declaration", Position{Line: 12, Character: 4}, []string{"fn pick[T: Named](a: T, b: T) -> T"}},
+		{"generic call", Position{Line: 17, Character: 13}, []string{"fn pick[T: Named](a: T, b: T) -> T", "instantiated as", "fn pick[Dog](a: Dog, b: Dog) -> Dog"}},
+		{"inferred local", Position{Line: 17, Character: 8}, []string{"d: Dog"}},
+		{"expression", Position{Line: 18, Character: 14}, []string{"```malphas\nint\n```"}},
+		{"use of local", Position{Line: 19, Character: 12}, []string{"n: int"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hover := s.getHover(doc, tt.pos)
+			if hover == nil {
+				t.Fatal("expected hover information")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(hover.Contents.Value, want) {
+					t.Errorf("hover %q should contain %q", hover.Contents.Value, want)
+				}
+			}
+		})
+	}
+
+	// The range of an expression hover covers the whole expression
+	hover := s.getHover(doc, Position{Line: 18, Character: 14})
+	want := Range{Start: Position{Line: 18, Character: 12}, End: Position{Line: 18, Character: 17}}
+	if *hover.Range != want {
+		t.Errorf("expression range = %+v, want %+v", *hover.Range, want)
+	}
+}
+
+func TestPositionToOffsetCountsUTF16(t *testing.T) {
+	content := "let s = \"😀\"; x\nnext"
+	// 😀 is one rune but two UTF-16 code units
+	if got := positionToOffset(content, Position{Line: 0, Character: 14}); got != 13 {
+		t.Errorf("offset of x = %d, want 13", got)
+	}
+	if got := positionToOffset(content, Position{Line: 1, Character: 0}); got != 15 {
+		t.Errorf("offset of second line = %d, want 15", got)
+	}
+}
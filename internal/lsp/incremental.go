@@ -0,0 +1,286 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// A change made while typing usually stays inside one function body. Such a
+// change is analyzed by editing the analysis of the document's last
+// version: the nodes outside the body are kept, moved to their new places,
+// so what the check recorded about them stays valid.
+
+// textEdit is where two versions of a document differ: the runes
+// [start, oldEnd) of the old version are [start, newEnd) of the new one.
+// oldLine and oldColumn are the position of oldEnd in the old version,
+// newLine and newColumn that of newEnd in the new one.
+type textEdit struct {
+	start, oldEnd, newEnd int
+	oldLine, oldColumn    int
+	newLine, newColumn    int
+}
+
+// diffContent returns the edit between old and new that keeps the longest
+// common prefix and suffix
+func diffContent(old, new string) textEdit {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	for prefix > 0 && prefix < len(old) && !utf8.RuneStart(old[prefix]) {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !utf8.RuneStart(old[len(old)-suffix]) {
+		suffix--
+	}
+
+	oldEnd, newEnd := len(old)-suffix, len(new)-suffix
+	e := textEdit{start: utf8.RuneCountInString(old[:prefix])}
+	e.oldEnd = e.start + utf8.RuneCountInString(old[prefix:oldEnd])
+	e.newEnd = e.start + utf8.RuneCountInString(new[prefix:newEnd])
+	e.oldLine, e.oldColumn = linePosition(old[:oldEnd])
+	e.newLine, e.newColumn = linePosition(new[:newEnd])
+	return e
+}
+
+// linePosition returns the line and column, counted in runes from 1 as the
+// lexer does, of the end of text
+func linePosition(text string) (line, column int) {
+	lineStart := strings.LastIndexByte(text, '\n') + 1
+	return strings.Count(text, "\n") + 1, utf8.RuneCountInString(text[lineStart:]) + 1
+}
+
+// shift moves span, of the old version, to its place in the new one. It
+// reports false for a span that starts or ends inside the replaced text.
+func (e textEdit) shift(span lexer.Span) (lexer.Span, bool) {
+	moved := span
+	switch {
+	case span.Start < e.start:
+	case span.Start >= e.oldEnd:
+		moved.Start += e.newEnd - e.oldEnd
+		if span.Line == e.oldLine {
+			moved.Column += e.newColumn - e.oldColumn
+		}
+		if span.Line > 0 {
+			moved.Line += e.newLine - e.oldLine
+		}
+	default:
+		return span, false
+	}
+	switch {
+	case span.End == span.Start:
+		moved.End = moved.Start
+	case span.End <= e.start:
+	case span.End >= e.oldEnd:
+		moved.End += e.newEnd - e.oldEnd
+	default:
+		return span, false
+	}
+	return moved, true
+}
+
+// spanSetter is implemented by the nodes whose span can be moved
+type spanSetter interface {
+	SetSpan(lexer.Span)
+}
+
+// reanalyze analyzes content, the new content of the document at uri, by
+// editing base when the change from base's content stays inside one
+// function body. Only that body is parsed again, and only the declaration
+// holding it is checked again. It returns nil, leaving base alone, when the
+// change goes beyond one body or the new body does not parse.
+func (s *Server) reanalyze(uri, content string, base *analysis) *analysis {
+	if base == nil || base.result == nil {
+		return nil
+	}
+	edit := diffContent(base.content, content)
+	decl, body := editedBody(base.file, edit)
+	if body == nil {
+		return nil
+	}
+	oldBody := *body
+	filePath := uriToPath(uri)
+	bodySpan, _ := edit.shift(oldBody.Span())
+	newBody, bodyComments := parseBody(content, bodySpan, filePath)
+	if newBody == nil {
+		return nil
+	}
+
+	// Only decl has nodes the edit may have changed, so work out where its
+	// nodes move to before moving any
+	type spanMove struct {
+		node spanSetter
+		span lexer.Span
+	}
+	var moves []spanMove
+	ok := true
+	ast.Walk(decl, func(n ast.Node) bool {
+		if !ok || n == ast.Node(oldBody) {
+			return false
+		}
+		setter, settable := n.(spanSetter)
+		moved, shifted := edit.shift(n.Span())
+		ok = settable && shifted
+		if ok && moved != n.Span() {
+			moves = append(moves, spanMove{setter, moved})
+		}
+		return ok
+	})
+	if !ok {
+		return nil
+	}
+	for _, move := range moves {
+		move.node.SetSpan(move.span)
+	}
+	// The nodes after it move by the length the edit added
+	moveAll := func(n ast.Node) bool {
+		if setter, ok := n.(spanSetter); ok {
+			moved, _ := edit.shift(n.Span())
+			setter.SetSpan(moved)
+		}
+		return true
+	}
+	if base.file.Package != nil && base.file.Package.Span().Start >= edit.oldEnd {
+		ast.Walk(base.file.Package, moveAll)
+	}
+	for _, mod := range base.file.Mods {
+		if mod.Span().Start >= edit.oldEnd {
+			ast.Walk(mod, moveAll)
+		}
+	}
+	for _, use := range base.file.Uses {
+		if use.Span().Start >= edit.oldEnd {
+			ast.Walk(use, moveAll)
+		}
+	}
+	for _, d := range base.file.Decls {
+		if d.Span().Start >= edit.oldEnd {
+			ast.Walk(d, moveAll)
+		}
+	}
+	fileSpan, _ := edit.shift(base.file.Span())
+
+	// The comments before the body stay, those after it move, and those in
+	// it are the new body's
+	var comments, after []*ast.Comment
+	for _, comment := range base.file.Comments {
+		switch span := comment.Span(); {
+		case span.End <= oldBody.Span().Start:
+			comments = append(comments, comment)
+		case span.Start >= oldBody.Span().End:
+			after = append(after, comment)
+		}
+	}
+	comments = append(comments, bodyComments...)
+
+	for _, comment := range after {
+		moved, _ := edit.shift(comment.Span())
+		comment.SetSpan(moved)
+	}
+	*body = newBody
+	file := ast.NewFile(fileSpan)
+	file.Package, file.Mods, file.Uses, file.Decls = base.file.Package, base.file.Mods, base.file.Uses, base.file.Decls
+	file.Comments = append(comments, after...)
+
+	shift := func(span diag.Span) (diag.Span, bool) {
+		if span.Filename != filePath {
+			return span, true
+		}
+		moved, ok := edit.shift(lexer.Span{Filename: span.Filename, Line: span.Line, Column: span.Column, Start: span.Start, End: span.End})
+		return diag.Span{Filename: moved.Filename, Line: moved.Line, Column: moved.Column, Start: moved.Start, End: moved.End}, ok
+	}
+	a := &analysis{content: content, file: file}
+	a.result = s.program.Recheck(base.result, file, types.BodyEdit{Decl: decl, Old: oldBody, Shift: shift})
+	a.errors = append(a.errors, a.result.Errors...)
+	a.errors = append(a.errors, a.result.Warnings...)
+	return a
+}
+
+// bodyPrefix makes a function of a body parsed on its own
+const bodyPrefix = "fn f() "
+
+// parseBody parses the function body at span in content, returning it and
+// its comments with their spans in content, or nil if it does not parse as
+// exactly one block
+func parseBody(content string, span lexer.Span, filePath string) (*ast.BlockExpr, []*ast.Comment) {
+	runes := []rune(content)
+	if span.Start < 0 || span.End > len(runes) || span.Start >= span.End {
+		return nil, nil
+	}
+	p := parser.New(bodyPrefix+string(runes[span.Start:span.End]), parser.WithFilename(filePath))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 || len(file.Decls) != 1 || file.Package != nil || len(file.Mods) > 0 || len(file.Uses) > 0 {
+		return nil, nil
+	}
+	fn, ok := file.Decls[0].(*ast.FnDecl)
+	prefix := utf8.RuneCountInString(bodyPrefix)
+	if !ok || fn.Body == nil || fn.Body.Span().Start != prefix || fn.Body.Span().End != prefix+span.End-span.Start {
+		return nil, nil
+	}
+
+	// Move the body from after the prefix to span
+	edit := textEdit{
+		oldEnd: prefix, oldLine: 1, oldColumn: prefix + 1,
+		newEnd: span.Start, newLine: span.Line, newColumn: span.Column,
+	}
+	var nodes []ast.Node
+	var spans []lexer.Span
+	settable := true
+	ast.Walk(fn.Body, func(n ast.Node) bool {
+		_, ok := n.(spanSetter)
+		settable = settable && ok
+		moved, _ := edit.shift(n.Span())
+		nodes = append(nodes, n)
+		spans = append(spans, moved)
+		return settable
+	})
+	if !settable {
+		return nil, nil
+	}
+	for i, n := range nodes {
+		n.(spanSetter).SetSpan(spans[i])
+	}
+	for _, comment := range file.Comments {
+		moved, _ := edit.shift(comment.Span())
+		comment.SetSpan(moved)
+	}
+	return fn.Body, file.Comments
+}
+
+// editedBody returns the function body of file holding all of edit between
+// its braces, and the top-level declaration it belongs to, or nil
+func editedBody(file *ast.File, edit textEdit) (ast.Decl, **ast.BlockExpr) {
+	for _, decl := range file.Decls {
+		if span := decl.Span(); edit.start <= span.Start || edit.oldEnd >= span.End {
+			continue
+		}
+		var fns []*ast.FnDecl
+		switch d := decl.(type) {
+		case *ast.FnDecl:
+			fns = []*ast.FnDecl{d}
+		case *ast.ImplDecl:
+			fns = d.Methods
+		case *ast.TraitDecl:
+			fns = d.Methods
+		}
+		for _, fn := range fns {
+			if fn.Body == nil {
+				continue
+			}
+			if span := fn.Body.Span(); span.Start < edit.start && edit.oldEnd < span.End {
+				return decl, &fn.Body
+			}
+		}
+	}
+	return nil, nil
+}
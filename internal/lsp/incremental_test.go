@@ -0,0 +1,186 @@
+package lsp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func TestDiffContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     textEdit
+	}{
+		{"insert", "ab\ncd", "ab\ncxd", textEdit{start: 4, oldEnd: 4, newEnd: 5, oldLine: 2, oldColumn: 2, newLine: 2, newColumn: 3}},
+		{"delete line", "a\nb\nc", "a\nc", textEdit{start: 2, oldEnd: 4, newEnd: 2, oldLine: 3, oldColumn: 1, newLine: 2, newColumn: 1}},
+		{"astral character", "\"😀\" + x", "\"😀\" + yz", textEdit{start: 6, oldEnd: 7, newEnd: 8, oldLine: 1, oldColumn: 8, newLine: 1, newColumn: 9}},
+		{"same rune prefix", "é", "è", textEdit{start: 0, oldEnd: 1, newEnd: 1, oldLine: 1, oldColumn: 2, newLine: 1, newColumn: 2}},
+	}
+	for _, tt := range tests {
+		if got := diffContent(tt.old, tt.new); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShift(t *testing.T) {
+	// "ab\ncd" to "ab\ncxyd"
+	edit := textEdit{start: 4, oldEnd: 4, newEnd: 6, oldLine: 2, oldColumn: 2, newLine: 2, newColumn: 4}
+	span := func(line, column, start, end int) lexer.Span {
+		return lexer.Span{Line: line, Column: column, Start: start, End: end}
+	}
+	tests := []struct {
+		span lexer.Span
+		want lexer.Span
+	}{
+		{span(1, 1, 0, 2), span(1, 1, 0, 2)}, // Before
+		{span(2, 1, 3, 4), span(2, 1, 3, 4)}, // Ends where the text is inserted
+		{span(2, 2, 4, 5), span(2, 4, 6, 7)}, // Starts there
+		{span(1, 1, 0, 5), span(1, 1, 0, 7)}, // Around
+	}
+	for _, tt := range tests {
+		got, ok := edit.shift(tt.span)
+		if !ok || got != tt.want {
+			t.Errorf("shift(%+v) = %+v, %v; want %+v", tt.span, got, ok, tt.want)
+		}
+	}
+	// "ab\ncd" to "ab\nd"
+	edit = textEdit{start: 3, oldEnd: 4, newEnd: 3, oldLine: 2, oldColumn: 2, newLine: 2, newColumn: 1}
+	if got, ok := edit.shift(span(2, 1, 3, 5)); ok {
+		t.Errorf("shift of a span starting in deleted text = %+v, want false", got)
+	}
+}
+
+const recheckSource = `package main;
+
+struct Point { x: int, y: int }
+
+// Sums the point's coordinates
+fn sum(p: Point) -> int {
+    let unused = 1;
+    return p.x + p.y;
+}
+
+impl Point {
+    fn scaled(&self, k: int) -> Point {
+        return Point { x: self.x * k, y: self.y * k };
+    }
+
+    fn norm(&self) -> int {
+        let wrong: string = self.x;
+        return self.x * self.x + self.y * self.y;
+    }
+}
+
+fn main() {
+    let p = Point { x: 1, y: 2 };
+    println(sum(p.scaled(2)));
+}
+`
+
+// summary describes what a check found, by position, so that checks of
+// the same content made from different nodes can be compared
+func summary(r *types.CheckResult) []string {
+	var lines []string
+	ast.Walk(r.File, func(n ast.Node) bool {
+		if typ, ok := r.Types[n]; ok {
+			lines = append(lines, fmt.Sprintf("%v %T: %s", n.Span(), n, typ))
+		}
+		if ident, ok := n.(*ast.Ident); ok {
+			if sym := r.Uses[ident]; sym != nil {
+				var def lexer.Span
+				if defIdent := sym.DefIdent(); defIdent != nil {
+					def = defIdent.Span()
+				}
+				lines = append(lines, fmt.Sprintf("%v %s: use of %s defined at %v", n.Span(), ident.Name, sym.Name, def))
+			}
+			if sym := r.Defs[ident]; sym != nil {
+				lines = append(lines, fmt.Sprintf("%v %s: defines %s", n.Span(), ident.Name, sym.Name))
+			}
+		}
+		return true
+	})
+	for _, d := range append(r.Errors, r.Warnings...) {
+		lines = append(lines, fmt.Sprintf("%+v", d))
+	}
+	for _, c := range r.File.Comments {
+		lines = append(lines, fmt.Sprintf("%v %s", c.Span(), c.Text))
+	}
+	return lines
+}
+
+// fullCheck parses and checks content from scratch
+func fullCheck(t *testing.T, uri, content string) *types.CheckResult {
+	t.Helper()
+	p := parser.New(content, parser.WithFilename(uriToPath(uri)))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse: %v", p.Errors()[0])
+	}
+	return types.NewProgram().CheckVariant(file, absPath(uriToPath(uri)))
+}
+
+func TestEditInBodyReusesOtherDeclarations(t *testing.T) {
+	s := NewServer()
+	s.out = &strings.Builder{}
+	s.debounce = time.Hour
+	uri := "file:///tmp/recheck.mal"
+	notify(t, s, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: recheckSource},
+	})
+
+	edits := []struct {
+		name        string
+		from, to    string
+		incremental bool
+	}{
+		{"edit a method", "self.x * k", "self.x * k * 1", true},
+		{"add lines to a function", "    let unused = 1;\n", "    let unused = 1;\n    let q = p.y;\n    // Twice as far\n    let r = q * 2;\n", true},
+		{"break a body", "return p.x + p.y;", "return p.x + true;", true},
+		{"edit the last function", "sum(p.scaled(2))", "sum(p)", true},
+		{"change a signature", "fn sum(p: Point) -> int", "fn sum(p: Point, k: int) -> int", false},
+		{"edit after the new signature", "let q = p.y;", "let q = p.y + k;", true},
+	}
+	content := recheckSource
+	version := 1
+	for _, edit := range edits {
+		s.mu.RLock()
+		before := s.Documents[uri].Result
+		decls := append([]ast.Decl(nil), before.File.Decls...)
+		s.mu.RUnlock()
+
+		if !strings.Contains(content, edit.from) {
+			t.Fatalf("%s: %q not in the document", edit.name, edit.from)
+		}
+		content = strings.Replace(content, edit.from, edit.to, 1)
+		version++
+		notify(t, s, "textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: version},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: content}},
+		})
+		doc, ok := s.document(uri)
+		if !ok {
+			t.Fatal("document not open")
+		}
+
+		reused := len(doc.Result.File.Decls) == len(decls)
+		for i, decl := range decls {
+			reused = reused && doc.Result.File.Decls[i] == decl
+		}
+		if reused != edit.incremental {
+			t.Errorf("%s: declarations reused = %v, want %v", edit.name, reused, edit.incremental)
+		}
+		got, want := summary(doc.Result), summary(fullCheck(t, uri, content))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: the check differs from a full check:\n%s\nwant:\n%s", edit.name, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
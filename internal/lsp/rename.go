@@ -0,0 +1,237 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// RenameParams represents rename request parameters.
+type RenameParams struct {
+	TextDocumentPositionParams
+	NewName string `json:"newName"`
+}
+
+// WorkspaceEdit holds the text edits of a rename, by document URI.
+type WorkspaceEdit struct {
+	Changes map[string][]TextEdit `json:"changes"`
+}
+
+// TextEdit replaces the text in Range with NewText.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+func (s *Server) handleRename(msg *jsonrpcMessage) *jsonrpcMessage {
+	var params RenameParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &jsonrpcError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid params: %v", err),
+			},
+		}
+	}
+
+	doc, ok := s.document(params.TextDocument.URI)
+
+	if !ok || doc.Result == nil || doc.File == nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Result:  nil,
+		}
+	}
+
+	edit, err := s.rename(doc, params.Position, params.NewName)
+	if err != nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &jsonrpcError{
+				Code:    -32803, // RequestFailed
+				Message: err.Error(),
+			},
+		}
+	}
+
+	return &jsonrpcMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  edit,
+	}
+}
+
+// rename renames the symbol under pos and every reference to it. It fails
+// for builtins and stdlib symbols, for names that are not identifiers, and
+// when the new name would change what another identifier refers to.
+func (s *Server) rename(doc *Document, pos Position, newName string) (*WorkspaceEdit, error) {
+	sym := symbolAt(doc, pos)
+	if sym == nil {
+		return nil, fmt.Errorf("no symbol to rename at this position")
+	}
+	def := sym.DefIdent()
+	if def == nil {
+		return nil, fmt.Errorf("`%s` is built in and cannot be renamed", sym.Name)
+	}
+	if isStdlibFile(doc.Result, def.Span().Filename) {
+		return nil, fmt.Errorf("`%s` is defined in the standard library and cannot be renamed", sym.Name)
+	}
+	if !isIdentifier(newName) {
+		return nil, fmt.Errorf("`%s` is not a valid identifier", newName)
+	}
+	if newName == sym.Name {
+		return &WorkspaceEdit{Changes: map[string][]TextEdit{}}, nil
+	}
+	if err := checkRenameConflicts(doc, sym, newName); err != nil {
+		return nil, err
+	}
+
+	conv := newDiagnosticConverter(doc)
+	edit := &WorkspaceEdit{Changes: make(map[string][]TextEdit)}
+	for _, ident := range append([]*ast.Ident{def}, doc.Result.References(sym)...) {
+		location := conv.location(diagSpan(ident.Span()))
+		edit.Changes[location.URI] = append(edit.Changes[location.URI], TextEdit{Range: location.Range, NewText: newName})
+	}
+	return edit, nil
+}
+
+// isIdentifier reports whether name lexes as a single identifier, which
+// rules out keywords
+func isIdentifier(name string) bool {
+	l := lexer.New(name)
+	tok := l.NextToken()
+	return tok.Type == lexer.IDENT && tok.Literal == name && l.NextToken().Type == lexer.EOF
+}
+
+// isStdlibFile reports whether filename was loaded as a stdlib module
+func isStdlibFile(result *types.CheckResult, filename string) bool {
+	for name, mod := range result.Modules {
+		if strings.HasPrefix(name, "std/") && mod.FilePath == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeRegion is the part of a file in which a symbol can be named: from its
+// definition to the end of the enclosing block, or the whole file for
+// top-level symbols
+type scopeRegion struct {
+	filename   string
+	start, end int
+	owner      ast.Node // The block or declaration the symbol is scoped to
+}
+
+func (r scopeRegion) contains(span lexer.Span) bool {
+	return span.Filename == r.filename && span.Start >= r.start && span.End <= r.end
+}
+
+// encloses reports whether r contains the region other is visible in
+func (r scopeRegion) encloses(other scopeRegion) bool {
+	return other.filename == r.filename && other.start >= r.start && other.end <= r.end
+}
+
+// checkRenameConflicts rejects renaming sym to newName when another symbol
+// of that name is declared in the same scope, when a use of an outer symbol
+// called newName would be captured by sym, or when a reference to sym would
+// be captured by an inner symbol called newName.
+func checkRenameConflicts(doc *Document, sym *types.Symbol, newName string) error {
+	result := doc.Result
+	if builtin := result.Scope.Lookup(newName); builtin != nil && builtin.DefIdent() == nil {
+		return fmt.Errorf("`%s` would shadow the builtin `%s`", sym.Name, newName)
+	}
+
+	region, ok := regionOf(doc, sym)
+	if !ok {
+		return nil
+	}
+	refs := result.References(sym)
+
+	for _, other := range symbolsNamed(result, newName) {
+		otherRegion, ok := regionOf(doc, other)
+		if !ok {
+			continue
+		}
+		if otherRegion.owner == region.owner {
+			return fmt.Errorf("`%s` is already declared in this scope", newName)
+		}
+		if otherRegion.encloses(region) {
+			for _, use := range result.References(other) {
+				if region.contains(use.Span()) {
+					return fmt.Errorf("renaming `%s` to `%s` would make the use of `%s` on line %d refer to it", sym.Name, newName, newName, use.Span().Line)
+				}
+			}
+		}
+		if region.encloses(otherRegion) {
+			for _, ref := range refs {
+				if otherRegion.contains(ref.Span()) {
+					return fmt.Errorf("the reference to `%s` on line %d would refer to the `%s` declared on line %d", sym.Name, ref.Span().Line, newName, other.DefIdent().Span().Line)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// symbolsNamed returns the declared symbols called name
+func symbolsNamed(result *types.CheckResult, name string) []*types.Symbol {
+	var found []*types.Symbol
+	seen := make(map[*ast.Ident]bool)
+	for ident, sym := range result.Defs {
+		if sym.Name == name && !seen[ident] {
+			seen[ident] = true
+			found = append(found, sym)
+		}
+	}
+	return found
+}
+
+// regionOf finds the region sym is visible in, from the innermost scoping
+// node around its definition
+func regionOf(doc *Document, sym *types.Symbol) (scopeRegion, bool) {
+	def := sym.DefIdent()
+	if def == nil {
+		return scopeRegion{}, false
+	}
+	span := def.Span()
+	file := fileNamed(doc, span.Filename)
+	if file == nil {
+		return scopeRegion{}, false
+	}
+
+	path := file.Index().PathAt(span.Start)
+	for i := len(path) - 1; i >= 0; i-- {
+		switch path[i].(type) {
+		case *ast.BlockExpr, *ast.FnDecl, *ast.FunctionLiteral, *ast.MatchArm, *ast.ForStmt, *ast.SelectCase:
+			// A declaration's own name belongs to the scope around it
+			if fn, ok := path[i].(*ast.FnDecl); ok && fn.Name == def {
+				continue
+			}
+			return scopeRegion{filename: span.Filename, start: span.Start, end: path[i].Span().End, owner: path[i]}, true
+		}
+	}
+	return scopeRegion{filename: span.Filename, start: 0, end: math.MaxInt, owner: file}, true
+}
+
+// fileNamed returns the parsed file a span's filename refers to: the
+// document itself or one of the modules it loaded
+func fileNamed(doc *Document, filename string) *ast.File {
+	if filename == "" || newDiagnosticConverter(doc).isDocument(filename) {
+		return doc.File
+	}
+	for _, mod := range doc.Result.Modules {
+		if mod.FilePath == filename {
+			return mod.File
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const renameSource = `fn scale(n: int) -> int {
+    let factor = 2;
+    return n * factor;
+}
+
+fn main() {
+    let total = scale(3);
+    let other = 4;
+    println(scale(total) + other);
+}
+`
+
+func openRenameDoc(t *testing.T) (*Server, *Document) {
+	t.Helper()
+	s := NewServer()
+	doc := &Document{URI: "file:///tmp/rename.mal", Content: renameSource}
+	s.updateDocument(doc)
+	if errs := errorsOf(doc); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	return s, doc
+}
+
+func TestRenameLocal(t *testing.T) {
+	s, doc := openRenameDoc(t)
+	edit, err := s.rename(doc, Position{Line: 8, Character: 19}, "sum")
+	if err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	edits := edit.Changes[doc.URI]
+	want := []Range{
+		{Start: Position{Line: 6, Character: 8}, End: Position{Line: 6, Character: 13}},
+		{Start: Position{Line: 8, Character: 18}, End: Position{Line: 8, Character: 23}},
+	}
+	if len(edits) != len(want) {
+		t.Fatalf("edits = %+v, want %d", edits, len(want))
+	}
+	for i, e := range edits {
+		if e.Range != want[i] || e.NewText != "sum" {
+			t.Errorf("edit %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestRenameRejectsConflicts(t *testing.T) {
+	s, doc := openRenameDoc(t)
+	tests := []struct {
+		name    string
+		pos     Position
+		newName string
+		want    string
+	}{
+		{"builtin symbol", Position{Line: 8, Character: 5}, "print", "built in"},
+		{"keyword", Position{Line: 6, Character: 9}, "return", "not a valid identifier"},
+		{"same scope", Position{Line: 6, Character: 9}, "other", "already declared"},
+		{"captures outer use", Position{Line: 6, Character: 9}, "scale", "would make the use of `scale`"},
+		{"captured by inner", Position{Line: 0, Character: 9}, "factor", "would refer to the `factor`"},
+		{"shadows builtin", Position{Line: 6, Character: 9}, "println", "builtin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.rename(doc, tt.pos, tt.newName)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("rename to %s: error = %v, want it to mention %q", tt.newName, err, tt.want)
+			}
+		})
+	}
+
+	// A local in another function does not conflict
+	if _, err := s.rename(doc, Position{Line: 1, Character: 9}, "total"); err != nil {
+		t.Errorf("renaming factor to total should succeed: %v", err)
+	}
+}
+
+func TestRenameAcrossModules(t *testing.T) {
+	dir := t.TempDir()
+	utilsPath := filepath.Join(dir, "utils.mal")
+	if err := os.WriteFile(utilsPath, []byte("pub fn helper(n: int) -> int {\n    return n + 1;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer()
+	doc := &Document{
+		URI:     pathToURI(filepath.Join(dir, "main.mal")),
+		Content: "mod utils;\n\nfn main() {\n    println(utils::helper(1));\n}\n",
+	}
+	s.updateDocument(doc)
+
+	edit, err := s.rename(doc, Position{Line: 3, Character: 20}, "bump")
+	if err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	if len(edit.Changes[doc.URI]) != 1 || len(edit.Changes[pathToURI(utilsPath)]) != 1 {
+		t.Errorf("expected one edit in each file, got %+v", edit.Changes)
+	}
+}
@@ -0,0 +1,375 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf16"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// Semantic token types, in the order of semanticTokenTypes
+const (
+	tokenNamespace = iota
+	tokenType
+	tokenEnum
+	tokenInterface
+	tokenTypeParameter
+	tokenParameter
+	tokenVariable
+	tokenProperty
+	tokenEnumMember
+	tokenFunction
+	tokenMethod
+)
+
+// Semantic token modifiers, as bits in the order of semanticTokenModifiers
+const (
+	modDeclaration = 1 << iota
+	modReadonly
+	modMutable
+	modDefaultLibrary
+)
+
+// semanticTokenTypes and semanticTokenModifiers form the legend the client
+// decodes token data with. All but "mutable" are predefined by the protocol.
+var (
+	semanticTokenTypes = []string{
+		"namespace", "type", "enum", "interface", "typeParameter", "parameter",
+		"variable", "property", "enumMember", "function", "method",
+	}
+	semanticTokenModifiers = []string{"declaration", "readonly", "mutable", "defaultLibrary"}
+)
+
+// SemanticTokensOptions advertises semantic token support.
+type SemanticTokensOptions struct {
+	Legend SemanticTokensLegend `json:"legend"`
+	Full   bool                 `json:"full"`
+}
+
+// SemanticTokensLegend names the token types and modifiers.
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokens holds tokens encoded as the protocol specifies: five
+// integers per token, with positions relative to the previous token.
+type SemanticTokens struct {
+	Data []uint32 `json:"data"`
+}
+
+func (s *Server) handleSemanticTokens(msg *jsonrpcMessage) *jsonrpcMessage {
+	var params struct {
+		TextDocument TextDocumentIdentifier `json:"textDocument"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &jsonrpcError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid params: %v", err),
+			},
+		}
+	}
+
+	doc, ok := s.document(params.TextDocument.URI)
+	if !ok || doc.File == nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Result:  nil,
+		}
+	}
+
+	return &jsonrpcMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  semanticTokens(doc),
+	}
+}
+
+// semanticToken classifies the identifier at span
+type semanticToken struct {
+	span      lexer.Span
+	kind      int
+	modifiers int
+}
+
+// semanticTokens classifies the identifiers of doc by what the checker
+// resolved them to. Identifiers the checker does not link to a symbol, such
+// as fields, methods and enum variants after `::`, are classified from the
+// type of the expression they belong to.
+func semanticTokens(doc *Document) *SemanticTokens {
+	t := &tokenizer{result: doc.Result, seen: make(map[*ast.Ident]bool)}
+	ast.Walk(doc.File, t.visit)
+	return &SemanticTokens{Data: t.encode([]rune(doc.Content))}
+}
+
+type tokenizer struct {
+	result *types.CheckResult // May be nil, or from an older version of the document
+	tokens []semanticToken
+	seen   map[*ast.Ident]bool
+}
+
+func (t *tokenizer) add(ident *ast.Ident, kind, modifiers int) {
+	if ident == nil || t.seen[ident] {
+		return
+	}
+	t.seen[ident] = true
+	t.tokens = append(t.tokens, semanticToken{span: ident.Span(), kind: kind, modifiers: modifiers})
+}
+
+func (t *tokenizer) visit(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.FnDecl:
+		t.add(n.Name, tokenFunction, modDeclaration) // Methods were added by their impl or trait
+		t.typeParams(n.TypeParams)
+	case *ast.ImplDecl:
+		t.typeParams(n.TypeParams)
+		for _, method := range n.Methods {
+			t.add(method.Name, tokenMethod, modDeclaration)
+		}
+	case *ast.TraitDecl:
+		t.add(n.Name, tokenInterface, modDeclaration)
+		t.typeParams(n.TypeParams)
+		for _, method := range n.Methods {
+			t.add(method.Name, tokenMethod, modDeclaration)
+		}
+	case *ast.StructDecl:
+		t.add(n.Name, tokenType, modDeclaration)
+		t.typeParams(n.TypeParams)
+		for _, field := range n.Fields {
+			t.add(field.Name, tokenProperty, modDeclaration)
+		}
+	case *ast.EnumDecl:
+		t.add(n.Name, tokenEnum, modDeclaration)
+		t.typeParams(n.TypeParams)
+		for _, variant := range n.Variants {
+			t.add(variant.Name, tokenEnumMember, modDeclaration)
+		}
+	case *ast.NamedType:
+		if t.result != nil {
+			if _, ok := t.result.Types[n].(*types.TypeParam); ok {
+				t.add(n.Name, tokenTypeParameter, 0)
+			}
+		}
+	case *ast.StructLiteral:
+		for _, field := range n.Fields {
+			t.add(field.Name, tokenProperty, 0)
+		}
+	case *ast.PatternField:
+		t.add(n.Name, tokenProperty, 0)
+	case *ast.EnumPattern:
+		t.add(n.Variant, tokenEnumMember, 0)
+	case *ast.CallExpr:
+		if field, ok := n.Callee.(*ast.FieldExpr); ok && !t.hasField(field) {
+			t.add(field.Field, tokenMethod, 0)
+		}
+	case *ast.FieldExpr:
+		t.add(n.Field, tokenProperty, 0)
+	case *ast.InfixExpr:
+		if n.Op == lexer.DOUBLE_COLON {
+			t.path(n)
+		}
+	case *ast.Ident:
+		t.ident(n)
+	}
+	return true
+}
+
+func (t *tokenizer) typeParams(params []ast.GenericParam) {
+	for _, param := range params {
+		if tp, ok := param.(*ast.TypeParam); ok {
+			t.add(tp.Name, tokenTypeParameter, modDeclaration)
+		}
+	}
+}
+
+// hasField reports whether field names a field of its target's struct
+// type, rather than a method
+func (t *tokenizer) hasField(field *ast.FieldExpr) bool {
+	if t.result == nil {
+		return false
+	}
+	typ := t.result.Types[field.Target]
+	for typ != nil {
+		switch u := typ.(type) {
+		case *types.Reference:
+			typ = u.Elem
+			continue
+		case *types.Pointer:
+			typ = u.Elem
+			continue
+		case *types.Named:
+			typ = u.Ref
+			continue
+		case *types.GenericInstance:
+			typ = u.Base
+			continue
+		case *types.Struct:
+			for _, f := range u.Fields {
+				if f.Name == field.Field.Name {
+					return true
+				}
+			}
+		}
+		break
+	}
+	return false
+}
+
+// path classifies both sides of `left::right`: a module and one of its
+// symbols, or a type and one of its variants or static methods
+func (t *tokenizer) path(n *ast.InfixExpr) {
+	left, ok := n.Left.(*ast.Ident)
+	if index, isIndex := n.Left.(*ast.IndexExpr); isIndex {
+		left, ok = index.Target.(*ast.Ident) // Vec[int]::new
+	}
+	right, isIdent := n.Right.(*ast.Ident)
+	if !ok || t.result == nil {
+		return
+	}
+	if _, isModule := t.result.Modules[left.Name]; isModule {
+		t.add(left, tokenNamespace, 0)
+		return // The checker resolves the right-hand side
+	}
+	if !isIdent {
+		return
+	}
+	sym := t.result.SymbolOf(left)
+	if sym == nil {
+		sym = t.result.Scope.Lookup(left.Name)
+	}
+	if sym == nil {
+		return
+	}
+	if kind, modifiers, ok := symbolTokenType(sym); ok {
+		t.add(left, kind, modifiers)
+	}
+	if enum, ok := sym.Type.(*types.Enum); ok {
+		for _, variant := range enum.Variants {
+			if variant.Name == right.Name {
+				t.add(right, tokenEnumMember, 0)
+				return
+			}
+		}
+	}
+	if _, ok := t.result.MethodsOf(sym.Type)[right.Name]; ok {
+		t.add(right, tokenMethod, 0)
+	}
+}
+
+// ident classifies an identifier by the symbol it resolves to or defines
+func (t *tokenizer) ident(ident *ast.Ident) {
+	if t.seen[ident] || t.result == nil {
+		return
+	}
+	sym := t.result.SymbolOf(ident)
+	if sym == nil {
+		return
+	}
+	kind, modifiers, ok := symbolTokenType(sym)
+	if !ok {
+		return
+	}
+	def := sym.DefIdent()
+	if def == ident {
+		modifiers |= modDeclaration
+	}
+	if def == nil || isStdlibFile(t.result, def.Span().Filename) {
+		modifiers |= modDefaultLibrary
+	}
+	t.add(ident, kind, modifiers)
+}
+
+// symbolTokenType returns the token type of sym, from the declaration that
+// introduced it or, for builtins, from its type
+func symbolTokenType(sym *types.Symbol) (kind, modifiers int, ok bool) {
+	switch d := sym.DefNode.(type) {
+	case *ast.StructDecl, *ast.TypeAliasDecl:
+		return tokenType, 0, true
+	case *ast.EnumDecl:
+		return tokenEnum, 0, true
+	case *ast.TraitDecl:
+		return tokenInterface, 0, true
+	case *ast.EnumVariant:
+		return tokenEnumMember, 0, true
+	case *ast.FnDecl:
+		return tokenFunction, 0, true
+	case *ast.Param:
+		return tokenParameter, 0, true
+	case *ast.ConstDecl:
+		return tokenVariable, modReadonly, true
+	case *ast.StaticDecl:
+		if d.Mutable {
+			return tokenVariable, modMutable, true
+		}
+		return tokenVariable, modReadonly, true
+	case *ast.LetStmt:
+		if d.Mutable {
+			return tokenVariable, modMutable, true
+		}
+		return tokenVariable, 0, true
+	case *ast.VarPattern:
+		if d.Mutable {
+			return tokenVariable, modMutable, true
+		}
+		return tokenVariable, 0, true
+	case *ast.Ident:
+		return tokenVariable, 0, true
+	}
+
+	switch sym.Type.(type) {
+	case *types.Function:
+		return tokenFunction, 0, true
+	case *types.TypeParam:
+		return tokenTypeParameter, 0, true
+	case *types.Primitive, *types.Struct, *types.Named:
+		return tokenType, 0, true
+	case *types.Enum:
+		return tokenEnum, 0, true
+	case *types.Trait:
+		return tokenInterface, 0, true
+	}
+	return 0, 0, false
+}
+
+// encode sorts the tokens and encodes them relative to each other, with
+// positions and lengths in UTF-16 code units
+func (t *tokenizer) encode(src []rune) []uint32 {
+	sort.SliceStable(t.tokens, func(i, j int) bool { return t.tokens[i].span.Start < t.tokens[j].span.Start })
+
+	data := make([]uint32, 0, 5*len(t.tokens))
+	line, char, offset := 0, 0, 0
+	prevLine, prevChar := 0, 0
+	for _, tok := range t.tokens {
+		if tok.span.Start < offset || tok.span.End > len(src) || tok.span.End <= tok.span.Start {
+			continue // Overlaps the previous token, or has no position
+		}
+		for ; offset < tok.span.Start; offset++ {
+			if src[offset] == '\n' {
+				line++
+				char = 0
+			} else {
+				char += utf16.RuneLen(src[offset])
+			}
+		}
+		length := 0
+		for _, r := range src[tok.span.Start:tok.span.End] {
+			length += utf16.RuneLen(r)
+		}
+
+		deltaChar := char
+		if line == prevLine {
+			deltaChar = char - prevChar
+		}
+		data = append(data, uint32(line-prevLine), uint32(deltaChar), uint32(length), uint32(tok.kind), uint32(tok.modifiers))
+		prevLine, prevChar = line, char
+	}
+	return data
+}
@@ -0,0 +1,157 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+)
+
+const semanticSource = `const LIMIT: int = 10;
+
+enum Shape {
+    Circle(float),
+    Square(float),
+}
+
+trait Named {
+    fn name(&self) -> string;
+}
+
+struct Dog {
+    age: int,
+}
+
+impl Named for Dog {
+    fn name(&self) -> string {
+        return "dog";
+    }
+}
+
+fn pick[T: Named](a: T) -> T {
+    return a;
+}
+
+fn main() {
+    let mut count = 0;
+    let d = Dog { age: 3 };
+    count = count + d.age + LIMIT;
+    let s = Shape::Circle(1.0);
+    println(d.name());
+    let p = pick(d);
+}
+`
+
+// decodedToken is a semantic token with its text and legend names
+type decodedToken struct {
+	text      string
+	kind      string
+	modifiers []string
+}
+
+// decodeTokens turns encoded token data back into absolute tokens
+func decodeTokens(t *testing.T, src string, data []uint32) []decodedToken {
+	t.Helper()
+	if len(data)%5 != 0 {
+		t.Fatalf("token data has %d integers, not a multiple of 5", len(data))
+	}
+	lines := strings.Split(src, "\n")
+	var tokens []decodedToken
+	line, char := 0, 0
+	for i := 0; i < len(data); i += 5 {
+		if data[i] > 0 {
+			line += int(data[i])
+			char = 0
+		}
+		char += int(data[i+1])
+		tok := decodedToken{
+			text: lines[line][char : char+int(data[i+2])], // ASCII source
+			kind: semanticTokenTypes[data[i+3]],
+		}
+		for bit, name := range semanticTokenModifiers {
+			if data[i+4]&(1<<bit) != 0 {
+				tok.modifiers = append(tok.modifiers, name)
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+func TestSemanticTokens(t *testing.T) {
+	s := NewServer()
+	doc := &Document{URI: "file:///tmp/semantic.mal", Content: semanticSource}
+	s.updateDocument(doc)
+	if errs := errorsOf(doc); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	got := make(map[string]string) // First occurrence of each text
+	var all []string
+	for _, tok := range decodeTokens(t, semanticSource, semanticTokens(doc).Data) {
+		desc := tok.kind
+		if len(tok.modifiers) > 0 {
+			desc += "." + strings.Join(tok.modifiers, ".")
+		}
+		all = append(all, tok.text+":"+desc)
+		if _, ok := got[tok.text]; !ok {
+			got[tok.text] = desc
+		}
+	}
+
+	want := map[string]string{
+		"LIMIT":   "variable.declaration.readonly",
+		"Shape":   "enum.declaration",
+		"Circle":  "enumMember.declaration",
+		"Named":   "interface.declaration",
+		"name":    "method.declaration",
+		"Dog":     "type.declaration",
+		"age":     "property.declaration",
+		"pick":    "function.declaration",
+		"T":       "typeParameter.declaration",
+		"a":       "parameter.declaration",
+		"main":    "function.declaration",
+		"count":   "variable.declaration.mutable",
+		"d":       "variable.declaration",
+		"s":       "variable.declaration",
+		"println": "function.defaultLibrary",
+	}
+	for text, desc := range want {
+		if got[text] != desc {
+			t.Errorf("%s: got %q, want %q", text, got[text], desc)
+		}
+	}
+
+	// Later occurrences are uses
+	for _, use := range []string{
+		"count:variable.mutable", "LIMIT:variable.readonly", "Shape:enum",
+		"Circle:enumMember", "age:property", "name:method", "pick:function", "a:parameter",
+	} {
+		if !contains(all, use) {
+			t.Errorf("missing %s in %v", use, all)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSemanticTokensWithoutChecker(t *testing.T) {
+	src := "struct Point {\n    x: int,\n}\n\nfn main( {\n"
+	s := NewServer()
+	doc := &Document{URI: "file:///tmp/broken.mal", Content: src}
+	s.updateDocument(doc)
+	if doc.Result != nil {
+		t.Fatal("expected the document not to type check")
+	}
+
+	// Declarations are still classified from the syntax tree
+	tokens := decodeTokens(t, src, semanticTokens(doc).Data)
+	if len(tokens) < 2 || tokens[0].text != "Point" || tokens[0].kind != "type" || tokens[1].text != "x" || tokens[1].kind != "property" {
+		t.Errorf("got %+v, want Point and its field x", tokens)
+	}
+}
@@ -10,10 +10,10 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
 	"github.com/malphas-lang/malphas-lang/internal/diag"
-	"github.com/malphas-lang/malphas-lang/internal/parser"
 	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
@@ -23,11 +23,22 @@ type Server struct {
 	Documents map[string]*Document
 	mu        sync.RWMutex
 
-	// Checker for type checking
-	checker *types.Checker
+	// program checks the documents and indexes their symbols
+	program *types.Program
 
 	// Root path for workspace
 	rootPath string
+
+	// debounce is how long a document must go without changes before it is
+	// checked again
+	debounce time.Duration
+	// checkMu serializes the checks of each document by URI, so that the
+	// check the program records last is of the latest version
+	checkMu map[string]*sync.Mutex
+	// out receives responses and notifications; outMu keeps messages sent
+	// from debounced checks from interleaving with responses
+	out   io.Writer
+	outMu sync.Mutex
 }
 
 // Document represents an open document.
@@ -36,22 +47,28 @@ type Document struct {
 	Content string
 	Version int
 	File    *ast.File
-	Checker *types.Checker
+	Result  *types.CheckResult
 	Errors  []diag.Diagnostic
+
+	dirty   bool        // Content changed since File was parsed
+	pending *time.Timer // Scheduled check of the changes
+	checked *analysis   // Last analysis that type checked, which the next check starts from
 }
 
 // NewServer creates a new LSP server.
 func NewServer() *Server {
 	return &Server{
 		Documents: make(map[string]*Document),
-		checker:   types.NewChecker(),
+		program:   types.NewProgram(),
+		checkMu:   make(map[string]*sync.Mutex),
+		debounce:  defaultDebounce,
+		out:       os.Stdout,
 	}
 }
 
 // Run starts the LSP server, reading from stdin and writing to stdout.
 func (s *Server) Run(ctx context.Context) error {
 	reader := bufio.NewReader(os.Stdin)
-	writer := os.Stdout
 
 	for {
 		// Read Content-Length header
@@ -94,7 +111,7 @@ func (s *Server) Run(ctx context.Context) error {
 
 		// Send response if needed
 		if response != nil {
-			if err := s.sendResponse(writer, response); err != nil {
+			if err := s.sendResponse(response); err != nil {
 				log.Printf("Failed to send response: %v", err)
 			}
 		}
@@ -139,6 +156,22 @@ func (s *Server) handleMessage(ctx context.Context, msg *jsonrpcMessage) *jsonrp
 		return s.handleHover(msg)
 	case "textDocument/definition":
 		return s.handleDefinition(msg)
+	case "textDocument/references":
+		return s.handleReferences(msg)
+	case "textDocument/rename":
+		return s.handleRename(msg)
+	case "textDocument/documentSymbol":
+		return s.handleDocumentSymbol(msg)
+	case "workspace/symbol":
+		return s.handleWorkspaceSymbol(msg)
+	case "textDocument/semanticTokens/full":
+		return s.handleSemanticTokens(msg)
+	case "textDocument/formatting":
+		return s.handleFormatting(msg)
+	case "textDocument/rangeFormatting":
+		return s.handleRangeFormatting(msg)
+	case "textDocument/codeAction":
+		return s.handleCodeAction(msg)
 	case "textDocument/publishDiagnostics":
 		// This is a notification from client, not a request
 		return nil
@@ -160,12 +193,16 @@ func (s *Server) handleMessage(ctx context.Context, msg *jsonrpcMessage) *jsonrp
 }
 
 // sendResponse sends a JSON-RPC response.
-func (s *Server) sendResponse(writer io.Writer, msg *jsonrpcMessage) error {
+func (s *Server) sendResponse(msg *jsonrpcMessage) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal response: %w", err)
 	}
 
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	writer := s.out
+
 	header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(data))
 	if _, err := writer.Write([]byte(header)); err != nil {
 		return fmt.Errorf("failed to write header: %w", err)
@@ -180,23 +217,31 @@ func (s *Server) sendResponse(writer io.Writer, msg *jsonrpcMessage) error {
 
 // InitializeParams represents the initialize request parameters.
 type InitializeParams struct {
-	ProcessID int                    `json:"processId,omitempty"`
-	RootPath  string                 `json:"rootPath,omitempty"`
-	RootURI   string                 `json:"rootUri,omitempty"`
+	ProcessID    int                    `json:"processId,omitempty"`
+	RootPath     string                 `json:"rootPath,omitempty"`
+	RootURI      string                 `json:"rootUri,omitempty"`
 	Capabilities map[string]interface{} `json:"capabilities,omitempty"`
 }
 
 // InitializeResult represents the initialize response.
 type InitializeResult struct {
 	Capabilities ServerCapabilities `json:"capabilities"`
-	ServerInfo   ServerInfo          `json:"serverInfo"`
+	ServerInfo   ServerInfo         `json:"serverInfo"`
 }
 
 type ServerCapabilities struct {
-	TextDocumentSync   int                      `json:"textDocumentSync"`
-	CompletionProvider map[string]interface{}    `json:"completionProvider,omitempty"`
-	HoverProvider      bool                     `json:"hoverProvider"`
-	DefinitionProvider bool                     `json:"definitionProvider"`
+	TextDocumentSync        int                    `json:"textDocumentSync"`
+	CompletionProvider      map[string]interface{} `json:"completionProvider,omitempty"`
+	HoverProvider           bool                   `json:"hoverProvider"`
+	DefinitionProvider      bool                   `json:"definitionProvider"`
+	ReferencesProvider      bool                   `json:"referencesProvider"`
+	RenameProvider          bool                   `json:"renameProvider"`
+	DocumentSymbolProvider  bool                   `json:"documentSymbolProvider"`
+	WorkspaceSymbolProvider bool                   `json:"workspaceSymbolProvider"`
+	SemanticTokensProvider  *SemanticTokensOptions `json:"semanticTokensProvider,omitempty"`
+	FormattingProvider      bool                   `json:"documentFormattingProvider"`
+	RangeFormattingProvider bool                   `json:"documentRangeFormattingProvider"`
+	CodeActionProvider      *CodeActionOptions     `json:"codeActionProvider,omitempty"`
 }
 
 type ServerInfo struct {
@@ -226,12 +271,26 @@ func (s *Server) handleInitialize(msg *jsonrpcMessage) *jsonrpcMessage {
 
 	result := InitializeResult{
 		Capabilities: ServerCapabilities{
-			TextDocumentSync:   1, // Incremental sync
+			TextDocumentSync: 2, // Incremental sync
 			CompletionProvider: map[string]interface{}{
 				"triggerCharacters": []string{".", "::"},
 			},
-			HoverProvider:      true,
-			DefinitionProvider: true,
+			HoverProvider:           true,
+			DefinitionProvider:      true,
+			ReferencesProvider:      true,
+			RenameProvider:          true,
+			DocumentSymbolProvider:  true,
+			WorkspaceSymbolProvider: true,
+			SemanticTokensProvider: &SemanticTokensOptions{
+				Legend: SemanticTokensLegend{
+					TokenTypes:     semanticTokenTypes,
+					TokenModifiers: semanticTokenModifiers,
+				},
+				Full: true,
+			},
+			FormattingProvider:      true,
+			RangeFormattingProvider: true,
+			CodeActionProvider:      &CodeActionOptions{CodeActionKinds: []string{codeActionQuickFix}},
 		},
 		ServerInfo: ServerInfo{
 			Name:    "malphas-lsp",
@@ -292,7 +351,7 @@ func (s *Server) handleDidOpen(msg *jsonrpcMessage) {
 
 // DidChangeTextDocumentParams represents didChange notification parameters.
 type DidChangeTextDocumentParams struct {
-	TextDocument   VersionedTextDocumentIdentifier `json:"textDocument"`
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
 	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
 }
 
@@ -301,8 +360,11 @@ type VersionedTextDocumentIdentifier struct {
 	Version int    `json:"version"`
 }
 
+// TextDocumentContentChangeEvent replaces Range with Text, or the whole
+// document when Range is nil.
 type TextDocumentContentChangeEvent struct {
-	Text string `json:"text"`
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
 }
 
 func (s *Server) handleDidChange(msg *jsonrpcMessage) {
@@ -317,19 +379,18 @@ func (s *Server) handleDidChange(msg *jsonrpcMessage) {
 
 	uri := params.TextDocument.URI
 	doc, ok := s.Documents[uri]
-	if !ok {
+	if !ok || len(params.ContentChanges) == 0 {
 		return
 	}
 
-	// Update content (for now, we only handle full document updates)
-	if len(params.ContentChanges) > 0 {
-		doc.Content = params.ContentChanges[len(params.ContentChanges)-1].Text
-		doc.Version = params.TextDocument.Version
-
-		// Re-parse and type check
-		s.updateDocument(doc)
-		s.publishDiagnostics(doc)
+	for _, change := range params.ContentChanges {
+		doc.Content = applyChange(doc.Content, change)
 	}
+	doc.Version = params.TextDocument.Version
+
+	// Typing sends a change per keystroke; check once the typing pauses
+	doc.dirty = true
+	s.scheduleCheck(doc)
 }
 
 func (s *Server) handleDidClose(msg *jsonrpcMessage) {
@@ -344,7 +405,12 @@ func (s *Server) handleDidClose(msg *jsonrpcMessage) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if doc, ok := s.Documents[params.TextDocument.URI]; ok && doc.pending != nil {
+		doc.pending.Stop()
+	}
 	delete(s.Documents, params.TextDocument.URI)
+	delete(s.checkMu, params.TextDocument.URI)
+	s.program.Forget(absPath(uriToPath(params.TextDocument.URI)))
 }
 
 type TextDocumentIdentifier struct {
@@ -353,72 +419,37 @@ type TextDocumentIdentifier struct {
 
 // updateDocument parses and type checks a document.
 func (s *Server) updateDocument(doc *Document) {
-	filePath := uriToPath(doc.URI)
-
-	// Parse
-	p := parser.New(doc.Content, parser.WithFilename(filePath))
-	file := p.ParseFile()
-
-	// Collect parse errors
-	var errors []diag.Diagnostic
-	for _, err := range p.Errors() {
-		errors = append(errors, diag.Diagnostic{
-			Stage:    diag.StageParser,
-			Severity: err.Severity,
-			Code:     diag.Code("PARSE_ERROR"),
-			Message:  err.Message,
-			Span: diag.Span{
-				Filename: err.Span.Filename,
-				Line:     err.Span.Line,
-				Column:   err.Span.Column,
-				Start:    err.Span.Start,
-				End:      err.Span.End,
-			},
-		})
-	}
+	install(doc, s.analyze(doc.URI, doc.Content, doc.checked))
+}
 
-	// Type check if parsing succeeded
-	if len(p.Errors()) == 0 {
-		checker := types.NewChecker()
-		absPath, err := filepath.Abs(filePath)
-		if err != nil {
-			absPath = filePath
-		}
-		checker.CheckWithFilename(file, absPath)
-		errors = append(errors, checker.Errors...)
-		doc.Checker = checker
-	}
+// checkFile type checks a parsed document, resolving modules relative to
+// it, and records the result in the program
+func (s *Server) checkFile(file *ast.File, filePath string) *types.CheckResult {
+	return s.program.Check(file, absPath(filePath))
+}
 
-	doc.File = file
-	doc.Errors = errors
+// absPath returns the absolute form of filePath, or filePath itself when it
+// cannot be made absolute
+func absPath(filePath string) string {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return filePath
+	}
+	return abs
 }
 
 // publishDiagnostics sends diagnostics to the client.
 func (s *Server) publishDiagnostics(doc *Document) {
-	// Convert diagnostics to LSP format
+	converter := newDiagnosticConverter(doc)
 	lspDiagnostics := make([]Diagnostic, 0, len(doc.Errors))
-	for _, diag := range doc.Errors {
-		lspDiag := Diagnostic{
-			Range: Range{
-				Start: Position{
-					Line:      diag.Span.Line - 1,      // LSP uses 0-based lines
-					Character: diag.Span.Column - 1,    // LSP uses 0-based columns
-				},
-				End: Position{
-					Line:      diag.Span.Line - 1,
-					Character: diag.Span.Column - 1,
-				},
-			},
-			Severity: diagnosticSeverity(diag.Severity),
-			Message:  diag.Message,
-			Code:     string(diag.Code),
-		}
-		lspDiagnostics = append(lspDiagnostics, lspDiag)
+	for _, d := range doc.Errors {
+		lspDiagnostics = append(lspDiagnostics, converter.convert(d))
 	}
 
 	// Send notification
 	params, _ := json.Marshal(map[string]interface{}{
 		"uri":         doc.URI,
+		"version":     doc.Version,
 		"diagnostics": lspDiagnostics,
 	})
 	notification := &jsonrpcMessage{
@@ -427,38 +458,7 @@ func (s *Server) publishDiagnostics(doc *Document) {
 		Params:  params,
 	}
 
-	s.sendResponse(os.Stdout, notification)
-}
-
-// Diagnostic represents an LSP diagnostic.
-type Diagnostic struct {
-	Range    Range    `json:"range"`
-	Severity int      `json:"severity"`
-	Message  string   `json:"message"`
-	Code     string   `json:"code,omitempty"`
-}
-
-type Range struct {
-	Start Position `json:"start"`
-	End   Position `json:"end"`
-}
-
-type Position struct {
-	Line      int `json:"line"`
-	Character int `json:"character"`
-}
-
-func diagnosticSeverity(sev diag.Severity) int {
-	switch sev {
-	case diag.SeverityError:
-		return 1 // Error
-	case diag.SeverityWarning:
-		return 2 // Warning
-	case diag.SeverityNote:
-		return 3 // Information
-	default:
-		return 1
-	}
+	s.sendResponse(notification)
 }
 
 // uriToPath converts a file:// URI to a file path.
@@ -473,4 +473,3 @@ func uriToPath(uri string) string {
 	}
 	return uri
 }
-
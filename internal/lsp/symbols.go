@@ -0,0 +1,340 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+// Symbol kinds from the LSP specification
+const (
+	symbolKindModule     = 2
+	symbolKindClass      = 5
+	symbolKindMethod     = 6
+	symbolKindField      = 8
+	symbolKindEnum       = 10
+	symbolKindInterface  = 11
+	symbolKindFunction   = 12
+	symbolKindVariable   = 13
+	symbolKindConstant   = 14
+	symbolKindEnumMember = 22
+	symbolKindStruct     = 23
+	symbolKindTypeParam  = 26
+)
+
+// maxWorkspaceSymbols bounds a workspace/symbol result
+const maxWorkspaceSymbols = 200
+
+// DocumentSymbol is one entry of a document's outline.
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           int              `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// SymbolInformation is a workspace/symbol match.
+type SymbolInformation struct {
+	Name          string   `json:"name"`
+	Kind          int      `json:"kind"`
+	Location      Location `json:"location"`
+	ContainerName string   `json:"containerName,omitempty"`
+}
+
+// WorkspaceSymbolParams represents workspace/symbol request parameters.
+type WorkspaceSymbolParams struct {
+	Query string `json:"query"`
+}
+
+func (s *Server) handleDocumentSymbol(msg *jsonrpcMessage) *jsonrpcMessage {
+	var params struct {
+		TextDocument TextDocumentIdentifier `json:"textDocument"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &jsonrpcError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid params: %v", err),
+			},
+		}
+	}
+
+	doc, ok := s.document(params.TextDocument.URI)
+
+	if !ok || doc.File == nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Result:  nil,
+		}
+	}
+
+	return &jsonrpcMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  documentSymbols(doc),
+	}
+}
+
+func (s *Server) handleWorkspaceSymbol(msg *jsonrpcMessage) *jsonrpcMessage {
+	var params WorkspaceSymbolParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return &jsonrpcMessage{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+			Error: &jsonrpcError{
+				Code:    -32602,
+				Message: fmt.Sprintf("Invalid params: %v", err),
+			},
+		}
+	}
+
+	return &jsonrpcMessage{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  s.workspaceSymbols(params.Query),
+	}
+}
+
+// documentSymbols builds the outline of doc from its syntax tree, which is
+// available even when the document does not type check
+func documentSymbols(doc *Document) []DocumentSymbol {
+	o := outliner{conv: newDiagnosticConverter(doc), src: []rune(doc.Content)}
+	symbols := []DocumentSymbol{}
+	for _, mod := range doc.File.Mods {
+		symbols = append(symbols, o.symbol(mod, mod.Name, symbolKindModule, "", nil))
+	}
+	for _, decl := range doc.File.Decls {
+		if sym, ok := o.decl(decl); ok {
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols
+}
+
+// outliner turns declarations into document symbols
+type outliner struct {
+	conv *diagnosticConverter
+	src  []rune
+}
+
+func (o outliner) decl(decl ast.Decl) (DocumentSymbol, bool) {
+	switch d := decl.(type) {
+	case *ast.FnDecl:
+		return o.fn(d, symbolKindFunction), true
+	case *ast.StructDecl:
+		var fields []DocumentSymbol
+		for _, field := range d.Fields {
+			fields = append(fields, o.symbol(field, field.Name, symbolKindField, o.text(field.Type), nil))
+		}
+		return o.symbol(d, d.Name, symbolKindStruct, "struct", fields), true
+	case *ast.EnumDecl:
+		var variants []DocumentSymbol
+		for _, variant := range d.Variants {
+			variants = append(variants, o.symbol(variant, variant.Name, symbolKindEnumMember, "", nil))
+		}
+		return o.symbol(d, d.Name, symbolKindEnum, "enum", variants), true
+	case *ast.TraitDecl:
+		return o.symbol(d, d.Name, symbolKindInterface, "trait", o.methods(d.Methods)), true
+	case *ast.ImplDecl:
+		name := "impl " + o.text(d.Target)
+		if d.Trait != nil {
+			name = "impl " + o.text(d.Trait) + " for " + o.text(d.Target)
+		}
+		sym := DocumentSymbol{
+			Name:           name,
+			Kind:           symbolKindClass,
+			Range:          o.rangeOf(d),
+			SelectionRange: o.rangeOf(d.Target),
+			Children:       o.methods(d.Methods),
+		}
+		return sym, true
+	case *ast.ConstDecl:
+		return o.symbol(d, d.Name, symbolKindConstant, o.text(d.Type), nil), true
+	case *ast.StaticDecl:
+		return o.symbol(d, d.Name, symbolKindVariable, o.text(d.Type), nil), true
+	case *ast.TypeAliasDecl:
+		return o.symbol(d, d.Name, symbolKindTypeParam, "type "+o.text(d.Target), nil), true
+	}
+	return DocumentSymbol{}, false
+}
+
+func (o outliner) methods(methods []*ast.FnDecl) []DocumentSymbol {
+	var symbols []DocumentSymbol
+	for _, method := range methods {
+		symbols = append(symbols, o.fn(method, symbolKindMethod))
+	}
+	return symbols
+}
+
+// fn describes a function by the text of its signature after the name
+func (o outliner) fn(d *ast.FnDecl, kind int) DocumentSymbol {
+	end := d.Span().End
+	if d.Body != nil {
+		end = d.Body.Span().Start
+	}
+	header := strings.TrimSuffix(strings.TrimSpace(o.textBetween(d.Name.Span().End, end)), ";")
+	return o.symbol(d, d.Name, kind, strings.Join(strings.Fields(header), " "), nil)
+}
+
+func (o outliner) symbol(node ast.Node, name *ast.Ident, kind int, detail string, children []DocumentSymbol) DocumentSymbol {
+	return DocumentSymbol{
+		Name:           name.Name,
+		Detail:         detail,
+		Kind:           kind,
+		Range:          o.rangeOf(node),
+		SelectionRange: o.rangeOf(name),
+		Children:       children,
+	}
+}
+
+func (o outliner) rangeOf(node ast.Node) Range {
+	return o.conv.rangeOf(diagSpan(node.Span()))
+}
+
+// text returns the source of node, as written
+func (o outliner) text(node ast.Node) string {
+	if node == nil {
+		return ""
+	}
+	span := node.Span()
+	return o.textBetween(span.Start, span.End)
+}
+
+func (o outliner) textBetween(start, end int) string {
+	if start < 0 || end > len(o.src) || start >= end {
+		return ""
+	}
+	return string(o.src[start:end])
+}
+
+// workspaceSymbols searches the declarations of every .mal file under the
+// workspace root, and of open documents, for names matching query
+func (s *Server) workspaceSymbols(query string) []SymbolInformation {
+	s.flushAll()
+	docs := make(map[string]*Document)
+	s.mu.RLock()
+	for _, doc := range s.Documents {
+		snapshot := *doc
+		docs[uriToPath(doc.URI)] = &snapshot
+	}
+	root := s.rootPath
+	s.mu.RUnlock()
+
+	if root != "" {
+		filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if entry.IsDir() {
+				if path != root && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".mal" || docs[path] != nil {
+				return nil
+			}
+			if src, err := os.ReadFile(path); err == nil {
+				p := parser.New(string(src), parser.WithFilename(path))
+				docs[path] = &Document{URI: pathToURI(path), Content: string(src), File: p.ParseFile()}
+			}
+			return nil
+		})
+	}
+
+	type match struct {
+		info  SymbolInformation
+		score int
+	}
+	var matches []match
+	for _, doc := range docs {
+		if doc.File == nil {
+			continue
+		}
+		var visit func(symbols []DocumentSymbol, container string)
+		visit = func(symbols []DocumentSymbol, container string) {
+			for _, sym := range symbols {
+				if score, ok := fuzzyMatch(query, sym.Name); ok {
+					matches = append(matches, match{
+						info: SymbolInformation{
+							Name:          sym.Name,
+							Kind:          sym.Kind,
+							Location:      Location{URI: doc.URI, Range: sym.SelectionRange},
+							ContainerName: container,
+						},
+						score: score,
+					})
+				}
+				visit(sym.Children, sym.Name)
+			}
+		}
+		visit(documentSymbols(doc), "")
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.info.Name != b.info.Name {
+			return a.info.Name < b.info.Name
+		}
+		return a.info.Location.URI < b.info.Location.URI
+	})
+	if len(matches) > maxWorkspaceSymbols {
+		matches = matches[:maxWorkspaceSymbols]
+	}
+	result := make([]SymbolInformation, len(matches))
+	for i, m := range matches {
+		result[i] = m.info
+	}
+	return result
+}
+
+// fuzzyMatch reports whether the characters of query appear in name in
+// order, ignoring case. Matches that start at the beginning of name or at a
+// word boundary, and runs of consecutive characters, score higher.
+func fuzzyMatch(query, name string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	n := []rune(name)
+	lower := []rune(strings.ToLower(name))
+	score := 0
+	qi := 0
+	prev := -2
+	for i := 0; i < len(lower) && qi < len(q); i++ {
+		if lower[i] != q[qi] {
+			continue
+		}
+		switch {
+		case i == 0:
+			score += 3
+		case prev == i-1:
+			score += 2
+		case n[i-1] == '_' || (n[i] >= 'A' && n[i] <= 'Z'):
+			score += 2
+		default:
+			score++
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	if len(q) == len(n) {
+		score += 5 // Exact match
+	}
+	return score, true
+}
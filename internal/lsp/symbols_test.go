@@ -0,0 +1,102 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const outlineSource = `struct Point { x: int, y: int }
+
+trait Show {
+    fn show(&self) -> string;
+}
+
+impl Show for Point {
+    fn show(&self) -> string {
+        return "point";
+    }
+}
+
+fn distance(a: Point, b: Point) -> int {
+    return 0;
+}
+`
+
+func TestDocumentSymbols(t *testing.T) {
+	doc := &Document{URI: "file:///tmp/outline.mal", Content: outlineSource}
+	NewServer().updateDocument(doc)
+
+	symbols := documentSymbols(doc)
+	want := []struct {
+		name     string
+		kind     int
+		detail   string
+		children int
+	}{
+		{"Point", symbolKindStruct, "struct", 2},
+		{"Show", symbolKindInterface, "trait", 1},
+		{"impl Show for Point", symbolKindClass, "", 1},
+		{"distance", symbolKindFunction, "(a: Point, b: Point) -> int", 0},
+	}
+	if len(symbols) != len(want) {
+		t.Fatalf("got %d symbols, want %d: %+v", len(symbols), len(want), symbols)
+	}
+	for i, w := range want {
+		got := symbols[i]
+		if got.Name != w.name || got.Kind != w.kind || got.Detail != w.detail || len(got.Children) != w.children {
+			t.Errorf("symbol %d = %s (kind %d, %q, %d children), want %+v", i, got.Name, got.Kind, got.Detail, len(got.Children), w)
+		}
+	}
+
+	distance := symbols[3]
+	if distance.Range.Start.Line != 12 || distance.Range.End.Line != 14 {
+		t.Errorf("distance should span lines 13-15, got %+v", distance.Range)
+	}
+	if distance.SelectionRange != (Range{Start: Position{Line: 12, Character: 3}, End: Position{Line: 12, Character: 11}}) {
+		t.Errorf("distance selection range = %+v", distance.SelectionRange)
+	}
+	if method := symbols[2].Children[0]; method.Name != "show" || method.Kind != symbolKindMethod || method.Detail != "(&self) -> string" {
+		t.Errorf("unexpected impl method %+v", method)
+	}
+}
+
+func TestWorkspaceSymbols(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "geometry.mal"), []byte(outlineSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".hidden"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".hidden", "skip.mal"), []byte("fn distant_cousin() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer()
+	s.rootPath = dir
+	open := &Document{URI: "file:///elsewhere/open.mal", Content: "fn display_total() {}\n"}
+	s.updateDocument(open)
+	s.Documents[open.URI] = open
+
+	results := s.workspaceSymbols("distan")
+	if len(results) != 1 || results[0].Name != "distance" || results[0].Location.URI != pathToURI(filepath.Join(dir, "geometry.mal")) {
+		t.Fatalf("workspace symbols for distan = %+v", results)
+	}
+
+	// Fuzzy matches rank exact prefixes first and include open documents
+	results = s.workspaceSymbols("dt")
+	if len(results) != 2 || results[0].Name != "display_total" || results[1].Name != "distance" {
+		t.Errorf("workspace symbols for dt = %+v", results)
+	}
+
+	results = s.workspaceSymbols("show")
+	if len(results) != 4 {
+		t.Errorf("expected the trait, both show methods and the impl, got %+v", results)
+	}
+	for _, r := range results {
+		if r.Name == "show" && r.ContainerName == "" {
+			t.Errorf("method show should have a container: %+v", r)
+		}
+	}
+}
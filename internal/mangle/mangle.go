@@ -0,0 +1,82 @@
+// Package mangle turns the names of Malphas functions and types into symbols
+// that LLVM and the system linker accept, and back.
+//
+// A MIR name such as geo::Point::new or Vec::push$Box[int] uses characters
+// that are not allowed in symbols. Symbol encodes it so that every name maps
+// to a different symbol:
+//
+//   - letters, digits, '_' and '$' stand for themselves;
+//   - the path separator "::" becomes '.';
+//   - every other byte, as well as a digit starting the name, becomes '-'
+//     followed by two upper-case hex digits.
+//
+// Identifiers made of letters, digits and '_' are therefore unchanged, which
+// keeps main, runtime functions and extern "C" functions as they are.
+// Demangle reverses the encoding.
+package mangle
+
+import "strings"
+
+const hexDigits = "0123456789ABCDEF"
+
+// Symbol returns the symbol for the MIR name.
+func Symbol(name string) string {
+	if name == "" {
+		return "_"
+	}
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == ':' && i+1 < len(name) && name[i+1] == ':':
+			sb.WriteByte('.')
+			i++
+		case isIdentByte(c) && !(i == 0 && isDigit(c)):
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte('-')
+			sb.WriteByte(hexDigits[c>>4])
+			sb.WriteByte(hexDigits[c&0xF])
+		}
+	}
+	return sb.String()
+}
+
+// Demangle returns the MIR name a symbol was made from. Parts that Symbol
+// cannot have produced are kept as they are, so Demangle can be applied to
+// any symbol, such as the names in a backtrace.
+func Demangle(symbol string) string {
+	var sb strings.Builder
+	for i := 0; i < len(symbol); i++ {
+		c := symbol[i]
+		switch {
+		case c == '.':
+			sb.WriteString("::")
+		case c == '-' && i+2 < len(symbol) && isHex(symbol[i+1]) && isHex(symbol[i+2]):
+			sb.WriteByte(unhex(symbol[i+1])<<4 | unhex(symbol[i+2]))
+			i += 2
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func isIdentByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c == '_' || c == '$'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || c >= 'A' && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	if isDigit(c) {
+		return c - '0'
+	}
+	return c - 'A' + 10
+}
@@ -0,0 +1,78 @@
+package mangle
+
+import "testing"
+
+func TestSymbol(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"main", "main"},
+		{"runtime_alloc", "runtime_alloc"},
+		{"__add__", "__add__"},
+		{"Point::new", "Point.new"},
+		{"geo::shapes::square", "geo.shapes.square"},
+		{"id$int", "id$int"},
+		{"pair$Box[int],bool", "pair$Box-5Bint-5D-2Cbool"},
+		{"get$*Node", "get$-2ANode"},
+		{"a.b", "a-2Eb"},
+		{"a:b", "a-3Ab"},
+		{"1st", "-31st"},
+		{"", "_"},
+	}
+	for _, tt := range tests {
+		if got := Symbol(tt.name); got != tt.want {
+			t.Errorf("Symbol(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSymbolIsUnambiguous(t *testing.T) {
+	// Joining type and method with '_' made these the same symbol
+	pairs := [][2]string{
+		{"Foo_bar::baz", "Foo::bar_baz"},
+		{"a::b::c", "a__b::c"},
+		{"a::b", "a.b"},
+		{"f$A_B", "f$A,B"},
+		{"f$Box[int]", "f$Box_int"},
+	}
+	for _, p := range pairs {
+		if Symbol(p[0]) == Symbol(p[1]) {
+			t.Errorf("%q and %q both mangle to %q", p[0], p[1], Symbol(p[0]))
+		}
+	}
+}
+
+func TestDemangle(t *testing.T) {
+	names := []string{
+		"main",
+		"Foo_bar::baz",
+		"Foo::bar_baz",
+		"geo::shapes::square",
+		"Vec::push$Box[int]",
+		"pair$[]u8,[4]float",
+		"a.b:c",
+		"9lives",
+		"-",
+		"x-41",
+	}
+	for _, name := range names {
+		if got := Demangle(Symbol(name)); got != name {
+			t.Errorf("Demangle(Symbol(%q)) = %q via %q", name, got, Symbol(name))
+		}
+	}
+}
+
+func TestDemangleForeignSymbols(t *testing.T) {
+	tests := []struct {
+		symbol, want string
+	}{
+		{"printf", "printf"},
+		{"x-zz", "x-zz"},
+		{"trailing-4", "trailing-4"},
+	}
+	for _, tt := range tests {
+		if got := Demangle(tt.symbol); got != tt.want {
+			t.Errorf("Demangle(%q) = %q, want %q", tt.symbol, got, tt.want)
+		}
+	}
+}
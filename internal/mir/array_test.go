@@ -0,0 +1,67 @@
+package mir
+
+import "testing"
+
+func TestLowerArrayLenIsConstant(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn main() {
+    let arr = [1, 2, 3];
+    let n = len(arr);
+    for x in arr {
+        println(x);
+    }
+}
+`)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			switch s := stmt.(type) {
+			case *Call:
+				if s.Func == "len" || s.Func == "runtime_slice_len" {
+					t.Errorf("the length of an array should be a constant: %s", s.PrettyPrint())
+				}
+			case *LoadIndex:
+				if !s.InBounds {
+					t.Errorf("a for loop over an array should index it in bounds: %s", s.PrettyPrint())
+				}
+			}
+		}
+	}
+}
+
+func TestLowerArrayElementAssignWritesBack(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+struct Grid {
+    cells: [int; 3],
+}
+fn main() {
+    let mut g = Grid { cells: [1, 2, 3] };
+    g.cells[1] = 5;
+}
+`)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+	var storeIndex *StoreIndex
+	var storeField *StoreField
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			switch s := stmt.(type) {
+			case *StoreIndex:
+				storeIndex = s
+			case *StoreField:
+				storeField = s
+			}
+		}
+	}
+	if storeIndex == nil || storeField == nil {
+		t.Fatalf("g.cells[1] = 5 should store into a copy of the array and write it back")
+	}
+	if storeField.Field != "cells" || operandString(storeField.Value) != operandString(storeIndex.Target) {
+		t.Errorf("writes back %s to field %s, want the stored array %s", operandString(storeField.Value), storeField.Field, operandString(storeIndex.Target))
+	}
+}
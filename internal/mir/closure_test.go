@@ -0,0 +1,74 @@
+package mir
+
+import (
+	"testing"
+)
+
+func TestClosureCapturesVariables(t *testing.T) {
+	src := `
+fn main() {
+    let base = 10;
+    let add_base = |x: int| { x + base };
+    let y = add_base(5);
+}
+`
+	module, _ := lowerModule(t, src)
+
+	closure := findFunction(module, "main_closure_1")
+	if closure == nil {
+		t.Fatalf("closure function not found:\n%s", module.PrettyPrint())
+	}
+	if len(closure.Params) != 2 || closure.Params[0].Name != "env" {
+		t.Fatalf("closure should take its environment first, got params %v", closure.Params)
+	}
+	load, ok := closure.Entry.Statements[0].(*LoadField)
+	if !ok || load.Field != "base" {
+		t.Fatalf("closure should load base from its environment on entry:\n%s", closure.PrettyPrint())
+	}
+	if ref, ok := load.Target.(*LocalRef); !ok || ref.Local.ID != closure.Params[0].ID {
+		t.Errorf("base should be loaded from the env parameter, got %s", load.PrettyPrint())
+	}
+
+	main := findFunction(module, "main")
+	var env *ConstructStruct
+	var call *Call
+	for _, stmt := range main.Entry.Statements {
+		switch s := stmt.(type) {
+		case *ConstructStruct:
+			env = s
+		case *Call:
+			call = s
+		}
+	}
+	if env == nil || env.Fields["base"] == nil {
+		t.Fatalf("main should copy base into the environment:\n%s", main.PrettyPrint())
+	}
+	if call == nil || call.FuncOperand == nil {
+		t.Fatalf("the call of add_base should be indirect:\n%s", main.PrettyPrint())
+	}
+}
+
+func TestNestedClosureCapturesThroughEnclosingClosure(t *testing.T) {
+	src := `
+fn main() {
+    let base = 10;
+    let outer = |x: int| {
+        let inner = |y: int| { y + base };
+        inner(x)
+    };
+    let z = outer(1);
+}
+`
+	module, _ := lowerModule(t, src)
+
+	for _, name := range []string{"main_closure_1", "main_closure_1_closure_4"} {
+		fn := findFunction(module, name)
+		if fn == nil {
+			t.Fatalf("function %s not found:\n%s", name, module.PrettyPrint())
+		}
+		load, ok := fn.Entry.Statements[0].(*LoadField)
+		if !ok || load.Field != "base" {
+			t.Errorf("%s should load base from its environment on entry:\n%s", name, fn.PrettyPrint())
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package mir
+
+import "testing"
+
+func TestLowerConsts(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+const LIMIT: int = 2 * 5;
+fn take(v: int) {}
+fn main() {
+    take(LIMIT);
+    match 3 {
+        LIMIT => take(1),
+        _ => take(2),
+    };
+}
+`)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+
+	var args, compared []string
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			call, ok := stmt.(*Call)
+			if !ok {
+				continue
+			}
+			switch call.Func {
+			case "take":
+				args = append(args, operandString(call.Args[0]))
+			case "__eq__":
+				compared = append(compared, operandString(call.Args[1]))
+			}
+		}
+	}
+	if len(args) == 0 || args[0] != "10" {
+		t.Errorf("take(LIMIT) passes %v, want the literal 10", args)
+	}
+	// The arm compares with the const instead of binding a variable
+	if len(compared) != 1 || compared[0] != "10" {
+		t.Errorf("match compares with %v, want [10]", compared)
+	}
+}
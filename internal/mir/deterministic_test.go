@@ -0,0 +1,48 @@
+package mir
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLoweringIsDeterministic lowers a module with many types repeatedly:
+// the types are collected from the checker's maps, which Go iterates in a
+// different order each time
+func TestLoweringIsDeterministic(t *testing.T) {
+	src := `package main;
+struct Alpha { a: int }
+struct Beta { b: int, c: float }
+struct Gamma { g: bool }
+struct Delta { d: int, e: int, f: int }
+enum Kind { One, Two }
+enum Shape { Dot, Line(int) }
+enum Mood { Calm, Angry }
+fn main() {
+    let _d = Delta { f: 3, d: 1, e: 2 };
+    let _b = Beta { c: 1.5, b: 2 };
+}
+`
+	lower := func() string {
+		file, checker := parseAndTypeCheck(t, src)
+		lowerer := NewLowerer(checker.ExprTypes, checker.CallTypeArgs, checker.GlobalScope, checker.MethodTable, checker.Modules)
+		module, err := lowerer.LowerModule(file)
+		if err != nil {
+			t.Fatalf("lowering error: %v", err)
+		}
+		var names []string
+		for _, s := range module.Structs {
+			names = append(names, s.Name)
+		}
+		for _, e := range module.Enums {
+			names = append(names, e.Name)
+		}
+		return strings.Join(names, ",") + "\n" + module.PrettyPrint()
+	}
+
+	want := lower()
+	for i := 0; i < 20; i++ {
+		if got := lower(); got != want {
+			t.Fatalf("lowering the same source twice gave different modules:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package mir
+
+import "testing"
+
+const dropDecls = `package main;
+
+struct File { fd: int }
+
+impl Drop for File {
+    fn drop(&mut self) { println(self.fd); }
+}
+
+fn consume(f: File) {}
+`
+
+// dropCalls returns the names of the variables fn drops, in block order
+func dropCalls(fn *Function) []string {
+	var dropped []string
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if call, ok := stmt.(*Call); ok && call.Func == "File::drop" {
+				dropped = append(dropped, call.Args[0].(*LocalRef).Local.Name)
+			}
+		}
+	}
+	return dropped
+}
+
+func TestLowerDropsAtScopeExit(t *testing.T) {
+	module, _ := lowerModule(t, dropDecls+`
+fn main() {
+    let a = File { fd: 1 };
+    let b = File { fd: 2 };
+}
+`)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+	got := dropCalls(fn)
+	if len(got) != 2 || got[0] != "b" || got[1] != "a" {
+		t.Errorf("dropped %v, want [b a]", got)
+	}
+
+	registered := 0
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if _, ok := stmt.(*RegisterDrop); ok {
+				registered++
+			}
+		}
+	}
+	if registered != 2 {
+		t.Errorf("registered %d finalizers, want one per literal", registered)
+	}
+}
+
+func TestLowerDropsOnEarlyExit(t *testing.T) {
+	module, _ := lowerModule(t, dropDecls+`
+fn main() {
+    let a = File { fd: 1 };
+    let mut i = 0;
+    while i < 3 {
+        let b = File { fd: i };
+        if i == 1 {
+            return;
+        }
+        if i == 2 {
+            break;
+        }
+        i = i + 1;
+    }
+}
+`)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+	// return drops b and a, break drops b, the end of the loop body drops b
+	// and the end of main drops a
+	counts := make(map[string]int)
+	for _, name := range dropCalls(fn) {
+		counts[name]++
+	}
+	if counts["a"] != 2 || counts["b"] != 3 {
+		t.Errorf("drop counts %v, want a twice and b three times", counts)
+	}
+}
+
+func TestLowerMoveClearsDropFlag(t *testing.T) {
+	module, _ := lowerModule(t, dropDecls+`
+fn main() {
+    let a = File { fd: 1 };
+    consume(a);
+}
+`)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+	cleared := false
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if assign, ok := stmt.(*Assign); ok {
+				if lit, ok := assign.RHS.(*Literal); ok && lit.Value == false {
+					cleared = true
+				}
+			}
+		}
+	}
+	if !cleared {
+		t.Error("moving a into consume does not clear its drop flag")
+	}
+
+	// consume owns its parameter and drops it
+	if got := dropCalls(findFunction(module, "consume")); len(got) != 1 || got[0] != "f" {
+		t.Errorf("consume drops %v, want [f]", got)
+	}
+}
@@ -0,0 +1,525 @@
+package interp
+
+import (
+	"io"
+	"math"
+	"math/bits"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/codegen/mir2llvm"
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// callStmt runs a call: of a closure, a builtin, an intrinsic or a function
+// of the module
+func (t *thread) callStmt(f *frame, c *mir.Call) Value {
+	if c.Func == "" {
+		closure, ok := deref(t.eval(f, c.FuncOperand)).(*Closure)
+		if !ok {
+			t.errorf("call of a value that is not a closure")
+		}
+		fn, ok := t.in.funcs[closure.Func]
+		if !ok {
+			t.errorf("closure of undefined function %s", closure.Func)
+		}
+		// A closure's function takes its environment first
+		return t.call(fn, append([]Value{closure.Env}, t.evalAll(f, c.Args)...))
+	}
+
+	if result, ok := t.operator(f, c); ok {
+		return result
+	}
+	args := t.evalAll(f, c.Args)
+	if result, ok := t.builtin(f, c, args); ok {
+		return result
+	}
+	if _, ok := types.LookupIntrinsic(c.Func); ok {
+		return t.intrinsic(c.Func, args)
+	}
+	if fn, ok := t.in.funcs[c.Func]; ok {
+		return t.call(fn, args)
+	}
+	if _, ok := t.in.externs[c.Func]; ok {
+		t.errorf("cannot call extern function %s: the interpreter does not load C code", c.Func)
+	}
+	t.errorf("call of undefined function %s", c.Func)
+	return nil
+}
+
+// operator runs an operator intrinsic, or the checked_* and wrapping_*
+// builtins
+func (t *thread) operator(f *frame, c *mir.Call) (Value, bool) {
+	switch c.Func {
+	case "__add__", "__sub__", "__mul__", "__div__",
+		"__eq__", "__ne__", "__lt__", "__le__", "__gt__", "__ge__",
+		"__and__", "__or__", "__neg__", "__not__":
+	default:
+		if op, ok := strings.CutPrefix(c.Func, "checked_"); ok && isArith(op) && len(c.Args) == 2 {
+			a, b := t.ints(f, c.Args)
+			v, overflow := arith(op, a, b, c.Args[0].OperandType())
+			if overflow {
+				return nil, true
+			}
+			return v, true
+		}
+		if op, ok := strings.CutPrefix(c.Func, "wrapping_"); ok && isArith(op) && len(c.Args) == 2 {
+			a, b := t.ints(f, c.Args)
+			v, _ := arith(op, a, b, c.Args[0].OperandType())
+			return v, true
+		}
+		return nil, false
+	}
+
+	args := make([]Value, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = deref(t.eval(f, arg))
+	}
+	want := 2
+	if c.Func == "__neg__" || c.Func == "__not__" {
+		want = 1
+	}
+	if len(args) != want {
+		t.errorf("%s takes %d arguments, got %d", c.Func, want, len(args))
+	}
+
+	switch c.Func {
+	case "__and__", "__or__", "__not__":
+		a, _ := args[0].(bool)
+		switch c.Func {
+		case "__not__":
+			return !a, true
+		case "__and__":
+			b, _ := args[1].(bool)
+			return a && b, true
+		}
+		b, _ := args[1].(bool)
+		return a || b, true
+	case "__eq__":
+		return t.compare(c, args) == 0, true
+	case "__ne__":
+		// Like LLVM's fcmp one, NaN is not unequal to anything
+		if isNaN(args[0]) || isNaN(args[1]) {
+			return false, true
+		}
+		return t.compare(c, args) != 0, true
+	case "__lt__":
+		return t.compare(c, args) == -1, true
+	case "__le__":
+		cmp := t.compare(c, args)
+		return cmp == -1 || cmp == 0, true
+	case "__gt__":
+		return t.compare(c, args) == 1, true
+	case "__ge__":
+		cmp := t.compare(c, args)
+		return cmp == 1 || cmp == 0, true
+	}
+
+	switch a := args[0].(type) {
+	case float64:
+		if c.Func == "__neg__" {
+			return -a, true
+		}
+		b, _ := args[1].(float64)
+		switch c.Func {
+		case "__add__":
+			return a + b, true
+		case "__sub__":
+			return a - b, true
+		case "__mul__":
+			return a * b, true
+		}
+		return a / b, true
+	case string:
+		if b, ok := args[1].(string); ok && c.Func == "__add__" {
+			return a + b, true
+		}
+	case int64:
+		typ := c.Result.Type
+		if _, _, ok := intBits(typ); !ok {
+			typ = c.Args[0].OperandType()
+		}
+		if c.Func == "__neg__" {
+			v, overflow := arith("sub", 0, a, typ)
+			if overflow && t.in.opts.Overflow != mir2llvm.OverflowWrap {
+				t.panicf("integer overflow in sub")
+			}
+			return v, true
+		}
+		b, _ := args[1].(int64)
+		op := strings.Trim(c.Func, "_")
+		if op == "div" {
+			return t.divide(a, b, typ), true
+		}
+		v, overflow := arith(op, a, b, typ)
+		if overflow && t.in.opts.Overflow != mir2llvm.OverflowWrap {
+			t.panicf("integer overflow in %s", op)
+		}
+		return v, true
+	}
+	t.errorf("%s of values of type %s", c.Func, c.Args[0].OperandType())
+	return nil, true
+}
+
+func isArith(op string) bool {
+	return op == "add" || op == "sub" || op == "mul"
+}
+
+// ints returns the values of two integer operands
+func (t *thread) ints(f *frame, ops []mir.Operand) (int64, int64) {
+	a, ok1 := t.eval(f, ops[0]).(int64)
+	b, ok2 := t.eval(f, ops[1]).(int64)
+	if !ok1 || !ok2 {
+		t.errorf("integer operation on values of type %s", ops[0].OperandType())
+	}
+	return a, b
+}
+
+// unordered is the result of compare for values with no order, such as NaN
+const unordered = 2
+
+// compare returns -1, 0 or 1 as args[0] is less than, equal to or greater
+// than args[1], or unordered
+func (t *thread) compare(c *mir.Call, args []Value) int {
+	switch a := args[0].(type) {
+	case int64:
+		b, ok := args[1].(int64)
+		if !ok {
+			return unordered
+		}
+		typ := c.Args[0].OperandType()
+		if _, ok := c.Args[0].(*mir.Literal); ok {
+			typ = c.Args[1].OperandType()
+		}
+		if _, unsigned, _ := intBits(typ); unsigned {
+			return cmp3(uint64(a), uint64(b))
+		}
+		return cmp3(a, b)
+	case float64:
+		b, _ := args[1].(float64)
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return unordered
+		}
+		return cmp3(a, b)
+	case string:
+		if b, ok := args[1].(string); ok {
+			return strings.Compare(a, b)
+		}
+	}
+	if equal(args[0], args[1]) {
+		return 0
+	}
+	return unordered
+}
+
+func isNaN(v Value) bool {
+	f, ok := v.(float64)
+	return ok && math.IsNaN(f)
+}
+
+func cmp3[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// arith computes a op b in the integer type t and reports whether the
+// result overflowed it, in which case the wrapped result is returned
+func arith(op string, a, b int64, t types.Type) (int64, bool) {
+	width, unsigned, ok := intBits(t)
+	if !ok {
+		width = 64
+	}
+	if width < 64 {
+		// The exact result fits in 64 bits
+		var exact int64
+		switch op {
+		case "add":
+			exact = a + b
+		case "sub":
+			exact = a - b
+		default:
+			exact = a * b
+		}
+		v := normalize(t, exact)
+		return v, v != exact
+	}
+	if unsigned {
+		var v, carry uint64
+		switch op {
+		case "add":
+			v, carry = bits.Add64(uint64(a), uint64(b), 0)
+		case "sub":
+			v, carry = bits.Sub64(uint64(a), uint64(b), 0)
+		default:
+			carry, v = bits.Mul64(uint64(a), uint64(b))
+		}
+		return int64(v), carry != 0
+	}
+	switch op {
+	case "add":
+		v := a + b
+		return v, (a^v)&(b^v) < 0
+	case "sub":
+		v := a - b
+		return v, (a^b)&(a^v) < 0
+	}
+	v := a * b
+	return v, a != 0 && (v/a != b || (a == -1 && b == math.MinInt64))
+}
+
+// divide computes a / b in the integer type t. Division by zero always
+// panics; MIN / -1 wraps unless the overflow mode is checked.
+func (t *thread) divide(a, b int64, typ types.Type) int64 {
+	_, unsigned, _ := intBits(typ)
+	if b == 0 {
+		t.panicf("integer division by zero or overflow in div")
+	}
+	if unsigned {
+		return normalize(typ, int64(uint64(a)/uint64(b)))
+	}
+	v := normalize(typ, a/b)
+	if v != a/b || (a == math.MinInt64 && b == -1) {
+		if t.in.opts.Overflow == mir2llvm.OverflowChecked {
+			t.panicf("integer division by zero or overflow in div")
+		}
+	}
+	return v
+}
+
+// builtin runs a builtin function of the language or a runtime function the
+// lowerer calls
+func (t *thread) builtin(f *frame, c *mir.Call, args []Value) (Value, bool) {
+	arg := func(i int) Value {
+		if i >= len(args) {
+			t.errorf("%s takes at least %d arguments, got %d", c.Func, i+1, len(args))
+		}
+		return deref(args[i])
+	}
+
+	switch c.Func {
+	case "println":
+		line := ""
+		if len(args) > 0 {
+			line = formatValue(arg(0))
+		}
+		t.in.print(line + "\n")
+		return nil, true
+
+	case "panic":
+		msg, ok := arg(0).(string)
+		if !ok {
+			msg = "explicit panic"
+		}
+		t.panicf("%s", msg)
+
+	case "runtime_optional_unwrap":
+		if args[0] == nil {
+			msg, ok := arg(1).(string)
+			if !ok {
+				msg = "called unwrap() on nil"
+			}
+			t.panicf("%s", msg)
+		}
+		return args[0], true
+
+	case "format":
+		format, _ := arg(0).(string)
+		var b strings.Builder
+		next := 1
+		for {
+			before, after, found := strings.Cut(format, "{}")
+			b.WriteString(before)
+			if !found {
+				break
+			}
+			if next < len(args) {
+				b.WriteString(formatValue(arg(next)))
+				next++
+			} else {
+				b.WriteString("{}")
+			}
+			format = after
+		}
+		return b.String(), true
+
+	case "len":
+		switch v := arg(0).(type) {
+		case *Slice:
+			return int64(len(v.Elems)), true
+		case string:
+			return int64(len(v)), true
+		case *Map:
+			return int64(v.len()), true
+		case nil:
+			return int64(0), true
+		}
+		t.errorf("len of a value of type %s", c.Args[0].OperandType())
+
+	case "append":
+		s := t.slice(arg(0))
+		return &Slice{Elems: push(s.Elems, arg(1))}, true
+
+	case "runtime_channel_close":
+		t.close(t.channelValue(arg(0)))
+		return nil, true
+
+	case "__map_new__":
+		m := newMap()
+		for i := 0; i+1 < len(args); i += 2 {
+			m.put(args[i], args[i+1])
+		}
+		return m, true
+
+	case "contains", "delete", "__map_remove__", "__map_keys__", "__map_values__":
+		m, ok := arg(0).(*Map)
+		if !ok {
+			return nil, false
+		}
+		switch c.Func {
+		case "__map_keys__", "__map_values__":
+			keys, values := m.snapshot()
+			if c.Func == "__map_values__" {
+				keys = values
+			}
+			return &Slice{Elems: append(make([]Value, 0, max(len(keys), 1)), keys...)}, true
+		case "contains":
+			_, ok := m.get(arg(1))
+			return ok, true
+		}
+		v, _ := m.remove(arg(1))
+		return v, true
+	}
+
+	if strings.HasPrefix(c.Func, "runtime_slice_") {
+		return t.sliceCall(c, args), true
+	}
+	return nil, false
+}
+
+// print writes s to the program's standard output
+func (in *Interpreter) print(s string) {
+	in.out.Lock()
+	defer in.out.Unlock()
+	io.WriteString(in.opts.Stdout, s)
+}
+
+func (t *thread) slice(v Value) *Slice {
+	s, ok := v.(*Slice)
+	if !ok {
+		if v == nil {
+			t.panicf("slice operation on a nil slice")
+		}
+		t.errorf("slice operation on a value that is not a slice")
+	}
+	return s
+}
+
+func (t *thread) channelValue(v Value) *Channel {
+	ch, ok := v.(*Channel)
+	if !ok {
+		t.errorf("channel operation on a value that is not a channel")
+	}
+	return ch
+}
+
+// push appends v to elems, growing the capacity as the runtime does: it
+// doubles, from 1
+func push(elems []Value, v Value) []Value {
+	if len(elems) == cap(elems) {
+		elems = grow(elems, max(2*cap(elems), 1))
+	}
+	return append(elems, v)
+}
+
+// grow moves elems to a new buffer of capacity n
+func grow(elems []Value, n int) []Value {
+	return append(make([]Value, 0, n), elems...)
+}
+
+// sliceCall runs one of the runtime's slice functions. Their value
+// arguments are passed by address, as a pointer to a temporary for
+// primitive elements.
+func (t *thread) sliceCall(c *mir.Call, args []Value) Value {
+	if len(args) == 0 {
+		t.errorf("%s without a slice", c.Func)
+	}
+	s := t.slice(deref(args[0]))
+	var elem types.Type
+	if typ, ok := resolve(c.Args[0].OperandType()).(*types.Slice); ok {
+		elem = typ.Elem
+	}
+	value := func(i int) Value {
+		if i >= len(args) {
+			t.errorf("%s takes %d arguments, got %d", c.Func, i+1, len(args))
+		}
+		if primitiveKind(elem) != "" {
+			return deref(args[i])
+		}
+		return args[i]
+	}
+	index := func(i int) int64 {
+		if i >= len(args) {
+			t.errorf("%s takes %d arguments, got %d", c.Func, i+1, len(args))
+		}
+		n, _ := deref(args[i]).(int64)
+		return n
+	}
+
+	switch c.Func {
+	case "runtime_slice_len":
+		return int64(len(s.Elems))
+	case "runtime_slice_cap":
+		return int64(cap(s.Elems))
+	case "runtime_slice_push":
+		s.Elems = push(s.Elems, value(1))
+	case "runtime_slice_pop":
+		if len(s.Elems) == 0 {
+			return nil
+		}
+		v := s.Elems[len(s.Elems)-1]
+		s.Elems = s.Elems[:len(s.Elems)-1]
+		return v
+	case "runtime_slice_set":
+		s.Elems[t.element(s, index(1))] = value(2)
+	case "runtime_slice_insert":
+		i := index(1)
+		if uint64(i) > uint64(len(s.Elems)) {
+			t.panicf("insertion index out of bounds")
+		}
+		elems := push(s.Elems, nil)
+		copy(elems[i+1:], elems[i:])
+		elems[i] = value(2)
+		s.Elems = elems
+	case "runtime_slice_remove":
+		i := t.element(s, index(1))
+		s.Elems = append(s.Elems[:i], s.Elems[i+1:]...)
+	case "runtime_slice_clear":
+		s.Elems = s.Elems[:0]
+	case "runtime_slice_reserve":
+		needed := len(s.Elems) + int(index(1))
+		if needed > cap(s.Elems) {
+			n := cap(s.Elems)
+			for n < needed {
+				n = max(2*n, 1)
+			}
+			s.Elems = grow(s.Elems, n)
+		}
+	case "runtime_slice_copy":
+		return &Slice{Elems: append(make([]Value, 0, cap(s.Elems)), s.Elems...)}
+	case "runtime_slice_subslice":
+		start, end := index(1), index(2)
+		if uint64(start) > uint64(end) || uint64(end) > uint64(len(s.Elems)) {
+			t.panicf("invalid range [%d:%d) for slice of length %d", start, end, len(s.Elems))
+		}
+		// A view of the elements, whose capacity ends with its length
+		return &Slice{Elems: s.Elems[start:end:end]}
+	default:
+		t.errorf("unknown runtime function %s", c.Func)
+	}
+	return nil
+}
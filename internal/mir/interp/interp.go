@@ -0,0 +1,765 @@
+// Package interp runs MIR directly, without generating code. It runs
+// programs on machines without LLVM (`malphas run --interp`), evaluates
+// functions at compile time, and serves as a reference semantics to test the
+// LLVM backend against.
+//
+// Legions run as goroutines and channels are Go channels. Values are
+// described by Value. The interpreter follows the runtime where the program
+// can observe it: the output of println, panic messages and the exit status,
+// the capacity of growing slices, and the results of the runtime's string,
+// file, network and synchronization functions. It differs where the native
+// behavior is undefined or an artifact of the layout: integer overflow
+// wraps to the width of the type, unsigned values compare as unsigned, and
+// strings compare by contents.
+//
+// As natively, the program ends when main returns, whether or not the
+// legions it spawned have finished. Drop finalizers (RegisterDrop) are never
+// run, as there is no collector to
+// run them, and extern functions cannot be called.
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/malphas-lang/malphas-lang/internal/codegen/mir2llvm"
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// PanicExitStatus is the exit status of a program that panicked, as in the
+// runtime.
+const PanicExitStatus = 101
+
+// Options configures an Interpreter.
+type Options struct {
+	Stdin  io.Reader // os.Stdin if nil
+	Stdout io.Writer // os.Stdout if nil
+	Stderr io.Writer // os.Stderr if nil
+
+	// Args are the program's arguments, starting with its name
+	Args []string
+
+	// Overflow is the integer overflow mode, as given to --overflow
+	Overflow mir2llvm.OverflowMode
+}
+
+// Panic is a panic of the interpreted program.
+type Panic struct {
+	Message string
+	// Backtrace lists the interpreted functions that were running,
+	// innermost first
+	Backtrace []string
+}
+
+func (p *Panic) Error() string { return "panic: " + p.Message }
+
+// Interpreter runs the functions of a MIR module.
+type Interpreter struct {
+	opts    Options
+	funcs   map[string]*mir.Function
+	externs map[string]*mir.Extern
+	statics map[string]*static
+
+	out     sync.Mutex // serializes writes to Stdout and Stderr
+	handles handles
+
+	failOnce sync.Once
+	failed   chan error // the first panic or error of any legion
+}
+
+// static is the value of a static variable, shared by all legions
+type static struct {
+	mu    sync.Mutex
+	value Value
+}
+
+// New returns an interpreter for module, which must be monomorphized.
+func New(module *mir.Module, opts Options) *Interpreter {
+	if opts.Stdin == nil {
+		opts.Stdin = os.Stdin
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+	in := &Interpreter{
+		opts:    opts,
+		funcs:   make(map[string]*mir.Function),
+		externs: make(map[string]*mir.Extern),
+		statics: make(map[string]*static),
+		failed:  make(chan error, 1),
+	}
+	for _, fn := range module.Functions {
+		in.funcs[fn.Name] = fn
+	}
+	for _, ext := range module.Externs {
+		in.externs[ext.Name] = ext
+	}
+	for _, s := range module.Statics {
+		value := s.Value
+		if i, ok := value.(int); ok {
+			value = int64(i)
+		}
+		in.statics[s.Name] = &static{value: value}
+	}
+	in.handles.init(in)
+	return in
+}
+
+// Call runs the function name with args and returns its result. A panic of
+// the program, in the function or in a legion it spawned, is returned as a
+// *Panic.
+func (in *Interpreter) Call(name string, args ...Value) (Value, error) {
+	fn, ok := in.funcs[name]
+	if !ok {
+		return nil, fmt.Errorf("undefined function %s", name)
+	}
+	if len(args) != len(fn.Params) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name, len(fn.Params), len(args))
+	}
+
+	type outcome struct {
+		value Value
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		var result Value
+		err := in.guard(func() {
+			result = (&thread{in: in}).call(fn, args)
+		})
+		done <- outcome{result, err}
+	}()
+	select {
+	case o := <-done:
+		return o.value, o.err
+	case err := <-in.failed:
+		return nil, err
+	}
+}
+
+// Run runs the program's main function like the native executable would:
+// it returns the exit status, after printing a panic or an Err returned from
+// main to Stderr. The error is only set if the program cannot be
+// interpreted.
+func (in *Interpreter) Run() (int, error) {
+	fn, ok := in.funcs["main"]
+	if !ok {
+		return 0, errors.New("the program has no main function")
+	}
+	result, err := in.Call("main")
+	var p *Panic
+	if errors.As(err, &p) {
+		in.out.Lock()
+		defer in.out.Unlock()
+		fmt.Fprintf(in.opts.Stderr, "panic: %s\n", p.Message)
+		if len(p.Backtrace) > 0 {
+			fmt.Fprintln(in.opts.Stderr, "stack backtrace:")
+			for i, name := range p.Backtrace {
+				fmt.Fprintf(in.opts.Stderr, "  %d: %s\n", i, name)
+			}
+		}
+		return PanicExitStatus, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if enum, errType, ok := resultType(fn.ReturnType); ok {
+		e, _ := result.(*Enum)
+		if e == nil || e.Tag != enum.VariantIndex("Err") {
+			return 0, nil
+		}
+		in.out.Lock()
+		defer in.out.Unlock()
+		// Only string errors can be printed generically
+		if msg, ok := e.Payload[0].(string); ok && primitiveKind(errType) == types.String {
+			fmt.Fprintf(in.opts.Stderr, "Error: %s\n", msg)
+		} else {
+			fmt.Fprintln(in.opts.Stderr, "Error: main returned Err")
+		}
+		return 1, nil
+	}
+	if code, ok := result.(int64); ok {
+		return int(int32(code)), nil
+	}
+	return 0, nil
+}
+
+// resultType reports whether t, the return type of main, is a Result, and
+// returns its error type
+func resultType(t types.Type) (*types.Enum, types.Type, bool) {
+	enum, args, ok := types.AsResult(t)
+	if !ok {
+		return nil, nil, false
+	}
+	var errType types.Type
+	if len(args) == 2 {
+		errType = args[1]
+	}
+	return enum, errType, true
+}
+
+// guard runs f, turning a panic of the program into a *Panic error and a
+// failure of the interpreter into an error
+func (in *Interpreter) guard(f func()) (err error) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case *Panic:
+			err = r
+		case error:
+			err = r
+		default:
+			err = fmt.Errorf("interpreter failure: %v", r)
+		}
+	}()
+	f()
+	return nil
+}
+
+// fail reports the first failure of a legion to Call, which stops waiting
+// for the function it runs
+func (in *Interpreter) fail(err error) {
+	in.failOnce.Do(func() { in.failed <- err })
+}
+
+// thread is a running legion: the interpreted functions it is in and its
+// file error code
+type thread struct {
+	in        *Interpreter
+	stack     []string
+	lastError int64
+}
+
+// frame holds the locals of a running function
+type frame struct {
+	fn     *mir.Function
+	locals []Value
+}
+
+func (f *frame) get(id int) Value {
+	if id < 0 || id >= len(f.locals) {
+		return nil
+	}
+	return f.locals[id]
+}
+
+func (f *frame) set(l mir.Local, v Value) {
+	if l.ID >= len(f.locals) {
+		f.locals = append(f.locals, make([]Value, l.ID+1-len(f.locals))...)
+	}
+	f.locals[l.ID] = v
+}
+
+// panicf panics the program with a message, recording the interpreted stack
+func (t *thread) panicf(format string, args ...any) {
+	trace := make([]string, len(t.stack))
+	for i, name := range t.stack {
+		trace[len(t.stack)-1-i] = name
+	}
+	panic(&Panic{Message: fmt.Sprintf(format, args...), Backtrace: trace})
+}
+
+// errorf stops the interpreter on MIR it cannot run
+func (t *thread) errorf(format string, args ...any) {
+	where := ""
+	if len(t.stack) > 0 {
+		where = " in " + t.stack[len(t.stack)-1]
+	}
+	panic(fmt.Errorf("interp%s: %s", where, fmt.Sprintf(format, args...)))
+}
+
+// call runs fn with args and returns its result
+func (t *thread) call(fn *mir.Function, args []Value) Value {
+	size := 0
+	for _, l := range fn.Params {
+		size = max(size, l.ID+1)
+	}
+	for _, l := range fn.Locals {
+		size = max(size, l.ID+1)
+	}
+	f := &frame{fn: fn, locals: make([]Value, size)}
+	for i, param := range fn.Params {
+		if i < len(args) {
+			f.set(param, copyValue(param.Type, args[i]))
+		}
+	}
+
+	t.stack = append(t.stack, fn.Name)
+	defer func() { t.stack = t.stack[:len(t.stack)-1] }()
+
+	block := fn.Entry
+	if block == nil && len(fn.Blocks) > 0 {
+		block = fn.Blocks[0]
+	}
+	var pred *mir.BasicBlock
+	for block != nil {
+		stmts := t.phis(f, block, pred)
+		for _, stmt := range stmts {
+			t.exec(f, stmt)
+		}
+		var done bool
+		var result Value
+		pred = block
+		block, done, result = t.terminate(f, block.Terminator)
+		if done {
+			return result
+		}
+	}
+	t.errorf("control reaches the end of a block without a terminator")
+	return nil
+}
+
+// phis assigns the phi nodes at the start of block, all at once, with the
+// values coming from pred, and returns the statements that follow them
+func (t *thread) phis(f *frame, block, pred *mir.BasicBlock) []mir.Statement {
+	n := 0
+	for n < len(block.Statements) {
+		if _, ok := block.Statements[n].(*mir.Phi); !ok {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		return block.Statements
+	}
+	values := make([]Value, n)
+	for i, stmt := range block.Statements[:n] {
+		phi := stmt.(*mir.Phi)
+		input, ok := phi.Inputs[pred]
+		if !ok {
+			t.errorf("phi for %s has no input from %s", phi.Result.Name, blockLabel(pred))
+		}
+		values[i] = t.eval(f, input)
+	}
+	for i, stmt := range block.Statements[:n] {
+		f.set(stmt.(*mir.Phi).Result, values[i])
+	}
+	return block.Statements[n:]
+}
+
+func blockLabel(b *mir.BasicBlock) string {
+	if b == nil {
+		return "the function entry"
+	}
+	return b.Label
+}
+
+// eval returns the value of an operand
+func (t *thread) eval(f *frame, op mir.Operand) Value {
+	switch op := op.(type) {
+	case *mir.LocalRef:
+		return f.get(op.Local.ID)
+	case *mir.Literal:
+		switch v := op.Value.(type) {
+		case int:
+			return intLiteral(op.Type, int64(v))
+		case int64:
+			return intLiteral(op.Type, v)
+		case float32:
+			return float64(v)
+		}
+		return op.Value
+	case nil:
+		return nil
+	}
+	t.errorf("unsupported operand %T", op)
+	return nil
+}
+
+// intLiteral returns the value of an integer literal of type t. An integer
+// literal where a float is expected, as in 2.0 * 3, is a float.
+func intLiteral(t types.Type, v int64) Value {
+	if primitiveKind(t) == types.Float {
+		return float64(v)
+	}
+	return normalize(t, v)
+}
+
+// evalAll returns the values of ops
+func (t *thread) evalAll(f *frame, ops []mir.Operand) []Value {
+	values := make([]Value, len(ops))
+	for i, op := range ops {
+		values[i] = copyValue(op.OperandType(), t.eval(f, op))
+	}
+	return values
+}
+
+// deref follows pointers to the value they point to
+func deref(v Value) Value {
+	for {
+		p, ok := v.(*Pointer)
+		if !ok {
+			return v
+		}
+		v = p.load()
+	}
+}
+
+func (p *Pointer) load() Value {
+	return p.frame.get(p.index)
+}
+
+// terminate runs a terminator and returns the block to continue with, or
+// reports that the function returned
+func (t *thread) terminate(f *frame, term mir.Terminator) (*mir.BasicBlock, bool, Value) {
+	switch term := term.(type) {
+	case *mir.Return:
+		if term.Value == nil {
+			return nil, true, nil
+		}
+		return nil, true, copyValue(term.Value.OperandType(), t.eval(f, term.Value))
+	case *mir.Goto:
+		return term.Target, false, nil
+	case *mir.Branch:
+		cond, ok := t.eval(f, term.Condition).(bool)
+		if !ok {
+			t.errorf("branch condition is not a bool")
+		}
+		if cond {
+			return term.True, false, nil
+		}
+		return term.False, false, nil
+	case *mir.Select:
+		return t.selectCase(f, term), false, nil
+	case *mir.Unreachable:
+		t.errorf("reached an unreachable block")
+	case nil:
+		t.errorf("block has no terminator")
+	}
+	t.errorf("unsupported terminator %T", term)
+	return nil, false, nil
+}
+
+// exec runs a statement
+func (t *thread) exec(f *frame, stmt mir.Statement) {
+	switch s := stmt.(type) {
+	case *mir.Assign:
+		f.set(s.Local, copyValue(s.RHS.OperandType(), t.eval(f, s.RHS)))
+
+	case *mir.Call:
+		f.set(s.Result, t.callStmt(f, s))
+
+	case *mir.Spawn:
+		handle := t.spawn(f, s)
+		if s.Result != nil {
+			f.set(*s.Result, handle)
+		}
+
+	case *mir.Join:
+		handle, ok := t.eval(f, s.Handle).(*JoinHandle)
+		if !ok {
+			t.errorf("join of a value that is not a join handle")
+		}
+		<-handle.done
+		f.set(s.Result, handle.result)
+
+	case *mir.Yield:
+		runtime.Gosched()
+
+	case *mir.Load:
+		v := t.eval(f, s.Address)
+		if p, ok := v.(*Pointer); ok {
+			// A loaded optional is the value it holds, even a pointer
+			if _, opt := resolve(s.Address.OperandType()).(*types.Optional); !opt {
+				v = p.load()
+			}
+		}
+		f.set(s.Result, copyValue(s.Result.Type, v))
+
+	case *mir.LoadStatic:
+		st := t.static(s.Name)
+		st.mu.Lock()
+		v := st.value
+		st.mu.Unlock()
+		f.set(s.Result, v)
+
+	case *mir.StoreStatic:
+		st := t.static(s.Name)
+		v := t.eval(f, s.Value)
+		st.mu.Lock()
+		st.value = v
+		st.mu.Unlock()
+
+	case *mir.LoadField:
+		obj, i := t.field(f, s.Target, s.Field)
+		f.set(s.Result, copyValue(s.Result.Type, obj.Values[i]))
+
+	case *mir.StoreField:
+		obj, i := t.field(f, s.Target, s.Field)
+		obj.Values[i] = copyValue(s.Value.OperandType(), t.eval(f, s.Value))
+
+	case *mir.LoadIndex:
+		f.set(s.Result, copyValue(s.Result.Type, t.loadIndex(f, s)))
+
+	case *mir.StoreIndex:
+		t.storeIndex(f, s)
+
+	case *mir.ConstructStruct:
+		f.set(s.Result, t.constructStruct(f, s))
+
+	case *mir.ConstructArray:
+		elems := t.evalAll(f, s.Elements)
+		if _, ok := resolve(s.Type).(*types.Slice); ok {
+			// New slices have room for at least one element, as in the runtime
+			elems = append(make([]Value, 0, max(len(elems), 1)), elems...)
+		}
+		f.set(s.Result, &Slice{Elems: elems})
+
+	case *mir.ConstructTuple:
+		tuple := &Struct{Values: t.evalAll(f, s.Elements)}
+		for i := range s.Elements {
+			tuple.Names = append(tuple.Names, fmt.Sprint(i))
+		}
+		f.set(s.Result, tuple)
+
+	case *mir.ConstructEnum:
+		f.set(s.Result, &Enum{Type: s.Type, Variant: s.Variant, Tag: s.VariantIndex, Payload: t.evalAll(f, s.Values)})
+
+	case *mir.Discriminant:
+		f.set(s.Result, int64(t.enum(f, s.Target).Tag))
+
+	case *mir.AccessVariantPayload:
+		e := t.enum(f, s.Target)
+		if e.Tag != s.VariantIndex || s.MemberIndex >= len(e.Payload) {
+			t.errorf("%s::%s has no payload member %d of variant %d", e.Type, e.Variant, s.MemberIndex, s.VariantIndex)
+		}
+		f.set(s.Result, e.Payload[s.MemberIndex])
+
+	case *mir.MakeChannel:
+		capacity := int64(0)
+		if s.Capacity != nil {
+			capacity, _ = t.eval(f, s.Capacity).(int64)
+		}
+		var elem types.Type
+		if ch, ok := resolve(s.Type).(*types.Channel); ok {
+			elem = ch.Elem
+		}
+		f.set(s.Result, &Channel{ch: make(chan Value, max(capacity, 0)), elem: elem})
+
+	case *mir.Send:
+		t.send(t.channel(f, s.Channel), copyValue(s.Value.OperandType(), t.eval(f, s.Value)))
+
+	case *mir.Receive:
+		ch := t.channel(f, s.Channel)
+		v, ok := <-ch.ch
+		switch {
+		case ok:
+		case s.Optional:
+			v = nil
+		default:
+			v = zeroValue(ch.elem)
+		}
+		f.set(s.Result, v)
+
+	case *mir.SizeOf:
+		f.set(s.Result, sizeOf(s.Type))
+
+	case *mir.AlignOf:
+		f.set(s.Result, alignOf(s.Type))
+
+	case *mir.AddressOf:
+		f.set(s.Result, &Pointer{frame: f, index: s.Target.ID})
+
+	case *mir.PtrOffset:
+		p, ok := t.eval(f, s.Pointer).(*Pointer)
+		if !ok {
+			t.errorf("offset of a pointer the interpreter did not create")
+		}
+		offset, _ := t.eval(f, s.Offset).(int64)
+		f.set(s.Result, &Pointer{frame: p.frame, index: p.index + int(offset)})
+
+	case *mir.Cast:
+		f.set(s.Result, t.cast(t.eval(f, s.Operand), s.Operand.OperandType(), s.Type))
+
+	case *mir.MakeClosure:
+		f.set(s.Result, &Closure{Func: s.Func, Env: t.eval(f, s.Env)})
+
+	case *mir.RegisterDrop, *mir.CancelDrop:
+		// Finalizers run when the collector frees a value; nothing is
+		// collected here
+
+	case *mir.Phi:
+		t.errorf("phi node after the start of a block")
+
+	default:
+		t.errorf("unsupported statement %T", stmt)
+	}
+}
+
+func (t *thread) static(name string) *static {
+	st, ok := t.in.statics[name]
+	if !ok {
+		t.errorf("undefined static %s", name)
+	}
+	return st
+}
+
+// field returns the struct or tuple target refers to and the index of its
+// field name
+func (t *thread) field(f *frame, target mir.Operand, name string) (*Struct, int) {
+	obj, ok := deref(t.eval(f, target)).(*Struct)
+	if !ok {
+		if obj == nil {
+			t.panicf("field %s of a nil value", name)
+		}
+		t.errorf("field %s of a value that is not a struct", name)
+	}
+	i, ok := obj.field(name)
+	if !ok {
+		t.errorf("%s has no field %s", obj.Type, name)
+	}
+	return obj, i
+}
+
+// enum returns the enum value target refers to
+func (t *thread) enum(f *frame, target mir.Operand) *Enum {
+	e, ok := deref(t.eval(f, target)).(*Enum)
+	if !ok {
+		t.errorf("%s is not an enum value", target.OperandType())
+	}
+	return e
+}
+
+// constructStruct builds a struct with its fields in declaration order
+func (t *thread) constructStruct(f *frame, s *mir.ConstructStruct) Value {
+	if _, ok := resolve(s.Type).(*types.Map); ok {
+		// An empty map literal
+		return newMap()
+	}
+	obj := &Struct{Type: s.Type.String()}
+	if st, ok := resolve(s.Type).(*types.Struct); ok {
+		obj.Type = st.Name
+		for _, field := range st.Fields {
+			if _, ok := s.Fields[field.Name]; ok {
+				obj.Names = append(obj.Names, field.Name)
+			}
+		}
+	}
+	if len(obj.Names) != len(s.Fields) {
+		obj.Names = obj.Names[:0]
+		for name := range s.Fields {
+			obj.Names = append(obj.Names, name)
+		}
+		sort.Strings(obj.Names)
+	}
+	for _, name := range obj.Names {
+		op := s.Fields[name]
+		obj.Values = append(obj.Values, copyValue(op.OperandType(), t.eval(f, op)))
+	}
+	return obj
+}
+
+// index returns the integer value of an index operand
+func (t *thread) index(f *frame, op mir.Operand) int64 {
+	switch v := t.eval(f, op).(type) {
+	case int64:
+		return v
+	case *Enum:
+		return int64(v.Tag)
+	}
+	t.errorf("index of type %s is not an integer", op.OperandType())
+	return 0
+}
+
+// element checks that i indexes s and returns it as an int
+func (t *thread) element(s *Slice, i int64) int {
+	if i < 0 || i >= int64(len(s.Elems)) {
+		t.panicf("index out of bounds: the len is %d but the index is %d", len(s.Elems), i)
+	}
+	return int(i)
+}
+
+func (t *thread) loadIndex(f *frame, s *mir.LoadIndex) Value {
+	v := deref(t.eval(f, s.Target))
+	for _, idx := range s.Indices {
+		switch target := v.(type) {
+		case *Slice:
+			v = target.Elems[t.element(target, t.index(f, idx))]
+		case *Map:
+			v, _ = target.get(t.eval(f, idx))
+		case string:
+			i := t.index(f, idx)
+			if i < 0 || i >= int64(len(target)) {
+				t.panicf("index out of bounds: the len is %d but the index is %d", len(target), i)
+			}
+			v = int64(target[i])
+		case nil:
+			t.panicf("index of a nil value")
+		default:
+			t.errorf("index of a value of type %s", s.Target.OperandType())
+		}
+	}
+	return v
+}
+
+func (t *thread) storeIndex(f *frame, s *mir.StoreIndex) {
+	if len(s.Indices) == 0 {
+		t.errorf("store_index without an index")
+	}
+	v := deref(t.eval(f, s.Target))
+	for _, idx := range s.Indices[:len(s.Indices)-1] {
+		target, ok := v.(*Slice)
+		if !ok {
+			t.errorf("nested index of a value that is not a slice")
+		}
+		v = target.Elems[t.element(target, t.index(f, idx))]
+	}
+	last := s.Indices[len(s.Indices)-1]
+	value := copyValue(s.Value.OperandType(), t.eval(f, s.Value))
+	switch target := v.(type) {
+	case *Slice:
+		target.Elems[t.element(target, t.index(f, last))] = value
+	case *Map:
+		target.put(t.eval(f, last), value)
+	case nil:
+		t.panicf("index of a nil value")
+	default:
+		t.errorf("store into an index of a value of type %s", s.Target.OperandType())
+	}
+}
+
+// cast converts v, of type from, to the type to
+func (t *thread) cast(v Value, from, to types.Type) Value {
+	if e, ok := v.(*Enum); ok && primitiveKind(to) != "" && primitiveKind(to) != types.Nil {
+		// An enum converts to its tag
+		v = int64(e.Tag)
+	}
+	if bits, _, ok := intBits(to); ok && bits > 0 {
+		switch x := v.(type) {
+		case int64:
+			return normalize(to, x)
+		case float64:
+			return normalize(to, int64(x))
+		case bool:
+			if x {
+				return int64(1)
+			}
+			return int64(0)
+		}
+		return v
+	}
+	switch primitiveKind(to) {
+	case types.Float:
+		if x, ok := v.(int64); ok {
+			if _, unsigned, _ := intBits(from); unsigned {
+				return float64(uint64(x))
+			}
+			return float64(x)
+		}
+	case types.Bool:
+		if x, ok := v.(int64); ok {
+			return x != 0
+		}
+	}
+	return v
+}
@@ -0,0 +1,231 @@
+package interp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/codegen/mir2llvm"
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// lowerModule parses, checks, lowers and monomorphizes src
+func lowerModule(t *testing.T, src string) *mir.Module {
+	t.Helper()
+
+	p := parser.New(src)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	checker := types.NewChecker()
+	checker.Check(file)
+	if len(checker.Errors) > 0 {
+		t.Fatalf("type check errors: %v", checker.Errors)
+	}
+
+	lowerer := mir.NewLowerer(checker.ExprTypes, checker.CallTypeArgs, checker.GlobalScope, checker.MethodTable, checker.Modules)
+	lowerer.Moves = checker.Moves
+	lowerer.Consts = checker.Consts
+	module, err := lowerer.LowerModule(file)
+	if err != nil {
+		t.Fatalf("lowering error: %v", err)
+	}
+	if err := mir.NewMonomorphizer(module).Monomorphize(); err != nil {
+		t.Fatalf("monomorphization error: %v", err)
+	}
+	return module
+}
+
+// run interprets the program src and returns its output and exit status
+func run(t *testing.T, src string, overflow mir2llvm.OverflowMode) (stdout, stderr string, status int) {
+	t.Helper()
+
+	var out, errOut bytes.Buffer
+	in := New(lowerModule(t, src), Options{
+		Stdin:    strings.NewReader(""),
+		Stdout:   &out,
+		Stderr:   &errOut,
+		Args:     []string{"test.mal"},
+		Overflow: overflow,
+	})
+	status, err := in.Run()
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return out.String(), errOut.String(), status
+}
+
+func TestRunPrograms(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "structs and enums",
+			src: `package main;
+struct Point { x: int, y: int }
+enum Shape { Circle(int), Square(int) }
+fn area(s: Shape) -> int {
+    return match s {
+        Shape::Circle(r) => r * r * 3,
+        Shape::Square(w) => w * w,
+    };
+}
+fn main() {
+    let p = Point { x: 3, y: 4 };
+    println(p.x + p.y);
+    println(area(Shape::Circle(2)));
+    println(area(Shape::Square(5)));
+}
+`,
+			want: "7\n12\n25\n",
+		},
+		{
+			name: "closures and loops",
+			src: `package main;
+fn main() {
+    let base = 10;
+    let add = |x: int| x + base;
+    let mut total = 0;
+    let mut i = 0;
+    while i < 4 {
+        total = total + add(i);
+        i = i + 1;
+    }
+    println(total);
+    println(2.5);
+}
+`,
+			want: "46\n2.5\n",
+		},
+		{
+			name: "slices and maps",
+			src: `package main;
+fn main() {
+    let xs: []int = [10, 20, 30];
+    println(len(xs));
+    println(xs[2]);
+    let mut m = map[string, int]{};
+    m["a"] = 1;
+    m["b"] = 2;
+    for k in m {
+        println(k);
+    }
+    println(m["b"]);
+}
+`,
+			want: "3\n30\na\nb\n2\n",
+		},
+		{
+			name: "channels, select and spawn",
+			src: `package main;
+fn square(n: int) -> int { return n * n; }
+fn main() {
+    let c = Channel[int]::new(1);
+    c <- 7;
+    select {
+        case let v = <-c => { println(v); }
+    }
+    let h = spawn square(6);
+    println(h.join());
+}
+`,
+			want: "7\n36\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, status := run(t, tt.src, mir2llvm.OverflowWrap)
+			if status != 0 {
+				t.Fatalf("exit status %d, stderr:\n%s", status, stderr)
+			}
+			if stdout != tt.want {
+				t.Errorf("stdout = %q, want %q", stdout, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPanic(t *testing.T) {
+	stdout, stderr, status := run(t, `package main;
+fn get(xs: []int, i: int) -> int { return xs[i]; }
+fn main() {
+    let xs: []int = [1, 2, 3];
+    println("before");
+    println(get(xs, 5));
+}
+`, mir2llvm.OverflowWrap)
+
+	if status != PanicExitStatus {
+		t.Errorf("exit status = %d, want %d", status, PanicExitStatus)
+	}
+	if stdout != "before\n" {
+		t.Errorf("stdout = %q, want %q", stdout, "before\n")
+	}
+	want := "panic: index out of bounds: the len is 3 but the index is 5\nstack backtrace:\n  0: get\n  1: main\n"
+	if stderr != want {
+		t.Errorf("stderr = %q, want %q", stderr, want)
+	}
+}
+
+func TestRunOverflow(t *testing.T) {
+	src := `package main;
+fn main() {
+    let x: i8 = 127;
+    let y: i8 = x + 1;
+    println(y);
+}
+`
+	if stdout, _, _ := run(t, src, mir2llvm.OverflowWrap); stdout != "-128\n" {
+		t.Errorf("wrapping stdout = %q, want %q", stdout, "-128\n")
+	}
+	_, stderr, status := run(t, src, mir2llvm.OverflowPanic)
+	if status != PanicExitStatus || !strings.HasPrefix(stderr, "panic: integer overflow in add\n") {
+		t.Errorf("checked run exited %d with stderr %q, want an overflow panic", status, stderr)
+	}
+}
+
+func TestRunExitStatus(t *testing.T) {
+	_, _, status := run(t, `package main;
+fn main() -> int { return 3; }
+`, mir2llvm.OverflowWrap)
+	if status != 3 {
+		t.Errorf("exit status = %d, want 3", status)
+	}
+
+	_, stderr, status := run(t, `package main;
+enum Result[T, E] { Ok(T), Err(E) }
+fn main() -> Result[void, string] {
+    return Result[void, string]::Err("bad input");
+}
+`, mir2llvm.OverflowWrap)
+	if status != 1 || stderr != "Error: bad input\n" {
+		t.Errorf("Err from main exited %d with stderr %q, want 1 and %q", status, stderr, "Error: bad input\n")
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New(lowerModule(t, `package main;
+fn fib(n: int) -> int {
+    if n < 2 { return n; }
+    return fib(n - 1) + fib(n - 2);
+}
+fn main() {}
+`), Options{})
+
+	got, err := in.Call("fib", int64(20))
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if got != int64(6765) {
+		t.Errorf("fib(20) = %v, want 6765", got)
+	}
+	if _, err := in.Call("missing"); err == nil {
+		t.Error("expected an error calling an undefined function")
+	}
+}
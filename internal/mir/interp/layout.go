@@ -0,0 +1,114 @@
+package interp
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// pointerSize is the size of a pointer on the 64-bit targets the
+// interpreter reports sizes for
+const pointerSize = 8
+
+// sizeOf returns the size of t, as the LLVM backend lays it out
+func sizeOf(t types.Type) int64 {
+	switch typ := resolve(t).(type) {
+	case *types.Struct:
+		size, _ := fieldsLayout(fieldTypes(typ))
+		return size
+	case *types.Enum:
+		size, _ := enumLayout(typ)
+		return size
+	case *types.Slice:
+		return 24
+	case *types.Tuple:
+		return pointerSize
+	}
+	size, _ := slotLayout(t)
+	return size
+}
+
+// alignOf returns the alignment of t, as the LLVM backend lays it out
+func alignOf(t types.Type) int64 {
+	switch typ := resolve(t).(type) {
+	case *types.Struct:
+		_, align := fieldsLayout(fieldTypes(typ))
+		return align
+	case *types.Enum:
+		_, align := enumLayout(typ)
+		return align
+	}
+	_, align := slotLayout(t)
+	return align
+}
+
+func fieldTypes(s *types.Struct) []types.Type {
+	fields := make([]types.Type, len(s.Fields))
+	for i, f := range s.Fields {
+		fields[i] = f.Type
+	}
+	return fields
+}
+
+// slotLayout returns the size and alignment of a value of type t stored in
+// a field, an element or a local: structs, slices and the other heap values
+// are pointers there
+func slotLayout(t types.Type) (int64, int64) {
+	switch typ := resolve(t).(type) {
+	case *types.Primitive:
+		switch typ.Kind {
+		case types.Int8, types.U8, types.Bool:
+			return 1, 1
+		case types.U16:
+			return 2, 2
+		case types.Int32, types.U32:
+			return 4, 4
+		case types.U128:
+			return 16, 16
+		}
+		return 8, 8
+	case *types.Array:
+		size, align := slotLayout(typ.Elem)
+		return size * typ.Len, align
+	case *types.Tuple:
+		return fieldsLayout(typ.Elements)
+	case *types.Enum:
+		if typ.Fieldless() {
+			return enumLayout(typ)
+		}
+	}
+	return pointerSize, pointerSize
+}
+
+// fieldsLayout returns the size and alignment of a struct with fields of
+// the given types
+func fieldsLayout(fields []types.Type) (int64, int64) {
+	var size, align int64 = 0, 1
+	for _, f := range fields {
+		fsize, falign := slotLayout(f)
+		size = roundUp(size, falign) + fsize
+		align = max(align, falign)
+	}
+	return roundUp(size, align), align
+}
+
+// enumLayout returns the size and alignment of an enum: its tag alone if no
+// variant has a payload, and otherwise the tag followed by the bytes of the
+// largest payload
+func enumLayout(e *types.Enum) (int64, int64) {
+	tagSize, tagAlign := int64(4), int64(4)
+	if e.Repr != nil {
+		tagSize, tagAlign = slotLayout(e.Repr)
+	}
+	if e.Fieldless() {
+		return tagSize, tagAlign
+	}
+	var payload int64
+	for _, v := range e.Variants {
+		size, _ := fieldsLayout(v.Params)
+		payload = max(payload, size)
+	}
+	return roundUp(tagSize+payload, tagAlign), tagAlign
+}
+
+func roundUp(n, align int64) int64 {
+	return (n + align - 1) / align * align
+}
@@ -0,0 +1,159 @@
+package interp
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// spawn starts a legion running s.Func
+func (t *thread) spawn(f *frame, s *mir.Spawn) *JoinHandle {
+	fn, ok := t.in.funcs[s.Func]
+	if !ok {
+		t.errorf("spawn of undefined function %s", s.Func)
+	}
+	args := t.evalAll(f, s.Args)
+	handle := &JoinHandle{done: make(chan struct{})}
+	in := t.in
+	go func() {
+		err := in.guard(func() {
+			handle.result = (&thread{in: in}).call(fn, args)
+		})
+		if err != nil {
+			in.fail(err)
+			return
+		}
+		close(handle.done)
+	}()
+	return handle
+}
+
+// channel returns the channel op refers to
+func (t *thread) channel(f *frame, op mir.Operand) *Channel {
+	ch, ok := deref(t.eval(f, op)).(*Channel)
+	if !ok {
+		t.errorf("%s is not a channel", op.OperandType())
+	}
+	return ch
+}
+
+// send sends v on ch, panicking like the runtime if ch is closed
+func (t *thread) send(ch *Channel, v Value) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.panicf("send on closed channel")
+		}
+	}()
+	ch.ch <- v
+}
+
+// trySend sends v on ch if it does not block
+func (t *thread) trySend(ch *Channel, v Value) (sent bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.panicf("send on closed channel")
+		}
+	}()
+	select {
+	case ch.ch <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *thread) close(ch *Channel) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.closed {
+		t.panicf("close of closed channel")
+	}
+	ch.closed = true
+	close(ch.ch)
+}
+
+// selectCase runs a select: the first ready case in order, otherwise the
+// default case, otherwise the first case to become ready or time out
+func (t *thread) selectCase(f *frame, s *mir.Select) *mir.BasicBlock {
+	var fallback *mir.BasicBlock
+	hasDefault := false
+	values := make([]Value, len(s.Cases))
+	for i, c := range s.Cases {
+		switch c.Kind {
+		case "send":
+			values[i] = copyValue(c.Value.OperandType(), t.eval(f, c.Value))
+			if t.trySend(t.channel(f, c.Channel), values[i]) {
+				return c.Target
+			}
+		case "recv":
+			ch := t.channel(f, c.Channel)
+			select {
+			case v, ok := <-ch.ch:
+				t.received(f, c, ch, v, ok)
+				return c.Target
+			default:
+			}
+		case "default":
+			fallback, hasDefault = c.Target, true
+		case "after":
+		default:
+			t.errorf("unsupported select case %q", c.Kind)
+		}
+	}
+	if hasDefault {
+		return fallback
+	}
+
+	cases := make([]reflect.SelectCase, len(s.Cases))
+	for i, c := range s.Cases {
+		switch c.Kind {
+		case "send":
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(t.channel(f, c.Channel).ch), Send: reflect.ValueOf(&values[i]).Elem()}
+		case "recv":
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.channel(f, c.Channel).ch)}
+		case "after":
+			ms, _ := t.eval(f, c.Timeout).(int64)
+			timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+			defer timer.Stop()
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)}
+		}
+	}
+	chosen, v, ok := t.reflectSelect(cases)
+	c := s.Cases[chosen]
+	if c.Kind == "recv" {
+		var value Value
+		if ok {
+			value = v.Interface()
+		}
+		t.received(f, c, t.channel(f, c.Channel), value, ok)
+	}
+	return c.Target
+}
+
+// reflectSelect blocks in a select on cases, panicking like the runtime if
+// it sends on a closed channel
+func (t *thread) reflectSelect(cases []reflect.SelectCase) (chosen int, v reflect.Value, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.panicf("send on closed channel")
+		}
+	}()
+	return reflect.Select(cases)
+}
+
+// received stores the value a recv case received, the zero value if the
+// channel is closed
+func (t *thread) received(f *frame, c mir.SelectCase, ch *Channel, v Value, ok bool) {
+	if c.Result == nil {
+		return
+	}
+	if !ok {
+		v = zeroValue(ch.elem)
+		if _, opt := resolve(c.Result.Type).(*types.Optional); opt {
+			v = nil
+		}
+	}
+	f.set(*c.Result, v)
+}
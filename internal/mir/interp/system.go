@@ -0,0 +1,436 @@
+package interp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// monotonicStart is the start of the monotonic clock of __time_monotonic_ns__
+var monotonicStart = time.Now()
+
+// handles holds what the runtime's intrinsics refer to by integer handles:
+// string builders, file descriptors, line readers and synchronization words
+type handles struct {
+	mu       sync.Mutex
+	next     int64
+	builders map[int64]*strings.Builder
+	files    map[int64]*file
+	readers  map[int64]*bufio.Reader
+	words    map[int64]*atomic.Int64
+	nextFD   int64
+}
+
+// file is an open file descriptor: a file, a connection or a listener
+type file struct {
+	r        io.Reader
+	w        io.Writer
+	c        io.Closer
+	listener net.Listener
+	conn     net.Conn
+}
+
+func (h *handles) init(in *Interpreter) {
+	h.next = 1
+	h.builders = make(map[int64]*strings.Builder)
+	h.readers = make(map[int64]*bufio.Reader)
+	h.words = make(map[int64]*atomic.Int64)
+	// Descriptors 0, 1 and 2 are the standard streams; writes to them are
+	// serialized with println
+	h.files = map[int64]*file{
+		0: {r: in.opts.Stdin},
+		1: {w: lockedWriter{in, in.opts.Stdout}},
+		2: {w: lockedWriter{in, in.opts.Stderr}},
+	}
+	h.nextFD = 3
+}
+
+type lockedWriter struct {
+	in *Interpreter
+	w  io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.in.out.Lock()
+	defer l.in.out.Unlock()
+	return l.w.Write(p)
+}
+
+// add registers a value in one of the handle tables and returns its handle
+func add[T any](h *handles, table map[int64]T, v T) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := h.next
+	h.next++
+	table[id] = v
+	return id
+}
+
+func lookup[T any](h *handles, table map[int64]T, id int64) (T, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok := table[id]
+	return v, ok
+}
+
+func (h *handles) open(f *file) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fd := h.nextFD
+	h.nextFD++
+	h.files[fd] = f
+	return fd
+}
+
+// errno returns the negated error number of err, as the runtime's
+// functions report failures
+func errno(err error) int64 {
+	var e syscall.Errno
+	switch {
+	case errors.As(err, &e):
+		return -int64(e)
+	case errors.Is(err, fs.ErrNotExist):
+		return -int64(syscall.ENOENT)
+	case errors.Is(err, fs.ErrPermission):
+		return -int64(syscall.EACCES)
+	case errors.Is(err, net.ErrClosed), errors.Is(err, fs.ErrClosed):
+		return -int64(syscall.EBADF)
+	}
+	return -int64(syscall.EIO)
+}
+
+// intrinsic runs one of the intrinsics of types.LookupIntrinsic
+func (t *thread) intrinsic(name string, args []Value) Value {
+	for i, arg := range args {
+		args[i] = deref(arg)
+	}
+	str := func(i int) string {
+		s, _ := args[i].(string)
+		return s
+	}
+	num := func(i int) int64 {
+		n, _ := args[i].(int64)
+		return n
+	}
+	h := &t.in.handles
+
+	switch name {
+	case "__string_len__":
+		return int64(len(str(0)))
+	case "__string_concat__":
+		return str(0) + str(1)
+	case "__string_substring__":
+		s, start, end := str(0), max(num(1), 0), min(num(2), int64(len(str(0))))
+		if start >= end {
+			return ""
+		}
+		return s[start:end]
+	case "__string_index_of__":
+		return int64(strings.Index(str(0), str(1)))
+	case "__string_compare__":
+		return int64(strings.Compare(str(0), str(1)))
+	case "__string_to_upper__":
+		return mapASCII(str(0), 'a', 'z', 'A'-'a')
+	case "__string_to_lower__":
+		return mapASCII(str(0), 'A', 'Z', 'a'-'A')
+	case "__string_trim__":
+		return strings.Trim(str(0), " \t\n\r")
+	case "__string_from_int__":
+		return strconv.FormatInt(num(0), 10)
+
+	case "__string_builder_new__":
+		b := &strings.Builder{}
+		b.Grow(int(max(num(0), 16)))
+		return add(h, h.builders, b)
+	case "__string_builder_append__":
+		t.builder(num(0)).WriteString(str(1))
+		return nil
+	case "__string_builder_len__":
+		return int64(t.builder(num(0)).Len())
+	case "__string_builder_build__":
+		return t.builder(num(0)).String()
+	case "__string_builder_clear__":
+		t.builder(num(0)).Reset()
+		return nil
+
+	case "__args_count__":
+		return int64(len(t.in.opts.Args))
+	case "__args_get__":
+		if i := num(0); i >= 0 && i < int64(len(t.in.opts.Args)) {
+			return t.in.opts.Args[i]
+		}
+		return ""
+	case "__env_get__":
+		if v, ok := os.LookupEnv(str(0)); ok {
+			return v
+		}
+		return nil
+	case "__env_set__":
+		if os.Setenv(str(0), str(1)) != nil {
+			return int64(-1)
+		}
+		return int64(0)
+
+	case "__time_monotonic_ns__":
+		return int64(time.Since(monotonicStart))
+	case "__time_wall_ns__":
+		return time.Now().UnixNano()
+	case "__time_sleep_ns__":
+		time.Sleep(time.Duration(num(0)))
+		return nil
+
+	case "__fs_open__":
+		flags := map[int64]int{
+			0: os.O_RDONLY,
+			1: os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+			2: os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+		}
+		flag, ok := flags[num(1)]
+		if !ok {
+			return -int64(syscall.EINVAL)
+		}
+		f, err := os.OpenFile(str(0), flag, 0o644)
+		if err != nil {
+			return errno(err)
+		}
+		return h.open(&file{r: f, w: f, c: f})
+	case "__fs_close__":
+		h.mu.Lock()
+		f, ok := h.files[num(0)]
+		delete(h.files, num(0))
+		h.mu.Unlock()
+		if !ok {
+			return -int64(syscall.EBADF)
+		}
+		if f.c != nil {
+			if err := f.c.Close(); err != nil {
+				return errno(err)
+			}
+		}
+		return int64(0)
+	case "__fs_write__":
+		f, ok := lookup(h, h.files, num(0))
+		if !ok || f.w == nil {
+			return -int64(syscall.EBADF)
+		}
+		n, err := io.WriteString(f.w, str(1))
+		if err != nil {
+			return errno(err)
+		}
+		return int64(n)
+	case "__fs_read_all__":
+		t.lastError = 0
+		f, ok := lookup(h, h.files, num(0))
+		if !ok || f.r == nil {
+			t.lastError = int64(syscall.EBADF)
+			return ""
+		}
+		data, err := io.ReadAll(f.r)
+		if err != nil {
+			t.lastError = -errno(err)
+			return ""
+		}
+		return string(data)
+	case "__fs_last_error__":
+		return t.lastError
+	case "__fs_strerror__":
+		msg := syscall.Errno(num(0)).Error()
+		if msg == "" {
+			return msg
+		}
+		// As C's strerror spells it
+		return strings.ToUpper(msg[:1]) + msg[1:]
+	case "__fs_reader_new__":
+		var r io.Reader = strings.NewReader("")
+		if f, ok := lookup(h, h.files, num(0)); ok && f.r != nil {
+			r = f.r
+		}
+		return add(h, h.readers, bufio.NewReader(r))
+	case "__fs_reader_read_line__":
+		t.lastError = 0
+		line, err := t.reader(num(0)).ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err != io.EOF {
+				t.lastError = -errno(err)
+			}
+			return nil
+		}
+		line = strings.TrimSuffix(line, "\n")
+		return strings.TrimSuffix(line, "\r")
+	case "__fs_reader_read__":
+		t.lastError = 0
+		if num(1) <= 0 {
+			return ""
+		}
+		buf := make([]byte, num(1))
+		n, err := t.reader(num(0)).Read(buf)
+		if err != nil && err != io.EOF {
+			t.lastError = -errno(err)
+			return ""
+		}
+		return string(buf[:n])
+
+	case "__net_listen__":
+		if num(1) < 0 || num(1) > 65535 {
+			return -int64(syscall.EINVAL)
+		}
+		ln, err := net.Listen("tcp", net.JoinHostPort(str(0), strconv.FormatInt(num(1), 10)))
+		if err != nil {
+			return errno(err)
+		}
+		return h.open(&file{c: ln, listener: ln})
+	case "__net_accept__":
+		f, ok := lookup(h, h.files, num(0))
+		if !ok || f.listener == nil {
+			return -int64(syscall.EBADF)
+		}
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return errno(err)
+		}
+		return h.open(&file{r: conn, w: conn, c: conn, conn: conn})
+	case "__net_connect__":
+		if num(1) < 0 || num(1) > 65535 {
+			return -int64(syscall.EINVAL)
+		}
+		conn, err := net.Dial("tcp", net.JoinHostPort(str(0), strconv.FormatInt(num(1), 10)))
+		if err != nil {
+			return errno(err)
+		}
+		return h.open(&file{r: conn, w: conn, c: conn, conn: conn})
+	case "__net_local_port__":
+		f, ok := lookup(h, h.files, num(0))
+		var addr net.Addr
+		switch {
+		case !ok:
+			return -int64(syscall.EBADF)
+		case f.listener != nil:
+			addr = f.listener.Addr()
+		case f.conn != nil:
+			addr = f.conn.LocalAddr()
+		default:
+			return -int64(syscall.ENOTSOCK)
+		}
+		if tcp, ok := addr.(*net.TCPAddr); ok {
+			return int64(tcp.Port)
+		}
+		return -int64(syscall.ENOTSOCK)
+
+	// Mutexes and read-write locks are words, as in the runtime: a mutex is
+	// 1 while locked, a lock -1 while write-locked and otherwise the number
+	// of readers
+	case "__mutex_new__", "__rwlock_new__":
+		return add(h, h.words, &atomic.Int64{})
+	case "__mutex_try_lock__":
+		if t.word(num(0)).CompareAndSwap(0, 1) {
+			return int64(1)
+		}
+		return int64(0)
+	case "__mutex_lock__":
+		for w := t.word(num(0)); !w.CompareAndSwap(0, 1); {
+			runtime.Gosched()
+		}
+		return nil
+	case "__mutex_unlock__", "__rwlock_write_unlock__":
+		t.word(num(0)).Store(0)
+		return nil
+	case "__rwlock_read_lock__":
+		w := t.word(num(0))
+		for {
+			if readers := w.Load(); readers >= 0 && w.CompareAndSwap(readers, readers+1) {
+				return nil
+			}
+			runtime.Gosched()
+		}
+	case "__rwlock_read_unlock__":
+		t.word(num(0)).Add(-1)
+		return nil
+	case "__rwlock_write_lock__":
+		for w := t.word(num(0)); !w.CompareAndSwap(0, -1); {
+			runtime.Gosched()
+		}
+		return nil
+	case "__atomic_new__":
+		w := &atomic.Int64{}
+		w.Store(num(0))
+		return add(h, h.words, w)
+	case "__atomic_load__":
+		return t.word(num(0)).Load()
+	case "__atomic_store__":
+		t.word(num(0)).Store(num(1))
+		return nil
+	case "__atomic_fetch_add__":
+		return t.word(num(0)).Add(num(1)) - num(1)
+	case "__atomic_fetch_sub__":
+		return t.word(num(0)).Add(-num(1)) + num(1)
+	case "__atomic_swap__":
+		return t.word(num(0)).Swap(num(1))
+	case "__atomic_compare_exchange__":
+		if t.word(num(0)).CompareAndSwap(num(1), num(2)) {
+			return int64(1)
+		}
+		return int64(0)
+
+	case "__slice_len__", "__slice_cap__":
+		s, ok := args[0].(*Slice)
+		if !ok {
+			return int64(0)
+		}
+		if name == "__slice_cap__" {
+			return int64(cap(s.Elems))
+		}
+		return int64(len(s.Elems))
+	case "__map_len__":
+		if m, ok := args[0].(*Map); ok {
+			return int64(m.len())
+		}
+		return int64(0)
+	}
+	t.errorf("intrinsic %s is not supported", name)
+	return nil
+}
+
+// mapASCII shifts the ASCII letters from lo to hi by delta
+func mapASCII(s string, lo, hi byte, delta int) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= lo && c <= hi {
+			b[i] = byte(int(c) + delta)
+		}
+	}
+	return string(b)
+}
+
+func (t *thread) builder(id int64) *strings.Builder {
+	b, ok := lookup(&t.in.handles, t.in.handles.builders, id)
+	if !ok {
+		t.errorf("invalid string builder handle %d", id)
+	}
+	return b
+}
+
+func (t *thread) reader(id int64) *bufio.Reader {
+	r, ok := lookup(&t.in.handles, t.in.handles.readers, id)
+	if !ok {
+		t.errorf("invalid reader handle %d", id)
+	}
+	return r
+}
+
+func (t *thread) word(id int64) *atomic.Int64 {
+	w, ok := lookup(&t.in.handles, t.in.handles.words, id)
+	if !ok {
+		t.errorf("invalid synchronization handle %d", id)
+	}
+	return w
+}
@@ -0,0 +1,356 @@
+package interp
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// Value is a Malphas value at run time:
+//
+//   - int64 for every integer type, kept within the range of its type
+//   - float64, bool and string
+//   - nil for nil optionals and pointers and for void
+//   - *Struct for structs and tuples, *Enum for enums
+//   - *Slice for slices and arrays, *Map, *Channel, *Closure
+//   - *Pointer for the address of a local, *JoinHandle for a spawned call
+//
+// An optional holding a value is the value itself: T? is a pointer to T in
+// the LLVM backend, and loading through it yields the T.
+type Value = any
+
+// Struct is a struct or tuple value. Structs live on the heap in the LLVM
+// backend, so a *Struct is shared by every copy of it. The fields of a tuple
+// are named "0", "1", ...
+type Struct struct {
+	Type   string
+	Names  []string
+	Values []Value
+}
+
+func (s *Struct) field(name string) (int, bool) {
+	for i, n := range s.Names {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Enum is an enum value: the index of its variant and the variant's payload.
+type Enum struct {
+	Type    string
+	Variant string
+	Tag     int
+	Payload []Value
+}
+
+// Slice is a slice, or an array. A slice value is a shared header, like the
+// runtime's Slice: pushing onto it is seen through every copy of it. Arrays
+// are values and are copied whenever they are assigned or passed.
+type Slice struct {
+	Elems []Value // len(Elems) is the slice's length, cap(Elems) its capacity
+}
+
+// Map is a map value. Its keys are kept in insertion order, so iterating a
+// map is deterministic (the runtime's hash order is not specified).
+type Map struct {
+	mu      sync.Mutex
+	entries map[Value]Value // by mapKey
+	keys    []Value
+}
+
+// enumKey is the map key of a fieldless enum value, which the runtime hashes
+// by its tag
+type enumKey struct {
+	typ string
+	tag int
+}
+
+// mapKey returns the Go map key of the Malphas map key v
+func mapKey(v Value) Value {
+	if e, ok := v.(*Enum); ok && len(e.Payload) == 0 {
+		return enumKey{e.Type, e.Tag}
+	}
+	return v
+}
+
+func newMap() *Map {
+	return &Map{entries: make(map[Value]Value)}
+}
+
+func (m *Map) get(key Value) (Value, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.entries[mapKey(key)]
+	return v, ok
+}
+
+func (m *Map) put(key, value Value) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := mapKey(key)
+	if _, ok := m.entries[k]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[k] = value
+}
+
+func (m *Map) remove(key Value) (Value, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := mapKey(key)
+	v, ok := m.entries[k]
+	if !ok {
+		return nil, false
+	}
+	delete(m.entries, k)
+	for i, existing := range m.keys {
+		if mapKey(existing) == k {
+			m.keys = append(m.keys[:i:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return v, true
+}
+
+func (m *Map) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.keys)
+}
+
+// snapshot returns the keys and values of m in insertion order
+func (m *Map) snapshot() (keys, values []Value) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys = append([]Value(nil), m.keys...)
+	for _, k := range keys {
+		values = append(values, m.entries[mapKey(k)])
+	}
+	return keys, values
+}
+
+// Channel is a channel value, backed by a Go channel.
+type Channel struct {
+	ch     chan Value
+	elem   types.Type // for the zero value received once it is closed
+	mu     sync.Mutex
+	closed bool
+}
+
+// Closure is a closure value: the function and the environment it is called
+// with as its first argument.
+type Closure struct {
+	Func string
+	Env  Value
+}
+
+// Pointer is the address of a local, taken by AddressOf and moved by
+// PtrOffset.
+type Pointer struct {
+	frame *frame
+	index int
+}
+
+// JoinHandle is the handle of a spawned call; Join waits for done.
+type JoinHandle struct {
+	done   chan struct{}
+	result Value
+}
+
+// resolve returns the type a named type refers to, and the generic type of
+// an instance
+func resolve(t types.Type) types.Type {
+	for {
+		switch typ := t.(type) {
+		case *types.Named:
+			if typ.Ref == nil {
+				return t
+			}
+			t = typ.Ref
+		case *types.GenericInstance:
+			t = typ.Base
+		default:
+			return t
+		}
+	}
+}
+
+// primitiveKind returns the kind of t if it is a primitive type, or ""
+func primitiveKind(t types.Type) types.PrimitiveKind {
+	if p, ok := resolve(t).(*types.Primitive); ok {
+		return p.Kind
+	}
+	if n, ok := t.(*types.Named); ok {
+		// Types parsed from MIR text name their primitives
+		return types.PrimitiveKind(n.Name)
+	}
+	return ""
+}
+
+// intBits returns the width of the integer type t and whether it is
+// unsigned; ok is false if t is not an integer type
+func intBits(t types.Type) (bits uint, unsigned, ok bool) {
+	switch primitiveKind(t) {
+	case types.Int, types.Int64:
+		return 64, false, true
+	case types.Int8:
+		return 8, false, true
+	case types.Int32:
+		return 32, false, true
+	case types.U8:
+		return 8, true, true
+	case types.U16:
+		return 16, true, true
+	case types.U32:
+		return 32, true, true
+	case types.U64, types.Usize, types.U128:
+		// u128 is computed on 64 bits
+		return 64, true, true
+	}
+	return 0, false, false
+}
+
+// normalize brings the integer v into the range of the type t, wrapping
+// like two's complement arithmetic does
+func normalize(t types.Type, v int64) int64 {
+	bits, unsigned, ok := intBits(t)
+	if !ok || bits == 64 {
+		return v
+	}
+	shift := 64 - bits
+	if unsigned {
+		return int64(uint64(v) << shift >> shift)
+	}
+	return v << shift >> shift
+}
+
+// zeroValue returns the zero value of t, as received from a closed channel
+func zeroValue(t types.Type) Value {
+	switch primitiveKind(t) {
+	case types.Int, types.Int8, types.Int32, types.Int64,
+		types.U8, types.U16, types.U32, types.U64, types.U128, types.Usize:
+		return int64(0)
+	case types.Float:
+		return float64(0)
+	case types.Bool:
+		return false
+	case types.String:
+		return ""
+	}
+	if e, ok := resolve(t).(*types.Enum); ok && e.Fieldless() && len(e.Variants) > 0 {
+		return &Enum{Type: e.Name, Variant: e.Variants[0].Name}
+	}
+	return nil
+}
+
+// copyValue copies v if its type t is an array type, so that the copy and
+// the original no longer share elements
+func copyValue(t types.Type, v Value) Value {
+	arr, ok := resolve(t).(*types.Array)
+	if !ok {
+		return v
+	}
+	s, ok := v.(*Slice)
+	if !ok {
+		return v
+	}
+	elems := make([]Value, len(s.Elems))
+	for i, elem := range s.Elems {
+		elems[i] = copyValue(arr.Elem, elem)
+	}
+	return &Slice{Elems: elems}
+}
+
+// equal reports whether two values are equal. Strings and enums compare by
+// contents; structs, slices and the other heap values by identity.
+func equal(a, b Value) bool {
+	switch x := a.(type) {
+	case *Enum:
+		y, ok := b.(*Enum)
+		if !ok || x.Type != y.Type || x.Tag != y.Tag || len(x.Payload) != len(y.Payload) {
+			return false
+		}
+		for i := range x.Payload {
+			if !equal(x.Payload[i], y.Payload[i]) {
+				return false
+			}
+		}
+		return true
+	case *Pointer:
+		y, ok := b.(*Pointer)
+		return ok && *x == *y
+	}
+	return a == b
+}
+
+// formatValue formats v as println prints it
+func formatValue(v Value) string {
+	switch x := v.(type) {
+	case nil:
+		return "nil"
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return formatFloat(x)
+	case bool:
+		return strconv.FormatBool(x)
+	case string:
+		return x
+	case *Enum:
+		if len(x.Payload) == 0 {
+			return x.Variant
+		}
+		parts := make([]string, len(x.Payload))
+		for i, p := range x.Payload {
+			parts[i] = formatValue(p)
+		}
+		return x.Variant + "(" + strings.Join(parts, ", ") + ")"
+	case *Struct:
+		parts := make([]string, len(x.Values))
+		for i, f := range x.Values {
+			parts[i] = formatValue(f)
+		}
+		return x.Type + "(" + strings.Join(parts, ", ") + ")"
+	case *Slice:
+		parts := make([]string, len(x.Elems))
+		for i, e := range x.Elems {
+			parts[i] = formatValue(e)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	}
+	return "<" + typeName(v) + ">"
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case *Map:
+		return "map"
+	case *Channel:
+		return "channel"
+	case *Closure:
+		return "closure"
+	case *Pointer:
+		return "pointer"
+	case *JoinHandle:
+		return "join handle"
+	}
+	return "value"
+}
+
+// formatFloat formats f like C's "%g", as the runtime prints floats
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
@@ -0,0 +1,108 @@
+package mir
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// captureScope collects the variables a function literal uses from the
+// functions around it. Each closure carries its own environment struct with
+// a field per captured variable, copied when the closure is made, and the
+// closure's function receives a pointer to it as its first parameter. Two
+// closures made from the same literal therefore never share state.
+type captureScope struct {
+	parent *captureScope // scope of the enclosing literal, if any
+
+	fn    *Function        // function of the literal
+	outer map[string]Local // variables of the enclosing function
+	env   Local            // environment parameter of fn
+
+	names  []string         // captured variables, in order of first use
+	values map[string]Local // variable of the enclosing function for each
+	loads  map[string]Local // local of fn holding each captured value
+}
+
+func newCaptureScope(parent *captureScope, fn *Function, outer map[string]Local, env Local) *captureScope {
+	return &captureScope{
+		parent: parent,
+		fn:     fn,
+		outer:  outer,
+		env:    env,
+		values: make(map[string]Local),
+		loads:  make(map[string]Local),
+	}
+}
+
+// lookupLocal returns the variable name refers to: a local of the current
+// function, or a variable of an enclosing function captured by the current
+// function literal.
+func (l *Lowerer) lookupLocal(name string) (Local, bool) {
+	if local, ok := l.locals[name]; ok {
+		return local, true
+	}
+	if l.captures == nil {
+		return Local{}, false
+	}
+	local, ok := l.capture(l.captures, name)
+	if ok {
+		l.locals[name] = local
+	}
+	return local, ok
+}
+
+// capture returns the local of s.fn holding the captured variable name,
+// capturing it in the enclosing literals too if it belongs to a function
+// further out.
+func (l *Lowerer) capture(s *captureScope, name string) (Local, bool) {
+	if local, ok := s.loads[name]; ok {
+		return local, true
+	}
+	value, ok := s.outer[name]
+	if !ok && s.parent != nil {
+		value, ok = l.capture(s.parent, name)
+		if ok {
+			s.outer[name] = value
+		}
+	}
+	if !ok {
+		return Local{}, false
+	}
+	local := l.newLocal(name, value.Type)
+	s.fn.Locals = append(s.fn.Locals, local)
+	s.names = append(s.names, name)
+	s.values[name] = value
+	s.loads[name] = local
+	return local, true
+}
+
+// loadCaptures prepends the loads of the captured variables from the
+// environment to the entry block of s.fn, so that they dominate every use.
+func (s *captureScope) loadCaptures() {
+	loads := make([]Statement, 0, len(s.names)+len(s.fn.Entry.Statements))
+	for _, name := range s.names {
+		loads = append(loads, &LoadField{
+			Result: s.loads[name],
+			Target: &LocalRef{Local: s.env},
+			Field:  name,
+		})
+	}
+	s.fn.Entry.Statements = append(loads, s.fn.Entry.Statements...)
+}
+
+// envFields returns the fields of the environment struct
+func (s *captureScope) envFields() []types.Field {
+	fields := make([]types.Field, len(s.names))
+	for i, name := range s.names {
+		fields[i] = types.Field{Name: name, Type: s.values[name].Type}
+	}
+	return fields
+}
+
+// envValues returns the values of the enclosing function to store in the
+// environment when the closure is made
+func (s *captureScope) envValues() map[string]Operand {
+	values := make(map[string]Operand, len(s.names))
+	for _, name := range s.names {
+		values[name] = &LocalRef{Local: s.values[name]}
+	}
+	return values
+}
@@ -0,0 +1,152 @@
+package mir
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// dropState tracks the variables of the function being lowered whose values
+// are dropped when their scope exits.
+type dropState struct {
+	// scopes holds the variables declared in each open block, innermost
+	// last. The first scope holds the function's parameters.
+	scopes [][]dropVar
+	// flags maps the ID of each tracked variable to its drop flag
+	flags map[int]Local
+}
+
+// dropVar is a variable of a type with a Drop impl. Its drop flag is a bool
+// that is cleared when the value is moved out, so that it is only dropped
+// by its new owner.
+type dropVar struct {
+	value Local
+	flag  Local
+}
+
+func newDropState() *dropState {
+	return &dropState{
+		scopes: [][]dropVar{nil},
+		flags:  make(map[int]Local),
+	}
+}
+
+// pushDropScope opens the drop scope of a block.
+func (l *Lowerer) pushDropScope() {
+	l.drops.scopes = append(l.drops.scopes, nil)
+}
+
+// popDropScope closes the innermost drop scope, dropping its variables if
+// control falls through the end of the block.
+func (l *Lowerer) popDropScope() {
+	n := len(l.drops.scopes)
+	scope := l.drops.scopes[n-1]
+	l.drops.scopes = l.drops.scopes[:n-1]
+	if l.currentBlock.Terminator == nil {
+		l.emitDrops(scope)
+	}
+}
+
+// dropScopesFrom drops the variables of the scopes at depth and above,
+// innermost first, before a return, break or continue leaves them.
+func (l *Lowerer) dropScopesFrom(depth int) {
+	for i := len(l.drops.scopes) - 1; i >= depth; i-- {
+		l.emitDrops(l.drops.scopes[i])
+	}
+}
+
+// trackDrop makes the innermost scope drop local when it exits, if its type
+// has a Drop impl.
+func (l *Lowerer) trackDrop(local Local) {
+	if !types.NeedsDrop(local.Type) {
+		return
+	}
+	flag := l.newLocal("", &types.Primitive{Kind: types.Bool})
+	l.currentFunc.Locals = append(l.currentFunc.Locals, flag)
+	l.drops.flags[local.ID] = flag
+	n := len(l.drops.scopes)
+	l.drops.scopes[n-1] = append(l.drops.scopes[n-1], dropVar{value: local, flag: flag})
+	l.setDropFlag(local, true)
+}
+
+// markMoved clears the drop flag of local if ident moves its value out.
+func (l *Lowerer) markMoved(ident *ast.Ident, local Local) {
+	if l.Moves[ident] {
+		l.setDropFlag(local, false)
+	}
+}
+
+// setDropFlag sets the drop flag of local, if it has one.
+func (l *Lowerer) setDropFlag(local Local, live bool) {
+	flag, ok := l.drops.flags[local.ID]
+	if !ok {
+		return
+	}
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &Assign{
+		Local: flag,
+		RHS:   &Literal{Type: &types.Primitive{Kind: types.Bool}, Value: live},
+	})
+}
+
+// dropBeforeAssign drops the value local holds before it is overwritten.
+func (l *Lowerer) dropBeforeAssign(local Local) {
+	if flag, ok := l.drops.flags[local.ID]; ok {
+		l.emitDrop(dropVar{value: local, flag: flag})
+	}
+}
+
+// emitDrops drops vars in the reverse of their declaration order.
+func (l *Lowerer) emitDrops(vars []dropVar) {
+	for i := len(vars) - 1; i >= 0; i-- {
+		l.emitDrop(vars[i])
+	}
+}
+
+// emitDrop calls the drop method of v's value if its drop flag is set. The
+// finalizer registered for the value is cancelled first, so the garbage
+// collector does not drop it again.
+func (l *Lowerer) emitDrop(v dropVar) {
+	dropBlock := l.newBlock("")
+	next := l.newBlock("")
+	l.currentFunc.Blocks = append(l.currentFunc.Blocks, dropBlock, next)
+	l.currentBlock.Terminator = &Branch{
+		Condition: &LocalRef{Local: v.flag},
+		True:      dropBlock,
+		False:     next,
+	}
+
+	l.currentBlock = dropBlock
+	value := &LocalRef{Local: v.value}
+	result := l.newLocal("", &types.Primitive{Kind: types.Void})
+	l.currentFunc.Locals = append(l.currentFunc.Locals, result)
+	funcName, typeArgs := l.dropMethod(v.value.Type)
+	l.currentBlock.Statements = append(l.currentBlock.Statements,
+		&CancelDrop{Value: value},
+		&Call{Result: result, Func: funcName, Args: []Operand{value}, TypeArgs: typeArgs},
+	)
+	l.currentBlock.Terminator = &Goto{Target: next}
+	l.currentBlock = next
+}
+
+// registerDrop registers the drop method of a newly constructed value as its
+// finalizer, if its type has a Drop impl.
+func (l *Lowerer) registerDrop(local Local) {
+	if !types.NeedsDrop(local.Type) {
+		return
+	}
+	funcName, typeArgs := l.dropMethod(local.Type)
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &RegisterDrop{
+		Value:    &LocalRef{Local: local},
+		Func:     funcName,
+		TypeArgs: typeArgs,
+	})
+}
+
+// dropMethod returns the name of the drop method of typ, with the type
+// arguments it is called with if typ instantiates a generic struct.
+func (l *Lowerer) dropMethod(typ types.Type) (string, []types.Type) {
+	var typeArgs []types.Type
+	if inst, ok := typ.(*types.GenericInstance); ok {
+		typeArgs = inst.Args
+	}
+	return l.getTypeName(typ) + "::drop", typeArgs
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
 // lowerFieldExpr lowers a field access expression
@@ -47,6 +48,10 @@ func (l *Lowerer) lowerIndexExpr(expr *ast.IndexExpr) (Operand, error) {
 		return nil, fmt.Errorf("index expression requires at least one index")
 	}
 
+	if r, ok := expr.Indices[0].(*ast.RangeExpr); ok && len(expr.Indices) == 1 {
+		return l.lowerSliceRange(expr, target, r)
+	}
+
 	var indices []Operand
 	for _, indexExpr := range expr.Indices {
 		index, err := l.lowerExpr(indexExpr)
@@ -75,3 +80,76 @@ func (l *Lowerer) lowerIndexExpr(expr *ast.IndexExpr) (Operand, error) {
 
 	return &LocalRef{Local: resultLocal}, nil
 }
+
+// lowerSliceRange lowers `target[start..end]` on an array or slice to a
+// runtime_slice_subslice call, which panics unless start <= end <= len and
+// returns a slice sharing target's elements; an array is a value, so its
+// range is taken from a copy of the array as a slice. On a string it is a call of
+// substring, which clamps the range to the string instead. A missing start
+// is 0 and a missing end is the length of target.
+func (l *Lowerer) lowerSliceRange(expr *ast.IndexExpr, target Operand, r *ast.RangeExpr) (Operand, error) {
+	targetType := l.getType(expr.Target, l.TypeInfo)
+	for {
+		if ref, ok := targetType.(*types.Reference); ok {
+			targetType = ref.Elem
+			continue
+		}
+		if ptr, ok := targetType.(*types.Pointer); ok {
+			targetType = ptr.Elem
+			continue
+		}
+		break
+	}
+	lenFunc, rangeFunc := "runtime_slice_len", "runtime_slice_subslice"
+	switch targetType.(type) {
+	case *types.Slice, *types.Array:
+	default:
+		if targetType != types.TypeString {
+			return nil, fmt.Errorf("range indexing of %s is not supported", targetType)
+		}
+		lenFunc, rangeFunc = "__string_len__", "__string_substring__"
+	}
+
+	var start Operand = &Literal{Type: types.TypeInt, Value: int64(0)}
+	if r.Start != nil {
+		op, err := l.lowerExpr(r.Start)
+		if err != nil {
+			return nil, err
+		}
+		start = op
+	}
+
+	var end Operand
+	if r.End != nil {
+		op, err := l.lowerExpr(r.End)
+		if err != nil {
+			return nil, err
+		}
+		end = op
+	} else if arr, ok := targetType.(*types.Array); ok {
+		end = &Literal{Type: types.TypeInt, Value: arr.Len}
+	} else {
+		lenLocal := l.newLocal("", types.TypeInt)
+		l.currentFunc.Locals = append(l.currentFunc.Locals, lenLocal)
+		l.currentBlock.Statements = append(l.currentBlock.Statements, &Call{
+			Result: lenLocal,
+			Func:   lenFunc,
+			Args:   []Operand{target},
+		})
+		end = &LocalRef{Local: lenLocal}
+	}
+
+	resultType := l.getType(expr, l.TypeInfo)
+	if resultType == nil {
+		return nil, fmt.Errorf("failed to determine type for index expression: %v", expr)
+	}
+	resultLocal := l.newLocal("", resultType)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &Call{
+		Result: resultLocal,
+		Func:   rangeFunc,
+		Args:   []Operand{target, start, end},
+	})
+
+	return &LocalRef{Local: resultLocal}, nil
+}
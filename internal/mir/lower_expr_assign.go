@@ -4,36 +4,55 @@ import (
 	"fmt"
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
 // lowerAssignExpr lowers an assignment expression
 func (l *Lowerer) lowerAssignExpr(expr *ast.AssignExpr) (Operand, error) {
 	// Lower the value expression
-	value, err := l.lowerExpr(expr.Value)
+	value, err := l.lowerValue(expr.Value)
 	if err != nil {
 		return nil, err
 	}
+	if err := l.assignTo(expr.Target, value); err != nil {
+		return nil, err
+	}
 
-	// Handle different target types
-	switch target := expr.Target.(type) {
+	// Return the assigned value as the result of the expression
+	return value, nil
+}
+
+// assignTo stores value into the place target names
+func (l *Lowerer) assignTo(targetExpr ast.Expr, value Operand) error {
+	switch target := targetExpr.(type) {
 	case *ast.Ident:
 		// Assignment to local variable
-		local, ok := l.locals[target.Name]
+		local, ok := l.lookupLocal(target.Name)
 		if !ok {
-			return nil, fmt.Errorf("unknown variable: %s", target.Name)
+			if static := l.findStatic(target.Name); static != nil {
+				l.currentBlock.Statements = append(l.currentBlock.Statements, &StoreStatic{
+					Name:  static.Name,
+					Value: value,
+				})
+				break
+			}
+			return fmt.Errorf("unknown variable: %s", target.Name)
 		}
 
-		// Emit assignment
+		// Emit assignment, dropping the value it replaces
+		l.dropBeforeAssign(local)
 		l.currentBlock.Statements = append(l.currentBlock.Statements, &Assign{
 			Local: local,
 			RHS:   value,
 		})
+		l.setDropFlag(local, true)
 
 	case *ast.FieldExpr:
 		// Assignment to struct field
 		targetOp, err := l.lowerExpr(target.Target)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		l.currentBlock.Statements = append(l.currentBlock.Statements, &StoreField{
@@ -43,10 +62,30 @@ func (l *Lowerer) lowerAssignExpr(expr *ast.AssignExpr) (Operand, error) {
 		})
 
 	case *ast.IndexExpr:
-		// Assignment to array/slice index
-		targetOp, err := l.lowerExpr(target.Target)
+		// Assignment to array/slice index. An array is a value, so unless
+		// it is a local variable or is reached through a pointer, the
+		// store goes into a copy that is then written back to the place
+		// the array came from: a field, another array or a static.
+		place := target.Target
+		writeBack := false
+		if _, ok := l.getType(place, l.TypeInfo).(*types.Array); ok {
+			switch t := place.(type) {
+			case *ast.Ident:
+				_, isLocal := l.locals[t.Name]
+				writeBack = !isLocal
+			case *ast.PrefixExpr:
+				if t.Op == lexer.ASTERISK {
+					place = t.Expr
+				} else {
+					writeBack = true
+				}
+			default:
+				writeBack = true
+			}
+		}
+		targetOp, err := l.lowerExpr(place)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		// Lower indices
@@ -54,7 +93,7 @@ func (l *Lowerer) lowerAssignExpr(expr *ast.AssignExpr) (Operand, error) {
 		for _, indexExpr := range target.Indices {
 			indexOp, err := l.lowerExpr(indexExpr)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			indices = append(indices, indexOp)
 		}
@@ -64,11 +103,12 @@ func (l *Lowerer) lowerAssignExpr(expr *ast.AssignExpr) (Operand, error) {
 			Indices: indices,
 			Value:   value,
 		})
+		if writeBack {
+			return l.assignTo(target.Target, targetOp)
+		}
 
 	default:
-		return nil, fmt.Errorf("invalid assignment target: %T", expr.Target)
+		return fmt.Errorf("invalid assignment target: %T", targetExpr)
 	}
-
-	// Return the assigned value as the result of the expression
-	return value, nil
+	return nil
 }
@@ -18,6 +18,9 @@ func (l *Lowerer) lowerCallExpr(call *ast.CallExpr) (Operand, error) {
 		if ident, ok := idx.Target.(*ast.Ident); ok && ident.Name == "make" {
 			isMake = true
 		}
+	} else if isChannelNew(call.Callee) {
+		// Channel::new(cap) and Channel[T]::new(cap) build channels like make
+		isMake = true
 	}
 
 	if isMake {
@@ -100,6 +103,23 @@ func (l *Lowerer) lowerCallExpr(call *ast.CallExpr) (Operand, error) {
 	// Check for built-in functions
 	calleeName := l.getCalleeName(call.Callee)
 
+	if calleeName == "close" && len(call.Args) == 1 {
+		if _, ok := l.getType(call.Args[0], l.TypeInfo).(*types.Channel); ok {
+			ch, err := l.lowerExpr(call.Args[0])
+			if err != nil {
+				return nil, err
+			}
+			resultLocal := l.newLocal("", &types.Primitive{Kind: types.Void})
+			l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+			l.currentBlock.Statements = append(l.currentBlock.Statements, &Call{
+				Result: resultLocal,
+				Func:   "runtime_channel_close",
+				Args:   []Operand{ch},
+			})
+			return &LocalRef{Local: resultLocal}, nil
+		}
+	}
+
 	if calleeName == "sizeof" {
 		typeArgs := l.CallTypeArgs[call]
 		if len(typeArgs) != 1 {
@@ -132,11 +152,57 @@ func (l *Lowerer) lowerCallExpr(call *ast.CallExpr) (Operand, error) {
 		return &LocalRef{Local: resultLocal}, nil
 	}
 
+	if calleeName == "enum_tag" && len(call.Args) == 1 {
+		target, err := l.lowerExpr(call.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return l.lowerEnumTag(target, l.getType(call, l.TypeInfo)), nil
+	}
+
+	if calleeName == "len" && len(call.Args) == 1 {
+		if arr, ok := l.getType(call.Args[0], l.TypeInfo).(*types.Array); ok {
+			// The length of an array is part of its type
+			if _, err := l.lowerExpr(call.Args[0]); err != nil {
+				return nil, err
+			}
+			return &Literal{Type: types.TypeInt, Value: arr.Len}, nil
+		}
+	}
+
+	// ptr.offset(n) on a raw pointer
+	if field, ok := call.Callee.(*ast.FieldExpr); ok && field.Field.Name == "offset" && len(call.Args) == 1 {
+		if ptrType, ok := l.getType(field.Target, l.TypeInfo).(*types.Pointer); ok {
+			ptr, err := l.lowerExpr(field.Target)
+			if err != nil {
+				return nil, err
+			}
+			offset, err := l.lowerExpr(call.Args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			resultLocal := l.newLocal("", ptrType)
+			l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+
+			l.currentBlock.Statements = append(l.currentBlock.Statements, &PtrOffset{
+				Result:  resultLocal,
+				Pointer: ptr,
+				Offset:  offset,
+			})
+			return &LocalRef{Local: resultLocal}, nil
+		}
+	}
+
 	// Check for enum variant construction: Enum::Variant(args...)
 	// Check for enum variant construction: Enum::Variant(args...)
 	if infix, ok := call.Callee.(*ast.InfixExpr); ok && infix.Op == lexer.DOUBLE_COLON {
 		var typeName string
 		if ident, ok := infix.Left.(*ast.Ident); ok {
+			if l.isModuleName(ident.Name) {
+				// mod::fn(...) returning an enum is a plain call
+				goto notEnum
+			}
 			typeName = ident.Name
 		} else if indexExpr, ok := infix.Left.(*ast.IndexExpr); ok {
 			if ident, ok := indexExpr.Target.(*ast.Ident); ok {
@@ -156,9 +222,9 @@ func (l *Lowerer) lowerCallExpr(call *ast.CallExpr) (Operand, error) {
 		}
 
 		// Get result type
-		retType := l.getType(call, l.TypeInfo)
-		if retType == nil {
-			retType = &types.Named{Name: typeName}
+		retType, err := l.typeOf(call)
+		if err != nil {
+			return nil, err
 		}
 
 		// Resolve variant index
@@ -196,7 +262,8 @@ func (l *Lowerer) lowerCallExpr(call *ast.CallExpr) (Operand, error) {
 				}
 			}
 			if !found {
-				return nil, fmt.Errorf("variant %s not found in enum %s", rightIdent.Name, enumType.Name)
+				// An associated function returning the enum, Color::from_int(1)
+				goto notEnum
 			}
 
 			// Lower arguments
@@ -236,6 +303,33 @@ notEnum:
 			targetType = ptr.Elem
 		}
 
+		if handle, ok := targetType.(*types.JoinHandle); ok && fieldExpr.Field.Name == "join" {
+			return l.lowerJoin(fieldExpr.Target, handle)
+		}
+
+		if opt, ok := targetType.(*types.Optional); ok && (fieldExpr.Field.Name == "unwrap" || fieldExpr.Field.Name == "expect") {
+			return l.lowerOptionalUnwrap(fieldExpr.Target, call, opt)
+		}
+
+		if ch, ok := targetType.(*types.Channel); ok && fieldExpr.Field.Name == "recv" {
+			chOp, err := l.lowerExpr(fieldExpr.Target)
+			if err != nil {
+				return nil, err
+			}
+			resultLocal := l.newLocal("", &types.Optional{Elem: ch.Elem})
+			l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+			l.currentBlock.Statements = append(l.currentBlock.Statements, &Receive{
+				Result:   resultLocal,
+				Channel:  chOp,
+				Optional: true,
+			})
+			return &LocalRef{Local: resultLocal}, nil
+		}
+
+		if _, ok := targetType.(*types.Map); ok {
+			return l.lowerMapMethod(call, fieldExpr)
+		}
+
 		if _, ok := targetType.(*types.Slice); ok {
 			methodName := fieldExpr.Field.Name
 			var runtimeFunc string
@@ -259,6 +353,10 @@ notEnum:
 				runtimeFunc = "runtime_slice_subslice"
 			case "set":
 				runtimeFunc = "runtime_slice_set"
+			case "len":
+				runtimeFunc = "runtime_slice_len"
+			case "cap":
+				runtimeFunc = "runtime_slice_cap"
 			}
 
 			if runtimeFunc != "" {
@@ -289,9 +387,12 @@ notEnum:
 						// We need to pass a pointer to the value
 						valType := op.OperandType()
 
-						// If it's a primitive, we need to take its address
+						// If it's a primitive, we need to take its address. So
+						// does a type parameter, which may stand for one (or for
+						// a string) once the function is monomorphized.
 						_, isPrim := valType.(*types.Primitive)
-						if isPrim {
+						_, isTypeParam := valType.(*types.TypeParam)
+						if isPrim || isTypeParam {
 							// Create a temporary local to hold the value
 							tempLocal := l.newLocal("", valType)
 							l.currentFunc.Locals = append(l.currentFunc.Locals, tempLocal)
@@ -344,9 +445,9 @@ notEnum:
 				}
 
 				// Get return type
-				retType := l.getType(call, l.TypeInfo)
-				if retType == nil {
-					retType = &types.Primitive{Kind: types.Void}
+				retType, err := l.typeOf(call)
+				if err != nil {
+					return nil, err
 				}
 
 				// Create result local
@@ -410,9 +511,11 @@ notEnum:
 
 	// Check if callee is a local variable (indirect call)
 	var funcOperand Operand
-	if local, ok := l.locals[calleeName]; ok {
+	if local, ok := l.lookupLocal(calleeName); ok {
 		funcOperand = &LocalRef{Local: local}
 		calleeName = "" // Clear name to indicate indirect call
+	} else if _, ok := call.Callee.(*ast.Ident); ok {
+		calleeName = l.importedFunctionName(calleeName)
 	}
 
 	// Lower arguments
@@ -437,9 +540,9 @@ notEnum:
 	}
 
 	// Get return type
-	retType := l.getType(call, l.TypeInfo)
-	if retType == nil {
-		retType = &types.Primitive{Kind: types.Void}
+	retType, err := l.typeOf(call)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create result local
@@ -472,5 +575,109 @@ notEnum:
 		TypeArgs:    typeArgs,
 	})
 
+	// Control never comes back from a call of a function returning `!`, so
+	// the code after it is lowered into a block nothing jumps to
+	if prim, ok := retType.(*types.Primitive); ok && prim.Kind == types.Never {
+		l.currentBlock.Terminator = &Unreachable{}
+		l.currentBlock = l.newBlock("")
+		l.currentFunc.Blocks = append(l.currentFunc.Blocks, l.currentBlock)
+		l.diverged[l.currentBlock] = true
+	}
+
+	return &LocalRef{Local: resultLocal}, nil
+}
+
+// lowerOptionalUnwrap lowers opt.unwrap() and opt.expect(msg). T? is a
+// pointer to T, so the value is loaded after runtime_optional_unwrap has
+// panicked on nil.
+func (l *Lowerer) lowerOptionalUnwrap(target ast.Expr, call *ast.CallExpr, opt *types.Optional) (Operand, error) {
+	targetOp, err := l.lowerExpr(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg Operand = &Literal{Type: &types.Primitive{Kind: types.String}, Value: "called unwrap() on nil"}
+	if len(call.Args) == 1 {
+		msg, err = l.lowerExpr(call.Args[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	voidPtr := &types.Primitive{Kind: types.Nil} // i8*
+	raw := l.newLocal("", voidPtr)
+	checked := l.newLocal("", voidPtr)
+	typed := l.newLocal("", opt)
+	value := l.newLocal("", opt.Elem)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, raw, checked, typed, value)
+
+	l.currentBlock.Statements = append(l.currentBlock.Statements,
+		&Cast{Result: raw, Operand: targetOp, Type: voidPtr},
+		&Call{Result: checked, Func: "runtime_optional_unwrap", Args: []Operand{&LocalRef{Local: raw}, msg}},
+		&Cast{Result: typed, Operand: &LocalRef{Local: checked}, Type: opt},
+		&Load{Result: value, Address: &LocalRef{Local: typed}},
+	)
+	return &LocalRef{Local: value}, nil
+}
+
+// isChannelNew reports whether callee is Channel::new or Channel[T]::new
+func isChannelNew(callee ast.Expr) bool {
+	infix, ok := callee.(*ast.InfixExpr)
+	if !ok || infix.Op != lexer.DOUBLE_COLON {
+		return false
+	}
+	if right, ok := infix.Right.(*ast.Ident); !ok || right.Name != "new" {
+		return false
+	}
+	left := infix.Left
+	if idx, ok := left.(*ast.IndexExpr); ok {
+		left = idx.Target
+	}
+	ident, ok := left.(*ast.Ident)
+	return ok && ident.Name == "Channel"
+}
+
+// mapMethodBuiltins maps the methods of map[K, V] to the builtins codegen
+// implements them with
+var mapMethodBuiltins = map[string]string{
+	"contains": "contains",
+	"remove":   "__map_remove__",
+	"keys":     "__map_keys__",
+	"values":   "__map_values__",
+	"len":      "len",
+}
+
+// lowerMapMethod lowers a method call on a map to a call of the builtin
+// implementing it, with the map as the first argument
+func (l *Lowerer) lowerMapMethod(call *ast.CallExpr, fieldExpr *ast.FieldExpr) (Operand, error) {
+	builtin, ok := mapMethodBuiltins[fieldExpr.Field.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown map method: %s", fieldExpr.Field.Name)
+	}
+
+	receiver, err := l.lowerExpr(fieldExpr.Target)
+	if err != nil {
+		return nil, err
+	}
+	args := []Operand{receiver}
+	for _, arg := range call.Args {
+		op, err := l.lowerExpr(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, op)
+	}
+
+	retType, err := l.typeOf(call)
+	if err != nil {
+		return nil, err
+	}
+	resultLocal := l.newLocal("", retType)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &Call{
+		Result: resultLocal,
+		Func:   builtin,
+		Args:   args,
+	})
 	return &LocalRef{Local: resultLocal}, nil
 }
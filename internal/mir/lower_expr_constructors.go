@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
-	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
 // lowerStructLiteral lowers a struct literal
@@ -16,11 +15,9 @@ func (l *Lowerer) lowerStructLiteral(expr *ast.StructLiteral) (Operand, error) {
 	}
 
 	// Get result type
-	resultType := l.getType(expr, l.TypeInfo)
-	if resultType == nil {
-		// Try to infer from struct name
-		// For now, create a named type
-		resultType = &types.Named{Name: structName}
+	resultType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
 	}
 
 	// Lower field values
@@ -43,6 +40,7 @@ func (l *Lowerer) lowerStructLiteral(expr *ast.StructLiteral) (Operand, error) {
 		Type:   resultType,
 		Fields: fields,
 	})
+	l.registerDrop(resultLocal)
 
 	return &LocalRef{Local: resultLocal}, nil
 }
@@ -50,122 +48,30 @@ func (l *Lowerer) lowerStructLiteral(expr *ast.StructLiteral) (Operand, error) {
 // lowerArrayLiteral lowers an array/slice literal
 func (l *Lowerer) lowerArrayLiteral(expr *ast.ArrayLiteral) (Operand, error) {
 	// Get result type
-	resultType := l.getType(expr, l.TypeInfo)
-	if resultType == nil {
-		// Try to infer from elements
-		if len(expr.Elements) > 0 {
-			elemType := l.getType(expr.Elements[0], l.TypeInfo)
-			if elemType != nil {
-				resultType = &types.Slice{Elem: elemType}
-			} else {
-				resultType = &types.Slice{Elem: &types.Primitive{Kind: types.Int}}
-			}
-		} else {
-			resultType = &types.Slice{Elem: &types.Primitive{Kind: types.Int}}
-		}
-	}
-
-	// Determine if it's an array or slice
-	var isSlice bool
-
-	switch resultType.(type) {
-	case *types.Slice:
-		isSlice = true
-	case *types.Array:
-		isSlice = false
-	default:
-		// Default to slice if we can't determine
-		isSlice = true
+	resultType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create result local
-	resultLocal := l.newLocal("", resultType)
-	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
-
-	// Allocate/create the array or slice
-	if isSlice {
-		// For slices, call runtime_slice_new to create the slice
-		// runtime_slice_new(elem_size, len, cap) -> *Slice
-		// We need to calculate element size - for now, use a placeholder
-		// The actual size calculation will be done in the MIR-to-LLVM backend
-		elemSizeLocal := l.newLocal("", &types.Primitive{Kind: types.Int64})
-		l.currentFunc.Locals = append(l.currentFunc.Locals, elemSizeLocal)
-
-		lenLocal := l.newLocal("", &types.Primitive{Kind: types.Int64})
-		l.currentFunc.Locals = append(l.currentFunc.Locals, lenLocal)
-		lenValue := &Literal{
-			Type:  &types.Primitive{Kind: types.Int64},
-			Value: int64(len(expr.Elements)),
-		}
-		l.currentBlock.Statements = append(l.currentBlock.Statements, &Assign{
-			Local: lenLocal,
-			RHS:   lenValue,
-		})
-
-		capLocal := l.newLocal("", &types.Primitive{Kind: types.Int64})
-		l.currentFunc.Locals = append(l.currentFunc.Locals, capLocal)
-		capValue := &Literal{
-			Type:  &types.Primitive{Kind: types.Int64},
-			Value: int64(len(expr.Elements)),
-		}
-		l.currentBlock.Statements = append(l.currentBlock.Statements, &Assign{
-			Local: capLocal,
-			RHS:   capValue,
-		})
-
-		// Call runtime_slice_new(elem_size, len, cap)
-		// Note: elem_size will need to be calculated in the backend
-		// For now, we pass a placeholder
-		elemSizeValue := &Literal{
-			Type:  &types.Primitive{Kind: types.Int64},
-			Value: int64(8), // Placeholder - will be calculated in backend
-		}
-		l.currentBlock.Statements = append(l.currentBlock.Statements, &Assign{
-			Local: elemSizeLocal,
-			RHS:   elemSizeValue,
-		})
-
-		l.currentBlock.Statements = append(l.currentBlock.Statements, &Call{
-			Result: resultLocal,
-			Func:   "runtime_slice_new",
-			Args: []Operand{
-				&LocalRef{Local: elemSizeLocal},
-				&LocalRef{Local: lenLocal},
-				&LocalRef{Local: capLocal},
-			},
-		})
-	} else {
-		// For arrays, use ConstructArray to allocate the array
-		// ConstructArray will allocate the array with the correct size
-		l.currentBlock.Statements = append(l.currentBlock.Statements, &ConstructArray{
-			Result:   resultLocal,
-			Type:     resultType,
-			Elements: []Operand{}, // Empty - we'll initialize elements separately
-		})
-	}
-
-	// Lower and store each element
+	// Lower the elements, then build the slice holding them in one step.
+	// Arrays share the slice representation, with a length of their own.
+	elements := make([]Operand, 0, len(expr.Elements))
 	for i, elem := range expr.Elements {
-		// Lower the element expression
 		elemOp, err := l.lowerExpr(elem)
 		if err != nil {
 			return nil, fmt.Errorf("failed to lower element %d: %w", i, err)
 		}
-
-		// Create index literal
-		indexOp := &Literal{
-			Type:  &types.Primitive{Kind: types.Int64},
-			Value: int64(i),
-		}
-
-		// Store element at index i
-		l.currentBlock.Statements = append(l.currentBlock.Statements, &StoreIndex{
-			Target:  &LocalRef{Local: resultLocal},
-			Indices: []Operand{indexOp},
-			Value:   elemOp,
-		})
+		elements = append(elements, elemOp)
 	}
 
+	resultLocal := l.newLocal("", resultType)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &ConstructArray{
+		Result:   resultLocal,
+		Type:     resultType,
+		Elements: elements,
+	})
+
 	return &LocalRef{Local: resultLocal}, nil
 }
 
@@ -182,24 +88,9 @@ func (l *Lowerer) lowerTupleLiteral(expr *ast.TupleLiteral) (Operand, error) {
 	}
 
 	// Get result type
-	resultType := l.getType(expr, l.TypeInfo)
-	if resultType == nil {
-		// Infer from lowered elements
-		elemTypes := make([]types.Type, 0, len(elements))
-		for _, op := range elements {
-			var elemType types.Type
-			switch o := op.(type) {
-			case *Literal:
-				elemType = o.Type
-			case *LocalRef:
-				elemType = o.Local.Type
-			default:
-				// Fallback if we can't determine type (shouldn't happen for valid MIR)
-				elemType = types.TypeInt
-			}
-			elemTypes = append(elemTypes, elemType)
-		}
-		resultType = &types.Tuple{Elements: elemTypes}
+	resultType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create result local
@@ -218,11 +109,9 @@ func (l *Lowerer) lowerTupleLiteral(expr *ast.TupleLiteral) (Operand, error) {
 // lowerRecordLiteral lowers a record literal (anonymous struct)
 func (l *Lowerer) lowerRecordLiteral(expr *ast.RecordLiteral) (Operand, error) {
 	// Get result type
-	resultType := l.getType(expr, l.TypeInfo)
-	if resultType == nil {
-		// Infer from fields
-		// For now, use a placeholder
-		resultType = &types.Primitive{Kind: types.Int} // Placeholder
+	resultType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
 	}
 
 	// Lower field values
@@ -253,25 +142,9 @@ func (l *Lowerer) lowerRecordLiteral(expr *ast.RecordLiteral) (Operand, error) {
 // lowerMapLiteral lowers a map literal
 func (l *Lowerer) lowerMapLiteral(expr *ast.MapLiteral) (Operand, error) {
 	// Get result type
-	resultType := l.getType(expr, l.TypeInfo)
-	if resultType == nil {
-		// Infer from entries
-		if len(expr.Entries) > 0 {
-			keyType := l.getType(expr.Entries[0].Key, l.TypeInfo)
-			valueType := l.getType(expr.Entries[0].Value, l.TypeInfo)
-			if keyType == nil {
-				keyType = &types.Primitive{Kind: types.String}
-			}
-			if valueType == nil {
-				valueType = &types.Primitive{Kind: types.Int}
-			}
-			resultType = &types.Map{Key: keyType, Value: valueType}
-		} else {
-			resultType = &types.Map{
-				Key:   &types.Primitive{Kind: types.String},
-				Value: &types.Primitive{Kind: types.Int},
-			}
-		}
+	resultType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
 	}
 
 	// For map literals, we'll treat them as function calls to a constructor
@@ -10,9 +10,9 @@ import (
 // lowerIfExpr lowers an if expression (returns a value)
 func (l *Lowerer) lowerIfExpr(expr *ast.IfExpr) (Operand, error) {
 	// Create result local
-	resultType := l.getType(expr, l.TypeInfo)
-	if resultType == nil {
-		resultType = &types.Primitive{Kind: types.Int}
+	resultType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
 	}
 	resultLocal := l.newLocal("", resultType)
 	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
@@ -22,7 +22,7 @@ func (l *Lowerer) lowerIfExpr(expr *ast.IfExpr) (Operand, error) {
 	l.currentFunc.Blocks = append(l.currentFunc.Blocks, mergeBlock)
 
 	// Lower if-else chain, storing result in resultLocal
-	err := l.lowerIfChain(expr.Clauses, expr.Else, l.currentBlock, mergeBlock, true, resultLocal)
+	err = l.lowerIfChain(expr.Clauses, expr.Else, l.currentBlock, mergeBlock, true, resultLocal)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +84,9 @@ func (l *Lowerer) lowerIfChain(
 				return err
 			}
 
-			if result != nil {
+			if l.currentBlock.Terminator != nil {
+				// The branch returned or broke out, so it has no value
+			} else if result != nil {
 				// Store result in resultLocal
 				l.currentBlock.Statements = append(l.currentBlock.Statements, &Assign{
 					Local: resultLocal,
@@ -106,9 +108,7 @@ func (l *Lowerer) lowerIfChain(
 		}
 
 		// If true block doesn't have a terminator, goto merge
-		if l.currentBlock.Terminator == nil {
-			l.currentBlock.Terminator = &Goto{Target: mergeBlock}
-		}
+		l.jumpTo(mergeBlock)
 
 		// Move to next clause
 		currentBlock = falseBlock
@@ -124,7 +124,9 @@ func (l *Lowerer) lowerIfChain(
 				return err
 			}
 
-			if result != nil {
+			if l.currentBlock.Terminator != nil {
+				// The branch returned or broke out, so it has no value
+			} else if result != nil {
 				// Store result in resultLocal
 				l.currentBlock.Statements = append(l.currentBlock.Statements, &Assign{
 					Local: resultLocal,
@@ -146,9 +148,7 @@ func (l *Lowerer) lowerIfChain(
 		}
 
 		// If else block doesn't have a terminator, goto merge
-		if l.currentBlock.Terminator == nil {
-			l.currentBlock.Terminator = &Goto{Target: mergeBlock}
-		}
+		l.jumpTo(mergeBlock)
 	} else if currentBlock != mergeBlock {
 		// No else block, but we have a false block - goto merge
 		currentBlock.Terminator = &Goto{Target: mergeBlock}
@@ -166,9 +166,9 @@ func (l *Lowerer) lowerMatchExpr(expr *ast.MatchExpr) (Operand, error) {
 	}
 
 	// Create result local
-	resultType := l.getType(expr, l.TypeInfo)
-	if resultType == nil {
-		resultType = &types.Primitive{Kind: types.Int}
+	resultType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
 	}
 	resultLocal := l.newLocal("", resultType)
 	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
@@ -226,9 +226,7 @@ func (l *Lowerer) lowerMatchExpr(expr *ast.MatchExpr) (Operand, error) {
 		}
 
 		// Goto merge
-		if l.currentBlock.Terminator == nil {
-			l.currentBlock.Terminator = &Goto{Target: mergeBlock}
-		}
+		l.jumpTo(mergeBlock)
 
 		currentBlock = nextBlock
 	}
@@ -253,76 +251,6 @@ func (l *Lowerer) lowerPattern(
 ) error {
 	switch p := pattern.(type) {
 	case *ast.StructPattern:
-		// Check struct type (static check usually sufficient, but for dynamic/any types we'd need runtime check)
-		// For now, assume static typing ensures subject is the correct struct type.
-
-		// Match fields
-		for _, field := range p.Fields {
-			// Load field
-			var fieldType types.Type = &types.Primitive{Kind: types.Int} // Placeholder, ideally we get real field type
-			// We need to look up the field type from the struct definition
-			// But we don't have easy access to the struct definition here without resolving the type.
-			// l.getType(subject) should give us the struct type.
-
-			// Get subject type
-			var subjectType types.Type
-			if locRef, ok := subject.(*LocalRef); ok {
-				subjectType = locRef.Local.Type
-			} else if lit, ok := subject.(*Literal); ok {
-				subjectType = lit.Type
-			}
-
-			// If subject is a pointer, we might need to dereference or handle it.
-			// For now assume direct struct value or reference handled by LoadField.
-
-			if structType, ok := subjectType.(*types.Struct); ok {
-				if f := structType.FieldByName(field.Name.Name); f != nil {
-					fieldType = f.Type
-				}
-			}
-
-			fieldLocal := l.newLocal(field.Name.Name, fieldType)
-			l.currentFunc.Locals = append(l.currentFunc.Locals, fieldLocal)
-
-			currentBlock.Statements = append(currentBlock.Statements, &LoadField{
-				Result: fieldLocal,
-				Target: subject,
-				Field:  field.Name.Name,
-			})
-
-			// Recurse
-			err := l.lowerPattern(&LocalRef{Local: fieldLocal}, field.Pattern, successBlock, failBlock, currentBlock)
-			if err != nil {
-				return err
-			}
-
-			// Note: lowerPattern might change currentBlock (e.g. by adding branches).
-			// But here we are passing the SAME successBlock and failBlock.
-			// If lowerPattern adds a check, it will split the control flow.
-			// We need to chain the checks.
-			// Actually, lowerPattern takes currentBlock and appends to it.
-			// If it branches, it terminates currentBlock.
-			// So we need to update currentBlock for the next field check.
-			// But wait, lowerPattern generates a branch to successBlock or failBlock.
-			// If it branches to successBlock, it means *that specific pattern* matched.
-			// But we need *all* fields to match.
-			// So we need a chain:
-			// Check field 1 -> if match, go to check field 2 block. If fail, go to failBlock.
-			// Check field 2 -> ... -> if match, go to successBlock.
-
-			// We need to create a new block for the next check
-			nextCheckBlock := l.newBlock("pat_check")
-			l.currentFunc.Blocks = append(l.currentFunc.Blocks, nextCheckBlock)
-
-			// Update the recursive call to jump to nextCheckBlock on success instead of final successBlock
-			// UNLESS it's the last field.
-			// Actually, let's just rewrite the recursive call logic.
-		}
-
-		// Wait, the loop above is wrong because lowerPattern terminates the block.
-		// We need to chain them.
-
-		// Let's restart the loop logic.
 		return l.lowerStructPattern(subject, p, successBlock, failBlock, currentBlock)
 
 	case *ast.EnumPattern:
@@ -334,16 +262,16 @@ func (l *Lowerer) lowerPattern(
 		return nil
 
 	case *ast.VarPattern:
+		if v, ok := l.Consts[p.Name]; ok {
+			// Names a const, whose value it matches
+			return l.lowerEqualityPattern(subject, l.constLiteral(p.Name, v), successBlock, failBlock, currentBlock)
+		}
 		// Always matches and binds variable
 		// We need to create a local variable for the binding
 		// The type should be the type of the subject
-		var subjectType types.Type
-		if locRef, ok := subject.(*LocalRef); ok {
-			subjectType = locRef.Local.Type
-		} else if lit, ok := subject.(*Literal); ok {
-			subjectType = lit.Type
-		} else {
-			subjectType = &types.Primitive{Kind: types.Int} // Fallback
+		subjectType := subject.OperandType()
+		if subjectType == nil {
+			return fmt.Errorf("cannot bind `%s`: the matched value has no type", p.Name.Name)
 		}
 
 		bindingLocal := l.newLocal(p.Name.Name, subjectType)
@@ -365,22 +293,7 @@ func (l *Lowerer) lowerPattern(
 		if err != nil {
 			return err
 		}
-
-		eqResult := l.newLocal("", &types.Primitive{Kind: types.Bool})
-		l.currentFunc.Locals = append(l.currentFunc.Locals, eqResult)
-
-		currentBlock.Statements = append(currentBlock.Statements, &Call{
-			Result: eqResult,
-			Func:   "__eq__",
-			Args:   []Operand{subject, patVal},
-		})
-
-		currentBlock.Terminator = &Branch{
-			Condition: &LocalRef{Local: eqResult},
-			True:      successBlock,
-			False:     failBlock,
-		}
-		return nil
+		return l.lowerEqualityPattern(subject, patVal, successBlock, failBlock, currentBlock)
 
 	case *ast.TuplePattern:
 		// Check tuple type
@@ -441,6 +354,26 @@ func (l *Lowerer) lowerPattern(
 	}
 }
 
+// lowerEqualityPattern branches to successBlock if subject equals the value
+// of a literal or const pattern, and to failBlock otherwise
+func (l *Lowerer) lowerEqualityPattern(subject, value Operand, successBlock, failBlock, currentBlock *BasicBlock) error {
+	eqResult := l.newLocal("", &types.Primitive{Kind: types.Bool})
+	l.currentFunc.Locals = append(l.currentFunc.Locals, eqResult)
+
+	currentBlock.Statements = append(currentBlock.Statements, &Call{
+		Result: eqResult,
+		Func:   "__eq__",
+		Args:   []Operand{subject, value},
+	})
+
+	currentBlock.Terminator = &Branch{
+		Condition: &LocalRef{Local: eqResult},
+		True:      successBlock,
+		False:     failBlock,
+	}
+	return nil
+}
+
 func (l *Lowerer) lowerStructPattern(
 	subject Operand,
 	p *ast.StructPattern,
@@ -463,37 +396,15 @@ func (l *Lowerer) lowerStructPattern(
 		}
 
 		// Load field
-		// We need to know the field type
-		var subjectType types.Type
-		if locRef, ok := subject.(*LocalRef); ok {
-			subjectType = locRef.Local.Type
-		} else if lit, ok := subject.(*Literal); ok {
-			subjectType = lit.Type
+		structType := patternStruct(subject.OperandType())
+		if structType == nil {
+			return fmt.Errorf("cannot match field `%s` of non-struct type `%s`", field.Name.Name, subject.OperandType())
 		}
-
-		fieldType := types.Type(&types.Primitive{Kind: types.Int}) // Fallback
-		var structType *types.Struct
-		if s, ok := subjectType.(*types.Struct); ok {
-			structType = s
-		} else if genInst, ok := subjectType.(*types.GenericInstance); ok {
-			if s, ok := genInst.Base.(*types.Struct); ok {
-				structType = s
-			}
-		} else if ptr, ok := subjectType.(*types.Pointer); ok {
-			if s, ok := ptr.Elem.(*types.Struct); ok {
-				structType = s
-			} else if genInst, ok := ptr.Elem.(*types.GenericInstance); ok {
-				if s, ok := genInst.Base.(*types.Struct); ok {
-					structType = s
-				}
-			}
-		}
-
-		if structType != nil {
-			if f := structType.FieldByName(field.Name.Name); f != nil {
-				fieldType = f.Type
-			}
+		f := structType.FieldByName(field.Name.Name)
+		if f == nil {
+			return fmt.Errorf("struct `%s` has no field `%s`", structType.Name, field.Name.Name)
 		}
+		fieldType := f.Type
 
 		fieldLocal := l.newLocal(field.Name.Name, fieldType)
 		l.currentFunc.Locals = append(l.currentFunc.Locals, fieldLocal)
@@ -521,6 +432,22 @@ func (l *Lowerer) lowerStructPattern(
 	return nil
 }
 
+// patternStruct returns the struct a struct pattern destructures, given the
+// type of the matched value
+func patternStruct(t types.Type) *types.Struct {
+	switch t := t.(type) {
+	case *types.Struct:
+		return t
+	case *types.Named:
+		return patternStruct(t.Ref)
+	case *types.GenericInstance:
+		return patternStruct(t.Base)
+	case *types.Pointer:
+		return patternStruct(t.Elem)
+	}
+	return nil
+}
+
 func (l *Lowerer) lowerEnumPattern(
 	subject Operand,
 	p *ast.EnumPattern,
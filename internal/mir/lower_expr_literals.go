@@ -9,13 +9,35 @@ import (
 
 // lowerIdent lowers an identifier
 func (l *Lowerer) lowerIdent(ident *ast.Ident) (Operand, error) {
-	local, ok := l.locals[ident.Name]
+	local, ok := l.lookupLocal(ident.Name)
 	if !ok {
+		if static := l.findStatic(ident.Name); static != nil {
+			return l.loadStatic(static), nil
+		}
 		return nil, fmt.Errorf("undefined variable: %s", ident.Name)
 	}
+	l.markMoved(ident, local)
 	return &LocalRef{Local: local}, nil
 }
 
+// constLiteral returns the value v of a const named by expr as a literal
+func (l *Lowerer) constLiteral(expr ast.Expr, v any) *Literal {
+	typ := l.getType(expr, l.TypeInfo)
+	if typ == nil {
+		switch v.(type) {
+		case int64:
+			typ = &types.Primitive{Kind: types.Int}
+		case float64:
+			typ = &types.Primitive{Kind: types.Float}
+		case bool:
+			typ = &types.Primitive{Kind: types.Bool}
+		case string:
+			typ = &types.Primitive{Kind: types.String}
+		}
+	}
+	return &Literal{Type: typ, Value: v}
+}
+
 // lowerIntegerLit lowers an integer literal
 func (l *Lowerer) lowerIntegerLit(lit *ast.IntegerLit) (Operand, error) {
 	// Parse integer value
@@ -25,9 +47,9 @@ func (l *Lowerer) lowerIntegerLit(lit *ast.IntegerLit) (Operand, error) {
 	}
 
 	// Get type from type info
-	typ := l.getType(lit, l.TypeInfo)
-	if typ == nil {
-		typ = &types.Primitive{Kind: types.Int}
+	typ, err := l.typeOf(lit)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Literal{
@@ -72,9 +94,9 @@ func (l *Lowerer) lowerFloatLit(lit *ast.FloatLit) (Operand, error) {
 	}
 
 	// Get type from type info
-	typ := l.getType(lit, l.TypeInfo)
-	if typ == nil {
-		typ = &types.Primitive{Kind: types.Float}
+	typ, err := l.typeOf(lit)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Literal{
@@ -85,6 +107,16 @@ func (l *Lowerer) lowerFloatLit(lit *ast.FloatLit) (Operand, error) {
 
 // lowerFunctionLiteral lowers a function literal (closure)
 func (l *Lowerer) lowerFunctionLiteral(expr *ast.FunctionLiteral) (Operand, error) {
+	fnType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
+	}
+	sig, ok := fnType.(*types.Function)
+	if !ok || len(sig.Params) != len(expr.Params) {
+		return nil, fmt.Errorf("function literal has type `%s`", fnType)
+	}
+	paramTypes := sig.Params
+
 	// 1. Create closure function name
 	name := fmt.Sprintf("%s_closure_%d", l.currentFunc.Name, l.localCounter)
 	l.localCounter++
@@ -103,6 +135,8 @@ func (l *Lowerer) lowerFunctionLiteral(expr *ast.FunctionLiteral) (Operand, erro
 	oldFunc := l.currentFunc
 	oldBlock := l.currentBlock
 	oldLocals := l.locals
+	oldDrops := l.drops
+	oldCaptures := l.captures
 
 	// 4. Switch to new function context
 	l.currentFunc = fn
@@ -110,15 +144,21 @@ func (l *Lowerer) lowerFunctionLiteral(expr *ast.FunctionLiteral) (Operand, erro
 	fn.Entry = l.currentBlock
 	fn.Blocks = []*BasicBlock{fn.Entry}
 	l.locals = make(map[string]Local)
+	l.drops = newDropState()
 
-	// 5. Lower parameters
-	// TODO: Handle closure environment (captures) as first parameter
-	for _, param := range expr.Params {
-		paramType := l.getType(param, l.TypeInfo)
-		if paramType == nil {
-			paramType = &types.Primitive{Kind: types.Int} // Default
-		}
-		local := l.newLocal(param.Name.Name, paramType)
+	// 5. Lower parameters, after the environment the closure passes in
+	closureStructName := name + "_env"
+	closureStruct := &types.Struct{
+		Name: closureStructName,
+	}
+	envType := &types.Named{Name: closureStructName, Ref: closureStruct}
+	env := l.newLocal("env", envType)
+	fn.Params = append(fn.Params, env)
+	l.captures = newCaptureScope(oldCaptures, fn, oldLocals, env)
+	captures := l.captures
+
+	for i, param := range expr.Params {
+		local := l.newLocal(param.Name.Name, paramTypes[i])
 		fn.Params = append(fn.Params, local)
 		l.locals[param.Name.Name] = local
 	}
@@ -141,49 +181,35 @@ func (l *Lowerer) lowerFunctionLiteral(expr *ast.FunctionLiteral) (Operand, erro
 		fn.ReturnType = &types.Primitive{Kind: types.Void}
 	}
 
+	// Load the captured variables on entry
+	captures.loadCaptures()
+
 	// 7. Restore state
 	l.currentFunc = oldFunc
 	l.currentBlock = oldBlock
 	l.locals = oldLocals
+	l.drops = oldDrops
+	l.captures = oldCaptures
 
 	// 8. Add function to module
 	l.Module.Functions = append(l.Module.Functions, fn)
 
-	// 9. Create closure struct
-	// For now, empty struct as we don't handle captures
-	// struct Closure { }
-	closureStructName := name + "_env"
-	closureStruct := &types.Struct{
-		Name: closureStructName,
-	}
+	// 9. Create the environment struct, with a field per captured variable
+	closureStruct.Fields = captures.envFields()
 	l.Module.Structs = append(l.Module.Structs, closureStruct)
 
-	// 10. Return ConstructStruct for the closure environment
-	// The type should be the closure struct type
-	envLocal := l.newLocal("", &types.Named{Name: closureStructName, Ref: closureStruct})
+	// 10. Copy the captured variables into a new environment
+	envLocal := l.newLocal("", envType)
 	l.currentFunc.Locals = append(l.currentFunc.Locals, envLocal)
 
 	l.currentBlock.Statements = append(l.currentBlock.Statements, &ConstructStruct{
 		Result: envLocal,
-		Type:   &types.Named{Name: closureStructName, Ref: closureStruct},
-		Fields: map[string]Operand{},
+		Type:   envType,
+		Fields: captures.envValues(),
 	})
 
 	// 11. Create closure object
 	// The result type is the function type of the literal
-	fnType := l.getType(expr, l.TypeInfo)
-	if fnType == nil {
-		// Fallback: construct function type from params and return
-		var paramTypes []types.Type
-		for _, p := range fn.Params {
-			paramTypes = append(paramTypes, p.Type)
-		}
-		fnType = &types.Function{
-			Params: paramTypes,
-			Return: fn.ReturnType,
-		}
-	}
-
 	resultLocal := l.newLocal("", fnType)
 	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
 
@@ -24,9 +24,9 @@ func (l *Lowerer) lowerInfixExpr(expr *ast.InfixExpr) (Operand, error) {
 		}
 
 		// Get result type (Enum type)
-		resultType := l.getType(expr, l.TypeInfo)
-		if resultType == nil {
-			resultType = &types.Named{Name: leftIdent.Name}
+		resultType, err := l.typeOf(expr)
+		if err != nil {
+			return nil, err
 		}
 
 		// Resolve variant index
@@ -106,6 +106,10 @@ func (l *Lowerer) lowerInfixExpr(expr *ast.InfixExpr) (Operand, error) {
 		return nil, nil
 	}
 
+	if l.isStringConcat(expr) {
+		return l.lowerStringConcat(expr)
+	}
+
 	// For now, treat as a function call
 	// TODO: optimize common operations like +, -, *, /, etc.
 	left, err := l.lowerExpr(expr.Left)
@@ -120,9 +124,9 @@ func (l *Lowerer) lowerInfixExpr(expr *ast.InfixExpr) (Operand, error) {
 
 	// Create a synthetic call to the operator
 	opName := l.getOperatorName(expr.Op)
-	retType := l.getType(expr, l.TypeInfo)
-	if retType == nil {
-		retType = &types.Primitive{Kind: types.Int}
+	retType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
 	}
 
 	resultLocal := l.newLocal("", retType)
@@ -146,10 +150,9 @@ func (l *Lowerer) lowerPrefixExpr(expr *ast.PrefixExpr) (Operand, error) {
 			return nil, err
 		}
 
-		retType := l.getType(expr, l.TypeInfo)
-		if retType == nil {
-			// Fallback if type info missing
-			retType = &types.Primitive{Kind: types.Int}
+		retType, err := l.typeOf(expr)
+		if err != nil {
+			return nil, err
 		}
 
 		resultLocal := l.newLocal("", retType)
@@ -191,8 +194,8 @@ func (l *Lowerer) lowerPrefixExpr(expr *ast.PrefixExpr) (Operand, error) {
 		return &LocalRef{Local: resultLocal}, nil
 	}
 
-	// Handle address-of: &val
-	if expr.Op == lexer.AMPERSAND {
+	// Handle address-of: &val and &mut val
+	if expr.Op == lexer.AMPERSAND || expr.Op == lexer.REF_MUT {
 		// We need to lower the expression, but we expect it to be an l-value (LocalRef)
 		operand, err := l.lowerExpr(expr.Expr)
 		if err != nil {
@@ -227,9 +230,9 @@ func (l *Lowerer) lowerPrefixExpr(expr *ast.PrefixExpr) (Operand, error) {
 	}
 
 	opName := l.getPrefixOperatorName(expr.Op)
-	retType := l.getType(expr, l.TypeInfo)
-	if retType == nil {
-		retType = &types.Primitive{Kind: types.Int}
+	retType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
 	}
 
 	resultLocal := l.newLocal("", retType)
@@ -258,6 +261,13 @@ func (l *Lowerer) lowerCastExpr(expr *ast.CastExpr) (Operand, error) {
 		return nil, fmt.Errorf("unknown type in cast")
 	}
 
+	// A fieldless enum casts to its tag
+	if _, ok := op.OperandType().(*types.Enum); ok {
+		if _, ok := targetType.(*types.Primitive); ok {
+			return l.lowerEnumTag(op, targetType), nil
+		}
+	}
+
 	// Create result local
 	resultLocal := l.newLocal("", targetType)
 	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
@@ -271,3 +281,19 @@ func (l *Lowerer) lowerCastExpr(expr *ast.CastExpr) (Operand, error) {
 
 	return &LocalRef{Local: resultLocal}, nil
 }
+
+// lowerEnumTag loads the tag, the variant index, of the enum value target
+// as a value of type typ
+func (l *Lowerer) lowerEnumTag(target Operand, typ types.Type) Operand {
+	if typ == nil {
+		typ = &types.Primitive{Kind: types.Int}
+	}
+	resultLocal := l.newLocal("", typ)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &Discriminant{
+		Result: resultLocal,
+		Target: target,
+	})
+	return &LocalRef{Local: resultLocal}
+}
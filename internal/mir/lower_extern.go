@@ -0,0 +1,47 @@
+package mir
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// declareExtern records the C function declared by decl in the module,
+// unless it already is.
+func (l *Lowerer) declareExtern(decl *ast.FnDecl) {
+	if l.Module == nil {
+		return
+	}
+	for _, ext := range l.Module.Externs {
+		if ext.Name == decl.Name.Name {
+			return
+		}
+	}
+
+	ext := &Extern{Name: decl.Name.Name, ReturnType: l.getReturnType(decl)}
+	if fnType, ok := l.TypeInfo[decl].(*types.Function); ok {
+		ext.Params = fnType.Params
+	}
+	l.Module.Externs = append(l.Module.Externs, ext)
+}
+
+// lowerExternBody gives fn, lowered from the extern function decl, a body
+// that calls the C function. Modules call their functions under qualified
+// names, which the C symbol cannot have.
+func (l *Lowerer) lowerExternBody(decl *ast.FnDecl, fn *Function) {
+	l.declareExtern(decl)
+
+	args := make([]Operand, len(fn.Params))
+	for i, param := range fn.Params {
+		args[i] = &LocalRef{Local: param}
+	}
+	call := &Call{Func: decl.Name.Name, Args: args}
+	ret := &Return{}
+	if fn.ReturnType != nil {
+		call.Result = l.newLocal("", fn.ReturnType)
+		fn.Locals = append(fn.Locals, call.Result)
+		ret.Value = &LocalRef{Local: call.Result}
+	}
+
+	fn.Entry.Statements = append(fn.Entry.Statements, call)
+	fn.Entry.Terminator = ret
+}
@@ -34,11 +34,24 @@ func (l *Lowerer) newBlock(label string) *BasicBlock {
 
 func (l *Lowerer) getType(node ast.Node, typeInfo map[ast.Node]types.Type) types.Type {
 	if typ, ok := typeInfo[node]; ok {
+		if l.selfType != nil {
+			// The checker typed trait default bodies with Self abstract
+			return types.Substitute(typ, map[string]types.Type{"Self": l.selfType})
+		}
 		return typ
 	}
 	return nil
 }
 
+// typeOf returns the type the checker recorded for node. Lowering never
+// guesses a missing type: it is a checker bug, reported as an error.
+func (l *Lowerer) typeOf(node ast.Node) (types.Type, error) {
+	if typ := l.getType(node, l.TypeInfo); typ != nil {
+		return typ, nil
+	}
+	return nil, types.MissingTypeError(node)
+}
+
 func (l *Lowerer) getReturnType(decl *ast.FnDecl) types.Type {
 	// Try to get from type info
 	if typ, ok := l.TypeInfo[decl]; ok {
@@ -70,6 +83,11 @@ func (l *Lowerer) getCalleeName(callee ast.Expr) string {
 	}
 	if infix, ok := callee.(*ast.InfixExpr); ok && infix.Op == "::" {
 		left := l.getCalleeName(infix.Left)
+		if ident, ok := infix.Left.(*ast.Ident); ok {
+			if self := l.selfTypeName(ident); self != "" {
+				left = self
+			}
+		}
 		right := l.getCalleeName(infix.Right)
 		if left != "" && right != "" {
 			return left + "::" + right
@@ -96,6 +114,23 @@ func (l *Lowerer) getCalleeName(callee ast.Expr) string {
 	return ""
 }
 
+// selfTypeName returns the name of the type whose associated function the
+// path left::f calls: Point for Default::default() with Self fixed to Point,
+// and a bound of T for T::default(), which monomorphization replaces.
+func (l *Lowerer) selfTypeName(left *ast.Ident) string {
+	switch t := l.getType(left, l.TypeInfo).(type) {
+	case *types.TypeParam:
+		if t.Name != left.Name {
+			// Default::default() with Self fixed to a type parameter
+			return left.Name
+		}
+		return l.getTypeName(t)
+	case *types.Struct, *types.Enum, *types.GenericInstance:
+		return l.getTypeName(t)
+	}
+	return ""
+}
+
 func (l *Lowerer) getOperatorName(op lexer.TokenType) string {
 	// Map operators to function names
 	opMap := map[lexer.TokenType]string{
@@ -168,11 +203,58 @@ func (l *Lowerer) getTypeName(typ types.Type) string {
 		return l.getTypeName(t.Elem)
 	case *types.Trait:
 		return t.Name
+	case *types.Primitive:
+		// Methods on string come from stdlib/string.mal (impl string)
+		if t.Kind == types.String {
+			return "string"
+		}
+		return ""
 	default:
 		return ""
 	}
 }
 
+// jumpTo ends the current block with a jump to target, unless the block
+// already ended. A block begun after a call that never returns ends with
+// Unreachable instead, so that a diverging branch does not make target
+// reachable.
+func (l *Lowerer) jumpTo(target *BasicBlock) {
+	switch {
+	case l.currentBlock.Terminator != nil:
+	case l.diverged[l.currentBlock]:
+		l.currentBlock.Terminator = &Unreachable{}
+	default:
+		l.currentBlock.Terminator = &Goto{Target: target}
+	}
+}
+
+// hasPredecessor reports whether control can reach block, either because it
+// is the entry block or because another block of fn jumps to it
+func hasPredecessor(fn *Function, block *BasicBlock) bool {
+	if block == fn.Entry {
+		return true
+	}
+	for _, b := range fn.Blocks {
+		switch t := b.Terminator.(type) {
+		case *Goto:
+			if t.Target == block {
+				return true
+			}
+		case *Branch:
+			if t.True == block || t.False == block {
+				return true
+			}
+		case *Select:
+			for _, c := range t.Cases {
+				if c.Target == block {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 func parseInt(text string) (int64, error) {
 	// Try parsing as int64
 	val, err := strconv.ParseInt(text, 10, 64)
@@ -0,0 +1,82 @@
+package mir
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// lowerValue lowers expr where its value is bound by a let or stored by an
+// assignment. Structs live behind a pointer, so reading one out of a
+// variable, field or element shares it with its source. That is all a move
+// needs, since the checker rejects later uses of the source. A #[copy]
+// struct can still be used and changed through its source, so it is copied
+// instead.
+func (l *Lowerer) lowerValue(expr ast.Expr) (Operand, error) {
+	value, err := l.lowerExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	switch expr.(type) {
+	case *ast.Ident, *ast.FieldExpr, *ast.IndexExpr:
+		return l.copyValue(value, l.getType(expr, l.TypeInfo)), nil
+	}
+	// Anything else, like a literal or a call, is a fresh value
+	return value, nil
+}
+
+// copyValue copies value if it is a #[copy] struct, along with the #[copy]
+// structs in its fields, and returns it unchanged otherwise.
+func (l *Lowerer) copyValue(value Operand, typ types.Type) Operand {
+	s, subst := copyStruct(typ)
+	if s == nil {
+		return value
+	}
+	fields := make(map[string]Operand, len(s.Fields))
+	for _, field := range s.Fields {
+		fieldType := field.Type
+		if subst != nil {
+			fieldType = types.Substitute(fieldType, subst)
+		}
+		loaded := l.newLocal("", fieldType)
+		l.currentFunc.Locals = append(l.currentFunc.Locals, loaded)
+		l.currentBlock.Statements = append(l.currentBlock.Statements, &LoadField{
+			Result: loaded,
+			Target: value,
+			Field:  field.Name,
+		})
+		fields[field.Name] = l.copyValue(&LocalRef{Local: loaded}, fieldType)
+	}
+	result := l.newLocal("", typ)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, result)
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &ConstructStruct{
+		Result: result,
+		Type:   typ,
+		Fields: fields,
+	})
+	return &LocalRef{Local: result}
+}
+
+// copyStruct returns the struct typ is if it is declared #[copy], with the
+// substitution for its type parameters if typ is an instance of it.
+func copyStruct(typ types.Type) (*types.Struct, map[string]types.Type) {
+	if named, ok := typ.(*types.Named); ok && named.Ref != nil {
+		typ = named.Ref
+	}
+	switch t := typ.(type) {
+	case *types.Struct:
+		if t.Copy && len(t.TypeParams) == 0 {
+			return t, nil
+		}
+	case *types.GenericInstance:
+		s, ok := t.Base.(*types.Struct)
+		if !ok || !s.Copy || len(s.TypeParams) != len(t.Args) {
+			return nil, nil
+		}
+		subst := make(map[string]types.Type, len(t.Args))
+		for i, param := range s.TypeParams {
+			subst[param.Name] = t.Args[i]
+		}
+		return s, subst
+	}
+	return nil, nil
+}
@@ -16,22 +16,19 @@ func (l *Lowerer) lowerSpawnStmt(stmt *ast.SpawnStmt) error {
 
 	if stmt.Call != nil {
 		// Form 1: spawn worker(args)
-		// Extract function name
-		funcName = l.getCalleeName(stmt.Call.Callee)
-		if funcName == "" {
-			return fmt.Errorf("cannot determine function name for spawn")
-		}
-
-		// Lower arguments
-		args, err = l.lowerArgs(stmt.Call.Args)
+		spawn, err := l.lowerSpawnCall(stmt.Call)
 		if err != nil {
 			return err
 		}
-
+		l.currentBlock.Statements = append(l.currentBlock.Statements, spawn)
+		return nil
 	} else if stmt.Block != nil {
 		// Form 2: spawn { ... }
 		// Create an anonymous function for the block
-		funcName = l.createBlockWrapper(stmt.Block)
+		funcName, err = l.createBlockWrapper(stmt.Block)
+		if err != nil {
+			return err
+		}
 
 		// No explicit arguments, but we might need to capture free variables
 		// For now, captured variables are handled in the wrapper generation phase (MIR→LLVM)
@@ -40,7 +37,10 @@ func (l *Lowerer) lowerSpawnStmt(stmt *ast.SpawnStmt) error {
 	} else if stmt.FunctionLiteral != nil {
 		// Form 3: spawn |x| { ... }(args)
 		// Create an anonymous function for the literal
-		funcName = l.createFunctionLiteralWrapper(stmt.FunctionLiteral)
+		funcName, err = l.createFunctionLiteralWrapper(stmt.FunctionLiteral)
+		if err != nil {
+			return err
+		}
 
 		// Lower the arguments passed to the function literal
 		args, err = l.lowerArgs(stmt.Args)
@@ -52,26 +52,73 @@ func (l *Lowerer) lowerSpawnStmt(stmt *ast.SpawnStmt) error {
 		return fmt.Errorf("spawn statement must have a call, block, or function literal")
 	}
 
-	// Get type arguments if this is a generic function call
-	var typeArgs []types.Type
-	if stmt.Call != nil {
-		if callTypeArgs, ok := l.CallTypeArgs[stmt.Call]; ok {
-			typeArgs = callTypeArgs
-		}
-	}
-
 	// Add Spawn instruction to current block
 	l.currentBlock.Statements = append(l.currentBlock.Statements, &Spawn{
-		Func:     funcName,
-		Args:     args,
-		TypeArgs: typeArgs,
+		Func: funcName,
+		Args: args,
 	})
 
 	return nil
 }
 
+// lowerSpawnCall builds the Spawn instruction for `spawn worker(args)`
+func (l *Lowerer) lowerSpawnCall(call *ast.CallExpr) (*Spawn, error) {
+	funcName := l.getCalleeName(call.Callee)
+	if funcName == "" {
+		return nil, fmt.Errorf("cannot determine function name for spawn")
+	}
+
+	args, err := l.lowerArgs(call.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get type arguments if this is a generic function call
+	return &Spawn{
+		Func:     funcName,
+		Args:     args,
+		TypeArgs: l.CallTypeArgs[call],
+	}, nil
+}
+
+// lowerSpawnExpr lowers `spawn worker(args)` in expression position to a
+// Spawn that yields the legion's JoinHandle
+func (l *Lowerer) lowerSpawnExpr(expr *ast.SpawnExpr) (Operand, error) {
+	spawn, err := l.lowerSpawnCall(expr.Call)
+	if err != nil {
+		return nil, err
+	}
+
+	handleType, err := l.typeOf(expr)
+	if err != nil {
+		return nil, err
+	}
+	resultLocal := l.newLocal("", handleType)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+	spawn.Result = &resultLocal
+
+	l.currentBlock.Statements = append(l.currentBlock.Statements, spawn)
+	return &LocalRef{Local: resultLocal}, nil
+}
+
+// lowerJoin lowers `handle.join()`
+func (l *Lowerer) lowerJoin(target ast.Expr, handle *types.JoinHandle) (Operand, error) {
+	handleOp, err := l.lowerExpr(target)
+	if err != nil {
+		return nil, err
+	}
+
+	resultLocal := l.newLocal("", handle.Elem)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &Join{
+		Result: resultLocal,
+		Handle: handleOp,
+	})
+	return &LocalRef{Local: resultLocal}, nil
+}
+
 // createBlockWrapper creates a MIR function for a spawn block
-func (l *Lowerer) createBlockWrapper(block *ast.BlockExpr) string {
+func (l *Lowerer) createBlockWrapper(block *ast.BlockExpr) (string, error) {
 	// Generate unique function name
 	funcName := fmt.Sprintf("spawn_block_%d", l.localCounter)
 	l.localCounter++
@@ -99,21 +146,22 @@ func (l *Lowerer) createBlockWrapper(block *ast.BlockExpr) string {
 	oldFunc := l.currentFunc
 	oldBlock := l.currentBlock
 	oldLocals := l.locals
+	oldDrops := l.drops
 
 	// Set up new context for lowering the block
 	l.currentFunc = mirFunc
 	l.currentBlock = entryBlock
 	l.locals = make(map[string]Local)
+	l.drops = newDropState()
 
 	// Lower the block statements
 	for _, stmt := range block.Stmts {
 		if err := l.lowerStmt(stmt); err != nil {
-			// If lowering fails, restore state and return error name
-			// In production, we'd propagate the error properly
 			l.currentFunc = oldFunc
 			l.currentBlock = oldBlock
 			l.locals = oldLocals
-			return funcName // Return name anyway for now
+			l.drops = oldDrops
+			return "", err
 		}
 	}
 
@@ -126,30 +174,38 @@ func (l *Lowerer) createBlockWrapper(block *ast.BlockExpr) string {
 	l.currentFunc = oldFunc
 	l.currentBlock = oldBlock
 	l.locals = oldLocals
+	l.drops = oldDrops
 
 	// Add the new function to the module
 	l.Module.Functions = append(l.Module.Functions, mirFunc)
 
-	return funcName
+	return funcName, nil
 }
 
 // createFunctionLiteralWrapper creates a MIR function for a spawn function literal
-func (l *Lowerer) createFunctionLiteralWrapper(lit *ast.FunctionLiteral) string {
+func (l *Lowerer) createFunctionLiteralWrapper(lit *ast.FunctionLiteral) (string, error) {
 	// Generate unique function name
 	funcName := fmt.Sprintf("spawn_lambda_%d", l.localCounter)
 	l.localCounter++
 
 	// Create parameters from the function literal
+	fnType, err := l.typeOf(lit)
+	if err != nil {
+		return "", err
+	}
+	sig, ok := fnType.(*types.Function)
+	if !ok || len(sig.Params) != len(lit.Params) {
+		return "", fmt.Errorf("function literal has type `%s`", fnType)
+	}
 	params := make([]Local, len(lit.Params))
 	for i, param := range lit.Params {
-		paramType := l.getType(param, l.TypeInfo)
-		if paramType == nil {
-			paramType = &types.Primitive{Kind: types.Int} // fallback
+		if sig.Params[i] == nil {
+			return "", types.MissingTypeError(param)
 		}
 		params[i] = Local{
 			ID:   i,
 			Name: param.Name.Name,
-			Type: paramType,
+			Type: sig.Params[i],
 		}
 	}
 
@@ -179,11 +235,13 @@ func (l *Lowerer) createFunctionLiteralWrapper(lit *ast.FunctionLiteral) string
 	oldFunc := l.currentFunc
 	oldBlock := l.currentBlock
 	oldLocals := l.locals
+	oldDrops := l.drops
 
 	// Set up new context
 	l.currentFunc = mirFunc
 	l.currentBlock = entryBlock
 	l.locals = make(map[string]Local)
+	l.drops = newDropState()
 
 	// Add parameters to locals
 	for _, param := range params {
@@ -197,7 +255,8 @@ func (l *Lowerer) createFunctionLiteralWrapper(lit *ast.FunctionLiteral) string
 			l.currentFunc = oldFunc
 			l.currentBlock = oldBlock
 			l.locals = oldLocals
-			return funcName
+			l.drops = oldDrops
+			return "", err
 		}
 	}
 
@@ -210,11 +269,12 @@ func (l *Lowerer) createFunctionLiteralWrapper(lit *ast.FunctionLiteral) string
 	l.currentFunc = oldFunc
 	l.currentBlock = oldBlock
 	l.locals = oldLocals
+	l.drops = oldDrops
 
 	// Add function to module
 	l.Module.Functions = append(l.Module.Functions, mirFunc)
 
-	return funcName
+	return funcName, nil
 }
 
 // lowerArgs lowers a slice of argument expressions to operands
@@ -0,0 +1,58 @@
+package mir
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// declareStatics records the statics declared in file in the module, with
+// the constant values the checker computed for their initializers.
+func (l *Lowerer) declareStatics(file *ast.File) {
+	for _, decl := range file.Decls {
+		d, ok := decl.(*ast.StaticDecl)
+		if !ok {
+			continue
+		}
+		static := &Static{
+			Name:    d.Name.Name,
+			Type:    l.staticType(d),
+			Value:   l.Consts[d.Value],
+			Mutable: d.Mutable,
+		}
+		l.Module.Statics = append(l.Module.Statics, static)
+	}
+}
+
+// staticType returns the declared type of the static d
+func (l *Lowerer) staticType(d *ast.StaticDecl) types.Type {
+	if l.GlobalScope != nil {
+		if sym := l.GlobalScope.Lookup(d.Name.Name); sym != nil && sym.Type != nil {
+			return sym.Type
+		}
+	}
+	return l.getType(d.Value, l.TypeInfo)
+}
+
+// findStatic returns the static of the module named name, or nil
+func (l *Lowerer) findStatic(name string) *Static {
+	if l.Module == nil {
+		return nil
+	}
+	for _, static := range l.Module.Statics {
+		if static.Name == name {
+			return static
+		}
+	}
+	return nil
+}
+
+// loadStatic reads the static into a new local
+func (l *Lowerer) loadStatic(static *Static) Operand {
+	result := l.newLocal("", static.Type)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, result)
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &LoadStatic{
+		Result: result,
+		Name:   static.Name,
+	})
+	return &LocalRef{Local: result}
+}
@@ -55,17 +55,26 @@ func (l *Lowerer) lowerSelectStmt(stmt *ast.SelectStmt) error {
 		var mirCase SelectCase
 		mirCase.Target = bodyBlock
 
-		if astCase.Comm == nil {
+		if astCase.After != nil {
+			// Timeout case; evaluated with the other operands on entry
+			mirCase.Kind = "after"
+			timeoutOp, err := l.lowerExpr(astCase.After)
+			if err != nil {
+				return err
+			}
+			if _, ok := timeoutOp.OperandType().(*types.Primitive); !ok {
+				// The checker only lets through a std::time Duration
+				timeoutOp = l.durationMillis(timeoutOp)
+			}
+			mirCase.Timeout = timeoutOp
+		} else if astCase.Comm == nil {
 			// Default case
 			mirCase.Kind = "default"
 		} else {
 			// Send or Receive
 			switch comm := astCase.Comm.(type) {
 			case *ast.ExprStmt:
-				// Check for "default" identifier
-				if ident, ok := comm.Expr.(*ast.Ident); ok && ident.Name == "default" {
-					mirCase.Kind = "default"
-				} else if infix, ok := comm.Expr.(*ast.InfixExpr); ok && infix.Op == lexer.LARROW {
+				if infix, ok := comm.Expr.(*ast.InfixExpr); ok && infix.Op == lexer.LARROW {
 					// Send: ch <- val
 					mirCase.Kind = "send"
 
@@ -122,9 +131,9 @@ func (l *Lowerer) lowerSelectStmt(stmt *ast.SelectStmt) error {
 					// We need to define this local so it can be used in the body block
 					varType := l.getType(comm, l.TypeInfo)
 					if varType == nil {
-						varType = l.getType(comm.Value, l.TypeInfo)
-						if varType == nil {
-							varType = &types.Primitive{Kind: types.Int} // Fallback
+						varType, err = l.typeOf(comm.Value)
+						if err != nil {
+							return err
 						}
 					}
 
@@ -199,9 +208,7 @@ func (l *Lowerer) lowerSelectStmt(stmt *ast.SelectStmt) error {
 		}
 
 		// Jump to merge block
-		if l.currentBlock.Terminator == nil {
-			l.currentBlock.Terminator = &Goto{Target: mergeBlock}
-		}
+		l.jumpTo(mergeBlock)
 
 		// Restore current block to header for next case analysis
 		l.currentBlock = headerBlock
@@ -216,10 +223,25 @@ func (l *Lowerer) lowerSelectStmt(stmt *ast.SelectStmt) error {
 	return nil
 }
 
+// durationMillis returns the std::time Duration d in whole milliseconds,
+// rounded up, so that a select never times out before d has passed
+func (l *Lowerer) durationMillis(d Operand) Operand {
+	ns := l.newLocal("", types.TypeInt)
+	rounded := l.newLocal("", types.TypeInt)
+	ms := l.newLocal("", types.TypeInt)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, ns, rounded, ms)
+	l.currentBlock.Statements = append(l.currentBlock.Statements,
+		&LoadField{Result: ns, Target: d, Field: "ns"},
+		&Call{Result: rounded, Func: "__add__", Args: []Operand{&LocalRef{Local: ns}, &Literal{Type: types.TypeInt, Value: int64(999999)}}},
+		&Call{Result: ms, Func: "__div__", Args: []Operand{&LocalRef{Local: rounded}, &Literal{Type: types.TypeInt, Value: int64(1000000)}}},
+	)
+	return &LocalRef{Local: ms}
+}
+
 // lowerLetStmt lowers a let statement
 func (l *Lowerer) lowerLetStmt(stmt *ast.LetStmt) error {
 	// Lower the RHS expression
-	rhs, err := l.lowerExpr(stmt.Value)
+	rhs, err := l.lowerValue(stmt.Value)
 	if err != nil {
 		return err
 	}
@@ -228,9 +250,9 @@ func (l *Lowerer) lowerLetStmt(stmt *ast.LetStmt) error {
 	varType := l.getType(stmt, l.TypeInfo)
 	if varType == nil {
 		// Infer from RHS
-		varType = l.getType(stmt.Value, l.TypeInfo)
-		if varType == nil {
-			varType = &types.Primitive{Kind: types.Int}
+		varType, err = l.typeOf(stmt.Value)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -244,6 +266,7 @@ func (l *Lowerer) lowerLetStmt(stmt *ast.LetStmt) error {
 		Local: local,
 		RHS:   rhs,
 	})
+	l.trackDrop(local)
 
 	return nil
 }
@@ -259,7 +282,15 @@ func (l *Lowerer) lowerReturnStmt(stmt *ast.ReturnStmt) error {
 		}
 	}
 
-	l.currentBlock.Terminator = &Return{Value: value}
+	// Every variable still in scope is dropped once the value is computed
+	l.dropScopesFrom(0)
+
+	ret := &Return{Value: value}
+	if ast.HasAttribute(stmt.Attrs, "tailcall") {
+		ret.TailCall = true
+		ret.Span = stmt.Span()
+	}
+	l.currentBlock.Terminator = ret
 	return nil
 }
 
@@ -292,8 +323,9 @@ func (l *Lowerer) lowerWhileStmt(stmt *ast.WhileStmt) error {
 
 	// Create loop context
 	loopCtx := &LoopContext{
-		Header: loopHeader,
-		End:    loopEnd,
+		Header:    loopHeader,
+		End:       loopEnd,
+		DropDepth: len(l.drops.scopes),
 	}
 
 	// Push loop context onto stack
@@ -306,22 +338,28 @@ func (l *Lowerer) lowerWhileStmt(stmt *ast.WhileStmt) error {
 	// Jump from current block to loop header
 	l.currentBlock.Terminator = &Goto{Target: loopHeader}
 
-	// Loop header: check condition
+	// Loop header: check condition. `while true` only ends through a break,
+	// so nothing else jumps to the end and the code after an endless loop is
+	// known to be unreachable.
 	l.currentBlock = loopHeader
-	condition, err := l.lowerExpr(stmt.Condition)
-	if err != nil {
-		return err
-	}
+	if lit, ok := stmt.Condition.(*ast.BoolLit); ok && lit.Value {
+		loopHeader.Terminator = &Goto{Target: loopBody}
+	} else {
+		condition, err := l.lowerExpr(stmt.Condition)
+		if err != nil {
+			return err
+		}
 
-	loopHeader.Terminator = &Branch{
-		Condition: condition,
-		True:      loopBody,
-		False:     loopEnd,
+		loopHeader.Terminator = &Branch{
+			Condition: condition,
+			True:      loopBody,
+			False:     loopEnd,
+		}
 	}
 
 	// Loop body
 	l.currentBlock = loopBody
-	_, err = l.lowerBlock(stmt.Body)
+	_, err := l.lowerBlock(stmt.Body)
 	if err != nil {
 		return err
 	}
@@ -338,117 +376,222 @@ func (l *Lowerer) lowerWhileStmt(stmt *ast.WhileStmt) error {
 	return nil
 }
 
-// lowerForStmt lowers a for loop
+// lowerForStmt lowers a for loop over a channel, slice, array or map, the
+// iterables the checker accepts
 func (l *Lowerer) lowerForStmt(stmt *ast.ForStmt) error {
-	// For loops iterate over an iterable (slice, array, map, etc.)
-	// Uses iterator protocol: has_next() and next() methods
+	iterableType, err := l.typeOf(stmt.Iterable)
+	if err != nil {
+		return err
+	}
+	switch t := iterableType.(type) {
+	case *types.Channel:
+		return l.lowerChannelForStmt(stmt, t)
+	case *types.Slice:
+		return l.lowerSliceForStmt(stmt, t.Elem)
+	case *types.Array:
+		return l.lowerSliceForStmt(stmt, t.Elem)
+	case *types.Map:
+		return l.lowerSliceForStmt(stmt, t.Key)
+	case *types.GenericInstance:
+		var elem types.Type
+		switch base := t.Base.(type) {
+		case *types.Slice:
+			elem = base.Elem
+		case *types.Array:
+			elem = base.Elem
+		}
+		if elem != nil {
+			if len(t.Args) > 0 {
+				elem = t.Args[0]
+			}
+			return l.lowerSliceForStmt(stmt, elem)
+		}
+	}
+	return fmt.Errorf("cannot lower a for loop over `%s`", iterableType)
+}
 
-	// Lower the iterable expression
-	iterable, err := l.lowerExpr(stmt.Iterable)
+// lowerChannelForStmt lowers `for x in ch { ... }`: receive in the header and
+// leave the loop once the channel is closed and drained
+func (l *Lowerer) lowerChannelForStmt(stmt *ast.ForStmt, ch *types.Channel) error {
+	chOp, err := l.lowerExpr(stmt.Iterable)
 	if err != nil {
 		return err
 	}
 
-	// Create basic blocks for the loop structure
 	loopHeader := l.newBlock("for_header")
 	loopBody := l.newBlock("for_body")
 	loopEnd := l.newBlock("for_end")
-
 	l.currentFunc.Blocks = append(l.currentFunc.Blocks, loopHeader, loopBody, loopEnd)
 
-	// Create loop context
-	loopCtx := &LoopContext{
-		Header: loopHeader,
-		End:    loopEnd,
-	}
-
-	// Push loop context onto stack
-	l.loopStack = append(l.loopStack, loopCtx)
+	l.loopStack = append(l.loopStack, &LoopContext{
+		Header:    loopHeader,
+		End:       loopEnd,
+		DropDepth: len(l.drops.scopes),
+	})
 	defer func() {
-		// Pop loop context when done
 		l.loopStack = l.loopStack[:len(l.loopStack)-1]
 	}()
 
-	// Jump from current block to loop header
 	l.currentBlock.Terminator = &Goto{Target: loopHeader}
 
-	// Loop header: check if we have more items
-	// Create iterator local by calling into_iter() on the iterable
-	// In a full implementation with proper types, we'd resolve the actual iterator type
-	// For now, use a simplified approach
-	iteratorType := &types.Primitive{Kind: types.Int} // Placeholder type
-	iterator := l.newLocal("iterator", iteratorType)
-	l.currentFunc.Locals = append(l.currentFunc.Locals, iterator)
-
-	// Call into_iter() to get the iterator
-	// In a full implementation, this would resolve the IntoIterator trait method
-	l.currentBlock.Statements = append(l.currentBlock.Statements, &Call{
-		Result: iterator,
-		Func:   "into_iter", // Trait method - would be resolved by type system
-		Args:   []Operand{iterable},
+	// Header: received := ch.recv(); continue while it is non-nil
+	received := l.newLocal("", &types.Optional{Elem: ch.Elem})
+	hasMore := l.newLocal("", &types.Primitive{Kind: types.Bool})
+	l.currentFunc.Locals = append(l.currentFunc.Locals, received, hasMore)
+	loopHeader.Statements = append(loopHeader.Statements,
+		&Receive{Result: received, Channel: chOp, Optional: true},
+		&Call{
+			Result: hasMore,
+			Func:   "__ne__",
+			Args:   []Operand{&LocalRef{Local: received}, &Literal{Type: &types.Primitive{Kind: types.Nil}, Value: nil}},
+		},
+	)
+	loopHeader.Terminator = &Branch{
+		Condition: &LocalRef{Local: hasMore},
+		True:      loopBody,
+		False:     loopEnd,
+	}
+
+	// Body: bind the iterator variable to the received value
+	l.currentBlock = loopBody
+	item := l.newLocal(stmt.Iterator.Name, ch.Elem)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, item)
+	loopBody.Statements = append(loopBody.Statements, &Load{
+		Result:  item,
+		Address: &LocalRef{Local: received},
 	})
 
-	// Jump to loop header
-	l.currentBlock.Terminator = &Goto{Target: loopHeader}
-	l.currentBlock = loopHeader
+	oldLocal, shadowed := l.locals[stmt.Iterator.Name]
+	l.locals[stmt.Iterator.Name] = item
+	_, err = l.lowerBlock(stmt.Body)
+	if shadowed {
+		l.locals[stmt.Iterator.Name] = oldLocal
+	} else {
+		delete(l.locals, stmt.Iterator.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if l.currentBlock.Terminator == nil {
+		l.currentBlock.Terminator = &Goto{Target: loopHeader}
+	}
+	l.currentBlock = loopEnd
+	return nil
+}
+
+// lowerSliceForStmt lowers `for x in s { ... }` over a slice or array, or
+// over the keys of a map, by counting an index up to the length:
+//
+//	  seq = s (or __map_keys__(m)); i = 0
+//	for_header:
+//	  n = len(seq); if i < n goto for_body else goto for_end
+//	for_body:
+//	  x = seq[i]; ...; goto for_step
+//	for_step:
+//	  i = i + 1; goto for_header
+//
+// `continue` goes to for_step. Keys are collected before the first
+// iteration, so the body may insert into or remove from the map.
+func (l *Lowerer) lowerSliceForStmt(stmt *ast.ForStmt, elem types.Type) error {
+	seq, err := l.lowerExpr(stmt.Iterable)
+	if err != nil {
+		return err
+	}
+	if _, ok := seq.OperandType().(*types.Map); ok {
+		keys := l.newLocal("", &types.Slice{Elem: elem})
+		l.currentFunc.Locals = append(l.currentFunc.Locals, keys)
+		l.currentBlock.Statements = append(l.currentBlock.Statements, &Call{
+			Result: keys,
+			Func:   "__map_keys__",
+			Args:   []Operand{seq},
+		})
+		seq = &LocalRef{Local: keys}
+	}
+
+	// An array is iterated as it was when the loop started, and its length
+	// is part of its type
+	arr, isArray := seq.OperandType().(*types.Array)
+	if isArray {
+		snapshot := l.newLocal("", arr)
+		l.currentFunc.Locals = append(l.currentFunc.Locals, snapshot)
+		l.currentBlock.Statements = append(l.currentBlock.Statements, &Assign{Local: snapshot, RHS: seq})
+		seq = &LocalRef{Local: snapshot}
+	}
+
+	loopHeader := l.newBlock("for_header")
+	loopBody := l.newBlock("for_body")
+	loopStep := l.newBlock("for_step")
+	loopEnd := l.newBlock("for_end")
+	l.currentFunc.Blocks = append(l.currentFunc.Blocks, loopHeader, loopBody, loopStep, loopEnd)
 
-	// Push loop context
 	l.loopStack = append(l.loopStack, &LoopContext{
-		Header: loopHeader,
-		End:    loopEnd,
+		Header:    loopStep,
+		End:       loopEnd,
+		DropDepth: len(l.drops.scopes),
 	})
+	defer func() {
+		l.loopStack = l.loopStack[:len(l.loopStack)-1]
+	}()
 
-	// Call has_next() on the iterator
-	hasMore := l.newLocal("has_more", &types.Primitive{Kind: types.Bool})
-	l.currentFunc.Locals = append(l.currentFunc.Locals, hasMore)
-
-	loopHeader.Statements = append(loopHeader.Statements, &Call{
-		Result: hasMore,
-		Func:   "has_next", // Iterator trait method
-		Args:   []Operand{&LocalRef{Local: iterator}},
+	index := l.newLocal("", types.TypeInt)
+	length := l.newLocal("", types.TypeInt)
+	hasMore := l.newLocal("", types.TypeBool)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, index, length, hasMore)
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &Assign{
+		Local: index,
+		RHS:   &Literal{Type: types.TypeInt, Value: int64(0)},
 	})
+	l.currentBlock.Terminator = &Goto{Target: loopHeader}
 
-	// Conditional branch based on has_next result
+	var lengthStmt Statement = &Call{Result: length, Func: "len", Args: []Operand{seq}}
+	if isArray {
+		lengthStmt = &Assign{Local: length, RHS: &Literal{Type: types.TypeInt, Value: arr.Len}}
+	}
+	loopHeader.Statements = append(loopHeader.Statements,
+		lengthStmt,
+		&Call{Result: hasMore, Func: "__lt__", Args: []Operand{&LocalRef{Local: index}, &LocalRef{Local: length}}},
+	)
 	loopHeader.Terminator = &Branch{
 		Condition: &LocalRef{Local: hasMore},
 		True:      loopBody,
 		False:     loopEnd,
 	}
 
-	l.currentBlock = loopBody
-
-	// Call next() to get the next item
-	itemType := types.TypeInt // Simplified: would need proper type resolution from Iterator::Item
-	nextItem := l.newLocal(stmt.Iterator.Name, itemType)
-	l.currentFunc.Locals = append(l.currentFunc.Locals, nextItem)
-
-	loopBody.Statements = append(loopBody.Statements, &Call{
-		Result: nextItem,
-		Func:   "next", // Iterator trait method - returns Option[T]
-		Args:   []Operand{&LocalRef{Local: iterator}},
+	loopStep.Statements = append(loopStep.Statements, &Call{
+		Result: index,
+		Func:   "__add__",
+		Args:   []Operand{&LocalRef{Local: index}, &Literal{Type: types.TypeInt, Value: int64(1)}},
 	})
+	loopStep.Terminator = &Goto{Target: loopHeader}
 
-	// In a full implementation, we would need to unwrap the Option here
-	// For now, assume next() directly returns the value
+	// Body: bind the iterator variable to the current element
+	l.currentBlock = loopBody
+	item := l.newLocal(stmt.Iterator.Name, elem)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, item)
+	loopBody.Statements = append(loopBody.Statements, &LoadIndex{
+		Result:   item,
+		Target:   seq,
+		Indices:  []Operand{&LocalRef{Local: index}},
+		InBounds: isArray,
+	})
 
-	// Lower loop body
+	oldLocal, shadowed := l.locals[stmt.Iterator.Name]
+	l.locals[stmt.Iterator.Name] = item
 	_, err = l.lowerBlock(stmt.Body)
+	if shadowed {
+		l.locals[stmt.Iterator.Name] = oldLocal
+	} else {
+		delete(l.locals, stmt.Iterator.Name)
+	}
 	if err != nil {
 		return err
 	}
 
-	// If body doesn't have a terminator (no break/continue), goto header
-	// If current block doesn't have a terminator (no break/continue), goto header
 	if l.currentBlock.Terminator == nil {
-		l.currentBlock.Terminator = &Goto{Target: loopHeader}
+		l.currentBlock.Terminator = &Goto{Target: loopStep}
 	}
-
-	// Pop loop context
-	l.loopStack = l.loopStack[:len(l.loopStack)-1]
-
-	// Set current block to end
 	l.currentBlock = loopEnd
-
 	return nil
 }
 
@@ -461,7 +604,8 @@ func (l *Lowerer) lowerBreakStmt(stmt *ast.BreakStmt) error {
 	// Get the innermost loop context
 	loopCtx := l.loopStack[len(l.loopStack)-1]
 
-	// Break jumps to loop end
+	// Break jumps to loop end, leaving the scopes inside the loop
+	l.dropScopesFrom(loopCtx.DropDepth)
 	l.currentBlock.Terminator = &Goto{Target: loopCtx.End}
 
 	return nil
@@ -476,7 +620,8 @@ func (l *Lowerer) lowerContinueStmt(stmt *ast.ContinueStmt) error {
 	// Get the innermost loop context
 	loopCtx := l.loopStack[len(l.loopStack)-1]
 
-	// Continue jumps to loop header
+	// Continue jumps to loop header, leaving the scopes inside the loop
+	l.dropScopesFrom(loopCtx.DropDepth)
 	l.currentBlock.Terminator = &Goto{Target: loopCtx.Header}
 
 	return nil
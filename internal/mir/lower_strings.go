@@ -0,0 +1,69 @@
+package mir
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// `+` on strings concatenates. Two strings are joined by the runtime
+// directly, but a chain like `a + b + c + d` would copy its growing prefix
+// once per `+`, so a chain of three or more pieces is lowered to appends to
+// one runtime string builder instead, which copies each piece once.
+
+// isStringConcat reports whether expr is a `+` of two strings
+func (l *Lowerer) isStringConcat(expr ast.Expr) bool {
+	infix, ok := expr.(*ast.InfixExpr)
+	if !ok || infix.Op != lexer.PLUS {
+		return false
+	}
+	p, ok := l.getType(infix, l.TypeInfo).(*types.Primitive)
+	return ok && p.Kind == types.String
+}
+
+// stringConcatPieces appends the strings concatenated by expr to pieces, in
+// evaluation order, looking through nested string `+` on either side.
+func (l *Lowerer) stringConcatPieces(expr ast.Expr, pieces []ast.Expr) []ast.Expr {
+	if !l.isStringConcat(expr) {
+		return append(pieces, expr)
+	}
+	infix := expr.(*ast.InfixExpr)
+	pieces = l.stringConcatPieces(infix.Left, pieces)
+	return l.stringConcatPieces(infix.Right, pieces)
+}
+
+// lowerStringConcat lowers a chain of string `+` rooted at expr
+func (l *Lowerer) lowerStringConcat(expr *ast.InfixExpr) (Operand, error) {
+	pieces := l.stringConcatPieces(expr, nil)
+	ops := make([]Operand, 0, len(pieces))
+	for _, piece := range pieces {
+		op, err := l.lowerExpr(piece)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+
+	if len(ops) == 2 {
+		return l.emitStringCall("__string_concat__", types.TypeString, ops...), nil
+	}
+
+	builder := l.emitStringCall("__string_builder_new__", types.TypeInt, &Literal{Type: types.TypeInt, Value: int64(0)})
+	for _, op := range ops {
+		l.emitStringCall("__string_builder_append__", types.TypeVoid, builder, op)
+	}
+	return l.emitStringCall("__string_builder_build__", types.TypeString, builder), nil
+}
+
+// emitStringCall emits a call to the string intrinsic name and returns its
+// result
+func (l *Lowerer) emitStringCall(name string, retType types.Type, args ...Operand) Operand {
+	resultLocal := l.newLocal("", retType)
+	l.currentFunc.Locals = append(l.currentFunc.Locals, resultLocal)
+	l.currentBlock.Statements = append(l.currentBlock.Statements, &Call{
+		Result: resultLocal,
+		Func:   name,
+		Args:   args,
+	})
+	return &LocalRef{Local: resultLocal}
+}
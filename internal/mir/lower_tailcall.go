@@ -0,0 +1,251 @@
+package mir
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// eliminateTailCalls rewrites calls a function makes to itself in tail
+// position into assignments to its parameters followed by a jump back to the
+// start of the body, so tail-recursive functions run in constant stack space.
+//
+// A call is in tail position when its result is returned unchanged, possibly
+// through copies and a goto into a block that only returns it. A
+// #[tailcall] return that cannot be rewritten is reported in l.Errors.
+func (l *Lowerer) eliminateTailCalls(module *Module) {
+	reported := make(map[string]bool)
+	for _, fn := range module.Functions {
+		// Generic bodies are rewritten in their specialized copies
+		if len(fn.TypeParams) > 0 || fn.Entry == nil {
+			continue
+		}
+
+		reason := ""
+		switch {
+		case fn.Name == "main":
+			reason = "`main` is called by the runtime and is never turned into a loop"
+		case takesAddress(fn):
+			reason = fmt.Sprintf("`%s` takes the address of a local, and reusing its frame would overwrite the value behind that reference", fn.Name)
+		}
+
+		var sites []tailCallSite
+		for _, block := range fn.Blocks {
+			site, why := findTailCall(fn, block)
+			if why == "" && reason == "" {
+				sites = append(sites, site)
+				continue
+			}
+			ret, ok := block.Terminator.(*Return)
+			if !ok || !ret.TailCall {
+				continue
+			}
+			if why == "" {
+				why = reason
+			}
+			key := fmt.Sprintf("%s:%d:%d", ret.Span.Filename, ret.Span.Start, ret.Span.End)
+			if !reported[key] {
+				reported[key] = true
+				l.Errors = append(l.Errors, tailCallError(fn, ret, why))
+			}
+		}
+
+		if len(sites) > 0 {
+			rewriteTailCalls(fn, sites)
+		}
+	}
+}
+
+// tailCallSite is a block ending in a self tail call: Statements[index] is
+// the call, and everything after it only forwards the call's result
+type tailCallSite struct {
+	block *BasicBlock
+	index int
+	call  *Call
+}
+
+// findTailCall returns the self tail call that ends block, or a reason why
+// the block does not end in one
+func findTailCall(fn *Function, block *BasicBlock) (tailCallSite, string) {
+	// Find the local whose value the function returns once block is left
+	returned, void, ok := returnedLocal(block.Terminator, 0)
+	if !ok {
+		return tailCallSite{}, "the value is not returned directly"
+	}
+
+	for i := len(block.Statements) - 1; i >= 0; i-- {
+		switch s := block.Statements[i].(type) {
+		case *Assign:
+			ref, isCopy := s.RHS.(*LocalRef)
+			if !void && isCopy && s.Local.ID == returned {
+				returned = ref.Local.ID
+				continue
+			}
+		case *Call:
+			if !void && s.Result.ID != returned {
+				break
+			}
+			if s.FuncOperand == nil && strings.HasPrefix(s.Func, "__") {
+				return tailCallSite{}, "the result of the recursive call is used in an expression before it is returned, so the call is not the last thing the function does"
+			}
+			if s.FuncOperand != nil || s.Func != fn.Name || len(s.TypeArgs) > 0 {
+				return tailCallSite{}, fmt.Sprintf("the returned call is not a direct call to `%s`; only calls a function makes to itself can become loops", fn.Name)
+			}
+			if len(s.Args) != len(fn.Params) {
+				return tailCallSite{}, fmt.Sprintf("the call passes %d arguments but `%s` takes %d", len(s.Args), fn.Name, len(fn.Params))
+			}
+			return tailCallSite{block: block, index: i, call: s}, ""
+		}
+		break
+	}
+	return tailCallSite{}, fmt.Sprintf("the returned value is not the result of a call to `%s`", fn.Name)
+}
+
+// returnedLocal follows term to the return it reaches and reports the local
+// that must hold the return value on entry to term's block. Blocks passed
+// through on the way may only copy that value. void is set for returns
+// without a value.
+func returnedLocal(term Terminator, depth int) (id int, void bool, ok bool) {
+	switch t := term.(type) {
+	case *Return:
+		if t.Value == nil {
+			return 0, true, true
+		}
+		ref, isLocal := t.Value.(*LocalRef)
+		if !isLocal {
+			return 0, false, false
+		}
+		return ref.Local.ID, false, true
+	case *Goto:
+		if depth >= 8 || t.Target == nil {
+			return 0, false, false
+		}
+		id, void, ok := returnedLocal(t.Target.Terminator, depth+1)
+		if !ok {
+			return 0, false, false
+		}
+		for i := len(t.Target.Statements) - 1; i >= 0; i-- {
+			assign, isAssign := t.Target.Statements[i].(*Assign)
+			if !isAssign {
+				return 0, false, false
+			}
+			ref, isCopy := assign.RHS.(*LocalRef)
+			if !isCopy || void || assign.Local.ID != id {
+				return 0, false, false
+			}
+			id = ref.Local.ID
+		}
+		return id, void, true
+	}
+	return 0, false, false
+}
+
+// rewriteTailCalls moves the body of fn's entry block into a new loop header
+// and replaces each tail call with parameter assignments and a jump to it.
+// The entry block itself cannot be a jump target.
+func rewriteTailCalls(fn *Function, sites []tailCallSite) {
+	entry := fn.Entry
+	header := &BasicBlock{
+		Label:      "tailrec",
+		Statements: entry.Statements,
+		Terminator: entry.Terminator,
+	}
+	entry.Statements = make([]Statement, 0)
+	entry.Terminator = &Goto{Target: header}
+
+	blocks := make([]*BasicBlock, 0, len(fn.Blocks)+1)
+	for _, block := range fn.Blocks {
+		blocks = append(blocks, block)
+		if block == entry {
+			blocks = append(blocks, header)
+		}
+		for _, stmt := range block.Statements {
+			if phi, ok := stmt.(*Phi); ok {
+				if value, ok := phi.Inputs[entry]; ok {
+					delete(phi.Inputs, entry)
+					phi.Inputs[header] = value
+				}
+			}
+		}
+	}
+	fn.Blocks = blocks
+
+	nextID := 0
+	paramIndex := make(map[int]int, len(fn.Params))
+	for i, param := range fn.Params {
+		paramIndex[param.ID] = i
+		if param.ID >= nextID {
+			nextID = param.ID + 1
+		}
+	}
+	for _, local := range fn.Locals {
+		if local.ID >= nextID {
+			nextID = local.ID + 1
+		}
+	}
+
+	for _, site := range sites {
+		stmts := site.block.Statements[:site.index:site.index]
+		args := make([]Operand, len(site.call.Args))
+		copy(args, site.call.Args)
+
+		// Arguments read parameters that are about to be reassigned, so
+		// any other parameter an argument reads is saved first
+		for i, arg := range args {
+			ref, ok := arg.(*LocalRef)
+			if !ok {
+				continue
+			}
+			if j, isParam := paramIndex[ref.Local.ID]; isParam && j != i {
+				temp := Local{ID: nextID, Name: fmt.Sprintf("tailrec.%s", fn.Params[i].Name), Type: ref.Local.Type}
+				nextID++
+				fn.Locals = append(fn.Locals, temp)
+				stmts = append(stmts, &Assign{Local: temp, RHS: &LocalRef{Local: ref.Local}})
+				args[i] = &LocalRef{Local: temp}
+			}
+		}
+		for i, arg := range args {
+			if ref, ok := arg.(*LocalRef); ok && ref.Local.ID == fn.Params[i].ID {
+				continue
+			}
+			stmts = append(stmts, &Assign{Local: fn.Params[i], RHS: arg})
+		}
+
+		site.block.Statements = stmts
+		site.block.Terminator = &Goto{Target: header}
+	}
+}
+
+// takesAddress reports whether any local of fn has its address taken
+func takesAddress(fn *Function) bool {
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if _, ok := stmt.(*AddressOf); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tailCallError reports a #[tailcall] return that stays a real call
+func tailCallError(fn *Function, ret *Return, reason string) diag.Diagnostic {
+	span := diag.Span{
+		Filename: ret.Span.Filename,
+		Line:     ret.Span.Line,
+		Column:   ret.Span.Column,
+		Start:    ret.Span.Start,
+		End:      ret.Span.End,
+	}
+	return diag.Diagnostic{
+		Stage:        diag.StageCodegen,
+		Severity:     diag.SeverityError,
+		Code:         diag.CodeGenTailCall,
+		Message:      fmt.Sprintf("`#[tailcall]` return in `%s` cannot be turned into a loop", fn.Name),
+		Span:         span,
+		LabeledSpans: []diag.LabeledSpan{{Span: span, Label: "this call would still use a new stack frame", Style: "primary"}},
+		Notes:        []string{reason},
+		Help:         "return the result of calling the enclosing function directly, e.g. `#[tailcall] return f(n - 1, acc * n);`, or remove the attribute",
+	}
+}
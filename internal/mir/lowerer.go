@@ -1,9 +1,14 @@
 package mir
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
 	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
@@ -36,17 +41,46 @@ type Lowerer struct {
 	// Map of variable names to locals
 	locals map[string]Local
 
+	// Variables captured by the function literal being lowered, if any
+	captures *captureScope
+
 	// Loop context stack (for break/continue)
 	loopStack []*LoopContext
 
+	// Blocks begun after a call that never returns, which nothing jumps to
+	diverged map[*BasicBlock]bool
+
 	// Map of call expressions to type arguments
 	CallTypeArgs map[*ast.CallExpr][]types.Type
 
+	// Identifiers whose use moves the value out of a variable, from the
+	// checker. A moved-out value is dropped by its new owner instead.
+	Moves map[*ast.Ident]bool
+
+	// Values of the identifiers and module paths that name a const, and of
+	// the patterns that match one, from the checker
+	Consts map[ast.Expr]any
+
+	// Variables of the current function to drop at scope exit
+	drops *dropState
+
 	// Parameter type overrides (for impl methods)
 	ParamOverrides map[string]types.Type
 
+	// Implementing type that Self stands for while a trait default method
+	// is lowered for it
+	selfType types.Type
+
 	// Module being constructed (for adding spawn block/literal functions)
 	Module *Module
+
+	// Module prefix of functions lowered from imported modules, used to
+	// qualify the unqualified sibling calls they make
+	modulePrefixes map[*Function]string
+
+	// Errors holds diagnostics for code that lowers but cannot be compiled
+	// as written, such as a #[tailcall] return that cannot become a loop
+	Errors []diag.Diagnostic
 }
 
 // NewLowerer creates a new MIR lowerer
@@ -61,18 +95,34 @@ func NewLowerer(typeInfo map[ast.Node]types.Type, callTypeArgs map[*ast.CallExpr
 		blockCounter: 0,
 		locals:       make(map[string]Local),
 		loopStack:    make([]*LoopContext, 0),
+		diverged:     make(map[*BasicBlock]bool),
+		drops:        newDropState(),
+
+		modulePrefixes: make(map[*Function]string),
 	}
 }
 
+// NewTypedLowerer creates a MIR lowerer for a checked file
+func NewTypedLowerer(typed *types.TypedFile) *Lowerer {
+	l := NewLowerer(typed.Types, typed.CallTypeArgs, typed.Scope, typed.Methods, typed.Modules)
+	l.Moves = typed.Moves
+	l.Consts = typed.Consts
+	return l
+}
+
 // LowerModule lowers an entire file to MIR
 func (l *Lowerer) LowerModule(file *ast.File) (*Module, error) {
 	module := &Module{
 		Functions: make([]*Function, 0),
 	}
 	l.Module = module // Set module so spawn blocks/literals can add functions
+	l.declareStatics(file)
 
 	for _, decl := range file.Decls {
-		if fnDecl, ok := decl.(*ast.FnDecl); ok {
+		if fnDecl, ok := decl.(*ast.FnDecl); ok && fnDecl.ABI != "" {
+			// Called directly by its C symbol
+			l.declareExtern(fnDecl)
+		} else if fnDecl, ok := decl.(*ast.FnDecl); ok {
 			fn, err := l.LowerFunction(fnDecl)
 			if err != nil {
 				return nil, fmt.Errorf("failed to lower function %s: %w", fnDecl.Name.Name, err)
@@ -100,7 +150,8 @@ func (l *Lowerer) LowerModule(file *ast.File) (*Module, error) {
 
 	// Collect struct and enum definitions from global scope
 	if l.GlobalScope != nil {
-		for _, sym := range l.GlobalScope.Symbols {
+		for _, name := range slices.Sorted(maps.Keys(l.GlobalScope.Symbols)) {
+			sym := l.GlobalScope.Symbols[name]
 			if t, ok := sym.Type.(*types.Struct); ok {
 				module.Structs = append(module.Structs, t)
 			} else if t, ok := sym.Type.(*types.Enum); ok {
@@ -113,7 +164,7 @@ func (l *Lowerer) LowerModule(file *ast.File) (*Module, error) {
 	// This makes stdlib methods available for monomorphization
 	// Also collect structs and enums from these modules for codegen
 	if l.Modules != nil {
-		for _, modInfo := range l.Modules {
+		for _, modInfo := range l.sortedModules() {
 			if modInfo.File != nil {
 				// Collect struct and enum declarations from this module
 				for _, decl := range modInfo.File.Decls {
@@ -144,6 +195,9 @@ func (l *Lowerer) LowerModule(file *ast.File) (*Module, error) {
 							fmt.Printf("warning: failed to lower impl from module %s: %v\n", modInfo.Name, err)
 							continue
 						}
+						for _, fn := range fns {
+							l.modulePrefixes[fn] = modulePathName(modInfo.Name)
+						}
 						module.Functions = append(module.Functions, fns...)
 					}
 				}
@@ -151,16 +205,221 @@ func (l *Lowerer) LowerModule(file *ast.File) (*Module, error) {
 		}
 	}
 
+	// Lower the free functions of imported modules that are reachable from this module
+	if err := l.lowerImportedFunctions(module); err != nil {
+		return nil, err
+	}
+
 	// Perform monomorphization pass
 	// This will specialize all generic functions based on their call sites
 	monomorphizer := NewMonomorphizer(module)
 	if err := monomorphizer.Monomorphize(); err != nil {
+		var depthErr *InstantiationDepthError
+		if errors.As(err, &depthErr) {
+			l.Errors = append(l.Errors, depthErr.Diagnostic())
+			return module, nil
+		}
 		return nil, fmt.Errorf("monomorphization failed: %w", err)
 	}
 
+	// Self calls now use their final (qualified, specialized) names
+	l.eliminateTailCalls(module)
+
 	return module, nil
 }
 
+// sortedModules returns the imported modules ordered by path, so that the
+// order of the lowered functions and types, and so the generated code, does
+// not depend on map iteration
+func (l *Lowerer) sortedModules() []*types.ModuleInfo {
+	modules := make([]*types.ModuleInfo, 0, len(l.Modules))
+	for _, path := range slices.Sorted(maps.Keys(l.Modules)) {
+		modules = append(modules, l.Modules[path])
+	}
+	return modules
+}
+
+// lowerImportedFunctions lowers free functions from imported modules on demand.
+// A function is lowered only if it is called, either as `mod::fn` or through a
+// `use` import, so unused library functions never reach codegen. Functions are
+// named after the full path of their module, as in `a::util::fn`, so that
+// modules of the same name in different places do not collide.
+func (l *Lowerer) lowerImportedFunctions(module *Module) error {
+	candidates := make(map[string]*ast.FnDecl)
+	for _, modInfo := range l.sortedModules() {
+		if modInfo.File == nil {
+			continue
+		}
+		prefix := modulePathName(modInfo.Name)
+		for _, decl := range modInfo.File.Decls {
+			if fnDecl, ok := decl.(*ast.FnDecl); ok {
+				candidates[prefix+"::"+fnDecl.Name.Name] = fnDecl
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Inline modules are already lowered as outer::inner::fn
+	defined := make(map[string]bool)
+	for _, fn := range module.Functions {
+		defined[fn.Name] = true
+	}
+
+	// Module functions and methods call their siblings unqualified
+	prefixes := l.modulePrefixes
+
+	for i := 0; i < len(module.Functions); i++ {
+		caller := module.Functions[i]
+		for _, block := range caller.Blocks {
+			for _, stmt := range block.Statements {
+				var callee *string
+				switch s := stmt.(type) {
+				case *Call:
+					callee = &s.Func
+				case *Spawn:
+					callee = &s.Func
+				}
+				if callee == nil || *callee == "" {
+					continue
+				}
+				name, decl := l.resolveModuleCall(candidates, prefixes[caller], *callee)
+				if decl == nil {
+					continue
+				}
+				*callee = name
+				if defined[name] {
+					continue
+				}
+
+				fn, err := l.LowerFunction(decl)
+				if err != nil {
+					return fmt.Errorf("failed to lower function %s: %w", name, err)
+				}
+				fn.Name = name
+				defined[name] = true
+				prefixes[fn] = name[:strings.LastIndex(name, "::")]
+				module.Functions = append(module.Functions, fn)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveModuleCall returns the full name and the declaration of the module
+// function that name refers to when called from the module prefix, "" for
+// the file being compiled, or a nil declaration if name is no module
+// function. Like the checker, it looks for a sibling or submodule of the
+// calling module first, then through a module imported with `use`, then for
+// a top-level module.
+func (l *Lowerer) resolveModuleCall(candidates map[string]*ast.FnDecl, prefix, name string) (string, *ast.FnDecl) {
+	var names []string
+	if prefix != "" {
+		names = append(names, prefix+"::"+name)
+	}
+	if head, rest, ok := strings.Cut(name, "::"); ok {
+		if modName := l.usedModule(prefix, head); modName != "" {
+			names = append(names, modulePathName(modName)+"::"+rest)
+		}
+		names = append(names, name)
+	}
+	for _, n := range names {
+		if decl, ok := candidates[n]; ok {
+			return n, decl
+		}
+	}
+	return name, nil
+}
+
+// usedModule returns the full name of the module that a `use` in the module
+// prefix, or in the file being compiled, brings into scope as name, or "".
+func (l *Lowerer) usedModule(prefix, name string) string {
+	scope := l.GlobalScope
+	if modInfo, ok := l.Modules[strings.ReplaceAll(prefix, "::", "/")]; ok && modInfo.InternalScope != nil {
+		scope = modInfo.InternalScope
+	}
+	if scope == nil {
+		return ""
+	}
+	sym := scope.Lookup(name)
+	if sym == nil {
+		return ""
+	}
+	if _, ok := sym.DefNode.(*ast.UseDecl); !ok {
+		return ""
+	}
+	if named, ok := sym.Type.(*types.Named); ok && named.Ref == nil {
+		return named.Name
+	}
+	return ""
+}
+
+// modulePathName returns the full name of the module the checker names
+// modName, a/util, as it qualifies functions: a::util.
+func modulePathName(modName string) string {
+	return strings.ReplaceAll(modName, "/", "::")
+}
+
+// importedFunctionName returns the qualified `mod::fn` name of a function
+// brought into scope with `use`, or name unchanged otherwise.
+func (l *Lowerer) importedFunctionName(name string) string {
+	if l.GlobalScope == nil {
+		return name
+	}
+	sym := l.GlobalScope.Lookup(name)
+	if sym == nil {
+		return name
+	}
+	useDecl, ok := sym.DefNode.(*ast.UseDecl)
+	if !ok || len(useDecl.Path) < 2 {
+		return name
+	}
+	if _, ok := sym.Type.(*types.Function); !ok {
+		return name
+	}
+	parts := make([]string, len(useDecl.Path))
+	for i, ident := range useDecl.Path {
+		parts[i] = ident.Name
+	}
+	return strings.Join(parts, "::")
+}
+
+// isModuleName reports whether name was brought into scope by a `use` of a whole module
+func (l *Lowerer) isModuleName(name string) bool {
+	if l.GlobalScope == nil {
+		return false
+	}
+	sym := l.GlobalScope.Lookup(name)
+	if sym == nil {
+		return false
+	}
+	if _, ok := sym.DefNode.(*ast.UseDecl); !ok {
+		return false
+	}
+	named, ok := sym.Type.(*types.Named)
+	return ok && named.Ref == nil
+}
+
+// inlineKind returns what the #[inline] attribute among attrs asks for.
+func inlineKind(attrs []*ast.Attribute) InlineKind {
+	attr := ast.FindAttribute(attrs, "inline")
+	if attr == nil {
+		return InlineDefault
+	}
+	if len(attr.Args) == 1 {
+		if ident, ok := attr.Args[0].(*ast.Ident); ok {
+			switch ident.Name {
+			case "always":
+				return InlineAlways
+			case "never":
+				return InlineNever
+			}
+		}
+	}
+	return InlineHint
+}
+
 // LowerFunction lowers a function declaration to MIR
 func (l *Lowerer) LowerFunction(decl *ast.FnDecl) (*Function, error) {
 	// Reset state for new function
@@ -168,6 +427,7 @@ func (l *Lowerer) LowerFunction(decl *ast.FnDecl) (*Function, error) {
 	l.blockCounter = 0
 	l.locals = make(map[string]Local)
 	l.loopStack = make([]*LoopContext, 0)
+	l.drops = newDropState()
 
 	// Get return type
 	returnType := l.getReturnType(decl)
@@ -179,6 +439,8 @@ func (l *Lowerer) LowerFunction(decl *ast.FnDecl) (*Function, error) {
 		ReturnType: returnType,
 		Locals:     make([]Local, 0),
 		Blocks:     make([]*BasicBlock, 0),
+		Span:       decl.Span(),
+		Inline:     inlineKind(decl.Attrs),
 	}
 
 	// Lower type parameters
@@ -231,14 +493,12 @@ func (l *Lowerer) LowerFunction(decl *ast.FnDecl) (*Function, error) {
 				paramType = override
 			} else {
 				paramType = l.getType(param, l.TypeInfo)
+				if paramType == nil && param.Type != nil {
+					// Use the type the checker resolved for the annotation
+					paramType = l.getType(param.Type, l.TypeInfo)
+				}
 				if paramType == nil {
-					// Try to infer from type annotation
-					if param.Type != nil {
-						// For now, default to int if we can't resolve
-						paramType = &types.Primitive{Kind: types.Int}
-					} else {
-						paramType = &types.Primitive{Kind: types.Int}
-					}
+					return nil, types.MissingTypeError(param)
 				}
 			}
 		}
@@ -255,11 +515,22 @@ func (l *Lowerer) LowerFunction(decl *ast.FnDecl) (*Function, error) {
 	l.currentFunc = fn
 
 	// Lower function body
-	if decl.Body != nil {
+	if decl.ABI != "" {
+		l.lowerExternBody(decl, fn)
+	} else if decl.Body != nil {
+		// Parameters are owned by the function, except for the receiver,
+		// which the caller keeps
+		for i, param := range decl.Params {
+			if param.Name.Name != "self" {
+				l.trackDrop(fn.Params[i])
+			}
+		}
+
 		result, err := l.lowerBlock(decl.Body)
 		if err != nil {
 			return nil, err
 		}
+		l.popDropScope()
 
 		// If block doesn't have a terminator, add implicit return
 		if l.currentBlock.Terminator == nil {
@@ -276,6 +547,9 @@ func (l *Lowerer) LowerFunction(decl *ast.FnDecl) (*Function, error) {
 				l.currentBlock.Terminator = &Return{Value: result}
 			} else if isVoid {
 				l.currentBlock.Terminator = &Return{Value: nil}
+			} else if !hasPredecessor(fn, l.currentBlock) {
+				// Every path returned or panicked before the end of the body
+				l.currentBlock.Terminator = &Unreachable{}
 			} else {
 				// Error: non-void function without return
 				return nil, fmt.Errorf("function %s has non-void return type but no return statement", decl.Name.Name)
@@ -294,7 +568,7 @@ func (l *Lowerer) lowerInlineModule(modDecl *ast.ModDecl, parentPath string) ([]
 	var functions []*Function
 	currentPath := modDecl.Name.Name
 	if parentPath != "" {
-		currentPath = parentPath + "__" + currentPath
+		currentPath = parentPath + "::" + currentPath
 	}
 
 	if modDecl.Body != nil {
@@ -305,7 +579,7 @@ func (l *Lowerer) lowerInlineModule(modDecl *ast.ModDecl, parentPath string) ([]
 					return nil, err
 				}
 				// Mangle function name with module path
-				fn.Name = currentPath + "__" + fn.Name
+				fn.Name = currentPath + "::" + fn.Name
 				functions = append(functions, fn)
 			} else if implDecl, ok := decl.(*ast.ImplDecl); ok {
 				fns, err := l.LowerImplDecl(implDecl)
@@ -375,6 +649,57 @@ func (l *Lowerer) LowerImplDecl(decl *ast.ImplDecl) ([]*Function, error) {
 		functions = append(functions, fn)
 	}
 
+	// Default methods the impl does not override get a copy of the trait's
+	// body per implementing type, so calls dispatch to them statically
+	if decl.Trait != nil {
+		defaults, err := l.lowerDefaultMethods(decl, targetType, targetTypeName)
+		if err != nil {
+			return nil, err
+		}
+		functions = append(functions, defaults...)
+	}
+
+	return functions, nil
+}
+
+// lowerDefaultMethods lowers the default methods of the trait implemented by
+// decl that decl does not override, with Self standing for targetType.
+func (l *Lowerer) lowerDefaultMethods(decl *ast.ImplDecl, targetType types.Type, targetTypeName string) ([]*Function, error) {
+	trait := types.TraitDeclOf(decl.Trait, l.GlobalScope)
+	for _, modInfo := range l.sortedModules() {
+		if trait != nil {
+			break
+		}
+		trait = types.TraitDeclOf(decl.Trait, modInfo.InternalScope)
+	}
+	if trait == nil {
+		return nil, nil
+	}
+
+	var implTypeParams []types.TypeParam
+	for _, param := range decl.TypeParams {
+		if tp, ok := param.(*ast.TypeParam); ok {
+			implTypeParams = append(implTypeParams, types.TypeParam{Name: tp.Name.Name})
+		}
+	}
+
+	var functions []*Function
+	for _, method := range trait.Methods {
+		if method.Body == nil || types.Overrides(decl, method.Name.Name) {
+			continue
+		}
+		l.ParamOverrides = map[string]types.Type{"self": targetType}
+		l.selfType = targetType
+		fn, err := l.LowerFunction(method)
+		l.ParamOverrides = nil
+		l.selfType = nil
+		if err != nil {
+			return nil, fmt.Errorf("default method %s for %s: %w", method.Name.Name, targetTypeName, err)
+		}
+		fn.Name = targetTypeName + "::" + method.Name.Name
+		fn.TypeParams = append(implTypeParams, fn.TypeParams...)
+		functions = append(functions, fn)
+	}
 	return functions, nil
 }
 
@@ -383,8 +708,16 @@ func isPrimitive(t types.Type) bool {
 	return ok
 }
 
-// lowerBlock lowers a block expression
+// lowerBlock lowers a block expression. The variables it declares are
+// dropped after its value is computed.
 func (l *Lowerer) lowerBlock(block *ast.BlockExpr) (Operand, error) {
+	l.pushDropScope()
+
+	// Names bound in the block go out of scope at its end, uncovering the
+	// bindings they shadowed
+	outer := maps.Clone(l.locals)
+	defer func() { l.locals = outer }()
+
 	// Lower statements
 	for _, stmt := range block.Stmts {
 		err := l.lowerStmt(stmt)
@@ -394,16 +727,25 @@ func (l *Lowerer) lowerBlock(block *ast.BlockExpr) (Operand, error) {
 	}
 
 	// Lower tail expression if present
+	var result Operand
 	if block.Tail != nil {
 		// Just evaluate it, the result is the block's value
-		return l.lowerExpr(block.Tail)
+		var err error
+		result, err = l.lowerExpr(block.Tail)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return nil, nil
+	l.popDropScope()
+	return result, nil
 }
 
 // lowerExpr lowers an expression to an operand
 func (l *Lowerer) lowerExpr(expr ast.Expr) (Operand, error) {
+	if v, ok := l.Consts[expr]; ok {
+		return l.constLiteral(expr, v), nil
+	}
 	switch e := expr.(type) {
 	case *ast.Ident:
 		return l.lowerIdent(e)
@@ -423,6 +765,8 @@ func (l *Lowerer) lowerExpr(expr ast.Expr) (Operand, error) {
 		return l.lowerInfixExpr(e)
 	case *ast.PrefixExpr:
 		return l.lowerPrefixExpr(e)
+	case *ast.SpawnExpr:
+		return l.lowerSpawnExpr(e)
 	case *ast.IfExpr:
 		return l.lowerIfExpr(e)
 	case *ast.MatchExpr:
@@ -447,6 +791,11 @@ func (l *Lowerer) lowerExpr(expr ast.Expr) (Operand, error) {
 		return l.lowerCastExpr(e)
 	case *ast.FunctionLiteral:
 		return l.lowerFunctionLiteral(e)
+	case *ast.BlockExpr:
+		return l.lowerBlock(e)
+	case *ast.UnsafeBlock:
+		// Unsafe blocks only relax checking
+		return l.lowerBlock(e.Block)
 	default:
 		return nil, fmt.Errorf("unsupported expression type: %T", expr)
 	}
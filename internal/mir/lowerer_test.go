@@ -1,6 +1,8 @@
 package mir
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -344,6 +346,32 @@ fn add(x: int, y: int) -> int {
 	}
 }
 
+func TestLowerExpression_Block(t *testing.T) {
+	src := `
+package test;
+
+fn f(c: bool) -> int {
+	let x = { let a = 3; a + 1 };
+	return { if c { x } else { { 0 } } };
+}
+`
+
+	fn := lowerFunction(t, src)
+
+	var x *Local
+	for i := range fn.Locals {
+		if fn.Locals[i].Name == "x" {
+			x = &fn.Locals[i]
+		}
+	}
+	if x == nil {
+		t.Fatalf("local x not found:\n%s", fn.PrettyPrint())
+	}
+	if x.Type.String() != "int" {
+		t.Errorf("x should take the type of the block's tail, got %s", x.Type)
+	}
+}
+
 func TestLowerExpression_FunctionCall(t *testing.T) {
 	src := `
 package test;
@@ -981,3 +1009,109 @@ fn factorial(n: int) -> int {
 		t.Errorf("expected recursive call to 'factorial', got %q", factorialCall.Func)
 	}
 }
+
+func TestLowerModule_ImportedFunctions(t *testing.T) {
+	src := `
+package test;
+
+use std::env;
+use std::env::arg;
+
+fn main() {
+	let n = env::arg_count();
+	let first = arg(0);
+}
+`
+
+	file, checker := parseAndTypeCheck(t, src)
+
+	lowerer := NewLowerer(checker.ExprTypes, checker.CallTypeArgs, checker.GlobalScope, checker.MethodTable, checker.Modules)
+	module, err := lowerer.LowerModule(file)
+	if err != nil {
+		t.Fatalf("lowering error: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, fn := range module.Functions {
+		names[fn.Name] = true
+	}
+
+	for _, want := range []string{"main", "std::env::arg_count", "std::env::arg"} {
+		if !names[want] {
+			t.Errorf("expected function %q to be lowered, got %v", want, names)
+		}
+	}
+	// Functions that are never called are not lowered
+	if names["std::env::args"] {
+		t.Errorf("expected uncalled std::env::args not to be lowered")
+	}
+}
+
+func TestLowerModule_ImportedFunctionsOfSameNamedModules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.mal":      "pub mod util;\n",
+		"b.mal":      "pub mod util;\n",
+		"a/util.mal": "pub fn name() -> int {\n    return helper();\n}\n\nfn helper() -> int {\n    return 1;\n}\n",
+		"b/util.mal": "pub fn name() -> int {\n    return helper();\n}\n\nfn helper() -> int {\n    return 2;\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := `
+package main;
+
+mod a;
+mod b;
+
+use b::util;
+
+fn main() {
+	let x = a::util::name();
+	let y = util::name();
+}
+`
+	p := parser.New(src)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	checker := types.NewChecker()
+	checker.CheckWithFilename(file, filepath.Join(dir, "main.mal"))
+	if len(checker.Errors) > 0 {
+		t.Fatalf("type check errors: %v", checker.Errors)
+	}
+	lowerer := NewLowerer(checker.ExprTypes, checker.CallTypeArgs, checker.GlobalScope, checker.MethodTable, checker.Modules)
+	module, err := lowerer.LowerModule(file)
+	if err != nil {
+		t.Fatalf("lowering error: %v", err)
+	}
+
+	// Each module calls its own helper, and main the function of each module
+	calls := make(map[string]string)
+	for _, fn := range module.Functions {
+		for _, block := range fn.Blocks {
+			for _, stmt := range block.Statements {
+				if call, ok := stmt.(*Call); ok {
+					calls[fn.Name] += call.Func + " "
+				}
+			}
+		}
+	}
+	want := map[string]string{
+		"main":          "a::util::name b::util::name ",
+		"a::util::name": "a::util::helper ",
+		"b::util::name": "b::util::helper ",
+	}
+	for fn, wantCalls := range want {
+		if calls[fn] != wantCalls {
+			t.Errorf("%s calls %q, want %q", fn, calls[fn], wantCalls)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package mir
+
+import "testing"
+
+func TestLowerMapForLoop(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn main() {
+    let mut m = map[string, int]{};
+    m["a"] = 1;
+    for k in m {
+        if m.contains(k) { continue; }
+        m.remove(k);
+    }
+}
+`)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+
+	calls := make(map[string]int)
+	var loads []*LoadIndex
+	var step *BasicBlock
+	for _, block := range fn.Blocks {
+		if block.Label == "for_step" {
+			step = block
+		}
+		for _, stmt := range block.Statements {
+			switch s := stmt.(type) {
+			case *Call:
+				calls[s.Func]++
+			case *LoadIndex:
+				loads = append(loads, s)
+			}
+		}
+	}
+	for _, name := range []string{"__map_keys__", "contains", "__map_remove__"} {
+		if calls[name] != 1 {
+			t.Errorf("got %d calls of %s, want 1", calls[name], name)
+		}
+	}
+	if len(loads) != 1 {
+		t.Fatalf("got %d load_index statements, want 1 reading the current key", len(loads))
+	}
+	if ref, ok := loads[0].Target.(*LocalRef); !ok || ref.Local.Type.String() != "[]string" {
+		t.Errorf("loop reads %s, want the []string of keys", operandString(loads[0].Target))
+	}
+
+	if step == nil {
+		t.Fatal("no for_step block")
+	}
+	// Only the entry and the step enter the header; `continue` has to
+	// advance the index first
+	for _, block := range fn.Blocks {
+		if g, ok := block.Terminator.(*Goto); ok && g.Target.Label == "for_header" && block != step && block != fn.Entry {
+			t.Errorf("%s jumps to for_header without advancing the index", block.Label)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package mir
 
 import (
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
 	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
@@ -18,6 +19,25 @@ type Module struct {
 	Functions []*Function
 	Structs   []*types.Struct
 	Enums     []*types.Enum
+	Externs   []*Extern
+	Statics   []*Static
+}
+
+// Extern is a C function declared with `extern "C" fn`, called by its
+// symbol name
+type Extern struct {
+	Name       string
+	Params     []types.Type
+	ReturnType types.Type // nil for void
+}
+
+// Static is a module-level variable declared with `static`, initialized
+// with the constant value of its initializer
+type Static struct {
+	Name    string
+	Type    types.Type
+	Value   any // int64, float64, bool or string
+	Mutable bool
 }
 
 // Function represents a MIR function with a control-flow graph
@@ -29,6 +49,26 @@ type Function struct {
 	Locals     []Local
 	Blocks     []*BasicBlock
 	Entry      *BasicBlock
+	Span       lexer.Span // Source declaration, if the function has one
+	Instance   *Instance  // Set on functions produced by monomorphization
+	Inline     InlineKind // Set by #[inline]
+}
+
+// InlineKind is what the #[inline] attribute of a function asks for.
+type InlineKind int
+
+const (
+	InlineDefault InlineKind = iota // no #[inline]: left to LLVM
+	InlineHint                      // #[inline]
+	InlineAlways                    // #[inline(always)]
+	InlineNever                     // #[inline(never)]
+)
+
+// Instance records which generic function a specialized function was
+// instantiated from and with which type arguments
+type Instance struct {
+	Generic  string
+	TypeArgs []types.Type
 }
 
 // Local represents a local variable or parameter
@@ -109,10 +149,19 @@ type Spawn struct {
 	Func     string    // Function name or wrapper name
 	Args     []Operand // Arguments to pass to the legion
 	TypeArgs []types.Type
+	Result   *Local // JoinHandle for `spawn` expressions; nil for statements
 }
 
 func (*Spawn) stmtNode() {}
 
+// Join waits for a spawned legion: result = join handle
+type Join struct {
+	Result Local
+	Handle Operand
+}
+
+func (*Join) stmtNode() {}
+
 // Yield statement: yields control to the legion scheduler
 type Yield struct{}
 
@@ -126,6 +175,22 @@ type Load struct {
 
 func (*Load) stmtNode() {}
 
+// LoadStatic reads a static variable: result = name
+type LoadStatic struct {
+	Result Local
+	Name   string
+}
+
+func (*LoadStatic) stmtNode() {}
+
+// StoreStatic writes a mutable static variable: name = value
+type StoreStatic struct {
+	Name  string
+	Value Operand
+}
+
+func (*StoreStatic) stmtNode() {}
+
 // LoadField loads a field from a struct
 type LoadField struct {
 	Result Local
@@ -149,6 +214,9 @@ type LoadIndex struct {
 	Result  Local
 	Target  Operand
 	Indices []Operand
+	// InBounds is set when the last index is known to be within the
+	// slice's length, so no bounds check is emitted for it
+	InBounds bool
 }
 
 func (*LoadIndex) stmtNode() {}
@@ -158,6 +226,9 @@ type StoreIndex struct {
 	Target  Operand
 	Indices []Operand
 	Value   Operand
+	// InBounds is set when the last index is known to be within the
+	// slice's length, so no bounds check is emitted for it
+	InBounds bool
 }
 
 func (*StoreIndex) stmtNode() {}
@@ -167,6 +238,10 @@ type ConstructStruct struct {
 	Result Local
 	Type   types.Type         // Struct type (can be *types.Struct or *types.GenericInstance)
 	Fields map[string]Operand // Field name -> value
+
+	// StackAlloc is set by escape analysis when the value never outlives the
+	// function, letting codegen place it in the frame instead of the GC heap
+	StackAlloc bool
 }
 
 func (*ConstructStruct) stmtNode() {}
@@ -184,6 +259,8 @@ func (*ConstructArray) stmtNode() {}
 type ConstructTuple struct {
 	Result   Local
 	Elements []Operand
+
+	StackAlloc bool // See ConstructStruct.StackAlloc
 }
 
 func (*ConstructTuple) stmtNode() {}
@@ -195,6 +272,8 @@ type ConstructEnum struct {
 	Variant      string    // Variant name
 	VariantIndex int       // Variant index (tag)
 	Values       []Operand // Payload values
+
+	StackAlloc bool // See ConstructStruct.StackAlloc
 }
 
 func (*ConstructEnum) stmtNode() {}
@@ -238,6 +317,9 @@ func (*Send) stmtNode() {}
 type Receive struct {
 	Result  Local
 	Channel Operand
+	// Optional receives yield T? (nil once the channel is closed and
+	// drained) instead of T's zero value
+	Optional bool
 }
 
 func (*Receive) stmtNode() {}
@@ -266,6 +348,16 @@ type AddressOf struct {
 
 func (*AddressOf) stmtNode() {}
 
+// PtrOffset moves a raw pointer by a number of elements of its element
+// type: result = pointer.offset(offset)
+type PtrOffset struct {
+	Result  Local
+	Pointer Operand
+	Offset  Operand
+}
+
+func (*PtrOffset) stmtNode() {}
+
 // Cast represents a type cast operation
 type Cast struct {
 	Result  Local
@@ -280,13 +372,34 @@ type MakeClosure struct {
 	Result Local
 	Func   string  // Name of the function to call
 	Env    Operand // Environment struct pointer
+
+	StackAlloc bool // See ConstructStruct.StackAlloc
 }
 
 func (*MakeClosure) stmtNode() {}
 
+// RegisterDrop statement: call Func, the drop method of Value's type, when
+// the garbage collector frees Value. Values of a type with a Drop impl get
+// one when constructed, so values no scope drops are still dropped.
+type RegisterDrop struct {
+	Value    Operand
+	Func     string
+	TypeArgs []types.Type
+}
+
+func (*RegisterDrop) stmtNode() {}
+
+// CancelDrop statement: forget the finalizer of Value, which is about to be
+// dropped at the end of its scope
+type CancelDrop struct {
+	Value Operand
+}
+
+func (*CancelDrop) stmtNode() {}
+
 // SelectCase represents a case in a select statement
 type SelectCase struct {
-	// Operation type: "send", "recv", "default"
+	// Operation type: "send", "recv", "default", "after"
 	Kind string
 
 	// For send/recv
@@ -295,6 +408,9 @@ type SelectCase struct {
 	// For send
 	Value Operand
 
+	// For after: timeout in milliseconds
+	Timeout Operand
+
 	// For recv
 	Result *Local // Optional (if capturing result)
 
@@ -313,6 +429,11 @@ func (*Select) terminatorNode() {}
 // Return terminator
 type Return struct {
 	Value Operand // nil for void return
+
+	// TailCall is set by #[tailcall]: the returned self call must be turned
+	// into a jump. Span locates the annotated return for diagnostics.
+	TailCall bool
+	Span     lexer.Span
 }
 
 func (*Return) terminatorNode() {}
@@ -333,9 +454,19 @@ type Branch struct {
 
 func (*Branch) terminatorNode() {}
 
+// Unreachable terminator: control never gets past the end of the block, such
+// as after a call of panic
+type Unreachable struct{}
+
+func (*Unreachable) terminatorNode() {}
+
 // LoopContext tracks loop information for break/continue
 // This is used internally by the lowerer but can be useful for analysis
 type LoopContext struct {
 	Header *BasicBlock
 	End    *BasicBlock
+
+	// DropDepth is the number of drop scopes open outside the loop, which
+	// break and continue leave open
+	DropDepth int
 }
@@ -16,8 +16,23 @@ type Monomorphizer struct {
 	instantiations map[string]string
 	// Map of specialized struct names to their definition
 	specializedStructs map[string]*types.Struct
+	// Map of specialized enum names to their definition
+	specializedEnums map[string]*types.Enum
+	// Number of instantiations between each specialized function and the
+	// non-generic function that first needed it, and the function that
+	// asked for it
+	depth        map[string]int
+	instantiator map[string]string
+
+	// DepthLimit is the longest chain of instantiations allowed before
+	// giving up, which stops a generic that calls itself with an ever
+	// larger type from specializing forever
+	DepthLimit int
 }
 
+// DefaultInstantiationDepth is the DepthLimit of a new Monomorphizer
+const DefaultInstantiationDepth = 64
+
 // NewMonomorphizer creates a new Monomorphizer
 func NewMonomorphizer(module *Module) *Monomorphizer {
 	return &Monomorphizer{
@@ -25,6 +40,10 @@ func NewMonomorphizer(module *Module) *Monomorphizer {
 		specializedFuncs:   make(map[string]*Function),
 		instantiations:     make(map[string]string),
 		specializedStructs: make(map[string]*types.Struct),
+		specializedEnums:   make(map[string]*types.Enum),
+		depth:              make(map[string]int),
+		instantiator:       make(map[string]string),
+		DepthLimit:         DefaultInstantiationDepth,
 	}
 }
 
@@ -40,12 +59,17 @@ func (m *Monomorphizer) Monomorphize() error {
 		copy(funcs, m.module.Functions)
 
 		for _, fn := range funcs {
+			// The calls of a generic function are specialized in its
+			// specialized copies, where their type arguments are known
+			if len(fn.TypeParams) > 0 {
+				continue
+			}
 			for _, block := range fn.Blocks {
 				for _, stmt := range block.Statements {
 					if call, ok := stmt.(*Call); ok {
 						if len(call.TypeArgs) > 0 {
 							// Found a generic call
-							specName, err := m.specialize(call.Func, call.TypeArgs)
+							specName, err := m.specialize(fn, call.Func, call.TypeArgs)
 							if err != nil {
 								return err
 							}
@@ -58,6 +82,18 @@ func (m *Monomorphizer) Monomorphize() error {
 							}
 						}
 					}
+					// The drop method of a generic struct is specialized like a call
+					if reg, ok := stmt.(*RegisterDrop); ok && len(reg.TypeArgs) > 0 {
+						specName, err := m.specialize(fn, reg.Func, reg.TypeArgs)
+						if err != nil {
+							return err
+						}
+						if reg.Func != specName {
+							reg.Func = specName
+							reg.TypeArgs = nil
+							changed = true
+						}
+					}
 				}
 			}
 		}
@@ -67,11 +103,46 @@ func (m *Monomorphizer) Monomorphize() error {
 		}
 	}
 
+	// Specialized bodies refer to concrete generic structs by their specialized
+	// name (Vec$int); rewrite the non-generic functions the same way so both
+	// sides of a call agree on the struct type.
+	for _, fn := range m.module.Functions {
+		if len(fn.TypeParams) == 0 {
+			*fn = *m.createSpecializedCopy(fn, fn.Name, nil)
+		}
+	}
+
+	// The same goes for the fields of non-generic structs and the payloads
+	// of non-generic enums, such as a field of type Box[int]. Specializing
+	// them can add more specialized structs and enums, which are already
+	// concrete, so one pass over the original definitions is enough.
+	structs := m.module.Structs
+	for _, st := range structs {
+		if len(st.TypeParams) > 0 || m.specializedStructs[st.Name] == st {
+			continue
+		}
+		for i, field := range st.Fields {
+			st.Fields[i].Type = m.substituteType(field.Type, nil)
+		}
+	}
+	enums := m.module.Enums
+	for _, e := range enums {
+		if len(e.TypeParams) > 0 || m.specializedEnums[e.Name] == e {
+			continue
+		}
+		for i, variant := range e.Variants {
+			for j, param := range variant.Params {
+				e.Variants[i].Params[j] = m.substituteType(param, nil)
+			}
+		}
+	}
+
 	return nil
 }
 
-// specialize creates a specialized version of a generic function if it doesn't exist
-func (m *Monomorphizer) specialize(funcName string, typeArgs []types.Type) (string, error) {
+// specialize creates a specialized version of a generic function if it
+// doesn't exist, for a call from caller
+func (m *Monomorphizer) specialize(caller *Function, funcName string, typeArgs []types.Type) (string, error) {
 	// Generate unique name for specialization
 	specName := m.mangleName(funcName, typeArgs)
 
@@ -93,8 +164,16 @@ func (m *Monomorphizer) specialize(funcName string, typeArgs []types.Type) (stri
 		return "", fmt.Errorf("generic function %s not found", funcName)
 	}
 
+	depth := m.depth[caller.Name] + 1
+	if depth > m.DepthLimit {
+		return "", m.depthError(caller, funcName, typeArgs)
+	}
+	m.depth[specName] = depth
+	m.instantiator[specName] = caller.Name
+
 	// Create specialized copy
 	specFn := m.createSpecializedCopy(genericFn, specName, typeArgs)
+	specFn.Instance = &Instance{Generic: funcName, TypeArgs: typeArgs}
 
 	// Add to module
 	m.module.Functions = append(m.module.Functions, specFn)
@@ -103,16 +182,23 @@ func (m *Monomorphizer) specialize(funcName string, typeArgs []types.Type) (stri
 	return specName, nil
 }
 
-// mangleName generates a unique name for a specialization
+// mangleName generates a unique name for a specialization: the generic name,
+// '$' and the type arguments separated by commas, as in pair$int,[]u8. An
+// argument that itself contains a comma is put in parentheses, as in
+// pair$(pair$int,bool),int, so different arguments never give the same name.
 func (m *Monomorphizer) mangleName(funcName string, typeArgs []types.Type) string {
 	var sb strings.Builder
 	sb.WriteString(funcName)
 	sb.WriteString("$")
 	for i, arg := range typeArgs {
 		if i > 0 {
-			sb.WriteString("_")
+			sb.WriteString(",")
+		}
+		mangled := m.mangleType(arg)
+		if strings.Contains(mangled, ",") {
+			mangled = "(" + mangled + ")"
 		}
-		sb.WriteString(m.mangleType(arg))
+		sb.WriteString(mangled)
 	}
 	return sb.String()
 }
@@ -125,17 +211,44 @@ func (m *Monomorphizer) mangleType(t types.Type) string {
 	case *types.Named:
 		return t.Name
 	case *types.Pointer:
-		return "ptr_" + m.mangleType(t.Elem)
+		return "*" + m.mangleType(t.Elem)
 	case *types.Slice:
-		return "slice_" + m.mangleType(t.Elem)
+		return "[]" + m.mangleType(t.Elem)
 	case *types.Array:
-		return fmt.Sprintf("arr_%d_%s", t.Len, m.mangleType(t.Elem))
+		return fmt.Sprintf("[%d]%s", t.Len, m.mangleType(t.Elem))
 	case *types.Struct:
 		return t.Name
 	case *types.Enum:
 		return t.Name
 	case *types.GenericInstance:
 		return m.mangleName(m.mangleType(t.Base), t.Args)
+	case *types.TypeParam:
+		return t.Name
+	case *types.Reference:
+		if t.Mutable {
+			return "&mut " + m.mangleType(t.Elem)
+		}
+		return "&" + m.mangleType(t.Elem)
+	case *types.Optional:
+		return m.mangleType(t.Elem) + "?"
+	case *types.Map:
+		return "map[" + m.mangleType(t.Key) + "]" + m.mangleType(t.Value)
+	case *types.Channel:
+		return "chan " + m.mangleType(t.Elem)
+	case *types.JoinHandle:
+		return "JoinHandle[" + m.mangleType(t.Elem) + "]"
+	case *types.Tuple:
+		elems := make([]string, len(t.Elements))
+		for i, elem := range t.Elements {
+			elems[i] = m.mangleType(elem)
+		}
+		return "(" + strings.Join(elems, ",") + ")"
+	case *types.Function:
+		params := make([]string, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = m.mangleType(param)
+		}
+		return "fn(" + strings.Join(params, ",") + ")" + m.mangleType(t.Return)
 	default:
 		return "unknown"
 	}
@@ -172,6 +285,9 @@ func (m *Monomorphizer) createSpecializedCopy(fn *Function, newName string, type
 		Locals:     make([]Local, len(fn.Locals)),
 		Blocks:     make([]*BasicBlock, 0, len(fn.Blocks)),
 		TypeParams: nil, // Specialized function is not generic
+		Span:       fn.Span,
+		Instance:   fn.Instance,
+		Inline:     fn.Inline,
 	}
 
 	// Copy locals with substitution
@@ -240,6 +356,35 @@ func (m *Monomorphizer) substituteType(t types.Type, subst map[string]types.Type
 		return &types.Slice{Elem: m.substituteType(t.Elem, subst)}
 	case *types.Array:
 		return &types.Array{Elem: m.substituteType(t.Elem, subst), Len: t.Len}
+	case *types.Optional:
+		return &types.Optional{Elem: m.substituteType(t.Elem, subst)}
+	case *types.Reference:
+		return &types.Reference{Mutable: t.Mutable, Elem: m.substituteType(t.Elem, subst)}
+	case *types.Map:
+		// The key and value sizes of a map are only known once K and V are
+		return &types.Map{Key: m.substituteType(t.Key, subst), Value: m.substituteType(t.Value, subst)}
+	case *types.Channel:
+		return &types.Channel{Elem: m.substituteType(t.Elem, subst), Dir: t.Dir}
+	case *types.Tuple:
+		elems := make([]types.Type, len(t.Elements))
+		for i, elem := range t.Elements {
+			elems[i] = m.substituteType(elem, subst)
+		}
+		return &types.Tuple{Elements: elems}
+	case *types.Function:
+		// A closure passed as an argument has the function type of the
+		// parameter, so fn(T) -> U must become fn(int) -> int and so on
+		params := make([]types.Type, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = m.substituteType(param, subst)
+		}
+		return &types.Function{
+			Unsafe:     t.Unsafe,
+			TypeParams: t.TypeParams,
+			Params:     params,
+			Return:     m.substituteType(t.Return, subst),
+			Receiver:   t.Receiver,
+		}
 	case *types.Named:
 		// fmt.Printf("DEBUG: substituteType Named %s Ref: %T\n", t.Name, t.Ref)
 		// If it's a named type that refers to a type param, we might need to substitute it
@@ -284,8 +429,17 @@ func (m *Monomorphizer) substituteType(t types.Type, subst map[string]types.Type
 			result = t
 		}
 
+		// An instance with type parameters left in its arguments belongs to a
+		// generic body, which is never generated
+		for _, arg := range result.Args {
+			if containsTypeParam(arg) {
+				return result
+			}
+		}
+
 		// Register struct specialization if needed
 		m.registerStructSpecialization(result)
+		m.registerEnumSpecialization(result)
 
 		// If it's a struct instantiation, return a Named type referring to the specialized struct
 		// This ensures that the backend sees the specialized name (e.g. Vec$int) instead of generic Vec
@@ -296,6 +450,15 @@ func (m *Monomorphizer) substituteType(t types.Type, subst map[string]types.Type
 				Ref:  m.specializedStructs[specName],
 			}
 		}
+		// Enums likewise, so that each instance gets a payload sized for its
+		// own arguments
+		if baseEnum, ok := result.Base.(*types.Enum); ok {
+			specName := m.mangleName(baseEnum.Name, result.Args)
+			return &types.Named{
+				Name: specName,
+				Ref:  m.specializedEnums[specName],
+			}
+		}
 
 		return result
 	default:
@@ -341,10 +504,11 @@ func (m *Monomorphizer) substituteStmt(stmt Statement, subst map[string]types.Ty
 		}
 
 		return &Call{
-			Result:   m.substituteLocal(s.Result, subst),
-			Func:     funcName,
-			Args:     newArgs,
-			TypeArgs: newTypeArgs,
+			Result:      m.substituteLocal(s.Result, subst),
+			Func:        funcName,
+			FuncOperand: m.substituteOperand(s.FuncOperand, subst),
+			Args:        newArgs,
+			TypeArgs:    newTypeArgs,
 		}
 	case *LoadField:
 		return &LoadField{
@@ -358,9 +522,10 @@ func (m *Monomorphizer) substituteStmt(stmt Statement, subst map[string]types.Ty
 			newIndices[i] = m.substituteOperand(idx, subst)
 		}
 		return &LoadIndex{
-			Result:  m.substituteLocal(s.Result, subst),
-			Target:  m.substituteOperand(s.Target, subst),
-			Indices: newIndices,
+			Result:   m.substituteLocal(s.Result, subst),
+			Target:   m.substituteOperand(s.Target, subst),
+			Indices:  newIndices,
+			InBounds: s.InBounds,
 		}
 	case *StoreField:
 		return &StoreField{
@@ -374,9 +539,10 @@ func (m *Monomorphizer) substituteStmt(stmt Statement, subst map[string]types.Ty
 			newIndices[i] = m.substituteOperand(idx, subst)
 		}
 		return &StoreIndex{
-			Target:  m.substituteOperand(s.Target, subst),
-			Indices: newIndices,
-			Value:   m.substituteOperand(s.Value, subst),
+			Target:   m.substituteOperand(s.Target, subst),
+			Indices:  newIndices,
+			Value:    m.substituteOperand(s.Value, subst),
+			InBounds: s.InBounds,
 		}
 	case *ConstructStruct:
 		newFields := make(map[string]Operand)
@@ -407,11 +573,135 @@ func (m *Monomorphizer) substituteStmt(stmt Statement, subst map[string]types.Ty
 			Result:   m.substituteLocal(s.Result, subst),
 			Elements: newElems,
 		}
+	case *ConstructEnum:
+		result := m.substituteLocal(s.Result, subst)
+		typeName := s.Type
+		if named, ok := result.Type.(*types.Named); ok {
+			if _, ok := named.Ref.(*types.Enum); ok {
+				typeName = named.Name
+			}
+		}
+		return &ConstructEnum{
+			Result:       result,
+			Type:         typeName,
+			Variant:      s.Variant,
+			VariantIndex: s.VariantIndex,
+			Values:       m.substituteOperands(s.Values, subst),
+		}
+	case *Discriminant:
+		return &Discriminant{
+			Result: m.substituteLocal(s.Result, subst),
+			Target: m.substituteOperand(s.Target, subst),
+		}
+	case *AccessVariantPayload:
+		return &AccessVariantPayload{
+			Result:       m.substituteLocal(s.Result, subst),
+			Target:       m.substituteOperand(s.Target, subst),
+			VariantIndex: s.VariantIndex,
+			MemberIndex:  s.MemberIndex,
+		}
+	case *LoadStatic:
+		return &LoadStatic{
+			Result: m.substituteLocal(s.Result, subst),
+			Name:   s.Name,
+		}
+	case *StoreStatic:
+		return &StoreStatic{
+			Name:  s.Name,
+			Value: m.substituteOperand(s.Value, subst),
+		}
+	case *Load:
+		return &Load{
+			Result:  m.substituteLocal(s.Result, subst),
+			Address: m.substituteOperand(s.Address, subst),
+		}
+	case *AddressOf:
+		return &AddressOf{
+			Result: m.substituteLocal(s.Result, subst),
+			Target: m.substituteLocal(s.Target, subst),
+		}
+	case *PtrOffset:
+		return &PtrOffset{
+			Result:  m.substituteLocal(s.Result, subst),
+			Pointer: m.substituteOperand(s.Pointer, subst),
+			Offset:  m.substituteOperand(s.Offset, subst),
+		}
+	case *Cast:
+		return &Cast{
+			Result:  m.substituteLocal(s.Result, subst),
+			Operand: m.substituteOperand(s.Operand, subst),
+			Type:    m.substituteType(s.Type, subst),
+		}
+	case *SizeOf:
+		return &SizeOf{
+			Result: m.substituteLocal(s.Result, subst),
+			Type:   m.substituteType(s.Type, subst),
+		}
+	case *AlignOf:
+		return &AlignOf{
+			Result: m.substituteLocal(s.Result, subst),
+			Type:   m.substituteType(s.Type, subst),
+		}
+	case *MakeChannel:
+		return &MakeChannel{
+			Result:   m.substituteLocal(s.Result, subst),
+			Type:     m.substituteType(s.Type, subst),
+			Capacity: m.substituteOperand(s.Capacity, subst),
+		}
+	case *Send:
+		return &Send{
+			Channel: m.substituteOperand(s.Channel, subst),
+			Value:   m.substituteOperand(s.Value, subst),
+		}
+	case *Receive:
+		return &Receive{
+			Result:   m.substituteLocal(s.Result, subst),
+			Channel:  m.substituteOperand(s.Channel, subst),
+			Optional: s.Optional,
+		}
+	case *Spawn:
+		var result *Local
+		if s.Result != nil {
+			r := m.substituteLocal(*s.Result, subst)
+			result = &r
+		}
+		return &Spawn{
+			Func:     s.Func,
+			Args:     m.substituteOperands(s.Args, subst),
+			TypeArgs: s.TypeArgs,
+			Result:   result,
+		}
+	case *Join:
+		return &Join{
+			Result: m.substituteLocal(s.Result, subst),
+			Handle: m.substituteOperand(s.Handle, subst),
+		}
+	case *RegisterDrop:
+		newTypeArgs := make([]types.Type, len(s.TypeArgs))
+		for i, arg := range s.TypeArgs {
+			newTypeArgs[i] = m.substituteType(arg, subst)
+		}
+		return &RegisterDrop{
+			Value:    m.substituteOperand(s.Value, subst),
+			Func:     s.Func,
+			TypeArgs: newTypeArgs,
+		}
+	case *CancelDrop:
+		return &CancelDrop{Value: m.substituteOperand(s.Value, subst)}
 	default:
 		return s
 	}
 }
 
+// substituteOperands substitutes types in each operand of a list
+func (m *Monomorphizer) substituteOperands(ops []Operand, subst map[string]types.Type) []Operand {
+	newOps := make([]Operand, len(ops))
+	for i, op := range ops {
+		newOps[i] = m.substituteOperand(op, subst)
+	}
+	return newOps
+}
+
 // substituteTerminator creates a copy of the terminator with types substituted
 func (m *Monomorphizer) substituteTerminator(term Terminator, subst map[string]types.Type, blockMap map[*BasicBlock]*BasicBlock) Terminator {
 	switch t := term.(type) {
@@ -420,7 +710,7 @@ func (m *Monomorphizer) substituteTerminator(term Terminator, subst map[string]t
 		if t.Value != nil {
 			val = m.substituteOperand(t.Value, subst)
 		}
-		return &Return{Value: val}
+		return &Return{Value: val, TailCall: t.TailCall, Span: t.Span}
 	case *Branch:
 		return &Branch{
 			Condition: m.substituteOperand(t.Condition, subst),
@@ -429,6 +719,28 @@ func (m *Monomorphizer) substituteTerminator(term Terminator, subst map[string]t
 		}
 	case *Goto:
 		return &Goto{Target: blockMap[t.Target]}
+	case *Select:
+		cases := make([]SelectCase, len(t.Cases))
+		for i, sc := range t.Cases {
+			cases[i] = SelectCase{
+				Kind:   sc.Kind,
+				Target: blockMap[sc.Target],
+			}
+			if sc.Channel != nil {
+				cases[i].Channel = m.substituteOperand(sc.Channel, subst)
+			}
+			if sc.Value != nil {
+				cases[i].Value = m.substituteOperand(sc.Value, subst)
+			}
+			if sc.Timeout != nil {
+				cases[i].Timeout = m.substituteOperand(sc.Timeout, subst)
+			}
+			if sc.Result != nil {
+				result := m.substituteLocal(*sc.Result, subst)
+				cases[i].Result = &result
+			}
+		}
+		return &Select{Cases: cases}
 	default:
 		return t
 	}
@@ -501,3 +813,87 @@ func (m *Monomorphizer) registerStructSpecialization(inst *types.GenericInstance
 		}
 	}
 }
+
+// registerEnumSpecialization creates a specialized enum definition if needed,
+// with the type arguments substituted into the variant payloads
+func (m *Monomorphizer) registerEnumSpecialization(inst *types.GenericInstance) {
+	baseEnum, ok := inst.Base.(*types.Enum)
+	if !ok {
+		return
+	}
+
+	specName := m.mangleName(baseEnum.Name, inst.Args)
+	if _, exists := m.specializedEnums[specName]; exists {
+		return
+	}
+
+	subst := make(map[string]types.Type)
+	for i, param := range baseEnum.TypeParams {
+		if i < len(inst.Args) {
+			subst[param.Name] = inst.Args[i]
+		}
+	}
+
+	specEnum := &types.Enum{
+		Name:     specName,
+		Variants: make([]types.Variant, len(baseEnum.Variants)),
+		Repr:     baseEnum.Repr,
+		Copy:     baseEnum.Copy,
+		Origin:   baseEnum,
+		TypeArgs: inst.Args,
+	}
+
+	// Register early to handle recursive types
+	m.specializedEnums[specName] = specEnum
+	m.module.Enums = append(m.module.Enums, specEnum)
+
+	for i, variant := range baseEnum.Variants {
+		params := make([]types.Type, len(variant.Params))
+		for j, param := range variant.Params {
+			params[j] = m.substituteType(param, subst)
+		}
+		specEnum.Variants[i] = types.Variant{Name: variant.Name, Params: params}
+	}
+}
+
+// containsTypeParam reports whether t mentions a type parameter
+func containsTypeParam(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Pointer:
+		return containsTypeParam(t.Elem)
+	case *types.Reference:
+		return containsTypeParam(t.Elem)
+	case *types.Slice:
+		return containsTypeParam(t.Elem)
+	case *types.Array:
+		return containsTypeParam(t.Elem)
+	case *types.Optional:
+		return containsTypeParam(t.Elem)
+	case *types.Channel:
+		return containsTypeParam(t.Elem)
+	case *types.Map:
+		return containsTypeParam(t.Key) || containsTypeParam(t.Value)
+	case *types.Tuple:
+		for _, elem := range t.Elements {
+			if containsTypeParam(elem) {
+				return true
+			}
+		}
+	case *types.GenericInstance:
+		for _, arg := range t.Args {
+			if containsTypeParam(arg) {
+				return true
+			}
+		}
+	case *types.Function:
+		for _, param := range t.Params {
+			if containsTypeParam(param) {
+				return true
+			}
+		}
+		return containsTypeParam(t.Return)
+	}
+	return false
+}
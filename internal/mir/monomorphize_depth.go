@@ -0,0 +1,103 @@
+package mir
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// InstantiationDepthError reports a chain of instantiations longer than the
+// Monomorphizer's DepthLimit, as made by a generic function that calls itself
+// with a larger type argument each time.
+type InstantiationDepthError struct {
+	Limit int
+	// Chain lists the instantiations from the non-generic function that
+	// started the chain to the one that was refused
+	Chain []string
+	Span  lexer.Span // Declaration of the function making the refused call
+}
+
+func (e *InstantiationDepthError) Error() string {
+	return fmt.Sprintf("reached the instantiation depth limit of %d while instantiating `%s`", e.Limit, shortName(e.Chain[len(e.Chain)-1]))
+}
+
+// chainShown is how many steps at the start of a long chain a diagnostic
+// lists before skipping to the last one
+const chainShown = 3
+
+// shortName cuts the middle out of a long instance name, which repeats the
+// same wrapper many times in a runaway chain
+func shortName(name string) string {
+	const keep = 32
+	if len(name) <= 2*keep+3 {
+		return name
+	}
+	return name[:keep] + "..." + name[len(name)-keep:]
+}
+
+// Diagnostic returns the error as a diagnostic whose notes walk the chain of
+// instantiations, leaving out all but the last step after the first few
+func (e *InstantiationDepthError) Diagnostic() diag.Diagnostic {
+	span := diag.Span{
+		Filename: e.Span.Filename,
+		Line:     e.Span.Line,
+		Column:   e.Span.Column,
+		Start:    e.Span.Start,
+		End:      e.Span.End,
+	}
+	var notes []string
+	for i := 1; i < len(e.Chain); i++ {
+		if i == chainShown+1 && len(e.Chain) > chainShown+3 {
+			skipped := len(e.Chain) - chainShown - 2
+			notes = append(notes, fmt.Sprintf("... %d more instantiations ...", skipped))
+			i += skipped - 1
+			continue
+		}
+		notes = append(notes, fmt.Sprintf("`%s` is instantiated by `%s`", shortName(e.Chain[i]), shortName(e.Chain[i-1])))
+	}
+	return diag.Diagnostic{
+		Stage:        diag.StageCodegen,
+		Severity:     diag.SeverityError,
+		Code:         diag.CodeGenInstantiationDepth,
+		Message:      e.Error(),
+		Span:         span,
+		LabeledSpans: []diag.LabeledSpan{{Span: span, Label: "this function instantiates itself without end", Style: "primary"}},
+		Notes:        notes,
+		Help:         "make sure a generic function does not call itself with a type argument built from its own, such as `f[Wrap[T]]` inside `f[T]`",
+	}
+}
+
+// depthError builds the error for caller asking for the instance specName of
+// funcName with typeArgs, one more than the depth limit allows
+func (m *Monomorphizer) depthError(caller *Function, funcName string, typeArgs []types.Type) error {
+	chain := []string{instanceName(funcName, typeArgs)}
+	for name := caller.Name; name != ""; name = m.instantiator[name] {
+		chain = append(chain, m.displayName(name))
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return &InstantiationDepthError{Limit: m.DepthLimit, Chain: chain, Span: caller.Span}
+}
+
+// displayName returns the source form of the function called name, such as
+// f[Wrap[int]] for a specialized copy
+func (m *Monomorphizer) displayName(name string) string {
+	for _, fn := range m.module.Functions {
+		if fn.Name == name && fn.Instance != nil {
+			return instanceName(fn.Instance.Generic, fn.Instance.TypeArgs)
+		}
+	}
+	return name
+}
+
+func instanceName(generic string, typeArgs []types.Type) string {
+	args := make([]string, len(typeArgs))
+	for i, arg := range typeArgs {
+		args[i] = arg.String()
+	}
+	return generic + "[" + strings.Join(args, ", ") + "]"
+}
@@ -1,8 +1,11 @@
 package mir
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/malphas-lang/malphas-lang/internal/diag"
 	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
@@ -151,3 +154,230 @@ func TestMonomorphize_Simple(t *testing.T) {
 		t.Errorf("Second call should have empty TypeArgs, got %v", call2.TypeArgs)
 	}
 }
+
+func TestMonomorphize_GenericMethod(t *testing.T) {
+	src := `package main;
+
+struct Box[T] { value: T }
+
+impl[T] Box[T] {
+    fn pick[U](&self, other: U) -> U {
+        return other;
+    }
+}
+
+fn main() {
+    let b = Box[int] { value: 2 };
+    let flag: bool = b.pick(true);
+    let x: float = b.pick(1.5);
+}
+`
+	module, _ := lowerModule(t, src)
+
+	// The receiver's type argument comes first, then the method's own
+	for name, ret := range map[string]types.Type{"Box::pick$int,bool": types.TypeBool, "Box::pick$int,float": types.TypeFloat} {
+		fn := findFunction(module, name)
+		if fn == nil {
+			t.Fatalf("expected specialization %s", name)
+		}
+		if fn.ReturnType != ret {
+			t.Errorf("%s returns %v, want %v", name, fn.ReturnType, ret)
+		}
+	}
+}
+
+func TestMonomorphize_GenericMethodWithClosure(t *testing.T) {
+	src := `package main;
+
+struct Box[T] { value: T }
+
+impl[T] Box[T] {
+    fn map[U](&self, f: fn(T) -> U) -> Box[U] {
+        return Box[U] { value: f(self.value) };
+    }
+}
+
+fn main() {
+    let b = Box[int] { value: 2 };
+    let c = b.map(|x: int| x > 1);
+}
+`
+	module, _ := lowerModule(t, src)
+
+	fn := findFunction(module, "Box::map$int,bool")
+	if fn == nil {
+		t.Fatal("expected specialization Box::map$int,bool")
+	}
+	f, ok := fn.Params[1].Type.(*types.Function)
+	if !ok {
+		t.Fatalf("f has type %v, want a function type", fn.Params[1].Type)
+	}
+	if f.Params[0] != types.TypeInt || f.Return != types.TypeBool {
+		t.Errorf("f has type %v, want fn(int) -> bool", f)
+	}
+}
+
+func TestMangleNameIsUnambiguous(t *testing.T) {
+	m := NewMonomorphizer(&Module{})
+	pair := func(args ...types.Type) types.Type {
+		return &types.GenericInstance{Base: &types.Named{Name: "Pair"}, Args: args}
+	}
+	named := func(name string) types.Type { return &types.Named{Name: name} }
+
+	tests := []struct {
+		args []types.Type
+		want string
+	}{
+		{[]types.Type{types.TypeInt}, "f$int"},
+		{[]types.Type{types.TypeInt, types.TypeBool}, "f$int,bool"},
+		{[]types.Type{named("int_bool")}, "f$int_bool"},
+		{[]types.Type{&types.Pointer{Elem: types.TypeInt}}, "f$*int"},
+		{[]types.Type{pair(types.TypeInt, types.TypeBool), types.TypeInt}, "f$(Pair$int,bool),int"},
+		{[]types.Type{pair(types.TypeInt), types.TypeBool, types.TypeInt}, "f$Pair$int,bool,int"},
+		{[]types.Type{&types.Reference{Elem: types.TypeInt}}, "f$&int"},
+		{[]types.Type{&types.Optional{Elem: types.TypeInt}}, "f$int?"},
+		{[]types.Type{&types.Tuple{Elements: []types.Type{types.TypeInt, types.TypeBool}}}, "f$((int,bool))"},
+	}
+	seen := make(map[string]bool)
+	for _, tt := range tests {
+		got := m.mangleName("f", tt.args)
+		if got != tt.want {
+			t.Errorf("mangleName(f, %v) = %q, want %q", tt.args, got, tt.want)
+		}
+		if seen[got] {
+			t.Errorf("%q names two specializations", got)
+		}
+		seen[got] = true
+	}
+}
+
+func TestMonomorphize_GenericEnumAndFieldLayouts(t *testing.T) {
+	src := `package main;
+
+enum Maybe[T] {
+    Just(T),
+    Nothing,
+}
+
+struct Box[T] { value: T }
+
+struct Holder {
+    b: Box[int],
+    m: Maybe[float],
+}
+
+fn main() {
+    let h = Holder { b: Box[int] { value: 1 }, m: Maybe::Just(2.5) };
+}
+`
+	file, checker := parseAndTypeCheck(t, src)
+	lowerer := NewLowerer(checker.ExprTypes, checker.CallTypeArgs, checker.GlobalScope, checker.MethodTable, checker.Modules)
+	module, err := lowerer.LowerModule(file)
+	if err != nil {
+		t.Fatalf("lowering error: %v", err)
+	}
+
+	// Each instance of a generic enum gets a definition of its own
+	var spec *types.Enum
+	for _, e := range module.Enums {
+		if e.Name == "Maybe$float" {
+			spec = e
+		}
+	}
+	if spec == nil {
+		t.Fatalf("expected the specialization Maybe$float:\n%s", module.PrettyPrint())
+	}
+	if len(spec.TypeParams) != 0 || spec.Variants[0].Params[0] != types.TypeFloat {
+		t.Errorf("Maybe$float should carry a float, got %v", spec.Variants[0].Params)
+	}
+
+	// and fields of non-generic structs name the specializations
+	var holder *types.Struct
+	for _, st := range module.Structs {
+		if st.Name == "Holder" {
+			holder = st
+		}
+	}
+	if holder == nil {
+		t.Fatal("struct Holder not found")
+	}
+	for i, want := range []string{"Box$int", "Maybe$float"} {
+		named, ok := holder.Fields[i].Type.(*types.Named)
+		if !ok || named.Name != want || named.Ref == nil {
+			t.Errorf("field %s has type %v, want the specialization %s", holder.Fields[i].Name, holder.Fields[i].Type, want)
+		}
+	}
+
+	// and so do the enums constructed in function bodies
+	for _, stmt := range findFunction(module, "main").Entry.Statements {
+		if cons, ok := stmt.(*ConstructEnum); ok && cons.Type != "Maybe$float" {
+			t.Errorf("Maybe::Just constructs %s, want Maybe$float", cons.Type)
+		}
+	}
+}
+
+func TestMonomorphize_InstantiationDepthLimit(t *testing.T) {
+	src := `package main;
+
+struct Wrap[T] { v: T }
+
+fn f[T](x: T, n: int) {
+    if n > 0 {
+        f[Wrap[T]](Wrap[T] { v: x }, n - 1);
+    }
+}
+
+fn main() {
+    f[int](1, 3);
+}
+`
+	file, checker := parseAndTypeCheck(t, src)
+	lowerer := NewLowerer(checker.ExprTypes, checker.CallTypeArgs, checker.GlobalScope, checker.MethodTable, checker.Modules)
+	if _, err := lowerer.LowerModule(file); err != nil {
+		t.Fatalf("lowering error: %v", err)
+	}
+	if len(lowerer.Errors) != 1 {
+		t.Fatalf("expected one instantiation depth error, got %v", lowerer.Errors)
+	}
+	d := lowerer.Errors[0]
+	if d.Code != diag.CodeGenInstantiationDepth {
+		t.Errorf("expected code %s, got %s", diag.CodeGenInstantiationDepth, d.Code)
+	}
+	if !strings.Contains(d.Message, "depth limit of 64") {
+		t.Errorf("message should name the limit, got %q", d.Message)
+	}
+	// The chain starts at main and ends with the step that was refused
+	if len(d.Notes) != 5 {
+		t.Fatalf("expected the first three steps, a gap and the last step, got %q", d.Notes)
+	}
+	if d.Notes[0] != "`f[int]` is instantiated by `main`" || d.Notes[3] != "... 61 more instantiations ..." {
+		t.Errorf("unexpected chain %q", d.Notes)
+	}
+}
+
+func TestMonomorphize_DepthLimitChain(t *testing.T) {
+	module := &Module{}
+	f := &Function{Name: "f", TypeParams: []types.TypeParam{{Name: "T"}}}
+	f.Entry = &BasicBlock{Label: "bb0", Statements: []Statement{
+		&Call{Func: "f", TypeArgs: []types.Type{&types.Array{Elem: &types.TypeParam{Name: "T"}, Len: 1}}},
+	}, Terminator: &Return{}}
+	f.Blocks = []*BasicBlock{f.Entry}
+	main := &Function{Name: "main"}
+	main.Entry = &BasicBlock{Label: "bb0", Statements: []Statement{
+		&Call{Func: "f", TypeArgs: []types.Type{types.TypeInt}},
+	}, Terminator: &Return{}}
+	main.Blocks = []*BasicBlock{main.Entry}
+	module.Functions = []*Function{f, main}
+
+	m := NewMonomorphizer(module)
+	m.DepthLimit = 3
+	err := m.Monomorphize()
+	var depthErr *InstantiationDepthError
+	if !errors.As(err, &depthErr) {
+		t.Fatalf("expected an InstantiationDepthError, got %v", err)
+	}
+	want := []string{"main", "f[int]", "f[[int; 1]]", "f[[[int; 1]; 1]]", "f[[[[int; 1]; 1]; 1]]"}
+	if strings.Join(depthErr.Chain, " -> ") != strings.Join(want, " -> ") {
+		t.Errorf("chain = %q, want %q", depthErr.Chain, want)
+	}
+}
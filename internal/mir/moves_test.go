@@ -0,0 +1,40 @@
+package mir
+
+import "testing"
+
+func TestLowerCopiesOnlyCopyStructs(t *testing.T) {
+	src := `package main;
+
+struct P { x: int }
+
+#[copy]
+struct C { x: int }
+
+fn main() {
+    let p = P { x: 1 };
+    let q = p;
+    let mut c = C { x: 2 };
+    let mut d = c;
+    d.x = 3;
+    println(q.x + c.x + d.x);
+}
+`
+	module, _ := lowerModule(t, src)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+
+	// The two literals, and a copy of c for d; q shares p's value
+	constructed := make(map[string]int)
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if cs, ok := stmt.(*ConstructStruct); ok {
+				constructed[cs.Type.String()]++
+			}
+		}
+	}
+	if constructed["P"] != 1 || constructed["C"] != 2 {
+		t.Errorf("constructed %v, want P once and C twice", constructed)
+	}
+}
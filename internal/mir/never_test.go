@@ -0,0 +1,62 @@
+package mir
+
+import "testing"
+
+func TestLowerEndlessLoop(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn serve() -> ! {
+    while true {
+        println(1);
+    }
+}
+fn main() {
+    serve();
+}
+`)
+	fn := findFunction(module, "serve")
+	if fn == nil {
+		t.Fatal("no serve in module")
+	}
+	for _, block := range fn.Blocks {
+		switch block.Terminator.(type) {
+		case nil:
+			t.Errorf("block %s has no terminator", block.Label)
+		case *Branch:
+			t.Errorf("block %s branches on the constant condition", block.Label)
+		case *Return:
+			t.Errorf("block %s returns from a function returning `!`", block.Label)
+		case *Unreachable:
+			if hasPredecessor(fn, block) {
+				t.Errorf("the end of the loop is reachable")
+			}
+		}
+	}
+}
+
+func TestLowerBranchThatReturns(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn f(a: int) -> int {
+    let x = if a > 0 { return 1; } else { 7 };
+    return x;
+}
+fn main() {
+    println(f(1));
+}
+`)
+	fn := findFunction(module, "f")
+	if fn == nil {
+		t.Fatal("no f in module")
+	}
+	for _, block := range fn.Blocks {
+		if _, ok := block.Terminator.(*Return); !ok {
+			continue
+		}
+		for _, stmt := range block.Statements {
+			if assign, ok := stmt.(*Assign); ok {
+				if lit, ok := assign.RHS.(*Literal); ok && lit.Value == nil {
+					t.Errorf("block %s stores a value for a branch that returned", block.Label)
+				}
+			}
+		}
+	}
+}
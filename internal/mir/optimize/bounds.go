@@ -0,0 +1,339 @@
+package optimize
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// EliminateBoundsChecks marks slice accesses whose index is known to be in
+// range, so that codegen addresses them without a bounds check. It looks for
+// the guard of a counting loop:
+//
+//	loop.header:
+//	  _3 = call len(s)
+//	  _4 = call __lt__(i, _3)
+//	  if _4 goto loop.body else goto loop.end
+//
+// where i is never negative: it has an unsigned type, or is only ever set to
+// a non-negative literal or incremented. Accesses s[i] in the blocks only
+// reachable through the guard's true edge are in bounds until i or s is
+// written or a call that could shrink s is made.
+//
+// The module is updated in place and returned.
+func EliminateBoundsChecks(module *mir.Module) *mir.Module {
+	for _, fn := range module.Functions {
+		eliminateBoundsChecks(fn)
+	}
+	return module
+}
+
+// boundsGuard is a branch on `index < len(slice)`
+type boundsGuard struct {
+	index mir.Local
+	slice int
+	body  *mir.BasicBlock
+}
+
+func eliminateBoundsChecks(fn *mir.Function) {
+	taken := addressTaken(fn)
+	defs := localDefinitions(fn)
+	preds := make(map[*mir.BasicBlock][]*mir.BasicBlock)
+	for _, block := range fn.Blocks {
+		for _, succ := range getSuccessors(block) {
+			preds[succ] = append(preds[succ], block)
+		}
+	}
+
+	for _, block := range fn.Blocks {
+		guard, ok := findBoundsGuard(block)
+		if !ok || taken[guard.index.ID] || taken[guard.slice] {
+			continue
+		}
+		if body := preds[guard.body]; len(body) != 1 || body[0] != block {
+			continue
+		}
+		if !nonNegative(guard.index, defs) {
+			continue
+		}
+		markInBounds(guard, preds)
+	}
+}
+
+// findBoundsGuard reports whether block ends in a branch on
+// `index < len(slice)`, with neither compared value changing between the
+// comparison and the end of the block.
+func findBoundsGuard(block *mir.BasicBlock) (boundsGuard, bool) {
+	br, ok := block.Terminator.(*mir.Branch)
+	if !ok || br.True == br.False {
+		return boundsGuard{}, false
+	}
+	cond, ok := br.Condition.(*mir.LocalRef)
+	if !ok {
+		return boundsGuard{}, false
+	}
+
+	cmpAt := lastDefinition(block, cond.Local.ID)
+	if cmpAt < 0 {
+		return boundsGuard{}, false
+	}
+	cmp, ok := block.Statements[cmpAt].(*mir.Call)
+	if !ok || len(cmp.Args) != 2 {
+		return boundsGuard{}, false
+	}
+	var indexOp, lenOp mir.Operand
+	switch cmp.Func {
+	case "__lt__":
+		indexOp, lenOp = cmp.Args[0], cmp.Args[1]
+	case "__gt__":
+		lenOp, indexOp = cmp.Args[0], cmp.Args[1]
+	default:
+		return boundsGuard{}, false
+	}
+	index, ok := indexOp.(*mir.LocalRef)
+	if !ok {
+		return boundsGuard{}, false
+	}
+	length, ok := lenOp.(*mir.LocalRef)
+	if !ok {
+		return boundsGuard{}, false
+	}
+
+	lenAt := lastDefinition(block, length.Local.ID)
+	if lenAt < 0 || lenAt > cmpAt {
+		return boundsGuard{}, false
+	}
+	// `i < s.len() as int` compares against a cast of the length
+	callAt := lenAt
+	if cast, ok := block.Statements[lenAt].(*mir.Cast); ok {
+		ref, ok := cast.Operand.(*mir.LocalRef)
+		if !ok {
+			return boundsGuard{}, false
+		}
+		callAt = lastDefinition(block, ref.Local.ID)
+		if callAt < 0 || callAt > lenAt {
+			return boundsGuard{}, false
+		}
+	}
+	lenCall, ok := block.Statements[callAt].(*mir.Call)
+	if !ok || !isLenCall(lenCall) {
+		return boundsGuard{}, false
+	}
+	slice, ok := lenCall.Args[0].(*mir.LocalRef)
+	if !ok {
+		return boundsGuard{}, false
+	}
+
+	guard := boundsGuard{index: index.Local, slice: slice.Local.ID, body: br.True}
+	for i, stmt := range block.Statements[callAt+1:] {
+		if invalidatesGuard(stmt, guard) {
+			return boundsGuard{}, false
+		}
+		result := statementResult(stmt)
+		if result != nil && result.ID == length.Local.ID && callAt+1+i != lenAt {
+			return boundsGuard{}, false
+		}
+	}
+	return guard, true
+}
+
+// markInBounds marks the accesses guarded by guard, following control flow
+// from the guarded block into blocks that can only be entered from it.
+func markInBounds(guard boundsGuard, preds map[*mir.BasicBlock][]*mir.BasicBlock) {
+	visited := make(map[*mir.BasicBlock]bool)
+	worklist := []*mir.BasicBlock{guard.body}
+	for len(worklist) > 0 {
+		block := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		if visited[block] {
+			continue
+		}
+		visited[block] = true
+
+		valid := true
+		for _, stmt := range block.Statements {
+			switch s := stmt.(type) {
+			case *mir.LoadIndex:
+				if guard.covers(s.Target, s.Indices) {
+					s.InBounds = true
+				}
+			case *mir.StoreIndex:
+				if guard.covers(s.Target, s.Indices) {
+					s.InBounds = true
+				}
+			}
+			if invalidatesGuard(stmt, guard) {
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			continue
+		}
+		for _, succ := range getSuccessors(block) {
+			if p := preds[succ]; len(p) == 1 && p[0] == block {
+				worklist = append(worklist, succ)
+			}
+		}
+	}
+}
+
+// covers reports whether an access target[indices] is guard's slice[index]
+func (guard boundsGuard) covers(target mir.Operand, indices []mir.Operand) bool {
+	if len(indices) != 1 {
+		return false
+	}
+	slice, ok := target.(*mir.LocalRef)
+	if !ok || slice.Local.ID != guard.slice {
+		return false
+	}
+	index, ok := indices[0].(*mir.LocalRef)
+	return ok && index.Local.ID == guard.index.ID
+}
+
+// invalidatesGuard reports whether stmt may change the index or the length
+// of the slice a guard compares. Calls other than operators and a few
+// builtins could shrink the slice, so they end the guarded region.
+func invalidatesGuard(stmt mir.Statement, guard boundsGuard) bool {
+	if result := statementResult(stmt); result != nil {
+		if result.ID == guard.index.ID || result.ID == guard.slice {
+			return true
+		}
+	}
+	if call, ok := stmt.(*mir.Call); ok {
+		return !isOperatorIntrinsic(call.Func) && !preservesLength(call.Func)
+	}
+	return false
+}
+
+// preservesLength reports whether the builtin name cannot change the length
+// of a slice passed to it
+func preservesLength(name string) bool {
+	switch name {
+	case "len", "runtime_slice_len", "print", "println":
+		return true
+	}
+	return false
+}
+
+func isLenCall(call *mir.Call) bool {
+	return (call.Func == "len" || call.Func == "runtime_slice_len") && len(call.Args) == 1
+}
+
+// lastDefinition returns the index of the last statement in block writing
+// the local id, or -1
+func lastDefinition(block *mir.BasicBlock, id int) int {
+	for i := len(block.Statements) - 1; i >= 0; i-- {
+		if result := statementResult(block.Statements[i]); result != nil && result.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// localDefinitions maps each local to the statements writing it.
+// Parameters and select results map to a nil entry, for an unknown value.
+func localDefinitions(fn *mir.Function) map[int][]mir.Statement {
+	defs := make(map[int][]mir.Statement)
+	for _, param := range fn.Params {
+		defs[param.ID] = append(defs[param.ID], nil)
+	}
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if result := statementResult(stmt); result != nil {
+				defs[result.ID] = append(defs[result.ID], stmt)
+			}
+		}
+		if sel, ok := block.Terminator.(*mir.Select); ok {
+			for _, c := range sel.Cases {
+				if c.Result != nil {
+					defs[c.Result.ID] = append(defs[c.Result.ID], nil)
+				}
+			}
+		}
+	}
+	return defs
+}
+
+// nonNegative reports whether index can never hold a negative value: its
+// type is unsigned, or every write sets it to a non-negative literal or adds
+// a non-negative literal to it.
+func nonNegative(index mir.Local, defs map[int][]mir.Statement) bool {
+	if isUnsignedType(index.Type) {
+		return true
+	}
+	for _, def := range defs[index.ID] {
+		switch s := def.(type) {
+		case *mir.Assign:
+			if !nonNegativeValue(s.RHS, index, defs) {
+				return false
+			}
+		case *mir.Call:
+			if !isIncrement(s, index) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// nonNegativeValue reports whether op, assigned to index, is a non-negative
+// literal or a temporary holding an increment of index
+func nonNegativeValue(op mir.Operand, index mir.Local, defs map[int][]mir.Statement) bool {
+	switch op := op.(type) {
+	case *mir.Literal:
+		return nonNegativeLiteral(op)
+	case *mir.LocalRef:
+		tempDefs := defs[op.Local.ID]
+		if len(tempDefs) != 1 {
+			return false
+		}
+		switch s := tempDefs[0].(type) {
+		case *mir.Call:
+			return isIncrement(s, index)
+		case *mir.Cast:
+			lit, ok := s.Operand.(*mir.Literal)
+			return ok && nonNegativeLiteral(lit)
+		}
+	}
+	return false
+}
+
+// isIncrement reports whether call adds a non-negative literal to index
+func isIncrement(call *mir.Call, index mir.Local) bool {
+	if call.Func != "__add__" || len(call.Args) != 2 {
+		return false
+	}
+	for i, arg := range call.Args {
+		ref, ok := arg.(*mir.LocalRef)
+		if !ok || ref.Local.ID != index.ID {
+			continue
+		}
+		lit, ok := call.Args[1-i].(*mir.Literal)
+		return ok && nonNegativeLiteral(lit)
+	}
+	return false
+}
+
+func nonNegativeLiteral(lit *mir.Literal) bool {
+	switch v := lit.Value.(type) {
+	case int64:
+		return v >= 0
+	case int:
+		return v >= 0
+	}
+	return false
+}
+
+func isUnsignedType(t types.Type) bool {
+	p, ok := t.(*types.Primitive)
+	if !ok {
+		return false
+	}
+	switch p.Kind {
+	case types.U8, types.U16, types.U32, types.U64, types.U128, types.Usize:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package optimize
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// countingLoop builds
+//
+//	entry:  i = start; goto header
+//	header: n = len(s); c = i < n; if c goto body else goto end
+//	body:   x = s[i]; s[i] = x; <extra>; i = i + 1; goto header
+//	end:    return
+//
+// and returns the function with the body's load and store
+func countingLoop(start int64, extra ...mir.Statement) (*mir.Function, *mir.LoadIndex, *mir.StoreIndex) {
+	s := mir.Local{ID: 1, Name: "s", Type: &types.Slice{Elem: types.TypeInt}}
+	i := mir.Local{ID: 2, Name: "i", Type: types.TypeInt}
+	n := mir.Local{ID: 3, Name: "_3", Type: types.TypeInt}
+	c := mir.Local{ID: 4, Name: "_4", Type: types.TypeBool}
+	x := mir.Local{ID: 5, Name: "x", Type: types.TypeInt}
+
+	end := &mir.BasicBlock{Label: "end", Terminator: &mir.Return{}}
+	header := &mir.BasicBlock{Label: "header"}
+	load := &mir.LoadIndex{Result: x, Target: ref(s), Indices: []mir.Operand{ref(i)}}
+	store := &mir.StoreIndex{Target: ref(s), Indices: []mir.Operand{ref(i)}, Value: ref(x)}
+	body := &mir.BasicBlock{Label: "body", Statements: append(append([]mir.Statement{load, store}, extra...),
+		&mir.Call{Result: i, Func: "__add__", Args: []mir.Operand{ref(i), intLit(1)}},
+	), Terminator: &mir.Goto{Target: header}}
+	header.Statements = []mir.Statement{
+		&mir.Call{Result: n, Func: "len", Args: []mir.Operand{ref(s)}},
+		&mir.Call{Result: c, Func: "__lt__", Args: []mir.Operand{ref(i), ref(n)}},
+	}
+	header.Terminator = &mir.Branch{Condition: ref(c), True: body, False: end}
+	entry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{
+		&mir.Assign{Local: i, RHS: intLit(start)},
+	}, Terminator: &mir.Goto{Target: header}}
+
+	fn := &mir.Function{
+		Name:       "f",
+		Params:     []mir.Local{s},
+		Entry:      entry,
+		Blocks:     []*mir.BasicBlock{entry, header, body, end},
+		Locals:     []mir.Local{i, n, c, x},
+		ReturnType: types.TypeVoid,
+	}
+	return fn, load, store
+}
+
+// TestEliminateBoundsChecks tests that accesses indexed by the counter of a
+// loop guarded by `i < len(s)` are marked in bounds
+func TestEliminateBoundsChecks(t *testing.T) {
+	fn, load, store := countingLoop(0)
+
+	EliminateBoundsChecks(&mir.Module{Functions: []*mir.Function{fn}})
+
+	if !load.InBounds {
+		t.Errorf("expected the load to be marked in bounds")
+	}
+	if !store.InBounds {
+		t.Errorf("expected the store to be marked in bounds")
+	}
+}
+
+// TestEliminateBoundsChecksKeepsUnprovenChecks tests that checks are kept
+// when the counter may be negative or a call may shrink the slice
+func TestEliminateBoundsChecksKeepsUnprovenChecks(t *testing.T) {
+	fn, load, _ := countingLoop(-1)
+	EliminateBoundsChecks(&mir.Module{Functions: []*mir.Function{fn}})
+	if load.InBounds {
+		t.Errorf("expected the load from a counter starting at -1 to stay checked")
+	}
+
+	pop := &mir.Call{Result: mir.Local{ID: 6, Type: types.TypeInt}, Func: "pop", Args: []mir.Operand{
+		&mir.LocalRef{Local: mir.Local{ID: 1, Name: "s", Type: &types.Slice{Elem: types.TypeInt}}},
+	}}
+	fn, load, store := countingLoop(0, pop)
+	EliminateBoundsChecks(&mir.Module{Functions: []*mir.Function{fn}})
+	if !load.InBounds || !store.InBounds {
+		t.Errorf("expected the accesses before the call to be marked in bounds")
+	}
+	after := &mir.LoadIndex{Result: mir.Local{ID: 7, Type: types.TypeInt}, Target: pop.Args[0], Indices: load.Indices}
+	fn, _, _ = countingLoop(0, pop, after)
+	EliminateBoundsChecks(&mir.Module{Functions: []*mir.Function{fn}})
+	if after.InBounds {
+		t.Errorf("expected the load after the call to stay checked")
+	}
+}
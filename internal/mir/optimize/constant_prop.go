@@ -301,6 +301,7 @@ func replaceConstants(fn *mir.Function, lattice map[int]*ConstantInfo) *mir.Func
 		Locals:     fn.Locals,
 		Blocks:     make([]*mir.BasicBlock, 0, len(fn.Blocks)),
 		Entry:      nil,
+		Inline:     fn.Inline,
 	}
 
 	// Map old blocks to new blocks
@@ -382,15 +383,22 @@ func replaceOperandsInStatement(stmt mir.Statement, lattice map[int]*ConstantInf
 			Value:  replaceOperand(s.Value, lattice),
 		}
 
+	case *mir.StoreStatic:
+		return &mir.StoreStatic{
+			Name:  s.Name,
+			Value: replaceOperand(s.Value, lattice),
+		}
+
 	case *mir.LoadIndex:
 		newIndices := make([]mir.Operand, len(s.Indices))
 		for i, idx := range s.Indices {
 			newIndices[i] = replaceOperand(idx, lattice)
 		}
 		return &mir.LoadIndex{
-			Result:  s.Result,
-			Target:  replaceOperand(s.Target, lattice),
-			Indices: newIndices,
+			Result:   s.Result,
+			Target:   replaceOperand(s.Target, lattice),
+			Indices:  newIndices,
+			InBounds: s.InBounds,
 		}
 
 	case *mir.StoreIndex:
@@ -399,9 +407,10 @@ func replaceOperandsInStatement(stmt mir.Statement, lattice map[int]*ConstantInf
 			newIndices[i] = replaceOperand(idx, lattice)
 		}
 		return &mir.StoreIndex{
-			Target:  replaceOperand(s.Target, lattice),
-			Indices: newIndices,
-			Value:   replaceOperand(s.Value, lattice),
+			Target:   replaceOperand(s.Target, lattice),
+			Indices:  newIndices,
+			Value:    replaceOperand(s.Value, lattice),
+			InBounds: s.InBounds,
 		}
 
 	case *mir.ConstructStruct:
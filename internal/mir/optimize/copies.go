@@ -0,0 +1,148 @@
+package optimize
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+// RemoveRedundantCopies removes the copies lowering introduces when a value
+// is computed into a temporary and then moved into its variable:
+//
+//	_3 = construct_struct Point {x: i, y: 2}
+//	p = _3
+//
+// becomes `p = construct_struct Point {...}`. The rewrite applies when the
+// temporary is written once and read only by the copy, the copy follows in
+// the same block, and the variable is not touched in between. Self-copies
+// (`x = x`) are dropped outright.
+//
+// The module is updated in place and returned.
+func RemoveRedundantCopies(module *mir.Module) *mir.Module {
+	for _, fn := range module.Functions {
+		removeCopies(fn)
+	}
+	return module
+}
+
+func removeCopies(fn *mir.Function) {
+	uses := localUseCounts(fn)
+	taken := addressTaken(fn)
+	defs := make(map[int]int)
+	for _, param := range fn.Params {
+		defs[param.ID]++
+	}
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if result := statementResult(stmt); result != nil {
+				defs[result.ID]++
+			}
+		}
+		if sel, ok := block.Terminator.(*mir.Select); ok {
+			for _, c := range sel.Cases {
+				if c.Result != nil {
+					defs[c.Result.ID]++
+				}
+			}
+		}
+	}
+
+	removed := make(map[int]bool)
+	for _, block := range fn.Blocks {
+		kept := block.Statements[:0]
+		for i := 0; i < len(block.Statements); i++ {
+			stmt := block.Statements[i]
+			if assign, ok := stmt.(*mir.Assign); ok {
+				if ref, ok := assign.RHS.(*mir.LocalRef); ok && ref.Local.ID == assign.Local.ID {
+					continue
+				}
+			}
+
+			result := statementResult(stmt)
+			if result != nil && retargetable(stmt) && defs[result.ID] == 1 && uses[result.ID] == 1 && !taken[result.ID] {
+				if j, dest := findCopy(block.Statements, i, *result); j > i && !taken[dest.ID] {
+					removed[result.ID] = true
+					*result = dest
+					block.Statements[j] = nil
+				}
+			}
+			if stmt != nil {
+				kept = append(kept, stmt)
+			}
+		}
+		block.Statements = kept
+	}
+
+	if len(removed) == 0 {
+		return
+	}
+	locals := fn.Locals[:0]
+	for _, local := range fn.Locals {
+		if !removed[local.ID] {
+			locals = append(locals, local)
+		}
+	}
+	fn.Locals = locals
+}
+
+// findCopy looks for `dest = temp` after statement i in the same block. It
+// returns the copy's index and destination, or -1 if temp is read by
+// anything else first or dest is read or written in between.
+func findCopy(stmts []mir.Statement, i int, temp mir.Local) (int, mir.Local) {
+	var dest mir.Local
+	for j := i + 1; j < len(stmts); j++ {
+		if stmts[j] == nil {
+			continue
+		}
+		if assign, ok := stmts[j].(*mir.Assign); ok {
+			if ref, ok := assign.RHS.(*mir.LocalRef); ok && ref.Local.ID == temp.ID {
+				dest = assign.Local
+				if dest.ID == temp.ID || !sameType(dest, temp) {
+					return -1, dest
+				}
+				for k := i + 1; k < j; k++ {
+					if stmts[k] != nil && mentions(stmts[k], dest.ID) {
+						return -1, dest
+					}
+				}
+				return j, dest
+			}
+		}
+		if mentions(stmts[j], temp.ID) {
+			return -1, dest
+		}
+	}
+	return -1, dest
+}
+
+// retargetable reports whether stmt computes a fresh value whose result
+// local can be swapped for another one without changing codegen
+func retargetable(stmt mir.Statement) bool {
+	switch s := stmt.(type) {
+	case *mir.Call, *mir.LoadField, *mir.LoadIndex,
+		*mir.ConstructStruct, *mir.ConstructArray, *mir.ConstructTuple, *mir.ConstructEnum:
+		return true
+	case *mir.Assign:
+		_, isLiteral := s.RHS.(*mir.Literal)
+		return isLiteral
+	}
+	return false
+}
+
+// mentions reports whether stmt reads or writes the local id
+func mentions(stmt mir.Statement, id int) bool {
+	if result := statementResult(stmt); result != nil && result.ID == id {
+		return true
+	}
+	for _, op := range statementUses(stmt) {
+		if ref, ok := op.(*mir.LocalRef); ok && ref.Local.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sameType(a, b mir.Local) bool {
+	if a.Type == nil || b.Type == nil {
+		return a.Type == b.Type
+	}
+	return a.Type.String() == b.Type.String()
+}
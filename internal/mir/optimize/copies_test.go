@@ -0,0 +1,62 @@
+package optimize
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// TestRemoveRedundantCopies tests that a temporary copied into a variable
+// is replaced by the variable
+func TestRemoveRedundantCopies(t *testing.T) {
+	temp := mir.Local{ID: 1, Name: "_1", Type: escapePointType}
+	p := mir.Local{ID: 2, Name: "p", Type: escapePointType}
+	x := mir.Local{ID: 3, Name: "x", Type: types.TypeInt}
+
+	cons := constructPoint(temp)
+	entry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{
+		cons,
+		&mir.Assign{Local: p, RHS: ref(temp)},
+		&mir.Assign{Local: p, RHS: ref(p)},
+		&mir.LoadField{Result: x, Target: ref(p), Field: "x"},
+	}, Terminator: &mir.Return{Value: ref(x)}}
+	fn := &mir.Function{Name: "f", Entry: entry, Blocks: []*mir.BasicBlock{entry}, Locals: []mir.Local{temp, p, x}, ReturnType: types.TypeInt}
+
+	RemoveRedundantCopies(&mir.Module{Functions: []*mir.Function{fn}})
+
+	if len(entry.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(entry.Statements))
+	}
+	if entry.Statements[0] != cons || cons.Result.ID != p.ID {
+		t.Errorf("expected the construction to write p directly")
+	}
+	if len(fn.Locals) != 2 || fn.Locals[0].ID != p.ID {
+		t.Errorf("expected the temporary to be removed from locals")
+	}
+}
+
+// TestRemoveRedundantCopiesKeepsObservableCopies tests that copies are kept
+// when the temporary is read again or the variable is used in between
+func TestRemoveRedundantCopiesKeepsObservableCopies(t *testing.T) {
+	temp := mir.Local{ID: 1, Name: "_1", Type: types.TypeInt}
+	total := mir.Local{ID: 2, Name: "total", Type: types.TypeInt}
+	other := mir.Local{ID: 3, Name: "other", Type: types.TypeInt}
+	temp2 := mir.Local{ID: 4, Name: "_4", Type: types.TypeInt}
+
+	entry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{
+		&mir.Call{Result: temp, Func: "__add__", Args: []mir.Operand{ref(total), intLit(1)}},
+		&mir.Assign{Local: total, RHS: ref(temp)},
+		&mir.Assign{Local: other, RHS: ref(temp)},
+		&mir.Call{Result: temp2, Func: "__add__", Args: []mir.Operand{ref(total), intLit(1)}},
+		&mir.Call{Result: mir.Local{ID: 5, Type: types.TypeVoid}, Func: "println", Args: []mir.Operand{ref(total)}},
+		&mir.Assign{Local: total, RHS: ref(temp2)},
+	}, Terminator: &mir.Return{}}
+	fn := &mir.Function{Name: "f", Entry: entry, Blocks: []*mir.BasicBlock{entry}, ReturnType: types.TypeVoid}
+
+	RemoveRedundantCopies(&mir.Module{Functions: []*mir.Function{fn}})
+
+	if len(entry.Statements) != 6 {
+		t.Errorf("expected all 6 statements to be kept, got %d", len(entry.Statements))
+	}
+}
@@ -18,6 +18,45 @@ func EliminateDeadCode(module *mir.Module) *mir.Module {
 	return optimizedModule
 }
 
+// EliminateDeadBlocks removes blocks that cannot be reached from their
+// function's entry, such as the untaken side of a folded branch. Phi inputs
+// arriving from removed blocks are dropped.
+//
+// Unlike EliminateDeadCode it keeps every local and updates the module in
+// place, so it is safe to run ahead of code generation.
+func EliminateDeadBlocks(module *mir.Module) *mir.Module {
+	for _, fn := range module.Functions {
+		if fn.Entry == nil {
+			continue
+		}
+		reachable := markReachableBlocks(fn)
+		if len(reachable) == len(fn.Blocks) {
+			continue
+		}
+
+		live := fn.Blocks[:0]
+		for _, block := range fn.Blocks {
+			if reachable[block] {
+				live = append(live, block)
+			}
+		}
+		fn.Blocks = live
+
+		for _, block := range fn.Blocks {
+			for _, stmt := range block.Statements {
+				if phi, ok := stmt.(*mir.Phi); ok {
+					for pred := range phi.Inputs {
+						if !reachable[pred] {
+							delete(phi.Inputs, pred)
+						}
+					}
+				}
+			}
+		}
+	}
+	return module
+}
+
 // eliminateDeadCodeInFunction removes dead code from a single function
 func eliminateDeadCodeInFunction(fn *mir.Function) *mir.Function {
 	// Step 1: Mark reachable blocks
@@ -57,6 +96,7 @@ func eliminateDeadCodeInFunction(fn *mir.Function) *mir.Function {
 		Locals:     liveLocals,
 		Blocks:     liveBlocks,
 		Entry:      fn.Entry,
+		Inline:     fn.Inline,
 	}
 
 	return optimizedFn
@@ -100,6 +140,12 @@ func getSuccessors(block *mir.BasicBlock) []*mir.BasicBlock {
 		return []*mir.BasicBlock{term.Target}
 	case *mir.Branch:
 		return []*mir.BasicBlock{term.True, term.False}
+	case *mir.Select:
+		succs := make([]*mir.BasicBlock, 0, len(term.Cases))
+		for _, c := range term.Cases {
+			succs = append(succs, c.Target)
+		}
+		return succs
 	case *mir.Return:
 		return nil
 	default:
@@ -149,6 +195,12 @@ func visitStatementForUses(stmt mir.Statement, used map[int]bool) {
 		visitOperandForUses(s.Target, used)
 		visitOperandForUses(s.Value, used)
 
+	case *mir.LoadStatic:
+		used[s.Result.ID] = true
+
+	case *mir.StoreStatic:
+		visitOperandForUses(s.Value, used)
+
 	case *mir.LoadIndex:
 		used[s.Result.ID] = true
 		visitOperandForUses(s.Target, used)
@@ -185,11 +237,22 @@ func visitStatementForUses(stmt mir.Statement, used map[int]bool) {
 		used[s.Result.ID] = true
 		visitOperandForUses(s.Target, used)
 
+	case *mir.PtrOffset:
+		used[s.Result.ID] = true
+		visitOperandForUses(s.Pointer, used)
+		visitOperandForUses(s.Offset, used)
+
 	case *mir.Phi:
 		used[s.Result.ID] = true
 		for _, input := range s.Inputs {
 			visitOperandForUses(input, used)
 		}
+
+	case *mir.RegisterDrop:
+		visitOperandForUses(s.Value, used)
+
+	case *mir.CancelDrop:
+		visitOperandForUses(s.Value, used)
 	}
 }
 
@@ -223,3 +223,41 @@ func TestBuildUsedLocals(t *testing.T) {
 		t.Error("unused should not be marked as used")
 	}
 }
+
+// TestEliminateDeadBlocksInPlace tests that dead blocks are removed without
+// touching locals, and that phi inputs from removed blocks are dropped
+func TestEliminateDeadBlocksInPlace(t *testing.T) {
+	x := mir.Local{ID: 1, Name: "x", Type: types.TypeInt}
+	unused := mir.Local{ID: 2, Name: "unused", Type: types.TypeInt}
+
+	entry := &mir.BasicBlock{Label: "entry"}
+	dead := &mir.BasicBlock{Label: "dead"}
+	merge := &mir.BasicBlock{Label: "merge"}
+	phi := &mir.Phi{Result: x, Inputs: map[*mir.BasicBlock]mir.Operand{
+		entry: &mir.Literal{Type: types.TypeInt, Value: int64(1)},
+		dead:  &mir.Literal{Type: types.TypeInt, Value: int64(2)},
+	}}
+	entry.Terminator = &mir.Goto{Target: merge}
+	dead.Terminator = &mir.Goto{Target: merge}
+	merge.Statements = []mir.Statement{phi}
+	merge.Terminator = &mir.Return{Value: &mir.LocalRef{Local: x}}
+
+	fn := &mir.Function{
+		Name:       "test",
+		Entry:      entry,
+		Blocks:     []*mir.BasicBlock{entry, dead, merge},
+		Locals:     []mir.Local{x, unused},
+		ReturnType: types.TypeInt,
+	}
+	EliminateDeadBlocks(&mir.Module{Functions: []*mir.Function{fn}})
+
+	if len(fn.Blocks) != 2 || fn.Blocks[0] != entry || fn.Blocks[1] != merge {
+		t.Errorf("expected blocks [entry merge], got %d blocks", len(fn.Blocks))
+	}
+	if len(fn.Locals) != 2 {
+		t.Errorf("expected locals to be kept, got %d", len(fn.Locals))
+	}
+	if _, ok := phi.Inputs[dead]; ok || len(phi.Inputs) != 1 {
+		t.Errorf("expected phi input from the dead block to be dropped")
+	}
+}
@@ -0,0 +1,339 @@
+package optimize
+
+import (
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+// AnalyzeEscapes marks struct, tuple, enum and closure constructions whose
+// value provably never outlives the function that builds it. The code
+// generator places marked values in the stack frame instead of the GC heap.
+//
+// A value escapes when any local that may hold it is returned, stored into
+// another value, sent on a channel, handed to spawn, or passed to a callee
+// that lets the matching parameter escape. Parameter summaries are computed
+// for every function in the module up to a fixed point, so calls between
+// module functions do not force a heap allocation.
+//
+// The module is updated in place and returned.
+func AnalyzeEscapes(module *mir.Module) *mir.Module {
+	facts := make(map[*mir.Function]*escapeFacts, len(module.Functions))
+	summaries := make(map[string][]bool, len(module.Functions))
+	for _, fn := range module.Functions {
+		facts[fn] = collectEscapeFacts(fn)
+		summaries[fn.Name] = make([]bool, len(fn.Params))
+	}
+
+	// Summaries only ever flip from false to true, so this terminates
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range module.Functions {
+			for i, param := range fn.Params {
+				if summaries[fn.Name][i] {
+					continue
+				}
+				if facts[fn].escapes(param.ID, summaries) {
+					summaries[fn.Name][i] = true
+					changed = true
+				}
+			}
+		}
+	}
+
+	for _, fn := range module.Functions {
+		markStackAllocations(fn, facts[fn], summaries)
+	}
+
+	return module
+}
+
+// escapeFacts records how values flow between the locals of one function
+type escapeFacts struct {
+	// flows maps a local to the locals that may receive its value
+	flows map[int][]int
+	// sinks holds locals whose value leaves the function
+	sinks map[int]bool
+	// args lists locals passed to module functions, resolved against the
+	// callee's parameter summary
+	args []escapeArg
+	// defs counts the statements that write each local
+	defs map[int]int
+}
+
+type escapeArg struct {
+	local  int
+	callee string
+	index  int
+}
+
+func collectEscapeFacts(fn *mir.Function) *escapeFacts {
+	f := &escapeFacts{
+		flows: make(map[int][]int),
+		sinks: make(map[int]bool),
+		defs:  make(map[int]int),
+	}
+	for _, param := range fn.Params {
+		f.defs[param.ID]++
+	}
+
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			f.collectStatement(stmt)
+		}
+		switch term := block.Terminator.(type) {
+		case *mir.Return:
+			f.sink(term.Value)
+		case *mir.Select:
+			for _, c := range term.Cases {
+				f.sink(c.Value)
+				if c.Result != nil {
+					f.defs[c.Result.ID]++
+				}
+			}
+		}
+	}
+
+	return f
+}
+
+func (f *escapeFacts) collectStatement(stmt mir.Statement) {
+	switch s := stmt.(type) {
+	case *mir.Assign:
+		f.defs[s.Local.ID]++
+		f.flow(s.RHS, s.Local)
+	case *mir.Phi:
+		f.defs[s.Result.ID]++
+		for _, input := range s.Inputs {
+			f.flow(input, s.Result)
+		}
+	case *mir.Cast:
+		f.defs[s.Result.ID]++
+		f.flow(s.Operand, s.Result)
+	case *mir.Load:
+		f.defs[s.Result.ID]++
+		f.flow(s.Address, s.Result)
+	case *mir.PtrOffset:
+		f.defs[s.Result.ID]++
+		f.flow(s.Pointer, s.Result)
+	case *mir.LoadStatic:
+		f.defs[s.Result.ID]++
+	case *mir.AddressOf:
+		f.defs[s.Result.ID]++
+		f.flow(&mir.LocalRef{Local: s.Target}, s.Result)
+	case *mir.AccessVariantPayload:
+		f.defs[s.Result.ID]++
+		f.flow(s.Target, s.Result)
+	case *mir.MakeClosure:
+		// The closure keeps its environment alive
+		f.defs[s.Result.ID]++
+		f.flow(s.Env, s.Result)
+	case *mir.Call:
+		f.defs[s.Result.ID]++
+		if s.FuncOperand != nil || s.Func == "" {
+			for _, arg := range s.Args {
+				f.sink(arg)
+			}
+			return
+		}
+		if isNonRetainingBuiltin(s.Func) {
+			return
+		}
+		for i, arg := range s.Args {
+			if ref, ok := arg.(*mir.LocalRef); ok {
+				f.args = append(f.args, escapeArg{local: ref.Local.ID, callee: s.Func, index: i})
+			}
+		}
+	case *mir.Spawn:
+		if s.Result != nil {
+			f.defs[s.Result.ID]++
+		}
+		for _, arg := range s.Args {
+			f.sink(arg)
+		}
+	case *mir.Send:
+		f.sink(s.Value)
+	case *mir.StoreField:
+		f.sink(s.Value)
+	case *mir.StoreStatic:
+		f.sink(s.Value)
+	case *mir.StoreIndex:
+		f.sink(s.Value)
+	case *mir.ConstructStruct:
+		f.defs[s.Result.ID]++
+		for _, v := range s.Fields {
+			f.sink(v)
+		}
+	case *mir.ConstructArray:
+		f.defs[s.Result.ID]++
+		for _, v := range s.Elements {
+			f.sink(v)
+		}
+	case *mir.ConstructTuple:
+		f.defs[s.Result.ID]++
+		for _, v := range s.Elements {
+			f.sink(v)
+		}
+	case *mir.ConstructEnum:
+		f.defs[s.Result.ID]++
+		for _, v := range s.Values {
+			f.sink(v)
+		}
+	case *mir.LoadField:
+		f.defs[s.Result.ID]++
+	case *mir.LoadIndex:
+		f.defs[s.Result.ID]++
+	case *mir.Discriminant:
+		f.defs[s.Result.ID]++
+	case *mir.MakeChannel:
+		f.defs[s.Result.ID]++
+	case *mir.Receive:
+		f.defs[s.Result.ID]++
+	case *mir.Join:
+		f.defs[s.Result.ID]++
+	case *mir.SizeOf:
+		f.defs[s.Result.ID]++
+	case *mir.AlignOf:
+		f.defs[s.Result.ID]++
+	}
+}
+
+func (f *escapeFacts) flow(from mir.Operand, to mir.Local) {
+	if ref, ok := from.(*mir.LocalRef); ok {
+		f.flows[ref.Local.ID] = append(f.flows[ref.Local.ID], to.ID)
+	}
+}
+
+func (f *escapeFacts) sink(op mir.Operand) {
+	if ref, ok := op.(*mir.LocalRef); ok {
+		f.sinks[ref.Local.ID] = true
+	}
+}
+
+// holders returns every local that may hold the value stored in root
+func (f *escapeFacts) holders(root int) map[int]bool {
+	seen := map[int]bool{root: true}
+	worklist := []int{root}
+	for len(worklist) > 0 {
+		id := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+		for _, next := range f.flows[id] {
+			if !seen[next] {
+				seen[next] = true
+				worklist = append(worklist, next)
+			}
+		}
+	}
+	return seen
+}
+
+// escapes reports whether the value held in root can leave the function
+func (f *escapeFacts) escapes(root int, summaries map[string][]bool) bool {
+	return f.holdersEscape(f.holders(root), summaries)
+}
+
+func (f *escapeFacts) holdersEscape(holders map[int]bool, summaries map[string][]bool) bool {
+	for id := range holders {
+		if f.sinks[id] {
+			return true
+		}
+	}
+	for _, arg := range f.args {
+		if !holders[arg.local] {
+			continue
+		}
+		params, ok := summaries[arg.callee]
+		if !ok || arg.index >= len(params) || params[arg.index] {
+			return true
+		}
+	}
+	return false
+}
+
+// markStackAllocations sets StackAlloc on every construction in fn whose
+// value does not escape.
+//
+// Each construction gets one stack slot per call, so a construction inside a
+// loop reuses its slot on every iteration. That is only sound if no local can
+// still hold the previous iteration's value, which holds when every local the
+// value reaches is written exactly once: it is then overwritten along with
+// the slot.
+func markStackAllocations(fn *mir.Function, f *escapeFacts, summaries map[string][]bool) {
+	inLoop := blocksInLoops(fn)
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			var result mir.Local
+			var flag *bool
+			switch s := stmt.(type) {
+			case *mir.ConstructStruct:
+				if s.Type == nil {
+					continue
+				}
+				result, flag = s.Result, &s.StackAlloc
+			case *mir.ConstructTuple:
+				result, flag = s.Result, &s.StackAlloc
+			case *mir.ConstructEnum:
+				result, flag = s.Result, &s.StackAlloc
+			case *mir.MakeClosure:
+				result, flag = s.Result, &s.StackAlloc
+			default:
+				continue
+			}
+
+			holders := f.holders(result.ID)
+			if f.holdersEscape(holders, summaries) {
+				*flag = false
+				continue
+			}
+			if inLoop[block] && !f.singlyDefined(holders) {
+				*flag = false
+				continue
+			}
+			*flag = true
+		}
+	}
+}
+
+func (f *escapeFacts) singlyDefined(locals map[int]bool) bool {
+	for id := range locals {
+		if f.defs[id] != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// blocksInLoops returns the blocks that lie on a cycle of the CFG
+func blocksInLoops(fn *mir.Function) map[*mir.BasicBlock]bool {
+	inLoop := make(map[*mir.BasicBlock]bool)
+	for _, block := range fn.Blocks {
+		// A block is on a cycle if it can reach itself
+		seen := make(map[*mir.BasicBlock]bool)
+		worklist := getSuccessors(block)
+		for len(worklist) > 0 {
+			next := worklist[len(worklist)-1]
+			worklist = worklist[:len(worklist)-1]
+			if next == block {
+				inLoop[block] = true
+				break
+			}
+			if next == nil || seen[next] {
+				continue
+			}
+			seen[next] = true
+			worklist = append(worklist, getSuccessors(next)...)
+		}
+	}
+	return inLoop
+}
+
+// isNonRetainingBuiltin reports whether a call to name is known not to keep
+// references to its arguments: printing, formatting and the `__op__`
+// operator and runtime intrinsics
+func isNonRetainingBuiltin(name string) bool {
+	switch name {
+	case "println", "print", "format", "panic":
+		return true
+	}
+	return len(name) > 4 && strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__")
+}
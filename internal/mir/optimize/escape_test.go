@@ -0,0 +1,162 @@
+package optimize
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+var escapePointType = &types.Struct{
+	Name:   "Point",
+	Fields: []types.Field{{Name: "x", Type: types.TypeInt}},
+}
+
+func constructPoint(result mir.Local) *mir.ConstructStruct {
+	return &mir.ConstructStruct{
+		Result: result,
+		Type:   escapePointType,
+		Fields: map[string]mir.Operand{"x": &mir.Literal{Type: types.TypeInt, Value: int64(1)}},
+	}
+}
+
+func ref(l mir.Local) mir.Operand { return &mir.LocalRef{Local: l} }
+
+// TestEscapeLocalOnly tests that a value only read locally goes on the stack
+func TestEscapeLocalOnly(t *testing.T) {
+	p := mir.Local{ID: 1, Name: "p", Type: escapePointType}
+	x := mir.Local{ID: 2, Name: "x", Type: types.TypeInt}
+	cons := constructPoint(p)
+
+	entry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{
+		cons,
+		&mir.LoadField{Result: x, Target: ref(p), Field: "x"},
+		&mir.Call{Result: mir.Local{ID: 3, Type: types.TypeVoid}, Func: "println", Args: []mir.Operand{ref(x)}},
+	}, Terminator: &mir.Return{}}
+	fn := &mir.Function{Name: "main", Entry: entry, Blocks: []*mir.BasicBlock{entry}, ReturnType: types.TypeVoid}
+
+	AnalyzeEscapes(&mir.Module{Functions: []*mir.Function{fn}})
+
+	if !cons.StackAlloc {
+		t.Error("expected non-escaping struct to be stack allocated")
+	}
+}
+
+// TestEscapeReturnAndStore tests that returned or stored values stay on the heap
+func TestEscapeReturnAndStore(t *testing.T) {
+	p := mir.Local{ID: 1, Name: "p", Type: escapePointType}
+	alias := mir.Local{ID: 2, Name: "q", Type: escapePointType}
+	returned := constructPoint(p)
+
+	entry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{
+		returned,
+		&mir.Assign{Local: alias, RHS: ref(p)},
+	}, Terminator: &mir.Return{Value: ref(alias)}}
+	fn := &mir.Function{Name: "make", Entry: entry, Blocks: []*mir.BasicBlock{entry}, ReturnType: escapePointType}
+
+	inner := mir.Local{ID: 1, Name: "inner", Type: escapePointType}
+	outer := mir.Local{ID: 2, Name: "outer", Type: escapePointType}
+	stored := constructPoint(inner)
+	container := &mir.ConstructStruct{
+		Result: outer,
+		Type:   escapePointType,
+		Fields: map[string]mir.Operand{"x": ref(inner)},
+	}
+	entry2 := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{stored, container}, Terminator: &mir.Return{}}
+	fn2 := &mir.Function{Name: "nest", Entry: entry2, Blocks: []*mir.BasicBlock{entry2}, ReturnType: types.TypeVoid}
+
+	AnalyzeEscapes(&mir.Module{Functions: []*mir.Function{fn, fn2}})
+
+	if returned.StackAlloc {
+		t.Error("expected value returned through an alias to escape")
+	}
+	if stored.StackAlloc {
+		t.Error("expected value stored into another struct to escape")
+	}
+	if !container.StackAlloc {
+		t.Error("expected the containing struct itself to be stack allocated")
+	}
+}
+
+// TestEscapeThroughCallee tests that parameter summaries decide whether
+// passing a value to a module function makes it escape
+func TestEscapeThroughCallee(t *testing.T) {
+	param := mir.Local{ID: 1, Name: "p", Type: escapePointType}
+	x := mir.Local{ID: 2, Name: "x", Type: types.TypeInt}
+	readEntry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{
+		&mir.LoadField{Result: x, Target: ref(param), Field: "x"},
+	}, Terminator: &mir.Return{Value: ref(x)}}
+	reader := &mir.Function{Name: "read", Params: []mir.Local{param}, Entry: readEntry, Blocks: []*mir.BasicBlock{readEntry}, ReturnType: types.TypeInt}
+
+	keepEntry := &mir.BasicBlock{Label: "entry", Terminator: &mir.Return{Value: ref(param)}}
+	keeper := &mir.Function{Name: "keep", Params: []mir.Local{param}, Entry: keepEntry, Blocks: []*mir.BasicBlock{keepEntry}, ReturnType: escapePointType}
+
+	a := mir.Local{ID: 1, Name: "a", Type: escapePointType}
+	b := mir.Local{ID: 2, Name: "b", Type: escapePointType}
+	c := mir.Local{ID: 3, Name: "c", Type: escapePointType}
+	read := constructPoint(a)
+	kept := constructPoint(b)
+	unknown := constructPoint(c)
+	mainEntry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{
+		read,
+		&mir.Call{Result: mir.Local{ID: 4, Type: types.TypeInt}, Func: "read", Args: []mir.Operand{ref(a)}},
+		kept,
+		&mir.Call{Result: mir.Local{ID: 5, Type: escapePointType}, Func: "keep", Args: []mir.Operand{ref(b)}},
+		unknown,
+		&mir.Call{Result: mir.Local{ID: 6, Type: types.TypeVoid}, Func: "runtime_something", Args: []mir.Operand{ref(c)}},
+	}, Terminator: &mir.Return{}}
+	mainFn := &mir.Function{Name: "main", Entry: mainEntry, Blocks: []*mir.BasicBlock{mainEntry}, ReturnType: types.TypeVoid}
+
+	AnalyzeEscapes(&mir.Module{Functions: []*mir.Function{mainFn, reader, keeper}})
+
+	if !read.StackAlloc {
+		t.Error("expected value passed to a non-escaping parameter to be stack allocated")
+	}
+	if kept.StackAlloc {
+		t.Error("expected value passed to a parameter that is returned to escape")
+	}
+	if unknown.StackAlloc {
+		t.Error("expected value passed to an unknown function to escape")
+	}
+}
+
+// TestEscapeLoopReassignment tests that a construction in a loop stays on
+// the heap when a local outside the iteration can keep an earlier value
+func TestEscapeLoopReassignment(t *testing.T) {
+	keep := mir.Local{ID: 1, Name: "keep", Type: escapePointType}
+	p := mir.Local{ID: 2, Name: "p", Type: escapePointType}
+	q := mir.Local{ID: 3, Name: "q", Type: escapePointType}
+	cond := mir.Local{ID: 4, Name: "cond", Type: types.TypeBool}
+
+	entry := &mir.BasicBlock{Label: "entry"}
+	header := &mir.BasicBlock{Label: "header"}
+	body := &mir.BasicBlock{Label: "body"}
+	exit := &mir.BasicBlock{Label: "exit", Terminator: &mir.Return{}}
+
+	initial := constructPoint(keep)
+	saved := constructPoint(p)
+	scratch := constructPoint(q)
+	entry.Statements = []mir.Statement{initial}
+	entry.Terminator = &mir.Goto{Target: header}
+	header.Statements = []mir.Statement{&mir.Assign{Local: cond, RHS: &mir.Literal{Type: types.TypeBool, Value: true}}}
+	header.Terminator = &mir.Branch{Condition: ref(cond), True: body, False: exit}
+	body.Statements = []mir.Statement{
+		saved,
+		&mir.Assign{Local: keep, RHS: ref(p)},
+		scratch,
+	}
+	body.Terminator = &mir.Goto{Target: header}
+
+	fn := &mir.Function{Name: "main", Entry: entry, Blocks: []*mir.BasicBlock{entry, header, body, exit}, ReturnType: types.TypeVoid}
+	AnalyzeEscapes(&mir.Module{Functions: []*mir.Function{fn}})
+
+	if !initial.StackAlloc {
+		t.Error("expected construction outside the loop to be stack allocated")
+	}
+	if saved.StackAlloc {
+		t.Error("expected value saved across iterations to stay on the heap")
+	}
+	if !scratch.StackAlloc {
+		t.Error("expected per-iteration value to be stack allocated")
+	}
+}
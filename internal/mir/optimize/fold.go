@@ -0,0 +1,180 @@
+package optimize
+
+import (
+	"math"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// FoldConstants evaluates operator calls on `int` and `bool` constants at
+// compile time and turns branches on a constant condition into gotos.
+//
+// MIR locals can be reassigned, so values are only tracked forward within a
+// basic block, and locals whose address is taken are never tracked. Integer
+// arithmetic is only folded when it cannot overflow and division only when
+// the divisor is a safe non-zero value, so the result is the same under
+// every --overflow mode.
+//
+// The module is updated in place and returned.
+func FoldConstants(module *mir.Module) *mir.Module {
+	for _, fn := range module.Functions {
+		foldFunction(fn)
+	}
+	return module
+}
+
+func foldFunction(fn *mir.Function) {
+	taken := addressTaken(fn)
+	for _, block := range fn.Blocks {
+		known := make(map[int]*mir.Literal)
+		value := func(op mir.Operand) *mir.Literal {
+			switch o := op.(type) {
+			case *mir.Literal:
+				return o
+			case *mir.LocalRef:
+				return known[o.Local.ID]
+			}
+			return nil
+		}
+
+		for i, stmt := range block.Statements {
+			if call, ok := stmt.(*mir.Call); ok && call.FuncOperand == nil {
+				args := make([]*mir.Literal, len(call.Args))
+				for j, arg := range call.Args {
+					args[j] = value(arg)
+				}
+				if lit := foldOperator(call.Func, call.Result.Type, args); lit != nil {
+					stmt = &mir.Assign{Local: call.Result, RHS: lit}
+					block.Statements[i] = stmt
+				}
+			}
+
+			result := statementResult(stmt)
+			if result == nil {
+				continue
+			}
+			delete(known, result.ID)
+			if assign, ok := stmt.(*mir.Assign); ok && !taken[result.ID] {
+				if lit := value(assign.RHS); lit != nil && isFoldable(lit.Type) {
+					known[result.ID] = lit
+				}
+			}
+		}
+
+		if branch, ok := block.Terminator.(*mir.Branch); ok {
+			if lit := value(branch.Condition); lit != nil {
+				if cond, ok := lit.Value.(bool); ok {
+					target := branch.False
+					if cond {
+						target = branch.True
+					}
+					block.Terminator = &mir.Goto{Target: target}
+				}
+			}
+		}
+	}
+}
+
+// isFoldable reports whether constants of type t are tracked: the
+// platform-width `int` and `bool`
+func isFoldable(t types.Type) bool {
+	p, ok := t.(*types.Primitive)
+	return ok && (p.Kind == types.Int || p.Kind == types.Int64 || p.Kind == types.Bool)
+}
+
+// foldOperator evaluates an operator intrinsic on constant arguments. It
+// returns nil if any argument is unknown or the result cannot be computed
+// without changing runtime behavior.
+func foldOperator(name string, resultType types.Type, args []*mir.Literal) *mir.Literal {
+	if !isFoldable(resultType) {
+		return nil
+	}
+	for _, arg := range args {
+		if arg == nil || !isFoldable(arg.Type) {
+			return nil
+		}
+	}
+
+	lit := func(v interface{}) *mir.Literal {
+		return &mir.Literal{Type: resultType, Value: v}
+	}
+
+	if len(args) == 1 {
+		switch v := args[0].Value.(type) {
+		case int64:
+			if name == "__neg__" && v != math.MinInt64 {
+				return lit(-v)
+			}
+		case bool:
+			if name == "__not__" {
+				return lit(!v)
+			}
+		}
+		return nil
+	}
+	if len(args) != 2 {
+		return nil
+	}
+
+	if a, ok := args[0].Value.(bool); ok {
+		b, ok := args[1].Value.(bool)
+		if !ok {
+			return nil
+		}
+		switch name {
+		case "__and__":
+			return lit(a && b)
+		case "__or__":
+			return lit(a || b)
+		case "__eq__":
+			return lit(a == b)
+		case "__ne__":
+			return lit(a != b)
+		}
+		return nil
+	}
+
+	a, ok := args[0].Value.(int64)
+	if !ok {
+		return nil
+	}
+	b, ok := args[1].Value.(int64)
+	if !ok {
+		return nil
+	}
+	switch name {
+	case "__add__":
+		if sum := a + b; (sum > a) == (b > 0) {
+			return lit(sum)
+		}
+	case "__sub__":
+		if diff := a - b; (diff < a) == (b > 0) {
+			return lit(diff)
+		}
+	case "__mul__":
+		if a == 0 || b == 0 {
+			return lit(int64(0))
+		}
+		if prod := a * b; prod/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64) {
+			return lit(prod)
+		}
+	case "__div__":
+		if b != 0 && !(a == math.MinInt64 && b == -1) {
+			return lit(a / b)
+		}
+	case "__eq__":
+		return lit(a == b)
+	case "__ne__":
+		return lit(a != b)
+	case "__lt__":
+		return lit(a < b)
+	case "__le__":
+		return lit(a <= b)
+	case "__gt__":
+		return lit(a > b)
+	case "__ge__":
+		return lit(a >= b)
+	}
+	return nil
+}
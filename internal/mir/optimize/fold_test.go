@@ -0,0 +1,89 @@
+package optimize
+
+import (
+	"math"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+func intLit(v int64) *mir.Literal { return &mir.Literal{Type: types.TypeInt, Value: v} }
+
+// TestFoldConstantsArithmetic tests folding of operator calls on constants
+// tracked through assignments in the same block
+func TestFoldConstantsArithmetic(t *testing.T) {
+	x := mir.Local{ID: 1, Name: "x", Type: types.TypeInt}
+	sum := mir.Local{ID: 2, Name: "_2", Type: types.TypeInt}
+	less := mir.Local{ID: 3, Name: "_3", Type: types.TypeBool}
+
+	entry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{
+		&mir.Assign{Local: x, RHS: intLit(2)},
+		&mir.Call{Result: sum, Func: "__add__", Args: []mir.Operand{ref(x), intLit(3)}},
+		&mir.Call{Result: less, Func: "__lt__", Args: []mir.Operand{ref(sum), intLit(10)}},
+	}, Terminator: &mir.Return{Value: ref(sum)}}
+	fn := &mir.Function{Name: "f", Entry: entry, Blocks: []*mir.BasicBlock{entry}, ReturnType: types.TypeInt}
+
+	FoldConstants(&mir.Module{Functions: []*mir.Function{fn}})
+
+	want := []interface{}{int64(2), int64(5), true}
+	for i, stmt := range entry.Statements {
+		assign, ok := stmt.(*mir.Assign)
+		if !ok {
+			t.Fatalf("statement %d: expected folded assignment, got %T", i, stmt)
+		}
+		lit, ok := assign.RHS.(*mir.Literal)
+		if !ok || lit.Value != want[i] {
+			t.Errorf("statement %d: expected %v, got %v", i, want[i], assign.RHS)
+		}
+	}
+}
+
+// TestFoldConstantsKeepsRuntimeBehavior tests that overflowing arithmetic,
+// division by zero and values from other blocks are left alone
+func TestFoldConstantsKeepsRuntimeBehavior(t *testing.T) {
+	x := mir.Local{ID: 1, Name: "x", Type: types.TypeInt}
+	calls := []*mir.Call{
+		{Result: mir.Local{ID: 2, Type: types.TypeInt}, Func: "__add__", Args: []mir.Operand{intLit(math.MaxInt64), intLit(1)}},
+		{Result: mir.Local{ID: 3, Type: types.TypeInt}, Func: "__mul__", Args: []mir.Operand{intLit(math.MinInt64), intLit(-1)}},
+		{Result: mir.Local{ID: 4, Type: types.TypeInt}, Func: "__div__", Args: []mir.Operand{intLit(1), intLit(0)}},
+		{Result: mir.Local{ID: 5, Type: types.TypeInt}, Func: "__add__", Args: []mir.Operand{ref(x), intLit(1)}},
+	}
+
+	entry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{&mir.Assign{Local: x, RHS: intLit(1)}}}
+	next := &mir.BasicBlock{Label: "next", Terminator: &mir.Return{}}
+	entry.Terminator = &mir.Goto{Target: next}
+	for _, c := range calls {
+		next.Statements = append(next.Statements, c)
+	}
+	fn := &mir.Function{Name: "f", Entry: entry, Blocks: []*mir.BasicBlock{entry, next}, ReturnType: types.TypeVoid}
+
+	FoldConstants(&mir.Module{Functions: []*mir.Function{fn}})
+
+	for i, stmt := range next.Statements {
+		if _, ok := stmt.(*mir.Call); !ok {
+			t.Errorf("statement %d: expected call to be kept, got %T", i, stmt)
+		}
+	}
+}
+
+// TestFoldConstantBranch tests that a branch on a constant becomes a goto
+func TestFoldConstantBranch(t *testing.T) {
+	cond := mir.Local{ID: 1, Name: "cond", Type: types.TypeBool}
+	yes := &mir.BasicBlock{Label: "yes", Terminator: &mir.Return{}}
+	no := &mir.BasicBlock{Label: "no", Terminator: &mir.Return{}}
+	entry := &mir.BasicBlock{Label: "entry", Statements: []mir.Statement{
+		&mir.Call{Result: cond, Func: "__gt__", Args: []mir.Operand{intLit(1), intLit(2)}},
+	}, Terminator: &mir.Branch{Condition: ref(cond), True: yes, False: no}}
+	fn := &mir.Function{Name: "f", Entry: entry, Blocks: []*mir.BasicBlock{entry, yes, no}, ReturnType: types.TypeVoid}
+
+	module := Run(&mir.Module{Functions: []*mir.Function{fn}}, DefaultPasses)
+
+	g, ok := entry.Terminator.(*mir.Goto)
+	if !ok || g.Target != no {
+		t.Fatalf("expected goto no, got %#v", entry.Terminator)
+	}
+	if blocks := module.Functions[0].Blocks; len(blocks) != 2 || blocks[1] != no {
+		t.Errorf("expected the untaken block to be removed, got %d blocks", len(blocks))
+	}
+}
@@ -0,0 +1,177 @@
+package optimize
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+// statementUses returns every operand a statement reads. Unlike
+// getStatementOperands it covers all statement kinds, which passes that
+// delete or retarget definitions rely on.
+func statementUses(stmt mir.Statement) []mir.Operand {
+	switch s := stmt.(type) {
+	case *mir.Assign:
+		return []mir.Operand{s.RHS}
+	case *mir.Phi:
+		uses := make([]mir.Operand, 0, len(s.Inputs))
+		for _, input := range s.Inputs {
+			uses = append(uses, input)
+		}
+		return uses
+	case *mir.Call:
+		return append([]mir.Operand{s.FuncOperand}, s.Args...)
+	case *mir.Spawn:
+		return s.Args
+	case *mir.Join:
+		return []mir.Operand{s.Handle}
+	case *mir.Load:
+		return []mir.Operand{s.Address}
+	case *mir.StoreStatic:
+		return []mir.Operand{s.Value}
+	case *mir.LoadField:
+		return []mir.Operand{s.Target}
+	case *mir.StoreField:
+		return []mir.Operand{s.Target, s.Value}
+	case *mir.LoadIndex:
+		return append([]mir.Operand{s.Target}, s.Indices...)
+	case *mir.StoreIndex:
+		return append(append([]mir.Operand{s.Target}, s.Indices...), s.Value)
+	case *mir.ConstructStruct:
+		uses := make([]mir.Operand, 0, len(s.Fields))
+		for _, v := range s.Fields {
+			uses = append(uses, v)
+		}
+		return uses
+	case *mir.ConstructArray:
+		return s.Elements
+	case *mir.ConstructTuple:
+		return s.Elements
+	case *mir.ConstructEnum:
+		return s.Values
+	case *mir.Discriminant:
+		return []mir.Operand{s.Target}
+	case *mir.AccessVariantPayload:
+		return []mir.Operand{s.Target}
+	case *mir.MakeChannel:
+		return []mir.Operand{s.Capacity}
+	case *mir.Send:
+		return []mir.Operand{s.Channel, s.Value}
+	case *mir.Receive:
+		return []mir.Operand{s.Channel}
+	case *mir.AddressOf:
+		return []mir.Operand{&mir.LocalRef{Local: s.Target}}
+	case *mir.PtrOffset:
+		return []mir.Operand{s.Pointer, s.Offset}
+	case *mir.Cast:
+		return []mir.Operand{s.Operand}
+	case *mir.MakeClosure:
+		return []mir.Operand{s.Env}
+	case *mir.RegisterDrop:
+		return []mir.Operand{s.Value}
+	case *mir.CancelDrop:
+		return []mir.Operand{s.Value}
+	}
+	return nil
+}
+
+// terminatorUses returns every operand a terminator reads
+func terminatorUses(term mir.Terminator) []mir.Operand {
+	switch t := term.(type) {
+	case *mir.Return:
+		return []mir.Operand{t.Value}
+	case *mir.Branch:
+		return []mir.Operand{t.Condition}
+	case *mir.Select:
+		var uses []mir.Operand
+		for _, c := range t.Cases {
+			uses = append(uses, c.Channel, c.Value, c.Timeout)
+		}
+		return uses
+	}
+	return nil
+}
+
+// statementResult returns the local a statement writes, or nil
+func statementResult(stmt mir.Statement) *mir.Local {
+	switch s := stmt.(type) {
+	case *mir.Assign:
+		return &s.Local
+	case *mir.Phi:
+		return &s.Result
+	case *mir.Call:
+		return &s.Result
+	case *mir.Spawn:
+		return s.Result
+	case *mir.Join:
+		return &s.Result
+	case *mir.Load:
+		return &s.Result
+	case *mir.LoadStatic:
+		return &s.Result
+	case *mir.LoadField:
+		return &s.Result
+	case *mir.LoadIndex:
+		return &s.Result
+	case *mir.ConstructStruct:
+		return &s.Result
+	case *mir.ConstructArray:
+		return &s.Result
+	case *mir.ConstructTuple:
+		return &s.Result
+	case *mir.ConstructEnum:
+		return &s.Result
+	case *mir.Discriminant:
+		return &s.Result
+	case *mir.AccessVariantPayload:
+		return &s.Result
+	case *mir.MakeChannel:
+		return &s.Result
+	case *mir.Receive:
+		return &s.Result
+	case *mir.SizeOf:
+		return &s.Result
+	case *mir.AlignOf:
+		return &s.Result
+	case *mir.AddressOf:
+		return &s.Result
+	case *mir.PtrOffset:
+		return &s.Result
+	case *mir.Cast:
+		return &s.Result
+	case *mir.MakeClosure:
+		return &s.Result
+	}
+	return nil
+}
+
+// localUseCounts counts how often each local is read in fn
+func localUseCounts(fn *mir.Function) map[int]int {
+	counts := make(map[int]int)
+	count := func(ops []mir.Operand) {
+		for _, op := range ops {
+			if ref, ok := op.(*mir.LocalRef); ok {
+				counts[ref.Local.ID]++
+			}
+		}
+	}
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			count(statementUses(stmt))
+		}
+		count(terminatorUses(block.Terminator))
+	}
+	return counts
+}
+
+// addressTaken returns the locals whose address is taken in fn. Their value
+// can change through a pointer, so passes must not reason about them.
+func addressTaken(fn *mir.Function) map[int]bool {
+	taken := make(map[int]bool)
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if addr, ok := stmt.(*mir.AddressOf); ok {
+				taken[addr.Target.ID] = true
+			}
+		}
+	}
+	return taken
+}
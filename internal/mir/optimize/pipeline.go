@@ -0,0 +1,63 @@
+package optimize
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+)
+
+// Pass is a named MIR-to-MIR transformation. Passes may update the module in
+// place; Run always continues with the module a pass returns.
+type Pass struct {
+	Name string
+	Run  func(*mir.Module) *mir.Module
+}
+
+// DefaultPasses is the pipeline selected by "all". Folding runs first so
+// that branches on folded conditions leave dead blocks behind for dce, and
+// copy removal cleans up the temporaries folding produces. Bounds check
+// elimination runs after it, when loop counters are updated in one step.
+var DefaultPasses = []Pass{
+	{Name: "fold", Run: FoldConstants},
+	{Name: "dce", Run: EliminateDeadBlocks},
+	{Name: "copies", Run: RemoveRedundantCopies},
+	{Name: "bounds", Run: EliminateBoundsChecks},
+}
+
+// ParsePasses parses a pass selection: "all" (or "1") for DefaultPasses,
+// "none", "0" or "" for no passes, or a comma-separated list of pass names
+// run in the given order.
+func ParsePasses(spec string) ([]Pass, error) {
+	switch strings.TrimSpace(spec) {
+	case "", "0", "none":
+		return nil, nil
+	case "1", "all":
+		return DefaultPasses, nil
+	}
+
+	var passes []Pass
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, p := range DefaultPasses {
+			if p.Name == name {
+				passes = append(passes, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown MIR optimization pass %q (available: fold, dce, copies, bounds)", name)
+		}
+	}
+	return passes, nil
+}
+
+// Run applies passes to module in order and returns the result
+func Run(module *mir.Module, passes []Pass) *mir.Module {
+	for _, p := range passes {
+		module = p.Run(module)
+	}
+	return module
+}
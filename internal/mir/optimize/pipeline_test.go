@@ -0,0 +1,35 @@
+package optimize
+
+import "testing"
+
+func TestParsePasses(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"none", nil, false},
+		{"all", []string{"fold", "dce", "copies", "bounds"}, false},
+		{"1", []string{"fold", "dce", "copies", "bounds"}, false},
+		{"copies, fold", []string{"copies", "fold"}, false},
+		{"inline", nil, true},
+	}
+
+	for _, tt := range tests {
+		passes, err := ParsePasses(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePasses(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if len(passes) != len(tt.want) {
+			t.Errorf("ParsePasses(%q) returned %d passes, want %d", tt.spec, len(passes), len(tt.want))
+			continue
+		}
+		for i, p := range passes {
+			if p.Name != tt.want[i] {
+				t.Errorf("ParsePasses(%q)[%d] = %s, want %s", tt.spec, i, p.Name, tt.want[i])
+			}
+		}
+	}
+}
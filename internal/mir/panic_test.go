@@ -0,0 +1,84 @@
+package mir
+
+import "testing"
+
+func TestLowerPanicEndsBlock(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn half(n: int) -> int {
+    if n > 0 {
+        return n / 2;
+    }
+    panic("odd");
+}
+fn main() {
+    println(half(4));
+}
+`)
+	fn := findFunction(module, "half")
+	if fn == nil {
+		t.Fatal("no half in module")
+	}
+	for _, block := range fn.Blocks {
+		n := len(block.Statements)
+		if n == 0 {
+			continue
+		}
+		call, ok := block.Statements[n-1].(*Call)
+		if !ok || call.Func != "panic" {
+			continue
+		}
+		if _, ok := block.Terminator.(*Unreachable); !ok {
+			t.Errorf("block calling panic ends with %T, want *Unreachable", block.Terminator)
+		}
+		return
+	}
+	t.Fatal("half does not call panic")
+}
+
+func TestLowerReturnsOnEveryPath(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn sign(n: int) -> int {
+    if n < 0 {
+        return -1;
+    } else {
+        return 1;
+    }
+}
+fn main() {
+    println(sign(4));
+}
+`)
+	fn := findFunction(module, "sign")
+	if fn == nil {
+		t.Fatal("no sign in module")
+	}
+	for _, block := range fn.Blocks {
+		if block.Terminator == nil {
+			t.Errorf("block %s has no terminator", block.Label)
+		}
+	}
+}
+
+func TestLowerPanicInElse(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn pick(x: int) -> int {
+    if x > 0 {
+        return x;
+    } else {
+        panic("negative");
+    }
+}
+fn main() {
+    println(pick(3));
+}
+`)
+	fn := findFunction(module, "pick")
+	if fn == nil {
+		t.Fatal("no pick in module")
+	}
+	for _, block := range fn.Blocks {
+		if _, ok := block.Terminator.(*Goto); ok && !hasPredecessor(fn, block) {
+			t.Errorf("block %s after the panic jumps past the if", block.Label)
+		}
+	}
+}
@@ -0,0 +1,1313 @@
+package mir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// Parse reads a module in the textual form printed by Module.PrettyPrint,
+// so that printing the result gives back the same text. Types are kept as
+// written: primitive types are resolved, and any other type becomes a
+// types.Named whose name is its text. The module has no struct or enum
+// definitions, so it is meant for tools and tests that inspect or compare
+// MIR, not for code generation.
+func Parse(src string) (*Module, error) {
+	p := &mirParser{lines: strings.Split(src, "\n"), module: &Module{}}
+	if err := p.parseModule(); err != nil {
+		return nil, err
+	}
+	return p.module, nil
+}
+
+// mirParser reads a module line by line
+type mirParser struct {
+	lines  []string
+	line   int // index of the current line
+	module *Module
+}
+
+func (p *mirParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line+1, fmt.Sprintf(format, args...))
+}
+
+func (p *mirParser) parseModule() error {
+	var attrs []string
+	for ; p.line < len(p.lines); p.line++ {
+		text := strings.TrimSpace(p.lines[p.line])
+		switch {
+		case text == "" || strings.HasPrefix(text, "//"):
+			continue
+		case strings.HasPrefix(text, "#["):
+			attrs = append(attrs, text)
+		case strings.HasPrefix(text, "extern fn "):
+			ext, err := p.parseExtern(text)
+			if err != nil {
+				return err
+			}
+			p.module.Externs = append(p.module.Externs, ext)
+		case strings.HasPrefix(text, "static "):
+			static, err := p.parseStatic(text)
+			if err != nil {
+				return err
+			}
+			p.module.Statics = append(p.module.Statics, static)
+		case strings.HasPrefix(text, "fn "):
+			fn, err := p.parseFunction(attrs)
+			if err != nil {
+				return err
+			}
+			attrs = nil
+			p.module.Functions = append(p.module.Functions, fn)
+		default:
+			return p.errorf("unexpected %q", text)
+		}
+	}
+	if len(attrs) > 0 {
+		return p.errorf("attribute %s is not followed by a function", attrs[0])
+	}
+	return nil
+}
+
+func (p *mirParser) parseExtern(text string) (*Extern, error) {
+	s := &lineScanner{text: text, p: p}
+	s.accept("extern fn ")
+	ext := &Extern{}
+	var err error
+	if ext.Name, err = s.symbol(); err != nil {
+		return nil, err
+	}
+	if err := s.expect("("); err != nil {
+		return nil, err
+	}
+	for !s.accept(")") {
+		if len(ext.Params) > 0 {
+			if err := s.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		typ, err := s.typ()
+		if err != nil {
+			return nil, err
+		}
+		ext.Params = append(ext.Params, typ)
+	}
+	if err := s.expect("->"); err != nil {
+		return nil, err
+	}
+	if ext.ReturnType, err = s.typ(); err != nil {
+		return nil, err
+	}
+	return ext, s.end()
+}
+
+func (p *mirParser) parseStatic(text string) (*Static, error) {
+	s := &lineScanner{text: text, p: p}
+	s.accept("static ")
+	static := &Static{Mutable: s.acceptWord("mut")}
+	var err error
+	if static.Name, err = s.symbol(); err != nil {
+		return nil, err
+	}
+	if err := s.expect(":"); err != nil {
+		return nil, err
+	}
+	if static.Type, err = s.typ(); err != nil {
+		return nil, err
+	}
+	if err := s.expect("="); err != nil {
+		return nil, err
+	}
+	lit, err := s.literal()
+	if err != nil {
+		return nil, err
+	}
+	static.Value = lit.Value
+	return static, s.end()
+}
+
+// funcParser holds the state of the function being read: its locals by ID,
+// and its blocks by label, which may be used before they are defined
+type funcParser struct {
+	*mirParser
+	fn      *Function
+	locals  map[int]Local
+	blocks  map[string]*BasicBlock
+	defined map[string]bool
+}
+
+func (p *mirParser) parseFunction(attrs []string) (*Function, error) {
+	f := &funcParser{
+		mirParser: p,
+		fn:        &Function{},
+		locals:    make(map[int]Local),
+		blocks:    make(map[string]*BasicBlock),
+		defined:   make(map[string]bool),
+	}
+	for _, attr := range attrs {
+		if err := f.parseAttribute(attr); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.parseHeader(); err != nil {
+		return nil, err
+	}
+
+	var current *BasicBlock
+	entry := ""
+	for p.line++; p.line < len(p.lines); p.line++ {
+		text := strings.TrimSpace(p.lines[p.line])
+		switch {
+		case text == "" || strings.HasPrefix(text, "//"):
+			continue
+		case text == "}":
+			return f.finish(entry)
+		case strings.HasPrefix(text, "let "):
+			local, err := f.parseLocalDecl(text)
+			if err != nil {
+				return nil, err
+			}
+			f.fn.Locals = append(f.fn.Locals, local)
+		case strings.HasPrefix(text, "entry "):
+			s := &lineScanner{text: text[len("entry "):], p: p}
+			label, err := s.symbol()
+			if err != nil {
+				return nil, err
+			}
+			entry = label
+		case strings.HasSuffix(text, ":") && isLabel(strings.TrimSuffix(text, ":")):
+			label, err := (&lineScanner{text: strings.TrimSuffix(text, ":"), p: p}).symbol()
+			if err != nil {
+				return nil, err
+			}
+			if f.defined[label] {
+				return nil, p.errorf("block %s is defined twice", label)
+			}
+			f.defined[label] = true
+			current = f.block(label)
+			f.fn.Blocks = append(f.fn.Blocks, current)
+		default:
+			if current == nil {
+				return nil, p.errorf("%q is outside a block", text)
+			}
+			if current.Terminator != nil {
+				return nil, p.errorf("%q follows the terminator of block %s", text, current.Label)
+			}
+			if err := f.parseInstruction(current, text); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, p.errorf("function %s is not closed with }", f.fn.Name)
+}
+
+// isLabel reports whether text, a line ending in a colon, is a block label
+func isLabel(text string) bool {
+	if strings.HasPrefix(text, `"`) {
+		_, err := strconv.Unquote(text)
+		return err == nil
+	}
+	return text != "" && symbolLen(text) == len(text)
+}
+
+func (f *funcParser) parseAttribute(attr string) error {
+	switch attr {
+	case "#[inline]":
+		f.fn.Inline = InlineHint
+		return nil
+	case "#[inline(always)]":
+		f.fn.Inline = InlineAlways
+		return nil
+	case "#[inline(never)]":
+		f.fn.Inline = InlineNever
+		return nil
+	}
+	if !strings.HasPrefix(attr, "#[instance(") || !strings.HasSuffix(attr, ")]") {
+		return f.errorf("unknown attribute %s", attr)
+	}
+	s := &lineScanner{text: strings.TrimSuffix(strings.TrimPrefix(attr, "#[instance("), ")]"), p: f.mirParser}
+	instance := &Instance{}
+	var err error
+	if instance.Generic, err = s.symbol(); err != nil {
+		return err
+	}
+	if instance.TypeArgs, err = s.typeArgs(); err != nil {
+		return err
+	}
+	f.fn.Instance = instance
+	return s.end()
+}
+
+func (f *funcParser) parseHeader() error {
+	text, span := splitSpan(strings.TrimSpace(f.lines[f.line]))
+	f.fn.Span = span
+	s := &lineScanner{text: text, p: f.mirParser}
+	s.accept("fn ")
+	var err error
+	if f.fn.Name, err = s.symbol(); err != nil {
+		return err
+	}
+
+	if s.peek('[') {
+		s.pos++
+		for !s.accept("]") {
+			if len(f.fn.TypeParams) > 0 {
+				if err := s.expect(","); err != nil {
+					return err
+				}
+			}
+			name, err := s.name()
+			if err != nil {
+				return err
+			}
+			param := types.TypeParam{Name: name}
+			if s.accept(":") {
+				bounds, err := s.typeText()
+				if err != nil {
+					return err
+				}
+				for _, bound := range strings.Split(bounds, " + ") {
+					param.Bounds = append(param.Bounds, parseType(bound))
+				}
+			}
+			f.fn.TypeParams = append(f.fn.TypeParams, param)
+		}
+	}
+
+	if err := s.expect("("); err != nil {
+		return err
+	}
+	for !s.accept(")") {
+		if len(f.fn.Params) > 0 {
+			if err := s.expect(","); err != nil {
+				return err
+			}
+		}
+		param, err := f.parseTypedLocal(s)
+		if err != nil {
+			return err
+		}
+		f.fn.Params = append(f.fn.Params, param)
+	}
+	if err := s.expect("->"); err != nil {
+		return err
+	}
+	if f.fn.ReturnType, err = s.typ(); err != nil {
+		return err
+	}
+	if err := s.expect("{"); err != nil {
+		return err
+	}
+	return s.end()
+}
+
+func (f *funcParser) parseLocalDecl(text string) (Local, error) {
+	s := &lineScanner{text: text[len("let "):], p: f.mirParser}
+	local, err := f.parseTypedLocal(s)
+	if err != nil {
+		return Local{}, err
+	}
+	return local, s.end()
+}
+
+// parseTypedLocal reads `name_ID: type` and records the local
+func (f *funcParser) parseTypedLocal(s *lineScanner) (Local, error) {
+	word, err := s.name()
+	if err != nil {
+		return Local{}, err
+	}
+	local, err := f.parseLocalName(word)
+	if err != nil {
+		return Local{}, err
+	}
+	if err := s.expect(":"); err != nil {
+		return Local{}, err
+	}
+	if local.Type, err = s.typ(); err != nil {
+		return Local{}, err
+	}
+	f.locals[local.ID] = local
+	return local, nil
+}
+
+// parseLocalName splits name_ID into a local's name and ID
+func (f *funcParser) parseLocalName(word string) (Local, error) {
+	i := strings.LastIndex(word, "_")
+	if i < 0 {
+		return Local{}, f.errorf("%q is not a local (expected name_ID)", word)
+	}
+	id, err := strconv.Atoi(word[i+1:])
+	if err != nil {
+		return Local{}, f.errorf("%q is not a local (expected name_ID)", word)
+	}
+	return Local{ID: id, Name: word[:i]}, nil
+}
+
+// local returns the local written as word, with the type it was declared
+// with, if any
+func (f *funcParser) local(word string) (Local, error) {
+	local, err := f.parseLocalName(word)
+	if err != nil {
+		return Local{}, err
+	}
+	if declared, ok := f.locals[local.ID]; ok && declared.Name == local.Name {
+		return declared, nil
+	}
+	return local, nil
+}
+
+// block returns the block labeled label, creating it on first use
+func (f *funcParser) block(label string) *BasicBlock {
+	block, ok := f.blocks[label]
+	if !ok {
+		block = &BasicBlock{Label: label}
+		f.blocks[label] = block
+	}
+	return block
+}
+
+func (f *funcParser) finish(entry string) (*Function, error) {
+	for label := range f.blocks {
+		if !f.defined[label] {
+			return nil, f.errorf("function %s jumps to undefined block %s", f.fn.Name, label)
+		}
+	}
+	switch {
+	case entry != "":
+		f.fn.Entry = f.blocks[entry]
+		if f.fn.Entry == nil {
+			return nil, f.errorf("entry block %s of %s is not defined", entry, f.fn.Name)
+		}
+	case len(f.fn.Blocks) > 0:
+		f.fn.Entry = f.fn.Blocks[0]
+	}
+	return f.fn, nil
+}
+
+// parseInstruction reads a statement or terminator of block
+func (f *funcParser) parseInstruction(block *BasicBlock, text string) error {
+	text, span := splitSpan(text)
+	s := &lineScanner{text: text, p: f.mirParser}
+	switch s.peekWord() {
+	case "return":
+		s.word()
+		ret := &Return{Span: span}
+		if !s.done() && !s.peek('(') || s.peek('(') && !strings.HasPrefix(s.rest(), "(tailcall)") {
+			value, err := f.operand(s)
+			if err != nil {
+				return err
+			}
+			ret.Value = value
+		}
+		ret.TailCall = s.accept("(tailcall)")
+		block.Terminator = ret
+	case "goto":
+		s.word()
+		label, err := s.symbol()
+		if err != nil {
+			return err
+		}
+		block.Terminator = &Goto{Target: f.block(label)}
+	case "if":
+		s.word()
+		cond, err := f.operand(s)
+		if err != nil {
+			return err
+		}
+		labels := make([]string, 2)
+		for i, keyword := range []string{"goto", "goto"} {
+			if i == 1 && !s.acceptWord("else") {
+				return s.errorf("expected else")
+			}
+			if !s.acceptWord(keyword) {
+				return s.errorf("expected goto")
+			}
+			if labels[i], err = s.symbol(); err != nil {
+				return err
+			}
+		}
+		block.Terminator = &Branch{Condition: cond, True: f.block(labels[0]), False: f.block(labels[1])}
+	case "unreachable":
+		s.word()
+		block.Terminator = &Unreachable{}
+	case "select":
+		s.word()
+		if err := s.expect("{"); err != nil {
+			return err
+		}
+		if err := s.end(); err != nil {
+			return err
+		}
+		sel, err := f.parseSelect()
+		if err != nil {
+			return err
+		}
+		block.Terminator = sel
+		return nil
+	default:
+		stmt, err := f.parseStatement(s)
+		if err != nil {
+			return err
+		}
+		block.Statements = append(block.Statements, stmt)
+	}
+	return s.end()
+}
+
+// parseSelect reads the cases of a select, up to its closing brace
+func (f *funcParser) parseSelect() (*Select, error) {
+	sel := &Select{}
+	for f.line++; f.line < len(f.lines); f.line++ {
+		text := strings.TrimSpace(f.lines[f.line])
+		if text == "}" {
+			return sel, nil
+		}
+		s := &lineScanner{text: text, p: f.mirParser}
+		var c SelectCase
+		var err error
+		switch {
+		case s.acceptWord("default"):
+			c.Kind = "default"
+		case s.accept("after("):
+			c.Kind = "after"
+			if c.Timeout, err = f.operand(s); err != nil {
+				return nil, err
+			}
+			if err := s.expect(")"); err != nil {
+				return nil, err
+			}
+		case s.acceptWord("case"):
+			if s.accept("<-") {
+				c.Kind = "recv"
+				if c.Channel, err = f.operand(s); err != nil {
+					return nil, err
+				}
+				break
+			}
+			first, err := f.operand(s)
+			if err != nil {
+				return nil, err
+			}
+			if s.accept("<-") {
+				c.Kind = "send"
+				c.Channel = first
+				if c.Value, err = f.operand(s); err != nil {
+					return nil, err
+				}
+				break
+			}
+			ref, ok := first.(*LocalRef)
+			if !ok || !s.accept("=") || !s.accept("<-") {
+				return nil, s.errorf("expected a send or receive case")
+			}
+			c.Kind = "recv"
+			c.Result = &ref.Local
+			if c.Channel, err = f.operand(s); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, s.errorf("expected a select case")
+		}
+		if err := s.expect("=>"); err != nil {
+			return nil, err
+		}
+		if !s.acceptWord("goto") {
+			return nil, s.errorf("expected goto")
+		}
+		label, err := s.symbol()
+		if err != nil {
+			return nil, err
+		}
+		c.Target = f.block(label)
+		if err := s.end(); err != nil {
+			return nil, err
+		}
+		sel.Cases = append(sel.Cases, c)
+	}
+	return nil, f.errorf("select is not closed with }")
+}
+
+// parseStatement reads a statement, which either starts with a keyword or
+// assigns a local
+func (f *funcParser) parseStatement(s *lineScanner) (Statement, error) {
+	switch s.peekWord() {
+	case "yield":
+		s.word()
+		return &Yield{}, nil
+	case "store_field":
+		s.word()
+		target, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		field, err := f.fieldName(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &StoreField{Target: target, Field: field, Value: value}, nil
+	case "store_index":
+		s.word()
+		target, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		indices, err := f.operandList(s, "[", "]")
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &StoreIndex{Target: target, Indices: indices, Value: value, InBounds: s.accept("(in bounds)")}, nil
+	case "static":
+		s.word()
+		name, err := s.symbol()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &StoreStatic{Name: name, Value: value}, nil
+	case "send":
+		s.word()
+		ch, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect("<-"); err != nil {
+			return nil, err
+		}
+		value, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Send{Channel: ch, Value: value}, nil
+	case "register_drop":
+		s.word()
+		value, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect(","); err != nil {
+			return nil, err
+		}
+		fn, err := s.symbol()
+		if err != nil {
+			return nil, err
+		}
+		typeArgs, err := s.typeArgs()
+		if err != nil {
+			return nil, err
+		}
+		return &RegisterDrop{Value: value, Func: fn, TypeArgs: typeArgs}, nil
+	case "cancel_drop":
+		s.word()
+		value, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &CancelDrop{Value: value}, nil
+	case "spawn":
+		return f.parseSpawn(s, nil)
+	}
+
+	word, err := s.name()
+	if err != nil {
+		return nil, err
+	}
+	result, err := f.local(word)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.expect("="); err != nil {
+		return nil, err
+	}
+	return f.parseRvalue(s, result)
+}
+
+// parseRvalue reads the right-hand side of `result = ...`
+func (f *funcParser) parseRvalue(s *lineScanner, result Local) (Statement, error) {
+	if s.accept("&") {
+		word, err := s.name()
+		if err != nil {
+			return nil, err
+		}
+		target, err := f.local(word)
+		if err != nil {
+			return nil, err
+		}
+		return &AddressOf{Result: result, Target: target}, nil
+	}
+
+	switch s.peekWord() {
+	case "call":
+		s.word()
+		call := &Call{Result: result}
+		var err error
+		if s.accept("(") {
+			if call.FuncOperand, err = f.operand(s); err != nil {
+				return nil, err
+			}
+			if err := s.expect(")"); err != nil {
+				return nil, err
+			}
+		} else if call.Func, err = s.symbol(); err != nil {
+			return nil, err
+		}
+		if call.TypeArgs, err = s.typeArgs(); err != nil {
+			return nil, err
+		}
+		if call.Args, err = f.operandList(s, "(", ")"); err != nil {
+			return nil, err
+		}
+		return call, nil
+	case "spawn":
+		return f.parseSpawn(s, &result)
+	case "join":
+		s.word()
+		handle, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Join{Result: result, Handle: handle}, nil
+	case "load":
+		s.word()
+		address, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Load{Result: result, Address: address}, nil
+	case "load_field":
+		s.word()
+		target, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		field, err := f.fieldName(s)
+		if err != nil {
+			return nil, err
+		}
+		return &LoadField{Result: result, Target: target, Field: field}, nil
+	case "load_index":
+		s.word()
+		target, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		indices, err := f.operandList(s, "[", "]")
+		if err != nil {
+			return nil, err
+		}
+		return &LoadIndex{Result: result, Target: target, Indices: indices, InBounds: s.accept("(in bounds)")}, nil
+	case "construct_struct", "construct_record":
+		cons := &ConstructStruct{Result: result, Fields: make(map[string]Operand)}
+		if s.word() == "construct_struct" {
+			typ, err := s.typ()
+			if err != nil {
+				return nil, err
+			}
+			cons.Type = typ
+		}
+		if err := s.expect("{"); err != nil {
+			return nil, err
+		}
+		for !s.accept("}") {
+			if len(cons.Fields) > 0 {
+				if err := s.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			name, err := s.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := s.expect(":"); err != nil {
+				return nil, err
+			}
+			if cons.Fields[name], err = f.operand(s); err != nil {
+				return nil, err
+			}
+		}
+		cons.StackAlloc = s.accept("(stack)")
+		return cons, nil
+	case "construct_array":
+		s.word()
+		elements, err := f.operandList(s, "[", "]")
+		if err != nil {
+			return nil, err
+		}
+		cons := &ConstructArray{Result: result, Elements: elements}
+		if s.acceptWord("as") {
+			if cons.Type, err = s.typ(); err != nil {
+				return nil, err
+			}
+		}
+		return cons, nil
+	case "construct_tuple":
+		s.word()
+		elements, err := f.operandList(s, "(", ")")
+		if err != nil {
+			return nil, err
+		}
+		return &ConstructTuple{Result: result, Elements: elements, StackAlloc: s.accept("(stack)")}, nil
+	case "construct_enum":
+		s.word()
+		name, err := s.symbol()
+		if err != nil {
+			return nil, err
+		}
+		i := strings.LastIndex(name, "::")
+		if i < 0 {
+			return nil, s.errorf("expected Enum::Variant, found %q", name)
+		}
+		if err := s.expect("#"); err != nil {
+			return nil, err
+		}
+		index, err := s.int()
+		if err != nil {
+			return nil, err
+		}
+		values, err := f.operandList(s, "(", ")")
+		if err != nil {
+			return nil, err
+		}
+		return &ConstructEnum{
+			Result:       result,
+			Type:         name[:i],
+			Variant:      name[i+2:],
+			VariantIndex: index,
+			Values:       values,
+			StackAlloc:   s.accept("(stack)"),
+		}, nil
+	case "discriminant":
+		s.word()
+		target, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Discriminant{Result: result, Target: target}, nil
+	case "variant_payload":
+		s.word()
+		target, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		access := &AccessVariantPayload{Result: result, Target: target}
+		if err := s.expect("("); err != nil {
+			return nil, err
+		}
+		if !s.acceptWord("variant") {
+			return nil, s.errorf("expected variant")
+		}
+		if access.VariantIndex, err = s.int(); err != nil {
+			return nil, err
+		}
+		if err := s.expect(","); err != nil {
+			return nil, err
+		}
+		if !s.acceptWord("member") {
+			return nil, s.errorf("expected member")
+		}
+		if access.MemberIndex, err = s.int(); err != nil {
+			return nil, err
+		}
+		return access, s.expect(")")
+	case "phi":
+		s.word()
+		phi := &Phi{Result: result, Inputs: make(map[*BasicBlock]Operand)}
+		if err := s.expect("["); err != nil {
+			return nil, err
+		}
+		for !s.accept("]") {
+			if len(phi.Inputs) > 0 {
+				if err := s.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			label, err := s.symbol()
+			if err != nil {
+				return nil, err
+			}
+			if err := s.expect(":"); err != nil {
+				return nil, err
+			}
+			if phi.Inputs[f.block(label)], err = f.operand(s); err != nil {
+				return nil, err
+			}
+		}
+		return phi, nil
+	case "static":
+		s.word()
+		name, err := s.symbol()
+		if err != nil {
+			return nil, err
+		}
+		return &LoadStatic{Result: result, Name: name}, nil
+	case "make_channel":
+		s.word()
+		if err := s.expect("(cap="); err != nil {
+			return nil, err
+		}
+		capacity, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect(")"); err != nil {
+			return nil, err
+		}
+		mc := &MakeChannel{Result: result, Capacity: capacity}
+		if s.acceptWord("as") {
+			if mc.Type, err = s.typ(); err != nil {
+				return nil, err
+			}
+		}
+		return mc, nil
+	case "recv":
+		s.word()
+		optional := s.accept("?")
+		ch, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &Receive{Result: result, Channel: ch, Optional: optional}, nil
+	case "sizeof", "alignof":
+		keyword := s.word()
+		if err := s.expect("("); err != nil {
+			return nil, err
+		}
+		typ, err := s.typ()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect(")"); err != nil {
+			return nil, err
+		}
+		if keyword == "sizeof" {
+			return &SizeOf{Result: result, Type: typ}, nil
+		}
+		return &AlignOf{Result: result, Type: typ}, nil
+	case "offset":
+		s.word()
+		pointer, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		if !s.acceptWord("by") {
+			return nil, s.errorf("expected by")
+		}
+		offset, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		return &PtrOffset{Result: result, Pointer: pointer, Offset: offset}, nil
+	case "cast":
+		s.word()
+		operand, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		if !s.acceptWord("to") {
+			return nil, s.errorf("expected to")
+		}
+		typ, err := s.typ()
+		if err != nil {
+			return nil, err
+		}
+		return &Cast{Result: result, Operand: operand, Type: typ}, nil
+	case "make_closure":
+		s.word()
+		fn, err := s.symbol()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect("(env="); err != nil {
+			return nil, err
+		}
+		env, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect(")"); err != nil {
+			return nil, err
+		}
+		return &MakeClosure{Result: result, Func: fn, Env: env, StackAlloc: s.accept("(stack)")}, nil
+	}
+
+	rhs, err := f.operand(s)
+	if err != nil {
+		return nil, err
+	}
+	return &Assign{Local: result, RHS: rhs}, nil
+}
+
+func (f *funcParser) parseSpawn(s *lineScanner, result *Local) (Statement, error) {
+	s.word()
+	spawn := &Spawn{Result: result}
+	var err error
+	if spawn.Func, err = s.symbol(); err != nil {
+		return nil, err
+	}
+	if spawn.TypeArgs, err = s.typeArgs(); err != nil {
+		return nil, err
+	}
+	if spawn.Args, err = f.operandList(s, "(", ")"); err != nil {
+		return nil, err
+	}
+	return spawn, nil
+}
+
+// fieldName reads the `.field` after the target of a field access
+func (f *funcParser) fieldName(s *lineScanner) (string, error) {
+	if err := s.expect("."); err != nil {
+		return "", err
+	}
+	return s.name()
+}
+
+// operandList reads operands separated by commas between open and close
+func (f *funcParser) operandList(s *lineScanner, open, close string) ([]Operand, error) {
+	if err := s.expect(open); err != nil {
+		return nil, err
+	}
+	var ops []Operand
+	for !s.accept(close) {
+		if len(ops) > 0 {
+			if err := s.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		op, err := f.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// operand reads a local or a literal
+func (f *funcParser) operand(s *lineScanner) (Operand, error) {
+	s.skipSpaces()
+	if s.peek('(') || s.peek('"') || s.peek('-') || s.peek('+') || s.peekDigit() {
+		return s.literal()
+	}
+	switch s.peekWord() {
+	case "true", "false", "nil", "NaN":
+		return s.literal()
+	}
+	word, err := s.name()
+	if err != nil {
+		return nil, err
+	}
+	local, err := f.local(word)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalRef{Local: local}, nil
+}
+
+// lineScanner reads the tokens of one line
+type lineScanner struct {
+	text string
+	pos  int
+	p    *mirParser
+}
+
+func (s *lineScanner) errorf(format string, args ...any) error {
+	return s.p.errorf("%s, at %q", fmt.Sprintf(format, args...), s.rest())
+}
+
+func (s *lineScanner) skipSpaces() {
+	for s.pos < len(s.text) && s.text[s.pos] == ' ' {
+		s.pos++
+	}
+}
+
+func (s *lineScanner) rest() string {
+	return s.text[s.pos:]
+}
+
+func (s *lineScanner) done() bool {
+	s.skipSpaces()
+	return s.pos >= len(s.text)
+}
+
+func (s *lineScanner) end() error {
+	if !s.done() {
+		return s.errorf("unexpected text")
+	}
+	return nil
+}
+
+func (s *lineScanner) peek(c byte) bool {
+	s.skipSpaces()
+	return s.pos < len(s.text) && s.text[s.pos] == c
+}
+
+func (s *lineScanner) peekDigit() bool {
+	s.skipSpaces()
+	return s.pos < len(s.text) && s.text[s.pos] >= '0' && s.text[s.pos] <= '9'
+}
+
+// accept consumes tok if the rest of the line starts with it
+func (s *lineScanner) accept(tok string) bool {
+	s.skipSpaces()
+	if strings.HasPrefix(s.rest(), tok) {
+		s.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (s *lineScanner) expect(tok string) error {
+	if !s.accept(tok) {
+		return s.errorf("expected %q", tok)
+	}
+	return nil
+}
+
+// peekWord returns the identifier at the scanner without consuming it
+func (s *lineScanner) peekWord() string {
+	s.skipSpaces()
+	end := s.pos
+	for end < len(s.text) && isNameRune(rune(s.text[end])) {
+		end++
+	}
+	return s.text[s.pos:end]
+}
+
+// word consumes the identifier at the scanner
+func (s *lineScanner) word() string {
+	w := s.peekWord()
+	s.pos += len(w)
+	return w
+}
+
+// acceptWord consumes the identifier w if it is next
+func (s *lineScanner) acceptWord(w string) bool {
+	if s.peekWord() != w {
+		return false
+	}
+	s.pos += len(w)
+	return true
+}
+
+// name reads a local or field name: an identifier or a quoted string
+func (s *lineScanner) name() (string, error) {
+	return s.quotedOr(nameLen, "a name")
+}
+
+// symbol reads a function, static, variant or block name
+func (s *lineScanner) symbol() (string, error) {
+	return s.quotedOr(symbolLen, "a name")
+}
+
+// quotedOr reads a quoted string, or else the bare name of length n(text)
+// at the start of the rest of the line
+func (s *lineScanner) quotedOr(n func(string) int, what string) (string, error) {
+	s.skipSpaces()
+	if s.peek('"') {
+		quoted, err := strconv.QuotedPrefix(s.rest())
+		if err != nil {
+			return "", s.errorf("bad quoted name")
+		}
+		s.pos += len(quoted)
+		return strconv.Unquote(quoted)
+	}
+	start := s.pos
+	s.pos += n(s.rest())
+	if s.pos == start {
+		return "", s.errorf("expected %s", what)
+	}
+	return s.text[start:s.pos], nil
+}
+
+func (s *lineScanner) int() (int, error) {
+	s.skipSpaces()
+	start := s.pos
+	for s.pos < len(s.text) && s.text[s.pos] >= '0' && s.text[s.pos] <= '9' {
+		s.pos++
+	}
+	n, err := strconv.Atoi(s.text[start:s.pos])
+	if err != nil {
+		return 0, s.errorf("expected a number")
+	}
+	return n, nil
+}
+
+// literal reads a literal, with its type if it is written as (value: type)
+func (s *lineScanner) literal() (*Literal, error) {
+	if s.accept("(") {
+		lit, err := s.literal()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect(":"); err != nil {
+			return nil, err
+		}
+		if lit.Type, err = s.typ(); err != nil {
+			return nil, err
+		}
+		return lit, s.expect(")")
+	}
+
+	s.skipSpaces()
+	if s.peek('"') {
+		quoted, err := strconv.QuotedPrefix(s.rest())
+		if err != nil {
+			return nil, s.errorf("bad string literal")
+		}
+		s.pos += len(quoted)
+		v, _ := strconv.Unquote(quoted)
+		return &Literal{Type: types.TypeString, Value: v}, nil
+	}
+	switch s.peekWord() {
+	case "true", "false":
+		return &Literal{Type: types.TypeBool, Value: s.word() == "true"}, nil
+	case "nil":
+		s.word()
+		return &Literal{Type: types.TypeNil, Value: nil}, nil
+	}
+
+	start := s.pos
+	for s.pos < len(s.text) && !strings.ContainsRune(" ,)]}:", rune(s.text[s.pos])) {
+		s.pos++
+	}
+	text := s.text[start:s.pos]
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return &Literal{Type: types.TypeInt, Value: n}, nil
+	}
+	if v, err := strconv.ParseFloat(text, 64); err == nil {
+		return &Literal{Type: types.TypeFloat, Value: v}, nil
+	}
+	s.pos = start
+	return nil, s.errorf("expected a literal")
+}
+
+// typeArgs reads the type arguments written [T, U] right after a name, if
+// there are any
+func (s *lineScanner) typeArgs() ([]types.Type, error) {
+	if s.pos >= len(s.text) || s.text[s.pos] != '[' {
+		return nil, nil
+	}
+	s.pos++
+	var args []types.Type
+	for !s.accept("]") {
+		if len(args) > 0 {
+			if err := s.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		typ, err := s.typ()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, typ)
+	}
+	return args, nil
+}
+
+func (s *lineScanner) typ() (types.Type, error) {
+	text, err := s.typeText()
+	if err != nil {
+		return nil, err
+	}
+	return parseType(text), nil
+}
+
+// typeText reads the text of a type. A type runs until a comma, equals
+// sign or closing bracket outside of any brackets it opens, or a brace
+// after a space, which starts the fields of a construct_struct.
+func (s *lineScanner) typeText() (string, error) {
+	s.skipSpaces()
+	start := s.pos
+	depth := 0
+scan:
+	for ; s.pos < len(s.text); s.pos++ {
+		switch c := s.text[s.pos]; c {
+		case '(', '[':
+			depth++
+		case '{':
+			if depth == 0 && s.pos > start && s.text[s.pos-1] == ' ' {
+				break scan
+			}
+			depth++
+		case ')', ']', '}':
+			if depth == 0 {
+				break scan
+			}
+			depth--
+		case ',', '=':
+			if depth == 0 {
+				break scan
+			}
+		}
+	}
+	text := strings.TrimSpace(s.text[start:s.pos])
+	if text == "" {
+		return "", s.errorf("expected a type")
+	}
+	return text, nil
+}
+
+// parseType returns the type written as text: a primitive type, or a
+// types.Named that prints as text
+func parseType(text string) types.Type {
+	for _, prim := range []*types.Primitive{
+		types.TypeInt, types.TypeInt8, types.TypeInt32, types.TypeInt64,
+		types.TypeU8, types.TypeU16, types.TypeU32, types.TypeU64, types.TypeUsize,
+		types.TypeFloat, types.TypeBool, types.TypeString, types.TypeNil, types.TypeVoid,
+	} {
+		if text == string(prim.Kind) {
+			return prim
+		}
+	}
+	return &types.Named{Name: text}
+}
+
+// splitSpan separates the span comment printed by spanComment from the
+// end of a line
+func splitSpan(text string) (string, lexer.Span) {
+	i := strings.LastIndex(text, "  // ")
+	if i < 0 {
+		return text, lexer.Span{}
+	}
+	pos := text[i+len("  // "):]
+	colon := strings.LastIndex(pos, ":")
+	if colon < 0 {
+		return text, lexer.Span{}
+	}
+	column, err := strconv.Atoi(pos[colon+1:])
+	if err != nil {
+		return text, lexer.Span{}
+	}
+	pos = pos[:colon]
+	colon = strings.LastIndex(pos, ":")
+	if colon < 0 {
+		return text, lexer.Span{}
+	}
+	line, err := strconv.Atoi(pos[colon+1:])
+	if err != nil || line == 0 {
+		return text, lexer.Span{}
+	}
+	return text[:i], lexer.Span{Filename: pos[:colon], Line: line, Column: column}
+}
@@ -0,0 +1,165 @@
+package mir
+
+import (
+	"strings"
+	"testing"
+)
+
+// roundTripSource exercises most statements the lowerer emits
+const roundTripSource = `package main;
+
+struct Point { x: int, y: float }
+
+enum Shape {
+    Circle(float),
+    Rect(float, float),
+    Empty,
+}
+
+static mut COUNTER: int = 0;
+
+fn area(s: Shape) -> float {
+    match s {
+        Shape::Circle(r) => 3.5 * r * r,
+        Shape::Rect(w, h) => w * h,
+        Shape::Empty => 0.0,
+    }
+}
+
+fn worker(ch: chan int, n: int) {
+    ch <- n;
+}
+
+fn pick[T](a: T, b: T, first: bool) -> T {
+    if first { return a; }
+    return b;
+}
+
+fn main() {
+    let p = Point { x: 1, y: 2.5 };
+    let arr = [1, 2, 3];
+    let t = (p.x, "two\n");
+    let total = arr[0] + t.0;
+    unsafe { COUNTER = COUNTER + total; };
+    let add = |v: int| { v + 1 };
+    let ch = Channel[int]::new(1);
+    spawn worker(ch, add(2));
+    let got = <-ch;
+    let _s = pick(area(Shape::Circle(1.0)), area(Shape::Rect(2.0, 3.0)), got > 0);
+    let mut i = 0;
+    while i < 3 {
+        i = i + 1;
+    }
+}
+`
+
+func TestParseRoundTrip(t *testing.T) {
+	module, _ := lowerModule(t, roundTripSource)
+	want := module.PrettyPrint()
+	parsed, err := Parse(want)
+	if err != nil {
+		t.Fatalf("Parse: %v\n%s", err, want)
+	}
+	if got := parsed.PrettyPrint(); got != want {
+		t.Errorf("round trip changed the module\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseRoundTripText(t *testing.T) {
+	src := `extern fn puts(string) -> int
+
+static mut "odd name": float = 1.0
+static LIMIT: u8 = 255
+
+#[inline(never)]
+#[instance(pick[int])]
+fn pick_int(a_0: int, b_1: Option[int]) -> int {  // main.mal:3:1
+  let r_2: int
+  let c_3: bool
+  let s_4: Point
+  let e_5: Option[int]
+
+  entry start
+
+  body:
+    r_2 = phi [body: (1: u8), start: a_0]
+    s_4 = construct_struct Point {x: r_2, y: -2.5} (stack)
+    store_field s_4.x = 3
+    e_5 = construct_enum Option::Some#1(r_2)
+    r_2 = variant_payload e_5 (variant 1, member 0)
+    store_index s_4[r_2, 1] = "a \"quoted\" string" (in bounds)
+    r_2 = call (s_4)(r_2, nil)
+    return r_2 (tailcall)  // main.mal:9:5
+
+  start:
+    c_3 = call __gt__(a_0, 0)
+    if c_3 goto body else goto done
+
+  done:
+    select {
+      case r_2 = <-s_4 => goto body
+      case s_4 <- a_0 => goto done
+      after(10) => goto start
+      default => goto done
+    }
+}
+`
+	module, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	fn := findFunction(module, "pick_int")
+	if fn == nil || fn.Entry != fn.Blocks[1] || fn.Instance == nil || fn.Inline != InlineNever {
+		t.Fatalf("header of pick_int was not parsed: %+v", fn)
+	}
+	if fn.Span.Line != 3 || fn.Span.Filename != "main.mal" {
+		t.Errorf("span = %+v, want main.mal:3:1", fn.Span)
+	}
+	if got := module.PrettyPrint(); got != strings.TrimSuffix(src, "\n") {
+		t.Errorf("round trip changed the module\ngot:\n%s\nwant:\n%s", got, src)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"unclosed function", "fn f() -> void {\nentry:\n  return", "function f is not closed"},
+		{"undefined block", "fn f() -> void {\nentry:\n  goto nowhere\n}", "undefined block nowhere"},
+		{"statement outside block", "fn f() -> void {\n  return\n}", "line 2:"},
+		{"bad local", "fn f() -> void {\nentry:\n  x = 1\n}", "line 3:"},
+		{"after terminator", "fn f() -> void {\nentry:\n  return\n  return\n}", "follows the terminator"},
+		{"junk", "hello", "line 1:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrettyPrintRenamesDuplicateLabels(t *testing.T) {
+	first := &BasicBlock{Label: "loop"}
+	second := &BasicBlock{Label: "loop"}
+	first.Terminator = &Goto{Target: second}
+	second.Terminator = &Goto{Target: first}
+	fn := &Function{Name: "spin", Blocks: []*BasicBlock{first, second}, Entry: first}
+	module := &Module{Functions: []*Function{fn}}
+
+	text := module.PrettyPrint()
+	if !strings.Contains(text, "  loop.1:\n    goto loop\n") {
+		t.Fatalf("second loop block should print as loop.1:\n%s", text)
+	}
+	parsed, err := Parse(text)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := parsed.PrettyPrint(); got != text {
+		t.Errorf("round trip changed the module\ngot:\n%s\nwant:\n%s", got, text)
+	}
+}
@@ -9,7 +9,9 @@ import (
 
 func TestLowerPattern_Struct(t *testing.T) {
 	// Setup
-	l := NewLowerer(nil, nil, nil, nil, nil)
+	// The checker types the literal in the pattern
+	one := &ast.IntegerLit{Text: "1"}
+	l := NewLowerer(map[ast.Node]types.Type{one: types.TypeInt}, nil, nil, nil, nil)
 	l.currentFunc = &Function{
 		Name: "test_func",
 	}
@@ -39,7 +41,7 @@ func TestLowerPattern_Struct(t *testing.T) {
 			{
 				Name: &ast.Ident{Name: "x"},
 				Pattern: &ast.LiteralPattern{
-					Value: &ast.IntegerLit{Text: "1"},
+					Value: one,
 				},
 			},
 			{
@@ -149,7 +151,9 @@ func TestLowerPattern_Enum(t *testing.T) {
 
 func TestLowerPattern_Tuple(t *testing.T) {
 	// Setup
-	l := NewLowerer(nil, nil, nil, nil, nil)
+	// The checker types the literal in the pattern
+	one := &ast.IntegerLit{Text: "1"}
+	l := NewLowerer(map[ast.Node]types.Type{one: types.TypeInt}, nil, nil, nil, nil)
 	l.currentFunc = &Function{
 		Name: "test_func",
 	}
@@ -171,7 +175,7 @@ func TestLowerPattern_Tuple(t *testing.T) {
 	pattern := &ast.TuplePattern{
 		Elements: []ast.Pattern{
 			&ast.LiteralPattern{
-				Value: &ast.IntegerLit{Text: "1"},
+				Value: one,
 			},
 			&ast.VarPattern{
 				Name: &ast.Ident{Name: "y"},
@@ -227,7 +231,9 @@ func TestLowerPattern_Tuple(t *testing.T) {
 
 func TestLowerPattern_Nested(t *testing.T) {
 	// Setup
-	l := NewLowerer(nil, nil, nil, nil, nil)
+	// The checker types the literal in the pattern
+	one := &ast.IntegerLit{Text: "1"}
+	l := NewLowerer(map[ast.Node]types.Type{one: types.TypeInt}, nil, nil, nil, nil)
 	l.currentFunc = &Function{Name: "test_func"}
 	entry := l.newBlock("entry")
 	l.currentFunc.Blocks = append(l.currentFunc.Blocks, entry)
@@ -256,7 +262,7 @@ func TestLowerPattern_Nested(t *testing.T) {
 			&ast.TuplePattern{
 				Elements: []ast.Pattern{
 					&ast.VarPattern{Name: &ast.Ident{Name: "x"}},
-					&ast.LiteralPattern{Value: &ast.IntegerLit{Text: "1"}},
+					&ast.LiteralPattern{Value: one},
 				},
 			},
 		},
@@ -2,55 +2,129 @@ package mir
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
 	"github.com/malphas-lang/malphas-lang/internal/types"
 )
 
+// The textual form of MIR is stable: the same module always prints the
+// same way, and Parse reads it back. A function looks like
+//
+//	#[inline]
+//	fn add_one(x_0: int) -> int {  // main.mal:3:1
+//	  let _1: int
+//
+//	  bb0:
+//	    _1 = call __add__(x_0, 1)
+//	    return _1
+//	}
+//
+// Locals are written as name_ID, or _ID when unnamed, so shadowed names
+// stay distinct. Their types are listed once at the top of the function.
+// A literal whose type is not the default for its value (int, float, bool,
+// string) carries it, as in (1: u8). Names that are not plain identifiers
+// are quoted. Spans follow the line they belong to as a // comment.
+
 // PrettyPrint returns a human-readable string representation of a MIR module
 func (m *Module) PrettyPrint() string {
-	var b strings.Builder
-	for i, fn := range m.Functions {
-		if i > 0 {
-			b.WriteString("\n\n")
+	var sections []string
+	if len(m.Externs) > 0 {
+		var b strings.Builder
+		for _, ext := range m.Externs {
+			b.WriteString(ext.PrettyPrint())
+			b.WriteString("\n")
 		}
-		b.WriteString(fn.PrettyPrint())
+		sections = append(sections, strings.TrimSuffix(b.String(), "\n"))
 	}
-	return b.String()
+	if len(m.Statics) > 0 {
+		var b strings.Builder
+		for _, static := range m.Statics {
+			b.WriteString(static.PrettyPrint())
+			b.WriteString("\n")
+		}
+		sections = append(sections, strings.TrimSuffix(b.String(), "\n"))
+	}
+	for _, fn := range m.Functions {
+		sections = append(sections, fn.PrettyPrint())
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// PrettyPrint returns the declaration of an extern function
+func (e *Extern) PrettyPrint() string {
+	params := make([]string, len(e.Params))
+	for i, p := range e.Params {
+		params[i] = typeString(p)
+	}
+	return fmt.Sprintf("extern fn %s(%s) -> %s", symbolString(e.Name), strings.Join(params, ", "), typeString(e.ReturnType))
+}
+
+// PrettyPrint returns the declaration of a static
+func (s *Static) PrettyPrint() string {
+	keyword := "static"
+	if s.Mutable {
+		keyword = "static mut"
+	}
+	return fmt.Sprintf("%s %s: %s = %s", keyword, symbolString(s.Name), typeString(s.Type), valueString(s.Value))
 }
 
 // PrettyPrint returns a human-readable string representation of a function
 func (f *Function) PrettyPrint() string {
 	var b strings.Builder
 
+	// Attributes
+	switch f.Inline {
+	case InlineHint:
+		b.WriteString("#[inline]\n")
+	case InlineAlways:
+		b.WriteString("#[inline(always)]\n")
+	case InlineNever:
+		b.WriteString("#[inline(never)]\n")
+	}
+	if f.Instance != nil {
+		b.WriteString(fmt.Sprintf("#[instance(%s%s)]\n", symbolString(f.Instance.Generic), typeArgsString(f.Instance.TypeArgs)))
+	}
+
 	// Function signature
-	b.WriteString(fmt.Sprintf("fn %s(", f.Name))
+	b.WriteString(fmt.Sprintf("fn %s", symbolString(f.Name)))
+	if len(f.TypeParams) > 0 {
+		params := make([]string, len(f.TypeParams))
+		for i := range f.TypeParams {
+			params[i] = f.TypeParams[i].String()
+		}
+		b.WriteString("[" + strings.Join(params, ", ") + "]")
+	}
 	params := make([]string, len(f.Params))
 	for i, p := range f.Params {
-		params[i] = fmt.Sprintf("%s: %s", p.Name, typeString(p.Type))
+		params[i] = fmt.Sprintf("%s: %s", localString(p), typeString(p.Type))
 	}
-	b.WriteString(strings.Join(params, ", "))
-	b.WriteString(") -> ")
+	b.WriteString("(" + strings.Join(params, ", ") + ") -> ")
 	b.WriteString(typeString(f.ReturnType))
-	b.WriteString(" {\n")
+	b.WriteString(" {")
+	b.WriteString(spanComment(f.Span))
+	b.WriteString("\n")
 
 	// Locals
 	if len(f.Locals) > 0 {
-		b.WriteString("  // Locals:\n")
 		for _, local := range f.Locals {
-			if local.Name == "" {
-				b.WriteString(fmt.Sprintf("  let _%d: %s\n", local.ID, typeString(local.Type)))
-			} else {
-				b.WriteString(fmt.Sprintf("  let %s: %s\n", local.Name, typeString(local.Type)))
-			}
+			b.WriteString(fmt.Sprintf("  let %s: %s\n", localString(local), typeString(local.Type)))
 		}
 		b.WriteString("\n")
 	}
+	labels := newBlockLabels(f.Blocks)
+	if f.Entry != nil && len(f.Blocks) > 0 && f.Entry != f.Blocks[0] {
+		b.WriteString(fmt.Sprintf("  entry %s\n\n", labels.of(f.Entry)))
+	}
 
 	// Basic blocks
-	for _, block := range f.Blocks {
-		b.WriteString(block.PrettyPrint())
-		b.WriteString("\n")
+	for i, block := range f.Blocks {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(block.prettyPrint(labels))
 	}
 
 	b.WriteString("}")
@@ -59,20 +133,51 @@ func (f *Function) PrettyPrint() string {
 
 // PrettyPrint returns a human-readable string representation of a basic block
 func (bb *BasicBlock) PrettyPrint() string {
+	return bb.prettyPrint(nil)
+}
+
+// blockLabels names the blocks of a function in its dump. Labels need not
+// be unique within a function, so a block whose label is already taken gets
+// a .N suffix, as in the generated LLVM IR.
+type blockLabels map[*BasicBlock]string
+
+func newBlockLabels(blocks []*BasicBlock) blockLabels {
+	labels := make(blockLabels, len(blocks))
+	used := make(map[string]bool, len(blocks))
+	for _, block := range blocks {
+		label := block.Label
+		for n := 1; used[label]; n++ {
+			label = fmt.Sprintf("%s.%d", block.Label, n)
+		}
+		used[label] = true
+		labels[block] = label
+	}
+	return labels
+}
+
+// of returns the printed label of bb
+func (l blockLabels) of(bb *BasicBlock) string {
+	if label, ok := l[bb]; ok {
+		return symbolString(label)
+	}
+	return symbolString(bb.Label)
+}
+
+func (bb *BasicBlock) prettyPrint(labels blockLabels) string {
 	var b strings.Builder
-	b.WriteString(fmt.Sprintf("  %s:\n", bb.Label))
+	b.WriteString(fmt.Sprintf("  %s:\n", labels.of(bb)))
 
 	// Statements
 	for _, stmt := range bb.Statements {
 		b.WriteString("    ")
-		b.WriteString(prettyPrintStmt(stmt))
+		b.WriteString(prettyPrintStmt(stmt, labels))
 		b.WriteString("\n")
 	}
 
 	// Terminator
 	if bb.Terminator != nil {
 		b.WriteString("    ")
-		b.WriteString(prettyPrintTerminator(bb.Terminator))
+		b.WriteString(prettyPrintTerminator(bb.Terminator, labels))
 		b.WriteString("\n")
 	}
 
@@ -86,51 +191,59 @@ func (a *Assign) PrettyPrint() string {
 }
 
 func (c *Call) PrettyPrint() string {
-	args := make([]string, len(c.Args))
-	for i, arg := range c.Args {
-		args[i] = operandString(arg)
+	funcName := symbolString(c.Func)
+	if c.Func == "" && c.FuncOperand != nil {
+		funcName = "(" + operandString(c.FuncOperand) + ")"
 	}
-	funcName := c.Func
-	if funcName == "" && c.FuncOperand != nil {
-		funcName = operandString(c.FuncOperand)
-	}
-	return fmt.Sprintf("%s = call %s(%s)", localString(c.Result), funcName, strings.Join(args, ", "))
+	return fmt.Sprintf("%s = call %s%s(%s)", localString(c.Result), funcName, typeArgsString(c.TypeArgs), operandsString(c.Args))
 }
 
 func (s *Spawn) PrettyPrint() string {
-	args := make([]string, len(s.Args))
-	for i, arg := range s.Args {
-		args[i] = operandString(arg)
+	call := fmt.Sprintf("spawn %s%s(%s)", symbolString(s.Func), typeArgsString(s.TypeArgs), operandsString(s.Args))
+	if s.Result != nil {
+		return fmt.Sprintf("%s = %s", localString(*s.Result), call)
 	}
-	return fmt.Sprintf("spawn %s(%s)", s.Func, strings.Join(args, ", "))
+	return call
+}
+
+func (j *Join) PrettyPrint() string {
+	return fmt.Sprintf("%s = join %s", localString(j.Result), operandString(j.Handle))
 }
 
 func (y *Yield) PrettyPrint() string {
 	return "yield"
 }
 
+func (l *Load) PrettyPrint() string {
+	return fmt.Sprintf("%s = load %s", localString(l.Result), operandString(l.Address))
+}
+
+func (a *AddressOf) PrettyPrint() string {
+	return fmt.Sprintf("%s = &%s", localString(a.Result), localString(a.Target))
+}
+
 func (lf *LoadField) PrettyPrint() string {
-	return fmt.Sprintf("%s = load_field %s.%s", localString(lf.Result), operandString(lf.Target), lf.Field)
+	return fmt.Sprintf("%s = load_field %s.%s", localString(lf.Result), operandString(lf.Target), nameString(lf.Field))
 }
 
 func (sf *StoreField) PrettyPrint() string {
-	return fmt.Sprintf("store_field %s.%s = %s", operandString(sf.Target), sf.Field, operandString(sf.Value))
+	return fmt.Sprintf("store_field %s.%s = %s", operandString(sf.Target), nameString(sf.Field), operandString(sf.Value))
 }
 
 func (li *LoadIndex) PrettyPrint() string {
-	indices := make([]string, len(li.Indices))
-	for i, idx := range li.Indices {
-		indices[i] = operandString(idx)
+	out := fmt.Sprintf("%s = load_index %s[%s]", localString(li.Result), operandString(li.Target), operandsString(li.Indices))
+	if li.InBounds {
+		out += " (in bounds)"
 	}
-	return fmt.Sprintf("%s = load_index %s[%s]", localString(li.Result), operandString(li.Target), strings.Join(indices, ", "))
+	return out
 }
 
 func (si *StoreIndex) PrettyPrint() string {
-	indices := make([]string, len(si.Indices))
-	for i, idx := range si.Indices {
-		indices[i] = operandString(idx)
+	out := fmt.Sprintf("store_index %s[%s] = %s", operandString(si.Target), operandsString(si.Indices), operandString(si.Value))
+	if si.InBounds {
+		out += " (in bounds)"
 	}
-	return fmt.Sprintf("store_index %s[%s] = %s", operandString(si.Target), strings.Join(indices, ", "), operandString(si.Value))
+	return out
 }
 
 func (cs *ConstructStruct) PrettyPrint() string {
@@ -141,33 +254,78 @@ func (cs *ConstructStruct) PrettyPrint() string {
 		b.WriteString(fmt.Sprintf("%s = construct_struct %s {", localString(cs.Result), cs.Type.String()))
 	}
 
-	fields := make([]string, 0, len(cs.Fields))
-	for name, value := range cs.Fields {
-		fields = append(fields, fmt.Sprintf("%s: %s", name, operandString(value)))
+	names := make([]string, 0, len(cs.Fields))
+	for name := range cs.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]string, len(names))
+	for i, name := range names {
+		fields[i] = fmt.Sprintf("%s: %s", nameString(name), operandString(cs.Fields[name]))
 	}
 	b.WriteString(strings.Join(fields, ", "))
 	b.WriteString("}")
+	if cs.StackAlloc {
+		b.WriteString(" (stack)")
+	}
 	return b.String()
 }
 
+func (ls *LoadStatic) PrettyPrint() string {
+	return fmt.Sprintf("%s = static %s", localString(ls.Result), symbolString(ls.Name))
+}
+
+func (ss *StoreStatic) PrettyPrint() string {
+	return fmt.Sprintf("static %s = %s", symbolString(ss.Name), operandString(ss.Value))
+}
+
 func (ca *ConstructArray) PrettyPrint() string {
-	elements := make([]string, len(ca.Elements))
-	for i, elem := range ca.Elements {
-		elements[i] = operandString(elem)
+	out := fmt.Sprintf("%s = construct_array [%s]", localString(ca.Result), operandsString(ca.Elements))
+	if ca.Type != nil {
+		out += " as " + ca.Type.String()
 	}
-	return fmt.Sprintf("%s = construct_array [%s]", localString(ca.Result), strings.Join(elements, ", "))
+	return out
 }
 
 func (ct *ConstructTuple) PrettyPrint() string {
-	elements := make([]string, len(ct.Elements))
-	for i, elem := range ct.Elements {
-		elements[i] = operandString(elem)
+	out := fmt.Sprintf("%s = construct_tuple (%s)", localString(ct.Result), operandsString(ct.Elements))
+	if ct.StackAlloc {
+		out += " (stack)"
+	}
+	return out
+}
+
+func (ce *ConstructEnum) PrettyPrint() string {
+	out := fmt.Sprintf("%s = construct_enum %s#%d(%s)", localString(ce.Result), symbolString(ce.Type+"::"+ce.Variant), ce.VariantIndex, operandsString(ce.Values))
+	if ce.StackAlloc {
+		out += " (stack)"
+	}
+	return out
+}
+
+func (d *Discriminant) PrettyPrint() string {
+	return fmt.Sprintf("%s = discriminant %s", localString(d.Result), operandString(d.Target))
+}
+
+func (a *AccessVariantPayload) PrettyPrint() string {
+	return fmt.Sprintf("%s = variant_payload %s (variant %d, member %d)", localString(a.Result), operandString(a.Target), a.VariantIndex, a.MemberIndex)
+}
+
+func (p *Phi) PrettyPrint() string {
+	return p.prettyPrint(nil)
+}
+
+func (p *Phi) prettyPrint(labels blockLabels) string {
+	inputs := make([]string, 0, len(p.Inputs))
+	for block, value := range p.Inputs {
+		inputs = append(inputs, fmt.Sprintf("%s: %s", labels.of(block), operandString(value)))
 	}
-	return fmt.Sprintf("%s = construct_tuple (%s)", localString(ct.Result), strings.Join(elements, ", "))
+	sort.Strings(inputs)
+	return fmt.Sprintf("%s = phi [%s]", localString(p.Result), strings.Join(inputs, ", "))
 }
 
 // prettyPrintStmt dispatches to the appropriate PrettyPrint method
-func prettyPrintStmt(stmt Statement) string {
+func prettyPrintStmt(stmt Statement, labels blockLabels) string {
 	switch s := stmt.(type) {
 	case *Assign:
 		return s.PrettyPrint()
@@ -175,8 +333,14 @@ func prettyPrintStmt(stmt Statement) string {
 		return s.PrettyPrint()
 	case *Spawn:
 		return s.PrettyPrint()
+	case *Join:
+		return s.PrettyPrint()
 	case *Yield:
 		return s.PrettyPrint()
+	case *Load:
+		return s.PrettyPrint()
+	case *AddressOf:
+		return s.PrettyPrint()
 	case *LoadField:
 		return s.PrettyPrint()
 	case *StoreField:
@@ -191,6 +355,14 @@ func prettyPrintStmt(stmt Statement) string {
 		return s.PrettyPrint()
 	case *ConstructTuple:
 		return s.PrettyPrint()
+	case *ConstructEnum:
+		return s.PrettyPrint()
+	case *Discriminant:
+		return s.PrettyPrint()
+	case *AccessVariantPayload:
+		return s.PrettyPrint()
+	case *Phi:
+		return s.prettyPrint(labels)
 	case *MakeChannel:
 		return s.PrettyPrint()
 	case *Send:
@@ -201,25 +373,37 @@ func prettyPrintStmt(stmt Statement) string {
 		return s.PrettyPrint()
 	case *AlignOf:
 		return s.PrettyPrint()
+	case *PtrOffset:
+		return s.PrettyPrint()
 	case *Cast:
 		return s.PrettyPrint()
 	case *MakeClosure:
 		return s.PrettyPrint()
+	case *RegisterDrop:
+		return s.PrettyPrint()
+	case *CancelDrop:
+		return s.PrettyPrint()
+	case *LoadStatic:
+		return s.PrettyPrint()
+	case *StoreStatic:
+		return s.PrettyPrint()
 	default:
 		return fmt.Sprintf("<?stmt:%T>", stmt)
 	}
 }
 
 // prettyPrintTerminator dispatches to the appropriate PrettyPrint method
-func prettyPrintTerminator(term Terminator) string {
+func prettyPrintTerminator(term Terminator, labels blockLabels) string {
 	switch t := term.(type) {
 	case *Return:
 		return t.PrettyPrint()
 	case *Goto:
-		return t.PrettyPrint()
+		return t.prettyPrint(labels)
 	case *Branch:
-		return t.PrettyPrint()
+		return t.prettyPrint(labels)
 	case *Select:
+		return t.prettyPrint(labels)
+	case *Unreachable:
 		return t.PrettyPrint()
 	default:
 		return fmt.Sprintf("<?terminator:%T>", term)
@@ -227,7 +411,11 @@ func prettyPrintTerminator(term Terminator) string {
 }
 
 func (mc *MakeChannel) PrettyPrint() string {
-	return fmt.Sprintf("%s = make_channel(cap=%s)", localString(mc.Result), operandString(mc.Capacity))
+	out := fmt.Sprintf("%s = make_channel(cap=%s)", localString(mc.Result), operandString(mc.Capacity))
+	if mc.Type != nil {
+		out += " as " + mc.Type.String()
+	}
+	return out
 }
 
 func (s *Send) PrettyPrint() string {
@@ -235,6 +423,9 @@ func (s *Send) PrettyPrint() string {
 }
 
 func (r *Receive) PrettyPrint() string {
+	if r.Optional {
+		return fmt.Sprintf("%s = recv? %s", localString(r.Result), operandString(r.Channel))
+	}
 	return fmt.Sprintf("%s = recv %s", localString(r.Result), operandString(r.Channel))
 }
 
@@ -246,59 +437,97 @@ func (a *AlignOf) PrettyPrint() string {
 	return fmt.Sprintf("%s = alignof(%s)", localString(a.Result), typeString(a.Type))
 }
 
+func (p *PtrOffset) PrettyPrint() string {
+	return fmt.Sprintf("%s = offset %s by %s", localString(p.Result), operandString(p.Pointer), operandString(p.Offset))
+}
+
 func (c *Cast) PrettyPrint() string {
 	return fmt.Sprintf("%s = cast %s to %s", localString(c.Result), operandString(c.Operand), typeString(c.Type))
 }
 
 func (mc *MakeClosure) PrettyPrint() string {
-	return fmt.Sprintf("%s = make_closure %s(env=%s)", localString(mc.Result), mc.Func, operandString(mc.Env))
+	out := fmt.Sprintf("%s = make_closure %s(env=%s)", localString(mc.Result), symbolString(mc.Func), operandString(mc.Env))
+	if mc.StackAlloc {
+		out += " (stack)"
+	}
+	return out
 }
 
 func (s *Select) PrettyPrint() string {
-	var cases []string
+	return s.prettyPrint(nil)
+}
+
+func (s *Select) prettyPrint(labels blockLabels) string {
+	var b strings.Builder
+	b.WriteString("select {\n")
 	for _, c := range s.Cases {
-		var caseStr string
+		target := labels.of(c.Target)
+		b.WriteString("      ")
 		switch c.Kind {
 		case "send":
-			caseStr = fmt.Sprintf("case %s <- %s => goto %s", operandString(c.Channel), operandString(c.Value), c.Target.Label)
+			b.WriteString(fmt.Sprintf("case %s <- %s => goto %s", operandString(c.Channel), operandString(c.Value), target))
 		case "recv":
 			if c.Result != nil {
-				caseStr = fmt.Sprintf("case %s = <-%s => goto %s", localString(*c.Result), operandString(c.Channel), c.Target.Label)
+				b.WriteString(fmt.Sprintf("case %s = <-%s => goto %s", localString(*c.Result), operandString(c.Channel), target))
 			} else {
-				caseStr = fmt.Sprintf("case <-%s => goto %s", operandString(c.Channel), c.Target.Label)
+				b.WriteString(fmt.Sprintf("case <-%s => goto %s", operandString(c.Channel), target))
 			}
 		case "default":
-			caseStr = fmt.Sprintf("default => goto %s", c.Target.Label)
+			b.WriteString(fmt.Sprintf("default => goto %s", target))
+		case "after":
+			b.WriteString(fmt.Sprintf("after(%s) => goto %s", operandString(c.Timeout), target))
 		}
-		cases = append(cases, caseStr)
+		b.WriteString("\n")
 	}
-	return fmt.Sprintf("select {\n\t\t%s\n\t}", strings.Join(cases, "\n\t\t"))
+	b.WriteString("    }")
+	return b.String()
 }
 
 // PrettyPrint implementations for terminators
 
 func (r *Return) PrettyPrint() string {
-	if r.Value == nil {
-		return "return"
+	out := "return"
+	if r.Value != nil {
+		out += " " + operandString(r.Value)
 	}
-	return fmt.Sprintf("return %s", operandString(r.Value))
+	if r.TailCall {
+		out += " (tailcall)"
+	}
+	return out + spanComment(r.Span)
 }
 
 func (g *Goto) PrettyPrint() string {
-	return fmt.Sprintf("goto %s", g.Target.Label)
+	return g.prettyPrint(nil)
+}
+
+func (g *Goto) prettyPrint(labels blockLabels) string {
+	return fmt.Sprintf("goto %s", labels.of(g.Target))
 }
 
 func (b *Branch) PrettyPrint() string {
-	return fmt.Sprintf("if %s goto %s else goto %s", operandString(b.Condition), b.True.Label, b.False.Label)
+	return b.prettyPrint(nil)
+}
+
+func (b *Branch) prettyPrint(labels blockLabels) string {
+	return fmt.Sprintf("if %s goto %s else goto %s", operandString(b.Condition), labels.of(b.True), labels.of(b.False))
+}
+
+func (*Unreachable) PrettyPrint() string {
+	return "unreachable"
+}
+
+func (r *RegisterDrop) PrettyPrint() string {
+	return fmt.Sprintf("register_drop %s, %s%s", operandString(r.Value), symbolString(r.Func), typeArgsString(r.TypeArgs))
+}
+
+func (c *CancelDrop) PrettyPrint() string {
+	return fmt.Sprintf("cancel_drop %s", operandString(c.Value))
 }
 
 // Helper functions for pretty printing
 
 func localString(local Local) string {
-	if local.Name == "" {
-		return fmt.Sprintf("_%d", local.ID)
-	}
-	return local.Name
+	return fmt.Sprintf("%s_%d", local.Name, local.ID)
 }
 
 func operandString(op Operand) string {
@@ -312,6 +541,14 @@ func operandString(op Operand) string {
 	}
 }
 
+func operandsString(ops []Operand) string {
+	parts := make([]string, len(ops))
+	for i, op := range ops {
+		parts[i] = operandString(op)
+	}
+	return strings.Join(parts, ", ")
+}
+
 func rvalueString(rv Rvalue) string {
 	// Since Operand implements Rvalue, we can use operandString
 	if op, ok := rv.(Operand); ok {
@@ -321,18 +558,31 @@ func rvalueString(rv Rvalue) string {
 }
 
 func literalString(lit *Literal) string {
-	switch v := lit.Value.(type) {
+	value := valueString(lit.Value)
+	if lit.Type != nil && lit.Type.String() != defaultLiteralType(lit.Value) {
+		return fmt.Sprintf("(%s: %s)", value, lit.Type)
+	}
+	return value
+}
+
+// valueString returns the text of a literal or static value
+func valueString(v any) string {
+	switch v := v.(type) {
 	case int64:
-		return fmt.Sprintf("%d", v)
+		return strconv.FormatInt(v, 10)
 	case float64:
-		return fmt.Sprintf("%g", v)
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0" // keep it apart from an integer
+		}
+		return s
 	case bool:
 		if v {
 			return "true"
 		}
 		return "false"
 	case string:
-		return fmt.Sprintf("%q", v)
+		return strconv.Quote(v)
 	case nil:
 		return "nil"
 	default:
@@ -340,9 +590,92 @@ func literalString(lit *Literal) string {
 	}
 }
 
+// defaultLiteralType is the type a literal with value v has unless it
+// says otherwise
+func defaultLiteralType(v any) string {
+	switch v.(type) {
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	}
+	return "nil"
+}
+
 func typeString(typ types.Type) string {
 	if typ == nil {
 		return "void"
 	}
 	return typ.String()
 }
+
+func typeArgsString(args []types.Type) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = typeString(arg)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// spanComment returns the comment giving the source position of span, or
+// "" if it has none
+func spanComment(span lexer.Span) string {
+	if span.Line == 0 {
+		return ""
+	}
+	return fmt.Sprintf("  // %s:%d:%d", span.Filename, span.Line, span.Column)
+}
+
+// nameString returns a field name, quoted unless it is an identifier
+func nameString(name string) string {
+	if name != "" && strings.IndexFunc(name, func(r rune) bool { return !isNameRune(r) }) < 0 {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// symbolString returns the name of a function, static, enum variant or
+// block, quoted unless it is made of identifiers joined by :: or .
+func symbolString(name string) string {
+	if name != "" && symbolLen(name) == len(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// nameLen returns the length of the identifier at the start of text
+func nameLen(text string) int {
+	n := 0
+	for n < len(text) && isNameRune(rune(text[n])) {
+		n++
+	}
+	return n
+}
+
+// symbolLen returns the length of the symbol at the start of text:
+// identifiers joined by :: or . (a single colon ends it, as in `label:`)
+func symbolLen(text string) int {
+	n := 0
+	for n < len(text) {
+		switch {
+		case isNameRune(rune(text[n])) || text[n] == '.':
+			n++
+		case strings.HasPrefix(text[n:], "::"):
+			n += 2
+		default:
+			return n
+		}
+	}
+	return n
+}
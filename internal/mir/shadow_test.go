@@ -0,0 +1,37 @@
+package mir
+
+import "testing"
+
+func TestLowerBlockScopedLet(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn take(v: int) {}
+fn main() {
+    let y = 5;
+    if y > 0 {
+        let y = "inner";
+    }
+    take(y);
+}
+`)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			call, ok := stmt.(*Call)
+			if !ok || call.Func != "take" {
+				continue
+			}
+			ref, ok := call.Args[0].(*LocalRef)
+			if !ok {
+				t.Fatalf("take(y) passes %s, want a local", operandString(call.Args[0]))
+			}
+			if ref.Local.Type.String() != "int" {
+				t.Errorf("take(y) reads the %s binding from the inner block, want the outer int", ref.Local.Type)
+			}
+			return
+		}
+	}
+	t.Fatal("no call of take")
+}
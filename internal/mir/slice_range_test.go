@@ -0,0 +1,53 @@
+package mir
+
+import "testing"
+
+func TestLowerSliceRange(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn main() {
+    let arr = [1, 2, 3, 4];
+    let mid = arr[1..3];
+    let tail = arr[2..];
+    let word = "hello"[..2];
+}
+`)
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+
+	var constructs int
+	calls := make(map[string][]*Call)
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			switch s := stmt.(type) {
+			case *ConstructArray:
+				constructs++
+				if len(s.Elements) != 4 {
+					t.Errorf("array literal built with %d elements, want 4", len(s.Elements))
+				}
+			case *StoreIndex:
+				t.Errorf("array literal stores its elements one by one: %s", s.PrettyPrint())
+			case *Call:
+				calls[s.Func] = append(calls[s.Func], s)
+			}
+		}
+	}
+	if constructs != 1 {
+		t.Errorf("got %d construct_array statements, want 1", constructs)
+	}
+
+	subslices := calls["runtime_slice_subslice"]
+	if len(subslices) != 2 {
+		t.Fatalf("got %d runtime_slice_subslice calls, want 2", len(subslices))
+	}
+	if lit, ok := subslices[0].Args[2].(*Literal); !ok || lit.Value != int64(3) {
+		t.Errorf("arr[1..3] ends at %s, want 3", operandString(subslices[0].Args[2]))
+	}
+	if lit, ok := subslices[1].Args[2].(*Literal); !ok || lit.Value != int64(4) {
+		t.Errorf("arr[2..] ends at %s, want the length of arr, 4", operandString(subslices[1].Args[2]))
+	}
+	if len(calls["__string_substring__"]) != 1 {
+		t.Errorf("a string range should lower to a substring call")
+	}
+}
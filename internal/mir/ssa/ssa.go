@@ -33,6 +33,7 @@ func transformFunction(fn *mir.Function) *mir.Function {
 		Locals:     make([]mir.Local, 0),
 		Blocks:     make([]*mir.BasicBlock, 0),
 		Entry:      nil,
+		Inline:     fn.Inline,
 	}
 
 	copy(ssaFn.Params, fn.Params)
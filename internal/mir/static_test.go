@@ -0,0 +1,47 @@
+package mir
+
+import "testing"
+
+func TestLowerStatics(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+static LIMIT: int = 2 * 5;
+static mut COUNT: int = 0;
+fn main() {
+    unsafe {
+        COUNT = COUNT + LIMIT;
+    };
+}
+`)
+	if len(module.Statics) != 2 {
+		t.Fatalf("module has %d statics, want 2", len(module.Statics))
+	}
+	limit, count := module.Statics[0], module.Statics[1]
+	if limit.Name != "LIMIT" || limit.Value != int64(10) || limit.Mutable {
+		t.Errorf("LIMIT = %+v, want immutable with value 10", limit)
+	}
+	if count.Name != "COUNT" || count.Value != int64(0) || !count.Mutable {
+		t.Errorf("COUNT = %+v, want mutable with value 0", count)
+	}
+
+	fn := findFunction(module, "main")
+	if fn == nil {
+		t.Fatal("no main in module")
+	}
+	var loads, stores []string
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			switch s := stmt.(type) {
+			case *LoadStatic:
+				loads = append(loads, s.Name)
+			case *StoreStatic:
+				stores = append(stores, s.Name)
+			}
+		}
+	}
+	if len(loads) != 2 || loads[0] != "COUNT" || loads[1] != "LIMIT" {
+		t.Errorf("main loads statics %v, want [COUNT LIMIT]", loads)
+	}
+	if len(stores) != 1 || stores[0] != "COUNT" {
+		t.Errorf("main stores statics %v, want [COUNT]", stores)
+	}
+}
@@ -0,0 +1,83 @@
+package mir
+
+import "testing"
+
+// stringCalls returns the functions called by fn, in order
+func stringCalls(fn *Function) []string {
+	var calls []string
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if call, ok := stmt.(*Call); ok {
+				calls = append(calls, call.Func)
+			}
+		}
+	}
+	return calls
+}
+
+func TestLowerStringConcat(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{"two pieces", `a + "y"`, []string{"__string_concat__"}},
+		{"chain", `a + "y" + a + "z"`, []string{
+			"__string_builder_new__",
+			"__string_builder_append__",
+			"__string_builder_append__",
+			"__string_builder_append__",
+			"__string_builder_append__",
+			"__string_builder_build__",
+		}},
+		{"nested right", `a + ("1" + "2")`, []string{
+			"__string_builder_new__",
+			"__string_builder_append__",
+			"__string_builder_append__",
+			"__string_builder_append__",
+			"__string_builder_build__",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			module, _ := lowerModule(t, `package main;
+fn concat(a: string) -> string {
+    return `+tt.expr+`;
+}
+fn main() {}
+`)
+			fn := findFunction(module, "concat")
+			if fn == nil {
+				t.Fatal("no concat in module")
+			}
+			got := stringCalls(fn)
+			if len(got) != len(tt.want) {
+				t.Fatalf("calls = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("calls = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestLowerIntAddIsNotStringConcat(t *testing.T) {
+	module, _ := lowerModule(t, `package main;
+fn add(a: int) -> int {
+    return a + 1 + a;
+}
+fn main() {}
+`)
+	fn := findFunction(module, "add")
+	if fn == nil {
+		t.Fatal("no add in module")
+	}
+	for _, name := range stringCalls(fn) {
+		if name != "__add__" {
+			t.Errorf("int + should lower to __add__, got %s", name)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package mir
+
+import (
+	"strings"
+	"testing"
+)
+
+// lowerModule parses, checks and lowers src, returning the module and lowerer
+func lowerModule(t *testing.T, src string) (*Module, *Lowerer) {
+	t.Helper()
+	file, checker := parseAndTypeCheck(t, src)
+	lowerer := NewLowerer(checker.ExprTypes, checker.CallTypeArgs, nil, nil, nil)
+	lowerer.Moves = checker.Moves
+	lowerer.Consts = checker.Consts
+	module, err := lowerer.LowerModule(file)
+	if err != nil {
+		t.Fatalf("lowering error: %v", err)
+	}
+	return module, lowerer
+}
+
+func findFunction(module *Module, name string) *Function {
+	for _, fn := range module.Functions {
+		if fn.Name == name {
+			return fn
+		}
+	}
+	return nil
+}
+
+// selfCalls counts the calls fn makes to itself
+func selfCalls(fn *Function) int {
+	count := 0
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if call, ok := stmt.(*Call); ok && call.Func == fn.Name {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestTailCallsBecomeLoops(t *testing.T) {
+	src := `
+package test;
+
+fn sum(n: int, acc: int) -> int {
+	if n == 0 {
+		return acc;
+	}
+	return sum(n - 1, acc + n);
+}
+
+fn count(n: int) -> int {
+	if n == 0 {
+		0
+	} else {
+		count(n - 1)
+	}
+}
+
+fn countdown(n: int) {
+	if n == 0 {
+		return;
+	}
+	countdown(n - 1);
+}
+
+fn fib(n: int) -> int {
+	if n < 2 {
+		return n;
+	}
+	return fib(n - 1) + fib(n - 2);
+}
+`
+	module, lowerer := lowerModule(t, src)
+	if len(lowerer.Errors) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", lowerer.Errors)
+	}
+
+	for _, name := range []string{"sum", "count", "countdown"} {
+		fn := findFunction(module, name)
+		if n := selfCalls(fn); n != 0 {
+			t.Errorf("%s: expected the tail call to become a loop, %d self calls remain", name, n)
+		}
+		if _, ok := fn.Entry.Terminator.(*Goto); !ok || len(fn.Entry.Statements) != 0 {
+			t.Errorf("%s: entry block should only jump to the loop header", name)
+		}
+	}
+
+	// Neither call in fib is in tail position
+	if n := selfCalls(findFunction(module, "fib")); n != 2 {
+		t.Errorf("fib: expected 2 self calls to remain, got %d", n)
+	}
+}
+
+func TestTailCallSwapsParameters(t *testing.T) {
+	src := `
+package test;
+
+fn swap(a: int, b: int, n: int) -> int {
+	if n == 0 {
+		return a;
+	}
+	return swap(b, a, n - 1);
+}
+`
+	module, _ := lowerModule(t, src)
+	fn := findFunction(module, "swap")
+
+	// a and b are read by each other's argument, so both must be saved
+	// before either is overwritten
+	var loop *BasicBlock
+	for _, block := range fn.Blocks {
+		if g, ok := block.Terminator.(*Goto); ok && block != fn.Entry && g.Target.Label == "tailrec" {
+			loop = block
+		}
+	}
+	if loop == nil {
+		t.Fatal("expected a block jumping back to the loop header")
+	}
+	var assigned []string
+	for _, stmt := range loop.Statements {
+		if assign, ok := stmt.(*Assign); ok {
+			assigned = append(assigned, assign.Local.Name)
+		}
+	}
+	got := strings.Join(assigned, " ")
+	if got != "tailrec.a tailrec.b a b n" {
+		t.Errorf("expected saved copies before parameter assignments, got %q", got)
+	}
+}
+
+func TestTailCallAttributeReportsNonTailCalls(t *testing.T) {
+	src := `
+package test;
+
+fn helper(n: int) -> int {
+	return n;
+}
+
+fn fact(n: int) -> int {
+	if n <= 1 {
+		return 1;
+	}
+	#[tailcall] return n * fact(n - 1);
+}
+
+fn other(n: int) -> int {
+	#[tailcall] return helper(n);
+}
+
+fn ok(n: int) -> int {
+	if n == 0 {
+		return 0;
+	}
+	#[tailcall] return ok(n - 1);
+}
+`
+	_, lowerer := lowerModule(t, src)
+	if len(lowerer.Errors) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d: %v", len(lowerer.Errors), lowerer.Errors)
+	}
+	for i, fnName := range []string{"fact", "other"} {
+		d := lowerer.Errors[i]
+		if !strings.Contains(d.Message, "`"+fnName+"`") {
+			t.Errorf("diagnostic %d should name %s, got %q", i, fnName, d.Message)
+		}
+		if d.Span.Line == 0 || len(d.Notes) == 0 {
+			t.Errorf("diagnostic %d should point at the return and explain why", i)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package mir
+
+import "testing"
+
+func TestLowerTraitDefaultMethods(t *testing.T) {
+	src := `package main;
+
+trait Shape {
+    fn area(&self) -> int;
+    fn double_area(&self) -> int {
+        return self.area() * 2;
+    }
+    fn describe(&self) -> int {
+        return 0;
+    }
+}
+
+#[copy]
+struct Square { side: int }
+struct Rect { w: int, h: int }
+
+impl Shape for Square {
+    fn area(&self) -> int { return self.side * self.side; }
+}
+
+impl Shape for Rect {
+    fn area(&self) -> int { return self.w * self.h; }
+    fn describe(&self) -> int { return 1; }
+}
+
+fn twice[T: Shape](s: T) -> int {
+    return s.double_area();
+}
+
+fn main() {
+    let s = Square { side: 3 };
+    let r = Rect { w: 2, h: 5 };
+    println(s.double_area() + r.double_area());
+    println(twice(s) + s.describe() + r.describe());
+}
+`
+	file, checker := parseAndTypeCheck(t, src)
+	lowerer := NewLowerer(checker.ExprTypes, checker.CallTypeArgs, checker.GlobalScope, checker.MethodTable, checker.Modules)
+	module, err := lowerer.LowerModule(file)
+	if err != nil {
+		t.Fatalf("lowering error: %v", err)
+	}
+
+	// Each impl gets its own copy of the default body, calling its own area
+	for _, typeName := range []string{"Square", "Rect"} {
+		fn := findFunction(module, typeName+"::double_area")
+		if fn == nil {
+			t.Fatalf("no %s::double_area in module", typeName)
+		}
+		if !callsFunction(fn, typeName+"::area") {
+			t.Errorf("%s::double_area does not call %s::area", typeName, typeName)
+		}
+		if got := fn.Params[0].Type.String(); got != typeName {
+			t.Errorf("%s::double_area self type = %s, want %s", typeName, got, typeName)
+		}
+	}
+
+	// The override replaces the default rather than adding a second copy
+	count := 0
+	for _, fn := range module.Functions {
+		if fn.Name == "Rect::describe" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("found %d Rect::describe functions, want 1", count)
+	}
+
+	// Static dispatch through a bound reaches the default copy
+	twice := findFunction(module, "twice$Square")
+	if twice == nil {
+		t.Fatal("twice was not specialized for Square")
+	}
+	if !callsFunction(twice, "Square::double_area") {
+		t.Error("twice$Square does not call Square::double_area")
+	}
+}
+
+func TestLowerAssociatedFunctionPaths(t *testing.T) {
+	src := `package main;
+
+trait Default {
+    fn default() -> Self;
+}
+
+struct Point { x: int, y: int }
+
+impl Default for Point {
+    fn default() -> Point { return Point { x: 1, y: 2 }; }
+}
+
+enum Color { Red, Green }
+
+impl Color {
+    fn from_int(n: int) -> Color {
+        if n == 0 { return Color::Red; }
+        return Color::Green;
+    }
+}
+
+fn make[T: Default]() -> T {
+    return T::default();
+}
+
+fn make_inferred[T: Default]() -> T {
+    let t: T = Default::default();
+    return t;
+}
+
+fn main() {
+    let p: Point = Default::default();
+    let c = Color::from_int(1);
+    let q: Point = make[Point]();
+    let r: Point = make_inferred[Point]();
+}
+`
+	module, _ := lowerModule(t, src)
+
+	main := findFunction(module, "main")
+	for _, name := range []string{"Point::default", "Color::from_int"} {
+		if !callsFunction(main, name) {
+			t.Errorf("main does not call %s", name)
+		}
+	}
+	// Self is the type argument in each specialization
+	for _, name := range []string{"make$Point", "make_inferred$Point"} {
+		fn := findFunction(module, name)
+		if fn == nil {
+			t.Fatalf("no %s in module", name)
+		}
+		if !callsFunction(fn, "Point::default") {
+			t.Errorf("%s does not call Point::default", name)
+		}
+	}
+}
+
+// callsFunction reports whether fn contains a direct call to name
+func callsFunction(fn *Function, name string) bool {
+	for _, block := range fn.Blocks {
+		for _, stmt := range block.Statements {
+			if call, ok := stmt.(*Call); ok && call.Func == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+)
+
+// attachComments records the comments the lexer skipped in file.Comments
+// and attaches each to the declaration or statement it belongs to.
+//
+// A comment belongs to the innermost file, block, declaration or statement
+// containing it. Among that node's own declarations and statements, it
+// trails the one ending just before it on the same line, or else leads the
+// next one. Comments with neither, such as one before the `}` of a block,
+// are only kept in file.Comments.
+func (p *Parser) attachComments(file *ast.File) {
+	if p.lx == nil || len(p.lx.Comments) == 0 {
+		return
+	}
+
+	for _, tok := range p.lx.Comments {
+		file.Comments = append(file.Comments, ast.NewComment(tok.Raw, tok.Span))
+	}
+
+	p.attachCommentsIn(file, file.Comments)
+}
+
+// attachCommentsIn attaches comments, which all lie within container, to
+// the declarations and statements directly inside it, and hands those lying
+// within nested containers down to them.
+func (p *Parser) attachCommentsIn(container ast.Node, comments []*ast.Comment) {
+	var children []ast.Commented
+	var inner []ast.Node
+	ast.Walk(container, func(n ast.Node) bool {
+		if n == container || n == nil {
+			return true
+		}
+		if c, ok := n.(ast.Commented); ok {
+			children = append(children, c)
+		}
+		if isCommentContainer(n) {
+			inner = append(inner, n)
+			return false
+		}
+		return true
+	})
+
+	nested := make(map[ast.Node][]*ast.Comment)
+	for _, comment := range comments {
+		if in := enclosing(inner, comment); in != nil {
+			nested[in] = append(nested[in], comment)
+			continue
+		}
+
+		var prev, next ast.Commented
+		for _, child := range children {
+			switch {
+			case child.Span().End <= comment.Span().Start:
+				prev = child
+			case next == nil && child.Span().Start >= comment.Span().End:
+				next = child
+			}
+		}
+
+		switch {
+		case prev != nil && prev.Attached().Trailing == nil && !p.newlineBetween(prev.Span().End, comment.Span().Start):
+			prev.Attached().Trailing = comment
+		case next != nil:
+			next.Attached().Leading = append(next.Attached().Leading, comment)
+		}
+	}
+
+	for _, in := range inner {
+		if cs := nested[in]; len(cs) > 0 {
+			p.attachCommentsIn(in, cs)
+		}
+	}
+}
+
+// isCommentContainer reports whether comments within n are attached
+// relative to the declarations and statements inside n.
+func isCommentContainer(n ast.Node) bool {
+	switch n.(type) {
+	case ast.Commented, *ast.BlockExpr, *ast.File:
+		return true
+	}
+	return false
+}
+
+// enclosing returns the node of nodes whose span contains comment, or nil.
+func enclosing(nodes []ast.Node, comment *ast.Comment) ast.Node {
+	for _, n := range nodes {
+		if n.Span().Start <= comment.Span().Start && comment.Span().End <= n.Span().End {
+			return n
+		}
+	}
+	return nil
+}
+
+// newlineBetween reports whether the source has a line break between the
+// character offsets start and end.
+func (p *Parser) newlineBetween(start, end int) bool {
+	for i := max(0, start); i < end && i < len(p.src); i++ {
+		if p.src[i] == '\n' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+)
+
+func commentTexts(comments []*ast.Comment) []string {
+	var texts []string
+	for _, c := range comments {
+		texts = append(texts, c.Text)
+	}
+	return texts
+}
+
+func TestParseFileAttachesComments(t *testing.T) {
+	const src = `// Package doc.
+package foo;
+
+// Point is a point.
+/* Two lines
+   of doc. */
+struct Point { x: int, y: int } // trailing struct
+
+fn main() {
+	// leading let
+	let x = 1; // trailing let
+	let f = |a: int| {
+		// inside closure
+		return a;
+	};
+	// dangling at end of block
+}
+`
+
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	if len(file.Comments) != 8 {
+		t.Fatalf("expected 8 comments in file, got %d: %q", len(file.Comments), commentTexts(file.Comments))
+	}
+
+	if got := commentTexts(file.Package.Leading); len(got) != 1 || got[0] != "// Package doc." {
+		t.Fatalf("unexpected package comments: %q", got)
+	}
+
+	st := file.Decls[0].(*ast.StructDecl)
+	if got := commentTexts(st.Leading); len(got) != 2 || got[0] != "// Point is a point." || got[1] != "/* Two lines\n   of doc. */" {
+		t.Fatalf("unexpected struct leading comments: %q", got)
+	}
+	if st.Trailing == nil || st.Trailing.Text != "// trailing struct" {
+		t.Fatalf("unexpected struct trailing comment: %#v", st.Trailing)
+	}
+
+	fn := file.Decls[1].(*ast.FnDecl)
+	if len(fn.Leading) != 0 || fn.Trailing != nil {
+		t.Fatalf("expected no comments on main, got %q / %#v", commentTexts(fn.Leading), fn.Trailing)
+	}
+
+	let := fn.Body.Stmts[0].(*ast.LetStmt)
+	if got := commentTexts(let.Leading); len(got) != 1 || got[0] != "// leading let" {
+		t.Fatalf("unexpected let leading comments: %q", got)
+	}
+	if let.Trailing == nil || let.Trailing.Text != "// trailing let" {
+		t.Fatalf("unexpected let trailing comment: %#v", let.Trailing)
+	}
+
+	closure := fn.Body.Stmts[1].(*ast.LetStmt).Value.(*ast.FunctionLiteral)
+	ret := closure.Body.Stmts[0].(*ast.ReturnStmt)
+	if got := commentTexts(ret.Leading); len(got) != 1 || got[0] != "// inside closure" {
+		t.Fatalf("unexpected return leading comments: %q", got)
+	}
+	if got := commentTexts(fn.Body.Stmts[1].(*ast.LetStmt).Leading); len(got) != 0 {
+		t.Fatalf("closure comment leaked to its let: %q", got)
+	}
+}
@@ -64,6 +64,33 @@ fn main() {
 	}
 }
 
+func TestParseSpawnExpr(t *testing.T) {
+	const src = `
+package foo;
+
+fn main() {
+	let h = spawn worker(1, 2);
+}
+`
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	fn := file.Decls[0].(*ast.FnDecl)
+	letStmt, ok := fn.Body.Stmts[0].(*ast.LetStmt)
+	if !ok {
+		t.Fatalf("expected *ast.LetStmt, got %T", fn.Body.Stmts[0])
+	}
+
+	spawnExpr, ok := letStmt.Value.(*ast.SpawnExpr)
+	if !ok {
+		t.Fatalf("expected *ast.SpawnExpr, got %T", letStmt.Value)
+	}
+
+	if len(spawnExpr.Call.Args) != 2 {
+		t.Fatalf("expected 2 spawn arguments, got %d", len(spawnExpr.Call.Args))
+	}
+}
+
 func TestParseSelectStmt(t *testing.T) {
 	const src = `
 package foo;
@@ -124,3 +151,44 @@ fn main() {
 		t.Fatal("expected case 2 to have type annotation")
 	}
 }
+
+func TestParseSelectDefaultAndAfter(t *testing.T) {
+	const src = `
+package foo;
+
+fn main() {
+	select {
+		let msg = <-ch => {
+		},
+		after(100) => {
+		},
+		default => {
+		}
+	}
+}
+`
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	fn := file.Decls[0].(*ast.FnDecl)
+	selectStmt := fn.Body.Stmts[0].(*ast.SelectStmt)
+	if len(selectStmt.Cases) != 3 {
+		t.Fatalf("expected 3 cases, got %d", len(selectStmt.Cases))
+	}
+
+	after := selectStmt.Cases[1]
+	if after.Comm != nil {
+		t.Fatalf("expected after case to have no communication, got %T", after.Comm)
+	}
+	lit, ok := after.After.(*ast.IntegerLit)
+	if !ok || lit.Text != "100" {
+		t.Fatalf("expected after(100) timeout, got %#v", after.After)
+	}
+
+	if !selectStmt.Cases[2].IsDefault() {
+		t.Fatal("expected case 2 to be the default case")
+	}
+	if selectStmt.Cases[0].IsDefault() {
+		t.Fatal("expected case 0 not to be a default case")
+	}
+}
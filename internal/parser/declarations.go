@@ -8,6 +8,12 @@ import (
 func (p *Parser) parseModDecl() *ast.ModDecl {
 	start := p.curTok.Span
 
+	isPub := false
+	if p.curTok.Type == lexer.PUB {
+		isPub = true
+		p.nextToken()
+	}
+
 	if p.curTok.Type != lexer.MOD {
 		p.reportExpectedError("'mod' keyword", p.curTok, p.curTok.Span)
 		return nil
@@ -38,13 +44,13 @@ func (p *Parser) parseModDecl() *ast.ModDecl {
 
 		// Parse items until '}'
 		for p.curTok.Type != lexer.RBRACE && p.curTok.Type != lexer.EOF {
-			switch p.curTok.Type {
-			case lexer.USE:
+			switch {
+			case p.curTok.Type == lexer.USE:
 				useDecl := p.parseUseDecl()
 				if useDecl != nil {
 					body.Uses = append(body.Uses, useDecl)
 				}
-			case lexer.MOD:
+			case p.curTok.Type == lexer.MOD || p.curTok.Type == lexer.PUB && p.peekTok.Type == lexer.MOD:
 				modDecl := p.parseModDecl()
 				if modDecl != nil {
 					body.Mods = append(body.Mods, modDecl)
@@ -69,19 +75,24 @@ func (p *Parser) parseModDecl() *ast.ModDecl {
 		body.SetSpan(mergeSpan(body.Span(), endSpan))
 		p.nextToken() // consume '}'
 
-		return ast.NewModDecl(name, body, mergeSpan(start, endSpan))
+		decl := ast.NewModDecl(name, body, mergeSpan(start, endSpan))
+		decl.Pub = isPub
+		return decl
 	}
 
 	// External module: mod name;
 	if p.curTok.Type != lexer.SEMICOLON {
 		p.reportError("expected ';' or '{' after module name", p.curTok.Span)
-		return ast.NewModDecl(name, nil, mergeSpan(start, nameTok.Span))
+		decl := ast.NewModDecl(name, nil, mergeSpan(start, nameTok.Span))
+		decl.Pub = isPub
+		return decl
 	}
 
 	// Advance past the semicolon
 	p.nextToken()
 
 	decl := ast.NewModDecl(name, nil, mergeSpan(start, nameTok.Span))
+	decl.Pub = isPub
 	return decl
 }
 
@@ -170,6 +181,8 @@ func (p *Parser) parseDecl() ast.Decl {
 		switch p.peekTok.Type {
 		case lexer.FN, lexer.UNSAFE:
 			return p.parseFnDecl()
+		case lexer.EXTERN:
+			return p.parseExternFnDecl()
 		case lexer.STRUCT:
 			return p.parseStructDecl()
 		case lexer.ENUM:
@@ -178,6 +191,8 @@ func (p *Parser) parseDecl() ast.Decl {
 			return p.parseTypeAliasDecl()
 		case lexer.CONST:
 			return p.parseConstDecl()
+		case lexer.STATIC:
+			return p.parseStaticDecl()
 		case lexer.TRAIT:
 			return p.parseTraitDecl()
 		default:
@@ -192,6 +207,8 @@ func (p *Parser) parseDecl() ast.Decl {
 		}
 		p.reportError("expected 'fn' after 'unsafe'", p.peekTok.Span)
 		return nil
+	case lexer.EXTERN:
+		return p.parseExternFnDecl()
 	case lexer.STRUCT:
 		return p.parseStructDecl()
 	case lexer.ENUM:
@@ -200,10 +217,14 @@ func (p *Parser) parseDecl() ast.Decl {
 		return p.parseTypeAliasDecl()
 	case lexer.CONST:
 		return p.parseConstDecl()
+	case lexer.STATIC:
+		return p.parseStaticDecl()
 	case lexer.TRAIT:
 		return p.parseTraitDecl()
 	case lexer.IMPL:
 		return p.parseImplDecl()
+	case lexer.HASH:
+		return p.parseAttributedDecl()
 	default:
 		lexeme := p.curTok.Literal
 		if lexeme == "" {
@@ -215,6 +236,34 @@ func (p *Parser) parseDecl() ast.Decl {
 	return nil
 }
 
+// parseAttributedDecl parses `#[name] ... decl`. Functions, structs and
+// enums take attributes; on any other declaration they are reported and
+// dropped.
+func (p *Parser) parseAttributedDecl() ast.Decl {
+	attrs := p.parseAttributes()
+	decl := p.parseDecl()
+	if decl == nil || len(attrs) == 0 {
+		return decl
+	}
+
+	span := mergeSpan(attrs[0].Span(), decl.Span())
+	switch d := decl.(type) {
+	case *ast.FnDecl:
+		d.Attrs = attrs
+		d.SetSpan(span)
+	case *ast.StructDecl:
+		d.Attrs = attrs
+		d.SetSpan(span)
+	case *ast.EnumDecl:
+		d.Attrs = attrs
+		d.SetSpan(span)
+	default:
+		p.reportErrorWithHelp("attributes are only allowed on functions, structs and enums", attrs[0].Span(),
+			"remove the attribute, or move it to a function, struct or enum")
+	}
+	return decl
+}
+
 func (p *Parser) parsePackageDecl() *ast.PackageDecl {
 	start := p.curTok.Span
 
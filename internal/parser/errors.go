@@ -7,6 +7,46 @@ import (
 	"github.com/malphas-lang/malphas-lang/internal/lexer"
 )
 
+// Diagnostic converts the parse error into the diagnostic form shared by the
+// CLI formatter and the language server.
+func (e ParseError) Diagnostic() diag.Diagnostic {
+	span := toDiagSpan(e.Span)
+
+	code := e.Code
+	if code == "" {
+		code = diag.Code("PARSE_ERROR")
+	}
+
+	d := diag.Diagnostic{
+		Stage:    diag.StageParser,
+		Severity: e.Severity,
+		Code:     code,
+		Message:  e.Message,
+		Span:     span,
+		Help:     e.Help,
+		Notes:    e.Notes,
+	}
+	if span.IsValid() {
+		d = d.WithPrimarySpan(span, e.PrimaryLabel)
+	}
+	for _, sec := range e.SecondarySpans {
+		if secSpan := toDiagSpan(sec.Span); secSpan.IsValid() {
+			d = d.WithSecondarySpan(secSpan, sec.Label)
+		}
+	}
+	return d
+}
+
+func toDiagSpan(span lexer.Span) diag.Span {
+	return diag.Span{
+		Filename: span.Filename,
+		Line:     span.Line,
+		Column:   span.Column,
+		Start:    span.Start,
+		End:      span.End,
+	}
+}
+
 // emitParseDiagnostic records a recoverable diagnostic without aborting parsing. All
 // call sites must supply the best-effort span available at the failure site so
 // assertions like TestParseLetStmtWithPrefixExprErrors can validate message and
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+
 	"github.com/malphas-lang/malphas-lang/internal/ast"
 	"github.com/malphas-lang/malphas-lang/internal/lexer"
 )
@@ -106,6 +108,49 @@ func (p *Parser) parseFnDecl() ast.Decl {
 	return ast.NewFnDecl(isPub, isUnsafe, name, typeParams, params, returnType, effects, whereClause, body, span)
 }
 
+// parseExternFnDecl parses `extern "C" fn name(params) -> ret;`, the
+// signature of a function implemented in C. The ABI string may be left out.
+func (p *Parser) parseExternFnDecl() ast.Decl {
+	start := p.curTok.Span
+	isPub := false
+	if p.curTok.Type == lexer.PUB {
+		isPub = true
+		p.nextToken() // consume 'pub'
+	}
+
+	abi := "C"
+	if p.peekTok.Type == lexer.STRING {
+		p.nextToken()
+		abi = p.curTok.Value
+		if abi != "C" {
+			p.reportErrorWithHelp(fmt.Sprintf("unsupported ABI %q", abi), p.curTok.Span,
+				"the only supported ABI is \"C\":\n  extern \"C\" fn puts(s: *u8) -> i32;")
+		}
+	}
+
+	if !p.expect(lexer.FN) {
+		return nil
+	}
+
+	_, isUnsafe, name, typeParams, params, returnType, effects, whereClause, _ := p.parseFnHeader()
+	if name == nil {
+		return nil
+	}
+
+	if p.peekTok.Type != lexer.SEMICOLON {
+		p.reportErrorWithHelp("expected ';' after extern function signature", p.peekTok.Span,
+			"extern functions are implemented outside Malphas and have no body")
+		return nil
+	}
+	p.nextToken()
+	span := mergeSpan(start, p.curTok.Span)
+	p.nextToken()
+
+	decl := ast.NewFnDecl(isPub, isUnsafe, name, typeParams, params, returnType, effects, whereClause, nil, span)
+	decl.ABI = abi
+	return decl
+}
+
 func (p *Parser) parseTraitMethod() *ast.FnDecl {
 	isPub, isUnsafe, name, typeParams, params, returnType, effects, whereClause, headerSpan := p.parseFnHeader()
 	if name == nil {
@@ -0,0 +1,80 @@
+package parser_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+// addSeedCorpus seeds f with the parser's testdata and the programs under
+// the repository's tests directory
+func addSeedCorpus(f *testing.F) {
+	f.Helper()
+	for _, pattern := range []string{
+		filepath.Join("testdata", "*.mlp"),
+		filepath.Join("..", "..", "tests", "*", "*.mal"),
+	} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(string(data))
+		}
+	}
+	f.Add("")
+	f.Add("fn main() { let x = [1, 2; }")
+	f.Add("struct S[T] where T: { x: T, }")
+}
+
+// checkSpan reports a span that does not lie within a source of n runes
+func checkSpan(t *testing.T, what string, span lexer.Span, n int) {
+	t.Helper()
+	if span == (lexer.Span{}) {
+		// Nodes synthesized while recovering from errors have no position
+		return
+	}
+	if span.Start < 0 || span.End < span.Start || span.End > n {
+		t.Fatalf("%s has span [%d, %d) outside the %d-rune source", what, span.Start, span.End, n)
+	}
+	if span.Line < 1 || span.Column < 1 {
+		t.Fatalf("%s has position %d:%d", what, span.Line, span.Column)
+	}
+}
+
+// FuzzParseFile checks that parsing never panics and that the spans of the
+// nodes and errors it produces lie within the source.
+func FuzzParseFile(f *testing.F) {
+	addSeedCorpus(f)
+	f.Fuzz(func(t *testing.T, src string) {
+		if !utf8.ValidString(src) {
+			return
+		}
+		n := utf8.RuneCountInString(src)
+		p := parser.New(src)
+		file := p.ParseFile()
+		for _, err := range p.Errors() {
+			checkSpan(t, "error "+err.Message, err.Span, n)
+		}
+		if file == nil {
+			return
+		}
+		ast.Walk(file, func(node ast.Node) bool {
+			if v := reflect.ValueOf(node); v.Kind() == reflect.Pointer && v.IsNil() {
+				return false
+			}
+			checkSpan(t, reflect.TypeOf(node).String(), node.Span(), n)
+			return true
+		})
+	})
+}
@@ -66,7 +66,7 @@ func (p *Parser) parseBlockExpr() *ast.BlockExpr {
 	p.nextToken()
 
 	for p.curTok.Type != lexer.RBRACE && p.curTok.Type != lexer.EOF {
-		prevTok := p.curTok
+		prevTok, level := p.curTok, p.nesting
 		stmt := p.parseStmt()
 		if stmt != nil {
 			block.Stmts = append(block.Stmts, stmt)
@@ -83,7 +83,7 @@ func (p *Parser) parseBlockExpr() *ast.BlockExpr {
 
 			if p.peekTok.Type != lexer.RBRACE {
 				p.reportError("expected '}' after block tail expression", p.peekTok.Span)
-				p.recoverStatement(prevTok)
+				p.recoverStatement(prevTok, level)
 				continue
 			}
 
@@ -95,7 +95,7 @@ func (p *Parser) parseBlockExpr() *ast.BlockExpr {
 			break
 		}
 
-		p.recoverStatement(prevTok)
+		p.recoverStatement(prevTok, level)
 	}
 
 	if p.curTok.Type != lexer.RBRACE {
@@ -158,6 +158,9 @@ func (p *Parser) parseBlockLiteral() ast.Expr {
 	p.pendingTail = prevTail
 	p.allowBlockTail = prevAllow
 
+	if block == nil {
+		return nil
+	}
 	return block
 }
 
@@ -16,21 +16,34 @@ func (p *Parser) parseParamList() ([]*ast.Param, bool) {
 	}
 
 	p.nextToken()
-	param := p.parseParam()
-	if param == nil {
-		return nil, false
-	}
-	params = append(params, param)
+	recovered := false
+	for {
+		param := p.parseParam()
+		if param == nil {
+			// A declaration keyword means the list runs into the next
+			// declaration, so the whole declaration is dropped
+			if isTopLevelDeclStart(p.curTok.Type) {
+				return nil, false
+			}
+			p.recoverParam()
+			recovered = true
+		} else {
+			params = append(params, param)
+		}
 
-	for p.peekTok.Type == lexer.COMMA {
+		if p.peekTok.Type != lexer.COMMA {
+			break
+		}
 		p.nextToken() // move to comma
 		p.nextToken() // move to next parameter start
+	}
 
-		param = p.parseParam()
-		if param == nil {
-			return nil, false
+	// Without its `)`, the list ends where the rest of the signature starts
+	if p.peekTok.Type == lexer.ARROW || p.peekTok.Type == lexer.LBRACE {
+		if !recovered {
+			p.reportError("expected ')' to close parameter list", p.peekTok.Span)
 		}
-		params = append(params, param)
+		return params, true
 	}
 
 	if !p.expect(lexer.RPAREN) {
@@ -40,6 +53,31 @@ func (p *Parser) parseParamList() ([]*ast.Param, bool) {
 	return params, true
 }
 
+// recoverParam skips the rest of a malformed parameter, up to the token
+// before the `,` or `)` ending it, or before the `->` or `{` following a
+// parameter list that is not closed.
+func (p *Parser) recoverParam() {
+	depth := 0
+	for p.peekTok.Type != lexer.EOF {
+		switch p.peekTok.Type {
+		case lexer.LPAREN, lexer.LBRACKET:
+			depth++
+		case lexer.RBRACKET:
+			depth = max(0, depth-1)
+		case lexer.RPAREN:
+			if depth == 0 {
+				return
+			}
+			depth--
+		case lexer.COMMA, lexer.ARROW, lexer.LBRACE:
+			if depth == 0 {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseParam() *ast.Param {
 	start := p.curTok.Span
 
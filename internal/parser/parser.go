@@ -96,6 +96,7 @@ type ParseError struct {
 //     constructor must participate in this discipline.
 type Parser struct {
 	lx      *lexer.Lexer
+	src     []rune // the input, for placing comments
 	curTok  lexer.Token
 	peekTok lexer.Token
 
@@ -110,6 +111,11 @@ type Parser struct {
 	allowBlockTail bool
 
 	tokenBuffer []lexer.Token
+
+	// nesting counts the braces opened and not yet closed before curTok.
+	// Error recovery compares it with its value where the malformed
+	// construct started, to tell which braces the construct itself opened.
+	nesting int
 }
 
 // New returns a parser initialised with the provided source input.
@@ -121,6 +127,7 @@ func New(input string, opts ...Option) *Parser {
 
 	p := &Parser{
 		lx:          lexer.New(input),
+		src:         []rune(input),
 		prefixFns:   make(map[lexer.TokenType]prefixParseFn),
 		infixFns:    make(map[lexer.TokenType]infixParseFn),
 		filename:    cfg.filename,
@@ -160,6 +167,7 @@ func (p *Parser) registerParsers() {
 	p.registerPrefix(lexer.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(lexer.MATCH, p.parseMatchExpr)
 	p.registerPrefix(lexer.UNSAFE, p.parseUnsafeBlock)
+	p.registerPrefix(lexer.SPAWN, p.parseSpawnExpr)
 	p.registerPrefix(lexer.DOT_DOT, p.parseRangePrefix)
 	p.registerPrefix(lexer.PIPE, p.parseFunctionLiteralExpr)
 	// Also register OR as prefix for function literals when followed by {
@@ -218,7 +226,7 @@ func (p *Parser) ParseFile() *ast.File {
 	// No default package assignment - files are modules by default (Rust-like)
 
 	// Parse mod declarations
-	for p.curTok.Type == lexer.MOD {
+	for p.curTok.Type == lexer.MOD || p.curTok.Type == lexer.PUB && p.peekTok.Type == lexer.MOD {
 		modDecl := p.parseModDecl()
 		if modDecl != nil {
 			file.Mods = append(file.Mods, modDecl)
@@ -236,7 +244,7 @@ func (p *Parser) ParseFile() *ast.File {
 	}
 
 	for p.curTok.Type != lexer.EOF {
-		prevTok := p.curTok
+		prevTok, level := p.curTok, p.nesting
 		decl := p.parseDecl()
 		if decl != nil {
 			file.Decls = append(file.Decls, decl)
@@ -248,10 +256,11 @@ func (p *Parser) ParseFile() *ast.File {
 			break
 		}
 
-		p.recoverDecl(prevTok)
+		p.recoverDecl(prevTok, level)
 	}
 
 	file.SetSpan(mergeSpan(file.Span(), p.curTok.Span))
+	p.attachComments(file)
 
 	return file
 }
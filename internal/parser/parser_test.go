@@ -644,6 +644,39 @@ fn f() -> i32 {
 	}
 }
 
+func TestParseBlockStatementWithoutSemicolon(t *testing.T) {
+	const src = `
+package foo;
+
+fn f() {
+	{
+		let x = 1;
+	}
+	let y = { 2 };
+}
+`
+
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	fn := file.Decls[0].(*ast.FnDecl)
+	if len(fn.Body.Stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(fn.Body.Stmts))
+	}
+	stmt, ok := fn.Body.Stmts[0].(*ast.ExprStmt)
+	if !ok {
+		t.Fatalf("expected statement type *ast.ExprStmt, got %T", fn.Body.Stmts[0])
+	}
+	if _, ok := stmt.Expr.(*ast.BlockExpr); !ok {
+		t.Fatalf("expected a block statement, got %T", stmt.Expr)
+	}
+	if let, ok := fn.Body.Stmts[1].(*ast.LetStmt); !ok {
+		t.Fatalf("expected statement type *ast.LetStmt, got %T", fn.Body.Stmts[1])
+	} else if _, ok := let.Value.(*ast.BlockExpr); !ok {
+		t.Fatalf("expected let value type *ast.BlockExpr, got %T", let.Value)
+	}
+}
+
 func TestParseLetStmtWithTypeAnnotation(t *testing.T) {
 	const src = `
 package foo;
@@ -1424,6 +1457,37 @@ const MAX: i32 = 10;
 	}
 }
 
+func TestParseStaticDecl(t *testing.T) {
+	const src = `
+package foo;
+
+static LIMIT: int = 10;
+pub static mut COUNT: int = 0;
+`
+
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	if len(file.Decls) != 2 {
+		t.Fatalf("expected 2 decls, got %d", len(file.Decls))
+	}
+	for i, want := range []struct {
+		name         string
+		pub, mutable bool
+	}{{"LIMIT", false, false}, {"COUNT", true, true}} {
+		decl, ok := file.Decls[i].(*ast.StaticDecl)
+		if !ok {
+			t.Fatalf("expected *ast.StaticDecl, got %T", file.Decls[i])
+		}
+		if decl.Name.Name != want.name || decl.Pub != want.pub || decl.Mutable != want.mutable {
+			t.Errorf("decl %d = %s (pub %v, mut %v), want %s (pub %v, mut %v)", i, decl.Name.Name, decl.Pub, decl.Mutable, want.name, want.pub, want.mutable)
+		}
+		if decl.Type == nil || decl.Value == nil {
+			t.Errorf("decl %d has no type or value", i)
+		}
+	}
+}
+
 func TestParseTraitDecl(t *testing.T) {
 	const src = `
 package foo;
@@ -2180,6 +2244,124 @@ fn ok() {}
 	}
 }
 
+func TestParseFileRecoveryQuality(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		wantErrs  int
+		wantDecls []string
+	}{
+		{
+			name: "bad expression keeps following statements",
+			src: `
+package foo;
+
+fn one() {
+	let x = foo([1, 2 +], 3);
+	let y = 2;
+	let z = 3;
+}
+
+fn two() {}
+`,
+			wantErrs:  1,
+			wantDecls: []string{"fn one: 2 stmts", "fn two: 0 stmts"},
+		},
+		{
+			name: "error inside match arm stays in its statement",
+			src: `
+package foo;
+
+fn one() {
+	let v = match x { 1 => , _ => 2 };
+	println(v);
+}
+
+fn two() {}
+`,
+			wantErrs:  1,
+			wantDecls: []string{"fn one: 1 stmts", "fn two: 0 stmts"},
+		},
+		{
+			name: "bad struct field keeps other fields",
+			src: `
+package foo;
+
+struct P { x: int, y int, z: int }
+
+fn one() {}
+`,
+			wantErrs:  1,
+			wantDecls: []string{"struct P: 2 fields", "fn one: 0 stmts"},
+		},
+		{
+			name: "bad enum variant keeps other variants",
+			src: `
+package foo;
+
+enum E { A, B(), C(int) }
+
+fn one() {}
+`,
+			wantErrs:  1,
+			wantDecls: []string{"enum E: 2 variants", "fn one: 0 stmts"},
+		},
+		{
+			name: "bad parameter keeps function and body",
+			src: `
+package foo;
+
+fn one(a: int, b, c: int) -> int {
+	let x = a;
+	return x;
+}
+`,
+			wantErrs:  1,
+			wantDecls: []string{"fn one: 2 stmts"},
+		},
+		{
+			name: "unclosed struct keeps following declaration",
+			src: `
+package foo;
+
+struct P { x: int,
+
+fn one() {}
+`,
+			wantErrs:  1,
+			wantDecls: []string{"struct P: 1 fields", "fn one: 0 stmts"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			file, errs := parseFile(t, tc.src)
+
+			if len(errs) != tc.wantErrs {
+				t.Fatalf("expected %d errors, got %d: %v", tc.wantErrs, len(errs), errs)
+			}
+
+			var got []string
+			for _, decl := range file.Decls {
+				switch d := decl.(type) {
+				case *ast.FnDecl:
+					got = append(got, fmt.Sprintf("fn %s: %d stmts", d.Name.Name, len(d.Body.Stmts)))
+				case *ast.StructDecl:
+					got = append(got, fmt.Sprintf("struct %s: %d fields", d.Name.Name, len(d.Fields)))
+				case *ast.EnumDecl:
+					got = append(got, fmt.Sprintf("enum %s: %d variants", d.Name.Name, len(d.Variants)))
+				default:
+					got = append(got, fmt.Sprintf("%T", d))
+				}
+			}
+
+			if !reflect.DeepEqual(got, tc.wantDecls) {
+				t.Fatalf("unexpected decls after recovery:\n got  %q\n want %q", got, tc.wantDecls)
+			}
+		})
+	}
+}
+
 func TestParseErrorIncludesFilenameAndSeverity(t *testing.T) {
 	const src = `
 package;
@@ -3639,3 +3821,242 @@ fn main() {
 		})
 	}
 }
+
+func TestParseTailCallAttribute(t *testing.T) {
+	src := `package main;
+
+fn f(n: int) -> int {
+	#[tailcall] return f(n - 1);
+}
+`
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	fn := file.Decls[0].(*ast.FnDecl)
+	ret, ok := fn.Body.Stmts[0].(*ast.ReturnStmt)
+	if !ok {
+		t.Fatalf("expected a return statement, got %T", fn.Body.Stmts[0])
+	}
+	if !ast.HasAttribute(ret.Attrs, "tailcall") {
+		t.Fatalf("expected #[tailcall] on the return statement")
+	}
+	if ret.Span().Column != 2 {
+		t.Errorf("expected the statement span to start at the attribute, got column %d", ret.Span().Column)
+	}
+}
+
+func TestParseMisplacedAttributes(t *testing.T) {
+	src := `package main;
+
+fn f() {
+	#[inline] return;
+	#[tailcall] let x = 1;
+}
+`
+	// #[inline] on a return is the checker's to reject
+	_, errs := parseFile(t, src)
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if !strings.Contains(errs[0].Message, "only allowed on return statements") {
+		t.Errorf("unexpected error: %s", errs[0].Message)
+	}
+}
+
+func TestParseDeprecatedAttribute(t *testing.T) {
+	const src = `
+package foo;
+
+#[deprecated]
+fn old() {}
+
+#[deprecated]
+struct Point { x: int }
+`
+
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	if len(file.Decls) != 2 {
+		t.Fatalf("expected 2 decls, got %d", len(file.Decls))
+	}
+
+	fn, ok := file.Decls[0].(*ast.FnDecl)
+	if !ok || len(fn.Attrs) != 1 || fn.Attrs[0].Name.Name != "deprecated" {
+		t.Fatalf("expected fn with #[deprecated], got %#v", file.Decls[0])
+	}
+	if fn.Span().Line != 4 {
+		t.Errorf("expected fn span to start at the attribute, got line %d", fn.Span().Line)
+	}
+
+	st, ok := file.Decls[1].(*ast.StructDecl)
+	if !ok || len(st.Attrs) != 1 {
+		t.Fatalf("expected struct with #[deprecated], got %#v", file.Decls[1])
+	}
+}
+
+func TestParseAttributeArgs(t *testing.T) {
+	const src = `
+package foo;
+
+#[inline(always)]
+#[deprecated("use g", 2)]
+fn f() {}
+
+#[test()]
+fn g() {}
+`
+
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	f := file.Decls[0].(*ast.FnDecl)
+	if len(f.Attrs) != 2 {
+		t.Fatalf("expected 2 attributes, got %d", len(f.Attrs))
+	}
+	inline := ast.FindAttribute(f.Attrs, "inline")
+	if inline == nil || len(inline.Args) != 1 {
+		t.Fatalf("expected #[inline] with one argument, got %#v", inline)
+	}
+	if ident, ok := inline.Args[0].(*ast.Ident); !ok || ident.Name != "always" {
+		t.Errorf("expected argument `always`, got %#v", inline.Args[0])
+	}
+	deprecated := ast.FindAttribute(f.Attrs, "deprecated")
+	if deprecated == nil || len(deprecated.Args) != 2 {
+		t.Fatalf("expected #[deprecated] with two arguments, got %#v", deprecated)
+	}
+	if msg, ok := deprecated.Args[0].(*ast.StringLit); !ok || msg.Value != "use g" {
+		t.Errorf("expected message argument, got %#v", deprecated.Args[0])
+	}
+
+	test := file.Decls[1].(*ast.FnDecl).Attrs[0]
+	if test.Args == nil || len(test.Args) != 0 {
+		t.Errorf("expected empty, non-nil arguments for #[test()], got %#v", test.Args)
+	}
+}
+
+func TestParseAttributeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "unclosed arguments",
+			src:  "package foo;\n#[deprecated(\"old\"]\nfn f() {}\n",
+			want: "expected",
+		},
+		{
+			name: "attribute on const",
+			src:  "package foo;\n#[deprecated]\nconst X: int = 1;\n",
+			want: "attributes are only allowed on functions, structs and enums",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := parseFile(t, tt.src)
+			if len(errs) == 0 {
+				t.Fatalf("expected a parse error")
+			}
+			if !strings.Contains(errs[0].Message, tt.want) {
+				t.Errorf("error = %q, want it to mention %q", errs[0].Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExternFnDecl(t *testing.T) {
+	src := `package main;
+
+extern "C" fn abs(x: i32) -> i32;
+pub extern fn puts(s: *u8) -> i32;
+`
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	if len(file.Decls) != 2 {
+		t.Fatalf("expected 2 decls, got %d", len(file.Decls))
+	}
+	for i, name := range []string{"abs", "puts"} {
+		fn, ok := file.Decls[i].(*ast.FnDecl)
+		if !ok {
+			t.Fatalf("decl %d: expected *ast.FnDecl, got %T", i, file.Decls[i])
+		}
+		if fn.Name.Name != name || fn.ABI != "C" || fn.Body != nil {
+			t.Errorf("decl %d: got %s with ABI %q and body %v, want %s with ABI \"C\" and no body", i, fn.Name.Name, fn.ABI, fn.Body, name)
+		}
+	}
+	if !file.Decls[1].(*ast.FnDecl).Pub {
+		t.Errorf("expected puts to be public")
+	}
+}
+
+func TestParseExternFnDeclErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "unsupported ABI",
+			src:  "package main;\nextern \"stdcall\" fn f();\n",
+			want: "unsupported ABI",
+		},
+		{
+			name: "body",
+			src:  "package main;\nextern \"C\" fn f() {}\n",
+			want: "expected ';' after extern function signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs := parseFile(t, tt.src)
+			if len(errs) == 0 {
+				t.Fatalf("expected an error mentioning %q", tt.want)
+			}
+			if !strings.Contains(errs[0].Message, tt.want) {
+				t.Errorf("error = %q, want it to mention %q", errs[0].Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePubModDecl(t *testing.T) {
+	src := `package main;
+
+pub mod geo {
+    pub mod shapes;
+    mod util;
+}
+mod io;
+
+fn area(s: geo::shapes::Square) {}
+`
+	file, errs := parseFile(t, src)
+	assertNoErrors(t, errs)
+
+	if len(file.Mods) != 2 {
+		t.Fatalf("expected 2 mods, got %d", len(file.Mods))
+	}
+	geo, io := file.Mods[0], file.Mods[1]
+	if !geo.Pub || io.Pub {
+		t.Errorf("got geo.Pub = %v and io.Pub = %v, want true and false", geo.Pub, io.Pub)
+	}
+	if geo.Body == nil || len(geo.Body.Mods) != 2 {
+		t.Fatalf("expected geo to declare 2 mods, got %+v", geo.Body)
+	}
+	if shapes, util := geo.Body.Mods[0], geo.Body.Mods[1]; !shapes.Pub || util.Pub {
+		t.Errorf("got shapes.Pub = %v and util.Pub = %v, want true and false", shapes.Pub, util.Pub)
+	}
+
+	fn := file.Decls[0].(*ast.FnDecl)
+	outer, ok := fn.Params[0].Type.(*ast.ProjectedTypeExpr)
+	if !ok || outer.Assoc.Name != "Square" {
+		t.Fatalf("expected geo::shapes::Square, got %#v", fn.Params[0].Type)
+	}
+	if inner, ok := outer.Base.(*ast.ProjectedTypeExpr); !ok || inner.Assoc.Name != "shapes" {
+		t.Errorf("expected geo::shapes as the base, got %#v", outer.Base)
+	}
+}
@@ -25,11 +25,91 @@ func (p *Parser) parseStmt() ast.Stmt {
 		return p.parseSpawnStmt()
 	case lexer.SELECT:
 		return p.parseSelectStmt()
+	case lexer.HASH:
+		return p.parseAttributedStmt()
 	default:
 		return p.parseExprStmt()
 	}
 }
 
+// parseAttributedStmt parses `#[name] ... stmt`. Only return statements take
+// attributes; on any other statement they are reported and dropped.
+func (p *Parser) parseAttributedStmt() ast.Stmt {
+	attrs := p.parseAttributes()
+
+	if p.curTok.Type != lexer.RETURN {
+		if len(attrs) > 0 {
+			p.reportErrorWithHelp("attributes are only allowed on return statements", attrs[0].Span(),
+				"put the attribute directly before `return`:\n  #[tailcall] return f(n - 1);")
+		}
+		return p.parseStmt()
+	}
+
+	stmt := p.parseReturnStmt()
+	if ret, ok := stmt.(*ast.ReturnStmt); ok && len(attrs) > 0 {
+		ret.Attrs = attrs
+		ret.SetSpan(mergeSpan(attrs[0].Span(), ret.Span()))
+	}
+	return stmt
+}
+
+// parseAttributes parses a run of `#[name]` and `#[name(args)]` attributes,
+// leaving the current token on whatever follows them. Which attributes exist
+// and where they apply is checked by the type checker.
+func (p *Parser) parseAttributes() []*ast.Attribute {
+	var attrs []*ast.Attribute
+	for p.curTok.Type == lexer.HASH {
+		start := p.curTok.Span
+		if !p.expect(lexer.LBRACKET) || !p.expect(lexer.IDENT) {
+			p.nextToken()
+			continue
+		}
+		name := ast.NewIdent(p.curTok.Literal, p.curTok.Span)
+
+		var args []ast.Expr
+		if p.peekTok.Type == lexer.LPAREN {
+			p.nextToken()
+			var ok bool
+			if args, ok = p.parseAttributeArgs(); !ok {
+				p.nextToken()
+				continue
+			}
+		}
+
+		if !p.expect(lexer.RBRACKET) {
+			p.nextToken()
+			continue
+		}
+		attrs = append(attrs, ast.NewAttribute(name, args, mergeSpan(start, p.curTok.Span)))
+		p.nextToken()
+	}
+	return attrs
+}
+
+// parseAttributeArgs parses the comma-separated arguments of an attribute,
+// starting on the `(` and leaving the current token on the `)`.
+func (p *Parser) parseAttributeArgs() ([]ast.Expr, bool) {
+	args := []ast.Expr{}
+	for p.peekTok.Type != lexer.RPAREN {
+		p.nextToken()
+		arg := p.parseExpr()
+		if arg == nil {
+			return nil, false
+		}
+		args = append(args, arg)
+
+		if p.peekTok.Type != lexer.COMMA {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expect(lexer.RPAREN) {
+		return nil, false
+	}
+	return args, true
+}
+
 func (p *Parser) parseLetStmt() ast.Stmt {
 	start := p.curTok.Span
 
@@ -175,6 +255,12 @@ func (p *Parser) parseExprStmt() ast.Stmt {
 		return ast.NewIfStmt(ifExpr.Clauses, ifExpr.Else, ifExpr.Span())
 	}
 
+	// A block used as a statement needs no semicolon either
+	if block, ok := expr.(*ast.BlockExpr); ok && p.peekTok.Type != lexer.SEMICOLON && p.peekTok.Type != lexer.RBRACE {
+		p.nextToken()
+		return ast.NewExprStmt(block, block.Span())
+	}
+
 	switch p.peekTok.Type {
 	case lexer.SEMICOLON:
 		if !p.expect(lexer.SEMICOLON) {
@@ -443,6 +529,27 @@ func (p *Parser) parseSpawnStmt() ast.Stmt {
 	return ast.NewSpawnStmt(call, span)
 }
 
+// parseSpawnExpr parses `spawn call(args)` in expression position, e.g.
+// `let h = spawn worker(1);`. Only the call form yields a join handle;
+// spawn blocks and function literals remain statements.
+func (p *Parser) parseSpawnExpr() ast.Expr {
+	start := p.curTok.Span
+	p.nextToken() // consume 'spawn'
+
+	expr := p.parseExpr()
+	if expr == nil {
+		return nil
+	}
+
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		p.reportError("expected function call after 'spawn'", expr.Span())
+		return nil
+	}
+
+	return ast.NewSpawnExpr(call, mergeSpan(start, call.Span()))
+}
+
 func (p *Parser) parseSelectStmt() ast.Stmt {
 	start := p.curTok.Span
 
@@ -495,7 +602,22 @@ func (p *Parser) parseSelectCase() *ast.SelectCase {
 		p.nextToken() // consume 'case'
 	}
 
-	if p.curTok.Type == lexer.LET {
+	// `default` and `after` are contextual: they only start a case when
+	// followed by `=>` and `(` respectively
+	var after ast.Expr
+	if p.curTok.Type == lexer.IDENT && p.curTok.Literal == "default" && p.peekTok.Type == lexer.FATARROW {
+		// default => { ... } has no communication
+	} else if p.curTok.Type == lexer.IDENT && p.curTok.Literal == "after" && p.peekTok.Type == lexer.LPAREN {
+		p.nextToken() // consume 'after'
+		p.nextToken() // consume '('
+		after = p.parseExpr()
+		if after == nil {
+			return nil
+		}
+		if !p.expect(lexer.RPAREN) {
+			return nil
+		}
+	} else if p.curTok.Type == lexer.LET {
 		// Parse let binding without semicolon
 		p.nextToken() // consume 'let'
 		mutable := false
@@ -561,6 +683,9 @@ func (p *Parser) parseSelectCase() *ast.SelectCase {
 		p.nextToken()
 	}
 
+	if after != nil {
+		return ast.NewSelectAfterCase(after, body, mergeSpan(start, body.Span()))
+	}
 	return ast.NewSelectCase(comm, body, mergeSpan(start, body.Span()))
 }
 
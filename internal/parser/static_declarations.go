@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+func (p *Parser) parseStaticDecl() ast.Decl {
+	start := p.curTok.Span
+	isPub := false
+
+	if p.curTok.Type == lexer.PUB {
+		isPub = true
+		p.nextToken() // consume 'pub'
+	}
+
+	if p.curTok.Type != lexer.STATIC {
+		p.reportError("expected 'static' keyword", p.curTok.Span)
+		return nil
+	}
+
+	mutable := false
+	if p.peekTok.Type == lexer.MUT {
+		mutable = true
+		p.nextToken() // move to 'mut'
+	}
+
+	if !p.expect(lexer.IDENT) {
+		return nil
+	}
+
+	nameTok := p.curTok
+	name := ast.NewIdent(nameTok.Literal, nameTok.Span)
+
+	if p.peekTok.Type != lexer.COLON {
+		p.reportError("expected ':' after static name '"+nameTok.Literal+"'", p.peekTok.Span)
+		return nil
+	}
+
+	p.nextToken() // move to ':'
+	p.nextToken() // move to type start
+
+	if !isTypeStart(p.curTok.Type) {
+		p.reportError("expected type expression after ':' in static '"+nameTok.Literal+"'", p.curTok.Span)
+		return nil
+	}
+
+	typ := p.parseType()
+	if typ == nil {
+		return nil
+	}
+
+	if !p.expect(lexer.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	value := p.parseExpr()
+	if value == nil {
+		return nil
+	}
+
+	if !p.expect(lexer.SEMICOLON) {
+		return nil
+	}
+
+	span := mergeSpan(start, p.curTok.Span)
+
+	p.nextToken()
+
+	return ast.NewStaticDecl(isPub, mutable, name, typ, value, span)
+}
@@ -10,6 +10,13 @@ import (
 // prefix expression tests depend on this guarantee to keep Pratt precedence
 // calculation stable across nested constructs.
 func (p *Parser) nextToken() {
+	switch p.curTok.Type {
+	case lexer.LBRACE:
+		p.nesting++
+	case lexer.RBRACE:
+		p.nesting = max(0, p.nesting-1)
+	}
+
 	p.curTok = p.peekTok
 	if len(p.tokenBuffer) > 0 {
 		p.peekTok = p.tokenBuffer[0]
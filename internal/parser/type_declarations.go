@@ -41,10 +41,11 @@ func (p *Parser) parseStructDecl() ast.Decl {
 
 	p.nextToken()
 
-	for p.curTok.Type != lexer.RBRACE && p.curTok.Type != lexer.EOF {
+	for p.curTok.Type != lexer.RBRACE && p.curTok.Type != lexer.EOF && !isTopLevelDeclStart(p.curTok.Type) {
 		if p.curTok.Type != lexer.IDENT {
 			p.reportError("expected struct field name", p.curTok.Span)
-			return nil
+			p.recoverListItem()
+			continue
 		}
 
 		fieldTok := p.curTok
@@ -52,7 +53,8 @@ func (p *Parser) parseStructDecl() ast.Decl {
 
 		if p.peekTok.Type != lexer.COLON {
 			p.reportError("expected ':' after struct field '"+fieldTok.Literal+"'", p.peekTok.Span)
-			return nil
+			p.recoverListItem()
+			continue
 		}
 
 		p.nextToken() // move to ':'
@@ -60,12 +62,14 @@ func (p *Parser) parseStructDecl() ast.Decl {
 
 		if !isTypeStart(p.curTok.Type) {
 			p.reportError("expected type expression after ':' in struct field '"+fieldTok.Literal+"'", p.curTok.Span)
-			return nil
+			p.recoverListItem()
+			continue
 		}
 
 		fieldType := p.parseType()
 		if fieldType == nil {
-			return nil
+			p.recoverListItem()
+			continue
 		}
 
 		fieldSpan := mergeSpan(fieldTok.Span, fieldType.Span())
@@ -83,14 +87,23 @@ func (p *Parser) parseStructDecl() ast.Decl {
 			goto doneStruct
 		default:
 			p.reportError("expected ',' or '}' after struct field", p.peekTok.Span)
-			return nil
+			p.recoverListItem()
+			continue
 		}
 	}
 
 doneStruct:
 	if p.curTok.Type != lexer.RBRACE {
 		p.reportError("expected '}' to close struct declaration", p.curTok.Span)
-		return nil
+		if !isTopLevelDeclStart(p.curTok.Type) {
+			return nil
+		}
+		// Keep what was parsed; the next declaration starts here
+		span := mergeSpan(start, name.Span())
+		if len(fields) > 0 {
+			span = mergeSpan(span, fields[len(fields)-1].Span())
+		}
+		return ast.NewStructDecl(isPub, name, typeParams, whereClause, fields, span)
 	}
 
 	span := mergeSpan(start, p.curTok.Span)
@@ -136,10 +149,12 @@ func (p *Parser) parseEnumDecl() ast.Decl {
 
 	p.nextToken()
 
-	for p.curTok.Type != lexer.RBRACE && p.curTok.Type != lexer.EOF {
+variants:
+	for p.curTok.Type != lexer.RBRACE && p.curTok.Type != lexer.EOF && !isTopLevelDeclStart(p.curTok.Type) {
 		if p.curTok.Type != lexer.IDENT {
 			p.reportError("expected enum variant name", p.curTok.Span)
-			return nil
+			p.recoverListItem()
+			continue variants
 		}
 
 		variantTok := p.curTok
@@ -152,7 +167,8 @@ func (p *Parser) parseEnumDecl() ast.Decl {
 
 			if p.peekTok.Type == lexer.RPAREN {
 				p.reportError("expected type expression in enum variant payload", p.peekTok.Span)
-				return nil
+				p.recoverListItem()
+				continue variants
 			}
 
 			p.nextToken() // move to first payload type token
@@ -160,12 +176,14 @@ func (p *Parser) parseEnumDecl() ast.Decl {
 			for {
 				if !isTypeStart(p.curTok.Type) {
 					p.reportError("expected type expression in enum variant payload", p.curTok.Span)
-					return nil
+					p.recoverListItem()
+					continue variants
 				}
 
 				payload := p.parseType()
 				if payload == nil {
-					return nil
+					p.recoverListItem()
+					continue variants
 				}
 				payloads = append(payloads, payload)
 
@@ -174,7 +192,8 @@ func (p *Parser) parseEnumDecl() ast.Decl {
 					p.nextToken()
 					if p.curTok.Type == lexer.RPAREN {
 						p.reportError("expected type expression in enum variant payload", p.curTok.Span)
-						return nil
+						p.recoverListItem()
+						continue variants
 					}
 					continue
 				}
@@ -183,7 +202,8 @@ func (p *Parser) parseEnumDecl() ast.Decl {
 			}
 
 			if !p.expect(lexer.RPAREN) {
-				return nil
+				p.recoverListItem()
+				continue variants
 			}
 
 			variantSpan = mergeSpan(variantSpan, p.curTok.Span)
@@ -195,11 +215,13 @@ func (p *Parser) parseEnumDecl() ast.Decl {
 			p.nextToken() // move to type start
 			if !isTypeStart(p.curTok.Type) {
 				p.reportError("expected return type for enum variant", p.curTok.Span)
-				return nil
+				p.recoverListItem()
+				continue variants
 			}
 			returnType = p.parseType()
 			if returnType == nil {
-				return nil
+				p.recoverListItem()
+				continue variants
 			}
 			variantSpan = mergeSpan(variantSpan, returnType.Span())
 		}
@@ -218,14 +240,23 @@ func (p *Parser) parseEnumDecl() ast.Decl {
 			goto doneEnum
 		default:
 			p.reportError("expected ',' or '}' after enum variant", p.peekTok.Span)
-			return nil
+			p.recoverListItem()
+			continue variants
 		}
 	}
 
 doneEnum:
 	if p.curTok.Type != lexer.RBRACE {
 		p.reportError("expected '}' to close enum declaration", p.curTok.Span)
-		return nil
+		if !isTopLevelDeclStart(p.curTok.Type) {
+			return nil
+		}
+		// Keep what was parsed; the next declaration starts here
+		span := mergeSpan(start, name.Span())
+		if len(variants) > 0 {
+			span = mergeSpan(span, variants[len(variants)-1].Span())
+		}
+		return ast.NewEnumDecl(isPub, name, typeParams, whereClause, variants, span)
 	}
 
 	span := mergeSpan(start, p.curTok.Span)
@@ -28,8 +28,9 @@ func (p *Parser) parseTypePrefix() ast.TypeExpr {
 	case lexer.IDENT:
 		typ := p.parseNamedOrGenericType()
 
-		// Check for projected type (e.g., Self::Item, T::AssocType)
-		if p.peekTok.Type == lexer.DOUBLE_COLON {
+		// Check for projected type (e.g., Self::Item, T::AssocType) and
+		// module paths (e.g., geo::shapes::Square)
+		for p.peekTok.Type == lexer.DOUBLE_COLON {
 			p.nextToken() // consume ::
 			p.nextToken() // move to assoc name
 
@@ -41,10 +42,13 @@ func (p *Parser) parseTypePrefix() ast.TypeExpr {
 			assocName := ast.NewIdent(p.curTok.Literal, p.curTok.Span)
 			span := mergeSpan(typ.Span(), p.curTok.Span)
 
-			return ast.NewProjectedTypeExpr(typ, assocName, span)
+			typ = ast.NewProjectedTypeExpr(typ, assocName, span)
 		}
 
 		return typ
+	case lexer.BANG:
+		// The never type `!` resolves like a primitive named type
+		return ast.NewNamedType(ast.NewIdent("!", p.curTok.Span), p.curTok.Span)
 	case lexer.FN:
 		return p.parseFunctionType()
 	case lexer.CHAN:
@@ -64,7 +68,7 @@ func (p *Parser) parseTypePrefix() ast.TypeExpr {
 	case lexer.FORALL:
 		return p.parseForallType()
 	default:
-		help := "expected a type expression\n\nValid type expressions include:\n  - Primitive types: int, float, bool, string\n  - Named types: MyType\n  - Pointer types: *T, &T, &mut T\n  - Array types: [T; N], []T\n  - Generic types: Vec[T]\n  - Record types: { x: int, y: bool }\n  - Existential types: exists T: Trait. Type"
+		help := "expected a type expression\n\nValid type expressions include:\n  - Primitive types: int, float, bool, string\n  - The never type: !\n  - Named types: MyType\n  - Pointer types: *T, &T, &mut T\n  - Array types: [T; N], []T\n  - Generic types: Vec[T]\n  - Record types: { x: int, y: bool }\n  - Existential types: exists T: Trait. Type"
 		p.reportErrorWithHelp("expected type expression", p.curTok.Span, help)
 		return nil
 	}
@@ -23,7 +23,7 @@ func sameTokenPosition(a, b lexer.Token) bool {
 
 func isTopLevelDeclStart(tt lexer.TokenType) bool {
 	switch tt {
-	case lexer.FN, lexer.STRUCT, lexer.ENUM, lexer.TYPE, lexer.CONST, lexer.TRAIT, lexer.IMPL, lexer.UNSAFE:
+	case lexer.FN, lexer.STRUCT, lexer.ENUM, lexer.TYPE, lexer.CONST, lexer.STATIC, lexer.TRAIT, lexer.IMPL, lexer.UNSAFE, lexer.EXTERN:
 		return true
 	default:
 		return false
@@ -55,7 +55,12 @@ func (p *Parser) curPrecedence() int {
 	return precedenceLowest
 }
 
-func (p *Parser) recoverDecl(prev lexer.Token) {
+// recoverDecl skips the rest of a malformed top-level declaration, up to
+// the start of the next one. Braces and brackets opened within the
+// declaration, such as a function body, are skipped whole; a `}` outside them closes the
+// malformed declaration and is consumed. level is p.nesting at the start of
+// the declaration.
+func (p *Parser) recoverDecl(prev lexer.Token, level int) {
 	if p.curTok.Type == lexer.EOF {
 		return
 	}
@@ -64,15 +69,21 @@ func (p *Parser) recoverDecl(prev lexer.Token) {
 		p.nextToken()
 	}
 
+	parens := 0
 	for p.curTok.Type != lexer.EOF {
 		switch p.curTok.Type {
-		case lexer.SEMICOLON:
-			p.nextToken()
-			return
-		case lexer.RBRACE:
-			return
-		default:
-			if isTopLevelDeclStart(p.curTok.Type) {
+		case lexer.LPAREN, lexer.LBRACKET:
+			parens++
+		case lexer.RPAREN, lexer.RBRACKET:
+			parens = max(0, parens-1)
+		}
+
+		if p.nesting <= level && parens == 0 {
+			switch {
+			case p.curTok.Type == lexer.RBRACE, p.curTok.Type == lexer.SEMICOLON:
+				p.nextToken()
+				return
+			case isTopLevelDeclStart(p.curTok.Type):
 				return
 			}
 		}
@@ -81,7 +92,12 @@ func (p *Parser) recoverDecl(prev lexer.Token) {
 	}
 }
 
-func (p *Parser) recoverStatement(prev lexer.Token) {
+// recoverStatement skips the rest of a malformed statement, up to the `;`
+// ending it, the `}` closing the enclosing block, or the start of another
+// statement. Braces and brackets opened within the statement are skipped
+// whole, even when the error came from deep inside them. level is p.nesting at the
+// start of the statement.
+func (p *Parser) recoverStatement(prev lexer.Token, level int) {
 	if p.curTok.Type == lexer.EOF {
 		return
 	}
@@ -90,15 +106,23 @@ func (p *Parser) recoverStatement(prev lexer.Token) {
 		p.nextToken()
 	}
 
+	parens := 0
 	for p.curTok.Type != lexer.EOF {
 		switch p.curTok.Type {
-		case lexer.SEMICOLON:
-			p.nextToken()
-			return
-		case lexer.RBRACE:
-			return
-		default:
-			if isTopLevelDeclStart(p.curTok.Type) || isStatementStart(p.curTok.Type) {
+		case lexer.LPAREN, lexer.LBRACKET:
+			parens++
+		case lexer.RPAREN, lexer.RBRACKET:
+			parens = max(0, parens-1)
+		}
+
+		if p.nesting <= level && parens == 0 {
+			switch {
+			case p.curTok.Type == lexer.RBRACE:
+				return
+			case p.curTok.Type == lexer.SEMICOLON:
+				p.nextToken()
+				return
+			case isTopLevelDeclStart(p.curTok.Type), isStatementStart(p.curTok.Type):
 				return
 			}
 		}
@@ -107,3 +131,34 @@ func (p *Parser) recoverStatement(prev lexer.Token) {
 	}
 }
 
+// recoverListItem skips the rest of a malformed item of a brace-delimited
+// list, such as a struct field or an enum variant, past the `,` ending it,
+// or up to the `}` closing the list or a declaration keyword if the list is
+// not closed.
+func (p *Parser) recoverListItem() {
+	depth := 0
+	for p.curTok.Type != lexer.EOF {
+		switch p.curTok.Type {
+		case lexer.LBRACE, lexer.LPAREN, lexer.LBRACKET:
+			depth++
+		case lexer.RPAREN, lexer.RBRACKET:
+			depth = max(0, depth-1)
+		case lexer.RBRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+		case lexer.COMMA:
+			if depth == 0 {
+				p.nextToken()
+				return
+			}
+		case lexer.STRUCT, lexer.ENUM, lexer.TRAIT, lexer.IMPL, lexer.CONST, lexer.STATIC:
+			if depth == 0 {
+				return
+			}
+		}
+
+		p.nextToken()
+	}
+}
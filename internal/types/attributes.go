@@ -0,0 +1,156 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// attributeSpec describes an attribute the checker knows.
+type attributeSpec struct {
+	// targets lists what the attribute may be written on: "function",
+	// "struct", "enum" or "return statement".
+	targets []string
+	// args describes the arguments accepted, for the help of a bad use.
+	// An attribute with no description takes none.
+	args string
+	// checkArgs validates the arguments, returning a message for bad ones.
+	checkArgs func(args []ast.Expr) string
+}
+
+// knownAttributes is the registry of attributes. Attributes are parsed
+// whatever their name, so every use is validated against it.
+var knownAttributes = map[string]attributeSpec{
+	"copy": {
+		targets: []string{"struct", "enum"},
+	},
+	"deprecated": {
+		targets:   []string{"function", "struct", "enum"},
+		args:      `an optional message, as in #[deprecated("use g instead")]`,
+		checkArgs: optionalStringArg,
+	},
+	"inline": {
+		targets: []string{"function"},
+		args:    "optionally `always` or `never`, as in #[inline(always)]",
+		checkArgs: func(args []ast.Expr) string {
+			if len(args) == 0 {
+				return ""
+			}
+			if ident, ok := args[0].(*ast.Ident); ok && len(args) == 1 && (ident.Name == "always" || ident.Name == "never") {
+				return ""
+			}
+			return "expected `always` or `never`"
+		},
+	},
+	"repr": {
+		targets:   []string{"enum"},
+		args:      "the integer type of the tag, as in #[repr(u8)]",
+		checkArgs: reprArg,
+	},
+	"test": {
+		targets: []string{"function"},
+	},
+	"tailcall": {
+		targets: []string{"return statement"},
+	},
+}
+
+// optionalStringArg accepts no arguments or a single string literal.
+func optionalStringArg(args []ast.Expr) string {
+	if len(args) == 0 {
+		return ""
+	}
+	if _, ok := args[0].(*ast.StringLit); ok && len(args) == 1 {
+		return ""
+	}
+	return "expected a single string literal"
+}
+
+// checkAttributes reports the attributes of attrs that are unknown, not
+// allowed on target, or given bad arguments.
+func (c *Checker) checkAttributes(attrs []*ast.Attribute, target string) {
+	for _, attr := range attrs {
+		name := attr.Name.Name
+		spec, ok := knownAttributes[name]
+		if !ok {
+			c.reportErrorWithCode(fmt.Sprintf("unknown attribute `#[%s]`", name), attr.Span(), diag.CodeTypeInvalidAttribute,
+				"known attributes are "+knownAttributeNames(), nil)
+			continue
+		}
+
+		allowed := false
+		for _, t := range spec.targets {
+			allowed = allowed || t == target
+		}
+		if !allowed {
+			c.reportErrorWithCode(fmt.Sprintf("`#[%s]` cannot be used on a %s", name, target), attr.Span(), diag.CodeTypeInvalidAttribute,
+				fmt.Sprintf("`#[%s]` can be used on a %s", name, orList(spec.targets)), nil)
+			continue
+		}
+
+		msg := ""
+		switch {
+		case spec.checkArgs != nil:
+			msg = spec.checkArgs(attr.Args)
+		case attr.Args != nil:
+			msg = "expected no arguments"
+		}
+		if msg != "" {
+			help := fmt.Sprintf("`#[%s]` takes no arguments", name)
+			if spec.args != "" {
+				help = fmt.Sprintf("`#[%s]` takes %s", name, spec.args)
+			}
+			c.reportErrorWithCode(fmt.Sprintf("invalid arguments to `#[%s]`: %s", name, msg), attr.Span(), diag.CodeTypeInvalidAttribute, help, nil)
+		}
+	}
+}
+
+// knownAttributeNames lists the registered attributes for help messages.
+func knownAttributeNames() string {
+	names := make([]string, 0, len(knownAttributes))
+	for name := range knownAttributes {
+		names = append(names, "`#["+name+"]`")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// orList joins items as "a, b or c".
+func orList(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+// checkDeclAttributes validates the attributes of decl, and records it in
+// c.Tests if it is a test function.
+func (c *Checker) checkDeclAttributes(decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.FnDecl:
+		c.checkAttributes(d.Attrs, "function")
+		if ast.HasAttribute(d.Attrs, "test") {
+			c.checkTestFn(d)
+		}
+	case *ast.StructDecl:
+		c.checkAttributes(d.Attrs, "struct")
+	case *ast.EnumDecl:
+		c.checkAttributes(d.Attrs, "enum")
+	}
+}
+
+// checkTestFn reports a #[test] function that cannot be called on its own,
+// and otherwise records it in c.Tests.
+func (c *Checker) checkTestFn(decl *ast.FnDecl) {
+	if len(decl.Params) > 0 || len(decl.TypeParams) > 0 || decl.ReturnType != nil {
+		c.reportErrorWithCode(fmt.Sprintf("test function `%s` must take no parameters and return nothing", decl.Name.Name),
+			decl.Name.Span(), diag.CodeTypeInvalidAttribute, "a test passes unless it panics", nil)
+		return
+	}
+	if !c.inModule {
+		c.Tests = append(c.Tests, decl)
+	}
+}
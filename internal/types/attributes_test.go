@@ -0,0 +1,112 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestAttributeRegistry(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // substring of the only error, or "" for none
+	}{
+		{
+			name: "known attributes",
+			src: `package main;
+#[inline]
+fn a() {}
+#[inline(never)]
+fn b() {}
+#[deprecated("use a")]
+struct S { x: int }
+#[test]
+fn t() {}
+fn main() {}
+`,
+		},
+		{
+			name: "unknown attribute",
+			src:  "package main;\n#[inlined]\nfn f() {}\nfn main() {}\n",
+			want: "unknown attribute `#[inlined]`",
+		},
+		{
+			name: "attribute on the wrong declaration",
+			src:  "package main;\n#[inline]\nstruct S { x: int }\nfn main() {}\n",
+			want: "`#[inline]` cannot be used on a struct",
+		},
+		{
+			name: "attribute on the wrong statement",
+			src:  "package main;\nfn f() { #[inline] return; }\nfn main() {}\n",
+			want: "`#[inline]` cannot be used on a return statement",
+		},
+		{
+			name: "bad arguments",
+			src:  "package main;\n#[inline(sometimes)]\nfn f() {}\nfn main() {}\n",
+			want: "invalid arguments to `#[inline]`",
+		},
+		{
+			name: "arguments to an attribute taking none",
+			src:  "package main;\n#[test(1)]\nfn f() {}\nfn main() {}\n",
+			want: "invalid arguments to `#[test]`",
+		},
+		{
+			name: "test function with parameters",
+			src:  "package main;\n#[test]\nfn f(x: int) {}\nfn main() {}\n",
+			want: "test function `f` must take no parameters and return nothing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := checkSource(t, tt.src, "main.mal")
+			if tt.want == "" {
+				if len(checker.Errors) > 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			if len(checker.Errors) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(checker.Errors), checker.Errors)
+			}
+			if got := checker.Errors[0]; !strings.Contains(got.Message, tt.want) || got.Code != diag.CodeTypeInvalidAttribute {
+				t.Errorf("error = %s %q, want it to mention %q", got.Code, got.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestFunctionsRecorded(t *testing.T) {
+	src := `package main;
+#[test]
+fn adds() {}
+fn helper() {}
+#[test]
+fn subtracts() {}
+fn main() {}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+	if len(checker.Tests) != 2 || checker.Tests[0].Name.Name != "adds" || checker.Tests[1].Name.Name != "subtracts" {
+		t.Fatalf("unexpected tests: %v", checker.Tests)
+	}
+}
+
+func TestDeprecatedMessageInHelp(t *testing.T) {
+	src := `package main;
+#[deprecated("use new_fn instead")]
+fn old_fn() {}
+fn main() { old_fn(); }
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %v", checker.Warnings)
+	}
+	if help := checker.Warnings[0].Help; help != "`old_fn` is deprecated: use new_fn instead" {
+		t.Errorf("help = %q", help)
+	}
+}
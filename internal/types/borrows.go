@@ -0,0 +1,304 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+// Borrows last only as long as they are used. A borrow that is not stored
+// anywhere, like the one in `inc(&mut x);`, ends with its statement. A
+// borrow stored in a binding, as in `let r = &mut x;`, ends after the last
+// statement of the binding's block that mentions it, rather than with the
+// block itself.
+
+// The receiver of a method call that is not a place, as in
+// `make_point().len()`, is a temporary: it lives until the end of the
+// statement, and like a `let mut` binding may be borrowed mutably by a
+// method taking `&mut self`. A reference such a method returns cannot be
+// stored past the statement, since nothing owns the receiver after it.
+
+// borrow starts a borrow of sym created by the statement being checked. It
+// is not tied to the scope it is created in: `let r = { &mut x };` keeps it.
+func (c *Checker) borrow(sym *Symbol, kind BorrowKind, span lexer.Span) {
+	b := sym.addBorrow(kind, span)
+	c.stmtBorrows = append(c.stmtBorrows, activeBorrow{sym: sym, borrow: b})
+}
+
+// checkBorrowingStmt checks stmt and then ends the borrows it created,
+// except those stored in a binding by a let or an assignment.
+func (c *Checker) checkBorrowingStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
+	mark := len(c.stmtBorrows)
+	tempMark := len(c.stmtTemps)
+	c.checkStmt(stmt, scope, inUnsafe)
+	created := c.stmtBorrows[mark:]
+	c.stmtBorrows = c.stmtBorrows[:mark]
+	temps := c.stmtTemps[tempMark:]
+	c.stmtTemps = c.stmtTemps[:tempMark]
+
+	holder := c.borrowHolder(stmt, scope)
+	if holder != nil && len(temps) > 0 {
+		c.reportTemporaryBorrow(temps[0], holder)
+	}
+	for _, ab := range created {
+		if holder == nil {
+			ab.sym.release(ab.borrow)
+			continue
+		}
+		ab.borrow.Holder = holder
+		c.held = append(c.held, ab)
+	}
+	if holder != nil {
+		c.reborrow(stmt, holder)
+	}
+}
+
+// borrowReceiver borrows the receiver target of the method call call for as
+// long as the reference the method returns is kept, if it returns one. A
+// temporary receiver is noted in stmtTemps instead.
+func (c *Checker) borrowReceiver(call *ast.CallExpr, target ast.Expr, method *Function, scope *Scope) {
+	if method.Receiver == nil || method.Receiver.ByValue || method.Return == nil || !holdsReference(method.Return) {
+		return
+	}
+	if !isPlace(target) {
+		c.stmtTemps = append(c.stmtTemps, call)
+		return
+	}
+	if sym := c.getSymbol(target, scope); sym != nil {
+		kind := BorrowShared
+		if method.Receiver.IsMutable {
+			kind = BorrowExclusive
+		}
+		c.borrow(sym, kind, call.Span())
+	}
+}
+
+// isPlace reports whether expr denotes a storage location that outlives
+// the statement: a variable, or a field, element or dereference of one.
+func isPlace(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.FieldExpr:
+		return isPlace(e.Target)
+	case *ast.IndexExpr:
+		return isPlace(e.Target)
+	case *ast.PrefixExpr:
+		return e.Op == lexer.ASTERISK
+	}
+	return false
+}
+
+// reportTemporaryBorrow reports holder keeping the reference call returns
+// into its temporary receiver.
+func (c *Checker) reportTemporaryBorrow(call *ast.CallExpr, holder *Symbol) {
+	callee := call.Callee.(*ast.FieldExpr)
+	target := callee.Target
+	c.reportErrorWithLabeledSpans(
+		fmt.Sprintf("temporary value is freed at the end of the statement while `%s` still borrows it", holder.Name),
+		diag.CodeTypeTemporaryBorrow,
+		target.Span(),
+		"this temporary is freed at the end of the statement",
+		[]struct {
+			span  lexer.Span
+			label string
+		}{{span: call.Span(), label: fmt.Sprintf("the reference stored in `%s` points into it", holder.Name)}},
+		fmt.Sprintf("bind the value to a variable first, so that it lives as long as the reference:\n  let value = ...;\n  let %s = value.%s(...);", holder.Name, callee.Field.Name),
+	)
+}
+
+// checkBranch checks one of several alternative blocks, such as the
+// branches of an if. The borrows it leaves are set aside in borrows until
+// joinBranches, so they do not conflict with those of other branches.
+func (c *Checker) checkBranch(body *ast.BlockExpr, scope *Scope, inUnsafe bool, borrows *[]activeBorrow) Type {
+	mark := len(c.stmtBorrows)
+	typ := c.checkBlock(body, scope, inUnsafe)
+	for _, ab := range c.stmtBorrows[mark:] {
+		ab.sym.release(ab.borrow)
+		*borrows = append(*borrows, ab)
+	}
+	c.stmtBorrows = c.stmtBorrows[:mark]
+	return typ
+}
+
+// joinBranches resumes the borrows set aside by checkBranch once all the
+// branches are checked.
+func (c *Checker) joinBranches(borrows []activeBorrow) {
+	for _, ab := range borrows {
+		ab.sym.Borrows = append(ab.sym.Borrows, ab.borrow)
+		c.stmtBorrows = append(c.stmtBorrows, ab)
+	}
+}
+
+// borrowHolder returns the binding stmt stores a reference in, or nil.
+func (c *Checker) borrowHolder(stmt ast.Stmt, scope *Scope) *Symbol {
+	var sym *Symbol
+	switch s := stmt.(type) {
+	case *ast.LetStmt:
+		sym = scope.Lookup(s.Name.Name)
+	case *ast.ExprStmt:
+		assign, ok := s.Expr.(*ast.AssignExpr)
+		if !ok {
+			return nil
+		}
+		target, ok := assign.Target.(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		sym = scope.Lookup(target.Name)
+	}
+	if sym == nil || !holdsReference(sym.Type) {
+		return nil
+	}
+	return sym
+}
+
+// reborrow makes holder also hold the borrows of the bindings stmt copies
+// into it, so that `let s = r;` keeps the borrow in r alive while s is.
+func (c *Checker) reborrow(stmt ast.Stmt, holder *Symbol) {
+	var value ast.Expr
+	switch s := stmt.(type) {
+	case *ast.LetStmt:
+		value = s.Value
+	case *ast.ExprStmt:
+		value = s.Expr.(*ast.AssignExpr).Value
+	}
+	if value == nil {
+		return
+	}
+	sources := make(map[*Symbol]bool)
+	ast.Walk(value, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			if sym := c.Uses[ident]; sym != nil && sym != holder {
+				sources[sym] = true
+			}
+		}
+		return true
+	})
+	for _, ab := range c.held {
+		if !sources[ab.borrow.Holder] {
+			continue
+		}
+		b := ab.sym.addBorrow(ab.borrow.Kind, ab.borrow.Span)
+		b.Holder = holder
+		c.held = append(c.held, activeBorrow{sym: ab.sym, borrow: b})
+	}
+}
+
+// endBorrows ends the borrows held by holder.
+func (c *Checker) endBorrows(holder *Symbol) {
+	live := c.held[:0]
+	for _, ab := range c.held {
+		if ab.borrow.Holder == holder {
+			ab.sym.release(ab.borrow)
+			continue
+		}
+		live = append(live, ab)
+	}
+	c.held = live
+}
+
+// endDeadBorrows ends the borrows held by the bindings in holders that no
+// statement from the one at index next on uses.
+func (c *Checker) endDeadBorrows(holders map[*Symbol]int, next int) {
+	for holder, last := range holders {
+		if last < next {
+			c.endBorrows(holder)
+			delete(holders, holder)
+		}
+	}
+}
+
+// endAllBorrows ends every borrow, once the function being checked is done.
+func (c *Checker) endAllBorrows() {
+	for _, ab := range append(c.held, c.stmtBorrows...) {
+		ab.sym.release(ab.borrow)
+	}
+	c.held = nil
+	c.stmtBorrows = nil
+	c.stmtTemps = nil
+}
+
+// lastUse returns the index of the last statement of block after the one at
+// index from that mentions name, len(block.Stmts) if only the tail does, or
+// -1 if nothing does. A later let of the same name ends the search.
+func lastUse(block *ast.BlockExpr, from int, name string) int {
+	last := -1
+	for i := from + 1; i < len(block.Stmts); i++ {
+		stmt := block.Stmts[i]
+		if let, ok := stmt.(*ast.LetStmt); ok && let.Name.Name == name {
+			if mentions(let.Value, name) {
+				last = i
+			}
+			return last
+		}
+		if mentions(stmt, name) {
+			last = i
+		}
+	}
+	if block.Tail != nil && mentions(block.Tail, name) {
+		last = len(block.Stmts)
+	}
+	return last
+}
+
+// mentions reports whether an identifier named name occurs in node.
+func mentions(node ast.Node, name string) bool {
+	if node == nil {
+		return false
+	}
+	found := false
+	ast.Walk(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// holdsReference reports whether a value of type t can hold a reference.
+func holdsReference(t Type) bool {
+	return holdsReferenceIn(t, make(map[*Struct]bool))
+}
+
+func holdsReferenceIn(t Type, seen map[*Struct]bool) bool {
+	switch t := t.(type) {
+	case *Reference:
+		return true
+	case *Infer:
+		return t.Ref != nil && holdsReferenceIn(t.Ref, seen)
+	case *Struct:
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		for _, field := range t.Fields {
+			if holdsReferenceIn(field.Type, seen) {
+				return true
+			}
+		}
+	case *GenericInstance:
+		for _, arg := range t.Args {
+			if holdsReferenceIn(arg, seen) {
+				return true
+			}
+		}
+		return holdsReferenceIn(t.Base, seen)
+	case *Tuple:
+		for _, elem := range t.Elements {
+			if holdsReferenceIn(elem, seen) {
+				return true
+			}
+		}
+	case *Optional:
+		return holdsReferenceIn(t.Elem, seen)
+	case *Array:
+		return holdsReferenceIn(t.Elem, seen)
+	case *Slice:
+		return holdsReferenceIn(t.Elem, seen)
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+func TestChannelClose(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		hasError bool
+		errorMsg string
+	}{
+		{
+			name: "close then iterate",
+			input: `
+			package main;
+			fn main() {
+				let c = Channel[int]::new(2);
+				c <- 1;
+				close(c);
+				let total = 0;
+				for x in c {
+					total = total + x;
+				}
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "recv returns optional",
+			input: `
+			package main;
+			fn main() {
+				let c = Channel[string]::new(1);
+				let v: string? = c.recv();
+				if v != nil {
+					let s: string = v.unwrap();
+				}
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "send after close",
+			input: `
+			package main;
+			fn main() {
+				let c = Channel[int]::new(1);
+				close(c);
+				c <- 1;
+			}
+			`,
+			hasError: true,
+			errorMsg: "send on closed channel `c`",
+		},
+		{
+			name: "send after close in an earlier branch",
+			input: `
+			package main;
+			fn main() {
+				let c = Channel[int]::new(1);
+				let done = false;
+				if done {
+					close(c);
+					return;
+				}
+				c <- 1;
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "close non-channel",
+			input: `
+			package main;
+			fn main() {
+				close(3);
+			}
+			`,
+			hasError: true,
+			errorMsg: "cannot close non-channel type",
+		},
+		{
+			name: "close without arguments",
+			input: `
+			package main;
+			fn main() {
+				close();
+			}
+			`,
+			hasError: true,
+			errorMsg: "close takes 1 argument, got 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(tt.input)
+			file := p.ParseFile()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+
+			checker := NewChecker()
+			checker.Check(file)
+
+			if tt.hasError {
+				found := false
+				for _, err := range checker.Errors {
+					if strings.Contains(err.Message, tt.errorMsg) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("expected error %q, got %v", tt.errorMsg, checker.Errors)
+				}
+			} else if len(checker.Errors) > 0 {
+				t.Errorf("unexpected errors: %v", checker.Errors)
+			}
+		})
+	}
+}
+
+func TestSelectDefaultAndAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		hasError bool
+		errorMsg string
+	}{
+		{
+			name: "default and timeout cases",
+			input: `
+			package main;
+			fn main() {
+				let c = Channel[int]::new(1);
+				select {
+					let x = <-c => {
+						let y: int = x;
+					},
+					after(100) => {
+					}
+				}
+				select {
+					c <- 1 => {
+					},
+					default => {
+					}
+				}
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "non-integer timeout",
+			input: `
+			package main;
+			fn main() {
+				let c = Channel[int]::new(1);
+				select {
+					let x = <-c => {
+					},
+					after("soon") => {
+					}
+				}
+			}
+			`,
+			hasError: true,
+			errorMsg: "select timeout must be a `time::Duration` or an `int` number of milliseconds",
+		},
+		{
+			name: "duplicate default",
+			input: `
+			package main;
+			fn main() {
+				select {
+					default => {
+					},
+					default => {
+					}
+				}
+			}
+			`,
+			hasError: true,
+			errorMsg: "select has more than one `default` case",
+		},
+		{
+			name: "default with timeout",
+			input: `
+			package main;
+			fn main() {
+				let c = Channel[int]::new(1);
+				select {
+					let x = <-c => {
+					},
+					after(5) => {
+					},
+					default => {
+					}
+				}
+			}
+			`,
+			hasError: true,
+			errorMsg: "select cannot have both a `default` and an `after` case",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(tt.input)
+			file := p.ParseFile()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+
+			checker := NewChecker()
+			checker.Check(file)
+
+			if tt.hasError {
+				found := false
+				for _, err := range checker.Errors {
+					if strings.Contains(err.Message, tt.errorMsg) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("expected error %q, got %v", tt.errorMsg, checker.Errors)
+				}
+			} else if len(checker.Errors) > 0 {
+				t.Errorf("unexpected errors: %v", checker.Errors)
+			}
+		})
+	}
+}
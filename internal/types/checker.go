@@ -7,11 +7,12 @@ import (
 
 // ModuleInfo represents information about a loaded module.
 type ModuleInfo struct {
-	Name          string    // Module name (e.g., "utils")
-	File          *ast.File // Parsed AST of the module file
-	FilePath      string    // Full path to the module file
-	Scope         *Scope    // Scope containing ONLY public symbols (for external access)
-	InternalScope *Scope    // Scope containing ALL symbols (for body checking)
+	Name          string       // Module name (e.g., "utils")
+	File          *ast.File    // Parsed AST of the module file
+	FilePath      string       // Full path to the module file
+	Scope         *Scope       // Scope containing ONLY public symbols (for external access)
+	InternalScope *Scope       // Scope containing ALL symbols (for body checking)
+	Decl          *ast.ModDecl // The `mod` item declaring the module, nil for std modules
 }
 
 // Checker performs semantic analysis on the AST.
@@ -19,6 +20,19 @@ type Checker struct {
 	GlobalScope *Scope
 	Env         *Environment // Tracks trait implementations
 	Errors      []diag.Diagnostic
+	// Warnings holds diagnostics that do not stop compilation, such as
+	// unused variables in the file being checked
+	Warnings []diag.Diagnostic
+	// WarnShadow enables the SHADOWED_VARIABLE lint, reporting let bindings
+	// that hide a local variable (see shadow.go)
+	WarnShadow bool
+	// inModule is set while the bodies of loaded modules are checked, whose
+	// warnings are not the concern of the file being checked
+	inModule bool
+	// module is the name of the module whose items are being collected or
+	// checked, "" for the file being checked; private items are visible in
+	// it and in the modules nested in it
+	module string
 	// MethodTable maps type names to their methods
 	MethodTable map[string]map[string]*Function // typename -> methodname -> function
 	// Modules tracks loaded modules by their name
@@ -31,24 +45,91 @@ type Checker struct {
 	ExprTypes map[ast.Node]Type
 	// CallTypeArgs maps CallExpr nodes to their inferred/explicit type arguments
 	CallTypeArgs map[*ast.CallExpr][]Type
+	// Uses maps identifiers in expressions and type names to the symbols
+	// they resolve to
+	Uses map[*ast.Ident]*Symbol
+	// Defs maps the identifiers that name symbols at their definitions to
+	// those symbols
+	Defs map[*ast.Ident]*Symbol
+	// Moves records the identifiers whose use moves the value out of a
+	// local variable, which no longer has to be dropped
+	Moves map[*ast.Ident]bool
+	// Consts maps the identifiers and module paths that name a const, and
+	// the patterns that match one, to its value (see consts.go)
+	Consts map[ast.Expr]any
+	// constValues holds the value of each evaluated const or static, nil
+	// if its initializer is not constant
+	constValues map[ast.Decl]any
+	// constEvaluating holds the consts and statics whose initializers are
+	// being evaluated, for reporting cycles
+	constEvaluating map[ast.Decl]bool
 	// CurrentReturn tracks the expected return type of the current function
 	CurrentReturn Type
 	// CurrentFnName tracks the name of the current function (for main checks)
 	CurrentFnName string
+	// spawnScope is the scope enclosing the spawn body being checked, if any
+	spawnScope *Scope
+	// closedChannels maps channel variables passed to close() to the scope
+	// of that call, for rejecting later sends
+	closedChannels map[*Symbol]*Scope
+	// file is the file passed to CheckWithFilename, which fixes that add
+	// declarations edit
+	file *ast.File
+	// frame tracks the errors of the expression or statement being checked
+	frame errorFrame
+	// dropped is set when the last error reported was suppressed
+	dropped bool
+	// Tests holds the functions of the checked file marked #[test]
+	Tests []*ast.FnDecl
+	// collected, declDiags and unused locate the diagnostics of the checked
+	// file in Errors and Warnings: those of collecting its declarations,
+	// those of checking the body of each, and the unused variables. The
+	// diagnostics of loaded modules follow them (see recheck.go).
+	collected diagCount
+	declDiags map[ast.Decl]diagRange
+	unused    diagRange
+	// infers holds the inference variables of the function being checked
+	infers []*Infer
+	// selfBounds holds the traits the Self of its trait function calls,
+	// like Default::default(), must implement
+	selfBounds []selfBound
+	// stmtBorrows holds the borrows created by the statement being checked
+	stmtBorrows []activeBorrow
+	// held holds the borrows stored in bindings that are still live
+	held []activeBorrow
+	// stmtTemps holds the method calls of the statement being checked that
+	// return a reference into a temporary receiver
+	stmtTemps []*ast.CallExpr
+}
+
+// errorFrame tracks the errors of an expression or statement, apart from
+// those of the expressions it contains.
+type errorFrame struct {
+	poisoned bool // An operand has TypeError, so its errors are suppressed
+	errors   bool // Errors were reported or suppressed
 }
 
 // NewChecker creates a new type checker.
 func NewChecker() *Checker {
 	c := &Checker{
-		GlobalScope:    NewScope(nil),
-		Env:            NewEnvironment(),
-		Errors:         []diag.Diagnostic{},
-		MethodTable:    make(map[string]map[string]*Function),
-		Modules:        make(map[string]*ModuleInfo),
-		LoadingModules: make(map[string]bool),
-		ExprTypes:      make(map[ast.Node]Type),
-		CallTypeArgs:   make(map[*ast.CallExpr][]Type),
+		GlobalScope:     NewScope(nil),
+		Env:             NewEnvironment(),
+		Errors:          []diag.Diagnostic{},
+		Warnings:        []diag.Diagnostic{},
+		MethodTable:     make(map[string]map[string]*Function),
+		Modules:         make(map[string]*ModuleInfo),
+		LoadingModules:  make(map[string]bool),
+		ExprTypes:       make(map[ast.Node]Type),
+		CallTypeArgs:    make(map[*ast.CallExpr][]Type),
+		Uses:            make(map[*ast.Ident]*Symbol),
+		Moves:           make(map[*ast.Ident]bool),
+		Consts:          make(map[ast.Expr]any),
+		constValues:     make(map[ast.Decl]any),
+		constEvaluating: make(map[ast.Decl]bool),
+		Defs:            make(map[*ast.Ident]*Symbol),
+		declDiags:       make(map[ast.Decl]diagRange),
 	}
+	c.GlobalScope.defs = c.Defs
 
 	// Add built-in types
 	c.GlobalScope.Insert("int", &Symbol{Name: "int", Type: TypeInt})
@@ -76,13 +157,13 @@ func NewChecker() *Checker {
 		},
 	})
 
-	// panic: fn(string) -> ! (diverges)
-	// For now, we treat it as returning void, but the checker knows it terminates.
+	// panic: fn(string) -> !
+	// Prints the message and a backtrace, then exits; it never returns.
 	c.GlobalScope.Insert("panic", &Symbol{
 		Name: "panic",
 		Type: &Function{
 			Params: []Type{TypeString},
-			Return: TypeVoid,
+			Return: TypeNever,
 		},
 	})
 
@@ -119,6 +200,15 @@ func NewChecker() *Checker {
 		},
 	})
 
+	// close: fn(chan T) -> void
+	c.GlobalScope.Insert("close", &Symbol{
+		Name: "close",
+		Type: &Function{
+			Params: []Type{&Named{Name: "any"}}, // channel, checked in checkCloseCall
+			Return: TypeVoid,
+		},
+	})
+
 	// delete: fn(map[K]V, K) -> void
 	c.GlobalScope.Insert("delete", &Symbol{
 		Name: "delete",
@@ -137,6 +227,15 @@ func NewChecker() *Checker {
 		},
 	})
 
+	// enum_tag: fn(enum) -> int, the variant index; checked in checkEnumTagCall
+	c.GlobalScope.Insert("enum_tag", &Symbol{
+		Name: "enum_tag",
+		Type: &Function{
+			Params: []Type{&Named{Name: "any"}},
+			Return: TypeInt,
+		},
+	})
+
 	// contains: fn(map[K]V, K) -> bool
 	c.GlobalScope.Insert("contains", &Symbol{
 		Name: "contains",
@@ -146,6 +245,32 @@ func NewChecker() *Checker {
 		},
 	})
 
+	// Integer arithmetic with explicit overflow behavior, independent of --overflow:
+	// wrapping_*: fn[T](T, T) -> T (two's complement wrapping)
+	// checked_*: fn[T](T, T) -> T? (nil on overflow)
+	// T is any integer type, checked in checkArithmeticBuiltinCall
+	for _, op := range []string{"add", "sub", "mul"} {
+		c.GlobalScope.Insert("wrapping_"+op, &Symbol{
+			Name: "wrapping_" + op,
+			Type: &Function{
+				TypeParams: []TypeParam{{Name: "T"}},
+				Params:     []Type{&TypeParam{Name: "T"}, &TypeParam{Name: "T"}},
+				Return:     &TypeParam{Name: "T"},
+			},
+		})
+		c.GlobalScope.Insert("checked_"+op, &Symbol{
+			Name: "checked_" + op,
+			Type: &Function{
+				TypeParams: []TypeParam{{Name: "T"}},
+				Params:     []Type{&TypeParam{Name: "T"}, &TypeParam{Name: "T"}},
+				Return:     &Optional{Elem: &TypeParam{Name: "T"}},
+			},
+		})
+	}
+
+	// Runtime intrinsics used by the stdlib sources
+	declareIntrinsics(c.GlobalScope)
+
 	// comparable interface (marker for Go compatibility)
 	c.GlobalScope.Insert("comparable", &Symbol{
 		Name: "comparable",
@@ -163,28 +288,57 @@ func (c *Checker) Check(file *ast.File) {
 // CheckWithFilename validates the types in the given file with a filename for module resolution.
 func (c *Checker) CheckWithFilename(file *ast.File, filename string) {
 	c.CurrentFile = filename
+	c.file = file
+	c.Env.InvalidateCache()
 	// Pass 1: Collect declarations (this will load modules)
 	c.collectDecls(file)
+	c.collected = c.diagCount()
 
 	// Pass 2: Check bodies of the main file
 	c.checkBodies(file)
+	start := c.diagCount()
+	c.reportUnusedVariables(file)
+	c.unused = diagRange{start, c.diagCount()}
 
 	// Pass 2b: Check bodies of all loaded modules
-	// Note: iterate over a copy of keys to avoid concurrent map iteration issues if checkBodies loads more modules
-	// (though collectDecls should have loaded everything reachable)
-	// We use a simple loop because map iteration order is random
-	for _, modInfo := range c.Modules {
-		// Update CurrentFile for correct error reporting
-		oldFile := c.CurrentFile
-		c.CurrentFile = modInfo.FilePath
-
-		// Update GlobalScope to module scope
-		oldScope := c.GlobalScope
-		c.GlobalScope = modInfo.InternalScope
-
-		c.checkBodies(modInfo.File)
-
-		c.GlobalScope = oldScope
-		c.CurrentFile = oldFile
+	c.checkModuleBodies(make(map[string]bool))
+}
+
+// checkModuleBodies checks the bodies of the loaded modules not in checked,
+// adding them to it. Checking a body can load further modules on demand
+// (e.g. prelude modules), so it keeps going until every module has been
+// checked once.
+func (c *Checker) checkModuleBodies(checked map[string]bool) {
+	for {
+		var pending []*ModuleInfo
+		for name, modInfo := range c.Modules {
+			if !checked[name] {
+				checked[name] = true
+				pending = append(pending, modInfo)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		for _, modInfo := range pending {
+			// Update CurrentFile for correct error reporting
+			oldFile := c.CurrentFile
+			c.CurrentFile = modInfo.FilePath
+
+			// Update GlobalScope to module scope
+			oldScope := c.GlobalScope
+			c.GlobalScope = modInfo.InternalScope
+
+			c.inModule = true
+			c.module = modInfo.Name
+			c.Env.InvalidateCache()
+			c.checkBodies(modInfo.File)
+			c.module = ""
+			c.inModule = false
+
+			c.GlobalScope = oldScope
+			c.CurrentFile = oldFile
+		}
 	}
 }
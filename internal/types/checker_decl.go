@@ -19,6 +19,25 @@ func (c *Checker) collectDecls(file *ast.File) {
 		c.processUseDecl(useDecl)
 	}
 
+	// Consts and statics come first, so that array lengths in the other
+	// declarations can use them
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.ConstDecl:
+			c.GlobalScope.Insert(d.Name.Name, &Symbol{
+				Name:    d.Name.Name,
+				Type:    c.resolveType(d.Type),
+				DefNode: d,
+			})
+		case *ast.StaticDecl:
+			c.GlobalScope.Insert(d.Name.Name, &Symbol{
+				Name:    d.Name.Name,
+				Type:    c.resolveType(d.Type),
+				DefNode: d,
+			})
+		}
+	}
+
 	// Finally, process regular declarations
 	for _, decl := range file.Decls {
 		switch d := decl.(type) {
@@ -116,6 +135,7 @@ func (c *Checker) collectDecls(file *ast.File) {
 					Name:       d.Name.Name,
 					TypeParams: typeParams,
 					Fields:     fields,
+					Copy:       ast.HasAttribute(d.Attrs, "copy"),
 				},
 				DefNode: d,
 			})
@@ -126,13 +146,6 @@ func (c *Checker) collectDecls(file *ast.File) {
 				Type:    target,
 				DefNode: d,
 			})
-		case *ast.ConstDecl:
-			typ := c.resolveType(d.Type)
-			c.GlobalScope.Insert(d.Name.Name, &Symbol{
-				Name:    d.Name.Name,
-				Type:    typ,
-				DefNode: d,
-			})
 		case *ast.EnumDecl:
 			// Build type params
 			var typeParams []TypeParam
@@ -153,6 +166,8 @@ func (c *Checker) collectDecls(file *ast.File) {
 			enumType := &Enum{
 				Name:       d.Name.Name,
 				TypeParams: typeParams,
+				Repr:       c.enumRepr(d),
+				Copy:       ast.HasAttribute(d.Attrs, "copy"),
 				// Variants will be filled later
 			}
 			c.GlobalScope.Insert(d.Name.Name, &Symbol{
@@ -371,197 +386,278 @@ func (c *Checker) collectDecls(file *ast.File) {
 
 			// Process each method in the impl block
 			for _, method := range d.Methods {
-				// Build function type
-				var params []Type
-				var receiver *ReceiverType
-
-				// Check if first parameter is a receiver (self, &self, &mut self)
-				if len(method.Params) > 0 {
-					firstParam := method.Params[0]
-					if firstParam.Name.Name == "self" {
-						// Determine receiver type from parameter type annotation
-						if firstParam.Type != nil {
-							if refType, ok := firstParam.Type.(*ast.ReferenceType); ok {
-								// &self or &mut self
-								receiver = &ReceiverType{
-									IsMutable: refType.Mutable,
-									Type:      targetType,
-								}
-							} else {
-								// self (by value)
-								receiver = &ReceiverType{
-									IsMutable: false,
-									Type:      targetType,
-								}
-							}
-						} else {
-							// No type annotation on self - assume &self
-							receiver = &ReceiverType{
-								IsMutable: false,
-								Type:      targetType,
-							}
-						}
+				c.MethodTable[targetName][method.Name.Name] = c.implMethodType(method, targetType, typeParamMap)
+			}
+			c.registerDefaultMethods(d, targetName, targetType, typeParamMap)
+			c.registerDrop(d, targetType)
+		}
+	}
+}
 
-						// Skip the receiver when processing remaining params
-						// Resolve with Self/typeParam context
-						for i := 1; i < len(method.Params); i++ {
-							paramType := c.resolveTypeWithContext(method.Params[i].Type, typeParamMap)
-							params = append(params, paramType)
-						}
-					} else {
-						// Regular parameters (no receiver)
-						for _, p := range method.Params {
-							paramType := c.resolveTypeWithContext(p.Type, typeParamMap)
-							params = append(params, paramType)
-						}
+// implMethodType builds the type of a method declared in an impl block for
+// targetType. typeParamMap resolves Self and the type parameters of the impl.
+func (c *Checker) implMethodType(method *ast.FnDecl, targetType Type, typeParamMap map[string]Type) *Function {
+	methodTypeParams, methodContext := c.methodTypeParams(method, typeParamMap)
+
+	// Build function type
+	var params []Type
+	var receiver *ReceiverType
+
+	// Check if first parameter is a receiver (self, &self, &mut self)
+	if len(method.Params) > 0 {
+		firstParam := method.Params[0]
+		if firstParam.Name.Name == "self" {
+			// Determine receiver type from parameter type annotation
+			if firstParam.Type != nil {
+				if refType, ok := firstParam.Type.(*ast.ReferenceType); ok {
+					// &self or &mut self
+					receiver = &ReceiverType{
+						IsMutable: refType.Mutable,
+						Type:      targetType,
 					}
 				} else {
-					// No parameters - could still be a method with no args
-					// Assume it needs a receiver (will need &self)
+					// self (by value)
 					receiver = &ReceiverType{
 						IsMutable: false,
+						ByValue:   true,
 						Type:      targetType,
 					}
 				}
-
-				var returnType Type = TypeVoid
-				if method.ReturnType != nil {
-					returnType = c.resolveTypeWithContext(method.ReturnType, typeParamMap)
+			} else {
+				// No type annotation on self - assume &self
+				receiver = &ReceiverType{
+					IsMutable: false,
+					Type:      targetType,
 				}
+			}
 
-				c.MethodTable[targetName][method.Name.Name] = &Function{
-					Unsafe:   method.Unsafe,
-					Params:   params,
-					Return:   returnType,
-					Receiver: receiver,
-				}
+			// Skip the receiver when processing remaining params
+			// Resolve with Self/typeParam context
+			for i := 1; i < len(method.Params); i++ {
+				paramType := c.resolveTypeWithContext(method.Params[i].Type, methodContext)
+				params = append(params, paramType)
+			}
+		} else {
+			// Regular parameters (no receiver)
+			for _, p := range method.Params {
+				paramType := c.resolveTypeWithContext(p.Type, methodContext)
+				params = append(params, paramType)
 			}
 		}
+	} else {
+		// No parameters - could still be a method with no args
+		// Assume it needs a receiver (will need &self)
+		receiver = &ReceiverType{
+			IsMutable: false,
+			Type:      targetType,
+		}
+	}
+
+	var returnType Type = TypeVoid
+	if method.ReturnType != nil {
+		returnType = c.resolveTypeWithContext(method.ReturnType, methodContext)
+	}
+
+	return &Function{
+		Unsafe:     method.Unsafe,
+		TypeParams: methodTypeParams,
+		Params:     params,
+		Return:     returnType,
+		Receiver:   receiver,
 	}
 }
 
+// checkBodies checks the bodies of the declarations of file. The
+// diagnostics of each declaration of the file being checked are recorded,
+// for Recheck to reuse those of the declarations an edit left alone.
 func (c *Checker) checkBodies(file *ast.File) {
 	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-		case *ast.FnDecl:
+		start := c.diagCount()
+		c.checkDeclBody(decl)
+		if !c.inModule {
+			c.declDiags[decl] = diagRange{start, c.diagCount()}
+		}
+	}
+}
+
+// checkDeclBody checks the body of the top-level declaration decl.
+func (c *Checker) checkDeclBody(decl ast.Decl) {
+	c.checkDeclAttributes(decl)
+	c.checkCopyDecl(decl)
+	switch d := decl.(type) {
+	case *ast.FnDecl:
+		// Create function scope
+		fnScope := NewScope(c.GlobalScope)
+		// Get the function symbol to access already resolved parameter types
+		fnSym := c.GlobalScope.Lookup(d.Name.Name)
+		fnType := fnSym.Type.(*Function)
+		if d.ABI != "" {
+			c.checkExternFn(d, fnType)
+			return
+		}
+
+		// Type parameters are in scope for paths like T::default()
+		for i := range fnType.TypeParams {
+			tp := &fnType.TypeParams[i]
+			fnScope.Insert(tp.Name, &Symbol{Name: tp.Name, Type: tp})
+		}
+
+		// Add params to scope using the resolved types from fnType
+		// This ensures TypeParams are correctly referenced
+		for i, param := range d.Params {
+			fnScope.Insert(param.Name.Name, &Symbol{
+				Name:    param.Name.Name,
+				Type:    fnType.Params[i],
+				DefNode: param,
+			})
+		}
+		// Set current return type and function name
+		oldReturn := c.CurrentReturn
+		oldFnName := c.CurrentFnName
+		c.CurrentReturn = c.GlobalScope.Lookup(d.Name.Name).Type.(*Function).Return
+		c.CurrentFnName = d.Name.Name
+		if d.Name.Name == "main" {
+			c.checkMainSignature(d, fnType)
+		}
+		c.checkFnBody(d.Body, fnScope, d.Unsafe)
+		c.CurrentReturn = oldReturn
+		c.CurrentFnName = oldFnName
+	case *ast.ConstDecl:
+		c.checkConstDecl(d)
+	case *ast.StaticDecl:
+		c.checkStaticDecl(d)
+	case *ast.TraitDecl:
+		c.checkTraitDefaults(d)
+	case *ast.ImplDecl:
+		// Resolve target type
+		targetType := c.resolveType(d.Target)
+
+		// Create impl scope for type params
+		implScope := NewScope(c.GlobalScope)
+
+		// Add type params to scope if generic
+		if _, ok := targetType.(*GenericInstance); ok {
+			// Map type param names to TypeParam types?
+			// Or just ensure they are resolvable?
+			// Actually, impl Vec[T]. T is a type param.
+			// We need to add T to scope so it resolves to TypeParam.
+			// But resolveType already handled it?
+			// No, resolveType resolves T to Named("T").
+			// We need to bind "T" in scope.
+
+			// If d.Target is GenericType in AST
+			if genType, ok := d.Target.(*ast.GenericType); ok {
+				for _, arg := range genType.Args {
+					if named, ok := arg.(*ast.NamedType); ok {
+						// Add T to scope
+						implScope.Insert(named.Name.Name, &Symbol{
+							Name: named.Name.Name,
+							Type: &Named{Name: named.Name.Name}, // Placeholder for TypeParam
+						})
+					}
+				}
+			}
+		}
+
+		// Check methods
+		for _, method := range d.Methods {
 			// Create function scope
-			fnScope := NewScope(c.GlobalScope)
-			// Get the function symbol to access already resolved parameter types
-			fnSym := c.GlobalScope.Lookup(d.Name.Name)
-			fnType := fnSym.Type.(*Function)
-
-			// Add params to scope using the resolved types from fnType
-			// This ensures TypeParams are correctly referenced
-			for i, param := range d.Params {
+			fnScope := NewScope(implScope)
+
+			// Add Self to scope
+			// Self is the target type
+			fnScope.Insert("Self", &Symbol{
+				Name: "Self",
+				Type: targetType,
+			})
+
+			// Build type parameter map for resolving method param types
+			typeParamMap := make(map[string]Type)
+			typeParamMap["Self"] = targetType
+
+			// If target is generic, map type params
+			if genType, ok := d.Target.(*ast.GenericType); ok {
+				if namedBase, ok := genType.Base.(*ast.NamedType); ok {
+					baseTypeName := namedBase.Name.Name
+					if sym := c.GlobalScope.Lookup(baseTypeName); sym != nil {
+						var baseTypeParams []TypeParam
+						switch baseType := sym.Type.(type) {
+						case *Struct:
+							baseTypeParams = baseType.TypeParams
+						case *Enum:
+							baseTypeParams = baseType.TypeParams
+						}
+
+						for i, tp := range baseTypeParams {
+							if i < len(genType.Args) {
+								typeParamMap[tp.Name] = &TypeParam{Name: tp.Name, Bounds: tp.Bounds}
+							}
+						}
+					}
+				}
+			}
+
+			// Add params to scope with proper type substitution
+			_, methodContext := c.methodTypeParams(method, typeParamMap)
+			for _, param := range method.Params {
+				paramType := c.resolveTypeWithContext(param.Type, methodContext)
 				fnScope.Insert(param.Name.Name, &Symbol{
 					Name:    param.Name.Name,
-					Type:    fnType.Params[i],
+					Type:    paramType,
 					DefNode: param,
 				})
 			}
 			// Set current return type and function name
 			oldReturn := c.CurrentReturn
 			oldFnName := c.CurrentFnName
-			c.CurrentReturn = c.GlobalScope.Lookup(d.Name.Name).Type.(*Function).Return
-			c.CurrentFnName = d.Name.Name
-			c.checkBlock(d.Body, fnScope, d.Unsafe)
-			c.CurrentReturn = oldReturn
-			c.CurrentFnName = oldFnName
-		case *ast.ImplDecl:
-			// Resolve target type
-			targetType := c.resolveType(d.Target)
-
-			// Create impl scope for type params
-			implScope := NewScope(c.GlobalScope)
-
-			// Add type params to scope if generic
-			if _, ok := targetType.(*GenericInstance); ok {
-				// Map type param names to TypeParam types?
-				// Or just ensure they are resolvable?
-				// Actually, impl Vec[T]. T is a type param.
-				// We need to add T to scope so it resolves to TypeParam.
-				// But resolveType already handled it?
-				// No, resolveType resolves T to Named("T").
-				// We need to bind "T" in scope.
-
-				// If d.Target is GenericType in AST
-				if genType, ok := d.Target.(*ast.GenericType); ok {
-					for _, arg := range genType.Args {
-						if named, ok := arg.(*ast.NamedType); ok {
-							// Add T to scope
-							implScope.Insert(named.Name.Name, &Symbol{
-								Name: named.Name.Name,
-								Type: &Named{Name: named.Name.Name}, // Placeholder for TypeParam
-							})
-						}
-					}
+
+			// Look up method in MethodTable to get the resolved return type
+			targetName := c.getTypeName(targetType)
+			if methods, ok := c.MethodTable[targetName]; ok {
+				if fn, ok := methods[method.Name.Name]; ok {
+					c.CurrentReturn = fn.Return
 				}
 			}
 
-			// Check methods
-			for _, method := range d.Methods {
-				// Create function scope
-				fnScope := NewScope(implScope)
-
-				// Add Self to scope
-				// Self is the target type
-				fnScope.Insert("Self", &Symbol{
-					Name: "Self",
-					Type: targetType,
-				})
-
-				// Build type parameter map for resolving method param types
-				typeParamMap := make(map[string]Type)
-				typeParamMap["Self"] = targetType
-
-				// If target is generic, map type params
-				if genType, ok := d.Target.(*ast.GenericType); ok {
-					if namedBase, ok := genType.Base.(*ast.NamedType); ok {
-						baseTypeName := namedBase.Name.Name
-						if sym := c.GlobalScope.Lookup(baseTypeName); sym != nil {
-							var baseTypeParams []TypeParam
-							switch baseType := sym.Type.(type) {
-							case *Struct:
-								baseTypeParams = baseType.TypeParams
-							case *Enum:
-								baseTypeParams = baseType.TypeParams
-							}
-
-							for i, tp := range baseTypeParams {
-								if i < len(genType.Args) {
-									typeParamMap[tp.Name] = &TypeParam{Name: tp.Name, Bounds: tp.Bounds}
-								}
-							}
-						}
-					}
-				}
+			c.CurrentFnName = method.Name.Name
+			c.checkFnBody(method.Body, fnScope, method.Unsafe)
+			c.CurrentReturn = oldReturn
+			c.CurrentFnName = oldFnName
+		}
+	}
+}
 
-				// Add params to scope with proper type substitution
-				for _, param := range method.Params {
-					paramType := c.resolveTypeWithContext(param.Type, typeParamMap)
-					fnScope.Insert(param.Name.Name, &Symbol{
-						Name:    param.Name.Name,
-						Type:    paramType,
-						DefNode: param,
-					})
-				}
-				// Set current return type and function name
-				oldReturn := c.CurrentReturn
-				oldFnName := c.CurrentFnName
-
-				// Look up method in MethodTable to get the resolved return type
-				targetName := c.getTypeName(targetType)
-				if methods, ok := c.MethodTable[targetName]; ok {
-					if fn, ok := methods[method.Name.Name]; ok {
-						c.CurrentReturn = fn.Return
-					}
-				}
+// checkMainSignature validates the return type of main. main may return nothing,
+// an integer exit code, or Result[void, E] (Err exits with status 1).
+func (c *Checker) checkMainSignature(decl *ast.FnDecl, fnType *Function) {
+	isVoid := func(t Type) bool {
+		p, ok := t.(*Primitive)
+		return t == nil || (ok && p.Kind == Void)
+	}
 
-				c.CurrentFnName = method.Name.Name
-				c.checkBlock(method.Body, fnScope, method.Unsafe)
-				c.CurrentReturn = oldReturn
-				c.CurrentFnName = oldFnName
-			}
+	ret := fnType.Return
+	if isVoid(ret) {
+		return
+	}
+	if p, ok := ret.(*Primitive); ok {
+		switch p.Kind {
+		case Int, Int8, Int32, Int64, U8, U16, U32, U64, Usize:
+			return
 		}
 	}
+	if _, args, ok := AsResult(ret); ok && len(args) == 2 && isVoid(args[0]) {
+		return
+	}
+
+	span := decl.Name.Span()
+	if decl.ReturnType != nil {
+		span = decl.ReturnType.Span()
+	}
+	c.reportErrorWithCode(
+		fmt.Sprintf("`main` cannot return `%s`", ret),
+		span,
+		diag.CodeTypeMismatch,
+		"`main` must return nothing, an integer exit code, or `Result[void, E]`",
+		nil,
+	)
 }
@@ -20,6 +20,20 @@ func (c *Checker) toDiagSpan(span lexer.Span) diag.Span {
 	}
 }
 
+// addError records d, unless it follows from an operand of type TypeError
+// or an identical error was already recorded.
+func (c *Checker) addError(d diag.Diagnostic) {
+	c.frame.errors = true
+	c.dropped = c.frame.poisoned
+	for i := len(c.Errors) - 1; i >= 0 && !c.dropped; i-- {
+		e := c.Errors[i]
+		c.dropped = e.Code == d.Code && e.Message == d.Message && e.Span == d.Span
+	}
+	if !c.dropped {
+		c.Errors = append(c.Errors, d)
+	}
+}
+
 func (c *Checker) reportError(msg string, span lexer.Span) {
 	c.reportErrorWithCode(msg, span, "", "", nil)
 }
@@ -46,7 +60,28 @@ func (c *Checker) reportErrorWithCode(msg string, span lexer.Span, code diag.Cod
 		diag = diag.WithPrimarySpan(diagSpan, "")
 	}
 
-	c.Errors = append(c.Errors, diag)
+	c.addError(diag)
+}
+
+// reportWarning records a warning labeled label at span, unless a loaded
+// module rather than the file being checked is being checked.
+func (c *Checker) reportWarning(msg string, span lexer.Span, code diag.Code, label string, help string) {
+	if c.inModule {
+		return
+	}
+	diagSpan := c.toDiagSpan(span)
+	warning := diag.Diagnostic{
+		Stage:    diag.StageTypeCheck,
+		Severity: diag.SeverityWarning,
+		Code:     code,
+		Message:  msg,
+		Span:     diagSpan,
+		Help:     help,
+	}
+	if diagSpan.IsValid() {
+		warning = warning.WithPrimarySpan(diagSpan, label)
+	}
+	c.Warnings = append(c.Warnings, warning)
 }
 
 // reportErrorWithLabeledSpans reports an error with labeled spans (primary/secondary).
@@ -75,7 +110,7 @@ func (c *Checker) reportErrorWithLabeledSpans(msg string, code diag.Code, primar
 		}
 	}
 
-	c.Errors = append(c.Errors, diag)
+	c.addError(diag)
 }
 
 // reportConstraintError reports a constraint failure with proof chain.
@@ -169,7 +204,7 @@ func (c *Checker) reportConstraintError(typ Type, bound Type, boundSpan lexer.Sp
 		diag = diag.WithNote(fmt.Sprintf("trait `%s` requires the following methods: %s", bound, strings.Join(missingMethods, ", ")))
 	}
 
-	c.Errors = append(c.Errors, diag)
+	c.addError(diag)
 }
 
 // Helper functions for common error patterns
@@ -205,7 +240,7 @@ func (c *Checker) reportUndefinedIdentifier(name string, span lexer.Span, scope
 		for modName, modInfo := range c.Modules {
 			if modInfo.Scope != nil {
 				if sym := modInfo.Scope.Lookup(name); sym != nil {
-					help = fmt.Sprintf("`%s` exists in module `%s`. Import it with:\n  mod %s;", name, modName, modName)
+					help = fmt.Sprintf("`%s` exists in module `%s`. Import it with:\n  use %s::%s;", name, modName, strings.ReplaceAll(modInfo.Name, "/", "::"), name)
 					break
 				}
 			}
@@ -216,7 +251,7 @@ func (c *Checker) reportUndefinedIdentifier(name string, span lexer.Span, scope
 		for modName, modInfo := range c.Modules {
 			if modInfo.Scope != nil {
 				if sym := modInfo.Scope.Lookup(name); sym != nil {
-					help = fmt.Sprintf("`%s` exists in module `%s`. Import it with:\n  mod %s;", name, modName, modName)
+					help = fmt.Sprintf("`%s` exists in module `%s`. Import it with:\n  use %s::%s;", name, modName, strings.ReplaceAll(modInfo.Name, "/", "::"), name)
 					foundInModule = true
 					break
 				}
@@ -235,6 +270,10 @@ func (c *Checker) reportUndefinedIdentifier(name string, span lexer.Span, scope
 		secondarySpans,
 		help,
 	)
+	if suggestion != "" {
+		c.attachFixes(c.replaceFix(fmt.Sprintf("Change to `%s`", suggestion), span, suggestion))
+	}
+	c.attachFixes(c.importFixes(name, span)...)
 }
 
 // findSimilarIdentifierWithSymbol finds a similar identifier and returns both the name and the symbol.
@@ -810,8 +849,11 @@ func editDistance(s1, s2 string) int {
 func (c *Checker) reportFieldNotFound(targetType Type, fieldName string, fieldSpan lexer.Span, structType *Struct) {
 	msg := fmt.Sprintf("type `%s` has no field `%s`", targetType, fieldName)
 
-	// Try to find similar field name
+	// Try to find similar field name, or method name for a misspelled call
 	similarField := c.findSimilarField(fieldName, structType.Fields)
+	if similarField == "" {
+		similarField = c.findSimilarMethodName(targetType, fieldName)
+	}
 	fieldList := c.listFieldNames(structType.Fields)
 
 	var help string
@@ -829,6 +871,9 @@ func (c *Checker) reportFieldNotFound(targetType Type, fieldName string, fieldSp
 		nil,
 		help,
 	)
+	if similarField != "" {
+		c.attachFixes(c.replaceFix(fmt.Sprintf("Change to `%s`", similarField), fieldSpan, similarField))
+	}
 }
 
 // reportMethodNotFound reports a method not found error with suggestions for similar method names.
@@ -870,6 +915,9 @@ func (c *Checker) reportMethodNotFound(targetType Type, methodName string, metho
 		nil,
 		help,
 	)
+	if similarMethod != "" {
+		c.attachFixes(c.replaceFix(fmt.Sprintf("Change to `%s`", similarMethod), methodSpan, similarMethod))
+	}
 }
 
 // reportMissingField reports a missing field error in a struct literal with helpful suggestions.
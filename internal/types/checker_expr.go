@@ -11,11 +11,37 @@ import (
 )
 
 func (c *Checker) checkExpr(expr ast.Expr, scope *Scope, inUnsafe bool) Type {
+	outer := c.frame
+	c.frame = errorFrame{}
 	typ := c.checkExprInternal(expr, scope, inUnsafe)
+	if len(c.infers) > 0 {
+		// Uses see the types that inference variables were fixed to
+		typ = Substitute(typ, nil)
+		c.placeInfers(typ, expr.Span())
+	}
+	// An expression whose errors were reported, or that is void because an
+	// operand has errors, has no meaningful type
+	if (c.frame.errors || c.frame.poisoned) && (typ == nil || typ == TypeVoid) {
+		typ = TypeError
+	}
+	c.frame = outer
+	if c.poisons(typ) {
+		c.frame.poisoned = true
+	}
 	c.ExprTypes[expr] = typ
 	return typ
 }
 
+// poisons reports whether typ is, or is built from, TypeError, like the map
+// type of a literal with a key that has errors. Such types only arise once
+// errors have been reported.
+func (c *Checker) poisons(typ Type) bool {
+	if typ == TypeError {
+		return true
+	}
+	return len(c.Errors) > 0 && typ != nil && strings.Contains(typ.String(), string(Error))
+}
+
 func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool) Type {
 	switch e := expr.(type) {
 	case *ast.UnsafeBlock:
@@ -32,10 +58,19 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 		return TypeNil
 	case *ast.Ident:
 		sym := scope.Lookup(e.Name)
+		if sym == nil {
+			sym = c.lookupPrelude(e.Name)
+		}
 		if sym == nil {
 			c.reportUndefinedIdentifier(e.Name, e.Span(), scope)
 			return TypeVoid
 		}
+		c.recordUse(e, sym)
+		c.recordConst(e, sym)
+		c.checkStaticUse(e, sym, inUnsafe)
+		if c.spawnScope != nil {
+			c.checkSpawnCapture(e, sym)
+		}
 		return sym.Type
 	case *ast.InfixExpr:
 		// Handle static method access: Type::Method
@@ -93,8 +128,30 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 				}
 			}
 
+			// Handle module access: module::symbol and module::sub::symbol
+			if module := c.moduleOfExpr(e.Left, scope); module != nil {
+				if rightIdent, ok := e.Right.(*ast.Ident); ok {
+					c.ExprTypes[e.Left] = &Named{Name: module.Name}
+					if sym := c.moduleItem(module, rightIdent.Name, rightIdent.Span()); sym != nil {
+						c.recordUse(rightIdent, sym)
+						c.recordConst(e, sym)
+						return sym.Type
+					}
+					c.reportError(fmt.Sprintf("symbol '%s' not found in module '%s'", rightIdent.Name, module.Name), e.Right.Span())
+					return TypeVoid
+				}
+			}
+
 			// Handle user-defined generic types: Result[int, string]::Ok
 			leftType := c.resolveTypeFromExpr(e.Left)
+			if ident, ok := e.Left.(*ast.Ident); ok {
+				// Self and the type parameters of the function are in scope
+				if sym := scope.Lookup(ident.Name); sym != nil {
+					if _, ok := sym.Type.(*TypeParam); ok || ident.Name == "Self" {
+						leftType = sym.Type
+					}
+				}
+			}
 			c.ExprTypes[e.Left] = leftType
 
 			if genInst, ok := leftType.(*GenericInstance); ok {
@@ -139,33 +196,16 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 								}
 							}
 						}
+						if method := c.lookupMethod(enumType, rightIdent.Name); method != nil {
+							return staticMethodOf(enumType.TypeParams, genInst.Args, method)
+						}
 					}
 				} else if structType, ok := genInst.Base.(*Struct); ok {
 					// Handle generic struct static method: HashMap[int, int]::new
 					if rightIdent, ok := e.Right.(*ast.Ident); ok {
 						method := c.lookupMethod(structType, rightIdent.Name)
 						if method != nil {
-							// Substitute type params
-							subst := make(map[string]Type)
-							for i, tp := range structType.TypeParams {
-								if i < len(genInst.Args) {
-									subst[tp.Name] = genInst.Args[i]
-								}
-							}
-
-							// Return substituted function type
-							newParams := []Type{}
-							for _, p := range method.Params {
-								newParams = append(newParams, Substitute(p, subst))
-							}
-							newReturn := Substitute(method.Return, subst)
-
-							return &Function{
-								Unsafe:   method.Unsafe,
-								Params:   newParams,
-								Return:   newReturn,
-								Receiver: nil, // Static call
-							}
+							return staticMethodOf(structType.TypeParams, genInst.Args, method)
 						}
 					}
 				}
@@ -181,13 +221,18 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 
 							// Handle generic enum
 							if len(enumType.TypeParams) > 0 {
-								// If it's a unit variant (no payload), we can't infer type args from arguments.
-								// We return the raw enum type for now, but this will likely fail assignment to specific instance.
-								// The user should use explicit instantiation: Enum[T]::Variant
+								// A unit variant has no arguments to infer the type
+								// arguments from: they are left to the expected type,
+								// as in `let c: Option[int] = Option::None;`
 								if len(params) == 0 {
-									// TODO: We could potentially return a special "UninferredGeneric" type
-									// but for now, we'll return the raw enum and rely on the user to instantiate.
-									return enumType
+									if variant.ReturnType != nil {
+										return variant.ReturnType
+									}
+									args := make([]Type, len(enumType.TypeParams))
+									for i, tp := range enumType.TypeParams {
+										args[i] = c.newInfer(tp.Name)
+									}
+									return &GenericInstance{Base: enumType, Args: args}
 								}
 
 								// For variants with payload, we return a generic function.
@@ -245,28 +290,40 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 							}
 						}
 					}
+					// Not a variant: an associated function, Color::from_int
+					if method := c.lookupMethod(enumType, rightIdent.Name); method != nil {
+						return method
+					}
 				}
 			} else if structType, ok := leftType.(*Struct); ok {
 				// Handle non-generic Struct::Method
 				if rightIdent, ok := e.Right.(*ast.Ident); ok {
 					method := c.lookupMethod(structType, rightIdent.Name)
+					if method != nil && len(structType.TypeParams) > 0 {
+						// Vec::new(): the type arguments are left to later uses
+						args := make([]Type, len(structType.TypeParams))
+						for i, tp := range structType.TypeParams {
+							args[i] = c.newInfer(tp.Name)
+						}
+						c.ExprTypes[e.Left] = &GenericInstance{Base: structType, Args: args}
+						return staticMethodOf(structType.TypeParams, args, method)
+					}
 					if method != nil {
 						return method
 					}
 				}
-			} else if named, ok := leftType.(*Named); ok {
-				// Handle module access: module::symbol
-				// Check if the named type corresponds to a loaded module
-				if moduleInfo, ok := c.Modules[named.Name]; ok {
-					if rightIdent, ok := e.Right.(*ast.Ident); ok {
-						sym := moduleInfo.Scope.Lookup(rightIdent.Name)
-						if sym != nil {
-							// If it's a function, we might need to check visibility
-							// But for now, just return the type
-							return sym.Type
-						}
-						c.reportError(fmt.Sprintf("symbol '%s' not found in module '%s'", rightIdent.Name, named.Name), e.Right.Span())
-						return TypeVoid
+			} else if typeParam, ok := leftType.(*TypeParam); ok {
+				// T::default() in a function generic over T: Default
+				if rightIdent, ok := e.Right.(*ast.Ident); ok {
+					if method := c.lookupMethod(typeParam, rightIdent.Name); method != nil {
+						return method
+					}
+				}
+			} else if trait := traitOf(leftType); trait != nil {
+				// Default::default(): Self is left to the expected type
+				if rightIdent, ok := e.Right.(*ast.Ident); ok {
+					if method := c.traitFunction(e, trait, rightIdent, scope); method != nil {
+						return method
 					}
 				}
 			}
@@ -283,10 +340,12 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 
 		left := c.checkExpr(e.Left, scope, inUnsafe)
 		right := c.checkExpr(e.Right, scope, inUnsafe)
+		left, right = c.adaptLiteralOperand(e, left, right)
 		if left != right {
 			// Special case for channel send: ch <- val
 			if e.Op == lexer.LARROW {
 				if ch, ok := left.(*Channel); ok {
+					c.checkSendAfterClose(e.Left, e.Span(), scope)
 					if ch.Dir == RecvOnly {
 						help := c.generateChannelErrorHelp("cannot send to receive-only channel", ch, false, true)
 						c.reportErrorWithCode(
@@ -334,7 +393,11 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 				isArithmetic = true
 			}
 
-			if isComparison || isArithmetic {
+			if isNumericType(left) && isNumericType(right) {
+				if !sameNumericType(left, right) {
+					c.reportNumericMismatch(e, left, right)
+				}
+			} else if isComparison || isArithmetic {
 				if !c.assignableTo(left, right) && !c.assignableTo(right, left) {
 					c.reportTypeMismatch(left, right, e.Span(), "binary expression")
 				}
@@ -389,6 +452,7 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 			return TypeVoid
 		} else if e.Op == lexer.AMPERSAND {
 			elemType := c.checkExpr(e.Expr, scope, inUnsafe)
+			c.checkStaticBorrow(e.Expr, scope)
 
 			// Borrow check: &x
 			if sym := c.getSymbol(e.Expr, scope); sym != nil {
@@ -404,7 +468,7 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 						)
 					}
 				}
-				scope.AddBorrow(sym, BorrowShared, e.Span())
+				c.borrow(sym, BorrowShared, e.Span())
 			}
 
 			return &Reference{Mutable: false, Elem: elemType}
@@ -412,6 +476,7 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 			// Mutable reference: &mut x
 			// 1. Check operand type
 			elemType := c.checkExpr(e.Expr, scope, inUnsafe)
+			c.checkStaticBorrow(e.Expr, scope)
 
 			// 2. Verify l-value (addressable)
 			if !c.isLValue(e.Expr) {
@@ -449,7 +514,7 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 						nil,
 					)
 				}
-				scope.AddBorrow(sym, BorrowExclusive, e.Span())
+				c.borrow(sym, BorrowExclusive, e.Span())
 			}
 
 			return &Reference{Mutable: true, Elem: elemType}
@@ -482,13 +547,32 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 			return TypeVoid
 		}
 		return c.checkExpr(e.Expr, scope, inUnsafe)
+	case *ast.SpawnExpr:
+		retType := c.checkExpr(e.Call, scope, inUnsafe)
+		c.checkSpawnArgs(e.Call.Args)
+		return &JoinHandle{Elem: retType}
 	case *ast.CallExpr:
+		if ident, ok := e.Callee.(*ast.Ident); ok && ident.Name == "close" && scope.Lookup("close") == c.GlobalScope.Lookup("close") {
+			return c.checkCloseCall(e, scope, inUnsafe)
+		}
+		if ident, ok := e.Callee.(*ast.Ident); ok && ident.Name == "enum_tag" && scope.Lookup("enum_tag") == c.GlobalScope.Lookup("enum_tag") {
+			return c.checkEnumTagCall(e, scope, inUnsafe)
+		}
+		if ident, ok := e.Callee.(*ast.Ident); ok && isArithmeticBuiltin(ident.Name) && scope.Lookup(ident.Name) == c.GlobalScope.Lookup(ident.Name) {
+			return c.checkArithmeticBuiltinCall(e, ident.Name, scope, inUnsafe)
+		}
+
 		// Check callee
 		// Special handling for methods on Optional types (e.g. unwrap, expect)
 		// We peek into Callee to see if it's a FieldExpr on an Optional
 		if fieldExpr, ok := e.Callee.(*ast.FieldExpr); ok {
 			targetType := c.checkExpr(fieldExpr.Target, scope, inUnsafe)
 
+			// Pointer arithmetic on the raw pointer itself, before auto-deref
+			if ptr, ok := targetType.(*Pointer); ok && fieldExpr.Field.Name == "offset" {
+				return c.checkPtrOffset(e, ptr, scope, inUnsafe)
+			}
+
 			// AUTO-DEREF: Unwrap references and pointers for method lookup
 			// Keep dereferencing until we reach a concrete type
 			for {
@@ -556,11 +640,54 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 					}
 					return opt.Elem
 				default:
-					c.reportMethodNotFound(targetType, fieldExpr.Field.Name, fieldExpr.Span())
+					c.reportMethodNotFound(targetType, fieldExpr.Field.Name, fieldExpr.Field.Span())
 					return TypeVoid
 				}
 			}
 
+			if ch, ok := targetType.(*Channel); ok && fieldExpr.Field.Name == "recv" {
+				if ch.Dir == SendOnly {
+					c.reportErrorWithCode(
+						"cannot receive from send-only channel",
+						e.Span(),
+						diag.CodeTypeInvalidOperation,
+						c.generateChannelErrorHelp("cannot receive from send-only channel", ch, true, false),
+						nil,
+					)
+				}
+				if len(e.Args) != 0 {
+					c.reportErrorWithCode(
+						"recv takes no arguments",
+						e.Span(),
+						diag.CodeTypeInvalidOperation,
+						"call `ch.recv()`; it returns nil once the channel is closed and drained",
+						nil,
+					)
+				}
+				return &Optional{Elem: ch.Elem}
+			}
+
+			if handle, ok := targetType.(*JoinHandle); ok {
+				if fieldExpr.Field.Name != "join" {
+					c.reportMethodNotFound(targetType, fieldExpr.Field.Name, fieldExpr.Field.Span())
+					return TypeVoid
+				}
+				if len(e.Args) != 0 {
+					c.reportErrorWithCode(
+						"join takes no arguments",
+						e.Span(),
+						diag.CodeTypeInvalidOperation,
+						"call `handle.join()` to wait for the spawned function",
+						nil,
+					)
+				}
+				return handle.Elem
+			}
+
+			if m, ok := targetType.(*Map); ok {
+				return c.checkMapMethod(e, fieldExpr.Field, m, scope, inUnsafe)
+			}
+
 			// AUTO-BORROWING: Check if this is a method call on a regular type
 			method := c.lookupMethod(targetType, fieldExpr.Field.Name)
 			if method != nil && method.Receiver != nil {
@@ -584,6 +711,14 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 							method = substitutedMethod
 						}
 					}
+				} else if slice, ok := targetType.(*Slice); ok {
+					// Methods on []T come from core's Slice[T]
+					if s := c.coreSliceStruct(); s != nil && len(s.TypeParams) == 1 {
+						subst := map[string]Type{s.TypeParams[0].Name: slice.Elem}
+						if substitutedMethod, ok := Substitute(method, subst).(*Function); ok {
+							method = substitutedMethod
+						}
+					}
 				}
 
 				// This is a method call - perform auto-borrowing
@@ -634,6 +769,7 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 						// NOTE: Don't register borrow for method calls - they're temporary
 					}
 				}
+				c.borrowReceiver(e, fieldExpr.Target, method, scope)
 
 				// Check argument types against method parameters
 				var argTypes []Type
@@ -671,6 +807,33 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 						help,
 					)
 				}
+				if len(method.TypeParams) > 0 && len(argTypes) == len(method.Params) {
+					// fn map[U](self, f: fn(T) -> U) is instantiated from the arguments
+					inferred, err := c.inferMethodTypeArgs(method, argTypes)
+					if err != nil {
+						c.reportErrorWithCode(
+							fmt.Sprintf("type inference failed for method %s: %v", fieldExpr.Field.Name, err),
+							e.Span(),
+							diag.CodeTypeInvalidGenericArgs,
+							"ensure the argument types match the method's type parameters",
+							nil,
+						)
+						return TypeVoid
+					}
+					subst := make(map[string]Type)
+					for i, tp := range method.TypeParams {
+						subst[tp.Name] = inferred[i]
+						for _, bound := range tp.Bounds {
+							if err := Satisfies(inferred[i], []Type{bound}, c.Env); err != nil {
+								c.reportConstraintError(inferred[i], bound, lexer.Span{}, tp.Name, lexer.Span{}, e.Span())
+							}
+						}
+					}
+					c.CallTypeArgs[e] = inferred
+					if substitutedMethod, ok := Substitute(method, subst).(*Function); ok {
+						method = substitutedMethod
+					}
+				}
 				for i := 0; i < len(argTypes) && i < len(method.Params); i++ {
 					if !c.assignableTo(argTypes[i], method.Params[i]) {
 						c.reportTypeMismatch(method.Params[i], argTypes[i], e.Args[i].Span(), fmt.Sprintf("argument %d to method %s", i+1, fieldExpr.Field.Name))
@@ -742,6 +905,12 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 
 			// Check argument count for non-generic functions
 			if len(fn.TypeParams) == 0 {
+				// A lone void parameter takes no argument, e.g. Ok() for Result[void, E]
+				if len(argTypes) == 0 && len(fn.Params) == 1 {
+					if p, ok := fn.Params[0].(*Primitive); ok && p.Kind == Void {
+						return fn.Return
+					}
+				}
 				if len(argTypes) != len(fn.Params) {
 					// Build function signature string for help text
 					paramStrs := make([]string, len(fn.Params))
@@ -1056,7 +1225,7 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 				return field.Type
 			}
 			// Field not found - report error with suggestion
-			c.reportFieldNotFound(targetType, e.Field.Name, e.Span(), s)
+			c.reportFieldNotFound(targetType, e.Field.Name, e.Field.Span(), s)
 			return TypeVoid
 		}
 
@@ -1153,8 +1322,7 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 		)
 		return TypeVoid
 	case *ast.BlockExpr:
-		c.checkBlock(e, scope, inUnsafe)
-		return TypeVoid // Simplified
+		return c.checkBlock(e, scope, inUnsafe)
 	case *ast.ArrayLiteral:
 		var explicitType Type
 		if e.Type != nil {
@@ -1365,9 +1533,10 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 			}
 		}
 
-		// Check function body
+		// Check function body. A body that always returns has type `!`, but
+		// the literal still returns to its caller.
 		returnType := c.checkBlock(e.Body, fnScope, inUnsafe)
-		if returnType == nil {
+		if returnType == nil || returnType == TypeNever {
 			returnType = TypeVoid
 		}
 
@@ -1519,6 +1688,7 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 	case *ast.IfExpr:
 		// Check all if clauses - all branches must return the same type
 		var resultType Type
+		var branchBorrows []activeBorrow
 		for i, clause := range e.Clauses {
 			condType := c.checkExpr(clause.Condition, scope, inUnsafe)
 			if condType != TypeBool {
@@ -1530,8 +1700,9 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 					nil,
 				)
 			}
-			branchType := c.checkBlock(clause.Body, scope, inUnsafe)
-			if i == 0 {
+			branchType := c.checkBranch(clause.Body, scope, inUnsafe, &branchBorrows)
+			// A branch of type `!` does not decide the type of the if
+			if i == 0 || resultType == TypeNever {
 				resultType = branchType
 			} else {
 				if !c.assignableTo(branchType, resultType) && !c.assignableTo(resultType, branchType) {
@@ -1547,8 +1718,8 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 		}
 		// Check else branch if present
 		if e.Else != nil {
-			elseType := c.checkBlock(e.Else, scope, inUnsafe)
-			if resultType != nil {
+			elseType := c.checkBranch(e.Else, scope, inUnsafe, &branchBorrows)
+			if resultType != nil && resultType != TypeNever {
 				if !c.assignableTo(elseType, resultType) && !c.assignableTo(resultType, elseType) {
 					c.reportErrorWithCode(
 						fmt.Sprintf("else branch returns %s, but if branches returned %s", elseType, resultType),
@@ -1562,7 +1733,9 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 				resultType = elseType
 			}
 		}
-		if resultType == nil {
+		c.joinBranches(branchBorrows)
+		// Without an else, control falls through when no condition holds
+		if resultType == nil || (resultType == TypeNever && e.Else == nil) {
 			return TypeVoid
 		}
 		return resultType
@@ -1873,7 +2046,8 @@ func (c *Checker) checkExprInternal(expr ast.Expr, scope *Scope, inUnsafe bool)
 	case *ast.AssignExpr:
 		// Check both target and value expressions
 		targetType := c.checkExpr(e.Target, scope, inUnsafe)
-		valueType := c.checkExpr(e.Value, scope, inUnsafe)
+		valueType := c.adaptLiteral(e.Value, c.checkExpr(e.Value, scope, inUnsafe), targetType)
+		c.checkStaticAssign(e.Target, scope)
 
 		// Verify assignment compatibility
 		if !c.assignableTo(valueType, targetType) {
@@ -1954,6 +2128,11 @@ func (c *Checker) checkMatchExpr(expr *ast.MatchExpr, scope *Scope, inUnsafe boo
 	}
 	gadtDivergence := false
 
+	// Only one arm runs, so the borrows the arms leave are joined after all
+	// of them are checked instead of conflicting with each other
+	var armBorrows []activeBorrow
+	defer func() { c.joinBranches(armBorrows) }()
+
 	for _, arm := range expr.Arms {
 		var matchedVariant *Variant
 		// Create scope for the arm
@@ -1963,8 +2142,8 @@ func (c *Checker) checkMatchExpr(expr *ast.MatchExpr, scope *Scope, inUnsafe boo
 		if _, ok := arm.Pattern.(*ast.WildcardPattern); ok {
 			hasDefault = true
 			// Check body
-			bodyType := c.checkBlock(arm.Body, armScope, inUnsafe)
-			if returnType == nil {
+			bodyType := c.checkBranch(arm.Body, armScope, inUnsafe, &armBorrows)
+			if returnType == nil || returnType == TypeNever {
 				returnType = bodyType
 			} else {
 				if !c.assignableTo(bodyType, returnType) && !c.assignableTo(returnType, bodyType) {
@@ -1985,11 +2164,13 @@ func (c *Checker) checkMatchExpr(expr *ast.MatchExpr, scope *Scope, inUnsafe boo
 			// Check pattern for Enum
 			// Pattern is likely a CallExpr (Variant(args)) or Ident/FieldExpr (Variant)
 			var variantName string
+			var variantSpan lexer.Span
 			var args []ast.Pattern
 
 			switch p := arm.Pattern.(type) {
 			case *ast.EnumPattern:
 				variantName = p.Variant.Name
+				variantSpan = p.Variant.Span()
 				// Convert []ast.Pattern to []ast.Expr is not possible directly.
 				// But we need to check args recursively.
 				// The existing code expects args to be []ast.Expr to check them later?
@@ -2048,6 +2229,9 @@ func (c *Checker) checkMatchExpr(expr *ast.MatchExpr, scope *Scope, inUnsafe boo
 					suggestionMsg,
 					nil,
 				)
+				if suggestion != "" {
+					c.attachFixes(c.replaceFix(fmt.Sprintf("Change to `%s`", suggestion), variantSpan, suggestion))
+				}
 				continue
 			}
 
@@ -2234,6 +2418,9 @@ func (c *Checker) checkMatchExpr(expr *ast.MatchExpr, scope *Scope, inUnsafe boo
 			case *ast.WildcardPattern:
 				// Always matches
 			case *ast.VarPattern:
+				if c.constPattern(p, resolvedType, armScope) {
+					break
+				}
 				// Binds variable
 				armScope.Insert(p.Name.Name, &Symbol{
 					Name:    p.Name.Name,
@@ -2247,10 +2434,10 @@ func (c *Checker) checkMatchExpr(expr *ast.MatchExpr, scope *Scope, inUnsafe boo
 		}
 
 		// Check body
-		bodyType := c.checkBlock(arm.Body, armScope, inUnsafe)
+		bodyType := c.checkBranch(arm.Body, armScope, inUnsafe, &armBorrows)
 
-		// Unify return types
-		if returnType == nil {
+		// Unify return types; an arm of type `!` does not decide the type
+		if returnType == nil || returnType == TypeNever {
 			returnType = bodyType
 		} else {
 			if !c.assignableTo(bodyType, returnType) && !c.assignableTo(returnType, bodyType) {
@@ -2335,6 +2522,7 @@ func (c *Checker) checkMatchExpr(expr *ast.MatchExpr, scope *Scope, inUnsafe boo
 
 	// Check exhaustiveness
 	if isEnum {
+		var missing []Variant
 		for _, v := range enumType.Variants {
 			// Check if variant is possible given GADT constraints
 			isPossible := true
@@ -2346,13 +2534,24 @@ func (c *Checker) checkMatchExpr(expr *ast.MatchExpr, scope *Scope, inUnsafe boo
 			}
 
 			if isPossible && !coveredVariants[v.Name] && !hasDefault {
-				c.reportErrorWithCode(
-					fmt.Sprintf("match is not exhaustive, missing variant: %s", v.Name),
-					expr.Span(),
-					diag.CodeTypeNonExhaustiveMatch,
-					fmt.Sprintf("add a match arm for variant `%s` or use a default case `_`", v.Name),
-					nil,
-				)
+				missing = append(missing, v)
+			}
+		}
+		// One fix per missing variant, then one adding them all
+		fixes := c.matchArmFixes(expr, enumType, missing)
+		for i, v := range missing {
+			c.reportErrorWithCode(
+				fmt.Sprintf("match is not exhaustive, missing variant: %s", v.Name),
+				expr.Span(),
+				diag.CodeTypeNonExhaustiveMatch,
+				fmt.Sprintf("add a match arm for variant `%s` or use a default case `_`", v.Name),
+				nil,
+			)
+			if i < len(fixes) {
+				c.attachFixes(fixes[i])
+			}
+			if len(fixes) > len(missing) {
+				c.attachFixes(fixes[len(missing)])
 			}
 		}
 	} else if isOptional {
@@ -2501,19 +2700,23 @@ func (c *Checker) resolveEnumTypeFromPattern(pattern ast.Expr, scope *Scope) Typ
 // checkNestedEnumPattern recursively checks a nested enum pattern and binds variables.
 func (c *Checker) checkNestedEnumPattern(callExpr *ast.CallExpr, enumType Type, scope *Scope) {
 	// Extract variant name
-	var variantName string
+	var variantIdent *ast.Ident
 	switch callee := callExpr.Callee.(type) {
 	case *ast.Ident:
-		variantName = callee.Name
+		variantIdent = callee
 	case *ast.FieldExpr:
-		variantName = callee.Field.Name
+		variantIdent = callee.Field
 	case *ast.InfixExpr:
 		if callee.Op == lexer.DOUBLE_COLON {
 			if ident, ok := callee.Right.(*ast.Ident); ok {
-				variantName = ident.Name
+				variantIdent = ident
 			}
 		}
 	}
+	var variantName string
+	if variantIdent != nil {
+		variantName = variantIdent.Name
+	}
 
 	if variantName == "" {
 		return
@@ -2569,6 +2772,9 @@ func (c *Checker) checkNestedEnumPattern(callExpr *ast.CallExpr, enumType Type,
 			help,
 			nil,
 		)
+		if suggestion != "" {
+			c.attachFixes(c.replaceFix(fmt.Sprintf("Change to `%s`", suggestion), variantIdent.Span(), suggestion))
+		}
 		return
 	}
 
@@ -2660,6 +2866,10 @@ func (c *Checker) isMutable(expr ast.Expr, scope *Scope) bool {
 		if decl, ok := sym.DefNode.(*ast.LetStmt); ok {
 			return decl.Mutable
 		}
+		// Bindings of type &mut T (e.g. `&mut self`) can be mutated through
+		if ref, ok := sym.Type.(*Reference); ok && ref.Mutable {
+			return true
+		}
 		// Function params? For now assume params are immutable unless marked mut (not supported yet)
 		// TODO: Support 'mut' params or 'var' params
 		return false
@@ -2726,9 +2936,10 @@ func (c *Checker) isValidCast(src, dst Type) bool {
 	if isInt(src) && isPointer(dst) {
 		return true
 	}
-	// Allow enum <-> int casts
-	if _, ok := src.(*Enum); ok && isInt(dst) {
-		return true
+	// Allow enum <-> int casts; an enum with payloads has more to it than
+	// its tag, which enum_tag gives
+	if enum, ok := src.(*Enum); ok && isInt(dst) {
+		return enum.Fieldless()
 	}
 	if isInt(src) {
 		if _, ok := dst.(*Enum); ok {
@@ -2739,6 +2950,37 @@ func (c *Checker) isValidCast(src, dst Type) bool {
 	return false
 }
 
+// checkPtrOffset checks ptr.offset(n), which moves ptr by n elements and
+// is only allowed in unsafe code.
+func (c *Checker) checkPtrOffset(call *ast.CallExpr, ptr *Pointer, scope *Scope, inUnsafe bool) Type {
+	if !inUnsafe {
+		help := "wrap the pointer arithmetic in an unsafe block:\n  unsafe {\n    let next = ptr.offset(1);\n  }"
+		c.reportErrorWithCode("pointer offset requires unsafe block", call.Span(), diag.CodeTypeInvalidOperation, help, nil)
+	}
+	if len(call.Args) != 1 {
+		c.reportErrorWithCode(fmt.Sprintf("offset takes 1 argument, got %d", len(call.Args)), call.Span(),
+			diag.CodeTypeInvalidOperation, "pass the number of elements to move by, as in `ptr.offset(1)`", nil)
+		return ptr
+	}
+	argType := c.checkExpr(call.Args[0], scope, inUnsafe)
+	if !c.poisons(argType) && !isIntegerType(argType) {
+		c.reportErrorWithCode(fmt.Sprintf("offset must be an integer, got %s", argType), call.Args[0].Span(),
+			diag.CodeTypeMismatch, "the offset counts elements of the pointed-to type and may be negative", nil)
+	}
+	return ptr
+}
+
+// isIntegerType reports whether t is one of the integer primitives.
+func isIntegerType(t Type) bool {
+	if p, ok := t.(*Primitive); ok {
+		switch p.Kind {
+		case Int, Int8, Int32, Int64, U8, U16, U32, U64, U128, Usize:
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Checker) getSymbol(expr ast.Expr, scope *Scope) *Symbol {
 	switch e := expr.(type) {
 	case *ast.Ident:
@@ -2776,11 +3018,67 @@ func (c *Checker) getTypeName(typ Type) string {
 		return c.getTypeName(t.Base)
 	case *Slice:
 		return "Slice"
+	case *Primitive:
+		if t.Kind == String {
+			return "string"
+		}
+		return ""
 	default:
 		return ""
 	}
 }
 
+// traitOf returns the trait t names, or nil
+func traitOf(t Type) *Trait {
+	if named, ok := t.(*Named); ok && named.Ref != nil {
+		t = named.Ref
+	}
+	trait, _ := t.(*Trait)
+	return trait
+}
+
+// traitFunction returns the type of the function of trait named by the
+// path Trait::name, as in Default::default(). Self is left to an inference
+// variable fixed by the expected type, which must implement the trait.
+func (c *Checker) traitFunction(path *ast.InfixExpr, trait *Trait, name *ast.Ident, scope *Scope) *Function {
+	for _, m := range trait.Methods {
+		if m.Name != name.Name {
+			continue
+		}
+		self := c.newInfer("Self")
+		self.Span = path.Span()
+		c.ExprTypes[path.Left] = self
+		c.selfBounds = append(c.selfBounds, selfBound{self: self, trait: trait, scope: scope})
+		fn := &Function{TypeParams: m.TypeParams, Params: m.Params, Return: m.Return}
+		return Substitute(fn, map[string]Type{"Self": self}).(*Function)
+	}
+	return nil
+}
+
+// staticMethodOf returns the type of method, called as a static method of
+// the generic struct or enum with the type parameters typeParams
+// instantiated with args.
+func staticMethodOf(typeParams []TypeParam, args []Type, method *Function) *Function {
+	subst := make(map[string]Type)
+	for i, tp := range typeParams {
+		if i < len(args) {
+			subst[tp.Name] = args[i]
+		}
+	}
+
+	newParams := []Type{}
+	for _, p := range method.Params {
+		newParams = append(newParams, Substitute(p, subst))
+	}
+
+	return &Function{
+		Unsafe:   method.Unsafe,
+		Params:   newParams,
+		Return:   Substitute(method.Return, subst),
+		Receiver: nil, // Static call
+	}
+}
+
 // lookupMethod finds a method on a given type
 func (c *Checker) lookupMethod(typ Type, methodName string) *Function {
 	// Unwrap named types
@@ -2857,12 +3155,29 @@ func (c *Checker) lookupMethod(typ Type, methodName string) *Function {
 		return nil
 	}
 
-	if methods, ok := c.MethodTable[typeName]; ok {
-		return methods[methodName]
+	if method := c.MethodTable[typeName][methodName]; method != nil {
+		return method
+	}
+	// Builtin types get their methods from the stdlib on first use
+	if c.loadPreludeMethods(typeName) {
+		return c.MethodTable[typeName][methodName]
 	}
 	return nil
 }
 
+// MethodsOf returns the methods declared for t by name, or nil if t has
+// none. Methods of prelude types are loaded from the stdlib on first use.
+func (c *Checker) MethodsOf(t Type) map[string]*Function {
+	typeName := c.getTypeName(t)
+	if typeName == "" {
+		return nil
+	}
+	if _, ok := c.MethodTable[typeName]; !ok {
+		c.loadPreludeMethods(typeName)
+	}
+	return c.MethodTable[typeName]
+}
+
 // checkFunctionLiteralWithType checks a function literal against an expected function type.
 // It infers parameter types from the expected type if they're not provided in the literal.
 func (c *Checker) checkFunctionLiteralWithType(fnLit *ast.FunctionLiteral, expectedType *Function, scope *Scope, inUnsafe bool) Type {
@@ -3074,6 +3389,9 @@ func (c *Checker) checkPattern(pattern ast.Pattern, expectedType Type, scope *Sc
 		return
 
 	case *ast.VarPattern:
+		if c.constPattern(p, expectedType, scope) {
+			return
+		}
 		// Binds variable
 		scope.Insert(p.Name.Name, &Symbol{
 			Name:    p.Name.Name,
@@ -3251,14 +3569,41 @@ func (c *Checker) checkCastExpr(expr *ast.CastExpr, scope *Scope, inUnsafe bool)
 
 	// Validate cast
 	if !c.isValidCast(srcType, dstType) {
+		help := "invalid cast"
+		if _, ok := srcType.(*Enum); ok {
+			help = "only enums without payloads cast to integers; use `enum_tag(value)` for the variant index"
+		}
 		c.reportErrorWithCode(
 			fmt.Sprintf("cannot cast type %s to %s", srcType, dstType),
 			expr.Span(),
 			diag.CodeTypeInvalidOperation,
-			"invalid cast",
+			help,
+			nil,
+		)
+	} else if !inUnsafe && makesRawPointer(srcType, dstType) {
+		help := fmt.Sprintf("wrap the cast in an unsafe block:\n  unsafe {\n    let p = value as %s;\n  }", dstType)
+		c.reportErrorWithCode(
+			fmt.Sprintf("cast from %s to %s requires unsafe block", srcType, dstType),
+			expr.Span(),
+			diag.CodeTypeInvalidOperation,
+			help,
 			nil,
 		)
 	}
 
 	return dstType
 }
+
+// makesRawPointer reports whether casting src to dst produces a raw pointer
+// the compiler cannot vouch for, or a reference from one. Casting a raw
+// pointer to its own type or to an integer is safe.
+func makesRawPointer(src, dst Type) bool {
+	if ptr, ok := dst.(*Pointer); ok {
+		return src.String() != ptr.String()
+	}
+	if _, ok := src.(*Pointer); ok {
+		_, toRef := dst.(*Reference)
+		return toRef
+	}
+	return false
+}
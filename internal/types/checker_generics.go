@@ -28,7 +28,8 @@ func (c *Checker) inferTypeArgs(typeParams []TypeParam, paramTypes []Type, argTy
 	for i, tp := range typeParams {
 		inferred, ok := subst[tp.Name]
 		if !ok {
-			return nil, fmt.Errorf("cannot infer type for parameter %s", tp.Name)
+			// Left to later uses, like T of `fn empty[T]() -> Vec[T]`
+			inferred = c.newInfer(tp.Name)
 		}
 		result[i] = inferred
 	}
@@ -36,6 +37,51 @@ func (c *Checker) inferTypeArgs(typeParams []TypeParam, paramTypes []Type, argTy
 	return result, nil
 }
 
+// methodTypeParams returns the type parameters a method declares beyond
+// those of its impl, such as U in `fn map[U](self, f: fn(T) -> U)`, and a
+// copy of context that resolves them as well.
+func (c *Checker) methodTypeParams(method *ast.FnDecl, context map[string]Type) ([]TypeParam, map[string]Type) {
+	if len(method.TypeParams) == 0 {
+		return nil, context
+	}
+	methodContext := make(map[string]Type, len(context)+len(method.TypeParams))
+	for name, t := range context {
+		methodContext[name] = t
+	}
+	var typeParams []TypeParam
+	for _, tp := range method.TypeParams {
+		astTP, ok := tp.(*ast.TypeParam)
+		if !ok {
+			continue
+		}
+		var bounds []Type
+		for _, b := range astTP.Bounds {
+			bounds = append(bounds, c.resolveTypeWithContext(b, methodContext))
+		}
+		typeParams = append(typeParams, TypeParam{Name: astTP.Name.Name, Bounds: bounds})
+		methodContext[astTP.Name.Name] = &TypeParam{Name: astTP.Name.Name, Bounds: bounds}
+	}
+	return typeParams, methodContext
+}
+
+// inferMethodTypeArgs infers the type arguments of the type parameters
+// method declares itself from the argument types of a call. Parameters that
+// do not mention them are left to the usual assignability check.
+func (c *Checker) inferMethodTypeArgs(method *Function, argTypes []Type) ([]Type, error) {
+	var params, args []Type
+	for i, param := range method.Params {
+		free := CollectFreeTypeVars(param)
+		for _, tp := range method.TypeParams {
+			if free[tp.Name] {
+				params = append(params, param)
+				args = append(args, argTypes[i])
+				break
+			}
+		}
+	}
+	return c.inferTypeArgs(method.TypeParams, params, args)
+}
+
 // inferStructTypeArgs infers type arguments for a generic struct from field values in a struct literal.
 func (c *Checker) inferStructTypeArgs(structType *Struct, fields []*ast.StructLiteralField, scope *Scope, inUnsafe bool) ([]Type, error) {
 	if len(structType.TypeParams) == 0 {
@@ -199,6 +245,25 @@ func (c *Checker) sameBaseType(t1, t2 Type) bool {
 
 // assignableTo checks if a source type can be assigned to a destination type.
 func (c *Checker) assignableTo(src, dst Type) bool {
+	// The error was reported where the TypeError came from
+	if src == TypeError || dst == TypeError {
+		return true
+	}
+	// An expression that never produces a value fits wherever a value is
+	// expected
+	if src == TypeNever {
+		return true
+	}
+	// An open inference variable is fixed by the first type it must match
+	if v, ok := openInfer(dst); ok {
+		return v.fix(src)
+	}
+	if v, ok := openInfer(src); ok {
+		return v.fix(dst)
+	}
+	if len(c.infers) > 0 {
+		src, dst = Substitute(src, nil), Substitute(dst, nil)
+	}
 	// Handle Named types (unwrap aliases)
 	if named, ok := src.(*Named); ok && named.Ref != nil {
 		return c.assignableTo(named.Ref, dst)
@@ -240,6 +305,12 @@ func (c *Checker) assignableTo(src, dst Type) bool {
 				return true
 			}
 		}
+		// Allow T? -> U? when T is assignable to U
+		if srcOpt, ok := src.(*Optional); ok {
+			if c.assignableTo(srcOpt.Elem, dstOpt.Elem) {
+				return true
+			}
+		}
 		// Allow T -> T? (Implicit wrapping)
 		if c.assignableTo(src, dstOpt.Elem) {
 			return true
@@ -279,6 +350,16 @@ func (c *Checker) assignableTo(src, dst Type) bool {
 		}
 	}
 
+	// Handle Reference assignment: &mut T can be used where &T is expected
+	if dstRef, ok := dst.(*Reference); ok {
+		if srcRef, ok := src.(*Reference); ok {
+			if dstRef.Mutable && !srcRef.Mutable {
+				return false
+			}
+			return c.assignableTo(srcRef.Elem, dstRef.Elem)
+		}
+	}
+
 	// Handle Map assignment
 	if dstMap, ok := dst.(*Map); ok {
 		if srcMap, ok := src.(*Map); ok {
@@ -304,6 +385,12 @@ func (c *Checker) assignableTo(src, dst Type) bool {
 		}
 	}
 
+	if srcHandle, ok := src.(*JoinHandle); ok {
+		if dstHandle, ok := dst.(*JoinHandle); ok {
+			return c.assignableTo(srcHandle.Elem, dstHandle.Elem)
+		}
+	}
+
 	// Handle Function types
 	if srcFn, ok := src.(*Function); ok {
 		if dstFn, ok := dst.(*Function); ok {
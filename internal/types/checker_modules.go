@@ -85,6 +85,7 @@ func (c *Checker) processModDecl(modDecl *ast.ModDecl, currentFile *ast.File, pa
 		File:     moduleFile,
 		FilePath: modulePath,
 		Scope:    NewScope(nil),
+		Decl:     modDecl,
 	}
 
 	// Store module info BEFORE processing (so sub-modules can reference it if needed)
@@ -94,7 +95,9 @@ func (c *Checker) processModDecl(modDecl *ast.ModDecl, currentFile *ast.File, pa
 	// Save current state
 	oldCurrentFile := c.CurrentFile
 	oldGlobalScope := c.GlobalScope
+	oldModule := c.module
 	c.CurrentFile = modulePath
+	c.module = moduleName
 
 	// Create a temporary scope for the module (child of global scope for built-ins)
 	moduleScope := NewScope(c.GlobalScope)
@@ -202,6 +205,7 @@ func (c *Checker) processModDecl(modDecl *ast.ModDecl, currentFile *ast.File, pa
 					Name:       d.Name.Name,
 					TypeParams: typeParams,
 					Fields:     fields,
+					Copy:       ast.HasAttribute(d.Attrs, "copy"),
 				},
 				DefNode: d,
 			}
@@ -234,6 +238,17 @@ func (c *Checker) processModDecl(modDecl *ast.ModDecl, currentFile *ast.File, pa
 			if d.Pub {
 				moduleInfo.Scope.Insert(d.Name.Name, symbol)
 			}
+		case *ast.StaticDecl:
+			typ := c.resolveType(d.Type)
+			symbol = &Symbol{
+				Name:    d.Name.Name,
+				Type:    typ,
+				DefNode: d,
+			}
+			c.GlobalScope.Insert(d.Name.Name, symbol)
+			if d.Pub {
+				moduleInfo.Scope.Insert(d.Name.Name, symbol)
+			}
 		case *ast.EnumDecl:
 			// Build type params
 			var typeParams []TypeParam
@@ -267,6 +282,8 @@ func (c *Checker) processModDecl(modDecl *ast.ModDecl, currentFile *ast.File, pa
 					Name:       d.Name.Name,
 					TypeParams: typeParams,
 					Variants:   variants,
+					Repr:       c.enumRepr(d),
+					Copy:       ast.HasAttribute(d.Attrs, "copy"),
 				},
 				DefNode: d,
 			}
@@ -342,77 +359,17 @@ func (c *Checker) processModDecl(modDecl *ast.ModDecl, currentFile *ast.File, pa
 
 			// Process each method in the impl block
 			for _, method := range d.Methods {
-				// Build function type
-				var params []Type
-				var receiver *ReceiverType
-
-				// Check if first parameter is a receiver (self, &self, &mut self)
-				if len(method.Params) > 0 {
-					firstParam := method.Params[0]
-					if firstParam.Name.Name == "self" {
-						// Determine receiver type from parameter type annotation
-						if firstParam.Type != nil {
-							if refType, ok := firstParam.Type.(*ast.ReferenceType); ok {
-								// &self or &mut self
-								receiver = &ReceiverType{
-									IsMutable: refType.Mutable,
-									Type:      targetType,
-								}
-							} else {
-								// self (by value)
-								receiver = &ReceiverType{
-									IsMutable: false,
-									Type:      targetType,
-								}
-							}
-						} else {
-							// No type annotation on self - assume &self
-							receiver = &ReceiverType{
-								IsMutable: false,
-								Type:      targetType,
-							}
-						}
-
-						// Skip the receiver when processing remaining params
-						// Resolve with Self/typeParam context
-						for i := 1; i < len(method.Params); i++ {
-							paramType := c.resolveTypeWithContext(method.Params[i].Type, typeParamMap)
-							params = append(params, paramType)
-						}
-					} else {
-						// Regular parameters (no receiver)
-						for _, p := range method.Params {
-							paramType := c.resolveTypeWithContext(p.Type, typeParamMap)
-							params = append(params, paramType)
-						}
-					}
-				} else {
-					// No parameters - could still be a method with no args
-					// Assume it needs a receiver (will need &self)
-					receiver = &ReceiverType{
-						IsMutable: false,
-						Type:      targetType,
-					}
-				}
-
-				var returnType Type = TypeVoid
-				if method.ReturnType != nil {
-					returnType = c.resolveTypeWithContext(method.ReturnType, typeParamMap)
-				}
-
-				c.MethodTable[targetName][method.Name.Name] = &Function{
-					Unsafe:   method.Unsafe,
-					Params:   params,
-					Return:   returnType,
-					Receiver: receiver,
-				}
+				c.MethodTable[targetName][method.Name.Name] = c.implMethodType(method, targetType, typeParamMap)
 			}
+			c.registerDefaultMethods(d, targetName, targetType, typeParamMap)
+			c.registerDrop(d, targetType)
 		}
 	}
 
 	// Restore checker state
 	c.GlobalScope = oldGlobalScope
 	c.CurrentFile = oldCurrentFile
+	c.module = oldModule
 
 	// Module info is already stored (we stored it before processing)
 	// Just make sure it's still there (it should be)
@@ -627,6 +584,14 @@ func (c *Checker) resolveModulePath(path []string, span lexer.Span) Type {
 			return &Named{Name: "std"} // Namespace
 		}
 
+		// std::collections::Vec and std::collections::HashMap name the
+		// prelude's types, which need no `use`
+		if path[1] == "collections" && len(path) == 3 && preludeTypes[path[2]] != "" {
+			if sym := c.lookupPrelude(path[2]); sym != nil {
+				return sym.Type
+			}
+		}
+
 		// Try to load "std/collections"
 		modName := "std/" + path[1]
 		c.ensureStdModuleLoaded(modName, path[1])
@@ -700,20 +665,14 @@ func (c *Checker) resolveUserModulePath(path []string, moduleInfo *ModuleInfo, s
 
 	// Check if the first path component is a submodule
 	// For example, in core::slice::Slice, we need to check if "slice" is a submodule of "core"
-	if len(path) > 1 {
-		// Build the submodule name
-		submoduleName := moduleInfo.Name + "/" + path[0]
-
-		// Check if this submodule exists in c.Modules
-		if submoduleInfo, exists := c.Modules[submoduleName]; exists {
-			// Recursively resolve the rest of the path in the submodule
-			return c.resolveUserModulePath(path[1:], submoduleInfo, span)
-		}
+	if submoduleInfo := c.submodule(moduleInfo, path[0], span); submoduleInfo != nil {
+		// Recursively resolve the rest of the path in the submodule
+		return c.resolveUserModulePath(path[1:], submoduleInfo, span)
 	}
 
-	// Look up the symbol in the module's scope (direct lookup, no parent search)
-	symbol, exists := moduleInfo.Scope.Symbols[path[0]]
-	if !exists || symbol == nil {
+	// Look up the symbol in the module (direct lookup, no parent search)
+	symbol := c.moduleItem(moduleInfo, path[0], span)
+	if symbol == nil {
 		c.reportError(fmt.Sprintf("symbol '%s' not found in module '%s'", path[0], moduleInfo.Name), span)
 		return nil
 	}
@@ -729,6 +688,106 @@ func (c *Checker) resolveUserModulePath(path []string, moduleInfo *ModuleInfo, s
 	return symbol.Type
 }
 
+// lookupModule returns the module that name refers to from the code being
+// checked: a submodule of the current module, or else a top-level module.
+func (c *Checker) lookupModule(name string) *ModuleInfo {
+	if c.module != "" {
+		if mod, ok := c.Modules[c.module+"/"+name]; ok {
+			return mod
+		}
+	}
+	return c.Modules[name]
+}
+
+// moduleOfExpr returns the module named by expr, which is either a module
+// identifier such as `geo` or a path through submodules such as
+// `geo::shapes`, or nil if expr names no module.
+func (c *Checker) moduleOfExpr(expr ast.Expr, scope *Scope) *ModuleInfo {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if sym := scope.Lookup(e.Name); sym != nil {
+			// A `use`d module, unless a variable or type shadows it
+			named, ok := sym.Type.(*Named)
+			if !ok || named.Ref != nil {
+				return nil
+			}
+			return c.Modules[named.Name]
+		}
+		return c.lookupModule(e.Name)
+	case *ast.InfixExpr:
+		right, ok := e.Right.(*ast.Ident)
+		if e.Op != lexer.DOUBLE_COLON || !ok {
+			return nil
+		}
+		parent := c.moduleOfExpr(e.Left, scope)
+		if parent == nil {
+			return nil
+		}
+		return c.submodule(parent, right.Name, right.Span())
+	}
+	return nil
+}
+
+// submodule returns the module name declared in parent, or nil if there is
+// none. A private submodule named from outside parent is reported at span.
+func (c *Checker) submodule(parent *ModuleInfo, name string, span lexer.Span) *ModuleInfo {
+	sub, ok := c.Modules[parent.Name+"/"+name]
+	if !ok {
+		return nil
+	}
+	if sub.Decl != nil && !sub.Decl.Pub && !c.canSee(parent.Name) {
+		c.reportPrivate(name, parent.Name, span, sub.Decl.Name.Span())
+	}
+	return sub
+}
+
+// moduleItem returns the item name declared in module, or nil if there is
+// none. A private item is reported at span unless it is visible from the
+// code being checked, and returned either way so checking can go on.
+func (c *Checker) moduleItem(module *ModuleInfo, name string, span lexer.Span) *Symbol {
+	if sym := module.Scope.Symbols[name]; sym != nil {
+		return sym
+	}
+	if module.InternalScope == nil {
+		return nil
+	}
+	sym := module.InternalScope.Symbols[name]
+	if sym == nil {
+		return nil
+	}
+	if !c.canSee(module.Name) {
+		var defSpan lexer.Span
+		if ident := sym.DefIdent(); ident != nil {
+			defSpan = ident.Span()
+		}
+		c.reportPrivate(name, module.Name, span, defSpan)
+	}
+	return sym
+}
+
+// canSee reports whether the private items of module are visible from the
+// code being checked, which holds inside module and the modules nested in it.
+func (c *Checker) canSee(module string) bool {
+	return c.module == module || strings.HasPrefix(c.module, module+"/")
+}
+
+// reportPrivate reports a use at span of name, which module keeps private
+// and which is defined at defSpan.
+func (c *Checker) reportPrivate(name, module string, span, defSpan lexer.Span) {
+	modulePath := strings.ReplaceAll(module, "/", "::")
+	c.reportErrorWithLabeledSpans(
+		fmt.Sprintf("`%s` is private to module `%s`", name, modulePath),
+		diag.CodeTypePrivateItem,
+		span,
+		"private item",
+		[]struct {
+			span  lexer.Span
+			label string
+		}{{span: defSpan, label: fmt.Sprintf("`%s` is defined here without `pub`", name)}},
+		fmt.Sprintf("mark `%s` as `pub` to use it outside `%s`", name, modulePath),
+	)
+}
+
 // resolveStdFilePath resolves a path within the standard library to a file path.
 func (c *Checker) resolveStdFilePath(relPath string) (string, error) {
 	stdlibDir, err := c.getStdlibDir()
@@ -772,7 +831,7 @@ func (c *Checker) ensureStdModuleLoaded(fullModuleName string, relPath string) {
 		// We found the file but failed to load/parse it. This is a real error.
 		// Since we don't have a span here, we print to stderr or append to errors with dummy span.
 		// For now, let's append a generic error.
-		c.Errors = append(c.Errors, diag.Diagnostic{
+		c.addError(diag.Diagnostic{
 			Stage:    diag.StageTypeCheck,
 			Severity: diag.SeverityError,
 			Message:  fmt.Sprintf("failed to load std module '%s': %v", fullModuleName, err),
@@ -794,11 +853,14 @@ func (c *Checker) ensureStdModuleLoaded(fullModuleName string, relPath string) {
 	// Save current state
 	oldCurrentFile := c.CurrentFile
 	oldGlobalScope := c.GlobalScope
+	oldModule := c.module
 	c.CurrentFile = filePath
+	c.module = fullModuleName
 
 	// Create a temporary scope for the module
 	moduleScope := NewScope(c.GlobalScope)
 	c.GlobalScope = moduleScope
+	moduleInfo.InternalScope = moduleScope
 
 	// Process mod declarations in the module file (recursive)
 	for _, subModDecl := range file.Mods {
@@ -899,6 +961,7 @@ func (c *Checker) ensureStdModuleLoaded(fullModuleName string, relPath string) {
 					Name:       d.Name.Name,
 					TypeParams: typeParams,
 					Fields:     fields,
+					Copy:       ast.HasAttribute(d.Attrs, "copy"),
 				},
 				DefNode: d,
 			}
@@ -928,6 +991,17 @@ func (c *Checker) ensureStdModuleLoaded(fullModuleName string, relPath string) {
 			if d.Pub {
 				moduleInfo.Scope.Insert(d.Name.Name, symbol)
 			}
+		case *ast.StaticDecl:
+			typ := c.resolveType(d.Type)
+			symbol = &Symbol{
+				Name:    d.Name.Name,
+				Type:    typ,
+				DefNode: d,
+			}
+			c.GlobalScope.Insert(d.Name.Name, symbol)
+			if d.Pub {
+				moduleInfo.Scope.Insert(d.Name.Name, symbol)
+			}
 		case *ast.EnumDecl:
 			var typeParams []TypeParam
 			for _, tp := range d.TypeParams {
@@ -960,6 +1034,8 @@ func (c *Checker) ensureStdModuleLoaded(fullModuleName string, relPath string) {
 					Name:       d.Name.Name,
 					TypeParams: typeParams,
 					Variants:   variants,
+					Repr:       c.enumRepr(d),
+					Copy:       ast.HasAttribute(d.Attrs, "copy"),
 				},
 				DefNode: d,
 			}
@@ -1032,75 +1108,15 @@ func (c *Checker) ensureStdModuleLoaded(fullModuleName string, relPath string) {
 
 			// Process each method in the impl block
 			for _, method := range d.Methods {
-				// Build function type
-				var params []Type
-				var receiver *ReceiverType
-
-				// Check if first parameter is a receiver (self, &self, &mut self)
-				if len(method.Params) > 0 {
-					firstParam := method.Params[0]
-					if firstParam.Name.Name == "self" {
-						// Determine receiver type from parameter type annotation
-						if firstParam.Type != nil {
-							if refType, ok := firstParam.Type.(*ast.ReferenceType); ok {
-								// &self or &mut self
-								receiver = &ReceiverType{
-									IsMutable: refType.Mutable,
-									Type:      targetType,
-								}
-							} else {
-								// self (by value)
-								receiver = &ReceiverType{
-									IsMutable: false,
-									Type:      targetType,
-								}
-							}
-						} else {
-							// No type annotation on self - assume &self
-							receiver = &ReceiverType{
-								IsMutable: false,
-								Type:      targetType,
-							}
-						}
-
-						// Skip the receiver when processing remaining params
-						// Resolve with Self/typeParam context
-						for i := 1; i < len(method.Params); i++ {
-							paramType := c.resolveTypeWithContext(method.Params[i].Type, typeParamMap)
-							params = append(params, paramType)
-						}
-					} else {
-						// Regular parameters (no receiver)
-						for _, p := range method.Params {
-							paramType := c.resolveTypeWithContext(p.Type, typeParamMap)
-							params = append(params, paramType)
-						}
-					}
-				} else {
-					// No parameters - could still be a method with no args
-					// Assume it needs a receiver (will need &self)
-					receiver = &ReceiverType{
-						IsMutable: false,
-						Type:      targetType,
-					}
-				}
-
-				var returnType Type = TypeVoid
-				if method.ReturnType != nil {
-					returnType = c.resolveTypeWithContext(method.ReturnType, typeParamMap)
-				}
-
-				c.MethodTable[targetName][method.Name.Name] = &Function{
-					Unsafe:   method.Unsafe,
-					Params:   params,
-					Return:   returnType,
-					Receiver: receiver,
-				}
+				c.MethodTable[targetName][method.Name.Name] = c.implMethodType(method, targetType, typeParamMap)
 			}
+			c.registerDefaultMethods(d, targetName, targetType, typeParamMap)
+			c.registerDrop(d, targetType)
 		}
 	}
 
 	// Restore checker state
 	c.GlobalScope = oldGlobalScope
 	c.CurrentFile = oldCurrentFile
+	c.module = oldModule
 }
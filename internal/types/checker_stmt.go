@@ -12,10 +12,20 @@ func (c *Checker) checkBlock(block *ast.BlockExpr, parent *Scope, inUnsafe bool)
 	scope := NewScope(parent)
 	defer scope.Close() // Clean up borrows when scope ends
 
+	// holders maps the bindings of this block that hold borrows to the
+	// index of the last statement using them
+	holders := make(map[*Symbol]int)
+	defer func() {
+		for holder := range holders {
+			c.endBorrows(holder)
+		}
+	}()
+
 	var unreachableSpan lexer.Span
 	hasUnreachable := false
 
 	for i, stmt := range block.Stmts {
+		c.endDeadBorrows(holders, i)
 		if hasUnreachable {
 			if unreachableSpan == (lexer.Span{}) {
 				unreachableSpan = stmt.Span()
@@ -27,7 +37,12 @@ func (c *Checker) checkBlock(block *ast.BlockExpr, parent *Scope, inUnsafe bool)
 			continue
 		}
 
-		c.checkStmt(stmt, scope, inUnsafe)
+		c.checkBorrowingStmt(stmt, scope, inUnsafe)
+		if let, ok := stmt.(*ast.LetStmt); ok {
+			if sym := scope.Symbols[let.Name.Name]; sym != nil && holdsReference(sym.Type) {
+				holders[sym] = lastUse(block, i, let.Name.Name)
+			}
+		}
 
 		// Check if statement terminates control flow
 		if c.isTerminating(stmt) {
@@ -40,31 +55,30 @@ func (c *Checker) checkBlock(block *ast.BlockExpr, parent *Scope, inUnsafe bool)
 	}
 
 	if hasUnreachable && unreachableSpan != (lexer.Span{}) {
-		c.reportErrorWithCode(
-			"unreachable statement",
-			unreachableSpan,
-			diag.CodeUnreachableCode,
-			"this code can never be executed",
-			nil,
-		)
+		c.reportWarning("unreachable statement", unreachableSpan, diag.CodeUnreachableCode,
+			"this code can never be executed", "")
 	}
 
+	c.endDeadBorrows(holders, len(block.Stmts))
+	// A block that never reaches its end has type `!`, so that it fits
+	// wherever the value of the block is expected
 	if block.Tail != nil {
 		if hasUnreachable {
-			c.reportErrorWithCode(
-				"unreachable expression",
-				block.Tail.Span(),
-				diag.CodeUnreachableCode,
-				"this expression can never be executed",
-				nil,
-			)
-			return TypeVoid
+			c.reportWarning("unreachable expression", block.Tail.Span(), diag.CodeUnreachableCode,
+				"this expression can never be executed", "")
+			return TypeNever
 		}
 		return c.checkExpr(block.Tail, scope, inUnsafe)
 	}
+	if hasUnreachable {
+		return TypeNever
+	}
 	return TypeVoid
 }
 
+// isTerminating reports whether control never gets past stmt: it returns,
+// breaks, continues or evaluates an expression of type `!`, such as a call
+// of panic, or every branch of an if does so, or it loops forever.
 func (c *Checker) isTerminating(stmt ast.Stmt) bool {
 	switch s := stmt.(type) {
 	case *ast.ReturnStmt:
@@ -74,17 +88,57 @@ func (c *Checker) isTerminating(stmt ast.Stmt) bool {
 	case *ast.ContinueStmt:
 		return true
 	case *ast.ExprStmt:
-		// Check for panic() call
-		if call, ok := s.Expr.(*ast.CallExpr); ok {
-			if ident, ok := call.Callee.(*ast.Ident); ok && ident.Name == "panic" {
-				return true
+		return c.ExprTypes[s.Expr] == TypeNever
+	case *ast.LetStmt:
+		return c.ExprTypes[s.Value] == TypeNever
+	case *ast.IfStmt:
+		if s.Else == nil || !c.blockDiverges(s.Else) {
+			return false
+		}
+		for _, clause := range s.Clauses {
+			if !c.blockDiverges(clause.Body) {
+				return false
 			}
 		}
+		return true
+	case *ast.WhileStmt:
+		lit, ok := s.Condition.(*ast.BoolLit)
+		return ok && lit.Value && !breaksOut(s.Body)
 	}
 	return false
 }
 
+// blockDiverges reports whether control never gets past the end of block
+func (c *Checker) blockDiverges(block *ast.BlockExpr) bool {
+	for _, stmt := range block.Stmts {
+		if c.isTerminating(stmt) {
+			return true
+		}
+	}
+	return block.Tail != nil && c.ExprTypes[block.Tail] == TypeNever
+}
+
+// breaksOut reports whether body, the body of a loop, contains a break out of
+// that loop. Breaks in nested loops and function literals leave those.
+func breaksOut(body *ast.BlockExpr) bool {
+	found := false
+	ast.Walk(body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.BreakStmt:
+			found = true
+		case *ast.WhileStmt, *ast.ForStmt, *ast.FunctionLiteral, *ast.SpawnExpr, *ast.SpawnStmt:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
 func (c *Checker) checkStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
+	outer := c.frame
+	c.frame = errorFrame{}
+	defer func() { c.frame = outer }()
+
 	switch s := stmt.(type) {
 	case *ast.LetStmt:
 		// Special handling for function literals with type annotations
@@ -111,17 +165,24 @@ func (c *Checker) checkStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
 				}
 			} else {
 				// Not a function type, check normally
-				initType = c.checkExpr(s.Value, scope, inUnsafe)
+				initType = c.adaptLiteral(s.Value, c.checkExpr(s.Value, scope, inUnsafe), declType)
 				if !c.assignableTo(initType, declType) {
 					c.reportCannotAssign(initType, declType, s.Value.Span())
 				}
 				initType = declType
 			}
+			// The lowerer gives the variable its declared type, which may
+			// differ from the initializer's: an array literal bound to a
+			// slice variable
+			c.ExprTypes[s] = declType
 		} else {
 			// No type annotation, check normally
 			initType = c.checkExpr(s.Value, scope, inUnsafe)
 		}
 
+		c.bindInfers(initType, s.Name.Name)
+		c.checkShadow(s.Name, scope)
+
 		// Add to scope
 		scope.Insert(s.Name.Name, &Symbol{
 			Name:    s.Name.Name,
@@ -131,14 +192,16 @@ func (c *Checker) checkStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
 	case *ast.ExprStmt:
 		c.checkExpr(s.Expr, scope, inUnsafe)
 	case *ast.ReturnStmt:
+		c.checkAttributes(s.Attrs, "return statement")
+
 		// Check return value against expected return type
 		expected := c.CurrentReturn
 		if expected == nil {
 			expected = TypeVoid
 		}
 
-		// Special check for main
-		if c.CurrentFnName == "main" {
+		// Special check for main without a declared return type
+		if c.CurrentFnName == "main" && expected == TypeVoid {
 			if s.Value != nil {
 				valType := c.checkExpr(s.Value, scope, inUnsafe)
 				c.reportErrorWithCode(
@@ -187,45 +250,99 @@ func (c *Checker) checkStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
 	case *ast.SpawnStmt:
 		if s.Call != nil {
 			c.checkExpr(s.Call, scope, inUnsafe)
+			c.checkSpawnArgs(s.Call.Args)
 		} else if s.Block != nil {
 			// Type check the block (spawn { ... })
+			outer := c.spawnScope
+			c.spawnScope = scope
 			c.checkBlock(s.Block, scope, inUnsafe)
+			c.spawnScope = outer
 		} else if s.FunctionLiteral != nil {
 			// Type check function literal: spawn |params| { ... }(args)
-			// First, create a scope for the function literal parameters
-			fnScope := NewScope(scope)
+			// The arguments are evaluated in the spawning scope
+			argTypes := make([]Type, len(s.Args))
+			for i, arg := range s.Args {
+				argTypes[i] = c.checkExpr(arg, scope, inUnsafe)
+			}
+			c.checkSpawnArgs(s.Args)
 
-			// Check parameters
-			for _, param := range s.FunctionLiteral.Params {
+			// An unannotated parameter takes the type of its argument
+			fnScope := NewScope(scope)
+			paramTypes := make([]Type, len(s.FunctionLiteral.Params))
+			for i, param := range s.FunctionLiteral.Params {
+				var paramType Type
 				if param.Type != nil {
-					paramType := c.resolveType(param.Type)
-					// If type is nil, it will be inferred from usage
-					if paramType != nil {
-						fnScope.Insert(param.Name.Name, &Symbol{
-							Name:    param.Name.Name,
-							Type:    paramType,
-							DefNode: param,
-						})
-					}
+					paramType = c.resolveType(param.Type)
+				} else if i < len(argTypes) {
+					paramType = argTypes[i]
+				}
+				if paramType == nil {
+					continue
 				}
+				paramTypes[i] = paramType
+				fnScope.Insert(param.Name.Name, &Symbol{
+					Name:    param.Name.Name,
+					Type:    paramType,
+					DefNode: param,
+				})
 			}
+			c.ExprTypes[s.FunctionLiteral] = &Function{Params: paramTypes, Return: TypeVoid}
 
 			// Check function body
+			outer := c.spawnScope
+			c.spawnScope = scope
 			c.checkBlock(s.FunctionLiteral.Body, fnScope, inUnsafe)
-
-			// Check arguments if provided
-			for _, arg := range s.Args {
-				c.checkExpr(arg, scope, inUnsafe)
-			}
+			c.spawnScope = outer
 
 			fnScope.Close()
 		}
 	case *ast.SelectStmt:
+		var defaultCase, afterCase *ast.SelectCase
 		for i, case_ := range s.Cases {
 			// Create a new scope for this case to hold bound variables
 			caseScope := NewScope(scope)
 			var boundVarType Type
 
+			// default and after(ms) cases have no communication to validate
+			if case_.Comm == nil {
+				if case_.After != nil {
+					timeoutType := c.checkExpr(case_.After, scope, inUnsafe)
+					if !c.assignableTo(timeoutType, TypeInt) && !c.isTimeDuration(timeoutType) {
+						c.reportErrorWithCode(
+							fmt.Sprintf("select timeout must be a `time::Duration` or an `int` number of milliseconds, but found `%s`", timeoutType),
+							case_.After.Span(),
+							diag.CodeTypeMismatch,
+							"pass the timeout as a duration, e.g. `after(Duration::milliseconds(100)) => { ... }`, or in milliseconds",
+							nil,
+						)
+					}
+					if afterCase != nil {
+						c.reportErrorWithCode(
+							"select has more than one `after` case",
+							case_.Span(),
+							diag.CodeTypeInvalidOperation,
+							"keep a single `after` case with the shortest timeout",
+							nil,
+						)
+					}
+					afterCase = case_
+				} else {
+					if defaultCase != nil {
+						c.reportErrorWithCode(
+							"select has more than one `default` case",
+							case_.Span(),
+							diag.CodeTypeInvalidOperation,
+							"remove the extra `default` case",
+							nil,
+						)
+					}
+					defaultCase = case_
+				}
+				c.checkBlock(case_.Body, caseScope, inUnsafe)
+				caseScope.Close()
+				continue
+			}
+
 			// Validate that the communication statement is a channel operation
 			switch comm := case_.Comm.(type) {
 			case *ast.LetStmt:
@@ -298,21 +415,21 @@ func (c *Checker) checkStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
 				if boundVarType == nil {
 					boundVarType = TypeVoid
 				}
+				// Record the binding's type for MIR lowering
+				c.ExprTypes[comm] = boundVarType
 				caseScope.Insert(comm.Name.Name, &Symbol{
 					Name:    comm.Name.Name,
 					Type:    boundVarType,
 					DefNode: comm,
 				})
 			case *ast.ExprStmt:
-				// Check for "default" identifier
-				if ident, ok := comm.Expr.(*ast.Ident); ok && ident.Name == "default" {
-					// Valid default case
-				} else if infix, ok := comm.Expr.(*ast.InfixExpr); ok && infix.Op == lexer.LARROW {
+				if infix, ok := comm.Expr.(*ast.InfixExpr); ok && infix.Op == lexer.LARROW {
 					// Send operation: ch <- val
 					leftType := c.checkExpr(infix.Left, scope, inUnsafe)
 					rightType := c.checkExpr(infix.Right, scope, inUnsafe)
 
 					if ch, ok := leftType.(*Channel); ok {
+						c.checkSendAfterClose(infix.Left, infix.Span(), scope)
 						// Check direction
 						if ch.Dir == RecvOnly {
 							help := c.generateChannelErrorHelp("cannot send to receive-only channel", ch, false, true)
@@ -392,6 +509,17 @@ func (c *Checker) checkStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
 			c.checkBlock(case_.Body, caseScope, inUnsafe)
 			caseScope.Close()
 		}
+		// A default case runs as soon as nothing is ready, so the timeout
+		// could never fire
+		if defaultCase != nil && afterCase != nil {
+			c.reportErrorWithCode(
+				"select cannot have both a `default` and an `after` case",
+				afterCase.Span(),
+				diag.CodeTypeInvalidOperation,
+				"drop `default` to wait for the timeout, or drop `after` to never wait",
+				nil,
+			)
+		}
 	case *ast.IfStmt:
 		// Check all if clauses
 		for _, clause := range s.Clauses {
@@ -435,9 +563,26 @@ func (c *Checker) checkStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
 		case *Array:
 			elementType = t.Elem
 			isValidIterable = true
+		case *Channel:
+			// Receives until the channel is closed and drained
+			if t.Dir == SendOnly {
+				c.reportErrorWithCode(
+					"cannot iterate over send-only channel",
+					s.Iterable.Span(),
+					diag.CodeTypeInvalidOperation,
+					c.generateChannelErrorHelp("cannot receive from send-only channel", t, true, false),
+					nil,
+				)
+			}
+			elementType = t.Elem
+			isValidIterable = true
 		case *Slice:
 			elementType = t.Elem
 			isValidIterable = true
+		case *Map:
+			// Visits the keys in insertion order
+			elementType = t.Key
+			isValidIterable = true
 		case *GenericInstance:
 			// Check if it's a generic instance of Array or Slice
 			if array, ok := t.Base.(*Array); ok {
@@ -461,16 +606,17 @@ func (c *Checker) checkStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
 
 		if !isValidIterable {
 			c.reportErrorWithCode(
-				fmt.Sprintf("for loop iterable must be an array or slice, got `%s`", iterableType),
+				fmt.Sprintf("for loop iterable must be an array, slice, map or channel, got `%s`", iterableType),
 				s.Iterable.Span(),
 				diag.CodeTypeMismatch,
-				"use an array (e.g., [int; 5]) or slice (e.g., []int) as the iterable",
+				"use an array (e.g., [int; 5]), slice (e.g., []int), map or channel as the iterable",
 				nil,
 			)
 		}
 
 		// Create a new scope for the loop body with the iterator variable
 		loopScope := NewScope(scope)
+		c.checkShadow(s.Iterator, loopScope)
 		loopScope.Insert(s.Iterator.Name, &Symbol{
 			Name:    s.Iterator.Name,
 			Type:    elementType,
@@ -483,3 +629,120 @@ func (c *Checker) checkStmt(stmt ast.Stmt, scope *Scope, inUnsafe bool) {
 		// Continue is valid (no type checking needed)
 	}
 }
+
+// checkSpawnCapture rejects uses of mutable locals from outside a spawn body.
+// Spawned code runs concurrently with its parent, so shared mutable state
+// has to go through Mutex[T], RwLock[T] or AtomicInt instead.
+func (c *Checker) checkSpawnCapture(ident *ast.Ident, sym *Symbol) {
+	if c.spawnScope.Lookup(ident.Name) != sym || c.GlobalScope.Lookup(ident.Name) == sym {
+		return
+	}
+	if ref, ok := sym.Type.(*Reference); ok && ref.Mutable {
+		c.reportUnsyncedCapture(fmt.Sprintf("cannot capture mutable reference `%s` in spawn", ident.Name), ident.Span())
+		return
+	}
+	if let, ok := sym.DefNode.(*ast.LetStmt); ok && let.Mutable {
+		c.reportUnsyncedCapture(fmt.Sprintf("cannot capture mutable variable `%s` in spawn", ident.Name), ident.Span())
+	}
+}
+
+// checkSpawnArgs rejects `&mut` arguments to a spawned call; the arguments
+// must already have been checked. This holds whether or not the call itself
+// type checks, so errors in it do not suppress these.
+func (c *Checker) checkSpawnArgs(args []ast.Expr) {
+	poisoned := c.frame.poisoned
+	c.frame.poisoned = false
+	defer func() { c.frame.poisoned = poisoned }()
+	for _, arg := range args {
+		if ref, ok := c.ExprTypes[arg].(*Reference); ok && ref.Mutable {
+			c.reportUnsyncedCapture("cannot pass a mutable reference to a spawned function", arg.Span())
+		}
+	}
+}
+
+func (c *Checker) reportUnsyncedCapture(msg string, span lexer.Span) {
+	c.reportErrorWithCode(
+		msg,
+		span,
+		diag.CodeTypeUnsyncedCapture,
+		"wrap shared state in Mutex[T] or RwLock[T], or use AtomicInt for counters",
+		nil,
+	)
+}
+
+// checkCloseCall checks the builtin close(ch) and remembers ch as closed for
+// the rest of the enclosing scope.
+func (c *Checker) checkCloseCall(call *ast.CallExpr, scope *Scope, inUnsafe bool) Type {
+	if len(call.Args) != 1 {
+		c.reportErrorWithCode(
+			fmt.Sprintf("close takes 1 argument, got %d", len(call.Args)),
+			call.Span(),
+			diag.CodeTypeInvalidOperation,
+			"call `close(ch)` with the channel to close",
+			nil,
+		)
+		for _, arg := range call.Args {
+			c.checkExpr(arg, scope, inUnsafe)
+		}
+		return TypeVoid
+	}
+
+	argType := c.checkExpr(call.Args[0], scope, inUnsafe)
+	ch, ok := argType.(*Channel)
+	if !ok {
+		c.reportErrorWithCode(
+			fmt.Sprintf("cannot close non-channel type `%s`", argType),
+			call.Args[0].Span(),
+			diag.CodeTypeMismatch,
+			"close only accepts channels",
+			nil,
+		)
+		return TypeVoid
+	}
+	if ch.Dir == RecvOnly {
+		c.reportErrorWithCode(
+			"cannot close receive-only channel",
+			call.Args[0].Span(),
+			diag.CodeTypeInvalidOperation,
+			"only the sending side of a channel may close it",
+			nil,
+		)
+	}
+
+	if ident, ok := call.Args[0].(*ast.Ident); ok {
+		if sym := scope.Lookup(ident.Name); sym != nil {
+			if c.closedChannels == nil {
+				c.closedChannels = make(map[*Symbol]*Scope)
+			}
+			c.closedChannels[sym] = scope
+		}
+	}
+	return TypeVoid
+}
+
+// checkSendAfterClose reports a send on a channel variable that was closed
+// earlier in the same or an enclosing scope. Closes inside a nested block
+// (e.g. one branch of an if) are not tracked past that block.
+func (c *Checker) checkSendAfterClose(target ast.Expr, span lexer.Span, scope *Scope) {
+	ident, ok := target.(*ast.Ident)
+	if !ok {
+		return
+	}
+	sym := scope.Lookup(ident.Name)
+	closeScope, ok := c.closedChannels[sym]
+	if !ok {
+		return
+	}
+	for s := scope; s != nil; s = s.Parent {
+		if s == closeScope {
+			c.reportErrorWithCode(
+				fmt.Sprintf("send on closed channel `%s`", ident.Name),
+				span,
+				diag.CodeTypeInvalidOperation,
+				"move the send before `close`, or only close the channel once all sends are done",
+				nil,
+			)
+			return
+		}
+	}
+}
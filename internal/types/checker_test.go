@@ -6,6 +6,7 @@ import (
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
 	"github.com/malphas-lang/malphas-lang/internal/lexer"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
 )
 
 func TestChecker_BasicTypes(t *testing.T) {
@@ -218,10 +219,12 @@ func TestChecker_BorrowChecking(t *testing.T) {
 				// let mut x = 1;
 				// let y = &mut x;
 				// let z = &mut x;
+				// y;
 				letX := &ast.LetStmt{Mutable: true, Name: ast.NewIdent("x", lexer.Span{}), Value: ast.NewIntegerLit("1", lexer.Span{})}
 				letY := &ast.LetStmt{Name: ast.NewIdent("y", lexer.Span{}), Value: &ast.PrefixExpr{Op: lexer.REF_MUT, Expr: ast.NewIdent("x", lexer.Span{})}}
 				letZ := &ast.LetStmt{Name: ast.NewIdent("z", lexer.Span{}), Value: &ast.PrefixExpr{Op: lexer.REF_MUT, Expr: ast.NewIdent("x", lexer.Span{})}}
-				return wrapStmts(letX, letY, letZ)
+				useY := &ast.ExprStmt{Expr: ast.NewIdent("y", lexer.Span{})}
+				return wrapStmts(letX, letY, letZ, useY)
 			},
 			wantError: "cannot borrow \"x\" as mutable because it is already borrowed",
 		},
@@ -231,10 +234,12 @@ func TestChecker_BorrowChecking(t *testing.T) {
 				// let mut x = 1;
 				// let y = &x;
 				// let z = &mut x;
+				// y;
 				letX := &ast.LetStmt{Mutable: true, Name: ast.NewIdent("x", lexer.Span{}), Value: ast.NewIntegerLit("1", lexer.Span{})}
 				letY := &ast.LetStmt{Name: ast.NewIdent("y", lexer.Span{}), Value: &ast.PrefixExpr{Op: lexer.AMPERSAND, Expr: ast.NewIdent("x", lexer.Span{})}}
 				letZ := &ast.LetStmt{Name: ast.NewIdent("z", lexer.Span{}), Value: &ast.PrefixExpr{Op: lexer.REF_MUT, Expr: ast.NewIdent("x", lexer.Span{})}}
-				return wrapStmts(letX, letY, letZ)
+				useY := &ast.ExprStmt{Expr: ast.NewIdent("y", lexer.Span{})}
+				return wrapStmts(letX, letY, letZ, useY)
 			},
 			wantError: "cannot borrow \"x\" as mutable because it is already borrowed",
 		},
@@ -244,10 +249,12 @@ func TestChecker_BorrowChecking(t *testing.T) {
 				// let mut x = 1;
 				// let y = &mut x;
 				// let z = &x;
+				// y;
 				letX := &ast.LetStmt{Mutable: true, Name: ast.NewIdent("x", lexer.Span{}), Value: ast.NewIntegerLit("1", lexer.Span{})}
 				letY := &ast.LetStmt{Name: ast.NewIdent("y", lexer.Span{}), Value: &ast.PrefixExpr{Op: lexer.REF_MUT, Expr: ast.NewIdent("x", lexer.Span{})}}
 				letZ := &ast.LetStmt{Name: ast.NewIdent("z", lexer.Span{}), Value: &ast.PrefixExpr{Op: lexer.AMPERSAND, Expr: ast.NewIdent("x", lexer.Span{})}}
-				return wrapStmts(letX, letY, letZ)
+				useY := &ast.ExprStmt{Expr: ast.NewIdent("y", lexer.Span{})}
+				return wrapStmts(letX, letY, letZ, useY)
 			},
 			wantError: "cannot borrow \"x\" as immutable because it is already borrowed as mutable",
 		},
@@ -309,6 +316,218 @@ func TestChecker_BorrowChecking(t *testing.T) {
 	}
 }
 
+func TestBorrowsEndAtLastUse(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantError string
+	}{
+		{
+			name:      "sequential temporary borrows",
+			body:      "inc(&mut x);\n    inc(&mut x);\n    let a = &x;\n    inc(&mut x);",
+			wantError: "",
+		},
+		{
+			name:      "temporary borrows in a loop",
+			body:      "let mut i = 0;\n    while i < 3 {\n        inc(&mut x);\n        i = i + 1;\n    }",
+			wantError: "",
+		},
+		{
+			name:      "binding unused after its last use",
+			body:      "let r = &mut x;\n    *r = 2;\n    let s = &x;\n    inc(&mut x);",
+			wantError: "",
+		},
+		{
+			name:      "borrow in a branch",
+			body:      "let r = if x > 0 { &mut x } else { &mut x };\n    let s = &x;\n    *r = 2;",
+			wantError: "cannot borrow \"x\" as immutable because it is already borrowed as mutable",
+		},
+		{
+			name:      "binding used later",
+			body:      "let r = &mut x;\n    inc(&mut x);\n    *r = 2;",
+			wantError: "cannot borrow \"x\" as mutable because it is already borrowed",
+		},
+		{
+			name:      "shared binding used later",
+			body:      "let r = &x;\n    inc(&mut x);\n    println(*r);",
+			wantError: "cannot borrow \"x\" as mutable because it is already borrowed",
+		},
+		{
+			name:      "copied reference used later",
+			body:      "let r = &mut x;\n    let s = r;\n    let a = &x;\n    *s = 2;",
+			wantError: "cannot borrow \"x\" as immutable because it is already borrowed as mutable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package main;\n\nfn inc(p: &mut int) {\n    *p = *p + 1;\n}\n\nfn main() {\n    let mut x = 1;\n    " + tt.body + "\n}\n"
+			checker := checkSource(t, src, "main.mal")
+			if tt.wantError == "" {
+				if len(checker.Errors) > 0 {
+					t.Errorf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			for _, err := range checker.Errors {
+				if err.Message == tt.wantError {
+					return
+				}
+			}
+			t.Errorf("expected error %q, got %v", tt.wantError, checker.Errors)
+		})
+	}
+}
+
+func TestMethodCallsOnTemporaries(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantError string
+	}{
+		{
+			name:      "methods on a call result",
+			body:      "let n = make().len();\n    let m = make().bump();\n    let v = *make().x_ref();",
+			wantError: "",
+		},
+		{
+			name:      "method on a struct literal",
+			body:      "let n = Point { x: 1, y: 2 }.len();",
+			wantError: "",
+		},
+		{
+			name:      "reference into a temporary kept",
+			body:      "let r = make().x_ref();\n    println(*r);",
+			wantError: "temporary value is freed at the end of the statement while `r` still borrows it",
+		},
+		{
+			name:      "reference into a variable kept",
+			body:      "let mut p = make();\n    let r = p.x_mut();\n    let n = p.len();\n    println(*r);",
+			wantError: "cannot borrow \"p\" as immutable because it is already borrowed as mutable",
+		},
+		{
+			name:      "reference into a variable no longer used",
+			body:      "let mut p = make();\n    let r = p.x_mut();\n    println(*r);\n    let n = p.len();",
+			wantError: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := `package main;
+
+struct Point { x: int, y: int }
+
+impl Point {
+    fn len(&self) -> int { self.x + self.y }
+    fn bump(&mut self) -> int { self.x = self.x + 1; self.x }
+    fn x_ref(&self) -> &int { &self.x }
+    fn x_mut(&mut self) -> &mut int { &mut self.x }
+}
+
+fn make() -> Point { Point { x: 3, y: 4 } }
+
+fn main() {
+    ` + tt.body + `
+}
+`
+			checker := checkSource(t, src, "main.mal")
+			if tt.wantError == "" {
+				if len(checker.Errors) > 0 {
+					t.Errorf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			for _, err := range checker.Errors {
+				if err.Message == tt.wantError {
+					return
+				}
+			}
+			t.Errorf("expected error %q, got %v", tt.wantError, checker.Errors)
+		})
+	}
+}
+
+func TestAssociatedFunctionPaths(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantError string
+	}{
+		{
+			name: "enum associated function",
+			body: "let c: Color = Color::from_int(1);",
+		},
+		{
+			name: "trait function with Self from the annotation",
+			body: "let p: Point = Default::default();\n    println(p.x);",
+		},
+		{
+			name: "trait function with Self from a later use",
+			body: "let mut p = make();\n    p = Default::default();\n    println(p.x);",
+		},
+		{
+			name:      "trait function for a type without the impl",
+			body:      "let l: Line = Default::default();\n    println(l.a);",
+			wantError: "type `Line` does not satisfy trait `Default` (missing methods: default)",
+		},
+		{
+			name:      "trait function with Self never fixed",
+			body:      "let _u = Default::default();",
+			wantError: "type annotations needed: cannot infer type argument `Self`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := `package main;
+
+trait Default {
+    fn default() -> Self;
+}
+
+struct Point { x: int, y: int }
+struct Line { a: int }
+
+impl Default for Point {
+    fn default() -> Point { Point { x: 1, y: 2 } }
+}
+
+enum Color { Red, Green }
+
+impl Color {
+    fn from_int(n: int) -> Color {
+        if n == 0 { Color::Red } else { Color::Green }
+    }
+}
+
+fn make() -> Point { Point { x: 3, y: 4 } }
+
+fn pick[T: Default]() -> T {
+    T::default()
+}
+
+fn main() {
+    ` + tt.body + `
+}
+`
+			checker := checkSource(t, src, "main.mal")
+			if tt.wantError == "" {
+				if len(checker.Errors) > 0 {
+					t.Errorf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			for _, err := range checker.Errors {
+				if err.Message == tt.wantError {
+					return
+				}
+			}
+			t.Errorf("expected error %q, got %v", tt.wantError, checker.Errors)
+		})
+	}
+}
+
 func wrapStmts(stmts ...ast.Stmt) *ast.File {
 	fnBody := &ast.BlockExpr{
 		Stmts: stmts,
@@ -323,3 +542,56 @@ func wrapStmts(stmts ...ast.Stmt) *ast.File {
 		Decls: []ast.Decl{fnDecl},
 	}
 }
+
+func TestChecker_CascadingErrorsSuppressed(t *testing.T) {
+	src := `package main;
+struct Point { x: int, y: int }
+fn takes(n: int) -> int { return n; }
+fn main() {
+    let a = undefinedThing;
+    let b = a + 1;
+    let c: int = a;
+    takes(a);
+    let d = a.foo;
+    if a { println("x"); }
+    let p = Point { x: a, y: 2 };
+    let g = nope(1, 2);
+    let h: string = g;
+    let s: string = 1;
+    println(b, c, d, p, h, s);
+}
+`
+	p := parser.New(src)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	checker := NewChecker()
+	checker.Check(file)
+
+	// The two undefined identifiers, and the unrelated mismatch
+	var lines []int
+	for _, err := range checker.Errors {
+		lines = append(lines, err.Span.Line)
+	}
+	if len(lines) != 3 || lines[0] != 5 || lines[1] != 12 || lines[2] != 14 {
+		t.Errorf("errors at lines %v, want [5 12 14]: %v", lines, checker.Errors)
+	}
+}
+
+func TestBlockExpressionsHaveTheirTailType(t *testing.T) {
+	src := `package main;
+
+fn main() {
+    let x = { let a = 1; a + 1 };
+    let y: int = { { x } };
+    let s: string = { 1 };
+    println(y);
+    println(s);
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 1 || !strings.Contains(checker.Errors[0].Message, "cannot assign value of type `int` to variable of type `string`") {
+		t.Fatalf("expected only the string mismatch, got %v", checker.Errors)
+	}
+}
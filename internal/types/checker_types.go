@@ -2,7 +2,6 @@ package types
 
 import (
 	"fmt"
-	"strconv"
 
 	"github.com/malphas-lang/malphas-lang/internal/ast"
 	"github.com/malphas-lang/malphas-lang/internal/diag"
@@ -15,6 +14,37 @@ func (c *Checker) resolveType(typ ast.TypeExpr) Type {
 	return t
 }
 
+// resolveModuleType resolves name in the module that base refers to (the
+// namespace type of a `use std::net;` import or of a `mod` item), or returns
+// nil if base is not a module or declares no such type. A submodule resolves
+// to its own namespace type, so that geo::shapes::Square resolves too.
+func (c *Checker) resolveModuleType(base Type, name *ast.Ident) Type {
+	named, ok := base.(*Named)
+	if !ok || named.Ref != nil {
+		return nil
+	}
+	modInfo := c.lookupModule(named.Name)
+	if modInfo == nil {
+		return nil
+	}
+	if sub := c.submodule(modInfo, name.Name, name.Span()); sub != nil {
+		return &Named{Name: sub.Name}
+	}
+	sym := c.moduleItem(modInfo, name.Name, name.Span())
+	if sym == nil || sym.Type == nil {
+		return nil
+	}
+	switch t := sym.Type.(type) {
+	case *Named:
+		if t.Ref != nil {
+			return t.Ref
+		}
+	case *Struct, *Enum:
+		return t
+	}
+	return nil
+}
+
 func (c *Checker) resolveTypeInternal(typ ast.TypeExpr) Type {
 	switch t := typ.(type) {
 	case *ast.NamedType:
@@ -30,6 +60,8 @@ func (c *Checker) resolveTypeInternal(typ ast.TypeExpr) Type {
 			return TypeString
 		case "void":
 			return TypeVoid
+		case "!":
+			return TypeNever
 		case "i8":
 			return TypeInt8
 		case "i32":
@@ -52,6 +84,7 @@ func (c *Checker) resolveTypeInternal(typ ast.TypeExpr) Type {
 			// Look up in global scope first
 			sym := c.GlobalScope.Lookup(t.Name.Name)
 			if sym != nil && sym.Type != nil {
+				c.recordUse(t.Name, sym)
 				// If the symbol has a Ref, use it (for resolved types)
 				if named, ok := sym.Type.(*Named); ok && named.Ref != nil {
 					return named.Ref
@@ -59,9 +92,16 @@ func (c *Checker) resolveTypeInternal(typ ast.TypeExpr) Type {
 				return sym.Type
 			}
 
+			// Try the prelude (Vec, HashMap, ...) before searching loaded modules
+			if sym := c.lookupPrelude(t.Name.Name); sym != nil && sym.Type != nil {
+				c.recordUse(t.Name, sym)
+				return sym.Type
+			}
+
 			// Try to resolve from loaded modules
 			for _, modInfo := range c.Modules {
 				if modSym := modInfo.Scope.Lookup(t.Name.Name); modSym != nil && modSym.Type != nil {
+					c.recordUse(t.Name, modSym)
 					// If the symbol has a Ref, use it
 					if named, ok := modSym.Type.(*Named); ok && named.Ref != nil {
 						return named.Ref
@@ -98,6 +138,9 @@ func (c *Checker) resolveTypeInternal(typ ast.TypeExpr) Type {
 			val := c.resolveType(t.Args[1])
 			return &Map{Key: key, Value: val}
 		}
+		if named, ok := t.Base.(*ast.NamedType); ok && named.Name.Name == "JoinHandle" && len(t.Args) == 1 {
+			return &JoinHandle{Elem: c.resolveType(t.Args[0])}
+		}
 
 		baseType := c.resolveType(t.Base)
 		var args []Type
@@ -160,6 +203,9 @@ func (c *Checker) resolveTypeInternal(typ ast.TypeExpr) Type {
 							help,
 							nil,
 						)
+						if base, ok := t.Base.(*ast.NamedType); ok && suggestion != "" {
+							c.attachFixes(c.replaceFix(fmt.Sprintf("Change to `%s`", suggestion), base.Name.Span(), suggestion))
+						}
 					}
 				}
 			}
@@ -319,6 +365,11 @@ func (c *Checker) resolveTypeInternal(typ ast.TypeExpr) Type {
 		// Resolve Self::Item or T::AssocType
 		baseType := c.resolveType(t.Base)
 
+		// mod::Type names a type declared in an imported module
+		if modType := c.resolveModuleType(baseType, t.Assoc); modType != nil {
+			return modType
+		}
+
 		// Return a ProjectedType that can be resolved later in context
 		return NewProjectedType(baseType, t.Assoc.Name)
 
@@ -333,27 +384,9 @@ func (c *Checker) resolveTypeInternal(typ ast.TypeExpr) Type {
 		return &Optional{Elem: elem}
 	case *ast.ArrayType:
 		elem := c.resolveType(t.Elem)
-		var length int64 = 0
-		if intLit, ok := t.Len.(*ast.IntegerLit); ok {
-			if val, err := strconv.ParseInt(intLit.Text, 10, 64); err == nil {
-				length = val
-			} else {
-				c.reportErrorWithCode(
-					"array length must be a positive integer",
-					t.Len.Span(),
-					diag.CodeTypeInvalidOperation,
-					"array length must be a compile-time constant integer > 0",
-					nil,
-				)
-			}
-		} else {
-			c.reportErrorWithCode(
-				"array length must be an integer literal constant",
-				t.Len.Span(),
-				diag.CodeTypeInvalidOperation,
-				"array length must be a compile-time constant (e.g., 5, not a variable)",
-				nil,
-			)
+		length, ok := c.arrayLen(t.Len)
+		if !ok {
+			return TypeError
 		}
 		return &Array{Elem: elem, Len: length}
 	case *ast.SliceType:
@@ -540,13 +573,11 @@ func (c *Checker) resolveTypeWithContextInternal(t ast.TypeExpr, context map[str
 		return &Slice{Elem: elem}
 	case *ast.ArrayType:
 		elem := c.resolveTypeWithContext(t.Elem, context)
-		var length int64 = 0
-		if intLit, ok := t.Len.(*ast.IntegerLit); ok {
-			if val, err := strconv.ParseInt(intLit.Text, 10, 64); err == nil {
-				length = val
-			}
-		}
-		return &Array{Elem: elem, Len: length}
+		// Method signatures are resolved more than once, so the length
+		// is evaluated without reporting errors
+		length, _ := c.evalConst(t.Len)
+		n, _ := length.(int64)
+		return &Array{Elem: elem, Len: n}
 	case *ast.OptionalType:
 		elem := c.resolveTypeWithContext(t.Elem, context)
 		return &Optional{Elem: elem}
@@ -556,6 +587,19 @@ func (c *Checker) resolveTypeWithContextInternal(t ast.TypeExpr, context map[str
 			elements = append(elements, c.resolveTypeWithContext(e, context))
 		}
 		return &Tuple{Elements: elements}
+	case *ast.FunctionType:
+		if len(t.TypeParams) > 0 {
+			return c.resolveType(t)
+		}
+		var params []Type
+		for _, p := range t.Params {
+			params = append(params, c.resolveTypeWithContext(p, context))
+		}
+		var ret Type = TypeVoid
+		if t.Return != nil {
+			ret = c.resolveTypeWithContext(t.Return, context)
+		}
+		return &Function{Params: params, Return: ret}
 	default:
 		// Fall back to regular resolution for other types
 		return c.resolveType(t)
@@ -49,6 +49,31 @@ func Satisfies(typ Type, bounds []Type, env *Environment) error {
 }
 
 func satisfiesSingle(typ Type, bound Type, env *Environment) error {
+	if env == nil {
+		return checkBound(typ, bound, nil)
+	}
+	key := satisfiesKey{typ: typ, bound: boundName(bound)}
+	if err, ok := env.satisfied[key]; ok {
+		return err
+	}
+	err := checkBound(typ, bound, env)
+	env.satisfied[key] = err
+	return err
+}
+
+// boundName returns the name of the trait a bound refers to, or its
+// spelling for a bound that is not a trait
+func boundName(bound Type) string {
+	switch b := bound.(type) {
+	case *Named:
+		return b.Name
+	case *Trait:
+		return b.Name
+	}
+	return bound.String()
+}
+
+func checkBound(typ Type, bound Type, env *Environment) error {
 	// If bound is a trait, check if typ implements it
 	if trait, ok := bound.(*Named); ok {
 		// Look up trait implementations in the environment
@@ -78,12 +103,22 @@ func satisfiesSingle(typ Type, bound Type, env *Environment) error {
 type Environment struct {
 	// Map from (trait name, type) -> bool indicating if impl exists
 	impls map[string]map[string]bool
+	// Results of checking a type against a bound, so that a bound checked
+	// at every call site does not spell out the type each time. Keyed on
+	// the type's identity: an equal type made elsewhere is checked anew.
+	satisfied map[satisfiesKey]error
+}
+
+type satisfiesKey struct {
+	typ   Type
+	bound string
 }
 
 // NewEnvironment creates a new type checking environment.
 func NewEnvironment() *Environment {
 	return &Environment{
-		impls: make(map[string]map[string]bool),
+		impls:     make(map[string]map[string]bool),
+		satisfied: make(map[satisfiesKey]error),
 	}
 }
 
@@ -93,6 +128,17 @@ func (e *Environment) RegisterImpl(traitName string, typ Type) {
 		e.impls[traitName] = make(map[string]bool)
 	}
 	e.impls[traitName][typ.String()] = true
+	// A type that did not satisfy the trait before may now
+	e.InvalidateCache()
+}
+
+// InvalidateCache forgets the results of earlier bound checks. The checker
+// calls it when it starts on a compilation unit, so the cache does not keep
+// the types of the previous one alive.
+func (e *Environment) InvalidateCache() {
+	if len(e.satisfied) > 0 {
+		e.satisfied = make(map[satisfiesKey]error)
+	}
 }
 
 // HasImpl checks if a type implements a trait.
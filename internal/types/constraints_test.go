@@ -0,0 +1,42 @@
+package types
+
+import "testing"
+
+// spelledType counts how often it is spelled out
+type spelledType struct {
+	name  string
+	calls int
+}
+
+func (t *spelledType) String() string {
+	t.calls++
+	return t.name
+}
+
+func (t *spelledType) IsType() {}
+
+func TestSatisfiesCachesPerTypeAndTrait(t *testing.T) {
+	env := NewEnvironment()
+	typ := &spelledType{name: "Vec[Vec[Vec[int]]]"}
+	env.RegisterImpl("Show", typ)
+	show := &Named{Name: "Show"}
+
+	for i := 0; i < 3; i++ {
+		if err := Satisfies(typ, []Type{show}, env); err != nil {
+			t.Fatalf("Satisfies: %v", err)
+		}
+	}
+	if typ.calls != 2 {
+		t.Errorf("type spelled %d times, want once to register and once to check", typ.calls)
+	}
+
+	// A failed check is cached too, until an impl is registered
+	eq := &Named{Name: "Eq"}
+	if err := Satisfies(typ, []Type{eq}, env); err == nil {
+		t.Fatal("expected Vec[Vec[Vec[int]]] not to satisfy Eq")
+	}
+	env.RegisterImpl("Eq", typ)
+	if err := Satisfies(typ, []Type{eq}, env); err != nil {
+		t.Errorf("registering an impl should invalidate the cached failure: %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+// The checker evaluates const and static initializers, so that consts can be
+// used where a compile-time value is needed: array lengths and match
+// patterns. A constant expression is built from int, float, bool and string
+// literals, consts, immutable statics, and the arithmetic, comparison and
+// logical operators. Its value is an int64, float64, bool or string.
+
+// constValue returns the value of the const or static declared by decl,
+// evaluating its initializer the first time. It reports an initializer that
+// is not a constant expression, or that depends on decl itself, and returns
+// false for it.
+func (c *Checker) constValue(decl ast.Decl) (any, bool) {
+	if v, done := c.constValues[decl]; done {
+		return v, v != nil
+	}
+	kind, name, value := constInit(decl)
+	if c.constEvaluating[decl] {
+		c.reportErrorWithCode(
+			fmt.Sprintf("%s `%s` is defined in terms of itself", kind, name.Name),
+			name.Span(),
+			diag.CodeTypeInvalidOperation,
+			fmt.Sprintf("break the cycle by giving one of the %ss a literal value", kind),
+			nil,
+		)
+		c.constValues[decl] = nil
+		return nil, false
+	}
+	c.constEvaluating[decl] = true
+	v, bad := c.evalConst(value)
+	delete(c.constEvaluating, decl)
+	if _, done := c.constValues[decl]; done {
+		// A cycle through decl was reported while evaluating it
+		return nil, false
+	}
+	if bad != nil {
+		c.reportErrorWithCode(
+			fmt.Sprintf("initializer of %s `%s` is not a constant expression", kind, name.Name),
+			bad.Span(),
+			diag.CodeTypeInvalidOperation,
+			fmt.Sprintf("a %s can only be computed from literals, consts, immutable statics and operators", kind),
+			nil,
+		)
+	}
+	c.constValues[decl] = v
+	return v, v != nil
+}
+
+// constInit returns the keyword that declared decl, a const or static, and
+// its name and initializer.
+func constInit(decl ast.Decl) (string, *ast.Ident, ast.Expr) {
+	if d, ok := decl.(*ast.StaticDecl); ok {
+		return "static", d.Name, d.Value
+	}
+	d := decl.(*ast.ConstDecl)
+	return "const", d.Name, d.Value
+}
+
+// constDecl returns the declaration of sym if its value is constant: a
+// const or an immutable static.
+func constDecl(sym *Symbol) ast.Decl {
+	switch d := sym.DefNode.(type) {
+	case *ast.ConstDecl:
+		return d
+	case *ast.StaticDecl:
+		if !d.Mutable {
+			return d
+		}
+	}
+	return nil
+}
+
+// evalConst evaluates expr as a constant expression. If it is not one, it
+// returns the subexpression at fault; a nil value with no subexpression at
+// fault means a const it uses was already reported.
+func (c *Checker) evalConst(expr ast.Expr) (any, ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.IntegerLit:
+		v, err := strconv.ParseInt(e.Text, 10, 64)
+		if err != nil {
+			return nil, e
+		}
+		return v, nil
+	case *ast.FloatLit:
+		v, err := strconv.ParseFloat(e.Text, 64)
+		if err != nil {
+			return nil, e
+		}
+		return v, nil
+	case *ast.BoolLit:
+		return e.Value, nil
+	case *ast.StringLit:
+		return e.Value, nil
+	case *ast.Ident:
+		sym := c.GlobalScope.Lookup(e.Name)
+		if sym == nil {
+			return nil, e
+		}
+		decl := constDecl(sym)
+		if decl == nil {
+			return nil, e
+		}
+		c.recordUse(e, sym)
+		v, _ := c.constValue(decl)
+		return v, nil
+	case *ast.PrefixExpr:
+		v, bad := c.evalConst(e.Expr)
+		if v == nil {
+			return nil, bad
+		}
+		switch x := v.(type) {
+		case int64:
+			if e.Op == lexer.MINUS {
+				return -x, nil
+			}
+		case float64:
+			if e.Op == lexer.MINUS {
+				return -x, nil
+			}
+		case bool:
+			if e.Op == lexer.BANG {
+				return !x, nil
+			}
+		}
+		return nil, e
+	case *ast.InfixExpr:
+		left, bad := c.evalConst(e.Left)
+		if left == nil {
+			return nil, bad
+		}
+		right, bad := c.evalConst(e.Right)
+		if right == nil {
+			return nil, bad
+		}
+		if v := foldConst(e.Op, left, right); v != nil {
+			return v, nil
+		}
+		return nil, e
+	}
+	return nil, expr
+}
+
+// foldConst applies the binary operator op to two constant values, or
+// returns nil if it does not apply to them. Integer arithmetic wraps around
+// like int64, and dividing an integer by zero is not constant.
+func foldConst(op lexer.TokenType, left, right any) any {
+	switch l := left.(type) {
+	case int64:
+		r, ok := right.(int64)
+		if !ok {
+			return nil
+		}
+		switch op {
+		case lexer.PLUS:
+			return l + r
+		case lexer.MINUS:
+			return l - r
+		case lexer.ASTERISK:
+			return l * r
+		case lexer.SLASH:
+			if r == 0 {
+				return nil
+			}
+			return l / r
+		}
+		return compareConst(op, l, r)
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil
+		}
+		switch op {
+		case lexer.PLUS:
+			return l + r
+		case lexer.MINUS:
+			return l - r
+		case lexer.ASTERISK:
+			return l * r
+		case lexer.SLASH:
+			return l / r
+		}
+		return compareConst(op, l, r)
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil
+		}
+		if op == lexer.PLUS {
+			return l + r
+		}
+		return compareConst(op, l, r)
+	case bool:
+		r, ok := right.(bool)
+		if !ok {
+			return nil
+		}
+		switch op {
+		case lexer.AND:
+			return l && r
+		case lexer.OR:
+			return l || r
+		case lexer.EQ:
+			return l == r
+		case lexer.NOT_EQ:
+			return l != r
+		}
+	}
+	return nil
+}
+
+// compareConst applies the comparison operator op to l and r, or returns nil
+// if op is not a comparison.
+func compareConst[T int64 | float64 | string](op lexer.TokenType, l, r T) any {
+	switch op {
+	case lexer.EQ:
+		return l == r
+	case lexer.NOT_EQ:
+		return l != r
+	case lexer.LT:
+		return l < r
+	case lexer.LE:
+		return l <= r
+	case lexer.GT:
+		return l > r
+	case lexer.GE:
+		return l >= r
+	}
+	return nil
+}
+
+// arrayLen returns the length of an array type [T; n], which is a constant
+// integer expression, reporting it if it is not one.
+func (c *Checker) arrayLen(n ast.Expr) (int64, bool) {
+	v, bad := c.evalConst(n)
+	length, ok := v.(int64)
+	switch {
+	case v == nil && bad == nil:
+		// The const it uses was already reported
+		return 0, false
+	case !ok:
+		if bad == nil {
+			bad = n
+		}
+		c.reportErrorWithCode(
+			"array length must be a constant integer expression",
+			bad.Span(),
+			diag.CodeTypeInvalidOperation,
+			"array length must be a compile-time constant (e.g., 5 or a const, not a variable)",
+			nil,
+		)
+		return 0, false
+	case length < 0:
+		c.reportErrorWithCode(
+			"array length must not be negative",
+			n.Span(),
+			diag.CodeTypeInvalidOperation,
+			fmt.Sprintf("the length evaluates to %d", length),
+			nil,
+		)
+		return 0, false
+	}
+	return length, true
+}
+
+// checkConstDecl checks the initializer of a const against its declared type
+// and evaluates it. As for statics, a mutable static in the initializer is
+// reported as not constant.
+func (c *Checker) checkConstDecl(d *ast.ConstDecl) {
+	typ := c.resolveType(d.Type)
+	valueType := c.checkExpr(d.Value, c.GlobalScope, true)
+	if !c.assignableTo(valueType, typ) {
+		c.reportTypeMismatch(typ, valueType, d.Value.Span(), "const initializer")
+		return
+	}
+	c.constValue(d)
+}
+
+// recordConst records the value of expr, an identifier or module path that
+// resolved to sym, if sym is a const, so that the lowerer uses the value.
+func (c *Checker) recordConst(expr ast.Expr, sym *Symbol) {
+	decl, ok := sym.DefNode.(*ast.ConstDecl)
+	if !ok {
+		return
+	}
+	if v, ok := c.constValue(decl); ok {
+		c.Consts[expr] = v
+	}
+}
+
+// constPattern reports whether a variable pattern names a const in scope,
+// in which case it matches the const's value instead of binding a variable,
+// and records the value for the lowerer.
+func (c *Checker) constPattern(p *ast.VarPattern, expected Type, scope *Scope) bool {
+	sym := scope.Lookup(p.Name.Name)
+	if sym == nil {
+		return false
+	}
+	decl, ok := sym.DefNode.(*ast.ConstDecl)
+	if !ok {
+		return false
+	}
+	c.recordUse(p.Name, sym)
+	if !c.assignableTo(sym.Type, expected) {
+		c.reportErrorWithCode(
+			fmt.Sprintf("mismatched types in pattern: expected `%s`, found const `%s` of type `%s`", expected, p.Name.Name, sym.Type),
+			p.Span(),
+			diag.CodeTypeMismatch,
+			"pattern constant must match the expected type",
+			nil,
+		)
+		return true
+	}
+	if v, ok := c.constValue(decl); ok {
+		c.Consts[p.Name] = v
+		c.ExprTypes[p.Name] = sym.Type
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+)
+
+func TestConstEvaluation(t *testing.T) {
+	tests := []struct {
+		name  string
+		decls string
+		body  string
+		want  string // substring of the only error, or "" for none
+	}{
+		{
+			name:  "array length",
+			decls: "const N: int = 2 * (1 + 1);",
+			body:  "let a: [int; N] = [1, 2, 3, 4];",
+		},
+		{
+			name:  "declared after use",
+			decls: "struct Grid { cells: [int; N + 1] }\nconst N: int = 1;",
+			body:  "let g = Grid { cells: [1, 2] };",
+		},
+		{
+			name:  "match arm",
+			decls: "const LIMIT: int = 10;",
+			body:  "match 3 { LIMIT => println(\"limit\"), _ => println(\"other\"), };",
+		},
+		{
+			name:  "wrong length",
+			decls: "const N: int = 2;",
+			body:  "let a: [int; N] = [1, 2, 3];",
+			want:  "[int; 2]",
+		},
+		{
+			name: "variable length",
+			body: "let n = 2;\n    let a: [int; n] = [1, 2];",
+			want: "array length must be a constant integer expression",
+		},
+		{
+			name:  "negative length",
+			decls: "const N: int = 1 - 2;",
+			body:  "let a: [int; N] = [1];",
+			want:  "array length must not be negative",
+		},
+		{
+			name:  "call in initializer",
+			decls: "fn f() -> int { return 1; }\nconst N: int = f();",
+			want:  "initializer of const `N` is not a constant expression",
+		},
+		{
+			name:  "cycle",
+			decls: "const A: int = B;\nconst B: int = A;",
+			want:  "is defined in terms of itself",
+		},
+		{
+			name:  "pattern type",
+			decls: "const NAME: string = \"x\";",
+			body:  "match 3 { NAME => println(\"name\"), _ => println(\"other\"), };",
+			want:  "found const `NAME` of type `string`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package main;\n" + tt.decls + "\nfn main() {\n    " + tt.body + "\n}\n"
+			checker := checkSource(t, src, "main.mal")
+			if tt.want == "" {
+				if len(checker.Errors) > 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			if len(checker.Errors) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(checker.Errors), checker.Errors)
+			}
+			if got := checker.Errors[0].Message; !strings.Contains(got, tt.want) {
+				t.Errorf("error = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstValues(t *testing.T) {
+	checker := checkSource(t, `package main;
+const BASE: int = 6;
+const AREA: int = BASE * BASE / 4;
+const HALF: float = 1.0 / 2.0;
+const NAME: string = "a" + "b";
+const BIG: bool = AREA > 5 && !false;
+fn main() {
+    println(AREA);
+    println(HALF);
+    println(NAME);
+    println(BIG);
+}
+`, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+	got := make(map[string]any)
+	for expr, v := range checker.Consts {
+		if ident, ok := expr.(*ast.Ident); ok {
+			got[ident.Name] = v
+		}
+	}
+	want := map[string]any{
+		"BASE": int64(6),
+		"AREA": int64(9),
+		"HALF": 0.5,
+		"NAME": "ab",
+		"BIG":  true,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %#v, want %#v", name, got[name], v)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// registerDrop marks the struct a Drop impl d is for, so that its values are
+// dropped when the variable owning them goes out of scope. Only structs can
+// implement Drop, and not #[copy] ones, whose copies would each be dropped.
+func (c *Checker) registerDrop(d *ast.ImplDecl, targetType Type) {
+	if d.Trait == nil || !c.isDropTrait(d.Trait) {
+		return
+	}
+	s := dropStructOf(targetType)
+	if s == nil {
+		c.reportErrorWithCode(fmt.Sprintf("Drop can only be implemented for structs, not `%s`", targetType),
+			d.Target.Span(), diag.CodeTypeConstraintViolation, "", nil)
+		return
+	}
+	if s.Copy {
+		c.reportErrorWithCode(fmt.Sprintf("`%s` cannot implement Drop because it is #[copy]", s.Name),
+			d.Target.Span(), diag.CodeTypeConstraintViolation,
+			fmt.Sprintf("remove #[copy] from `%s`; every copy would be dropped", s.Name), nil)
+		return
+	}
+	s.Drop = true
+}
+
+// isDropTrait reports whether expr names the prelude Drop trait.
+func (c *Checker) isDropTrait(expr ast.TypeExpr) bool {
+	named, ok := expr.(*ast.NamedType)
+	if !ok {
+		return false
+	}
+	mod, ok := c.Modules["std/drop"]
+	if !ok {
+		return false
+	}
+	drop := mod.Scope.Symbols["Drop"]
+	return drop != nil && c.GlobalScope.Lookup(named.Name.Name) == drop
+}
+
+// dropStructOf returns the struct t is or instantiates, or nil.
+func dropStructOf(t Type) *Struct {
+	switch t := t.(type) {
+	case *Struct:
+		return t
+	case *Named:
+		if t.Ref != nil {
+			return dropStructOf(t.Ref)
+		}
+	case *GenericInstance:
+		return dropStructOf(t.Base)
+	}
+	return nil
+}
+
+// NeedsDrop reports whether values of type t are dropped at scope exit.
+func NeedsDrop(t Type) bool {
+	s := dropStructOf(t)
+	return s != nil && s.Drop
+}
+
+// checkExplicitDrop rejects calling the drop method of a Drop type directly,
+// which would drop the value a second time when its owner goes out of scope.
+func (c *Checker) checkExplicitDrop(call *ast.CallExpr) {
+	field, ok := call.Callee.(*ast.FieldExpr)
+	if !ok || field.Field.Name != "drop" {
+		return
+	}
+	typ := c.ExprTypes[field.Target]
+	if ref, ok := typ.(*Reference); ok {
+		typ = ref.Elem
+	}
+	if !NeedsDrop(typ) {
+		return
+	}
+	c.reportErrorWithCode("explicit call of a destructor",
+		field.Field.Span(), diag.CodeTypeInvalidOperation,
+		"values are dropped when their owner goes out of scope; let it go out of scope early with a block instead", nil)
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDropImpl(t *testing.T) {
+	const decls = `package main;
+struct File { fd: int }
+impl Drop for File {
+    fn drop(&mut self) { println(self.fd); }
+}
+`
+	tests := []struct {
+		name string
+		src  string
+		want string // substring of the only error, or "" for none
+	}{
+		{
+			name: "struct",
+			src:  decls + "fn main() { let f = File { fd: 1 }; }\n",
+		},
+		{
+			name: "enum",
+			src:  "package main;\nenum E { A }\nimpl Drop for E { fn drop(&mut self) {} }\nfn main() {}\n",
+			want: "Drop can only be implemented for structs, not `E`",
+		},
+		{
+			name: "copy struct",
+			src:  "package main;\n#[copy]\nstruct C { x: int }\nimpl Drop for C { fn drop(&mut self) {} }\nfn main() {}\n",
+			want: "`C` cannot implement Drop because it is #[copy]",
+		},
+		{
+			name: "explicit drop call",
+			src:  decls + "fn main() {\n    let mut f = File { fd: 1 };\n    f.drop();\n}\n",
+			want: "explicit call of a destructor",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := checkSource(t, tt.src, "main.mal")
+			if tt.want == "" {
+				if len(checker.Errors) > 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			if len(checker.Errors) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(checker.Errors), checker.Errors)
+			}
+			if got := checker.Errors[0].Message; !strings.Contains(got, tt.want) {
+				t.Errorf("error = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropMarksStruct(t *testing.T) {
+	src := `package main;
+struct File { fd: int }
+struct Plain { x: int }
+impl Drop for File {
+    fn drop(&mut self) {}
+}
+fn main() {}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+	if !NeedsDrop(checker.GlobalScope.Lookup("File").Type) {
+		t.Error("File has a Drop impl but is not dropped")
+	}
+	if NeedsDrop(checker.GlobalScope.Lookup("Plain").Type) {
+		t.Error("Plain has no Drop impl but is dropped")
+	}
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// reprTypes are the tag types #[repr] accepts, by name.
+var reprTypes = map[string]*Primitive{
+	"i8":    TypeInt8,
+	"i32":   TypeInt32,
+	"i64":   TypeInt64,
+	"int":   TypeInt,
+	"u8":    TypeU8,
+	"u16":   TypeU16,
+	"u32":   TypeU32,
+	"u64":   TypeU64,
+	"usize": TypeUsize,
+}
+
+// reprMax is the largest tag of each #[repr] type narrower than 64 bits.
+var reprMax = map[PrimitiveKind]uint64{
+	Int8:  1<<7 - 1,
+	Int32: 1<<31 - 1,
+	U8:    1<<8 - 1,
+	U16:   1<<16 - 1,
+	U32:   1<<32 - 1,
+}
+
+// reprArg accepts a single tag type name.
+func reprArg(args []ast.Expr) string {
+	if len(args) == 1 {
+		if ident, ok := args[0].(*ast.Ident); ok && reprTypes[ident.Name] != nil {
+			return ""
+		}
+	}
+	return "expected an integer type"
+}
+
+// enumRepr returns the tag type given to decl by #[repr], or nil. A type too
+// narrow for the variant indices is reported; bad arguments are left to
+// checkAttributes.
+func (c *Checker) enumRepr(decl *ast.EnumDecl) *Primitive {
+	attr := ast.FindAttribute(decl.Attrs, "repr")
+	if attr == nil || len(attr.Args) != 1 {
+		return nil
+	}
+	ident, ok := attr.Args[0].(*ast.Ident)
+	if !ok || reprTypes[ident.Name] == nil {
+		return nil
+	}
+	repr := reprTypes[ident.Name]
+
+	if max, ok := reprMax[repr.Kind]; ok && uint64(len(decl.Variants)) > max+1 {
+		c.reportErrorWithCode(fmt.Sprintf("enum `%s` has %d variants, more than `%s` can number", decl.Name.Name, len(decl.Variants), repr),
+			attr.Span(), diag.CodeTypeInvalidAttribute, fmt.Sprintf("`%s` holds tags up to %d; use a wider type", repr, max), nil)
+		return nil
+	}
+	return repr
+}
+
+// checkEnumTagCall checks enum_tag(value), which returns the index of the
+// variant of value as the enum's tag type.
+func (c *Checker) checkEnumTagCall(call *ast.CallExpr, scope *Scope, inUnsafe bool) Type {
+	if len(call.Args) != 1 {
+		c.reportErrorWithCode(fmt.Sprintf("enum_tag takes 1 argument, got %d", len(call.Args)), call.Span(),
+			diag.CodeTypeInvalidOperation, "call `enum_tag(value)` with an enum value", nil)
+		for _, arg := range call.Args {
+			c.checkExpr(arg, scope, inUnsafe)
+		}
+		return TypeInt
+	}
+
+	argType := c.checkExpr(call.Args[0], scope, inUnsafe)
+	if enum := enumOf(argType); enum != nil {
+		return enum.TagType()
+	}
+	if !c.poisons(argType) {
+		c.reportErrorWithCode(fmt.Sprintf("enum_tag expects an enum, got `%s`", argType), call.Args[0].Span(),
+			diag.CodeTypeMismatch, "enum_tag returns the index of the variant of an enum value", nil)
+	}
+	return TypeInt
+}
+
+// enumOf returns the enum t is, or is an instance of, or nil.
+func enumOf(t Type) *Enum {
+	switch t := t.(type) {
+	case *Enum:
+		return t
+	case *Named:
+		if t.Ref != nil {
+			return enumOf(t.Ref)
+		}
+	case *GenericInstance:
+		return enumOf(t.Base)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnumTagsAndRepr(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // substring of the only error, or "" for none
+	}{
+		{
+			name: "tags",
+			src: `package main;
+#[repr(u8)]
+enum Color { Red, Green, Blue }
+enum Shape { Circle(int), Square(int) }
+fn main() {
+	let c = Color::Green;
+	let small: u8 = enum_tag(c);
+	let wide = c as int;
+	let s: int = enum_tag(Shape::Circle(1));
+}
+`,
+		},
+		{
+			name: "cast of an enum with payloads",
+			src:  "package main;\nenum Shape { Circle(int) }\nfn main() { let n = Shape::Circle(1) as int; }\n",
+			want: "cannot cast type Shape to int",
+		},
+		{
+			name: "enum_tag of a non-enum",
+			src:  "package main;\nfn main() { let n = enum_tag(3); }\n",
+			want: "enum_tag expects an enum, got `int`",
+		},
+		{
+			name: "repr of a non-integer type",
+			src:  "package main;\n#[repr(float)]\nenum E { A }\nfn main() {}\n",
+			want: "invalid arguments to `#[repr]`",
+		},
+		{
+			name: "repr on a struct",
+			src:  "package main;\n#[repr(u8)]\nstruct S { x: int }\nfn main() {}\n",
+			want: "`#[repr]` cannot be used on a struct",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := checkSource(t, tt.src, "main.mal")
+			if tt.want == "" {
+				if len(checker.Errors) > 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			if len(checker.Errors) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(checker.Errors), checker.Errors)
+			}
+			if got := checker.Errors[0].Message; !strings.Contains(got, tt.want) {
+				t.Errorf("error = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnumReprTooNarrow(t *testing.T) {
+	var variants []string
+	for i := 0; i < 129; i++ {
+		variants = append(variants, "V"+strings.Repeat("x", i))
+	}
+	src := "package main;\n#[repr(i8)]\nenum Big { " + strings.Join(variants, ", ") + " }\nfn main() {}\n"
+
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 1 || !strings.Contains(checker.Errors[0].Message, "enum `Big` has 129 variants, more than `i8` can number") {
+		t.Fatalf("expected a too-narrow repr error, got %v", checker.Errors)
+	}
+}
@@ -0,0 +1,50 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// ffiHelp lists the types extern functions may take and return.
+const ffiHelp = "extern functions take and return integers (`int`, `i8` to `i64`, `u8` to `u64`, `usize`), `float`, `bool` and raw pointers such as `*u8`"
+
+// checkExternFn reports what keeps an extern function from being called
+// through the C ABI: type parameters, and parameter or return types that C
+// has no counterpart for.
+func (c *Checker) checkExternFn(decl *ast.FnDecl, fnType *Function) {
+	if len(decl.TypeParams) > 0 || decl.Effects != nil || decl.Where != nil {
+		c.reportErrorWithCode(fmt.Sprintf("extern function `%s` cannot be generic", decl.Name.Name), decl.Name.Span(),
+			diag.CodeTypeNotFFISafe, "C functions have a single signature; declare one extern function per type", nil)
+		return
+	}
+
+	for i, param := range decl.Params {
+		if i < len(fnType.Params) && !isFFISafe(fnType.Params[i]) {
+			c.reportErrorWithCode(fmt.Sprintf("type `%s` of parameter `%s` cannot be passed to C", fnType.Params[i], param.Name.Name),
+				param.Type.Span(), diag.CodeTypeNotFFISafe, ffiHelp, nil)
+		}
+	}
+
+	if decl.ReturnType != nil && fnType.Return != TypeVoid && !isFFISafe(fnType.Return) {
+		c.reportErrorWithCode(fmt.Sprintf("type `%s` cannot be returned from C", fnType.Return),
+			decl.ReturnType.Span(), diag.CodeTypeNotFFISafe, ffiHelp, nil)
+	}
+}
+
+// isFFISafe reports whether values of t have the same representation in
+// Malphas and C. Raw pointers are passed as plain addresses, whatever they
+// point to.
+func isFFISafe(t Type) bool {
+	switch t := t.(type) {
+	case *Primitive:
+		switch t.Kind {
+		case Int, Int8, Int32, Int64, U8, U16, U32, U64, Usize, Float, Bool:
+			return true
+		}
+	case *Pointer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestExternFnTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // substring of the only error, or "" for none
+	}{
+		{
+			name: "C types",
+			src: `package main;
+extern "C" fn abs(x: i32) -> i32;
+extern "C" fn puts(s: *u8) -> i32;
+extern "C" fn srand(seed: u32);
+fn magnitude(x: i32) -> i32 {
+	return abs(x);
+}
+fn main() {}
+`,
+		},
+		{
+			name: "string parameter",
+			src:  "package main;\nextern \"C\" fn puts(s: string) -> i32;\nfn main() {}\n",
+			want: "type `string` of parameter `s` cannot be passed to C",
+		},
+		{
+			name: "struct return",
+			src:  "package main;\nstruct P { x: int }\nextern \"C\" fn make() -> P;\nfn main() {}\n",
+			want: "cannot be returned from C",
+		},
+		{
+			name: "generic",
+			src:  "package main;\nextern \"C\" fn id[T](x: T) -> T;\nfn main() {}\n",
+			want: "extern function `id` cannot be generic",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := checkSource(t, tt.src, "main.mal")
+			if tt.want == "" {
+				if len(checker.Errors) > 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			if len(checker.Errors) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(checker.Errors), checker.Errors)
+			}
+			if got := checker.Errors[0]; !strings.Contains(got.Message, tt.want) || got.Code != diag.CodeTypeNotFFISafe {
+				t.Errorf("error = %s %q, want it to mention %q", got.Code, got.Message, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+// attachFixes adds fixes to the diagnostic reported last
+func (c *Checker) attachFixes(fixes ...diag.Fix) {
+	if len(c.Errors) == 0 || len(fixes) == 0 || c.dropped {
+		return
+	}
+	last := &c.Errors[len(c.Errors)-1]
+	last.Fixes = append(last.Fixes, fixes...)
+}
+
+// replaceFix replaces the text of span with text
+func (c *Checker) replaceFix(message string, span lexer.Span, text string) diag.Fix {
+	return diag.Fix{Message: message, Edits: []diag.Edit{{Span: c.toDiagSpan(span), NewText: text}}}
+}
+
+// importFixes offers a `use` declaration for each loaded module that
+// exports name, for a name undefined at span. The declaration goes before
+// the file's first use declaration, or its first item.
+func (c *Checker) importFixes(name string, span lexer.Span) []diag.Fix {
+	if c.file == nil {
+		return nil
+	}
+	var anchor lexer.Span
+	text := "use %s::%s;\n"
+	switch {
+	case len(c.file.Uses) > 0:
+		anchor = c.file.Uses[0].Span()
+	case len(c.file.Decls) > 0:
+		anchor = c.file.Decls[0].Span()
+		text += "\n"
+	default:
+		return nil
+	}
+	if anchor.Filename != span.Filename {
+		return nil // The name is undefined in a module, not in the checked file
+	}
+	anchor.End = anchor.Start
+
+	var fixes []diag.Fix
+	for _, modName := range c.sortedModuleNames() {
+		mod := c.Modules[modName]
+		if mod.Scope == nil || mod.Scope.Lookup(name) == nil {
+			continue
+		}
+		path := strings.ReplaceAll(mod.Name, "/", "::")
+		fixes = append(fixes, c.replaceFix(
+			fmt.Sprintf("Import `%s` from `%s`", name, path),
+			anchor,
+			fmt.Sprintf(text, path, name),
+		))
+	}
+	return fixes
+}
+
+func (c *Checker) sortedModuleNames() []string {
+	names := make([]string, 0, len(c.Modules))
+	for name := range c.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchArmFixes adds arms for the missing variants of enum after the last
+// arm of expr, indented like it. A placeholder `{}` body is left to fill in.
+func (c *Checker) matchArmFixes(expr *ast.MatchExpr, enum *Enum, missing []Variant) []diag.Fix {
+	if len(expr.Arms) == 0 || len(missing) == 0 {
+		return nil
+	}
+	last := expr.Arms[len(expr.Arms)-1]
+	at := last.Span()
+	at.Start = at.End
+	indent := strings.Repeat(" ", max(last.Pattern.Span().Column-1, 0))
+
+	arm := func(v Variant) string {
+		pattern := enum.Name + "::" + v.Name
+		if len(v.Params) > 0 {
+			pattern += "(" + strings.TrimSuffix(strings.Repeat("_, ", len(v.Params)), ", ") + ")"
+		}
+		return ",\n" + indent + pattern + " => {}"
+	}
+
+	var fixes []diag.Fix
+	for _, v := range missing {
+		fixes = append(fixes, c.replaceFix(fmt.Sprintf("Add match arm for `%s::%s`", enum.Name, v.Name), at, arm(v)))
+	}
+	if len(missing) > 1 {
+		var all strings.Builder
+		for _, v := range missing {
+			all.WriteString(arm(v))
+		}
+		fixes = append(fixes, c.replaceFix("Add all missing match arms", at, all.String()))
+	}
+	return fixes
+}
+
+// reportUnusedVariables warns about the variables bound in file by `let`
+// and match patterns that are never used. Names starting with an
+// underscore are exempt, and prefixing one is the offered fix.
+func (c *Checker) reportUnusedVariables(file *ast.File) {
+	used := make(map[*ast.Ident]bool)
+	for _, sym := range c.Uses {
+		if def := sym.DefIdent(); def != nil {
+			used[def] = true
+		}
+	}
+
+	ast.Walk(file, func(n ast.Node) bool {
+		var name *ast.Ident
+		switch n := n.(type) {
+		case *ast.LetStmt:
+			name = n.Name
+		case *ast.VarPattern:
+			name = n.Name
+		default:
+			return true
+		}
+		if name == nil || strings.HasPrefix(name.Name, "_") || used[name] || c.Defs[name] == nil {
+			return true
+		}
+		span := c.toDiagSpan(name.Span())
+		warning := diag.Diagnostic{
+			Stage:    diag.StageTypeCheck,
+			Severity: diag.SeverityWarning,
+			Code:     diag.CodeUnusedVariable,
+			Message:  fmt.Sprintf("unused variable `%s`", name.Name),
+			Span:     span,
+			Help:     fmt.Sprintf("if this is intentional, prefix it with an underscore: `_%s`", name.Name),
+		}
+		warning = warning.WithPrimarySpan(span, "never used")
+		warning = warning.WithFix(fmt.Sprintf("Prefix `%s` with an underscore", name.Name), diag.Edit{Span: span, NewText: "_" + name.Name})
+		c.Warnings = append(c.Warnings, warning)
+		return true
+	})
+}
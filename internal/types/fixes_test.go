@@ -0,0 +1,242 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+// checkSource parses and checks src as the file filename
+func checkSource(t *testing.T, src, filename string) *Checker {
+	t.Helper()
+	p := parser.New(src, parser.WithFilename(filename))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	checker := NewChecker()
+	checker.CheckWithFilename(file, filename)
+	return checker
+}
+
+// applyFix applies the edits of fix, which must not overlap, to src
+func applyFix(src string, fix diag.Fix) string {
+	runes := []rune(src)
+	for i := len(fix.Edits) - 1; i >= 0; i-- {
+		e := fix.Edits[i]
+		runes = append(runes[:e.Span.Start], append([]rune(e.NewText), runes[e.Span.End:]...)...)
+	}
+	return string(runes)
+}
+
+// findFix returns the fix with the given message among the fixes of ds
+func findFix(t *testing.T, ds []diag.Diagnostic, message string) diag.Fix {
+	t.Helper()
+	for _, d := range ds {
+		for _, fix := range d.Fixes {
+			if fix.Message == message {
+				return fix
+			}
+		}
+	}
+	t.Fatalf("no fix %q in %v", message, ds)
+	return diag.Fix{}
+}
+
+func TestUnusedVariableFix(t *testing.T) {
+	src := `package main;
+
+fn main() {
+    let used = 1;
+    let unused = 2;
+    let _ignored = 3;
+    println(used);
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+	if len(checker.Warnings) != 1 || checker.Warnings[0].Code != diag.CodeUnusedVariable {
+		t.Fatalf("expected one unused variable warning, got %v", checker.Warnings)
+	}
+
+	fixed := applyFix(src, findFix(t, checker.Warnings, "Prefix `unused` with an underscore"))
+	if checker := checkSource(t, fixed, "main.mal"); len(checker.Warnings) != 0 {
+		t.Errorf("warnings after fix: %v\n%s", checker.Warnings, fixed)
+	}
+}
+
+func TestNonExhaustiveMatchFix(t *testing.T) {
+	src := `package main;
+
+enum Shape {
+    Circle(int),
+    Square(int, int),
+    Dot,
+}
+
+fn describe(s: Shape) {
+    match s {
+        Shape::Circle(r) => {
+            println(r);
+        }
+    };
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %v", checker.Errors)
+	}
+
+	fixed := applyFix(src, findFix(t, checker.Errors, "Add match arm for `Shape::Square`"))
+	want := `        Shape::Circle(r) => {
+            println(r);
+        },
+        Shape::Square(_, _) => {}
+    };`
+	if !strings.Contains(fixed, want) {
+		t.Errorf("fixed source:\n%s\nwant it to contain:\n%s", fixed, want)
+	}
+
+	fixed = applyFix(src, findFix(t, checker.Errors, "Add all missing match arms"))
+	if checker := checkSource(t, fixed, "main.mal"); len(checker.Errors) != 0 {
+		t.Errorf("errors after fix: %v\n%s", checker.Errors, fixed)
+	}
+}
+
+func TestUndefinedIdentifierFixes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "utils.mal"), []byte("pub fn add(a: int, b: int) -> int {\n    return a + b;\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := `package main;
+
+mod utils;
+
+fn main() {
+    let total = 1;
+    println(totl);
+    println(add(total, 2));
+}
+`
+	mainPath := filepath.Join(dir, "main.mal")
+
+	checker := checkSource(t, src, mainPath)
+	fixed := applyFix(src, findFix(t, checker.Errors, "Change to `total`"))
+	fixed = applyFix(fixed, findFix(t, checkSource(t, fixed, mainPath).Errors, "Import `add` from `utils`"))
+	if !strings.Contains(fixed, "mod utils;\n\nuse utils::add;\n\nfn main() {") {
+		t.Errorf("import not inserted before the first item:\n%s", fixed)
+	}
+	if checker := checkSource(t, fixed, mainPath); len(checker.Errors) != 0 {
+		t.Errorf("errors after fixes: %v\n%s", checker.Errors, fixed)
+	}
+}
+
+func TestUnreachableCodeWarns(t *testing.T) {
+	src := `package main;
+
+fn f() -> int {
+    return 1;
+    println("never");
+}
+
+fn main() {
+    println(f());
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unreachable code should not be an error: %v", checker.Errors)
+	}
+	if len(checker.Warnings) != 1 || checker.Warnings[0].Code != diag.CodeUnreachableCode {
+		t.Fatalf("expected one unreachable code warning, got %v", checker.Warnings)
+	}
+	if checker.Warnings[0].Severity != diag.SeverityWarning {
+		t.Errorf("severity = %q, want warning", checker.Warnings[0].Severity)
+	}
+}
+
+func TestRenameFixes(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		fix  string
+		want string // A line of the fixed source
+	}{
+		{
+			name: "field",
+			src: `package main;
+
+struct Point { x: int, y: int }
+
+fn main() {
+    let p = Point { x: 1, y: 2 };
+    println(p.yy);
+}
+`,
+			fix:  "Change to `y`",
+			want: "    println(p.y);",
+		},
+		{
+			name: "method",
+			src: `package main;
+
+struct Counter { n: int }
+
+impl Counter {
+    fn value(&self) -> int {
+        return self.n;
+    }
+}
+
+fn main() {
+    let c = Counter { n: 1 };
+    println(c.valeu());
+}
+`,
+			fix:  "Change to `value`",
+			want: "    println(c.value());",
+		},
+		{
+			name: "variant",
+			src: `package main;
+
+enum Shape {
+    Circle(int),
+    Dot,
+}
+
+fn area(s: Shape) -> int {
+    match s {
+        Shape::Circel(r) => r * r,
+        Shape::Dot => 0,
+    }
+}
+
+fn main() {
+    println(area(Shape::Dot));
+}
+`,
+			fix:  "Change to `Circle`",
+			want: "        Shape::Circle(r) => r * r,",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := checkSource(t, tt.src, "main.mal")
+			fixed := applyFix(tt.src, findFix(t, checker.Errors, tt.fix))
+			if !strings.Contains(fixed, tt.want+"\n") {
+				t.Fatalf("fixed source lacks %q:\n%s", tt.want, fixed)
+			}
+			if checker := checkSource(t, fixed, "main.mal"); len(checker.Errors) != 0 {
+				t.Errorf("errors after fix: %v\n%s", checker.Errors, fixed)
+			}
+		})
+	}
+}
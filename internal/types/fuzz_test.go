@@ -0,0 +1,69 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+// FuzzCheck checks that type checking a file that parses never panics, and
+// that its diagnostics point within the source. The seed corpus is the
+// parser's testdata and the programs under the repository's tests directory.
+func FuzzCheck(f *testing.F) {
+	for _, pattern := range []string{
+		filepath.Join("..", "parser", "testdata", "*.mlp"),
+		filepath.Join("..", "..", "tests", "*", "*.mal"),
+	} {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(string(data))
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, src string) {
+		if !utf8.ValidString(src) {
+			return
+		}
+		p := parser.New(src)
+		file := p.ParseFile()
+		if len(p.Errors()) > 0 {
+			// Files that do not parse are never checked
+			return
+		}
+
+		checker := NewChecker()
+		checker.Check(file)
+
+		n := utf8.RuneCountInString(src)
+		for _, d := range checker.Errors {
+			checkDiagnosticSpan(t, d, d.Span, n)
+			for _, label := range d.LabeledSpans {
+				checkDiagnosticSpan(t, d, label.Span, n)
+			}
+		}
+	})
+}
+
+// checkDiagnosticSpan reports a span of d that does not lie within a source
+// of n runes. Spans in other files, such as a declaration in the standard
+// library, are not checked.
+func checkDiagnosticSpan(t *testing.T, d diag.Diagnostic, span diag.Span, n int) {
+	t.Helper()
+	if span.Filename != "" {
+		return
+	}
+	if span.Start < 0 || span.End < span.Start || span.End > n {
+		t.Fatalf("%s: %q has span [%d, %d) outside the %d-rune source", d.Code, d.Message, span.Start, span.End, n)
+	}
+}
@@ -0,0 +1,103 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+const genericMethodsSrc = `package main;
+
+trait Show {
+    fn show(&self) -> string;
+}
+
+struct Box[T] { value: T }
+struct Pair[A, B] { first: A, second: B }
+
+impl[T] Box[T] {
+    fn with[U](self, other: U) -> Pair[T, U] {
+        return Pair[T, U] { first: self.value, second: other };
+    }
+    fn map[U](self, f: fn(T) -> U) -> U {
+        return f(self.value);
+    }
+    fn shown[S: Show](&self, s: S) -> string {
+        return s.show();
+    }
+}
+`
+
+func TestGenericMethodInference(t *testing.T) {
+	src := genericMethodsSrc + `
+fn main() {
+    let b = Box[int] { value: 2 };
+    let p = b.with("two");
+    let first: int = p.first;
+    let second: string = p.second;
+    let s: string = b.map(|x: int| { "hi" });
+    println(first);
+    println(second);
+    println(s);
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+
+	got := make(map[string]string)
+	for call, args := range checker.CallTypeArgs {
+		if field, ok := call.Callee.(*ast.FieldExpr); ok {
+			got[field.Field.Name] = fmt.Sprint(args)
+		}
+	}
+	// The receiver's own type arguments are added when the call is lowered
+	want := map[string]string{"with": "[string]", "map": "[string]"}
+	for name, args := range want {
+		if got[name] != args {
+			t.Errorf("type arguments of %s = %q, want %q", name, got[name], args)
+		}
+	}
+}
+
+func TestGenericMethodErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code diag.Code
+		want string
+	}{
+		{
+			name: "mismatched closure",
+			body: "let s: int = b.map(|x: string| { 1 });",
+			code: diag.CodeTypeInvalidGenericArgs,
+			want: "type inference failed for method map",
+		},
+		{
+			name: "result used as another type",
+			body: "let s: bool = b.with(1).second;",
+			code: diag.CodeTypeCannotAssign,
+		},
+		{
+			name: "unsatisfied bound",
+			body: "println(b.shown(3));",
+			code: diag.CodeTypeConstraintNotSatisfied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := genericMethodsSrc + "\nfn main() {\n    let b = Box[int] { value: 2 };\n    " + tt.body + "\n}\n"
+			checker := checkSource(t, src, "main.mal")
+			for _, err := range checker.Errors {
+				if err.Code == tt.code && strings.Contains(err.Message, tt.want) {
+					return
+				}
+			}
+			t.Errorf("want a %s error mentioning %q, got %v", tt.code, tt.want, checker.Errors)
+		})
+	}
+}
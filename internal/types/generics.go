@@ -60,6 +60,11 @@ func Substitute(t Type, subst map[string]Type) Type {
 			return replacement
 		}
 		return t
+	case *Infer:
+		if t.Ref != nil {
+			return Substitute(t.Ref, subst)
+		}
+		return t
 	case *Named:
 		if replacement, ok := subst[t.Name]; ok {
 			return replacement
@@ -103,6 +108,12 @@ func Substitute(t Type, subst map[string]Type) Type {
 			return &Channel{Elem: newElem, Dir: t.Dir}
 		}
 		return t
+	case *JoinHandle:
+		newElem := Substitute(t.Elem, subst)
+		if newElem != t.Elem {
+			return &JoinHandle{Elem: newElem}
+		}
+		return t
 	case *Slice:
 		newElem := Substitute(t.Elem, subst)
 		if newElem != t.Elem {
@@ -181,6 +192,12 @@ func unify(t1, t2 Type, subst map[string]Type) error {
 	if p, ok := t2.(*TypeParam); ok {
 		return bind(p.Name, t1, subst)
 	}
+	if v, ok := openInfer(t1); ok && v.fix(t2) {
+		return nil
+	}
+	if v, ok := openInfer(t2); ok && v.fix(t1) {
+		return nil
+	}
 
 	switch t1 := t1.(type) {
 	case *GenericInstance:
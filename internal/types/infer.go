@@ -0,0 +1,260 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+// Infer is an inference variable: a type argument that could not be
+// inferred where it was needed, such as T in `let v = Vec::new();`. It is
+// fixed by a later use, like `v.push(1)`, before the enclosing function is
+// finished.
+type Infer struct {
+	Param   string     // Type parameter the variable stands for
+	Ref     Type       // Type the variable was fixed to, nil while open
+	Span    lexer.Span // Expression that introduced the variable
+	Binding string     // Let binding whose type holds it, if any
+	// BindingType is the type of Binding when it was bound
+	BindingType Type
+}
+
+func (v *Infer) String() string {
+	if v.Ref != nil {
+		return v.Ref.String()
+	}
+	return "_"
+}
+
+func (v *Infer) IsType() {}
+
+// openInfer returns t as an open inference variable, following fixed ones.
+func openInfer(t Type) (*Infer, bool) {
+	for {
+		v, ok := t.(*Infer)
+		if !ok {
+			return nil, false
+		}
+		if v.Ref == nil {
+			return v, true
+		}
+		t = v.Ref
+	}
+}
+
+// openInfers returns the open inference variables t is built from.
+func openInfers(t Type) []*Infer {
+	var vars []*Infer
+	var walk func(Type)
+	walk = func(t Type) {
+		switch t := t.(type) {
+		case *Infer:
+			if t.Ref != nil {
+				walk(t.Ref)
+			} else {
+				vars = append(vars, t)
+			}
+		case *GenericInstance:
+			for _, arg := range t.Args {
+				walk(arg)
+			}
+		case *Function:
+			for _, p := range t.Params {
+				walk(p)
+			}
+			walk(t.Return)
+		case *Tuple:
+			for _, elem := range t.Elements {
+				walk(elem)
+			}
+		case *Map:
+			walk(t.Key)
+			walk(t.Value)
+		case *Channel:
+			walk(t.Elem)
+		case *JoinHandle:
+			walk(t.Elem)
+		case *Slice:
+			walk(t.Elem)
+		case *Array:
+			walk(t.Elem)
+		case *Pointer:
+			walk(t.Elem)
+		case *Reference:
+			walk(t.Elem)
+		case *Optional:
+			walk(t.Elem)
+		}
+	}
+	walk(t)
+	return vars
+}
+
+// newInfer creates an open inference variable for the type parameter
+// named param. It is placed at the expression whose type first holds it.
+func (c *Checker) newInfer(param string) *Infer {
+	v := &Infer{Param: param}
+	c.infers = append(c.infers, v)
+	return v
+}
+
+// fix fixes the open variable v to t, unless t is built from v itself.
+func (v *Infer) fix(t Type) bool {
+	if other, ok := openInfer(t); ok && other == v {
+		return true
+	}
+	for _, inner := range openInfers(t) {
+		if inner == v {
+			return false
+		}
+	}
+	v.Ref = t
+	return true
+}
+
+// placeInfers gives the open variables in typ without a position the span
+// of the expression of that type.
+func (c *Checker) placeInfers(typ Type, span lexer.Span) {
+	for _, v := range openInfers(typ) {
+		if v.Span.Line == 0 {
+			v.Span = span
+		}
+	}
+}
+
+// bindInfers records that the let binding name holds the open variables
+// of typ, for suggesting an annotation if they are never fixed.
+func (c *Checker) bindInfers(typ Type, name string) {
+	for _, v := range openInfers(typ) {
+		if v.Binding == "" {
+			v.Binding = name
+			v.BindingType = typ
+		}
+	}
+}
+
+// finishInference reports the inference variables of the function just
+// checked that no use fixed, and replaces the fixed ones with their types
+// in everything recorded about it.
+func (c *Checker) finishInference() {
+	if len(c.infers) == 0 {
+		return
+	}
+	for _, v := range c.infers {
+		if v.Ref != nil {
+			continue
+		}
+		help := fmt.Sprintf("no later use fixes `%s`; give the type arguments explicitly", v.Param)
+		if v.Binding != "" {
+			help = fmt.Sprintf("no later use fixes `%s`; annotate the binding, replacing `_` with a type:\n  let %s: %s = ...", v.Param, v.Binding, v.BindingType)
+		}
+		c.reportErrorWithCode(
+			fmt.Sprintf("type annotations needed: cannot infer type argument `%s`", v.Param),
+			v.Span,
+			diag.CodeTypeInvalidGenericArgs,
+			help,
+			nil,
+		)
+		v.Ref = TypeError
+	}
+
+	c.checkSelfBounds()
+
+	for node, t := range c.ExprTypes {
+		c.ExprTypes[node] = Substitute(t, nil)
+	}
+	for call, args := range c.CallTypeArgs {
+		for i, arg := range args {
+			args[i] = Substitute(arg, nil)
+		}
+		c.CallTypeArgs[call] = args
+	}
+	for _, sym := range c.Defs {
+		sym.Type = Substitute(sym.Type, nil)
+	}
+	c.infers = nil
+}
+
+// checkTail checks the tail expression of a function body, of type typ,
+// against the return type. This also fixes the type arguments left open in
+// the tail, as in a body ending in `Result::Err("bad")`.
+func (c *Checker) checkTail(body *ast.BlockExpr, typ Type) {
+	expected := c.CurrentReturn
+	if body.Tail == nil || expected == nil || expected == TypeVoid || expected == TypeNever || typ == TypeNever {
+		return
+	}
+	if !c.assignableTo(typ, expected) {
+		c.reportErrorWithCode(
+			fmt.Sprintf("expected `%s`, found `%s`", expected, typ),
+			body.Tail.Span(),
+			diag.CodeTypeMismatch,
+			fmt.Sprintf("function expects return type `%s`", expected),
+			nil,
+		)
+	}
+}
+
+// selfBound records that the type Self stands for in a call like
+// Default::default() must implement trait.
+type selfBound struct {
+	self  *Infer
+	trait *Trait
+	scope *Scope // Scope of the call, for the type parameters Self may name
+}
+
+// checkSelfBounds reports the trait function calls whose Self was fixed to
+// a type not implementing the trait.
+func (c *Checker) checkSelfBounds() {
+	for _, b := range c.selfBounds {
+		self := Substitute(b.self, nil)
+		if self == TypeError {
+			continue
+		}
+		if named, ok := self.(*Named); ok && named.Ref == nil {
+			// A type parameter spelled in an annotation, `let t: T = ...`
+			if sym := b.scope.Lookup(named.Name); sym != nil {
+				self = sym.Type
+			}
+		}
+		bound := &Named{Name: b.trait.Name}
+		if tp, ok := self.(*TypeParam); ok {
+			if hasBound(tp, b.trait.Name) {
+				continue
+			}
+		} else if Satisfies(self, []Type{bound}, c.Env) == nil {
+			continue
+		}
+		c.reportConstraintError(self, bound, lexer.Span{}, "Self", lexer.Span{}, b.self.Span)
+	}
+	c.selfBounds = nil
+}
+
+// hasBound reports whether the type parameter tp is bounded by the trait
+// named trait.
+func hasBound(tp *TypeParam, trait string) bool {
+	for _, bound := range tp.Bounds {
+		if boundName(bound) == trait {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFnBody checks the body of a function, method or trait default,
+// whose inference variables must all be fixed and borrows ended by its end.
+// Uses of moved values are found once the types of its variables are known.
+// The body of a function returning `!` must not reach its end.
+func (c *Checker) checkFnBody(body *ast.BlockExpr, scope *Scope, inUnsafe bool) {
+	typ := c.checkBlock(body, scope, inUnsafe)
+	if c.CurrentReturn == TypeNever && typ != TypeNever {
+		c.reportErrorWithCode("function returning `!` can reach the end of its body",
+			body.Span(), diag.CodeTypeMismatch,
+			"end the body with a call of panic, or with a loop that never breaks", nil)
+	}
+	c.checkTail(body, typ)
+	c.finishInference()
+	c.endAllBorrows()
+	c.checkMoves(body)
+}
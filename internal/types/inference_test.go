@@ -2,7 +2,11 @@ package types
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
 )
 
 func TestInferTypeArgs(t *testing.T) {
@@ -36,3 +40,148 @@ func TestInferTypeArgs(t *testing.T) {
 
 	fmt.Printf("Successfully inferred: T = %v\n", inferred[0])
 }
+
+func TestDeferredLetInference(t *testing.T) {
+	src := `package main;
+
+fn empty[T]() -> []T {
+    return []T{};
+}
+
+fn main() {
+    let v = Vec::new();
+    v.push(1);
+    let n: int = v.get(0);
+    let ch = Channel::new(1);
+    ch <- "hi";
+    let xs = empty();
+    let ys: []bool = xs;
+    println(n);
+    println(ys);
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+
+	want := map[string]string{"v": "Vec[int]", "ch": "chan string", "xs": "[]bool"}
+	for ident, sym := range checker.Defs {
+		if typ, ok := want[ident.Name]; ok {
+			if got := sym.Type.String(); got != typ {
+				t.Errorf("type of %s = %s, want %s", ident.Name, got, typ)
+			}
+			delete(want, ident.Name)
+		}
+	}
+	for name := range want {
+		t.Errorf("no binding %s", name)
+	}
+
+	for call, args := range checker.CallTypeArgs {
+		for _, arg := range args {
+			if len(openInfers(arg)) > 0 || strings.Contains(arg.String(), "_") {
+				t.Errorf("call at %v keeps an inference variable: %v", call.Span(), args)
+			}
+		}
+	}
+}
+
+func TestDeferredLetInferenceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+		help string
+	}{
+		{
+			name: "never fixed",
+			body: "let w = Vec::new();\n    println(1);",
+			want: "type annotations needed: cannot infer type argument `T`",
+			help: "let w: Vec[_] = ...",
+		},
+		{
+			name: "conflicting uses",
+			body: "let w = Vec::new();\n    w.push(1);\n    w.push(\"a\");",
+			want: "argument 1 to method push",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package main;\n\nfn main() {\n    " + tt.body + "\n}\n"
+			checker := checkSource(t, src, "main.mal")
+			if len(checker.Errors) == 0 {
+				t.Fatalf("expected an error containing %q", tt.want)
+			}
+			found := false
+			for _, err := range checker.Errors {
+				if strings.Contains(err.Message, tt.want) {
+					found = true
+					if tt.help != "" && !strings.Contains(err.Suggestion, tt.help) {
+						t.Errorf("suggestion = %q, want it to contain %q", err.Suggestion, tt.help)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("errors = %v, want one containing %q", checker.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestQualifiedVariantsTakeTheExpectedType(t *testing.T) {
+	src := `package main;
+
+enum Res[T, E] { Ok(T), Err(E) }
+enum Opt[T] { Some(T), Nothing }
+
+fn parse(n: int) -> Res[int, string] {
+    if n > 0 { return Res::Ok(n); }
+    Res::Err("negative")
+}
+
+fn pick(b: bool) -> Opt[int] {
+    if b { Opt::Nothing } else { Opt::Some(1) }
+}
+
+fn main() {
+    let a: Res[int, string] = Res::Err("bad");
+    let c: Opt[bool] = Opt::Nothing;
+    println(parse(1));
+    println(pick(true));
+    println(a);
+    println(c);
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+
+	// The variants themselves carry the expected instance
+	want := map[string]bool{"Res[int, string]": false, "Opt[int]": false, "Opt[bool]": false}
+	for node, typ := range checker.ExprTypes {
+		if call, ok := node.(*ast.CallExpr); ok {
+			node = call.Callee
+		}
+		if infix, ok := node.(*ast.InfixExpr); ok && infix.Op == lexer.DOUBLE_COLON {
+			if _, ok := want[typ.String()]; ok {
+				want[typ.String()] = true
+			}
+		}
+	}
+	for typ, seen := range want {
+		if !seen {
+			t.Errorf("no variant of type %s", typ)
+		}
+	}
+}
+
+func TestFunctionTailMustMatchReturnType(t *testing.T) {
+	src := "package main;\n\nfn f() -> int {\n    \"s\"\n}\n"
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 1 || checker.Errors[0].Message != "expected `int`, found `string`" {
+		t.Errorf("expected a return type mismatch, got %v", checker.Errors)
+	}
+}
@@ -0,0 +1,104 @@
+package types
+
+// Intrinsic is a builtin function whose calls are bridged directly to a C runtime
+// function. Intrinsics use the same __name__ spelling as the operator intrinsics
+// and are not meant to be called by user code; the stdlib sources wrap them in
+// ordinary Malphas functions and methods (see stdlib/string.mal).
+type Intrinsic struct {
+	Name    string    // Name visible to Malphas code, e.g. __string_len__
+	Runtime string    // C runtime symbol the call is lowered to
+	Type    *Function // Signature used by the checker
+}
+
+var anyType = &Named{Name: "any"}
+
+// intrinsics lists every intrinsic known to the checker and code generator.
+var intrinsics = []*Intrinsic{
+	// Strings
+	{Name: "__string_len__", Runtime: "runtime_string_len", Type: &Function{Params: []Type{TypeString}, Return: TypeInt}},
+	{Name: "__string_concat__", Runtime: "runtime_string_concat", Type: &Function{Params: []Type{TypeString, TypeString}, Return: TypeString}},
+	{Name: "__string_substring__", Runtime: "runtime_string_substring", Type: &Function{Params: []Type{TypeString, TypeInt, TypeInt}, Return: TypeString}},
+	{Name: "__string_index_of__", Runtime: "runtime_string_index_of", Type: &Function{Params: []Type{TypeString, TypeString}, Return: TypeInt}},
+	{Name: "__string_compare__", Runtime: "runtime_string_compare", Type: &Function{Params: []Type{TypeString, TypeString}, Return: TypeInt}},
+	{Name: "__string_to_upper__", Runtime: "runtime_string_to_upper", Type: &Function{Params: []Type{TypeString}, Return: TypeString}},
+	{Name: "__string_to_lower__", Runtime: "runtime_string_to_lower", Type: &Function{Params: []Type{TypeString}, Return: TypeString}},
+	{Name: "__string_trim__", Runtime: "runtime_string_trim", Type: &Function{Params: []Type{TypeString}, Return: TypeString}},
+	{Name: "__string_from_int__", Runtime: "runtime_string_from_i64", Type: &Function{Params: []Type{TypeInt}, Return: TypeString}},
+	{Name: "__string_builder_new__", Runtime: "runtime_string_builder_new", Type: &Function{Params: []Type{TypeInt}, Return: TypeInt}},
+	{Name: "__string_builder_append__", Runtime: "runtime_string_builder_append", Type: &Function{Params: []Type{TypeInt, TypeString}, Return: TypeVoid}},
+	{Name: "__string_builder_len__", Runtime: "runtime_string_builder_len", Type: &Function{Params: []Type{TypeInt}, Return: TypeInt}},
+	{Name: "__string_builder_build__", Runtime: "runtime_string_builder_build", Type: &Function{Params: []Type{TypeInt}, Return: TypeString}},
+	{Name: "__string_builder_clear__", Runtime: "runtime_string_builder_clear", Type: &Function{Params: []Type{TypeInt}, Return: TypeVoid}},
+
+	// Process arguments and environment
+	{Name: "__args_count__", Runtime: "runtime_args_count", Type: &Function{Return: TypeInt}},
+	{Name: "__args_get__", Runtime: "runtime_args_get", Type: &Function{Params: []Type{TypeInt}, Return: TypeString}},
+	{Name: "__env_get__", Runtime: "runtime_env_get", Type: &Function{Params: []Type{TypeString}, Return: &Optional{Elem: TypeString}}},
+	{Name: "__env_set__", Runtime: "runtime_env_set", Type: &Function{Params: []Type{TypeString, TypeString}, Return: TypeInt}},
+
+	// Time
+	{Name: "__time_monotonic_ns__", Runtime: "runtime_time_monotonic_ns", Type: &Function{Return: TypeInt}},
+	{Name: "__time_wall_ns__", Runtime: "runtime_time_wall_ns", Type: &Function{Return: TypeInt}},
+	{Name: "__time_sleep_ns__", Runtime: "runtime_time_sleep_ns", Type: &Function{Params: []Type{TypeInt}, Return: TypeVoid}},
+
+	// File I/O
+	{Name: "__fs_open__", Runtime: "runtime_fs_open", Type: &Function{Params: []Type{TypeString, TypeInt}, Return: TypeInt}},
+	{Name: "__fs_close__", Runtime: "runtime_fs_close", Type: &Function{Params: []Type{TypeInt}, Return: TypeInt}},
+	{Name: "__fs_write__", Runtime: "runtime_fs_write", Type: &Function{Params: []Type{TypeInt, TypeString}, Return: TypeInt}},
+	{Name: "__fs_read_all__", Runtime: "runtime_fs_read_all", Type: &Function{Params: []Type{TypeInt}, Return: TypeString}},
+	{Name: "__fs_last_error__", Runtime: "runtime_fs_last_error", Type: &Function{Return: TypeInt}},
+	{Name: "__fs_strerror__", Runtime: "runtime_fs_strerror", Type: &Function{Params: []Type{TypeInt}, Return: TypeString}},
+	{Name: "__fs_reader_new__", Runtime: "runtime_fs_reader_new", Type: &Function{Params: []Type{TypeInt}, Return: TypeInt}},
+	{Name: "__fs_reader_read_line__", Runtime: "runtime_fs_reader_read_line", Type: &Function{Params: []Type{TypeInt}, Return: &Optional{Elem: TypeString}}},
+	{Name: "__fs_reader_read__", Runtime: "runtime_fs_reader_read", Type: &Function{Params: []Type{TypeInt, TypeInt}, Return: TypeString}},
+
+	// TCP networking
+	{Name: "__net_listen__", Runtime: "runtime_net_listen", Type: &Function{Params: []Type{TypeString, TypeInt}, Return: TypeInt}},
+	{Name: "__net_accept__", Runtime: "runtime_net_accept", Type: &Function{Params: []Type{TypeInt}, Return: TypeInt}},
+	{Name: "__net_connect__", Runtime: "runtime_net_connect", Type: &Function{Params: []Type{TypeString, TypeInt}, Return: TypeInt}},
+	{Name: "__net_local_port__", Runtime: "runtime_net_local_port", Type: &Function{Params: []Type{TypeInt}, Return: TypeInt}},
+
+	// Synchronization
+	{Name: "__mutex_new__", Runtime: "runtime_mutex_new", Type: &Function{Return: TypeInt}},
+	{Name: "__mutex_lock__", Runtime: "runtime_mutex_lock", Type: &Function{Params: []Type{TypeInt}, Return: TypeVoid}},
+	{Name: "__mutex_try_lock__", Runtime: "runtime_mutex_try_lock", Type: &Function{Params: []Type{TypeInt}, Return: TypeInt}},
+	{Name: "__mutex_unlock__", Runtime: "runtime_mutex_unlock", Type: &Function{Params: []Type{TypeInt}, Return: TypeVoid}},
+	{Name: "__rwlock_new__", Runtime: "runtime_rwlock_new", Type: &Function{Return: TypeInt}},
+	{Name: "__rwlock_read_lock__", Runtime: "runtime_rwlock_read_lock", Type: &Function{Params: []Type{TypeInt}, Return: TypeVoid}},
+	{Name: "__rwlock_read_unlock__", Runtime: "runtime_rwlock_read_unlock", Type: &Function{Params: []Type{TypeInt}, Return: TypeVoid}},
+	{Name: "__rwlock_write_lock__", Runtime: "runtime_rwlock_write_lock", Type: &Function{Params: []Type{TypeInt}, Return: TypeVoid}},
+	{Name: "__rwlock_write_unlock__", Runtime: "runtime_rwlock_write_unlock", Type: &Function{Params: []Type{TypeInt}, Return: TypeVoid}},
+	{Name: "__atomic_new__", Runtime: "runtime_atomic_new", Type: &Function{Params: []Type{TypeInt}, Return: TypeInt}},
+	{Name: "__atomic_load__", Runtime: "runtime_atomic_load", Type: &Function{Params: []Type{TypeInt}, Return: TypeInt}},
+	{Name: "__atomic_store__", Runtime: "runtime_atomic_store", Type: &Function{Params: []Type{TypeInt, TypeInt}, Return: TypeVoid}},
+	{Name: "__atomic_fetch_add__", Runtime: "runtime_atomic_fetch_add", Type: &Function{Params: []Type{TypeInt, TypeInt}, Return: TypeInt}},
+	{Name: "__atomic_fetch_sub__", Runtime: "runtime_atomic_fetch_sub", Type: &Function{Params: []Type{TypeInt, TypeInt}, Return: TypeInt}},
+	{Name: "__atomic_swap__", Runtime: "runtime_atomic_swap", Type: &Function{Params: []Type{TypeInt, TypeInt}, Return: TypeInt}},
+	{Name: "__atomic_compare_exchange__", Runtime: "runtime_atomic_compare_exchange", Type: &Function{Params: []Type{TypeInt, TypeInt, TypeInt}, Return: TypeInt}},
+
+	// Collections
+	{Name: "__slice_len__", Runtime: "runtime_slice_len", Type: &Function{Params: []Type{anyType}, Return: TypeInt}},
+	{Name: "__slice_cap__", Runtime: "runtime_slice_cap", Type: &Function{Params: []Type{anyType}, Return: TypeInt}},
+	{Name: "__map_len__", Runtime: "runtime_hashmap_len", Type: &Function{Params: []Type{anyType}, Return: TypeInt}},
+}
+
+var intrinsicsByName = func() map[string]*Intrinsic {
+	m := make(map[string]*Intrinsic, len(intrinsics))
+	for _, in := range intrinsics {
+		m[in.Name] = in
+	}
+	return m
+}()
+
+// LookupIntrinsic returns the intrinsic with the given name, if any.
+func LookupIntrinsic(name string) (*Intrinsic, bool) {
+	in, ok := intrinsicsByName[name]
+	return in, ok
+}
+
+// declareIntrinsics inserts all intrinsics into the given scope.
+func declareIntrinsics(scope *Scope) {
+	for _, in := range intrinsics {
+		scope.Insert(in.Name, &Symbol{Name: in.Name, Type: in.Type})
+	}
+}
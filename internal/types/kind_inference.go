@@ -62,6 +62,9 @@ func InferKind(t Type) Kind {
 		// Channels have kind * -> *
 		return KindUnary
 
+	case *JoinHandle:
+		return KindUnary
+
 	case *Existential, *Forall:
 		// Quantified types have kind *
 		return KindStar
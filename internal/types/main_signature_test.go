@@ -0,0 +1,119 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+func TestMainSignature(t *testing.T) {
+	const resultDecl = `
+			enum Result[T, E] {
+				Ok(T),
+				Err(E),
+			}
+	`
+
+	tests := []struct {
+		name     string
+		input    string
+		hasError bool
+		errorMsg string
+	}{
+		{
+			name: "void main",
+			input: `
+			package main;
+			fn main() {}
+			`,
+			hasError: false,
+		},
+		{
+			name: "return value from void main",
+			input: `
+			package main;
+			fn main() {
+				return 1;
+			}
+			`,
+			hasError: true,
+			errorMsg: "cannot return a value from `main`",
+		},
+		{
+			name: "int main",
+			input: `
+			package main;
+			fn main() -> int {
+				return 3;
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "Result main",
+			input: `
+			package main;
+			` + resultDecl + `
+			fn main() -> Result[void, string] {
+				if false {
+					return Result[void, string]::Err("failed");
+				}
+				return Result[void, string]::Ok();
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "Result main with non-void Ok",
+			input: `
+			package main;
+			` + resultDecl + `
+			fn main() -> Result[int, string] {
+				return Result[int, string]::Ok(1);
+			}
+			`,
+			hasError: true,
+			errorMsg: "`main` cannot return",
+		},
+		{
+			name: "string main",
+			input: `
+			package main;
+			fn main() -> string {
+				return "x";
+			}
+			`,
+			hasError: true,
+			errorMsg: "`main` cannot return `string`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(tt.input)
+			file := p.ParseFile()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+
+			checker := NewChecker()
+			checker.Check(file)
+
+			if tt.hasError {
+				found := false
+				for _, err := range checker.Errors {
+					if strings.Contains(err.Message, tt.errorMsg) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("expected error %q, got %v", tt.errorMsg, checker.Errors)
+				}
+			} else if len(checker.Errors) > 0 {
+				t.Errorf("unexpected errors: %v", checker.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// mapMethods lists the methods of map[K, V], which the code generator
+// implements on the runtime hash table
+var mapMethods = map[string]int{ // name -> number of arguments
+	"contains": 1,
+	"remove":   1,
+	"keys":     0,
+	"values":   0,
+	"len":      0,
+}
+
+// checkMapMethod checks a call of method on a map and returns its type:
+//
+//	m.contains(k) -> bool
+//	m.remove(k)   -> V?  (the removed value, nil if k was absent)
+//	m.keys()      -> []K (in insertion order)
+//	m.values()    -> []V (in insertion order)
+//	m.len()       -> int
+func (c *Checker) checkMapMethod(call *ast.CallExpr, method *ast.Ident, m *Map, scope *Scope, inUnsafe bool) Type {
+	want, ok := mapMethods[method.Name]
+	if !ok {
+		c.reportMethodNotFound(m, method.Name, method.Span())
+		return TypeVoid
+	}
+	if len(call.Args) != want {
+		help := fmt.Sprintf("call `m.%s()`", method.Name)
+		if want == 1 {
+			help = fmt.Sprintf("call `m.%s(key)` with a key of type `%s`", method.Name, m.Key)
+		}
+		c.reportErrorWithCode(
+			fmt.Sprintf("%s takes %d argument(s), got %d", method.Name, want, len(call.Args)),
+			call.Span(),
+			diag.CodeTypeInvalidOperation,
+			help,
+			nil,
+		)
+	}
+	for _, arg := range call.Args {
+		c.checkMapKey(m, arg, scope, inUnsafe)
+	}
+
+	switch method.Name {
+	case "contains":
+		return TypeBool
+	case "remove":
+		return &Optional{Elem: m.Value}
+	case "keys":
+		return &Slice{Elem: m.Key}
+	case "values":
+		return &Slice{Elem: m.Value}
+	default: // len
+		return TypeInt
+	}
+}
+
+// checkMapKey checks that key can be used as a key of m
+func (c *Checker) checkMapKey(m *Map, key ast.Expr, scope *Scope, inUnsafe bool) {
+	keyType := c.checkExpr(key, scope, inUnsafe)
+	if !c.assignableTo(keyType, m.Key) {
+		c.reportErrorWithCode(
+			fmt.Sprintf("map key type mismatch: expected %s, got %s", m.Key, keyType),
+			key.Span(),
+			diag.CodeTypeMismatch,
+			fmt.Sprintf("the key must be of type %s, but got %s", m.Key, keyType),
+			nil,
+		)
+	}
+}
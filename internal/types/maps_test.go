@@ -0,0 +1,55 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMapMethods(t *testing.T) {
+	const decl = "package main;\nfn main() {\n    let mut m = map[string, int]{};\n    m[\"a\"] = 1;\n"
+	tests := []struct {
+		name string
+		body string
+		want string // substring of the only error, or "" for none
+	}{
+		{name: "contains", body: "let b: bool = m.contains(\"a\");"},
+		{name: "remove", body: "let v: int? = m.remove(\"a\");"},
+		{name: "keys", body: "let ks: []string = m.keys();"},
+		{name: "values", body: "let vs: []int = m.values();"},
+		{name: "len", body: "let n: int = m.len();"},
+		{name: "iterate keys", body: "for k in m { let s: string = k; }"},
+		{
+			name: "wrong key type",
+			body: "m.contains(1);",
+			want: "map key type mismatch: expected string, got int",
+		},
+		{
+			name: "missing key",
+			body: "m.remove();",
+			want: "remove takes 1 argument(s), got 0",
+		},
+		{
+			name: "unknown method",
+			body: "m.clear();",
+			want: "has no method `clear`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := checkSource(t, decl+"    "+tt.body+"\n}\n", "main.mal")
+			if tt.want == "" {
+				if len(checker.Errors) > 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			if len(checker.Errors) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(checker.Errors), checker.Errors)
+			}
+			if got := checker.Errors[0].Message; !strings.Contains(got, tt.want) {
+				t.Errorf("error = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
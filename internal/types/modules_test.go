@@ -0,0 +1,97 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestNestedModulePaths(t *testing.T) {
+	src := `package main;
+
+mod geo {
+    pub mod shapes {
+        pub struct Square { side: int }
+        pub fn square(side: int) -> Square { return Square { side: side }; }
+    }
+    mod util {
+        pub fn double(x: int) -> int { return x * 2; }
+    }
+    pub fn area(s: shapes::Square) -> int { return util::double(s.side); }
+}
+
+use geo::shapes;
+
+fn main() {
+    let s: geo::shapes::Square = geo::shapes::square(3);
+    let t: shapes::Square = shapes::square(4);
+    println(geo::area(s) + geo::area(t));
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+}
+
+func TestPrivateModuleItems(t *testing.T) {
+	dir := t.TempDir()
+	geo := "pub mod shapes;\nmod util;\n\nfn secret() -> int {\n    return 1;\n}\n\npub fn open() -> int {\n    return secret() + util::helper();\n}\n"
+	files := map[string]string{
+		"geo.mal":        geo,
+		"geo/shapes.mal": "struct Hidden { x: int }\n",
+		"geo/util.mal":   "pub fn helper() -> int {\n    return 2;\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"private function", "println(geo::secret());", "`secret` is private to module `geo`"},
+		{"private submodule", "println(geo::util::helper());", "`util` is private to module `geo`"},
+		{"private type", "let h: geo::shapes::Hidden = geo::shapes::Hidden { x: 1 };", "`Hidden` is private to module `geo::shapes`"},
+		{"public function", "println(geo::open());", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package main;\n\nmod geo;\n\nfn main() {\n    " + tt.body + "\n}\n"
+			checker := checkSource(t, src, filepath.Join(dir, "main.mal"))
+			var got *diag.Diagnostic
+			for i, err := range checker.Errors {
+				if err.Code == diag.CodeTypePrivateItem {
+					got = &checker.Errors[i]
+					break
+				}
+			}
+			if tt.want == "" {
+				if len(checker.Errors) != 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			if got == nil || got.Message != tt.want {
+				t.Fatalf("want error %q, got %v", tt.want, checker.Errors)
+			}
+			if len(got.LabeledSpans) != 2 {
+				t.Fatalf("want the use and the definition labeled, got %+v", got.LabeledSpans)
+			}
+			def := got.LabeledSpans[1]
+			if def.Style != "secondary" || !strings.HasPrefix(def.Span.Filename, filepath.Join(dir, "geo")) {
+				t.Errorf("definition label = %+v", def)
+			}
+		})
+	}
+}
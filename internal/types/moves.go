@@ -0,0 +1,445 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+// IsCopy reports whether values of type t are copied when used by value.
+// Values of other types are moved: the variable they were read from cannot
+// be used again until it is assigned a new value. Structs and enums are
+// moved unless declared #[copy]; an instance of a generic #[copy] type is
+// copied if its fields are, given its type arguments.
+func IsCopy(t Type) bool {
+	return isCopy(t, make(map[Type]bool))
+}
+
+// isCopy is IsCopy, taking the generic types whose fields are being checked
+// to be copyable.
+func isCopy(t Type, seen map[Type]bool) bool {
+	switch t := t.(type) {
+	case *Struct:
+		return t.Copy
+	case *Enum:
+		return t.Copy
+	case *GenericInstance:
+		if !isCopy(t.Base, seen) {
+			return false
+		}
+		if seen[t.Base] {
+			return true
+		}
+		seen[t.Base] = true
+		defer delete(seen, t.Base)
+		for _, part := range instanceParts(t) {
+			if !isCopy(part, seen) {
+				return false
+			}
+		}
+	case *TypeParam:
+		return false
+	case *Named:
+		return t.Ref == nil || isCopy(t.Ref, seen)
+	case *Infer:
+		return t.Ref == nil || isCopy(t.Ref, seen)
+	case *Tuple:
+		for _, elem := range t.Elements {
+			if !isCopy(elem, seen) {
+				return false
+			}
+		}
+	case *Array:
+		return isCopy(t.Elem, seen)
+	case *Optional:
+		return isCopy(t.Elem, seen)
+	}
+	return true
+}
+
+// instanceParts returns the field or payload types of a generic struct or
+// enum instance, with its type arguments substituted.
+func instanceParts(t *GenericInstance) []Type {
+	var params []TypeParam
+	var parts []Type
+	switch base := t.Base.(type) {
+	case *Struct:
+		params = base.TypeParams
+		for _, field := range base.Fields {
+			parts = append(parts, field.Type)
+		}
+	case *Enum:
+		params = base.TypeParams
+		for _, variant := range base.Variants {
+			parts = append(parts, variant.Params...)
+		}
+	}
+	subst := make(map[string]Type, len(params))
+	for i, param := range params {
+		if i < len(t.Args) {
+			subst[param.Name] = t.Args[i]
+		}
+	}
+	for i, part := range parts {
+		parts[i] = Substitute(part, subst)
+	}
+	return parts
+}
+
+// checkCopyDecl reports the fields and payloads of a #[copy] struct or enum
+// whose types are moved, since copying the value would copy them too.
+func (c *Checker) checkCopyDecl(decl ast.Decl) {
+	var attrs []*ast.Attribute
+	var name string
+	var parts []Type
+	switch d := decl.(type) {
+	case *ast.StructDecl:
+		attrs, name = d.Attrs, d.Name.Name
+	case *ast.EnumDecl:
+		attrs, name = d.Attrs, d.Name.Name
+	default:
+		return
+	}
+	attr := ast.FindAttribute(attrs, "copy")
+	if attr == nil {
+		return
+	}
+	sym := c.GlobalScope.Lookup(name)
+	if sym == nil {
+		return
+	}
+	var params []TypeParam
+	switch t := sym.Type.(type) {
+	case *Struct:
+		params = t.TypeParams
+		for _, field := range t.Fields {
+			parts = append(parts, field.Type)
+		}
+	case *Enum:
+		params = t.TypeParams
+		for _, variant := range t.Variants {
+			parts = append(parts, variant.Params...)
+		}
+	}
+	// Type parameters stand for copyable arguments here; an instance with
+	// moved arguments is itself moved
+	subst := make(map[string]Type, len(params))
+	for _, param := range params {
+		subst[param.Name] = TypeInt
+	}
+	for _, part := range parts {
+		if part = Substitute(part, subst); !IsCopy(part) {
+			c.reportErrorWithCode(fmt.Sprintf("`%s` cannot be #[copy] because it holds `%s`, which is moved", name, part),
+				attr.Span(), diag.CodeTypeInvalidAttribute,
+				fmt.Sprintf("declare `%s` #[copy] too, or remove #[copy] from `%s`", part, name), nil)
+			return
+		}
+	}
+}
+
+// moveChecker finds uses of local variables whose values were moved out.
+// It walks a function body in evaluation order after type checking.
+type moveChecker struct {
+	c *Checker
+	// moved maps the variables moved out of to where they were moved
+	moved map[*Symbol]lexer.Span
+}
+
+// checkMoves reports the uses of moved values in the function body. Its
+// tail is the return value, so it is moved out.
+func (c *Checker) checkMoves(body *ast.BlockExpr) {
+	m := &moveChecker{c: c, moved: make(map[*Symbol]lexer.Span)}
+	m.block(body, true)
+}
+
+// save returns a copy of the moved variables.
+func (m *moveChecker) save() map[*Symbol]lexer.Span {
+	saved := make(map[*Symbol]lexer.Span, len(m.moved))
+	for sym, span := range m.moved {
+		saved[sym] = span
+	}
+	return saved
+}
+
+// block walks b, whose tail is moved out of if move is set. The moves of a
+// block that always leaves early do not reach the code after it.
+func (m *moveChecker) block(b *ast.BlockExpr, move bool) {
+	before := m.save()
+	terminates := false
+	for _, stmt := range b.Stmts {
+		m.stmt(stmt)
+		if m.c.isTerminating(stmt) {
+			terminates = true
+		}
+	}
+	if b.Tail != nil {
+		m.expr(b.Tail, move)
+	}
+	if terminates {
+		m.moved = before
+	}
+}
+
+// branches walks alternative blocks, each from the state before any of them.
+// A variable is moved after them if any branch that can fall through moves
+// it. Without a final else, falling through without a branch is one more.
+func (m *moveChecker) branches(blocks []*ast.BlockExpr, exhaustive bool, move bool) {
+	before := m.save()
+	after := make(map[*Symbol]lexer.Span)
+	if !exhaustive {
+		after = m.save()
+	}
+	for _, b := range blocks {
+		m.moved = make(map[*Symbol]lexer.Span, len(before))
+		for sym, span := range before {
+			m.moved[sym] = span
+		}
+		m.block(b, move)
+		for sym, span := range m.moved {
+			if _, ok := after[sym]; !ok {
+				after[sym] = span
+			}
+		}
+	}
+	m.moved = after
+}
+
+// loop walks a loop body, which runs again after it ends. A variable from
+// outside the loop that the body moves and does not reassign would be used
+// after the move on the next iteration.
+func (m *moveChecker) loop(body *ast.BlockExpr) {
+	before := m.save()
+	m.block(body, false)
+	for sym, span := range m.moved {
+		if _, ok := before[sym]; ok || declaredIn(sym, body) {
+			continue
+		}
+		m.c.reportErrorWithLabeledSpans(
+			fmt.Sprintf("use of moved value `%s`", sym.Name),
+			diag.CodeTypeUseAfterMove,
+			span, "value moved here, in previous iteration of loop",
+			nil,
+			m.moveHelp(sym),
+		)
+	}
+	for sym, span := range before {
+		if _, ok := m.moved[sym]; !ok {
+			m.moved[sym] = span
+		}
+	}
+}
+
+// declaredIn reports whether the variable sym is declared inside node.
+func declaredIn(sym *Symbol, node ast.Node) bool {
+	if sym.DefNode == nil {
+		return false
+	}
+	def, span := sym.DefNode.Span(), node.Span()
+	return def.Start >= span.Start && def.End <= span.End
+}
+
+func (m *moveChecker) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.LetStmt:
+		m.expr(s.Value, true)
+	case *ast.ExprStmt:
+		m.expr(s.Expr, false)
+	case *ast.ReturnStmt:
+		if s.Value != nil {
+			m.expr(s.Value, true)
+		}
+	case *ast.IfStmt:
+		m.ifChain(s.Clauses, s.Else, false)
+	case *ast.WhileStmt:
+		m.expr(s.Condition, false)
+		m.loop(s.Body)
+	case *ast.ForStmt:
+		m.expr(s.Iterable, false)
+		m.loop(s.Body)
+	case *ast.SpawnStmt:
+		switch {
+		case s.Call != nil:
+			m.expr(s.Call, false)
+		case s.Block != nil:
+			m.closure(s.Block)
+		case s.FunctionLiteral != nil:
+			m.closure(s.FunctionLiteral.Body)
+			for _, arg := range s.Args {
+				m.expr(arg, true)
+			}
+		}
+	default:
+		m.children(stmt)
+	}
+}
+
+// ifChain walks the conditions and branches of an if.
+func (m *moveChecker) ifChain(clauses []*ast.IfClause, elseBlock *ast.BlockExpr, move bool) {
+	var blocks []*ast.BlockExpr
+	for _, clause := range clauses {
+		m.expr(clause.Condition, false)
+		blocks = append(blocks, clause.Body)
+	}
+	if elseBlock != nil {
+		blocks = append(blocks, elseBlock)
+	}
+	m.branches(blocks, elseBlock != nil, move)
+}
+
+// closure walks the body of a closure or spawned block, which may run any
+// number of times later, so its moves do not reach the code after it.
+func (m *moveChecker) closure(body *ast.BlockExpr) {
+	before := m.save()
+	m.block(body, false)
+	m.moved = before
+}
+
+// expr walks e, whose value is moved out of if move is set.
+func (m *moveChecker) expr(e ast.Expr, move bool) {
+	switch e := e.(type) {
+	case nil:
+	case *ast.Ident:
+		m.use(e, move)
+	case *ast.CallExpr:
+		m.c.checkExplicitDrop(e)
+		m.expr(e.Callee, false)
+		moves := m.movesArgs(e)
+		for _, arg := range e.Args {
+			m.expr(arg, moves)
+		}
+	case *ast.AssignExpr:
+		m.expr(e.Value, true)
+		if ident, ok := e.Target.(*ast.Ident); ok {
+			if sym := m.local(ident); sym != nil {
+				delete(m.moved, sym)
+				return
+			}
+		}
+		m.expr(e.Target, false)
+	case *ast.StructLiteral:
+		for _, field := range e.Fields {
+			m.expr(field.Value, true)
+		}
+	case *ast.RecordLiteral:
+		for _, field := range e.Fields {
+			m.expr(field.Value, true)
+		}
+	case *ast.ArrayLiteral:
+		for _, elem := range e.Elements {
+			m.expr(elem, true)
+		}
+	case *ast.TupleLiteral:
+		for _, elem := range e.Elements {
+			m.expr(elem, true)
+		}
+	case *ast.MapLiteral:
+		for _, entry := range e.Entries {
+			m.expr(entry.Key, true)
+			m.expr(entry.Value, true)
+		}
+	case *ast.InfixExpr:
+		m.expr(e.Left, false)
+		// Sending on a channel moves the value sent
+		m.expr(e.Right, e.Op == lexer.LARROW)
+	case *ast.BlockExpr:
+		m.block(e, move)
+	case *ast.UnsafeBlock:
+		m.block(e.Block, move)
+	case *ast.IfExpr:
+		m.ifChain(e.Clauses, e.Else, move)
+	case *ast.MatchExpr:
+		m.expr(e.Subject, false)
+		var blocks []*ast.BlockExpr
+		for _, arm := range e.Arms {
+			blocks = append(blocks, arm.Body)
+		}
+		m.branches(blocks, true, move)
+	case *ast.FunctionLiteral:
+		m.closure(e.Body)
+	default:
+		m.children(e)
+	}
+}
+
+// children walks the expressions directly inside node, none of which it
+// moves out of.
+func (m *moveChecker) children(node ast.Node) {
+	ast.Walk(node, func(n ast.Node) bool {
+		if n == node {
+			return true
+		}
+		switch n := n.(type) {
+		case ast.Expr:
+			m.expr(n, false)
+			return false
+		case ast.Stmt:
+			m.stmt(n)
+			return false
+		}
+		return true
+	})
+}
+
+// movesArgs reports whether call moves its arguments. Calls of builtins
+// such as println only read them.
+func (m *moveChecker) movesArgs(call *ast.CallExpr) bool {
+	callee := call.Callee
+	if index, ok := callee.(*ast.IndexExpr); ok {
+		callee = index.Target
+	}
+	if ident, ok := callee.(*ast.Ident); ok {
+		sym := m.c.Uses[ident]
+		return sym != nil && sym.DefNode != nil
+	}
+	return true
+}
+
+// local returns the local variable or parameter ident refers to, or nil.
+func (m *moveChecker) local(ident *ast.Ident) *Symbol {
+	sym := m.c.Uses[ident]
+	if sym == nil {
+		return nil
+	}
+	switch sym.DefNode.(type) {
+	case *ast.LetStmt, *ast.Param:
+		return sym
+	}
+	return nil
+}
+
+// use records a use of ident, reporting it if its value was moved out.
+func (m *moveChecker) use(ident *ast.Ident, move bool) {
+	sym := m.local(ident)
+	if sym == nil {
+		return
+	}
+	if span, ok := m.moved[sym]; ok {
+		m.c.reportErrorWithLabeledSpans(
+			fmt.Sprintf("use of moved value `%s`", sym.Name),
+			diag.CodeTypeUseAfterMove,
+			ident.Span(), "value used here after move",
+			[]struct {
+				span  lexer.Span
+				label string
+			}{{span: span, label: "value moved here"}},
+			m.moveHelp(sym),
+		)
+		return
+	}
+	typ := m.c.ExprTypes[ident]
+	if typ == nil {
+		typ = sym.Type
+	}
+	if move && !IsCopy(typ) {
+		m.moved[sym] = ident.Span()
+		m.c.Moves[ident] = true
+	}
+}
+
+// moveHelp suggests how to keep using sym after passing it on.
+func (m *moveChecker) moveHelp(sym *Symbol) string {
+	return fmt.Sprintf("`%s` has type `%s`, which is moved rather than copied; pass a reference with `&%s`, or declare the type #[copy] if all its fields are",
+		sym.Name, sym.Type, sym.Name)
+}
@@ -0,0 +1,112 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUseAfterMove(t *testing.T) {
+	const decls = `package main;
+struct P { x: int }
+#[copy]
+struct C { x: int }
+#[copy]
+struct W[T] { v: T }
+fn take(p: P) -> int { return p.x; }
+fn peek(p: &P) -> int { return p.x; }
+fn keep(c: C) -> int { return c.x; }
+`
+	tests := []struct {
+		name string
+		body string
+		want string // substring of the only error, or "" for none
+	}{
+		{
+			name: "use after passing by value",
+			body: "let p = P { x: 1 };\n    take(p);\n    println(p.x);",
+			want: "use of moved value `p`",
+		},
+		{
+			name: "use after let",
+			body: "let p = P { x: 1 };\n    let q = p;\n    take(p);",
+			want: "use of moved value `p`",
+		},
+		{
+			name: "copy struct",
+			body: "let c = C { x: 1 };\n    keep(c);\n    keep(c);",
+		},
+		{
+			name: "generic copy struct",
+			body: "let w = W { v: 1 };\n    let a = w;\n    let b = w;\n    let m = W { v: P { x: 1 } };\n    let c = m;\n    let d = m;",
+			want: "use of moved value `m`",
+		},
+		{
+			name: "borrow does not move",
+			body: "let p = P { x: 1 };\n    peek(&p);\n    take(p);",
+		},
+		{
+			name: "field reads and builtins do not move",
+			body: "let p = P { x: 1 };\n    println(p.x);\n    println(p);\n    take(p);",
+		},
+		{
+			name: "reassignment after move",
+			body: "let mut p = P { x: 1 };\n    take(p);\n    p = P { x: 2 };\n    take(p);",
+		},
+		{
+			name: "move in a branch that returns",
+			body: "let p = P { x: 1 };\n    if true {\n        take(p);\n        return;\n    }\n    take(p);",
+		},
+		{
+			name: "move in either branch",
+			body: "let p = P { x: 1 };\n    if true {\n        take(p);\n    } else {\n        peek(&p);\n    }\n    take(p);",
+			want: "use of moved value `p`",
+		},
+		{
+			name: "move in a loop",
+			body: "let p = P { x: 1 };\n    let mut i = 0;\n    while i < 2 {\n        take(p);\n        i = i + 1;\n    }",
+			want: "use of moved value `p`",
+		},
+		{
+			name: "loop reassigns what it moves",
+			body: "let mut p = P { x: 1 };\n    let mut i = 0;\n    while i < 2 {\n        take(p);\n        p = P { x: i };\n        i = i + 1;\n    }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := checkSource(t, decls+"fn main() {\n    "+tt.body+"\n}\n", "main.mal")
+			if tt.want == "" {
+				if len(checker.Errors) > 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			if len(checker.Errors) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(checker.Errors), checker.Errors)
+			}
+			if got := checker.Errors[0].Message; !strings.Contains(got, tt.want) {
+				t.Errorf("error = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUseAfterMoveLabelsMove(t *testing.T) {
+	src := "package main;\nstruct P { x: int }\nfn take(p: P) {}\nfn main() {\n    let p = P { x: 1 };\n    take(p);\n    take(p);\n}\n"
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 1 {
+		t.Fatalf("expected 1 error, got %v", checker.Errors)
+	}
+	labels := checker.Errors[0].LabeledSpans
+	if len(labels) != 2 || labels[1].Label != "value moved here" || labels[1].Span.Line != 6 {
+		t.Errorf("labels = %+v, want the move on line 6 labeled", labels)
+	}
+}
+
+func TestCopyRequiresCopyFields(t *testing.T) {
+	src := "package main;\nstruct P { x: int }\n#[copy]\nstruct C { p: P }\nfn main() {}\n"
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 1 || !strings.Contains(checker.Errors[0].Message, "`C` cannot be #[copy] because it holds `P`") {
+		t.Fatalf("expected a #[copy] field error, got %v", checker.Errors)
+	}
+}
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestNeverType(t *testing.T) {
+	const fail = "fn fail(msg: string) -> ! {\n    panic(msg);\n}\n"
+	tests := []struct {
+		name        string
+		src         string
+		want        string // substring of the only error, or "" for none
+		unreachable int    // number of unreachable code warnings
+	}{
+		{
+			name: "diverging else branch",
+			src:  fail + "fn f(a: int) -> int {\n    let x = if a > 0 { a } else { fail(\"neg\") };\n    return x;\n}\n",
+		},
+		{
+			name: "diverging first branch",
+			src:  "fn f(a: int) -> int {\n    let x = if a > 0 { return 1; } else { 7 };\n    return x;\n}\n",
+		},
+		{
+			name: "diverging match arm",
+			src:  "fn f(a: int) -> int {\n    let x = match a {\n        0 => { panic(\"zero\") },\n        _ => { a },\n    };\n    return x;\n}\n",
+		},
+		{
+			name: "endless loop",
+			src:  "fn serve() -> ! {\n    while true {\n        println(1);\n    }\n}\n",
+		},
+		{
+			name: "loop with break",
+			src:  "fn serve() -> ! {\n    while true {\n        break;\n    }\n}\n",
+			want: "function returning `!` can reach the end of its body",
+		},
+		{
+			name: "body falls through",
+			src:  "fn fail() -> ! {\n    println(1);\n}\n",
+			want: "function returning `!` can reach the end of its body",
+		},
+		{
+			name: "return from diverging function",
+			src:  "fn fail() -> ! {\n    return;\n}\n",
+			want: "expected `!`, found `()`",
+		},
+		{
+			name:        "code after diverging call",
+			src:         fail + "fn f() {\n    fail(\"now\");\n    println(1);\n}\n",
+			unreachable: 1,
+		},
+		{
+			name:        "code after diverging if",
+			src:         "fn f(a: int) -> int {\n    if a > 0 {\n        return 1;\n    } else {\n        panic(\"neg\");\n    }\n    return 0;\n}\n",
+			unreachable: 1,
+		},
+		{
+			name: "if without else",
+			src:  "fn f(a: int) -> int {\n    if a > 0 {\n        panic(\"pos\");\n    }\n    return 0;\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := checkSource(t, "package main;\n"+tt.src+"fn main() {}\n", "main.mal")
+			if tt.want == "" {
+				if len(checker.Errors) > 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+			} else {
+				if len(checker.Errors) != 1 {
+					t.Fatalf("expected 1 error, got %d: %v", len(checker.Errors), checker.Errors)
+				}
+				if got := checker.Errors[0].Message; !strings.Contains(got, tt.want) {
+					t.Errorf("error = %q, want it to mention %q", got, tt.want)
+				}
+			}
+
+			unreachable := 0
+			for _, w := range checker.Warnings {
+				if w.Code == diag.CodeUnreachableCode {
+					unreachable++
+				}
+			}
+			if unreachable != tt.unreachable {
+				t.Errorf("got %d unreachable code warnings, want %d: %v", unreachable, tt.unreachable, checker.Warnings)
+			}
+		})
+	}
+}
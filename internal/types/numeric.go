@@ -0,0 +1,193 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/lexer"
+)
+
+// Malphas never converts between numeric types implicitly: both operands of
+// a binary operator have the same type, and `as` converts one to the other.
+// The one exception is an integer literal, which takes the numeric type of
+// the other operand, so `x * 2` works for a float or i64 x, and the type of
+// the variable it initializes or is assigned to, as in `let x: u8 = 1`.
+
+// isNumericType reports whether t is an integer primitive or float.
+func isNumericType(t Type) bool {
+	return isFloatType(t) || isIntegerType(t)
+}
+
+func isFloatType(t Type) bool {
+	p, ok := t.(*Primitive)
+	return ok && p.Kind == Float
+}
+
+// sameNumericType reports whether the numeric types a and b are the same
+func sameNumericType(a, b Type) bool {
+	return a.(*Primitive).Kind == b.(*Primitive).Kind
+}
+
+// integerWidth returns the number of bits of an integer primitive
+func integerWidth(t Type) int {
+	switch t.(*Primitive).Kind {
+	case Int8, U8:
+		return 8
+	case U16:
+		return 16
+	case Int32, U32:
+		return 32
+	case U128:
+		return 128
+	}
+	return 64
+}
+
+// isIntegerLiteral reports whether expr is an integer literal, possibly
+// negated.
+func isIntegerLiteral(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.IntegerLit:
+		return true
+	case *ast.PrefixExpr:
+		return e.Op == lexer.MINUS && isIntegerLiteral(e.Expr)
+	}
+	return false
+}
+
+// retypeLiteral records typ as the type of the integer literal expr
+func (c *Checker) retypeLiteral(expr ast.Expr, typ Type) {
+	c.ExprTypes[expr] = typ
+	if prefix, ok := expr.(*ast.PrefixExpr); ok {
+		c.retypeLiteral(prefix.Expr, typ)
+	}
+}
+
+// adaptLiteral gives expr, of type typ, the numeric type want if expr is
+// an integer literal, and returns the type expr ends up with.
+func (c *Checker) adaptLiteral(expr ast.Expr, typ, want Type) Type {
+	if !isIntegerType(typ) || typ.(*Primitive).Kind != Int || !isNumericType(want) || !isIntegerLiteral(expr) {
+		return typ
+	}
+	c.retypeLiteral(expr, want)
+	return want
+}
+
+// adaptLiteralOperand gives an integer literal operand of e the numeric type
+// of the other operand, and returns the resulting operand types.
+func (c *Checker) adaptLiteralOperand(e *ast.InfixExpr, left, right Type) (Type, Type) {
+	if !isNumericType(left) || !isNumericType(right) || sameNumericType(left, right) {
+		return left, right
+	}
+	if adapted := c.adaptLiteral(e.Left, left, right); adapted != left {
+		return adapted, right
+	}
+	return left, c.adaptLiteral(e.Right, right, left)
+}
+
+// reportNumericMismatch reports the operands of e having the distinct
+// numeric types left and right. The fix converts the integer operand to
+// float, or the narrower integer to the wider type.
+func (c *Checker) reportNumericMismatch(e *ast.InfixExpr, left, right Type) {
+	// Convert the right operand to the type of the left one, unless that
+	// would lose the fraction or the high bits of the right one
+	convert, from, to := e.Right, right, left
+	if !isFloatType(left) && (isFloatType(right) || integerWidth(left) < integerWidth(right)) {
+		convert, from, to = e.Left, left, right
+	}
+
+	c.reportErrorWithLabeledSpans(
+		fmt.Sprintf("cannot apply `%s` to `%s` and `%s`", e.Op, left, right),
+		diag.CodeTypeNumericMismatch,
+		convert.Span(),
+		fmt.Sprintf("this is `%s`", from),
+		nil,
+		fmt.Sprintf("numeric types are not converted implicitly; convert the `%s` operand to `%s` with `as %s`", from, to, to),
+	)
+	c.attachFixes(c.castFix(convert, to))
+}
+
+// castFix converts expr to typ with `as`, parenthesizing expr unless it
+// binds tighter than a cast
+func (c *Checker) castFix(expr ast.Expr, typ Type) diag.Fix {
+	span := expr.Span()
+	end := span
+	end.Start = end.End
+	cast := diag.Edit{Span: c.toDiagSpan(end), NewText: fmt.Sprintf(" as %s", typ)}
+	switch expr.(type) {
+	case *ast.Ident, *ast.IntegerLit, *ast.FloatLit, *ast.CallExpr, *ast.FieldExpr, *ast.IndexExpr:
+		return diag.Fix{Message: fmt.Sprintf("Convert to `%s`", typ), Edits: []diag.Edit{cast}}
+	}
+	start := span
+	start.End = start.Start
+	cast.NewText = ")" + cast.NewText
+	return diag.Fix{
+		Message: fmt.Sprintf("Convert to `%s`", typ),
+		Edits:   []diag.Edit{{Span: c.toDiagSpan(start), NewText: "("}, cast},
+	}
+}
+
+// isArithmeticBuiltin reports whether name is one of the wrapping_* and
+// checked_* builtins.
+func isArithmeticBuiltin(name string) bool {
+	switch name {
+	case "wrapping_add", "wrapping_sub", "wrapping_mul", "checked_add", "checked_sub", "checked_mul":
+		return true
+	}
+	return false
+}
+
+// checkArithmeticBuiltinCall checks a call of a wrapping_* or checked_*
+// builtin. Both operands have the same integer type T, with an integer
+// literal taking the type of the other operand as it does for an operator,
+// and the call returns T, or T? for checked_*.
+func (c *Checker) checkArithmeticBuiltinCall(call *ast.CallExpr, name string, scope *Scope, inUnsafe bool) Type {
+	result := func(t Type) Type {
+		if strings.HasPrefix(name, "checked_") {
+			return &Optional{Elem: t}
+		}
+		return t
+	}
+	if len(call.Args) != 2 {
+		c.reportErrorWithCode(fmt.Sprintf("%s takes 2 arguments, got %d", name, len(call.Args)), call.Span(),
+			diag.CodeTypeInvalidOperation, fmt.Sprintf("call `%s(a, b)` with two integers of the same type", name), nil)
+		for _, arg := range call.Args {
+			c.checkExpr(arg, scope, inUnsafe)
+		}
+		return result(TypeInt)
+	}
+
+	left := c.checkExpr(call.Args[0], scope, inUnsafe)
+	right := c.checkExpr(call.Args[1], scope, inUnsafe)
+	if c.poisons(left) || c.poisons(right) {
+		return result(TypeInt)
+	}
+	for i, t := range []Type{left, right} {
+		if !isIntegerType(t) {
+			c.reportErrorWithCode(fmt.Sprintf("%s expects integers, got `%s`", name, t), call.Args[i].Span(),
+				diag.CodeTypeMismatch, "wrapping_* and checked_* work on the integer types, such as int, i32 and u8", nil)
+			return result(TypeInt)
+		}
+	}
+	if !sameNumericType(left, right) {
+		if adapted := c.adaptLiteral(call.Args[0], left, right); adapted != left {
+			left = adapted
+		} else {
+			right = c.adaptLiteral(call.Args[1], right, left)
+		}
+	}
+	if !sameNumericType(left, right) {
+		c.reportErrorWithLabeledSpans(
+			fmt.Sprintf("%s expects two integers of the same type, got `%s` and `%s`", name, left, right),
+			diag.CodeTypeNumericMismatch,
+			call.Args[1].Span(),
+			fmt.Sprintf("this is `%s`", right),
+			nil,
+			fmt.Sprintf("numeric types are not converted implicitly; convert the operand with `as %s`", left),
+		)
+		c.attachFixes(c.castFix(call.Args[1], left))
+	}
+	return result(left)
+}
@@ -0,0 +1,114 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+func TestIntegerLiteralsTakeTheOtherNumericType(t *testing.T) {
+	src := `package main;
+
+fn main() {
+    let x = 2.5;
+    let y = x * 2;
+    let z: float = -3;
+    let mut b: u8 = 7;
+    b = b + 1;
+    b = 2;
+    println(y);
+    println(z);
+    println(b);
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+}
+
+func TestMixedNumericOperandsNeedAConversion(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // expression after applying the fix
+	}{
+		{"a + b", "a as float + b"},
+		{"b < a", "b < a as float"},
+		{"(a + 1) * b", "((a + 1)) as float * b"},
+		{"c - a", "c as int - a"},
+	}
+	for _, tt := range tests {
+		src := `package main;
+
+fn f(a: int, b: float, c: i32) {
+    let r = ` + tt.expr + `;
+    println(r);
+}
+`
+		checker := checkSource(t, src, "main.mal")
+		if len(checker.Errors) != 1 || checker.Errors[0].Code != diag.CodeTypeNumericMismatch {
+			t.Errorf("%s: expected one %s error, got %v", tt.expr, diag.CodeTypeNumericMismatch, checker.Errors)
+			continue
+		}
+		fix := checker.Errors[0].Fixes[0]
+		want := `package main;
+
+fn f(a: int, b: float, c: i32) {
+    let r = ` + tt.want + `;
+    println(r);
+}
+`
+		if got := applyFix(src, fix); got != want {
+			t.Errorf("%s: fix gives\n%s\nwant\n%s", tt.expr, got, want)
+		}
+	}
+}
+
+func TestArithmeticBuiltinsTakeAnyIntegerType(t *testing.T) {
+	src := `package main;
+
+fn main() {
+    let a: u8 = 250;
+    let b: i32 = 7;
+    let w: u8 = wrapping_add(a, 10);
+    let c: u8? = checked_mul(2, a);
+    let d: i32 = wrapping_sub(b, b);
+    let e: i32? = checked_add(b, 1);
+    let f: int = wrapping_mul(3, 4);
+    println(w);
+    println(c);
+    println(d);
+    println(e);
+    println(f);
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+}
+
+func TestArithmeticBuiltinsRejectMixedOrNonIntegerOperands(t *testing.T) {
+	tests := []struct {
+		call string
+		code diag.Code
+	}{
+		{"wrapping_add(a, b)", diag.CodeTypeNumericMismatch},
+		{"checked_sub(b, a)", diag.CodeTypeNumericMismatch},
+		{"wrapping_mul(x, x)", diag.CodeTypeMismatch},
+		{"checked_add(a)", diag.CodeTypeInvalidOperation},
+	}
+	for _, tt := range tests {
+		src := `package main;
+
+fn f(a: u8, b: i32, x: float) {
+    let r = ` + tt.call + `;
+    println(r);
+}
+`
+		checker := checkSource(t, src, "main.mal")
+		if len(checker.Errors) != 1 || checker.Errors[0].Code != tt.code {
+			t.Errorf("%s: expected one %s error, got %v", tt.call, tt.code, checker.Errors)
+		}
+	}
+}
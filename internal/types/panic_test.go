@@ -0,0 +1,31 @@
+package types
+
+import "testing"
+
+func TestPanicDiverges(t *testing.T) {
+	src := `package main;
+fn half(n: int) -> int {
+    if n > 0 {
+        return n / 2;
+    }
+    panic("odd");
+}
+fn main() {
+    let x: int = panic("never assigned");
+    let s: string = panic("never assigned");
+    println(half(4));
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+
+	fn, ok := checker.GlobalScope.Lookup("panic").Type.(*Function)
+	if !ok {
+		t.Fatal("panic is not a function")
+	}
+	if fn.Return != TypeNever {
+		t.Errorf("panic returns %s, want !", fn.Return)
+	}
+}
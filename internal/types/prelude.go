@@ -0,0 +1,117 @@
+package types
+
+import "sort"
+
+// preludeTypes maps names that are usable without a `use` declaration to the
+// stdlib module defining them. Prelude modules are loaded on first use, so
+// programs that never mention them are checked exactly as before.
+var preludeTypes = map[string]string{
+	"Vec":     "vec",
+	"HashMap": "map",
+	"Result":  "result",
+
+	"StringBuilder": "string",
+
+	"Mutex":     "sync",
+	"RwLock":    "sync",
+	"AtomicInt": "sync",
+
+	"Drop": "drop",
+}
+
+// PreludeTypeNames returns the sorted names of the stdlib types usable
+// without a `use` declaration.
+func PreludeTypeNames() []string {
+	names := make([]string, 0, len(preludeTypes))
+	for name := range preludeTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// preludeMethods maps builtin type names to the stdlib module providing their methods.
+var preludeMethods = map[string]string{
+	"string": "string",
+	"Slice":  "core",
+}
+
+// loadPreludeModule loads the stdlib module relPath as std/relPath if it exists.
+// It reports whether the module is available afterwards.
+func (c *Checker) loadPreludeModule(relPath string) bool {
+	fullName := "std/" + relPath
+	if _, ok := c.Modules[fullName]; !ok {
+		c.ensureStdModuleLoaded(fullName, relPath)
+	}
+	_, ok := c.Modules[fullName]
+	return ok
+}
+
+// lookupPrelude resolves a prelude name, loading its stdlib module on demand.
+// The symbol is inserted into the root scope so later lookups find it directly.
+func (c *Checker) lookupPrelude(name string) *Symbol {
+	relPath, ok := preludeTypes[name]
+	if !ok || !c.loadPreludeModule(relPath) {
+		return nil
+	}
+
+	sym := c.Modules["std/"+relPath].Scope.Symbols[name]
+	if sym == nil {
+		return nil
+	}
+
+	root := c.GlobalScope
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	root.Insert(name, sym)
+	return sym
+}
+
+// loadPreludeMethods makes the stdlib methods of a builtin type available.
+// It reports whether a module was loaded.
+func (c *Checker) loadPreludeMethods(typeName string) bool {
+	relPath, ok := preludeMethods[typeName]
+	if !ok {
+		return false
+	}
+	if _, loaded := c.Modules["std/"+relPath]; loaded {
+		return false
+	}
+	return c.loadPreludeModule(relPath)
+}
+
+// coreSliceStruct returns the Slice[T] struct from std/core, if it has been loaded.
+func (c *Checker) coreSliceStruct() *Struct {
+	mod, ok := c.Modules["std/core"]
+	if !ok {
+		return nil
+	}
+	sym := mod.Scope.Symbols["Slice"]
+	if sym == nil {
+		return nil
+	}
+	s, _ := sym.Type.(*Struct)
+	return s
+}
+
+// isTimeDuration reports whether t is the Duration struct of std/time, which
+// is only loaded by a program that uses it.
+func (c *Checker) isTimeDuration(t Type) bool {
+	mod, ok := c.Modules["std/time"]
+	if !ok {
+		return false
+	}
+	sym := mod.Scope.Symbols["Duration"]
+	if sym == nil {
+		return false
+	}
+	for {
+		named, ok := t.(*Named)
+		if !ok || named.Ref == nil {
+			break
+		}
+		t = named.Ref
+	}
+	return t == sym.Type
+}
@@ -0,0 +1,172 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+func TestPrelude(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		hasError bool
+		errorMsg string
+	}{
+		{
+			name: "string methods without use",
+			input: `
+			package main;
+			fn main() {
+				let s = "  Hello ";
+				let n: int = s.len();
+				let t: string = s.trim().to_upper();
+				let b: bool = t.contains("ELL");
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "Vec without use",
+			input: `
+			package main;
+			fn main() {
+				let mut v = Vec[int]::new();
+				v.push(1);
+				let n: int = v.len();
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "HashMap without use",
+			input: `
+			package main;
+			fn main() {
+				let mut m = HashMap[string, int]::new();
+				m.put("a", 1);
+				let n: int = m.len();
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "Vec and HashMap through std::collections",
+			input: `
+			package main;
+			use std::collections::HashMap;
+			use std::collections::Vec;
+			fn main() {
+				let mut v = Vec[string]::new();
+				v.push("a");
+				let s: string = v.get(0);
+				let mut m = HashMap[string, int]::new();
+				m.put(s, 1);
+				let n: int? = m.get("a");
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "sync types without use",
+			input: `
+			package main;
+			fn main() {
+				let m = Mutex[int]::new(0);
+				m.lock();
+				m.set(m.get() + 1);
+				m.unlock();
+				let rw = RwLock[string]::new("a");
+				rw.read_lock();
+				let s: string = rw.get();
+				rw.read_unlock();
+				let n = AtomicInt::new(0);
+				let prev: int = n.fetch_add(1);
+				let ok: bool = n.compare_exchange(1, 2);
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "Result without use",
+			input: `
+			package main;
+			fn parse(s: string) -> Result[int, string] {
+				if s.is_empty() {
+					return Result[int, string]::Err("empty");
+				}
+				return Result[int, string]::Ok(s.len());
+			}
+			fn main() {
+				let r = parse("abc");
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "intrinsic argument type",
+			input: `
+			package main;
+			fn main() {
+				let n = __string_len__(42);
+			}
+			`,
+			hasError: true,
+			errorMsg: "argument 1 to function __string_len__",
+		},
+		{
+			name: "unknown string method",
+			input: `
+			package main;
+			fn main() {
+				let s = "x";
+				s.frobnicate();
+			}
+			`,
+			hasError: true,
+			errorMsg: "frobnicate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(tt.input)
+			file := p.ParseFile()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+
+			checker := NewChecker()
+			checker.Check(file)
+
+			if tt.hasError {
+				found := false
+				for _, err := range checker.Errors {
+					if strings.Contains(err.Message, tt.errorMsg) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("expected error %q, got %v", tt.errorMsg, checker.Errors)
+				}
+			} else if len(checker.Errors) > 0 {
+				t.Errorf("unexpected errors: %v", checker.Errors)
+			}
+		})
+	}
+}
+
+func TestLookupIntrinsic(t *testing.T) {
+	in, ok := LookupIntrinsic("__string_len__")
+	if !ok {
+		t.Fatal("expected __string_len__ to be an intrinsic")
+	}
+	if in.Runtime != "runtime_string_len" {
+		t.Errorf("expected runtime_string_len, got %s", in.Runtime)
+	}
+	if _, ok := LookupIntrinsic("println"); ok {
+		t.Error("println should not be an intrinsic")
+	}
+}
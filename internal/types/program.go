@@ -0,0 +1,255 @@
+package types
+
+import (
+	"maps"
+	"sort"
+	"sync"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// CheckResult is what checking one file produced. It does not change after
+// it is returned, so it can be read from several goroutines at once, and it
+// stays valid while other files are checked, until it is passed to Recheck.
+type CheckResult struct {
+	File *ast.File
+	// Filename is the name the file was checked as, against which its
+	// modules were resolved
+	Filename string
+
+	Errors   []diag.Diagnostic
+	Warnings []diag.Diagnostic
+
+	// Types maps expressions and type annotations to their types
+	Types map[ast.Node]Type
+	// CallTypeArgs maps generic calls to their type arguments
+	CallTypeArgs map[*ast.CallExpr][]Type
+	// Uses maps identifiers to the symbols they refer to
+	Uses map[*ast.Ident]*Symbol
+	// Defs maps the identifiers that define symbols to those symbols
+	Defs map[*ast.Ident]*Symbol
+	// Scope is the global scope
+	Scope *Scope
+	// Modules are the modules the file loaded, by name
+	Modules map[string]*ModuleInfo
+	// Tests holds the functions of the file marked #[test]
+	Tests []*ast.FnDecl
+
+	checker *Checker
+	prelude *preludeIndex
+}
+
+// Result returns the result of c's check of file, after which c must not
+// be used to check anything else. Methods of builtin types that the check
+// did not load are loaded from the stdlib by a checker of their own.
+func (c *Checker) Result() *CheckResult {
+	return c.result(&preludeIndex{})
+}
+
+func (c *Checker) result(prelude *preludeIndex) *CheckResult {
+	return &CheckResult{
+		File:         c.file,
+		Filename:     c.CurrentFile,
+		Errors:       c.Errors,
+		Warnings:     c.Warnings,
+		Types:        c.ExprTypes,
+		CallTypeArgs: c.CallTypeArgs,
+		Uses:         c.Uses,
+		Defs:         c.Defs,
+		Scope:        c.GlobalScope,
+		Modules:      c.Modules,
+		Tests:        c.Tests,
+		checker:      c,
+		prelude:      prelude,
+	}
+}
+
+// Failed reports whether the check found errors.
+func (r *CheckResult) Failed() bool {
+	return len(r.Errors) > 0
+}
+
+// Typed returns the typed file of a check without errors.
+func (r *CheckResult) Typed() (*TypedFile, error) {
+	return r.checker.Typed(r.File)
+}
+
+// SymbolOf returns the symbol ident refers to or defines, or nil.
+func (r *CheckResult) SymbolOf(ident *ast.Ident) *Symbol {
+	return r.checker.SymbolOf(ident)
+}
+
+// References returns every identifier that refers to sym (see
+// Checker.References).
+func (r *CheckResult) References(sym *Symbol) []*ast.Ident {
+	return r.checker.References(sym)
+}
+
+// MethodsOf returns the methods declared for t by name, or nil if t has
+// none. Unlike Checker.MethodsOf it never changes the result: methods of
+// builtin types the file did not use come from the shared prelude index.
+func (r *CheckResult) MethodsOf(t Type) map[string]*Function {
+	typeName := r.checker.getTypeName(t)
+	if typeName == "" {
+		return nil
+	}
+	if methods, ok := r.checker.MethodTable[typeName]; ok {
+		return methods
+	}
+	return r.prelude.methods(typeName)
+}
+
+// preludeIndex loads the stdlib methods of builtin types for the files of a
+// program, each at most once.
+type preludeIndex struct {
+	mu      sync.Mutex
+	checker *Checker                        // Loads the stdlib modules, created on first use
+	loaded  map[string]map[string]*Function // By type name
+}
+
+// methods returns the stdlib methods of the builtin type typeName
+func (p *preludeIndex) methods(typeName string) map[string]*Function {
+	if _, ok := preludeMethods[typeName]; !ok {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if methods, ok := p.loaded[typeName]; ok {
+		return methods
+	}
+	if p.checker == nil {
+		p.checker = NewChecker()
+		p.loaded = make(map[string]map[string]*Function)
+	}
+	// Loading one module can add methods to the types of another, so each
+	// type keeps the methods it had when it was first asked for
+	p.checker.loadPreludeMethods(typeName)
+	methods := maps.Clone(p.checker.MethodTable[typeName])
+	p.loaded[typeName] = methods
+	return methods
+}
+
+// Program checks the files of a program and indexes the symbols they
+// declare. Its methods can be called from several goroutines at once; each
+// file is checked by a checker of its own, so files check in parallel.
+type Program struct {
+	// WarnShadow enables the SHADOWED_VARIABLE lint for the files checked
+	WarnShadow bool
+
+	prelude preludeIndex
+
+	mu      sync.RWMutex
+	results map[string]*CheckResult // By filename
+	symbols map[string][]*Symbol    // Top-level symbols of the results by name
+}
+
+// NewProgram creates an empty program.
+func NewProgram() *Program {
+	return &Program{
+		results: make(map[string]*CheckResult),
+		symbols: make(map[string][]*Symbol),
+	}
+}
+
+// Check checks file as the file filename and records the result in place
+// of the last one for filename. Checking the file that was last checked as
+// filename again returns the recorded result. When two versions of a file
+// are checked at once, the one to finish last is recorded.
+func (p *Program) Check(file *ast.File, filename string) *CheckResult {
+	p.mu.RLock()
+	cached := p.results[filename]
+	p.mu.RUnlock()
+	if cached != nil && cached.File == file {
+		return cached
+	}
+
+	result := p.CheckVariant(file, filename)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record(filename, result)
+	return result
+}
+
+// record makes result the result for filename and indexes its symbols.
+// The caller holds p.mu.
+func (p *Program) record(filename string, result *CheckResult) {
+	p.unindex(filename)
+	p.results[filename] = result
+	decls := topLevel(result.File)
+	for name, sym := range result.Scope.Symbols {
+		if decls[sym.DefNode] {
+			p.symbols[name] = append(p.symbols[name], sym)
+		}
+	}
+}
+
+// CheckVariant checks a variant of the file filename, such as a copy edited
+// to find out what the code at a position is, without recording the result.
+func (p *Program) CheckVariant(file *ast.File, filename string) *CheckResult {
+	checker := NewChecker()
+	checker.WarnShadow = p.WarnShadow
+	checker.CheckWithFilename(file, filename)
+	return checker.result(&p.prelude)
+}
+
+// Result returns the recorded result for filename, or nil.
+func (p *Program) Result(filename string) *CheckResult {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.results[filename]
+}
+
+// Forget drops the result for filename and its symbols from the index.
+func (p *Program) Forget(filename string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unindex(filename)
+	delete(p.results, filename)
+}
+
+// Lookup returns the top-level symbols named name declared by the checked
+// files, ordered by the name of their file.
+func (p *Program) Lookup(name string) []*Symbol {
+	p.mu.RLock()
+	syms := append([]*Symbol(nil), p.symbols[name]...)
+	p.mu.RUnlock()
+	sort.SliceStable(syms, func(i, j int) bool {
+		return syms[i].DefIdent().Span().Filename < syms[j].DefIdent().Span().Filename
+	})
+	return syms
+}
+
+// unindex removes the symbols of the result for filename from the index.
+// The caller holds p.mu.
+func (p *Program) unindex(filename string) {
+	old := p.results[filename]
+	if old == nil {
+		return
+	}
+	decls := topLevel(old.File)
+	for name := range old.Scope.Symbols {
+		kept := p.symbols[name][:0]
+		for _, sym := range p.symbols[name] {
+			if !decls[sym.DefNode] {
+				kept = append(kept, sym)
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.symbols, name)
+		} else {
+			p.symbols[name] = kept
+		}
+	}
+}
+
+// topLevel returns the set of top-level items of file. Symbols defined by
+// them are the file's own, as opposed to builtins and imported items.
+func topLevel(file *ast.File) map[ast.Node]bool {
+	decls := make(map[ast.Node]bool, len(file.Decls))
+	for _, d := range file.Decls {
+		decls[d] = true
+	}
+	return decls
+}
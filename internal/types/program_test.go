@@ -0,0 +1,152 @@
+package types
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+func parseProgramFile(t *testing.T, src, filename string) *ast.File {
+	t.Helper()
+	p := parser.New(src, parser.WithFilename(filename))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return file
+}
+
+func TestProgramChecksInParallel(t *testing.T) {
+	program := NewProgram()
+	const n = 8
+	files := make([]*ast.File, n)
+	for i := range files {
+		src := fmt.Sprintf(`package main;
+fn helper%d(s: string) -> int { return s.len(); }
+fn main() {
+    let x: int = helper%d("abc");
+    println(x);
+}
+`, i, i)
+		if i == n-1 {
+			src += "fn broken() -> int { return \"no\"; }\n"
+		}
+		files[i] = parseProgramFile(t, src, fmt.Sprintf("f%d.mal", i))
+	}
+
+	results := make([]*CheckResult, n)
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = program.Check(file, fmt.Sprintf("f%d.mal", i))
+		}()
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if got, want := result.Failed(), i == n-1; got != want {
+			t.Errorf("f%d.mal: Failed = %v, want %v (errors %v)", i, got, want, result.Errors)
+		}
+		if program.Result(fmt.Sprintf("f%d.mal", i)) != result {
+			t.Errorf("f%d.mal: Result is not the recorded check", i)
+		}
+	}
+
+	// Every file declares its own main
+	if mains := program.Lookup("main"); len(mains) != n {
+		t.Errorf("Lookup(main) found %d symbols, want %d", len(mains), n)
+	}
+	if helpers := program.Lookup("helper3"); len(helpers) != 1 || helpers[0].DefNode != files[3].Decls[0] {
+		t.Errorf("Lookup(helper3) = %v, want the declaration in f3.mal", helpers)
+	}
+	if builtins := program.Lookup("println"); len(builtins) != 0 {
+		t.Errorf("Lookup(println) = %v, want no builtins", builtins)
+	}
+
+	// Checking the same file again gives the recorded result; forgetting
+	// it drops its symbols
+	if program.Check(files[3], "f3.mal") != results[3] {
+		t.Error("rechecking an unchanged file did not reuse its result")
+	}
+	program.Forget("f3.mal")
+	if program.Result("f3.mal") != nil || len(program.Lookup("helper3")) != 0 || len(program.Lookup("main")) != n-1 {
+		t.Error("Forget left the file's result or symbols behind")
+	}
+}
+
+func TestCheckResultMethodsOf(t *testing.T) {
+	program := NewProgram()
+	file := parseProgramFile(t, `package main;
+fn main() { println(1); }
+`, "plain.mal")
+	result := program.Check(file, "plain.mal")
+	modules := len(result.Modules)
+
+	// The file never used a string, so its methods come from the prelude
+	// index without changing the result
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result.MethodsOf(TypeString)["len"] == nil {
+				t.Error("MethodsOf(string) has no len")
+			}
+		}()
+	}
+	wg.Wait()
+	if len(result.Modules) != modules {
+		t.Errorf("MethodsOf loaded %d modules into the result", len(result.Modules)-modules)
+	}
+}
+
+func TestRecheckChecksOnlyTheEditedDeclaration(t *testing.T) {
+	const src = `package main;
+fn a() -> int { return "no"; }
+fn b() -> int { return 1; }
+`
+	const edited = `package main;
+fn a() -> int { return "no"; }
+fn b() -> int { return true; }
+`
+	for _, movable := range []bool{true, false} {
+		program := NewProgram()
+		file := parseProgramFile(t, src, "edit.mal")
+		prev := program.Check(file, "edit.mal")
+		if len(prev.Errors) != 1 {
+			t.Fatalf("errors before the edit: %v", prev.Errors)
+		}
+		checker := prev.checker
+
+		// Only b changed, and nothing moved
+		b := file.Decls[1].(*ast.FnDecl)
+		old := b.Body
+		b.Body = parseProgramFile(t, edited, "edit.mal").Decls[1].(*ast.FnDecl).Body
+		next := ast.NewFile(file.Span())
+		next.Package, next.Decls = file.Package, file.Decls
+		shift := func(span diag.Span) (diag.Span, bool) { return span, movable }
+		result := program.Recheck(prev, next, BodyEdit{Decl: b, Old: old, Shift: shift})
+
+		if reused := result.checker == checker; reused != movable {
+			t.Errorf("movable %v: reused the check = %v", movable, reused)
+		}
+		if len(result.Errors) != 2 || result.Errors[0].Span.Line != 2 || result.Errors[1].Span.Line != 3 {
+			t.Errorf("movable %v: errors after the edit: %v", movable, result.Errors)
+		}
+		ast.Walk(old, func(n ast.Node) bool {
+			if _, ok := result.Types[n]; ok {
+				t.Errorf("movable %v: the replaced body's %T still has a type", movable, n)
+			}
+			return true
+		})
+		if program.Result("edit.mal") != result || len(program.Lookup("b")) != 1 {
+			t.Errorf("movable %v: the result of the edit is not recorded", movable)
+		}
+	}
+}
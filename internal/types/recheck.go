@@ -0,0 +1,185 @@
+package types
+
+import (
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// BodyEdit is an edit of a checked file that replaced the body of one
+// function and left the rest of the file as it was, apart from moving it.
+type BodyEdit struct {
+	// Decl is the top-level declaration holding the function: the function
+	// itself, or the impl or trait it is a method of
+	Decl ast.Decl
+	// Old is the body the edit replaced. Decl holds the new one.
+	Old *ast.BlockExpr
+	// Shift moves a span of the checked version of the file to its place in
+	// the edited one, reporting false for a span the edit changed
+	Shift func(diag.Span) (diag.Span, bool)
+}
+
+// diagCount is a position in the diagnostics of a checker
+type diagCount struct {
+	errors, warnings int
+}
+
+// diagRange is the diagnostics of a checker between two positions
+type diagRange struct {
+	start, end diagCount
+}
+
+func (c *Checker) diagCount() diagCount {
+	return diagCount{len(c.Errors), len(c.Warnings)}
+}
+
+// movedDiags holds diagnostics moved to their place in an edited file
+type movedDiags struct {
+	errors, warnings []diag.Diagnostic
+}
+
+// Recheck checks file, the file of prev after edit, and records the result
+// like Check. The top-level nodes of file must be those of prev.File, with
+// their spans moved to their places in file: only edit.Decl is checked
+// again, and what checking the other declarations found is kept. When
+// prev's diagnostics cannot be moved to file, file is checked from scratch.
+// prev is updated in place and must not be used afterwards.
+func (p *Program) Recheck(prev *CheckResult, file *ast.File, edit BodyEdit) *CheckResult {
+	if !prev.checker.recheck(file, edit) {
+		return p.Check(file, prev.Filename)
+	}
+	result := prev.checker.result(&p.prelude)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.record(prev.Filename, result)
+	return result
+}
+
+// recheck checks file after edit, reusing the diagnostics and the recorded
+// types and symbols of the declarations edit did not change. It reports
+// false, having changed nothing, if some diagnostic cannot be moved.
+func (c *Checker) recheck(file *ast.File, edit BodyEdit) bool {
+	// Move every diagnostic that is kept before changing anything
+	move := func(r diagRange) (movedDiags, bool) {
+		var moved movedDiags
+		var ok bool
+		moved.errors, ok = moveDiagnostics(c.Errors[r.start.errors:r.end.errors], edit.Shift)
+		if !ok {
+			return moved, false
+		}
+		moved.warnings, ok = moveDiagnostics(c.Warnings[r.start.warnings:r.end.warnings], edit.Shift)
+		return moved, ok
+	}
+	collected, ok := move(diagRange{end: c.collected})
+	if !ok {
+		return false
+	}
+	decls := make(map[ast.Decl]movedDiags, len(file.Decls))
+	for _, decl := range file.Decls {
+		if decl == edit.Decl {
+			continue
+		}
+		r, found := c.declDiags[decl]
+		if !found {
+			return false
+		}
+		if decls[decl], ok = move(r); !ok {
+			return false
+		}
+	}
+	modules, ok := move(diagRange{c.unused.end, c.diagCount()})
+	if !ok {
+		return false
+	}
+
+	c.forget(edit.Old)
+	c.file = file
+	c.Errors, c.Warnings = collected.errors, collected.warnings
+	c.collected = c.diagCount()
+	c.declDiags = make(map[ast.Decl]diagRange, len(file.Decls))
+	c.Env.InvalidateCache()
+	for _, decl := range file.Decls {
+		start := c.diagCount()
+		if decl == edit.Decl {
+			c.checkDeclBody(decl)
+		} else {
+			c.Errors = append(c.Errors, decls[decl].errors...)
+			c.Warnings = append(c.Warnings, decls[decl].warnings...)
+		}
+		c.declDiags[decl] = diagRange{start, c.diagCount()}
+	}
+	start := c.diagCount()
+	c.reportUnusedVariables(file)
+	c.unused = diagRange{start, c.diagCount()}
+	c.Errors = append(c.Errors, modules.errors...)
+	c.Warnings = append(c.Warnings, modules.warnings...)
+
+	// The new body may use modules no other code loaded
+	checked := make(map[string]bool, len(c.Modules))
+	for name := range c.Modules {
+		checked[name] = true
+	}
+	c.checkModuleBodies(checked)
+	return true
+}
+
+// forget drops what checking node recorded about it and the nodes in it.
+func (c *Checker) forget(node ast.Node) {
+	ast.Walk(node, func(n ast.Node) bool {
+		delete(c.ExprTypes, n)
+		switch n := n.(type) {
+		case *ast.Ident:
+			delete(c.Uses, n)
+			delete(c.Defs, n)
+			delete(c.Moves, n)
+		case *ast.CallExpr:
+			delete(c.CallTypeArgs, n)
+		}
+		if e, ok := n.(ast.Expr); ok {
+			delete(c.Consts, e)
+		}
+		if d, ok := n.(ast.Decl); ok {
+			delete(c.constValues, d)
+		}
+		return true
+	})
+}
+
+// moveDiagnostics returns diags with their spans moved by shift, or false if
+// shift cannot move one of them
+func moveDiagnostics(diags []diag.Diagnostic, shift func(diag.Span) (diag.Span, bool)) ([]diag.Diagnostic, bool) {
+	moved := make([]diag.Diagnostic, 0, len(diags))
+	ok := true
+	move := func(span *diag.Span) {
+		if ok && *span != (diag.Span{}) {
+			*span, ok = shift(*span)
+		}
+	}
+	for _, d := range diags {
+		move(&d.Span)
+		d.Related = append([]diag.Span(nil), d.Related...)
+		for i := range d.Related {
+			move(&d.Related[i])
+		}
+		d.LabeledSpans = append([]diag.LabeledSpan(nil), d.LabeledSpans...)
+		for i := range d.LabeledSpans {
+			move(&d.LabeledSpans[i].Span)
+		}
+		d.ProofChain = append([]diag.ProofStep(nil), d.ProofChain...)
+		for i := range d.ProofChain {
+			move(&d.ProofChain[i].Span)
+		}
+		d.Fixes = append([]diag.Fix(nil), d.Fixes...)
+		for i := range d.Fixes {
+			d.Fixes[i].Edits = append([]diag.Edit(nil), d.Fixes[i].Edits...)
+			for j := range d.Fixes[i].Edits {
+				move(&d.Fixes[i].Edits[j].Span)
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+		moved = append(moved, d)
+	}
+	return moved, true
+}
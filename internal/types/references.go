@@ -0,0 +1,118 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// DefIdent returns the identifier that names s at its definition, or nil for
+// built-in symbols and definitions without a name.
+func (s *Symbol) DefIdent() *ast.Ident {
+	switch n := s.DefNode.(type) {
+	case *ast.Ident:
+		return n
+	case *ast.FnDecl:
+		return n.Name
+	case *ast.StructDecl:
+		return n.Name
+	case *ast.EnumDecl:
+		return n.Name
+	case *ast.EnumVariant:
+		return n.Name
+	case *ast.TraitDecl:
+		return n.Name
+	case *ast.TypeAliasDecl:
+		return n.Name
+	case *ast.ConstDecl:
+		return n.Name
+	case *ast.StaticDecl:
+		return n.Name
+	case *ast.LetStmt:
+		return n.Name
+	case *ast.Param:
+		return n.Name
+	case *ast.VarPattern:
+		return n.Name
+	case *ast.UseDecl:
+		if n.Alias != nil {
+			return n.Alias
+		}
+		if len(n.Path) > 0 {
+			return n.Path[len(n.Path)-1]
+		}
+	}
+	return nil
+}
+
+// recordUse links an identifier to the symbol it resolved to
+func (c *Checker) recordUse(ident *ast.Ident, sym *Symbol) {
+	if ident == nil || sym == nil {
+		return
+	}
+	if _, seen := c.Uses[ident]; !seen {
+		c.warnDeprecated(ident, sym)
+	}
+	c.Uses[ident] = sym
+}
+
+// warnDeprecated warns about ident when it uses a declaration marked
+// #[deprecated]
+func (c *Checker) warnDeprecated(ident *ast.Ident, sym *Symbol) {
+	var attrs []*ast.Attribute
+	var kind string
+	switch d := sym.DefNode.(type) {
+	case *ast.FnDecl:
+		attrs, kind = d.Attrs, "function"
+	case *ast.StructDecl:
+		attrs, kind = d.Attrs, "struct"
+	case *ast.EnumDecl:
+		attrs, kind = d.Attrs, "enum"
+	}
+	attr := ast.FindAttribute(attrs, "deprecated")
+	if attr == nil {
+		return
+	}
+	help := fmt.Sprintf("`%s` is marked `#[deprecated]` and may be removed in a future version", sym.Name)
+	if len(attr.Args) == 1 {
+		if msg, ok := attr.Args[0].(*ast.StringLit); ok {
+			help = fmt.Sprintf("`%s` is deprecated: %s", sym.Name, msg.Value)
+		}
+	}
+	c.reportWarning(fmt.Sprintf("use of deprecated %s `%s`", kind, ident.Name), ident.Span(), diag.CodeDeprecated,
+		"deprecated", help)
+}
+
+// SymbolOf returns the symbol ident refers to, or the symbol it names when
+// ident is part of a definition. It returns nil for identifiers the checker
+// did not resolve, such as field and method names.
+func (c *Checker) SymbolOf(ident *ast.Ident) *Symbol {
+	if sym, ok := c.Uses[ident]; ok {
+		return sym
+	}
+	return c.Defs[ident]
+}
+
+// References returns every identifier that refers to sym, ordered by file
+// and position. Symbols that share a definition, such as a function and its
+// re-export from a module, count as the same symbol. The defining identifier
+// is not included.
+func (c *Checker) References(sym *Symbol) []*ast.Ident {
+	def := sym.DefIdent()
+	var refs []*ast.Ident
+	for ident, use := range c.Uses {
+		if use == sym || (def != nil && use.DefIdent() == def) {
+			refs = append(refs, ident)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i].Span(), refs[j].Span()
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start < b.Start
+	})
+	return refs
+}
@@ -0,0 +1,97 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+func TestUsesLinkIdentifiersToDefinitions(t *testing.T) {
+	input := `
+	package main;
+	struct Point { x: int }
+	fn origin() -> Point {
+		return Point { x: 0 };
+	}
+	fn main() {
+		let p: Point = origin();
+		println(p.x);
+	}
+	`
+	p := parser.New(input)
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	checker := NewChecker()
+	checker.Check(file)
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+
+	var pointDecl *ast.StructDecl
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.StructDecl); ok {
+			pointDecl = d
+		}
+	}
+	sym := checker.SymbolOf(pointDecl.Name)
+	if sym == nil || sym.DefIdent() != pointDecl.Name {
+		t.Fatalf("SymbolOf(Point declaration) = %+v", sym)
+	}
+
+	// The return type, the struct literal and the annotation on p
+	refs := checker.References(sym)
+	if len(refs) != 3 {
+		t.Fatalf("expected 3 references to Point, got %d", len(refs))
+	}
+	for i, ref := range refs {
+		if ref.Name != "Point" || checker.Uses[ref] != sym {
+			t.Errorf("reference %d resolves to %+v", i, checker.Uses[ref])
+		}
+		if i > 0 && ref.Span().Start <= refs[i-1].Span().Start {
+			t.Errorf("references are not in source order")
+		}
+	}
+}
+
+func TestDeprecatedUseWarns(t *testing.T) {
+	src := `package main;
+
+#[deprecated]
+fn old() -> int {
+    return 1;
+}
+
+#[deprecated]
+struct Legacy { x: int }
+
+fn main() {
+    let a = old();
+    let l = Legacy { x: a };
+    println(l.x);
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+
+	want := []string{"use of deprecated function `old`", "use of deprecated struct `Legacy`"}
+	var got []string
+	for _, w := range checker.Warnings {
+		if w.Code == diag.CodeDeprecated {
+			got = append(got, w.Message)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("deprecation warnings = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("warning %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -9,8 +9,8 @@ import (
 type Symbol struct {
 	Name    string
 	Type    Type
-	DefNode ast.Node // The AST node where this symbol is defined
-	Borrows []Borrow // Active borrows of this symbol
+	DefNode ast.Node  // The AST node where this symbol is defined
+	Borrows []*Borrow // Active borrows of this symbol
 }
 
 // BorrowKind represents the type of borrow (shared or exclusive).
@@ -25,28 +25,44 @@ const (
 type Borrow struct {
 	Kind BorrowKind
 	Span lexer.Span
+	// Holder is the binding the reference was stored in. A borrow without
+	// a holder is a temporary that ends with its statement; a held borrow
+	// lasts until the holder's last use.
+	Holder *Symbol
 }
 
 // Scope represents a lexical scope containing symbols.
 type Scope struct {
 	Parent  *Scope
 	Symbols map[string]*Symbol
-	// Borrowed tracks symbols that were borrowed within this scope.
-	// Used to clean up borrows when the scope ends.
-	Borrowed []*Symbol
+	// borrows tracks the borrows created within this scope, so that
+	// whatever is still active when the scope ends can be released.
+	borrows []activeBorrow
+	// defs records the identifier each inserted symbol is defined by. It is
+	// shared with every scope created below this one.
+	defs map[*ast.Ident]*Symbol
 }
 
 // NewScope creates a new scope with an optional parent.
 func NewScope(parent *Scope) *Scope {
-	return &Scope{
+	s := &Scope{
 		Parent:  parent,
 		Symbols: make(map[string]*Symbol),
 	}
+	if parent != nil {
+		s.defs = parent.defs
+	}
+	return s
 }
 
 // Insert adds a symbol to the current scope.
 func (s *Scope) Insert(name string, sym *Symbol) {
 	s.Symbols[name] = sym
+	if s.defs != nil && sym != nil {
+		if ident := sym.DefIdent(); ident != nil {
+			s.defs[ident] = sym
+		}
+	}
 }
 
 // Lookup finds a symbol in the current scope or any parent scope.
@@ -60,25 +76,40 @@ func (s *Scope) Lookup(name string) *Symbol {
 	return nil
 }
 
+// activeBorrow is a borrow together with the symbol it borrows.
+type activeBorrow struct {
+	sym    *Symbol
+	borrow *Borrow
+}
+
 // AddBorrow registers a borrow of a symbol in this scope.
-func (s *Scope) AddBorrow(sym *Symbol, kind BorrowKind, span lexer.Span) {
-	sym.Borrows = append(sym.Borrows, Borrow{Kind: kind, Span: span})
-	s.Borrowed = append(s.Borrowed, sym)
+func (s *Scope) AddBorrow(sym *Symbol, kind BorrowKind, span lexer.Span) *Borrow {
+	b := sym.addBorrow(kind, span)
+	s.borrows = append(s.borrows, activeBorrow{sym: sym, borrow: b})
+	return b
 }
 
-// Close cleans up borrows created in this scope.
+// Close releases the borrows created in this scope that are still active.
 func (s *Scope) Close() {
-	for _, sym := range s.Borrowed {
-		// Remove the last borrow (LIFO assumption for nested scopes works,
-		// but here we just remove *a* borrow matching this scope?
-		// Since we append to s.Borrowed when we append to sym.Borrows,
-		// we can just pop the last one from sym.Borrows if we assume strict nesting.
-		// However, to be safe, we should probably remove the specific borrow we added.
-		// But for now, let's assume stack discipline: the last borrow added is the one to remove
-		// because scopes nest perfectly.
-		if len(sym.Borrows) > 0 {
-			sym.Borrows = sym.Borrows[:len(sym.Borrows)-1]
+	for _, ab := range s.borrows {
+		ab.sym.release(ab.borrow)
+	}
+	s.borrows = nil
+}
+
+// addBorrow starts a borrow of sym.
+func (sym *Symbol) addBorrow(kind BorrowKind, span lexer.Span) *Borrow {
+	b := &Borrow{Kind: kind, Span: span}
+	sym.Borrows = append(sym.Borrows, b)
+	return b
+}
+
+// release ends the borrow b of sym. Releasing an ended borrow does nothing.
+func (sym *Symbol) release(b *Borrow) {
+	for i, active := range sym.Borrows {
+		if active == b {
+			sym.Borrows = append(sym.Borrows[:i], sym.Borrows[i+1:]...)
+			return
 		}
 	}
-	s.Borrowed = nil
 }
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// Shadowing: a `let` may reuse the name of any binding in scope, including
+// one in the same block. The new binding has its own type and is visible
+// from the next statement on; its initializer still sees the old one, so
+// `let x = x + 1;` is allowed. The old binding is visible again once the
+// block declaring the new one ends.
+//
+// Because accidental shadowing is a common bug, the SHADOWED_VARIABLE lint
+// (`-W shadow`) reports bindings that hide a local variable or parameter.
+// It is off by default.
+
+// checkShadow reports a SHADOWED_VARIABLE warning if WarnShadow is set and
+// binding name, about to be inserted into scope, hides a local binding.
+// Names starting with an underscore are never reported.
+func (c *Checker) checkShadow(name *ast.Ident, scope *Scope) {
+	if !c.WarnShadow || c.inModule || name == nil || strings.HasPrefix(name.Name, "_") {
+		return
+	}
+	prev := scope.Lookup(name.Name)
+	if prev == nil {
+		return
+	}
+	switch prev.DefNode.(type) {
+	case *ast.LetStmt, *ast.Param, *ast.VarPattern, *ast.Ident:
+	default:
+		// Functions, types and constants are not local bindings
+		return
+	}
+	prevIdent := prev.DefIdent()
+	if prevIdent == nil || prevIdent == name {
+		return
+	}
+
+	span := c.toDiagSpan(name.Span())
+	warning := diag.Diagnostic{
+		Stage:    diag.StageTypeCheck,
+		Severity: diag.SeverityWarning,
+		Code:     diag.CodeShadowedVariable,
+		Message:  fmt.Sprintf("`%s` shadows an earlier binding", name.Name),
+		Span:     span,
+		Help:     "rename one of the bindings if they are meant to be different variables",
+	}
+	warning = warning.WithPrimarySpan(span, "shadows the earlier binding")
+	if prevSpan := c.toDiagSpan(prevIdent.Span()); prevSpan.IsValid() {
+		warning = warning.WithSecondarySpan(prevSpan, fmt.Sprintf("`%s` first bound here", name.Name))
+	}
+	c.Warnings = append(c.Warnings, warning)
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+func TestShadowing(t *testing.T) {
+	src := `package main;
+fn count() -> int { return 1; }
+fn main() {
+    let x = 1;
+    let x = x + 1;
+    let s: string = "two";
+    if x > 0 {
+        let s: int = 2;
+        let n: int = s + 1;
+    }
+    let t: string = s;
+    for x in [1, 2] {
+        println(x);
+    }
+    let _x = 3;
+    let _x = 4;
+    let count = count();
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) > 0 {
+		t.Fatalf("shadowing should be allowed, got errors: %v", checker.Errors)
+	}
+	for _, w := range checker.Warnings {
+		if w.Code == diag.CodeShadowedVariable {
+			t.Errorf("shadow lint reported without WarnShadow: %s", w.Message)
+		}
+	}
+
+	checker = NewChecker()
+	checker.WarnShadow = true
+	checker.CheckWithFilename(parser.New(src, parser.WithFilename("main.mal")).ParseFile(), "main.mal")
+	var shadowed []string
+	for _, w := range checker.Warnings {
+		if w.Code == diag.CodeShadowedVariable {
+			shadowed = append(shadowed, w.Message)
+		}
+	}
+	want := []string{
+		"`x` shadows an earlier binding",
+		"`s` shadows an earlier binding",
+		"`x` shadows an earlier binding",
+	}
+	if len(shadowed) != len(want) {
+		t.Fatalf("got shadow warnings %q, want %q", shadowed, want)
+	}
+	for i := range want {
+		if shadowed[i] != want[i] {
+			t.Errorf("warning %d = %q, want %q", i, shadowed[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+func TestSpawn(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		hasError bool
+		errorMsg string
+	}{
+		{
+			name: "spawn call with values",
+			input: `
+			package main;
+			fn worker(n: int) {}
+			fn main() {
+				let mut n = 1;
+				spawn worker(n);
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "spawn call with mutable reference",
+			input: `
+			package main;
+			fn worker(n: &mut int) {}
+			fn main() {
+				let mut n = 1;
+				spawn worker(&mut n);
+			}
+			`,
+			hasError: true,
+			errorMsg: "cannot pass a mutable reference to a spawned function",
+		},
+		{
+			name: "spawn block capturing mutable variable",
+			input: `
+			package main;
+			fn main() {
+				let mut count = 0;
+				spawn {
+					count = count + 1;
+				};
+			}
+			`,
+			hasError: true,
+			errorMsg: "cannot capture mutable variable `count` in spawn",
+		},
+		{
+			name: "spawn block capturing immutable variable",
+			input: `
+			package main;
+			fn main() {
+				let limit = 10;
+				spawn {
+					let mut i = 0;
+					while i < limit {
+						i = i + 1;
+					}
+				};
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "spawn block capturing mutable reference parameter",
+			input: `
+			package main;
+			fn run(total: &mut int) {
+				spawn {
+					let x = total;
+				};
+			}
+			fn main() {}
+			`,
+			hasError: true,
+			errorMsg: "cannot capture mutable reference `total` in spawn",
+		},
+		{
+			name: "spawn expression joins to the return type",
+			input: `
+			package main;
+			fn square(n: int) -> int { return n * n; }
+			fn main() {
+				let h: JoinHandle[int] = spawn square(3);
+				let n: int = h.join();
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "join result type mismatch",
+			input: `
+			package main;
+			fn square(n: int) -> int { return n * n; }
+			fn main() {
+				let h = spawn square(3);
+				let s: string = h.join();
+			}
+			`,
+			hasError: true,
+			errorMsg: "cannot assign",
+		},
+		{
+			name: "spawn block sharing an atomic",
+			input: `
+			package main;
+			fn main() {
+				let count = AtomicInt::new(0);
+				spawn {
+					count.fetch_add(1);
+				};
+			}
+			`,
+			hasError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(tt.input)
+			file := p.ParseFile()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+
+			checker := NewChecker()
+			checker.Check(file)
+
+			if tt.hasError {
+				found := false
+				for _, err := range checker.Errors {
+					if strings.Contains(err.Message, tt.errorMsg) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("expected error %q, got %v", tt.errorMsg, checker.Errors)
+				}
+			} else if len(checker.Errors) > 0 {
+				t.Errorf("unexpected errors: %v", checker.Errors)
+			}
+		})
+	}
+}
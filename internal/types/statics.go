@@ -0,0 +1,89 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+)
+
+// A static is a module-level variable. Its initializer must be a constant
+// expression (see consts.go), so statics are initialized before the program
+// starts and no static can observe another one uninitialized. A `static mut`
+// can be changed by any thread at any time, so every use of one must be in
+// an unsafe block.
+
+// checkStaticDecl checks the initializer of a static against its declared
+// type and evaluates it, recording the value for the lowerer. A mutable
+// static in the initializer is reported as not constant rather than as
+// needing an unsafe block.
+func (c *Checker) checkStaticDecl(d *ast.StaticDecl) {
+	typ := c.resolveType(d.Type)
+	valueType := c.checkExpr(d.Value, c.GlobalScope, true)
+	if !c.assignableTo(valueType, typ) {
+		c.reportTypeMismatch(typ, valueType, d.Value.Span(), "static initializer")
+		return
+	}
+	if v, ok := c.constValue(d); ok {
+		c.Consts[d.Value] = v
+	}
+}
+
+// checkStaticUse reports a use of a mutable static outside an unsafe block.
+func (c *Checker) checkStaticUse(ident *ast.Ident, sym *Symbol, inUnsafe bool) {
+	d, ok := sym.DefNode.(*ast.StaticDecl)
+	if !ok || !d.Mutable || inUnsafe {
+		return
+	}
+	help := fmt.Sprintf("mutable statics can be changed by any thread; wrap the access in an unsafe block:\n  unsafe {\n    %s = %s + 1;\n  }", ident.Name, ident.Name)
+	c.reportErrorWithCode(
+		fmt.Sprintf("use of mutable static `%s` requires unsafe block", ident.Name),
+		ident.Span(),
+		diag.CodeTypeUnsafeRequired,
+		help,
+		nil,
+	)
+}
+
+// checkStaticAssign reports an assignment to an immutable static.
+func (c *Checker) checkStaticAssign(target ast.Expr, scope *Scope) {
+	ident, ok := target.(*ast.Ident)
+	if !ok {
+		return
+	}
+	sym := scope.Lookup(ident.Name)
+	if sym == nil {
+		return
+	}
+	if d, ok := sym.DefNode.(*ast.StaticDecl); ok && !d.Mutable {
+		c.reportErrorWithCode(
+			fmt.Sprintf("cannot assign to immutable static `%s`", ident.Name),
+			ident.Span(),
+			diag.CodeTypeInvalidOperation,
+			fmt.Sprintf("declare it as `static mut %s` to allow assignment", ident.Name),
+			nil,
+		)
+	}
+}
+
+// checkStaticBorrow reports a reference taken to a static, which lives in
+// a global rather than in a local variable that can be borrowed.
+func (c *Checker) checkStaticBorrow(operand ast.Expr, scope *Scope) {
+	ident, ok := operand.(*ast.Ident)
+	if !ok {
+		return
+	}
+	sym := scope.Lookup(ident.Name)
+	if sym == nil {
+		return
+	}
+	if _, ok := sym.DefNode.(*ast.StaticDecl); ok {
+		c.reportErrorWithCode(
+			fmt.Sprintf("cannot take a reference to static `%s`", ident.Name),
+			ident.Span(),
+			diag.CodeTypeInvalidOperation,
+			fmt.Sprintf("copy the static into a local variable first:\n  let value = %s;", ident.Name),
+			nil,
+		)
+	}
+}
@@ -0,0 +1,78 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatics(t *testing.T) {
+	tests := []struct {
+		name  string
+		decls string
+		body  string
+		want  string // substring of the only error, or "" for none
+	}{
+		{
+			name:  "read immutable static",
+			decls: "static LIMIT: int = 10;",
+			body:  "println(LIMIT);",
+		},
+		{
+			name:  "static from statics and consts",
+			decls: "const BASE: int = 2;\nstatic LIMIT: int = BASE * 5;\nstatic DOUBLE: int = LIMIT * 2;\nstruct Grid { cells: [int; DOUBLE / 10] }",
+			body:  "println(DOUBLE);",
+		},
+		{
+			name:  "mutable static in unsafe block",
+			decls: "static mut COUNT: int = 0;",
+			body:  "unsafe {\n        COUNT = COUNT + 1;\n    };",
+		},
+		{
+			name:  "mutable static outside unsafe block",
+			decls: "static mut COUNT: int = 0;",
+			body:  "println(COUNT);",
+			want:  "use of mutable static `COUNT` requires unsafe block",
+		},
+		{
+			name:  "assign immutable static",
+			decls: "static LIMIT: int = 10;",
+			body:  "LIMIT = 11;",
+			want:  "cannot assign to immutable static `LIMIT`",
+		},
+		{
+			name:  "borrow static",
+			decls: "static LIMIT: int = 10;",
+			body:  "let r = &LIMIT;\n    println(*r);",
+			want:  "cannot take a reference to static `LIMIT`",
+		},
+		{
+			name:  "initializer reads mutable static",
+			decls: "static mut COUNT: int = 0;\nstatic LIMIT: int = COUNT;",
+			want:  "initializer of static `LIMIT` is not a constant expression",
+		},
+		{
+			name:  "initializer type",
+			decls: "static LIMIT: int = \"ten\";",
+			want:  "static initializer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package main;\n" + tt.decls + "\nfn main() {\n    " + tt.body + "\n}\n"
+			checker := checkSource(t, src, "main.mal")
+			if tt.want == "" {
+				if len(checker.Errors) > 0 {
+					t.Fatalf("unexpected errors: %v", checker.Errors)
+				}
+				return
+			}
+			if len(checker.Errors) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(checker.Errors), checker.Errors)
+			}
+			if got := checker.Errors[0].Message; !strings.Contains(got, tt.want) {
+				t.Errorf("error = %q, want it to mention %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+// TestStdlibModules checks that programs using the std modules type-check.
+func TestStdlibModules(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "std::env",
+			input: `
+			package main;
+			use std::env;
+			fn main() {
+				let all: []string = env::args();
+				let n: int = env::arg_count();
+				let first: string = env::arg(0);
+				let home: string? = env::var("HOME");
+				let ok: bool = env::set_var("MALPHAS_TEST", "1");
+			}
+			`,
+		},
+		{
+			name: "std::fs",
+			input: `
+			package main;
+			use std::fs;
+			fn main() {
+				let w = fs::write_string("/tmp/x", "a\nb\n");
+				let r = fs::read_to_string("/tmp/x");
+				let input: fs::File = fs::stdin();
+				match fs::open("/tmp/x") {
+					Result::Ok(f) => {
+						let reader = f.lines();
+						let line: string? = reader.read_line();
+						f.close();
+					},
+					Result::Err(e) => {
+						let code: int = e.code;
+						let msg: string = e.message;
+					},
+				};
+			}
+			`,
+		},
+		{
+			name: "std::net",
+			input: `
+			package main;
+			use std::net;
+			fn handle(conn: net::Conn) {
+				let line: string? = conn.read_line();
+				let r = conn.read(512);
+				conn.write("ok\n");
+				conn.close();
+			}
+			fn main() {
+				match net::listen("", 0) {
+					Result::Ok(l) => {
+						let port: int = l.port();
+						match l.accept() {
+							Result::Ok(c) => { spawn handle(c); },
+							Result::Err(e) => { let msg: string = e.message; },
+						};
+					},
+					Result::Err(e) => {},
+				};
+				let c = net::connect("localhost", 80);
+			}
+			`,
+		},
+		{
+			name: "std::time",
+			input: `
+			package main;
+			use std::time;
+			use std::time::Duration;
+			fn main() {
+				let start: time::Instant = time::now();
+				time::sleep(Duration::milliseconds(5));
+				let took: Duration = start.elapsed();
+				let text: string = took.add(Duration::seconds(1)).to_string();
+				let secs: int = time::since_epoch().as_seconds();
+				let c = Channel[int]::new(1);
+				select {
+					let x = <-c => {},
+					after(Duration::seconds(1)) => {},
+				}
+			}
+			`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(tt.input)
+			file := p.ParseFile()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parse errors: %v", p.Errors())
+			}
+
+			checker := NewChecker()
+			checker.Check(file)
+
+			if len(checker.Errors) > 0 {
+				t.Errorf("unexpected errors: %v", checker.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package types
+
+import "github.com/malphas-lang/malphas-lang/internal/ast"
+
+// TraitDeclOf returns the declaration of the trait named by expr, looked up
+// in scope, or nil if expr does not name a declared trait.
+func TraitDeclOf(expr ast.TypeExpr, scope *Scope) *ast.TraitDecl {
+	if generic, ok := expr.(*ast.GenericType); ok {
+		expr = generic.Base
+	}
+	named, ok := expr.(*ast.NamedType)
+	if !ok || scope == nil {
+		return nil
+	}
+	sym := scope.Lookup(named.Name.Name)
+	if sym == nil {
+		return nil
+	}
+	decl, _ := sym.DefNode.(*ast.TraitDecl)
+	return decl
+}
+
+// Overrides reports whether the impl block d declares a method named name.
+func Overrides(d *ast.ImplDecl, name string) bool {
+	for _, method := range d.Methods {
+		if method.Name.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// registerDefaultMethods adds the trait methods with a default body that the
+// trait impl d does not override to the methods of targetName, typed for
+// targetType as if the impl had declared them.
+func (c *Checker) registerDefaultMethods(d *ast.ImplDecl, targetName string, targetType Type, typeParamMap map[string]Type) {
+	if d.Trait == nil {
+		return
+	}
+	trait := TraitDeclOf(d.Trait, c.GlobalScope)
+	if trait == nil {
+		return
+	}
+	for _, method := range trait.Methods {
+		if method.Body == nil || Overrides(d, method.Name.Name) {
+			continue
+		}
+		c.MethodTable[targetName][method.Name.Name] = c.implMethodType(method, targetType, typeParamMap)
+	}
+}
+
+// checkTraitDefaults checks the default method bodies of a trait. Self is a
+// type parameter bounded by the trait, so a default body can only use what
+// every implementing type provides.
+func (c *Checker) checkTraitDefaults(d *ast.TraitDecl) {
+	sym := c.GlobalScope.Lookup(d.Name.Name)
+	if sym == nil {
+		return
+	}
+	self := &TypeParam{Name: "Self", Bounds: []Type{sym.Type}}
+	context := map[string]Type{"Self": self}
+
+	for _, method := range d.Methods {
+		if method.Body == nil {
+			continue
+		}
+		fnScope := NewScope(c.GlobalScope)
+		fnScope.Insert("Self", &Symbol{Name: "Self", Type: self})
+
+		_, methodContext := c.methodTypeParams(method, context)
+		for _, param := range method.Params {
+			fnScope.Insert(param.Name.Name, &Symbol{
+				Name:    param.Name.Name,
+				Type:    c.resolveTypeWithContext(param.Type, methodContext),
+				DefNode: param,
+			})
+		}
+
+		oldReturn := c.CurrentReturn
+		oldFnName := c.CurrentFnName
+		c.CurrentReturn = c.resolveTypeWithContext(method.ReturnType, methodContext)
+		c.CurrentFnName = method.Name.Name
+		c.checkFnBody(method.Body, fnScope, method.Unsafe)
+		c.CurrentReturn = oldReturn
+		c.CurrentFnName = oldFnName
+	}
+}
@@ -0,0 +1,85 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+const traitDefaultsSrc = `package main;
+
+trait Shape {
+    fn area(&self) -> int;
+    fn double_area(&self) -> int {
+        return self.area() * 2;
+    }
+    fn describe(&self) -> string {
+        return "shape";
+    }
+}
+
+struct Square { side: int }
+struct Rect { w: int, h: int }
+
+impl Shape for Square {
+    fn area(&self) -> int { return self.side * self.side; }
+}
+
+impl Shape for Rect {
+    fn area(&self) -> int { return self.w * self.h; }
+    fn describe(&self) -> string { return "rect"; }
+}
+`
+
+func TestTraitDefaultMethods(t *testing.T) {
+	src := traitDefaultsSrc + `
+fn twice[T: Shape](s: T) -> int {
+    return s.double_area();
+}
+
+fn main() {
+    let s = Square { side: 3 };
+    let r = Rect { w: 2, h: 5 };
+    let a: int = s.double_area();
+    let b: string = s.describe();
+    let c: string = r.describe();
+    println(a + twice(r));
+    println(b);
+    println(c);
+}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", checker.Errors)
+	}
+
+	for _, typeName := range []string{"Square", "Rect"} {
+		method := checker.MethodTable[typeName]["double_area"]
+		if method == nil {
+			t.Fatalf("%s has no double_area method", typeName)
+		}
+		if method.Receiver == nil || method.Receiver.Type.String() != typeName {
+			t.Errorf("double_area receiver of %s = %v, want %s", typeName, method.Receiver, typeName)
+		}
+	}
+}
+
+func TestTraitDefaultBodyChecked(t *testing.T) {
+	src := `package main;
+
+trait Named {
+    fn name(&self) -> string;
+    fn shout(&self) -> string {
+        return self.volume();
+    }
+}
+
+fn main() {}
+`
+	checker := checkSource(t, src, "main.mal")
+	if len(checker.Errors) == 0 {
+		t.Fatal("expected an error for a default body calling a method Self does not have")
+	}
+	if msg := checker.Errors[0].Message; !strings.Contains(msg, "volume") {
+		t.Errorf("error = %q, want it to mention volume", msg)
+	}
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+)
+
+// TypedFile is a file that checked without errors together with what the
+// checker recorded about it: the types of its expressions, the type
+// arguments of its generic calls, its declarations and the modules it
+// loaded. It is the input to lowering, which asks TypeOf for the type of
+// each expression and fails when the checker left one untyped rather than
+// guessing.
+type TypedFile struct {
+	File *ast.File
+
+	// Types maps expressions and type annotations to their types
+	Types map[ast.Node]Type
+	// CallTypeArgs maps generic calls to their type arguments
+	CallTypeArgs map[*ast.CallExpr][]Type
+	// Scope is the global scope
+	Scope *Scope
+	// Methods maps type names to their methods
+	Methods map[string]map[string]*Function
+	// Modules are the modules the file loaded, by name
+	Modules map[string]*ModuleInfo
+	// Moves are the identifiers whose use moves the value out of a variable
+	Moves map[*ast.Ident]bool
+	// Consts maps the expressions that name a const to its value
+	Consts map[ast.Expr]any
+}
+
+// Typed returns the typed file of file, which c has checked. It fails if
+// the check reported errors, since an erroneous file has no complete types.
+func (c *Checker) Typed(file *ast.File) (*TypedFile, error) {
+	if len(c.Errors) > 0 {
+		return nil, fmt.Errorf("cannot lower a file with %d type errors", len(c.Errors))
+	}
+	return &TypedFile{
+		File:         file,
+		Types:        c.ExprTypes,
+		CallTypeArgs: c.CallTypeArgs,
+		Scope:        c.GlobalScope,
+		Methods:      c.MethodTable,
+		Modules:      c.Modules,
+		Moves:        c.Moves,
+		Consts:       c.Consts,
+	}, nil
+}
+
+// TypeOf returns the type recorded for node. A node without one is an
+// internal error: the checker accepted the file without typing all of it.
+func (t *TypedFile) TypeOf(node ast.Node) (Type, error) {
+	if typ := t.Types[node]; typ != nil {
+		return typ, nil
+	}
+	return nil, MissingTypeError(node)
+}
+
+// MissingTypeError reports that the checker recorded no type for node.
+func MissingTypeError(node ast.Node) error {
+	span := node.Span()
+	where := fmt.Sprintf("%d:%d", span.Line, span.Column)
+	if span.Filename != "" {
+		where = span.Filename + ":" + where
+	}
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	return fmt.Errorf("internal compiler error: no type recorded for %s at %s", kind, where)
+}
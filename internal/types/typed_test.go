@@ -0,0 +1,62 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+)
+
+func TestTyped(t *testing.T) {
+	src := `package main;
+fn main() {
+    let x = 1 + 2;
+    println(x);
+}
+`
+	p := parser.New(src, parser.WithFilename("typed.mal"))
+	file := p.ParseFile()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	checker := NewChecker()
+	checker.CheckWithFilename(file, "typed.mal")
+	typed, err := checker.Typed(file)
+	if err != nil {
+		t.Fatalf("Typed: %v", err)
+	}
+
+	var sum *ast.InfixExpr
+	ast.Walk(file, func(n ast.Node) bool {
+		if infix, ok := n.(*ast.InfixExpr); ok {
+			sum = infix
+		}
+		return true
+	})
+	if typ, err := typed.TypeOf(sum); err != nil || typ != TypeInt {
+		t.Errorf("TypeOf(1 + 2) = %v, %v; want int", typ, err)
+	}
+
+	// A node the checker never saw has no type
+	stray := ast.NewIntegerLit("3", sum.Span())
+	_, err = typed.TypeOf(stray)
+	if err == nil || !strings.Contains(err.Error(), "no type recorded for IntegerLit at typed.mal:3:") {
+		t.Errorf("TypeOf(stray) error = %v, want a missing type error", err)
+	}
+}
+
+func TestTypedRejectsErrors(t *testing.T) {
+	src := `package main;
+fn main() {
+    let x: int = "one";
+}
+`
+	p := parser.New(src)
+	file := p.ParseFile()
+	checker := NewChecker()
+	checker.Check(file)
+	if _, err := checker.Typed(file); err == nil {
+		t.Error("Typed accepted a file with type errors")
+	}
+}
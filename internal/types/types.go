@@ -31,6 +31,11 @@ const (
 	String PrimitiveKind = "string"
 	Nil    PrimitiveKind = "nil"
 	Void   PrimitiveKind = "void"
+	// Never is the type of expressions that do not produce a value because
+	// control never gets past them, such as a call of panic
+	Never PrimitiveKind = "!"
+	// Error is the kind of TypeError
+	Error PrimitiveKind = "{error}"
 )
 
 // Primitive represents a primitive type.
@@ -58,6 +63,10 @@ var (
 	TypeString = &Primitive{Kind: String}
 	TypeNil    = &Primitive{Kind: Nil}
 	TypeVoid   = &Primitive{Kind: Void}
+	TypeNever  = &Primitive{Kind: Never}
+	// TypeError is the type of expressions whose errors have been reported.
+	// Errors that follow from an operand of this type are suppressed.
+	TypeError = &Primitive{Kind: Error}
 )
 
 // Struct represents a struct type.
@@ -65,6 +74,8 @@ type Struct struct {
 	Name       string
 	TypeParams []TypeParam
 	Fields     []Field
+	Copy       bool // declared #[copy], so values are copied instead of moved
+	Drop       bool // has a Drop impl, so values are dropped when their owner goes out of scope
 	// fieldMap provides O(1) lookup of field name -> field index
 	// It's built lazily on first access via ensureFieldMap()
 	fieldMap map[string]int
@@ -140,6 +151,12 @@ type Enum struct {
 	Name       string
 	TypeParams []TypeParam
 	Variants   []Variant
+	Repr       *Primitive // integer type of the tag, from #[repr]; nil for the default
+	Copy       bool       // declared #[copy], so values are copied instead of moved
+	// Origin is the generic enum this one specializes, with TypeArgs as its
+	// type arguments; nil unless the monomorphizer made e
+	Origin   *Enum
+	TypeArgs []Type
 }
 
 type Variant struct {
@@ -151,6 +168,69 @@ type Variant struct {
 func (e *Enum) String() string { return e.Name }
 func (e *Enum) IsType()        {}
 
+// VariantIndex returns the index of the named variant, or -1.
+func (e *Enum) VariantIndex(name string) int {
+	for i, v := range e.Variants {
+		if v.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// TagType returns the type of the tag of e, as given by enum_tag: its
+// #[repr] type, or int.
+func (e *Enum) TagType() Type {
+	if e.Repr != nil {
+		return e.Repr
+	}
+	return TypeInt
+}
+
+// Fieldless reports whether no variant of e carries a payload.
+func (e *Enum) Fieldless() bool {
+	for _, v := range e.Variants {
+		if len(v.Params) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// AsResult reports whether t is an instance of a Result[T, E] enum (an enum named
+// Result with Ok and Err variants) and returns the enum and its type arguments.
+// A specialization of Result made by the monomorphizer counts as an instance.
+func AsResult(t Type) (*Enum, []Type, bool) {
+	var args []Type
+	for {
+		switch typ := t.(type) {
+		case *Named:
+			if typ.Ref == nil {
+				return nil, nil, false
+			}
+			t = typ.Ref
+			continue
+		case *GenericInstance:
+			args = typ.Args
+			t = typ.Base
+			continue
+		case *Enum:
+			base := typ
+			if typ.Origin != nil {
+				base = typ.Origin
+				if args == nil {
+					args = typ.TypeArgs
+				}
+			}
+			if base.Name != "Result" || typ.VariantIndex("Ok") < 0 || typ.VariantIndex("Err") < 0 {
+				return nil, nil, false
+			}
+			return typ, args, true
+		}
+		return nil, nil, false
+	}
+}
+
 // Array represents a fixed-size array type [T; N].
 type Array struct {
 	Elem Type
@@ -158,8 +238,7 @@ type Array struct {
 }
 
 func (a *Array) String() string {
-	// TODO: Format length properly
-	return "[" + a.Elem.String() + "; " + "N" + "]"
+	return fmt.Sprintf("[%s; %d]", a.Elem, a.Len)
 }
 func (a *Array) IsType() {}
 
@@ -210,6 +289,7 @@ type Function struct {
 // ReceiverType represents a method receiver.
 type ReceiverType struct {
 	IsMutable bool // true for &mut self, false for &self
+	ByValue   bool // true for `self: T`, which takes the receiver by value
 	Type      Type // the type being implemented on
 }
 
@@ -236,6 +316,15 @@ type Channel struct {
 	Dir  ChanDir
 }
 
+// JoinHandle is produced by a `spawn` expression. Calling join() on it waits
+// for the spawned call to finish and yields its return value.
+type JoinHandle struct {
+	Elem Type
+}
+
+func (j *JoinHandle) String() string { return "JoinHandle[" + j.Elem.String() + "]" }
+func (j *JoinHandle) IsType()        {}
+
 type ChanDir int
 
 const (
@@ -85,6 +85,68 @@ func TestUnsafeChecks(t *testing.T) {
 			`,
 			hasError: false,
 		},
+		{
+			name: "valid pointer casts and offset inside unsafe block",
+			input: `
+			package main;
+			fn main() {
+				let x: int = 5;
+				unsafe {
+					let p = &x as *int;
+					let bytes = p as *u8;
+					let next = bytes.offset(1);
+					let back = next.offset(-1) as *int;
+					let addr = back as int;
+				}
+			}
+			`,
+			hasError: false,
+		},
+		{
+			name: "invalid reference to raw pointer cast outside block",
+			input: `
+			package main;
+			fn main() {
+				let x: int = 5;
+				let p = &x as *int;
+			}
+			`,
+			hasError: true,
+			errorMsg: "cast from &int to *int requires unsafe block",
+		},
+		{
+			name: "invalid raw pointer cast outside block",
+			input: `
+			package main;
+			fn f(p: *int) -> *u8 {
+				return p as *u8;
+			}
+			`,
+			hasError: true,
+			errorMsg: "requires unsafe block",
+		},
+		{
+			name: "invalid pointer offset outside block",
+			input: `
+			package main;
+			fn f(p: *int) -> *int {
+				return p.offset(1);
+			}
+			`,
+			hasError: true,
+			errorMsg: "pointer offset requires unsafe block",
+		},
+		{
+			name: "invalid pointer offset by a non-integer",
+			input: `
+			package main;
+			unsafe fn f(p: *int) -> *int {
+				return p.offset(true);
+			}
+			`,
+			hasError: true,
+			errorMsg: "offset must be an integer, got bool",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,329 @@
+// Package malphas is the Go API of the Malphas compiler, for tools such as
+// build systems and linters that embed it instead of running the malphas
+// command. It covers the front of the pipeline: parsing, type checking and
+// compiling a file to LLVM IR. Assembling and linking the IR is left to the
+// caller, as `malphas build` leaves it to llc and the C compiler.
+//
+// The options structs mirror the flags of the malphas command; their zero
+// values select the command's defaults.
+package malphas
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/malphas-lang/malphas-lang/internal/ast"
+	"github.com/malphas-lang/malphas-lang/internal/codegen/mir2llvm"
+	"github.com/malphas-lang/malphas-lang/internal/diag"
+	"github.com/malphas-lang/malphas-lang/internal/mir"
+	"github.com/malphas-lang/malphas-lang/internal/mir/optimize"
+	"github.com/malphas-lang/malphas-lang/internal/parser"
+	"github.com/malphas-lang/malphas-lang/internal/types"
+)
+
+// File is a parsed source file.
+type File struct {
+	filename string
+	src      string
+	ast      *ast.File
+	syntax   []Diagnostic // The syntax errors
+}
+
+// Filename returns the name the file was parsed as.
+func (f *File) Filename() string { return f.filename }
+
+// Source returns the text of the file.
+func (f *File) Source() string { return f.src }
+
+// ParseOptions configures Parse.
+type ParseOptions struct {
+	// Filename names the file in diagnostics. Modules declared with `mod`
+	// are resolved relative to it, so it should be the file's path when
+	// the file is checked or compiled.
+	Filename string
+}
+
+// CheckOptions configures Check, mirroring the -W flags.
+type CheckOptions struct {
+	// Lints enables off-by-default lints by name, as -W <lint>: "shadow"
+	// reports let bindings that hide a local variable.
+	Lints []string
+	// Errors turns the warnings with these codes into errors, as
+	// -W error=<code>; "all" selects every warning.
+	Errors []string
+	// Ignore drops the warnings with these codes, as -W ignore=<code>;
+	// "all" selects every warning. A code in Errors wins over "all" here.
+	Ignore []string
+}
+
+// CompileOptions configures CompileLLVM, mirroring the flags of
+// `malphas build`.
+type CompileOptions struct {
+	Check CheckOptions
+	// Overflow is the integer overflow behavior, as --overflow: "wrap"
+	// (the default), "panic" or "checked".
+	Overflow string
+	// MIROpt selects the MIR optimization passes, as --mir-opt: "all",
+	// "none" (the default) or a comma-separated list of pass names.
+	MIROpt string
+	// Target is the target triple, as --target. The default is
+	// x86_64-unknown-linux-gnu.
+	Target string
+}
+
+// Parse parses src as a Malphas source file. A file with syntax errors is
+// returned along with them; the parser recovers from each error, so the
+// rest of the file is still there.
+func Parse(src string, opts *ParseOptions) (*File, []Diagnostic) {
+	if opts == nil {
+		opts = &ParseOptions{}
+	}
+	p := parser.New(src, parser.WithFilename(opts.Filename))
+	file := &File{filename: opts.Filename, src: src, ast: p.ParseFile()}
+	for _, err := range p.Errors() {
+		file.syntax = append(file.syntax, newDiagnostic(err.Diagnostic()))
+	}
+	return file, file.syntax
+}
+
+// Check type checks file and returns its errors and warnings, with the
+// levels of opts applied to the warnings. A file with syntax errors is not
+// checked; its syntax errors are returned instead. The error is for
+// invalid options.
+func Check(file *File, opts *CheckOptions) ([]Diagnostic, error) {
+	_, ds, err := check(file, opts)
+	return ds, err
+}
+
+// CompileLLVM checks file and compiles it to LLVM IR, which it returns as
+// text. If the file does not compile, the error is an *Error holding the
+// diagnostics that say why; warnings of a file that compiles are dropped.
+func CompileLLVM(file *File, opts *CompileOptions) (string, error) {
+	if opts == nil {
+		opts = &CompileOptions{}
+	}
+	overflow, err := mir2llvm.ParseOverflowMode(opts.Overflow)
+	if err != nil {
+		return "", err
+	}
+	passes, err := optimize.ParsePasses(opts.MIROpt)
+	if err != nil {
+		return "", err
+	}
+	target := mir2llvm.DefaultTarget
+	if opts.Target != "" {
+		if target, err = mir2llvm.ParseTarget(opts.Target); err != nil {
+			return "", err
+		}
+	}
+
+	checker, ds, err := check(file, &opts.Check)
+	if err != nil {
+		return "", err
+	}
+	if checker == nil || hasErrors(ds) {
+		return "", &Error{Diagnostics: ds}
+	}
+	typed, err := checker.Typed(file.ast)
+	if err != nil {
+		return "", err
+	}
+
+	lowerer := mir.NewTypedLowerer(typed)
+	module, err := lowerer.LowerModule(typed.File)
+	if err != nil {
+		return "", fmt.Errorf("MIR lowering error: %v", err)
+	}
+	if len(lowerer.Errors) > 0 {
+		return "", newError(lowerer.Errors)
+	}
+	if err := mir.NewMonomorphizer(module).Monomorphize(); err != nil {
+		return "", fmt.Errorf("MIR monomorphization error: %v", err)
+	}
+	module = optimize.Run(module, passes)
+	optimize.AnalyzeEscapes(module)
+
+	gen := mir2llvm.NewGenerator()
+	gen.Overflow = overflow
+	gen.Target = target
+	ir, err := gen.Generate(module)
+	if len(gen.Errors) > 0 {
+		return "", newError(gen.Errors)
+	}
+	if err != nil {
+		return "", fmt.Errorf("MIR-to-LLVM codegen error: %v", err)
+	}
+	return ir, nil
+}
+
+// check type checks file, returning its checker and its diagnostics with
+// the warning levels of opts applied. The checker is nil for a file with
+// syntax errors, whose diagnostics are those errors.
+func check(file *File, opts *CheckOptions) (*types.Checker, []Diagnostic, error) {
+	if opts == nil {
+		opts = &CheckOptions{}
+	}
+	levels := make(map[diag.Code]string)
+	for _, name := range opts.Lints {
+		if name != "shadow" {
+			return nil, nil, fmt.Errorf("unknown lint %q (expected shadow)", name)
+		}
+		levels[diag.CodeShadowedVariable] = "warn"
+	}
+	for _, code := range opts.Ignore {
+		levels[diag.Code(code)] = "ignore"
+	}
+	for _, code := range opts.Errors {
+		levels[diag.Code(code)] = "error"
+	}
+
+	// Checking a file that did not parse would report errors about the
+	// parser's recovery rather than about the source
+	if len(file.syntax) > 0 {
+		return nil, file.syntax, nil
+	}
+
+	filename := file.filename
+	if abs, err := filepath.Abs(filename); err == nil && filename != "" {
+		filename = abs
+	}
+	checker := types.NewChecker()
+	level := levels[diag.CodeShadowedVariable]
+	checker.WarnShadow = level == "warn" || level == "error"
+	checker.CheckWithFilename(file.ast, filename)
+
+	var ds []Diagnostic
+	for _, d := range checker.Errors {
+		ds = append(ds, newDiagnostic(d))
+	}
+	for _, d := range checker.Warnings {
+		level, ok := levels[d.Code]
+		if !ok {
+			level = levels["all"]
+		}
+		switch level {
+		case "ignore":
+			continue
+		case "error":
+			d.Severity = diag.SeverityError
+		}
+		ds = append(ds, newDiagnostic(d))
+	}
+	return checker, ds, nil
+}
+
+// Severity is how serious a diagnostic is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+)
+
+// Diagnostic is an error or warning about a source file.
+type Diagnostic struct {
+	Severity Severity
+	// Code identifies the kind of diagnostic, such as TYPE_MISMATCH. Some
+	// errors have none.
+	Code    string
+	Message string
+	// Help suggests how to fix the problem, if there is a suggestion
+	Help string
+
+	// The position of the code the diagnostic is about: Line and Column
+	// count from 1, Start and End are rune offsets into the source. A
+	// diagnostic about no code in particular has a zero Line.
+	Filename   string
+	Line       int
+	Column     int
+	Start, End int
+}
+
+// newDiagnostic converts a diagnostic of the compiler.
+func newDiagnostic(d diag.Diagnostic) Diagnostic {
+	severity := Severity(d.Severity)
+	if severity == "" {
+		severity = SeverityError
+	}
+	help := d.Help
+	if help == "" {
+		help = d.Suggestion
+	}
+	span := d.Span
+	if len(d.LabeledSpans) > 0 && !span.IsValid() {
+		span = d.LabeledSpans[0].Span
+	}
+	return Diagnostic{
+		Severity: severity,
+		Code:     string(d.Code),
+		Message:  d.Message,
+		Help:     help,
+		Filename: span.Filename,
+		Line:     span.Line,
+		Column:   span.Column,
+		Start:    span.Start,
+		End:      span.End,
+	}
+}
+
+// String formats d on one line, as malphas --short prints it.
+func (d Diagnostic) String() string {
+	tag := string(d.Severity)
+	if d.Code != "" {
+		tag += "[" + d.Code + "]"
+	}
+	message := strings.ReplaceAll(d.Message, "\n", " ")
+	if d.Line == 0 {
+		return tag + ": " + message
+	}
+	where := fmt.Sprintf("%d:%d", d.Line, d.Column)
+	if d.Filename != "" {
+		where = d.Filename + ":" + where
+	}
+	return where + ": " + tag + ": " + message
+}
+
+// Error is the error of a file that does not compile.
+type Error struct {
+	// Diagnostics are the errors that stopped compilation, and the warnings
+	// reported with them
+	Diagnostics []Diagnostic
+}
+
+// newError returns the error of the compiler diagnostics ds
+func newError(ds []diag.Diagnostic) *Error {
+	e := &Error{}
+	for _, d := range ds {
+		e.Diagnostics = append(e.Diagnostics, newDiagnostic(d))
+	}
+	return e
+}
+
+// Error returns the first error, and how many more there are.
+func (e *Error) Error() string {
+	var errors []Diagnostic
+	for _, d := range e.Diagnostics {
+		if d.Severity == SeverityError {
+			errors = append(errors, d)
+		}
+	}
+	switch len(errors) {
+	case 0:
+		return "compilation failed"
+	case 1:
+		return errors[0].String()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", errors[0], len(errors)-1)
+}
+
+// hasErrors reports whether ds holds an error.
+func hasErrors(ds []Diagnostic) bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
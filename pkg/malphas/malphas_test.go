@@ -0,0 +1,82 @@
+package malphas_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/malphas-lang/malphas-lang/pkg/malphas"
+)
+
+const helloSrc = `package main;
+fn main() {
+    let unused = 1;
+    println("hello");
+}
+`
+
+func TestParse(t *testing.T) {
+	file, ds := malphas.Parse(helloSrc, &malphas.ParseOptions{Filename: "hello.mal"})
+	if len(ds) > 0 || file.Filename() != "hello.mal" {
+		t.Fatalf("Parse = %q, %v", file.Filename(), ds)
+	}
+
+	_, ds = malphas.Parse("package main;\nfn main( {}\n", nil)
+	if len(ds) == 0 || ds[0].Severity != malphas.SeverityError || ds[0].Line != 2 {
+		t.Errorf("syntax errors = %v, want an error on line 2", ds)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	file, _ := malphas.Parse(helloSrc, &malphas.ParseOptions{Filename: "hello.mal"})
+
+	ds, err := malphas.Check(file, nil)
+	if err != nil || len(ds) != 1 || ds[0].Severity != malphas.SeverityWarning || ds[0].Code != "UNUSED_VARIABLE" {
+		t.Fatalf("Check = %v, %v; want the unused variable warning", ds, err)
+	}
+	if got, want := ds[0].String(), "hello.mal:3:9: warning[UNUSED_VARIABLE]: "; !strings.HasPrefix(got, want) {
+		t.Errorf("String = %q, want prefix %q", got, want)
+	}
+
+	ds, _ = malphas.Check(file, &malphas.CheckOptions{Errors: []string{"UNUSED_VARIABLE"}})
+	if len(ds) != 1 || ds[0].Severity != malphas.SeverityError {
+		t.Errorf("with -W error=UNUSED_VARIABLE: %v, want an error", ds)
+	}
+	ds, _ = malphas.Check(file, &malphas.CheckOptions{Ignore: []string{"all"}})
+	if len(ds) != 0 {
+		t.Errorf("with -W ignore=all: %v, want nothing", ds)
+	}
+	if _, err := malphas.Check(file, &malphas.CheckOptions{Lints: []string{"unknown"}}); err == nil {
+		t.Error("Check accepted an unknown lint")
+	}
+
+	bad, _ := malphas.Parse("package main;\nfn main() { let x: int = \"s\"; println(x); }\n", nil)
+	ds, _ = malphas.Check(bad, nil)
+	if len(ds) != 1 || ds[0].String() != "2:26: error[TYPE_CANNOT_ASSIGN]: cannot assign value of type `string` to variable of type `int`" {
+		t.Errorf("Check = %v, want the assignment error", ds)
+	}
+}
+
+func TestCompileLLVM(t *testing.T) {
+	file, _ := malphas.Parse(helloSrc, &malphas.ParseOptions{Filename: "hello.mal"})
+	ir, err := malphas.CompileLLVM(file, &malphas.CompileOptions{Overflow: "panic", MIROpt: "all"})
+	if err != nil {
+		t.Fatalf("CompileLLVM: %v", err)
+	}
+	if !strings.Contains(ir, "define i32 @main(") || !strings.Contains(ir, "x86_64-unknown-linux-gnu") {
+		t.Errorf("IR lacks main or the default target:\n%s", ir)
+	}
+
+	_, err = malphas.CompileLLVM(file, &malphas.CompileOptions{Check: malphas.CheckOptions{Errors: []string{"all"}}})
+	var compileErr *malphas.Error
+	if !errors.As(err, &compileErr) || len(compileErr.Diagnostics) != 1 {
+		t.Fatalf("CompileLLVM with warnings as errors = %v, want an *Error", err)
+	}
+	if !strings.Contains(err.Error(), "error[UNUSED_VARIABLE]") {
+		t.Errorf("Error = %q, want the unused variable", err)
+	}
+
+	if _, err := malphas.CompileLLVM(file, &malphas.CompileOptions{Target: "nonsense"}); err == nil {
+		t.Error("CompileLLVM accepted an invalid target")
+	}
+}
@@ -0,0 +1,111 @@
+// runtime/platform_wasi.h
+// WASI shims for the POSIX interfaces runtime.c is written against
+
+// A wasm32-wasi program runs on a single thread: WASI preview 1 has no
+// threads, no way to switch stacks and no sockets of its own. This header
+// therefore turns the runtime into a single-threaded one:
+//
+//   - MALPHAS_NO_LEGIONS leaves out the scheduler and the context switch.
+//     The compiler rejects spawn for the target, and channel operations run
+//     on the main thread only.
+//   - Mutexes are no-ops, and waiting on a condition variable can never be
+//     woken by another thread, so it is reported as a deadlock. A timed wait
+//     sleeps until its deadline.
+//   - MALPHAS_NO_SOCKETS leaves out listen and connect, which WASI cannot
+//     do. Accepting on a socket the host pre-opened still works.
+//
+// As in platform_win32.h, the shims are static functions named malphas_*,
+// and macros map the POSIX names onto them. They therefore never collide
+// with the single-threaded pthread stubs of newer wasi-libc releases.
+
+#ifndef MALPHAS_PLATFORM_WASI_H
+#define MALPHAS_PLATFORM_WASI_H
+
+#include <errno.h>
+#include <fcntl.h>
+#include <poll.h>
+#include <sched.h>
+#include <sys/socket.h>
+#include <time.h>
+#include <unistd.h>
+
+#define MALPHAS_NO_LEGIONS
+#define MALPHAS_NO_SOCKETS
+
+// ============================================================================
+// Threads
+// ============================================================================
+
+typedef struct {
+  int unused;
+} malphas_mutex_t;
+typedef struct {
+  int unused;
+} malphas_cond_t;
+
+#define pthread_mutex_t malphas_mutex_t
+#define pthread_cond_t malphas_cond_t
+
+#define PTHREAD_MUTEX_INITIALIZER {0}
+#define PTHREAD_COND_INITIALIZER {0}
+
+static int malphas_mutex_init(malphas_mutex_t *m, const void *attr) {
+  (void)m;
+  (void)attr;
+  return 0;
+}
+
+static int malphas_mutex_lock(malphas_mutex_t *m) {
+  (void)m;
+  return 0;
+}
+
+static int malphas_mutex_unlock(malphas_mutex_t *m) {
+  (void)m;
+  return 0;
+}
+
+static int malphas_cond_init(malphas_cond_t *c, const void *attr) {
+  (void)c;
+  (void)attr;
+  return 0;
+}
+
+static int malphas_cond_signal(malphas_cond_t *c) {
+  (void)c;
+  return 0;
+}
+
+static int malphas_cond_wait(malphas_cond_t *c, malphas_mutex_t *m) {
+  (void)c;
+  (void)m;
+  runtime_panic_cstr("deadlock: a channel operation would block forever, "
+                     "as this target runs a single thread");
+}
+
+static int malphas_cond_timedwait(malphas_cond_t *c, malphas_mutex_t *m,
+                                  const struct timespec *abstime) {
+  (void)c;
+  (void)m;
+  struct timespec now;
+  clock_gettime(CLOCK_REALTIME, &now);
+  int64_t ns = (int64_t)(abstime->tv_sec - now.tv_sec) * 1000000000LL +
+               (abstime->tv_nsec - now.tv_nsec);
+  if (ns > 0) {
+    struct timespec req = {.tv_sec = (time_t)(ns / 1000000000LL),
+                           .tv_nsec = (long)(ns % 1000000000LL)};
+    nanosleep(&req, NULL);
+  }
+  return ETIMEDOUT;
+}
+
+#define pthread_mutex_init malphas_mutex_init
+#define pthread_mutex_lock malphas_mutex_lock
+#define pthread_mutex_unlock malphas_mutex_unlock
+#define pthread_cond_init malphas_cond_init
+#define pthread_cond_signal malphas_cond_signal
+#define pthread_cond_broadcast malphas_cond_signal
+#define pthread_cond_wait malphas_cond_wait
+#define pthread_cond_timedwait malphas_cond_timedwait
+
+#endif // MALPHAS_PLATFORM_WASI_H
@@ -0,0 +1,537 @@
+// runtime/platform_win32.h
+// Windows shims for the POSIX interfaces runtime.c is written against
+
+// runtime.c uses the subset of pthreads, poll, BSD sockets, mmap and the
+// POSIX clocks listed below. On Windows this header provides them on top of
+// Win32: threads are CreateThread threads, mutexes and condition variables
+// are SRW locks and CONDITION_VARIABLEs, and sockets are Winsock sockets.
+//
+// The shims are static functions named malphas_*; macros map the POSIX names
+// onto them, so runtime.c is unchanged and nothing here collides with a
+// winpthreads or MinGW declaration of the same name.
+//
+// Malphas passes files and sockets around as one int64 descriptor, which
+// runtime_fs_* functions accept for both. CRT file descriptors are small
+// integers; sockets get descriptors from MALPHAS_SOCKET_FD_BASE on, indexing
+// a table of SOCKET handles, so read/write/close/poll can tell them apart.
+
+#ifndef MALPHAS_PLATFORM_WIN32_H
+#define MALPHAS_PLATFORM_WIN32_H
+
+// runtime.c defines _WIN32_WINNT, WIN32_LEAN_AND_MEAN and
+// _CRT_DECLARE_NONSTDC_NAMES before its first include, as they must precede
+// any Windows or CRT header
+
+#include <winsock2.h>
+#include <ws2tcpip.h>
+#include <windows.h>
+
+#include <errno.h>
+#include <fcntl.h>
+#include <io.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <sys/stat.h>
+#include <time.h>
+
+#ifdef _MSC_VER
+typedef intptr_t ssize_t;
+#endif
+
+// ============================================================================
+// Threads
+// ============================================================================
+
+typedef HANDLE malphas_thread_t;
+typedef SRWLOCK malphas_mutex_t;
+typedef CONDITION_VARIABLE malphas_cond_t;
+typedef INIT_ONCE malphas_once_t;
+typedef DWORD malphas_key_t;
+
+#define pthread_t malphas_thread_t
+#define pthread_mutex_t malphas_mutex_t
+#define pthread_cond_t malphas_cond_t
+#define pthread_once_t malphas_once_t
+#define pthread_key_t malphas_key_t
+
+#define PTHREAD_MUTEX_INITIALIZER SRWLOCK_INIT
+#define PTHREAD_COND_INITIALIZER CONDITION_VARIABLE_INIT
+#define PTHREAD_ONCE_INIT INIT_ONCE_STATIC_INIT
+
+typedef struct {
+  void *(*fn)(void *);
+  void *arg;
+} MalphasThreadStart;
+
+static DWORD WINAPI malphas_thread_main(LPVOID param) {
+  MalphasThreadStart start = *(MalphasThreadStart *)param;
+  free(param);
+  start.fn(start.arg);
+  return 0;
+}
+
+static int malphas_thread_create(malphas_thread_t *thread, const void *attr,
+                                 void *(*fn)(void *), void *arg) {
+  (void)attr;
+  MalphasThreadStart *start =
+      (MalphasThreadStart *)malloc(sizeof(MalphasThreadStart));
+  if (!start) {
+    return ENOMEM;
+  }
+  start->fn = fn;
+  start->arg = arg;
+  *thread = CreateThread(NULL, 0, malphas_thread_main, start, 0, NULL);
+  if (!*thread) {
+    free(start);
+    return EAGAIN;
+  }
+  return 0;
+}
+
+static int malphas_thread_join(malphas_thread_t thread, void **result) {
+  if (result) {
+    *result = NULL;
+  }
+  WaitForSingleObject(thread, INFINITE);
+  CloseHandle(thread);
+  return 0;
+}
+
+static int malphas_mutex_init(malphas_mutex_t *m, const void *attr) {
+  (void)attr;
+  InitializeSRWLock(m);
+  return 0;
+}
+
+static int malphas_mutex_lock(malphas_mutex_t *m) {
+  AcquireSRWLockExclusive(m);
+  return 0;
+}
+
+static int malphas_mutex_unlock(malphas_mutex_t *m) {
+  ReleaseSRWLockExclusive(m);
+  return 0;
+}
+
+// SRW locks hold no resources
+static int malphas_mutex_destroy(malphas_mutex_t *m) {
+  (void)m;
+  return 0;
+}
+
+static int malphas_cond_init(malphas_cond_t *c, const void *attr) {
+  (void)attr;
+  InitializeConditionVariable(c);
+  return 0;
+}
+
+static int malphas_cond_wait(malphas_cond_t *c, malphas_mutex_t *m) {
+  SleepConditionVariableSRW(c, m, INFINITE, 0);
+  return 0;
+}
+
+// Wait until the CLOCK_REALTIME time abstime at the latest
+static int malphas_cond_timedwait(malphas_cond_t *c, malphas_mutex_t *m,
+                                  const struct timespec *abstime) {
+  struct timespec now;
+  timespec_get(&now, TIME_UTC);
+  int64_t ms = (int64_t)(abstime->tv_sec - now.tv_sec) * 1000 +
+               (abstime->tv_nsec - now.tv_nsec) / 1000000;
+  if (ms < 0) {
+    ms = 0;
+  }
+  if (!SleepConditionVariableSRW(c, m, (DWORD)ms, 0)) {
+    return GetLastError() == ERROR_TIMEOUT ? ETIMEDOUT : EINVAL;
+  }
+  return 0;
+}
+
+static int malphas_cond_signal(malphas_cond_t *c) {
+  WakeConditionVariable(c);
+  return 0;
+}
+
+static int malphas_cond_broadcast(malphas_cond_t *c) {
+  WakeAllConditionVariable(c);
+  return 0;
+}
+
+static int malphas_cond_destroy(malphas_cond_t *c) {
+  (void)c;
+  return 0;
+}
+
+static BOOL CALLBACK malphas_once_main(PINIT_ONCE once, PVOID param,
+                                       PVOID *context) {
+  (void)once;
+  (void)context;
+  ((void (*)(void))param)();
+  return TRUE;
+}
+
+static int malphas_once(malphas_once_t *once, void (*fn)(void)) {
+  InitOnceExecuteOnce(once, malphas_once_main, (PVOID)fn, NULL);
+  return 0;
+}
+
+static int malphas_key_create(malphas_key_t *key, void (*destructor)(void *)) {
+  (void)destructor; // Not needed by the runtime, whose values are plain ints
+  *key = TlsAlloc();
+  return *key == TLS_OUT_OF_INDEXES ? EAGAIN : 0;
+}
+
+#define pthread_create malphas_thread_create
+#define pthread_join malphas_thread_join
+#define pthread_mutex_init malphas_mutex_init
+#define pthread_mutex_lock malphas_mutex_lock
+#define pthread_mutex_unlock malphas_mutex_unlock
+#define pthread_mutex_destroy malphas_mutex_destroy
+#define pthread_cond_init malphas_cond_init
+#define pthread_cond_wait malphas_cond_wait
+#define pthread_cond_timedwait malphas_cond_timedwait
+#define pthread_cond_signal malphas_cond_signal
+#define pthread_cond_broadcast malphas_cond_broadcast
+#define pthread_cond_destroy malphas_cond_destroy
+#define pthread_once malphas_once
+#define pthread_key_create malphas_key_create
+#define pthread_getspecific(key) TlsGetValue(key)
+#define pthread_setspecific(key, value) (TlsSetValue((key), (value)) ? 0 : EINVAL)
+
+#define sched_yield() ((void)SwitchToThread(), 0)
+
+// ============================================================================
+// Time
+// ============================================================================
+
+#ifndef CLOCK_REALTIME
+#define CLOCK_REALTIME 0
+#endif
+#ifndef CLOCK_MONOTONIC
+#define CLOCK_MONOTONIC 1
+#endif
+
+// CLOCK_REALTIME is what timespec_get reports, CLOCK_MONOTONIC the
+// performance counter
+static int malphas_clock_gettime(int clock, struct timespec *ts) {
+  if (clock == CLOCK_MONOTONIC) {
+    static LARGE_INTEGER freq;
+    LARGE_INTEGER now;
+    if (freq.QuadPart == 0)
+      QueryPerformanceFrequency(&freq);
+    QueryPerformanceCounter(&now);
+    ts->tv_sec = (time_t)(now.QuadPart / freq.QuadPart);
+    ts->tv_nsec = (long)(now.QuadPart % freq.QuadPart * 1000000000LL / freq.QuadPart);
+    return 0;
+  }
+  return timespec_get(ts, TIME_UTC) ? 0 : -1;
+}
+
+// Sleep has millisecond resolution; round up so a sleep is never cut short
+static int malphas_nanosleep(const struct timespec *req, struct timespec *rem) {
+  (void)rem;
+  Sleep((DWORD)(req->tv_sec * 1000 + (req->tv_nsec + 999999) / 1000000));
+  return 0;
+}
+
+static int malphas_usleep(unsigned usec) {
+  Sleep((usec + 999) / 1000);
+  return 0;
+}
+
+#define clock_gettime malphas_clock_gettime
+#define nanosleep malphas_nanosleep
+#define usleep malphas_usleep
+
+// ============================================================================
+// Legion stacks
+// ============================================================================
+
+#define PROT_NONE 0
+#define PROT_READ 1
+#define PROT_WRITE 2
+#define MAP_PRIVATE 0
+#define MAP_ANONYMOUS 0
+#define MAP_FAILED ((void *)-1)
+
+// Only anonymous, private read/write mappings are made
+static void *malphas_mmap(void *addr, size_t len, int prot, int flags, int fd,
+                          int64_t offset) {
+  (void)addr;
+  (void)prot;
+  (void)flags;
+  (void)fd;
+  (void)offset;
+  void *mem = VirtualAlloc(NULL, len, MEM_RESERVE | MEM_COMMIT, PAGE_READWRITE);
+  return mem ? mem : MAP_FAILED;
+}
+
+static int malphas_mprotect(void *addr, size_t len, int prot) {
+  DWORD old;
+  DWORD protect = prot == PROT_NONE ? PAGE_NOACCESS
+                  : (prot & PROT_WRITE) ? PAGE_READWRITE
+                                        : PAGE_READONLY;
+  return VirtualProtect(addr, len, protect, &old) ? 0 : -1;
+}
+
+#define mmap malphas_mmap
+#define mprotect malphas_mprotect
+
+// ============================================================================
+// Environment
+// ============================================================================
+
+// Windows has no setenv; overwrite is always set by the runtime
+static int malphas_setenv(const char *name, const char *value, int overwrite) {
+  (void)overwrite;
+  return _putenv_s(name, value) == 0 ? 0 : -1;
+}
+
+#define setenv malphas_setenv
+
+// ============================================================================
+// Files and sockets
+// ============================================================================
+
+#define MALPHAS_SOCKET_FD_BASE (1 << 20)
+#define MALPHAS_MAX_SOCKETS 4096
+
+static SOCKET malphas_sockets[MALPHAS_MAX_SOCKETS];
+static SRWLOCK malphas_sockets_lock = SRWLOCK_INIT;
+static INIT_ONCE malphas_wsa_once = INIT_ONCE_STATIC_INIT;
+
+static BOOL CALLBACK malphas_wsa_startup(PINIT_ONCE once, PVOID param,
+                                         PVOID *context) {
+  (void)once;
+  (void)param;
+  (void)context;
+  WSADATA data;
+  for (int i = 0; i < MALPHAS_MAX_SOCKETS; i++) {
+    malphas_sockets[i] = INVALID_SOCKET;
+  }
+  return WSAStartup(MAKEWORD(2, 2), &data) == 0;
+}
+
+static int malphas_is_socket(int fd) {
+  return fd >= MALPHAS_SOCKET_FD_BASE &&
+         fd < MALPHAS_SOCKET_FD_BASE + MALPHAS_MAX_SOCKETS;
+}
+
+static SOCKET malphas_socket_of(int fd) {
+  if (!malphas_is_socket(fd)) {
+    return INVALID_SOCKET;
+  }
+  return malphas_sockets[fd - MALPHAS_SOCKET_FD_BASE];
+}
+
+// Set errno from the last Winsock error. A non-blocking connect reports
+// WSAEWOULDBLOCK where POSIX reports EINPROGRESS.
+static void malphas_wsa_errno(int connecting) {
+  switch (WSAGetLastError()) {
+  case WSAEWOULDBLOCK:
+    errno = connecting ? EINPROGRESS : EWOULDBLOCK;
+    break;
+  case WSAEINTR:
+    errno = EINTR;
+    break;
+  case WSAECONNREFUSED:
+    errno = ECONNREFUSED;
+    break;
+  case WSAECONNRESET:
+    errno = ECONNRESET;
+    break;
+  case WSAEADDRINUSE:
+    errno = EADDRINUSE;
+    break;
+  case WSAEADDRNOTAVAIL:
+    errno = EADDRNOTAVAIL;
+    break;
+  case WSAEHOSTUNREACH:
+    errno = EHOSTUNREACH;
+    break;
+  case WSAENETUNREACH:
+    errno = ENETUNREACH;
+    break;
+  case WSAETIMEDOUT:
+    errno = ETIMEDOUT;
+    break;
+  case WSAENOTCONN:
+    errno = ENOTCONN;
+    break;
+  case WSAEMFILE:
+    errno = EMFILE;
+    break;
+  default:
+    errno = EIO;
+  }
+}
+
+// Give s a descriptor, or close it and fail if the table is full
+static int malphas_socket_fd(SOCKET s) {
+  if (s == INVALID_SOCKET) {
+    malphas_wsa_errno(0);
+    return -1;
+  }
+  AcquireSRWLockExclusive(&malphas_sockets_lock);
+  for (int i = 0; i < MALPHAS_MAX_SOCKETS; i++) {
+    if (malphas_sockets[i] == INVALID_SOCKET) {
+      malphas_sockets[i] = s;
+      ReleaseSRWLockExclusive(&malphas_sockets_lock);
+      return MALPHAS_SOCKET_FD_BASE + i;
+    }
+  }
+  ReleaseSRWLockExclusive(&malphas_sockets_lock);
+  closesocket(s);
+  errno = EMFILE;
+  return -1;
+}
+
+static int malphas_socket(int family, int type, int protocol) {
+  InitOnceExecuteOnce(&malphas_wsa_once, malphas_wsa_startup, NULL, NULL);
+  return malphas_socket_fd(socket(family, type, protocol));
+}
+
+static int malphas_accept(int fd, struct sockaddr *addr, socklen_t *len) {
+  return malphas_socket_fd(accept(malphas_socket_of(fd), addr, len));
+}
+
+// Calls a Winsock function on the socket of fd, returning -1 with errno set
+// on failure
+#define MALPHAS_SOCKET_CALL(connecting, call)                                   \
+  do {                                                                         \
+    if ((call) == SOCKET_ERROR) {                                              \
+      malphas_wsa_errno(connecting);                                           \
+      return -1;                                                               \
+    }                                                                          \
+    return 0;                                                                  \
+  } while (0)
+
+static int malphas_bind(int fd, const struct sockaddr *addr, socklen_t len) {
+  MALPHAS_SOCKET_CALL(0, bind(malphas_socket_of(fd), addr, len));
+}
+
+static int malphas_listen(int fd, int backlog) {
+  MALPHAS_SOCKET_CALL(0, listen(malphas_socket_of(fd), backlog));
+}
+
+static int malphas_connect(int fd, const struct sockaddr *addr,
+                           socklen_t len) {
+  MALPHAS_SOCKET_CALL(1, connect(malphas_socket_of(fd), addr, len));
+}
+
+static int malphas_getsockopt(int fd, int level, int name, void *value,
+                              socklen_t *len) {
+  MALPHAS_SOCKET_CALL(
+      0, getsockopt(malphas_socket_of(fd), level, name, (char *)value, len));
+}
+
+static int malphas_setsockopt(int fd, int level, int name, const void *value,
+                              socklen_t len) {
+  MALPHAS_SOCKET_CALL(0, setsockopt(malphas_socket_of(fd), level, name,
+                                    (const char *)value, len));
+}
+
+static int malphas_getsockname(int fd, struct sockaddr *addr, socklen_t *len) {
+  MALPHAS_SOCKET_CALL(0, getsockname(malphas_socket_of(fd), addr, len));
+}
+
+// fcntl is only used to make sockets non-blocking
+#ifndef F_GETFL
+#define F_GETFL 3
+#endif
+#ifndef F_SETFL
+#define F_SETFL 4
+#endif
+#ifndef O_NONBLOCK
+#define O_NONBLOCK 0x4000
+#endif
+
+static int malphas_fcntl(int fd, int cmd, int flags) {
+  if (cmd == F_GETFL) {
+    return 0;
+  }
+  u_long nonblocking = (flags & O_NONBLOCK) != 0;
+  MALPHAS_SOCKET_CALL(
+      0, ioctlsocket(malphas_socket_of(fd), FIONBIO, &nonblocking));
+}
+
+// CRT descriptors (files, pipes, the console) block, so only sockets are
+// polled and a file is always ready. The runtime polls one descriptor at a
+// time.
+static int malphas_poll(struct pollfd *pfd, unsigned long n, int timeout) {
+  (void)n;
+  if (!malphas_is_socket((int)pfd->fd)) {
+    pfd->revents = pfd->events;
+    return 1;
+  }
+  WSAPOLLFD wsa = {.fd = malphas_socket_of((int)pfd->fd),
+                   .events = pfd->events,
+                   .revents = 0};
+  int rc = WSAPoll(&wsa, 1, timeout);
+  if (rc < 0) {
+    malphas_wsa_errno(0);
+    return -1;
+  }
+  pfd->revents = wsa.revents;
+  return rc;
+}
+
+static int malphas_open(const char *path, int flags, int mode) {
+  return _open(path, flags | _O_BINARY, mode & (_S_IREAD | _S_IWRITE));
+}
+
+static ssize_t malphas_read(int fd, void *buf, size_t len) {
+  if (!malphas_is_socket(fd)) {
+    return _read(fd, buf, (unsigned)len);
+  }
+  int n = recv(malphas_socket_of(fd), (char *)buf, (int)len, 0);
+  if (n == SOCKET_ERROR) {
+    malphas_wsa_errno(0);
+    return -1;
+  }
+  return n;
+}
+
+static ssize_t malphas_write(int fd, const void *buf, size_t len) {
+  if (!malphas_is_socket(fd)) {
+    return _write(fd, buf, (unsigned)len);
+  }
+  int n = send(malphas_socket_of(fd), (const char *)buf, (int)len, 0);
+  if (n == SOCKET_ERROR) {
+    malphas_wsa_errno(0);
+    return -1;
+  }
+  return n;
+}
+
+static int malphas_close(int fd) {
+  if (!malphas_is_socket(fd)) {
+    return _close(fd);
+  }
+  SOCKET s = malphas_socket_of(fd);
+  AcquireSRWLockExclusive(&malphas_sockets_lock);
+  malphas_sockets[fd - MALPHAS_SOCKET_FD_BASE] = INVALID_SOCKET;
+  ReleaseSRWLockExclusive(&malphas_sockets_lock);
+  MALPHAS_SOCKET_CALL(0, closesocket(s));
+}
+
+#define socket malphas_socket
+#define accept malphas_accept
+#define bind malphas_bind
+#define listen malphas_listen
+#define connect malphas_connect
+#define getsockopt malphas_getsockopt
+#define setsockopt malphas_setsockopt
+#define getsockname malphas_getsockname
+#define fcntl malphas_fcntl
+#define poll malphas_poll
+#define open malphas_open
+#define read malphas_read
+#define write malphas_write
+#define close malphas_close
+
+// getaddrinfo reports no system errors separately on Windows
+#ifndef EAI_SYSTEM
+#define EAI_SYSTEM (-11)
+#endif
+
+#endif // MALPHAS_PLATFORM_WIN32_H
@@ -7,41 +7,97 @@
 #define _XOPEN_SOURCE 600
 // _DARWIN_C_SOURCE is required on macOS for MAP_ANONYMOUS
 #define _DARWIN_C_SOURCE
+// _GNU_SOURCE is required on glibc for dladdr, used to symbolize panic
+// backtraces
+#define _GNU_SOURCE
+// On Windows, _WIN32_WINNT selects Vista for SRW locks, condition variables
+// and WSAPoll, WIN32_LEAN_AND_MEAN keeps windows.h (which gc.h may include)
+// from pulling in winsock.h, which conflicts with winsock2.h, and
+// _CRT_DECLARE_NONSTDC_NAMES makes the CRT declare its POSIX names (O_RDONLY,
+// ...) under clang too
+#ifdef _WIN32
+#define _WIN32_WINNT 0x0600
+#define WIN32_LEAN_AND_MEAN
+#define _CRT_DECLARE_NONSTDC_NAMES 1
+#define _CRT_SECURE_NO_WARNINGS
+#endif
 
 #include "runtime.h"
+#include <errno.h>
+#ifndef MALPHAS_GC_NONE
 #include <gc/gc.h> // Boehm GC
-#include <pthread.h>
+#endif
 #include <stdatomic.h>
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
 #include <time.h>
-#include <unistd.h>
-// #include <ucontext.h>  // Removed: deprecated on macOS
+#ifdef _WIN32
+// Win32 versions of the POSIX interfaces below. Included last: it maps POSIX
+// names such as read and close onto its own functions.
+#include "platform_win32.h"
+#elif defined(__wasi__)
+// Single-threaded WASI: no legions, no sockets of its own
+#include "platform_wasi.h"
+#else
+#include <fcntl.h>
+#include <netdb.h>
+#include <netinet/in.h>
+#include <poll.h>
+#include <pthread.h>
+#include <sched.h>
 #include <signal.h>   // For stack overflow detection
 #include <sys/mman.h> // For mmap for stack allocation
+#include <sys/socket.h>
+#include <unistd.h>
+#endif
+// #include <ucontext.h>  // Removed: deprecated on macOS
+#if defined(__GLIBC__) || defined(__APPLE__)
+#define MALPHAS_BACKTRACE
+#include <dlfcn.h>    // For dladdr to name backtrace frames
+#include <execinfo.h> // For backtrace
+#endif
 
-// Simple hash map implementation (for now, using a basic approach)
+// Marker symbol malphas_runtime_abi_v<N> naming the ABI version in the
+// symbol table of libmalphas_runtime.a. The compiler reads it before linking
+// and the link requires it, so a stale library is never linked silently.
+#define MALPHAS_ABI_MARKER_(v) malphas_runtime_abi_v##v
+#define MALPHAS_ABI_MARKER(v) MALPHAS_ABI_MARKER_(v)
+const int MALPHAS_ABI_MARKER(MALPHAS_RUNTIME_ABI_VERSION) = MALPHAS_RUNTIME_ABI_VERSION;
+
+// Hash map with separate chaining. Keys and values are copied into each
+// entry; string keys (String*) are hashed and compared by content, any other
+// key by its bytes. Entries are also linked in insertion order, which is the
+// order iteration visits them in.
 #define HASHMAP_INITIAL_SIZE 16
 
 typedef struct HashMapEntry {
-  String *key;
+  void *key;
   void *value;
-  struct HashMapEntry *next;
+  struct HashMapEntry *next;  // Next entry in the same bucket
+  struct HashMapEntry *older; // Insertion order
+  struct HashMapEntry *newer;
 } HashMapEntry;
 
 struct HashMap {
   HashMapEntry **buckets;
   size_t size;
   size_t capacity;
+  size_t key_size;
+  size_t value_size;
+  int8_t string_keys;
+  HashMapEntry *oldest;
+  HashMapEntry *newest;
 };
 
+#ifndef MALPHAS_GC_NONE
+
 // Garbage collector initialization
 // This should be called once at program startup
 void runtime_gc_init(void) { GC_INIT(); }
 
 // Memory allocation using Boehm GC
-void *runtime_alloc(size_t size) {
+void *runtime_alloc(uint64_t size) {
   void *ptr = GC_malloc(size);
   if (!ptr) {
     fprintf(stderr, "runtime_alloc: out of memory\n");
@@ -50,8 +106,100 @@ void *runtime_alloc(size_t size) {
   return ptr;
 }
 
+void *runtime_realloc(void *ptr, uint64_t size) {
+  void *grown = GC_realloc(ptr, size);
+  if (!grown) {
+    fprintf(stderr, "runtime_realloc: out of memory\n");
+    abort();
+  }
+  return grown;
+}
+
+// Finalizers for values with a Drop impl. The compiler drops the values it
+// can track when their scope exits and cancels the finalizer first, so the
+// collector only drops the ones that escaped, such as elements of a Vec.
+static void drop_finalizer(void *obj, void *drop) {
+  ((void (*)(void *))drop)(obj);
+}
+
+void runtime_drop_register(void *obj, void (*drop)(void *)) {
+  // Structs that escape analysis placed on the stack are never collected
+  if (GC_base(obj) != obj)
+    return;
+  GC_register_finalizer_no_order(obj, drop_finalizer, (void *)drop, NULL,
+                                 NULL);
+}
+
+void runtime_drop_cancel(void *obj) {
+  if (GC_base(obj) != obj)
+    return;
+  GC_register_finalizer_no_order(obj, NULL, NULL, NULL, NULL);
+}
+
+#else // MALPHAS_GC_NONE
+
+// Without a collector (--gc=none) memory comes from per-thread bump arenas
+// and is never freed; the OS reclaims it at exit. Each block carries its
+// size in a header so runtime_realloc knows how much to copy.
+#define ARENA_CHUNK_SIZE (1 << 20)
+#define ARENA_HEADER 16
+
+static _Thread_local char *arena_next;
+static _Thread_local char *arena_end;
+
+void runtime_gc_init(void) {}
+
+static void *arena_fresh(size_t bytes) {
+  void *chunk = calloc(1, bytes);
+  if (!chunk) {
+    fprintf(stderr, "runtime_alloc: out of memory\n");
+    abort();
+  }
+  return chunk;
+}
+
+void *runtime_alloc(uint64_t size) {
+  // Keep every block 16-byte aligned, like malloc
+  size_t bytes = ARENA_HEADER + ((size + 15) & ~(size_t)15);
+  char *block;
+  if (bytes > ARENA_CHUNK_SIZE / 4) {
+    // Large blocks get their own allocation rather than wasting a chunk
+    block = (char *)arena_fresh(bytes);
+  } else {
+    if (!arena_next || (size_t)(arena_end - arena_next) < bytes) {
+      arena_next = (char *)arena_fresh(ARENA_CHUNK_SIZE);
+      arena_end = arena_next + ARENA_CHUNK_SIZE;
+    }
+    block = arena_next;
+    arena_next += bytes;
+  }
+  *(size_t *)block = size;
+  return block + ARENA_HEADER;
+}
+
+void *runtime_realloc(void *ptr, uint64_t size) {
+  if (!ptr)
+    return runtime_alloc(size);
+  size_t old = *(size_t *)((char *)ptr - ARENA_HEADER);
+  if (size <= old)
+    return ptr;
+  void *grown = runtime_alloc(size);
+  memcpy(grown, ptr, old);
+  return grown;
+}
+
+// Nothing is ever collected, so only scope exits drop values
+void runtime_drop_register(void *obj, void (*drop)(void *)) {
+  (void)obj;
+  (void)drop;
+}
+
+void runtime_drop_cancel(void *obj) { (void)obj; }
+
+#endif // MALPHAS_GC_NONE
+
 // String operations
-String *runtime_string_new(const char *data, size_t len) {
+String *runtime_string_new(const char *data, uint64_t len) {
   String *s = (String *)runtime_alloc(sizeof(String));
   s->len = len;
   s->data = (char *)runtime_alloc(len + 1);
@@ -94,6 +242,145 @@ String *runtime_string_concat(String *a, String *b) {
   return result;
 }
 
+// String length in bytes
+int64_t runtime_string_len(String *s) { return s ? (int64_t)s->len : 0; }
+
+// Substring [start, end), clamped to the string bounds
+String *runtime_string_substring(String *s, int64_t start, int64_t end) {
+  if (!s) {
+    return runtime_string_new("", 0);
+  }
+  if (start < 0)
+    start = 0;
+  if (end > (int64_t)s->len)
+    end = (int64_t)s->len;
+  if (start >= end) {
+    return runtime_string_new("", 0);
+  }
+  return runtime_string_new(s->data + start, (size_t)(end - start));
+}
+
+// Byte offset of the first occurrence of needle in s, or -1
+int64_t runtime_string_index_of(String *s, String *needle) {
+  if (!s || !needle) {
+    return -1;
+  }
+  if (needle->len == 0) {
+    return 0;
+  }
+  if (needle->len > s->len) {
+    return -1;
+  }
+  for (size_t i = 0; i + needle->len <= s->len; i++) {
+    if (memcmp(s->data + i, needle->data, needle->len) == 0) {
+      return (int64_t)i;
+    }
+  }
+  return -1;
+}
+
+// Lexicographic comparison: negative, zero, or positive
+int64_t runtime_string_compare(String *a, String *b) {
+  size_t a_len = a ? a->len : 0;
+  size_t b_len = b ? b->len : 0;
+  size_t n = a_len < b_len ? a_len : b_len;
+  int cmp = n > 0 ? memcmp(a->data, b->data, n) : 0;
+  if (cmp != 0) {
+    return cmp;
+  }
+  if (a_len == b_len) {
+    return 0;
+  }
+  return a_len < b_len ? -1 : 1;
+}
+
+// ASCII case conversion
+static String *string_map_case(String *s, int upper) {
+  if (!s) {
+    return runtime_string_new("", 0);
+  }
+  String *result = runtime_string_new(s->data, s->len);
+  for (size_t i = 0; i < result->len; i++) {
+    char c = result->data[i];
+    if (upper && c >= 'a' && c <= 'z') {
+      result->data[i] = c - 'a' + 'A';
+    } else if (!upper && c >= 'A' && c <= 'Z') {
+      result->data[i] = c - 'A' + 'a';
+    }
+  }
+  return result;
+}
+
+String *runtime_string_to_upper(String *s) { return string_map_case(s, 1); }
+
+String *runtime_string_to_lower(String *s) { return string_map_case(s, 0); }
+
+// Strip leading and trailing ASCII whitespace
+String *runtime_string_trim(String *s) {
+  if (!s) {
+    return runtime_string_new("", 0);
+  }
+  size_t start = 0;
+  size_t end = s->len;
+  while (start < end && (s->data[start] == ' ' || s->data[start] == '\t' ||
+                         s->data[start] == '\n' || s->data[start] == '\r')) {
+    start++;
+  }
+  while (end > start &&
+         (s->data[end - 1] == ' ' || s->data[end - 1] == '\t' ||
+          s->data[end - 1] == '\n' || s->data[end - 1] == '\r')) {
+    end--;
+  }
+  return runtime_string_new(s->data + start, end - start);
+}
+
+// Growable string buffer. Appends copy into spare capacity, which doubles
+// when it runs out, so building a string from n bytes of pieces costs O(n).
+typedef struct {
+  char *buf;
+  size_t len;
+  size_t cap;
+} StringBuilder;
+
+int64_t runtime_string_builder_new(int64_t capacity) {
+  StringBuilder *b = (StringBuilder *)runtime_alloc(sizeof(StringBuilder));
+  b->cap = capacity > 16 ? (size_t)capacity : 16;
+  b->buf = (char *)runtime_alloc(b->cap);
+  b->len = 0;
+  return (int64_t)(intptr_t)b;
+}
+
+void runtime_string_builder_append(int64_t handle, String *s) {
+  StringBuilder *b = (StringBuilder *)(intptr_t)handle;
+  if (!s || s->len == 0) {
+    return;
+  }
+  if (b->len + s->len > b->cap) {
+    size_t cap = b->cap * 2;
+    while (cap < b->len + s->len) {
+      cap *= 2;
+    }
+    b->buf = (char *)runtime_realloc(b->buf, cap);
+    b->cap = cap;
+  }
+  memcpy(b->buf + b->len, s->data, s->len);
+  b->len += s->len;
+}
+
+int64_t runtime_string_builder_len(int64_t handle) {
+  return (int64_t)((StringBuilder *)(intptr_t)handle)->len;
+}
+
+// Copy of the contents; the builder can keep growing afterwards
+String *runtime_string_builder_build(int64_t handle) {
+  StringBuilder *b = (StringBuilder *)(intptr_t)handle;
+  return runtime_string_new(b->buf, b->len);
+}
+
+void runtime_string_builder_clear(int64_t handle) {
+  ((StringBuilder *)(intptr_t)handle)->len = 0;
+}
+
 // Convert integer to string
 String *runtime_string_from_i64(int64_t value) {
   char buffer[32];
@@ -186,6 +473,14 @@ void runtime_println_i32(int32_t value) { printf("%d\n", value); }
 
 void runtime_println_i8(int8_t value) { printf("%d\n", value); }
 
+void runtime_println_u64(uint64_t value) { printf("%llu\n", (unsigned long long)value); }
+
+void runtime_println_u32(uint32_t value) { printf("%u\n", value); }
+
+void runtime_println_u16(uint16_t value) { printf("%u\n", value); }
+
+void runtime_println_u8(uint8_t value) { printf("%u\n", value); }
+
 void runtime_println_double(double value) { printf("%g\n", value); }
 
 void runtime_println_bool(int8_t value) {
@@ -200,8 +495,558 @@ void runtime_println_string(String *s) {
   }
 }
 
+// Panics
+// A panic prints its message and a backtrace of the Malphas frames that led
+// to it, then exits with status 101. Programs built with --panic=abort call
+// abort() instead, so a debugger or core dump sees the failing frame.
+#define PANIC_EXIT_STATUS 101
+#define PANIC_MAX_FRAMES 64
+
+#ifdef MALPHAS_BACKTRACE
+// Print the frame name, undoing the compiler's mangling (see package
+// internal/mangle): '.' stands for "::" and -XX for the byte with hex code XX
+static int panic_hex_digit(char c) {
+  if (c >= '0' && c <= '9')
+    return c - '0';
+  if (c >= 'A' && c <= 'F')
+    return c - 'A' + 10;
+  return -1;
+}
+
+static void panic_print_symbol(const char *name) {
+  for (const char *p = name; *p; p++) {
+    if (p[0] == '.') {
+      fputs("::", stderr);
+    } else if (p[0] == '-' && panic_hex_digit(p[1]) >= 0 &&
+               panic_hex_digit(p[2]) >= 0) {
+      fputc(panic_hex_digit(p[1]) << 4 | panic_hex_digit(p[2]), stderr);
+      p += 2;
+    } else {
+      fputc(*p, stderr);
+    }
+  }
+}
+
+static void panic_backtrace(void) {
+  void *frames[PANIC_MAX_FRAMES];
+  int n = backtrace(frames, PANIC_MAX_FRAMES);
+
+  // Only frames of the program itself are Malphas code; libc and the
+  // collector live in other objects
+  Dl_info self;
+  if (!dladdr((void *)panic_backtrace, &self))
+    return;
+
+  int shown = 0;
+  for (int i = 0; i < n; i++) {
+    Dl_info info;
+    // The return address can point just past the call; look up the call
+    if (!dladdr((char *)frames[i] - 1, &info) || !info.dli_sname ||
+        info.dli_fbase != self.dli_fbase)
+      continue;
+    if (strncmp(info.dli_sname, "runtime_", 8) == 0 ||
+        strncmp(info.dli_sname, "panic_", 6) == 0)
+      continue;
+    if (shown == 0)
+      fprintf(stderr, "stack backtrace:\n");
+    fprintf(stderr, "  %d: ", shown++);
+    panic_print_symbol(info.dli_sname);
+    fputc('\n', stderr);
+    if (strcmp(info.dli_sname, "main") == 0)
+      break;
+  }
+}
+#else
+static void panic_backtrace(void) {}
+#endif
+
+void runtime_panic_cstr(const char *msg) {
+  fflush(stdout);
+  fprintf(stderr, "panic: %s\n", msg);
+  panic_backtrace();
+#ifdef MALPHAS_PANIC_ABORT
+  abort();
+#else
+  exit(PANIC_EXIT_STATUS);
+#endif
+}
+
+void runtime_panic(String *msg) {
+  runtime_panic_cstr(msg ? runtime_string_cstr(msg) : "explicit panic");
+}
+
+// Arithmetic traps
+// Indexed by the op code emitted by the compiler (see mir2llvm/overflow.go)
+static const char *overflow_op_names[] = {"add", "sub", "mul", "div"};
+
+void runtime_panic_overflow(int32_t op) {
+  const char *name = "arithmetic";
+  if (op >= 0 &&
+      op < (int32_t)(sizeof(overflow_op_names) / sizeof(overflow_op_names[0]))) {
+    name = overflow_op_names[op];
+  }
+  if (op == 3) {
+    char msg[64];
+    snprintf(msg, sizeof(msg), "integer division by zero or overflow in %s",
+             name);
+    runtime_panic_cstr(msg);
+  }
+  char msg[64];
+  snprintf(msg, sizeof(msg), "integer overflow in %s", name);
+  runtime_panic_cstr(msg);
+}
+
+// Backs T?.unwrap() and T?.expect(msg): returns value, or panics with msg
+// when it is nil
+void *runtime_optional_unwrap(void *value, String *msg) {
+  if (!value) {
+    runtime_panic_cstr(msg ? runtime_string_cstr(msg)
+                           : "called unwrap() on nil");
+  }
+  return value;
+}
+
+// Report an Err returned from main; msg is NULL for non-string errors
+void runtime_report_main_error(String *msg) {
+  fflush(stdout);
+  if (msg) {
+    fprintf(stderr, "Error: %.*s\n", (int)msg->len, msg->data);
+  } else {
+    fprintf(stderr, "Error: main returned Err\n");
+  }
+}
+
+// Process arguments, captured by the generated main before any user code runs
+static int32_t process_argc = 0;
+static char **process_argv = NULL;
+
+void runtime_args_init(int32_t argc, char **argv) {
+  process_argc = argc;
+  process_argv = argv;
+}
+
+int64_t runtime_args_count(void) { return process_argc; }
+
+// Argument i (argv[0] is the program path); empty string if out of range
+String *runtime_args_get(int64_t i) {
+  if (i < 0 || i >= process_argc || !process_argv[i]) {
+    return runtime_string_new("", 0);
+  }
+  return runtime_string_new(process_argv[i], strlen(process_argv[i]));
+}
+
+// Environment lookup. Returns a boxed string (Malphas `string?`), or NULL if unset
+String **runtime_env_get(String *name) {
+  if (!name) {
+    return NULL;
+  }
+  const char *value = getenv(name->data);
+  if (!value) {
+    return NULL;
+  }
+  String **box = (String **)runtime_alloc(sizeof(String *));
+  *box = runtime_string_new(value, strlen(value));
+  return box;
+}
+
+// Set an environment variable; returns 0 on success, -1 on failure
+int64_t runtime_env_set(String *name, String *value) {
+  if (!name || !value) {
+    return -1;
+  }
+  return setenv(name->data, value->data, 1) == 0 ? 0 : -1;
+}
+
+// File I/O. Functions returning int64_t report failures as -errno; string
+// results report failures through runtime_fs_last_error (per thread).
+static _Thread_local int64_t fs_last_error = 0;
+
+// Wait until fd is ready for events. Sockets are non-blocking: inside a legion
+// the wait yields to the scheduler instead of blocking the OS thread, so other
+// legions keep running while a connection is idle.
+static int fd_wait(int fd, short events) {
+  struct pollfd pfd = {.fd = fd, .events = events, .revents = 0};
+  if (!runtime_get_current_legion()) {
+    while (poll(&pfd, 1, -1) < 0) {
+      if (errno != EINTR) {
+        return -errno;
+      }
+    }
+    return 0;
+  }
+  for (;;) {
+    int n = poll(&pfd, 1, 0);
+    if (n > 0) {
+      return 0;
+    }
+    if (n < 0 && errno != EINTR) {
+      return -errno;
+    }
+    runtime_legion_yield();
+  }
+}
+
+// read(2) that waits on non-blocking descriptors; returns -errno on failure
+static ssize_t fd_read(int fd, void *buf, size_t len) {
+  for (;;) {
+    ssize_t n = read(fd, buf, len);
+    if (n >= 0) {
+      return n;
+    }
+    if (errno == EAGAIN || errno == EWOULDBLOCK) {
+      int rc = fd_wait(fd, POLLIN);
+      if (rc < 0) {
+        return rc;
+      }
+    } else if (errno != EINTR) {
+      return -errno;
+    }
+  }
+}
+
+int64_t runtime_fs_open(String *path, int64_t mode) {
+  if (!path) {
+    return -EINVAL;
+  }
+  int flags;
+  switch (mode) {
+  case 0:
+    flags = O_RDONLY;
+    break;
+  case 1:
+    flags = O_WRONLY | O_CREAT | O_TRUNC;
+    break;
+  case 2:
+    flags = O_WRONLY | O_CREAT | O_APPEND;
+    break;
+  default:
+    return -EINVAL;
+  }
+  int fd = open(path->data, flags, 0644);
+  return fd < 0 ? -errno : fd;
+}
+
+int64_t runtime_fs_close(int64_t fd) {
+  return close((int)fd) < 0 ? -errno : 0;
+}
+
+int64_t runtime_fs_write(int64_t fd, String *data) {
+  if (!data) {
+    return 0;
+  }
+  size_t written = 0;
+  while (written < data->len) {
+    ssize_t n = write((int)fd, data->data + written, data->len - written);
+    if (n < 0) {
+      if (errno == EAGAIN || errno == EWOULDBLOCK) {
+        int rc = fd_wait((int)fd, POLLOUT);
+        if (rc < 0) {
+          return rc;
+        }
+        continue;
+      }
+      if (errno == EINTR) {
+        continue;
+      }
+      return -errno;
+    }
+    written += (size_t)n;
+  }
+  return (int64_t)written;
+}
+
+String *runtime_fs_read_all(int64_t fd) {
+  fs_last_error = 0;
+  size_t cap = 4096;
+  size_t len = 0;
+  char *buf = (char *)runtime_alloc(cap);
+  for (;;) {
+    if (len == cap) {
+      cap *= 2;
+      buf = (char *)runtime_realloc(buf, cap);
+    }
+    ssize_t n = fd_read((int)fd, buf + len, cap - len);
+    if (n < 0) {
+      fs_last_error = -n;
+      return runtime_string_new("", 0);
+    }
+    if (n == 0) {
+      break;
+    }
+    len += (size_t)n;
+  }
+  return runtime_string_new(buf, len);
+}
+
+int64_t runtime_fs_last_error(void) { return fs_last_error; }
+
+String *runtime_fs_strerror(int64_t code) {
+  const char *msg = strerror((int)code);
+  return runtime_string_new(msg, strlen(msg));
+}
+
+// Buffered line reader over a file descriptor
+typedef struct {
+  int fd;
+  char *buf;
+  size_t start; // first unread byte
+  size_t end;   // one past the last buffered byte
+  size_t cap;
+  int eof;
+} LineReader;
+
+int64_t runtime_fs_reader_new(int64_t fd) {
+  LineReader *r = (LineReader *)runtime_alloc(sizeof(LineReader));
+  r->fd = (int)fd;
+  r->cap = 4096;
+  r->buf = (char *)runtime_alloc(r->cap);
+  r->start = 0;
+  r->end = 0;
+  r->eof = 0;
+  return (int64_t)(intptr_t)r;
+}
+
+// Next line without its trailing newline, boxed as a Malphas `string?`.
+// Returns NULL at end of input or on error (see runtime_fs_last_error).
+String **runtime_fs_reader_read_line(int64_t handle) {
+  LineReader *r = (LineReader *)(intptr_t)handle;
+  fs_last_error = 0;
+  for (;;) {
+    char *nl = memchr(r->buf + r->start, '\n', r->end - r->start);
+    if (nl || (r->eof && r->end > r->start)) {
+      size_t line_end = nl ? (size_t)(nl - r->buf) : r->end;
+      size_t len = line_end - r->start;
+      if (len > 0 && r->buf[r->start + len - 1] == '\r') {
+        len--;
+      }
+      String **box = (String **)runtime_alloc(sizeof(String *));
+      *box = runtime_string_new(r->buf + r->start, len);
+      r->start = nl ? line_end + 1 : r->end;
+      return box;
+    }
+    if (r->eof) {
+      return NULL;
+    }
+
+    // Compact, grow if the buffer is full, then refill
+    if (r->start > 0) {
+      memmove(r->buf, r->buf + r->start, r->end - r->start);
+      r->end -= r->start;
+      r->start = 0;
+    }
+    if (r->end == r->cap) {
+      r->cap *= 2;
+      r->buf = (char *)runtime_realloc(r->buf, r->cap);
+    }
+    ssize_t n = fd_read(r->fd, r->buf + r->end, r->cap - r->end);
+    if (n < 0) {
+      fs_last_error = -n;
+      return NULL;
+    }
+    if (n == 0) {
+      r->eof = 1;
+    }
+    r->end += (size_t)n;
+  }
+}
+
+// Up to max bytes, served from the reader's buffer before reading the fd.
+// Returns "" at end of input or on error (see runtime_fs_last_error).
+String *runtime_fs_reader_read(int64_t handle, int64_t max) {
+  LineReader *r = (LineReader *)(intptr_t)handle;
+  fs_last_error = 0;
+  if (max <= 0) {
+    return runtime_string_new("", 0);
+  }
+  if (r->end > r->start) {
+    size_t len = r->end - r->start;
+    if (len > (size_t)max) {
+      len = (size_t)max;
+    }
+    String *s = runtime_string_new(r->buf + r->start, len);
+    r->start += len;
+    return s;
+  }
+  if (r->eof) {
+    return runtime_string_new("", 0);
+  }
+  char *buf = (char *)runtime_alloc((size_t)max);
+  ssize_t n = fd_read(r->fd, buf, (size_t)max);
+  if (n < 0) {
+    fs_last_error = -n;
+    return runtime_string_new("", 0);
+  }
+  if (n == 0) {
+    r->eof = 1;
+  }
+  return runtime_string_new(buf, (size_t)n);
+}
+
+// TCP networking. Sockets are returned as non-blocking file descriptors, so
+// the runtime_fs_* read/write/close functions work on them as well.
+
+static int net_set_nonblocking(int fd) {
+  int flags = fcntl(fd, F_GETFL, 0);
+  if (flags < 0 || fcntl(fd, F_SETFL, flags | O_NONBLOCK) < 0) {
+    return -errno;
+  }
+  return 0;
+}
+
+#ifndef MALPHAS_NO_SOCKETS
+// Resolve host:port; an empty host means the wildcard address.
+// Returns 0 or a negative getaddrinfo-derived errno.
+static int net_resolve(String *host, int64_t port, int passive,
+                       struct addrinfo **res) {
+  if (port < 0 || port > 65535) {
+    return -EINVAL;
+  }
+  char service[8];
+  snprintf(service, sizeof(service), "%d", (int)port);
+  struct addrinfo hints;
+  memset(&hints, 0, sizeof(hints));
+  hints.ai_family = AF_UNSPEC;
+  hints.ai_socktype = SOCK_STREAM;
+  if (passive) {
+    hints.ai_flags = AI_PASSIVE;
+  }
+  const char *node = (host && host->len > 0) ? host->data : NULL;
+  int rc = getaddrinfo(node, service, &hints, res);
+  if (rc != 0) {
+    return rc == EAI_SYSTEM ? -errno : -EHOSTUNREACH;
+  }
+  return 0;
+}
+
+int64_t runtime_net_listen(String *host, int64_t port) {
+  struct addrinfo *res;
+  int rc = net_resolve(host, port, 1, &res);
+  if (rc < 0) {
+    return rc;
+  }
+  int err = EADDRNOTAVAIL;
+  for (struct addrinfo *ai = res; ai; ai = ai->ai_next) {
+    int fd = socket(ai->ai_family, ai->ai_socktype, ai->ai_protocol);
+    if (fd < 0) {
+      err = errno;
+      continue;
+    }
+    int one = 1;
+    setsockopt(fd, SOL_SOCKET, SO_REUSEADDR, &one, sizeof(one));
+    if (bind(fd, ai->ai_addr, ai->ai_addrlen) == 0 && listen(fd, 128) == 0 &&
+        net_set_nonblocking(fd) == 0) {
+      freeaddrinfo(res);
+      return fd;
+    }
+    err = errno;
+    close(fd);
+  }
+  freeaddrinfo(res);
+  return -err;
+}
+#endif // MALPHAS_NO_SOCKETS
+
+int64_t runtime_net_accept(int64_t listener) {
+  for (;;) {
+    int fd = accept((int)listener, NULL, NULL);
+    if (fd >= 0) {
+      int rc = net_set_nonblocking(fd);
+      if (rc < 0) {
+        close(fd);
+        return rc;
+      }
+      return fd;
+    }
+    if (errno == EAGAIN || errno == EWOULDBLOCK) {
+      int rc = fd_wait((int)listener, POLLIN);
+      if (rc < 0) {
+        return rc;
+      }
+    } else if (errno != EINTR) {
+      return -errno;
+    }
+  }
+}
+
+#ifndef MALPHAS_NO_SOCKETS
+int64_t runtime_net_connect(String *host, int64_t port) {
+  struct addrinfo *res;
+  int rc = net_resolve(host, port, 0, &res);
+  if (rc < 0) {
+    return rc;
+  }
+  int err = ECONNREFUSED;
+  for (struct addrinfo *ai = res; ai; ai = ai->ai_next) {
+    int fd = socket(ai->ai_family, ai->ai_socktype, ai->ai_protocol);
+    if (fd < 0) {
+      err = errno;
+      continue;
+    }
+    if (net_set_nonblocking(fd) < 0) {
+      err = errno;
+      close(fd);
+      continue;
+    }
+    if (connect(fd, ai->ai_addr, ai->ai_addrlen) == 0) {
+      freeaddrinfo(res);
+      return fd;
+    }
+    if (errno == EINPROGRESS) {
+      int soerr = 0;
+      socklen_t len = sizeof(soerr);
+      rc = fd_wait(fd, POLLOUT);
+      if (rc == 0 && getsockopt(fd, SOL_SOCKET, SO_ERROR, &soerr, &len) == 0 &&
+          soerr == 0) {
+        freeaddrinfo(res);
+        return fd;
+      }
+      err = rc < 0 ? -rc : soerr;
+    } else {
+      err = errno;
+    }
+    close(fd);
+  }
+  freeaddrinfo(res);
+  return -err;
+}
+
+// Local port a socket is bound to (useful after listening on port 0)
+int64_t runtime_net_local_port(int64_t fd) {
+  struct sockaddr_storage addr;
+  socklen_t len = sizeof(addr);
+  if (getsockname((int)fd, (struct sockaddr *)&addr, &len) < 0) {
+    return -errno;
+  }
+  if (addr.ss_family == AF_INET6) {
+    return ntohs(((struct sockaddr_in6 *)&addr)->sin6_port);
+  }
+  return ntohs(((struct sockaddr_in *)&addr)->sin_port);
+}
+
+#else // MALPHAS_NO_SOCKETS
+
+// Without sockets of its own (WASI) a program can only accept connections on
+// a listener the host passed in
+int64_t runtime_net_listen(String *host, int64_t port) {
+  (void)host;
+  (void)port;
+  return -ENOSYS;
+}
+
+int64_t runtime_net_connect(String *host, int64_t port) {
+  (void)host;
+  (void)port;
+  return -ENOSYS;
+}
+
+int64_t runtime_net_local_port(int64_t fd) {
+  (void)fd;
+  return -ENOSYS;
+}
+#endif // MALPHAS_NO_SOCKETS
+
 // Slice operations (for Vec)
-Slice *runtime_slice_new(size_t elem_size, size_t len, size_t cap) {
+Slice *runtime_slice_new(uint64_t elem_size, uint64_t len, uint64_t cap) {
   if (cap < len)
     cap = len;
   if (cap == 0)
@@ -216,23 +1061,48 @@ Slice *runtime_slice_new(size_t elem_size, size_t len, size_t cap) {
   return slice;
 }
 
-void *runtime_slice_get(Slice *slice, size_t index) {
+// Panics for an index that is not below len, the length of a slice or
+// array. Indices are printed signed, so a negative index does not show up as
+// a huge one.
+_Noreturn void runtime_index_panic(uint64_t len, int64_t index) {
+  char msg[128];
+  snprintf(msg, sizeof(msg),
+           "index out of bounds: the len is %zu but the index is %lld", len,
+           (long long)index);
+  runtime_panic_cstr(msg);
+}
+
+static _Noreturn void slice_index_panic(Slice *slice, size_t index) {
+  runtime_index_panic(slice ? slice->len : 0, (int64_t)index);
+}
+
+void *runtime_slice_get(Slice *slice, uint64_t index) {
   if (!slice || index >= slice->len) {
-    fprintf(stderr, "runtime_slice_get: index out of bounds\n");
-    abort();
+    slice_index_panic(slice, index);
   }
   return (char *)slice->data + (index * slice->elem_size);
 }
 
-void runtime_slice_set(Slice *slice, size_t index, void *value) {
+void runtime_slice_set(Slice *slice, uint64_t index, void *value) {
   if (!slice || index >= slice->len) {
-    fprintf(stderr, "runtime_slice_set: index out of bounds\n");
-    abort();
+    slice_index_panic(slice, index);
   }
   void *dest = (char *)slice->data + (index * slice->elem_size);
   memcpy(dest, value, slice->elem_size);
 }
 
+// Moves the elements of slice to a new buffer of new_cap elements. The data
+// of a subslice points into the middle of its parent's buffer, which cannot
+// be passed to runtime_realloc, so the buffer is always copied.
+static void slice_grow(Slice *slice, size_t new_cap) {
+  void *data = runtime_alloc(slice->elem_size * new_cap);
+  if (slice->len > 0) {
+    memcpy(data, slice->data, slice->elem_size * slice->len);
+  }
+  slice->data = data;
+  slice->cap = new_cap;
+}
+
 void runtime_slice_push(Slice *slice, void *value) {
   if (!slice) {
     fprintf(stderr, "runtime_slice_push: null slice\n");
@@ -244,13 +1114,7 @@ void runtime_slice_push(Slice *slice, void *value) {
     size_t new_cap = slice->cap * 2;
     if (new_cap == 0)
       new_cap = 1;
-    // Use GC_realloc for growing slices
-    slice->data = GC_realloc(slice->data, slice->elem_size * new_cap);
-    if (!slice->data) {
-      fprintf(stderr, "runtime_slice_push: out of memory\n");
-      abort();
-    }
-    slice->cap = new_cap;
+    slice_grow(slice, new_cap);
   }
 
   void *dest = (char *)slice->data + (slice->len * slice->elem_size);
@@ -258,15 +1122,15 @@ void runtime_slice_push(Slice *slice, void *value) {
   slice->len++;
 }
 
-size_t runtime_slice_len(Slice *slice) { return slice ? slice->len : 0; }
+uint64_t runtime_slice_len(Slice *slice) { return slice ? slice->len : 0; }
 
 int8_t runtime_slice_is_empty(Slice *slice) {
   return (slice == NULL || slice->len == 0) ? 1 : 0;
 }
 
-size_t runtime_slice_cap(Slice *slice) { return slice ? slice->cap : 0; }
+uint64_t runtime_slice_cap(Slice *slice) { return slice ? slice->cap : 0; }
 
-void runtime_slice_reserve(Slice *slice, size_t additional) {
+void runtime_slice_reserve(Slice *slice, uint64_t additional) {
   if (!slice) {
     fprintf(stderr, "runtime_slice_reserve: null slice\n");
     abort();
@@ -282,12 +1146,7 @@ void runtime_slice_reserve(Slice *slice, size_t additional) {
         new_cap = 1;
     }
 
-    slice->data = GC_realloc(slice->data, slice->elem_size * new_cap);
-    if (!slice->data) {
-      fprintf(stderr, "runtime_slice_reserve: out of memory\n");
-      abort();
-    }
-    slice->cap = new_cap;
+    slice_grow(slice, new_cap);
   }
 }
 
@@ -313,10 +1172,9 @@ void *runtime_slice_pop(Slice *slice) {
   return result;
 }
 
-void runtime_slice_remove(Slice *slice, size_t index) {
+void runtime_slice_remove(Slice *slice, uint64_t index) {
   if (!slice || index >= slice->len) {
-    fprintf(stderr, "runtime_slice_remove: index out of bounds\n");
-    abort();
+    slice_index_panic(slice, index);
   }
 
   // Shift elements after index to the left
@@ -329,15 +1187,14 @@ void runtime_slice_remove(Slice *slice, size_t index) {
   slice->len--;
 }
 
-void runtime_slice_insert(Slice *slice, size_t index, void *value) {
+void runtime_slice_insert(Slice *slice, uint64_t index, void *value) {
   if (!slice) {
     fprintf(stderr, "runtime_slice_insert: null slice\n");
     abort();
   }
 
   if (index > slice->len) {
-    fprintf(stderr, "runtime_slice_insert: index out of bounds\n");
-    abort();
+    runtime_panic_cstr("insertion index out of bounds");
   }
 
   // Grow if needed
@@ -345,12 +1202,7 @@ void runtime_slice_insert(Slice *slice, size_t index, void *value) {
     size_t new_cap = slice->cap * 2;
     if (new_cap == 0)
       new_cap = 1;
-    slice->data = GC_realloc(slice->data, slice->elem_size * new_cap);
-    if (!slice->data) {
-      fprintf(stderr, "runtime_slice_insert: out of memory\n");
-      abort();
-    }
-    slice->cap = new_cap;
+    slice_grow(slice, new_cap);
   }
 
   // Shift elements from index to the right
@@ -386,34 +1238,29 @@ Slice *runtime_slice_copy(Slice *slice) {
   return copy;
 }
 
-Slice *runtime_slice_subslice(Slice *slice, size_t start, size_t end) {
+Slice *runtime_slice_subslice(Slice *slice, uint64_t start, uint64_t end) {
   if (!slice) {
     fprintf(stderr, "runtime_slice_subslice: null slice\n");
     abort();
   }
 
   if (start > end || end > slice->len) {
-    fprintf(stderr,
-            "runtime_slice_subslice: invalid range [%zu:%zu) for slice of "
-            "length %zu\n",
-            start, end, slice->len);
-    abort();
+    char msg[128];
+    snprintf(msg, sizeof(msg),
+             "invalid range [%lld:%lld) for slice of length %zu",
+             (long long)start, (long long)end, slice->len);
+    runtime_panic_cstr(msg);
   }
 
-  // Create a new slice with a copy of the data
-  // This ensures the subslice is independent and safe even if the original is
-  // freed
-  size_t sub_len = end - start;
+  // The subslice is a view: it shares the elements of the original, so
+  // writes through either are seen by both. Its capacity ends where its
+  // length does, so growing it moves it to a buffer of its own instead of
+  // overwriting the elements that follow it in the original.
   Slice *sub = (Slice *)runtime_alloc(sizeof(Slice));
-  sub->len = sub_len;
-  sub->cap = sub_len; // Capacity matches length for subslice
+  sub->data = (char *)slice->data + (start * slice->elem_size);
+  sub->len = end - start;
+  sub->cap = end - start;
   sub->elem_size = slice->elem_size;
-  sub->data = runtime_alloc(slice->elem_size * sub_len);
-
-  // Copy the elements from the original slice
-  void *src = (char *)slice->data + (start * slice->elem_size);
-  memcpy(sub->data, src, slice->elem_size * sub_len);
-
   return sub;
 }
 
@@ -441,83 +1288,155 @@ static int string_equal(String *a, String *b) {
 int runtime_string_equal(String *a, String *b) { return string_equal(a, b); }
 
 // HashMap operations
-HashMap *runtime_hashmap_new(void) {
+HashMap *runtime_hashmap_new(uint64_t key_size, uint64_t value_size,
+                             int8_t string_keys) {
   HashMap *map = (HashMap *)runtime_alloc(sizeof(HashMap));
+  memset(map, 0, sizeof(HashMap));
   map->capacity = HASHMAP_INITIAL_SIZE;
-  map->size = 0;
-  // Use GC_malloc instead of calloc, then zero-initialize
+  map->key_size = key_size;
+  map->value_size = value_size;
+  map->string_keys = string_keys;
   map->buckets =
       (HashMapEntry **)runtime_alloc(map->capacity * sizeof(HashMapEntry *));
   memset(map->buckets, 0, map->capacity * sizeof(HashMapEntry *));
   return map;
 }
 
-void runtime_hashmap_put(HashMap *map, String *key, void *value) {
+static size_t hashmap_hash(HashMap *map, void *key) {
+  if (map->string_keys)
+    return hash_string(*(String **)key);
+  size_t hash = 5381;
+  for (size_t i = 0; i < map->key_size; i++) {
+    hash = ((hash << 5) + hash) + ((unsigned char *)key)[i];
+  }
+  return hash;
+}
+
+static int hashmap_key_equal(HashMap *map, void *a, void *b) {
+  if (map->string_keys)
+    return string_equal(*(String **)a, *(String **)b);
+  return memcmp(a, b, map->key_size) == 0;
+}
+
+// Returns the link pointing at key's entry, or the empty link ending its
+// bucket's chain if key is absent
+static HashMapEntry **hashmap_find(HashMap *map, void *key) {
+  HashMapEntry **link = &map->buckets[hashmap_hash(map, key) % map->capacity];
+  while (*link && !hashmap_key_equal(map, (*link)->key, key)) {
+    link = &(*link)->next;
+  }
+  return link;
+}
+
+static void hashmap_grow(HashMap *map) {
+  size_t capacity = map->capacity * 2;
+  HashMapEntry **buckets =
+      (HashMapEntry **)runtime_alloc(capacity * sizeof(HashMapEntry *));
+  memset(buckets, 0, capacity * sizeof(HashMapEntry *));
+  for (HashMapEntry *entry = map->oldest; entry; entry = entry->newer) {
+    size_t index = hashmap_hash(map, entry->key) % capacity;
+    entry->next = buckets[index];
+    buckets[index] = entry;
+  }
+  map->buckets = buckets;
+  map->capacity = capacity;
+}
+
+void runtime_hashmap_put(HashMap *map, void *key, void *value) {
   if (!map || !key)
     return;
 
-  size_t hash = hash_string(key);
-  size_t index = hash % map->capacity;
+  // Each put stores a fresh copy, so a V? returned by an earlier get keeps
+  // the value it was read with
+  void *copy = runtime_alloc(map->value_size ? map->value_size : 1);
+  memcpy(copy, value, map->value_size);
 
-  // Check if key exists
-  HashMapEntry *entry = map->buckets[index];
-  while (entry) {
-    if (string_equal(entry->key, key)) {
-      entry->value = value;
-      return;
-    }
-    entry = entry->next;
+  HashMapEntry **link = hashmap_find(map, key);
+  if (*link) {
+    (*link)->value = copy;
+    return;
   }
 
-  // Insert new entry
-  entry = (HashMapEntry *)runtime_alloc(sizeof(HashMapEntry));
-  entry->key = key;
-  entry->value = value;
-  entry->next = map->buckets[index];
-  map->buckets[index] = entry;
+  // Keep the load factor below 3/4
+  if ((map->size + 1) * 4 > map->capacity * 3) {
+    hashmap_grow(map);
+    link = hashmap_find(map, key);
+  }
+
+  HashMapEntry *entry = (HashMapEntry *)runtime_alloc(sizeof(HashMapEntry));
+  entry->key = runtime_alloc(map->key_size);
+  memcpy(entry->key, key, map->key_size);
+  entry->value = copy;
+  entry->next = NULL;
+  entry->older = map->newest;
+  entry->newer = NULL;
+  if (map->newest)
+    map->newest->newer = entry;
+  else
+    map->oldest = entry;
+  map->newest = entry;
+  *link = entry;
   map->size++;
 }
 
-void *runtime_hashmap_get(HashMap *map, String *key) {
+void *runtime_hashmap_get(HashMap *map, void *key) {
   if (!map || !key)
     return NULL;
+  HashMapEntry *entry = *hashmap_find(map, key);
+  return entry ? entry->value : NULL;
+}
 
-  size_t hash = hash_string(key);
-  size_t index = hash % map->capacity;
-
-  HashMapEntry *entry = map->buckets[index];
-  while (entry) {
-    if (string_equal(entry->key, key)) {
-      return entry->value;
-    }
-    entry = entry->next;
-  }
-  return NULL;
+int8_t runtime_hashmap_contains_key(HashMap *map, void *key) {
+  return runtime_hashmap_get(map, key) != NULL ? 1 : 0;
 }
 
-int8_t runtime_hashmap_contains_key(HashMap *map, String *key) {
+void *runtime_hashmap_remove(HashMap *map, void *key) {
   if (!map || !key)
-    return 0;
-
-  size_t hash = hash_string(key);
-  size_t index = hash % map->capacity;
+    return NULL;
+  HashMapEntry **link = hashmap_find(map, key);
+  HashMapEntry *entry = *link;
+  if (!entry)
+    return NULL;
 
-  HashMapEntry *entry = map->buckets[index];
-  while (entry) {
-    if (string_equal(entry->key, key)) {
-      return 1; // Key exists
-    }
-    entry = entry->next;
-  }
-  return 0; // Key does not exist
+  *link = entry->next;
+  if (entry->older)
+    entry->older->newer = entry->newer;
+  else
+    map->oldest = entry->newer;
+  if (entry->newer)
+    entry->newer->older = entry->older;
+  else
+    map->newest = entry->older;
+  map->size--;
+  return entry->value;
 }
 
-size_t runtime_hashmap_len(HashMap *map) { return map ? map->size : 0; }
+uint64_t runtime_hashmap_len(HashMap *map) { return map ? map->size : 0; }
 
 int8_t runtime_hashmap_is_empty(HashMap *map) {
   return (map == NULL || map->size == 0) ? 1 : 0;
 }
 
+// Copies the keys (or values) of map into a new slice, in insertion order
+static Slice *hashmap_collect(HashMap *map, int keys) {
+  size_t elem_size = map ? (keys ? map->key_size : map->value_size) : 1;
+  size_t len = runtime_hashmap_len(map);
+  Slice *slice = runtime_slice_new(elem_size, len, len);
+  char *dst = (char *)slice->data;
+  for (HashMapEntry *entry = map ? map->oldest : NULL; entry;
+       entry = entry->newer) {
+    memcpy(dst, keys ? entry->key : entry->value, elem_size);
+    dst += elem_size;
+  }
+  return slice;
+}
+
+Slice *runtime_hashmap_keys(HashMap *map) { return hashmap_collect(map, 1); }
+
+Slice *runtime_hashmap_values(HashMap *map) {
+  return hashmap_collect(map, 0);
+}
+
 void runtime_hashmap_free(HashMap *map) {
   // With GC, we don't need to manually free memory
   // This function is kept for API compatibility but does nothing
@@ -549,11 +1468,18 @@ typedef enum {
   LEGION_STATE_DEAD      // Completed
 } LegionState;
 
+#ifndef MALPHAS_NO_LEGIONS
 // Forward declaration
 static void legion_entry(Legion *legion);
+#endif
 
 // Context structure for green threads
-#if defined(__aarch64__)
+#if defined(MALPHAS_NO_LEGIONS)
+// Without legions (WASI) no context is ever switched to
+typedef struct Context {
+  int unused;
+} Context;
+#elif defined(__aarch64__)
 // ARM64: x19-x28, fp, lr, sp
 typedef struct Context {
   uint64_t x19;
@@ -581,15 +1507,26 @@ typedef struct Context {
   uint64_t r15;
   uint64_t rsp;
   uint64_t rip;
+#ifdef _WIN32
+  // Stack bounds recorded in the thread information block, which Windows
+  // checks when unwinding and probing the stack
+  uint64_t stack_base;
+  uint64_t stack_limit;
+#endif
 } Context;
 #else
 #error "Unsupported architecture"
 #endif
 
+#ifndef MALPHAS_NO_LEGIONS
 // Context switching functions implemented in inline assembly
 __attribute__((noinline)) static void malphas_context_switch(Context *from,
                                                              Context *to) {
 #if defined(__aarch64__)
+  // As on x86_64: from/to are pinned so that loading x19-x28 cannot overwrite
+  // them, and the caller-saved registers are clobbered
+  register Context *from_reg __asm__("x0") = from;
+  register Context *to_reg __asm__("x1") = to;
   __asm__ volatile(
       // Save current context to 'from'
       "stp x19, x20, [%0, #0]\n\t"
@@ -613,9 +1550,13 @@ __attribute__((noinline)) static void malphas_context_switch(Context *from,
 
       // Return (lr is restored to x30, so ret will jump to saved location)
       "ret\n\t"
+      : "+r"(from_reg), "+r"(to_reg)
       :
-      : "r"(from), "r"(to)
-      : "memory", "x9");
+      : "memory", "cc", "x2", "x3", "x4", "x5", "x6", "x7", "x8", "x9", "x10",
+        "x11", "x12", "x13", "x14", "x15", "x16", "x17", "x18", "x30", "v0",
+        "v1", "v2", "v3", "v4", "v5", "v6", "v7", "v16", "v17", "v18", "v19",
+        "v20", "v21", "v22", "v23", "v24", "v25", "v26", "v27", "v28", "v29",
+        "v30", "v31");
 #elif defined(__x86_64__)
   __asm__ volatile(
       // Save current context to 'from'
@@ -660,6 +1601,17 @@ __attribute__((noinline)) static void malphas_context_switch(Context *from,
       "leaq 1f(%%rip), %%rax\n\t" // Get address of label 1
       "movq %%rax, 56(%0)\n\t" // Save it as RIP (for consistency, though mostly
                                // unused in switch)
+#ifdef _WIN32
+      // Switch the stack bounds of the thread information block too
+      "movq %%gs:8, %%rax\n\t"
+      "movq %%rax, 64(%0)\n\t"
+      "movq %%gs:16, %%rax\n\t"
+      "movq %%rax, 72(%0)\n\t"
+      "movq 64(%1), %%rax\n\t"
+      "movq %%rax, %%gs:8\n\t"
+      "movq 72(%1), %%rax\n\t"
+      "movq %%rax, %%gs:16\n\t"
+#endif
 
       // Load new context
       "movq 0(%1), %%rbx\n\t"
@@ -677,9 +1629,28 @@ __attribute__((noinline)) static void malphas_context_switch(Context *from,
       "jmp *56(%1)\n\t" // Jump to saved RIP (or entry point)
 
       "1:\n\t"
+  // Pin from/to to argument registers: with "r" they may be allocated to
+  // rbx/r12-r15, which are overwritten while the new context is loaded.
+  // Execution continues at 1 with the registers of whoever switched back, so
+  // every caller-saved register is declared clobbered: GCC's -fipa-ra would
+  // otherwise let callers keep values in the ones this function seems not to
+  // touch across the call.
+#ifdef _WIN32
+      // The Win64 ABI also preserves rdi, rsi and xmm6-xmm15. Declaring them
+      // clobbered makes this function save them on its own stack, where they
+      // are restored when the context switches back.
+      : "+c"(from), "+d"(to)
       :
-      : "r"(from), "r"(to)
-      : "memory", "rax");
+      : "memory", "cc", "rax", "rdi", "rsi", "r8", "r9", "r10", "r11", "xmm0",
+        "xmm1", "xmm2", "xmm3", "xmm4", "xmm5", "xmm6", "xmm7", "xmm8", "xmm9",
+        "xmm10", "xmm11", "xmm12", "xmm13", "xmm14", "xmm15");
+#else
+      : "+D"(from), "+S"(to)
+      :
+      : "memory", "cc", "rax", "rcx", "rdx", "r8", "r9", "r10", "r11", "xmm0",
+        "xmm1", "xmm2", "xmm3", "xmm4", "xmm5", "xmm6", "xmm7", "xmm8", "xmm9",
+        "xmm10", "xmm11", "xmm12", "xmm13", "xmm14", "xmm15");
+#endif
 #endif
 }
 
@@ -724,8 +1695,18 @@ static void legion_trampoline(void) {
       // In our case, legion_entry calls the user fn and then handles death.
       // So 'fn' here is actually 'legion_entry', and 'arg' is 'Legion*'.
   );
+#elif defined(__x86_64__) && defined(_WIN32)
+  __asm__ volatile("movq %rbx, %rcx\n\t" // arg (Win64 ABI: first arg in rcx)
+                   "andq $-16, %rsp\n\t" // align the stack for the call
+                   "subq $32, %rsp\n\t"  // shadow space for the callee
+                   "callq *%r12\n\t"     // call fn (stored in r12)
+  );
 #elif defined(__x86_64__)
+  // The trampoline is entered by a jump with the stack as a call leaves it,
+  // and the compiler need not have pushed anything since, so the stack is
+  // realigned to 16 bytes before the call or SSE spills in fn would fault
   __asm__ volatile("movq %rbx, %rdi\n\t" // arg (System V ABI: first arg in rdi)
+                   "andq $-16, %rsp\n\t" // align the stack for the call
                    "callq *%r12\n\t"     // call fn (stored in r12)
   );
 #endif
@@ -750,8 +1731,13 @@ static void malphas_context_make_trampoline(Context *ctx, void (*fn)(void *),
   ctx->rip = (uint64_t)legion_trampoline;
   ctx->rbx = (uint64_t)arg; // arg
   ctx->r12 = (uint64_t)fn;  // fn
+#ifdef _WIN32
+  ctx->stack_base = (uint64_t)stack_base + stack_size;
+  ctx->stack_limit = (uint64_t)stack_base;
+#endif
 #endif
 }
+#endif // MALPHAS_NO_LEGIONS
 typedef struct Channel Channel;
 
 // Legion structure - represents a spawned concurrent task
@@ -763,7 +1749,6 @@ struct Legion {
   size_t stack_size;     // Current stack size
   size_t stack_cap;      // Stack capacity
   Context ctx;           // Execution context (custom struct)
-  Context ctx_saved;     // Saved context when blocked
   LegionState state;     // Current state
   struct Legion *next;   // For linked lists (run queue, etc.)
   pthread_cond_t cond;   // Condition variable for blocking
@@ -772,6 +1757,7 @@ struct Legion {
   int id;                // Unique legion ID
   int thread_id;      // OS thread ID currently running this legion (-1 if none)
   int stack_overflow; // Flag for stack overflow detection
+  void *result;       // Boxed return value, set by the spawn wrapper
 };
 
 // Forward declaration for unblock_legion_from_channel (defined later)
@@ -793,8 +1779,54 @@ struct Channel {
   Legion *blocked_receivers; // Linked list of blocked receiving legions
 };
 
-Channel *runtime_channel_new(size_t elem_size, size_t capacity) {
+// A select with no ready case parks on select_cond until some channel
+// changes state. Every channel operation bumps select_epoch, and a waiter only
+// sleeps if the epoch is unchanged since it last polled its cases, so a
+// wake-up that lands between polling and sleeping is never lost.
+static pthread_mutex_t select_mutex = PTHREAD_MUTEX_INITIALIZER;
+static pthread_cond_t select_cond = PTHREAD_COND_INITIALIZER;
+static atomic_long select_epoch;
+static atomic_int select_waiters;
+
+static void channel_notify_select(void) {
+  atomic_fetch_add(&select_epoch, 1);
+  if (atomic_load(&select_waiters) > 0) {
+    pthread_mutex_lock(&select_mutex);
+    pthread_cond_broadcast(&select_cond);
+    pthread_mutex_unlock(&select_mutex);
+  }
+}
+
+// Elements travel by value: a slot holds elem_size bytes, which is the
+// whole struct for struct elements rather than a pointer to it. Sends copy
+// the bytes at value into the next free slot, and each receive copies the
+// oldest slot into a block of its own, so the sender and receivers never
+// share an element. Both must be called with ch->mutex held.
+static void channel_put(Channel *ch, const void *value) {
+  void *dest = (char *)ch->buffer + (ch->tail * ch->elem_size);
+  memcpy(dest, value, ch->elem_size);
+  ch->tail = (ch->tail + 1) % ch->capacity;
+  ch->count++;
+}
+
+static void *channel_take(Channel *ch) {
+  void *src = (char *)ch->buffer + (ch->head * ch->elem_size);
+  void *result = runtime_alloc(ch->elem_size);
+  memcpy(result, src, ch->elem_size);
+  // Clear the slot so the buffer does not keep what it pointed to alive
+  memset(src, 0, ch->elem_size);
+  ch->head = (ch->head + 1) % ch->capacity;
+  ch->count--;
+  return result;
+}
+
+Channel *runtime_channel_new(uint64_t elem_size, uint64_t capacity) {
   Channel *ch = (Channel *)runtime_alloc(sizeof(Channel));
+  // Unbuffered channels get a single slot; without one a send could never
+  // complete because there is no rendezvous with the receiver
+  if (capacity == 0) {
+    capacity = 1;
+  }
   ch->elem_size = elem_size;
   ch->capacity = capacity;
   ch->head = 0;
@@ -826,37 +1858,22 @@ void runtime_channel_send(Channel *ch, void *value) {
       current->next = ch->blocked_senders;
       ch->blocked_senders = current;
 
-      // Block the legion and release the channel mutex
+      // Park the legion until a wake-up; it resumes holding the mutex and
+      // checks again whether it can proceed
       runtime_legion_block(current, ch);
-      pthread_mutex_unlock(&ch->mutex);
-
-      // Yield to scheduler (this will switch contexts)
-      runtime_legion_yield();
-
-      // When we resume, re-acquire the mutex
-      pthread_mutex_lock(&ch->mutex);
-      // Check again if we can proceed
-      if (ch->count >= ch->capacity && atomic_load(&ch->closed) == 0) {
-        continue; // Still blocked, wait again
-      }
     } else {
       // Not in legion context, use traditional blocking
       pthread_cond_wait(&ch->not_full, &ch->mutex);
     }
   }
 
-  // If closed, unlock and return
+  // Sending on a closed channel is a program error
   if (atomic_load(&ch->closed) != 0) {
     pthread_mutex_unlock(&ch->mutex);
-    return;
+    runtime_panic_cstr("send on closed channel");
   }
 
-  // Copy value into buffer
-  void *dest = (char *)ch->buffer + (ch->tail * ch->elem_size);
-  memcpy(dest, value, ch->elem_size);
-
-  ch->tail = (ch->tail + 1) % ch->capacity;
-  ch->count++;
+  channel_put(ch, value);
 
   // Unblock a waiting receiver if any
   if (ch->blocked_receivers) {
@@ -869,6 +1886,7 @@ void runtime_channel_send(Channel *ch, void *value) {
   // Signal that channel is not empty (wake up waiting legions/threads)
   pthread_cond_signal(&ch->not_empty);
   pthread_mutex_unlock(&ch->mutex);
+  channel_notify_select();
 }
 
 void *runtime_channel_recv(Channel *ch) {
@@ -887,19 +1905,9 @@ void *runtime_channel_recv(Channel *ch) {
       current->next = ch->blocked_receivers;
       ch->blocked_receivers = current;
 
-      // Block the legion and release the channel mutex
+      // Park the legion until a wake-up; it resumes holding the mutex and
+      // checks again whether it can proceed
       runtime_legion_block(current, ch);
-      pthread_mutex_unlock(&ch->mutex);
-
-      // Yield to scheduler (this will switch contexts)
-      runtime_legion_yield();
-
-      // When we resume, re-acquire the mutex
-      pthread_mutex_lock(&ch->mutex);
-      // Check again if we can proceed
-      if (ch->count == 0 && atomic_load(&ch->closed) == 0) {
-        continue; // Still blocked, wait again
-      }
     } else {
       // Not in legion context, use traditional blocking
       pthread_cond_wait(&ch->not_empty, &ch->mutex);
@@ -912,13 +1920,7 @@ void *runtime_channel_recv(Channel *ch) {
     return NULL;
   }
 
-  // Read value from buffer
-  void *src = (char *)ch->buffer + (ch->head * ch->elem_size);
-  void *result = runtime_alloc(ch->elem_size);
-  memcpy(result, src, ch->elem_size);
-
-  ch->head = (ch->head + 1) % ch->capacity;
-  ch->count--;
+  void *result = channel_take(ch);
 
   // Unblock a waiting sender if any
   if (ch->blocked_senders) {
@@ -931,6 +1933,7 @@ void *runtime_channel_recv(Channel *ch) {
   // Signal that channel is not full (wake up waiting legions/threads)
   pthread_cond_signal(&ch->not_full);
   pthread_mutex_unlock(&ch->mutex);
+  channel_notify_select();
 
   return result;
 }
@@ -940,11 +1943,40 @@ void runtime_channel_close(Channel *ch) {
     return;
 
   pthread_mutex_lock(&ch->mutex);
+  if (atomic_load(&ch->closed) != 0) {
+    pthread_mutex_unlock(&ch->mutex);
+    runtime_panic_cstr("close of closed channel");
+  }
   atomic_store(&ch->closed, 1);
-  // Wake up all waiting threads
+  // Wake up all waiting threads and legions; receivers drain what is left
+  // and then see the channel as closed
   pthread_cond_broadcast(&ch->not_full);
   pthread_cond_broadcast(&ch->not_empty);
+  while (ch->blocked_receivers) {
+    Legion *receiver = ch->blocked_receivers;
+    ch->blocked_receivers = receiver->next;
+    receiver->next = NULL;
+    unblock_legion_from_channel(receiver);
+  }
+  while (ch->blocked_senders) {
+    Legion *sender = ch->blocked_senders;
+    ch->blocked_senders = sender->next;
+    sender->next = NULL;
+    unblock_legion_from_channel(sender);
+  }
   pthread_mutex_unlock(&ch->mutex);
+  channel_notify_select();
+}
+
+// Receive for `<-ch`: like runtime_channel_recv, but a closed and drained
+// channel yields a zeroed element instead of NULL
+void *runtime_channel_recv_or_zero(Channel *ch) {
+  void *value = runtime_channel_recv(ch);
+  if (!value) {
+    value = runtime_alloc(ch ? ch->elem_size : sizeof(int64_t));
+    memset(value, 0, ch ? ch->elem_size : sizeof(int64_t));
+  }
+  return value;
 }
 
 int8_t runtime_channel_is_closed(Channel *ch) {
@@ -972,16 +2004,20 @@ int8_t runtime_channel_try_send(Channel *ch, void *value) {
     return 0;
   }
 
-  // Copy value into buffer
-  void *dest = (char *)ch->buffer + (ch->tail * ch->elem_size);
-  memcpy(dest, value, ch->elem_size);
+  channel_put(ch, value);
 
-  ch->tail = (ch->tail + 1) % ch->capacity;
-  ch->count++;
+  // Unblock a waiting receiver if any
+  if (ch->blocked_receivers) {
+    Legion *receiver = ch->blocked_receivers;
+    ch->blocked_receivers = receiver->next;
+    receiver->next = NULL;
+    unblock_legion_from_channel(receiver);
+  }
 
   // Signal that channel is not empty
   pthread_cond_signal(&ch->not_empty);
   pthread_mutex_unlock(&ch->mutex);
+  channel_notify_select();
 
   return 1;
 }
@@ -1007,17 +2043,20 @@ int8_t runtime_channel_try_recv(Channel *ch, void **value) {
     return 0;
   }
 
-  // Read value from buffer
-  void *src = (char *)ch->buffer + (ch->head * ch->elem_size);
-  void *result = runtime_alloc(ch->elem_size);
-  memcpy(result, src, ch->elem_size);
+  void *result = channel_take(ch);
 
-  ch->head = (ch->head + 1) % ch->capacity;
-  ch->count--;
+  // Unblock a waiting sender if any
+  if (ch->blocked_senders) {
+    Legion *sender = ch->blocked_senders;
+    ch->blocked_senders = sender->next;
+    sender->next = NULL;
+    unblock_legion_from_channel(sender);
+  }
 
   // Signal that channel is not full
   pthread_cond_signal(&ch->not_full);
   pthread_mutex_unlock(&ch->mutex);
+  channel_notify_select();
 
   *value = result;
   return 1;
@@ -1047,20 +2086,169 @@ void runtime_channel_wait_for_recv(Channel *ch) {
   }
 }
 
-// Sleep for specified nanoseconds (for select polling with timeout)
-void runtime_nanosleep(long nanoseconds) {
+// Case record built by generated code for each channel arm of a select.
+// For a send, value points at the value to send; for a receive the runtime
+// stores a pointer to the received element (zeroed once the channel is
+// closed and drained).
+struct SelectCase {
+  Channel *ch;
+  void *value;
+  int64_t kind; // SELECT_SEND or SELECT_RECV
+};
+
+#define SELECT_SEND 0
+#define SELECT_RECV 1
+#define SELECT_DEFAULT -1
+#define SELECT_TIMEOUT -2
+
+// Attempt a single select case without blocking
+static int select_try_case(SelectCase *c) {
+  // A nil channel is never ready
+  if (!c->ch)
+    return 0;
+  if (c->kind == SELECT_SEND) {
+    if (runtime_channel_try_send(c->ch, c->value))
+      return 1;
+    if (atomic_load(&c->ch->closed) != 0) {
+      runtime_panic_cstr("send on closed channel");
+    }
+    return 0;
+  }
+  void *value = NULL;
+  if (runtime_channel_try_recv(c->ch, &value)) {
+    c->value = value;
+    return 1;
+  }
+  if (atomic_load(&c->ch->closed) != 0) {
+    // Nothing can be sent after close, so one more attempt drains any value
+    // that raced in before it; otherwise the receive yields a zero value
+    if (runtime_channel_try_recv(c->ch, &value)) {
+      c->value = value;
+    } else {
+      c->value = runtime_alloc(c->ch->elem_size);
+      memset(c->value, 0, c->ch->elem_size);
+    }
+    return 1;
+  }
+  return 0;
+}
+
+static int64_t select_now_ns(void) {
+  struct timespec ts;
+  clock_gettime(CLOCK_REALTIME, &ts);
+  return (int64_t)ts.tv_sec * 1000000000LL + ts.tv_nsec;
+}
+
+// Wait until one of the n cases can proceed and perform it. Returns the
+// index of the case that ran, SELECT_DEFAULT if has_default is set and no
+// case was ready, or SELECT_TIMEOUT once timeout_ms (if >= 0) has elapsed.
+// Cases are polled from a rotating start so that no ready case starves.
+int64_t runtime_select(SelectCase *cases, int64_t n, int8_t has_default,
+                       int64_t timeout_ms) {
+  static atomic_uint select_rotation;
+  int64_t deadline = -1;
+  if (timeout_ms >= 0) {
+    deadline = select_now_ns() + timeout_ms * 1000000LL;
+  }
+
+  atomic_fetch_add(&select_waiters, 1);
+  int64_t chosen = SELECT_TIMEOUT;
+  for (;;) {
+    long epoch = atomic_load(&select_epoch);
+    int64_t start = n > 0 ? (int64_t)(atomic_fetch_add(&select_rotation, 1) % (unsigned)n) : 0;
+    int found = 0;
+    for (int64_t k = 0; k < n; k++) {
+      int64_t i = (start + k) % n;
+      if (select_try_case(&cases[i])) {
+        chosen = i;
+        found = 1;
+        break;
+      }
+    }
+    if (found)
+      break;
+    if (has_default) {
+      chosen = SELECT_DEFAULT;
+      break;
+    }
+    if (deadline >= 0 && select_now_ns() >= deadline) {
+      chosen = SELECT_TIMEOUT;
+      break;
+    }
+
+    if (runtime_get_current_legion()) {
+      // Parking the OS thread would stall every legion scheduled on it, so
+      // a legion hands its thread back to the scheduler instead
+      runtime_legion_yield();
+      continue;
+    }
+
+    pthread_mutex_lock(&select_mutex);
+    if (atomic_load(&select_epoch) == epoch) {
+      if (deadline >= 0) {
+        struct timespec ts;
+        ts.tv_sec = deadline / 1000000000LL;
+        ts.tv_nsec = deadline % 1000000000LL;
+        pthread_cond_timedwait(&select_cond, &select_mutex, &ts);
+      } else {
+        pthread_cond_wait(&select_cond, &select_mutex);
+      }
+    }
+    pthread_mutex_unlock(&select_mutex);
+  }
+  atomic_fetch_sub(&select_waiters, 1);
+  return chosen;
+}
+
+// Sleep for specified nanoseconds
+void runtime_nanosleep(int64_t nanoseconds) {
   struct timespec req;
-  req.tv_sec = nanoseconds / 1000000000L;
-  req.tv_nsec = nanoseconds % 1000000000L;
+  req.tv_sec = (time_t)(nanoseconds / 1000000000LL);
+  req.tv_nsec = (long)(nanoseconds % 1000000000LL);
   nanosleep(&req, NULL);
 }
 
+// Read the monotonic clock, which measures elapsed time: unlike the wall
+// clock it is not set back or forward while the program runs
+int64_t runtime_time_monotonic_ns(void) {
+  struct timespec ts;
+  clock_gettime(CLOCK_MONOTONIC, &ts);
+  return (int64_t)ts.tv_sec * 1000000000LL + ts.tv_nsec;
+}
+
+// Read the wall clock: nanoseconds since the Unix epoch, 1970-01-01 UTC
+int64_t runtime_time_wall_ns(void) {
+  struct timespec ts;
+  clock_gettime(CLOCK_REALTIME, &ts);
+  return (int64_t)ts.tv_sec * 1000000000LL + ts.tv_nsec;
+}
+
+// Sleep for at least nanoseconds. A legion yields to the scheduler until
+// the time has passed rather than parking its OS thread, which would stall
+// every legion scheduled on it.
+void runtime_time_sleep_ns(int64_t nanoseconds) {
+  if (nanoseconds <= 0)
+    return;
+  int64_t deadline = runtime_time_monotonic_ns() + nanoseconds;
+  if (runtime_get_current_legion()) {
+    while (runtime_time_monotonic_ns() < deadline) {
+      runtime_legion_yield();
+    }
+    return;
+  }
+  // nanosleep returns early when a signal interrupts it
+  for (int64_t left = nanoseconds; left > 0; left = deadline - runtime_time_monotonic_ns()) {
+    runtime_nanosleep(left);
+  }
+}
+
 // ============================================================================
 // Legion (M:N Threading Model) - Infernal Scheduler (continued)
 // ============================================================================
 // The Legion struct is defined above (before Channel) to allow Channel
 // functions to access Legion members. Scheduler implementation continues below.
 
+#ifndef MALPHAS_NO_LEGIONS
 // Scheduler structure
 typedef struct {
   pthread_t threads[MAX_OS_THREADS]; // OS thread pool
@@ -1082,21 +2270,18 @@ typedef struct {
 static Scheduler *g_scheduler = NULL;
 static atomic_int g_legion_id_counter = 0;
 
-// Thread-local storage for current thread ID
-static void init_thread_local_id(void) {
-  pthread_key_create(&g_scheduler->thread_local_id, NULL);
-}
-
+// Thread-local storage for current thread ID. The key holds id + 1, since
+// pthread_getspecific cannot tell an id of 0 from an unset key
 static int get_thread_id(void) {
   void *id = pthread_getspecific(g_scheduler->thread_local_id);
   if (id == NULL) {
     return -1;
   }
-  return (int)(intptr_t)id;
+  return (int)(intptr_t)id - 1;
 }
 
 static void set_thread_id(int id) {
-  pthread_setspecific(g_scheduler->thread_local_id, (void *)(intptr_t)id);
+  pthread_setspecific(g_scheduler->thread_local_id, (void *)(intptr_t)(id + 1));
 }
 
 // Get the currently running legion
@@ -1164,7 +2349,7 @@ static void *allocate_stack_with_guard(size_t size) {
 }
 
 // Create a new legion (spawned entity)
-Legion *runtime_legion_spawn(void (*fn)(void *), void *arg, size_t stack_size) {
+Legion *runtime_legion_spawn(void (*fn)(void *), void *arg, uint64_t stack_size) {
   if (stack_size == 0) {
     stack_size = LEGION_STACK_SIZE;
   }
@@ -1194,23 +2379,25 @@ Legion *runtime_legion_spawn(void (*fn)(void *), void *arg, size_t stack_size) {
   legion->thread_id = -1;
   legion->stack_overflow = 0;
   legion->blocked_on = NULL;
+  legion->result = NULL;
   pthread_cond_init(&legion->cond, NULL);
   pthread_mutex_init(&legion->mutex, NULL);
 
-  // Initialize context
-  pthread_mutex_init(&legion->mutex, NULL);
-
   // Initialize context
   malphas_context_make_trampoline(&legion->ctx, (void (*)(void *))legion_entry,
                                   legion, legion->stack, stack_size);
 
   return legion;
-
-  return legion;
 }
 
-// Push legion to local queue (lock-free, called by owner thread)
-static int push_to_local_queue(int thread_id, Legion *legion) {
+// Run queues
+// Any thread pushes onto a run queue (spawns, wake-ups from channels, yields
+// requeued by the scheduler), and its owner pops from it while idle threads
+// steal from it. Every queue operation therefore holds the queue's mutex, so
+// a legion taken off a queue has been handed to exactly one thread.
+
+// Push legion onto a queue whose mutex is held; returns 0 if it is full
+static int queue_push_locked(int thread_id, Legion *legion) {
   int tail = atomic_load(&g_scheduler->queue_tail[thread_id]);
   int next_tail = (tail + 1) % LEGION_QUEUE_SIZE;
 
@@ -1224,8 +2411,8 @@ static int push_to_local_queue(int thread_id, Legion *legion) {
   return 1;
 }
 
-// Pop legion from local queue (lock-free, called by owner thread)
-static Legion *pop_from_local_queue(int thread_id) {
+// Pop a legion from a queue whose mutex is held
+static Legion *queue_pop_locked(int thread_id) {
   int head = atomic_load(&g_scheduler->queue_head[thread_id]);
   int tail = atomic_load(&g_scheduler->queue_tail[thread_id]);
 
@@ -1239,24 +2426,25 @@ static Legion *pop_from_local_queue(int thread_id) {
   return legion;
 }
 
-// Steal from another thread's queue (lock-free work-stealing)
-static Legion *steal_from_queue(int victim_thread_id) {
-  int head = atomic_load(&g_scheduler->queue_head[victim_thread_id]);
-  int tail = atomic_load(&g_scheduler->queue_tail[victim_thread_id]);
-
-  if (head == tail) {
-    return NULL; // Queue empty
-  }
-
-  // Try to increment head atomically
-  int expected = head;
-  int next_head = (head + 1) % LEGION_QUEUE_SIZE;
-  if (atomic_compare_exchange_strong(&g_scheduler->queue_head[victim_thread_id],
-                                     &expected, next_head)) {
-    return g_scheduler->run_queue[victim_thread_id][head];
+// Push legion onto a thread's queue and wake the thread; returns 0 if the
+// queue is full
+static int push_to_queue(int thread_id, Legion *legion) {
+  pthread_mutex_lock(&g_scheduler->queue_mutex[thread_id]);
+  int pushed = queue_push_locked(thread_id, legion);
+  if (pushed) {
+    pthread_cond_signal(&g_scheduler->queue_cond[thread_id]);
   }
+  pthread_mutex_unlock(&g_scheduler->queue_mutex[thread_id]);
+  return pushed;
+}
 
-  return NULL; // Failed to steal (race condition)
+// Pop a legion from a thread's queue: the thread's own, or a victim's when
+// stealing
+static Legion *pop_from_queue(int thread_id) {
+  pthread_mutex_lock(&g_scheduler->queue_mutex[thread_id]);
+  Legion *legion = queue_pop_locked(thread_id);
+  pthread_mutex_unlock(&g_scheduler->queue_mutex[thread_id]);
+  return legion;
 }
 
 // Get approximate queue length for a thread (lock-free, approximate)
@@ -1289,77 +2477,104 @@ static int find_least_loaded_thread(void) {
   return best_thread;
 }
 
+// Queue a runnable legion, on the least loaded thread if it has room. With
+// every queue full, wait for the scheduler threads to drain one rather than
+// drop the legion.
+static void enqueue_legion(Legion *legion) {
+  for (;;) {
+    if (push_to_queue(find_least_loaded_thread(), legion)) {
+      return;
+    }
+    for (int i = 0; i < MAX_OS_THREADS; i++) {
+      if (push_to_queue(i, legion)) {
+        return;
+      }
+    }
+    sched_yield();
+  }
+}
+
+#endif // MALPHAS_NO_LEGIONS
+
+// Spawn arguments
+// The spawning legion packs the arguments of a spawn into a block from
+// runtime_spawn_args_alloc and hands it to the spawned legion, which owns it
+// from then on: its wrapper frees it as soon as it has unpacked the
+// arguments. The block is therefore alive for as long as it is read, and
+// many short-lived spawns do not pile up arguments under --gc=none. With the
+// collector the block is uncollectable but scanned, so the values it points
+// to stay alive until the wrapper has copied them out.
+void *runtime_spawn_args_alloc(uint64_t size) {
+#ifndef MALPHAS_GC_NONE
+  void *args = GC_MALLOC_UNCOLLECTABLE(size);
+#else
+  void *args = malloc(size);
+#endif
+  if (!args) {
+    fprintf(stderr, "runtime_spawn_args_alloc: out of memory\n");
+    abort();
+  }
+  return args;
+}
+
+void runtime_spawn_args_free(void *args) {
+#ifndef MALPHAS_GC_NONE
+  GC_FREE(args);
+#else
+  free(args);
+#endif
+}
+
+#ifndef MALPHAS_NO_LEGIONS
 // Start a legion (add to scheduler)
+static pthread_once_t scheduler_once = PTHREAD_ONCE_INIT;
+
 void runtime_legion_start(Legion *legion) {
-  if (!legion || !g_scheduler) {
+  if (!legion) {
     return;
   }
+  // The scheduler's thread pool is started by the first spawn
+  pthread_once(&scheduler_once, runtime_scheduler_init);
 
   atomic_fetch_add(&g_scheduler->active_legions, 1);
-
-  // Add to a queue for load balancing - use load-aware distribution
-  // instead of simple round-robin for better thread utilization
-  int queue_idx = find_least_loaded_thread();
-
-  // Try lock-free push first
-  if (!push_to_local_queue(queue_idx, legion)) {
-    // Queue full, use mutex-protected path
-    pthread_mutex_lock(&g_scheduler->queue_mutex[queue_idx]);
-    int tail = atomic_load(&g_scheduler->queue_tail[queue_idx]);
-    int next_tail = (tail + 1) % LEGION_QUEUE_SIZE;
-    if (next_tail != atomic_load(&g_scheduler->queue_head[queue_idx])) {
-      g_scheduler->run_queue[queue_idx][tail] = legion;
-      atomic_store(&g_scheduler->queue_tail[queue_idx], next_tail);
-    }
-    pthread_cond_signal(&g_scheduler->queue_cond[queue_idx]);
-    pthread_mutex_unlock(&g_scheduler->queue_mutex[queue_idx]);
-  } else {
-    // Signal waiting thread
-    pthread_mutex_lock(&g_scheduler->queue_mutex[queue_idx]);
-    pthread_cond_signal(&g_scheduler->queue_cond[queue_idx]);
-    pthread_mutex_unlock(&g_scheduler->queue_mutex[queue_idx]);
-  }
+  enqueue_legion(legion);
 }
 
-// Scheduler context storage (per thread)
-static __thread Context g_scheduler_context;
+// Scheduler context storage (per thread). This points at the scheduler loop's
+// own Context, which is filled in each time it switches to a legion.
+static _Thread_local Context *g_scheduler_context;
 
 // Set scheduler context for current thread
-static void set_scheduler_context(Context *ctx) { g_scheduler_context = *ctx; }
+static void set_scheduler_context(Context *ctx) { g_scheduler_context = ctx; }
 
 // Get scheduler context for current thread
-static Context *get_scheduler_context(void) { return &g_scheduler_context; }
+static Context *get_scheduler_context(void) { return g_scheduler_context; }
 
 // Legion entry point (called when context is switched to)
 static void legion_entry(Legion *legion) {
+#ifndef _WIN32
   // Set up signal handler for stack overflow
   struct sigaction sa;
   sa.sa_handler = SIG_DFL;
   sigemptyset(&sa.sa_mask);
   sa.sa_flags = SA_ONSTACK;
   sigaction(SIGSEGV, &sa, NULL);
+#endif
+  // On Windows a guard page fault is an access violation, which terminates
+  // the process like the default SIGSEGV action
 
   // Execute the function
   legion->fn(legion->arg);
 
   // Function completed - mark as dead
-  legion->state = LEGION_STATE_DEAD;
+  // Release so a joiner that observes DEAD also sees legion->result
+  __atomic_store_n(&legion->state, LEGION_STATE_DEAD, __ATOMIC_RELEASE);
   atomic_fetch_sub(&g_scheduler->active_legions, 1);
 
-  // Switch back to scheduler
-  int thread_id = legion->thread_id;
-  if (thread_id >= 0 && thread_id < MAX_OS_THREADS) {
-    g_scheduler->current_legion[thread_id] = NULL;
-    legion->thread_id = -1;
-  }
-
-  // Return to scheduler (context switch)
-  // Return to scheduler (context switch)
+  // Return to scheduler (context switch). The legion is dead, so the context
+  // saved here is never resumed.
   Context *scheduler_ctx = get_scheduler_context();
   if (scheduler_ctx) {
-    // We don't have a 'from' context here because we are dying.
-    // But context_switch expects one. We can use a dummy or the legion's ctx.
-    // Since the legion is dead, its ctx won't be used again.
     malphas_context_switch(&legion->ctx, scheduler_ctx);
   }
 }
@@ -1410,7 +2625,7 @@ static int grow_legion_stack(Legion *legion) {
   // No, this function copies stack.
   // With custom context, the SP in 'ctx' points to the OLD stack.
   // We need to adjust it by the offset.
-  long offset = (char *)new_stack - (char *)legion->stack;
+  ptrdiff_t offset = (char *)new_stack - (char *)legion->stack;
 
 #if defined(__aarch64__)
   legion->ctx.sp += offset;
@@ -1423,68 +2638,48 @@ static int grow_legion_stack(Legion *legion) {
   return 1;
 }
 
+// Mutex a parking legion holds until it is off its stack (per thread). The
+// scheduler releases it after the switch back, so a waker that needs the
+// mutex cannot requeue the legion while its context is still being saved.
+static _Thread_local pthread_mutex_t *g_park_mutex;
+
 // Yield control to scheduler (cooperative)
 void runtime_legion_yield(void) {
-  int thread_id = get_thread_id();
-  if (thread_id < 0 || thread_id >= MAX_OS_THREADS) {
-    return; // Not running in scheduler context
-  }
-
-  Legion *current = g_scheduler->current_legion[thread_id];
+  Legion *current = runtime_get_current_legion();
   if (!current) {
-    return;
+    return; // Not running in scheduler context
   }
 
-  // Save current context
-  // We do this by switching TO the scheduler, which saves FROM (current).
-  // So we don't need explicit getcontext here.
-
-  // Save context and switch back to scheduler
-  // The scheduler will resume this legion later
-
-  // Save context and switch back to scheduler
-  // The scheduler will resume this legion later
+  // Save the context into legion->ctx and switch back to the scheduler, which
+  // requeues the legion once it is off its stack; a resumption, possibly on
+  // another thread, returns here
   current->state = LEGION_STATE_RUNNABLE;
-
-  // Push back to queue
-  if (!push_to_local_queue(thread_id, current)) {
-    // Queue full, try another queue
-    for (int i = 0; i < MAX_OS_THREADS; i++) {
-      if (i != thread_id && push_to_local_queue(i, current)) {
-        break;
-      }
-    }
-  }
-
-  g_scheduler->current_legion[thread_id] = NULL;
-  current->thread_id = -1;
-
-  // Context switch back to scheduler
-  // Context switch back to scheduler
   Context *scheduler_ctx = get_scheduler_context();
   if (scheduler_ctx) {
-    malphas_context_switch(&current->ctx_saved, scheduler_ctx);
+    malphas_context_switch(&current->ctx, scheduler_ctx);
   }
 }
 
-// Block a legion (called when blocking on channel)
+// Block a legion on a channel (called with the channel's mutex held, after
+// adding the legion to one of the channel's waiter lists). The mutex is
+// released once the legion has switched out, and reacquired when a wake-up
+// resumes it.
 void runtime_legion_block(Legion *legion, Channel *channel) {
   if (!legion)
     return;
 
-  int thread_id = get_thread_id();
-  if (thread_id >= 0 && thread_id < MAX_OS_THREADS) {
-    g_scheduler->current_legion[thread_id] = NULL;
-    legion->thread_id = -1;
-  }
-
   legion->state = LEGION_STATE_BLOCKED;
   legion->blocked_on = channel;
-
   atomic_fetch_sub(&g_scheduler->active_legions, 1);
+
+  g_park_mutex = &channel->mutex;
+  malphas_context_switch(&legion->ctx, get_scheduler_context());
+
+  pthread_mutex_lock(&channel->mutex);
 }
 
-// Unblock a blocked legion and add it back to the scheduler
+// Unblock a blocked legion and add it back to the scheduler (called with the
+// mutex of the channel it is blocked on held)
 static void unblock_legion_from_channel(Legion *legion) {
   if (!legion || legion->state != LEGION_STATE_BLOCKED) {
     return;
@@ -1492,7 +2687,6 @@ static void unblock_legion_from_channel(Legion *legion) {
 
   legion->state = LEGION_STATE_RUNNABLE;
   legion->blocked_on = NULL;
-  atomic_fetch_add(&g_scheduler->active_legions, 1);
 
   // Add back to scheduler
   runtime_legion_start(legion);
@@ -1500,25 +2694,12 @@ static void unblock_legion_from_channel(Legion *legion) {
 
 // Unblock a legion (called when channel operation completes)
 void runtime_legion_unblock(Legion *legion) {
-  if (!legion || legion->state != LEGION_STATE_BLOCKED) {
-    return;
-  }
-
-  legion->state = LEGION_STATE_RUNNABLE;
-  legion->blocked_on = NULL;
-  atomic_fetch_add(&g_scheduler->active_legions, 1);
-
-  // Add back to scheduler
-  runtime_legion_start(legion);
+  unblock_legion_from_channel(legion);
 }
 
 // Scheduler main loop (runs on each OS thread)
 void *runtime_scheduler_run(void *arg) {
   int thread_id = *(int *)arg;
-  runtime_alloc(sizeof(int)); // Free the arg (GC will handle it)
-  set_thread_id(thread_id);
-
-  runtime_alloc(sizeof(int)); // Free the arg (GC will handle it)
   set_thread_id(thread_id);
 
   Context scheduler_ctx;
@@ -1530,13 +2711,13 @@ void *runtime_scheduler_run(void *arg) {
     Legion *legion = NULL;
 
     // 1. Try to pop from local queue
-    legion = pop_from_local_queue(thread_id);
+    legion = pop_from_queue(thread_id);
 
     // 2. If local queue empty, try work-stealing
     if (!legion) {
       for (int attempt = 0; attempt < WORK_STEAL_ATTEMPTS; attempt++) {
         int victim = (thread_id + attempt + 1) % MAX_OS_THREADS;
-        legion = steal_from_queue(victim);
+        legion = pop_from_queue(victim);
         if (legion) {
           break;
         }
@@ -1548,7 +2729,7 @@ void *runtime_scheduler_run(void *arg) {
       pthread_mutex_lock(&g_scheduler->queue_mutex[thread_id]);
 
       // Double-check queue is still empty
-      legion = pop_from_local_queue(thread_id);
+      legion = queue_pop_locked(thread_id);
       if (!legion) {
         // Wait for work or shutdown
         struct timespec timeout;
@@ -1563,7 +2744,7 @@ void *runtime_scheduler_run(void *arg) {
                                &g_scheduler->queue_mutex[thread_id], &timeout);
 
         // Try one more time after wakeup
-        legion = pop_from_local_queue(thread_id);
+        legion = queue_pop_locked(thread_id);
       }
 
       pthread_mutex_unlock(&g_scheduler->queue_mutex[thread_id]);
@@ -1575,26 +2756,23 @@ void *runtime_scheduler_run(void *arg) {
       legion->thread_id = thread_id;
       legion->state = LEGION_STATE_RUNNING;
 
-      // Set up context for legion execution
-      // It's already set up by spawn or previous yield
-
-      // Save scheduler context and switch to legion
-      Context *saved_ctx = &scheduler_ctx;
-      malphas_context_switch(saved_ctx, &legion->ctx);
-
-      // We return here when legion yields or completes
-      // The legion may have yielded, so check if it's still current
-      if (g_scheduler->current_legion[thread_id] == legion) {
-        g_scheduler->current_legion[thread_id] = NULL;
-        legion->thread_id = -1;
-
-        // If legion completed, it already decremented active_legions
-        // If it yielded, it should have been re-queued
-        if (legion->state == LEGION_STATE_DEAD) {
-          // Legion completed - nothing to do
-        } else if (legion->state == LEGION_STATE_RUNNABLE) {
-          // Legion yielded - already re-queued
-        }
+      // Switch to the legion, from its entry point or from where it last
+      // yielded or blocked
+      malphas_context_switch(&scheduler_ctx, &legion->ctx);
+
+      // We return here when the legion yields, blocks or completes, with its
+      // context saved and its stack no longer in use
+      g_scheduler->current_legion[thread_id] = NULL;
+      legion->thread_id = -1;
+
+      if (g_park_mutex) {
+        // The legion blocked on a channel: from here on a waker may take it
+        pthread_mutex_t *mutex = g_park_mutex;
+        g_park_mutex = NULL;
+        pthread_mutex_unlock(mutex);
+      } else if (legion->state == LEGION_STATE_RUNNABLE) {
+        // The legion yielded
+        enqueue_legion(legion);
       }
     } else if (!legion && atomic_load(&g_scheduler->active_legions) == 0) {
       // No active legions, sleep a bit
@@ -1624,3 +2802,175 @@ void runtime_scheduler_shutdown(void) {
     pthread_cond_destroy(&g_scheduler->queue_cond[i]);
   }
 }
+
+#else // MALPHAS_NO_LEGIONS
+
+// A single-threaded target has no legions: the compiler rejects spawn for
+// it, so the code running is always the main thread's, outside any legion.
+static _Noreturn void legions_unsupported(void) {
+  runtime_panic_cstr("spawn is not supported on this target: it has no threads");
+}
+
+Legion *runtime_get_current_legion(void) { return NULL; }
+
+void runtime_scheduler_init(void) {}
+
+Legion *runtime_legion_spawn(void (*fn)(void *), void *arg, uint64_t stack_size) {
+  (void)fn;
+  (void)arg;
+  (void)stack_size;
+  legions_unsupported();
+}
+
+void runtime_legion_start(Legion *legion) {
+  (void)legion;
+  legions_unsupported();
+}
+
+void runtime_legion_yield(void) { sched_yield(); }
+
+void runtime_legion_block(Legion *legion, Channel *channel) {
+  (void)legion;
+  (void)channel;
+  legions_unsupported();
+}
+
+static void unblock_legion_from_channel(Legion *legion) { (void)legion; }
+
+void runtime_legion_unblock(Legion *legion) { (void)legion; }
+
+void *runtime_scheduler_run(void *arg) {
+  (void)arg;
+  return NULL;
+}
+
+void runtime_scheduler_shutdown(void) {}
+#endif // MALPHAS_NO_LEGIONS
+
+// ============================================================================
+// Synchronization primitives (Mutex, RwLock, atomic integers)
+// ============================================================================
+// Locks are plain stdatomic words rather than pthread mutexes: a legion can be
+// resumed on a different OS thread than the one it locked on, and a pthread
+// mutex must be unlocked by the thread that owns it. Handles are GC-allocated
+// words passed to Malphas as int64_t.
+
+// Back off while waiting for a lock: yield to other legions when running in
+// one, otherwise give up the OS thread's time slice.
+static void sync_backoff(void) {
+  if (runtime_get_current_legion()) {
+    runtime_legion_yield();
+  } else {
+    sched_yield();
+  }
+}
+
+static _Atomic int64_t *sync_word(int64_t handle) {
+  return (_Atomic int64_t *)(intptr_t)handle;
+}
+
+static int64_t sync_word_new(int64_t value) {
+  _Atomic int64_t *word =
+      (_Atomic int64_t *)runtime_alloc(sizeof(_Atomic int64_t));
+  atomic_init(word, value);
+  return (int64_t)(intptr_t)word;
+}
+
+// Mutex: 0 = unlocked, 1 = locked
+int64_t runtime_mutex_new(void) { return sync_word_new(0); }
+
+int64_t runtime_mutex_try_lock(int64_t mutex) {
+  int64_t expected = 0;
+  return atomic_compare_exchange_strong(sync_word(mutex), &expected, 1);
+}
+
+void runtime_mutex_lock(int64_t mutex) {
+  while (!runtime_mutex_try_lock(mutex)) {
+    sync_backoff();
+  }
+}
+
+void runtime_mutex_unlock(int64_t mutex) {
+  atomic_store(sync_word(mutex), 0);
+}
+
+// RwLock: -1 = write-locked, otherwise the number of readers
+int64_t runtime_rwlock_new(void) { return sync_word_new(0); }
+
+void runtime_rwlock_read_lock(int64_t lock) {
+  _Atomic int64_t *word = sync_word(lock);
+  for (;;) {
+    int64_t readers = atomic_load(word);
+    if (readers >= 0 &&
+        atomic_compare_exchange_weak(word, &readers, readers + 1)) {
+      return;
+    }
+    sync_backoff();
+  }
+}
+
+void runtime_rwlock_read_unlock(int64_t lock) {
+  atomic_fetch_sub(sync_word(lock), 1);
+}
+
+void runtime_rwlock_write_lock(int64_t lock) {
+  for (;;) {
+    int64_t expected = 0;
+    if (atomic_compare_exchange_weak(sync_word(lock), &expected, -1)) {
+      return;
+    }
+    sync_backoff();
+  }
+}
+
+void runtime_rwlock_write_unlock(int64_t lock) {
+  atomic_store(sync_word(lock), 0);
+}
+
+// Atomic integers (sequentially consistent)
+int64_t runtime_atomic_new(int64_t value) { return sync_word_new(value); }
+
+int64_t runtime_atomic_load(int64_t atomic) {
+  return atomic_load(sync_word(atomic));
+}
+
+void runtime_atomic_store(int64_t atomic, int64_t value) {
+  atomic_store(sync_word(atomic), value);
+}
+
+int64_t runtime_atomic_fetch_add(int64_t atomic, int64_t delta) {
+  return atomic_fetch_add(sync_word(atomic), delta);
+}
+
+int64_t runtime_atomic_fetch_sub(int64_t atomic, int64_t delta) {
+  return atomic_fetch_sub(sync_word(atomic), delta);
+}
+
+int64_t runtime_atomic_swap(int64_t atomic, int64_t value) {
+  return atomic_exchange(sync_word(atomic), value);
+}
+
+// Stores desired if the current value equals expected; returns 1 on success
+int64_t runtime_atomic_compare_exchange(int64_t atomic, int64_t expected,
+                                        int64_t desired) {
+  return atomic_compare_exchange_strong(sync_word(atomic), &expected, desired);
+}
+
+// Join handles: a `spawn` expression hands back the Legion itself. The spawn
+// wrapper boxes the function's return value with runtime_legion_set_result
+// before the legion finishes.
+void runtime_legion_set_result(void *result) {
+  Legion *current = runtime_get_current_legion();
+  if (current) {
+    current->result = result;
+  }
+}
+
+// Wait for legion to finish and return its boxed result (NULL for void)
+void *runtime_legion_join(Legion *legion) {
+  while (__atomic_load_n(&legion->state, __ATOMIC_ACQUIRE) !=
+         LEGION_STATE_DEAD) {
+    sync_backoff();
+  }
+  return legion->result;
+}
@@ -9,20 +9,30 @@
 #include <stdio.h>
 #include <string.h>
 #include <stdlib.h>
+#ifndef MALPHAS_GC_NONE
 #include <gc/gc.h>  // Boehm GC
+#endif
+
+// ABI version of the runtime: the layout of its types and the signatures of
+// its functions as generated code uses them. Bump it together with
+// runtimeABIVersion in cmd/malphas/runtime.go whenever either changes.
+#define MALPHAS_RUNTIME_ABI_VERSION 1
+
+// Lengths and sizes are 64-bit on every target, as generated code passes
+// them as i64, rather than size_t, which is 32-bit on wasm32.
 
 // String type
 typedef struct {
-    size_t len;
+    uint64_t len;
     char* data;
 } String;
 
 // Slice type (generic, used for Vec)
 typedef struct {
     void* data;
-    size_t len;
-    size_t cap;
-    size_t elem_size;
+    uint64_t len;
+    uint64_t cap;
+    uint64_t elem_size;
 } Slice;
 
 // HashMap type (simplified)
@@ -30,6 +40,7 @@ typedef struct HashMap HashMap;
 
 // Channel type
 typedef struct Channel Channel;
+typedef struct SelectCase SelectCase;
 
 // Legion (user-level concurrent entity, spawned by spawn keyword) type
 // Named after the demonic host - many legions can run concurrently
@@ -38,11 +49,14 @@ typedef struct Legion Legion;
 // Garbage collector initialization
 void runtime_gc_init(void);
 
-// Memory allocation
-void* runtime_alloc(size_t size);
+// Memory allocation (Boehm GC, or a leak-at-exit arena with -DMALPHAS_GC_NONE)
+void* runtime_alloc(uint64_t size);
+void* runtime_realloc(void* ptr, uint64_t size);  // Grow a runtime_alloc block, keeping its contents
+void runtime_drop_register(void* obj, void (*drop)(void*));  // Run drop when the collector frees obj
+void runtime_drop_cancel(void* obj);  // obj was dropped at scope exit; forget its finalizer
 
 // String operations
-String* runtime_string_new(const char* data, size_t len);
+String* runtime_string_new(const char* data, uint64_t len);
 void runtime_string_free(String* s);
 const char* runtime_string_cstr(String* s);
 int runtime_string_equal(String* a, String* b);  // Returns 1 if equal, 0 otherwise
@@ -51,62 +65,148 @@ String* runtime_string_from_i64(int64_t value);  // Convert int64 to string
 String* runtime_string_from_double(double value);  // Convert double to string
 String* runtime_string_from_bool(int8_t value);  // Convert bool to string
 String* runtime_string_format(String* fmt, String* arg1, String* arg2, String* arg3, String* arg4);  // Format string with {} placeholders
+int64_t runtime_string_len(String* s);  // Length in bytes
+String* runtime_string_substring(String* s, int64_t start, int64_t end);  // Substring [start, end), clamped to bounds
+int64_t runtime_string_index_of(String* s, String* needle);  // Byte offset of needle, or -1
+int64_t runtime_string_compare(String* a, String* b);  // Lexicographic compare (<0, 0, >0)
+String* runtime_string_to_upper(String* s);  // ASCII uppercase copy
+String* runtime_string_to_lower(String* s);  // ASCII lowercase copy
+String* runtime_string_trim(String* s);  // Copy without leading/trailing whitespace
+int64_t runtime_string_builder_new(int64_t capacity);  // Growable string buffer handle
+void runtime_string_builder_append(int64_t builder, String* s);  // Append s, growing by doubling
+int64_t runtime_string_builder_len(int64_t builder);  // Bytes appended so far
+String* runtime_string_builder_build(int64_t builder);  // Copy of the contents
+void runtime_string_builder_clear(int64_t builder);  // Empty the buffer, keeping its capacity
 
 // Print functions
 void runtime_println_i64(int64_t value);
 void runtime_println_i32(int32_t value);
 void runtime_println_i8(int8_t value);
+void runtime_println_u64(uint64_t value);
+void runtime_println_u32(uint32_t value);
+void runtime_println_u16(uint16_t value);
+void runtime_println_u8(uint8_t value);
 void runtime_println_double(double value);
 void runtime_println_bool(int8_t value);  // i1 in LLVM, int8_t in C
 void runtime_println_string(String* s);
 
+// Panics (print the message and a backtrace, then exit with status 101, or
+// abort with --panic=abort)
+_Noreturn void runtime_panic(String* msg);  // Backs the panic(msg) builtin
+_Noreturn void runtime_panic_cstr(const char* msg);  // Panic with a C string message
+_Noreturn void runtime_index_panic(uint64_t len, int64_t index);  // Panic for an index not below len
+
+// Arithmetic traps (emitted with --overflow=panic|checked)
+void* runtime_optional_unwrap(void* value, String* msg);  // Return value, or panic with msg if it is NULL
+void runtime_panic_overflow(int32_t op);  // Report integer overflow for op (0=add, 1=sub, 2=mul, 3=div) and abort
+void runtime_report_main_error(String* msg);  // Print the Err returned from main (msg may be NULL)
+
+// Process arguments and environment
+void runtime_args_init(int32_t argc, char** argv);  // Called from the generated main
+int64_t runtime_args_count(void);  // Number of arguments, including the program path
+String* runtime_args_get(int64_t i);  // Argument i, or "" if out of range
+String** runtime_env_get(String* name);  // Boxed value (string?), or NULL if unset
+int64_t runtime_env_set(String* name, String* value);  // 0 on success, -1 on failure
+
+// Time
+int64_t runtime_time_monotonic_ns(void);  // Nanoseconds on a clock that never goes back, from an arbitrary start
+int64_t runtime_time_wall_ns(void);       // Nanoseconds since the Unix epoch
+void runtime_time_sleep_ns(int64_t nanoseconds);  // Sleep; a legion yields to the scheduler meanwhile
+
+// File I/O (int64_t results are >= 0 on success, -errno on failure)
+int64_t runtime_fs_open(String* path, int64_t mode);  // mode: 0=read, 1=write (create/truncate), 2=append; returns fd
+int64_t runtime_fs_close(int64_t fd);
+int64_t runtime_fs_write(int64_t fd, String* data);  // Writes all of data; returns bytes written
+String* runtime_fs_read_all(int64_t fd);  // Reads to EOF; "" and runtime_fs_last_error() set on failure
+int64_t runtime_fs_last_error(void);  // errno from the last string-returning fs call on this thread (0 if none)
+String* runtime_fs_strerror(int64_t code);  // Message for an errno value
+int64_t runtime_fs_reader_new(int64_t fd);  // Buffered line reader handle
+String** runtime_fs_reader_read_line(int64_t reader);  // Boxed line (string?), NULL at EOF or on error
+String* runtime_fs_reader_read(int64_t reader, int64_t max);  // Up to max bytes ("" at end of input)
+
+// TCP networking (sockets are non-blocking fds usable with runtime_fs_*)
+int64_t runtime_net_listen(String* host, int64_t port);
+int64_t runtime_net_accept(int64_t listener);
+int64_t runtime_net_connect(String* host, int64_t port);
+int64_t runtime_net_local_port(int64_t fd);
+
+// Synchronization (handles are opaque int64_t values; *_try_lock and
+// compare_exchange return 1 on success, 0 otherwise)
+int64_t runtime_mutex_new(void);
+int64_t runtime_mutex_try_lock(int64_t mutex);
+void runtime_mutex_lock(int64_t mutex);
+void runtime_mutex_unlock(int64_t mutex);
+int64_t runtime_rwlock_new(void);
+void runtime_rwlock_read_lock(int64_t lock);
+void runtime_rwlock_read_unlock(int64_t lock);
+void runtime_rwlock_write_lock(int64_t lock);
+void runtime_rwlock_write_unlock(int64_t lock);
+int64_t runtime_atomic_new(int64_t value);
+int64_t runtime_atomic_load(int64_t atomic);
+void runtime_atomic_store(int64_t atomic, int64_t value);
+int64_t runtime_atomic_fetch_add(int64_t atomic, int64_t delta);
+int64_t runtime_atomic_fetch_sub(int64_t atomic, int64_t delta);
+int64_t runtime_atomic_swap(int64_t atomic, int64_t value);
+int64_t runtime_atomic_compare_exchange(int64_t atomic, int64_t expected, int64_t desired);
+
 // Slice operations (for Vec)
-Slice* runtime_slice_new(size_t elem_size, size_t len, size_t cap);
-void* runtime_slice_get(Slice* slice, size_t index);
-void runtime_slice_set(Slice* slice, size_t index, void* value);
+Slice* runtime_slice_new(uint64_t elem_size, uint64_t len, uint64_t cap);
+void* runtime_slice_get(Slice* slice, uint64_t index);
+void runtime_slice_set(Slice* slice, uint64_t index, void* value);
 void runtime_slice_push(Slice* slice, void* value);
-size_t runtime_slice_len(Slice* slice);
+uint64_t runtime_slice_len(Slice* slice);
 int8_t runtime_slice_is_empty(Slice* slice);  // Returns 1 if empty, 0 otherwise
-size_t runtime_slice_cap(Slice* slice);  // Get capacity
-void runtime_slice_reserve(Slice* slice, size_t additional);  // Reserve additional capacity
+uint64_t runtime_slice_cap(Slice* slice);  // Get capacity
+void runtime_slice_reserve(Slice* slice, uint64_t additional);  // Reserve additional capacity
 void runtime_slice_clear(Slice* slice);  // Clear all elements (set len to 0)
 void* runtime_slice_pop(Slice* slice);  // Remove and return last element (returns NULL if empty)
-void runtime_slice_remove(Slice* slice, size_t index);  // Remove element at index
-void runtime_slice_insert(Slice* slice, size_t index, void* value);  // Insert element at index
+void runtime_slice_remove(Slice* slice, uint64_t index);  // Remove element at index
+void runtime_slice_insert(Slice* slice, uint64_t index, void* value);  // Insert element at index
 Slice* runtime_slice_copy(Slice* slice);  // Create a copy of the slice
-Slice* runtime_slice_subslice(Slice* slice, size_t start, size_t end);  // Create sub-slice [start:end)
+Slice* runtime_slice_subslice(Slice* slice, uint64_t start, uint64_t end);  // View of elements [start:end), sharing the original's storage
 
 // HashMap operations
-HashMap* runtime_hashmap_new(void);
-void runtime_hashmap_put(HashMap* map, String* key, void* value);
-void* runtime_hashmap_get(HashMap* map, String* key);
-int8_t runtime_hashmap_contains_key(HashMap* map, String* key);  // Returns 1 if key exists, 0 otherwise
-size_t runtime_hashmap_len(HashMap* map);  // Returns the number of key-value pairs
+// Keys and values are passed by pointer and copied into the map. With
+// string_keys set, keys are String* compared by content.
+HashMap* runtime_hashmap_new(uint64_t key_size, uint64_t value_size, int8_t string_keys);
+void runtime_hashmap_put(HashMap* map, void* key, void* value);
+void* runtime_hashmap_get(HashMap* map, void* key);  // Pointer to the value, or NULL if key is absent
+int8_t runtime_hashmap_contains_key(HashMap* map, void* key);  // Returns 1 if key exists, 0 otherwise
+void* runtime_hashmap_remove(HashMap* map, void* key);  // Removes key, returning its value or NULL if absent
+uint64_t runtime_hashmap_len(HashMap* map);  // Returns the number of key-value pairs
 int8_t runtime_hashmap_is_empty(HashMap* map);  // Returns 1 if empty, 0 otherwise
+Slice* runtime_hashmap_keys(HashMap* map);  // Keys in insertion order
+Slice* runtime_hashmap_values(HashMap* map);  // Values in insertion order
 void runtime_hashmap_free(HashMap* map);
 
 // Channel operations
-Channel* runtime_channel_new(size_t elem_size, size_t capacity);  // Create a new channel
-void runtime_channel_send(Channel* ch, void* value);  // Send a value to channel (blocks if full)
-void* runtime_channel_recv(Channel* ch);  // Receive a value from channel (blocks if empty)
-void runtime_channel_close(Channel* ch);  // Close the channel
+Channel* runtime_channel_new(uint64_t elem_size, uint64_t capacity);  // Create a new channel
+void runtime_channel_send(Channel* ch, void* value);  // Send a copy of the elem_size bytes at value (blocks if full)
+void* runtime_channel_recv(Channel* ch);  // Receive a value from channel (blocks if empty); NULL once closed and drained
+void* runtime_channel_recv_or_zero(Channel* ch);  // As runtime_channel_recv, but a zeroed element once closed and drained
+void runtime_channel_close(Channel* ch);  // Close the channel (panics if already closed)
 int8_t runtime_channel_is_closed(Channel* ch);  // Returns 1 if closed, 0 otherwise
 int8_t runtime_channel_try_send(Channel* ch, void* value);  // Try to send (non-blocking), returns 1 if successful, 0 if would block
 int8_t runtime_channel_try_recv(Channel* ch, void** value);  // Try to receive (non-blocking), returns 1 if successful, 0 if would block
 void runtime_channel_wait_for_send(Channel* ch);  // Wait on condition variable for send to become possible (must hold mutex)
 void runtime_channel_wait_for_recv(Channel* ch);  // Wait on condition variable for recv to become possible (must hold mutex)
-void runtime_nanosleep(long nanoseconds);  // Sleep for specified nanoseconds (for select polling with timeout)
+int64_t runtime_select(SelectCase* cases, int64_t n, int8_t has_default, int64_t timeout_ms);  // Run one ready select case; returns its index, -1 for default or -2 on timeout
+void runtime_nanosleep(int64_t nanoseconds);  // Sleep for specified nanoseconds
 
 // Legion and scheduler operations
 void runtime_scheduler_init(void);  // Initialize the infernal scheduler (call once at startup)
-Legion* runtime_legion_spawn(void (*fn)(void*), void* arg, size_t stack_size);  // Spawn a new legion (from spawn keyword)
+Legion* runtime_legion_spawn(void (*fn)(void*), void* arg, uint64_t stack_size);  // Spawn a new legion (from spawn keyword)
 void runtime_legion_start(Legion* legion);  // Start a legion (add to scheduler)
+void* runtime_spawn_args_alloc(uint64_t size);  // Allocate the arguments of a spawn, owned by the spawned legion
+void runtime_spawn_args_free(void* args);  // Free spawn arguments once the spawn wrapper has unpacked them
 void runtime_legion_yield(void);  // Yield control to scheduler (cooperative)
 void* runtime_scheduler_run(void* arg);  // Run the infernal scheduler (called by OS threads)
 void runtime_scheduler_shutdown(void);  // Shutdown scheduler (wait for all legions to complete)
 Legion* runtime_get_current_legion(void);  // Get the currently running legion (NULL if not in legion context)
 void runtime_legion_block(Legion* legion, Channel* channel);  // Block a legion on a channel
 void runtime_legion_unblock(Legion* legion);  // Unblock a legion
+void runtime_legion_set_result(void* result);  // Box the current legion's return value (spawn wrapper)
+void* runtime_legion_join(Legion* legion);  // Wait for a legion to finish; returns its boxed result
 
 #endif // RUNTIME_H
 